@@ -0,0 +1,474 @@
+// account manages user accounts in the database configured in config.json
+// in the working directory, for operators without psql at hand.
+//
+// Usage:
+//
+//	account create hunter                  # Create an account, reading the password from stdin
+//	account create --rights HL,EX hunter   # Create it with courses
+//	account passwd hunter                  # Reset the password, read from stdin
+//	account rights hunter                  # Show the account's rights
+//	account rights hunter +NetCafe,-EX     # Add and remove courses
+//	account rights hunter 12               # Set the rights bitmask
+//	account ban --for 7d hunter            # Ban for a week; permanently without --for
+//	account unban hunter
+//	account chars hunter                   # List the account's characters
+//
+// Passwords are read from the first line of standard input, so they stay
+// out of the shell history. Every change, bans included, is recorded in the
+// audit log. A ban takes effect on the next login; use
+// the in-game or Discord commands to disconnect a player who is online.
+package main
+
+import (
+	"bufio"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"erupe-ce/common/mhfcourse"
+	cfg "erupe-ce/config"
+	"erupe-ce/server/audit"
+	"erupe-ce/server/channelserver"
+
+	"github.com/jmoiron/sqlx"
+	_ "github.com/lib/pq"
+	"go.uber.org/zap"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(1)
+	}
+	switch os.Args[1] {
+	case "create":
+		runCreate(os.Args[2:])
+	case "passwd":
+		runPasswd(os.Args[2:])
+	case "rights":
+		runRights(os.Args[2:])
+	case "ban":
+		runBan(os.Args[2:])
+	case "unban":
+		runUnban(os.Args[2:])
+	case "chars":
+		runChars(os.Args[2:])
+	case "-h", "--help", "help":
+		usage()
+	default:
+		fmt.Fprintf(os.Stderr, "error: unknown command %q\n", os.Args[1])
+		usage()
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, `Usage: account <command> [flags] USERNAME [args]
+
+Commands:
+  create USERNAME          Create an account
+  passwd USERNAME          Reset an account's password
+  rights USERNAME [RIGHTS] Show or change an account's rights
+  ban USERNAME             Ban an account
+  unban USERNAME           Lift an account's ban
+  chars USERNAME           List an account's characters
+
+RIGHTS is a bitmask, a comma separated list of courses such as HL,EX, or
+courses to add or remove such as +NetCafe,-EX.
+Run account <command> -h for the command's flags.`)
+}
+
+func runCreate(args []string) {
+	fs := flag.NewFlagSet("create", flag.ExitOnError)
+	rights := fs.String("rights", "", "Rights to give the account instead of the database default")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: account create [flags] USERNAME < password")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(1)
+	}
+	username := fs.Arg(0)
+	var mask uint32
+	if *rights != "" {
+		var err error
+		if mask, err = parseRights(*rights, 0); err != nil {
+			fatalf("--rights: %v", err)
+		}
+	}
+	hash := readPassword()
+
+	db := openDB()
+	defer func() { _ = db.Close() }()
+	if _, err := lookupUser(db, username); err == nil {
+		fatalf("account %q already exists", username)
+	}
+	var id uint32
+	err := db.QueryRow(`INSERT INTO users (username, password, return_expires) VALUES ($1, $2, $3) RETURNING id`,
+		username, string(hash), time.Now().Add(time.Hour*24*30)).Scan(&id)
+	if err != nil {
+		fatalf("create account: %v", err)
+	}
+	if *rights != "" {
+		if err := channelserver.NewUserRepository(db).SetRights(id, mask); err != nil {
+			fatalf("set rights: %v", err)
+		}
+	}
+	record(db, "account:create", id, map[string]any{"username": username, "rights": *rights})
+	fmt.Printf("Created account %q with ID %d\n", username, id)
+}
+
+func runPasswd(args []string) {
+	fs := flag.NewFlagSet("passwd", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: account passwd USERNAME < password")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(1)
+	}
+	hash := readPassword()
+
+	db := openDB()
+	defer func() { _ = db.Close() }()
+	id := mustLookupUser(db, fs.Arg(0))
+	if _, err := db.Exec(`UPDATE users SET password=$1 WHERE id=$2`, string(hash), id); err != nil {
+		fatalf("set password: %v", err)
+	}
+	record(db, "account:passwd", id, nil)
+	fmt.Printf("Reset the password of %q\n", fs.Arg(0))
+}
+
+func runRights(args []string) {
+	fs := flag.NewFlagSet("rights", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: account rights USERNAME [RIGHTS]")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+	if fs.NArg() != 1 && fs.NArg() != 2 {
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	db := openDB()
+	defer func() { _ = db.Close() }()
+	id := mustLookupUser(db, fs.Arg(0))
+	repo := channelserver.NewUserRepository(db)
+	current, err := repo.GetRights(id)
+	if err != nil {
+		fatalf("get rights: %v", err)
+	}
+	if fs.NArg() == 1 {
+		fmt.Printf("%s: %s\n", fs.Arg(0), describeRights(current))
+		return
+	}
+	rights, err := parseRights(fs.Arg(1), current)
+	if err != nil {
+		fatalf("%v", err)
+	}
+	if err := repo.SetRights(id, rights); err != nil {
+		fatalf("set rights: %v", err)
+	}
+	record(db, "account:rights", id, map[string]any{"from": current, "to": rights})
+	fmt.Printf("%s: %s\n", fs.Arg(0), describeRights(rights))
+}
+
+func runBan(args []string) {
+	fs := flag.NewFlagSet("ban", flag.ExitOnError)
+	length := fs.String("for", "", "How long to ban for, such as 12h, 7d, 3mo or 1y; permanently if empty")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: account ban [flags] USERNAME")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(1)
+	}
+	var expires *time.Time
+	if *length != "" {
+		d, err := parseLength(*length)
+		if err != nil {
+			fatalf("--for: %v", err)
+		}
+		t := time.Now().Add(d)
+		expires = &t
+	}
+
+	db := openDB()
+	defer func() { _ = db.Close() }()
+	id := mustLookupUser(db, fs.Arg(0))
+	if err := moderation(db).Ban(id, expires, channelserver.ModerationSourceCLI, audit.CLIActor("account")); err != nil {
+		fatalf("ban: %v", err)
+	}
+	if expires == nil {
+		fmt.Printf("Banned %q permanently\n", fs.Arg(0))
+		return
+	}
+	fmt.Printf("Banned %q until %s\n", fs.Arg(0), expires.Format(time.DateTime))
+}
+
+func runUnban(args []string) {
+	fs := flag.NewFlagSet("unban", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: account unban USERNAME")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	db := openDB()
+	defer func() { _ = db.Close() }()
+	id := mustLookupUser(db, fs.Arg(0))
+	if err := moderation(db).Unban(id, channelserver.ModerationSourceCLI, audit.CLIActor("account")); err != nil {
+		fatalf("unban: %v", err)
+	}
+	fmt.Printf("Lifted any ban on %q\n", fs.Arg(0))
+}
+
+// character is a row of the chars listing.
+type character struct {
+	ID        uint32 `db:"id"`
+	Name      string `db:"name"`
+	HR        uint16 `db:"hr"`
+	GR        uint16 `db:"gr"`
+	LastLogin int64  `db:"last_login"`
+	Deleted   bool   `db:"deleted"`
+}
+
+func runChars(args []string) {
+	fs := flag.NewFlagSet("chars", flag.ExitOnError)
+	all := fs.Bool("all", false, "Include deleted characters")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: account chars [flags] USERNAME")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	db := openDB()
+	defer func() { _ = db.Close() }()
+	id := mustLookupUser(db, fs.Arg(0))
+	var chars []character
+	err := db.Select(&chars, `SELECT id, COALESCE(name, '') AS name, COALESCE(hr, 0) AS hr, COALESCE(gr, 0) AS gr,
+		COALESCE(last_login, 0) AS last_login, deleted
+		FROM characters WHERE user_id=$1 AND ($2 OR NOT deleted) ORDER BY id`, id, *all)
+	if err != nil {
+		fatalf("list characters: %v", err)
+	}
+	writeChars(os.Stdout, chars)
+}
+
+// writeChars prints characters as a table.
+func writeChars(w io.Writer, chars []character) {
+	if len(chars) == 0 {
+		_, _ = fmt.Fprintln(w, "No characters")
+		return
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "ID\tNAME\tHR\tGR\tLAST LOGIN\t")
+	for _, c := range chars {
+		login := "never"
+		if c.LastLogin > 0 {
+			login = time.Unix(c.LastLogin, 0).Format(time.DateTime)
+		}
+		name := c.Name
+		if c.Deleted {
+			name += " (deleted)"
+		}
+		_, _ = fmt.Fprintf(tw, "%d\t%s\t%d\t%d\t%s\t\n", c.ID, name, c.HR, c.GR, login)
+	}
+	_ = tw.Flush()
+}
+
+// parseRights parses a rights bitmask, a comma separated list of courses, or
+// courses prefixed with + or - to add to or remove from current.
+func parseRights(s string, current uint32) (uint32, error) {
+	if n, err := strconv.ParseUint(s, 10, 32); err == nil {
+		return uint32(n), nil
+	}
+	var set, add, remove uint32
+	absolute := false
+	for _, name := range strings.Split(s, ",") {
+		name = strings.TrimSpace(name)
+		op := byte(0)
+		if name != "" && (name[0] == '+' || name[0] == '-') {
+			op, name = name[0], name[1:]
+		}
+		course, ok := mhfcourse.FindCourse(name)
+		if !ok {
+			return 0, fmt.Errorf("unknown course %q", name)
+		}
+		switch op {
+		case '+':
+			add |= course.Value()
+		case '-':
+			remove |= course.Value()
+		default:
+			set |= course.Value()
+			absolute = true
+		}
+	}
+	if absolute {
+		if add != 0 || remove != 0 {
+			return 0, errors.New("mix of courses to set and to add or remove")
+		}
+		return set, nil
+	}
+	return current&^remove | add, nil
+}
+
+// describeRights formats a rights bitmask with the courses it holds.
+func describeRights(rights uint32) string {
+	var names []string
+	for _, course := range mhfcourse.Courses() {
+		if rights&course.Value() == 0 {
+			continue
+		}
+		if aliases := course.Aliases(); len(aliases) > 0 {
+			names = append(names, aliases[0])
+		} else {
+			names = append(names, fmt.Sprintf("course %d", course.ID))
+		}
+	}
+	if len(names) == 0 {
+		return fmt.Sprintf("%d (no courses)", rights)
+	}
+	return fmt.Sprintf("%d (%s)", rights, strings.Join(names, ", "))
+}
+
+// parseLength parses a ban length as the in-game ban command does: a number
+// followed by s, m, h, d, mo or y.
+func parseLength(s string) (time.Duration, error) {
+	i := strings.IndexFunc(s, func(r rune) bool { return r < '0' || r > '9' })
+	if i <= 0 {
+		return 0, fmt.Errorf("bad length %q", s)
+	}
+	n, err := strconv.Atoi(s[:i])
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("bad length %q", s)
+	}
+	var unit time.Duration
+	switch s[i:] {
+	case "s":
+		unit = time.Second
+	case "m":
+		unit = time.Minute
+	case "h":
+		unit = time.Hour
+	case "d":
+		unit = time.Hour * 24
+	case "mo":
+		unit = time.Hour * 24 * 30
+	case "y":
+		unit = time.Hour * 24 * 365
+	default:
+		return 0, fmt.Errorf("bad length %q, use s, m, h, d, mo or y", s)
+	}
+	return time.Duration(n) * unit, nil
+}
+
+// readPassword reads a password from the first line of standard input and
+// returns its hash, prompting for it when standard input is a terminal.
+func readPassword() []byte {
+	if fi, err := os.Stdin.Stat(); err == nil && fi.Mode()&os.ModeCharDevice != 0 {
+		fmt.Fprint(os.Stderr, "Password: ")
+	}
+	password, err := firstLine(os.Stdin)
+	if err != nil {
+		fatalf("read password: %v", err)
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		fatalf("hash password: %v", err)
+	}
+	return hash
+}
+
+// firstLine returns the first line of r without its line ending, refusing
+// an empty one.
+func firstLine(r io.Reader) (string, error) {
+	line, err := bufio.NewReader(r).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", err
+	}
+	line = strings.TrimRight(line, "\r\n")
+	if line == "" {
+		return "", errors.New("empty password")
+	}
+	return line, nil
+}
+
+// openDB connects to the database in config.json.
+func openDB() *sqlx.DB {
+	config, err := cfg.LoadConfig()
+	if err != nil {
+		fatalf("load config: %v", err)
+	}
+	db, err := sqlx.Open("postgres", fmt.Sprintf(
+		"host='%s' port='%d' user='%s' password='%s' dbname='%s' sslmode=disable",
+		config.Database.Host, config.Database.Port, config.Database.User,
+		config.Database.Password, config.Database.Database,
+	))
+	if err != nil {
+		fatalf("%v", err)
+	}
+	if err := db.Ping(); err != nil {
+		_ = db.Close()
+		fatalf("connect to database: %v", err)
+	}
+	return db
+}
+
+func lookupUser(db *sqlx.DB, username string) (uint32, error) {
+	var id uint32
+	err := db.QueryRow(`SELECT id FROM users WHERE username=$1`, username).Scan(&id)
+	return id, err
+}
+
+func mustLookupUser(db *sqlx.DB, username string) uint32 {
+	id, err := lookupUser(db, username)
+	if errors.Is(err, sql.ErrNoRows) {
+		fatalf("no account named %q", username)
+	} else if err != nil {
+		fatalf("look up account: %v", err)
+	}
+	return id
+}
+
+func moderation(db *sqlx.DB) *channelserver.ModerationService {
+	return channelserver.NewModerationService(channelserver.NewUserRepository(db),
+		channelserver.NewModerationRepository(db), zap.NewNop())
+}
+
+// record writes an audit log entry, warning rather than failing since the
+// change has been made.
+func record(db *sqlx.DB, action string, userID uint32, params any) {
+	if err := audit.NewRepository(db).Record(audit.SourceCLI, audit.CLIActor("account"), action, strconv.FormatUint(uint64(userID), 10), params); err != nil {
+		fmt.Fprintf(os.Stderr, "warning: failed to record audit log entry: %v\n", err)
+	}
+}
+
+func fatalf(format string, args ...any) {
+	fmt.Fprintf(os.Stderr, "error: "+format+"\n", args...)
+	os.Exit(1)
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseRights(t *testing.T) {
+	// HunterLife is course 2 and Extra course 3; NetCafe is course 26.
+	tests := []struct {
+		in      string
+		current uint32
+		want    uint32
+	}{
+		{"12", 0xFF, 12},
+		{"HL,EX", 0xFF, 1<<2 | 1<<3},
+		{"hl", 0, 1 << 2},
+		{"+NetCafe", 1 << 2, 1<<2 | 1<<26},
+		{"+NetCafe,-HL", 1<<2 | 1<<3, 1<<3 | 1<<26},
+		{"-EX", 1 << 2, 1 << 2},
+	}
+	for _, tt := range tests {
+		got, err := parseRights(tt.in, tt.current)
+		if err != nil || got != tt.want {
+			t.Errorf("parseRights(%q, %d) = %d, %v, want %d", tt.in, tt.current, got, err, tt.want)
+		}
+	}
+	for _, bad := range []string{"Gold", "HL,+EX", "", "-"} {
+		if _, err := parseRights(bad, 0); err == nil {
+			t.Errorf("parseRights(%q) succeeded", bad)
+		}
+	}
+}
+
+func TestDescribeRights(t *testing.T) {
+	if got := describeRights(1<<2 | 1<<3); got != "12 (HunterLife, Extra)" {
+		t.Errorf("describeRights = %q", got)
+	}
+	if got := describeRights(1 << 14); got != "16384 (course 14)" {
+		t.Errorf("describeRights of an unnamed course = %q", got)
+	}
+	if got := describeRights(0); got != "0 (no courses)" {
+		t.Errorf("describeRights(0) = %q", got)
+	}
+}
+
+func TestParseLength(t *testing.T) {
+	tests := map[string]time.Duration{
+		"30s": 30 * time.Second,
+		"5m":  5 * time.Minute,
+		"12h": 12 * time.Hour,
+		"7d":  7 * 24 * time.Hour,
+		"3mo": 90 * 24 * time.Hour,
+		"1y":  365 * 24 * time.Hour,
+	}
+	for in, want := range tests {
+		if got, err := parseLength(in); err != nil || got != want {
+			t.Errorf("parseLength(%q) = %v, %v, want %v", in, got, err, want)
+		}
+	}
+	for _, bad := range []string{"", "d", "7", "7w", "0d", "-1d"} {
+		if _, err := parseLength(bad); err == nil {
+			t.Errorf("parseLength(%q) succeeded", bad)
+		}
+	}
+}
+
+func TestFirstLine(t *testing.T) {
+	for in, want := range map[string]string{
+		"hunter2\n":         "hunter2",
+		"hunter2\r\nrest\n": "hunter2",
+		"hunter2":           "hunter2",
+	} {
+		if got, err := firstLine(strings.NewReader(in)); err != nil || got != want {
+			t.Errorf("firstLine(%q) = %q, %v", in, got, err)
+		}
+	}
+	if _, err := firstLine(strings.NewReader("\n")); err == nil {
+		t.Error("firstLine accepted an empty password")
+	}
+}
+
+func TestWriteChars(t *testing.T) {
+	var sb strings.Builder
+	writeChars(&sb, []character{
+		{ID: 7, Name: "Hunter", HR: 999, GR: 50, LastLogin: time.Date(2026, 1, 2, 3, 4, 5, 0, time.Local).Unix()},
+		{ID: 9, Name: "Alt", Deleted: true},
+	})
+	out := sb.String()
+	for _, want := range []string{"Hunter", "2026-01-02 03:04:05", "Alt (deleted)", "never"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("writeChars output missing %q:\n%s", want, out)
+		}
+	}
+	sb.Reset()
+	writeChars(&sb, nil)
+	if sb.String() != "No characters\n" {
+		t.Errorf("writeChars(nil) = %q", sb.String())
+	}
+}
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// format is the archive layout this build writes and reads. It only
+// changes when the layout below does.
+const format = 1
+
+const (
+	manifestName = "manifest.json"
+	databaseName = "database.pgdump"
+	configName   = "config.json"
+	filesPrefix  = "files/"
+)
+
+// The parts of a server an archive can hold. Directory parts are stored
+// under files/<part>/.
+const (
+	partDB          = "db"
+	partConfig      = "config"
+	partBin         = "bin"
+	partScreenshots = "screenshots"
+	partSaveDumps   = "savedumps"
+)
+
+var allParts = []string{partDB, partConfig, partBin, partScreenshots, partSaveDumps}
+
+// manifest describes an archive. It is written last, so an archive without
+// one was cut short.
+type manifest struct {
+	Format        int            `json:"format"`
+	Created       time.Time      `json:"created"`
+	SchemaVersion int            `json:"schema_version"` // Last migration applied to the dumped database
+	Parts         []string       `json:"parts"`
+	Files         map[string]int `json:"files,omitempty"` // Files stored for each directory part
+}
+
+// check reports whether a build whose newest migration is latest can
+// restore the archive. Older schemas are brought up to date by the server
+// on its next start; newer ones would be missing from this build.
+func (m manifest) check(latest int) error {
+	if m.Format != format {
+		return fmt.Errorf("archive format %d is not supported, this build reads format %d", m.Format, format)
+	}
+	if slices.Contains(m.Parts, partDB) && m.SchemaVersion > latest {
+		return fmt.Errorf("database is at schema version %d, newer than this build's %d; upgrade Erupe first", m.SchemaVersion, latest)
+	}
+	return nil
+}
+
+// archiveWriter writes a full backup archive.
+type archiveWriter struct {
+	gz       *gzip.Writer
+	tw       *tar.Writer
+	manifest manifest
+}
+
+func newArchiveWriter(w io.Writer, created time.Time) *archiveWriter {
+	gz := gzip.NewWriter(w)
+	return &archiveWriter{
+		gz:       gz,
+		tw:       tar.NewWriter(gz),
+		manifest: manifest{Format: format, Created: created, Files: map[string]int{}},
+	}
+}
+
+// addFile stores the file at src as name.
+func (a *archiveWriter) addFile(name, src string) error {
+	f, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = f.Close() }()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	hdr := &tar.Header{Name: name, Mode: 0644, Size: info.Size(), ModTime: info.ModTime(), Typeflag: tar.TypeReg}
+	if err := a.tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	if _, err := io.Copy(a.tw, f); err != nil {
+		return fmt.Errorf("%s: %w", src, err)
+	}
+	return nil
+}
+
+// addDir stores every regular file under dir as the given directory part.
+// A missing directory is stored as an empty one.
+func (a *archiveWriter) addDir(part, dir string) error {
+	a.manifest.Parts = append(a.manifest.Parts, part)
+	a.manifest.Files[part] = 0
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) && p == dir {
+				return fs.SkipDir
+			}
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		if err := a.addFile(filesPrefix+part+"/"+filepath.ToSlash(rel), p); err != nil {
+			return err
+		}
+		a.manifest.Files[part]++
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", part, err)
+	}
+	return nil
+}
+
+// close writes the manifest and flushes the archive.
+func (a *archiveWriter) close() error {
+	data, err := json.MarshalIndent(a.manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	hdr := &tar.Header{Name: manifestName, Mode: 0644, Size: int64(len(data)), ModTime: a.manifest.Created, Typeflag: tar.TypeReg}
+	if err := a.tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	if _, err := a.tw.Write(data); err != nil {
+		return err
+	}
+	if err := a.tw.Close(); err != nil {
+		return err
+	}
+	return a.gz.Close()
+}
+
+// readArchive reads the archive at p, passing every entry but the
+// manifest to fn when it is not nil, and returns the manifest. Entry names
+// are checked before fn sees them, so none escapes the directory it is
+// restored to.
+func readArchive(p string, fn func(name string, r io.Reader) error) (manifest, error) {
+	var m manifest
+	f, err := os.Open(p)
+	if err != nil {
+		return m, err
+	}
+	defer func() { _ = f.Close() }()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return m, err
+	}
+	tr := tar.NewReader(gz)
+	found := false
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return m, err
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		if !filepath.IsLocal(hdr.Name) || path.Clean(hdr.Name) != hdr.Name {
+			return m, fmt.Errorf("%s: unsafe entry name", hdr.Name)
+		}
+		if hdr.Name == manifestName {
+			if err := json.NewDecoder(tr).Decode(&m); err != nil {
+				return m, fmt.Errorf("%s: %w", hdr.Name, err)
+			}
+			found = true
+			continue
+		}
+		if fn != nil {
+			if err := fn(hdr.Name, tr); err != nil {
+				return m, fmt.Errorf("%s: %w", hdr.Name, err)
+			}
+		}
+	}
+	if !found {
+		return m, errors.New("archive has no manifest, it was cut short")
+	}
+	return m, nil
+}
+
+// splitFileEntry returns the directory part and path within it of an
+// entry stored by addDir.
+func splitFileEntry(name string) (part, rel string, ok bool) {
+	rest, ok := strings.CutPrefix(name, filesPrefix)
+	if !ok {
+		return "", "", false
+	}
+	return strings.Cut(rest, "/")
+}
+
+// writeFile copies r to dst, creating its directory.
+func writeFile(dst string, r io.Reader) error {
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// parseParts reads a comma separated list of parts, all of them when empty.
+func parseParts(s string) ([]string, error) {
+	if s == "" {
+		return allParts, nil
+	}
+	var parts []string
+	for _, p := range strings.Split(s, ",") {
+		p = strings.TrimSpace(p)
+		if !slices.Contains(allParts, p) {
+			return nil, fmt.Errorf("unknown part %q, want one of %s", p, strings.Join(allParts, ", "))
+		}
+		if !slices.Contains(parts, p) {
+			parts = append(parts, p)
+		}
+	}
+	return parts, nil
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestArchiveRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	bin := filepath.Join(dir, "bin")
+	for name, data := range map[string]string{"quests/23045d0.bin": "quest", "events/1.bin": "event"} {
+		if err := writeFile(filepath.Join(bin, filepath.FromSlash(name)), strings.NewReader(data)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	config := filepath.Join(dir, "config.json")
+	if err := os.WriteFile(config, []byte(`{"Host": ""}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	name := filepath.Join(dir, "full.tar.gz")
+	f, err := os.Create(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	created := time.Date(2026, 10, 17, 3, 0, 0, 0, time.UTC)
+	a := newArchiveWriter(f, created)
+	if err := a.addFile(configName, config); err != nil {
+		t.Fatal(err)
+	}
+	a.manifest.Parts = append(a.manifest.Parts, partConfig)
+	if err := a.addDir(partBin, bin); err != nil {
+		t.Fatal(err)
+	}
+	// A directory that was never created is archived empty.
+	if err := a.addDir(partScreenshots, filepath.Join(dir, "missing")); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.close(); err != nil {
+		t.Fatal(err)
+	}
+	_ = f.Close()
+
+	entries := map[string]string{}
+	m, err := readArchive(name, func(entry string, r io.Reader) error {
+		data, err := io.ReadAll(r)
+		entries[entry] = string(data)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Format != format || !m.Created.Equal(created) || m.Files[partBin] != 2 || m.Files[partScreenshots] != 0 {
+		t.Errorf("manifest = %+v", m)
+	}
+	if got := strings.Join(m.Parts, ","); got != "config,bin,screenshots" {
+		t.Errorf("Parts = %s", got)
+	}
+	want := map[string]string{
+		configName:                     `{"Host": ""}`,
+		"files/bin/quests/23045d0.bin": "quest",
+		"files/bin/events/1.bin":       "event",
+	}
+	if len(entries) != len(want) {
+		t.Errorf("entries = %v", entries)
+	}
+	for entry, data := range want {
+		if entries[entry] != data {
+			t.Errorf("%s = %q, want %q", entry, entries[entry], data)
+		}
+	}
+}
+
+// writeTar writes a gzipped tar holding the given entries in order.
+func writeTar(t *testing.T, entries ...string) string {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for _, name := range entries {
+		data := []byte("{}")
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(data)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatal(err)
+		}
+		_, _ = tw.Write(data)
+	}
+	_ = tw.Close()
+	_ = gz.Close()
+	name := filepath.Join(t.TempDir(), "archive.tar.gz")
+	if err := os.WriteFile(name, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return name
+}
+
+func TestReadArchive_Errors(t *testing.T) {
+	tests := []struct {
+		entries []string
+		want    string
+	}{
+		{[]string{configName}, "cut short"},
+		{[]string{"files/bin/../../etc/passwd", manifestName}, "unsafe entry name"},
+		{[]string{"/etc/passwd", manifestName}, "unsafe entry name"},
+	}
+	for _, tt := range tests {
+		_, err := readArchive(writeTar(t, tt.entries...), func(string, io.Reader) error { return nil })
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("readArchive(%v) = %v, want %q", tt.entries, err, tt.want)
+		}
+	}
+}
+
+func TestManifestCheck(t *testing.T) {
+	tests := []struct {
+		m    manifest
+		want string
+	}{
+		{manifest{Format: format, Parts: []string{partDB}, SchemaVersion: 12}, ""},
+		{manifest{Format: format, Parts: []string{partDB}, SchemaVersion: 3}, ""},
+		{manifest{Format: format, Parts: []string{partDB}, SchemaVersion: 13}, "upgrade Erupe first"},
+		{manifest{Format: format, Parts: []string{partBin}, SchemaVersion: 13}, ""},
+		{manifest{Format: format + 1}, "not supported"},
+	}
+	for _, tt := range tests {
+		err := tt.m.check(12)
+		if tt.want == "" && err != nil || tt.want != "" && (err == nil || !strings.Contains(err.Error(), tt.want)) {
+			t.Errorf("check(%+v) = %v, want %q", tt.m, err, tt.want)
+		}
+	}
+}
+
+func TestParseParts(t *testing.T) {
+	if parts, err := parseParts(""); err != nil || len(parts) != len(allParts) {
+		t.Errorf(`parseParts("") = %v, %v`, parts, err)
+	}
+	if parts, err := parseParts("bin, db,bin"); err != nil || strings.Join(parts, ",") != "bin,db" {
+		t.Errorf("parseParts() = %v, %v", parts, err)
+	}
+	if _, err := parseParts("db,quests"); err == nil {
+		t.Error("parseParts accepted an unknown part")
+	}
+}
+
+func TestSplitFileEntry(t *testing.T) {
+	if part, rel, ok := splitFileEntry("files/savedumps/12/savedata.bin"); !ok || part != partSaveDumps || rel != "12/savedata.bin" {
+		t.Errorf("splitFileEntry() = %q, %q, %v", part, rel, ok)
+	}
+	for _, name := range []string{configName, databaseName, "files/bin"} {
+		if _, _, ok := splitFileEntry(name); ok {
+			t.Errorf("splitFileEntry(%q) matched", name)
+		}
+	}
+}
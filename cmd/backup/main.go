@@ -0,0 +1,412 @@
+// backup snapshots a whole server into one archive and restores it, for
+// recovering from a lost host. An archive holds a pg_dump of the database,
+// config.json, the quest binaries in BinPath, screenshots and save dumps.
+// It uses config.json in the working directory, and pg_dump and
+// pg_restore from the PATH.
+//
+// Usage:
+//
+//	backup create                              # Write erupe-full-<time>.tar.gz here
+//	backup create --out /mnt/backups --only db,config
+//	backup inspect erupe-full-20261017-030000.tar.gz
+//	backup restore erupe-full-20261017-030000.tar.gz
+//	backup restore --only bin --force erupe-full-20261017-030000.tar.gz
+//
+// A restore reads the whole archive before changing anything, and refuses
+// archives whose database is newer than the migrations of this build. An
+// older database is brought up to date by the server on its next start.
+// Stop the server before restoring.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	cfg "erupe-ce/config"
+	"erupe-ce/server/audit"
+	"erupe-ce/server/migrations"
+
+	"github.com/jmoiron/sqlx"
+	_ "github.com/lib/pq"
+	"github.com/spf13/viper"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(1)
+	}
+	switch os.Args[1] {
+	case "create":
+		runCreate(os.Args[2:])
+	case "inspect":
+		runInspect(os.Args[2:])
+	case "restore":
+		runRestore(os.Args[2:])
+	case "-h", "--help", "help":
+		usage()
+	default:
+		fmt.Fprintf(os.Stderr, "error: unknown command %q\n", os.Args[1])
+		usage()
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, `Usage: backup <command> [flags]
+
+Commands:
+  create           Archive the database, config and data directories
+  inspect ARCHIVE  Check an archive and show what it holds
+  restore ARCHIVE  Restore a server from an archive
+
+Parts, for --only: db, config, bin, screenshots, savedumps
+
+Run backup <command> -h for the command's flags.`)
+}
+
+func runCreate(args []string) {
+	fs := flag.NewFlagSet("create", flag.ExitOnError)
+	out := fs.String("out", ".", "Directory to write the archive to")
+	only := fs.String("only", "", "Comma separated parts to archive; all when empty")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: backup create [flags]")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+	parts, err := parseParts(*only)
+	if err != nil || fs.NArg() != 0 {
+		fs.Usage()
+		os.Exit(1)
+	}
+	config := loadConfig()
+
+	created := time.Now()
+	name := filepath.Join(*out, "erupe-full-"+created.UTC().Format("20060102-150405")+".tar.gz")
+	partial := name + ".partial"
+	f, err := os.Create(partial)
+	if err != nil {
+		fatalf("%v", err)
+	}
+	defer func() { _ = os.Remove(partial) }()
+	a := newArchiveWriter(f, created)
+
+	if slices.Contains(parts, partDB) {
+		if err := addDatabase(a, config.Database); err != nil {
+			fatalf("database: %v", err)
+		}
+	}
+	if slices.Contains(parts, partConfig) {
+		if err := a.addFile(configName, viper.ConfigFileUsed()); err != nil {
+			fatalf("config: %v", err)
+		}
+		a.manifest.Parts = append(a.manifest.Parts, partConfig)
+	}
+	for _, part := range parts {
+		if dir, ok := partDir(config, part); ok {
+			if err := a.addDir(part, dir); err != nil {
+				fatalf("%v", err)
+			}
+		}
+	}
+	if err := a.close(); err != nil {
+		fatalf("write %s: %v", partial, err)
+	}
+	if err := f.Close(); err != nil {
+		fatalf("write %s: %v", partial, err)
+	}
+	if err := os.Rename(partial, name); err != nil {
+		fatalf("%v", err)
+	}
+	fmt.Printf("Wrote %s\n", name)
+	writeManifest(os.Stdout, a.manifest)
+}
+
+// addDatabase dumps the database into the archive and records its schema
+// version.
+func addDatabase(a *archiveWriter, db cfg.Database) error {
+	conn := openDB(db)
+	version, err := migrations.Version(conn)
+	_ = conn.Close()
+	if err != nil {
+		return fmt.Errorf("read schema version: %w", err)
+	}
+	tmp, err := os.CreateTemp("", "erupe-*.pgdump")
+	if err != nil {
+		return err
+	}
+	_ = tmp.Close()
+	defer func() { _ = os.Remove(tmp.Name()) }()
+	if err := pgCommand("pg_dump", db, "--format=custom", "--file="+tmp.Name()).Run(); err != nil {
+		return fmt.Errorf("pg_dump: %w", err)
+	}
+	if err := a.addFile(databaseName, tmp.Name()); err != nil {
+		return err
+	}
+	a.manifest.Parts = append(a.manifest.Parts, partDB)
+	a.manifest.SchemaVersion = version
+	return nil
+}
+
+func runInspect(args []string) {
+	fs := flag.NewFlagSet("inspect", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: backup inspect ARCHIVE")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(1)
+	}
+	m, err := readArchive(fs.Arg(0), nil)
+	if err != nil {
+		fatalf("%s: %v", fs.Arg(0), err)
+	}
+	writeManifest(os.Stdout, m)
+	latest, err := migrations.Latest()
+	if err != nil {
+		fatalf("read migrations: %v", err)
+	}
+	if err := m.check(latest); err != nil {
+		fmt.Printf("Cannot be restored by this build: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Println("Can be restored by this build")
+}
+
+func runRestore(args []string) {
+	fs := flag.NewFlagSet("restore", flag.ExitOnError)
+	only := fs.String("only", "", "Comma separated parts to restore; all the archive holds when empty")
+	force := fs.Bool("force", false, "Replace an existing config.json and write into non-empty directories")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: backup restore [flags] ARCHIVE")
+		fmt.Fprintln(os.Stderr, "The database part replaces every table; stop the server first.")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+	parts, err := parseParts(*only)
+	if err != nil || fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(1)
+	}
+	name := fs.Arg(0)
+
+	// Read the whole archive first, so a truncated or incompatible one
+	// changes nothing.
+	m, err := readArchive(name, nil)
+	if err != nil {
+		fatalf("%s: %v", name, err)
+	}
+	latest, err := migrations.Latest()
+	if err != nil {
+		fatalf("read migrations: %v", err)
+	}
+	if err := m.check(latest); err != nil {
+		fatalf("%s: %v", name, err)
+	}
+	if *only == "" {
+		parts = m.Parts
+	} else if missing := slices.DeleteFunc(slices.Clone(parts), func(p string) bool { return slices.Contains(m.Parts, p) }); len(missing) > 0 {
+		fatalf("%s does not hold %s", name, strings.Join(missing, ", "))
+	}
+
+	// The archived config.json is only put in place ahead of the rest when
+	// there is none to read the database and directories from.
+	restoreConfig := slices.Contains(parts, partConfig)
+	if restoreConfig {
+		if _, err := os.Stat(configName); err == nil && !*force {
+			fatalf("%s exists; pass --force to replace it", configName)
+		} else if errors.Is(err, os.ErrNotExist) {
+			if err := extract(name, func(entry string) (string, bool) { return entry, entry == configName }); err != nil {
+				fatalf("restore config: %v", err)
+			}
+			restoreConfig = false
+		}
+	}
+	config := loadConfig()
+	var errs []error
+	for _, part := range parts {
+		if dir, ok := partDir(config, part); ok && !*force {
+			if empty, err := isEmptyDir(dir); err != nil {
+				errs = append(errs, err)
+			} else if !empty {
+				errs = append(errs, fmt.Errorf("%s: %s is not empty; pass --force to write into it", part, dir))
+			}
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		fatalf("%v", err)
+	}
+
+	tmpDir, err := os.MkdirTemp("", "erupe-restore-")
+	if err != nil {
+		fatalf("%v", err)
+	}
+	defer func() { _ = os.RemoveAll(tmpDir) }()
+	err = extract(name, func(entry string) (string, bool) {
+		switch entry {
+		case databaseName:
+			return filepath.Join(tmpDir, databaseName), slices.Contains(parts, partDB)
+		case configName:
+			return configName, restoreConfig
+		}
+		part, rel, ok := splitFileEntry(entry)
+		if !ok || !slices.Contains(parts, part) {
+			return "", false
+		}
+		dir, ok := partDir(config, part)
+		return filepath.Join(dir, filepath.FromSlash(rel)), ok
+	})
+	if err != nil {
+		fatalf("restore: %v", err)
+	}
+	for _, part := range parts {
+		if _, ok := partDir(config, part); ok {
+			fmt.Printf("Restored %d %s file(s)\n", m.Files[part], part)
+		}
+	}
+	if slices.Contains(parts, partConfig) {
+		fmt.Printf("Restored %s\n", configName)
+	}
+
+	if slices.Contains(parts, partDB) {
+		cmd := pgCommand("pg_restore", config.Database, "--clean", "--if-exists", "--no-owner",
+			"--single-transaction", "--exit-on-error", "--dbname="+config.Database.Database,
+			filepath.Join(tmpDir, databaseName))
+		if err := cmd.Run(); err != nil {
+			fatalf("pg_restore: %v", err)
+		}
+		fmt.Printf("Restored the database at schema version %d", m.SchemaVersion)
+		if m.SchemaVersion < latest {
+			fmt.Printf("; the server migrates it to %d on its next start", latest)
+		}
+		fmt.Println()
+	}
+
+	params := map[string]any{"archive": filepath.Base(name), "created": m.Created, "parts": parts}
+	if err := recordRestore(config.Database, filepath.Base(name), params); err != nil {
+		fmt.Fprintf(os.Stderr, "warning: failed to record audit log entry: %v\n", err)
+	}
+}
+
+// recordRestore adds the restore to the audit log. Files can be restored
+// without a database to reach, so failing to is not fatal.
+func recordRestore(d cfg.Database, target string, params any) error {
+	db, err := sqlx.Open("postgres", dsn(d))
+	if err != nil {
+		return err
+	}
+	defer func() { _ = db.Close() }()
+	return audit.NewRepository(db).Record(audit.SourceCLI, audit.CLIActor("backup"), "server:restore", target, params)
+}
+
+// extract writes the archive entries dest accepts to the paths it returns.
+func extract(name string, dest func(entry string) (string, bool)) error {
+	_, err := readArchive(name, func(entry string, r io.Reader) error {
+		p, ok := dest(entry)
+		if !ok {
+			return nil
+		}
+		return writeFile(p, r)
+	})
+	return err
+}
+
+// partDir returns the directory a directory part is archived from and
+// restored to.
+func partDir(config *cfg.Config, part string) (string, bool) {
+	switch part {
+	case partBin:
+		return config.BinPath, true
+	case partScreenshots:
+		return config.Screenshots.OutputDir, true
+	case partSaveDumps:
+		return config.SaveDumps.OutputDir, true
+	}
+	return "", false
+}
+
+// isEmptyDir reports whether dir holds nothing, treating a missing one as
+// empty.
+func isEmptyDir(dir string) (bool, error) {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return true, nil
+	}
+	return len(entries) == 0, err
+}
+
+func writeManifest(w io.Writer, m manifest) {
+	fmt.Fprintf(w, "Created:  %s\n", m.Created.Format("2006-01-02 15:04:05 MST"))
+	fmt.Fprintf(w, "Format:   %d\n", m.Format)
+	for _, part := range m.Parts {
+		switch {
+		case part == partDB:
+			fmt.Fprintf(w, "  %-12s schema version %d\n", part, m.SchemaVersion)
+		case part == partConfig:
+			fmt.Fprintf(w, "  %-12s %s\n", part, configName)
+		default:
+			fmt.Fprintf(w, "  %-12s %d file(s)\n", part, m.Files[part])
+		}
+	}
+}
+
+// pgCommand runs a PostgreSQL client tool against db, passing the
+// connection in the environment to keep the password off the command line.
+func pgCommand(name string, db cfg.Database, args ...string) *exec.Cmd {
+	cmd := exec.Command(name, args...)
+	cmd.Env = append(os.Environ(),
+		"PGHOST="+db.Host,
+		"PGPORT="+strconv.Itoa(db.Port),
+		"PGUSER="+db.User,
+		"PGPASSWORD="+db.Password,
+		"PGDATABASE="+db.Database,
+	)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd
+}
+
+func loadConfig() *cfg.Config {
+	config, err := cfg.LoadConfig()
+	if err != nil {
+		fatalf("load config: %v", err)
+	}
+	return config
+}
+
+// openDB connects to db.
+func openDB(d cfg.Database) *sqlx.DB {
+	db, err := sqlx.Open("postgres", dsn(d))
+	if err != nil {
+		fatalf("%v", err)
+	}
+	if err := db.Ping(); err != nil {
+		_ = db.Close()
+		fatalf("connect to database: %v", err)
+	}
+	return db
+}
+
+func dsn(d cfg.Database) string {
+	return fmt.Sprintf(
+		"host='%s' port='%d' user='%s' password='%s' dbname='%s' sslmode=disable",
+		d.Host, d.Port, d.User, d.Password, d.Database,
+	)
+}
+
+func fatalf(format string, args ...any) {
+	fmt.Fprintf(os.Stderr, "error: "+format+"\n", args...)
+	os.Exit(1)
+}
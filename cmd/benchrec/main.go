@@ -0,0 +1,194 @@
+// benchrec runs the hot path benchmarks, records the results to a file and
+// optionally compares them with an earlier recording, so a performance change
+// can be judged on numbers rather than impressions.
+//
+// Usage:
+//
+//	benchrec --out before.txt                   # Record a baseline
+//	benchrec --out after.txt --base before.txt  # Record again and compare
+//	benchrec --compare before.txt after.txt     # Compare two recordings
+//
+// Recordings are plain `go test -bench` output, so they also work with
+// benchstat.
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// defaultBench selects the benchmarks for the channel server's hot paths.
+const defaultBench = "^Benchmark(HandlePacketGroup|SaveDataParse|HandleSavedata|HandleLoaddata|EnumerateGuild|StageBroadcast200|HandleMsgSysCastBinary)$"
+
+func main() {
+	bench := flag.String("bench", defaultBench, "Benchmarks to run, as for go test -bench")
+	pkg := flag.String("pkg", "./server/channelserver/", "Package to benchmark")
+	count := flag.Int("count", 5, "Runs of each benchmark, as for go test -count")
+	benchtime := flag.String("benchtime", "1s", "Time or iterations per run, as for go test -benchtime")
+	out := flag.String("out", "", "File to record the results to")
+	base := flag.String("base", "", "Earlier recording to compare the results with")
+	compare := flag.Bool("compare", false, "Compare the two recordings given as arguments instead of running")
+	flag.Parse()
+
+	if *compare {
+		if flag.NArg() != 2 {
+			fatalf("--compare needs two recordings")
+		}
+		if err := compareFiles(os.Stdout, flag.Arg(0), flag.Arg(1)); err != nil {
+			fatalf("%v", err)
+		}
+		return
+	}
+	if *out == "" {
+		fmt.Fprintln(os.Stderr, "error: --out is required")
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	f, err := os.Create(*out)
+	if err != nil {
+		fatalf("%v", err)
+	}
+	cmd := exec.Command("go", "test", "-run", "^$", "-bench", *bench, "-benchmem",
+		"-count", strconv.Itoa(*count), "-benchtime", *benchtime, *pkg)
+	cmd.Stdout = io.MultiWriter(os.Stdout, f)
+	cmd.Stderr = os.Stderr
+	runErr := cmd.Run()
+	if err := f.Close(); err != nil {
+		fatalf("%v", err)
+	}
+	if runErr != nil {
+		fatalf("go test: %v", runErr)
+	}
+	fmt.Printf("\nRecorded to %s\n", *out)
+
+	if *base != "" {
+		fmt.Println()
+		if err := compareFiles(os.Stdout, *base, *out); err != nil {
+			fatalf("%v", err)
+		}
+	}
+}
+
+// results maps a benchmark name to its measurements by unit, one value per
+// run.
+type results map[string]map[string][]float64
+
+// parse reads go test -bench output, ignoring everything but result lines.
+func parse(r io.Reader) (results, error) {
+	res := make(results)
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		fields := strings.Fields(sc.Text())
+		if len(fields) < 4 || !strings.HasPrefix(fields[0], "Benchmark") {
+			continue
+		}
+		if _, err := strconv.Atoi(fields[1]); err != nil {
+			continue // Not an iteration count, e.g. a log line
+		}
+		name := fields[0]
+		if res[name] == nil {
+			res[name] = make(map[string][]float64)
+		}
+		for i := 2; i+1 < len(fields); i += 2 {
+			v, err := strconv.ParseFloat(fields[i], 64)
+			if err != nil {
+				return nil, fmt.Errorf("%s: bad value %q", name, fields[i])
+			}
+			res[name][fields[i+1]] = append(res[name][fields[i+1]], v)
+		}
+	}
+	return res, sc.Err()
+}
+
+func parseFile(path string) (results, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+	res, err := parse(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return res, nil
+}
+
+func compareFiles(w io.Writer, basePath, newPath string) error {
+	base, err := parseFile(basePath)
+	if err != nil {
+		return err
+	}
+	cur, err := parseFile(newPath)
+	if err != nil {
+		return err
+	}
+	writeComparison(w, base, cur)
+	return nil
+}
+
+// units are the measurements compared, in column order.
+var units = []string{"ns/op", "B/op", "allocs/op"}
+
+// writeComparison prints the median of each measurement in both recordings
+// and the change between them, for benchmarks present in both.
+func writeComparison(w io.Writer, base, cur results) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "benchmark\tunit\tbase\tnew\tdelta")
+	names := make([]string, 0, len(cur))
+	for name := range cur {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		if base[name] == nil {
+			_, _ = fmt.Fprintf(tw, "%s\t\t\t\tnew\n", name)
+			continue
+		}
+		for _, unit := range units {
+			b, c := base[name][unit], cur[name][unit]
+			if len(b) == 0 || len(c) == 0 {
+				continue
+			}
+			_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", name, unit,
+				formatValue(median(b)), formatValue(median(c)), delta(median(b), median(c)))
+		}
+	}
+	_ = tw.Flush()
+}
+
+func median(v []float64) float64 {
+	v = slices.Clone(v)
+	slices.Sort(v)
+	if len(v)%2 == 1 {
+		return v[len(v)/2]
+	}
+	return (v[len(v)/2-1] + v[len(v)/2]) / 2
+}
+
+func formatValue(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+func delta(base, cur float64) string {
+	if base == 0 {
+		if cur == 0 {
+			return "~"
+		}
+		return "+inf"
+	}
+	return fmt.Sprintf("%+.1f%%", (cur-base)/base*100)
+}
+
+func fatalf(format string, args ...any) {
+	fmt.Fprintf(os.Stderr, "error: "+format+"\n", args...)
+	os.Exit(1)
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+const baseOutput = `goos: linux
+goarch: amd64
+pkg: erupe-ce/server/channelserver
+BenchmarkHandlePacketGroup-8   	  300000	      4000 ns/op	     900 B/op	      26 allocs/op
+BenchmarkHandlePacketGroup-8   	  300000	      3000 ns/op	     900 B/op	      26 allocs/op
+BenchmarkHandlePacketGroup-8   	  300000	      5000 ns/op	     900 B/op	      26 allocs/op
+BenchmarkEnumerateGuild/name-8 	   30000	     36000 ns/op	   18000 B/op	      66 allocs/op
+2026-10-17T06:26:19.184Z	INFO	channelserver/handlers_data.go:95	Wrote recompressed savedata
+PASS
+ok  	erupe-ce/server/channelserver	3.2s
+`
+
+const newOutput = `BenchmarkHandlePacketGroup-8   	  300000	      2000 ns/op	     450 B/op	      13 allocs/op
+BenchmarkEnumerateGuild/name-8 	   30000	     36000 ns/op	   18000 B/op	      66 allocs/op
+BenchmarkHandleLoaddata-8      	    3000	    324566 ns/op	  853803 B/op	      38 allocs/op
+`
+
+func TestParse(t *testing.T) {
+	res, err := parse(strings.NewReader(baseOutput))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != 2 {
+		t.Fatalf("parsed %d benchmarks, want 2", len(res))
+	}
+	if got := res["BenchmarkHandlePacketGroup-8"]["ns/op"]; len(got) != 3 || median(got) != 4000 {
+		t.Errorf("ns/op = %v, want 3 runs with median 4000", got)
+	}
+	if got := res["BenchmarkEnumerateGuild/name-8"]["allocs/op"]; len(got) != 1 || got[0] != 66 {
+		t.Errorf("allocs/op = %v", got)
+	}
+}
+
+func TestWriteComparison(t *testing.T) {
+	base, _ := parse(strings.NewReader(baseOutput))
+	cur, _ := parse(strings.NewReader(newOutput))
+	var sb strings.Builder
+	writeComparison(&sb, base, cur)
+
+	// Compare lines with the column padding collapsed.
+	lines := make(map[string]bool)
+	for _, line := range strings.Split(sb.String(), "\n") {
+		lines[strings.Join(strings.Fields(line), " ")] = true
+	}
+	for _, want := range []string{
+		"BenchmarkHandlePacketGroup-8 ns/op 4000 2000 -50.0%",
+		"BenchmarkHandlePacketGroup-8 allocs/op 26 13 -50.0%",
+		"BenchmarkEnumerateGuild/name-8 B/op 18000 18000 +0.0%",
+		"BenchmarkHandleLoaddata-8 new",
+	} {
+		if !lines[want] {
+			t.Errorf("comparison missing %q:\n%s", want, sb.String())
+		}
+	}
+}
+
+func TestMedianEven(t *testing.T) {
+	if got := median([]float64{4, 1, 3, 2}); got != 2.5 {
+		t.Errorf("median = %v, want 2.5", got)
+	}
+}
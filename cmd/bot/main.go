@@ -0,0 +1,205 @@
+// bot is a load tool that logs many headless clients into an Erupe server,
+// idles them in the lobby and reports how the logins went. It is built on
+// the protbot client.
+//
+// Accounts are named <prefix>0001, <prefix>0002, ... and share one password,
+// so run it against a server with AutoCreateAccount enabled or create them
+// first.
+//
+// Usage:
+//
+//	bot --sign-addr 127.0.0.1:53312 --count 50 --hold 5m
+//	bot --sign-addr 127.0.0.1:53312 --count 20 --ramp 500ms --chat 30s
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"erupe-ce/cmd/protbot/protocol"
+	"erupe-ce/cmd/protbot/scenario"
+)
+
+// botResult is the outcome of one bot's run.
+type botResult struct {
+	user  string
+	stage string        // Step that failed, empty on success
+	err   error         // Why it failed
+	login time.Duration // Sign through channel login
+	ready time.Duration // Channel login through entering the lobby
+}
+
+// botOptions configure every bot in a run.
+type botOptions struct {
+	signAddr  string
+	pass      string
+	hold      time.Duration
+	heartbeat time.Duration
+	chat      time.Duration
+	stop      <-chan struct{}
+	chats     *atomic.Int64 // Chat messages received across all bots
+}
+
+func main() {
+	signAddr := flag.String("sign-addr", "127.0.0.1:53312", "Sign server address (host:port)")
+	count := flag.Int("count", 10, "Number of bots")
+	prefix := flag.String("user-prefix", "bot", "Username prefix; bots are <prefix>0001, <prefix>0002, ...")
+	pass := flag.String("pass", "bot", "Password shared by every bot")
+	ramp := flag.Duration("ramp", 100*time.Millisecond, "Delay between starting bots")
+	hold := flag.Duration("hold", time.Minute, "How long each bot stays in the lobby")
+	heartbeat := flag.Duration("heartbeat", 10*time.Second, "Interval between idle heartbeats")
+	chat := flag.Duration("chat", 0, "Interval between chat messages from each bot, 0 to stay quiet")
+	verbose := flag.Bool("v", false, "Print every bot's protocol progress")
+	flag.Parse()
+
+	if *count < 1 {
+		fmt.Fprintln(os.Stderr, "error: --count must be at least 1")
+		os.Exit(1)
+	}
+	if !*verbose {
+		protocol.SetOutput(io.Discard)
+	}
+
+	stop := make(chan struct{})
+	go func() {
+		sig := make(chan os.Signal, 1)
+		signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM)
+		<-sig
+		fmt.Println("\n[signal] Logging bots out...")
+		close(stop)
+	}()
+
+	opts := botOptions{
+		signAddr:  *signAddr,
+		pass:      *pass,
+		hold:      *hold,
+		heartbeat: *heartbeat,
+		chat:      *chat,
+		stop:      stop,
+		chats:     &atomic.Int64{},
+	}
+	results := make([]botResult, *count)
+	var wg sync.WaitGroup
+	start := time.Now()
+	fmt.Printf("[bot] Starting %d bot(s) against %s\n", *count, *signAddr)
+launch:
+	for i := range *count {
+		if i > 0 {
+			select {
+			case <-stop:
+				results = results[:i]
+				break launch
+			case <-time.After(*ramp):
+			}
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = runBot(fmt.Sprintf("%s%04d", *prefix, i+1), i, opts)
+		}()
+	}
+	wg.Wait()
+
+	if failed := report(os.Stdout, results, time.Since(start), opts.chats.Load()); failed > 0 {
+		os.Exit(1)
+	}
+}
+
+// runBot logs one bot in on channel index channel, idles it in the lobby
+// until the hold time is up or the run is stopped, then logs it out.
+func runBot(user string, channel int, opts botOptions) botResult {
+	res := botResult{user: user}
+	start := time.Now()
+	login, err := scenario.LoginChannel(opts.signAddr, user, opts.pass, channel)
+	if err != nil {
+		res.stage, res.err = "login", err
+		return res
+	}
+	res.login = time.Since(start)
+	ch := login.Channel
+
+	start = time.Now()
+	if _, err := scenario.SetupSession(ch, login.Sign.CharIDs[0]); err != nil {
+		_ = ch.Close()
+		res.stage, res.err = "session", err
+		return res
+	}
+	if err := scenario.EnterLobby(ch); err != nil {
+		_ = ch.Close()
+		res.stage, res.err = "lobby", err
+		return res
+	}
+	res.ready = time.Since(start)
+
+	ch.StartHeartbeat(opts.heartbeat)
+	scenario.ListenChat(ch, func(scenario.ChatMessage) { opts.chats.Add(1) })
+
+	var chatTick <-chan time.Time
+	if opts.chat > 0 {
+		ticker := time.NewTicker(opts.chat)
+		defer ticker.Stop()
+		chatTick = ticker.C
+	}
+	deadline := time.After(opts.hold)
+	for sent := 1; ; sent++ {
+		select {
+		case <-chatTick:
+			if err := scenario.SendChat(ch, 0x03, 1, fmt.Sprintf("load test %d", sent), user); err != nil {
+				_ = ch.Close()
+				res.stage, res.err = "chat", err
+				return res
+			}
+		case <-deadline:
+			_ = scenario.Logout(ch)
+			return res
+		case <-opts.stop:
+			_ = scenario.Logout(ch)
+			return res
+		}
+	}
+}
+
+// report prints a summary of a run and returns how many bots failed.
+func report(w io.Writer, results []botResult, elapsed time.Duration, chats int64) int {
+	var logins, readies []time.Duration
+	failures := make(map[string]int)
+	for _, r := range results {
+		if r.err != nil {
+			failures[r.stage]++
+			_, _ = fmt.Fprintf(w, "[bot] %s failed at %s: %v\n", r.user, r.stage, r.err)
+			continue
+		}
+		logins = append(logins, r.login)
+		readies = append(readies, r.ready)
+	}
+
+	failed := len(results) - len(logins)
+	_, _ = fmt.Fprintf(w, "[bot] %d/%d bot(s) succeeded in %s, %d chat message(s) received\n",
+		len(logins), len(results), elapsed.Round(time.Millisecond), chats)
+	for _, stage := range []string{"login", "session", "lobby", "chat"} {
+		if n := failures[stage]; n > 0 {
+			_, _ = fmt.Fprintf(w, "[bot]   %d failed at %s\n", n, stage)
+		}
+	}
+	if len(logins) > 0 {
+		_, _ = fmt.Fprintf(w, "[bot] Login: %s\n", summarize(logins))
+		_, _ = fmt.Fprintf(w, "[bot] Ready: %s\n", summarize(readies))
+	}
+	return failed
+}
+
+// summarize describes the spread of durations, which must not be empty.
+func summarize(d []time.Duration) string {
+	d = slices.Clone(d)
+	slices.Sort(d)
+	pct := func(p int) time.Duration { return d[(len(d)-1)*p/100].Round(time.Millisecond) }
+	return fmt.Sprintf("min %s, p50 %s, p95 %s, max %s", pct(0), pct(50), pct(95), pct(100))
+}
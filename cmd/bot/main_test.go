@@ -0,0 +1,39 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReport(t *testing.T) {
+	results := []botResult{
+		{user: "bot0001", login: 30 * time.Millisecond, ready: 5 * time.Millisecond},
+		{user: "bot0002", login: 10 * time.Millisecond, ready: 15 * time.Millisecond},
+		{user: "bot0003", stage: "session", err: errors.New("loaddata ack: ACK timeout for handle 3")},
+		{user: "bot0004", login: 20 * time.Millisecond, ready: 10 * time.Millisecond},
+	}
+	var out strings.Builder
+	if failed := report(&out, results, time.Second, 7); failed != 1 {
+		t.Errorf("report() = %d failed, want 1", failed)
+	}
+	for _, want := range []string{
+		"bot0003 failed at session: loaddata ack",
+		"3/4 bot(s) succeeded in 1s, 7 chat message(s) received",
+		"1 failed at session",
+		"Login: min 10ms, p50 20ms, p95 20ms, max 30ms",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("report output missing %q:\n%s", want, out.String())
+		}
+	}
+}
+
+func TestReport_AllFailed(t *testing.T) {
+	var out strings.Builder
+	failed := report(&out, []botResult{{user: "bot0001", stage: "login", err: errors.New("refused")}}, time.Second, 0)
+	if failed != 1 || strings.Contains(out.String(), "Login:") {
+		t.Errorf("report() = %d, output:\n%s", failed, out.String())
+	}
+}
@@ -0,0 +1,228 @@
+// distribute rolls out item distributions described in a spec file, so
+// event rewards can be reviewed like code and applied the same way every
+// time. It uses the database configured in config.json in the working
+// directory.
+//
+// Usage:
+//
+//	distribute check winter.yaml            # Validate specs without the database
+//	distribute apply --dry-run winter.yaml  # Resolve the targets and report what would be inserted
+//	distribute apply winter.yaml            # Insert the distribution
+//
+// A spec is YAML or JSON:
+//
+//	name: Winter Festival
+//	description: "~C05Thanks for playing this winter!"
+//	times: 1                        # Claims per character
+//	starts: 2026-12-20T00:00:00Z    # Optional; claimable at once without it
+//	deadline: 2027-01-05T00:00:00Z  # Optional; never expires without it
+//	target:
+//	  accounts: [hunter]            # Or characters: [IDs]; everyone without either
+//	  hr: {min: 100}
+//	items:
+//	  - {type: 7, id: 1234, quantity: 10}
+//
+// A targeted spec inserts one distribution per character, one for everyone
+// otherwise. Applied specs are recorded in the audit log.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	cfg "erupe-ce/config"
+	"erupe-ce/server/audit"
+	"erupe-ce/server/channelserver"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(1)
+	}
+	switch os.Args[1] {
+	case "check":
+		runCheck(os.Args[2:])
+	case "apply":
+		runApply(os.Args[2:])
+	case "-h", "--help", "help":
+		usage()
+	default:
+		fmt.Fprintf(os.Stderr, "error: unknown command %q\n", os.Args[1])
+		usage()
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, `Usage: distribute <command> [flags] SPEC
+
+Commands:
+  check SPEC...  Validate spec files without the database
+  apply SPEC     Insert the distribution a spec describes
+
+Run distribute <command> -h for the command's flags.`)
+}
+
+func runCheck(args []string) {
+	fs := flag.NewFlagSet("check", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: distribute check SPEC...")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+	if fs.NArg() == 0 {
+		fs.Usage()
+		os.Exit(1)
+	}
+	failed := false
+	for _, path := range fs.Args() {
+		if _, err := loadSpec(path); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", path, err)
+			failed = true
+			continue
+		}
+		fmt.Printf("%s: ok\n", path)
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+func runApply(args []string) {
+	fs := flag.NewFlagSet("apply", flag.ExitOnError)
+	dryRun := fs.Bool("dry-run", false, "Report what would be inserted without inserting it")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: distribute apply [flags] SPEC")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(1)
+	}
+	s, err := loadSpec(fs.Arg(0))
+	if err != nil {
+		fatalf("%s: %v", fs.Arg(0), err)
+	}
+
+	db := openDB()
+	defer func() { _ = db.Close() }()
+	var charIDs []uint32
+	if s.targeted() {
+		if charIDs, err = resolveTargets(db, s.Target); err != nil {
+			fatalf("%v", err)
+		}
+		if len(charIDs) == 0 {
+			fatalf("the target accounts have no characters")
+		}
+	}
+	writeReport(os.Stdout, s, charIDs)
+	if *dryRun {
+		fmt.Println("Dry run, nothing inserted")
+		return
+	}
+
+	ids, err := channelserver.NewDistributionRepository(db).Create(s.distribution(), s.items(), charIDs)
+	if err != nil {
+		fatalf("insert: %v", err)
+	}
+	target := make([]string, len(ids))
+	for i, id := range ids {
+		target[i] = strconv.FormatUint(uint64(id), 10)
+	}
+	if err := audit.NewRepository(db).Record(audit.SourceCLI, audit.CLIActor("distribute"), "distribution:create", strings.Join(target, ","), s); err != nil {
+		fmt.Fprintf(os.Stderr, "warning: failed to record audit log entry: %v\n", err)
+	}
+	fmt.Printf("Inserted distribution(s) %s\n", strings.Join(target, ", "))
+}
+
+// loadSpec reads and validates the spec at path.
+func loadSpec(path string) (*spec, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+	s, err := parseSpec(f)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.validate(time.Now()); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// resolveTargets returns the IDs of the characters the target names,
+// refusing characters and accounts that do not exist.
+func resolveTargets(db *sqlx.DB, t target) ([]uint32, error) {
+	ids := dedupe(t.Characters)
+	if len(ids) > 0 {
+		var found []uint32
+		if err := db.Select(&found, `SELECT id FROM characters WHERE id = ANY($1) AND NOT deleted`, pq.Array(ids)); err != nil {
+			return nil, fmt.Errorf("look up characters: %w", err)
+		}
+		if len(found) != len(ids) {
+			var missing []string
+			for _, id := range ids {
+				if !slices.Contains(found, id) {
+					missing = append(missing, strconv.FormatUint(uint64(id), 10))
+				}
+			}
+			return nil, fmt.Errorf("no characters with ID %s", strings.Join(missing, ", "))
+		}
+	}
+	var errs []error
+	for _, username := range t.Accounts {
+		var userID uint32
+		if err := db.Get(&userID, `SELECT id FROM users WHERE username = $1`, username); err != nil {
+			errs = append(errs, fmt.Errorf("account %q: %w", username, err))
+			continue
+		}
+		var chars []uint32
+		if err := db.Select(&chars, `SELECT id FROM characters WHERE user_id = $1 AND NOT deleted`, userID); err != nil {
+			return nil, fmt.Errorf("look up the characters of %q: %w", username, err)
+		}
+		ids = append(ids, chars...)
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return dedupe(ids), nil
+}
+
+// openDB connects to the database in config.json.
+func openDB() *sqlx.DB {
+	config, err := cfg.LoadConfig()
+	if err != nil {
+		fatalf("load config: %v", err)
+	}
+	db, err := sqlx.Open("postgres", fmt.Sprintf(
+		"host='%s' port='%d' user='%s' password='%s' dbname='%s' sslmode=disable",
+		config.Database.Host, config.Database.Port, config.Database.User,
+		config.Database.Password, config.Database.Database,
+	))
+	if err != nil {
+		fatalf("%v", err)
+	}
+	if err := db.Ping(); err != nil {
+		_ = db.Close()
+		fatalf("connect to database: %v", err)
+	}
+	return db
+}
+
+func fatalf(format string, args ...any) {
+	fmt.Fprintf(os.Stderr, "error: "+format+"\n", args...)
+	os.Exit(1)
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+var now = time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)
+
+const winterYAML = `
+name: Winter Festival
+description: "~C05Thanks for playing!"
+times: 2
+starts: 2026-12-20T00:00:00Z
+deadline: 2027-01-05T00:00:00Z
+target:
+  accounts: [hunter]
+  characters: [7, 3, 7]
+  hr: {min: 100}
+  gr: {min: 1, max: 50}
+items:
+  - {type: 7, id: 1234, quantity: 10}
+  - {type: 21, quantity: 500}
+`
+
+func TestParseSpec(t *testing.T) {
+	s, err := parseSpec(strings.NewReader(winterYAML))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.validate(now); err != nil {
+		t.Fatalf("validate() = %v", err)
+	}
+	d := s.distribution()
+	if d.Type != defaultType || d.EventName != "Winter Festival" || d.TimesAcceptable != 2 ||
+		d.MinHR != 100 || d.MaxHR != -1 || d.MinSR != -1 || d.MinGR != 1 || d.MaxGR != 50 {
+		t.Errorf("distribution() = %+v", d)
+	}
+	if d.Starts == nil || !d.Starts.Equal(time.Date(2026, 12, 20, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Starts = %v", d.Starts)
+	}
+	items := s.items()
+	if len(items) != 2 || items[0].ItemID != 1234 || items[1].ItemType != 21 || items[1].Quantity != 500 {
+		t.Errorf("items() = %+v", items)
+	}
+	if got := dedupe(s.Target.Characters); len(got) != 2 || got[0] != 3 || got[1] != 7 {
+		t.Errorf("dedupe() = %v", got)
+	}
+}
+
+func TestParseSpec_JSON(t *testing.T) {
+	s, err := parseSpec(strings.NewReader(`{"name": "Gift", "type": 2, "items": [{"type": 30, "quantity": 1}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.validate(now); err != nil {
+		t.Fatalf("validate() = %v", err)
+	}
+	if *s.Type != 2 || s.Times != 1 || s.targeted() {
+		t.Errorf("spec = %+v", s)
+	}
+}
+
+func TestParseSpec_Errors(t *testing.T) {
+	for _, in := range []string{"", "name: Gift\nitem: []\n", "name: [Gift]\n"} {
+		if _, err := parseSpec(strings.NewReader(in)); err == nil {
+			t.Errorf("parseSpec(%q) succeeded", in)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		spec string
+		want string
+	}{
+		{"items: [{type: 7, id: 1, quantity: 1}]", "name: is empty"},
+		{"name: Gift", "items: is empty"},
+		{"name: Gift\nitems: [{type: 7, id: 1}]", "items[0].quantity: is zero"},
+		{"name: Gift\nitems: [{type: 7, quantity: 1}]", "items[0].id: is zero"},
+		{"name: 🎁\nitems: [{type: 30, quantity: 1}]", "name: cannot be shown in Shift-JIS"},
+		{"name: " + strings.Repeat("a", 255) + "\nitems: [{type: 30, quantity: 1}]", "at most 254 fit"},
+		{"name: Gift\ndeadline: 2026-10-01T00:00:00Z\nitems: [{type: 30, quantity: 1}]", "has passed"},
+		{"name: Gift\nstarts: 2027-01-01T00:00:00Z\ndeadline: 2026-12-01T00:00:00Z\nitems: [{type: 30, quantity: 1}]", "not after starts"},
+		{"name: Gift\ntarget: {sr: {min: 5, max: 1}}\nitems: [{type: 30, quantity: 1}]", "target.sr: min 5 is above max 1"},
+		{"name: Gift\ntarget: {hr: {min: -1}}\nitems: [{type: 30, quantity: 1}]", "target.hr: is negative"},
+		{"name: Gift\ntarget: {accounts: ['']}\nitems: [{type: 30, quantity: 1}]", "target.accounts[0]: is empty"},
+	}
+	for _, tt := range tests {
+		s, err := parseSpec(strings.NewReader(tt.spec))
+		if err != nil {
+			t.Fatalf("parseSpec(%q) = %v", tt.spec, err)
+		}
+		if err := s.validate(now); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("validate(%q) = %v, want %q", tt.spec, err, tt.want)
+		}
+	}
+}
+
+func TestWriteReport(t *testing.T) {
+	s, err := parseSpec(strings.NewReader(winterYAML))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sb strings.Builder
+	writeReport(&sb, s, []uint32{3, 7, 12})
+	out := sb.String()
+	for _, want := range []string{
+		`Distribution "Winter Festival" (type 1)`,
+		"2 times, from 2026-12-20 00:00 UTC until 2027-01-05 00:00 UTC",
+		"HR 100+, GR 1-50",
+		"Characters:  3 (3, 7, 12)",
+		"type 21  id 0      x500",
+		"3 distribution(s) with 2 item(s) each",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("report missing %q:\n%s", want, out)
+		}
+	}
+
+	s, _ = parseSpec(strings.NewReader("name: Gift\nitems: [{type: 30, quantity: 1}]"))
+	sb.Reset()
+	writeReport(&sb, s, nil)
+	for _, want := range []string{"once, from now with no deadline", "Ranks:       any", "every character", "1 distribution(s)"} {
+		if !strings.Contains(sb.String(), want) {
+			t.Errorf("report missing %q:\n%s", want, sb.String())
+		}
+	}
+}
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
+
+	"erupe-ce/server/channelserver"
+
+	"golang.org/x/text/encoding/japanese"
+	"gopkg.in/yaml.v3"
+)
+
+// defaultType is the distribution type the seed distributions use.
+const defaultType = 1
+
+// spec is a distribution spec file. JSON files are read as YAML, which
+// they are a subset of.
+type spec struct {
+	Name        string     `yaml:"name"`        // Event name shown in the list
+	Description string     `yaml:"description"` // Text shown when the distribution is opened
+	Type        *uint8     `yaml:"type"`        // Distribution type, defaultType when missing
+	Times       uint16     `yaml:"times"`       // Times each character can claim it, 1 when missing
+	Selection   bool       `yaml:"selection"`   // The character picks one of the items
+	Starts      *time.Time `yaml:"starts"`      // Claimable from then, at once when missing
+	Deadline    *time.Time `yaml:"deadline"`    // Claimable until then, forever when missing
+	Target      target     `yaml:"target"`
+	Items       []item     `yaml:"items"`
+}
+
+// target selects who receives the distribution. With no characters or
+// accounts listed, every character does. The rank ranges are checked by
+// the client.
+type target struct {
+	Characters []uint32  `yaml:"characters"`
+	Accounts   []string  `yaml:"accounts"` // Usernames whose characters all receive it
+	HR         rankRange `yaml:"hr"`
+	SR         rankRange `yaml:"sr"`
+	GR         rankRange `yaml:"gr"`
+}
+
+type rankRange struct {
+	Min *int16 `yaml:"min"`
+	Max *int16 `yaml:"max"`
+}
+
+type namedRange struct {
+	name string
+	rankRange
+}
+
+func (t *target) ranks() []namedRange {
+	return []namedRange{{"HR", t.HR}, {"SR", t.SR}, {"GR", t.GR}}
+}
+
+type item struct {
+	Type     uint8  `yaml:"type"`
+	ID       uint32 `yaml:"id"`
+	Quantity uint32 `yaml:"quantity"`
+}
+
+// parseSpec reads a spec, refusing fields it does not know so that a typo
+// does not silently drop a rule.
+func parseSpec(r io.Reader) (*spec, error) {
+	dec := yaml.NewDecoder(r)
+	dec.KnownFields(true)
+	var s spec
+	if err := dec.Decode(&s); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, errors.New("empty spec")
+		}
+		return nil, err
+	}
+	if s.Type == nil {
+		t := uint8(defaultType)
+		s.Type = &t
+	}
+	if s.Times == 0 {
+		s.Times = 1
+	}
+	return &s, nil
+}
+
+// validate reports every problem with the spec as of now.
+func (s *spec) validate(now time.Time) error {
+	var errs []error
+	add := func(format string, args ...any) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+	if s.Name == "" {
+		add("name: is empty")
+	} else if n, err := sjisLength(s.Name); err != nil {
+		add("name: %v", err)
+	} else if n > 254 {
+		add("name: is %d bytes in Shift-JIS, at most 254 fit", n)
+	}
+	if _, err := sjisLength(s.Description); err != nil {
+		add("description: %v", err)
+	}
+	if s.Deadline != nil {
+		if !s.Deadline.After(now) {
+			add("deadline: %s has passed", s.Deadline.Format(time.RFC3339))
+		}
+		if s.Starts != nil && !s.Deadline.After(*s.Starts) {
+			add("deadline: is not after starts")
+		}
+	}
+	for _, r := range s.Target.ranks() {
+		if r.Min != nil && *r.Min < 0 || r.Max != nil && *r.Max < 0 {
+			add("target.%s: is negative", strings.ToLower(r.name))
+		} else if r.Min != nil && r.Max != nil && *r.Min > *r.Max {
+			add("target.%s: min %d is above max %d", strings.ToLower(r.name), *r.Min, *r.Max)
+		}
+	}
+	for i, username := range s.Target.Accounts {
+		if username == "" {
+			add("target.accounts[%d]: is empty", i)
+		}
+	}
+	if len(s.Items) == 0 {
+		add("items: is empty")
+	}
+	for i, it := range s.Items {
+		if it.Quantity == 0 {
+			add("items[%d].quantity: is zero", i)
+		}
+		if it.Type == 7 && it.ID == 0 {
+			add("items[%d].id: is zero for an item", i)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// sjisLength returns the length of x in Shift-JIS, the encoding the
+// client shows it in.
+func sjisLength(x string) (int, error) {
+	b, err := japanese.ShiftJIS.NewEncoder().Bytes([]byte(x))
+	if err != nil {
+		return 0, errors.New("cannot be shown in Shift-JIS")
+	}
+	return len(b), nil
+}
+
+func (s *spec) distribution() channelserver.NewDistribution {
+	limit := func(v *int16) int16 {
+		if v == nil {
+			return -1
+		}
+		return *v
+	}
+	return channelserver.NewDistribution{
+		Type:            *s.Type,
+		EventName:       s.Name,
+		Description:     s.Description,
+		TimesAcceptable: s.Times,
+		Selection:       s.Selection,
+		Starts:          s.Starts,
+		Deadline:        s.Deadline,
+		MinHR:           limit(s.Target.HR.Min),
+		MaxHR:           limit(s.Target.HR.Max),
+		MinSR:           limit(s.Target.SR.Min),
+		MaxSR:           limit(s.Target.SR.Max),
+		MinGR:           limit(s.Target.GR.Min),
+		MaxGR:           limit(s.Target.GR.Max),
+	}
+}
+
+func (s *spec) items() []channelserver.DistributionItem {
+	items := make([]channelserver.DistributionItem, len(s.Items))
+	for i, it := range s.Items {
+		items[i] = channelserver.DistributionItem{ItemType: it.Type, ItemID: it.ID, Quantity: it.Quantity}
+	}
+	return items
+}
+
+// targeted reports whether the spec names who receives it, rather than
+// going to every character.
+func (s *spec) targeted() bool {
+	return len(s.Target.Characters) > 0 || len(s.Target.Accounts) > 0
+}
+
+// writeReport describes what applying the spec to the characters in
+// charIDs inserts.
+func writeReport(w io.Writer, s *spec, charIDs []uint32) {
+	fmt.Fprintf(w, "Distribution %q (type %d)\n", s.Name, *s.Type)
+	if s.Description != "" {
+		fmt.Fprintf(w, "  Description: %s\n", s.Description)
+	}
+	window := "from now"
+	if s.Starts != nil {
+		window = "from " + s.Starts.UTC().Format("2006-01-02 15:04 MST")
+	}
+	if s.Deadline != nil {
+		window += " until " + s.Deadline.UTC().Format("2006-01-02 15:04 MST")
+	} else {
+		window += " with no deadline"
+	}
+	times := "once"
+	if s.Times > 1 {
+		times = fmt.Sprintf("%d times", s.Times)
+	}
+	fmt.Fprintf(w, "  Claimable:   %s, %s\n", times, window)
+	var ranks []string
+	for _, r := range s.Target.ranks() {
+		switch {
+		case r.Min != nil && r.Max != nil:
+			ranks = append(ranks, fmt.Sprintf("%s %d-%d", r.name, *r.Min, *r.Max))
+		case r.Min != nil:
+			ranks = append(ranks, fmt.Sprintf("%s %d+", r.name, *r.Min))
+		case r.Max != nil:
+			ranks = append(ranks, fmt.Sprintf("%s up to %d", r.name, *r.Max))
+		}
+	}
+	if len(ranks) == 0 {
+		ranks = []string{"any"}
+	}
+	fmt.Fprintf(w, "  Ranks:       %s\n", strings.Join(ranks, ", "))
+	if s.targeted() {
+		ids := make([]string, len(charIDs))
+		for i, id := range charIDs {
+			ids[i] = fmt.Sprint(id)
+		}
+		fmt.Fprintf(w, "  Characters:  %d (%s)\n", len(charIDs), strings.Join(ids, ", "))
+	} else {
+		fmt.Fprintf(w, "  Characters:  every character\n")
+	}
+	if s.Selection {
+		fmt.Fprintf(w, "  Items, one to choose:\n")
+	} else {
+		fmt.Fprintf(w, "  Items:\n")
+	}
+	for _, it := range s.Items {
+		fmt.Fprintf(w, "    type %-3d id %-6d x%d\n", it.Type, it.ID, it.Quantity)
+	}
+	rows := 1
+	if s.targeted() {
+		rows = len(charIDs)
+	}
+	fmt.Fprintf(w, "%d distribution(s) with %d item(s) each\n", rows, len(s.Items))
+}
+
+// dedupe returns ids sorted without repeats.
+func dedupe(ids []uint32) []uint32 {
+	ids = slices.Clone(ids)
+	slices.Sort(ids)
+	return slices.Compact(ids)
+}
@@ -15,10 +15,15 @@ import (
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"erupe-ce/cmd/protbot/scenario"
 )
 
+// heartbeatInterval keeps idle sessions well inside the channel server's 30
+// second timeout.
+const heartbeatInterval = 10 * time.Second
+
 func main() {
 	signAddr := flag.String("sign-addr", "127.0.0.1:53312", "Sign server address (host:port)")
 	user := flag.String("user", "", "Username")
@@ -74,6 +79,7 @@ func main() {
 			_ = result.Channel.Close()
 			os.Exit(1)
 		}
+		result.Channel.StartHeartbeat(heartbeatInterval)
 		fmt.Println("[session] Connected. Press Ctrl+C to disconnect.")
 		waitForSignal()
 		_ = scenario.Logout(result.Channel)
@@ -96,6 +102,8 @@ func main() {
 			os.Exit(1)
 		}
 
+		result.Channel.StartHeartbeat(heartbeatInterval)
+
 		// Register chat listener.
 		scenario.ListenChat(result.Channel, func(msg scenario.ChatMessage) {
 			fmt.Printf("[chat] <%s> (type=%d): %s\n", msg.SenderName, msg.ChatType, msg.Message)
@@ -16,10 +16,12 @@ type PacketHandler func(opcode uint16, data []byte)
 // ChannelConn manages a connection to a channel server.
 type ChannelConn struct {
 	conn       *conn.MHFConn
+	sendMu     sync.Mutex // Serializes sends; the crypto keys rotate per packet
 	ackCounter uint32
 	waiters    sync.Map // map[uint32]chan *AckResponse
 	handlers   sync.Map // map[uint16]PacketHandler
 	closed     atomic.Bool
+	done       chan struct{} // Closed by Close to stop the heartbeat
 }
 
 // OnPacket registers a handler for a specific server-pushed opcode.
@@ -46,6 +48,7 @@ func ConnectChannel(addr string) (*ChannelConn, error) {
 
 	ch := &ChannelConn{
 		conn: c,
+		done: make(chan struct{}),
 	}
 
 	go ch.recvLoop()
@@ -60,6 +63,8 @@ func (ch *ChannelConn) NextAckHandle() uint32 {
 // SendPacket encrypts and sends raw packet data (including the 0x00 0x10 terminator
 // which is already appended by the Build* functions in packets.go).
 func (ch *ChannelConn) SendPacket(data []byte) error {
+	ch.sendMu.Lock()
+	defer ch.sendMu.Unlock()
 	return ch.conn.SendPacket(data)
 }
 
@@ -77,9 +82,36 @@ func (ch *ChannelConn) WaitForAck(handle uint32, timeout time.Duration) (*AckRes
 	}
 }
 
+// StartHeartbeat sends MSG_SYS_TIME every interval until the connection is
+// closed, as the client does while idle. The channel server drops sessions
+// that send nothing for 30 seconds.
+func (ch *ChannelConn) StartHeartbeat(interval time.Duration) {
+	// The server answers each heartbeat with its own MSG_SYS_TIME.
+	ch.handlers.LoadOrStore(MSG_SYS_TIME, PacketHandler(func(uint16, []byte) {}))
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ch.done:
+				return
+			case <-ticker.C:
+				if err := ch.SendPacket(BuildTimePacket(false, uint32(time.Now().Unix()))); err != nil {
+					if !ch.closed.Load() {
+						Logf("[channel] heartbeat failed: %v\n", err)
+					}
+					return
+				}
+			}
+		}
+	}()
+}
+
 // Close closes the channel connection.
 func (ch *ChannelConn) Close() error {
-	ch.closed.Store(true)
+	if !ch.closed.Swap(true) {
+		close(ch.done)
+	}
 	return ch.conn.Close()
 }
 
@@ -95,7 +127,7 @@ func (ch *ChannelConn) recvLoop() {
 			if ch.closed.Load() {
 				return
 			}
-			fmt.Printf("[channel] read error: %v\n", err)
+			Logf("[channel] read error: %v\n", err)
 			return
 		}
 
@@ -116,7 +148,7 @@ func (ch *ChannelConn) recvLoop() {
 			if val, ok := ch.handlers.Load(opcode); ok {
 				val.(PacketHandler)(opcode, pkt[2:])
 			} else {
-				fmt.Printf("[channel] recv opcode 0x%04X (%d bytes)\n", opcode, len(pkt))
+				Logf("[channel] recv opcode 0x%04X (%d bytes)\n", opcode, len(pkt))
 			}
 		}
 	}
@@ -172,7 +204,7 @@ func (ch *ChannelConn) handleAck(data []byte) {
 		default:
 		}
 	} else {
-		fmt.Printf("[channel] unexpected ACK handle %d (error=%d, buffer=%v, %d bytes)\n",
+		Logf("[channel] unexpected ACK handle %d (error=%d, buffer=%v, %d bytes)\n",
 			ackHandle, errorCode, isBuffer, len(ackData))
 	}
 }
@@ -184,7 +216,7 @@ func (ch *ChannelConn) handlePing(data []byte) {
 		ackHandle = binary.BigEndian.Uint32(data[0:4])
 	}
 	pkt := BuildPingPacket(ackHandle)
-	if err := ch.conn.SendPacket(pkt); err != nil {
-		fmt.Printf("[channel] ping response failed: %v\n", err)
+	if err := ch.SendPacket(pkt); err != nil {
+		Logf("[channel] ping response failed: %v\n", err)
 	}
 }
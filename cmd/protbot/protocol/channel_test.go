@@ -0,0 +1,52 @@
+package protocol
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"erupe-ce/cmd/protbot/conn"
+)
+
+// TestHeartbeat verifies that an idle channel connection keeps sending
+// MSG_SYS_TIME until it is closed.
+func TestHeartbeat(t *testing.T) {
+	SetOutput(io.Discard)
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = l.Close() }()
+
+	ch, err := ConnectChannel(l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, err := l.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = raw.Close() }()
+	_ = raw.SetReadDeadline(time.Now().Add(5 * time.Second))
+	server := conn.NewCryptConn(raw)
+
+	ch.StartHeartbeat(10 * time.Millisecond)
+	for i := 0; i < 2; i++ {
+		pkt, err := server.ReadPacket()
+		if err != nil {
+			t.Fatalf("heartbeat %d: %v", i, err)
+		}
+		if op := binary.BigEndian.Uint16(pkt); op != MSG_SYS_TIME {
+			t.Fatalf("heartbeat %d: opcode 0x%04X, want 0x%04X", i, op, MSG_SYS_TIME)
+		}
+	}
+
+	_ = ch.Close()
+	for {
+		if _, err := server.ReadPacket(); err != nil {
+			break // The connection closed; no heartbeat outlives Close.
+		}
+	}
+}
@@ -0,0 +1,28 @@
+package protocol
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+var (
+	outputMu sync.Mutex
+	output   io.Writer = os.Stdout
+)
+
+// SetOutput sets where progress messages from this package and scenario are
+// written. Tests and load runs with many bots pass io.Discard.
+func SetOutput(w io.Writer) {
+	outputMu.Lock()
+	defer outputMu.Unlock()
+	output = w
+}
+
+// Logf writes a progress message.
+func Logf(format string, args ...any) {
+	outputMu.Lock()
+	defer outputMu.Unlock()
+	_, _ = fmt.Fprintf(output, format, args...)
+}
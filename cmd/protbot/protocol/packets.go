@@ -88,6 +88,22 @@ func BuildPingPacket(ackHandle uint32) []byte {
 	return bf.Data()
 }
 
+// BuildTimePacket builds a MSG_SYS_TIME packet, which the client sends
+// periodically as a heartbeat.
+//
+//	uint16 opcode
+//	uint8  getRemoteTime (bool)
+//	uint32 timestamp
+//	0x00 0x10 terminator
+func BuildTimePacket(getRemoteTime bool, timestamp uint32) []byte {
+	bf := byteframe.NewByteFrame()
+	bf.WriteUint16(MSG_SYS_TIME)
+	bf.WriteBool(getRemoteTime)
+	bf.WriteUint32(timestamp)
+	bf.WriteBytes([]byte{0x00, 0x10})
+	return bf.Data()
+}
+
 // BuildLogoutPacket builds a MSG_SYS_LOGOUT packet.
 //
 //	uint16 opcode
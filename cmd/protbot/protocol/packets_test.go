@@ -171,6 +171,26 @@ func TestBuildPingPacket(t *testing.T) {
 	}
 }
 
+// TestBuildTimePacket verifies MSG_SYS_TIME binary layout.
+func TestBuildTimePacket(t *testing.T) {
+	pkt := BuildTimePacket(true, 1700000000)
+	bf := byteframe.NewByteFrameFromBytes(pkt)
+
+	if op := bf.ReadUint16(); op != MSG_SYS_TIME {
+		t.Fatalf("opcode: got 0x%04X, want 0x%04X", op, MSG_SYS_TIME)
+	}
+	if get := bf.ReadBool(); !get {
+		t.Fatal("getRemoteTime: got false, want true")
+	}
+	if ts := bf.ReadUint32(); ts != 1700000000 {
+		t.Fatalf("timestamp: got %d, want 1700000000", ts)
+	}
+	term := bf.ReadBytes(2)
+	if term[0] != 0x00 || term[1] != 0x10 {
+		t.Fatalf("terminator: got %02X %02X, want 00 10", term[0], term[1])
+	}
+}
+
 // TestBuildLogoutPacket verifies MSG_SYS_LOGOUT binary layout.
 func TestBuildLogoutPacket(t *testing.T) {
 	pkt := BuildLogoutPacket()
@@ -1,8 +1,6 @@
 package scenario
 
 import (
-	"fmt"
-
 	"erupe-ce/common/byteframe"
 	"erupe-ce/common/stringsupport"
 
@@ -21,7 +19,7 @@ type ChatMessage struct {
 func SendChat(ch *protocol.ChannelConn, broadcastType, chatType uint8, message, senderName string) error {
 	payload := protocol.BuildChatPayload(chatType, message, senderName)
 	pkt := protocol.BuildCastBinaryPacket(broadcastType, 1, payload)
-	fmt.Printf("[chat] Sending chat (type=%d, broadcast=%d): %s\n", chatType, broadcastType, message)
+	protocol.Logf("[chat] Sending chat (type=%d, broadcast=%d): %s\n", chatType, broadcastType, message)
 	return ch.SendPacket(pkt)
 }
 
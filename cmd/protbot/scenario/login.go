@@ -15,15 +15,22 @@ type LoginResult struct {
 	Channel *protocol.ChannelConn
 }
 
-// Login performs the full sign → entrance → channel login flow.
+// Login performs the full sign → entrance → channel login flow on the first
+// channel.
 func Login(signAddr, username, password string) (*LoginResult, error) {
+	return LoginChannel(signAddr, username, password, 0)
+}
+
+// LoginChannel performs the full login flow on the channel at index channel
+// of the entrance server list, wrapping around if there are fewer.
+func LoginChannel(signAddr, username, password string, channel int) (*LoginResult, error) {
 	// Step 1: Sign server authentication.
-	fmt.Printf("[sign] Connecting to %s...\n", signAddr)
+	protocol.Logf("[sign] Connecting to %s...\n", signAddr)
 	sign, err := protocol.DoSign(signAddr, username, password)
 	if err != nil {
 		return nil, fmt.Errorf("sign: %w", err)
 	}
-	fmt.Printf("[sign] OK — tokenID=%d, %d character(s), entrance=%s\n",
+	protocol.Logf("[sign] OK — tokenID=%d, %d character(s), entrance=%s\n",
 		sign.TokenID, len(sign.CharIDs), sign.EntranceAddr)
 
 	if len(sign.CharIDs) == 0 {
@@ -31,7 +38,7 @@ func Login(signAddr, username, password string) (*LoginResult, error) {
 	}
 
 	// Step 2: Entrance server — get server/channel list.
-	fmt.Printf("[entrance] Connecting to %s...\n", sign.EntranceAddr)
+	protocol.Logf("[entrance] Connecting to %s...\n", sign.EntranceAddr)
 	servers, err := protocol.DoEntrance(sign.EntranceAddr)
 	if err != nil {
 		return nil, fmt.Errorf("entrance: %w", err)
@@ -40,13 +47,13 @@ func Login(signAddr, username, password string) (*LoginResult, error) {
 		return nil, fmt.Errorf("no channels available")
 	}
 	for i, s := range servers {
-		fmt.Printf("[entrance]   [%d] %s — %s:%d\n", i, s.Name, s.IP, s.Port)
+		protocol.Logf("[entrance]   [%d] %s — %s:%d\n", i, s.Name, s.IP, s.Port)
 	}
 
-	// Step 3: Connect to the first channel server.
-	first := servers[0]
-	channelAddr := fmt.Sprintf("%s:%d", first.IP, first.Port)
-	fmt.Printf("[channel] Connecting to %s...\n", channelAddr)
+	// Step 3: Connect to the channel server.
+	target := servers[channel%len(servers)]
+	channelAddr := fmt.Sprintf("%s:%d", target.IP, target.Port)
+	protocol.Logf("[channel] Connecting to %s...\n", channelAddr)
 	ch, err := protocol.ConnectChannel(channelAddr)
 	if err != nil {
 		return nil, fmt.Errorf("channel connect: %w", err)
@@ -56,7 +63,7 @@ func Login(signAddr, username, password string) (*LoginResult, error) {
 	charID := sign.CharIDs[0]
 	ack := ch.NextAckHandle()
 	loginPkt := protocol.BuildLoginPacket(ack, charID, sign.TokenID, sign.TokenString)
-	fmt.Printf("[channel] Sending MSG_SYS_LOGIN (charID=%d, ackHandle=%d)...\n", charID, ack)
+	protocol.Logf("[channel] Sending MSG_SYS_LOGIN (charID=%d, ackHandle=%d)...\n", charID, ack)
 	if err := ch.SendPacket(loginPkt); err != nil {
 		_ = ch.Close()
 		return nil, fmt.Errorf("channel send login: %w", err)
@@ -71,7 +78,7 @@ func Login(signAddr, username, password string) (*LoginResult, error) {
 		_ = ch.Close()
 		return nil, fmt.Errorf("channel login failed: error code %d", resp.ErrorCode)
 	}
-	fmt.Printf("[channel] Login ACK received (error=%d, %d bytes data)\n",
+	protocol.Logf("[channel] Login ACK received (error=%d, %d bytes data)\n",
 		resp.ErrorCode, len(resp.Data))
 
 	return &LoginResult{
@@ -8,7 +8,7 @@ import (
 
 // Logout sends MSG_SYS_LOGOUT and closes the channel connection.
 func Logout(ch *protocol.ChannelConn) error {
-	fmt.Println("[logout] Sending MSG_SYS_LOGOUT...")
+	protocol.Logf("[logout] Sending MSG_SYS_LOGOUT...\n")
 	if err := ch.SendPacket(protocol.BuildLogoutPacket()); err != nil {
 		_ = ch.Close()
 		return fmt.Errorf("logout send: %w", err)
@@ -11,7 +11,7 @@ import (
 func EnumerateQuests(ch *protocol.ChannelConn, world uint8, counter uint16) ([]byte, error) {
 	ack := ch.NextAckHandle()
 	pkt := protocol.BuildEnumerateQuestPacket(ack, world, counter, 0)
-	fmt.Printf("[quest] Sending MSG_MHF_ENUMERATE_QUEST (world=%d, counter=%d, ackHandle=%d)...\n",
+	protocol.Logf("[quest] Sending MSG_MHF_ENUMERATE_QUEST (world=%d, counter=%d, ackHandle=%d)...\n",
 		world, counter, ack)
 	if err := ch.SendPacket(pkt); err != nil {
 		return nil, fmt.Errorf("enumerate quest send: %w", err)
@@ -24,7 +24,7 @@ func EnumerateQuests(ch *protocol.ChannelConn, world uint8, counter uint16) ([]b
 	if resp.ErrorCode != 0 {
 		return nil, fmt.Errorf("enumerate quest failed: error code %d", resp.ErrorCode)
 	}
-	fmt.Printf("[quest] ENUMERATE_QUEST ACK (error=%d, %d bytes data)\n",
+	protocol.Logf("[quest] ENUMERATE_QUEST ACK (error=%d, %d bytes data)\n",
 		resp.ErrorCode, len(resp.Data))
 
 	return resp.Data, nil
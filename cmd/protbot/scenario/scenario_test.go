@@ -0,0 +1,110 @@
+package scenario
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"erupe-ce/cmd/protbot/protocol"
+	cfg "erupe-ce/config"
+	"erupe-ce/server/channelserver"
+	"erupe-ce/server/entranceserver"
+	"erupe-ce/server/signserver"
+
+	"go.uber.org/zap"
+)
+
+// freePort returns a TCP port that was free a moment ago.
+func freePort(t *testing.T) int {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = l.Close() }()
+	return l.Addr().(*net.TCPAddr).Port
+}
+
+// startServers runs a sign, entrance and channel server against the test
+// database, wired as main.go does for a single channel, and returns the
+// sign server's address.
+func startServers(t *testing.T) string {
+	t.Helper()
+	db := channelserver.SetupTestDB(t)
+	config := &cfg.Config{
+		Host:              "127.0.0.1",
+		RealClientMode:    cfg.ZZ,
+		AutoCreateAccount: true,
+		Sign:              cfg.Sign{Enabled: true, Port: freePort(t)},
+		Entrance: cfg.Entrance{Enabled: true, Port: uint16(freePort(t)), Entries: []cfg.EntranceServerInfo{{
+			Name:     "Test",
+			Type:     3,
+			Channels: []cfg.EntranceChannelInfo{{Port: uint16(freePort(t)), MaxPlayers: 100}},
+		}}},
+	}
+	logger := zap.NewNop()
+
+	sign := signserver.NewServer(&signserver.Config{Logger: logger, DB: db, ErupeConfig: config})
+	if err := sign.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(sign.Shutdown)
+	entrance := entranceserver.NewServer(&entranceserver.Config{Logger: logger, DB: db, ErupeConfig: config})
+	if err := entrance.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(entrance.Shutdown)
+
+	const sid = 4096 + 16 // First channel of the first entrance entry
+	channel := channelserver.NewServer(&channelserver.Config{ID: sid, Logger: logger, DB: db, ErupeConfig: config})
+	channel.IP = config.Host
+	channel.Port = config.Entrance.Entries[0].Channels[0].Port
+	channel.GlobalID = "0101"
+	channel.Registry = channelserver.NewLocalChannelRegistry([]*channelserver.Server{channel})
+	if err := channel.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(channel.Shutdown)
+	db.MustExec(`INSERT INTO servers (server_id, current_players, world_name, world_description, land) VALUES ($1, 0, 'Test', '', 1)`, sid)
+
+	return fmt.Sprintf("127.0.0.1:%d", config.Sign.Port)
+}
+
+// TestEndToEnd logs a new account in through every server, enters the lobby,
+// idles on heartbeats past the channel's 30 second timeout and logs out.
+func TestEndToEnd(t *testing.T) {
+	if testing.Short() {
+		t.Skip("idles for over 30 seconds")
+	}
+	signAddr := startServers(t)
+	protocol.SetOutput(io.Discard)
+
+	result, err := Login(signAddr, "e2ebot", "password")
+	if err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+	ch := result.Channel
+	if _, err := SetupSession(ch, result.Sign.CharIDs[0]); err != nil {
+		_ = ch.Close()
+		t.Fatalf("SetupSession: %v", err)
+	}
+	if err := EnterLobby(ch); err != nil {
+		_ = ch.Close()
+		t.Fatalf("EnterLobby: %v", err)
+	}
+
+	ch.StartHeartbeat(5 * time.Second)
+	time.Sleep(35 * time.Second)
+	ack := ch.NextAckHandle()
+	if err := ch.SendPacket(protocol.BuildPingPacket(ack)); err != nil {
+		t.Fatalf("ping send: %v", err)
+	}
+	if _, err := ch.WaitForAck(ack, 5*time.Second); err != nil {
+		t.Errorf("session did not survive idling: %v", err)
+	}
+	if err := Logout(ch); err != nil {
+		t.Errorf("Logout: %v", err)
+	}
+}
@@ -12,7 +12,7 @@ import (
 func SetupSession(ch *protocol.ChannelConn, charID uint32) ([]byte, error) {
 	// Step 1: Issue logkey.
 	ack := ch.NextAckHandle()
-	fmt.Printf("[session] Sending MSG_SYS_ISSUE_LOGKEY (ackHandle=%d)...\n", ack)
+	protocol.Logf("[session] Sending MSG_SYS_ISSUE_LOGKEY (ackHandle=%d)...\n", ack)
 	if err := ch.SendPacket(protocol.BuildIssueLogkeyPacket(ack)); err != nil {
 		return nil, fmt.Errorf("issue logkey send: %w", err)
 	}
@@ -20,11 +20,11 @@ func SetupSession(ch *protocol.ChannelConn, charID uint32) ([]byte, error) {
 	if err != nil {
 		return nil, fmt.Errorf("issue logkey ack: %w", err)
 	}
-	fmt.Printf("[session] ISSUE_LOGKEY ACK (error=%d, %d bytes)\n", resp.ErrorCode, len(resp.Data))
+	protocol.Logf("[session] ISSUE_LOGKEY ACK (error=%d, %d bytes)\n", resp.ErrorCode, len(resp.Data))
 
 	// Step 2: Rights reload.
 	ack = ch.NextAckHandle()
-	fmt.Printf("[session] Sending MSG_SYS_RIGHTS_RELOAD (ackHandle=%d)...\n", ack)
+	protocol.Logf("[session] Sending MSG_SYS_RIGHTS_RELOAD (ackHandle=%d)...\n", ack)
 	if err := ch.SendPacket(protocol.BuildRightsReloadPacket(ack)); err != nil {
 		return nil, fmt.Errorf("rights reload send: %w", err)
 	}
@@ -32,11 +32,11 @@ func SetupSession(ch *protocol.ChannelConn, charID uint32) ([]byte, error) {
 	if err != nil {
 		return nil, fmt.Errorf("rights reload ack: %w", err)
 	}
-	fmt.Printf("[session] RIGHTS_RELOAD ACK (error=%d, %d bytes)\n", resp.ErrorCode, len(resp.Data))
+	protocol.Logf("[session] RIGHTS_RELOAD ACK (error=%d, %d bytes)\n", resp.ErrorCode, len(resp.Data))
 
 	// Step 3: Load save data.
 	ack = ch.NextAckHandle()
-	fmt.Printf("[session] Sending MSG_MHF_LOADDATA (ackHandle=%d)...\n", ack)
+	protocol.Logf("[session] Sending MSG_MHF_LOADDATA (ackHandle=%d)...\n", ack)
 	if err := ch.SendPacket(protocol.BuildLoaddataPacket(ack)); err != nil {
 		return nil, fmt.Errorf("loaddata send: %w", err)
 	}
@@ -44,7 +44,7 @@ func SetupSession(ch *protocol.ChannelConn, charID uint32) ([]byte, error) {
 	if err != nil {
 		return nil, fmt.Errorf("loaddata ack: %w", err)
 	}
-	fmt.Printf("[session] LOADDATA ACK (error=%d, %d bytes)\n", resp.ErrorCode, len(resp.Data))
+	protocol.Logf("[session] LOADDATA ACK (error=%d, %d bytes)\n", resp.ErrorCode, len(resp.Data))
 
 	return resp.Data, nil
 }
@@ -25,7 +25,7 @@ func EnterLobby(ch *protocol.ChannelConn) error {
 	// Step 1: Enumerate stages with "sl1Ns" prefix (main lobby stages).
 	ack := ch.NextAckHandle()
 	enumPkt := protocol.BuildEnumerateStagePacket(ack, "sl1Ns")
-	fmt.Printf("[stage] Sending MSG_SYS_ENUMERATE_STAGE (prefix=\"sl1Ns\", ackHandle=%d)...\n", ack)
+	protocol.Logf("[stage] Sending MSG_SYS_ENUMERATE_STAGE (prefix=\"sl1Ns\", ackHandle=%d)...\n", ack)
 	if err := ch.SendPacket(enumPkt); err != nil {
 		return fmt.Errorf("enumerate stage send: %w", err)
 	}
@@ -39,9 +39,9 @@ func EnterLobby(ch *protocol.ChannelConn) error {
 	}
 
 	stages := parseEnumerateStageResponse(resp.Data)
-	fmt.Printf("[stage] Found %d stage(s)\n", len(stages))
+	protocol.Logf("[stage] Found %d stage(s)\n", len(stages))
 	for i, s := range stages {
-		fmt.Printf("[stage]   [%d] %s — %d/%d players, flags=0x%02X\n",
+		protocol.Logf("[stage]   [%d] %s — %d/%d players, flags=0x%02X\n",
 			i, s.ID, s.Clients, s.MaxPlayers, s.Flags)
 	}
 
@@ -54,7 +54,7 @@ func EnterLobby(ch *protocol.ChannelConn) error {
 
 	ack = ch.NextAckHandle()
 	enterPkt := protocol.BuildEnterStagePacket(ack, stageID)
-	fmt.Printf("[stage] Sending MSG_SYS_ENTER_STAGE (stageID=%q, ackHandle=%d)...\n", stageID, ack)
+	protocol.Logf("[stage] Sending MSG_SYS_ENTER_STAGE (stageID=%q, ackHandle=%d)...\n", stageID, ack)
 	if err := ch.SendPacket(enterPkt); err != nil {
 		return fmt.Errorf("enter stage send: %w", err)
 	}
@@ -66,7 +66,7 @@ func EnterLobby(ch *protocol.ChannelConn) error {
 	if resp.ErrorCode != 0 {
 		return fmt.Errorf("enter stage failed: error code %d", resp.ErrorCode)
 	}
-	fmt.Printf("[stage] Enter stage ACK received (error=%d)\n", resp.ErrorCode)
+	protocol.Logf("[stage] Enter stage ACK received (error=%d)\n", resp.ErrorCode)
 
 	return nil
 }
@@ -0,0 +1,169 @@
+// questbackport converts a directory of ZZ quest files for an older client
+// ahead of time, as AutoQuestBackport does for each file when a client asks
+// for it. Converted files are loaded as the server would before they are
+// written, and the files that cannot be converted are reported.
+//
+// Usage:
+//
+//	questbackport --mode G10 bin/quests quests-g10             # Convert every quest
+//	questbackport --mode F5 --dry-run bin/quests                # Only report what would fail
+//	questbackport --mode S6 --report report.json bin/quests out # Also write the report as JSON
+//
+// The mode defaults to ClientMode in config.json in the working directory.
+// Converted files are written unpacked. Serve them with AutoQuestBackport
+// off, or they are converted a second time.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"erupe-ce/common/decryption"
+	cfg "erupe-ce/config"
+	"erupe-ce/server/channelserver"
+	"erupe-ce/server/channelserver/questfile"
+)
+
+// report is what a run converted and what it could not.
+type report struct {
+	Mode      string    `json:"mode"`
+	DryRun    bool      `json:"dry_run"`
+	Converted int       `json:"converted"`
+	Failed    []failure `json:"failed"`
+}
+
+// failure is a quest that could not be converted.
+type failure struct {
+	File  string `json:"file"`
+	Error string `json:"error"`
+}
+
+func main() {
+	mode := flag.String("mode", "", "Client mode to convert for, as in ClientMode; defaults to config.json's")
+	dryRun := flag.Bool("dry-run", false, "Convert and validate without writing anything")
+	reportOut := flag.String("report", "", "File to write the report to as JSON")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: questbackport [flags] SRC [DST]")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 2 && !(*dryRun && flag.NArg() == 1) {
+		flag.Usage()
+		os.Exit(1)
+	}
+	m := targetMode(*mode)
+	if m > cfg.Z1 {
+		fatalf("%s clients read ZZ quest files as they are, pick a mode before Z2", m)
+	}
+
+	dst := ""
+	if !*dryRun {
+		dst = flag.Arg(1)
+		if err := os.MkdirAll(dst, 0755); err != nil {
+			fatalf("%v", err)
+		}
+	}
+	rep, err := convertDir(flag.Arg(0), dst, m)
+	if err != nil {
+		fatalf("%v", err)
+	}
+	rep.DryRun = *dryRun
+
+	for _, f := range rep.Failed {
+		fmt.Printf("FAIL %s: %s\n", f.File, f.Error)
+	}
+	verb := "Converted"
+	if *dryRun {
+		verb = "Would convert"
+	}
+	fmt.Printf("%s %d quest file(s) for %s, %d failed\n", verb, rep.Converted, m, len(rep.Failed))
+
+	if *reportOut != "" {
+		enc, err := json.MarshalIndent(rep, "", "  ")
+		if err != nil {
+			fatalf("%v", err)
+		}
+		if err := os.WriteFile(*reportOut, append(enc, '\n'), 0644); err != nil {
+			fatalf("%v", err)
+		}
+	}
+	if len(rep.Failed) > 0 {
+		os.Exit(1)
+	}
+}
+
+// targetMode parses the --mode flag, falling back to config.json.
+func targetMode(s string) cfg.Mode {
+	if s == "" {
+		config, err := cfg.LoadConfig()
+		if err != nil {
+			fatalf("--mode not given and config.json could not be loaded: %v", err)
+		}
+		return config.RealClientMode
+	}
+	mode, ok := cfg.ParseMode(s)
+	if !ok {
+		fmt.Fprintf(os.Stderr, "error: unknown mode %q\n", s)
+		flag.Usage()
+		os.Exit(1)
+	}
+	return mode
+}
+
+// convertDir converts every .bin file in src for mode, writing them to dst
+// unless it is empty.
+func convertDir(src, dst string, mode cfg.Mode) (*report, error) {
+	entries, err := os.ReadDir(src)
+	if err != nil {
+		return nil, err
+	}
+	rep := &report{Mode: mode.String(), Failed: []failure{}}
+	var names []string
+	for _, e := range entries {
+		if !e.IsDir() && strings.EqualFold(filepath.Ext(e.Name()), ".bin") {
+			names = append(names, e.Name())
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		data, err := os.ReadFile(filepath.Join(src, name))
+		if err == nil {
+			data, err = convert(data, mode)
+		}
+		if err == nil && dst != "" {
+			err = os.WriteFile(filepath.Join(dst, name), data, 0644)
+		}
+		if err != nil {
+			rep.Failed = append(rep.Failed, failure{File: name, Error: err.Error()})
+			continue
+		}
+		rep.Converted++
+	}
+	return rep, nil
+}
+
+// convert backports a ZZ quest file for mode and checks the server can load
+// the result for a client of mode.
+func convert(data []byte, mode cfg.Mode) ([]byte, error) {
+	converted, err := questfile.Backport(append([]byte(nil), decryption.UnpackSimple(data)...), mode)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := questfile.Parse(converted, mode); err != nil {
+		return nil, err
+	}
+	if err := channelserver.ValidateQuest(converted, mode, false); err != nil {
+		return nil, err
+	}
+	return converted, nil
+}
+
+func fatalf(format string, args ...any) {
+	fmt.Fprintf(os.Stderr, "error: "+format+"\n", args...)
+	os.Exit(1)
+}
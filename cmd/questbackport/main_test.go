@@ -0,0 +1,69 @@
+package main
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+
+	cfg "erupe-ce/config"
+)
+
+// testQuest builds an unpacked ZZ quest file whose eight strings are long
+// enough for the event quest of an older client to reach its minimum size.
+func testQuest() []byte {
+	const body, table = 0x40, 0x40 + 320
+	data := make([]byte, table+8*4)
+	le := binary.LittleEndian
+	le.PutUint32(data, body)
+	le.PutUint32(data[body+0x28:], table)
+	for i := 0; i < 8; i++ {
+		le.PutUint32(data[table+i*4:], uint32(len(data)))
+	}
+	return append(data, "A quest string of some length\x00"...)
+}
+
+func TestConvertDir(t *testing.T) {
+	src, dst := t.TempDir(), t.TempDir()
+	if err := os.WriteFile(filepath.Join(src, "00001d0.bin"), testQuest(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(src, "00002d0.bin"), testQuest()[:0x100], 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(src, "notes.txt"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	rep, err := convertDir(src, "", cfg.G101)
+	if err != nil {
+		t.Fatalf("convertDir() dry run error: %v", err)
+	}
+	if rep.Converted != 1 || len(rep.Failed) != 1 || rep.Failed[0].File != "00002d0.bin" {
+		t.Errorf("dry run report = %+v", rep)
+	}
+	if entries, _ := os.ReadDir(dst); len(entries) != 0 {
+		t.Errorf("dry run wrote %d file(s)", len(entries))
+	}
+
+	if _, err := convertDir(src, dst, cfg.G101); err != nil {
+		t.Fatalf("convertDir() error: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dst, "00001d0.bin")); err != nil {
+		t.Errorf("converted quest not written: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dst, "00002d0.bin")); !os.IsNotExist(err) {
+		t.Errorf("failed quest was written: %v", err)
+	}
+}
+
+func TestConvertLeavesInputAlone(t *testing.T) {
+	data := testQuest()
+	binary.LittleEndian.PutUint32(data[0x40+96+4:], 0xAABBCCDD)
+	if _, err := convert(data, cfg.S6); err != nil {
+		t.Fatalf("convert() error: %v", err)
+	}
+	if binary.LittleEndian.Uint32(data[0x40+96:]) != 0 {
+		t.Error("convert() changed its input")
+	}
+}
@@ -0,0 +1,243 @@
+// questtool inspects and edits quest files, the bin/quests/*.bin files sent
+// to clients, and checks them against the server's quest loader.
+//
+// Usage:
+//
+//	questtool dump 23045d0.bin                                 # Print the known fields as JSON
+//	questtool patch --out 23045d0.new.bin 23045d0.bin edit.json # Apply a partial dump
+//	questtool validate bin/quests/*.bin                        # Load files as the server would
+//
+// A patch has the shape of a dump holding only the fields to change, such as
+// {"reward": 6000, "strings": {"title": "Rich Rathalos"}}. Lists are replaced
+// whole and cannot grow past the slots the file has. Patched files are
+// written unpacked, which the server and clients read as well.
+//
+// The client mode and AutoQuestBackport come from config.json in the working
+// directory, or ZZ without backporting when there is none; --mode and
+// --backport override them.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	cfg "erupe-ce/config"
+	"erupe-ce/server/channelserver"
+	"erupe-ce/server/channelserver/questfile"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(1)
+	}
+	switch os.Args[1] {
+	case "dump":
+		runDump(os.Args[2:])
+	case "patch":
+		runPatch(os.Args[2:])
+	case "validate":
+		runValidate(os.Args[2:])
+	case "-h", "--help", "help":
+		usage()
+	default:
+		fmt.Fprintf(os.Stderr, "error: unknown command %q\n", os.Args[1])
+		usage()
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, `Usage: questtool <command> [flags] [args]
+
+Commands:
+  dump QUEST          Print the known fields of a quest file as JSON
+  patch QUEST PATCH   Apply a partial dump to a quest file
+  validate QUEST...   Load quest files as the server would
+
+Run questtool <command> -h for the command's flags.`)
+}
+
+// options are the flags every command takes.
+type options struct {
+	mode     *string
+	backport *bool
+}
+
+func addOptions(fs *flag.FlagSet) options {
+	return options{
+		mode:     fs.String("mode", "", "Client mode, as in ClientMode; defaults to config.json's, or ZZ"),
+		backport: fs.Bool("backport", false, "Backport files for clients before Z2, as AutoQuestBackport; defaults to config.json's"),
+	}
+}
+
+// resolve returns the client mode and backporting the command runs with.
+func (o options) resolve(fs *flag.FlagSet) (cfg.Mode, bool) {
+	mode, backport := cfg.ZZ, false
+	if _, err := os.Stat("config.json"); err == nil {
+		config, err := cfg.LoadConfig()
+		if err != nil {
+			fatalf("load config: %v", err)
+		}
+		mode, backport = config.RealClientMode, config.DebugOptions.AutoQuestBackport
+	}
+	if *o.mode != "" {
+		var ok bool
+		if mode, ok = cfg.ParseMode(*o.mode); !ok {
+			fmt.Fprintf(os.Stderr, "error: unknown mode %q\n", *o.mode)
+			fs.Usage()
+			os.Exit(1)
+		}
+	}
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == "backport" {
+			backport = *o.backport
+		}
+	})
+	return mode, backport
+}
+
+func runDump(args []string) {
+	fs := flag.NewFlagSet("dump", flag.ExitOnError)
+	opts := addOptions(fs)
+	out := fs.String("out", "", "File to write the JSON to, standard output if empty")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: questtool dump [flags] QUEST")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(1)
+	}
+	mode, _ := opts.resolve(fs)
+
+	data, err := os.ReadFile(fs.Arg(0))
+	if err != nil {
+		fatalf("%v", err)
+	}
+	enc, err := dump(data, mode)
+	if err != nil {
+		fatalf("%s: %v", fs.Arg(0), err)
+	}
+	if *out == "" {
+		fmt.Println(string(enc))
+		return
+	}
+	if err := os.WriteFile(*out, append(enc, '\n'), 0644); err != nil {
+		fatalf("%v", err)
+	}
+}
+
+func runPatch(args []string) {
+	fs := flag.NewFlagSet("patch", flag.ExitOnError)
+	opts := addOptions(fs)
+	out := fs.String("out", "", "File to write the patched quest to (required)")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: questtool patch [flags] QUEST PATCH")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+	if fs.NArg() != 2 || *out == "" {
+		fs.Usage()
+		os.Exit(1)
+	}
+	mode, backport := opts.resolve(fs)
+
+	data, err := os.ReadFile(fs.Arg(0))
+	if err != nil {
+		fatalf("%v", err)
+	}
+	edit, err := os.ReadFile(fs.Arg(1))
+	if err != nil {
+		fatalf("%v", err)
+	}
+	patched, err := patch(data, edit, mode, backport)
+	if err != nil {
+		fatalf("%v", err)
+	}
+	if err := os.WriteFile(*out, patched, 0644); err != nil {
+		fatalf("%v", err)
+	}
+	fmt.Printf("Wrote %d byte quest to %s\n", len(patched), *out)
+}
+
+func runValidate(args []string) {
+	fs := flag.NewFlagSet("validate", flag.ExitOnError)
+	opts := addOptions(fs)
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: questtool validate [flags] QUEST...")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+	if fs.NArg() == 0 {
+		fs.Usage()
+		os.Exit(1)
+	}
+	mode, backport := opts.resolve(fs)
+
+	failed := 0
+	for _, name := range fs.Args() {
+		data, err := os.ReadFile(name)
+		if err == nil {
+			err = validate(data, mode, backport)
+		}
+		if err != nil {
+			failed++
+			fmt.Printf("FAIL %s: %v\n", name, err)
+			continue
+		}
+		fmt.Printf("ok   %s\n", name)
+	}
+	if failed > 0 {
+		fmt.Printf("%d of %d quest file(s) failed for %s\n", failed, fs.NArg(), mode)
+		os.Exit(1)
+	}
+}
+
+// dump returns the known fields of a quest file as indented JSON.
+func dump(data []byte, mode cfg.Mode) ([]byte, error) {
+	q, err := questfile.Parse(data, mode)
+	if err != nil {
+		return nil, err
+	}
+	return json.MarshalIndent(q, "", "  ")
+}
+
+// patch applies edit, a partial dump, to a quest file and returns the
+// unpacked result, refusing one the server could not load.
+func patch(data, edit []byte, mode cfg.Mode, backport bool) ([]byte, error) {
+	q, err := questfile.Parse(data, mode)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(edit))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(q); err != nil {
+		return nil, fmt.Errorf("patch: %w", err)
+	}
+	patched, err := q.Bytes()
+	if err != nil {
+		return nil, err
+	}
+	if err := channelserver.ValidateQuest(patched, mode, backport); err != nil {
+		return nil, fmt.Errorf("patched quest would not load: %w", err)
+	}
+	return patched, nil
+}
+
+// validate checks a quest file parses and loads as the server would.
+func validate(data []byte, mode cfg.Mode, backport bool) error {
+	if _, err := questfile.Parse(data, mode); err != nil {
+		return err
+	}
+	return channelserver.ValidateQuest(data, mode, backport)
+}
+
+func fatalf(format string, args ...any) {
+	fmt.Fprintf(os.Stderr, "error: "+format+"\n", args...)
+	os.Exit(1)
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	cfg "erupe-ce/config"
+)
+
+// testQuest builds an unpacked ZZ quest file with a title and an otherwise
+// empty string table, and no supplies, rewards or monsters.
+func testQuest() []byte {
+	const body, table = 0x40, 0x40 + 320
+	data := make([]byte, table+8*4)
+	le := binary.LittleEndian
+	le.PutUint32(data, body)
+	le.PutUint32(data[body+0x0C:], 3000)
+	le.PutUint32(data[body+0x28:], table)
+	le.PutUint32(data[table:], uint32(len(data)))
+	for i := 1; i < 8; i++ {
+		le.PutUint32(data[table+i*4:], uint32(len(data)+5))
+	}
+	return append(data, "Hunt\x00\x00"...)
+}
+
+func TestDump(t *testing.T) {
+	enc, err := dump(testQuest(), cfg.ZZ)
+	if err != nil {
+		t.Fatalf("dump() error: %v", err)
+	}
+	var got struct {
+		Reward  uint32            `json:"reward"`
+		Strings map[string]string `json:"strings"`
+	}
+	if err := json.Unmarshal(enc, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Reward != 3000 || got.Strings["title"] != "Hunt" {
+		t.Errorf("dump = %s", enc)
+	}
+}
+
+func TestPatch(t *testing.T) {
+	patched, err := patch(testQuest(), []byte(`{"reward": 6000, "strings": {"title": "Hunt more"}}`), cfg.ZZ, false)
+	if err != nil {
+		t.Fatalf("patch() error: %v", err)
+	}
+	enc, err := dump(patched, cfg.ZZ)
+	if err != nil {
+		t.Fatalf("dump() of the patched quest: %v", err)
+	}
+	var got struct {
+		Reward  uint32            `json:"reward"`
+		Strings map[string]string `json:"strings"`
+	}
+	if err := json.Unmarshal(enc, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Reward != 6000 || got.Strings["title"] != "Hunt more" || got.Strings["contractor"] != "" {
+		t.Errorf("patched = %s", enc)
+	}
+
+	for _, bad := range []string{
+		`{"rewrd": 1}`,
+		`{"monsters": [{"id": 1}]}`,
+		`{"strings": {"description": "` + strings.Repeat("x", 600) + `"}}`,
+	} {
+		if _, err := patch(testQuest(), []byte(bad), cfg.ZZ, false); err == nil {
+			t.Errorf("patch(%.40q) succeeded", bad)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	if err := validate(testQuest(), cfg.ZZ, false); err != nil {
+		t.Errorf("validate() error: %v", err)
+	}
+	if err := validate(testQuest()[:0x80], cfg.ZZ, false); err == nil {
+		t.Error("validate() accepted a truncated file")
+	}
+}
@@ -30,13 +30,13 @@ type PacketDiff struct {
 
 func (d PacketDiff) String() string {
 	if d.Actual == nil {
-		if d.Expected.Opcode == 0 {
-			return fmt.Sprintf("#%d: unexpected extra response 0x%04X (%s)",
-				d.Index, d.Expected.Opcode, network.PacketID(d.Expected.Opcode))
-		}
 		return fmt.Sprintf("#%d: expected 0x%04X (%s), got no response",
 			d.Index, d.Expected.Opcode, network.PacketID(d.Expected.Opcode))
 	}
+	if d.Expected.Opcode == 0 {
+		return fmt.Sprintf("#%d: unexpected extra response 0x%04X (%s)",
+			d.Index, d.Actual.Opcode, network.PacketID(d.Actual.Opcode))
+	}
 	if d.OpcodeMismatch {
 		return fmt.Sprintf("#%d: opcode mismatch: expected 0x%04X (%s), got 0x%04X (%s)",
 			d.Index,
@@ -61,69 +61,142 @@ func (d PacketDiff) String() string {
 }
 
 // ComparePackets compares expected server responses against actual responses.
-// Only compares S→C packets (server responses).
-func ComparePackets(expected, actual []pcap.PacketRecord) []PacketDiff {
-	expectedS2C := pcap.FilterByDirection(expected, pcap.DirServerToClient)
-	actualS2C := pcap.FilterByDirection(actual, pcap.DirServerToClient)
+// Only S→C packets (server responses) are compared, each to the response to
+// the same request: with the C→S requests of both, responses are grouped by
+// request and the requests aligned, so one missing or extra request or
+// response does not throw off the ones after it. Responses are aligned by
+// opcode, those in between paired as opcode mismatches. Index is the
+// position of a response among the S→C packets of expected, or of actual
+// for an extra response. rules relaxes the comparison of some responses.
+func ComparePackets(expected, actual []pcap.PacketRecord, rules compareRules) []PacketDiff {
+	a, b := responseExchanges(expected), responseExchanges(actual)
+	// Without the requests of both, the responses are aligned on their own.
+	if !hasRequest(a) || !hasRequest(b) {
+		a, b = []exchange{allResponses(expected)}, []exchange{allResponses(actual)}
+	}
 
 	var diffs []PacketDiff
-	for i, exp := range expectedS2C {
-		if i >= len(actualS2C) {
-			diffs = append(diffs, PacketDiff{
-				Index:    i,
-				Expected: exp,
-				Actual:   nil,
-			})
-			continue
+	for _, p := range alignRequests(a, b) {
+		var exp, act exchange
+		if p.a != nil {
+			exp = *p.a
 		}
-		act := actualS2C[i]
-		if exp.Opcode != act.Opcode {
-			diffs = append(diffs, PacketDiff{
-				Index:          i,
-				Expected:       exp,
-				Actual:         &act,
-				OpcodeMismatch: true,
-			})
-		} else if len(exp.Payload) != len(act.Payload) {
-			diffs = append(diffs, PacketDiff{
-				Index:     i,
-				Expected:  exp,
-				Actual:    &act,
-				SizeDelta: len(act.Payload) - len(exp.Payload),
-			})
-		} else {
-			// Same opcode and size — check for byte-level diffs.
-			byteDiffs := comparePayloads(exp.Payload, act.Payload)
-			if len(byteDiffs) > 0 {
-				diffs = append(diffs, PacketDiff{
-					Index:        i,
-					Expected:     exp,
-					Actual:       &act,
-					PayloadDiffs: byteDiffs,
-				})
-			}
+		if p.b != nil {
+			act = *p.b
+		}
+		diffs = append(diffs, compareResponses(exp, act, rules)...)
+	}
+	return diffs
+}
+
+// responseExchanges groups records by request, indexing the responses by
+// their position among the S→C packets.
+func responseExchanges(records []pcap.PacketRecord) []exchange {
+	indexes := make([]int, len(records))
+	n := 0
+	for i, rec := range records {
+		if rec.Direction == pcap.DirServerToClient {
+			indexes[i] = n
+			n++
+		}
+	}
+	return exchanges(records, indexes)
+}
+
+func hasRequest(ex []exchange) bool {
+	for _, e := range ex {
+		if e.request != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// allResponses returns the S→C packets of records as one exchange with no
+// request.
+func allResponses(records []pcap.PacketRecord) exchange {
+	e := exchange{index: -1}
+	for _, rec := range records {
+		if rec.Direction == pcap.DirServerToClient {
+			e.responseIndexes = append(e.responseIndexes, len(e.responses))
+			e.responses = append(e.responses, rec)
 		}
 	}
+	return e
+}
 
-	// Extra actual packets beyond expected.
-	for i := len(expectedS2C); i < len(actualS2C); i++ {
-		act := actualS2C[i]
-		diffs = append(diffs, PacketDiff{
-			Index:    i,
-			Expected: pcap.PacketRecord{},
-			Actual:   &act,
-		})
+// compareResponses compares the responses of two exchanges with the same
+// request, either of which may have none. Responses rules skips are left
+// out before they are aligned.
+func compareResponses(exp, act exchange, rules compareRules) []PacketDiff {
+	request := exp.request
+	if request == nil {
+		request = act.request
 	}
+	exp, act = rules.keep(request, exp), rules.keep(request, act)
 
+	var diffs []PacketDiff
+	var missing, extra []int // Responses only one has, since the last pair
+	// A response only expected has and one only actual has, in between the
+	// same pairs, are one response that differs.
+	flush := func() {
+		for len(missing) > 0 && len(extra) > 0 {
+			diffs = append(diffs, comparePacket(exp, missing[0], act, extra[0], compareRule{})...)
+			missing, extra = missing[1:], extra[1:]
+		}
+		for _, i := range missing {
+			diffs = append(diffs, PacketDiff{Index: exp.responseIndexes[i], Expected: exp.responses[i]})
+		}
+		for _, j := range extra {
+			rec := act.responses[j]
+			diffs = append(diffs, PacketDiff{Index: act.responseIndexes[j], Expected: pcap.PacketRecord{}, Actual: &rec})
+		}
+		missing, extra = nil, nil
+	}
+	same := func(i, j int) bool { return exp.responses[i].Opcode == act.responses[j].Opcode }
+	for _, p := range alignSequences(len(exp.responses), len(act.responses), same) {
+		switch {
+		case p[1] < 0:
+			missing = append(missing, p[0])
+		case p[0] < 0:
+			extra = append(extra, p[1])
+		default:
+			flush()
+			diffs = append(diffs, comparePacket(exp, p[0], act, p[1], rules.rule(request, exp.responses[p[0]]))...)
+		}
+	}
+	flush()
 	return diffs
 }
 
-// comparePayloads returns byte-level diffs between two equal-length payloads.
-// Returns at most maxPayloadDiffs entries.
-func comparePayloads(expected, actual []byte) []ByteDiff {
+// comparePacket compares response i of exp to response j of act as rule
+// allows.
+func comparePacket(exp exchange, i int, act exchange, j int, rule compareRule) []PacketDiff {
+	e, a := exp.responses[i], act.responses[j]
+	d := PacketDiff{Index: exp.responseIndexes[i], Expected: e, Actual: &a}
+	switch {
+	case e.Opcode != a.Opcode:
+		d.OpcodeMismatch = true
+	case len(e.Payload) != len(a.Payload):
+		d.SizeDelta = len(a.Payload) - len(e.Payload)
+		if max(d.SizeDelta, -d.SizeDelta) <= rule.SizeDelta {
+			return nil
+		}
+	default:
+		// Same opcode and size — check for byte-level diffs.
+		if d.PayloadDiffs = comparePayloads(e.Payload, a.Payload, rule); len(d.PayloadDiffs) == 0 {
+			return nil
+		}
+	}
+	return []PacketDiff{d}
+}
+
+// comparePayloads returns byte-level diffs between two equal-length payloads,
+// but for the bytes rule ignores. Returns at most maxPayloadDiffs entries.
+func comparePayloads(expected, actual []byte, rule compareRule) []ByteDiff {
 	var diffs []ByteDiff
 	for i := 0; i < len(expected) && len(diffs) < maxPayloadDiffs; i++ {
-		if expected[i] != actual[i] {
+		if expected[i] != actual[i] && !rule.ignores(i) {
 			diffs = append(diffs, ByteDiff{
 				Offset:   i,
 				Expected: expected[i],
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"erupe-ce/network/pcap"
+)
+
+// logSlack is how long after the last packet of a capture correlate keeps
+// showing log entries, for those the server wrote as the session ended.
+const logSlack = time.Second
+
+// logTimeLayout is how both zap encoders the server logs with write times.
+const logTimeLayout = "2006-01-02T15:04:05.000Z0700"
+
+// logEntry is one entry of a server log.
+type logEntry struct {
+	ts     time.Time
+	level  string
+	logger string
+	msg    string
+	fields string   // The rest of the entry, as JSON
+	more   []string // Lines following it, such as a stack trace
+}
+
+// parseLogTime reads the time of an entry, as zap writes it or as RFC
+// 3339.
+func parseLogTime(s string) (time.Time, bool) {
+	for _, layout := range []string{logTimeLayout, time.RFC3339Nano} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// parseLogLine reads a line of a log in the json or console format of
+// Logging.Format. It reports false for a line that does not start an
+// entry.
+func parseLogLine(line string) (logEntry, bool) {
+	if strings.HasPrefix(line, "{") {
+		var m map[string]any
+		if json.Unmarshal([]byte(line), &m) != nil {
+			return logEntry{}, false
+		}
+		var e logEntry
+		switch ts := m["ts"].(type) {
+		case string:
+			t, ok := parseLogTime(ts)
+			if !ok {
+				return logEntry{}, false
+			}
+			e.ts = t
+		case float64:
+			e.ts = time.Unix(0, int64(ts*float64(time.Second)))
+		default:
+			return logEntry{}, false
+		}
+		e.level, _ = m["level"].(string)
+		e.logger, _ = m["logger"].(string)
+		e.msg, _ = m["msg"].(string)
+		for _, k := range []string{"ts", "level", "logger", "msg", "caller", "stacktrace"} {
+			delete(m, k)
+		}
+		if len(m) > 0 {
+			b, _ := json.Marshal(m)
+			e.fields = string(b)
+		}
+		return e, true
+	}
+
+	// Time, level, logger (when named), caller, message and fields, tab
+	// separated.
+	parts := strings.Split(line, "\t")
+	if len(parts) < 4 {
+		return logEntry{}, false
+	}
+	t, ok := parseLogTime(parts[0])
+	if !ok {
+		return logEntry{}, false
+	}
+	e := logEntry{ts: t, level: strings.ToLower(parts[1])}
+	rest := parts[2:]
+	if len(rest) >= 3 && strings.Contains(rest[1], ".go:") {
+		e.logger, rest = rest[0], rest[1:]
+	}
+	rest = rest[1:] // Caller
+	e.msg = rest[0]
+	if len(rest) > 1 {
+		e.fields = strings.Join(rest[1:], "\t")
+	}
+	return e, true
+}
+
+// readLog reads the entries of a server log in time order. Lines before
+// the first entry are dropped.
+func readLog(r io.Reader) ([]logEntry, error) {
+	var entries []logEntry
+	sc := bufio.NewScanner(r)
+	sc.Buffer(nil, 1<<20)
+	for sc.Scan() {
+		line := strings.TrimRight(sc.Text(), "\r")
+		if e, ok := parseLogLine(line); ok {
+			entries = append(entries, e)
+		} else if len(entries) > 0 && line != "" {
+			last := &entries[len(entries)-1]
+			last.more = append(last.more, line)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].ts.Before(entries[j].ts) })
+	return entries, nil
+}
+
+// writeLogLine writes a log entry in the timeline of a capture.
+func writeLogLine(w io.Writer, e logEntry, startNs int64) {
+	elapsed := time.Duration(e.ts.UnixNano() - startNs)
+	msg := e.msg
+	if e.logger != "" {
+		msg = e.logger + ": " + msg
+	}
+	line := fmt.Sprintf("  log  +%-12s  %-5s %s", elapsed, strings.ToUpper(e.level), msg)
+	if e.fields != "" {
+		line += "  " + e.fields
+	}
+	fmt.Fprintln(w, line)
+	for _, m := range e.more {
+		fmt.Fprintf(w, "        %s\n", m)
+	}
+}
+
+// correlate writes the packets of r the filter keeps with the log entries
+// written from the start of the capture to logSlack after its last packet,
+// in time order. An entry written at the same time as a packet comes
+// after it.
+func correlate(w io.Writer, r *pcap.Reader, filter packetFilter, entries []logEntry, decode bool) error {
+	records, indexes, err := readPackets(r, filter)
+	if err != nil {
+		return err
+	}
+	startNs := r.Header.SessionStartNs
+	endNs := startNs
+	if len(records) > 0 {
+		endNs = records[len(records)-1].TimestampNs
+	}
+	endNs += int64(logSlack)
+
+	next := sort.Search(len(entries), func(i int) bool { return entries[i].ts.UnixNano() >= startNs })
+	logged := 0
+	ctx := captureContext(r.Header)
+	showLogs := func(untilNs int64, inclusive bool) {
+		for ; next < len(entries); next++ {
+			ns := entries[next].ts.UnixNano()
+			if ns > endNs || ns > untilNs || !inclusive && ns == untilNs {
+				return
+			}
+			writeLogLine(w, entries[next], startNs)
+			logged++
+		}
+	}
+	for i, rec := range records {
+		showLogs(rec.TimestampNs, false)
+		writePacketLine(w, indexes[i], rec, startNs)
+		if decode {
+			writeDecoded(w, rec, packetServerType(r, rec), ctx)
+		}
+	}
+	showLogs(endNs, true)
+
+	fmt.Fprintf(w, "\nTotal: %d packets, %d log entries", len(records), logged)
+	if skipped := len(entries) - logged; skipped > 0 {
+		fmt.Fprintf(w, " (%d outside the capture)", skipped)
+	}
+	fmt.Fprintln(w)
+	return nil
+}
+
+// runCorrelate prints the packets of the capture at path interleaved with
+// the entries of the server log at logPath written while it was recorded,
+// showing what the server logged around each request.
+func runCorrelate(path string, filter packetFilter, logPath string, decode bool) error {
+	if logPath == "" {
+		return errors.New("--log is required")
+	}
+	lf, err := os.Open(logPath)
+	if err != nil {
+		return err
+	}
+	entries, err := readLog(lf)
+	_ = lf.Close()
+	if err != nil {
+		return fmt.Errorf("read log: %w", err)
+	}
+	if len(entries) == 0 {
+		return fmt.Errorf("no log entries in %s", logPath)
+	}
+
+	r, f, err := openCapture(path)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = f.Close() }()
+	printCaptureHeader(os.Stdout, path, r)
+	return correlate(os.Stdout, r, filter, entries, decode)
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"erupe-ce/network/pcap"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// logged is an entry serverLog writes.
+type logged struct {
+	at  time.Time
+	msg string
+}
+
+// serverLog writes entries the way server/logging does in format.
+func serverLog(t *testing.T, format string, entries ...logged) string {
+	t.Helper()
+	var enc zapcore.Encoder
+	if format == "console" {
+		enc = zapcore.NewConsoleEncoder(zap.NewDevelopmentEncoderConfig())
+	} else {
+		ec := zap.NewProductionEncoderConfig()
+		ec.EncodeTime = zapcore.ISO8601TimeEncoder
+		enc = zapcore.NewJSONEncoder(ec)
+	}
+	var buf bytes.Buffer
+	core := zapcore.NewCore(enc, zapcore.AddSync(&buf), zapcore.DebugLevel)
+	for _, e := range entries {
+		ce := core.With([]zapcore.Field{zap.Uint32("charID", 3)})
+		entry := zapcore.Entry{Level: zapcore.InfoLevel, Time: e.at, LoggerName: "channel-1", Message: e.msg,
+			Caller: zapcore.NewEntryCaller(0, "server/channelserver/handlers.go", 42, true)}
+		if err := ce.Write(entry, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return buf.String()
+}
+
+func TestParseLogLine(t *testing.T) {
+	at := time.Date(2024, 3, 6, 12, 0, 0, 123e6, time.UTC)
+	for _, format := range []string{"json", "console"} {
+		line := strings.TrimSpace(serverLog(t, format, logged{at, "Loaded character"}))
+		e, ok := parseLogLine(line)
+		if !ok || !e.ts.Equal(at) || e.level != "info" || e.logger != "channel-1" || e.msg != "Loaded character" || !strings.Contains(e.fields, `"charID"`) {
+			t.Errorf("%s: parseLogLine(%q) = %+v, %v", format, line, e, ok)
+		}
+	}
+	if _, ok := parseLogLine("goroutine 1 [running]:"); ok {
+		t.Error("parseLogLine() took a stack trace line for an entry")
+	}
+}
+
+func TestCorrelate(t *testing.T) {
+	start := time.Date(2024, 3, 6, 12, 0, 0, 0, time.UTC)
+	at := func(ms int) time.Time { return start.Add(time.Duration(ms) * time.Millisecond) }
+	path := filepath.Join(t.TempDir(), "channel.mhfr")
+	writeCapture(t, path, pcap.ServerTypeChannel, start.UnixNano(), pcap.SessionMetadata{}, []pcap.PacketRecord{
+		packet(at(100).UnixNano(), 0x0013), packet(at(300).UnixNano(), 0x0017),
+	})
+	log := serverLog(t, "console",
+		logged{at(-500), "Before the session"},
+		logged{at(150), "Handled login"},
+		logged{at(100), "Same time as the login"}, // Entries of goroutines can be written out of order
+		logged{at(1200), "Session closed"},
+		logged{at(5000), "Long after"},
+	) + "goroutine 1 [running]:\n"
+
+	entries, err := readLog(strings.NewReader(log))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 5 || len(entries[4].more) != 1 {
+		t.Fatalf("read %d entries, last %+v", len(entries), entries[len(entries)-1])
+	}
+	r, f, err := openCapture(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = f.Close() }()
+	var out strings.Builder
+	if err := correlate(&out, r, packetFilter{}, entries, false); err != nil {
+		t.Fatal(err)
+	}
+
+	var order []string
+	for _, line := range strings.Split(out.String(), "\n") {
+		switch {
+		case strings.HasPrefix(line, "#"):
+			order = append(order, strings.Fields(line)[0])
+		case strings.HasPrefix(line, "  log"):
+			order = append(order, line[strings.Index(line, "channel-1: ")+len("channel-1: "):strings.Index(line, "  {")])
+		}
+	}
+	want := "#0000|Same time as the login|Handled login|#0001|Session closed"
+	if got := strings.Join(order, "|"); got != want {
+		t.Errorf("timeline %s, want %s\n%s", got, want, out.String())
+	}
+	if !strings.Contains(out.String(), "+150ms") || !strings.Contains(out.String(), "2 packets, 3 log entries (2 outside the capture)") {
+		t.Errorf("output:\n%s", out.String())
+	}
+}
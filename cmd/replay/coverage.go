@@ -0,0 +1,135 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"erupe-ce/network"
+	"erupe-ce/network/clientctx"
+	"erupe-ce/network/mhfpacket"
+	"erupe-ce/network/pcap"
+	"erupe-ce/server/channelserver"
+)
+
+// opcodeCoverage is how the channel server handles one opcode a client
+// sent in the captures.
+type opcodeCoverage struct {
+	opcode      uint16
+	count       int
+	state       channelserver.HandlerState
+	noParser    bool // mhfpacket has no parser, so the server drops it unread
+	parseFailed int  // Packets the parser rejected
+}
+
+// coverageReport collects the client opcodes of channel captures.
+type coverageReport struct {
+	opcodes map[uint16]*opcodeCoverage
+}
+
+func (c *coverageReport) add(rec pcap.PacketRecord, ctx *clientctx.ClientContext) {
+	if rec.Direction != pcap.DirClientToServer {
+		return
+	}
+	oc := c.opcodes[rec.Opcode]
+	if oc == nil {
+		id := network.PacketID(rec.Opcode)
+		oc = &opcodeCoverage{
+			opcode:   rec.Opcode,
+			state:    channelserver.Handler(id),
+			noParser: mhfpacket.FromOpcode(id) == nil,
+		}
+		c.opcodes[rec.Opcode] = oc
+	}
+	oc.count++
+	if !oc.noParser {
+		if _, err := decodePacket(rec, ctx); err != nil {
+			oc.parseFailed++
+		}
+	}
+}
+
+// write prints the opcodes the server drops, ignores or cannot parse,
+// the most sent first.
+func (c *coverageReport) write(w io.Writer) {
+	sorted := make([]*opcodeCoverage, 0, len(c.opcodes))
+	counts := map[channelserver.HandlerState][2]int{} // Opcodes and packets
+	for _, oc := range c.opcodes {
+		sorted = append(sorted, oc)
+		n := counts[oc.state]
+		counts[oc.state] = [2]int{n[0] + 1, n[1] + oc.count}
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].count != sorted[j].count {
+			return sorted[i].count > sorted[j].count
+		}
+		return sorted[i].opcode < sorted[j].opcode
+	})
+
+	fmt.Fprintf(w, "Client opcodes: %d\n", len(c.opcodes))
+	for _, s := range []channelserver.HandlerState{channelserver.HandlerImplemented, channelserver.HandlerStub, channelserver.HandlerMissing} {
+		fmt.Fprintf(w, "  %-12s %4d opcodes %8d packets\n", s, counts[s][0], counts[s][1])
+	}
+
+	section := func(title string, keep func(*opcodeCoverage) bool) {
+		var rows []*opcodeCoverage
+		for _, oc := range sorted {
+			if keep(oc) {
+				rows = append(rows, oc)
+			}
+		}
+		fmt.Fprintf(w, "\n%s: %d\n", title, len(rows))
+		for _, oc := range rows {
+			note := ""
+			switch {
+			case oc.noParser:
+				note = "no parser"
+			case oc.parseFailed > 0:
+				note = fmt.Sprintf("%d not parsed", oc.parseFailed)
+			}
+			fmt.Fprintf(w, "  0x%04X   %-35s %8d  %s\n", oc.opcode, network.PacketID(oc.opcode), oc.count, note)
+		}
+	}
+	section("No handler", func(oc *opcodeCoverage) bool { return oc.state == channelserver.HandlerMissing })
+	section("Stub handler", func(oc *opcodeCoverage) bool { return oc.state == channelserver.HandlerStub })
+	section("Not parsed", func(oc *opcodeCoverage) bool { return oc.noParser || oc.parseFailed > 0 })
+}
+
+// runCoverage reports which opcodes clients sent in the captures at paths
+// the channel server has no handler for, only a stub handler, or cannot
+// parse. Sign and entrance captures carry no channel packets and are
+// skipped.
+func runCoverage(paths []string, filter packetFilter) error {
+	c := &coverageReport{opcodes: map[uint16]*opcodeCoverage{}}
+	var used []string
+	for _, p := range paths {
+		r, f, err := openCapture(p)
+		if err != nil {
+			return fmt.Errorf("%s: %w", p, err)
+		}
+		if r.Header.ServerType != pcap.ServerTypeChannel {
+			_ = f.Close()
+			fmt.Fprintf(os.Stderr, "Skipping %s: a %s capture has no channel packets\n", p, r.Header.ServerType)
+			continue
+		}
+		ctx := captureContext(r.Header)
+		err = eachPacket(r, filter, func(_ int, rec pcap.PacketRecord) error {
+			c.add(rec, ctx)
+			return nil
+		})
+		_ = f.Close()
+		if err != nil {
+			return fmt.Errorf("%s: %w", p, err)
+		}
+		used = append(used, p)
+	}
+	if len(used) == 0 {
+		fmt.Println("No channel captures")
+		return nil
+	}
+	fmt.Printf("=== Opcode Coverage: %s ===\n", strings.Join(used, ", "))
+	c.write(os.Stdout)
+	return nil
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	cfg "erupe-ce/config"
+	"erupe-ce/network/clientctx"
+	"erupe-ce/network/pcap"
+	"erupe-ce/server/channelserver"
+)
+
+func TestCoverageReport(t *testing.T) {
+	ctx := &clientctx.ClientContext{RealClientMode: cfg.ZZ}
+	c := &coverageReport{opcodes: map[uint16]*opcodeCoverage{}}
+	for _, rec := range []pcap.PacketRecord{
+		{Direction: pcap.DirClientToServer, Opcode: 0x0017, Payload: []byte{0x00, 0x17, 0x00, 0x00, 0x00, 0x01}},
+		{Direction: pcap.DirClientToServer, Opcode: 0x0017, Payload: []byte{0x00, 0x17}}, // Truncated
+		{Direction: pcap.DirClientToServer, Opcode: 0xFFFF, Payload: []byte{0xFF, 0xFF}},
+		{Direction: pcap.DirServerToClient, Opcode: 0x0012, Payload: []byte{0x00, 0x12}},
+	} {
+		c.add(rec, ctx)
+	}
+	if len(c.opcodes) != 2 {
+		t.Fatalf("%d opcodes, want the 2 the client sent", len(c.opcodes))
+	}
+	ping := c.opcodes[0x0017]
+	if ping.count != 2 || ping.state != channelserver.HandlerImplemented || ping.parseFailed != 1 {
+		t.Errorf("MSG_SYS_PING %+v", ping)
+	}
+	if unknown := c.opcodes[0xFFFF]; unknown.state != channelserver.HandlerMissing || !unknown.noParser {
+		t.Errorf("unknown opcode %+v", unknown)
+	}
+
+	var sb strings.Builder
+	c.write(&sb)
+	out := sb.String()
+	for _, want := range []string{"implemented     1 opcodes        2 packets", "No handler: 1", "0xFFFF", "no parser", "Not parsed: 2", "1 not parsed"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("report missing %q:\n%s", want, out)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+
+	"erupe-ce/common/byteframe"
+	cfg "erupe-ce/config"
+	"erupe-ce/network"
+	"erupe-ce/network/clientctx"
+	"erupe-ce/network/mhfpacket"
+	"erupe-ce/network/pcap"
+)
+
+// maxInlineBytes is the longest byte field printed on one line; longer
+// ones are hexdumped below their name.
+const maxInlineBytes = 32
+
+// decodePacket parses a channel server packet with the mhfpacket parser
+// for its opcode. Payloads start with the 2-byte opcode.
+func decodePacket(rec pcap.PacketRecord, ctx *clientctx.ClientContext) (pkt mhfpacket.MHFPacket, err error) {
+	if len(rec.Payload) < 2 {
+		return nil, errors.New("no opcode")
+	}
+	pkt = mhfpacket.FromOpcode(network.PacketID(rec.Opcode))
+	if pkt == nil {
+		return nil, errors.New("unknown opcode")
+	}
+	// Parsers trust the client's lengths, so a corrupt capture can panic them.
+	defer func() {
+		if r := recover(); r != nil {
+			pkt, err = nil, fmt.Errorf("parser panicked: %v", r)
+		}
+	}()
+	bf := byteframe.NewByteFrameFromBytes(rec.Payload[2:])
+	if err := pkt.Parse(bf, ctx); err != nil {
+		return nil, err
+	}
+	if err := bf.Err(); err != nil {
+		return nil, err
+	}
+	return pkt, nil
+}
+
+// writeDecoded prints the fields of the packet in rec, or a hexdump of its
+// payload when it cannot be decoded. Only channel server captures carry
+// MSG_* packets; the others are always hexdumped.
+func writeDecoded(w io.Writer, rec pcap.PacketRecord, serverType pcap.ServerType, ctx *clientctx.ClientContext) {
+	if serverType == pcap.ServerTypeChannel {
+		pkt, err := decodePacket(rec, ctx)
+		if err == nil {
+			writeFields(w, reflect.ValueOf(pkt).Elem(), "        ")
+			return
+		}
+		fmt.Fprintf(w, "        (not decoded: %v)\n", err)
+	}
+	writeHexdump(w, rec.Payload, "        ")
+}
+
+// writeFields prints the exported fields of the struct v, one per line.
+func writeFields(w io.Writer, v reflect.Value, indent string) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		fv := v.Field(i)
+		if b, ok := fv.Interface().([]byte); ok && len(b) > maxInlineBytes {
+			fmt.Fprintf(w, "%s%s: %d bytes\n", indent, f.Name, len(b))
+			writeHexdump(w, b, indent+"  ")
+			continue
+		}
+		fmt.Fprintf(w, "%s%s: %s\n", indent, f.Name, formatValue(fv))
+	}
+}
+
+func formatValue(v reflect.Value) string {
+	if b, ok := v.Interface().([]byte); ok {
+		if len(b) == 0 {
+			return "[]"
+		}
+		return hex.EncodeToString(b)
+	}
+	switch v.Kind() {
+	case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return fmt.Sprintf("%d (0x%X)", v.Uint(), v.Uint())
+	case reflect.String:
+		return fmt.Sprintf("%q", v.String())
+	}
+	return fmt.Sprintf("%+v", v.Interface())
+}
+
+func writeHexdump(w io.Writer, b []byte, indent string) {
+	if len(b) == 0 {
+		return
+	}
+	for _, line := range strings.Split(strings.TrimSuffix(hex.Dump(b), "\n"), "\n") {
+		fmt.Fprintf(w, "%s%s\n", indent, line)
+	}
+}
+
+// captureContext returns the context the capture's packets were encoded
+// with.
+func captureContext(hdr pcap.FileHeader) *clientctx.ClientContext {
+	return &clientctx.ClientContext{RealClientMode: cfg.Mode(hdr.ClientMode)}
+}
+
+// packetServerType returns the server type of the session rec belongs to
+// in a container, or that of the capture r.
+func packetServerType(r *pcap.Reader, rec pcap.PacketRecord) pcap.ServerType {
+	if s, ok := r.Meta.Session(rec.Session); ok {
+		return s.ServerType
+	}
+	return r.Header.ServerType
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	cfg "erupe-ce/config"
+	"erupe-ce/network/clientctx"
+	"erupe-ce/network/mhfpacket"
+	"erupe-ce/network/pcap"
+)
+
+func TestDecodePacket(t *testing.T) {
+	ctx := &clientctx.ClientContext{RealClientMode: cfg.ZZ}
+	ping := pcap.PacketRecord{Direction: pcap.DirClientToServer, Opcode: 0x0017, Payload: []byte{0x00, 0x17, 0x00, 0x00, 0x01, 0x02}}
+	pkt, err := decodePacket(ping, ctx)
+	if err != nil {
+		t.Fatalf("decodePacket: %v", err)
+	}
+	if p, ok := pkt.(*mhfpacket.MsgSysPing); !ok || p.AckHandle != 0x0102 {
+		t.Errorf("decoded %#v", pkt)
+	}
+
+	truncated := pcap.PacketRecord{Opcode: 0x0017, Payload: []byte{0x00, 0x17, 0x00}}
+	if _, err := decodePacket(truncated, ctx); err == nil {
+		t.Error("decodePacket accepted a truncated payload")
+	}
+}
+
+func TestWriteDecoded(t *testing.T) {
+	ctx := &clientctx.ClientContext{RealClientMode: cfg.ZZ}
+	ping := pcap.PacketRecord{Opcode: 0x0017, Payload: []byte{0x00, 0x17, 0x00, 0x00, 0x01, 0x02}}
+
+	var sb strings.Builder
+	writeDecoded(&sb, ping, pcap.ServerTypeChannel, ctx)
+	if !strings.Contains(sb.String(), "AckHandle: 258 (0x102)") {
+		t.Errorf("channel packet not decoded:\n%s", sb.String())
+	}
+
+	// Sign server packets are not MSG_* packets, so they are hexdumped.
+	sb.Reset()
+	writeDecoded(&sb, ping, pcap.ServerTypeSign, ctx)
+	if !strings.Contains(sb.String(), "00 17 00 00 01 02") {
+		t.Errorf("sign packet not hexdumped:\n%s", sb.String())
+	}
+
+	sb.Reset()
+	writeDecoded(&sb, pcap.PacketRecord{Opcode: 0xFFFF, Payload: []byte{0xFF, 0xFF, 0xAB}}, pcap.ServerTypeChannel, ctx)
+	if out := sb.String(); !strings.Contains(out, "not decoded") || !strings.Contains(out, "ff ff ab") {
+		t.Errorf("unknown opcode not hexdumped:\n%s", out)
+	}
+}
+
+func TestWriteFields_LongBytes(t *testing.T) {
+	ack := &mhfpacket.MsgSysAck{AckHandle: 1, IsBufferResponse: true, AckData: make([]byte, 40)}
+	var sb strings.Builder
+	writeFields(&sb, reflect.ValueOf(ack).Elem(), "")
+	out := sb.String()
+	for _, want := range []string{"AckHandle: 1 (0x1)", "IsBufferResponse: true", "AckData: 40 bytes", "00000020"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestRunDumpDecode(t *testing.T) {
+	path := createTestCapture(t, []pcap.PacketRecord{
+		{TimestampNs: 1000000100, Direction: pcap.DirClientToServer, Opcode: 0x0017, Payload: []byte{0x00, 0x17, 0x00, 0x00, 0x00, 0x01}},
+		{TimestampNs: 1000000200, Direction: pcap.DirServerToClient, Opcode: 0x0012, Payload: []byte{0x00, 0x12, 0xFF}},
+	})
+	if err := runDump(path, packetFilter{}, true); err != nil {
+		t.Fatalf("runDump: %v", err)
+	}
+}
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"erupe-ce/network"
+	"erupe-ce/network/pcap"
+)
+
+// alignWindow is how far ahead alignRequests looks for the request
+// matching one only the other capture has.
+const alignWindow = 64
+
+// exchange is a C→S request with the S→C packets that answer it: the
+// acknowledgement carrying its ack handle, and the packets that follow it
+// up to the next request. The packets a server sends before the first
+// request form an exchange with no request.
+type exchange struct {
+	index           int // Capture index of the request, or -1
+	request         *pcap.PacketRecord
+	responses       []pcap.PacketRecord
+	responseIndexes []int // Capture indexes of the responses
+}
+
+func (e exchange) opcode() int {
+	if e.request == nil {
+		return -1
+	}
+	return int(e.request.Opcode)
+}
+
+// exchanges groups the packets of a capture by request. An acknowledgement
+// arriving after later requests were sent joins the exchange of the request
+// with its ack handle, among the alignWindow before it.
+func exchanges(records []pcap.PacketRecord, indexes []int) []exchange {
+	out := []exchange{{index: -1}}
+	for i := range records {
+		if records[i].Direction == pcap.DirClientToServer {
+			out = append(out, exchange{index: indexes[i], request: &records[i]})
+			continue
+		}
+		e := &out[len(out)-1]
+		if handle, ok := ackHandle(records[i]); ok {
+			for k := len(out) - 1; k > 0 && k >= len(out)-alignWindow; k-- {
+				if h, ok := ackHandle(*out[k].request); ok && h == handle {
+					e = &out[k]
+					break
+				}
+			}
+		}
+		e.responses = append(e.responses, records[i])
+		e.responseIndexes = append(e.responseIndexes, indexes[i])
+	}
+	if out[0].responses == nil {
+		out = out[1:]
+	}
+	return out
+}
+
+// ackHandle returns the ack handle of a request, the first field after the
+// opcode of most, or of the MSG_SYS_ACK answering one.
+func ackHandle(rec pcap.PacketRecord) (uint32, bool) {
+	if len(rec.Payload) < 6 || rec.Direction == pcap.DirServerToClient && rec.Opcode != uint16(network.MSG_SYS_ACK) {
+		return 0, false
+	}
+	return binary.BigEndian.Uint32(rec.Payload[2:]), true
+}
+
+// alignedPair is a pair of exchanges with the same request, or an exchange
+// only one capture has, with the other nil.
+type alignedPair struct {
+	a, b *exchange
+}
+
+// alignRequests pairs the exchanges of two captures by their sequence of
+// request opcodes, see alignSequences.
+func alignRequests(a, b []exchange) []alignedPair {
+	var pairs []alignedPair
+	for _, p := range alignSequences(len(a), len(b), func(i, j int) bool { return a[i].opcode() == b[j].opcode() }) {
+		var pair alignedPair
+		if p[0] >= 0 {
+			pair.a = &a[p[0]]
+		}
+		if p[1] >= 0 {
+			pair.b = &b[p[1]]
+		}
+		pairs = append(pairs, pair)
+	}
+	return pairs
+}
+
+// alignSequences pairs the indexes of two sequences of lengths n and m
+// whose elements are the same, in order, with -1 for an element only one
+// has. Where they differ, the nearest element the other also has within
+// alignWindow resynchronises them; the elements skipped over are reported
+// as only in one sequence.
+func alignSequences(n, m int, same func(i, j int) bool) [][2]int {
+	var pairs [][2]int
+	i, j := 0, 0
+	for i < n && j < m {
+		if same(i, j) {
+			pairs = append(pairs, [2]int{i, j})
+			i, j = i+1, j+1
+			continue
+		}
+		// Skip whichever side reaches a match in fewer steps; drop both
+		// when neither does.
+		skipA, skipB := -1, -1
+		for k := 1; k < alignWindow && skipA < 0 && skipB < 0; k++ {
+			if i+k < n && same(i+k, j) {
+				skipA = k
+			} else if j+k < m && same(i, j+k) {
+				skipB = k
+			}
+		}
+		switch {
+		case skipA > 0:
+			for ; skipA > 0; skipA-- {
+				pairs = append(pairs, [2]int{i, -1})
+				i++
+			}
+		case skipB > 0:
+			for ; skipB > 0; skipB-- {
+				pairs = append(pairs, [2]int{-1, j})
+				j++
+			}
+		default:
+			pairs = append(pairs, [2]int{i, -1}, [2]int{-1, j})
+			i, j = i+1, j+1
+		}
+	}
+	for ; i < n; i++ {
+		pairs = append(pairs, [2]int{i, -1})
+	}
+	for ; j < m; j++ {
+		pairs = append(pairs, [2]int{-1, j})
+	}
+	return pairs
+}
+
+// runDiff compares the responses of the capture at path to those of the
+// capture at against, request by request, as rules allows. With showBytes,
+// the payloads of responses that differ are dumped line by line.
+func runDiff(path, against string, filter packetFilter, showBytes bool, rules compareRules) error {
+	if against == "" {
+		return errors.New("--against is required")
+	}
+	readAll := func(p string) ([]exchange, error) {
+		r, f, err := openCapture(p)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", p, err)
+		}
+		defer func() { _ = f.Close() }()
+		records, indexes, err := readPackets(r, filter)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", p, err)
+		}
+		return exchanges(records, indexes), nil
+	}
+	a, err := readAll(path)
+	if err != nil {
+		return err
+	}
+	b, err := readAll(against)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("=== Diff: %s against %s ===\n\n", path, against)
+	differences := writeDiff(os.Stdout, alignRequests(a, b), path, against, showBytes, rules)
+	fmt.Printf("\nRequests: %d and %d  Differences: %d\n", len(a), len(b), differences)
+	if differences == 0 {
+		fmt.Println("All responses match!")
+	}
+	return nil
+}
+
+// writeDiff reports the differences between aligned exchanges, returning
+// how many there were. Requests only one capture has are not reported when
+// rules skips their opcode.
+func writeDiff(w io.Writer, pairs []alignedPair, nameA, nameB string, showBytes bool, rules compareRules) int {
+	skipped := func(e *exchange) bool { return e.request != nil && rules[e.request.Opcode].Skip }
+	differences := 0
+	for _, p := range pairs {
+		switch {
+		case p.a == nil && skipped(p.b), p.b == nil && skipped(p.a):
+			continue
+		case p.b == nil:
+			fmt.Fprintf(w, "%s only in %s\n", requestName(p.a), nameA)
+			differences++
+			continue
+		case p.a == nil:
+			fmt.Fprintf(w, "%s only in %s\n", requestName(p.b), nameB)
+			differences++
+			continue
+		}
+		diffs := compareResponses(*p.a, *p.b, rules)
+		if len(diffs) == 0 {
+			continue
+		}
+		fmt.Fprintf(w, "%s (#%d in %s):\n", requestName(p.a), p.b.index, nameB)
+		for _, d := range diffs {
+			fmt.Fprintf(w, "  %s\n", d)
+			if showBytes && d.Actual != nil && !d.OpcodeMismatch && d.Expected.Opcode != 0 {
+				writeByteDiff(w, d.Expected.Payload, d.Actual.Payload, "    ")
+			}
+		}
+		differences += len(diffs)
+	}
+	return differences
+}
+
+func requestName(e *exchange) string {
+	if e.request == nil {
+		return "before the first request"
+	}
+	return fmt.Sprintf("request #%04d 0x%04X %s", e.index, e.request.Opcode, network.PacketID(e.request.Opcode))
+}
+
+// writeByteDiff dumps the 16-byte lines that differ between payloads a
+// and b, the line of a marked - and the line of b marked +.
+func writeByteDiff(w io.Writer, a, b []byte, indent string) {
+	line := func(p []byte, off int) []byte {
+		if off >= len(p) {
+			return nil
+		}
+		return p[off:min(off+16, len(p))]
+	}
+	for off := 0; off < max(len(a), len(b)); off += 16 {
+		la, lb := line(a, off), line(b, off)
+		if bytes.Equal(la, lb) {
+			continue
+		}
+		if la != nil {
+			fmt.Fprintf(w, "%s- %08x  % x\n", indent, off, la)
+		}
+		if lb != nil {
+			fmt.Fprintf(w, "%s+ %08x  % x\n", indent, off, lb)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"erupe-ce/network/pcap"
+)
+
+func c2s(op uint16) pcap.PacketRecord {
+	return pcap.PacketRecord{Direction: pcap.DirClientToServer, Opcode: op, Payload: []byte{byte(op >> 8), byte(op)}}
+}
+
+func s2c(op uint16, payload ...byte) pcap.PacketRecord {
+	return pcap.PacketRecord{Direction: pcap.DirServerToClient, Opcode: op, Payload: append([]byte{byte(op >> 8), byte(op)}, payload...)}
+}
+
+func testExchanges(records ...pcap.PacketRecord) []exchange {
+	indexes := make([]int, len(records))
+	for i := range indexes {
+		indexes[i] = i
+	}
+	return exchanges(records, indexes)
+}
+
+func TestExchanges(t *testing.T) {
+	ex := testExchanges(s2c(0x0012), c2s(0x0017), s2c(0x0012), s2c(0x0012), c2s(0x0061))
+	if len(ex) != 3 {
+		t.Fatalf("%d exchanges, want 3", len(ex))
+	}
+	if ex[0].request != nil || len(ex[0].responses) != 1 {
+		t.Errorf("first exchange %+v, want the response before any request", ex[0])
+	}
+	if ex[1].index != 1 || len(ex[1].responses) != 2 || ex[2].index != 4 || ex[2].responses != nil {
+		t.Errorf("exchanges %+v", ex[1:])
+	}
+
+	if ex := testExchanges(c2s(0x0017)); len(ex) != 1 || ex[0].request == nil {
+		t.Errorf("capture starting with a request: %+v", ex)
+	}
+}
+
+func TestAlignRequests(t *testing.T) {
+	a := testExchanges(c2s(1), c2s(2), c2s(3), c2s(4), c2s(6))
+	b := testExchanges(c2s(1), c2s(3), c2s(4), c2s(5), c2s(6), c2s(7))
+	var got []string
+	for _, p := range alignRequests(a, b) {
+		var s string
+		switch {
+		case p.b == nil:
+			s = "a"
+		case p.a == nil:
+			s = "b"
+		case p.a.opcode() != p.b.opcode():
+			t.Fatalf("paired requests %d and %d", p.a.opcode(), p.b.opcode())
+		}
+		op := 0
+		if p.a != nil {
+			op = p.a.opcode()
+		} else {
+			op = p.b.opcode()
+		}
+		got = append(got, s+string(rune('0'+op)))
+	}
+	if s := strings.Join(got, " "); s != "1 a2 3 4 b5 6 b7" {
+		t.Errorf("aligned %s", s)
+	}
+}
+
+func TestWriteDiff(t *testing.T) {
+	a := testExchanges(c2s(0x0017), s2c(0x0012, 1, 2, 3), c2s(0x0061), s2c(0x0012, 9))
+	b := testExchanges(c2s(0x0017), s2c(0x0012, 1, 2, 4), c2s(0x0061), s2c(0x0012, 9))
+	var sb strings.Builder
+	n := writeDiff(&sb, alignRequests(a, b), "a.mhfr", "b.mhfr", true, nil)
+	out := sb.String()
+	if n != 1 {
+		t.Errorf("%d differences, want 1:\n%s", n, out)
+	}
+	for _, want := range []string{"request #0000 0x0017 MSG_SYS_PING (#0 in b.mhfr)", "1 byte diff(s): [0x0004: 03→04]", "- 00000000  00 12 01 02 03", "+ 00000000  00 12 01 02 04"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("diff missing %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "0x0061") {
+		t.Errorf("matching exchange reported:\n%s", out)
+	}
+}
+
+func TestRunDiff(t *testing.T) {
+	a := createTestCapture(t, []pcap.PacketRecord{c2s(0x0017), s2c(0x0012, 1)})
+	b := createTestCapture(t, []pcap.PacketRecord{c2s(0x0017), s2c(0x0012, 2)})
+	if err := runDiff(a, b, packetFilter{}, true, nil); err != nil {
+		t.Fatalf("runDiff: %v", err)
+	}
+	if err := runDiff(a, "", packetFilter{}, false, nil); err == nil {
+		t.Error("runDiff accepted a missing --against")
+	}
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"erupe-ce/network"
+	"erupe-ce/network/pcap"
+)
+
+// packetFilter selects the packets of a capture a mode works on. The zero
+// value selects every packet.
+type packetFilter struct {
+	opcodes   map[uint16]bool // Opcodes to keep; all when empty
+	direction pcap.Direction  // Direction to keep; both when zero
+	start     int             // Index of the first packet to keep
+	end       int             // Index after the last packet to keep, when hasEnd is set
+	hasEnd    bool
+	sessions  map[uint16]bool // Container sessions to keep; all when empty
+}
+
+// parseFilter builds a filter from the values of the filter flags.
+func parseFilter(opcodes, direction, rng string) (packetFilter, error) {
+	var f packetFilter
+	if opcodes != "" {
+		f.opcodes = make(map[uint16]bool)
+		for _, s := range strings.Split(opcodes, ",") {
+			op, err := parseOpcode(strings.TrimSpace(s))
+			if err != nil {
+				return f, err
+			}
+			f.opcodes[op] = true
+		}
+	}
+	switch strings.ToLower(direction) {
+	case "":
+	case "c2s", "client":
+		f.direction = pcap.DirClientToServer
+	case "s2c", "server":
+		f.direction = pcap.DirServerToClient
+	default:
+		return f, fmt.Errorf("invalid direction %q, want c2s or s2c", direction)
+	}
+	if rng != "" {
+		start, end, ok := strings.Cut(rng, ":")
+		if !ok {
+			return f, fmt.Errorf("invalid range %q, want start:end", rng)
+		}
+		var err error
+		if start != "" {
+			if f.start, err = strconv.Atoi(start); err != nil || f.start < 0 {
+				return f, fmt.Errorf("invalid range start %q", start)
+			}
+		}
+		if end != "" {
+			if f.end, err = strconv.Atoi(end); err != nil || f.end < f.start {
+				return f, fmt.Errorf("invalid range end %q", end)
+			}
+			f.hasEnd = true
+		}
+	}
+	return f, nil
+}
+
+// parseSessions reads the comma separated session IDs of --filter-session.
+func parseSessions(s string) (map[uint16]bool, error) {
+	if s == "" {
+		return nil, nil
+	}
+	sessions := make(map[uint16]bool)
+	for _, id := range strings.Split(s, ",") {
+		n, err := strconv.ParseUint(strings.TrimSpace(id), 10, 16)
+		if err != nil || n == 0 {
+			return nil, fmt.Errorf("invalid session %q", id)
+		}
+		sessions[uint16(n)] = true
+	}
+	return sessions, nil
+}
+
+// parseOpcode reads an opcode given as a number, such as 0x0017 or 23,
+// or as a name, such as MSG_SYS_PING.
+func parseOpcode(s string) (uint16, error) {
+	if n, err := strconv.ParseUint(s, 0, 16); err == nil {
+		return uint16(n), nil
+	}
+	name := strings.ToUpper(s)
+	for id := network.PacketID(0); id <= network.MSG_SYS_reserve1AF; id++ {
+		if strings.ToUpper(id.String()) == name {
+			return uint16(id), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown opcode %q", s)
+}
+
+// match reports whether the packet at index i of the capture is kept.
+func (f packetFilter) match(i int, rec pcap.PacketRecord) bool {
+	if i < f.start || f.hasEnd && i >= f.end {
+		return false
+	}
+	if f.direction != 0 && rec.Direction != f.direction {
+		return false
+	}
+	if len(f.sessions) > 0 && !f.sessions[rec.Session] {
+		return false
+	}
+	return len(f.opcodes) == 0 || f.opcodes[rec.Opcode]
+}
+
+// done reports whether no packet from index i on can match.
+func (f packetFilter) done(i int) bool {
+	return f.hasEnd && i >= f.end
+}
+
+// eachPacket calls fn with the packets of r the filter keeps and their
+// indexes in the capture. Packets past the end of the range are not read,
+// and those before it are skipped with the capture's index when it has one.
+func eachPacket(r *pcap.Reader, f packetFilter, fn func(i int, rec pcap.PacketRecord) error) error {
+	first := 0
+	if f.start > 0 && r.Index() != nil {
+		if f.start >= r.Index().Packets {
+			return nil
+		}
+		if err := r.Seek(f.start); err != nil {
+			return err
+		}
+		first = f.start
+	}
+	for i := first; !f.done(i); i++ {
+		rec, err := r.ReadPacket()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if f.match(i, rec) {
+			if err := fn(i, rec); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// readPackets reads the packets of r the filter keeps, with their indexes
+// in the capture. The others are dropped as they are read, so large
+// captures can be filtered without holding all of them.
+func readPackets(r *pcap.Reader, f packetFilter) ([]pcap.PacketRecord, []int, error) {
+	var records []pcap.PacketRecord
+	var indexes []int
+	err := eachPacket(r, f, func(i int, rec pcap.PacketRecord) error {
+		records = append(records, rec)
+		indexes = append(indexes, i)
+		return nil
+	})
+	return records, indexes, err
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"erupe-ce/network/pcap"
+)
+
+func TestParseSessions(t *testing.T) {
+	if got, err := parseSessions("1, 3"); err != nil || len(got) != 2 || !got[1] || !got[3] {
+		t.Errorf(`parseSessions("1, 3") = %v, %v`, got, err)
+	}
+	if got, err := parseSessions(""); err != nil || got != nil {
+		t.Errorf(`parseSessions("") = %v, %v`, got, err)
+	}
+	for _, s := range []string{"0", "x", "70000"} {
+		if _, err := parseSessions(s); err == nil {
+			t.Errorf("parseSessions(%q) succeeded", s)
+		}
+	}
+}
+
+func TestParseFilter(t *testing.T) {
+	f, err := parseFilter("0x0017, MSG_SYS_ACK,19", "s2c", "2:10")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !f.opcodes[0x0017] || !f.opcodes[0x0012] || !f.opcodes[0x0013] || len(f.opcodes) != 3 {
+		t.Errorf("opcodes = %v", f.opcodes)
+	}
+	if f.direction != pcap.DirServerToClient || f.start != 2 || f.end != 10 || !f.hasEnd {
+		t.Errorf("filter = %+v", f)
+	}
+
+	if f, err := parseFilter("", "", "5:"); err != nil || f.start != 5 || f.hasEnd {
+		t.Errorf(`parseFilter("5:") = %+v, %v`, f, err)
+	}
+	for _, tt := range [][3]string{
+		{"MSG_NOPE", "", ""},
+		{"0x10000", "", ""},
+		{"", "up", ""},
+		{"", "", "5"},
+		{"", "", "5:2"},
+		{"", "", "-1:"},
+	} {
+		if _, err := parseFilter(tt[0], tt[1], tt[2]); err == nil {
+			t.Errorf("parseFilter(%q, %q, %q) succeeded", tt[0], tt[1], tt[2])
+		}
+	}
+}
+
+func TestReadPackets(t *testing.T) {
+	var records []pcap.PacketRecord
+	for i := 0; i < 6; i++ {
+		dir, op := pcap.DirClientToServer, uint16(0x0017)
+		if i%2 == 1 {
+			dir, op = pcap.DirServerToClient, 0x0012
+		}
+		records = append(records, pcap.PacketRecord{TimestampNs: int64(1000000100 + i), Direction: dir, Opcode: op, Payload: []byte{byte(op >> 8), byte(op)}})
+	}
+	path := createTestCapture(t, records)
+
+	tests := []struct {
+		opcodes, direction, rng string
+		want                    []int
+	}{
+		{"", "", "", []int{0, 1, 2, 3, 4, 5}},
+		{"", "c2s", "", []int{0, 2, 4}},
+		{"MSG_SYS_ACK", "", "", []int{1, 3, 5}},
+		{"", "", "1:4", []int{1, 2, 3}},
+		{"", "s2c", "2:", []int{3, 5}},
+		{"0x0017", "s2c", "", nil},
+	}
+	for _, tt := range tests {
+		f, err := parseFilter(tt.opcodes, tt.direction, tt.rng)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r, file, err := openCapture(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, indexes, err := readPackets(r, f)
+		_ = file.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Equal(indexes, tt.want) || len(got) != len(tt.want) {
+			t.Errorf("filter %q %q %q kept %v, want %v", tt.opcodes, tt.direction, tt.rng, indexes, tt.want)
+		}
+	}
+}
+
+func TestReadPackets_Indexed(t *testing.T) {
+	f, err := os.Create(filepath.Join(t.TempDir(), "indexed.mhfr"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	w, err := pcap.NewWriter(f, pcap.FileHeader{Version: pcap.FormatVersion, ServerType: pcap.ServerTypeChannel}, pcap.SessionMetadata{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3000; i++ {
+		_ = w.WritePacket(packet(int64(i), 0x0017))
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	_ = f.Close()
+
+	for _, rng := range []string{"2990:2995", "5000:"} {
+		filter, _ := parseFilter("", "", rng)
+		r, file, err := openCapture(f.Name())
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, indexes, err := readPackets(r, filter)
+		_ = file.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := []int{2990, 2991, 2992, 2993, 2994}
+		if rng == "5000:" {
+			want = nil
+		}
+		if !slices.Equal(indexes, want) {
+			t.Errorf("range %s kept %v", rng, indexes)
+		}
+	}
+}
@@ -0,0 +1,243 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"erupe-ce/common/byteframe"
+	"erupe-ce/network"
+	"erupe-ce/network/clientctx"
+	"erupe-ce/network/mhfpacket"
+	"erupe-ce/network/pcap"
+)
+
+// parseTimeout is how long a parser may take on one packet before fuzz
+// reports it as hanging, as a parser looping on a count read from the
+// packet would.
+const parseTimeout = time.Second
+
+// fuzzSeed is a packet a client sent, which fuzz mutates.
+type fuzzSeed struct {
+	rec pcap.PacketRecord
+	ctx *clientctx.ClientContext
+}
+
+// fuzzCrash is a panic or hang of the parser of an opcode, with the first
+// packet found to cause it.
+type fuzzCrash struct {
+	opcode  uint16
+	message string
+	payload []byte
+	count   int
+}
+
+// interesting are byte values and lengths parsers tend to mishandle.
+var (
+	interesting8  = []byte{0x00, 0x01, 0x7F, 0x80, 0xFF}
+	interesting32 = []uint32{0, 0xFFFFFFFF, 0x80000000, 0x7FFFFFFF, 0x10000}
+)
+
+// mutate returns a copy of payload with one to four random changes. The
+// opcode in its first two bytes is kept.
+func mutate(rng *rand.Rand, payload []byte) []byte {
+	p := bytes.Clone(payload)
+	for range 1 + rng.IntN(4) {
+		body := len(p) - 2
+		switch op := rng.IntN(6); {
+		case body <= 0 || op == 0:
+			p = append(p, randomBytes(rng, 1+rng.IntN(16))...)
+		case op == 1:
+			p[2+rng.IntN(body)] ^= 1 << rng.IntN(8)
+		case op == 2:
+			p[2+rng.IntN(body)] = byte(rng.Uint32())
+		case op == 3:
+			p[2+rng.IntN(body)] = interesting8[rng.IntN(len(interesting8))]
+		case op == 4:
+			// A count or length, big-endian like the rest of the packet.
+			v := binary.BigEndian.AppendUint32(nil, interesting32[rng.IntN(len(interesting32))])
+			copy(p[2+rng.IntN(body):], v[rng.IntN(3):])
+		default:
+			p = p[:2+rng.IntN(body)]
+		}
+	}
+	return p
+}
+
+func randomBytes(rng *rand.Rand, n int) []byte {
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = byte(rng.Uint32())
+	}
+	return b
+}
+
+// parsePanic parses payload with the mhfpacket parser of opcode, returning
+// what it panicked with, "" when it did not. A parser taking longer than
+// parseTimeout is reported as hanging and left to finish on its own.
+func parsePanic(opcode uint16, payload []byte, ctx *clientctx.ClientContext) string {
+	done := make(chan string, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				done <- fmt.Sprint(r)
+			}
+		}()
+		pkt := mhfpacket.FromOpcode(network.PacketID(opcode))
+		_ = pkt.Parse(byteframe.NewByteFrameFromBytes(payload[2:]), ctx)
+		done <- ""
+	}()
+	select {
+	case msg := <-done:
+		return msg
+	case <-time.After(parseTimeout):
+		return fmt.Sprintf("hang: no result after %s", parseTimeout)
+	}
+}
+
+// digits are replaced in panic messages, so an index out of range is one
+// crash whatever the index.
+var digits = regexp.MustCompile(`[0-9]+`)
+
+// fuzzReport collects the crashes of a fuzz run.
+type fuzzReport struct {
+	seeds   int
+	opcodes int
+	runs    int
+	crashes map[string]*fuzzCrash // By opcode and message without numbers
+}
+
+// fuzz parses iterations mutated seeds, picked at random, with parse,
+// recording the parsers that panic or hang. Opcodes whose parser hangs are
+// not fuzzed again, as the hanging parse keeps running.
+func fuzz(seeds []fuzzSeed, iterations int, rng *rand.Rand, parse func(uint16, []byte, *clientctx.ClientContext) string) *fuzzReport {
+	rep := &fuzzReport{seeds: len(seeds), crashes: map[string]*fuzzCrash{}}
+	opcodes := map[uint16]bool{}
+	for _, s := range seeds {
+		opcodes[s.rec.Opcode] = true
+	}
+	rep.opcodes = len(opcodes)
+	hung := map[uint16]bool{}
+	for ; rep.runs < iterations && len(hung) < len(opcodes); rep.runs++ {
+		s := seeds[rng.IntN(len(seeds))]
+		if hung[s.rec.Opcode] {
+			continue
+		}
+		payload := mutate(rng, s.rec.Payload)
+		msg := parse(s.rec.Opcode, payload, s.ctx)
+		if msg == "" {
+			continue
+		}
+		if strings.HasPrefix(msg, "hang:") {
+			hung[s.rec.Opcode] = true
+		}
+		key := fmt.Sprintf("%04X %s", s.rec.Opcode, digits.ReplaceAllString(msg, "N"))
+		if c := rep.crashes[key]; c != nil {
+			c.count++
+			continue
+		}
+		rep.crashes[key] = &fuzzCrash{opcode: s.rec.Opcode, message: msg, payload: payload, count: 1}
+	}
+	return rep
+}
+
+// sorted returns the crashes by opcode, then message.
+func (rep *fuzzReport) sorted() []*fuzzCrash {
+	crashes := make([]*fuzzCrash, 0, len(rep.crashes))
+	for _, c := range rep.crashes {
+		crashes = append(crashes, c)
+	}
+	sort.Slice(crashes, func(i, j int) bool {
+		if crashes[i].opcode != crashes[j].opcode {
+			return crashes[i].opcode < crashes[j].opcode
+		}
+		return crashes[i].message < crashes[j].message
+	})
+	return crashes
+}
+
+// write prints the crashes, each with the first packet causing it.
+func (rep *fuzzReport) write(w io.Writer) {
+	fmt.Fprintf(w, "Seeds: %d packets, %d opcodes  Mutations: %d\n", rep.seeds, rep.opcodes, rep.runs)
+	fmt.Fprintf(w, "\nCrashes: %d\n", len(rep.crashes))
+	for _, c := range rep.sorted() {
+		fmt.Fprintf(w, "  0x%04X   %-35s %6d times  %s\n", c.opcode, network.PacketID(c.opcode), c.count, c.message)
+		writeHexdump(w, c.payload, "        ")
+	}
+}
+
+// runFuzz mutates the packets clients sent in the channel captures at
+// paths and parses them with the mhfpacket parsers in-process, reporting
+// the parsers that panic or hang. The same seed fuzzes the same way. With
+// out, the first packet causing each crash is written to a capture there,
+// which dump --decode reproduces it with.
+func runFuzz(paths []string, filter packetFilter, iterations int, seed uint64, out string) error {
+	var seeds []fuzzSeed
+	var hdr pcap.FileHeader
+	seen := map[string]bool{}
+	var used []string
+	for _, p := range paths {
+		r, f, err := openCapture(p)
+		if err != nil {
+			return fmt.Errorf("%s: %w", p, err)
+		}
+		if r.Header.ServerType != pcap.ServerTypeChannel {
+			_ = f.Close()
+			fmt.Fprintf(os.Stderr, "Skipping %s: a %s capture has no channel packets\n", p, r.Header.ServerType)
+			continue
+		}
+		ctx := captureContext(r.Header)
+		err = eachPacket(r, filter, func(_ int, rec pcap.PacketRecord) error {
+			if rec.Direction != pcap.DirClientToServer || len(rec.Payload) < 2 ||
+				mhfpacket.FromOpcode(network.PacketID(rec.Opcode)) == nil || seen[string(rec.Payload)] {
+				return nil
+			}
+			seen[string(rec.Payload)] = true
+			seeds = append(seeds, fuzzSeed{rec: rec, ctx: ctx})
+			return nil
+		})
+		_ = f.Close()
+		if err != nil {
+			return fmt.Errorf("%s: %w", p, err)
+		}
+		if len(used) == 0 {
+			hdr = r.Header
+		}
+		used = append(used, p)
+	}
+	if len(seeds) == 0 {
+		fmt.Println("No client packets with a parser to fuzz")
+		return nil
+	}
+
+	fmt.Printf("=== Fuzz: %s (seed %d) ===\n", strings.Join(used, ", "), seed)
+	rep := fuzz(seeds, iterations, rand.New(rand.NewPCG(seed, seed)), parsePanic)
+	rep.write(os.Stdout)
+	if out == "" || len(rep.crashes) == 0 {
+		return nil
+	}
+
+	cw, err := createCapture(out, hdr, pcap.SessionMetadata{}, "")
+	if err != nil {
+		return err
+	}
+	for _, c := range rep.sorted() {
+		rec := pcap.PacketRecord{TimestampNs: hdr.SessionStartNs, Direction: pcap.DirClientToServer, Opcode: c.opcode, Payload: c.payload}
+		if err := cw.write(rec); err != nil {
+			_ = cw.close()
+			return err
+		}
+	}
+	if err := cw.close(); err != nil {
+		return err
+	}
+	fmt.Printf("\nCrashing packets written to %s\n", out)
+	return nil
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	cfg "erupe-ce/config"
+	"erupe-ce/network/clientctx"
+	"erupe-ce/network/pcap"
+)
+
+func TestMutate(t *testing.T) {
+	rng := rand.New(rand.NewPCG(1, 2))
+	seed := []byte{0x00, 0x17, 0x00, 0x00, 0x00, 0x01}
+	changed := 0
+	for range 1000 {
+		p := mutate(rng, seed)
+		if len(p) < 2 || p[0] != 0x00 || p[1] != 0x17 {
+			t.Fatalf("mutate() = % X, lost the opcode", p)
+		}
+		if !bytes.Equal(p, seed) {
+			changed++
+		}
+	}
+	if changed < 900 {
+		t.Errorf("%d of 1000 mutations changed the packet", changed)
+	}
+	if !bytes.Equal(seed, []byte{0x00, 0x17, 0x00, 0x00, 0x00, 0x01}) {
+		t.Error("mutate() changed the seed")
+	}
+	if p := mutate(rng, []byte{0x00, 0x17}); len(p) <= 2 {
+		t.Errorf("mutate() of an empty packet = % X, want bytes added", p)
+	}
+}
+
+func TestParsePanic(t *testing.T) {
+	ctx := &clientctx.ClientContext{RealClientMode: cfg.ZZ}
+	if msg := parsePanic(0x0017, []byte{0x00, 0x17}, ctx); msg != "" {
+		t.Errorf("parsePanic() of a truncated MSG_SYS_PING = %q, want no panic", msg)
+	}
+}
+
+func TestFuzz(t *testing.T) {
+	ctx := &clientctx.ClientContext{RealClientMode: cfg.ZZ}
+	seeds := []fuzzSeed{
+		{rec: pcap.PacketRecord{Opcode: 0x0017, Payload: []byte{0x00, 0x17, 0x00, 0x00, 0x00, 0x01}}, ctx: ctx},
+		{rec: pcap.PacketRecord{Opcode: 0x0013, Payload: []byte{0x00, 0x13, 0x01}}, ctx: ctx},
+	}
+	// A parser indexing by its first byte, and one hanging on 0xFF.
+	parse := func(opcode uint16, p []byte, _ *clientctx.ClientContext) string {
+		switch {
+		case opcode == 0x0017 && len(p) > 2 && int(p[2]) >= len(p):
+			return fmt.Sprintf("index out of range [%d] with length %d", p[2], len(p))
+		case opcode == 0x0013 && len(p) > 2 && p[2] == 0xFF:
+			return "hang: no result after 1s"
+		}
+		return ""
+	}
+	rep := fuzz(seeds, 5000, rand.New(rand.NewPCG(1, 2)), parse)
+	if rep.seeds != 2 || rep.opcodes != 2 {
+		t.Errorf("seeds %d, opcodes %d", rep.seeds, rep.opcodes)
+	}
+	crashes := rep.sorted()
+	if len(crashes) != 2 || crashes[0].opcode != 0x0013 || crashes[1].opcode != 0x0017 {
+		t.Fatalf("crashes %+v, want one hang of 0x0013 and one panic of 0x0017", crashes)
+	}
+	if crashes[0].count != 1 {
+		t.Errorf("0x0013 fuzzed %d times after hanging", crashes[0].count)
+	}
+	if crashes[1].count < 2 {
+		t.Errorf("panics of 0x0017 at several indexes counted %d times, want one crash", crashes[1].count)
+	}
+
+	var sb strings.Builder
+	rep.write(&sb)
+	for _, want := range []string{"Crashes: 2", "MSG_SYS_PING", "index out of range", "hang:"} {
+		if !strings.Contains(sb.String(), want) {
+			t.Errorf("report missing %q:\n%s", want, sb.String())
+		}
+	}
+}
+
+func TestRunFuzzNoCrashes(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "channel.mhfr")
+	writeCapture(t, path, pcap.ServerTypeChannel, 0, pcap.SessionMetadata{}, []pcap.PacketRecord{packet(1, 0x0017), packet(2, 0x0017)})
+	out := filepath.Join(t.TempDir(), "crashes.mhfr")
+	if err := runFuzz([]string{path}, packetFilter{}, 1000, 1, out); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(out); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("crash capture written without crashes: %v", err)
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"erupe-ce/network/pcap"
+)
+
+func TestGentestName(t *testing.T) {
+	for path, want := range map[string]string{
+		"zz_first_login.mhfr":                      "TestCaptureZzFirstLogin",
+		"captures/channel_20240306_127.0.0.1.mhfr": "TestCaptureChannel20240306127001",
+		"quest-accept.mhfr":                        "TestCaptureQuestAccept",
+	} {
+		if got := gentestName(path); got != want {
+			t.Errorf("gentestName(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
+
+func TestRunGentest(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "zz_login.mhfr")
+	ack := pcap.PacketRecord{TimestampNs: 20, Direction: pcap.DirServerToClient, Opcode: 0x0012, Payload: []byte{0x00, 0x12, 0xAB, 0x00, 0x10}}
+	writeCapture(t, path, pcap.ServerTypeChannel, 5, pcap.SessionMetadata{CharID: 3}, []pcap.PacketRecord{packet(10, 0x0017), ack})
+
+	out := filepath.Join(dir, "capture_login_test.go")
+	if err := runGentest(path, packetFilter{}, out); err != nil {
+		t.Fatal(err)
+	}
+	src, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := parser.ParseFile(token.NewFileSet(), out, src, 0)
+	if err != nil {
+		t.Fatalf("generated test does not parse: %v", err)
+	}
+	if f.Name.Name != "channelserver" {
+		t.Errorf("package %s, want channelserver", f.Name.Name)
+	}
+	for _, want := range []string{
+		"func TestCaptureZzLogin(t *testing.T)",
+		"CharID: 3",
+		`Direction: pcap.DirClientToServer, Opcode: 0x0017, Payload: goldenPayload("0017")`,
+		`Direction: pcap.DirServerToClient, Opcode: 0x0012, Payload: goldenPayload("0012ab0010")`,
+	} {
+		if !strings.Contains(string(src), want) {
+			t.Errorf("generated test missing %q:\n%s", want, src)
+		}
+	}
+
+	sign := filepath.Join(dir, "sign.mhfr")
+	writeCapture(t, sign, pcap.ServerTypeSign, 0, pcap.SessionMetadata{}, []pcap.PacketRecord{packet(1, 1)})
+	if err := runGentest(sign, packetFilter{}, ""); err == nil || !strings.Contains(err.Error(), "only channel captures") {
+		t.Errorf("runGentest() of a sign capture = %v", err)
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+	"time"
+
+	"erupe-ce/network"
+	"erupe-ce/network/pcap"
+)
+
+// handlerStats are the times the server took to handle the packets of one
+// opcode, as a capture with pcap.FlagTiming records them.
+type handlerStats struct {
+	opcode uint16
+	times  []time.Duration // Ascending
+}
+
+// percentile returns the time p percent of the packets were handled
+// within, by the nearest rank.
+func (s *handlerStats) percentile(p int) time.Duration {
+	rank := (p*len(s.times) + 99) / 100
+	return s.times[max(rank, 1)-1]
+}
+
+// handlerTimes returns the handling times of the C→S packets of records
+// by opcode, those with the slowest 99th percentile first. Packets without
+// a handling time are left out, so captures without timing have none.
+func handlerTimes(records []pcap.PacketRecord) []*handlerStats {
+	byOpcode := make(map[uint16]*handlerStats)
+	for _, rec := range records {
+		if rec.Direction != pcap.DirClientToServer || rec.ProcessingNs <= 0 {
+			continue
+		}
+		s, ok := byOpcode[rec.Opcode]
+		if !ok {
+			s = &handlerStats{opcode: rec.Opcode}
+			byOpcode[rec.Opcode] = s
+		}
+		s.times = append(s.times, time.Duration(rec.ProcessingNs))
+	}
+	stats := make([]*handlerStats, 0, len(byOpcode))
+	for _, s := range byOpcode {
+		slices.Sort(s.times)
+		stats = append(stats, s)
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		if pi, pj := stats[i].percentile(99), stats[j].percentile(99); pi != pj {
+			return pi > pj
+		}
+		return stats[i].opcode < stats[j].opcode
+	})
+	return stats
+}
+
+// writeHandlerTimes prints the percentiles of the handling times, if the
+// captures recorded any.
+func writeHandlerTimes(w io.Writer, stats []*handlerStats) {
+	if len(stats) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "\n=== Handler Times ===\n")
+	fmt.Fprintf(w, "%-8s %-35s %8s %10s %10s %10s %10s\n", "Opcode", "Name", "Count", "p50", "p90", "p99", "Max")
+	for _, s := range stats {
+		fmt.Fprintf(w, "0x%04X   %-35s %8d %10s %10s %10s %10s\n", s.opcode, network.PacketID(s.opcode), len(s.times),
+			s.percentile(50).Round(time.Microsecond), s.percentile(90).Round(time.Microsecond),
+			s.percentile(99).Round(time.Microsecond), s.times[len(s.times)-1].Round(time.Microsecond))
+	}
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"erupe-ce/network/pcap"
+)
+
+func TestHandlerTimes(t *testing.T) {
+	timed := func(op uint16, d time.Duration) pcap.PacketRecord {
+		rec := c2s(op)
+		rec.ProcessingNs = int64(d)
+		return rec
+	}
+	var records []pcap.PacketRecord
+	for i := 1; i <= 100; i++ {
+		records = append(records, timed(0x0017, time.Duration(i)*time.Millisecond))
+	}
+	records = append(records,
+		timed(0x0061, 500*time.Millisecond),
+		c2s(0x0061), // Not timed
+		s2c(0x0012), // A response
+		timed(0x0062, time.Second),
+	)
+
+	stats := handlerTimes(records)
+	if len(stats) != 3 || stats[0].opcode != 0x0062 || stats[1].opcode != 0x0061 || stats[2].opcode != 0x0017 {
+		t.Fatalf("stats not slowest first: %+v", stats)
+	}
+	ping := stats[2]
+	for p, want := range map[int]time.Duration{50: 50 * time.Millisecond, 90: 90 * time.Millisecond, 99: 99 * time.Millisecond, 100: 100 * time.Millisecond} {
+		if got := ping.percentile(p); got != want {
+			t.Errorf("p%d = %s, want %s", p, got, want)
+		}
+	}
+	if len(stats[1].times) != 1 || stats[1].percentile(50) != 500*time.Millisecond {
+		t.Errorf("0x0061 times %v", stats[1].times)
+	}
+
+	var sb strings.Builder
+	writeHandlerTimes(&sb, stats)
+	if out := sb.String(); !strings.Contains(out, "MSG_SYS_PING") || !strings.Contains(out, "50ms") || !strings.Contains(out, "99ms") {
+		t.Errorf("report:\n%s", out)
+	}
+
+	sb.Reset()
+	writeHandlerTimes(&sb, handlerTimes([]pcap.PacketRecord{c2s(0x0017)}))
+	if sb.Len() != 0 {
+		t.Errorf("report without timing:\n%s", sb.String())
+	}
+}
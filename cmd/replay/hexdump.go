@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"erupe-ce/network/pcap"
+)
+
+// runHexdump prints each packet's summary line followed by a dump of its
+// payload. Payloads longer than maxBytes are cut short, like the packet
+// logs of DebugOptions.MaxHexdumpLength; 0 prints them whole.
+func runHexdump(path string, filter packetFilter, maxBytes int) error {
+	if maxBytes < 0 {
+		return fmt.Errorf("invalid --max-bytes %d", maxBytes)
+	}
+	r, f, err := openCapture(path)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = f.Close() }()
+
+	printCaptureHeader(os.Stdout, path, r)
+	count := 0
+	err = eachPacket(r, filter, func(i int, rec pcap.PacketRecord) error {
+		writePacketLine(os.Stdout, i, rec, r.Header.SessionStartNs)
+		writeTruncatedHexdump(os.Stdout, rec.Payload, maxBytes, "        ")
+		fmt.Println()
+		count++
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Total: %d packets\n", count)
+	return nil
+}
+
+// writeTruncatedHexdump dumps at most limit bytes of b, noting how many
+// were left out. A limit of 0 dumps all of b.
+func writeTruncatedHexdump(w io.Writer, b []byte, limit int, indent string) {
+	if limit == 0 || len(b) <= limit {
+		writeHexdump(w, b, indent)
+		return
+	}
+	writeHexdump(w, b[:limit], indent)
+	fmt.Fprintf(w, "%s... %d more bytes\n", indent, len(b)-limit)
+}
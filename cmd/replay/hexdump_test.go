@@ -0,0 +1,47 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"erupe-ce/network/pcap"
+)
+
+func TestWriteTruncatedHexdump(t *testing.T) {
+	payload := []byte("0123456789abcdefghijklmnopqrstuvwxyz")
+
+	var sb strings.Builder
+	writeTruncatedHexdump(&sb, payload, 0, "")
+	if out := sb.String(); !strings.Contains(out, "00000020  77 78 79 7a") || strings.Contains(out, "more bytes") {
+		t.Errorf("full dump:\n%s", out)
+	}
+
+	sb.Reset()
+	writeTruncatedHexdump(&sb, payload, 16, "  ")
+	out := sb.String()
+	if !strings.Contains(out, "  00000000  30 31 32 33") || !strings.Contains(out, "|0123456789abcdef|") {
+		t.Errorf("truncated dump missing the first line:\n%s", out)
+	}
+	if strings.Contains(out, "00000010") || !strings.Contains(out, "  ... 20 more bytes") {
+		t.Errorf("truncated dump not cut at 16 bytes:\n%s", out)
+	}
+
+	sb.Reset()
+	writeTruncatedHexdump(&sb, payload[:4], 16, "")
+	if strings.Contains(sb.String(), "more bytes") {
+		t.Errorf("short payload reported as truncated:\n%s", sb.String())
+	}
+}
+
+func TestRunHexdump(t *testing.T) {
+	path := createTestCapture(t, []pcap.PacketRecord{
+		{TimestampNs: 1000000100, Direction: pcap.DirClientToServer, Opcode: 0x0017, Payload: []byte{0x00, 0x17, 0x00, 0x00, 0x00, 0x01}},
+		{TimestampNs: 1000000200, Direction: pcap.DirServerToClient, Opcode: 0x0012, Payload: make([]byte, 600)},
+	})
+	if err := runHexdump(path, packetFilter{}, 256); err != nil {
+		t.Fatalf("runHexdump: %v", err)
+	}
+	if err := runHexdump(path, packetFilter{}, -1); err == nil {
+		t.Error("runHexdump accepted a negative limit")
+	}
+}
@@ -3,23 +3,58 @@
 // Usage:
 //
 //	replay --capture file.mhfr --mode dump     # Human-readable text output
+//	replay --capture file.mhfr --mode dump --decode  # With the fields of each packet
+//	replay --capture file.mhfr --mode hexdump  # Offset/hex/ASCII dump of each payload
+//	replay --capture file.mhfr --mode tui      # Browse packets interactively
+//	replay --capture file.mhfr --mode serve --listen :8090  # Browse packets in a web browser
+//	replay --capture file.mhfr --mode tail --backlog 20  # Follow a capture being written, like tail -f
+//	replay --capture file.mhfr --mode correlate --log erupe.log  # With the server log entries written meanwhile
 //	replay --capture file.mhfr --mode json     # JSON export
+//	replay --capture file.mhfr --mode ndjson --payloads  # One JSON object per line, streamed
 //	replay --capture file.mhfr --mode stats    # Opcode histogram, duration, counts
+//	replay --capture file.mhfr --mode verify   # Whether the capture is whole, truncated or corrupt
+//	replay --mode stats sign.mhfr entrance.mhfr channel.mhfr  # With the time spent in each stage
 //	replay --capture file.mhfr --mode replay --target 127.0.0.1:54001 --no-auth  # Replay against live server
+//	replay --capture a.mhfr --mode diff --against b.mhfr --bytes  # Compare responses request by request
+//	replay --capture a.mhfr --mode diff --against b.mhfr --rules rules.yaml  # Allowing for timestamps, random IDs and the like
+//	replay --capture file.mhfr --mode scrub --out shared.mhfr  # Without account IDs, credentials and tokens
+//	replay --capture file.mhfr --mode pcapng --out file.pcapng  # For Wireshark, framed as TCP
+//	replay --mode coverage channel1.mhfr channel2.mhfr  # Client opcodes without a handler or with a stub
+//	replay --capture file.mhfr --mode gentest --out server/channelserver/capture_login_test.go  # A regression test replaying it
+//	replay --mode fuzz --iterations 100000 --out crashes.mhfr channel.mhfr  # Mutated client packets through the parsers
+//	replay --mode merge --out session.mhfr sign.mhfr entrance.mhfr channel.mhfr  # One timeline from several captures
+//	replay --capture file.mhfr --mode split --split-by opcode --out pieces/      # One capture per opcode
+//	replay --capture file.mhfr --mode split --split-by window --window 5m        # One capture per 5 minutes
+//	replay --mode merge --container --out login.mhfr sign.mhfr entrance.mhfr channel.mhfr  # One file, a session each
+//	replay --capture login.mhfr --mode split --split-by session --out sessions/  # One capture per session of a container
+//
+// Every mode can be limited to some packets with --filter-opcode (numbers or
+// names, comma separated), --filter-direction (c2s or s2c) and --range, a
+// start:end span of packet indexes in the capture with either end optional.
+// Containers, captures holding several sessions, can also be limited to some
+// of them with --filter-session (IDs, comma separated).
+//
+// Encrypted captures are read with --key, or --key-file naming a secret
+// reference such as env:ERUPE_CAPTURE_KEY; captures written from them are
+// encrypted with the same key.
 package main
 
 import (
+	"bufio"
 	"encoding/binary"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
 	"os"
 	"sort"
+	"strings"
 	"sync"
 	"time"
 
 	"erupe-ce/cmd/protbot/conn"
+	cfg "erupe-ce/config"
 	"erupe-ce/network"
 	"erupe-ce/network/pcap"
 )
@@ -29,41 +64,168 @@ const opcodeSysPing = 0x0017
 
 func main() {
 	capturePath := flag.String("capture", "", "Path to .mhfr capture file (required)")
-	mode := flag.String("mode", "dump", "Mode: dump, json, stats, replay")
+	mode := flag.String("mode", "dump", "Mode: dump, hexdump, tui, serve, tail, correlate, json, ndjson, stats, verify, replay, diff, scrub, pcapng, coverage, fuzz, gentest, merge, split")
 	target := flag.String("target", "", "Target server address for replay mode (host:port)")
 	speed := flag.Float64("speed", 1.0, "Replay speed multiplier (e.g. 2.0 = 2x faster)")
+	decode := flag.Bool("decode", false, "Dump and correlate modes: print the fields of known packets, hexdumping the rest")
+	maxBytes := flag.Int("max-bytes", 256, "Hexdump mode: bytes of each payload to print, 0 for all (as DebugOptions.MaxHexdumpLength)")
+	listen := flag.String("listen", ":8090", "Serve mode: address the web viewer listens on")
+	backlog := flag.Int("backlog", 10, "Tail mode: packets written before it started to print first")
+	logPath := flag.String("log", "", "Correlate mode: server log to interleave with the packets, in the json or console format")
+	payloads := flag.Bool("payloads", false, "JSON and NDJSON modes: include each payload, base64 encoded")
 	noAuth := flag.Bool("no-auth", false, "Skip auth token patching (requires DisableTokenCheck on server)")
 	_ = noAuth // currently only no-auth mode is supported
+	filterOpcode := flag.String("filter-opcode", "", "Only packets with these opcodes, comma separated (e.g. 0x0017,MSG_SYS_ACK)")
+	filterDirection := flag.String("filter-direction", "", "Only packets in this direction: c2s or s2c")
+	filterSession := flag.String("filter-session", "", "Only packets of these sessions of a container, comma separated (e.g. 1,3)")
+	packetRange := flag.String("range", "", "Only packets with capture indexes in start:end (end exclusive, either optional)")
+	against := flag.String("against", "", "Diff mode: capture to compare --capture against")
+	showBytes := flag.Bool("bytes", false, "Diff mode: dump the differing lines of mismatched payloads")
+	rulesPath := flag.String("rules", "", "Replay and diff modes: YAML or JSON file of per-opcode tolerances (skip, size_delta, ignore byte ranges)")
+	out := flag.String("out", "", "Merge, scrub, pcapng, fuzz and gentest modes: file to write; split mode: directory to write to (default .)")
+	splitBy := flag.String("split-by", "opcode", "Split mode: opcode, window or session")
+	container := flag.Bool("container", false, "Merge mode: write a container, keeping each capture a session of its own")
+	idle := flag.Duration("idle", 30*time.Second, "Stats mode: report client inactivity longer than this")
+	window := flag.Duration("window", 5*time.Minute, "Split mode: length of each window")
+	compress := flag.String("compress", "", "Merge, scrub and split modes: gzip, zstd or none to compress the captures written; as the input when empty")
+	iterations := flag.Int("iterations", 10000, "Fuzz mode: mutated packets to parse")
+	seed := flag.Uint64("seed", 1, "Fuzz mode: random seed, the same seed fuzzing the same way")
+	key := flag.String("key", "", "Secret encrypted captures are read and written with (as Capture.EncryptionKey)")
+	keyFile := flag.String("key-file", "", "Secret reference --key is read from instead, such as env:NAME or a file")
 	flag.Parse()
 
-	if *capturePath == "" {
+	// Merge, stats, coverage and fuzz read the captures listed after the flags as well.
+	capturePaths := flag.Args()
+	if *capturePath != "" {
+		capturePaths = append([]string{*capturePath}, capturePaths...)
+	}
+	if *capturePath == "" && (*mode != "merge" && *mode != "stats" && *mode != "coverage" && *mode != "fuzz" || len(capturePaths) == 0) {
 		fmt.Fprintln(os.Stderr, "error: --capture is required")
 		flag.Usage()
 		os.Exit(1)
 	}
 
+	if *keyFile != "" {
+		var err error
+		if *key, err = cfg.LoadSecret(*keyFile); err != nil {
+			fmt.Fprintf(os.Stderr, "error: --key-file: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	if *key != "" {
+		captureKey = []byte(*key)
+	}
+
+	filter, err := parseFilter(*filterOpcode, *filterDirection, *packetRange)
+	if err == nil {
+		filter.sessions, err = parseSessions(*filterSession)
+	}
+	var rules compareRules
+	if err == nil {
+		rules, err = loadRules(*rulesPath)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+
 	switch *mode {
 	case "dump":
-		if err := runDump(*capturePath); err != nil {
+		if err := runDump(*capturePath, filter, *decode); err != nil {
 			fmt.Fprintf(os.Stderr, "dump failed: %v\n", err)
 			os.Exit(1)
 		}
+	case "hexdump":
+		if err := runHexdump(*capturePath, filter, *maxBytes); err != nil {
+			fmt.Fprintf(os.Stderr, "hexdump failed: %v\n", err)
+			os.Exit(1)
+		}
+	case "tui":
+		if err := runTUI(*capturePath, filter); err != nil {
+			fmt.Fprintf(os.Stderr, "tui failed: %v\n", err)
+			os.Exit(1)
+		}
+	case "serve":
+		if err := runServe(*capturePath, filter, *listen); err != nil {
+			fmt.Fprintf(os.Stderr, "serve failed: %v\n", err)
+			os.Exit(1)
+		}
+	case "tail":
+		if err := runTail(*capturePath, filter, *backlog); err != nil {
+			fmt.Fprintf(os.Stderr, "tail failed: %v\n", err)
+			os.Exit(1)
+		}
+	case "correlate":
+		if err := runCorrelate(*capturePath, filter, *logPath, *decode); err != nil {
+			fmt.Fprintf(os.Stderr, "correlate failed: %v\n", err)
+			os.Exit(1)
+		}
 	case "json":
-		if err := runJSON(*capturePath); err != nil {
+		if err := runJSON(*capturePath, filter, *payloads); err != nil {
 			fmt.Fprintf(os.Stderr, "json failed: %v\n", err)
 			os.Exit(1)
 		}
+	case "ndjson":
+		if err := runNDJSON(*capturePath, filter, *payloads); err != nil {
+			fmt.Fprintf(os.Stderr, "ndjson failed: %v\n", err)
+			os.Exit(1)
+		}
 	case "stats":
-		if err := runStats(*capturePath); err != nil {
+		if err := runStats(capturePaths, filter, *idle); err != nil {
 			fmt.Fprintf(os.Stderr, "stats failed: %v\n", err)
 			os.Exit(1)
 		}
+	case "verify":
+		if err := runVerify(*capturePath); err != nil {
+			fmt.Fprintf(os.Stderr, "verify failed: %v\n", err)
+			os.Exit(1)
+		}
+	case "diff":
+		if err := runDiff(*capturePath, *against, filter, *showBytes, rules); err != nil {
+			fmt.Fprintf(os.Stderr, "diff failed: %v\n", err)
+			os.Exit(1)
+		}
+	case "scrub":
+		if err := runScrub(*capturePath, filter, *out, *compress); err != nil {
+			fmt.Fprintf(os.Stderr, "scrub failed: %v\n", err)
+			os.Exit(1)
+		}
+	case "pcapng":
+		if err := runPcapng(*capturePath, filter, *out); err != nil {
+			fmt.Fprintf(os.Stderr, "pcapng failed: %v\n", err)
+			os.Exit(1)
+		}
+	case "coverage":
+		if err := runCoverage(capturePaths, filter); err != nil {
+			fmt.Fprintf(os.Stderr, "coverage failed: %v\n", err)
+			os.Exit(1)
+		}
+	case "fuzz":
+		if err := runFuzz(capturePaths, filter, *iterations, *seed, *out); err != nil {
+			fmt.Fprintf(os.Stderr, "fuzz failed: %v\n", err)
+			os.Exit(1)
+		}
+	case "gentest":
+		if err := runGentest(*capturePath, filter, *out); err != nil {
+			fmt.Fprintf(os.Stderr, "gentest failed: %v\n", err)
+			os.Exit(1)
+		}
+	case "merge":
+		if err := runMerge(capturePaths, filter, *out, *compress, *container); err != nil {
+			fmt.Fprintf(os.Stderr, "merge failed: %v\n", err)
+			os.Exit(1)
+		}
+	case "split":
+		if err := runSplit(*capturePath, filter, *splitBy, *window, *out, *compress); err != nil {
+			fmt.Fprintf(os.Stderr, "split failed: %v\n", err)
+			os.Exit(1)
+		}
 	case "replay":
 		if *target == "" {
 			fmt.Fprintln(os.Stderr, "error: --target is required for replay mode")
 			os.Exit(1)
 		}
-		if err := runReplay(*capturePath, *target, *speed); err != nil {
+		if err := runReplay(*capturePath, filter, *target, *speed, rules); err != nil {
 			fmt.Fprintf(os.Stderr, "replay failed: %v\n", err)
 			os.Exit(1)
 		}
@@ -73,6 +235,9 @@ func main() {
 	}
 }
 
+// captureKey is the secret encrypted captures are read and written with.
+var captureKey []byte
+
 func openCapture(path string) (*pcap.Reader, *os.File, error) {
 	f, err := os.Open(path)
 	if err != nil {
@@ -83,32 +248,27 @@ func openCapture(path string) (*pcap.Reader, *os.File, error) {
 		_ = f.Close()
 		return nil, nil, fmt.Errorf("read capture: %w", err)
 	}
-	return r, f, nil
-}
-
-func readAllPackets(r *pcap.Reader) ([]pcap.PacketRecord, error) {
-	var records []pcap.PacketRecord
-	for {
-		rec, err := r.ReadPacket()
-		if err == io.EOF {
-			break
+	if r.Header.Flags&pcap.FlagEncrypted != 0 {
+		if captureKey == nil {
+			_ = f.Close()
+			return nil, nil, errors.New("capture is encrypted, pass --key or --key-file")
 		}
-		if err != nil {
-			return records, err
+		if err := r.SetKey(captureKey); err != nil {
+			_ = f.Close()
+			return nil, nil, err
 		}
-		records = append(records, rec)
 	}
-	return records, nil
+	return r, f, nil
 }
 
-func runReplay(path, target string, speed float64) error {
+func runReplay(path string, filter packetFilter, target string, speed float64, rules compareRules) error {
 	r, f, err := openCapture(path)
 	if err != nil {
 		return err
 	}
 	defer func() { _ = f.Close() }()
 
-	records, err := readAllPackets(r)
+	records, _, err := readPackets(r, filter)
 	if err != nil {
 		return err
 	}
@@ -137,8 +297,9 @@ func runReplay(path, target string, speed float64) error {
 		return fmt.Errorf("connect to %s: %w", target, err)
 	}
 
-	// Collect S→C responses concurrently.
-	var actualS2C []pcap.PacketRecord
+	// Collect S→C responses concurrently, with the requests sent so
+	// ComparePackets can tell which request each answers.
+	var actual []pcap.PacketRecord
 	var mu sync.Mutex
 	done := make(chan struct{})
 
@@ -162,7 +323,7 @@ func runReplay(path, target string, speed float64) error {
 			}
 
 			mu.Lock()
-			actualS2C = append(actualS2C, pcap.PacketRecord{
+			actual = append(actual, pcap.PacketRecord{
 				TimestampNs: time.Now().UnixNano(),
 				Direction:   pcap.DirServerToClient,
 				Opcode:      opcode,
@@ -184,6 +345,14 @@ func runReplay(path, target string, speed float64) error {
 		lastTs = pkt.TimestampNs
 		opcodeName := network.PacketID(pkt.Opcode).String()
 		fmt.Printf("[replay] #%d sending 0x%04X %-30s (%d bytes)\n", i, pkt.Opcode, opcodeName, len(pkt.Payload))
+		mu.Lock()
+		actual = append(actual, pcap.PacketRecord{
+			TimestampNs: time.Now().UnixNano(),
+			Direction:   pcap.DirClientToServer,
+			Opcode:      pkt.Opcode,
+			Payload:     pkt.Payload,
+		})
+		mu.Unlock()
 		if err := mhf.SendPacket(pkt.Payload); err != nil {
 			fmt.Printf("[replay] send error: %v\n", err)
 			break
@@ -198,7 +367,8 @@ func runReplay(path, target string, speed float64) error {
 
 	// Compare.
 	mu.Lock()
-	diffs := ComparePackets(expectedS2C, actualS2C)
+	diffs := ComparePackets(records, actual, rules)
+	actualS2C := pcap.FilterByDirection(actual, pcap.DirServerToClient)
 	mu.Unlock()
 
 	// Report.
@@ -224,42 +394,67 @@ func buildPingResponse() []byte {
 	return []byte{0x00, 0x17, 0x00, 0x10}
 }
 
-func runDump(path string) error {
+func runDump(path string, filter packetFilter, decode bool) error {
 	r, f, err := openCapture(path)
 	if err != nil {
 		return err
 	}
 	defer func() { _ = f.Close() }()
 
-	// Print header info.
-	startTime := time.Unix(0, r.Header.SessionStartNs)
-	fmt.Printf("=== MHFR Capture: %s ===\n", path)
-	fmt.Printf("Server: %s  ClientMode: %d  Start: %s\n",
-		r.Header.ServerType, r.Header.ClientMode, startTime.Format(time.RFC3339Nano))
-	if r.Meta.Host != "" {
-		fmt.Printf("Host: %s  Port: %d  Remote: %s\n", r.Meta.Host, r.Meta.Port, r.Meta.RemoteAddr)
-	}
-	if r.Meta.CharID != 0 {
-		fmt.Printf("CharID: %d  UserID: %d\n", r.Meta.CharID, r.Meta.UserID)
-	}
-	fmt.Println()
+	printCaptureHeader(os.Stdout, path, r)
 
-	records, err := readAllPackets(r)
+	records, indexes, err := readPackets(r, filter)
 	if err != nil {
 		return err
 	}
 
+	ctx := captureContext(r.Header)
 	for i, rec := range records {
-		elapsed := time.Duration(rec.TimestampNs - r.Header.SessionStartNs)
-		opcodeName := network.PacketID(rec.Opcode).String()
-		fmt.Printf("#%04d  +%-12s  %s  0x%04X %-30s  %d bytes\n",
-			i, elapsed, rec.Direction, rec.Opcode, opcodeName, len(rec.Payload))
+		writePacketLine(os.Stdout, indexes[i], rec, r.Header.SessionStartNs)
+		if decode {
+			writeDecoded(os.Stdout, rec, packetServerType(r, rec), ctx)
+		}
 	}
 
 	fmt.Printf("\nTotal: %d packets\n", len(records))
 	return nil
 }
 
+// printCaptureHeader prints the file header and metadata of a capture.
+func printCaptureHeader(w io.Writer, path string, r *pcap.Reader) {
+	startTime := time.Unix(0, r.Header.SessionStartNs)
+	fmt.Fprintf(w, "=== MHFR Capture: %s ===\n", path)
+	fmt.Fprintf(w, "Server: %s  ClientMode: %d  Start: %s  Compression: %s\n",
+		r.Header.ServerType, r.Header.ClientMode, startTime.Format(time.RFC3339Nano), pcap.Compression(r.Header.Flags))
+	if r.Meta.Host != "" {
+		fmt.Fprintf(w, "Host: %s  Port: %d  Remote: %s\n", r.Meta.Host, r.Meta.Port, r.Meta.RemoteAddr)
+	}
+	if r.Meta.CharID != 0 {
+		fmt.Fprintf(w, "CharID: %d  UserID: %d\n", r.Meta.CharID, r.Meta.UserID)
+	}
+	if r.Header.Container() {
+		fmt.Fprintf(w, "Sessions: %d\n", len(r.Meta.Sessions))
+		for _, s := range r.Meta.Sessions {
+			fmt.Fprintf(w, "  s%-3d %-9s +%-12s CharID: %d  UserID: %d  %s\n", s.ID, s.ServerType,
+				time.Duration(s.StartNs-r.Header.SessionStartNs), s.CharID, s.UserID, s.Source)
+		}
+	}
+	fmt.Fprintln(w)
+}
+
+// writePacketLine writes the one-line summary of the packet at index. The
+// packets of a container are marked with their session.
+func writePacketLine(w io.Writer, index int, rec pcap.PacketRecord, startNs int64) {
+	elapsed := time.Duration(rec.TimestampNs - startNs)
+	opcodeName := network.PacketID(rec.Opcode).String()
+	session := ""
+	if rec.Session != 0 {
+		session = fmt.Sprintf("s%-3d ", rec.Session)
+	}
+	fmt.Fprintf(w, "#%04d  +%-12s  %s%s  0x%04X %-30s  %d bytes\n",
+		index, elapsed, session, rec.Direction, rec.Opcode, opcodeName, len(rec.Payload))
+}
+
 type jsonCapture struct {
 	Header  jsonHeader           `json:"header"`
 	Meta    pcap.SessionMetadata `json:"metadata"`
@@ -274,48 +469,65 @@ type jsonHeader struct {
 }
 
 type jsonPacket struct {
-	Index      int    `json:"index"`
-	Timestamp  string `json:"timestamp"`
-	ElapsedNs  int64  `json:"elapsed_ns"`
-	Direction  string `json:"direction"`
-	Opcode     uint16 `json:"opcode"`
-	OpcodeName string `json:"opcode_name"`
-	PayloadLen int    `json:"payload_len"`
+	Index        int    `json:"index"`
+	Timestamp    string `json:"timestamp"`
+	ElapsedNs    int64  `json:"elapsed_ns"`
+	Direction    string `json:"direction"`
+	Session      uint16 `json:"session,omitempty"` // In a container
+	Opcode       uint16 `json:"opcode"`
+	OpcodeName   string `json:"opcode_name"`
+	PayloadLen   int    `json:"payload_len"`
+	ProcessingNs int64  `json:"processing_ns,omitempty"` // Time the server took to handle it, when recorded
+	Payload      []byte `json:"payload,omitempty"`       // Base64, with --payloads
 }
 
-func runJSON(path string) error {
+func newJSONHeader(hdr pcap.FileHeader) jsonHeader {
+	return jsonHeader{
+		Version:    hdr.Version,
+		ServerType: hdr.ServerType.String(),
+		ClientMode: int(hdr.ClientMode),
+		StartTime:  time.Unix(0, hdr.SessionStartNs).Format(time.RFC3339Nano),
+	}
+}
+
+func newJSONPacket(index int, rec pcap.PacketRecord, startNs int64, payload bool) jsonPacket {
+	p := jsonPacket{
+		Index:        index,
+		Timestamp:    time.Unix(0, rec.TimestampNs).Format(time.RFC3339Nano),
+		ElapsedNs:    rec.TimestampNs - startNs,
+		Direction:    rec.Direction.String(),
+		Session:      rec.Session,
+		Opcode:       rec.Opcode,
+		OpcodeName:   network.PacketID(rec.Opcode).String(),
+		PayloadLen:   len(rec.Payload),
+		ProcessingNs: rec.ProcessingNs,
+	}
+	if payload {
+		p.Payload = rec.Payload
+	}
+	return p
+}
+
+func runJSON(path string, filter packetFilter, payloads bool) error {
 	r, f, err := openCapture(path)
 	if err != nil {
 		return err
 	}
 	defer func() { _ = f.Close() }()
 
-	records, err := readAllPackets(r)
+	records, indexes, err := readPackets(r, filter)
 	if err != nil {
 		return err
 	}
 
 	out := jsonCapture{
-		Header: jsonHeader{
-			Version:    r.Header.Version,
-			ServerType: r.Header.ServerType.String(),
-			ClientMode: int(r.Header.ClientMode),
-			StartTime:  time.Unix(0, r.Header.SessionStartNs).Format(time.RFC3339Nano),
-		},
+		Header:  newJSONHeader(r.Header),
 		Meta:    r.Meta,
 		Packets: make([]jsonPacket, len(records)),
 	}
 
 	for i, rec := range records {
-		out.Packets[i] = jsonPacket{
-			Index:      i,
-			Timestamp:  time.Unix(0, rec.TimestampNs).Format(time.RFC3339Nano),
-			ElapsedNs:  rec.TimestampNs - r.Header.SessionStartNs,
-			Direction:  rec.Direction.String(),
-			Opcode:     rec.Opcode,
-			OpcodeName: network.PacketID(rec.Opcode).String(),
-			PayloadLen: len(rec.Payload),
-		}
+		out.Packets[i] = newJSONPacket(indexes[i], rec, r.Header.SessionStartNs, payloads)
 	}
 
 	enc := json.NewEncoder(os.Stdout)
@@ -323,17 +535,50 @@ func runJSON(path string) error {
 	return enc.Encode(out)
 }
 
-func runStats(path string) error {
+// runNDJSON writes the capture as JSON lines, the header and metadata
+// first and then one line per packet, as the packets are read, so large
+// captures are exported without holding them in memory.
+func runNDJSON(path string, filter packetFilter, payloads bool) error {
 	r, f, err := openCapture(path)
 	if err != nil {
 		return err
 	}
 	defer func() { _ = f.Close() }()
 
-	records, err := readAllPackets(r)
+	bw := bufio.NewWriter(os.Stdout)
+	enc := json.NewEncoder(bw)
+	err = enc.Encode(struct {
+		Header jsonHeader           `json:"header"`
+		Meta   pcap.SessionMetadata `json:"metadata"`
+	}{newJSONHeader(r.Header), r.Meta})
+	if err != nil {
+		return err
+	}
+	err = eachPacket(r, filter, func(i int, rec pcap.PacketRecord) error {
+		return enc.Encode(newJSONPacket(i, rec, r.Header.SessionStartNs, payloads))
+	})
 	if err != nil {
 		return err
 	}
+	return bw.Flush()
+}
+
+func runStats(paths []string, filter packetFilter, idle time.Duration) error {
+	var captures []sessionCapture
+	var records []pcap.PacketRecord
+	for _, p := range paths {
+		r, f, err := openCapture(p)
+		if err != nil {
+			return fmt.Errorf("%s: %w", p, err)
+		}
+		recs, _, err := readPackets(r, filter)
+		_ = f.Close()
+		if err != nil {
+			return fmt.Errorf("%s: %w", p, err)
+		}
+		captures = append(captures, sessionCapture{path: p, hdr: r.Header, records: recs})
+		records = append(records, recs...)
+	}
 
 	if len(records) == 0 {
 		fmt.Println("Empty capture (0 packets)")
@@ -378,11 +623,19 @@ func runStats(path string) error {
 		return sorted[i].count > sorted[j].count
 	})
 
-	duration := time.Duration(records[len(records)-1].TimestampNs - records[0].TimestampNs)
+	first, last := records[0].TimestampNs, records[0].TimestampNs
+	for _, rec := range records {
+		first, last = min(first, rec.TimestampNs), max(last, rec.TimestampNs)
+	}
+	duration := time.Duration(last - first)
 
-	fmt.Printf("=== Capture Stats: %s ===\n", path)
+	servers := make([]string, len(captures))
+	for i, c := range captures {
+		servers[i] = c.hdr.ServerType.String()
+	}
+	fmt.Printf("=== Capture Stats: %s ===\n", strings.Join(paths, ", "))
 	fmt.Printf("Server: %s  Duration: %s  Packets: %d\n",
-		r.Header.ServerType, duration, len(records))
+		strings.Join(servers, ", "), duration, len(records))
 	fmt.Printf("C→S: %d packets (%d bytes)  S→C: %d packets (%d bytes)\n\n",
 		totalC2S, bytesC2S, totalS2C, bytesS2C)
 
@@ -393,5 +646,7 @@ func runStats(path string) error {
 		fmt.Printf("0x%04X   %-35s %8d %10d\n", s.opcode, name, s.count, s.bytes)
 	}
 
+	writeHandlerTimes(os.Stdout, handlerTimes(records))
+	analyzeSession(captures, idle).write(os.Stdout, idle)
 	return nil
 }
@@ -0,0 +1,336 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"erupe-ce/network"
+	"erupe-ce/network/pcap"
+)
+
+// captureWriter is an open .mhfr file being written.
+type captureWriter struct {
+	path  string
+	f     *os.File
+	w     *pcap.Writer
+	count int
+}
+
+// createCapture creates a capture at path. compress names the compression
+// of its packets, or is empty to keep the one in hdr. A capture with the
+// encryption flag in hdr is encrypted with captureKey.
+func createCapture(path string, hdr pcap.FileHeader, meta pcap.SessionMetadata, compress string) (*captureWriter, error) {
+	if compress != "" {
+		flags, err := pcap.CompressionFlag(compress)
+		if err != nil {
+			return nil, err
+		}
+		hdr.Flags = hdr.Flags&^(pcap.FlagGzip|pcap.FlagZstd) | flags
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	var w *pcap.Writer
+	if hdr.Flags&pcap.FlagEncrypted != 0 {
+		w, err = pcap.NewEncryptedWriter(f, hdr, meta, captureKey)
+	} else {
+		w, err = pcap.NewWriter(f, hdr, meta)
+	}
+	if err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	return &captureWriter{path: path, f: f, w: w}, nil
+}
+
+func (c *captureWriter) write(rec pcap.PacketRecord) error {
+	c.count++
+	return c.w.WritePacket(rec)
+}
+
+func (c *captureWriter) close() error {
+	if err := c.w.Close(); err != nil {
+		_ = c.f.Close()
+		return err
+	}
+	return c.f.Close()
+}
+
+// mergeHeader returns the header and metadata of the capture merging
+// readers. The server type and client mode are taken from the latest
+// stage of the session captured, channel over entrance over sign, as the
+// one whose packets dump --decode can read.
+func mergeHeader(readers []*pcap.Reader, paths []string) (pcap.FileHeader, pcap.SessionMetadata) {
+	main := readers[0]
+	start := main.Header.SessionStartNs
+	for _, r := range readers[1:] {
+		if r.Header.ServerType > main.Header.ServerType {
+			main = r
+		}
+		start = min(start, r.Header.SessionStartNs)
+	}
+	hdr := main.Header
+	hdr.SessionStartNs = start
+	meta := main.Meta
+	for _, r := range readers {
+		if meta.CharID == 0 {
+			meta.CharID = r.Meta.CharID
+		}
+		if meta.UserID == 0 {
+			meta.UserID = r.Meta.UserID
+		}
+	}
+	meta.Merged = make([]string, len(paths))
+	for i, p := range paths {
+		meta.Merged[i] = filepath.Base(p)
+	}
+	return hdr, meta
+}
+
+// containerHeader returns the header and metadata of a container holding
+// the captures of readers, the session of each being its position from 1.
+func containerHeader(readers []*pcap.Reader, paths []string) (pcap.FileHeader, pcap.SessionMetadata) {
+	hdr, meta := mergeHeader(readers, paths)
+	hdr.Version = pcap.ContainerVersion
+	meta.Sessions = make([]pcap.SessionInfo, len(readers))
+	for i, r := range readers {
+		meta.Sessions[i] = pcap.SessionInfo{
+			ID:         uint16(i + 1),
+			ServerType: r.Header.ServerType,
+			ClientMode: r.Header.ClientMode,
+			StartNs:    r.Header.SessionStartNs,
+			Host:       r.Meta.Host,
+			Port:       r.Meta.Port,
+			CharID:     r.Meta.CharID,
+			UserID:     r.Meta.UserID,
+			RemoteAddr: r.Meta.RemoteAddr,
+			Source:     filepath.Base(paths[i]),
+		}
+	}
+	return hdr, meta
+}
+
+// sessionHeader returns the header and metadata of a capture of the
+// session id of the container r.
+func sessionHeader(r *pcap.Reader, id uint16) (pcap.FileHeader, pcap.SessionMetadata) {
+	hdr := r.Header
+	hdr.Version = pcap.FormatVersion
+	meta := pcap.SessionMetadata{ServerVersion: r.Meta.ServerVersion}
+	if s, ok := r.Meta.Session(id); ok {
+		hdr.ServerType, hdr.ClientMode, hdr.SessionStartNs = s.ServerType, s.ClientMode, s.StartNs
+		meta.Host, meta.Port, meta.RemoteAddr = s.Host, s.Port, s.RemoteAddr
+		meta.CharID, meta.UserID = s.CharID, s.UserID
+	}
+	return hdr, meta
+}
+
+// runMerge writes the packets of the captures at paths to out as one
+// timeline, in timestamp order. The filter applies to the merged timeline.
+// As a container, each capture stays a session of its own in out.
+func runMerge(paths []string, filter packetFilter, out, compress string, container bool) error {
+	if len(paths) < 2 {
+		return errors.New("merge needs at least two captures")
+	}
+	if out == "" {
+		return errors.New("--out is required")
+	}
+	readers := make([]*pcap.Reader, len(paths))
+	for i, p := range paths {
+		r, f, err := openCapture(p)
+		if err != nil {
+			return fmt.Errorf("%s: %w", p, err)
+		}
+		defer func() { _ = f.Close() }()
+		if r.Header.Container() {
+			return fmt.Errorf("%s is a container, split it by session first", p)
+		}
+		readers[i] = r
+	}
+
+	hdr, meta := mergeHeader(readers, paths)
+	if container {
+		hdr, meta = containerHeader(readers, paths)
+	}
+	w, err := createCapture(out, hdr, meta, compress)
+	if err != nil {
+		return err
+	}
+
+	// Each capture is in timestamp order, so the next packet of the merged
+	// timeline is always the earliest next packet of one of them.
+	heads := make([]*pcap.PacketRecord, len(readers))
+	next := func(i int) error {
+		rec, err := readers[i].ReadPacket()
+		if err != nil {
+			heads[i] = nil
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return fmt.Errorf("%s: %w", paths[i], err)
+		}
+		heads[i] = &rec
+		return nil
+	}
+	for i := range readers {
+		if err := next(i); err != nil {
+			_ = w.close()
+			return err
+		}
+	}
+	for index := 0; !filter.done(index); index++ {
+		first := -1
+		for i, h := range heads {
+			if h != nil && (first < 0 || h.TimestampNs < heads[first].TimestampNs) {
+				first = i
+			}
+		}
+		if first < 0 {
+			break
+		}
+		if container {
+			heads[first].Session = uint16(first + 1)
+		}
+		if filter.match(index, *heads[first]) {
+			if err := w.write(*heads[first]); err != nil {
+				_ = w.close()
+				return err
+			}
+		}
+		if err := next(first); err != nil {
+			_ = w.close()
+			return err
+		}
+	}
+	if err := w.close(); err != nil {
+		return err
+	}
+	if container {
+		fmt.Printf("Wrote %s (%d packets from %d captures, a session each)\n", out, w.count, len(paths))
+		return nil
+	}
+	fmt.Printf("Wrote %s (%d packets from %d captures, server %s)\n", out, w.count, len(paths), hdr.ServerType)
+	return nil
+}
+
+// runSplit writes the packets of the capture at path to one capture per
+// opcode, or per window of time since the session started, in dir. The
+// pieces keep the original header, so their elapsed times still line up.
+// A container can also be split into a capture per session, each with the
+// header of its session.
+func runSplit(path string, filter packetFilter, by string, window time.Duration, dir, compress string) error {
+	if by != "opcode" && by != "window" && by != "session" {
+		return fmt.Errorf("invalid --split-by %q, want opcode, window or session", by)
+	}
+	if by == "window" && window <= 0 {
+		return errors.New("--window must be positive")
+	}
+	r, f, err := openCapture(path)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = f.Close() }()
+	if by == "session" && !r.Header.Container() {
+		return errors.New("only a container can be split by session")
+	}
+	if dir == "" {
+		dir = "."
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	base := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	create := func(suffix string) (*captureWriter, error) {
+		return createCapture(filepath.Join(dir, base+"-"+suffix+".mhfr"), r.Header, r.Meta, compress)
+	}
+
+	var written []*captureWriter
+	var open []*captureWriter
+	switch by {
+	case "session":
+		bySession := map[uint16]*captureWriter{}
+		err = eachPacket(r, filter, func(_ int, rec pcap.PacketRecord) error {
+			w := bySession[rec.Session]
+			if w == nil {
+				hdr, meta := sessionHeader(r, rec.Session)
+				name := fmt.Sprintf("%s-s%d-%s.mhfr", base, rec.Session, hdr.ServerType)
+				var err error
+				if w, err = createCapture(filepath.Join(dir, name), hdr, meta, compress); err != nil {
+					return err
+				}
+				bySession[rec.Session] = w
+				written = append(written, w)
+				open = append(open, w)
+			}
+			rec.Session = 0
+			return w.write(rec)
+		})
+	case "opcode":
+		byOpcode := map[uint16]*captureWriter{}
+		err = eachPacket(r, filter, func(_ int, rec pcap.PacketRecord) error {
+			w := byOpcode[rec.Opcode]
+			if w == nil {
+				var err error
+				if w, err = create(opcodeFileName(rec.Opcode)); err != nil {
+					return err
+				}
+				byOpcode[rec.Opcode] = w
+				written = append(written, w)
+				open = append(open, w)
+			}
+			return w.write(rec)
+		})
+	default:
+		// Windows are written one at a time, so a long session split finely
+		// does not hold a file open per window. A packet recorded out of
+		// order across a boundary stays in the window before it.
+		var current *captureWriter
+		currentWindow := int64(-1)
+		err = eachPacket(r, filter, func(_ int, rec pcap.PacketRecord) error {
+			if n := (rec.TimestampNs - r.Header.SessionStartNs) / int64(window); n > currentWindow {
+				if current != nil {
+					open = open[:0]
+					if err := current.close(); err != nil {
+						return err
+					}
+				}
+				var err error
+				if current, err = create(fmt.Sprintf("%04d", n)); err != nil {
+					return err
+				}
+				currentWindow = n
+				written = append(written, current)
+				open = append(open, current)
+			}
+			return current.write(rec)
+		})
+	}
+	errs := []error{err}
+	for _, w := range open {
+		errs = append(errs, w.close())
+	}
+	if err := errors.Join(errs...); err != nil {
+		return err
+	}
+	for _, w := range written {
+		fmt.Printf("Wrote %s (%d packets)\n", w.path, w.count)
+	}
+	if len(written) == 0 {
+		fmt.Println("No packets to split")
+	}
+	return nil
+}
+
+// opcodeFileName names the piece of a split holding opcode.
+func opcodeFileName(opcode uint16) string {
+	name := network.PacketID(opcode).String()
+	if strings.HasPrefix(name, "PacketID(") {
+		return fmt.Sprintf("0x%04X", opcode)
+	}
+	return name
+}
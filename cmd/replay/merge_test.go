@@ -0,0 +1,243 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"erupe-ce/network/pcap"
+)
+
+// writeCapture writes a capture of the given server type to path.
+func writeCapture(t *testing.T, path string, st pcap.ServerType, startNs int64, meta pcap.SessionMetadata, records []pcap.PacketRecord) {
+	t.Helper()
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = f.Close() }()
+	w, err := pcap.NewWriter(f, pcap.FileHeader{Version: pcap.FormatVersion, ServerType: st, ClientMode: 40, SessionStartNs: startNs}, meta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, rec := range records {
+		if err := w.WritePacket(rec); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// readCapture returns the header, metadata and packet timestamps of the
+// capture at path.
+func readCapture(t *testing.T, path string) (pcap.FileHeader, pcap.SessionMetadata, []int64) {
+	t.Helper()
+	r, f, err := openCapture(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = f.Close() }()
+	records, _, err := readPackets(r, packetFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ts []int64
+	for _, rec := range records {
+		ts = append(ts, rec.TimestampNs)
+	}
+	return r.Header, r.Meta, ts
+}
+
+func packet(ts int64, op uint16) pcap.PacketRecord {
+	return pcap.PacketRecord{TimestampNs: ts, Direction: pcap.DirClientToServer, Opcode: op, Payload: []byte{byte(op >> 8), byte(op)}}
+}
+
+func TestRunMerge(t *testing.T) {
+	dir := t.TempDir()
+	sign := filepath.Join(dir, "sign.mhfr")
+	channel := filepath.Join(dir, "channel.mhfr")
+	writeCapture(t, sign, pcap.ServerTypeSign, 100, pcap.SessionMetadata{UserID: 7}, []pcap.PacketRecord{packet(110, 1), packet(130, 1)})
+	writeCapture(t, channel, pcap.ServerTypeChannel, 120, pcap.SessionMetadata{CharID: 3}, []pcap.PacketRecord{packet(120, 0x17), packet(125, 0x17), packet(140, 0x17)})
+
+	out := filepath.Join(dir, "merged.mhfr")
+	if err := runMerge([]string{channel, sign}, packetFilter{}, out, "", false); err != nil {
+		t.Fatal(err)
+	}
+	hdr, meta, ts := readCapture(t, out)
+	if hdr.ServerType != pcap.ServerTypeChannel || hdr.SessionStartNs != 100 {
+		t.Errorf("header = %+v", hdr)
+	}
+	if meta.CharID != 3 || meta.UserID != 7 || !slices.Equal(meta.Merged, []string{"channel.mhfr", "sign.mhfr"}) {
+		t.Errorf("metadata = %+v", meta)
+	}
+	if want := []int64{110, 120, 125, 130, 140}; !slices.Equal(ts, want) {
+		t.Errorf("timestamps = %v, want %v", ts, want)
+	}
+
+	f, _ := parseFilter("", "", "1:3")
+	if err := runMerge([]string{channel, sign}, f, out, "", false); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, ts := readCapture(t, out); !slices.Equal(ts, []int64{120, 125}) {
+		t.Errorf("filtered timestamps = %v", ts)
+	}
+
+	if err := runMerge([]string{channel, sign}, packetFilter{}, out, "zstd", false); err != nil {
+		t.Fatal(err)
+	}
+	if hdr, _, ts := readCapture(t, out); hdr.Flags != pcap.FlagZstd || len(ts) != 5 {
+		t.Errorf("compressed merge = %+v, %v", hdr, ts)
+	}
+
+	if err := runMerge([]string{sign}, packetFilter{}, out, "", false); err == nil {
+		t.Error("merged a single capture")
+	}
+}
+
+func TestRunSplit(t *testing.T) {
+	dir := t.TempDir()
+	in := filepath.Join(dir, "session.mhfr")
+	sec := int64(time.Second)
+	writeCapture(t, in, pcap.ServerTypeChannel, 0, pcap.SessionMetadata{}, []pcap.PacketRecord{
+		packet(1*sec, 0x17), packet(2*sec, 0x12), packet(61*sec, 0x17), packet(200*sec, 0x12),
+	})
+
+	byOpcode := filepath.Join(dir, "opcode")
+	if err := runSplit(in, packetFilter{}, "opcode", 0, byOpcode, ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, ts := readCapture(t, filepath.Join(byOpcode, "session-MSG_SYS_PING.mhfr")); !slices.Equal(ts, []int64{1 * sec, 61 * sec}) {
+		t.Errorf("MSG_SYS_PING = %v", ts)
+	}
+	if _, _, ts := readCapture(t, filepath.Join(byOpcode, "session-MSG_SYS_ACK.mhfr")); !slices.Equal(ts, []int64{2 * sec, 200 * sec}) {
+		t.Errorf("MSG_SYS_ACK = %v", ts)
+	}
+
+	byWindow := filepath.Join(dir, "window")
+	if err := runSplit(in, packetFilter{}, "window", time.Minute, byWindow, ""); err != nil {
+		t.Fatal(err)
+	}
+	entries, _ := os.ReadDir(byWindow)
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	if got := strings.Join(names, " "); got != "session-0000.mhfr session-0001.mhfr session-0003.mhfr" {
+		t.Errorf("windows = %s", got)
+	}
+	if hdr, _, ts := readCapture(t, filepath.Join(byWindow, "session-0001.mhfr")); !slices.Equal(ts, []int64{61 * sec}) || hdr.SessionStartNs != 0 {
+		t.Errorf("window 1 = %v, %+v", ts, hdr)
+	}
+
+	if err := runSplit(in, packetFilter{}, "window", 0, byWindow, ""); err == nil {
+		t.Error("split with an empty window")
+	}
+	if err := runSplit(in, packetFilter{}, "size", 0, byWindow, ""); err == nil {
+		t.Error("split by an unknown key")
+	}
+}
+
+func TestContainer(t *testing.T) {
+	dir := t.TempDir()
+	sign := filepath.Join(dir, "sign.mhfr")
+	channel := filepath.Join(dir, "channel.mhfr")
+	writeCapture(t, sign, pcap.ServerTypeSign, 100, pcap.SessionMetadata{UserID: 7}, []pcap.PacketRecord{packet(110, 1), packet(130, 1)})
+	writeCapture(t, channel, pcap.ServerTypeChannel, 120, pcap.SessionMetadata{CharID: 3}, []pcap.PacketRecord{packet(120, 0x17), packet(125, 0x17), packet(140, 0x17)})
+
+	out := filepath.Join(dir, "login.mhfr")
+	if err := runMerge([]string{channel, sign}, packetFilter{}, out, "", true); err != nil {
+		t.Fatal(err)
+	}
+	hdr, meta, ts := readCapture(t, out)
+	if !hdr.Container() || len(meta.Sessions) != 2 || !slices.Equal(ts, []int64{110, 120, 125, 130, 140}) {
+		t.Fatalf("container = %+v, %+v, %v", hdr, meta, ts)
+	}
+	if s, _ := meta.Session(2); s.ServerType != pcap.ServerTypeSign || s.StartNs != 100 || s.UserID != 7 || s.Source != "sign.mhfr" {
+		t.Errorf("session 2 = %+v", s)
+	}
+
+	r, f, err := openCapture(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	records, _, err := readPackets(r, packetFilter{sessions: map[uint16]bool{2: true}})
+	_ = f.Close()
+	if err != nil || len(records) != 2 || records[1].TimestampNs != 130 {
+		t.Errorf("packets of session 2 = %+v, %v", records, err)
+	}
+	var sb strings.Builder
+	writePacketLine(&sb, 0, records[0], 100)
+	if !strings.Contains(sb.String(), "s2   C→S") {
+		t.Errorf("packet line %q does not show the session", sb.String())
+	}
+
+	// Splitting by session gives the captures back.
+	pieces := filepath.Join(dir, "pieces")
+	if err := runSplit(out, packetFilter{}, "session", 0, pieces, ""); err != nil {
+		t.Fatal(err)
+	}
+	hdr, meta, ts = readCapture(t, filepath.Join(pieces, "login-s2-sign.mhfr"))
+	if hdr.Container() || hdr.ServerType != pcap.ServerTypeSign || hdr.SessionStartNs != 100 || meta.UserID != 7 || !slices.Equal(ts, []int64{110, 130}) {
+		t.Errorf("sign session = %+v, %+v, %v", hdr, meta, ts)
+	}
+	if _, meta, ts := readCapture(t, filepath.Join(pieces, "login-s1-channel.mhfr")); meta.CharID != 3 || !slices.Equal(ts, []int64{120, 125, 140}) {
+		t.Errorf("channel session = %+v, %v", meta, ts)
+	}
+
+	if err := runSplit(sign, packetFilter{}, "session", 0, pieces, ""); err == nil {
+		t.Error("split a single session capture by session")
+	}
+	if err := runMerge([]string{out, sign}, packetFilter{}, filepath.Join(dir, "again.mhfr"), "", true); err == nil {
+		t.Error("merged a container")
+	}
+}
+
+func TestOpcodeFileName(t *testing.T) {
+	if got := opcodeFileName(0x0017); got != "MSG_SYS_PING" {
+		t.Errorf("opcodeFileName(0x0017) = %s", got)
+	}
+	if got := opcodeFileName(0xFFFF); got != "0xFFFF" {
+		t.Errorf("opcodeFileName(0xFFFF) = %s", got)
+	}
+}
+
+func TestEncryptedCaptures(t *testing.T) {
+	dir := t.TempDir()
+	in := filepath.Join(dir, "in.mhfr")
+	f, err := os.Create(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w, err := pcap.NewEncryptedWriter(f, pcap.FileHeader{Version: pcap.FormatVersion, ServerType: pcap.ServerTypeChannel}, pcap.SessionMetadata{}, []byte("k"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, rec := range []pcap.PacketRecord{packet(1, 0x0013), packet(2, 0x0017)} {
+		_ = w.WritePacket(rec)
+	}
+	if err := errors.Join(w.Close(), f.Close()); err != nil {
+		t.Fatal(err)
+	}
+
+	captureKey = nil
+	if _, _, err := openCapture(in); err == nil || !strings.Contains(err.Error(), "--key") {
+		t.Fatalf("openCapture() without a key = %v", err)
+	}
+
+	captureKey = []byte("k")
+	defer func() { captureKey = nil }()
+	out := filepath.Join(dir, "out.mhfr")
+	if err := runScrub(in, packetFilter{}, out, ""); err != nil {
+		t.Fatal(err)
+	}
+	hdr, _, ts := readCapture(t, out)
+	if hdr.Flags&pcap.FlagEncrypted == 0 || !slices.Equal(ts, []int64{1, 2}) {
+		t.Errorf("scrubbed capture: flags %#x, packets %v", hdr.Flags, ts)
+	}
+}
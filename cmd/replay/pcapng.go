@@ -0,0 +1,291 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+
+	"erupe-ce/network/pcap"
+)
+
+// pcapng block types and the options the export writes.
+const (
+	pcapngSectionHeader    = 0x0A0D0D0A
+	pcapngInterface        = 0x00000001
+	pcapngEnhancedPacket   = 0x00000006
+	pcapngByteOrderMagic   = 0x1A2B3C4D
+	pcapngOptEnd           = 0
+	pcapngOptUserAppl      = 4 // shb_userappl
+	pcapngOptInterfaceName = 2 // if_name
+	pcapngOptTsResol       = 9 // if_tsresol
+	pcapngLinkEthernet     = 1
+)
+
+// tcpMSS is the largest segment a payload is sent in.
+const tcpMSS = 1460
+
+// pcapngWriter writes a little-endian pcapng file with one Ethernet
+// interface timestamped in nanoseconds.
+type pcapngWriter struct {
+	w *bufio.Writer
+}
+
+func newPcapngWriter(w io.Writer, iface string) (*pcapngWriter, error) {
+	p := &pcapngWriter{w: bufio.NewWriter(w)}
+	le := binary.LittleEndian
+
+	shb := le.AppendUint32(nil, pcapngByteOrderMagic)
+	shb = le.AppendUint16(shb, 1) // Major version
+	shb = le.AppendUint16(shb, 0) // Minor version
+	shb = le.AppendUint64(shb, ^uint64(0))
+	shb = appendOption(shb, pcapngOptUserAppl, []byte("Erupe replay"))
+	shb = appendOption(shb, pcapngOptEnd, nil)
+	if err := p.block(pcapngSectionHeader, shb); err != nil {
+		return nil, err
+	}
+
+	idb := le.AppendUint16(nil, pcapngLinkEthernet)
+	idb = le.AppendUint16(idb, 0) // Reserved
+	idb = le.AppendUint32(idb, 0) // No snapshot length limit
+	idb = appendOption(idb, pcapngOptInterfaceName, []byte(iface))
+	idb = appendOption(idb, pcapngOptTsResol, []byte{9})
+	idb = appendOption(idb, pcapngOptEnd, nil)
+	if err := p.block(pcapngInterface, idb); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// appendOption appends a pcapng option, padded to 32 bits.
+func appendOption(b []byte, code uint16, value []byte) []byte {
+	b = binary.LittleEndian.AppendUint16(b, code)
+	b = binary.LittleEndian.AppendUint16(b, uint16(len(value)))
+	b = append(b, value...)
+	return append(b, make([]byte, pad4(len(value)))...)
+}
+
+func pad4(n int) int {
+	return (4 - n%4) % 4
+}
+
+// block writes a block of type t around body, which is padded to 32 bits.
+func (p *pcapngWriter) block(t uint32, body []byte) error {
+	body = append(body, make([]byte, pad4(len(body)))...)
+	n := uint32(12 + len(body))
+	b := binary.LittleEndian.AppendUint32(nil, t)
+	b = binary.LittleEndian.AppendUint32(b, n)
+	b = append(b, body...)
+	b = binary.LittleEndian.AppendUint32(b, n)
+	_, err := p.w.Write(b)
+	return err
+}
+
+// writeFrame writes an Ethernet frame captured at tsNs.
+func (p *pcapngWriter) writeFrame(tsNs int64, frame []byte) error {
+	le := binary.LittleEndian
+	b := le.AppendUint32(nil, 0) // Interface
+	b = le.AppendUint32(b, uint32(uint64(tsNs)>>32))
+	b = le.AppendUint32(b, uint32(tsNs))
+	b = le.AppendUint32(b, uint32(len(frame)))
+	b = le.AppendUint32(b, uint32(len(frame)))
+	b = append(b, frame...)
+	return p.block(pcapngEnhancedPacket, b)
+}
+
+func (p *pcapngWriter) flush() error {
+	return p.w.Flush()
+}
+
+// TCP flags used by the synthetic connection.
+const (
+	tcpFIN = 0x01
+	tcpSYN = 0x02
+	tcpPSH = 0x08
+	tcpACK = 0x10
+)
+
+// tcpConn frames the packets of a capture as a TCP connection between the
+// client and the server, over IPv4 and Ethernet. Each packet is sent in
+// its own segments, so packet boundaries stay visible.
+type tcpConn struct {
+	addrs [2]*net.TCPAddr // Client, then server
+	seq   [2]uint32       // Next sequence number each side sends
+	ipID  uint16
+}
+
+// captureEndpoints returns the client and server addresses of a capture,
+// from its metadata where they are IPv4 addresses and made up otherwise.
+func captureEndpoints(hdr pcap.FileHeader, meta pcap.SessionMetadata) (client, server *net.TCPAddr) {
+	client = &net.TCPAddr{IP: net.IPv4(10, 0, 0, 1).To4(), Port: 50000}
+	server = &net.TCPAddr{IP: net.IPv4(10, 0, 0, 2).To4(), Port: defaultPort(hdr.ServerType)}
+	if host, port, err := net.SplitHostPort(meta.RemoteAddr); err == nil {
+		if ip := net.ParseIP(host).To4(); ip != nil {
+			client.IP = ip
+		}
+		if p, err := strconv.Atoi(port); err == nil {
+			client.Port = p
+		}
+	}
+	if ip := net.ParseIP(meta.Host).To4(); ip != nil {
+		server.IP = ip
+	}
+	if meta.Port != 0 {
+		server.Port = meta.Port
+	}
+	return client, server
+}
+
+// defaultPort is the port each server listens on by default.
+func defaultPort(st pcap.ServerType) int {
+	switch st {
+	case pcap.ServerTypeSign:
+		return 53312
+	case pcap.ServerTypeEntrance:
+		return 53310
+	}
+	return 54001
+}
+
+// side is 0 for the client and 1 for the server.
+func side(dir pcap.Direction) int {
+	if dir == pcap.DirServerToClient {
+		return 1
+	}
+	return 0
+}
+
+// segment builds the frame of a segment from side from, advancing its
+// sequence number.
+func (c *tcpConn) segment(from int, flags byte, payload []byte) []byte {
+	src, dst := c.addrs[from], c.addrs[1-from]
+	seq, ack := c.seq[from], c.seq[1-from]
+	c.seq[from] += uint32(len(payload))
+	if flags&(tcpSYN|tcpFIN) != 0 {
+		c.seq[from]++
+	}
+	if flags&tcpACK == 0 {
+		ack = 0
+	}
+	c.ipID++
+
+	be := binary.BigEndian
+	tcp := be.AppendUint16(nil, uint16(src.Port))
+	tcp = be.AppendUint16(tcp, uint16(dst.Port))
+	tcp = be.AppendUint32(tcp, seq)
+	tcp = be.AppendUint32(tcp, ack)
+	tcp = append(tcp, 5<<4, flags)
+	tcp = be.AppendUint16(tcp, 65535) // Window
+	tcp = be.AppendUint16(tcp, 0)     // Checksum
+	tcp = be.AppendUint16(tcp, 0)     // Urgent pointer
+	tcp = append(tcp, payload...)
+	pseudo := append(append([]byte{}, src.IP.To4()...), dst.IP.To4()...)
+	pseudo = append(pseudo, 0, 6)
+	pseudo = be.AppendUint16(pseudo, uint16(len(tcp)))
+	be.PutUint16(tcp[16:], checksum(append(pseudo, tcp...)))
+
+	ip := []byte{0x45, 0}
+	ip = be.AppendUint16(ip, uint16(20+len(tcp)))
+	ip = be.AppendUint16(ip, c.ipID)
+	ip = be.AppendUint16(ip, 0x4000) // Don't fragment
+	ip = append(ip, 64, 6)           // TTL, TCP
+	ip = be.AppendUint16(ip, 0)      // Checksum
+	ip = append(ip, src.IP.To4()...)
+	ip = append(ip, dst.IP.To4()...)
+	be.PutUint16(ip[10:], checksum(ip))
+
+	frame := []byte{0x02, 0, 0, 0, 0, byte(2 - from)} // Destination MAC
+	frame = append(frame, 0x02, 0, 0, 0, 0, byte(1+from))
+	frame = be.AppendUint16(frame, 0x0800)
+	frame = append(frame, ip...)
+	return append(frame, tcp...)
+}
+
+// checksum is the Internet checksum of b.
+func checksum(b []byte) uint16 {
+	var sum uint32
+	for i := 0; i+1 < len(b); i += 2 {
+		sum += uint32(b[i])<<8 | uint32(b[i+1])
+	}
+	if len(b)%2 == 1 {
+		sum += uint32(b[len(b)-1]) << 8
+	}
+	for sum > 0xFFFF {
+		sum = sum>>16 + sum&0xFFFF
+	}
+	return ^uint16(sum)
+}
+
+// runPcapng converts the packets of the capture at path the filter keeps
+// to a pcapng file Wireshark can open. They are framed as one TCP
+// connection, opened when the session started and closed after the last
+// packet. Payloads are written as recorded, decrypted and without the
+// 14-byte packet header of the game's protocol.
+func runPcapng(path string, filter packetFilter, out string) error {
+	if out == "" {
+		return errors.New("--out is required")
+	}
+	r, f, err := openCapture(path)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = f.Close() }()
+
+	o, err := os.Create(out)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = o.Close() }()
+	p, err := newPcapngWriter(o, r.Header.ServerType.String())
+	if err != nil {
+		return err
+	}
+
+	client, server := captureEndpoints(r.Header, r.Meta)
+	c := &tcpConn{addrs: [2]*net.TCPAddr{client, server}, seq: [2]uint32{1000, 5000}}
+	start := r.Header.SessionStartNs
+	last := start
+	write := func(ts int64, from int, flags byte, payload []byte) error {
+		return p.writeFrame(ts, c.segment(from, flags, payload))
+	}
+	if err := errors.Join(
+		write(start, 0, tcpSYN, nil),
+		write(start, 1, tcpSYN|tcpACK, nil),
+		write(start, 0, tcpACK, nil),
+	); err != nil {
+		return err
+	}
+	count := 0
+	err = eachPacket(r, filter, func(_ int, rec pcap.PacketRecord) error {
+		from := side(rec.Direction)
+		for off := 0; off < len(rec.Payload) || off == 0; off += tcpMSS {
+			seg := rec.Payload[off:min(off+tcpMSS, len(rec.Payload))]
+			if err := write(rec.TimestampNs, from, tcpPSH|tcpACK, seg); err != nil {
+				return err
+			}
+		}
+		last = max(last, rec.TimestampNs)
+		count++
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if err := errors.Join(
+		write(last, 0, tcpFIN|tcpACK, nil),
+		write(last, 1, tcpFIN|tcpACK, nil),
+		write(last, 0, tcpACK, nil),
+		p.flush(),
+	); err != nil {
+		return err
+	}
+	if err := o.Close(); err != nil {
+		return err
+	}
+	fmt.Printf("Wrote %s (%d packets, %s → %s)\n", out, count, client, server)
+	return nil
+}
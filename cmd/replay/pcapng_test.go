@@ -0,0 +1,112 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"erupe-ce/network/pcap"
+)
+
+// pcapngFrame is an Enhanced Packet Block read back from a pcapng file.
+type pcapngFrame struct {
+	tsNs  int64
+	frame []byte
+}
+
+func readPcapng(t *testing.T, b []byte) (types []uint32, frames []pcapngFrame) {
+	t.Helper()
+	le := binary.LittleEndian
+	for len(b) > 0 {
+		if len(b) < 12 {
+			t.Fatalf("%d trailing bytes", len(b))
+		}
+		typ, n := le.Uint32(b), int(le.Uint32(b[4:]))
+		if n%4 != 0 || n > len(b) || int(le.Uint32(b[n-4:])) != n {
+			t.Fatalf("block 0x%X has bad length %d", typ, n)
+		}
+		types = append(types, typ)
+		if typ == pcapngEnhancedPacket {
+			body := b[8 : n-4]
+			ts := int64(le.Uint32(body[4:]))<<32 | int64(le.Uint32(body[8:]))
+			caplen := le.Uint32(body[12:])
+			frames = append(frames, pcapngFrame{ts, body[20 : 20+caplen]})
+		}
+		b = b[n:]
+	}
+	return types, frames
+}
+
+func TestRunPcapng(t *testing.T) {
+	big := bytes.Repeat([]byte{0xAB}, 2000)
+	path := createTestCapture(t, []pcap.PacketRecord{
+		{TimestampNs: 1000000100, Direction: pcap.DirClientToServer, Opcode: 0x0017, Payload: []byte{0x00, 0x17, 0x00, 0x00, 0x00, 0x01}},
+		{TimestampNs: 1000000200, Direction: pcap.DirServerToClient, Opcode: 0x0012, Payload: big},
+	})
+	out := filepath.Join(t.TempDir(), "out.pcapng")
+	if err := runPcapng(path, packetFilter{}, out); err != nil {
+		t.Fatalf("runPcapng: %v", err)
+	}
+	b, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	types, frames := readPcapng(t, b)
+	if types[0] != pcapngSectionHeader || types[1] != pcapngInterface {
+		t.Fatalf("blocks start %X", types[:2])
+	}
+	// Handshake, one segment for the ping, two for the large payload, close.
+	if len(frames) != 3+1+2+3 {
+		t.Fatalf("%d frames, want 9", len(frames))
+	}
+
+	be := binary.BigEndian
+	var data [2][]byte
+	next := map[uint16]uint32{}
+	for i, f := range frames {
+		ip := f.frame[14:]
+		if be.Uint16(f.frame[12:]) != 0x0800 || checksum(ip[:20]) != 0 {
+			t.Fatalf("frame %d has a bad IPv4 header", i)
+		}
+		tcp := ip[20:be.Uint16(ip[2:])]
+		pseudo := append(append([]byte{}, ip[12:20]...), 0, 6)
+		pseudo = be.AppendUint16(pseudo, uint16(len(tcp)))
+		if checksum(append(pseudo, tcp...)) != 0 {
+			t.Errorf("frame %d has a bad TCP checksum", i)
+		}
+		port, seq, flags := be.Uint16(tcp), be.Uint32(tcp[4:]), tcp[13]
+		if want, ok := next[port]; ok && seq != want {
+			t.Errorf("frame %d from port %d has sequence %d, want %d", i, port, seq, want)
+		}
+		payload := tcp[20:]
+		next[port] = seq + uint32(len(payload))
+		if flags&(tcpSYN|tcpFIN) != 0 {
+			next[port]++
+		}
+		if port == 54001 {
+			data[1] = append(data[1], payload...)
+		} else {
+			data[0] = append(data[0], payload...)
+		}
+	}
+	if !bytes.Equal(data[0], []byte{0x00, 0x17, 0x00, 0x00, 0x00, 0x01}) || !bytes.Equal(data[1], big) {
+		t.Errorf("stream contents % x and %d bytes", data[0], len(data[1]))
+	}
+	if frames[3].tsNs != 1000000100 || frames[0].tsNs != 1000000000 {
+		t.Errorf("timestamps %d and %d", frames[0].tsNs, frames[3].tsNs)
+	}
+}
+
+func TestCaptureEndpoints(t *testing.T) {
+	hdr := pcap.FileHeader{ServerType: pcap.ServerTypeSign}
+	client, server := captureEndpoints(hdr, pcap.SessionMetadata{RemoteAddr: "192.168.1.5:61000", Host: "127.0.0.1", Port: 53312})
+	if client.String() != "192.168.1.5:61000" || server.String() != "127.0.0.1:53312" {
+		t.Errorf("endpoints %s and %s", client, server)
+	}
+	client, server = captureEndpoints(hdr, pcap.SessionMetadata{RemoteAddr: "[::1]:61000", Host: "example.com"})
+	if client.String() != "10.0.0.1:61000" || server.String() != "10.0.0.2:53312" {
+		t.Errorf("made up endpoints %s and %s", client, server)
+	}
+}
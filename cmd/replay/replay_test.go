@@ -3,10 +3,12 @@ package main
 import (
 	"bytes"
 	"encoding/binary"
+	"encoding/json"
 	"net"
 	"os"
 	"strings"
 	"testing"
+	"time"
 
 	"erupe-ce/network/pcap"
 )
@@ -48,7 +50,7 @@ func TestRunDump(t *testing.T) {
 		{TimestampNs: 1000000200, Direction: pcap.DirServerToClient, Opcode: 0x0012, Payload: []byte{0x00, 0x12, 0xFF}},
 	})
 	// Just verify it doesn't error.
-	if err := runDump(path); err != nil {
+	if err := runDump(path, packetFilter{}, false); err != nil {
 		t.Fatalf("runDump: %v", err)
 	}
 }
@@ -59,14 +61,14 @@ func TestRunStats(t *testing.T) {
 		{TimestampNs: 1000000200, Direction: pcap.DirServerToClient, Opcode: 0x0012, Payload: []byte{0x00, 0x12, 0xFF}},
 		{TimestampNs: 1000000300, Direction: pcap.DirClientToServer, Opcode: 0x0013, Payload: []byte{0x00, 0x13, 0xAA}},
 	})
-	if err := runStats(path); err != nil {
+	if err := runStats([]string{path}, packetFilter{}, 30*time.Second); err != nil {
 		t.Fatalf("runStats: %v", err)
 	}
 }
 
 func TestRunStatsEmpty(t *testing.T) {
 	path := createTestCapture(t, nil)
-	if err := runStats(path); err != nil {
+	if err := runStats([]string{path}, packetFilter{}, 30*time.Second); err != nil {
 		t.Fatalf("runStats empty: %v", err)
 	}
 }
@@ -80,7 +82,7 @@ func TestRunJSON(t *testing.T) {
 	r, w, _ := os.Pipe()
 	os.Stdout = w
 
-	if err := runJSON(path); err != nil {
+	if err := runJSON(path, packetFilter{}, false); err != nil {
 		os.Stdout = old
 		t.Fatalf("runJSON: %v", err)
 	}
@@ -99,6 +101,41 @@ func TestRunJSON(t *testing.T) {
 	}
 }
 
+func TestRunNDJSON(t *testing.T) {
+	path := createTestCapture(t, []pcap.PacketRecord{
+		{TimestampNs: 1000000100, Direction: pcap.DirClientToServer, Opcode: 0x0013, Payload: []byte{0x00, 0x13}},
+		{TimestampNs: 1000000200, Direction: pcap.DirServerToClient, Opcode: 0x0012, Payload: []byte{0x00, 0x12, 0xFF}},
+	})
+	old := os.Stdout
+	r, w, _ := os.Pipe()
+	os.Stdout = w
+
+	if err := runNDJSON(path, packetFilter{}, true); err != nil {
+		os.Stdout = old
+		t.Fatalf("runNDJSON: %v", err)
+	}
+
+	_ = w.Close()
+	os.Stdout = old
+
+	var buf bytes.Buffer
+	_, _ = buf.ReadFrom(r)
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("got %d lines, want a header and 2 packets:\n%s", len(lines), buf.String())
+	}
+	if !strings.Contains(lines[0], `"server_type":"channel"`) {
+		t.Errorf("header line %s", lines[0])
+	}
+	var pkt jsonPacket
+	if err := json.Unmarshal([]byte(lines[2]), &pkt); err != nil {
+		t.Fatalf("packet line %s: %v", lines[2], err)
+	}
+	if pkt.Index != 1 || pkt.Opcode != 0x0012 || !bytes.Equal(pkt.Payload, []byte{0x00, 0x12, 0xFF}) {
+		t.Errorf("packet %+v", pkt)
+	}
+}
+
 func TestComparePackets(t *testing.T) {
 	expected := []pcap.PacketRecord{
 		{Direction: pcap.DirClientToServer, Opcode: 0x0013, Payload: []byte{0x00, 0x13}},
@@ -110,7 +147,7 @@ func TestComparePackets(t *testing.T) {
 		{Direction: pcap.DirServerToClient, Opcode: 0x0099, Payload: []byte{0x00, 0x99}},             // opcode mismatch
 	}
 
-	diffs := ComparePackets(expected, actual)
+	diffs := ComparePackets(expected, actual, nil)
 	if len(diffs) != 2 {
 		t.Fatalf("expected 2 diffs, got %d", len(diffs))
 	}
@@ -135,7 +172,7 @@ func TestComparePacketsMissingResponse(t *testing.T) {
 		{Direction: pcap.DirServerToClient, Opcode: 0x0012, Payload: []byte{0x00, 0x12}},
 	}
 
-	diffs := ComparePackets(expected, actual)
+	diffs := ComparePackets(expected, actual, nil)
 	if len(diffs) != 1 {
 		t.Fatalf("expected 1 diff, got %d", len(diffs))
 	}
@@ -152,7 +189,7 @@ func TestComparePacketsPayloadDiff(t *testing.T) {
 		{Direction: pcap.DirServerToClient, Opcode: 0x0012, Payload: []byte{0x00, 0x12, 0xCC, 0xBB}},
 	}
 
-	diffs := ComparePackets(expected, actual)
+	diffs := ComparePackets(expected, actual, nil)
 	if len(diffs) != 1 {
 		t.Fatalf("expected 1 diff, got %d", len(diffs))
 	}
@@ -170,12 +207,58 @@ func TestComparePacketsIdentical(t *testing.T) {
 	records := []pcap.PacketRecord{
 		{Direction: pcap.DirServerToClient, Opcode: 0x0012, Payload: []byte{0x00, 0x12, 0xAA}},
 	}
-	diffs := ComparePackets(records, records)
+	diffs := ComparePackets(records, records, nil)
 	if len(diffs) != 0 {
 		t.Errorf("expected 0 diffs for identical packets, got %d", len(diffs))
 	}
 }
 
+func TestComparePacketsResynchronises(t *testing.T) {
+	// The second request goes unanswered; the responses after it still
+	// match.
+	expected := []pcap.PacketRecord{
+		c2s(0x0017), s2c(0x0012, 1),
+		c2s(0x0061), s2c(0x0012, 2), s2c(0x0013, 2),
+		c2s(0x0017), s2c(0x0012, 3),
+		c2s(0x0017), s2c(0x0012, 4),
+	}
+	actual := []pcap.PacketRecord{
+		c2s(0x0017), s2c(0x0012, 1),
+		c2s(0x0061),
+		c2s(0x0017), s2c(0x0012, 3),
+		c2s(0x0017), s2c(0x0012, 4), s2c(0x0099),
+	}
+	diffs := ComparePackets(expected, actual, nil)
+	if len(diffs) != 3 {
+		t.Fatalf("expected 3 diffs, got %d: %v", len(diffs), diffs)
+	}
+	if diffs[0].Index != 1 || diffs[0].Actual != nil || diffs[1].Index != 2 || diffs[1].Actual != nil {
+		t.Errorf("diffs %v, want responses 1 and 2 missing", diffs[:2])
+	}
+	if diffs[2].Expected.Opcode != 0 || diffs[2].Actual.Opcode != 0x0099 || diffs[2].Index != 3 {
+		t.Errorf("diffs[2] = %v, want response 3 of actual extra", diffs[2])
+	}
+}
+
+func TestComparePacketsAckHandle(t *testing.T) {
+	request := func(op uint16, handle byte) pcap.PacketRecord {
+		return pcap.PacketRecord{Direction: pcap.DirClientToServer, Opcode: op, Payload: []byte{byte(op >> 8), byte(op), 0, 0, 0, handle}}
+	}
+	ack := func(handle byte, data byte) pcap.PacketRecord { return s2c(0x0012, 0, 0, 0, handle, data) }
+	expected := []pcap.PacketRecord{
+		request(0x0061, 1), ack(1, 0xAA),
+		request(0x0062, 2), ack(2, 0xBB),
+	}
+	// The first acknowledgement arrives after the second request was sent.
+	actual := []pcap.PacketRecord{
+		request(0x0061, 1),
+		request(0x0062, 2), ack(2, 0xBB), ack(1, 0xAA),
+	}
+	if diffs := ComparePackets(expected, actual, nil); len(diffs) != 0 {
+		t.Errorf("expected no diffs, got %v", diffs)
+	}
+}
+
 func TestPacketDiffString(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -191,6 +274,14 @@ func TestPacketDiffString(t *testing.T) {
 			},
 			contains: "no response",
 		},
+		{
+			name: "extra response",
+			diff: PacketDiff{
+				Index:  4,
+				Actual: &pcap.PacketRecord{Opcode: 0x0012},
+			},
+			contains: "unexpected extra response 0x0012",
+		},
 		{
 			name: "opcode mismatch",
 			diff: PacketDiff{
@@ -267,7 +358,7 @@ func TestRunReplayWithMockServer(t *testing.T) {
 	})
 
 	// Run replay — the connection will fail (no Blowfish on mock), but it should not panic.
-	err = runReplay(path, ln.Addr().String(), 0)
+	err = runReplay(path, packetFilter{}, ln.Addr().String(), 0, nil)
 	// We expect an error or graceful handling since the mock doesn't speak Blowfish.
 	// The important thing is no panic.
 	_ = err
@@ -277,7 +368,7 @@ func TestComparePayloads(t *testing.T) {
 	a := []byte{0x00, 0x12, 0xAA, 0xBB, 0xCC}
 	b := []byte{0x00, 0x12, 0xAA, 0xDD, 0xCC}
 
-	diffs := comparePayloads(a, b)
+	diffs := comparePayloads(a, b, compareRule{})
 	if len(diffs) != 1 {
 		t.Fatalf("expected 1 diff, got %d", len(diffs))
 	}
@@ -294,7 +385,7 @@ func TestComparePayloadsMaxDiffs(t *testing.T) {
 		b[i] = 0xFF
 	}
 
-	diffs := comparePayloads(a, b)
+	diffs := comparePayloads(a, b, compareRule{})
 	if len(diffs) != maxPayloadDiffs {
 		t.Errorf("expected %d diffs (capped), got %d", maxPayloadDiffs, len(diffs))
 	}
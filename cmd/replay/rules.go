@@ -0,0 +1,121 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"erupe-ce/network"
+	"erupe-ce/network/pcap"
+
+	"gopkg.in/yaml.v3"
+)
+
+// compareRule relaxes the comparison of the responses to one opcode, for
+// fields a server fills differently each time, such as timestamps and
+// random IDs.
+type compareRule struct {
+	Skip      bool     `yaml:"skip"`       // Do not compare the responses at all
+	SizeDelta int      `yaml:"size_delta"` // Bytes the size may differ by; the bytes of responses of different sizes are not compared
+	Ignore    [][2]int `yaml:"ignore"`     // Byte ranges [start, end) of the payload allowed to differ
+}
+
+// ignores reports whether the byte at offset may differ.
+func (r compareRule) ignores(offset int) bool {
+	for _, span := range r.Ignore {
+		if offset >= span[0] && offset < span[1] {
+			return true
+		}
+	}
+	return false
+}
+
+// compareRules are keyed by the opcode of the request an acknowledgement
+// answers, or by the response's own opcode for anything else. A rules file
+// maps opcodes, as numbers or names, to rules, in YAML or JSON:
+//
+//	MSG_SYS_LOGIN:
+//	  ignore: [[6, 10]]  # The login time
+//	MSG_MHF_ENUMERATE_QUEST:
+//	  skip: true
+//	0x0061:
+//	  size_delta: 8
+//
+// Offsets are into the whole payload, opcode included, as the byte diffs
+// show them.
+type compareRules map[uint16]compareRule
+
+// rule returns the rule for rec, a response to request, which is nil
+// when the request is not known.
+func (rs compareRules) rule(request *pcap.PacketRecord, rec pcap.PacketRecord) compareRule {
+	if request != nil && rec.Opcode == uint16(network.MSG_SYS_ACK) {
+		if r, ok := rs[request.Opcode]; ok {
+			return r
+		}
+	}
+	return rs[rec.Opcode]
+}
+
+// keep returns the responses of e rs does not skip.
+func (rs compareRules) keep(request *pcap.PacketRecord, e exchange) exchange {
+	kept := exchange{index: e.index, request: e.request}
+	for i, rec := range e.responses {
+		if !rs.rule(request, rec).Skip {
+			kept.responses = append(kept.responses, rec)
+			kept.responseIndexes = append(kept.responseIndexes, e.responseIndexes[i])
+		}
+	}
+	return kept
+}
+
+// loadRules reads the rules file at path, or returns no rules when path is
+// empty.
+func loadRules(path string) (compareRules, error) {
+	if path == "" {
+		return nil, nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open rules: %w", err)
+	}
+	defer func() { _ = f.Close() }()
+	rules, err := parseRules(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return rules, nil
+}
+
+// parseRules reads rules, refusing fields it does not know so that a typo
+// does not silently drop a rule. JSON is read as the YAML it also is.
+func parseRules(r io.Reader) (compareRules, error) {
+	dec := yaml.NewDecoder(r)
+	dec.KnownFields(true)
+	var byName map[string]compareRule
+	if err := dec.Decode(&byName); err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	rules := make(compareRules, len(byName))
+	var errs []error
+	for name, rule := range byName {
+		op, err := parseOpcode(name)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if rule.SizeDelta < 0 {
+			errs = append(errs, fmt.Errorf("%s: size_delta %d is negative", name, rule.SizeDelta))
+		}
+		for _, span := range rule.Ignore {
+			if span[0] < 0 || span[1] <= span[0] {
+				errs = append(errs, fmt.Errorf("%s: ignore [%d, %d] is not a range", name, span[0], span[1]))
+			}
+		}
+		rules[op] = rule
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
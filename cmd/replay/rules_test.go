@@ -0,0 +1,78 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"erupe-ce/network"
+	"erupe-ce/network/pcap"
+)
+
+func TestParseRules(t *testing.T) {
+	yamlRules := `
+MSG_SYS_LOGIN:
+  ignore: [[6, 10]]
+0x0061:
+  size_delta: 8
+msg_mhf_enumerate_quest:
+  skip: true
+`
+	jsonRules := `{"MSG_SYS_LOGIN": {"ignore": [[6, 10]]}, "0x0061": {"size_delta": 8}, "MSG_MHF_ENUMERATE_QUEST": {"skip": true}}`
+	for _, text := range []string{yamlRules, jsonRules} {
+		rules, err := parseRules(strings.NewReader(text))
+		if err != nil {
+			t.Fatal(err)
+		}
+		login := rules[uint16(network.MSG_SYS_LOGIN)]
+		if len(rules) != 3 || !login.ignores(6) || login.ignores(10) || rules[0x0061].SizeDelta != 8 || !rules[uint16(network.MSG_MHF_ENUMERATE_QUEST)].Skip {
+			t.Errorf("rules %+v", rules)
+		}
+	}
+
+	if rules, err := parseRules(strings.NewReader("")); err != nil || len(rules) != 0 {
+		t.Errorf("empty file: %v, %v", rules, err)
+	}
+	for text, want := range map[string]string{
+		"MSG_SYS_PING:\n  skipp: true\n":      "skipp",
+		"MSG_SYS_NOPE:\n  skip: true\n":       "unknown opcode",
+		"MSG_SYS_PING:\n  ignore: [[4, 2]]\n": "not a range",
+		"MSG_SYS_PING:\n  size_delta: -1\n":   "negative",
+	} {
+		if _, err := parseRules(strings.NewReader(text)); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%q: error %v, want %q", text, err, want)
+		}
+	}
+}
+
+func TestComparePacketsRules(t *testing.T) {
+	request := func(op uint16, handle byte) pcap.PacketRecord {
+		return pcap.PacketRecord{Direction: pcap.DirClientToServer, Opcode: op, Payload: []byte{byte(op >> 8), byte(op), 0, 0, 0, handle}}
+	}
+	expected := []pcap.PacketRecord{
+		request(0x0061, 1), s2c(0x0012, 0, 0, 0, 1, 0xAA, 0xBB),
+		request(0x0062, 2), s2c(0x0012, 0, 0, 0, 2, 1, 2, 3),
+		request(0x0063, 3), s2c(0x0012, 0, 0, 0, 3, 7), s2c(0x0099, 1),
+	}
+	actual := []pcap.PacketRecord{
+		request(0x0061, 1), s2c(0x0012, 0, 0, 0, 1, 0xCC, 0xBB),
+		request(0x0062, 2), s2c(0x0012, 0, 0, 0, 2, 1, 2, 3, 4, 5),
+		request(0x0063, 3), s2c(0x0012, 0, 0, 0, 3, 7),
+	}
+	if diffs := ComparePackets(expected, actual, nil); len(diffs) != 3 {
+		t.Fatalf("without rules: %d diffs, want 3: %v", len(diffs), diffs)
+	}
+
+	// Acknowledgements are ruled by the opcode of their request.
+	rules := compareRules{
+		0x0061: {Ignore: [][2]int{{6, 7}}},
+		0x0062: {SizeDelta: 2},
+		0x0099: {Skip: true},
+	}
+	if diffs := ComparePackets(expected, actual, rules); len(diffs) != 0 {
+		t.Errorf("with rules: %v", diffs)
+	}
+	rules[0x0062] = compareRule{SizeDelta: 1}
+	if diffs := ComparePackets(expected, actual, rules); len(diffs) != 1 || diffs[0].SizeDelta != 2 {
+		t.Errorf("size delta over the rule: %v", diffs)
+	}
+}
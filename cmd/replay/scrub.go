@@ -0,0 +1,204 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+
+	cfg "erupe-ce/config"
+	"erupe-ce/network"
+	"erupe-ce/network/pcap"
+)
+
+// scrubFill overwrites credentials and tokens in scrubbed packets. The
+// strings keep their length, so the packets still parse.
+const scrubFill = '*'
+
+// scrubber removes account data from the packets of one capture. IDs are
+// replaced by random ones, the same for every packet, so the packets
+// referring to a character still line up; credentials and tokens are
+// overwritten.
+type scrubber struct {
+	hdr pcap.FileHeader
+	ids map[uint32]uint32
+	rng *rand.Rand
+}
+
+func newScrubber(hdr pcap.FileHeader, rng *rand.Rand) *scrubber {
+	return &scrubber{hdr: hdr, ids: map[uint32]uint32{0: 0}, rng: rng}
+}
+
+// id returns the replacement for a character or user ID.
+func (s *scrubber) id(v uint32) uint32 {
+	if r, ok := s.ids[v]; ok {
+		return r
+	}
+	r := s.rng.Uint32()
+	for r == 0 {
+		r = s.rng.Uint32()
+	}
+	s.ids[v] = r
+	return r
+}
+
+// meta scrubs the session metadata. The client's address goes too.
+func (s *scrubber) meta(m pcap.SessionMetadata) pcap.SessionMetadata {
+	m.CharID = s.id(m.CharID)
+	m.UserID = s.id(m.UserID)
+	m.RemoteAddr = ""
+	return m
+}
+
+// packet scrubs the packets known to carry credentials, tokens or IDs:
+// sign-in requests and responses on the sign server, and MSG_SYS_LOGIN on
+// channel servers. Others are left as they are. The payload is copied.
+func (s *scrubber) packet(rec pcap.PacketRecord) pcap.PacketRecord {
+	rec.Payload = bytes.Clone(rec.Payload)
+	switch s.hdr.ServerType {
+	case pcap.ServerTypeSign:
+		if rec.Direction == pcap.DirClientToServer {
+			s.signRequest(rec.Payload)
+		} else {
+			s.signResponse(rec.Payload)
+		}
+	case pcap.ServerTypeChannel:
+		if rec.Direction == pcap.DirClientToServer && rec.Opcode == uint16(network.MSG_SYS_LOGIN) {
+			s.login(rec.Payload)
+		}
+	}
+	return rec
+}
+
+// fillString overwrites the null-terminated string at p[off:], returning
+// the offset after it.
+func fillString(p []byte, off int) int {
+	for ; off < len(p) && p[off] != 0; off++ {
+		p[off] = scrubFill
+	}
+	return off + 1
+}
+
+// skipString returns the offset after the null-terminated string at p[off:].
+func skipString(p []byte, off int) int {
+	if i := bytes.IndexByte(p[min(off, len(p)):], 0); i >= 0 {
+		return off + i + 1
+	}
+	return len(p)
+}
+
+// putID replaces the ID at p[off:off+4].
+func (s *scrubber) putID(p []byte, off int) {
+	if off+4 <= len(p) {
+		binary.BigEndian.PutUint32(p[off:], s.id(binary.BigEndian.Uint32(p[off:])))
+	}
+}
+
+// signRequest scrubs the credentials of a sign server request, which
+// starts with its null-terminated type, such as "DSGN:100".
+func (s *scrubber) signRequest(p []byte) {
+	end := bytes.IndexByte(p, 0)
+	if end < 3 {
+		return
+	}
+	off := end + 1
+	switch string(p[:end-3]) {
+	case "DLTSKEYSIGN:", "DSGN:", "SIGN:":
+		off = fillString(p, off) // Username
+		fillString(p, off)       // Password
+	case "PS4SGN:", "PS3SGN:", "VITASGN:":
+		if string(p[:end-3]) != "PS4SGN:" {
+			off = skipString(p, off) + 84
+		}
+		fillString(p, off) // PSN ID
+	case "WIIUSGN:":
+		for i := off + 1; i < min(off+65, len(p)); i++ {
+			p[i] = scrubFill // Wii U key
+		}
+	case "VITACOGLNK:", "COGLNK:":
+		off = skipString(p, off) // Client ID
+		off = fillString(p, off) // Username and password
+		fillString(p, off)       // PSN token
+	case "DELETE:":
+		off = fillString(p, off) // Session token
+		s.putID(p, off)
+		if off+8 <= len(p) {
+			clear(p[off+4 : off+8]) // Token ID
+		}
+	}
+}
+
+// signResponse scrubs a successful sign-in response: its session token
+// and the IDs of the account's characters.
+func (s *scrubber) signResponse(p []byte) {
+	const tokenEnd = 24
+	if len(p) < tokenEnd+4 || p[0] != 1 { // SIGN_SUCCESS
+		return
+	}
+	chars := int(p[3])
+	clear(p[4:8]) // Token ID
+	for i := 8; i < tokenEnd; i++ {
+		p[i] = scrubFill
+	}
+	// The character list follows the patch and entrance server addresses.
+	off := tokenEnd + 4
+	for range 3 {
+		if off >= len(p) {
+			return
+		}
+		off += 1 + int(p[off])
+	}
+	size := 64
+	if cfg.Mode(s.hdr.ClientMode) >= cfg.G7 {
+		size = 68
+	}
+	for range chars {
+		s.putID(p, off)
+		off += size
+	}
+}
+
+// login scrubs MSG_SYS_LOGIN:
+//
+//	[2B] Opcode [4B] AckHandle [4B] CharID [4B] Token ID [2B] [2B] Version
+//	[4B] CharID [2B] [2B] Token string
+func (s *scrubber) login(p []byte) {
+	s.putID(p, 6)
+	if len(p) >= 14 {
+		clear(p[10:14])
+	}
+	s.putID(p, 18)
+	if len(p) > 26 {
+		fillString(p, 26)
+	}
+}
+
+// runScrub writes the packets of the capture at path the filter keeps to
+// out, with account data scrubbed, so the capture can be shared. A merged
+// capture is recorded as a channel capture, so its sign server packets are
+// not recognised; scrub the captures of a session before merging them.
+func runScrub(path string, filter packetFilter, out, compress string) error {
+	if out == "" {
+		return errors.New("--out is required")
+	}
+	r, f, err := openCapture(path)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = f.Close() }()
+
+	s := newScrubber(r.Header, rand.New(rand.NewPCG(rand.Uint64(), rand.Uint64())))
+	w, err := createCapture(out, r.Header, s.meta(r.Meta), compress)
+	if err != nil {
+		return err
+	}
+	err = eachPacket(r, filter, func(_ int, rec pcap.PacketRecord) error {
+		return w.write(s.packet(rec))
+	})
+	if err := errors.Join(err, w.close()); err != nil {
+		return err
+	}
+	fmt.Printf("Wrote %s (%d packets, %d IDs replaced)\n", out, w.count, len(s.ids)-1)
+	return nil
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math/rand/v2"
+	"path/filepath"
+	"testing"
+
+	"erupe-ce/common/byteframe"
+	cfg "erupe-ce/config"
+	"erupe-ce/network/clientctx"
+	"erupe-ce/network/mhfpacket"
+	"erupe-ce/network/pcap"
+)
+
+func testScrubber(st pcap.ServerType) *scrubber {
+	return newScrubber(pcap.FileHeader{ServerType: st, ClientMode: byte(cfg.ZZ)}, rand.New(rand.NewPCG(1, 2)))
+}
+
+func TestScrubLogin(t *testing.T) {
+	bf := byteframe.NewByteFrame()
+	bf.WriteUint16(0x0014) // MSG_SYS_LOGIN
+	bf.WriteUint32(7)      // AckHandle
+	bf.WriteUint32(1234)   // CharID0
+	bf.WriteUint32(99)     // LoginTokenNumber
+	bf.WriteUint16(0)
+	bf.WriteUint16(1)
+	bf.WriteUint32(1234) // CharID1
+	bf.WriteUint16(0)
+	bf.WriteUint16(11)
+	bf.WriteNullTerminatedBytes([]byte("secrettoken"))
+	rec := pcap.PacketRecord{Direction: pcap.DirClientToServer, Opcode: 0x0014, Payload: bf.Data()}
+
+	s := testScrubber(pcap.ServerTypeChannel)
+	out := s.packet(rec)
+	if bytes.Contains(rec.Payload, []byte("****")) {
+		t.Fatal("scrubbing changed the original payload")
+	}
+
+	var login mhfpacket.MsgSysLogin
+	if err := login.Parse(byteframe.NewByteFrameFromBytes(out.Payload[2:]), &clientctx.ClientContext{}); err != nil {
+		t.Fatal(err)
+	}
+	if login.AckHandle != 7 || login.CharID0 == 1234 || login.CharID0 != login.CharID1 || login.CharID0 != s.id(1234) {
+		t.Errorf("IDs not replaced consistently: %+v", login)
+	}
+	if login.LoginTokenNumber != 0 || login.LoginTokenString != "***********" {
+		t.Errorf("token not scrubbed: %+v", login)
+	}
+	if m := s.meta(pcap.SessionMetadata{CharID: 1234, UserID: 5, RemoteAddr: "10.0.0.1:5000"}); m.CharID != login.CharID0 || m.UserID == 5 || m.RemoteAddr != "" {
+		t.Errorf("metadata %+v", m)
+	}
+}
+
+func TestScrubSignRequest(t *testing.T) {
+	s := testScrubber(pcap.ServerTypeSign)
+	payload := []byte("DSGN:100\x00hunter\x00pa55\x00unk\x00")
+	out := s.packet(pcap.PacketRecord{Direction: pcap.DirClientToServer, Payload: payload})
+	if want := []byte("DSGN:100\x00******\x00****\x00unk\x00"); !bytes.Equal(out.Payload, want) {
+		t.Errorf("scrubbed to %q, want %q", out.Payload, want)
+	}
+
+	// Channel captures have no sign requests.
+	s = testScrubber(pcap.ServerTypeChannel)
+	if out := s.packet(pcap.PacketRecord{Direction: pcap.DirClientToServer, Payload: payload}); !bytes.Equal(out.Payload, payload) {
+		t.Errorf("channel packet scrubbed to %q", out.Payload)
+	}
+}
+
+func TestScrubSignResponse(t *testing.T) {
+	bf := byteframe.NewByteFrame()
+	bf.WriteUint8(1) // SIGN_SUCCESS
+	bf.WriteUint8(2)
+	bf.WriteUint8(1)
+	bf.WriteUint8(2) // Characters
+	bf.WriteUint32(77)
+	bf.WriteBytes([]byte("0123456789abcdef"))
+	bf.WriteUint32(1700000000)
+	for _, host := range []string{"patch", "patch", "127.0.0.1:53310"} {
+		bf.WriteUint8(uint8(len(host) + 1))
+		bf.WriteNullTerminatedBytes([]byte(host))
+	}
+	chars := int(bf.Index())
+	for _, id := range []uint32{1001, 1002} {
+		bf.WriteUint32(id)
+		bf.WriteBytes(make([]byte, 64))
+	}
+
+	s := testScrubber(pcap.ServerTypeSign)
+	out := s.packet(pcap.PacketRecord{Direction: pcap.DirServerToClient, Payload: bf.Data()}).Payload
+	if binary.BigEndian.Uint32(out[4:]) != 0 || !bytes.Equal(out[8:24], bytes.Repeat([]byte("*"), 16)) {
+		t.Errorf("token not scrubbed: % x", out[4:24])
+	}
+	for i, id := range []uint32{1001, 1002} {
+		if got := binary.BigEndian.Uint32(out[chars+68*i:]); got != s.id(id) || got == id {
+			t.Errorf("character %d has ID %d", i, got)
+		}
+	}
+}
+
+func TestRunScrub(t *testing.T) {
+	path := createTestCapture(t, []pcap.PacketRecord{
+		{TimestampNs: 1000000100, Direction: pcap.DirClientToServer, Opcode: 0x0017, Payload: []byte{0x00, 0x17, 0x00, 0x00, 0x00, 0x01}},
+	})
+	out := filepath.Join(t.TempDir(), "scrubbed.mhfr")
+	if err := runScrub(path, packetFilter{}, out, ""); err != nil {
+		t.Fatalf("runScrub: %v", err)
+	}
+	_, meta, ts := readCapture(t, out)
+	if len(ts) != 1 || meta.Host != "127.0.0.1" {
+		t.Errorf("scrubbed capture has %d packets, metadata %+v", len(ts), meta)
+	}
+	if err := runScrub(path, packetFilter{}, "", ""); err == nil {
+		t.Error("runScrub accepted a missing --out")
+	}
+}
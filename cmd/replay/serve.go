@@ -0,0 +1,183 @@
+package main
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"erupe-ce/network"
+	"erupe-ce/network/clientctx"
+	"erupe-ce/network/mhfpacket"
+	"erupe-ce/network/pcap"
+
+	"github.com/gorilla/mux"
+)
+
+//go:embed viewer.html
+var viewerHTML []byte
+
+// opcodeDocURL is where the packet of an opcode is defined, documented
+// by its fields, filled with the lower case name of the opcode.
+const opcodeDocURL = "https://github.com/Mezeporta/Erupe/blob/main/network/mhfpacket/%s.go"
+
+// Pages of the packet list.
+const (
+	defaultPageSize = 500
+	maxPageSize     = 5000
+)
+
+// viewer serves the packets of a capture to the web viewer.
+type viewer struct {
+	path        string
+	header      pcap.FileHeader
+	meta        pcap.SessionMetadata
+	records     []pcap.PacketRecord
+	indexes     []int // Capture indexes of records, ascending
+	serverTypes []pcap.ServerType
+	ctx         *clientctx.ClientContext
+}
+
+// viewerPacket is a packet as the viewer lists it.
+type viewerPacket struct {
+	jsonPacket
+	Doc string `json:"doc,omitempty"` // Where its opcode is defined
+}
+
+// runServe serves a web viewer of the packets of the capture at path the
+// filter keeps on listen, until the tool is stopped.
+func runServe(path string, filter packetFilter, listen string) error {
+	v, err := loadViewer(path, filter)
+	if err != nil {
+		return err
+	}
+	host := listen
+	if strings.HasPrefix(host, ":") {
+		host = "localhost" + host
+	}
+	fmt.Printf("Serving %s (%d packets) at http://%s\n", path, len(v.records), host)
+	return http.ListenAndServe(listen, v.router())
+}
+
+func loadViewer(path string, filter packetFilter) (*viewer, error) {
+	r, f, err := openCapture(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+	records, indexes, err := readPackets(r, filter)
+	if err != nil {
+		return nil, err
+	}
+	v := &viewer{path: path, header: r.Header, meta: r.Meta, records: records, indexes: indexes, ctx: captureContext(r.Header)}
+	for _, rec := range records {
+		v.serverTypes = append(v.serverTypes, packetServerType(r, rec))
+	}
+	return v, nil
+}
+
+func (v *viewer) router() http.Handler {
+	r := mux.NewRouter()
+	r.HandleFunc("/", v.handleIndex).Methods("GET")
+	r.HandleFunc("/api/capture", v.handleCapture).Methods("GET")
+	r.HandleFunc("/api/packets", v.handlePackets).Methods("GET")
+	r.HandleFunc("/api/packets/{index:[0-9]+}", v.handlePacket).Methods("GET")
+	return r
+}
+
+func (v *viewer) handleIndex(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_, _ = w.Write(viewerHTML)
+}
+
+func (v *viewer) handleCapture(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"path":        v.path,
+		"header":      newJSONHeader(v.header),
+		"metadata":    v.meta,
+		"compression": pcap.Compression(v.header.Flags),
+		"packets":     len(v.records),
+	})
+}
+
+// handlePackets lists a page of the packets, without their payloads. The
+// opcode, direction, range and session parameters filter them as the flags
+// of the same names do; offset and limit page through the rest.
+func (v *viewer) handlePackets(w http.ResponseWriter, req *http.Request) {
+	q := req.URL.Query()
+	filter, err := parseFilter(q.Get("opcode"), q.Get("direction"), q.Get("range"))
+	if err == nil {
+		filter.sessions, err = parseSessions(q.Get("session"))
+	}
+	offset, limit := 0, defaultPageSize
+	if err == nil && q.Get("offset") != "" {
+		offset, err = strconv.Atoi(q.Get("offset"))
+	}
+	if err == nil && q.Get("limit") != "" {
+		limit, err = strconv.Atoi(q.Get("limit"))
+	}
+	if err == nil && (offset < 0 || limit <= 0 || limit > maxPageSize) {
+		err = fmt.Errorf("offset must be at least 0 and limit from 1 to %d", maxPageSize)
+	}
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	packets := []viewerPacket{}
+	total := 0
+	for i, rec := range v.records {
+		if !filter.match(v.indexes[i], rec) {
+			continue
+		}
+		if total >= offset && len(packets) < limit {
+			packets = append(packets, v.packet(i, false))
+		}
+		total++
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"total": total, "packets": packets})
+}
+
+// handlePacket returns the packet at a capture index with its payload, as
+// a hexdump and, when it can be decoded, as fields.
+func (v *viewer) handlePacket(w http.ResponseWriter, req *http.Request) {
+	index, _ := strconv.Atoi(mux.Vars(req)["index"])
+	i := sort.SearchInts(v.indexes, index)
+	if i == len(v.indexes) || v.indexes[i] != index {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": fmt.Sprintf("no packet %d", index)})
+		return
+	}
+	rec := v.records[i]
+	var hexdump, fields strings.Builder
+	writeHexdump(&hexdump, rec.Payload, "")
+	resp := map[string]interface{}{"packet": v.packet(i, true), "hexdump": hexdump.String()}
+	if v.serverTypes[i] == pcap.ServerTypeChannel {
+		if pkt, err := decodePacket(rec, v.ctx); err == nil {
+			writeFields(&fields, reflect.ValueOf(pkt).Elem(), "")
+			resp["fields"] = fields.String()
+		} else {
+			resp["decode_error"] = err.Error()
+		}
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// packet returns records[i] as the viewer lists it.
+func (v *viewer) packet(i int, payload bool) viewerPacket {
+	rec := v.records[i]
+	p := viewerPacket{jsonPacket: newJSONPacket(v.indexes[i], rec, v.header.SessionStartNs, payload)}
+	if v.serverTypes[i] == pcap.ServerTypeChannel && mhfpacket.FromOpcode(network.PacketID(rec.Opcode)) != nil {
+		p.Doc = fmt.Sprintf(opcodeDocURL, strings.ToLower(network.PacketID(rec.Opcode).String()))
+	}
+	return p
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
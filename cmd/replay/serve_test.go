@@ -0,0 +1,85 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"erupe-ce/network"
+	"erupe-ce/network/mhfpacket"
+	"erupe-ce/network/pcap"
+)
+
+func TestViewer(t *testing.T) {
+	ping := pcap.PacketRecord{Direction: pcap.DirClientToServer, Opcode: 0x0017, Payload: []byte{0x00, 0x17, 0x00, 0x00, 0x00, 0x2A}}
+	path := createTestCapture(t, []pcap.PacketRecord{ping, s2c(0x0012, 1), ping, s2c(0x0012, 2)})
+	v, err := loadViewer(path, packetFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(v.router())
+	defer srv.Close()
+
+	get := func(url string, wantStatus int, body interface{}) {
+		t.Helper()
+		resp, err := http.Get(srv.URL + url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = resp.Body.Close() }()
+		if resp.StatusCode != wantStatus {
+			t.Fatalf("GET %s: status %d, want %d", url, resp.StatusCode, wantStatus)
+		}
+		if body != nil {
+			if err := json.NewDecoder(resp.Body).Decode(body); err != nil {
+				t.Fatalf("GET %s: %v", url, err)
+			}
+		}
+	}
+
+	get("/", http.StatusOK, nil)
+
+	var list struct {
+		Total   int            `json:"total"`
+		Packets []viewerPacket `json:"packets"`
+	}
+	get("/api/packets?direction=s2c&offset=1&limit=5", http.StatusOK, &list)
+	if list.Total != 2 || len(list.Packets) != 1 || list.Packets[0].Index != 3 || list.Packets[0].Payload != nil {
+		t.Errorf("S→C packets from the second: %+v", list)
+	}
+	get("/api/packets?opcode=MSG_SYS_PING", http.StatusOK, &list)
+	if list.Total != 2 || !strings.HasSuffix(list.Packets[0].Doc, "/network/mhfpacket/msg_sys_ping.go") {
+		t.Errorf("pings: %+v", list)
+	}
+	get("/api/packets?opcode=MSG_SYS_NOPE", http.StatusBadRequest, nil)
+	get("/api/packets?limit=0", http.StatusBadRequest, nil)
+
+	var detail struct {
+		Packet  viewerPacket `json:"packet"`
+		Hexdump string       `json:"hexdump"`
+		Fields  string       `json:"fields"`
+	}
+	get("/api/packets/2", http.StatusOK, &detail)
+	if detail.Packet.Opcode != 0x0017 || len(detail.Packet.Payload) != 6 || !strings.Contains(detail.Hexdump, "00 17 00 00 00 2a") || !strings.Contains(detail.Fields, "AckHandle: 42") {
+		t.Errorf("packet 2: %+v", detail)
+	}
+	get("/api/packets/9", http.StatusNotFound, nil)
+}
+
+// TestOpcodeDocFiles checks the packet of every opcode the viewer links is
+// defined in the file opcodeDocURL names.
+func TestOpcodeDocFiles(t *testing.T) {
+	for id := network.PacketID(0); id <= network.MSG_SYS_reserve1AF; id++ {
+		if mhfpacket.FromOpcode(id) == nil {
+			continue
+		}
+		name := strings.ToLower(id.String()) + ".go"
+		if _, err := os.Stat(filepath.Join("..", "..", "network", "mhfpacket", name)); err != nil {
+			t.Errorf("%s: %v", id, err)
+		}
+	}
+}
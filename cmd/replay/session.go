@@ -0,0 +1,199 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"time"
+
+	"erupe-ce/network"
+	"erupe-ce/network/mhfpacket"
+	"erupe-ce/network/pcap"
+)
+
+// maxReportRows is the most latencies and gaps the session report lists.
+const maxReportRows = 10
+
+// sessionCapture is one capture of the session stats reports on, such as
+// its sign, entrance or channel leg.
+type sessionCapture struct {
+	path    string
+	hdr     pcap.FileHeader
+	records []pcap.PacketRecord
+}
+
+// latencyStats are the response times of one kind of request.
+type latencyStats struct {
+	name  string
+	count int
+	total time.Duration
+	max   time.Duration
+}
+
+// idleGap is a span without client activity.
+type idleGap struct {
+	startNs int64
+	length  time.Duration
+}
+
+// sessionReport is the analysis of a session stats prints under the
+// opcode histogram.
+type sessionReport struct {
+	startNs   int64
+	stages    []sessionCapture
+	login     []loginStep
+	latencies []*latencyStats // Longest first
+	gaps      []idleGap       // Longest first
+}
+
+// loginStep is a request of the login flow and how long it took to answer.
+type loginStep struct {
+	name    string
+	sentNs  int64
+	latency time.Duration
+}
+
+// keepaliveOpcodes are sent by an idle client, so they do not end a gap.
+var keepaliveOpcodes = map[uint16]bool{
+	uint16(network.MSG_SYS_PING): true,
+	uint16(network.MSG_SYS_TIME): true,
+	uint16(network.MSG_SYS_NOP):  true,
+	uint16(network.MSG_SYS_END):  true,
+}
+
+// ackHandleOpcodes caches whether the packet of an opcode starts with an
+// AckHandle answered by MSG_SYS_ACK.
+var ackHandleOpcodes = map[uint16]bool{}
+
+func hasAckHandle(opcode uint16) bool {
+	has, ok := ackHandleOpcodes[opcode]
+	if !ok {
+		if pkt := mhfpacket.FromOpcode(network.PacketID(opcode)); pkt != nil {
+			f, found := reflect.TypeOf(pkt).Elem().FieldByName("AckHandle")
+			has = found && f.Index[0] == 0
+		}
+		ackHandleOpcodes[opcode] = has
+	}
+	return has
+}
+
+// analyzeSession reports on the captures of a session, ordered by when
+// they started. Client inactivity longer than idle is listed as a gap.
+func analyzeSession(captures []sessionCapture, idle time.Duration) sessionReport {
+	sort.SliceStable(captures, func(i, j int) bool { return captures[i].hdr.SessionStartNs < captures[j].hdr.SessionStartNs })
+	rep := sessionReport{startNs: captures[0].hdr.SessionStartNs, stages: captures}
+	latencies := map[string]*latencyStats{}
+	record := func(name string, sentNs, answeredNs int64) time.Duration {
+		d := time.Duration(answeredNs - sentNs)
+		l := latencies[name]
+		if l == nil {
+			l = &latencyStats{name: name}
+			latencies[name] = l
+		}
+		l.count++
+		l.total += d
+		l.max = max(l.max, d)
+		return d
+	}
+
+	var active []int64 // Client packets that end a gap
+	for _, c := range captures {
+		pending := map[uint32]pcap.PacketRecord{}
+		var request *pcap.PacketRecord // The sign or entrance request being answered
+		for i, rec := range c.records {
+			if rec.Direction == pcap.DirClientToServer && !keepaliveOpcodes[rec.Opcode] {
+				active = append(active, rec.TimestampNs)
+			}
+			if c.hdr.ServerType != pcap.ServerTypeChannel {
+				// Sign and entrance servers answer each request once.
+				if rec.Direction == pcap.DirClientToServer {
+					request = &c.records[i]
+				} else if request != nil {
+					name := c.hdr.ServerType.String() + " request"
+					d := record(name, request.TimestampNs, rec.TimestampNs)
+					if len(rep.login) == 0 || rep.login[len(rep.login)-1].name != name {
+						rep.login = append(rep.login, loginStep{name, request.TimestampNs, d})
+					}
+					request = nil
+				}
+				continue
+			}
+			if len(rec.Payload) < 6 {
+				continue
+			}
+			handle := binary.BigEndian.Uint32(rec.Payload[2:6])
+			switch {
+			case rec.Direction == pcap.DirClientToServer && hasAckHandle(rec.Opcode):
+				pending[handle] = rec
+			case rec.Direction == pcap.DirServerToClient && rec.Opcode == uint16(network.MSG_SYS_ACK):
+				req, ok := pending[handle]
+				if !ok {
+					continue
+				}
+				delete(pending, handle)
+				d := record(network.PacketID(req.Opcode).String(), req.TimestampNs, rec.TimestampNs)
+				if req.Opcode == uint16(network.MSG_SYS_LOGIN) {
+					rep.login = append(rep.login, loginStep{"channel login", req.TimestampNs, d})
+				}
+			}
+		}
+	}
+
+	for _, l := range latencies {
+		rep.latencies = append(rep.latencies, l)
+	}
+	sort.Slice(rep.latencies, func(i, j int) bool { return rep.latencies[i].max > rep.latencies[j].max })
+
+	sort.Slice(active, func(i, j int) bool { return active[i] < active[j] })
+	for i := 1; i < len(active); i++ {
+		if d := time.Duration(active[i] - active[i-1]); d > idle {
+			rep.gaps = append(rep.gaps, idleGap{active[i-1], d})
+		}
+	}
+	sort.SliceStable(rep.gaps, func(i, j int) bool { return rep.gaps[i].length > rep.gaps[j].length })
+	return rep
+}
+
+// write prints the report.
+func (rep sessionReport) write(w io.Writer, idle time.Duration) {
+	elapsed := func(ns int64) time.Duration { return time.Duration(ns - rep.startNs) }
+
+	fmt.Fprintf(w, "\n=== Session ===\n")
+	fmt.Fprintf(w, "%-10s %14s %14s %8s\n", "Stage", "Started", "Time spent", "Packets")
+	for _, c := range rep.stages {
+		spent := time.Duration(0)
+		if n := len(c.records); n > 0 {
+			spent = time.Duration(c.records[n-1].TimestampNs - c.hdr.SessionStartNs)
+		}
+		fmt.Fprintf(w, "%-10s %14s %14s %8d\n", c.hdr.ServerType, "+"+elapsed(c.hdr.SessionStartNs).String(), spent, len(c.records))
+	}
+
+	fmt.Fprintf(w, "\nLogin flow:\n")
+	if len(rep.login) == 0 {
+		fmt.Fprintf(w, "  no login requests answered\n")
+	}
+	for _, s := range rep.login {
+		fmt.Fprintf(w, "  %-18s sent at +%-12s answered in %s\n", s.name, elapsed(s.sentNs), s.latency)
+	}
+	if n := len(rep.login); n > 0 {
+		last := rep.login[n-1]
+		fmt.Fprintf(w, "  %-18s %s\n", "total", elapsed(last.sentNs)+last.latency)
+	}
+
+	fmt.Fprintf(w, "\nLongest server responses:\n")
+	if len(rep.latencies) == 0 {
+		fmt.Fprintf(w, "  no requests answered\n")
+	} else {
+		fmt.Fprintf(w, "  %-35s %6s %12s %12s\n", "Request", "Count", "Average", "Longest")
+	}
+	for _, l := range rep.latencies[:min(len(rep.latencies), maxReportRows)] {
+		fmt.Fprintf(w, "  %-35s %6d %12s %12s\n", l.name, l.count, l.total/time.Duration(l.count), l.max)
+	}
+
+	fmt.Fprintf(w, "\nClient inactivity over %s: %d gap(s)\n", idle, len(rep.gaps))
+	for _, g := range rep.gaps[:min(len(rep.gaps), maxReportRows)] {
+		fmt.Fprintf(w, "  from +%-12s for %s\n", elapsed(g.startNs), g.length)
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"erupe-ce/network/pcap"
+)
+
+// acked builds a channel packet starting with an AckHandle.
+func acked(ts int64, dir pcap.Direction, op uint16, handle byte) pcap.PacketRecord {
+	return pcap.PacketRecord{TimestampNs: ts, Direction: dir, Opcode: op, Payload: []byte{byte(op >> 8), byte(op), 0, 0, 0, handle}}
+}
+
+func TestAnalyzeSession(t *testing.T) {
+	const ms = int64(time.Millisecond)
+	sign := sessionCapture{hdr: pcap.FileHeader{ServerType: pcap.ServerTypeSign, SessionStartNs: 0}, records: []pcap.PacketRecord{
+		{TimestampNs: 10 * ms, Direction: pcap.DirClientToServer, Payload: []byte("DSGN:100\x00")},
+		{TimestampNs: 60 * ms, Direction: pcap.DirServerToClient, Payload: []byte{1}},
+	}}
+	channel := sessionCapture{hdr: pcap.FileHeader{ServerType: pcap.ServerTypeChannel, SessionStartNs: 1000 * ms}, records: []pcap.PacketRecord{
+		acked(1010*ms, pcap.DirClientToServer, 0x0014, 1), // MSG_SYS_LOGIN
+		acked(1030*ms, pcap.DirServerToClient, 0x0012, 1), // MSG_SYS_ACK
+		acked(1100*ms, pcap.DirClientToServer, 0x0017, 2), // MSG_SYS_PING
+		acked(1400*ms, pcap.DirServerToClient, 0x0012, 2),
+		acked(1500*ms, pcap.DirServerToClient, 0x0012, 9), // Unanswered handle
+		acked(5000*ms, pcap.DirClientToServer, 0x0017, 3), // Keepalive, still idle
+		acked(9010*ms, pcap.DirClientToServer, 0x0014, 4),
+	}}
+
+	// The stages are ordered by start whatever order they are given in.
+	rep := analyzeSession([]sessionCapture{channel, sign}, 2*time.Second)
+	if rep.stages[0].hdr.ServerType != pcap.ServerTypeSign {
+		t.Errorf("first stage %s", rep.stages[0].hdr.ServerType)
+	}
+	if len(rep.login) != 2 || rep.login[0].name != "sign request" || rep.login[0].latency != 50*time.Millisecond ||
+		rep.login[1].name != "channel login" || rep.login[1].latency != 20*time.Millisecond {
+		t.Errorf("login flow %+v", rep.login)
+	}
+	if len(rep.latencies) != 3 || rep.latencies[0].name != "MSG_SYS_PING" || rep.latencies[0].max != 300*time.Millisecond {
+		t.Errorf("latencies %+v", rep.latencies)
+	}
+	if len(rep.gaps) != 1 || rep.gaps[0].startNs != 1010*ms || rep.gaps[0].length != 8*time.Second {
+		t.Errorf("gaps %+v", rep.gaps)
+	}
+
+	var sb strings.Builder
+	rep.write(&sb, 2*time.Second)
+	for _, want := range []string{"sign", "+1s", "channel login", "total              1.03s", "MSG_SYS_PING", "from +1.01s"} {
+		if !strings.Contains(sb.String(), want) {
+			t.Errorf("report missing %q:\n%s", want, sb.String())
+		}
+	}
+}
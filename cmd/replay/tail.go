@@ -0,0 +1,152 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"strings"
+	"time"
+
+	"erupe-ce/network/pcap"
+)
+
+// tailPoll is how often tail looks for packets written to a capture since
+// it last reached the end. The server flushes captures about once a
+// second.
+const tailPoll = 200 * time.Millisecond
+
+// followReader reads a file that is still being written: at its end, it
+// waits for more instead of returning io.EOF.
+type followReader struct {
+	f        *os.File
+	caughtUp bool            // The end of what was written was reached
+	stop     <-chan struct{} // Makes Read return io.EOF at the end when closed
+}
+
+func (fr *followReader) Read(p []byte) (int, error) {
+	for {
+		n, err := fr.f.Read(p)
+		if n > 0 || err != io.EOF {
+			return n, err
+		}
+		fr.caughtUp = true
+		// A capture discarded as not targeted, or pruned, is gone for good.
+		if _, err := os.Stat(fr.f.Name()); errors.Is(err, fs.ErrNotExist) {
+			return 0, errors.New("capture was deleted")
+		}
+		select {
+		case <-fr.stop:
+			return 0, io.EOF
+		case <-time.After(tailPoll):
+		}
+	}
+}
+
+// nextPart returns the path of the file after path in a capture rotated
+// into parts, or "" when path is not a part.
+func nextPart(path string, part int) string {
+	suffix := fmt.Sprintf("-%04d.mhfr", part)
+	if part == 0 || !strings.HasSuffix(path, suffix) {
+		return ""
+	}
+	return fmt.Sprintf("%s-%04d.mhfr", strings.TrimSuffix(path, suffix), part+1)
+}
+
+// runTail follows the capture at path as a running server writes it,
+// printing the packets the filter keeps with their fields as they arrive,
+// after the last backlog packets written before. It returns when the
+// server closes the capture; a capture rotated into parts is followed
+// into the next part.
+func runTail(path string, filter packetFilter, backlog int) error {
+	return tailCapture(os.Stdout, path, filter, backlog, nil)
+}
+
+// tailCapture is runTail writing to w. Closing stop ends it at the end of
+// what was written.
+func tailCapture(w io.Writer, path string, filter packetFilter, backlog int, stop <-chan struct{}) error {
+	for path != "" {
+		part, err := tailFile(w, path, filter, backlog, stop)
+		if err != nil {
+			return err
+		}
+		next := nextPart(path, part)
+		if next == "" {
+			break
+		}
+		// The server creates the next part as soon as it closes a part;
+		// without one, the session ended.
+		time.Sleep(tailPoll)
+		if _, err := os.Stat(next); err != nil {
+			break
+		}
+		fmt.Fprintf(w, "\n=== Continued in %s ===\n", next)
+		path, backlog = next, -1
+	}
+	fmt.Fprintln(w, "\nCapture closed")
+	return nil
+}
+
+// tailFile follows one file, returning its part number. A negative
+// backlog prints every packet already written.
+func tailFile(w io.Writer, path string, filter packetFilter, backlog int, stop <-chan struct{}) (int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, fmt.Errorf("open capture: %w", err)
+	}
+	defer func() { _ = f.Close() }()
+	fr := &followReader{f: f, stop: stop}
+	r, err := pcap.NewReader(fr)
+	if err != nil {
+		return 0, fmt.Errorf("read capture: %w", err)
+	}
+	if r.Header.Flags&pcap.FlagEncrypted != 0 && captureKey == nil {
+		return 0, errors.New("capture is encrypted, pass --key or --key-file")
+	}
+	if err := r.SetKey(captureKey); err != nil {
+		return 0, err
+	}
+	printCaptureHeader(w, path, r)
+
+	type indexed struct {
+		index int
+		rec   pcap.PacketRecord
+	}
+	var earlier []indexed // The last backlog packets written before tail started
+	skipped := 0
+	live := backlog < 0
+	ctx := captureContext(r.Header)
+	show := func(i int, rec pcap.PacketRecord) {
+		writePacketLine(w, i, rec, r.Header.SessionStartNs)
+		writeDecoded(w, rec, packetServerType(r, rec), ctx)
+	}
+	catchUp := func() {
+		if skipped > 0 {
+			fmt.Fprintf(w, "... %d earlier packets\n", skipped)
+		}
+		for _, p := range earlier {
+			show(p.index, p.rec)
+		}
+		earlier, live = nil, true
+	}
+	err = eachPacket(r, filter, func(i int, rec pcap.PacketRecord) error {
+		if !live && !fr.caughtUp {
+			earlier = append(earlier, indexed{i, rec})
+			if len(earlier) > backlog {
+				earlier = earlier[1:]
+				skipped++
+			}
+			return nil
+		}
+		if !live {
+			catchUp()
+		}
+		show(i, rec)
+		return nil
+	})
+	if !live {
+		catchUp()
+	}
+	return r.Meta.Part, err
+}
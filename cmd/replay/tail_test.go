@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"erupe-ce/network/pcap"
+)
+
+func TestTailCapture(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "live.mhfr")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = f.Close() }()
+	w, err := pcap.NewWriter(f, pcap.FileHeader{Version: pcap.FormatVersion, ServerType: pcap.ServerTypeChannel, ClientMode: 40}, pcap.SessionMetadata{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range 3 {
+		_ = w.WritePacket(packet(int64(i), 0x0017))
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	done := make(chan error)
+	go func() { done <- tailCapture(&out, path, packetFilter{}, 1, nil) }()
+
+	// A packet written while tail follows the capture, then the end.
+	time.Sleep(2 * tailPoll)
+	_ = w.WritePacket(packet(3, 0x0013))
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("tail did not stop when the capture was closed")
+	}
+
+	got := out.String()
+	for _, want := range []string{"... 2 earlier packets", "#0002", "#0003", "MSG_SYS_TERMINAL_LOG", "Capture closed"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("output is missing %q:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "#0001") {
+		t.Errorf("output shows more than the backlog:\n%s", got)
+	}
+}
+
+func TestTailCaptureParts(t *testing.T) {
+	dir := t.TempDir()
+	rec, err := pcap.CreateRecording(filepath.Join(dir, "channel.mhfr"), pcap.FileHeader{Version: pcap.FormatVersion, ServerType: pcap.ServerTypeChannel}, pcap.SessionMetadata{}, pcap.RecordingOptions{MaxBytes: 20})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range 2 {
+		_ = rec.WritePacket(packet(int64(i), 0x0017))
+	}
+	if err := rec.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	stop := make(chan struct{})
+	close(stop)
+	if err := tailCapture(&out, filepath.Join(dir, "channel-0001.mhfr"), packetFilter{}, 10, stop); err != nil {
+		t.Fatal(err)
+	}
+	if got := out.String(); !strings.Contains(got, "Continued in "+filepath.Join(dir, "channel-0002.mhfr")) || strings.Count(got, "MSG_SYS_PING") != 2 {
+		t.Errorf("output:\n%s", got)
+	}
+	if got := nextPart("a.mhfr", 0); got != "" {
+		t.Errorf("nextPart() of a capture without parts = %q", got)
+	}
+}
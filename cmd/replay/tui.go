@@ -0,0 +1,370 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode/utf8"
+
+	"erupe-ce/network/clientctx"
+	"erupe-ce/network/pcap"
+
+	"golang.org/x/term"
+)
+
+// browser is the state of the interactive capture browser. It is kept
+// apart from the terminal so it can be driven by tests.
+type browser struct {
+	path    string
+	hdr     pcap.FileHeader
+	ctx     *clientctx.ClientContext
+	packets []listedPacket
+	load    func(index int) ([]byte, error) // Reads payloads left out of packets
+
+	shown   []int // Positions in packets passing the opcode filter
+	cursor  int   // Position in shown of the selected packet
+	top     int   // Position in shown of the first listed packet
+	scroll  int   // First line of the payload pane
+	decode  bool  // Payload pane shows decoded fields rather than a hexdump
+	opcodes string
+	editing bool // The opcode filter box has focus
+	input   string
+	status  string
+
+	payloadFor int // Capture index whose payload is cached, or -1
+	payload    []byte
+}
+
+// listedPacket is a packet of the browser's list.
+type listedPacket struct {
+	rec   pcap.PacketRecord // Payload is nil when the browser loads it
+	index int               // Index in the capture
+	size  int               // Payload length
+}
+
+func newBrowser(path string, hdr pcap.FileHeader, packets []listedPacket, load func(int) ([]byte, error)) *browser {
+	b := &browser{
+		path:       path,
+		hdr:        hdr,
+		ctx:        captureContext(hdr),
+		packets:    packets,
+		load:       load,
+		decode:     hdr.ServerType == pcap.ServerTypeChannel,
+		payloadFor: -1,
+	}
+	_ = b.setFilter("")
+	return b
+}
+
+// setFilter shows only the packets with the opcodes listed in s, as
+// --filter-opcode takes them, or every packet when s is empty.
+func (b *browser) setFilter(s string) error {
+	f, err := parseFilter(s, "", "")
+	if err != nil {
+		return err
+	}
+	b.opcodes = s
+	b.shown = b.shown[:0]
+	for i, p := range b.packets {
+		if f.match(0, p.rec) {
+			b.shown = append(b.shown, i)
+		}
+	}
+	b.cursor, b.top, b.scroll = 0, 0, 0
+	return nil
+}
+
+// selected returns the selected packet with its payload.
+func (b *browser) selected() (pcap.PacketRecord, int, error) {
+	if len(b.shown) == 0 {
+		return pcap.PacketRecord{}, -1, errors.New("no packets")
+	}
+	p := b.packets[b.shown[b.cursor]]
+	rec, index := p.rec, p.index
+	if rec.Payload == nil && b.load != nil {
+		if b.payloadFor != index {
+			payload, err := b.load(index)
+			if err != nil {
+				return rec, index, err
+			}
+			b.payload, b.payloadFor = payload, index
+		}
+		rec.Payload = b.payload
+	}
+	return rec, index, nil
+}
+
+// key applies a key press, returning false when the browser should quit.
+// Keys are single characters or the names readKey gives escape sequences.
+func (b *browser) key(k string, listHeight int) bool {
+	if b.editing {
+		switch k {
+		case "enter":
+			if err := b.setFilter(strings.TrimSpace(b.input)); err != nil {
+				b.status = err.Error()
+			} else {
+				b.status = ""
+			}
+			b.editing = false
+		case "esc":
+			b.editing = false
+		case "backspace":
+			if len(b.input) > 0 {
+				_, n := utf8.DecodeLastRuneInString(b.input)
+				b.input = b.input[:len(b.input)-n]
+			}
+		default:
+			if utf8.RuneCountInString(k) == 1 && k[0] >= ' ' {
+				b.input += k
+			}
+		}
+		return true
+	}
+
+	b.status = ""
+	move := 0
+	switch k {
+	case "q", "esc", "ctrl-c":
+		return false
+	case "up", "k":
+		move = -1
+	case "down", "j":
+		move = 1
+	case "pgup":
+		move = -listHeight
+	case "pgdown", " ":
+		move = listHeight
+	case "home", "g":
+		move = -len(b.shown)
+	case "end", "G":
+		move = len(b.shown)
+	case "K":
+		b.scroll = max(b.scroll-1, 0)
+	case "J":
+		b.scroll++
+	case "tab":
+		b.decode = !b.decode
+		b.scroll = 0
+	case "/":
+		b.editing, b.input = true, b.opcodes
+	}
+	if move != 0 && len(b.shown) > 0 {
+		b.cursor = min(max(b.cursor+move, 0), len(b.shown)-1)
+		b.scroll = 0
+	}
+	// Keep the cursor in the list pane.
+	if b.cursor < b.top {
+		b.top = b.cursor
+	} else if b.cursor >= b.top+listHeight {
+		b.top = b.cursor - listHeight + 1
+	}
+	return true
+}
+
+// listHeight is the number of packets listed on a screen of height lines.
+// The rest holds the title, the selected packet's payload and the status
+// line.
+func listHeight(height int) int {
+	return max((height-3)/2, 1)
+}
+
+// render draws the browser on a screen of width by height cells.
+func (b *browser) render(w io.Writer, width, height int) {
+	lh := listHeight(height)
+	var lines []string
+
+	title := fmt.Sprintf(" %s  %s  %d/%d packets", b.path, b.hdr.ServerType, len(b.shown), len(b.packets))
+	if b.opcodes != "" {
+		title += "  opcodes: " + b.opcodes
+	}
+	lines = append(lines, "\x1b[7m"+fit(title, width)+"\x1b[0m")
+
+	for row := 0; row < lh; row++ {
+		pos := b.top + row
+		if pos >= len(b.shown) {
+			lines = append(lines, "")
+			continue
+		}
+		p := b.packets[b.shown[pos]]
+		line := fit(packetSummary(p, b.hdr.SessionStartNs), width)
+		if pos == b.cursor {
+			line = "\x1b[7m" + line + "\x1b[0m"
+		}
+		lines = append(lines, line)
+	}
+
+	view := "hexdump"
+	if b.decode {
+		view = "decoded"
+	}
+	lines = append(lines, "\x1b[7m"+fit(" payload ("+view+")  Tab: switch view  J/K: scroll", width)+"\x1b[0m")
+
+	var payload []string
+	if rec, _, err := b.selected(); err != nil {
+		payload = []string{err.Error()}
+	} else {
+		var sb strings.Builder
+		if b.decode {
+			writeDecoded(&sb, rec, b.hdr.ServerType, b.ctx)
+		} else {
+			writeHexdump(&sb, rec.Payload, "")
+		}
+		payload = strings.Split(strings.TrimSuffix(sb.String(), "\n"), "\n")
+	}
+	ph := height - len(lines) - 1
+	b.scroll = min(b.scroll, max(len(payload)-ph, 0))
+	for row := 0; row < ph; row++ {
+		if b.scroll+row < len(payload) {
+			lines = append(lines, fit(payload[b.scroll+row], width))
+		} else {
+			lines = append(lines, "")
+		}
+	}
+
+	switch {
+	case b.editing:
+		lines = append(lines, fit("Opcodes: "+b.input, width))
+	case b.status != "":
+		lines = append(lines, fit(b.status, width))
+	default:
+		lines = append(lines, fit("↑/↓ PgUp/PgDn Home/End: move  /: filter opcodes  q: quit", width))
+	}
+
+	bw := bufio.NewWriter(w)
+	_, _ = bw.WriteString("\x1b[H")
+	for i, line := range lines {
+		_, _ = bw.WriteString(line + "\x1b[K")
+		if i < len(lines)-1 {
+			_, _ = bw.WriteString("\r\n")
+		}
+	}
+	_ = bw.Flush()
+}
+
+// fit cuts s to width characters.
+func fit(s string, width int) string {
+	if utf8.RuneCountInString(s) <= width {
+		return s
+	}
+	return string([]rune(s)[:width])
+}
+
+// packetSummary is the line dump mode prints for a packet.
+func packetSummary(p listedPacket, startNs int64) string {
+	rec := p.rec
+	rec.Payload = make([]byte, p.size)
+	var sb strings.Builder
+	writePacketLine(&sb, p.index, rec, startNs)
+	return strings.TrimSuffix(sb.String(), "\n")
+}
+
+// keyNames names the escape sequences of the keys the browser uses.
+var keyNames = map[string]string{
+	"\x1b[A": "up", "\x1bOA": "up",
+	"\x1b[B": "down", "\x1bOB": "down",
+	"\x1b[5~": "pgup", "\x1b[6~": "pgdown",
+	"\x1b[H": "home", "\x1b[1~": "home", "\x1bOH": "home",
+	"\x1b[F": "end", "\x1b[4~": "end", "\x1bOF": "end",
+}
+
+// readKey reads one key press from a terminal in raw mode.
+func readKey(r *bufio.Reader) (string, error) {
+	c, _, err := r.ReadRune()
+	if err != nil {
+		return "", err
+	}
+	switch c {
+	case 0x03:
+		return "ctrl-c", nil
+	case '\r', '\n':
+		return "enter", nil
+	case '\t':
+		return "tab", nil
+	case 0x7F, 0x08:
+		return "backspace", nil
+	case 0x1B:
+		// A lone Escape is not followed by the rest of a sequence.
+		if r.Buffered() == 0 {
+			return "esc", nil
+		}
+		seq := []byte{0x1B}
+		for r.Buffered() > 0 {
+			c, err := r.ReadByte()
+			if err != nil {
+				return "", err
+			}
+			seq = append(seq, c)
+			if len(seq) > 2 && (c >= 'A' && c <= 'Z' || c == '~') {
+				break
+			}
+		}
+		return keyNames[string(seq)], nil
+	}
+	return string(c), nil
+}
+
+// runTUI browses the packets of the capture at path the filter keeps in
+// the terminal. Only their summaries are held; payloads are read as they
+// are shown when the capture has an index, and held too otherwise.
+func runTUI(path string, filter packetFilter) error {
+	if !term.IsTerminal(int(os.Stdin.Fd())) || !term.IsTerminal(int(os.Stdout.Fd())) {
+		return errors.New("tui mode needs a terminal")
+	}
+	r, f, err := openCapture(path)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = f.Close() }()
+
+	indexed := r.Index() != nil
+	var packets []listedPacket
+	err = eachPacket(r, filter, func(i int, rec pcap.PacketRecord) error {
+		p := listedPacket{rec: rec, index: i, size: len(rec.Payload)}
+		if indexed {
+			p.rec.Payload = nil
+		}
+		packets = append(packets, p)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	var load func(int) ([]byte, error)
+	if indexed {
+		load = func(i int) ([]byte, error) {
+			rec, err := r.ReadAt(i)
+			return rec.Payload, err
+		}
+	}
+	b := newBrowser(path, r.Header, packets, load)
+
+	state, err := term.MakeRaw(int(os.Stdin.Fd()))
+	if err != nil {
+		return err
+	}
+	// Draw on the alternate screen, restoring the terminal on the way out.
+	fmt.Print("\x1b[?1049h\x1b[?25l\x1b[2J")
+	defer func() {
+		fmt.Print("\x1b[?25h\x1b[?1049l")
+		_ = term.Restore(int(os.Stdin.Fd()), state)
+	}()
+
+	in := bufio.NewReader(os.Stdin)
+	for {
+		width, height, err := term.GetSize(int(os.Stdout.Fd()))
+		if err != nil {
+			width, height = 80, 24
+		}
+		b.render(os.Stdout, width, height)
+		k, err := readKey(in)
+		if err != nil {
+			return err
+		}
+		if !b.key(k, listHeight(height)) {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"bufio"
+	"strings"
+	"testing"
+
+	"erupe-ce/network/pcap"
+)
+
+func testBrowser(load func(int) ([]byte, error)) *browser {
+	packets := []pcap.PacketRecord{
+		{TimestampNs: 100, Direction: pcap.DirClientToServer, Opcode: 0x0017, Payload: []byte{0x00, 0x17, 0x00, 0x00, 0x00, 0x01}},
+		{TimestampNs: 200, Direction: pcap.DirServerToClient, Opcode: 0x0012, Payload: []byte{0x00, 0x12, 0xAB}},
+		{TimestampNs: 300, Direction: pcap.DirClientToServer, Opcode: 0x0017, Payload: []byte{0x00, 0x17, 0x00, 0x00, 0x00, 0x02}},
+	}
+	listed := make([]listedPacket, len(packets))
+	for i, rec := range packets {
+		listed[i] = listedPacket{rec: rec, index: 10 + i, size: len(rec.Payload)}
+		if load != nil {
+			listed[i].rec.Payload = nil
+		}
+	}
+	hdr := pcap.FileHeader{ServerType: pcap.ServerTypeChannel}
+	return newBrowser("test.mhfr", hdr, listed, load)
+}
+
+func TestBrowserKeys(t *testing.T) {
+	b := testBrowser(nil)
+	b.key("down", 2)
+	b.key("down", 2)
+	if b.cursor != 2 || b.top != 1 {
+		t.Errorf("after moving down: cursor %d top %d, want 2 and 1", b.cursor, b.top)
+	}
+	b.key("down", 2)
+	if b.cursor != 2 {
+		t.Errorf("cursor moved past the last packet: %d", b.cursor)
+	}
+	b.key("home", 2)
+	if b.cursor != 0 || b.top != 0 {
+		t.Errorf("after home: cursor %d top %d", b.cursor, b.top)
+	}
+	if b.key("q", 2) {
+		t.Error("q did not quit")
+	}
+}
+
+func TestBrowserFilterBox(t *testing.T) {
+	b := testBrowser(nil)
+	b.key("/", 2)
+	for _, k := range strings.Split("MSG_SYS_PINGX", "") {
+		b.key(k, 2)
+	}
+	b.key("backspace", 2)
+	b.key("enter", 2)
+	if b.editing || len(b.shown) != 2 || b.opcodes != "MSG_SYS_PING" {
+		t.Fatalf("filter %q shows %v", b.opcodes, b.shown)
+	}
+	if _, index, _ := b.selected(); index != 10 {
+		t.Errorf("selected capture index %d, want 10", index)
+	}
+
+	b.key("/", 2)
+	b.key("z", 2)
+	b.key("enter", 2)
+	if b.status == "" || b.opcodes != "MSG_SYS_PING" {
+		t.Errorf("bad filter accepted: status %q, opcodes %q", b.status, b.opcodes)
+	}
+}
+
+func TestBrowserLoadsPayloads(t *testing.T) {
+	loads := 0
+	b := testBrowser(func(i int) ([]byte, error) {
+		loads++
+		return []byte{0x00, 0x12, byte(i)}, nil
+	})
+	b.key("down", 2)
+	rec, _, err := b.selected()
+	if err != nil || rec.Payload[2] != 11 {
+		t.Fatalf("selected %v, %v", rec.Payload, err)
+	}
+	_, _, _ = b.selected()
+	if loads != 1 {
+		t.Errorf("payload loaded %d times, want once", loads)
+	}
+}
+
+func TestBrowserRender(t *testing.T) {
+	b := testBrowser(nil)
+	var sb strings.Builder
+	b.render(&sb, 100, 20)
+	out := sb.String()
+	for _, want := range []string{"test.mhfr", "3/3 packets", "#0010", "MSG_SYS_PING", "6 bytes", "AckHandle: 1 (0x1)", "q: quit"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("screen missing %q:\n%s", want, out)
+		}
+	}
+	if n := strings.Count(out, "\r\n"); n != 19 {
+		t.Errorf("screen has %d lines, want 20", n+1)
+	}
+
+	b.key("tab", listHeight(20))
+	sb.Reset()
+	b.render(&sb, 100, 20)
+	if !strings.Contains(sb.String(), "00000000  00 17 00 00 00 01") {
+		t.Errorf("hexdump view missing the payload:\n%s", sb.String())
+	}
+}
+
+func TestReadKey(t *testing.T) {
+	r := bufio.NewReader(strings.NewReader("\x1b[Aj\x1b[6~\r\x7f"))
+	var keys []string
+	for range 5 {
+		k, err := readKey(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		keys = append(keys, k)
+	}
+	if got := strings.Join(keys, " "); got != "up j pgdown enter backspace" {
+		t.Errorf("keys %q", got)
+	}
+}
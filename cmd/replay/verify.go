@@ -0,0 +1,101 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"erupe-ce/network/pcap"
+)
+
+// verifyResult is what verify found of a capture.
+type verifyResult string
+
+const (
+	verifyOK        verifyResult = "OK"
+	verifyTruncated verifyResult = "TRUNCATED" // Ends without the end marker, as when the server was killed
+	verifyCorrupt   verifyResult = "CORRUPT"   // Has bytes other than those written
+)
+
+// runVerify reads every packet of the capture at path, checking the
+// checksums of a capture written with them, and reports whether it is
+// whole, cut short or corrupt, with the last packet read intact. It fails
+// unless the capture is whole.
+func runVerify(path string) error {
+	r, f, err := openCapture(path)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = f.Close() }()
+
+	res, err := verifyCapture(os.Stdout, path, r)
+	if err != nil {
+		return err
+	}
+	if res != verifyOK {
+		return fmt.Errorf("capture is %s", res)
+	}
+	return nil
+}
+
+// verifyCapture is runVerify writing to w, returning its result. The error
+// is for a capture that could not be read for another reason, such as a
+// wrong key.
+func verifyCapture(w io.Writer, path string, r *pcap.Reader) (verifyResult, error) {
+	printCaptureHeader(w, path, r)
+	checksums := r.Header.Flags&pcap.FlagChecksums != 0
+	fmt.Fprintf(w, "Checksums: %t  Index: %t\n", checksums, r.Index() != nil)
+
+	var last pcap.PacketRecord
+	n := 0
+	var readErr error
+	for {
+		rec, err := r.ReadPacket()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			readErr = err
+			break
+		}
+		last = rec
+		n++
+	}
+
+	res := verifyOK
+	var reason string
+	switch {
+	case errors.Is(readErr, pcap.ErrCorrupt):
+		res, reason = verifyCorrupt, readErr.Error()
+	case errors.Is(readErr, io.ErrUnexpectedEOF):
+		res, reason = verifyTruncated, "the file ends within a packet"
+	case readErr != nil:
+		return "", readErr
+	case !r.Closed():
+		res, reason = verifyTruncated, "the file ends without the end of the packets"
+	case r.Index() != nil && r.Index().Packets != n:
+		res, reason = verifyCorrupt, fmt.Sprintf("the index counts %d packets", r.Index().Packets)
+	}
+
+	fmt.Fprintf(w, "Packets: %d\n", n)
+	if res != verifyOK {
+		fmt.Fprintf(w, "Reason: %s\n", reason)
+		if n > 0 {
+			fmt.Fprint(w, "Last valid: ")
+			writePacketLine(w, n-1, last, r.Header.SessionStartNs)
+		} else {
+			fmt.Fprintln(w, "Last valid: none")
+		}
+	}
+	if res == verifyOK && checksums {
+		fmt.Fprintln(w, "Every checksum and the hash of the packets match")
+	}
+	fmt.Fprintf(w, "Result: %s\n", res)
+	if res == verifyTruncated && n > 0 {
+		fmt.Fprintf(w, "The first %d packets, to +%s, can be read; the rest were lost\n",
+			n, time.Duration(last.TimestampNs-r.Header.SessionStartNs))
+	}
+	return res, nil
+}
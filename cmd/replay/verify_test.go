@@ -0,0 +1,78 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"erupe-ce/network/pcap"
+)
+
+func TestVerifyCapture(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "channel.mhfr")
+	hdr := pcap.FileHeader{Version: pcap.FormatVersion, ServerType: pcap.ServerTypeChannel, ClientMode: 40, SessionStartNs: 1000, Flags: pcap.FlagChecksums}
+	c, err := createCapture(path, hdr, pcap.SessionMetadata{}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := int64(0); i < 4; i++ {
+		if err := c.write(packet(1000+i*int64(1e9), 0x0013)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := c.close(); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	verify := func(name string, data []byte) (verifyResult, string) {
+		t.Helper()
+		p := filepath.Join(dir, name)
+		if err := os.WriteFile(p, data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		r, f, err := openCapture(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = f.Close() }()
+		var out strings.Builder
+		res, err := verifyCapture(&out, p, r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res, out.String()
+	}
+
+	if res, out := verify("whole.mhfr", data); res != verifyOK || !strings.Contains(out, "Packets: 4") {
+		t.Errorf("whole capture: %s\n%s", res, out)
+	}
+
+	// The packets follow the header and metadata, each record 15 bytes, a
+	// 2-byte payload and a 4-byte checksum.
+	r, f, err := openCapture(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = f.Close()
+	first := pcap.HeaderSize + int(r.Header.MetadataLen)
+	res, out := verify("cut.mhfr", data[:first+2*21+5])
+	if res != verifyTruncated || !strings.Contains(out, "Last valid: #0001  +1s") {
+		t.Errorf("cut capture: %s\n%s", res, out)
+	}
+
+	corrupt := append([]byte(nil), data...)
+	corrupt[first+2*21+16] ^= 0xFF
+	if res, out := verify("corrupt.mhfr", corrupt); res != verifyCorrupt || !strings.Contains(out, "checksum of packet 2") {
+		t.Errorf("corrupt capture: %s\n%s", res, out)
+	}
+
+	if err := runVerify(filepath.Join(dir, "cut.mhfr")); err == nil || !strings.Contains(err.Error(), "TRUNCATED") {
+		t.Errorf("runVerify of a cut capture = %v", err)
+	}
+}
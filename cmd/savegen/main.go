@@ -0,0 +1,131 @@
+// savegen writes a structurally valid character save for any client mode,
+// for seeding a test database or reproducing save parsing bugs by hand.
+//
+// The save is nullcomp compressed, as stored in characters.savedata, unless
+// --raw is given. An item box, which lives in the warehouse rather than the
+// save, is written separately with --items-out.
+//
+// Usage:
+//
+//	savegen --mode ZZ --name Hunter --gr 300 --out save.bin
+//	savegen --mode F5 --name Hunter --hr 999 --raw --out save.raw
+//	savegen --name Hunter --items 1:10,7:99 --items-out itembox.bin --out save.bin
+package main
+
+import (
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"erupe-ce/common/mhfitem"
+	cfg "erupe-ce/config"
+	"erupe-ce/server/channelserver"
+)
+
+func main() {
+	mode := flag.String("mode", "ZZ", "Client mode, as in ClientMode (S1.0 ... ZZ)")
+	name := flag.String("name", "Hunter", "Character name")
+	female := flag.Bool("female", false, "Female character")
+	hr := flag.Uint("hr", 1, "Hunter rank")
+	gr := flag.Uint("gr", 0, "G rank (G1 onward), overrides --hr")
+	rp := flag.Uint("rp", 0, "Road points")
+	weaponType := flag.Uint("weapon-type", 0, "Equipped weapon type")
+	weaponID := flag.Uint("weapon-id", 0, "Equipped weapon ID")
+	playtime := flag.Uint("playtime", 0, "Playtime in seconds")
+	kqf := flag.String("kqf", "", "Key quest flags as 16 hex digits (G10 onward)")
+	items := flag.String("items", "", "Item box as comma-separated id:quantity pairs")
+	out := flag.String("out", "", "Path to write the save to (required)")
+	itemsOut := flag.String("items-out", "", "Path to write the serialized item box to")
+	raw := flag.Bool("raw", false, "Write the save uncompressed")
+	flag.Parse()
+
+	if *out == "" {
+		fmt.Fprintln(os.Stderr, "error: --out is required")
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	fixture := channelserver.SaveFixture{
+		Name:       *name,
+		Female:     *female,
+		HR:         uint16(*hr),
+		GR:         uint16(*gr),
+		RP:         uint16(*rp),
+		WeaponType: uint8(*weaponType),
+		WeaponID:   uint16(*weaponID),
+		Playtime:   uint32(*playtime),
+	}
+	var ok bool
+	if fixture.Mode, ok = cfg.ParseMode(*mode); !ok {
+		fatalf("unknown mode %q", *mode)
+	}
+	if *kqf != "" {
+		b, err := hex.DecodeString(*kqf)
+		if err != nil || len(b) != 8 {
+			fatalf("--kqf must be 16 hex digits")
+		}
+		fixture.KQF = b
+	}
+	var err error
+	if fixture.ItemBox, err = parseItems(*items); err != nil {
+		fatalf("--items: %v", err)
+	}
+
+	data := fixture.Build()
+	if !*raw {
+		if data, err = fixture.Compressed(); err != nil {
+			fatalf("compress: %v", err)
+		}
+	}
+	if err := os.WriteFile(*out, data, 0644); err != nil {
+		fatalf("%v", err)
+	}
+	fmt.Printf("Wrote %d byte save to %s\n", len(data), *out)
+
+	if *itemsOut != "" {
+		if fixture.ItemBox == nil {
+			fatalf("--items-out needs --items")
+		}
+		if err := os.WriteFile(*itemsOut, fixture.ItemBoxData(), 0644); err != nil {
+			fatalf("%v", err)
+		}
+		fmt.Printf("Wrote %d item stack(s) to %s\n", len(fixture.ItemBox), *itemsOut)
+	}
+}
+
+// parseItems parses id:quantity pairs separated by commas into item stacks
+// with sequential warehouse IDs.
+func parseItems(s string) ([]mhfitem.MHFItemStack, error) {
+	if s == "" {
+		return nil, nil
+	}
+	var stacks []mhfitem.MHFItemStack
+	for i, pair := range strings.Split(s, ",") {
+		id, qty, found := strings.Cut(strings.TrimSpace(pair), ":")
+		if !found {
+			return nil, fmt.Errorf("%q is not id:quantity", pair)
+		}
+		itemID, err := strconv.ParseUint(id, 10, 16)
+		if err != nil {
+			return nil, fmt.Errorf("item ID %q: %w", id, err)
+		}
+		quantity, err := strconv.ParseUint(qty, 10, 16)
+		if err != nil {
+			return nil, fmt.Errorf("quantity %q: %w", qty, err)
+		}
+		stacks = append(stacks, mhfitem.MHFItemStack{
+			WarehouseID: uint32(i + 1),
+			Item:        mhfitem.MHFItem{ItemID: uint16(itemID)},
+			Quantity:    uint16(quantity),
+		})
+	}
+	return stacks, nil
+}
+
+func fatalf(format string, args ...any) {
+	fmt.Fprintf(os.Stderr, "error: "+format+"\n", args...)
+	os.Exit(1)
+}
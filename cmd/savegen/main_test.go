@@ -0,0 +1,25 @@
+package main
+
+import "testing"
+
+func TestParseItems(t *testing.T) {
+	stacks, err := parseItems("1:10, 7:99")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stacks) != 2 {
+		t.Fatalf("got %d stacks, want 2", len(stacks))
+	}
+	if s := stacks[1]; s.WarehouseID != 2 || s.Item.ItemID != 7 || s.Quantity != 99 {
+		t.Errorf("second stack = %+v", s)
+	}
+
+	if stacks, err := parseItems(""); err != nil || stacks != nil {
+		t.Errorf("empty input = %v, %v", stacks, err)
+	}
+	for _, bad := range []string{"1", "x:1", "1:x", "1:70000"} {
+		if _, err := parseItems(bad); err == nil {
+			t.Errorf("parseItems(%q) succeeded", bad)
+		}
+	}
+}
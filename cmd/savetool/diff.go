@@ -0,0 +1,142 @@
+package main
+
+import (
+	"encoding/binary"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"erupe-ce/common/bfutil"
+	"erupe-ce/common/stringsupport"
+	"erupe-ce/server/channelserver/savedata"
+)
+
+func runDiff(args []string) {
+	fs := flag.NewFlagSet("diff", flag.ExitOnError)
+	mode := fs.String("mode", "ZZ", "Client mode the saves were written in, as in ClientMode")
+	gap := fs.Int("gap", 8, "Join changed regions separated by fewer equal bytes than this")
+	maxBytes := fs.Int("bytes", 32, "Bytes of each region to print, 0 for all")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: savetool diff [flags] A B")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+	if fs.NArg() != 2 {
+		fs.Usage()
+		os.Exit(1)
+	}
+	fields := savedata.Fields(parseModeFlag(fs, *mode))
+
+	var src source
+	a, err := src.load(fs.Arg(0))
+	if err != nil {
+		fatalf("%v", err)
+	}
+	b, err := src.load(fs.Arg(1))
+	if err != nil {
+		fatalf("%v", err)
+	}
+	fmt.Printf("a: %s, %d bytes\nb: %s, %d bytes\n", fs.Arg(0), len(a), fs.Arg(1), len(b))
+	writeDiff(os.Stdout, a, b, fields, diffRegions(a, b, *gap), *maxBytes)
+}
+
+// region is a run of bytes from start up to end that differs between two
+// saves.
+type region struct {
+	start, end int
+}
+
+// diffRegions returns the regions where a and b differ, joining regions
+// separated by fewer than gap equal bytes. Bytes past the end of the shorter
+// save all differ.
+func diffRegions(a, b []byte, gap int) []region {
+	var regions []region
+	n := min(len(a), len(b))
+	add := func(start, end int) {
+		if last := len(regions) - 1; last >= 0 && start-regions[last].end < gap {
+			regions[last].end = end
+			return
+		}
+		regions = append(regions, region{start, end})
+	}
+	for i := 0; i < n; i++ {
+		if a[i] == b[i] {
+			continue
+		}
+		start := i
+		for i < n && a[i] != b[i] {
+			i++
+		}
+		add(start, i)
+	}
+	if len(a) != len(b) {
+		add(n, max(len(a), len(b)))
+	}
+	return regions
+}
+
+// writeDiff prints each region with both saves' bytes and the known fields
+// it touches, decoding the values of small fields.
+func writeDiff(w io.Writer, a, b []byte, fields []savedata.FieldInfo, regions []region, maxBytes int) {
+	changed := 0
+	for _, r := range regions {
+		changed += r.end - r.start
+	}
+	_, _ = fmt.Fprintf(w, "%d changed region(s), %d byte(s)\n", len(regions), changed)
+
+	for _, r := range regions {
+		var names []string
+		var touched []savedata.FieldInfo
+		for _, f := range fields {
+			if f.Offset < r.end && f.End() > r.start {
+				names = append(names, f.Name)
+				touched = append(touched, f)
+			}
+		}
+		if names == nil {
+			names = []string{"(unknown)"}
+		}
+		_, _ = fmt.Fprintf(w, "\n0x%06X-0x%06X (%d bytes): %s\n", r.start, r.end-1, r.end-r.start, strings.Join(names, ", "))
+		_, _ = fmt.Fprintf(w, "  a: %s\n  b: %s\n", hexRange(a, r, maxBytes), hexRange(b, r, maxBytes))
+		for _, f := range touched {
+			if va, vb := fieldValue(f, a), fieldValue(f, b); va != "" || vb != "" {
+				_, _ = fmt.Fprintf(w, "  %s: %s -> %s\n", f.Name, va, vb)
+			}
+		}
+	}
+}
+
+// hexRange formats the bytes of data in r, up to maxBytes of them.
+func hexRange(data []byte, r region, maxBytes int) string {
+	if r.start >= len(data) {
+		return "(past end)"
+	}
+	end := min(r.end, len(data))
+	if maxBytes > 0 && end-r.start > maxBytes {
+		return fmt.Sprintf("% x ... (%d more)", data[r.start:r.start+maxBytes], end-r.start-maxBytes)
+	}
+	return fmt.Sprintf("% x", data[r.start:end])
+}
+
+// fieldValue decodes the name and numeric fields of a save, returning "" for
+// fields only shown as bytes.
+func fieldValue(f savedata.FieldInfo, data []byte) string {
+	if f.End() > len(data) {
+		return "-"
+	}
+	v := data[f.Offset:f.End()]
+	switch {
+	case f.Field == savedata.FieldName:
+		return strconv.Quote(stringsupport.SJISToUTF8Lossy(bfutil.UpToNull(v)))
+	case f.Size == 1:
+		return strconv.Itoa(int(v[0]))
+	case f.Size == 2:
+		return strconv.Itoa(int(binary.LittleEndian.Uint16(v)))
+	case f.Size == 4:
+		return strconv.FormatUint(uint64(binary.LittleEndian.Uint32(v)), 10)
+	}
+	return ""
+}
@@ -0,0 +1,324 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"erupe-ce/common/bfutil"
+	"erupe-ce/common/stringsupport"
+	cfg "erupe-ce/config"
+	"erupe-ce/server/channelserver"
+	"erupe-ce/server/channelserver/compression/nullcomp"
+	"erupe-ce/server/channelserver/savedata"
+)
+
+// saveJSON is the JSON form of a decompressed save:
+//
+//	{
+//	  "mode": "ZZ",
+//	  "size": 150000,
+//	  "fields": {"name": "Hunter", "hr": 999, "kqf": "0000000000000000", ...},
+//	  "data": [{"offset": 4096, "hex": "0a0b0c"}, ...]
+//	}
+//
+// fields holds the known fields of the mode, named as in
+// savedata.Fields: the name as a string, fields of 1, 2 and 4 bytes
+// as little endian numbers and longer fields as hex, or "" when all zero.
+// data holds every other non-zero byte of the save as runs of hex, so
+// importing an unedited export rebuilds the save exactly. On import data is
+// written first and fields over it, so an edited field always takes effect; a
+// field left out keeps whatever data has for its bytes.
+type saveJSON struct {
+	Mode   string                     `json:"mode"`
+	Size   int                        `json:"size"`
+	Fields map[string]json.RawMessage `json:"fields"`
+	Data   []saveRun                  `json:"data"`
+}
+
+// saveRun is a run of bytes of a save outside its known fields.
+type saveRun struct {
+	Offset int    `json:"offset"`
+	Hex    string `json:"hex"`
+}
+
+// runGap is how many equal bytes two runs of data may be apart and still be
+// exported as one.
+const runGap = 16
+
+func runExport(args []string) {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	mode := fs.String("mode", "ZZ", "Client mode the save was written in, as in ClientMode")
+	out := fs.String("out", "", "File to write the JSON to, standard output if empty")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: savetool export [flags] SAVE")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(1)
+	}
+	m := parseModeFlag(fs, *mode)
+
+	var src source
+	data, err := src.load(fs.Arg(0))
+	if err != nil {
+		fatalf("%v", err)
+	}
+	export, err := exportSave(data, m, *mode)
+	if err != nil {
+		fatalf("%v", err)
+	}
+	enc, err := json.MarshalIndent(export, "", "  ")
+	if err != nil {
+		fatalf("%v", err)
+	}
+	enc = append(enc, '\n')
+	if *out == "" {
+		_, _ = os.Stdout.Write(enc)
+		return
+	}
+	if err := os.WriteFile(*out, enc, 0644); err != nil {
+		fatalf("%v", err)
+	}
+	fmt.Printf("Exported %d field(s) and %d data run(s) to %s\n", len(export.Fields), len(export.Data), *out)
+}
+
+func runImport(args []string) {
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	mode := fs.String("mode", "", "Client mode to write the save for, migrating it if exported from another; the export's if empty")
+	out := fs.String("out", "", "File to write the save to")
+	char := fs.Uint("char", 0, "Character to store the save for in the database instead of writing a file")
+	raw := fs.Bool("raw", false, "Write the file uncompressed")
+	force := fs.Bool("force", false, "Write the file even if the save fails validation")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: savetool import [flags] FILE.json")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+	if fs.NArg() != 1 || (*out == "") == (*char == 0) {
+		fmt.Fprintln(os.Stderr, "error: give a JSON file and one of --out or --char")
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	enc, err := os.ReadFile(fs.Arg(0))
+	if err != nil {
+		fatalf("%v", err)
+	}
+	var export saveJSON
+	if err := json.Unmarshal(enc, &export); err != nil {
+		fatalf("%s: %v", fs.Arg(0), err)
+	}
+	from := parseModeFlag(fs, export.Mode)
+	data, err := importSave(&export, from)
+	if err != nil {
+		fatalf("%s: %v", fs.Arg(0), err)
+	}
+	to := from
+	if *mode != "" {
+		to = parseModeFlag(fs, *mode)
+	}
+	if to != from {
+		if data, err = channelserver.MigrateSaveData(data, from, to); err != nil {
+			fatalf("migrate: %v", err)
+		}
+	}
+
+	if *char != 0 {
+		db, config, err := openDB()
+		if err != nil {
+			fatalf("%v", err)
+		}
+		defer func() { _ = db.Close() }()
+		repo, err := charRepo(db, config)
+		if err != nil {
+			fatalf("compression: %v", err)
+		}
+		if err := channelserver.StoreCharacterSave(repo, uint32(*char), data, to); err != nil {
+			fatalf("store save: %v", err)
+		}
+		fmt.Printf("Stored %d byte save for character %d\n", len(data), *char)
+		return
+	}
+
+	if err := channelserver.ValidateSaveData(data, to); err != nil && !*force {
+		fatalf("%v (--force to write it anyway)", err)
+	}
+	// Saves are stored compressed from G1 on.
+	if !*raw && to >= cfg.G1 {
+		if data, err = nullcomp.Compress(data); err != nil {
+			fatalf("compress: %v", err)
+		}
+	}
+	if err := os.WriteFile(*out, data, 0644); err != nil {
+		fatalf("%v", err)
+	}
+	fmt.Printf("Wrote %d byte save to %s\n", len(data), *out)
+}
+
+// exportSave converts a decompressed save to its JSON form. A field whose
+// bytes its JSON value cannot reproduce, such as a name that is not valid
+// Shift-JIS, is left to the data runs.
+func exportSave(data []byte, mode cfg.Mode, modeName string) (*saveJSON, error) {
+	export := &saveJSON{Mode: modeName, Size: len(data), Fields: make(map[string]json.RawMessage), Data: []saveRun{}}
+	fieldsOnly := make([]byte, len(data))
+	for _, f := range savedata.Fields(mode) {
+		if f.End() > len(data) {
+			continue
+		}
+		v, err := json.Marshal(fieldToJSON(f, data))
+		if err != nil {
+			return nil, err
+		}
+		if err := putField(f, fieldsOnly, v); err != nil {
+			continue
+		}
+		if !bytes.Equal(fieldsOnly[f.Offset:f.End()], data[f.Offset:f.End()]) && !nameMatches(f, fieldsOnly, data) {
+			clear(fieldsOnly[f.Offset:f.End()])
+			continue
+		}
+		export.Fields[f.Name] = v
+	}
+
+	// Export everything the fields do not reproduce as data.
+	for i := 0; i < len(data); i++ {
+		if data[i] == fieldsOnly[i] {
+			continue
+		}
+		start, end := i, i+1
+		for j := end; j < len(data) && j < end+runGap; j++ {
+			if data[j] != fieldsOnly[j] {
+				end = j + 1
+			}
+		}
+		export.Data = append(export.Data, saveRun{Offset: start, Hex: hex.EncodeToString(data[start:end])})
+		i = end
+	}
+	return export, nil
+}
+
+// nameMatches reports whether the name written to got matches the one in
+// want up to its terminator. Bytes after the terminator are kept as data.
+func nameMatches(f savedata.FieldInfo, got, want []byte) bool {
+	if f.Field != savedata.FieldName {
+		return false
+	}
+	n := len(bfutil.UpToNull(want[f.Offset:f.End()]))
+	return n < f.Size && bytes.Equal(got[f.Offset:f.Offset+n+1], want[f.Offset:f.Offset+n+1])
+}
+
+// importSave rebuilds a decompressed save from its JSON form.
+func importSave(export *saveJSON, mode cfg.Mode) ([]byte, error) {
+	if export.Size <= 0 {
+		return nil, fmt.Errorf("size %d", export.Size)
+	}
+	data := make([]byte, export.Size)
+	for _, r := range export.Data {
+		b, err := hex.DecodeString(r.Hex)
+		if err != nil {
+			return nil, fmt.Errorf("data at %d: %w", r.Offset, err)
+		}
+		if r.Offset < 0 || r.Offset+len(b) > len(data) {
+			return nil, fmt.Errorf("data at %d runs past the end of the save", r.Offset)
+		}
+		copy(data[r.Offset:], b)
+	}
+
+	known := make(map[string]savedata.FieldInfo)
+	for _, f := range savedata.Fields(mode) {
+		known[f.Name] = f
+	}
+	for name, v := range export.Fields {
+		f, ok := known[name]
+		if !ok {
+			return nil, fmt.Errorf("field %q is not known for mode %s", name, export.Mode)
+		}
+		if f.End() > len(data) {
+			return nil, fmt.Errorf("field %q is past the end of the save", name)
+		}
+		if err := putField(f, data, v); err != nil {
+			return nil, fmt.Errorf("field %q: %w", name, err)
+		}
+	}
+	return data, nil
+}
+
+// fieldToJSON returns the JSON value of a field of data.
+func fieldToJSON(f savedata.FieldInfo, data []byte) any {
+	v := data[f.Offset:f.End()]
+	switch {
+	case f.Field == savedata.FieldName:
+		return stringsupport.SJISToUTF8Lossy(bfutil.UpToNull(v))
+	case f.Size == 1:
+		return v[0]
+	case f.Size == 2:
+		return binary.LittleEndian.Uint16(v)
+	case f.Size == 4:
+		return binary.LittleEndian.Uint32(v)
+	}
+	if !slices.ContainsFunc(v, func(b byte) bool { return b != 0 }) {
+		return ""
+	}
+	return hex.EncodeToString(v)
+}
+
+// putField writes the JSON value of a field to data. A name is written with
+// its terminator, leaving the rest of the field as it is.
+func putField(f savedata.FieldInfo, data []byte, v json.RawMessage) error {
+	dst := data[f.Offset:f.End()]
+	if f.Field == savedata.FieldName {
+		var s string
+		if err := json.Unmarshal(v, &s); err != nil {
+			return err
+		}
+		b := stringsupport.UTF8ToSJIS(s)
+		if len(b) >= f.Size {
+			return fmt.Errorf("%q is longer than %d bytes in Shift-JIS", s, f.Size-1)
+		}
+		copy(dst, append(b, 0))
+		return nil
+	}
+	if f.Size > 4 {
+		var s string
+		if err := json.Unmarshal(v, &s); err != nil {
+			return err
+		}
+		if s == "" {
+			clear(dst)
+			return nil
+		}
+		b, err := hex.DecodeString(s)
+		if err != nil {
+			return err
+		}
+		if len(b) != f.Size {
+			return fmt.Errorf("%d bytes, want %d", len(b), f.Size)
+		}
+		copy(dst, b)
+		return nil
+	}
+	n, err := strconv.ParseUint(strings.TrimSpace(string(v)), 10, f.Size*8)
+	if err != nil {
+		return err
+	}
+	switch f.Size {
+	case 1:
+		dst[0] = uint8(n)
+	case 2:
+		binary.LittleEndian.PutUint16(dst, uint16(n))
+	case 4:
+		binary.LittleEndian.PutUint32(dst, uint32(n))
+	default:
+		return fmt.Errorf("no number form for a %d byte field", f.Size)
+	}
+	return nil
+}
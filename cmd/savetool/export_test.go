@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	cfg "erupe-ce/config"
+	"erupe-ce/server/channelserver"
+)
+
+func TestExportImport_RoundTrip(t *testing.T) {
+	for _, mode := range []cfg.Mode{cfg.S6, cfg.F5, cfg.G10, cfg.ZZ} {
+		data := channelserver.SaveFixture{Mode: mode, Name: "Hunter", HR: 999, GR: 120, Playtime: 3600, HouseTier: []byte{1, 2, 3, 4, 5}}.Build()
+		data[5000], data[5001], data[9000] = 0xAA, 0xBB, 0xCC // Unknown bytes
+		data[96] = 0x7F                                       // Past the name's terminator
+
+		export, err := exportSave(data, mode, "mode")
+		if err != nil {
+			t.Fatal(err)
+		}
+		enc, err := json.Marshal(export)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var decoded saveJSON
+		if err := json.Unmarshal(enc, &decoded); err != nil {
+			t.Fatal(err)
+		}
+		back, err := importSave(&decoded, mode)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(back, data) {
+			t.Errorf("mode %d: import of the export differs from the save", mode)
+		}
+		if string(decoded.Fields["name"]) != `"Hunter"` || len(decoded.Data) != 3 {
+			t.Errorf("mode %d: name %s, %d data runs, want 3", mode, decoded.Fields["name"], len(decoded.Data))
+		}
+	}
+}
+
+func TestImport_EditedFields(t *testing.T) {
+	data := channelserver.SaveFixture{Name: "Hunter", HR: 5}.Build()
+	export, err := exportSave(data, cfg.ZZ, "ZZ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	export.Fields["name"] = json.RawMessage(`"Renamed"`)
+	export.Fields["hr"] = json.RawMessage(`42`)
+	export.Fields["house_tier"] = json.RawMessage(`"0102030405"`)
+
+	back, err := importSave(export, cfg.ZZ)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edited := channelserver.SaveFixture{Name: "Renamed", HR: 42, HouseTier: []byte{1, 2, 3, 4, 5}}.Build()
+	if !bytes.Equal(back, edited) {
+		t.Error("edited import differs from a save built with the edits")
+	}
+}
+
+func TestImport_Errors(t *testing.T) {
+	base := func() *saveJSON {
+		e, _ := exportSave(channelserver.SaveFixture{Name: "Hunter"}.Build(), cfg.ZZ, "ZZ")
+		return e
+	}
+	tests := []struct {
+		name string
+		edit func(*saveJSON)
+		want string
+	}{
+		{"unknown field", func(e *saveJSON) { e.Fields["zenny"] = json.RawMessage(`1`) }, "not known"},
+		{"long name", func(e *saveJSON) { e.Fields["name"] = json.RawMessage(`"ABCDEFGHIJKL"`) }, "longer than"},
+		{"number range", func(e *saveJSON) { e.Fields["hr"] = json.RawMessage(`70000`) }, "hr"},
+		{"hex length", func(e *saveJSON) { e.Fields["kqf"] = json.RawMessage(`"0102"`) }, "want 8"},
+		{"data past end", func(e *saveJSON) { e.Data = append(e.Data, saveRun{Offset: e.Size - 1, Hex: "0102"}) }, "past the end"},
+		{"size", func(e *saveJSON) { e.Size = 0 }, "size"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := base()
+			tt.edit(e)
+			if _, err := importSave(e, cfg.ZZ); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("err = %v, want one containing %q", err, tt.want)
+			}
+		})
+	}
+}
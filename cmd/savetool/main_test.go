@@ -0,0 +1,82 @@
+package main
+
+import (
+	"encoding/binary"
+	"slices"
+	"strings"
+	"testing"
+
+	cfg "erupe-ce/config"
+	"erupe-ce/server/channelserver"
+	"erupe-ce/server/channelserver/savedata"
+)
+
+func TestDiffRegions(t *testing.T) {
+	a := []byte{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}
+	b := []byte{0, 9, 2, 3, 4, 5, 6, 9, 9, 9, 10, 11}
+	if got := diffRegions(a, b, 0); len(got) != 3 || got[0] != (region{1, 2}) || got[1] != (region{7, 9}) || got[2] != (region{10, 12}) {
+		t.Errorf("gap 0: %v", got)
+	}
+	if got := diffRegions(a, b, 6); len(got) != 1 || got[0] != (region{1, 12}) {
+		t.Errorf("gap 6: %v", got)
+	}
+	if got := diffRegions(a, a, 8); got != nil {
+		t.Errorf("equal saves: %v", got)
+	}
+}
+
+func TestWriteDiff(t *testing.T) {
+	before := channelserver.SaveFixture{Name: "Before", HR: 10, Playtime: 3600}.Build()
+	after := channelserver.SaveFixture{Name: "After", HR: 11, Playtime: 7200}.Build()
+	after[20] = 0xAA
+
+	var sb strings.Builder
+	writeDiff(&sb, before, after, savedata.Fields(cfg.ZZ), diffRegions(before, after, 8), 32)
+	out := sb.String()
+	for _, want := range []string{
+		"4 changed region(s)",
+		"0x000014-0x000014 (1 bytes): (unknown)",
+		`name: "Before" -> "After"`,
+		"playtime: 3600 -> 7200",
+		"hr: 10 -> 11",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("diff missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestHexRange(t *testing.T) {
+	data := make([]byte, 100)
+	binary.LittleEndian.PutUint16(data[10:], 0xBEEF)
+	if got := hexRange(data, region{10, 12}, 0); got != "ef be" {
+		t.Errorf("hexRange = %q", got)
+	}
+	if got := hexRange(data, region{0, 100}, 2); got != "00 00 ... (98 more)" {
+		t.Errorf("truncated hexRange = %q", got)
+	}
+	if got := hexRange(data, region{100, 110}, 0); got != "(past end)" {
+		t.Errorf("past end hexRange = %q", got)
+	}
+}
+
+func TestParseBoxes(t *testing.T) {
+	if got, err := parseBoxes("0, 3,9"); err != nil || !slices.Equal(got, []uint8{0, 3, 9}) {
+		t.Errorf("parseBoxes = %v, %v", got, err)
+	}
+	if got, err := parseBoxes(""); err != nil || len(got) != 0 {
+		t.Errorf("empty list = %v, %v", got, err)
+	}
+	if _, err := parseBoxes("1,x"); err == nil {
+		t.Error("bad page was accepted")
+	}
+}
+
+func TestParseCharIDs(t *testing.T) {
+	if got, err := parseCharIDs("12, 40"); err != nil || !slices.Equal(got, []uint32{12, 40}) {
+		t.Errorf("parseCharIDs = %v, %v", got, err)
+	}
+	if _, err := parseCharIDs("12,0"); err == nil {
+		t.Error("character 0 was accepted")
+	}
+}
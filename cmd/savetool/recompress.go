@@ -0,0 +1,45 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"slices"
+
+	"erupe-ce/server/channelserver"
+)
+
+func runRecompress(args []string) {
+	fs := flag.NewFlagSet("recompress", flag.ExitOnError)
+	column := fs.String("column", "", "Column to convert; every compressed column when empty")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: savetool recompress [flags]")
+		fmt.Fprintln(os.Stderr, "Rewrites stored blobs with the algorithms in the Compression section of config.json.")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+	columns := channelserver.CompressedColumns
+	if *column != "" {
+		if !slices.Contains(columns, *column) {
+			fatalf("%s is not one of %v", *column, columns)
+		}
+		columns = []string{*column}
+	}
+
+	db, config, err := openDB()
+	if err != nil {
+		fatalf("%v", err)
+	}
+	defer func() { _ = db.Close() }()
+	repo, err := charRepo(db, config)
+	if err != nil {
+		fatalf("compression: %v", err)
+	}
+	for _, c := range columns {
+		n, err := repo.Recompress(c)
+		if err != nil {
+			fatalf("%s: %v (%d row(s) converted)", c, err, n)
+		}
+		fmt.Printf("Converted %d %s row(s)\n", n, c)
+	}
+}
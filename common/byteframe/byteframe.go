@@ -24,6 +24,7 @@ type ByteFrame struct {
 	buf       []byte
 	byteOrder binary.ByteOrder
 	err       error // sticky error set on read overflow
+	pooled    bool  // buf came from Acquire and returns to the pool on Release
 }
 
 // NewByteFrame creates a new ByteFrame with valid default values.
@@ -68,6 +69,7 @@ func (b *ByteFrame) grow(size uint) {
 
 // wcheck checks if we have enough space to write.
 func (b *ByteFrame) wcheck(size uint) {
+	b.checkReleased()
 	if b.index+size > uint(len(b.buf)) {
 		b.grow(size)
 	}
@@ -86,7 +88,8 @@ func (b *ByteFrame) wprologue(size uint) {
 
 // rcheck checks if we have enough data to read.
 func (b *ByteFrame) rcheck(size uint) bool {
-	if b.index+size > uint(len(b.buf)) || b.index+size > b.usedSize+1 {
+	// Compare against what is left so a huge size cannot wrap the sum.
+	if size > uint(len(b.buf))-b.index || size > b.usedSize+1-b.index {
 		return false
 	}
 	return true
@@ -139,11 +142,13 @@ func (b *ByteFrame) Seek(offset int64, whence int) (int64, error) {
 
 // Data returns the data from the buffer start up to the max index.
 func (b *ByteFrame) Data() []byte {
+	b.checkReleased()
 	return b.buf[:b.usedSize]
 }
 
 // DataFromCurrent returns the data from the current index up to the max index.
 func (b *ByteFrame) DataFromCurrent() []byte {
+	b.checkReleased()
 	return b.buf[b.index:b.usedSize]
 }
 
@@ -421,6 +426,9 @@ func (b *ByteFrame) ReadBytes(size uint) (x []byte) {
 
 // ReadNullTerminatedBytes reads bytes up to a NULL terminator.
 func (b *ByteFrame) ReadNullTerminatedBytes() []byte {
+	if b.err != nil {
+		return nil
+	}
 	tmpData := b.DataFromCurrent()
 	tmp := bytes.SplitN(tmpData, []byte{0x00}, 2)[0]
 
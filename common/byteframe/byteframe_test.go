@@ -460,6 +460,22 @@ func TestByteFrame_ReadOverflowSetsError(t *testing.T) {
 	}
 }
 
+func TestByteFrame_ReadBytesHugeSizeSetsError(t *testing.T) {
+	bf := NewByteFrameFromBytes([]byte{0x00, 0x01, 0x02})
+	bf.ReadUint8()
+
+	// A size that wraps index+size around must not slip past the bounds check.
+	if got := bf.ReadBytes(^uint(0)); got != nil {
+		t.Errorf("ReadBytes(max) = % X, want nil", got)
+	}
+	if !errors.Is(bf.Err(), ErrReadOverflow) {
+		t.Errorf("Err() = %v, want ErrReadOverflow", bf.Err())
+	}
+	if got := bf.ReadNullTerminatedBytes(); got != nil {
+		t.Errorf("ReadNullTerminatedBytes() after overflow = % X, want nil", got)
+	}
+}
+
 func TestByteFrame_SequentialWrites(t *testing.T) {
 	bf := NewByteFrame()
 	bf.WriteUint8(0x01)
@@ -1,4 +1,7 @@
 // Package byteframe provides a seekable, growable byte buffer for reading and
 // writing binary data in big-endian or little-endian byte order. It is the
 // primary serialization primitive used throughout the Erupe network layer.
+//
+// Acquire and Release reuse frame buffers through a pool for hot paths that
+// build a packet and copy it out, such as queueing packets to sessions.
 package byteframe
@@ -0,0 +1,67 @@
+package byteframe
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+// FuzzReader runs a sequence of reads, chosen by ops, over data. Reads past
+// the end must set Err and return zero values rather than panic, and must
+// never consume more than data holds:
+//
+//	go test ./common/byteframe -run '^$' -fuzz FuzzReader
+func FuzzReader(f *testing.F) {
+	f.Add([]byte{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12}, []byte("\x00\x01\x02\x03\x04\x05\x06\x07\x08hello\x00world"))
+	f.Add([]byte{11, 11, 12}, []byte{0xFF, 0xFF, 0xFF})
+	f.Add([]byte{13, 11}, []byte{})
+
+	f.Fuzz(func(t *testing.T, ops []byte, data []byte) {
+		bf := NewByteFrameFromBytes(data)
+		for _, op := range ops {
+			before := bf.Index()
+			failed := bf.Err() != nil
+			switch op % 14 {
+			case 0:
+				bf.ReadUint8()
+			case 1:
+				bf.ReadBool()
+			case 2:
+				bf.ReadUint16()
+			case 3:
+				bf.ReadUint32()
+			case 4:
+				bf.ReadUint64()
+			case 5:
+				bf.ReadInt16()
+			case 6:
+				bf.ReadInt32()
+			case 7:
+				bf.ReadFloat32()
+			case 8:
+				bf.ReadFloat64()
+			case 9:
+				bf.SetLE()
+			case 10:
+				bf.SetBE()
+			case 11:
+				// Sizes read from the packet are untrusted, including ones
+				// that underflowed before reaching ReadBytes.
+				bf.ReadBytes(uint(bf.ReadUint8()) - 1)
+			case 12:
+				bf.ReadNullTerminatedBytes()
+			case 13:
+				_, _ = bf.Seek(int64(int8(op)), io.SeekCurrent)
+			}
+			if bf.Index() > uint(len(data)) {
+				t.Fatalf("op %d moved the index to %d of %d bytes", op%14, bf.Index(), len(data))
+			}
+			if failed && op%14 != 13 && bf.Index() != before {
+				t.Fatalf("op %d read after an error", op%14)
+			}
+		}
+		if !bytes.Equal(bf.Data(), data) {
+			t.Fatalf("Data() = % X, want % X", bf.Data(), data)
+		}
+	})
+}
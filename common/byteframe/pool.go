@@ -0,0 +1,57 @@
+package byteframe
+
+import (
+	"encoding/binary"
+	"sync"
+)
+
+// maxPooledSize is the largest buffer returned to the pool. Larger buffers,
+// such as those for full savedata, are left to the garbage collector so the
+// pool does not pin rarely needed memory.
+const maxPooledSize = 64 * 1024
+
+var bufPool = sync.Pool{
+	New: func() any {
+		buf := make([]byte, 512)
+		return &buf
+	},
+}
+
+// Acquire returns an empty big endian ByteFrame backed by a pooled buffer.
+// The caller must call Release once done with it. Slices returned by Data,
+// DataFromCurrent and the Read methods share the pooled buffer and must be
+// copied if they are needed after Release.
+func Acquire() *ByteFrame {
+	buf := *bufPool.Get().(*[]byte)
+	return &ByteFrame{
+		buf:       buf[:cap(buf)],
+		byteOrder: binary.BigEndian,
+		pooled:    true,
+	}
+}
+
+// Release returns the buffer of a ByteFrame from Acquire to the pool. The
+// ByteFrame must not be used afterwards; doing so panics rather than
+// corrupting the buffer's next user.
+func (b *ByteFrame) Release() {
+	if !b.pooled {
+		panic("byteframe: Release of a ByteFrame not from Acquire")
+	}
+	if b.buf == nil {
+		panic("byteframe: ByteFrame released twice")
+	}
+	if cap(b.buf) <= maxPooledSize {
+		buf := b.buf[:0]
+		bufPool.Put(&buf)
+	}
+	b.buf = nil
+	b.index = 0
+	b.usedSize = 0
+}
+
+// checkReleased panics if b is a pooled ByteFrame that was already released.
+func (b *ByteFrame) checkReleased() {
+	if b.pooled && b.buf == nil {
+		panic("byteframe: use of released ByteFrame")
+	}
+}
@@ -0,0 +1,96 @@
+package byteframe
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestAcquireRelease(t *testing.T) {
+	bf := Acquire()
+	bf.WriteUint32(0xDEADBEEF)
+	bf.WriteUint16(0x1234)
+	want := []byte{0xDE, 0xAD, 0xBE, 0xEF, 0x12, 0x34}
+	if !bytes.Equal(bf.Data(), want) {
+		t.Fatalf("Data() = %x, want %x", bf.Data(), want)
+	}
+	bf.Release()
+
+	// A reused buffer starts empty regardless of its previous contents.
+	bf = Acquire()
+	defer bf.Release()
+	if len(bf.Data()) != 0 {
+		t.Errorf("reacquired frame has %d bytes of data, want 0", len(bf.Data()))
+	}
+	bf.WriteUint8(0x01)
+	if !bytes.Equal(bf.Data(), []byte{0x01}) {
+		t.Errorf("Data() = %x, want 01", bf.Data())
+	}
+}
+
+func TestAcquireGrowsPastPooledSize(t *testing.T) {
+	bf := Acquire()
+	bf.WriteBytes(make([]byte, maxPooledSize+1))
+	if len(bf.Data()) != maxPooledSize+1 {
+		t.Errorf("len(Data()) = %d, want %d", len(bf.Data()), maxPooledSize+1)
+	}
+	bf.Release()
+}
+
+func TestReleaseGuards(t *testing.T) {
+	tests := []struct {
+		name string
+		fn   func()
+	}{
+		{"double release", func() {
+			bf := Acquire()
+			bf.Release()
+			bf.Release()
+		}},
+		{"write after release", func() {
+			bf := Acquire()
+			bf.Release()
+			bf.WriteUint8(1)
+		}},
+		{"data after release", func() {
+			bf := Acquire()
+			bf.Release()
+			_ = bf.Data()
+		}},
+		{"release unpooled", func() {
+			NewByteFrame().Release()
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Error("expected panic")
+				}
+			}()
+			tt.fn()
+		})
+	}
+}
+
+func BenchmarkNewByteFrame(b *testing.B) {
+	payload := make([]byte, 1024)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		bf := NewByteFrame()
+		bf.WriteUint16(0x0001)
+		bf.WriteBytes(payload)
+		_ = bytes.Clone(bf.Data())
+	}
+}
+
+func BenchmarkAcquireByteFrame(b *testing.B) {
+	payload := make([]byte, 1024)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		bf := Acquire()
+		bf.WriteUint16(0x0001)
+		bf.WriteBytes(payload)
+		_ = bytes.Clone(bf.Data())
+		bf.Release()
+	}
+}
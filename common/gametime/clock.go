@@ -0,0 +1,95 @@
+package gametime
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Clock is a source of the current time.
+type Clock interface {
+	Now() time.Time
+}
+
+// SystemClock reads the system clock.
+type SystemClock struct{}
+
+// Now returns time.Now().
+func (SystemClock) Now() time.Time { return time.Now() }
+
+// FakeClock is a Clock that only moves when set or advanced, for tests.
+type FakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+// NewFakeClock creates a FakeClock stopped at t.
+func NewFakeClock(t time.Time) *FakeClock {
+	return &FakeClock{now: t}
+}
+
+// Now returns the time the clock is stopped at.
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// Set stops the clock at t.
+func (c *FakeClock) Set(t time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = t
+}
+
+// Advance moves the clock forward by d.
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// clockBox lets clocks of different types share one atomic.Value.
+type clockBox struct{ Clock }
+
+var (
+	clock  atomic.Value // clockBox
+	offset atomic.Int64 // Time warp, in nanoseconds
+)
+
+func init() {
+	clock.Store(clockBox{SystemClock{}})
+}
+
+// SetClock replaces the clock behind Now and every helper in this package,
+// returning a function that restores the previous one. Tests use it with a
+// FakeClock to control time-based behavior:
+//
+//	clk := gametime.NewFakeClock(start)
+//	defer gametime.SetClock(clk)()
+func SetClock(c Clock) (restore func()) {
+	prev := clock.Swap(clockBox{c})
+	return func() { clock.Store(prev) }
+}
+
+// Warp moves the game clock d ahead of its source, on top of any earlier
+// warp, so daily and weekly resets, events and timers can be fast-forwarded
+// on a development server. A negative d moves it back.
+func Warp(d time.Duration) {
+	offset.Add(int64(d))
+}
+
+// ResetWarp undoes every Warp.
+func ResetWarp() {
+	offset.Store(0)
+}
+
+// WarpOffset returns how far the game clock has been warped.
+func WarpOffset() time.Duration {
+	return time.Duration(offset.Load())
+}
+
+// Now returns the current time from the clock, including any warp.
+func Now() time.Time {
+	return clock.Load().(clockBox).Now().Add(WarpOffset())
+}
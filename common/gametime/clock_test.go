@@ -0,0 +1,52 @@
+package gametime
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSetClock(t *testing.T) {
+	// Wednesday 2026-03-04 23:30 UTC is Thursday 08:30 in JST.
+	clk := NewFakeClock(time.Date(2026, 3, 4, 23, 30, 0, 0, time.UTC))
+	defer SetClock(clk)()
+
+	if got := Adjusted(); got.Day() != 5 || got.Hour() != 8 || got.Minute() != 30 {
+		t.Errorf("Adjusted() = %v, want 2026-03-05 08:30 JST", got)
+	}
+	if got := WeekStart(); got.Day() != 2 || got.Weekday() != time.Monday {
+		t.Errorf("WeekStart() = %v, want Monday 2026-03-02", got)
+	}
+
+	clk.Advance(4 * 24 * time.Hour)
+	if got := WeekStart(); got.Day() != 9 {
+		t.Errorf("WeekStart() after 4 days = %v, want Monday 2026-03-09", got)
+	}
+}
+
+func TestSetClock_Restore(t *testing.T) {
+	restore := SetClock(NewFakeClock(time.Unix(0, 0)))
+	restore()
+	if d := time.Since(Now()); d < -time.Second || d > time.Second {
+		t.Errorf("Now() after restore is %v from the system clock", d)
+	}
+}
+
+func TestWarp(t *testing.T) {
+	start := time.Date(2026, 3, 4, 0, 0, 0, 0, time.UTC)
+	defer SetClock(NewFakeClock(start))()
+	defer ResetWarp()
+
+	Warp(36 * time.Hour)
+	Warp(-12 * time.Hour)
+	if got := WarpOffset(); got != 24*time.Hour {
+		t.Errorf("WarpOffset() = %v, want 24h", got)
+	}
+	if got := Now(); !got.Equal(start.Add(24 * time.Hour)) {
+		t.Errorf("Now() = %v, want a day after %v", got, start)
+	}
+
+	ResetWarp()
+	if got := Now(); !got.Equal(start) {
+		t.Errorf("Now() after ResetWarp = %v, want %v", got, start)
+	}
+}
@@ -1,4 +1,6 @@
 // Package gametime provides time helpers anchored to the JST (UTC+9) timezone
 // used by Monster Hunter Frontier's game clock, including weekly reset
-// boundaries and the in-game absolute time cycle.
+// boundaries and the in-game absolute time cycle. Every helper reads a
+// replaceable Clock, so tests can fix the time and development servers can
+// warp it forward.
 package gametime
@@ -6,13 +6,13 @@ import (
 
 // Adjusted returns the current time in JST (UTC+9), the timezone used by MHF.
 func Adjusted() time.Time {
-	baseTime := time.Now().In(time.FixedZone("UTC+9", 9*60*60))
+	baseTime := Now().In(time.FixedZone("UTC+9", 9*60*60))
 	return time.Date(baseTime.Year(), baseTime.Month(), baseTime.Day(), baseTime.Hour(), baseTime.Minute(), baseTime.Second(), baseTime.Nanosecond(), baseTime.Location())
 }
 
 // Midnight returns today's midnight (00:00) in JST.
 func Midnight() time.Time {
-	baseTime := time.Now().In(time.FixedZone("UTC+9", 9*60*60))
+	baseTime := Now().In(time.FixedZone("UTC+9", 9*60*60))
 	return time.Date(baseTime.Year(), baseTime.Month(), baseTime.Day(), 0, 0, 0, 0, baseTime.Location())
 }
 
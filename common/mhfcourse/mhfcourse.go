@@ -3,6 +3,7 @@ package mhfcourse
 import (
 	"math"
 	"sort"
+	"strings"
 	"time"
 )
 
@@ -113,3 +114,15 @@ func GetCourseStruct(rights uint32, defaultCourses []uint16) ([]Course, uint32)
 	}
 	return resp, rights
 }
+
+// FindCourse returns the course with the given alias, compared case-insensitively.
+func FindCourse(name string) (Course, bool) {
+	for _, course := range Courses() {
+		for _, alias := range course.Aliases() {
+			if strings.EqualFold(name, alias) {
+				return course, true
+			}
+		}
+	}
+	return Course{}, false
+}
@@ -300,6 +300,29 @@ func TestCourse_ValueMatchesPowerOfTwo(t *testing.T) {
 	}
 }
 
+func TestFindCourse(t *testing.T) {
+	tests := []struct {
+		name   string
+		wantID uint16
+		wantOK bool
+	}{
+		{"HunterLife", 2, true},
+		{"hl", 2, true},
+		{"EXTRA", 3, true},
+		{"NetCafe", 26, true},
+		{"Unknown", 0, false},
+		{"", 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := FindCourse(tt.name)
+			if ok != tt.wantOK || got.ID != tt.wantID {
+				t.Errorf("FindCourse(%q) = (%d, %v), want (%d, %v)", tt.name, got.ID, ok, tt.wantID, tt.wantOK)
+			}
+		})
+	}
+}
+
 func BenchmarkCourse_Value(b *testing.B) {
 	c := Course{ID: 15}
 	b.ResetTimer()
@@ -1,9 +1,13 @@
 package config
 
 import (
+	"errors"
 	"fmt"
 	"net"
+	"path/filepath"
+	"slices"
 	"strings"
+	"sync/atomic"
 
 	"github.com/spf13/viper"
 )
@@ -62,30 +66,61 @@ func (m Mode) String() string {
 	return versionStrings[m]
 }
 
+// ParseMode returns the Mode for a ClientMode string such as "ZZ" or "G10.1",
+// ignoring case.
+func ParseMode(s string) (Mode, bool) {
+	for i, v := range versionStrings {
+		if strings.ToUpper(s) == v {
+			return Mode(i + 1), true
+		}
+	}
+	return 0, false
+}
+
 // Config holds the global server-wide config.
 type Config struct {
-	Host                   string `mapstructure:"Host"`
-	BinPath                string `mapstructure:"BinPath"`
-	Language               string
-	DisableSoftCrash       bool     // Disables the 'Press Return to exit' dialog allowing scripts to reboot the server automatically
-	HideLoginNotice        bool     // Hide the Erupe notice on login
-	LoginNotices           []string // MHFML string of the login notices displayed
-	PatchServerManifest    string   // Manifest patch server override
-	PatchServerFile        string   // File patch server override
-	DeleteOnSaveCorruption bool     // Attempts to save corrupted data will flag the save for deletion
-	ClientMode             string
-	RealClientMode         Mode
-	QuestCacheExpiry       int    // Number of seconds to keep quest data cached
-	CommandPrefix          string // The prefix for commands
-	AutoCreateAccount      bool   // Automatically create accounts if they don't exist
-	LoopDelay              int    // Delay in milliseconds between each loop iteration
-	DefaultCourses         []uint16
-	EarthStatus            int32
-	EarthID                int32
-	EarthMonsters          []int32
-	SaveDumps              SaveDumpOptions
-	Screenshots            ScreenshotsOptions
-	Capture                CaptureOptions
+	ConfigVersion        int    // Layout of this file, upgraded automatically on startup; see LatestConfigVersion
+	Host                 string `mapstructure:"Host"` // IPv4 address or name advertised to clients; worlds and channels can only be reached over IPv4
+	HostV6               string // IPv6 address advertised to clients that connect over IPv6, where the protocol carries an address as text
+	BinPath              string `mapstructure:"BinPath"`
+	Language             string
+	DisableSoftCrash     bool     // Disables the 'Press Return to exit' dialog allowing scripts to reboot the server automatically
+	HideLoginNotice      bool     // Hide the Erupe notice on login
+	LoginNotices         []string // MHFML string of the login notices displayed
+	PatchServerManifest  string   // Manifest patch server override
+	PatchServerFile      string   // File patch server override
+	ClientMode           string
+	RealClientMode       Mode
+	QuestCacheExpiry     int    // Number of seconds to keep quest data cached
+	QuestCacheMaxEntries int    // Maximum number of quests kept cached, 0 for unlimited
+	QuestCacheMaxBytes   int    // Maximum total bytes of cached quest data, 0 for unlimited
+	CommandPrefix        string // The prefix for commands
+	AutoCreateAccount    bool   // Automatically create accounts if they don't exist
+	LoopDelay            int    // Delay in milliseconds between each loop iteration
+	DefaultCourses       []uint16
+	EarthStatus          int32
+	EarthID              int32
+	EarthMonsters        []int32
+	SaveDumps            SaveDumpOptions
+	SaveCache            SaveCacheOptions
+	Compression          CompressionOptions
+	Backup               BackupOptions
+	Scheduler            SchedulerOptions
+	Screenshots          ScreenshotsOptions
+	Capture              CaptureOptions
+	Tracing              TracingOptions
+	Logging              LoggingOptions
+	ErrorReporting       ErrorReportingOptions
+	SessionEvents        SessionEventOptions
+	Analytics            AnalyticsOptions
+	Console              ConsoleOptions
+	Shutdown             ShutdownOptions
+	ProxyProtocol        ProxyProtocolOptions
+	ConnectionLimits     ConnectionLimitOptions
+	PacketCrypto         PacketCryptoOptions
+	Proxy                ProxyOptions
+	Cluster              ClusterOptions
+	Federation           FederationOptions
 
 	DebugOptions    DebugOptions
 	GameplayOptions GameplayOptions
@@ -97,12 +132,182 @@ type Config struct {
 	API             API
 	Channel         Channel
 	Entrance        Entrance
+
+	upgrades []string                   // Notes from upgrading the file to LatestConfigVersion on load
+	live     atomic.Pointer[Reloadable] // Running values of the reloadable settings; see Live
+}
+
+// Upgrades returns a note for each change made to the config file when it was
+// upgraded to LatestConfigVersion on load, or nil if it was already current.
+func (c *Config) Upgrades() []string {
+	return c.upgrades
 }
 
 type SaveDumpOptions struct {
+	Enabled       bool
+	RawEnabled    bool
+	OutputDir     string
+	Retention     int // Dumps of each kind kept per character; 0 keeps every dump
+	MaxTotalMB    int // Cap on the size of all dumps, pruning the oldest first; 0 disables
+	CompressAfter int // Dumps of each kind kept uncompressed per character before older ones are gzipped; 0 disables
+}
+
+// SaveCacheOptions keeps online characters' savedata in memory and writes it
+// to the database in the background.
+type SaveCacheOptions struct {
+	Enabled       bool
+	FlushInterval int    // Seconds between background flushes of changed savedata
+	JournalDir    string // Directory where unflushed savedata is journaled, empty to disable journaling
+}
+
+// CompressionOptions selects the algorithm each compressed character blob is
+// stored with in the database: null, the client's own format, or zstd, which
+// is smaller and converted back to the client's format when read.
+type CompressionOptions struct {
+	Savedata  string // Algorithm for characters.savedata
+	Platedata string // Algorithm for characters.platedata
+	Platebox  string // Algorithm for characters.platebox
+}
+
+// BackupOptions schedules archives of every character's saves and related
+// rows, kept in a local directory or an S3-compatible bucket.
+type BackupOptions struct {
+	Enabled   bool
+	Interval  int    // Hours between backups
+	Retention int    // Archives kept, deleting the oldest; 0 keeps every archive
+	Dir       string // Directory archives are written to when no S3 bucket is set
+	S3        BackupS3Options
+}
+
+// SchedulerOptions override when the server's background jobs run. Jobs
+// not listed run on their defaults.
+type SchedulerOptions struct {
+	Jobs map[string]string // Schedule per job name: a cron expression such as "30 4 * * *", @hourly, @daily, @weekly, "@every 6h", or "off"
+}
+
+// BackupS3Options stores backup archives in an S3-compatible bucket.
+type BackupS3Options struct {
+	Endpoint      string // Base URL of the service, e.g. https://s3.us-east-1.amazonaws.com or a MinIO server
+	Region        string
+	Bucket        string // Bucket archives are stored in, empty to use Dir
+	Prefix        string // Prepended to archive keys, e.g. erupe/
+	AccessKey     string // Falls back to the AWS_ACCESS_KEY_ID environment variable
+	SecretKey     string // Falls back to the AWS_SECRET_ACCESS_KEY environment variable
+	AccessKeyFile string // Secret reference AccessKey is read from instead, see LoadSecret
+	SecretKeyFile string // Secret reference SecretKey is read from instead, see LoadSecret
+}
+
+// ShutdownOptions control how players are drained when the server is stopped.
+type ShutdownOptions struct {
+	Countdown    int // Seconds players are warned in chat before being logged out
+	QuestTimeout int // Seconds to wait after the countdown for players on quests to return, 0 to not wait
+}
+
+// ProxyProtocolOptions accept HAProxy PROXY protocol headers on the sign,
+// entrance and channel listeners, so clients behind a load balancer or TCP
+// proxy are seen with their own address.
+type ProxyProtocolOptions struct {
+	Enabled        bool
+	TrustedProxies []string // Addresses or CIDR ranges allowed to send headers; required
+	HeaderTimeout  int      // Seconds to wait for a proxy to send its header
+}
+
+// ConnectionLimitOptions protect the sign, entrance and channel listeners
+// from scanners and floods by limiting connections per source IP.
+type ConnectionLimitOptions struct {
+	Enabled          bool
+	MaxPerIP         int // Open connections from one IP across all listeners, 0 for unlimited
+	MaxPerMinute     int // New connections from one IP per minute, 0 for unlimited
+	DenylistAfter    int // Refused connections from one IP within a minute before it is denylisted, 0 to never denylist
+	DenylistSeconds  int // How long a denylisted IP is refused
+	HandshakeTimeout int // Seconds a sign or entrance client has to send its opening bytes, 0 to wait forever
+}
+
+// PacketCryptoOptions select the cipher of packet bodies on each listener,
+// so patched or debug client builds can connect without a code change.
+type PacketCryptoOptions struct {
+	Cipher   string // Cipher of every listener: mhf, the retail cipher, or none for clients built without encryption
+	KeyFile  string // Replacement mhf tables for patched clients: a 256-byte S-box then a 256-byte shared key
+	Sign     string // Cipher of the sign server, empty for Cipher
+	Entrance string // Cipher of the entrance server, empty for Cipher
+	Channel  string // Cipher of the channel servers, empty for Cipher
+}
+
+// ProxyOptions runs Erupe as a recording man-in-the-middle between the
+// client and another server, to capture its behaviour for reference.
+type ProxyOptions struct {
+	Enabled        bool
+	OutputDir      string         // Directory for .mhfr recordings of proxied sessions
+	ExcludeOpcodes []uint16       // Opcodes left out of recordings
+	Compression    string         // "gzip" or "zstd" to compress recordings, "none" or empty to not
+	Routes         []ProxyRoute   // Listeners and the upstream servers they forward to
+	Rewrites       []ProxyRewrite // Byte replacements applied to packets in flight
+}
+
+// ProxyRoute forwards one local port to an upstream server.
+type ProxyRoute struct {
+	Server      string // "sign", "entrance" or "channel", which sets the handshake and the cipher
+	Port        uint16 // Local port the client connects to
+	Upstream    string // host:port of the server forwarded to
+	PassThrough bool   // Relay the bytes untouched, without recording, hooks or rewrites
+}
+
+// ProxyRewrite replaces bytes in proxied packets, such as an address the
+// upstream advertises that must point back at the proxy.
+type ProxyRewrite struct {
+	Server    string   // Route kind the rewrite applies to, empty for all
+	Direction string   // "client" for packets from the client, "server" for packets from the upstream, empty for both
+	Opcodes   []uint16 // Opcodes rewritten, empty for every packet
+	Find      string   // Hex of the bytes replaced
+	Replace   string   // Hex of the replacement
+}
+
+// ClusterOptions splits the channels across several Erupe processes or
+// hosts sharing the database, which reach each other over a bus for world
+// chat, searches, mail notices and disconnects.
+type ClusterOptions struct {
 	Enabled    bool
-	RawEnabled bool
-	OutputDir  string
+	Node       string   // Name of this process in the logs of the others, empty for the host name
+	Channels   []uint16 // Ports of the channels this process runs, empty for every enabled channel
+	Listen     string   // host:port the bus accepts the other processes on
+	Peers      []string // host:port of the buses of the other processes
+	Secret     string   // Shared secret every process must present
+	SecretFile string   // Secret reference Secret is read from instead, see LoadSecret
+}
+
+// FederationOptions links this server with allied community servers, each
+// keeping its own database, to share world chat, who is online and guild
+// announcements.
+type FederationOptions struct {
+	Enabled  bool
+	Name     string            // Name of this server, shown on the allied servers and used as their Peer
+	Listen   string            // host:port the allied servers connect to
+	Allies   []FederationAlly  // Allied servers, which must list this one in turn
+	Chat     bool              // Share world and Mezeporta chat
+	Presence bool              // Share the names of the players online
+	Guilds   []FederationGuild // Guilds sharing their announcements with a guild on an allied server
+}
+
+// FederationAlly is an allied server. Each pair of allies shares a secret of
+// its own, so no ally can speak for another.
+type FederationAlly struct {
+	Name       string // Federation.Name of the allied server
+	Address    string // host:port of its Federation.Listen
+	Secret     string // Secret shared with this ally only; it lists the same one for this server
+	SecretFile string // Secret reference Secret is read from instead, see LoadSecret
+}
+
+// FederationGuild links a guild to one on an allied server. The news posts
+// of each are shown to the online members of the other.
+type FederationGuild struct {
+	GuildID     uint32 // Guild on this server
+	Peer        string // Name of the allied server
+	PeerGuildID uint32 // Guild on the allied server
+}
+
+// RunsChannel reports whether this process runs the channel on port.
+func (c *ClusterOptions) RunsChannel(port uint16) bool {
+	return !c.Enabled || len(c.Channels) == 0 || slices.Contains(c.Channels, port)
 }
 
 type ScreenshotsOptions struct {
@@ -115,12 +320,101 @@ type ScreenshotsOptions struct {
 
 // CaptureOptions controls protocol packet capture recording.
 type CaptureOptions struct {
-	Enabled         bool     // Enable packet capture
-	OutputDir       string   // Directory for .mhfr capture files
-	ExcludeOpcodes  []uint16 // Opcodes to exclude from capture (e.g., ping, nop, position)
-	CaptureSign     bool     // Capture sign server sessions
-	CaptureEntrance bool     // Capture entrance server sessions
-	CaptureChannel  bool     // Capture channel server sessions
+	Enabled           bool     // Enable packet capture
+	OutputDir         string   // Directory for .mhfr capture files
+	ExcludeOpcodes    []uint16 // Opcodes to exclude from capture (e.g., ping, nop, position)
+	CaptureSign       bool     // Capture sign server sessions
+	CaptureEntrance   bool     // Capture entrance server sessions
+	CaptureChannel    bool     // Capture channel server sessions
+	RetentionDays     int      // Days capture files are kept before being deleted, 0 to keep forever
+	Compression       string   // "gzip" or "zstd" to compress the packets of capture files, "none" or empty to not
+	RotateSizeMB      int      // Megabytes of packets written to a capture file before the session continues in the next, 0 for no limit
+	RotateMinutes     int      // Minutes a capture file is written to before the session continues in the next, 0 for no limit
+	MaxTotalMB        int      // Cap on the size of all capture files in OutputDir, deleting the oldest first; 0 disables
+	CharIDs           []uint32 // Only keep the captures of sessions of these characters; with Usernames empty too, every session is kept
+	Usernames         []string // Only keep the captures of sessions of these accounts
+	EncryptionKey     string   // Secret the packet payloads of capture files are encrypted with, empty to write them in plaintext
+	EncryptionKeyFile string   // Secret reference EncryptionKey is read from instead, see LoadSecret
+}
+
+// Targeted reports whether only the captures of some sessions are kept.
+func (c *CaptureOptions) Targeted() bool {
+	return len(c.CharIDs) > 0 || len(c.Usernames) > 0
+}
+
+// Targets reports whether a session of the account username, with the
+// characters charIDs, is kept. Without CharIDs and Usernames every session
+// is. An empty username or no charIDs stand for an unknown identity.
+func (c *CaptureOptions) Targets(username string, charIDs ...uint32) bool {
+	if !c.Targeted() {
+		return true
+	}
+	if username != "" && slices.Contains(c.Usernames, username) {
+		return true
+	}
+	for _, id := range charIDs {
+		if slices.Contains(c.CharIDs, id) {
+			return true
+		}
+	}
+	return false
+}
+
+// TracingOptions exports OpenTelemetry spans for packet handlers, database
+// queries and broadcasts.
+type TracingOptions struct {
+	Enabled     bool
+	Exporter    string  // "otlp" to send spans to an OTLP/HTTP collector such as Jaeger or Tempo, "stdout" to print them
+	Endpoint    string  // host:port of the OTLP/HTTP collector
+	Insecure    bool    // Send spans to the collector over plain HTTP
+	SampleRatio float64 // Fraction of traces recorded, from 0 to 1
+	ServiceName string  // Service name spans are reported under
+}
+
+// LoggingOptions configures where and how much each subsystem logs.
+type LoggingOptions struct {
+	Level      string               // Minimum level logged: "debug", "info", "warn" or "error"
+	Format     string               // "console" for human-readable lines, "json" for one object per line
+	Output     string               // "stderr", "stdout" or the path of a log file
+	MaxSize    int                  // Megabytes written to a log file before it is rotated, 0 for no limit
+	MaxAge     int                  // Hours a log file is written to before it is rotated, 0 for no limit
+	MaxBackups int                  // Number of rotated files kept per log file, 0 to keep all
+	Subsystems map[string]LogTarget // Overrides by subsystem: "sign", "entrance", "channel", "api", "discord", "capture"
+}
+
+// LogTarget overrides the logging of one subsystem. Empty fields inherit
+// the top-level Logging settings.
+type LogTarget struct {
+	Level  string
+	Format string
+	Output string
+}
+
+// ErrorReportingOptions sends handler panics and error logs to a
+// Sentry-compatible error tracker.
+type ErrorReportingOptions struct {
+	DSN         string  // Sentry-compatible DSN of the project to report to, empty to disable
+	DSNFile     string  // Secret reference DSN is read from instead, see LoadSecret
+	Environment string  // Environment events are tagged with, such as "production" or "staging"
+	SampleRate  float64 // Fraction of events sent, from 0 to 1
+}
+
+// SessionEventOptions records session lifecycle events in the database.
+type SessionEventOptions struct {
+	Enabled       bool
+	RetentionDays int // Days events are kept before being deleted, 0 to keep forever
+}
+
+// AnalyticsOptions keeps daily rollups of the economy and activity in the
+// database.
+type AnalyticsOptions struct {
+	Enabled bool
+}
+
+// ConsoleOptions serves the interactive admin console on a local socket.
+type ConsoleOptions struct {
+	Enabled bool
+	Socket  string // Path of the Unix socket the console listens on
 }
 
 // DebugOptions holds various debug/temporary options for use while developing Erupe.
@@ -139,6 +433,24 @@ type DebugOptions struct {
 	AutoQuestBackport   bool   // Automatically backport quest files
 	ProxyPort           uint16 // Forces the game to connect to a channel server proxy
 	CapLink             CapLinkOptions
+	Pprof               bool   // Serve pprof profiles and runtime snapshots under /debug/
+	PprofAddress        string // Listen address for the debug endpoints; keep it on localhost
+	CrashReportDir      string // Directory for handler panic reports; empty logs them only
+	CrashReportPackets  int    // Number of recent inbound packets kept per session for crash reports
+	TimeWarp            bool   // Allow the admin console to move the game clock; never enable in production
+	LagSimulation       bool   // Allow the admin console to delay packets sent to chosen players; never enable in production
+	FaultInjection      FaultInjectionOptions
+}
+
+// FaultInjectionOptions degrade client connections on purpose so timeout and
+// reconnection handling can be tested. Never enable in production.
+type FaultInjectionOptions struct {
+	Enabled        bool    // Wrap every sign, entrance and channel connection
+	Seed           int64   // Each connection replays the same faults for the same traffic
+	LatencyMs      int     // Delay added to every packet in both directions
+	JitterMs       int     // Up to this many extra milliseconds per packet
+	TruncateRate   float64 // Chance from 0 to 1 that a packet is cut short
+	DisconnectRate float64 // Chance from 0 to 1 per packet that the connection is dropped
 }
 
 type CapLinkOptions struct {
@@ -206,15 +518,110 @@ type GameplayOptions struct {
 
 // Discord holds the discord integration config.
 type Discord struct {
-	Enabled      bool
-	BotToken     string
-	RelayChannel DiscordRelay
+	Enabled       bool
+	BotToken      string
+	BotTokenFile  string // Secret reference BotToken is read from instead, see LoadSecret
+	WebhookOnly   bool   // Run without a bot token, posting relayed chat and announcements through webhooks only
+	RelayChannel  DiscordRelay
+	Announcements []DiscordAnnouncement
+	RoleSync      DiscordRoleSync
+	Notifications DiscordNotifications
+	Recruitment   DiscordRecruitment
+	Registration  DiscordRegistration
+	Moderation    DiscordModeration
+	Status        DiscordStatus
 }
 
 type DiscordRelay struct {
 	Enabled          bool
 	MaxMessageLength int
-	RelayChannelID   string
+	RelayChannelID   string                // Legacy single relay channel, used when Mappings is empty
+	Mappings         []DiscordRelayMapping // Per-scope relay mappings
+}
+
+// DiscordRelayMapping relays one in-game chat scope to and from a Discord channel.
+type DiscordRelayMapping struct {
+	Scope       string   // world, guild or siege
+	ChannelID   string   // Discord channel ID
+	Webhook     string   // Webhook URL used instead of the bot to post to the channel
+	GuildID     uint32   // Restricts a guild mapping to one in-game guild, 0 matches any guild when sending to Discord
+	ToDiscord   bool     // Relay in-game messages to Discord
+	FromDiscord bool     // Relay Discord messages in-game
+	Filters     []string // Messages containing any of these words (case-insensitive) are not relayed
+	NameFormat  string   // Format of messages sent to Discord, {name} and {message} are substituted
+	GameFormat  string   // Format of messages shown in-game, {name} and {message} are substituted
+}
+
+// DiscordAnnouncement posts embeds for one in-game event type to a Discord channel.
+type DiscordAnnouncement struct {
+	Event     string // siege_start, siege_end, festa_standings, server_first or maintenance
+	Enabled   bool
+	ChannelID string
+	Webhook   string // Webhook URL used instead of the bot to post to the channel
+	Color     int    // Embed color, 0 uses the event type's default
+}
+
+// DiscordRoleSync assigns Discord roles to linked accounts based on their
+// rights and active courses.
+type DiscordRoleSync struct {
+	Enabled        bool
+	GuildID        string // Discord server whose member roles are managed
+	Interval       int    // Minutes between full re-syncs of every linked account
+	OperatorRoleID string // Role held by accounts with operator privileges
+	CourseRoles    []DiscordCourseRole
+	BoosterCourse  string // Course granted to server boosters, empty to disable
+}
+
+// DiscordCourseRole maps an in-game course to a Discord role.
+type DiscordCourseRole struct {
+	Course string // Course name or alias, e.g. HunterLife
+	RoleID string
+}
+
+// DiscordNotifications controls DMs sent to opted-in linked players about
+// mail, guild applications and friend additions received while offline.
+type DiscordNotifications struct {
+	Enabled         bool
+	BatchInterval   int // Minutes notifications are collected before being sent as one DM
+	QuietHoursStart int // Hour (0-23, server time) DMs are held from, equal to QuietHoursEnd to disable
+	QuietHoursEnd   int // Hour (0-23, server time) held DMs are sent again
+}
+
+// DiscordRecruitment maintains a pinned post listing recruiting guilds.
+type DiscordRecruitment struct {
+	Enabled   bool
+	ChannelID string
+	Interval  int // Minutes between board refreshes
+}
+
+// DiscordRegistration allows members of a Discord server to create game
+// accounts with the /register slash command.
+type DiscordRegistration struct {
+	Enabled        bool
+	GuildID        string // Discord server users must be a member of, required
+	RequiredRoleID string // Role users must hold, empty to allow every member
+	Cooldown       int    // Minutes between attempts by the same Discord user
+	MaxPerHour     int    // Accounts created per hour across all users, 0 for no limit
+	DeliverByDM    bool   // Send credentials by DM instead of an ephemeral reply
+}
+
+// DiscordModeration mirrors bans and timeouts between Discord and the game
+// for linked accounts. Each policy field names the action applied on the
+// other side, or is empty to not mirror that kind of action.
+type DiscordModeration struct {
+	Enabled        bool
+	GuildID        string // Discord server whose bans and timeouts are mirrored
+	DiscordBan     string // Applied in-game when a linked user is banned on Discord: "ban" or ""
+	DiscordTimeout string // Applied in-game when a linked user is timed out on Discord: "mute", "ban" or ""
+	GameBan        string // Applied on Discord when a user is permanently banned in-game: "ban" or ""
+	GameTempBan    string // Applied on Discord when a user is temporarily banned in-game: "timeout", "ban" or ""
+}
+
+// DiscordStatus shows the online player count and active events in the
+// bot's presence.
+type DiscordStatus struct {
+	Enabled  bool
+	Interval int // Minutes between presence updates
 }
 
 // Command is a channelserver chat command
@@ -233,11 +640,18 @@ type Course struct {
 
 // Database holds the postgres database config.
 type Database struct {
-	Host     string
-	Port     int
-	User     string
-	Password string
-	Database string
+	Host               string
+	Port               int
+	User               string
+	Password           string
+	PasswordFile       string // Secret reference Password is read from instead, see LoadSecret
+	Database           string
+	MaxOpenConns       int      // Maximum open connections in the pool, 0 for unlimited
+	MaxIdleConns       int      // Maximum idle connections kept in the pool
+	ConnMaxLifetime    int      // Seconds a connection is reused before being replaced, 0 to reuse forever
+	ConnMaxIdleTime    int      // Seconds an idle connection is kept open, 0 to keep forever
+	Replicas           []string // Connection strings of read replicas for read-only queries, empty to read from the primary
+	SlowQueryThreshold int      // Milliseconds after which a statement is logged as slow, 0 to disable
 }
 
 // Sign holds the sign server config.
@@ -248,13 +662,15 @@ type Sign struct {
 
 // API holds server config
 type API struct {
-	Enabled     bool
-	Port        int
-	PatchServer string
-	Banners     []APISignBanner
-	Messages    []APISignMessage
-	Links       []APISignLink
-	LandingPage LandingPage
+	Enabled        bool
+	Port           int
+	PatchServer    string
+	Banners        []APISignBanner
+	Messages       []APISignMessage
+	Links          []APISignLink
+	LandingPage    LandingPage
+	AdminToken     string // Bearer token required by the /admin endpoints; empty disables them
+	AdminTokenFile string // Secret reference AdminToken is read from instead, see LoadSecret
 }
 
 // LandingPage holds config for the browser-facing landing page at /.
@@ -283,7 +699,15 @@ type APISignLink struct {
 }
 
 type Channel struct {
-	Enabled bool
+	Enabled             bool
+	AsyncWriteInterval  int    // Milliseconds to batch non-critical writes (last login, time played, player counts, trend weapons) for, 0 to write immediately
+	SaveWorkers         int    // Goroutines compressing and writing savedata off the packet handlers, 0 to save inline
+	MetricsLogInterval  int    // Seconds between log summaries of the slowest packet handlers, 0 to disable
+	PacketValidation    string // What to do with packets that fail validation before their handler: reject, log or off
+	MaxPacketViolations int    // Invalid packets a session may send before it is disconnected when rejecting, 0 to never disconnect
+	KeepaliveTimeout    int    // Seconds without any packet, keepalives included, before a session is dropped as dead, 0 to never
+	IdleTimeout         int    // Seconds without player activity before a session is disconnected, 0 to never
+	IdleWarning         int    // Seconds before IdleTimeout that the player is warned in chat, 0 for no warning
 }
 
 // Entrance holds the entrance server config.
@@ -338,6 +762,17 @@ func getOutboundIP4() (net.IP, error) {
 	return localAddr.IP.To4(), nil
 }
 
+// getOutboundIP6 gets the preferred outbound ip6 of this machine.
+func getOutboundIP6() (net.IP, error) {
+	conn, err := net.Dial("udp6", "[2001:4860:4860::8888]:80")
+	if err != nil {
+		return nil, fmt.Errorf("detecting outbound IPv6: %w", err)
+	}
+	defer func() { _ = conn.Close() }()
+
+	return conn.LocalAddr().(*net.UDPAddr).IP, nil
+}
+
 // registerDefaults sets all sane defaults via Viper so that a minimal
 // config.json (just database credentials) produces a fully working server.
 func registerDefaults() {
@@ -350,6 +785,8 @@ func registerDefaults() {
 	})
 	viper.SetDefault("ClientMode", "ZZ")
 	viper.SetDefault("QuestCacheExpiry", 300)
+	viper.SetDefault("QuestCacheMaxEntries", 1024)
+	viper.SetDefault("QuestCacheMaxBytes", 64*1024*1024)
 	viper.SetDefault("CommandPrefix", "!")
 	viper.SetDefault("AutoCreateAccount", true)
 	viper.SetDefault("LoopDelay", 50)
@@ -358,10 +795,69 @@ func registerDefaults() {
 
 	// SaveDumps
 	viper.SetDefault("SaveDumps", SaveDumpOptions{
-		Enabled:   true,
-		OutputDir: "save-backups",
+		Enabled:       true,
+		OutputDir:     "save-backups",
+		Retention:     10,
+		CompressAfter: 1,
 	})
 
+	// SaveCache
+	viper.SetDefault("SaveCache.FlushInterval", 60)
+	viper.SetDefault("SaveCache.JournalDir", "save-journal")
+
+	// Compression
+	viper.SetDefault("Compression", CompressionOptions{
+		Savedata:  "null",
+		Platedata: "null",
+		Platebox:  "null",
+	})
+
+	// Backup
+	viper.SetDefault("Backup", BackupOptions{
+		Interval:  24,
+		Retention: 14,
+		Dir:       "backups",
+		S3:        BackupS3Options{Region: "us-east-1"},
+	})
+
+	// Shutdown
+	viper.SetDefault("Shutdown", ShutdownOptions{
+		Countdown:    10,
+		QuestTimeout: 300,
+	})
+
+	// ProxyProtocol
+	viper.SetDefault("ProxyProtocol", ProxyProtocolOptions{
+		TrustedProxies: []string{},
+		HeaderTimeout:  5,
+	})
+
+	// ConnectionLimits
+	viper.SetDefault("ConnectionLimits", ConnectionLimitOptions{
+		Enabled:          true,
+		MaxPerIP:         32,
+		MaxPerMinute:     120,
+		DenylistAfter:    20,
+		DenylistSeconds:  600,
+		HandshakeTimeout: 10,
+	})
+
+	// PacketCrypto
+	viper.SetDefault("PacketCrypto", PacketCryptoOptions{
+		Cipher: "mhf",
+	})
+
+	// Proxy
+	viper.SetDefault("Proxy.OutputDir", "captures")
+
+	// Cluster
+	viper.SetDefault("Cluster.Listen", ":54100")
+
+	// Federation
+	viper.SetDefault("Federation.Listen", ":54200")
+	viper.SetDefault("Federation.Chat", true)
+	viper.SetDefault("Federation.Presence", true)
+
 	// Screenshots
 	viper.SetDefault("Screenshots", ScreenshotsOptions{
 		Enabled:       true,
@@ -379,10 +875,44 @@ func registerDefaults() {
 		CaptureChannel:  true,
 	})
 
+	// Tracing
+	viper.SetDefault("Tracing", TracingOptions{
+		Exporter:    "otlp",
+		Endpoint:    "localhost:4318",
+		Insecure:    true,
+		SampleRatio: 1,
+		ServiceName: "erupe",
+	})
+
+	// Logging
+	viper.SetDefault("Logging.Level", "debug")
+	viper.SetDefault("Logging.Format", "console")
+	viper.SetDefault("Logging.Output", "stderr")
+	viper.SetDefault("Logging.MaxSize", 100)
+	viper.SetDefault("Logging.MaxAge", 24)
+	viper.SetDefault("Logging.MaxBackups", 7)
+
+	// ErrorReporting
+	viper.SetDefault("ErrorReporting.Environment", "production")
+	viper.SetDefault("ErrorReporting.SampleRate", 1.0)
+
+	// SessionEvents
+	viper.SetDefault("SessionEvents.Enabled", true)
+	viper.SetDefault("SessionEvents.RetentionDays", 30)
+
+	// Analytics
+	viper.SetDefault("Analytics.Enabled", true)
+
+	// Console
+	viper.SetDefault("Console.Socket", "erupe-console.sock")
+
 	// DebugOptions (dot-notation for per-field merge)
 	viper.SetDefault("DebugOptions.MaxHexdumpLength", 256)
 	viper.SetDefault("DebugOptions.FestaOverride", -1)
 	viper.SetDefault("DebugOptions.AutoQuestBackport", true)
+	viper.SetDefault("DebugOptions.PprofAddress", "127.0.0.1:6060")
+	viper.SetDefault("DebugOptions.CrashReportDir", "crashreports")
+	viper.SetDefault("DebugOptions.CrashReportPackets", 16)
 	viper.SetDefault("DebugOptions.CapLink", CapLinkOptions{
 		Values: []uint16{51728, 20000, 51729, 1, 20000},
 		Port:   80,
@@ -422,6 +952,16 @@ func registerDefaults() {
 
 	// Discord
 	viper.SetDefault("Discord.RelayChannel.MaxMessageLength", 183)
+	viper.SetDefault("Discord.RoleSync.Interval", 60)
+	viper.SetDefault("Discord.Notifications.BatchInterval", 10)
+	viper.SetDefault("Discord.Recruitment.Interval", 15)
+	viper.SetDefault("Discord.Registration.Cooldown", 10)
+	viper.SetDefault("Discord.Registration.MaxPerHour", 20)
+	viper.SetDefault("Discord.Moderation.DiscordBan", "ban")
+	viper.SetDefault("Discord.Moderation.DiscordTimeout", "mute")
+	viper.SetDefault("Discord.Moderation.GameBan", "ban")
+	viper.SetDefault("Discord.Moderation.GameTempBan", "timeout")
+	viper.SetDefault("Discord.Status.Interval", 5)
 
 	// Commands (whole-struct default — replaced entirely if user provides any)
 	viper.SetDefault("Commands", []Command{
@@ -437,6 +977,7 @@ func registerDefaults() {
 		{Name: "Ban", Enabled: false, Description: "Ban/Temp Ban a user", Prefix: "ban"},
 		{Name: "Timer", Enabled: true, Description: "Toggle the Quest timer", Prefix: "timer"},
 		{Name: "Playtime", Enabled: true, Description: "Show your playtime", Prefix: "playtime"},
+		{Name: "Allies", Enabled: true, Description: "List the players online on allied servers", Prefix: "allies"},
 	})
 
 	// Courses
@@ -459,6 +1000,11 @@ func registerDefaults() {
 	viper.SetDefault("Database.Port", 5432)
 	viper.SetDefault("Database.User", "postgres")
 	viper.SetDefault("Database.Database", "erupe")
+	viper.SetDefault("Database.MaxOpenConns", 50)
+	viper.SetDefault("Database.MaxIdleConns", 10)
+	viper.SetDefault("Database.ConnMaxLifetime", 300)
+	viper.SetDefault("Database.ConnMaxIdleTime", 120)
+	viper.SetDefault("Database.SlowQueryThreshold", 500)
 
 	// Sign server
 	viper.SetDefault("Sign.Enabled", true)
@@ -475,6 +1021,14 @@ func registerDefaults() {
 
 	// Channel server
 	viper.SetDefault("Channel.Enabled", true)
+	viper.SetDefault("Channel.AsyncWriteInterval", 1000)
+	viper.SetDefault("Channel.SaveWorkers", 4)
+	viper.SetDefault("Channel.MetricsLogInterval", 300)
+	viper.SetDefault("Channel.PacketValidation", "reject")
+	viper.SetDefault("Channel.MaxPacketViolations", 50)
+	viper.SetDefault("Channel.KeepaliveTimeout", 30)
+	viper.SetDefault("Channel.IdleTimeout", 0)
+	viper.SetDefault("Channel.IdleWarning", 60)
 
 	// Entrance server
 	viper.SetDefault("Entrance.Enabled", true)
@@ -534,27 +1088,47 @@ func LoadConfig() (*Config, error) {
 		return nil, err
 	}
 
-	c := &Config{}
+	// Older JSON files are upgraded in place before they are read for real.
+	var upgrades []string
+	if path := viper.ConfigFileUsed(); strings.EqualFold(filepath.Ext(path), ".json") {
+		if upgrades, err = upgradeConfig(path); err != nil {
+			return nil, fmt.Errorf("upgrading config: %w", err)
+		}
+		if len(upgrades) > 0 {
+			if err := viper.ReadInConfig(); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	c := &Config{upgrades: upgrades}
 	err = viper.Unmarshal(c)
 	if err != nil {
+		return nil, fmt.Errorf("invalid config: %w", err)
+	}
+	if err := c.loadSecrets(); err != nil {
+		return nil, err
+	}
+	if err := c.Validate(); err != nil {
 		return nil, err
 	}
 
 	if c.Host == "" {
 		ip, err := getOutboundIP4()
 		if err != nil {
+			if _, err6 := getOutboundIP6(); err6 == nil {
+				return nil, errors.New("failed to detect host IP: this host only has IPv6 connectivity, set Host to the IPv4 address of a proxy or tunnel forwarding to it")
+			}
 			return nil, fmt.Errorf("failed to detect host IP: %w", err)
 		}
 		c.Host = ip.To4().String()
 	}
 
-	for i := range versionStrings {
-		if strings.ToUpper(c.ClientMode) == versionStrings[i] {
-			c.RealClientMode = Mode(i + 1)
-			c.ClientMode = strings.ToUpper(c.ClientMode)
-			if c.RealClientMode <= G101 {
-				c.ClientMode += " (Debug only)"
-			}
+	if mode, ok := ParseMode(c.ClientMode); ok {
+		c.RealClientMode = mode
+		c.ClientMode = strings.ToUpper(c.ClientMode)
+		if c.RealClientMode <= G101 {
+			c.ClientMode += " (Debug only)"
 		}
 	}
 	if c.RealClientMode == 0 {
@@ -142,25 +142,24 @@ func TestLoadConfigDefaultModeWhenInvalid(t *testing.T) {
 // TestConfigStruct tests Config structure creation with all fields
 func TestConfigStruct(t *testing.T) {
 	cfg := &Config{
-		Host:                   "localhost",
-		BinPath:                "/opt/erupe",
-		Language:               "en",
-		DisableSoftCrash:       false,
-		HideLoginNotice:        false,
-		LoginNotices:           []string{"Welcome"},
-		PatchServerManifest:    "http://patch.example.com/manifest",
-		PatchServerFile:        "http://patch.example.com/files",
-		DeleteOnSaveCorruption: false,
-		ClientMode:             "ZZ",
-		RealClientMode:         ZZ,
-		QuestCacheExpiry:       3600,
-		CommandPrefix:          "!",
-		AutoCreateAccount:      false,
-		LoopDelay:              100,
-		DefaultCourses:         []uint16{1, 2, 3},
-		EarthStatus:            0,
-		EarthID:                0,
-		EarthMonsters:          []int32{100, 101, 102},
+		Host:                "localhost",
+		BinPath:             "/opt/erupe",
+		Language:            "en",
+		DisableSoftCrash:    false,
+		HideLoginNotice:     false,
+		LoginNotices:        []string{"Welcome"},
+		PatchServerManifest: "http://patch.example.com/manifest",
+		PatchServerFile:     "http://patch.example.com/files",
+		ClientMode:          "ZZ",
+		RealClientMode:      ZZ,
+		QuestCacheExpiry:    3600,
+		CommandPrefix:       "!",
+		AutoCreateAccount:   false,
+		LoopDelay:           100,
+		DefaultCourses:      []uint16{1, 2, 3},
+		EarthStatus:         0,
+		EarthID:             0,
+		EarthMonsters:       []int32{100, 101, 102},
 		SaveDumps: SaveDumpOptions{
 			Enabled:    true,
 			RawEnabled: false,
@@ -551,8 +550,8 @@ func TestMinimalConfigDefaults(t *testing.T) {
 	}
 
 	// Commands should be present
-	if len(cfg.Commands) != 12 {
-		t.Errorf("Commands = %d, want 12", len(cfg.Commands))
+	if len(cfg.Commands) != 13 {
+		t.Errorf("Commands = %d, want 13", len(cfg.Commands))
 	}
 
 	// Courses should be present
@@ -592,6 +591,12 @@ func TestMinimalConfigDefaults(t *testing.T) {
 	if cfg.Database.Port != 5432 {
 		t.Errorf("Database.Port = %d, want 5432", cfg.Database.Port)
 	}
+	if cfg.Database.MaxOpenConns != 50 || cfg.Database.MaxIdleConns != 10 {
+		t.Errorf("Database pool = %d open / %d idle, want 50 / 10", cfg.Database.MaxOpenConns, cfg.Database.MaxIdleConns)
+	}
+	if len(cfg.Database.Replicas) != 0 {
+		t.Errorf("Database.Replicas = %v, want none", cfg.Database.Replicas)
+	}
 
 	// ClientMode defaults to ZZ
 	if cfg.RealClientMode != ZZ {
@@ -643,8 +648,8 @@ func TestFullConfigBackwardCompat(t *testing.T) {
 	if len(cfg.Entrance.Entries) != 6 {
 		t.Errorf("Entrance.Entries = %d, want 6", len(cfg.Entrance.Entries))
 	}
-	if len(cfg.Commands) != 12 {
-		t.Errorf("Commands = %d, want 12", len(cfg.Commands))
+	if len(cfg.Commands) != 13 {
+		t.Errorf("Commands = %d, want 13", len(cfg.Commands))
 	}
 	if cfg.GameplayOptions.MaximumNP != 100000 {
 		t.Errorf("MaximumNP = %d, want 100000", cfg.GameplayOptions.MaximumNP)
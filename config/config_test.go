@@ -125,6 +125,30 @@ func TestVersionStringsContent(t *testing.T) {
 	}
 }
 
+// TestParseMode verifies ClientMode strings map to their Mode constants
+func TestParseMode(t *testing.T) {
+	tests := []struct {
+		input string
+		want  Mode
+		ok    bool
+	}{
+		{"S1.0", S1, true},
+		{"FW.5", F5, true},
+		{"g10.1", G101, true},
+		{"ZZ", ZZ, true},
+		{"zz", ZZ, true},
+		{"Z3", 0, false},
+		{"", 0, false},
+	}
+
+	for _, tt := range tests {
+		got, ok := ParseMode(tt.input)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("ParseMode(%q) = %d, %v, want %d, %v", tt.input, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
 // TestGetOutboundIP4 tests IP detection
 func TestGetOutboundIP4(t *testing.T) {
 	ip, err := getOutboundIP4()
@@ -149,25 +173,24 @@ func TestGetOutboundIP4(t *testing.T) {
 // TestConfigStructTypes verifies Config struct fields have correct types
 func TestConfigStructTypes(t *testing.T) {
 	cfg := &Config{
-		Host:                   "localhost",
-		BinPath:                "/path/to/bin",
-		Language:               "en",
-		DisableSoftCrash:       false,
-		HideLoginNotice:        false,
-		LoginNotices:           []string{"Notice"},
-		PatchServerManifest:    "http://patch.example.com",
-		PatchServerFile:        "http://files.example.com",
-		DeleteOnSaveCorruption: false,
-		ClientMode:             "ZZ",
-		RealClientMode:         ZZ,
-		QuestCacheExpiry:       3600,
-		CommandPrefix:          "!",
-		AutoCreateAccount:      false,
-		LoopDelay:              100,
-		DefaultCourses:         []uint16{1, 2, 3},
-		EarthStatus:            1,
-		EarthID:                1,
-		EarthMonsters:          []int32{1, 2, 3},
+		Host:                "localhost",
+		BinPath:             "/path/to/bin",
+		Language:            "en",
+		DisableSoftCrash:    false,
+		HideLoginNotice:     false,
+		LoginNotices:        []string{"Notice"},
+		PatchServerManifest: "http://patch.example.com",
+		PatchServerFile:     "http://files.example.com",
+		ClientMode:          "ZZ",
+		RealClientMode:      ZZ,
+		QuestCacheExpiry:    3600,
+		CommandPrefix:       "!",
+		AutoCreateAccount:   false,
+		LoopDelay:           100,
+		DefaultCourses:      []uint16{1, 2, 3},
+		EarthStatus:         1,
+		EarthID:             1,
+		EarthMonsters:       []int32{1, 2, 3},
 		SaveDumps: SaveDumpOptions{
 			Enabled:    true,
 			RawEnabled: false,
@@ -559,6 +582,54 @@ func TestEntranceChannelInfoIsEnabled(t *testing.T) {
 	}
 }
 
+// TestClusterOptionsRunsChannel tests which channels a cluster process runs
+func TestClusterOptionsRunsChannel(t *testing.T) {
+	tests := []struct {
+		name    string
+		cluster ClusterOptions
+		port    uint16
+		want    bool
+	}{
+		{"cluster disabled", ClusterOptions{Channels: []uint16{54002}}, 54001, true},
+		{"no channels listed", ClusterOptions{Enabled: true}, 54001, true},
+		{"listed", ClusterOptions{Enabled: true, Channels: []uint16{54001}}, 54001, true},
+		{"not listed", ClusterOptions{Enabled: true, Channels: []uint16{54002}}, 54001, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.cluster.RunsChannel(tt.port); got != tt.want {
+				t.Errorf("RunsChannel(%d) = %v, want %v", tt.port, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCaptureOptionsTargets(t *testing.T) {
+	targeted := CaptureOptions{CharIDs: []uint32{7}, Usernames: []string{"alice"}}
+	tests := []struct {
+		name     string
+		capture  CaptureOptions
+		username string
+		charIDs  []uint32
+		want     bool
+	}{
+		{"no targets", CaptureOptions{}, "", nil, true},
+		{"username", targeted, "alice", nil, true},
+		{"other username", targeted, "bob", []uint32{8}, false},
+		{"one of the characters", targeted, "", []uint32{8, 7}, true},
+		{"unknown identity", targeted, "", nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.capture.Targets(tt.username, tt.charIDs...); got != tt.want {
+				t.Errorf("Targets(%q, %v) = %v, want %v", tt.username, tt.charIDs, got, tt.want)
+			}
+		})
+	}
+}
+
 // TestDiscord verifies Discord struct
 func TestDiscord(t *testing.T) {
 	discord := Discord{
@@ -0,0 +1,184 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// UpdateGameplay applies patch, a JSON object holding some GameplayOptions
+// such as {"ZennyMultiplier": 2}, to c and returns the settings it changed.
+// Unknown settings and invalid values are refused and nothing is changed.
+// Like a reload, it publishes a new Live snapshot.
+func (c *Config) UpdateGameplay(patch []byte) ([]Change, error) {
+	snap := *c.Live()
+	next := snap.GameplayOptions
+	// Decoding reuses slices, so copy the rows out of the published snapshot.
+	next.ClanMemberLimits = nil
+	for _, row := range snap.GameplayOptions.ClanMemberLimits {
+		next.ClanMemberLimits = append(next.ClanMemberLimits, slices.Clone(row))
+	}
+	dec := json.NewDecoder(bytes.NewReader(patch))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&next); err != nil {
+		return nil, fmt.Errorf("GameplayOptions: %w", err)
+	}
+	v := &validator{}
+	checkGameplay(v, next)
+	if err := v.err(); err != nil {
+		return nil, err
+	}
+
+	var changes []Change
+	live, fresh := reflect.ValueOf(snap.GameplayOptions), reflect.ValueOf(next)
+	for i := 0; i < live.NumField(); i++ {
+		if !reflect.DeepEqual(live.Field(i).Interface(), fresh.Field(i).Interface()) {
+			changes = append(changes, Change{
+				Setting: "GameplayOptions." + live.Type().Field(i).Name,
+				Old:     live.Field(i).Interface(),
+				New:     fresh.Field(i).Interface(),
+			})
+		}
+	}
+	snap.GameplayOptions = next
+	c.live.Store(&snap)
+	return changes, nil
+}
+
+// Persist writes the new values of changes into the config file, so they
+// survive a restart. Other settings, and the order of every key, are kept.
+func (c *Config) Persist(changes []Change) error {
+	path := viper.ConfigFileUsed()
+	if path == "" {
+		return fmt.Errorf("no config file was loaded")
+	}
+	return persistTo(path, changes)
+}
+
+func persistTo(path string, changes []Change) error {
+	if !strings.EqualFold(filepath.Ext(path), ".json") {
+		return fmt.Errorf("%s is not a JSON config file", path)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	doc, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	for _, ch := range changes {
+		if doc, err = setJSONPath(doc, strings.Split(ch.Setting, "."), ch.New); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	return writeJSONFile(path, doc, info.Mode().Perm())
+}
+
+// writeJSONFile indents doc and writes it to path with perm. It writes
+// beside the file and renames over it, so a crash cannot leave a truncated
+// config.
+func writeJSONFile(path string, doc []byte, perm os.FileMode) error {
+	var out bytes.Buffer
+	if err := json.Indent(&out, doc, "", "  "); err != nil {
+		return err
+	}
+	out.WriteByte('\n')
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".config-*.json")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+	if _, err := tmp.Write(out.Bytes()); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), perm); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// jsonField is one key of a JSON object, kept in file order.
+type jsonField struct {
+	key   string
+	value json.RawMessage
+}
+
+// setJSONPath returns the JSON object doc with the value at path replaced,
+// or added along with any missing parent objects. Keys match without regard
+// to case, as they do when the config is loaded.
+func setJSONPath(doc []byte, path []string, value any) ([]byte, error) {
+	if len(path) == 0 {
+		return json.Marshal(value)
+	}
+	fields, err := jsonObject(doc)
+	if err != nil {
+		return nil, err
+	}
+	for i, f := range fields {
+		if strings.EqualFold(f.key, path[0]) {
+			if fields[i].value, err = setJSONPath(f.value, path[1:], value); err != nil {
+				return nil, err
+			}
+			return marshalJSONObject(fields), nil
+		}
+	}
+	child, err := setJSONPath(nil, path[1:], value)
+	if err != nil {
+		return nil, err
+	}
+	return marshalJSONObject(append(fields, jsonField{path[0], child})), nil
+}
+
+// jsonObject splits a JSON object into its fields. An empty doc is an empty
+// object.
+func jsonObject(doc []byte) ([]jsonField, error) {
+	if len(bytes.TrimSpace(doc)) == 0 {
+		return nil, nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(doc))
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+		return nil, fmt.Errorf("expected a JSON object")
+	}
+	var fields []jsonField
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		var value json.RawMessage
+		if err := dec.Decode(&value); err != nil {
+			return nil, err
+		}
+		fields = append(fields, jsonField{tok.(string), value})
+	}
+	return fields, nil
+}
+
+func marshalJSONObject(fields []jsonField) []byte {
+	var b bytes.Buffer
+	b.WriteByte('{')
+	for i, f := range fields {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		key, _ := json.Marshal(f.key)
+		b.Write(key)
+		b.WriteByte(':')
+		b.Write(f.value)
+	}
+	b.WriteByte('}')
+	return b.Bytes()
+}
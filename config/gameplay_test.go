@@ -0,0 +1,96 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestUpdateGameplay(t *testing.T) {
+	c := &Config{GameplayOptions: GameplayOptions{ZennyMultiplier: 1, BonusQuestAllowance: 3}}
+	changes, err := c.UpdateGameplay([]byte(`{"ZennyMultiplier": 2, "BonusQuestAllowance": 3, "EnableKaijiEvent": true}`))
+	if err != nil {
+		t.Fatalf("UpdateGameplay() error: %v", err)
+	}
+	if len(changes) != 2 || changes[0].Setting != "GameplayOptions.ZennyMultiplier" || changes[1].Setting != "GameplayOptions.EnableKaijiEvent" {
+		t.Errorf("changes = %+v", changes)
+	}
+	if g := c.Live().GameplayOptions; g.ZennyMultiplier != 2 || !g.EnableKaijiEvent || g.BonusQuestAllowance != 3 {
+		t.Errorf("GameplayOptions = %+v", g)
+	}
+
+	for _, patch := range []string{`{"ZenyMultiplier": 2}`, `{"ZennyMultiplier": -1}`, `{"ZennyMultiplier": "x"}`, `[]`} {
+		if _, err := c.UpdateGameplay([]byte(patch)); err == nil {
+			t.Errorf("UpdateGameplay(%s) succeeded", patch)
+		}
+	}
+	if c.Live().GameplayOptions.ZennyMultiplier != 2 {
+		t.Error("a refused update changed the settings")
+	}
+}
+
+func TestUpdateGameplayKeepsSnapshot(t *testing.T) {
+	c := &Config{GameplayOptions: GameplayOptions{ClanMemberLimits: [][]uint8{{0, 30}, {3, 40}}}}
+	before := c.Live()
+	if _, err := c.UpdateGameplay([]byte(`{"ClanMemberLimits": [[0, 50], [3, 60]]}`)); err != nil {
+		t.Fatalf("UpdateGameplay() error: %v", err)
+	}
+	if got := before.GameplayOptions.ClanMemberLimits; got[0][1] != 30 || got[1][1] != 40 {
+		t.Errorf("previous snapshot ClanMemberLimits = %v, want it unchanged", got)
+	}
+	if got := c.Live().GameplayOptions.ClanMemberLimits; got[0][1] != 50 || got[1][1] != 60 {
+		t.Errorf("ClanMemberLimits = %v", got)
+	}
+}
+
+func TestPersistTo(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	orig := `{
+  "Host": "127.0.0.1",
+  "gameplayOptions": {
+    "HRPMultiplier": 1,
+    "ZennyMultiplier": 1
+  },
+  "Database": {"Password": "x"}
+}
+`
+	if err := os.WriteFile(path, []byte(orig), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	changes := []Change{
+		{Setting: "GameplayOptions.ZennyMultiplier", New: float32(2.5)},
+		{Setting: "GameplayOptions.EnableNierEvent", New: true},
+		{Setting: "Channel.IdleTimeout", New: 600},
+	}
+	if err := persistTo(path, changes); err != nil {
+		t.Fatalf("persistTo() error: %v", err)
+	}
+	got, _ := os.ReadFile(path)
+	want := `{
+  "Host": "127.0.0.1",
+  "gameplayOptions": {
+    "HRPMultiplier": 1,
+    "ZennyMultiplier": 2.5,
+    "EnableNierEvent": true
+  },
+  "Database": {
+    "Password": "x"
+  },
+  "Channel": {
+    "IdleTimeout": 600
+  }
+}
+`
+	if string(got) != want {
+		t.Errorf("config =\n%s\nwant\n%s", got, want)
+	}
+	if info, _ := os.Stat(path); info.Mode().Perm() != 0o600 {
+		t.Errorf("mode = %v, want 0600", info.Mode().Perm())
+	}
+
+	toml := filepath.Join(t.TempDir(), "config.toml")
+	_ = os.WriteFile(toml, nil, 0o600)
+	if err := persistTo(toml, changes); err == nil {
+		t.Error("persistTo() wrote a TOML file")
+	}
+}
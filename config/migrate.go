@@ -0,0 +1,268 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// LatestConfigVersion is the layout of config files this build reads. Files
+// stamped with an older ConfigVersion, or none, are upgraded on startup.
+const LatestConfigVersion = 1
+
+// migrationStep edits a config file and returns a note for each change.
+type migrationStep func(doc []byte) ([]byte, []string, error)
+
+// migration upgrades a config file from the version before it to version.
+type migration struct {
+	version int
+	steps   []migrationStep
+}
+
+// migrations are applied in order to files older than their version. Append
+// a migration, and bump LatestConfigVersion, whenever a key is renamed or
+// moved, or a new default would change how an existing file behaves.
+var migrations = []migration{
+	// Version 1 is the 9.3 layout. Files without a ConfigVersion may still
+	// hold the 9.2 debug and Discord keys.
+	{version: 1, steps: []migrationStep{
+		moveKey("DevModeOptions.AutoCreateAccount", "AutoCreateAccount"),
+		moveKey("DevModeOptions.SaveDumps", "SaveDumps"),
+		moveKey("DevModeOptions.DivaEvent", "DevModeOptions.DivaOverride"),
+		moveKey("DevModeOptions.FestaEvent", "DevModeOptions.FestaOverride"),
+		moveKey("DevModeOptions.TournamentEvent", "DevModeOptions.TournamentOverride"),
+		moveKey("DevModeOptions.QuestDebugTools", "DevModeOptions.QuestTools"),
+		moveKey("DevModeOptions.MezFesAlt", "GameplayOptions.MezFesSwitchMinigame"),
+		removeKey("DevModeOptions.MezFesEvent", "MezFes runs every week"),
+		removeKey("DevMode", "DebugOptions apply on their own"),
+		moveKey("DevModeOptions", "DebugOptions"),
+		moveDiscordRelay,
+	}},
+}
+
+// upgradeConfig brings the JSON config file at path up to
+// LatestConfigVersion, copying the original beside it first. It returns a
+// note for each change, and none when the file is already current.
+func upgradeConfig(path string) ([]string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	orig, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	version := 0
+	if raw, ok, err := getJSONPath(orig, []string{"ConfigVersion"}); err != nil {
+		return nil, err
+	} else if ok {
+		if err := json.Unmarshal(raw, &version); err != nil {
+			return nil, fmt.Errorf("ConfigVersion: %w", err)
+		}
+	}
+	if version > LatestConfigVersion {
+		return nil, fmt.Errorf("ConfigVersion %d is newer than this build understands (%d)", version, LatestConfigVersion)
+	}
+	if version == LatestConfigVersion {
+		return nil, nil
+	}
+
+	doc := orig
+	var notes []string
+	for _, m := range migrations {
+		if m.version <= version {
+			continue
+		}
+		for _, step := range m.steps {
+			var stepNotes []string
+			if doc, stepNotes, err = step(doc); err != nil {
+				return nil, fmt.Errorf("upgrading to version %d: %w", m.version, err)
+			}
+			for _, note := range stepNotes {
+				notes = append(notes, fmt.Sprintf("v%d: %s", m.version, note))
+			}
+		}
+	}
+	if doc, err = stampVersion(doc); err != nil {
+		return nil, err
+	}
+	notes = append(notes, fmt.Sprintf("ConfigVersion: %d to %d", version, LatestConfigVersion))
+
+	backup, err := writeBackup(path, version, orig, info.Mode().Perm())
+	if err != nil {
+		return nil, fmt.Errorf("backing up %s: %w", path, err)
+	}
+	notes = append(notes, "original kept as "+backup)
+	if err := writeJSONFile(path, doc, info.Mode().Perm()); err != nil {
+		return nil, err
+	}
+	return notes, nil
+}
+
+// stampVersion sets ConfigVersion in doc, adding it as the first key.
+func stampVersion(doc []byte) ([]byte, error) {
+	_, ok, err := getJSONPath(doc, []string{"ConfigVersion"})
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		return setJSONPath(doc, []string{"ConfigVersion"}, LatestConfigVersion)
+	}
+	fields, err := jsonObject(doc)
+	if err != nil {
+		return nil, err
+	}
+	version, _ := json.Marshal(LatestConfigVersion)
+	return marshalJSONObject(append([]jsonField{{"ConfigVersion", version}}, fields...)), nil
+}
+
+// writeBackup copies the original file to path.v<version>.bak, or to a
+// timestamped name if that backup already exists, and returns its name.
+func writeBackup(path string, version int, orig []byte, perm os.FileMode) (string, error) {
+	name := fmt.Sprintf("%s.v%d.bak", path, version)
+	f, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm)
+	if errors.Is(err, os.ErrExist) {
+		name = fmt.Sprintf("%s.v%d-%s.bak", path, version, time.Now().Format("20060102-150405"))
+		f, err = os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm)
+	}
+	if err != nil {
+		return "", err
+	}
+	if _, err := f.Write(orig); err != nil {
+		_ = f.Close()
+		return "", err
+	}
+	return filepath.Base(name), f.Close()
+}
+
+// moveKey moves the value at from to to. If to is already set, the value at
+// from is dropped instead.
+func moveKey(from, to string) migrationStep {
+	return func(doc []byte) ([]byte, []string, error) {
+		src, dst := strings.Split(from, "."), strings.Split(to, ".")
+		value, ok, err := getJSONPath(doc, src)
+		if err != nil || !ok {
+			return doc, nil, err
+		}
+		if _, taken, err := getJSONPath(doc, dst); err != nil {
+			return nil, nil, err
+		} else if taken {
+			doc, err = deleteJSONPath(doc, src)
+			return doc, []string{fmt.Sprintf("removed %s, %s is already set", from, to)}, err
+		}
+		// Keep a renamed key where it was in its object.
+		if len(src) == len(dst) && strings.Join(src[:len(src)-1], ".") == strings.Join(dst[:len(dst)-1], ".") {
+			doc, err = renameJSONPath(doc, src, dst[len(dst)-1])
+		} else if doc, err = setJSONPath(doc, dst, value); err == nil {
+			doc, err = deleteJSONPath(doc, src)
+		}
+		return doc, []string{fmt.Sprintf("moved %s to %s", from, to)}, err
+	}
+}
+
+// removeKey drops a key that no longer means anything, giving why.
+func removeKey(key, why string) migrationStep {
+	return func(doc []byte) ([]byte, []string, error) {
+		path := strings.Split(key, ".")
+		if _, ok, err := getJSONPath(doc, path); err != nil || !ok {
+			return doc, nil, err
+		}
+		doc, err := deleteJSONPath(doc, path)
+		return doc, []string{fmt.Sprintf("removed %s, %s", key, why)}, err
+	}
+}
+
+// moveDiscordRelay moves the 9.2 relay channel ID into RelayChannel. The bot
+// used to relay whenever it ran, so the relay is enabled along with the bot.
+func moveDiscordRelay(doc []byte) ([]byte, []string, error) {
+	doc, notes, err := moveKey("Discord.RealtimeChannelID", "Discord.RelayChannel.RelayChannelID")(doc)
+	if err != nil || len(notes) == 0 {
+		return doc, notes, err
+	}
+	enabledPath := []string{"Discord", "RelayChannel", "Enabled"}
+	if _, ok, err := getJSONPath(doc, enabledPath); err != nil || ok {
+		return doc, notes, err
+	}
+	enabled, ok, err := getJSONPath(doc, []string{"Discord", "Enabled"})
+	if err != nil {
+		return nil, nil, err
+	}
+	if !ok {
+		enabled = json.RawMessage("false")
+	}
+	if doc, err = setJSONPath(doc, enabledPath, enabled); err != nil {
+		return nil, nil, err
+	}
+	return doc, append(notes, fmt.Sprintf("set Discord.RelayChannel.Enabled to %s, following Discord.Enabled", enabled)), nil
+}
+
+// getJSONPath returns the value at path in the JSON object doc, matching
+// keys without regard to case.
+func getJSONPath(doc []byte, path []string) (json.RawMessage, bool, error) {
+	if len(path) == 0 {
+		return doc, true, nil
+	}
+	fields, err := jsonObject(doc)
+	if err != nil {
+		return nil, false, err
+	}
+	for _, f := range fields {
+		if strings.EqualFold(f.key, path[0]) {
+			if len(path) > 1 && !isJSONObject(f.value) {
+				return nil, false, nil
+			}
+			return getJSONPath(f.value, path[1:])
+		}
+	}
+	return nil, false, nil
+}
+
+// deleteJSONPath returns doc without the value at path.
+func deleteJSONPath(doc []byte, path []string) ([]byte, error) {
+	fields, err := jsonObject(doc)
+	if err != nil {
+		return nil, err
+	}
+	for i, f := range fields {
+		if !strings.EqualFold(f.key, path[0]) {
+			continue
+		}
+		if len(path) == 1 {
+			return marshalJSONObject(append(fields[:i:i], fields[i+1:]...)), nil
+		}
+		if fields[i].value, err = deleteJSONPath(f.value, path[1:]); err != nil {
+			return nil, err
+		}
+		return marshalJSONObject(fields), nil
+	}
+	return doc, nil
+}
+
+// renameJSONPath returns doc with the key at path renamed to key, in place.
+func renameJSONPath(doc []byte, path []string, key string) ([]byte, error) {
+	fields, err := jsonObject(doc)
+	if err != nil {
+		return nil, err
+	}
+	for i, f := range fields {
+		if !strings.EqualFold(f.key, path[0]) {
+			continue
+		}
+		if len(path) == 1 {
+			fields[i].key = key
+		} else if fields[i].value, err = renameJSONPath(f.value, path[1:], key); err != nil {
+			return nil, err
+		}
+		return marshalJSONObject(fields), nil
+	}
+	return doc, nil
+}
+
+func isJSONObject(value json.RawMessage) bool {
+	trimmed := strings.TrimSpace(string(value))
+	return strings.HasPrefix(trimmed, "{")
+}
@@ -0,0 +1,143 @@
+package config
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+const legacyConfig = `{
+  "Host": "127.0.0.1",
+  "DevMode": true,
+  "DevModeOptions": {
+    "AutoCreateAccount": false,
+    "MaxLauncherHR": true,
+    "FestaEvent": -1,
+    "MezFesEvent": true,
+    "MezFesAlt": true,
+    "SaveDumps": {"Enabled": true, "OutputDir": "dumps"}
+  },
+  "Discord": {"Enabled": true, "BotToken": "t", "RealtimeChannelID": "42"}
+}`
+
+func TestUpgradeConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(legacyConfig), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	notes, err := upgradeConfig(path)
+	if err != nil {
+		t.Fatalf("upgradeConfig() error: %v", err)
+	}
+	if len(notes) != 11 {
+		t.Errorf("got %d notes, want 11: %q", len(notes), notes)
+	}
+	if last := notes[len(notes)-1]; last != "original kept as config.json.v0.bak" {
+		t.Errorf("last note = %q", last)
+	}
+
+	backup, err := os.ReadFile(path + ".v0.bak")
+	if err != nil || string(backup) != legacyConfig {
+		t.Errorf("backup = %q, %v", backup, err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(data), "{\n  \"ConfigVersion\": 1,\n  \"Host\"") {
+		t.Errorf("upgraded file does not start with the version:\n%s", data)
+	}
+	var got map[string]any
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := got["DevMode"]; ok {
+		t.Error("DevMode was kept")
+	}
+	if _, ok := got["DevModeOptions"]; ok {
+		t.Error("DevModeOptions was kept")
+	}
+	if got["AutoCreateAccount"] != false {
+		t.Errorf("AutoCreateAccount = %v, want false", got["AutoCreateAccount"])
+	}
+	debug := got["DebugOptions"].(map[string]any)
+	if debug["MaxLauncherHR"] != true || debug["FestaOverride"] != float64(-1) || debug["MezFesEvent"] != nil {
+		t.Errorf("DebugOptions = %v", debug)
+	}
+	if got["SaveDumps"].(map[string]any)["OutputDir"] != "dumps" {
+		t.Errorf("SaveDumps = %v", got["SaveDumps"])
+	}
+	if got["GameplayOptions"].(map[string]any)["MezFesSwitchMinigame"] != true {
+		t.Errorf("GameplayOptions = %v", got["GameplayOptions"])
+	}
+	relay := got["Discord"].(map[string]any)["RelayChannel"].(map[string]any)
+	if relay["RelayChannelID"] != "42" || relay["Enabled"] != true {
+		t.Errorf("Discord.RelayChannel = %v", relay)
+	}
+
+	// A current file is left alone.
+	if notes, err := upgradeConfig(path); err != nil || notes != nil {
+		t.Errorf("second upgradeConfig() = %q, %v", notes, err)
+	}
+}
+
+func TestUpgradeConfigKeepsExistingTarget(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	orig := `{"DevModeOptions": {"CleanDB": true}, "DebugOptions": {"CleanDB": false}}`
+	if err := os.WriteFile(path, []byte(orig), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	notes, err := upgradeConfig(path)
+	if err != nil {
+		t.Fatalf("upgradeConfig() error: %v", err)
+	}
+	if notes[0] != "v1: removed DevModeOptions, DebugOptions is already set" {
+		t.Errorf("notes = %q", notes)
+	}
+	data, _ := os.ReadFile(path)
+	var got Config
+	if err := json.Unmarshal(data, &got); err != nil || got.DebugOptions.CleanDB {
+		t.Errorf("DebugOptions = %+v, %v", got.DebugOptions, err)
+	}
+}
+
+func TestUpgradeConfigNewerVersion(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(`{"ConfigVersion": 99}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := upgradeConfig(path); err == nil || !strings.Contains(err.Error(), "newer") {
+		t.Errorf("upgradeConfig() error = %v, want a newer version error", err)
+	}
+}
+
+func TestLoadConfigUpgradesFile(t *testing.T) {
+	viper.Reset()
+	dir := t.TempDir()
+	origDir, _ := os.Getwd()
+	defer func() { _ = os.Chdir(origDir) }()
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	writeMinimalConfig(t, dir, `{"Host": "127.0.0.1", "DevModeOptions": {"AutoCreateAccount": false, "MaxLauncherHR": true}}`)
+	c, err := LoadConfig()
+	if err != nil {
+		t.Fatalf("LoadConfig() error: %v", err)
+	}
+	if c.ConfigVersion != LatestConfigVersion || c.AutoCreateAccount || !c.DebugOptions.MaxLauncherHR {
+		t.Errorf("ConfigVersion = %d, AutoCreateAccount = %v, MaxLauncherHR = %v",
+			c.ConfigVersion, c.AutoCreateAccount, c.DebugOptions.MaxLauncherHR)
+	}
+	if len(c.Upgrades()) == 0 {
+		t.Error("Upgrades() is empty")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "config.json.v0.bak")); err != nil {
+		t.Errorf("backup missing: %v", err)
+	}
+}
@@ -0,0 +1,168 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+)
+
+// reloadable lists the settings a reload applies to the running server, as
+// field paths into both Config and Reloadable. They are read through Live
+// where they are used rather than once at startup; everything else takes
+// effect on restart.
+var reloadable = []string{
+	"GameplayOptions",
+	"HideLoginNotice",
+	"LoginNotices",
+	"CommandPrefix",
+	"Commands",
+	"Capture",
+	"EarthStatus",
+	"EarthID",
+	"EarthMonsters",
+	"DebugOptions.DivaOverride",
+	"DebugOptions.FestaOverride",
+	"DebugOptions.TournamentOverride",
+	"Channel.KeepaliveTimeout",
+	"Channel.IdleTimeout",
+	"Channel.IdleWarning",
+}
+
+// restartExempt lists the top-level settings never reported as needing a
+// restart: Host is resolved at startup, and the console toggles DebugOptions.
+var restartExempt = map[string]bool{
+	"Host":         true,
+	"DebugOptions": true,
+}
+
+// Reloadable holds the running values of the reloadable settings, at the
+// same field paths as in Config. A snapshot is never modified once Live has
+// returned it; ApplyReload and UpdateGameplay publish a new one instead.
+type Reloadable struct {
+	GameplayOptions GameplayOptions
+	HideLoginNotice bool
+	LoginNotices    []string
+	CommandPrefix   string
+	Commands        []Command
+	Capture         CaptureOptions
+	EarthStatus     int32
+	EarthID         int32
+	EarthMonsters   []int32
+	DebugOptions    ReloadableDebugOptions
+	Channel         ReloadableChannel
+}
+
+// ReloadableDebugOptions holds the DebugOptions a reload applies.
+type ReloadableDebugOptions struct {
+	DivaOverride       int
+	FestaOverride      int
+	TournamentOverride int
+}
+
+// ReloadableChannel holds the Channel settings a reload applies.
+type ReloadableChannel struct {
+	KeepaliveTimeout int
+	IdleTimeout      int
+	IdleWarning      int
+}
+
+// Live returns the running values of the reloadable settings. Their fields
+// on c keep the values loaded at startup, so anything reading them while the
+// server runs goes through Live. Settings read from one snapshot are
+// consistent with each other.
+func (c *Config) Live() *Reloadable {
+	if r := c.live.Load(); r != nil {
+		return r
+	}
+	r := &Reloadable{}
+	to, from := reflect.ValueOf(r).Elem(), reflect.ValueOf(c).Elem()
+	for _, path := range reloadable {
+		fieldByPath(to, path).Set(fieldByPath(from, path))
+	}
+	c.live.CompareAndSwap(nil, r)
+	return c.live.Load()
+}
+
+// Change is a setting a reload changed.
+type Change struct {
+	Setting string `json:"setting"`
+	Old     any    `json:"old"`
+	New     any    `json:"new"`
+}
+
+// Reload reads the config file again and applies its reloadable settings to
+// c. See ApplyReload.
+func (c *Config) Reload() (changes []Change, restart []string, err error) {
+	next, err := LoadConfig()
+	if err != nil {
+		return nil, nil, err
+	}
+	return c.ApplyReload(next)
+}
+
+// ApplyReload validates the reloadable settings of next and publishes those
+// that differ as a new Live snapshot, returning them. Settings outside the
+// reloadable set that differ are returned by name in restart and left alone.
+// On a validation error nothing is changed. Callers serialize ApplyReload
+// and UpdateGameplay; readers need no lock.
+func (c *Config) ApplyReload(next *Config) (changes []Change, restart []string, err error) {
+	if err := validateReloadable(next); err != nil {
+		return nil, nil, err
+	}
+	snap := *c.Live()
+	to, fresh := reflect.ValueOf(&snap).Elem(), reflect.ValueOf(next).Elem()
+	for _, path := range reloadable {
+		old, from := fieldByPath(to, path), fieldByPath(fresh, path)
+		if reflect.DeepEqual(old.Interface(), from.Interface()) {
+			continue
+		}
+		changes = append(changes, Change{Setting: path, Old: old.Interface(), New: from.Interface()})
+		old.Set(from)
+	}
+	if len(changes) > 0 {
+		c.live.Store(&snap)
+	}
+
+	live := reflect.ValueOf(c).Elem()
+	t := live.Type()
+	for i := 0; i < t.NumField(); i++ {
+		name := t.Field(i).Name
+		if !t.Field(i).IsExported() || restartExempt[name] {
+			continue
+		}
+		if !reflect.DeepEqual(withReloaded(live.Field(i), fresh.Field(i), name).Interface(), fresh.Field(i).Interface()) {
+			restart = append(restart, name)
+		}
+	}
+	return changes, restart, nil
+}
+
+// withReloaded returns a copy of the top-level setting v with its reloadable
+// fields taken from fresh, so only the rest is compared for a restart.
+func withReloaded(v, fresh reflect.Value, name string) reflect.Value {
+	out := reflect.New(v.Type()).Elem()
+	out.Set(v)
+	for _, path := range reloadable {
+		if path == name {
+			return fresh
+		}
+		if sub, ok := strings.CutPrefix(path, name+"."); ok {
+			fieldByPath(out, sub).Set(fieldByPath(fresh, sub))
+		}
+	}
+	return out
+}
+
+func fieldByPath(v reflect.Value, path string) reflect.Value {
+	for _, name := range strings.Split(path, ".") {
+		v = v.FieldByName(name)
+	}
+	return v
+}
+
+// validateReloadable checks the reloadable settings of c for values that
+// would misbehave once applied.
+func validateReloadable(c *Config) error {
+	v := &validator{}
+	checkReloadable(v, c)
+	return v.err()
+}
@@ -0,0 +1,128 @@
+package config
+
+import (
+	"os"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func TestApplyReload(t *testing.T) {
+	base := func() *Config {
+		return &Config{
+			CommandPrefix:   "!",
+			EarthStatus:     1,
+			GameplayOptions: GameplayOptions{ZennyMultiplier: 1},
+			Database:        Database{Port: 5432},
+		}
+	}
+	live, next := base(), base()
+	next.EarthStatus = 2
+	next.GameplayOptions.ZennyMultiplier = 2
+	next.DebugOptions.FestaOverride = 3
+	next.Channel.IdleTimeout = 600
+	next.Database.Port = 5433
+
+	before := live.Live()
+	changes, restart, err := live.ApplyReload(next)
+	if err != nil {
+		t.Fatalf("ApplyReload() error: %v", err)
+	}
+	var changed []string
+	for _, c := range changes {
+		changed = append(changed, c.Setting)
+	}
+	if want := []string{"GameplayOptions", "EarthStatus", "DebugOptions.FestaOverride", "Channel.IdleTimeout"}; !slices.Equal(changed, want) {
+		t.Errorf("changed = %v, want %v", changed, want)
+	}
+	if !slices.Equal(restart, []string{"Database"}) {
+		t.Errorf("restart = %v, want [Database]", restart)
+	}
+	got := live.Live()
+	if got.EarthStatus != 2 || got.GameplayOptions.ZennyMultiplier != 2 || got.DebugOptions.FestaOverride != 3 || got.Channel.IdleTimeout != 600 {
+		t.Error("reloadable settings were not applied")
+	}
+	if before.EarthStatus != 1 || before.GameplayOptions.ZennyMultiplier != 1 {
+		t.Error("the previous snapshot was modified")
+	}
+	if live.Database.Port != 5432 {
+		t.Error("Database was applied without a restart")
+	}
+}
+
+func TestLiveReloadRace(t *testing.T) {
+	live := &Config{CommandPrefix: "!", LoginNotices: []string{"a"}}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			next := &Config{CommandPrefix: "!", LoginNotices: []string{strings.Repeat("b", i)}}
+			if _, _, err := live.ApplyReload(next); err != nil {
+				t.Errorf("ApplyReload() error: %v", err)
+				return
+			}
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		_ = strings.Join(live.Live().LoginNotices, "")
+	}
+	<-done
+}
+
+func TestApplyReloadRejectsInvalid(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(c *Config)
+	}{
+		{"empty prefix", func(c *Config) { c.CommandPrefix = "" }},
+		{"unnamed command", func(c *Config) { c.Commands = []Command{{Prefix: "x"}} }},
+		{"shared prefix", func(c *Config) {
+			c.Commands = []Command{{Name: "A", Prefix: "x", Enabled: true}, {Name: "B", Prefix: "x", Enabled: true}}
+		}},
+		{"negative duration", func(c *Config) { c.GameplayOptions.ClanMealDuration = -1 }},
+		{"clan limit", func(c *Config) { c.GameplayOptions.ClanMemberLimits = [][]uint8{{0}} }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			live := &Config{CommandPrefix: "!", EarthStatus: 1}
+			next := &Config{CommandPrefix: "!", EarthStatus: 2}
+			tt.modify(next)
+			if _, _, err := live.ApplyReload(next); err == nil {
+				t.Fatal("invalid config was applied")
+			}
+			if live.Live().EarthStatus != 1 {
+				t.Error("settings were applied despite the error")
+			}
+		})
+	}
+}
+
+func TestReloadReadsConfigFile(t *testing.T) {
+	viper.Reset()
+	dir := t.TempDir()
+	origDir, _ := os.Getwd()
+	defer func() { _ = os.Chdir(origDir) }()
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	writeMinimalConfig(t, dir, `{"Host": "127.0.0.1", "Database": {"Password": "test"}}`)
+	live, err := LoadConfig()
+	if err != nil {
+		t.Fatalf("LoadConfig() error: %v", err)
+	}
+
+	writeMinimalConfig(t, dir, `{"Host": "127.0.0.1", "Database": {"Password": "test"}, "LoginNotices": ["Reloaded"]}`)
+	changes, restart, err := live.Reload()
+	if err != nil {
+		t.Fatalf("Reload() error: %v", err)
+	}
+	if len(changes) != 1 || changes[0].Setting != "LoginNotices" || len(restart) != 0 {
+		t.Errorf("changes = %v, restart = %v", changes, restart)
+	}
+	if !slices.Equal(live.Live().LoginNotices, []string{"Reloaded"}) {
+		t.Errorf("LoginNotices = %v", live.Live().LoginNotices)
+	}
+}
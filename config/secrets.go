@@ -0,0 +1,281 @@
+package config
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// secretTimeout bounds how long a secret store or command may take.
+const secretTimeout = 10 * time.Second
+
+// LoadSecret reads a secret from the reference ref:
+//
+//	/run/secrets/db_password          the contents of a file
+//	file:/run/secrets/db_password     the same
+//	env:ERUPE_DB_PASSWORD             an environment variable
+//	vault://secret/data/erupe#db      the field db of a HashiCorp Vault KV secret, from VAULT_ADDR with VAULT_TOKEN
+//	exec:sops -d --extract '["db"]' secrets.enc.json
+//	                                  the output of a command, such as SOPS
+//
+// Surrounding whitespace, such as the trailing newline of a file, is
+// trimmed.
+func LoadSecret(ref string) (string, error) {
+	var value string
+	var err error
+	switch {
+	case strings.HasPrefix(ref, "env:"):
+		name := strings.TrimPrefix(ref, "env:")
+		var ok bool
+		if value, ok = os.LookupEnv(name); !ok {
+			err = fmt.Errorf("environment variable %s is not set", name)
+		}
+	case strings.HasPrefix(ref, "vault://"):
+		value, err = loadVaultSecret(strings.TrimPrefix(ref, "vault://"))
+	case strings.HasPrefix(ref, "exec:"):
+		value, err = runSecretCommand(strings.TrimPrefix(ref, "exec:"))
+	default:
+		var b []byte
+		b, err = os.ReadFile(strings.TrimPrefix(ref, "file:"))
+		value = string(b)
+	}
+	return strings.TrimSpace(value), err
+}
+
+// loadVaultSecret reads field of the secret at path, given as path#field,
+// from the Vault server at VAULT_ADDR. Both KV v1 and KV v2 paths work.
+func loadVaultSecret(ref string) (string, error) {
+	path, field, ok := strings.Cut(ref, "#")
+	if !ok || field == "" {
+		return "", fmt.Errorf("vault reference %q needs a #field", ref)
+	}
+	addr := os.Getenv("VAULT_ADDR")
+	if addr == "" {
+		return "", fmt.Errorf("VAULT_ADDR is not set")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), secretTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(addr, "/")+"/v1/"+(&url.URL{Path: path}).EscapedPath(), nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("X-Vault-Token", os.Getenv("VAULT_TOKEN"))
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("vault answered %s for %s", resp.Status, path)
+	}
+	var body struct {
+		Data map[string]any `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", fmt.Errorf("vault response: %w", err)
+	}
+	data := body.Data
+	// KV v2 nests the secret under data.data.
+	if inner, ok := data["data"].(map[string]any); ok {
+		data = inner
+	}
+	value, ok := data[field].(string)
+	if !ok {
+		return "", fmt.Errorf("vault secret %s has no string field %q", path, field)
+	}
+	return value, nil
+}
+
+// runSecretCommand returns the output of a shell command.
+func runSecretCommand(command string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), secretTimeout)
+	defer cancel()
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", command)
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", command)
+	}
+	cmd.Stderr = os.Stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("%q: %w", command, err)
+	}
+	return string(out), nil
+}
+
+// secret is a setting that may be read from a secret reference.
+type secret struct {
+	key   string
+	value *string
+	ref   string
+}
+
+func (c *Config) secrets() []secret {
+	secrets := []secret{
+		{"Database.Password", &c.Database.Password, c.Database.PasswordFile},
+		{"Discord.BotToken", &c.Discord.BotToken, c.Discord.BotTokenFile},
+		{"API.AdminToken", &c.API.AdminToken, c.API.AdminTokenFile},
+		{"Backup.S3.AccessKey", &c.Backup.S3.AccessKey, c.Backup.S3.AccessKeyFile},
+		{"Backup.S3.SecretKey", &c.Backup.S3.SecretKey, c.Backup.S3.SecretKeyFile},
+		{"Cluster.Secret", &c.Cluster.Secret, c.Cluster.SecretFile},
+		{"ErrorReporting.DSN", &c.ErrorReporting.DSN, c.ErrorReporting.DSNFile},
+		{"Capture.EncryptionKey", &c.Capture.EncryptionKey, c.Capture.EncryptionKeyFile},
+	}
+	for i := range c.Federation.Allies {
+		a := &c.Federation.Allies[i]
+		secrets = append(secrets, secret{fmt.Sprintf("Federation.Allies[%d].Secret", i), &a.Secret, a.SecretFile})
+	}
+	return secrets
+}
+
+// loadSecrets fills the settings that name a secret reference, reporting
+// every one that cannot be read.
+func (c *Config) loadSecrets() error {
+	v := &validator{}
+	for _, s := range c.secrets() {
+		if s.ref == "" {
+			continue
+		}
+		if *s.value != "" {
+			v.add(s.key+"File", "is set along with %s; set only one", s.key)
+			continue
+		}
+		value, err := LoadSecret(s.ref)
+		if err != nil {
+			v.add(s.key+"File", "%v", err)
+			continue
+		}
+		*s.value = value
+	}
+	return v.err()
+}
+
+// RedactedSecret stands in for the secrets RedactSecrets removes.
+const RedactedSecret = "<redacted>"
+
+// secretPaths returns the keys of the settings that hold secrets, split at
+// their dots.
+func secretPaths() [][]string {
+	var paths [][]string
+	for _, s := range (&Config{}).secrets() {
+		paths = append(paths, strings.Split(s.key, "."))
+	}
+	return paths
+}
+
+// alliesPath holds the allied servers, each with a secret of its own.
+var alliesPath = []string{"Federation", "Allies"}
+
+// RedactSecrets returns the config file doc with every secret that is set
+// replaced by RedactedSecret, so the rest can be shown. Secret references
+// are kept, as they say where a secret is rather than what it is.
+func RedactSecrets(doc []byte) ([]byte, error) {
+	var err error
+	for _, path := range secretPaths() {
+		if doc, err = redactSecret(doc, path); err != nil {
+			return nil, err
+		}
+	}
+	return editAllies(doc, func(ally []byte) ([]byte, error) {
+		return redactSecret(ally, []string{"Secret"})
+	})
+}
+
+// RestoreSecrets returns doc, an edit of the config file orig as
+// RedactSecrets returned it, with every secret still RedactedSecret set back
+// to its value in orig. An ally's secret is taken from the ally of the same
+// Name in orig, so allies can be reordered, added and removed.
+func RestoreSecrets(doc, orig []byte) ([]byte, error) {
+	var err error
+	for _, path := range secretPaths() {
+		if doc, err = restoreSecret(doc, orig, path); err != nil {
+			return nil, err
+		}
+	}
+	origAllies := make(map[string][]byte)
+	if _, err := editAllies(orig, func(ally []byte) ([]byte, error) {
+		origAllies[allyName(ally)] = ally
+		return ally, nil
+	}); err != nil {
+		return nil, err
+	}
+	return editAllies(doc, func(ally []byte) ([]byte, error) {
+		origAlly, ok := origAllies[allyName(ally)]
+		if !ok {
+			origAlly = []byte("{}")
+		}
+		return restoreSecret(ally, origAlly, []string{"Secret"})
+	})
+}
+
+// redactSecret replaces the secret at path in doc, if set, by RedactedSecret.
+func redactSecret(doc []byte, path []string) ([]byte, error) {
+	raw, ok, err := getJSONPath(doc, path)
+	if err != nil {
+		return nil, err
+	}
+	var value string
+	if !ok || json.Unmarshal(raw, &value) != nil || value == "" {
+		return doc, nil
+	}
+	return setJSONPath(doc, path, RedactedSecret)
+}
+
+// restoreSecret sets the secret at path in doc, if still RedactedSecret,
+// back to its value in orig, removing it if orig has none.
+func restoreSecret(doc, orig []byte, path []string) ([]byte, error) {
+	raw, ok, err := getJSONPath(doc, path)
+	if err != nil {
+		return nil, err
+	}
+	var value string
+	if !ok || json.Unmarshal(raw, &value) != nil || value != RedactedSecret {
+		return doc, nil
+	}
+	secret, ok, err := getJSONPath(orig, path)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return deleteJSONPath(doc, path)
+	}
+	return setJSONPath(doc, path, secret)
+}
+
+// editAllies returns doc with each allied server object replaced by what
+// edit returns for it. Anything else in the list is left alone.
+func editAllies(doc []byte, edit func(ally []byte) ([]byte, error)) ([]byte, error) {
+	raw, ok, err := getJSONPath(doc, alliesPath)
+	if err != nil || !ok {
+		return doc, err
+	}
+	var allies []json.RawMessage
+	if json.Unmarshal(raw, &allies) != nil {
+		return doc, nil
+	}
+	for i, ally := range allies {
+		if !isJSONObject(ally) {
+			continue
+		}
+		if allies[i], err = edit(ally); err != nil {
+			return nil, err
+		}
+	}
+	return setJSONPath(doc, alliesPath, allies)
+}
+
+// allyName returns the Name of an allied server object.
+func allyName(ally []byte) string {
+	raw, _, _ := getJSONPath(ally, []string{"Name"})
+	var name string
+	_ = json.Unmarshal(raw, &name)
+	return name
+}
@@ -0,0 +1,194 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func TestLoadSecret(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "password")
+	if err := os.WriteFile(path, []byte("hunter2\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("ERUPE_TEST_SECRET", "from-env")
+
+	tests := []struct {
+		ref  string
+		want string
+	}{
+		{path, "hunter2"},
+		{"file:" + path, "hunter2"},
+		{"env:ERUPE_TEST_SECRET", "from-env"},
+	}
+	if runtime.GOOS != "windows" {
+		tests = append(tests, struct {
+			ref  string
+			want string
+		}{"exec:echo from-command", "from-command"})
+	}
+	for _, tt := range tests {
+		got, err := LoadSecret(tt.ref)
+		if err != nil {
+			t.Errorf("LoadSecret(%q) error: %v", tt.ref, err)
+		} else if got != tt.want {
+			t.Errorf("LoadSecret(%q) = %q, want %q", tt.ref, got, tt.want)
+		}
+	}
+
+	for _, ref := range []string{filepath.Join(dir, "missing"), "env:ERUPE_TEST_UNSET", "vault://secret/erupe"} {
+		if _, err := LoadSecret(ref); err == nil {
+			t.Errorf("LoadSecret(%q) succeeded", ref)
+		}
+	}
+}
+
+func TestLoadVaultSecret(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "token" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		switch r.URL.Path {
+		case "/v1/secret/data/erupe":
+			_, _ = w.Write([]byte(`{"data": {"data": {"db": "kv2"}, "metadata": {}}}`))
+		case "/v1/kv/erupe":
+			_, _ = w.Write([]byte(`{"data": {"db": "kv1"}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+	t.Setenv("VAULT_ADDR", srv.URL)
+	t.Setenv("VAULT_TOKEN", "token")
+
+	for ref, want := range map[string]string{"vault://secret/data/erupe#db": "kv2", "vault://kv/erupe#db": "kv1"} {
+		if got, err := LoadSecret(ref); err != nil || got != want {
+			t.Errorf("LoadSecret(%q) = %q, %v, want %q", ref, got, err, want)
+		}
+	}
+	for _, ref := range []string{"vault://secret/data/erupe#missing", "vault://secret/data/other#db"} {
+		if _, err := LoadSecret(ref); err == nil {
+			t.Errorf("LoadSecret(%q) succeeded", ref)
+		}
+	}
+}
+
+func TestLoadSecrets(t *testing.T) {
+	t.Setenv("ERUPE_TEST_TOKEN", "token")
+	c := &Config{
+		API:        API{AdminTokenFile: "env:ERUPE_TEST_TOKEN"},
+		Federation: FederationOptions{Allies: []FederationAlly{{Name: "a", SecretFile: "env:ERUPE_TEST_TOKEN"}}},
+	}
+	if err := c.loadSecrets(); err != nil {
+		t.Fatalf("loadSecrets() error: %v", err)
+	}
+	if c.API.AdminToken != "token" {
+		t.Errorf("AdminToken = %q", c.API.AdminToken)
+	}
+	if c.Federation.Allies[0].Secret != "token" {
+		t.Errorf("Allies[0].Secret = %q", c.Federation.Allies[0].Secret)
+	}
+
+	c = &Config{
+		Database: Database{Password: "inline", PasswordFile: "env:ERUPE_TEST_TOKEN"},
+		Discord:  Discord{BotTokenFile: "env:ERUPE_TEST_UNSET"},
+	}
+	var verr *ValidationError
+	if err := c.loadSecrets(); !errors.As(err, &verr) || len(verr.Problems) != 2 {
+		t.Fatalf("loadSecrets() = %v, want 2 problems", err)
+	}
+	if verr.Problems[0].Key != "Database.PasswordFile" || verr.Problems[1].Key != "Discord.BotTokenFile" {
+		t.Errorf("problems = %v", verr.Problems)
+	}
+}
+
+func TestLoadConfigReadsSecretFiles(t *testing.T) {
+	viper.Reset()
+	dir := t.TempDir()
+	origDir, _ := os.Getwd()
+	defer func() { _ = os.Chdir(origDir) }()
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "db_password"), []byte("secret\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	writeMinimalConfig(t, dir, `{"Host": "127.0.0.1", "Database": {"PasswordFile": "db_password"}}`)
+	c, err := LoadConfig()
+	if err != nil {
+		t.Fatalf("LoadConfig() error: %v", err)
+	}
+	if c.Database.Password != "secret" {
+		t.Errorf("Password = %q, want secret", c.Database.Password)
+	}
+}
+
+func TestRedactSecrets(t *testing.T) {
+	orig := []byte(`{"Host": "127.0.0.1", "database": {"User": "postgres", "password": "hunter2"}, "Discord": {"BotToken": "", "BotTokenFile": "env:BOT"}, "Cluster": {"Secret": "s3cret"}}`)
+	redacted, err := RedactSecrets(orig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, secret := range []string{"hunter2", "s3cret"} {
+		if strings.Contains(string(redacted), secret) {
+			t.Errorf("redacted config still holds %q: %s", secret, redacted)
+		}
+	}
+	if ref, _, _ := getJSONPath(redacted, []string{"Discord", "BotTokenFile"}); string(ref) != `"env:BOT"` {
+		t.Errorf("secret reference was redacted: %s", redacted)
+	}
+
+	// The operator changes the cluster secret and keeps the password.
+	edited, err := setJSONPath(redacted, []string{"Cluster", "Secret"}, "n3w")
+	if err != nil {
+		t.Fatal(err)
+	}
+	restored, err := RestoreSecrets(edited, orig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got, want map[string]any
+	_ = json.Unmarshal(restored, &got)
+	_ = json.Unmarshal([]byte(`{"Host": "127.0.0.1", "database": {"User": "postgres", "password": "hunter2"}, "Discord": {"BotToken": "", "BotTokenFile": "env:BOT"}, "Cluster": {"Secret": "n3w"}}`), &want)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("restored config = %s, want %v", restored, want)
+	}
+}
+
+func TestRedactAllySecrets(t *testing.T) {
+	orig := []byte(`{"Federation": {"Allies": [{"Name": "a", "Secret": "alpha"}, {"Name": "b", "Secret": "bravo"}]}}`)
+	redacted, err := RedactSecrets(orig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, secret := range []string{"alpha", "bravo"} {
+		if strings.Contains(string(redacted), secret) {
+			t.Errorf("redacted config still holds %q: %s", secret, redacted)
+		}
+	}
+
+	// The operator drops a, puts c before b and keeps b's secret.
+	edited := []byte(`{"Federation": {"Allies": [{"Name": "c", "Secret": "` + RedactedSecret + `"}, {"Name": "b", "Secret": "` + RedactedSecret + `"}]}}`)
+	restored, err := RestoreSecrets(edited, orig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got, want map[string]any
+	_ = json.Unmarshal(restored, &got)
+	_ = json.Unmarshal([]byte(`{"Federation": {"Allies": [{"Name": "c"}, {"Name": "b", "Secret": "bravo"}]}}`), &want)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("restored config = %s, want %v", restored, want)
+	}
+}
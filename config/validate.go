@@ -0,0 +1,293 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// Problem is one invalid setting, named by its key path in config.json.
+type Problem struct {
+	Key     string
+	Message string
+}
+
+func (p Problem) String() string {
+	return p.Key + ": " + p.Message
+}
+
+// ValidationError lists every invalid setting of a config, so they can all
+// be fixed at once.
+type ValidationError struct {
+	Problems []Problem
+}
+
+func (e *ValidationError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "invalid config, %d problem(s):", len(e.Problems))
+	for _, p := range e.Problems {
+		b.WriteString("\n  ")
+		b.WriteString(p.String())
+	}
+	return b.String()
+}
+
+// validator collects the problems of a config.
+type validator struct {
+	problems []Problem
+}
+
+func (v *validator) add(key, format string, args ...any) {
+	v.problems = append(v.problems, Problem{Key: key, Message: fmt.Sprintf(format, args...)})
+}
+
+// err returns the problems as a *ValidationError, or nil without any.
+func (v *validator) err() error {
+	if len(v.problems) == 0 {
+		return nil
+	}
+	return &ValidationError{Problems: v.problems}
+}
+
+// nonNegative adds a problem for each key whose value is negative.
+func (v *validator) nonNegative(values map[string]int) {
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	for _, k := range keys {
+		if values[k] < 0 {
+			v.add(k, "cannot be negative, got %d", values[k])
+		}
+	}
+}
+
+func (v *validator) oneOf(key, value string, allowed ...string) {
+	if !slices.Contains(allowed, strings.ToLower(value)) {
+		v.add(key, "unknown value %q, want one of %s", value, strings.Join(allowed, ", "))
+	}
+}
+
+func (v *validator) ratio(key string, value float64) {
+	if value < 0 || value > 1 {
+		v.add(key, "must be between 0 and 1, got %g", value)
+	}
+}
+
+// ports tracks the listener ports in use so two listeners cannot share one.
+type ports struct {
+	v     *validator
+	owner map[int]string
+}
+
+func (p *ports) use(key string, port int) {
+	if port < 1 || port > 65535 {
+		p.v.add(key, "port %d is not between 1 and 65535", port)
+		return
+	}
+	if other, ok := p.owner[port]; ok {
+		p.v.add(key, "port %d is already used by %s", port, other)
+		return
+	}
+	p.owner[port] = key
+}
+
+// logLevels are the levels Logging accepts.
+var logLevels = []string{"debug", "info", "warn", "error", "dpanic", "panic", "fatal"}
+
+// Validate checks the settings of c and returns a *ValidationError listing
+// every problem found, or nil. Features that are disabled are not checked.
+func (c *Config) Validate() error {
+	v := &validator{}
+
+	mode := strings.TrimSuffix(c.ClientMode, " (Debug only)")
+	if _, ok := ParseMode(mode); mode != "" && !ok {
+		v.add("ClientMode", "unknown client version %q, want one of %s", c.ClientMode, strings.Join(versionStrings, ", "))
+	}
+	if c.Database.Port < 1 || c.Database.Port > 65535 {
+		v.add("Database.Port", "port %d is not between 1 and 65535", c.Database.Port)
+	}
+
+	p := &ports{v: v, owner: make(map[int]string)}
+	if c.Sign.Enabled {
+		p.use("Sign.Port", c.Sign.Port)
+	}
+	if c.API.Enabled {
+		p.use("API.Port", c.API.Port)
+	}
+	if c.Entrance.Enabled {
+		p.use("Entrance.Port", int(c.Entrance.Port))
+	}
+	var channelPorts []uint16
+	for i, e := range c.Entrance.Entries {
+		for j, ch := range e.Channels {
+			channelPorts = append(channelPorts, ch.Port)
+			if c.Channel.Enabled && ch.IsEnabled() && c.Cluster.RunsChannel(ch.Port) {
+				p.use(fmt.Sprintf("Entrance.Entries[%d].Channels[%d].Port", i, j), int(ch.Port))
+			}
+		}
+	}
+	if c.Proxy.Enabled {
+		v.oneOf("Proxy.Compression", c.Proxy.Compression, "", "none", "gzip", "zstd")
+		for i, r := range c.Proxy.Routes {
+			key := fmt.Sprintf("Proxy.Routes[%d]", i)
+			p.use(key+".Port", int(r.Port))
+			v.oneOf(key+".Server", r.Server, "sign", "entrance", "channel")
+			if r.Upstream == "" {
+				v.add(key+".Upstream", "is empty")
+			}
+		}
+	}
+
+	if c.ProxyProtocol.Enabled && len(c.ProxyProtocol.TrustedProxies) == 0 {
+		v.add("ProxyProtocol.TrustedProxies", "is empty, which would let any client claim any address")
+	}
+
+	if c.Discord.Enabled && c.Discord.Registration.Enabled && c.Discord.Registration.GuildID == "" {
+		v.add("Discord.Registration.GuildID", "is empty, which would let anyone able to message the bot register")
+	}
+
+	if c.Cluster.Enabled {
+		if c.Cluster.Secret == "" {
+			v.add("Cluster.Secret", "is empty")
+		}
+		if c.Cluster.Listen == "" {
+			v.add("Cluster.Listen", "is empty")
+		}
+		for i, port := range c.Cluster.Channels {
+			if !slices.Contains(channelPorts, port) {
+				v.add(fmt.Sprintf("Cluster.Channels[%d]", i), "port %d is not a channel in Entrance.Entries", port)
+			}
+		}
+	}
+
+	if c.Federation.Enabled {
+		if c.Federation.Name == "" {
+			v.add("Federation.Name", "is empty")
+		}
+		if c.Federation.Listen == "" {
+			v.add("Federation.Listen", "is empty")
+		}
+		allies := make(map[string]bool)
+		for i, a := range c.Federation.Allies {
+			key := fmt.Sprintf("Federation.Allies[%d]", i)
+			switch {
+			case a.Name == "":
+				v.add(key+".Name", "is empty")
+			case a.Name == c.Federation.Name:
+				v.add(key+".Name", "%q is this server's own name", a.Name)
+			case allies[a.Name]:
+				v.add(key+".Name", "%q is listed twice", a.Name)
+			}
+			allies[a.Name] = true
+			if a.Address == "" {
+				v.add(key+".Address", "is empty")
+			}
+			if a.Secret == "" {
+				v.add(key+".Secret", "is empty")
+			}
+		}
+		for i, g := range c.Federation.Guilds {
+			switch {
+			case g.Peer == "":
+				v.add(fmt.Sprintf("Federation.Guilds[%d].Peer", i), "is empty")
+			case !allies[g.Peer]:
+				v.add(fmt.Sprintf("Federation.Guilds[%d].Peer", i), "%q is not in Federation.Allies", g.Peer)
+			}
+		}
+	}
+
+	v.nonNegative(map[string]int{
+		"Channel.KeepaliveTimeout":    c.Channel.KeepaliveTimeout,
+		"Channel.IdleTimeout":         c.Channel.IdleTimeout,
+		"Channel.IdleWarning":         c.Channel.IdleWarning,
+		"Channel.MaxPacketViolations": c.Channel.MaxPacketViolations,
+		"Channel.SaveWorkers":         c.Channel.SaveWorkers,
+		"Shutdown.Countdown":          c.Shutdown.Countdown,
+		"Shutdown.QuestTimeout":       c.Shutdown.QuestTimeout,
+		"Capture.RotateSizeMB":        c.Capture.RotateSizeMB,
+		"Capture.RotateMinutes":       c.Capture.RotateMinutes,
+		"Capture.MaxTotalMB":          c.Capture.MaxTotalMB,
+	})
+	if c.Capture.Enabled {
+		v.oneOf("Capture.Compression", c.Capture.Compression, "", "none", "gzip", "zstd")
+	}
+	v.oneOf("Channel.PacketValidation", c.Channel.PacketValidation, "", "reject", "log", "off")
+
+	if c.Backup.Enabled && c.Backup.Interval < 1 {
+		v.add("Backup.Interval", "must be at least 1 hour, got %d", c.Backup.Interval)
+	}
+	if c.Tracing.Enabled {
+		v.oneOf("Tracing.Exporter", c.Tracing.Exporter, "otlp", "stdout")
+		v.ratio("Tracing.SampleRatio", c.Tracing.SampleRatio)
+	}
+	if c.ErrorReporting.DSN != "" {
+		v.ratio("ErrorReporting.SampleRate", c.ErrorReporting.SampleRate)
+	}
+
+	validateLogTarget(v, "Logging", LogTarget{Level: c.Logging.Level, Format: c.Logging.Format})
+	for name, t := range c.Logging.Subsystems {
+		validateLogTarget(v, "Logging.Subsystems."+name, t)
+	}
+
+	checkReloadable(v, c)
+	return v.err()
+}
+
+func validateLogTarget(v *validator, key string, t LogTarget) {
+	if t.Level != "" {
+		v.oneOf(key+".Level", t.Level, logLevels...)
+	}
+	if t.Format != "" {
+		v.oneOf(key+".Format", t.Format, "console", "json")
+	}
+}
+
+// checkReloadable checks the settings a reload may change.
+func checkReloadable(v *validator, c *Config) {
+	prefixes := make(map[string]string)
+	for i, cmd := range c.Commands {
+		key := fmt.Sprintf("Commands[%d]", i)
+		if cmd.Name == "" || cmd.Prefix == "" {
+			v.add(key, "command %q needs a name and a prefix", cmd.Name)
+			continue
+		}
+		if other, ok := prefixes[cmd.Prefix]; ok && cmd.Enabled {
+			v.add(key+".Prefix", "commands %s and %s share the prefix %q", other, cmd.Name, cmd.Prefix)
+		}
+		if cmd.Enabled {
+			prefixes[cmd.Prefix] = cmd.Name
+		}
+	}
+	if c.CommandPrefix == "" {
+		v.add("CommandPrefix", "is empty")
+	}
+
+	checkGameplay(v, c.GameplayOptions)
+}
+
+// checkGameplay checks the gameplay modifiers.
+func checkGameplay(v *validator, g GameplayOptions) {
+	v.nonNegative(map[string]int{
+		"GameplayOptions.MinFeatureWeapons": g.MinFeatureWeapons,
+		"GameplayOptions.MaxFeatureWeapons": g.MaxFeatureWeapons,
+		"GameplayOptions.MaximumNP":         g.MaximumNP,
+		"GameplayOptions.BoostTimeDuration": g.BoostTimeDuration,
+		"GameplayOptions.ClanMealDuration":  g.ClanMealDuration,
+		"GameplayOptions.MezFesDuration":    g.MezFesDuration,
+	})
+	// Every float is a rate or a reward multiplier.
+	rv := reflect.ValueOf(g)
+	for i := 0; i < rv.NumField(); i++ {
+		if f := rv.Field(i); f.Kind() == reflect.Float32 && f.Float() < 0 {
+			v.add("GameplayOptions."+rv.Type().Field(i).Name, "cannot be negative, got %g", f.Float())
+		}
+	}
+	for i, limit := range g.ClanMemberLimits {
+		if len(limit) != 2 {
+			v.add(fmt.Sprintf("GameplayOptions.ClanMemberLimits[%d]", i), "%v is not a [Rank, Members] pair", limit)
+		}
+	}
+}
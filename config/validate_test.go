@@ -0,0 +1,160 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func validConfig() *Config {
+	return &Config{
+		ClientMode:    "ZZ",
+		CommandPrefix: "!",
+		Database:      Database{Port: 5432},
+		Sign:          Sign{Enabled: true, Port: 53312},
+		API:           API{Enabled: true, Port: 8080},
+		Channel:       Channel{Enabled: true},
+		Entrance: Entrance{Enabled: true, Port: 53310, Entries: []EntranceServerInfo{
+			{Name: "World", Channels: []EntranceChannelInfo{{Port: 54001}, {Port: 54002}}},
+		}},
+	}
+}
+
+func TestValidateValid(t *testing.T) {
+	if err := validConfig().Validate(); err != nil {
+		t.Errorf("Validate() error: %v", err)
+	}
+	c := validConfig()
+	c.ClientMode = "G1 (Debug only)"
+	if err := c.Validate(); err != nil {
+		t.Errorf("Validate() rejected a normalized debug mode: %v", err)
+	}
+}
+
+func TestValidateProblems(t *testing.T) {
+	disabled := false
+	tests := []struct {
+		name    string
+		modify  func(c *Config)
+		wantKey string
+	}{
+		{"client mode", func(c *Config) { c.ClientMode = "ZZZ" }, "ClientMode"},
+		{"database port", func(c *Config) { c.Database.Port = 70000 }, "Database.Port"},
+		{"sign port zero", func(c *Config) { c.Sign.Port = 0 }, "Sign.Port"},
+		{"shared port", func(c *Config) { c.API.Port = 53312 }, "API.Port"},
+		{"duplicate channel", func(c *Config) { c.Entrance.Entries[0].Channels[1].Port = 54001 }, "Entrance.Entries[0].Channels[1].Port"},
+		{"negative multiplier", func(c *Config) { c.GameplayOptions.ZennyMultiplier = -1 }, "GameplayOptions.ZennyMultiplier"},
+		{"negative timeout", func(c *Config) { c.Channel.IdleTimeout = -5 }, "Channel.IdleTimeout"},
+		{"packet validation", func(c *Config) { c.Channel.PacketValidation = "strict" }, "Channel.PacketValidation"},
+		{"shared prefix", func(c *Config) {
+			c.Commands = []Command{{Name: "A", Prefix: "x", Enabled: true}, {Name: "B", Prefix: "x", Enabled: true}}
+		}, "Commands[1].Prefix"},
+		{"log level", func(c *Config) { c.Logging.Subsystems = map[string]LogTarget{"sign": {Level: "loud"}} }, "Logging.Subsystems.sign.Level"},
+		{"tracing ratio", func(c *Config) { c.Tracing = TracingOptions{Enabled: true, Exporter: "otlp", SampleRatio: 2} }, "Tracing.SampleRatio"},
+		{"backup interval", func(c *Config) { c.Backup.Enabled = true }, "Backup.Interval"},
+		{"discord registration guild", func(c *Config) {
+			c.Discord.Enabled = true
+			c.Discord.Registration = DiscordRegistration{Enabled: true}
+		}, "Discord.Registration.GuildID"},
+		{"cluster secret", func(c *Config) { c.Cluster = ClusterOptions{Enabled: true, Listen: ":54100"} }, "Cluster.Secret"},
+		{"proxy protocol without trusted proxies", func(c *Config) { c.ProxyProtocol = ProxyProtocolOptions{Enabled: true} }, "ProxyProtocol.TrustedProxies"},
+		{"cluster channel", func(c *Config) {
+			c.Cluster = ClusterOptions{Enabled: true, Listen: ":54100", Secret: "s", Channels: []uint16{54009}}
+		}, "Cluster.Channels[0]"},
+		{"federation name", func(c *Config) {
+			c.Federation = FederationOptions{Enabled: true, Listen: ":54200"}
+		}, "Federation.Name"},
+		{"federation ally secret", func(c *Config) {
+			c.Federation = FederationOptions{Enabled: true, Name: "a", Listen: ":54200", Allies: []FederationAlly{{Name: "b", Address: "b:54200"}}}
+		}, "Federation.Allies[0].Secret"},
+		{"federation ally named twice", func(c *Config) {
+			c.Federation = FederationOptions{Enabled: true, Name: "a", Listen: ":54200", Allies: []FederationAlly{
+				{Name: "b", Address: "b:54200", Secret: "ab"},
+				{Name: "b", Address: "c:54200", Secret: "ac"},
+			}}
+		}, "Federation.Allies[1].Name"},
+		{"federation ally named as this server", func(c *Config) {
+			c.Federation = FederationOptions{Enabled: true, Name: "a", Listen: ":54200", Allies: []FederationAlly{{Name: "a", Address: "b:54200", Secret: "ab"}}}
+		}, "Federation.Allies[0].Name"},
+		{"federation guild peer", func(c *Config) {
+			c.Federation = FederationOptions{Enabled: true, Name: "a", Listen: ":54200", Guilds: []FederationGuild{{GuildID: 1}}}
+		}, "Federation.Guilds[0].Peer"},
+		{"federation guild peer not an ally", func(c *Config) {
+			c.Federation = FederationOptions{Enabled: true, Name: "a", Listen: ":54200", Guilds: []FederationGuild{{GuildID: 1, Peer: "b", PeerGuildID: 2}}}
+		}, "Federation.Guilds[0].Peer"},
+		{"proxy route", func(c *Config) {
+			c.Proxy = ProxyOptions{Enabled: true, Routes: []ProxyRoute{{Server: "api", Port: 9000}}}
+		}, "Proxy.Routes[0].Server"},
+		{"proxy compression", func(c *Config) {
+			c.Proxy = ProxyOptions{Enabled: true, Compression: "lz4"}
+		}, "Proxy.Compression"},
+		{"capture compression", func(c *Config) {
+			c.Capture = CaptureOptions{Enabled: true, Compression: "xz"}
+		}, "Capture.Compression"},
+		{"capture rotation", func(c *Config) {
+			c.Capture = CaptureOptions{RotateSizeMB: -1}
+		}, "Capture.RotateSizeMB"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := validConfig()
+			tt.modify(c)
+			var verr *ValidationError
+			if err := c.Validate(); !errors.As(err, &verr) {
+				t.Fatalf("Validate() = %v, want a ValidationError", err)
+			}
+			for _, p := range verr.Problems {
+				if p.Key == tt.wantKey {
+					return
+				}
+			}
+			t.Errorf("problems = %v, want one for %s", verr.Problems, tt.wantKey)
+		})
+	}
+
+	t.Run("disabled listeners are not checked", func(t *testing.T) {
+		c := validConfig()
+		c.Sign = Sign{}
+		c.Entrance.Entries[0].Channels[1] = EntranceChannelInfo{Port: 54001, Enabled: &disabled}
+		if err := c.Validate(); err != nil {
+			t.Errorf("Validate() error: %v", err)
+		}
+	})
+}
+
+func TestValidateReportsAllProblems(t *testing.T) {
+	c := validConfig()
+	c.ClientMode = "nope"
+	c.Sign.Port = 0
+	c.CommandPrefix = ""
+	err := c.Validate()
+	var verr *ValidationError
+	if !errors.As(err, &verr) || len(verr.Problems) != 3 {
+		t.Fatalf("Validate() = %v, want 3 problems", err)
+	}
+	for _, key := range []string{"ClientMode:", "Sign.Port:", "CommandPrefix:"} {
+		if !strings.Contains(err.Error(), key) {
+			t.Errorf("error does not mention %s:\n%s", key, err)
+		}
+	}
+}
+
+func TestLoadConfigValidates(t *testing.T) {
+	viper.Reset()
+	dir := t.TempDir()
+	origDir, _ := os.Getwd()
+	defer func() { _ = os.Chdir(origDir) }()
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	writeMinimalConfig(t, dir, `{"Host": "127.0.0.1", "ClientMode": "Z3", "Sign": {"Port": 0}}`)
+	_, err := LoadConfig()
+	var verr *ValidationError
+	if !errors.As(err, &verr) || len(verr.Problems) != 2 {
+		t.Errorf("LoadConfig() = %v, want 2 problems", err)
+	}
+}
@@ -1,29 +1,53 @@
 package main
 
 import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
 	cfg "erupe-ce/config"
 	"flag"
 	"fmt"
-	"net"
 	"os"
 	"os/signal"
 	"path/filepath"
 	"runtime/debug"
+	"sync"
 	"syscall"
 	"time"
 
 	"erupe-ce/common/gametime"
+	"erupe-ce/network"
+	"erupe-ce/network/crypto"
+	"erupe-ce/network/pcap"
+	"erupe-ce/server/analytics"
 	"erupe-ce/server/api"
+	"erupe-ce/server/backup"
 	"erupe-ce/server/channelserver"
+	"erupe-ce/server/cluster"
+	"erupe-ce/server/console"
+	"erupe-ce/server/debugserver"
 	"erupe-ce/server/discordbot"
 	"erupe-ce/server/entranceserver"
+	"erupe-ce/server/errreport"
+	"erupe-ce/server/eventbus"
+	"erupe-ce/server/federation"
+	"erupe-ce/server/logging"
 	"erupe-ce/server/migrations"
+	"erupe-ce/server/opmetrics"
+	"erupe-ce/server/proxyserver"
+	"erupe-ce/server/questcache"
+	"erupe-ce/server/savedump"
+	"erupe-ce/server/scheduler"
+	"erupe-ce/server/sessionlog"
 	"erupe-ce/server/setup"
 	"erupe-ce/server/signserver"
+	"erupe-ce/server/slowquery"
+	"erupe-ce/server/status"
+	"erupe-ce/server/tracing"
 	"strings"
 
 	"github.com/jmoiron/sqlx"
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 	"go.uber.org/zap"
 )
 
@@ -56,6 +80,11 @@ func setupDiscordBot(config *cfg.Config, logger *zap.Logger) *discordbot.Discord
 		preventClose(config, fmt.Sprintf("Discord: Failed to start, %s", err.Error()))
 	}
 
+	// Webhook-only mode posts over HTTP and never connects to the gateway.
+	if config.Discord.WebhookOnly {
+		return bot
+	}
+
 	// Discord bot
 	err = bot.Start()
 
@@ -72,7 +101,7 @@ func setupDiscordBot(config *cfg.Config, logger *zap.Logger) *discordbot.Discord
 }
 
 func main() {
-	runSetup := flag.Bool("setup", false, "Launch the setup wizard (even if config.json exists)")
+	runSetup := flag.Bool("setup", false, "Launch the setup wizard, to edit config.json if it exists")
 	flag.Parse()
 
 	var err error
@@ -106,37 +135,97 @@ func main() {
 		}
 	}
 
+	// Switch from the startup logger to the one described by the Logging config.
+	configured, closeLogs, err := logging.New(config.Logging)
+	if err != nil {
+		preventClose(config, fmt.Sprintf("Logging: Failed to start, %s", err.Error()))
+	}
+	defer func() { _ = closeLogs() }()
+
+	// Report panics and error logs to the error tracker, if one is configured.
+	flushErrors, err := errreport.Setup(config.ErrorReporting, fmt.Sprintf("erupe@9.3b-%s", Commit()))
+	if err != nil {
+		preventClose(config, fmt.Sprintf("ErrorReporting: Failed to start, %s", err.Error()))
+	}
+	defer flushErrors()
+	if config.ErrorReporting.DSN != "" {
+		configured = configured.WithOptions(errreport.LoggerOption())
+	}
+	// Keep recent errors for the admin console.
+	var consoleErrors *console.ErrorLog
+	if config.Console.Enabled {
+		consoleErrors = console.NewErrorLog(100)
+		configured = configured.WithOptions(consoleErrors.LoggerOption())
+	}
+	_ = zapLogger.Sync()
+	zapLogger = configured
+	logger = zapLogger.Named("main")
+
 	logger.Info(fmt.Sprintf("Starting Erupe (9.3b-%s)", Commit()))
+	for _, note := range config.Upgrades() {
+		logger.Info("Config: Upgraded config file", zap.String("change", note))
+	}
 	logger.Info(fmt.Sprintf("Client Mode: %s (%d)", config.ClientMode, config.RealClientMode))
+	if fi := config.DebugOptions.FaultInjection; fi.Enabled {
+		logger.Warn("Fault injection is enabled, client connections will be degraded",
+			zap.Int64("seed", fi.Seed), zap.Int("latencyMs", fi.LatencyMs), zap.Int("jitterMs", fi.JitterMs),
+			zap.Float64("truncateRate", fi.TruncateRate), zap.Float64("disconnectRate", fi.DisconnectRate))
+	}
 
 	if config.Database.Password == "" {
 		preventClose(config, "Database password is blank")
 	}
 
-	if net.ParseIP(config.Host) == nil {
-		ips, _ := net.LookupIP(config.Host)
-		for _, ip := range ips {
-			if ip != nil {
-				config.Host = ip.String()
-				break
-			}
-		}
-		if net.ParseIP(config.Host) == nil {
-			preventClose(config, "Invalid host address")
+	// Worlds and channels are advertised as 4 byte IPv4 addresses.
+	host, err := network.ResolveIPv4(config.Host)
+	if err != nil {
+		preventClose(config, fmt.Sprintf("Invalid host address %q: %s; put IPv6 addresses in HostV6", config.Host, err.Error()))
+	}
+	config.Host = host
+	if config.HostV6 != "" && !network.IsIPv6Literal(config.HostV6) {
+		preventClose(config, fmt.Sprintf("Invalid HostV6 address %q: not an IPv6 address", config.HostV6))
+	}
+	for _, entry := range config.Entrance.Entries {
+		if entry.IP != "" && network.IPv4LE(entry.IP) == 0 {
+			preventClose(config, fmt.Sprintf("Entrance: World %q has IP %q, which is not an IPv4 address", entry.Name, entry.IP))
 		}
 	}
 
+	// Event bus for in-game announcements
+	events := eventbus.New()
+
 	// Discord bot
 	var discordBot *discordbot.DiscordBot = nil
 
 	if config.Discord.Enabled {
-		discordBot = setupDiscordBot(config, logger)
+		discordBot = setupDiscordBot(config, logger.Named("discord"))
+		discordBot.SubscribeEvents(events)
 
 		logger.Info("Discord: Started successfully")
 	} else {
 		logger.Info("Discord: Disabled")
 	}
 
+	// Start tracing before opening the database so its queries are traced.
+	stopTracing, err := tracing.Setup(context.Background(), config.Tracing)
+	if err != nil {
+		preventClose(config, fmt.Sprintf("Tracing: Failed to start, %s", err.Error()))
+	}
+	if config.Tracing.Enabled {
+		logger.Info("Tracing: Started successfully", zap.String("exporter", config.Tracing.Exporter))
+	}
+
+	// Per-opcode handler and slow query metrics, recorded by the channel
+	// servers and the database pools and served by the API.
+	opMetrics := opmetrics.New()
+	var slowQueries *slowquery.Monitor
+	if config.Database.SlowQueryThreshold > 0 {
+		slowQueries = slowquery.New(time.Duration(config.Database.SlowQueryThreshold)*time.Millisecond, logger.Named("db"), opMetrics.SlowQuery)
+	}
+	openDB := func(dsn string) (*sqlx.DB, error) {
+		return openPostgres(dsn, slowQueries, config.Tracing.Enabled)
+	}
+
 	// Create the postgres DB pool.
 	connectString := fmt.Sprintf(
 		"host='%s' port='%d' user='%s' password='%s' dbname='%s' sslmode=disable",
@@ -147,7 +236,7 @@ func main() {
 		config.Database.Database,
 	)
 
-	db, err := sqlx.Open("postgres", connectString)
+	db, err := openDB(connectString)
 	if err != nil {
 		preventClose(config, fmt.Sprintf("Database: Failed to open, %s", err.Error()))
 	}
@@ -159,13 +248,32 @@ func main() {
 	}
 
 	// Configure connection pool to avoid exhausting PostgreSQL under load.
-	db.SetMaxOpenConns(50)
-	db.SetMaxIdleConns(10)
-	db.SetConnMaxLifetime(5 * time.Minute)
-	db.SetConnMaxIdleTime(2 * time.Minute)
+	configurePool(db, config.Database)
 
 	logger.Info("Database: Started successfully")
 
+	// Read replicas serve read-only channel queries; an unreachable replica
+	// is skipped so reads fall back to the remaining pools.
+	var replicas []*sqlx.DB
+	for i, dsn := range config.Database.Replicas {
+		replica, err := openDB(dsn)
+		if err == nil {
+			err = replica.Ping()
+		}
+		if err != nil {
+			logger.Warn("Database: Skipping read replica", zap.Int("replica", i), zap.Error(err))
+			if replica != nil {
+				_ = replica.Close()
+			}
+			continue
+		}
+		configurePool(replica, config.Database)
+		replicas = append(replicas, replica)
+	}
+	if len(replicas) > 0 {
+		logger.Info("Database: Read replicas started", zap.Int("count", len(replicas)))
+	}
+
 	// Run database migrations
 	verBefore, _ := migrations.Version(db)
 	applied, migErr := migrations.Migrate(db, logger.Named("migrations"))
@@ -177,6 +285,41 @@ func main() {
 		logger.Info(fmt.Sprintf("Database: Applied %d migration(s), now at version %d", applied, ver))
 	}
 
+	compression, err := channelserver.CompressionFor(config.Compression)
+	if err != nil {
+		preventClose(config, fmt.Sprintf("Compression: %s", err.Error()))
+	}
+
+	if _, err := channelserver.ParsePacketValidation(config.Channel.PacketValidation); err != nil {
+		preventClose(config, fmt.Sprintf("Channel: %s", err.Error()))
+	}
+
+	// One limiter for every listener, so a client is limited across them all.
+	var connLimiter *network.ConnLimiter
+	if config.ConnectionLimits.Enabled {
+		connLimiter = network.NewConnLimiter(network.ConnLimitOptionsFromConfig(config.ConnectionLimits), opMetrics.ConnRejected)
+	}
+
+	ciphers := make(map[string]crypto.Cipher)
+	for _, listener := range []string{"sign", "entrance", "channel"} {
+		c, err := network.CipherFor(config.PacketCrypto, listener)
+		if err != nil {
+			preventClose(config, fmt.Sprintf("PacketCrypto: %s", err.Error()))
+		}
+		if c != crypto.Default {
+			logger.Warn("PacketCrypto: Listener does not use the retail cipher, retail clients cannot connect to it", zap.String("listener", listener))
+		}
+		ciphers[listener] = c
+	}
+
+	// Saves written before savedata_mode was tracked are assumed to be in
+	// the mode the server runs now, so a later ClientMode change migrates them.
+	if stamped, err := channelserver.NewCharacterRepository(db).StampSaveDataMode(config.RealClientMode); err != nil {
+		logger.Warn("Database: Failed to record savedata mode", zap.Error(err))
+	} else if stamped > 0 {
+		logger.Info(fmt.Sprintf("Database: Recorded client mode %d for %d existing save(s)", config.RealClientMode, stamped))
+	}
+
 	// Auto-apply seed data on a fresh database so users who skip the wizard
 	// still get shops, events, and gacha. Seed files use ON CONFLICT DO NOTHING
 	// so this is safe to run even if data already exists.
@@ -189,6 +332,19 @@ func main() {
 		}
 	}
 
+	// Discord features backed by game data need the database, migrated
+	// to the columns their jobs read.
+	stopRoleSync, stopNotifications, stopRecruitment, stopPresence := func() {}, func() {}, func() {}, func() {}
+	if discordBot != nil && !discordBot.WebhookOnly() {
+		discordBot.UseAccounts(discordbot.NewAccountRepository(db))
+		discordBot.UseGuilds(discordbot.NewGuildRepository(db))
+		discordBot.UseStatus(status.NewRepository(db))
+		stopRoleSync = discordBot.StartRoleSync()
+		stopNotifications = discordBot.StartNotifications()
+		stopRecruitment = discordBot.StartRecruitmentBoard()
+		stopPresence = discordBot.StartPresence()
+	}
+
 	// Pre-compute all server IDs this instance will own, so we only
 	// delete our own rows (safe for multi-instance on the same DB).
 	var ownedServerIDs []string
@@ -196,9 +352,11 @@ func main() {
 		si := 0
 		for _, ee := range config.Entrance.Entries {
 			ci := 0
-			for range ee.Channels {
+			for _, ce := range ee.Channels {
 				sid := (4096 + si*256) + (16 + ci)
-				ownedServerIDs = append(ownedServerIDs, fmt.Sprint(sid))
+				if config.Cluster.RunsChannel(ce.Port) {
+					ownedServerIDs = append(ownedServerIDs, fmt.Sprint(sid))
+				}
 				ci++
 			}
 			si++
@@ -244,6 +402,8 @@ func main() {
 				Logger:      logger.Named("entrance"),
 				ErupeConfig: config,
 				DB:          db,
+				ConnLimiter: connLimiter,
+				Cipher:      ciphers["entrance"],
 			})
 		err = entranceServer.Start()
 		if err != nil {
@@ -263,6 +423,8 @@ func main() {
 				Logger:      logger.Named("sign"),
 				ErupeConfig: config,
 				DB:          db,
+				ConnLimiter: connLimiter,
+				Cipher:      ciphers["sign"],
 			})
 		err = signServer.Start()
 		if err != nil {
@@ -273,14 +435,172 @@ func main() {
 		logger.Info("Sign: Disabled")
 	}
 
+	// Parsed quest files, shared by the channel servers and the API admin endpoints.
+	questCache := questcache.New(config.QuestCacheExpiry, config.QuestCacheMaxEntries, config.QuestCacheMaxBytes)
+
+	// Save dumps, written by the channel servers and restored by the API admin endpoints.
+	saveDumps := savedump.New(config.SaveDumps)
+
+	// Background jobs, run on their default schedules or those in Scheduler.Jobs.
+	jobs := scheduler.New(scheduler.NewRepository(db), config.Scheduler.Jobs, logger.Named("scheduler"))
+	registerJob := func(j scheduler.Job) {
+		if err := jobs.Register(j); err != nil {
+			preventClose(config, fmt.Sprintf("Scheduler: %s", err.Error()))
+		}
+	}
+
+	// Delete session events past their retention period.
+	if config.SessionEvents.Enabled && config.SessionEvents.RetentionDays > 0 {
+		retention := time.Duration(config.SessionEvents.RetentionDays) * 24 * time.Hour
+		registerJob(sessionlog.NewRepository(db).PruneJob(retention, logger.Named("sessionlog")))
+	}
+
+	// Archive every character on a schedule.
+	if config.Backup.Enabled {
+		backups, err := backup.New(db, config.Backup, logger.Named("backup"))
+		if err != nil {
+			preventClose(config, fmt.Sprintf("Backup: %s", err.Error()))
+		}
+		registerJob(backups.Job(time.Duration(config.Backup.Interval) * time.Hour))
+	}
+
+	// Free the memory of expired quests nobody has asked for again.
+	registerJob(scheduler.Job{
+		Name:     "quest-cache-purge",
+		Schedule: "*/10 * * * *",
+		Run: func(context.Context) error {
+			questCache.Purge()
+			return nil
+		},
+	})
+
+	// Delete packet captures past their retention period.
+	if config.Capture.Enabled && config.Capture.RetentionDays > 0 {
+		registerJob(scheduler.Job{
+			Name:     "capture-prune",
+			Schedule: "@daily",
+			Run: func(context.Context) error {
+				dir := config.Live().Capture.OutputDir
+				if dir == "" {
+					dir = "captures"
+				}
+				n, err := pcap.Prune(dir, time.Now().AddDate(0, 0, -config.Live().Capture.RetentionDays))
+				if n > 0 {
+					logger.Info("Capture: Deleted old capture files", zap.Int("deleted", n))
+				}
+				return err
+			},
+		})
+	}
+
+	// End Hunter's Festa events when they are over and schedule the next,
+	// unless DebugOptions.FestaOverride pins the event.
+	if config.Channel.Enabled {
+		festa := channelserver.NewFestaService(channelserver.NewFestaRepository(db), logger.Named("festa"))
+		registerJob(scheduler.Job{
+			Name:     "festa-rotate",
+			Schedule: "@hourly",
+			Run: func(context.Context) error {
+				if config.Live().DebugOptions.FestaOverride >= 0 {
+					return nil
+				}
+				_, err := festa.Rotate(channelserver.TimeAdjusted(), channelserver.TimeMidnight().Add(24*time.Hour))
+				return err
+			},
+		})
+	}
+	// Count the economy and activity into daily rollups.
+	var economy *analytics.Recorder
+	var economyStore *analytics.Repository
+	if config.Analytics.Enabled {
+		economy = analytics.NewRecorder()
+		economyStore = analytics.NewRepository(db)
+		registerJob(economy.RollupJob(economyStore))
+	}
+	stopJobs := jobs.Start()
+
+	stopMetricsLog := func() {}
+	if config.Channel.Enabled && config.Channel.MetricsLogInterval > 0 {
+		stopMetricsLog = opMetrics.StartLogSummary(logger.Named("metrics"), time.Duration(config.Channel.MetricsLogInterval)*time.Second)
+	}
+
+	// Config reload, run on SIGHUP and by the admin API.
+	var reloadMu sync.Mutex
+	reloadConfig := func() ([]cfg.Change, []string, error) {
+		reloadMu.Lock()
+		defer reloadMu.Unlock()
+		changes, restart, err := config.Reload()
+		if err != nil {
+			logger.Warn("Config: Reload failed, keeping the running config", zap.Error(err))
+			return nil, nil, err
+		}
+		for _, c := range changes {
+			if c.Setting == "Commands" {
+				channelserver.ReloadCommands(config.Live().Commands)
+			}
+			logger.Info("Config: Reloaded setting", zap.String("setting", c.Setting), zap.Any("old", c.Old), zap.Any("new", c.New))
+		}
+		if len(restart) > 0 {
+			logger.Warn("Config: Changed settings take effect on restart", zap.Strings("settings", restart))
+		}
+		logger.Info("Config: Reloaded", zap.Int("changed", len(changes)))
+		return changes, restart, nil
+	}
+
+	// Gameplay updates from the admin API, serialised with reloads.
+	updateGameplay := func(patch []byte, persist bool) ([]cfg.Change, bool, error) {
+		reloadMu.Lock()
+		defer reloadMu.Unlock()
+		changes, err := config.UpdateGameplay(patch)
+		if err != nil {
+			return nil, false, err
+		}
+		for _, c := range changes {
+			logger.Info("Config: Updated setting", zap.String("setting", c.Setting), zap.Any("old", c.Old), zap.Any("new", c.New))
+		}
+		if !persist || len(changes) == 0 {
+			return changes, false, nil
+		}
+		if err := config.Persist(changes); err != nil {
+			logger.Warn("Config: Failed to save updated settings, they last until restart", zap.Error(err))
+			return changes, false, nil
+		}
+		return changes, true, nil
+	}
+
+	// Federation with allied servers, started once the channels can
+	// deliver what they share.
+	var allies *federation.Federation
+	if config.Federation.Enabled {
+		allies = federation.New(config.Federation, logger.Named("federation"))
+	}
+
+	// Admin console, given the channels once they have started.
+	var adminConsole *console.Console
+	if config.Console.Enabled {
+		adminConsole = console.New(&console.Config{
+			Logger: logger.Named("console"),
+			Debug:  &config.DebugOptions,
+			Errors: consoleErrors,
+		})
+	}
+
 	// New Sign server
 	var ApiServer *api.APIServer
 	if config.API.Enabled {
 		ApiServer = api.NewAPIServer(
 			&api.Config{
-				Logger:      logger.Named("sign"),
-				ErupeConfig: config,
-				DB:          db,
+				Logger:         logger.Named("api"),
+				ErupeConfig:    config,
+				DB:             db,
+				QuestCache:     questCache,
+				OpMetrics:      opMetrics,
+				Federation:     allies,
+				Console:        adminConsole,
+				SaveDumps:      saveDumps,
+				Scheduler:      jobs,
+				ReloadConfig:   reloadConfig,
+				UpdateGameplay: updateGameplay,
 			})
 		err = ApiServer.Start()
 		if err != nil {
@@ -291,7 +611,29 @@ func main() {
 		logger.Info("API: Disabled")
 	}
 
+	// Savedata compression workers, shared so saves from any channel stay in order.
+	var saveWorkers *channelserver.SaveWorkerPool
+	if config.Channel.Enabled && config.Channel.SaveWorkers > 0 {
+		saveWorkers = channelserver.NewSaveWorkerPool(config.Channel.SaveWorkers)
+	}
+
+	var saveCache *channelserver.SaveDataCache
+	stopSaveFlush := func() {}
+	if config.Channel.Enabled && config.SaveCache.Enabled {
+		saveCache = channelserver.NewSaveDataCache(channelserver.NewCharacterRepository(db).WithCompression(compression), config.SaveCache.JournalDir, logger.Named("savecache"))
+		if err := saveCache.ReplayJournal(config.RealClientMode); err != nil {
+			preventClose(config, fmt.Sprintf("SaveCache: Failed to replay journal, %s", err.Error()))
+		}
+		interval := time.Duration(config.SaveCache.FlushInterval) * time.Second
+		if interval <= 0 {
+			interval = time.Minute
+		}
+		stopSaveFlush = saveCache.Start(interval)
+		logger.Info("SaveCache: Started successfully")
+	}
+
 	var channels []*channelserver.Server
+	var clusterBus *cluster.Bus
 
 	if config.Channel.Enabled {
 		channelQuery := ""
@@ -307,12 +649,29 @@ func main() {
 					count++
 					continue
 				}
+				if !config.Cluster.RunsChannel(ce.Port) {
+					logger.Info(fmt.Sprintf("Channel %d (%d): Run by another process", count, ce.Port))
+					ci++
+					count++
+					continue
+				}
 				c := *channelserver.NewServer(&channelserver.Config{
 					ID:          uint16(sid),
 					Logger:      logger.Named("channel-" + fmt.Sprint(count)),
 					ErupeConfig: config,
 					DB:          db,
+					ReadDBs:     replicas,
 					DiscordBot:  discordBot,
+					EventBus:    events,
+					SaveCache:   saveCache,
+					QuestCache:  questCache,
+					OpMetrics:   opMetrics,
+					Analytics:   economy,
+					Federation:  allies,
+					SaveWorkers: saveWorkers,
+					SaveDumps:   saveDumps,
+					ConnLimiter: connLimiter,
+					Cipher:      ciphers["channel"],
 				})
 				if ee.IP == "" {
 					c.IP = config.Host
@@ -342,35 +701,147 @@ func main() {
 		// Register all servers in DB
 		_ = db.MustExec(channelQuery)
 
-		registry := channelserver.NewLocalChannelRegistry(channels)
+		var registry channelserver.ChannelRegistry = channelserver.NewLocalChannelRegistry(channels)
+		if config.Cluster.Enabled {
+			node := config.Cluster.Node
+			if node == "" {
+				node, _ = os.Hostname()
+			}
+			clusterBus = cluster.New(node, config.Cluster.Secret, logger.Named("cluster"))
+			if err := clusterBus.Listen(config.Cluster.Listen); err != nil {
+				preventClose(config, fmt.Sprintf("Cluster: Failed to start, %s", err.Error()))
+			}
+			clusterBus.Connect(config.Cluster.Peers)
+			registry = channelserver.NewClusterChannelRegistry(channels, clusterBus)
+			logger.Info("Cluster: Started successfully", zap.String("node", node), zap.Int("peers", len(config.Cluster.Peers)))
+		}
 		for _, c := range channels {
 			c.Registry = registry
 		}
 	}
 
+	if allies != nil {
+		channelserver.ServeFederation(allies, channels)
+		if err := allies.Start(); err != nil {
+			preventClose(config, fmt.Sprintf("Federation: Failed to start, %s", err.Error()))
+		}
+		logger.Info("Federation: Started successfully", zap.String("name", config.Federation.Name), zap.Int("allies", len(config.Federation.Allies)))
+	}
+
+	if adminConsole != nil {
+		consoleChannels := make([]console.Channel, len(channels))
+		for i, c := range channels {
+			consoleChannels[i] = c
+		}
+		adminConsole.SetChannels(consoleChannels)
+		if config.Console.Socket != "" {
+			if err := adminConsole.Listen(config.Console.Socket); err != nil {
+				preventClose(config, fmt.Sprintf("Console: Failed to start, %s", err.Error()))
+			}
+			logger.Info("Console: Started successfully", zap.String("socket", config.Console.Socket))
+		}
+	}
+
+	// Debug server.
+
+	var debugServer *debugserver.Server
+	if config.DebugOptions.Pprof {
+		debugServer = debugserver.NewServer(&debugserver.Config{
+			Logger:  logger.Named("debug"),
+			Address: config.DebugOptions.PprofAddress,
+		})
+		if err := debugServer.Start(); err != nil {
+			preventClose(config, fmt.Sprintf("Debug: Failed to start, %s", err.Error()))
+		}
+		logger.Info("Debug: Started successfully", zap.String("address", config.DebugOptions.PprofAddress))
+	}
+
+	// Proxy server.
+
+	var proxyServer *proxyserver.Server
+	if config.Proxy.Enabled {
+		proxyServer = proxyserver.NewServer(&proxyserver.Config{
+			Logger:      logger.Named("proxy"),
+			ErupeConfig: config,
+		})
+		if err := proxyServer.Start(); err != nil {
+			preventClose(config, fmt.Sprintf("Proxy: Failed to start, %s", err.Error()))
+		}
+		logger.Info("Proxy: Started successfully", zap.Int("routes", len(config.Proxy.Routes)))
+	}
+
 	logger.Info("Finished starting Erupe")
 
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			logger.Info("Config: Reloading on SIGHUP")
+			_, _, _ = reloadConfig()
+		}
+	}()
+
 	// Wait for exit or interrupt with ctrl+C.
 	c := make(chan os.Signal, 1)
 	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
 	<-c
 
-	if !config.DisableSoftCrash {
-		for i := 0; i < 10; i++ {
-			message := fmt.Sprintf("Shutting down in %d...", 10-i)
-			for _, c := range channels {
-				c.BroadcastChatMessage(message)
-			}
-			logger.Info(message)
-			time.Sleep(time.Second)
+	events.Publish(eventbus.Event{
+		Type:    eventbus.Maintenance,
+		Title:   "Server maintenance",
+		Message: "The server is shutting down for maintenance.",
+	})
+
+	// Log everyone out before the channels close, so their data is saved.
+	if config.Channel.Enabled {
+		shutdownOpts := config.Shutdown
+		if config.DisableSoftCrash {
+			shutdownOpts = cfg.ShutdownOptions{}
 		}
+		channelserver.DrainChannels(channels, shutdownOpts, logger.Named("shutdown"))
 	}
 
+	stopRoleSync()
+	stopNotifications()
+	stopRecruitment()
+	stopPresence()
+	stopMetricsLog()
+	stopJobs()
+
 	if config.Channel.Enabled {
 		for _, c := range channels {
 			c.Shutdown()
 		}
 	}
+	if economy != nil {
+		if err := economy.Flush(economyStore); err != nil {
+			logger.Warn("Analytics: Failed to write the last counts", zap.Error(err))
+		}
+	}
+
+	if clusterBus != nil {
+		clusterBus.Close()
+	}
+	allies.Close()
+
+	if hits, misses := channelserver.StmtCacheStats(); hits+misses > 0 {
+		logger.Info("Database: Prepared statement cache",
+			zap.Uint64("hits", hits),
+			zap.Uint64("misses", misses),
+			zap.Float64("hit_rate", float64(hits)/float64(hits+misses)),
+		)
+	}
+
+	if saveWorkers != nil {
+		saveWorkers.Close()
+	}
+
+	stopSaveFlush()
+	if saveCache != nil {
+		if err := saveCache.FlushAll(); err != nil {
+			logger.Error("SaveCache: Failed to flush savedata", zap.Error(err))
+		}
+	}
 
 	if config.Sign.Enabled {
 		signServer.Shutdown()
@@ -384,9 +855,50 @@ func main() {
 		entranceServer.Shutdown()
 	}
 
+	if debugServer != nil {
+		debugServer.Shutdown()
+	}
+
+	if proxyServer != nil {
+		proxyServer.Shutdown()
+	}
+
+	if adminConsole != nil {
+		adminConsole.Shutdown()
+	}
+
+	if err := stopTracing(context.Background()); err != nil {
+		logger.Warn("Tracing: Failed to flush spans", zap.Error(err))
+	}
+
 	time.Sleep(1 * time.Second)
 }
 
+// openPostgres opens a postgres pool, timing its statements with slow when
+// non-nil and tracing them when traced is set.
+func openPostgres(dsn string, slow *slowquery.Monitor, traced bool) (*sqlx.DB, error) {
+	var c driver.Connector
+	c, err := pq.NewConnector(dsn)
+	if err != nil {
+		return nil, err
+	}
+	if slow != nil {
+		c = slow.Wrap(c)
+	}
+	if traced {
+		return sqlx.NewDb(tracing.OpenDB(c), "postgres"), nil
+	}
+	return sqlx.NewDb(sql.OpenDB(c), "postgres"), nil
+}
+
+// configurePool applies the connection pool limits from the database config.
+func configurePool(db *sqlx.DB, c cfg.Database) {
+	db.SetMaxOpenConns(c.MaxOpenConns)
+	db.SetMaxIdleConns(c.MaxIdleConns)
+	db.SetConnMaxLifetime(time.Duration(c.ConnMaxLifetime) * time.Second)
+	db.SetConnMaxIdleTime(time.Duration(c.ConnMaxIdleTime) * time.Second)
+}
+
 func wait() {
 	for {
 		time.Sleep(time.Millisecond * 100)
@@ -0,0 +1,96 @@
+package network
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// The MHF protocol carries the addresses of worlds and channels as 4 byte
+// IPv4 addresses, so those are always advertised over IPv4. Only addresses
+// the client receives as text, such as the entrance server's in the sign
+// response, can be IPv6.
+
+// remoteIP returns the IP of addr, or nil if it has none.
+func remoteIP(addr net.Addr) net.IP {
+	switch a := addr.(type) {
+	case *net.TCPAddr:
+		return a.IP
+	case *net.UDPAddr:
+		return a.IP
+	}
+	if addr == nil {
+		return nil
+	}
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		host = addr.String()
+	}
+	return net.ParseIP(host)
+}
+
+// IsLoopback reports whether addr is a loopback address, IPv4 or IPv6.
+func IsLoopback(addr net.Addr) bool {
+	ip := remoteIP(addr)
+	return ip != nil && ip.IsLoopback()
+}
+
+// IsIPv6 reports whether addr is an IPv6 address other than an IPv4-mapped
+// one, which is how a dual-stack listener sees IPv4 clients.
+func IsIPv6(addr net.Addr) bool {
+	ip := remoteIP(addr)
+	return ip != nil && ip.To4() == nil
+}
+
+// AdvertisedHost returns the host to advertise as text to a client at
+// remote: hostV6 for clients connected over IPv6 when it is set, host
+// otherwise. The result is joined with port, bracketing IPv6 addresses.
+func AdvertisedHost(host, hostV6 string, remote net.Addr, port uint16) string {
+	if hostV6 != "" && IsIPv6(remote) {
+		host = hostV6
+	}
+	return net.JoinHostPort(host, fmt.Sprint(port))
+}
+
+// IPv4LE returns the IPv4 address ip as the little endian uint32 the client
+// expects, or 0 if ip is not an IPv4 address.
+func IPv4LE(ip string) uint32 {
+	v4 := net.ParseIP(ip).To4()
+	if v4 == nil {
+		return 0
+	}
+	return binary.LittleEndian.Uint32(v4)
+}
+
+// ResolveIPv4 returns host if it is an IPv4 address, or the first IPv4
+// address a host name resolves to.
+func ResolveIPv4(host string) (string, error) {
+	if ip := net.ParseIP(host); ip != nil {
+		if ip.To4() == nil {
+			return "", errors.New("is an IPv6 address; MHF clients need an IPv4 address")
+		}
+		return ip.String(), nil
+	}
+	ips, err := net.LookupIP(host)
+	if err != nil {
+		return "", err
+	}
+	for _, ip := range ips {
+		if v4 := ip.To4(); v4 != nil {
+			return v4.String(), nil
+		}
+	}
+	if len(ips) > 0 {
+		return "", errors.New("resolves only to IPv6 addresses; MHF clients need an IPv4 address")
+	}
+	return "", errors.New("does not resolve")
+}
+
+// IsIPv6Literal reports whether s is an IPv6 address, with or without
+// brackets.
+func IsIPv6Literal(s string) bool {
+	ip := net.ParseIP(strings.TrimSuffix(strings.TrimPrefix(s, "["), "]"))
+	return ip != nil && ip.To4() == nil
+}
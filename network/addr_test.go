@@ -0,0 +1,63 @@
+package network
+
+import (
+	"net"
+	"testing"
+)
+
+func TestIsLoopbackAndIPv6(t *testing.T) {
+	tests := []struct {
+		addr     net.Addr
+		loopback bool
+		v6       bool
+	}{
+		{&net.TCPAddr{IP: net.ParseIP("127.0.0.1"), Port: 1}, true, false},
+		{&net.TCPAddr{IP: net.ParseIP("::1"), Port: 1}, true, true},
+		{&net.TCPAddr{IP: net.ParseIP("::ffff:127.0.0.1"), Port: 1}, true, false},
+		{&net.TCPAddr{IP: net.ParseIP("203.0.113.7"), Port: 1}, false, false},
+		{&net.TCPAddr{IP: net.ParseIP("2001:db8::7"), Port: 1}, false, true},
+	}
+	for _, tt := range tests {
+		if got := IsLoopback(tt.addr); got != tt.loopback {
+			t.Errorf("IsLoopback(%s) = %v, want %v", tt.addr, got, tt.loopback)
+		}
+		if got := IsIPv6(tt.addr); got != tt.v6 {
+			t.Errorf("IsIPv6(%s) = %v, want %v", tt.addr, got, tt.v6)
+		}
+	}
+}
+
+func TestAdvertisedHost(t *testing.T) {
+	v4 := &net.TCPAddr{IP: net.ParseIP("203.0.113.7")}
+	v6 := &net.TCPAddr{IP: net.ParseIP("2001:db8::7")}
+	if got := AdvertisedHost("198.51.100.1", "2001:db8::1", v4, 53310); got != "198.51.100.1:53310" {
+		t.Errorf("IPv4 client got %s", got)
+	}
+	if got := AdvertisedHost("198.51.100.1", "2001:db8::1", v6, 53310); got != "[2001:db8::1]:53310" {
+		t.Errorf("IPv6 client got %s", got)
+	}
+	if got := AdvertisedHost("198.51.100.1", "", v6, 53310); got != "198.51.100.1:53310" {
+		t.Errorf("IPv6 client without HostV6 got %s", got)
+	}
+}
+
+func TestIPv4LE(t *testing.T) {
+	if got := IPv4LE("127.0.0.1"); got != 0x0100007F {
+		t.Errorf("IPv4LE(127.0.0.1) = %#x", got)
+	}
+	if got := IPv4LE("2001:db8::1"); got != 0 {
+		t.Errorf("IPv4LE of an IPv6 address = %#x, want 0", got)
+	}
+}
+
+func TestResolveIPv4(t *testing.T) {
+	if got, err := ResolveIPv4("198.51.100.1"); err != nil || got != "198.51.100.1" {
+		t.Errorf("ResolveIPv4(literal) = %q, %v", got, err)
+	}
+	if _, err := ResolveIPv4("2001:db8::1"); err == nil {
+		t.Error("IPv6 literal accepted as Host")
+	}
+	if !IsIPv6Literal("[2001:db8::1]") || IsIPv6Literal("198.51.100.1") {
+		t.Error("IsIPv6Literal misjudged an address")
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"erupe-ce/network/crypto"
 	"io"
 	"net"
+	"sync"
 
 	"go.uber.org/zap"
 )
@@ -27,6 +28,7 @@ type CryptConn struct {
 	logger                      *zap.Logger
 	conn                        net.Conn
 	realClientMode              cfg.Mode
+	cipher                      crypto.Cipher
 	readKeyRot                  uint32
 	sendKeyRot                  uint32
 	sentPackets                 int32
@@ -36,19 +38,73 @@ type CryptConn struct {
 
 // NewCryptConn creates a new CryptConn with proper default values.
 func NewCryptConn(conn net.Conn, mode cfg.Mode, logger *zap.Logger) *CryptConn {
+	return NewCipherConn(conn, mode, crypto.Default, logger)
+}
+
+// NewCipherConn creates a CryptConn encrypting packet bodies with cipher, for
+// clients that do not use the retail cipher. A nil cipher is the retail one.
+func NewCipherConn(conn net.Conn, mode cfg.Mode, cipher crypto.Cipher, logger *zap.Logger) *CryptConn {
 	if logger == nil {
 		logger = zap.NewNop()
 	}
+	if cipher == nil {
+		cipher = crypto.Default
+	}
 	cc := &CryptConn{
 		logger:         logger,
 		conn:           conn,
 		realClientMode: mode,
+		cipher:         cipher,
 		readKeyRot:     995117,
 		sendKeyRot:     995117,
 	}
 	return cc
 }
 
+// CipherFor returns the packet cipher of a listener, "sign", "entrance" or
+// "channel", from the PacketCrypto settings.
+func CipherFor(c cfg.PacketCryptoOptions, listener string) (crypto.Cipher, error) {
+	name := c.Cipher
+	override := map[string]string{"sign": c.Sign, "entrance": c.Entrance, "channel": c.Channel}[listener]
+	if override != "" {
+		name = override
+	}
+	return crypto.ByName(name, c.KeyFile)
+}
+
+// maxPooledPacket is the largest packet buffer kept for reuse.
+const maxPooledPacket = 64 * 1024
+
+// packetBufPool holds buffers for encrypted packet bodies and outgoing frames,
+// which are only needed for the duration of a single read or write.
+var packetBufPool = sync.Pool{
+	New: func() any {
+		buf := make([]byte, 0, 1024)
+		return &buf
+	},
+}
+
+// getPacketBuf returns a buffer of length n, pooled if it is small enough.
+func getPacketBuf(n int) []byte {
+	if n > maxPooledPacket {
+		return make([]byte, n)
+	}
+	buf := *packetBufPool.Get().(*[]byte)
+	if cap(buf) < n {
+		buf = make([]byte, n)
+	}
+	return buf[:n]
+}
+
+// putPacketBuf returns buf to the pool. buf must not be used afterwards.
+func putPacketBuf(buf []byte) {
+	if cap(buf) > maxPooledPacket {
+		return
+	}
+	buf = buf[:0]
+	packetBufPool.Put(&buf)
+}
+
 // ReadPacket reads an packet from the connection and returns the decrypted data.
 func (cc *CryptConn) ReadPacket() ([]byte, error) {
 
@@ -70,10 +126,12 @@ func (cc *CryptConn) ReadPacket() ([]byte, error) {
 
 	// Don't know when support for this was added, works in Forward.4, doesn't work in Season 6.0
 	if cc.realClientMode < cfg.F1 {
-		encryptedPacketBody = make([]byte, cph.DataSize)
+		encryptedPacketBody = getPacketBuf(int(cph.DataSize))
 	} else {
-		encryptedPacketBody = make([]byte, uint32(cph.DataSize)+(uint32(cph.Pf0-0x03)*0x1000))
+		encryptedPacketBody = getPacketBuf(int(uint32(cph.DataSize) + (uint32(cph.Pf0-0x03) * 0x1000)))
 	}
+	// Decryption copies the body, so its buffer can be reused afterwards.
+	defer putPacketBuf(encryptedPacketBody)
 	_, err = io.ReadFull(cc.conn, encryptedPacketBody)
 	if err != nil {
 		return nil, err
@@ -84,7 +142,7 @@ func (cc *CryptConn) ReadPacket() ([]byte, error) {
 		cc.readKeyRot = uint32(cph.KeyRotDelta) * (cc.readKeyRot + 1)
 	}
 
-	out, combinedCheck, check0, check1, check2 := crypto.Crypto(encryptedPacketBody, cc.readKeyRot, false, nil)
+	out, combinedCheck, check0, check1, check2 := cc.cipher.Crypt(encryptedPacketBody, cc.readKeyRot, false, nil)
 	if cph.Check0 != check0 || cph.Check1 != check1 || cph.Check2 != check2 {
 		cc.logger.Warn("Crypto checksum mismatch",
 			zap.String("got", hex.EncodeToString([]byte{byte(check0 >> 8), byte(check0), byte(check1 >> 8), byte(check1), byte(check2 >> 8), byte(check2)})),
@@ -96,7 +154,7 @@ func (cc *CryptConn) ReadPacket() ([]byte, error) {
 		// Attempt to bruteforce it.
 		cc.logger.Warn("Crypto out of sync, attempting bruteforce")
 		for key := byte(0); key < 255; key++ {
-			out, combinedCheck, check0, check1, check2 = crypto.Crypto(encryptedPacketBody, 0, false, &key)
+			out, combinedCheck, check0, check1, check2 = cc.cipher.Crypt(encryptedPacketBody, 0, false, &key)
 			if cph.Check0 == check0 && cph.Check1 == check1 && cph.Check2 == check2 {
 				cc.logger.Info("Bruteforce successful", zap.Uint8("overrideKey", key))
 
@@ -124,7 +182,7 @@ func (cc *CryptConn) SendPacket(data []byte) error {
 	}
 
 	// Encrypt the data
-	encData, combinedCheck, check0, check1, check2 := crypto.Crypto(data, cc.sendKeyRot, true, nil)
+	encData, combinedCheck, check0, check1, check2 := cc.cipher.Crypt(data, cc.sendKeyRot, true, nil)
 
 	header := &CryptPacketHeader{}
 	header.Pf0 = byte(((uint(len(encData)) >> 12) & 0xF3) | 3)
@@ -141,7 +199,10 @@ func (cc *CryptConn) SendPacket(data []byte) error {
 		return err
 	}
 
-	_, err = cc.conn.Write(append(headerBytes, encData...))
+	frame := getPacketBuf(len(headerBytes) + len(encData))
+	copy(frame[copy(frame, headerBytes):], encData)
+	_, err = cc.conn.Write(frame)
+	putPacketBuf(frame)
 	if err != nil {
 		return err
 	}
@@ -443,3 +443,48 @@ func TestCryptConn_Interface(t *testing.T) {
 	// Test that CryptConn implements Conn interface
 	var _ Conn = (*CryptConn)(nil)
 }
+
+func TestCipherConn_RoundTrip(t *testing.T) {
+	sbox := make([]byte, 256)
+	for i := range sbox {
+		sbox[i] = byte(i*7 + 3)
+	}
+	patched, err := crypto.NewTables(sbox, bytes.Repeat([]byte{0x5A}, 256))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := []byte{0x00, 0x13, 0x01, 0x02, 0x03, 0x04, 0x00, 0x10}
+
+	for name, cipher := range map[string]crypto.Cipher{"patched": patched, "none": crypto.None{}} {
+		t.Run(name, func(t *testing.T) {
+			out := newMockConn(nil)
+			if err := NewCipherConn(out, cfg.ZZ, cipher, nil).SendPacket(data); err != nil {
+				t.Fatalf("SendPacket() error: %v", err)
+			}
+			if name == "none" && !bytes.Equal(out.writeData.Bytes()[CryptPacketHeaderLength:], data) {
+				t.Error("none cipher changed the packet body")
+			}
+			in := newMockConn(out.writeData.Bytes())
+			got, err := NewCipherConn(in, cfg.ZZ, cipher, nil).ReadPacket()
+			if err != nil {
+				t.Fatalf("ReadPacket() error: %v", err)
+			}
+			if !bytes.Equal(got, data) {
+				t.Errorf("ReadPacket() = % X, want % X", got, data)
+			}
+		})
+	}
+}
+
+func TestCipherFor(t *testing.T) {
+	opts := cfg.PacketCryptoOptions{Cipher: "mhf", Channel: "none"}
+	if c, err := CipherFor(opts, "sign"); err != nil || c != crypto.Default {
+		t.Errorf("CipherFor(sign) = %v, %v; want the retail cipher", c, err)
+	}
+	if c, err := CipherFor(opts, "channel"); err != nil || c != (crypto.None{}) {
+		t.Errorf("CipherFor(channel) = %v, %v; want none", c, err)
+	}
+	if _, err := CipherFor(cfg.PacketCryptoOptions{Cipher: "rot13"}, "sign"); !errors.Is(err, crypto.ErrUnknownCipher) {
+		t.Errorf("CipherFor(rot13) error = %v, want ErrUnknownCipher", err)
+	}
+}
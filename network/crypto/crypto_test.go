@@ -3,7 +3,10 @@ package crypto
 import (
 	"bytes"
 	"encoding/hex"
+	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
 	"testing"
 )
 
@@ -102,3 +105,47 @@ func TestDecrypt(t *testing.T) {
 	}
 
 }
+
+func TestNewTables(t *testing.T) {
+	if _, err := NewTables(make([]byte, 256), make([]byte, 256)); err == nil {
+		t.Error("an S-box that is not a permutation was accepted")
+	}
+	if _, err := NewTables(_encryptKey[:255], _sharedCryptKey); err == nil {
+		t.Error("a short S-box was accepted")
+	}
+}
+
+func TestLoadTables(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys.bin")
+	if err := os.WriteFile(path, append(append([]byte(nil), _encryptKey...), _sharedCryptKey...), 0644); err != nil {
+		t.Fatal(err)
+	}
+	tables, err := LoadTables(path)
+	if err != nil {
+		t.Fatalf("LoadTables() error: %v", err)
+	}
+	for k, tt := range tests {
+		out, _, _, _, _ := tables.Crypt(tt.encryptedData, tt.key, false, nil)
+		if !bytes.Equal(out, tt.decryptedData) {
+			t.Errorf("test %d: tables from a key file decrypt differently from the retail cipher", k)
+		}
+	}
+
+	if err := os.WriteFile(path, make([]byte, 100), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadTables(path); err == nil {
+		t.Error("a short key file was accepted")
+	}
+}
+
+func TestByName(t *testing.T) {
+	for name, want := range map[string]Cipher{"": Default, "mhf": Default, "none": None{}} {
+		if got, err := ByName(name, ""); err != nil || got != want {
+			t.Errorf("ByName(%q) = %v, %v", name, got, err)
+		}
+	}
+	if _, err := ByName("rot13", ""); !errors.Is(err, ErrUnknownCipher) {
+		t.Errorf("ByName(rot13) error = %v, want ErrUnknownCipher", err)
+	}
+}
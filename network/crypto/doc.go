@@ -2,4 +2,8 @@
 // Hunter Frontier to encrypt and decrypt TCP packet bodies. The algorithm uses
 // a 256-byte S-box with a rolling derived key and produces three integrity
 // checksums alongside the ciphertext.
+//
+// Connections encrypt through the Cipher interface, so patched clients with
+// their own tables (LoadTables) and debug clients built without encryption
+// (None) can be served by configuration alone.
 package crypto
@@ -0,0 +1,140 @@
+package network
+
+import (
+	"errors"
+	"io"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	cfg "erupe-ce/config"
+)
+
+// ErrInjectedDisconnect is returned by a FaultConn once it has dropped the
+// connection.
+var ErrInjectedDisconnect = errors.New("network: injected disconnect")
+
+// FaultOptions configure the faults a FaultConn injects.
+type FaultOptions struct {
+	Seed           int64         // Equal seeds give equal faults for the same traffic
+	Latency        time.Duration // Delay added to every packet
+	Jitter         time.Duration // Up to this much extra delay, chosen per packet
+	TruncateRate   float64       // Chance from 0 to 1 that a packet is cut short
+	DisconnectRate float64       // Chance from 0 to 1 that the connection drops instead of passing a packet
+}
+
+// FaultOptionsFromConfig converts the DebugOptions fault injection settings.
+func FaultOptionsFromConfig(c cfg.FaultInjectionOptions) FaultOptions {
+	return FaultOptions{
+		Seed:           c.Seed,
+		Latency:        time.Duration(c.LatencyMs) * time.Millisecond,
+		Jitter:         time.Duration(c.JitterMs) * time.Millisecond,
+		TruncateRate:   c.TruncateRate,
+		DisconnectRate: c.DisconnectRate,
+	}
+}
+
+// WithFaults wraps conn in a FaultConn when fault injection is enabled in c,
+// and returns it unchanged otherwise. closer is the underlying socket.
+func WithFaults(conn Conn, closer io.Closer, c cfg.FaultInjectionOptions) Conn {
+	if !c.Enabled {
+		return conn
+	}
+	return NewFaultConn(conn, closer, FaultOptionsFromConfig(c))
+}
+
+// FaultConn wraps a Conn and injects latency, jitter, truncated packets and
+// disconnects, for exercising timeout and reconnection handling.
+//
+// Reads and sends draw from separate generators seeded from the same seed,
+// so each direction sees the same faults for the same sequence of packets
+// however the two are interleaved. It is safe for concurrent use from
+// separate send/recv goroutines.
+type FaultConn struct {
+	inner  Conn
+	closer io.Closer
+	opts   FaultOptions
+	sleep  func(time.Duration)
+	closed atomic.Bool
+
+	readMu  sync.Mutex
+	readRng *rand.Rand
+	sendMu  sync.Mutex
+	sendRng *rand.Rand
+}
+
+// NewFaultConn wraps inner. closer, usually the underlying socket, is closed
+// when a disconnect is injected and may be nil.
+func NewFaultConn(inner Conn, closer io.Closer, opts FaultOptions) *FaultConn {
+	return &FaultConn{
+		inner:   inner,
+		closer:  closer,
+		opts:    opts,
+		sleep:   time.Sleep,
+		readRng: rand.New(rand.NewSource(opts.Seed)),
+		sendRng: rand.New(rand.NewSource(^opts.Seed)),
+	}
+}
+
+// ReadPacket reads from the inner connection, then applies a fault to the
+// packet before handing it on.
+func (fc *FaultConn) ReadPacket() ([]byte, error) {
+	if fc.closed.Load() {
+		return nil, ErrInjectedDisconnect
+	}
+	data, err := fc.inner.ReadPacket()
+	if err != nil {
+		return data, err
+	}
+	fc.readMu.Lock()
+	data, err = fc.inject(fc.readRng, data)
+	fc.readMu.Unlock()
+	return data, err
+}
+
+// SendPacket applies a fault to the packet, then sends what is left of it on
+// the inner connection.
+func (fc *FaultConn) SendPacket(data []byte) error {
+	if fc.closed.Load() {
+		return ErrInjectedDisconnect
+	}
+	fc.sendMu.Lock()
+	data, err := fc.inject(fc.sendRng, data)
+	fc.sendMu.Unlock()
+	if err != nil {
+		return err
+	}
+	return fc.inner.SendPacket(data)
+}
+
+// inject delays the packet and decides its fate. Every packet draws the same
+// number of values from rng, so one fault does not shift the ones after it.
+func (fc *FaultConn) inject(rng *rand.Rand, data []byte) ([]byte, error) {
+	delay := fc.opts.Latency
+	if fc.opts.Jitter > 0 {
+		delay += time.Duration(rng.Int63n(int64(fc.opts.Jitter) + 1))
+	}
+	disconnect := rng.Float64() < fc.opts.DisconnectRate
+	truncate := rng.Float64() < fc.opts.TruncateRate
+	cut := rng.Intn(max(len(data), 1))
+
+	if delay > 0 {
+		fc.sleep(delay)
+	}
+	if disconnect {
+		fc.disconnect()
+		return nil, ErrInjectedDisconnect
+	}
+	if truncate && len(data) > 0 {
+		data = data[:cut]
+	}
+	return data, nil
+}
+
+func (fc *FaultConn) disconnect() {
+	if fc.closed.Swap(true) || fc.closer == nil {
+		return
+	}
+	_ = fc.closer.Close()
+}
@@ -0,0 +1,145 @@
+package network
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	cfg "erupe-ce/config"
+)
+
+// packetConn is a Conn that returns the same packet on every read and
+// records every send.
+type packetConn struct {
+	packet []byte
+	sent   [][]byte
+}
+
+func (c *packetConn) ReadPacket() ([]byte, error) { return bytes.Clone(c.packet), nil }
+
+func (c *packetConn) SendPacket(data []byte) error {
+	c.sent = append(c.sent, data)
+	return nil
+}
+
+type closeCounter struct{ n int }
+
+func (c *closeCounter) Close() error {
+	c.n++
+	return nil
+}
+
+// faultRun sends n packets through a FaultConn and returns what arrived,
+// the delays it slept and the error that stopped it, if any.
+func faultRun(opts FaultOptions, n int) ([][]byte, []time.Duration, error) {
+	inner := &packetConn{}
+	fc := NewFaultConn(inner, nil, opts)
+	var delays []time.Duration
+	fc.sleep = func(d time.Duration) { delays = append(delays, d) }
+	for range n {
+		if err := fc.SendPacket([]byte("0123456789")); err != nil {
+			return inner.sent, delays, err
+		}
+	}
+	return inner.sent, delays, nil
+}
+
+func TestFaultConn_PassThrough(t *testing.T) {
+	sent, delays, err := faultRun(FaultOptions{Seed: 1}, 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(delays) != 0 {
+		t.Errorf("slept %v with no latency configured", delays)
+	}
+	for _, p := range sent {
+		if string(p) != "0123456789" {
+			t.Fatalf("packet altered to %q", p)
+		}
+	}
+}
+
+func TestFaultConn_Latency(t *testing.T) {
+	_, delays, err := faultRun(FaultOptions{Latency: 50 * time.Millisecond, Jitter: 10 * time.Millisecond}, 50)
+	if err != nil {
+		t.Fatal(err)
+	}
+	varied := false
+	for _, d := range delays {
+		if d < 50*time.Millisecond || d > 60*time.Millisecond {
+			t.Fatalf("delay %v outside 50ms+10ms", d)
+		}
+		varied = varied || d != delays[0]
+	}
+	if !varied {
+		t.Error("jitter never varied the delay")
+	}
+}
+
+func TestFaultConn_Truncate(t *testing.T) {
+	sent, _, err := faultRun(FaultOptions{Seed: 3, TruncateRate: 1}, 50)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range sent {
+		if len(p) >= 10 || !bytes.HasPrefix([]byte("0123456789"), p) {
+			t.Fatalf("packet %q was not truncated", p)
+		}
+	}
+}
+
+func TestFaultConn_Deterministic(t *testing.T) {
+	opts := FaultOptions{Seed: 42, Jitter: time.Second, TruncateRate: 0.3, DisconnectRate: 0.05}
+	sentA, delaysA, errA := faultRun(opts, 200)
+	sentB, delaysB, errB := faultRun(opts, 200)
+	if len(sentA) != len(sentB) || !errors.Is(errA, errB) {
+		t.Fatalf("runs diverged: %d packets (%v) vs %d (%v)", len(sentA), errA, len(sentB), errB)
+	}
+	for i := range sentA {
+		if !bytes.Equal(sentA[i], sentB[i]) || delaysA[i] != delaysB[i] {
+			t.Fatalf("packet %d differs between runs", i)
+		}
+	}
+
+	opts.Seed = 43
+	sentC, delaysC, _ := faultRun(opts, 200)
+	if len(sentC) == len(sentA) && delaysC[0] == delaysA[0] {
+		t.Error("a different seed gave the same faults")
+	}
+}
+
+func TestFaultConn_Disconnect(t *testing.T) {
+	inner := &packetConn{packet: []byte{1, 2, 3}}
+	closer := &closeCounter{}
+	fc := NewFaultConn(inner, closer, FaultOptions{DisconnectRate: 1})
+
+	if _, err := fc.ReadPacket(); !errors.Is(err, ErrInjectedDisconnect) {
+		t.Fatalf("ReadPacket error = %v, want ErrInjectedDisconnect", err)
+	}
+	if err := fc.SendPacket([]byte{1}); !errors.Is(err, ErrInjectedDisconnect) {
+		t.Fatalf("SendPacket error = %v, want ErrInjectedDisconnect", err)
+	}
+	if closer.n != 1 {
+		t.Errorf("socket closed %d times, want 1", closer.n)
+	}
+	if len(inner.sent) != 0 {
+		t.Errorf("sent %d packets after disconnecting", len(inner.sent))
+	}
+}
+
+func TestWithFaults(t *testing.T) {
+	inner := &packetConn{}
+	if got := WithFaults(inner, nil, cfg.FaultInjectionOptions{LatencyMs: 5}); got != Conn(inner) {
+		t.Error("disabled fault injection wrapped the connection")
+	}
+	got := WithFaults(inner, nil, cfg.FaultInjectionOptions{Enabled: true, Seed: 7, LatencyMs: 5, JitterMs: 2, TruncateRate: 0.5})
+	fc, ok := got.(*FaultConn)
+	if !ok {
+		t.Fatalf("WithFaults returned %T", got)
+	}
+	want := FaultOptions{Seed: 7, Latency: 5 * time.Millisecond, Jitter: 2 * time.Millisecond, TruncateRate: 0.5}
+	if fc.opts != want {
+		t.Errorf("options = %+v, want %+v", fc.opts, want)
+	}
+}
@@ -0,0 +1,311 @@
+package network
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	cfg "erupe-ce/config"
+)
+
+// ErrBadHandshake is returned by the first read of a connection whose client
+// did not open with the 8 NULL bytes MHF clients send the sign and entrance
+// servers.
+var ErrBadHandshake = errors.New("network: bad handshake")
+
+// Reasons a ConnLimiter refuses a connection, passed to its reject callback.
+const (
+	RejectConcurrent = "concurrent" // Too many open connections from the IP
+	RejectRate       = "rate"       // Too many new connections from the IP this minute
+	RejectDenylisted = "denylisted" // The IP is temporarily denylisted
+	RejectHandshake  = "handshake"  // The client opened with something other than the handshake
+)
+
+// handshakeLen is the length of the NULL handshake sign and entrance clients
+// open with.
+const handshakeLen = 8
+
+// limitWindow is the period rates and rejections are counted over.
+const limitWindow = time.Minute
+
+// ConnLimitOptions configure a ConnLimiter. Zero disables each limit.
+type ConnLimitOptions struct {
+	MaxPerIP         int           // Open connections from one IP
+	MaxPerMinute     int           // New connections from one IP per minute
+	DenylistAfter    int           // Rejections of one IP within a minute before it is denylisted
+	DenylistFor      time.Duration // How long a denylisted IP is refused
+	HandshakeTimeout time.Duration // Time a client has to send its handshake
+}
+
+// ConnLimitOptionsFromConfig converts the ConnectionLimits settings.
+func ConnLimitOptionsFromConfig(c cfg.ConnectionLimitOptions) ConnLimitOptions {
+	return ConnLimitOptions{
+		MaxPerIP:         c.MaxPerIP,
+		MaxPerMinute:     c.MaxPerMinute,
+		DenylistAfter:    c.DenylistAfter,
+		DenylistFor:      time.Duration(c.DenylistSeconds) * time.Second,
+		HandshakeTimeout: time.Duration(c.HandshakeTimeout) * time.Second,
+	}
+}
+
+// ConnLimiter limits connections per source IP across every listener it
+// wraps, refusing IPs that open too many connections at once or too quickly
+// and denylisting those that keep at it. Loopback clients are never limited.
+// It is safe for concurrent use.
+type ConnLimiter struct {
+	opts     ConnLimitOptions
+	onReject func(reason string)
+	now      func() time.Time
+
+	mu        sync.Mutex
+	ips       map[string]*ipState
+	lastSweep time.Time
+}
+
+type ipState struct {
+	active      int
+	recent      []time.Time // Connections within limitWindow
+	rejects     []time.Time // Rejections within limitWindow
+	deniedUntil time.Time
+}
+
+// NewConnLimiter creates a ConnLimiter. onReject, which may be nil, is called
+// with the reason for every refused connection.
+func NewConnLimiter(opts ConnLimitOptions, onReject func(reason string)) *ConnLimiter {
+	if onReject == nil {
+		onReject = func(string) {}
+	}
+	return &ConnLimiter{opts: opts, onReject: onReject, now: time.Now, ips: make(map[string]*ipState)}
+}
+
+// admit decides whether a connection from ip may open. When it may, the
+// returned function must be called once it closes.
+func (l *ConnLimiter) admit(ip string) (release func(), reason string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	l.sweep(now)
+	st := l.ips[ip]
+	if st == nil {
+		st = &ipState{}
+		l.ips[ip] = st
+	}
+	st.recent = trimBefore(st.recent, now.Add(-limitWindow))
+	switch {
+	case now.Before(st.deniedUntil):
+		reason = RejectDenylisted
+	case l.opts.MaxPerIP > 0 && st.active >= l.opts.MaxPerIP:
+		reason = RejectConcurrent
+	case l.opts.MaxPerMinute > 0 && len(st.recent) >= l.opts.MaxPerMinute:
+		reason = RejectRate
+	}
+	if reason != "" {
+		l.rejectLocked(st, now, reason)
+		return nil, reason
+	}
+	st.active++
+	st.recent = append(st.recent, now)
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			l.mu.Lock()
+			st.active--
+			l.mu.Unlock()
+		})
+	}, ""
+}
+
+// reject counts a refused connection from ip.
+func (l *ConnLimiter) reject(ip, reason string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	st := l.ips[ip]
+	if st == nil {
+		st = &ipState{}
+		l.ips[ip] = st
+	}
+	l.rejectLocked(st, l.now(), reason)
+}
+
+func (l *ConnLimiter) rejectLocked(st *ipState, now time.Time, reason string) {
+	l.onReject(reason)
+	if reason == RejectDenylisted || l.opts.DenylistAfter <= 0 {
+		return
+	}
+	st.rejects = append(trimBefore(st.rejects, now.Add(-limitWindow)), now)
+	if len(st.rejects) >= l.opts.DenylistAfter {
+		st.deniedUntil = now.Add(l.opts.DenylistFor)
+		st.rejects = nil
+	}
+}
+
+// sweep forgets IPs with nothing left to track, at most once a window.
+func (l *ConnLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < limitWindow {
+		return
+	}
+	l.lastSweep = now
+	cutoff := now.Add(-limitWindow)
+	for ip, st := range l.ips {
+		if st.active == 0 && now.After(st.deniedUntil) &&
+			len(trimBefore(st.recent, cutoff)) == 0 && len(trimBefore(st.rejects, cutoff)) == 0 {
+			delete(l.ips, ip)
+		}
+	}
+}
+
+// Denylisted returns the number of IPs currently denylisted.
+func (l *ConnLimiter) Denylisted() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	n := 0
+	for _, st := range l.ips {
+		if now.Before(st.deniedUntil) {
+			n++
+		}
+	}
+	return n
+}
+
+// trimBefore drops the times before cutoff from the front of ts, which is in
+// ascending order.
+func trimBefore(ts []time.Time, cutoff time.Time) []time.Time {
+	i := 0
+	for i < len(ts) && ts[i].Before(cutoff) {
+		i++
+	}
+	return ts[i:]
+}
+
+// Wrap returns a listener whose connections are subject to the limits. With
+// handshake set, each client must open with the NULL handshake of the sign
+// and entrance servers; the channel server's clients send none.
+func (l *ConnLimiter) Wrap(ln net.Listener, handshake bool) net.Listener {
+	return &limitListener{Listener: ln, limiter: l, handshake: handshake}
+}
+
+type limitListener struct {
+	net.Listener
+	limiter   *ConnLimiter
+	handshake bool
+}
+
+// Accept returns the next connection the limits allow, closing the others.
+// A connection from a proxy is checked on its first Read instead, once its
+// PROXY header has given the client's address, so a slow header does not
+// hold up the accept loop.
+func (ln *limitListener) Accept() (net.Conn, error) {
+	for {
+		conn, err := ln.Listener.Accept()
+		if err != nil {
+			return nil, err
+		}
+		lc := &limitConn{Conn: conn, limiter: ln.limiter, handshake: ln.handshake}
+		if _, proxied := conn.(*ProxyConn); proxied {
+			return lc, nil
+		}
+		if err := lc.admit(); err != nil {
+			continue
+		}
+		return lc, nil
+	}
+}
+
+// limitConn holds a place in the limits until it is closed.
+type limitConn struct {
+	net.Conn
+	limiter   *ConnLimiter
+	handshake bool // Whether the client must open with the NULL handshake
+
+	admitOnce sync.Once
+	admitErr  error
+	ip        string // Empty for clients that are not limited
+
+	handshakeOnce sync.Once
+	handshakeErr  error
+	pending       []byte // The handshake, still to be read by the server
+
+	mu      sync.Mutex
+	release func()
+	closed  bool
+}
+
+// admit checks the connection against the limits, closing it if refused.
+// Loopback clients are not limited.
+func (c *limitConn) admit() error {
+	c.admitOnce.Do(func() {
+		ip := remoteIP(c.Conn.RemoteAddr())
+		if ip == nil || ip.IsLoopback() {
+			return
+		}
+		c.ip = ip.String()
+		release, reason := c.limiter.admit(c.ip)
+		if reason != "" {
+			c.admitErr = fmt.Errorf("network: connection refused: %s", reason)
+			_ = c.Conn.Close()
+			return
+		}
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		if c.closed {
+			release()
+			return
+		}
+		c.release = release
+	})
+	return c.admitErr
+}
+
+// readHandshake reads the client's opening bytes, keeping them for the
+// server to read.
+func (c *limitConn) readHandshake() error {
+	if t := c.limiter.opts.HandshakeTimeout; t > 0 {
+		_ = c.Conn.SetReadDeadline(time.Now().Add(t))
+		defer func() { _ = c.Conn.SetReadDeadline(time.Time{}) }()
+	}
+	buf := make([]byte, handshakeLen)
+	if _, err := io.ReadFull(c.Conn, buf); err != nil || !bytes.Equal(buf, make([]byte, handshakeLen)) {
+		if c.ip != "" {
+			c.limiter.reject(c.ip, RejectHandshake)
+		}
+		_ = c.Close()
+		return ErrBadHandshake
+	}
+	c.pending = buf
+	return nil
+}
+
+func (c *limitConn) Read(b []byte) (int, error) {
+	if err := c.admit(); err != nil {
+		return 0, err
+	}
+	if c.handshake {
+		c.handshakeOnce.Do(func() { c.handshakeErr = c.readHandshake() })
+		if c.handshakeErr != nil {
+			return 0, c.handshakeErr
+		}
+	}
+	if len(c.pending) > 0 {
+		n := copy(b, c.pending)
+		c.pending = c.pending[n:]
+		return n, nil
+	}
+	return c.Conn.Read(b)
+}
+
+// Close closes the connection and gives up its place in the limits.
+func (c *limitConn) Close() error {
+	c.mu.Lock()
+	release := c.release
+	c.release, c.closed = nil, true
+	c.mu.Unlock()
+	if release != nil {
+		release()
+	}
+	return c.Conn.Close()
+}
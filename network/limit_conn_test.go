@@ -0,0 +1,215 @@
+package network
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	cfg "erupe-ce/config"
+)
+
+// testLimiter returns a limiter on a clock the test moves, and the reasons it
+// has refused connections for.
+func testLimiter(opts ConnLimitOptions) (*ConnLimiter, *time.Time, *[]string) {
+	var rejected []string
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	l := NewConnLimiter(opts, func(reason string) { rejected = append(rejected, reason) })
+	l.now = func() time.Time { return now }
+	return l, &now, &rejected
+}
+
+func TestConnLimiter_MaxPerIP(t *testing.T) {
+	l, _, rejected := testLimiter(ConnLimitOptions{MaxPerIP: 2})
+
+	first, _ := l.admit("203.0.113.7")
+	if _, reason := l.admit("203.0.113.7"); reason != "" {
+		t.Fatalf("second connection refused: %s", reason)
+	}
+	if _, reason := l.admit("203.0.113.7"); reason != RejectConcurrent {
+		t.Errorf("third connection: reason = %q, want %q", reason, RejectConcurrent)
+	}
+	if _, reason := l.admit("198.51.100.9"); reason != "" {
+		t.Errorf("another IP was refused: %s", reason)
+	}
+	first()
+	first() // Releasing twice frees one place
+	if _, reason := l.admit("203.0.113.7"); reason != "" {
+		t.Errorf("connection after a release refused: %s", reason)
+	}
+	if _, reason := l.admit("203.0.113.7"); reason != RejectConcurrent {
+		t.Errorf("double release freed two places")
+	}
+	if len(*rejected) != 2 {
+		t.Errorf("reject callback called %d times, want 2", len(*rejected))
+	}
+}
+
+func TestConnLimiter_MaxPerMinute(t *testing.T) {
+	l, now, _ := testLimiter(ConnLimitOptions{MaxPerMinute: 3})
+
+	for i := 0; i < 3; i++ {
+		release, reason := l.admit("203.0.113.7")
+		if reason != "" {
+			t.Fatalf("connection %d refused: %s", i, reason)
+		}
+		release()
+		*now = now.Add(time.Second)
+	}
+	if _, reason := l.admit("203.0.113.7"); reason != RejectRate {
+		t.Errorf("fourth connection in a minute: reason = %q, want %q", reason, RejectRate)
+	}
+	*now = now.Add(time.Minute)
+	if _, reason := l.admit("203.0.113.7"); reason != "" {
+		t.Errorf("connection a minute later refused: %s", reason)
+	}
+}
+
+func TestConnLimiter_Denylist(t *testing.T) {
+	l, now, rejected := testLimiter(ConnLimitOptions{MaxPerIP: 1, DenylistAfter: 3, DenylistFor: 10 * time.Minute})
+
+	if _, reason := l.admit("203.0.113.7"); reason != "" {
+		t.Fatal(reason)
+	}
+	for i := 0; i < 3; i++ {
+		l.admit("203.0.113.7")
+	}
+	if l.Denylisted() != 1 {
+		t.Fatalf("Denylisted() = %d after 3 rejections, want 1", l.Denylisted())
+	}
+	// Even an IP under its limits is refused while denylisted.
+	if _, reason := l.admit("203.0.113.7"); reason != RejectDenylisted {
+		t.Errorf("reason = %q, want %q", reason, RejectDenylisted)
+	}
+	if want := []string{RejectConcurrent, RejectConcurrent, RejectConcurrent, RejectDenylisted}; len(*rejected) != len(want) {
+		t.Errorf("rejections = %v, want %v", *rejected, want)
+	}
+
+	*now = now.Add(11 * time.Minute)
+	if l.Denylisted() != 0 {
+		t.Errorf("IP still denylisted after DenylistFor")
+	}
+}
+
+func TestConnLimiter_SweepForgetsIdleIPs(t *testing.T) {
+	l, now, _ := testLimiter(ConnLimitOptions{MaxPerMinute: 10})
+
+	release, _ := l.admit("203.0.113.7")
+	release()
+	*now = now.Add(2 * time.Minute)
+	l.admit("198.51.100.9")
+	if _, ok := l.ips["203.0.113.7"]; ok {
+		t.Error("idle IP was not swept")
+	}
+}
+
+// limitListen starts a limited listener behind the PROXY protocol, so its
+// connections appear to come from addresses other than loopback, and returns
+// a function that dials it from src, writes data and returns the accepted
+// connection.
+func limitListen(t *testing.T, l *ConnLimiter, handshake bool) func(src string, data []byte) net.Conn {
+	t.Helper()
+	inner, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("cannot listen: %v", err)
+	}
+	proxied, err := WithProxyProtocol(inner, cfg.ProxyProtocolOptions{Enabled: true, TrustedProxies: []string{"127.0.0.1"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ln := l.Wrap(proxied, handshake)
+	t.Cleanup(func() { _ = ln.Close() })
+	return func(src string, data []byte) net.Conn {
+		client, err := net.Dial("tcp", ln.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { _ = client.Close() })
+		header := "PROXY TCP4 " + src + " 10.0.0.2 51234 53310\r\n"
+		if _, err := client.Write(append([]byte(header), data...)); err != nil {
+			t.Fatal(err)
+		}
+		conn, err := ln.Accept()
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { _ = conn.Close() })
+		return conn
+	}
+}
+
+func TestLimitListener_Handshake(t *testing.T) {
+	l, _, rejected := testLimiter(ConnLimitOptions{MaxPerIP: 1, HandshakeTimeout: time.Second})
+	dial := limitListen(t, l, true)
+
+	conn := dial("203.0.113.7", []byte("\x00\x00\x00\x00\x00\x00\x00\x00rest"))
+	buf := make([]byte, 12)
+	if _, err := io.ReadFull(conn, buf); err != nil || !bytes.Equal(buf, []byte("\x00\x00\x00\x00\x00\x00\x00\x00rest")) {
+		t.Errorf("read %q after the handshake, err %v", buf, err)
+	}
+
+	conn = dial("198.51.100.9", []byte("GET / HTTP/1.1\r\n"))
+	if _, err := conn.Read(buf); !errors.Is(err, ErrBadHandshake) {
+		t.Errorf("Read after a bad handshake: err = %v, want ErrBadHandshake", err)
+	}
+	// The bad handshake gave up the IP's place.
+	conn = dial("198.51.100.9", make([]byte, 8))
+	if _, err := conn.Read(buf); err != nil {
+		t.Errorf("Read after a good handshake: %v", err)
+	}
+	if len(*rejected) != 1 || (*rejected)[0] != RejectHandshake {
+		t.Errorf("rejections = %v, want [%s]", *rejected, RejectHandshake)
+	}
+}
+
+func TestLimitListener_RefusesAndReleases(t *testing.T) {
+	l, _, _ := testLimiter(ConnLimitOptions{MaxPerIP: 1})
+	dial := limitListen(t, l, false)
+
+	first := dial("203.0.113.7", []byte("a"))
+	buf := make([]byte, 1)
+	if _, err := first.Read(buf); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := dial("203.0.113.7", []byte("b")).Read(buf); err == nil {
+		t.Error("second connection from the IP was not refused")
+	}
+	_ = first.Close()
+	if _, err := dial("203.0.113.7", []byte("c")).Read(buf); err != nil {
+		t.Errorf("connection after the first closed: %v", err)
+	}
+}
+
+func TestLimitListener_LoopbackExempt(t *testing.T) {
+	l, _, _ := testLimiter(ConnLimitOptions{MaxPerIP: 1})
+	inner, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("cannot listen: %v", err)
+	}
+	ln := l.Wrap(inner, false)
+	defer func() { _ = ln.Close() }()
+	for i := 0; i < 3; i++ {
+		client, err := net.Dial("tcp", ln.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = client.Close() }()
+		conn, err := ln.Accept()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = conn.Close() }()
+	}
+	if len(l.ips) != 0 {
+		t.Errorf("loopback connections were counted: %v", l.ips)
+	}
+}
+
+func TestConnLimitOptionsFromConfig(t *testing.T) {
+	got := ConnLimitOptionsFromConfig(cfg.ConnectionLimitOptions{MaxPerIP: 4, DenylistSeconds: 60, HandshakeTimeout: 5})
+	if got.MaxPerIP != 4 || got.DenylistFor != time.Minute || got.HandshakeTimeout != 5*time.Second {
+		t.Errorf("ConnLimitOptionsFromConfig() = %+v", got)
+	}
+}
@@ -0,0 +1,82 @@
+package mhfpacket
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"erupe-ce/common/byteframe"
+	cfg "erupe-ce/config"
+	"erupe-ce/network"
+	"erupe-ce/network/clientctx"
+	"erupe-ce/network/pcap"
+)
+
+// opcodeCount is the number of opcodes FromOpcode knows, MSG_HEAD through
+// MSG_SYS_reserve1AF.
+const opcodeCount = uint16(network.MSG_SYS_reserve1AF) + 1
+
+// FuzzParse feeds arbitrary payloads to the Parse method of every packet
+// type under every client mode. A malformed client packet must come back as
+// an error or a ByteFrame read error, never a panic:
+//
+//	go test ./network/mhfpacket -run '^$' -fuzz FuzzParse
+func FuzzParse(f *testing.F) {
+	for op := uint16(0); op < opcodeCount; op++ {
+		f.Add(op, uint8(cfg.ZZ), []byte{})
+		f.Add(op, uint8(cfg.ZZ), make([]byte, 32))
+	}
+	for _, rec := range captureSeeds(f) {
+		if len(rec.Payload) >= 2 {
+			f.Add(rec.Opcode, uint8(cfg.ZZ), rec.Payload[2:])
+		}
+	}
+
+	f.Fuzz(func(t *testing.T, op uint16, mode uint8, data []byte) {
+		opcode := network.PacketID(op % opcodeCount)
+		pkt := FromOpcode(opcode)
+		if pkt == nil {
+			return
+		}
+		ctx := &clientctx.ClientContext{RealClientMode: cfg.Mode(mode%uint8(cfg.ZZ) + 1)}
+		defer func() {
+			if r := recover(); r != nil {
+				t.Fatalf("%s Parse panicked on % X (mode %d): %v", opcode, data, ctx.RealClientMode, r)
+			}
+		}()
+		_ = pkt.Parse(byteframe.NewByteFrameFromBytes(data), ctx)
+	})
+}
+
+// captureSeeds returns the client packets of the channel captures used by
+// the golden tests, so fuzzing starts from real traffic.
+func captureSeeds(f *testing.F) []pcap.PacketRecord {
+	paths, _ := filepath.Glob(filepath.Join("..", "..", "server", "channelserver", "testdata", "golden", "*.mhfr"))
+	var records []pcap.PacketRecord
+	for _, path := range paths {
+		file, err := os.Open(path)
+		if err != nil {
+			f.Fatal(err)
+		}
+		r, err := pcap.NewReader(file)
+		if err != nil {
+			f.Fatalf("%s: %v", path, err)
+		}
+		for {
+			rec, err := r.ReadPacket()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				f.Fatalf("%s: %v", path, err)
+			}
+			if rec.Direction == pcap.DirClientToServer {
+				records = append(records, rec)
+			}
+		}
+		_ = file.Close()
+	}
+	return records
+}
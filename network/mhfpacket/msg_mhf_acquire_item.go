@@ -26,7 +26,7 @@ func (m *MsgMhfAcquireItem) Parse(bf *byteframe.ByteFrame, ctx *clientctx.Client
 	m.AckHandle = bf.ReadUint32()
 	m.Unk0 = bf.ReadUint16()
 	m.Length = bf.ReadUint16()
-	for i := 0; i < int(m.Length); i++ {
+	for i := 0; i < int(m.Length) && bf.Err() == nil; i++ {
 		m.Unk1 = append(m.Unk1, bf.ReadUint32())
 	}
 	return nil
@@ -24,7 +24,7 @@ func (m *MsgMhfAcquireTitle) Parse(bf *byteframe.ByteFrame, ctx *clientctx.Clien
 	m.AckHandle = bf.ReadUint32()
 	titles := int(bf.ReadUint16())
 	bf.ReadUint16() // Zeroed
-	for i := 0; i < titles; i++ {
+	for i := 0; i < titles && bf.Err() == nil; i++ {
 		m.TitleIDs = append(m.TitleIDs, bf.ReadUint16())
 	}
 	return nil
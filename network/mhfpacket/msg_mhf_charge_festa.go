@@ -27,7 +27,7 @@ func (m *MsgMhfChargeFesta) Parse(bf *byteframe.ByteFrame, ctx *clientctx.Client
 	m.AckHandle = bf.ReadUint32()
 	m.FestaID = bf.ReadUint32()
 	m.GuildID = bf.ReadUint32()
-	for i := bf.ReadUint16(); i > 0; i-- {
+	for i := bf.ReadUint16(); i > 0 && bf.Err() == nil; i-- {
 		m.Souls = append(m.Souls, bf.ReadUint16())
 	}
 	m.Auto = bf.ReadBool()
@@ -23,7 +23,7 @@ func (m *MsgMhfPostCafeDurationBonusReceived) Opcode() network.PacketID {
 func (m *MsgMhfPostCafeDurationBonusReceived) Parse(bf *byteframe.ByteFrame, ctx *clientctx.ClientContext) error {
 	m.AckHandle = bf.ReadUint32()
 	ids := int(bf.ReadUint32())
-	for i := 0; i < ids; i++ {
+	for i := 0; i < ids && bf.Err() == nil; i++ {
 		m.CafeBonusID = append(m.CafeBonusID, bf.ReadUint32())
 	}
 	return nil
@@ -36,7 +36,7 @@ func (m *MsgMhfPresentBox) Parse(bf *byteframe.ByteFrame, ctx *clientctx.ClientC
 	m.Unk4 = bf.ReadUint32()
 	m.Unk5 = bf.ReadUint32()
 	m.Unk6 = bf.ReadUint32()
-	for i := uint32(0); i < m.Unk2; i++ {
+	for i := uint32(0); i < m.Unk2 && bf.Err() == nil; i++ {
 		m.Unk7 = append(m.Unk7, bf.ReadUint32())
 	}
 	return nil
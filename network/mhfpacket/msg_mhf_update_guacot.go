@@ -32,7 +32,7 @@ func (m *MsgMhfUpdateGuacot) Parse(bf *byteframe.ByteFrame, ctx *clientctx.Clien
 	m.AckHandle = bf.ReadUint32()
 	m.EntryCount = bf.ReadUint16()
 	bf.ReadUint16() // Zeroed
-	for i := 0; i < int(m.EntryCount); i++ {
+	for i := 0; i < int(m.EntryCount) && bf.Err() == nil; i++ {
 		var temp Goocoo
 		temp.Index = bf.ReadUint32()
 		for j := 0; j < 22; j++ {
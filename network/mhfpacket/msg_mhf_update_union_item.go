@@ -26,7 +26,7 @@ func (m *MsgMhfUpdateUnionItem) Parse(bf *byteframe.ByteFrame, ctx *clientctx.Cl
 	changes := int(bf.ReadUint16())
 	bf.ReadUint8() // Zeroed
 	bf.ReadUint8() // Zeroed
-	for i := 0; i < changes; i++ {
+	for i := 0; i < changes && bf.Err() == nil; i++ {
 		m.UpdatedItems = append(m.UpdatedItems, mhfitem.ReadWarehouseItem(bf))
 	}
 	return nil
@@ -30,7 +30,7 @@ func (m *MsgMhfUpdateWarehouse) Parse(bf *byteframe.ByteFrame, ctx *clientctx.Cl
 	changes := int(bf.ReadUint16())
 	bf.ReadUint8() // Zeroed
 	bf.ReadUint8() // Zeroed
-	for i := 0; i < changes; i++ {
+	for i := 0; i < changes && bf.Err() == nil; i++ {
 		switch m.BoxType {
 		case 0:
 			m.UpdatedItems = append(m.UpdatedItems, mhfitem.ReadWarehouseItem(bf))
@@ -40,7 +40,7 @@ func (m *MsgSysTerminalLog) Parse(bf *byteframe.ByteFrame, ctx *clientctx.Client
 	entryCount := int(bf.ReadUint16())
 	bf.ReadUint16() // Zeroed
 
-	for i := 0; i < entryCount; i++ {
+	for i := 0; i < entryCount && bf.Err() == nil; i++ {
 		var e TerminalLogEntry
 		e.Index = bf.ReadUint32()
 		e.Type1 = bf.ReadUint8()
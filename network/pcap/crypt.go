@@ -0,0 +1,96 @@
+package pcap
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// ErrEncrypted is returned reading the packets of an encrypted capture
+// without a key.
+var ErrEncrypted = errors.New("pcap: capture is encrypted, a key is needed to read its packets")
+
+// payloadCipher seals the payloads of an encrypted capture with
+// AES-256-GCM. The key is derived from a secret and the salt of the file,
+// so each file has its own.
+//
+// A sealed payload is a 12-byte random nonce, the ciphertext, then the
+// 16-byte tag. The timestamp, direction, opcode, session and handling time
+// of the record are authenticated along with it.
+type payloadCipher struct {
+	aead cipher.AEAD
+}
+
+func newPayloadCipher(secret []byte, salt [8]byte) (*payloadCipher, error) {
+	if len(secret) == 0 {
+		return nil, errors.New("pcap: empty encryption key")
+	}
+	key, err := hkdf.Key(sha256.New, secret, salt[:], "erupe capture payloads", 32)
+	if err != nil {
+		return nil, fmt.Errorf("pcap: derive key: %w", err)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("pcap: %w", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("pcap: %w", err)
+	}
+	return &payloadCipher{aead: aead}, nil
+}
+
+// recordData is the part of a record authenticated with its payload. The
+// session of a container record and the handling time are included when
+// the record has them.
+func recordData(rec PacketRecord) []byte {
+	b := binary.BigEndian.AppendUint64(nil, uint64(rec.TimestampNs))
+	b = append(b, byte(rec.Direction))
+	b = binary.BigEndian.AppendUint16(b, rec.Opcode)
+	if rec.Session != 0 {
+		b = binary.BigEndian.AppendUint16(b, rec.Session)
+	}
+	if rec.ProcessingNs != 0 {
+		b = binary.BigEndian.AppendUint64(b, uint64(rec.ProcessingNs))
+	}
+	return b
+}
+
+// seal returns the sealed payload of rec.
+func (c *payloadCipher) seal(rec PacketRecord) []byte {
+	nonce := make([]byte, c.aead.NonceSize(), c.aead.NonceSize()+len(rec.Payload)+c.aead.Overhead())
+	_, _ = rand.Read(nonce)
+	return c.aead.Seal(nonce, nonce, rec.Payload, recordData(rec))
+}
+
+// open returns the payload rec was sealed from.
+func (c *payloadCipher) open(rec PacketRecord) ([]byte, error) {
+	n := c.aead.NonceSize()
+	if len(rec.Payload) < n+c.aead.Overhead() {
+		return nil, errors.New("pcap: encrypted payload too short")
+	}
+	p, err := c.aead.Open(nil, rec.Payload[:n], rec.Payload[n:], recordData(rec))
+	if err != nil {
+		return nil, errors.New("pcap: decrypt payload: wrong key or corrupt capture")
+	}
+	return p, nil
+}
+
+// SetKey sets the secret the payloads of an encrypted capture are
+// decrypted with. It does nothing for a capture that is not encrypted.
+func (rd *Reader) SetKey(secret []byte) error {
+	if rd.Header.Flags&FlagEncrypted == 0 {
+		return nil
+	}
+	c, err := newPayloadCipher(secret, rd.Header.KeySalt)
+	if err != nil {
+		return err
+	}
+	rd.cipher = c
+	return nil
+}
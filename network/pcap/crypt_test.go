@@ -0,0 +1,127 @@
+package pcap
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+)
+
+func writeEncrypted(t *testing.T, flags uint32, secret string, records []PacketRecord) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w, err := NewEncryptedWriter(&buf, FileHeader{Version: FormatVersion, Flags: flags}, SessionMetadata{CharID: 3}, []byte(secret))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, rec := range records {
+		if err := w.WritePacket(rec); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestEncryptedRoundTrip(t *testing.T) {
+	payload := []byte("\x00\x13secret chat message")
+	records := []PacketRecord{
+		{TimestampNs: 100, Direction: DirClientToServer, Opcode: 0x0013, Payload: payload},
+		{TimestampNs: 200, Direction: DirServerToClient, Opcode: 0x0012, Payload: nil},
+	}
+	for _, flags := range []uint32{0, FlagZstd} {
+		data := writeEncrypted(t, flags, "hunter2", records)
+		if bytes.Contains(data, []byte("secret chat")) {
+			t.Fatalf("flags %d: payload written in plaintext", flags)
+		}
+
+		r, err := NewReader(bytes.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r.Header.Flags&FlagEncrypted == 0 || r.Header.KeySalt == [8]byte{} || r.Meta.CharID != 3 {
+			t.Fatalf("header = %+v, meta = %+v", r.Header, r.Meta)
+		}
+		if err := r.SetKey([]byte("hunter2")); err != nil {
+			t.Fatal(err)
+		}
+		for i, want := range records {
+			got, err := r.ReadPacket()
+			if err != nil || got.Opcode != want.Opcode || !bytes.Equal(got.Payload, want.Payload) {
+				t.Errorf("flags %d: packet %d = %+v, %v", flags, i, got, err)
+			}
+		}
+		if _, err := r.ReadPacket(); err != io.EOF {
+			t.Errorf("flags %d: after the last packet: %v", flags, err)
+		}
+		// Seeking through the index decrypts too.
+		if got, err := r.ReadAt(0); err != nil || !bytes.Equal(got.Payload, payload) {
+			t.Errorf("flags %d: ReadAt(0) = %+v, %v", flags, got, err)
+		}
+	}
+}
+
+func TestEncryptedWithoutKey(t *testing.T) {
+	rec := PacketRecord{TimestampNs: 100, Direction: DirClientToServer, Opcode: 0x0013, Payload: []byte{0x00, 0x13}}
+	data := writeEncrypted(t, 0, "hunter2", []PacketRecord{rec})
+
+	r, err := NewReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.ReadPacket(); !errors.Is(err, ErrEncrypted) {
+		t.Errorf("ReadPacket() without a key = %v, want ErrEncrypted", err)
+	}
+
+	r, _ = NewReader(bytes.NewReader(data))
+	_ = r.SetKey([]byte("wrong"))
+	if _, err := r.ReadPacket(); err == nil {
+		t.Error("ReadPacket() with the wrong key succeeded")
+	}
+
+	// The opcode is authenticated with the payload.
+	tampered := bytes.Clone(data)
+	i := bytes.Index(tampered[HeaderSize+MinMetadataSize:], []byte{0x00, 0x13, 0x00, 0x00}) + HeaderSize + MinMetadataSize
+	tampered[i+1] = 0x14
+	r, _ = NewReader(bytes.NewReader(tampered))
+	_ = r.SetKey([]byte("hunter2"))
+	if _, err := r.ReadPacket(); err == nil {
+		t.Error("ReadPacket() of a tampered record succeeded")
+	}
+}
+
+func TestEncryptedWriterRules(t *testing.T) {
+	if _, err := NewWriter(io.Discard, FileHeader{Version: FormatVersion, Flags: FlagEncrypted}, SessionMetadata{}); err == nil {
+		t.Error("NewWriter() with the encryption flag succeeded")
+	}
+	if _, err := NewEncryptedWriter(io.Discard, FileHeader{Version: FormatVersion}, SessionMetadata{}, nil); err == nil {
+		t.Error("NewEncryptedWriter() without a key succeeded")
+	}
+	// A plaintext capture ignores the key.
+	var buf bytes.Buffer
+	w, _ := NewWriter(&buf, FileHeader{Version: FormatVersion}, SessionMetadata{})
+	_ = w.Close()
+	r, err := NewReader(bytes.NewReader(buf.Bytes()))
+	if err != nil || r.SetKey([]byte("unused")) != nil {
+		t.Errorf("SetKey() on a plaintext capture: %v", err)
+	}
+}
+
+func TestEncryptedSessionAuthenticated(t *testing.T) {
+	c, err := newPayloadCipher([]byte("hunter2"), [8]byte{1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := PacketRecord{TimestampNs: 100, Direction: DirClientToServer, Opcode: 0x0013, Session: 2, Payload: []byte{0x00, 0x13}}
+	rec.Payload = c.seal(rec)
+	if _, err := c.open(rec); err != nil {
+		t.Fatal(err)
+	}
+	// A record moved to another session of the container is rejected.
+	rec.Session = 3
+	if _, err := c.open(rec); err == nil {
+		t.Error("open() of a record with another session succeeded")
+	}
+}
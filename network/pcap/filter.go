@@ -40,3 +40,19 @@ func FilterExcludeOpcodes(records []PacketRecord, opcodes ...uint16) []PacketRec
 	}
 	return out
 }
+
+// FilterBySession returns only the records of a container belonging to any
+// of the given sessions.
+func FilterBySession(records []PacketRecord, sessions ...uint16) []PacketRecord {
+	set := make(map[uint16]struct{}, len(sessions))
+	for _, id := range sessions {
+		set[id] = struct{}{}
+	}
+	var out []PacketRecord
+	for _, r := range records {
+		if _, ok := set[r.Session]; ok {
+			out = append(out, r)
+		}
+	}
+	return out
+}
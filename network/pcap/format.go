@@ -1,6 +1,10 @@
 package pcap
 
-import "encoding/json"
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
 
 // Capture file format constants.
 const (
@@ -10,6 +14,11 @@ const (
 	// FormatVersion is the current capture format version.
 	FormatVersion uint16 = 1
 
+	// ContainerVersion is the format version of a container: a capture
+	// holding the packets of several sessions, interleaved, each record
+	// naming its session. The sessions are listed in the metadata.
+	ContainerVersion uint16 = 2
+
 	// HeaderSize is the fixed size of the file header in bytes.
 	HeaderSize = 32
 
@@ -19,6 +28,52 @@ const (
 	MinMetadataSize = 512
 )
 
+// Header flags. Files written before flags existed have none set.
+const (
+	// FlagGzip marks a file whose packet records are gzip-compressed.
+	FlagGzip uint32 = 1 << 0
+	// FlagZstd marks a file whose packet records are zstd-compressed.
+	FlagZstd uint32 = 1 << 1
+	// FlagEncrypted marks a file whose packet payloads are encrypted with
+	// AES-256-GCM, see NewEncryptedWriter.
+	FlagEncrypted uint32 = 1 << 2
+	// FlagChecksums marks a file whose packet records are each followed by
+	// a CRC-32 of their bytes, and whose end marker is followed by a
+	// SHA-256 of every record, so a corrupt capture can be told from a
+	// truncated one.
+	FlagChecksums uint32 = 1 << 3
+	// FlagTiming marks a file whose packet records carry the time the
+	// server took to handle them, see PacketRecord.ProcessingNs.
+	FlagTiming uint32 = 1 << 4
+
+	knownFlags = FlagGzip | FlagZstd | FlagEncrypted | FlagChecksums | FlagTiming
+)
+
+// CompressionFlag returns the header flag for a compression name: "gzip",
+// "zstd", or "" and "none" for no compression.
+func CompressionFlag(name string) (uint32, error) {
+	switch strings.ToLower(name) {
+	case "", "none":
+		return 0, nil
+	case "gzip":
+		return FlagGzip, nil
+	case "zstd":
+		return FlagZstd, nil
+	}
+	return 0, fmt.Errorf("pcap: unknown compression %q", name)
+}
+
+// Compression names the compression flags select, "none" without any.
+func Compression(flags uint32) string {
+	switch {
+	case flags&FlagGzip != 0:
+		return "gzip"
+	case flags&FlagZstd != 0:
+		return "zstd"
+	}
+	return "none"
+}
+
 // Direction indicates whether a packet was sent or received.
 type Direction byte
 
@@ -67,25 +122,80 @@ func (st ServerType) String() string {
 //	[1B] ServerType
 //	[1B] ClientMode
 //	[8B] SessionStartNs
-//	[4B] Reserved
+//	[4B] Flags
 //	[4B] MetadataLen
-//	[8B] Reserved
+//	[8B] KeySalt (reserved when not encrypted)
+//
+// The header and metadata are never compressed or encrypted, so metadata
+// can be patched in place; with a compression flag set, the packet records
+// that follow are one compressed stream.
+//
+// In a container, ServerType, ClientMode and SessionStartNs are those of
+// the container as a whole; each session has its own in the metadata.
 type FileHeader struct {
 	Version        uint16
 	ServerType     ServerType
 	ClientMode     byte
 	SessionStartNs int64
+	Flags          uint32
 	MetadataLen    uint32
+	KeySalt        [8]byte // Salt the key of an encrypted file is derived with
+}
+
+// Container reports whether the file is a container of several sessions.
+func (h FileHeader) Container() bool {
+	return h.Version == ContainerVersion
+}
+
+// RecordHeaderSize returns the fixed overhead per packet record of the
+// file, before the payload.
+func (h FileHeader) RecordHeaderSize() int {
+	size := PacketRecordHeaderSize
+	if h.Container() {
+		size += 2
+	}
+	if h.Flags&FlagTiming != 0 {
+		size += 8
+	}
+	return size
 }
 
 // SessionMetadata is the JSON-encoded metadata block following the file header.
 type SessionMetadata struct {
-	ServerVersion string `json:"server_version,omitempty"`
-	Host          string `json:"host,omitempty"`
-	Port          int    `json:"port,omitempty"`
-	CharID        uint32 `json:"char_id,omitempty"`
-	UserID        uint32 `json:"user_id,omitempty"`
-	RemoteAddr    string `json:"remote_addr,omitempty"`
+	ServerVersion string   `json:"server_version,omitempty"`
+	Host          string   `json:"host,omitempty"`
+	Port          int      `json:"port,omitempty"`
+	CharID        uint32   `json:"char_id,omitempty"`
+	UserID        uint32   `json:"user_id,omitempty"`
+	RemoteAddr    string   `json:"remote_addr,omitempty"`
+	Merged        []string `json:"merged,omitempty"` // Captures a merged capture was made from
+	Part          int      `json:"part,omitempty"`   // Position of a rotated file in its session, from 1
+
+	Sessions []SessionInfo `json:"sessions,omitempty"` // Sessions of a container
+}
+
+// SessionInfo describes one session of a container.
+type SessionInfo struct {
+	ID         uint16     `json:"id"` // Session of the records, from 1
+	ServerType ServerType `json:"server_type"`
+	ClientMode byte       `json:"client_mode"`
+	StartNs    int64      `json:"start_ns"`
+	Host       string     `json:"host,omitempty"`
+	Port       int        `json:"port,omitempty"`
+	CharID     uint32     `json:"char_id,omitempty"`
+	UserID     uint32     `json:"user_id,omitempty"`
+	RemoteAddr string     `json:"remote_addr,omitempty"`
+	Source     string     `json:"source,omitempty"` // Capture the session was taken from
+}
+
+// Session returns the session of a container with the given ID.
+func (m *SessionMetadata) Session(id uint16) (SessionInfo, bool) {
+	for _, s := range m.Sessions {
+		if s.ID == id {
+			return s, true
+		}
+	}
+	return SessionInfo{}, false
 }
 
 // MarshalJSON serializes the metadata to JSON.
@@ -97,12 +207,39 @@ func (m *SessionMetadata) MarshalJSON() ([]byte, error) {
 // PacketRecord is a single captured packet.
 //
 //	[8B] TimestampNs  [1B] Direction  [2B] Opcode  [4B] PayloadLen  [NB] Payload
+//
+// In a container, the opcode is followed by the session:
+//
+//	[8B] TimestampNs  [1B] Direction  [2B] Opcode  [2B] Session  [4B] PayloadLen  [NB] Payload
+//
+// With FlagTiming, the opcode, or the session of a container, is followed
+// by the handling time:
+//
+//	[8B] TimestampNs  [1B] Direction  [2B] Opcode  [8B] ProcessingNs  [4B] PayloadLen  [NB] Payload
 type PacketRecord struct {
-	TimestampNs int64
-	Direction   Direction
-	Opcode      uint16
-	Payload     []byte // Full decrypted packet bytes (includes the 2-byte opcode prefix)
+	TimestampNs  int64
+	Direction    Direction
+	Opcode       uint16
+	Session      uint16 // ID of the session in a container; 0 in other captures
+	ProcessingNs int64  // Time the server took to handle a C→S packet, with FlagTiming; 0 when not measured
+	Payload      []byte // Full decrypted packet bytes (includes the 2-byte opcode prefix)
 }
 
 // PacketRecordHeaderSize is the fixed overhead per packet record (before payload).
 const PacketRecordHeaderSize = 8 + 1 + 2 + 4 // 15 bytes
+
+// ContainerRecordHeaderSize is the fixed overhead per packet record of a
+// container.
+const ContainerRecordHeaderSize = PacketRecordHeaderSize + 2 // 17 bytes
+
+// With FlagChecksums, each record, the end marker included, is followed by
+//
+//	[4B] CRC-32 (IEEE) of the record
+//
+// and the end marker then by
+//
+//	[32B] SHA-256 of the records and their checksums, the end marker included
+const (
+	checksumSize = 4
+	hashSize     = 32
+)
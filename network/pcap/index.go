@@ -0,0 +1,234 @@
+package pcap
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// IndexBlockSize is the number of packets in each block of the index.
+const IndexBlockSize = 1024
+
+// indexMagic starts the index and ends the file after it.
+const indexMagic = "MHFX"
+
+// indexTrailerSize is the size of the trailer locating the index.
+const indexTrailerSize = 8 + 4
+
+// Index locates the packets of a closed capture file, so a reader can
+// start at any packet without reading the ones before it. It is written
+// after the end of the packet records:
+//
+//	[4B] "MHFX"  [4B] BlockSize  [8B] Packets  [4B] Blocks
+//	per block: [8B] Offset  [8B] FirstNs  [8B] LastNs  [2B] Opcodes  [2B each] Opcode
+//	[8B] Offset of the index  [4B] "MHFX"
+type Index struct {
+	BlockSize int // Packets in each block but the last
+	Packets   int
+	Blocks    []IndexBlock
+}
+
+// IndexBlock describes BlockSize consecutive packets.
+type IndexBlock struct {
+	Offset  int64    // Offset of the first packet from the start of the file; a compressed frame starts there
+	First   int      // Index of the first packet
+	FirstNs int64    // Earliest packet timestamp
+	LastNs  int64    // Latest packet timestamp
+	Opcodes []uint16 // Opcodes of the block's packets, in order of first appearance
+}
+
+// BlocksWithOpcode returns the blocks holding packets with opcode.
+func (ix *Index) BlocksWithOpcode(opcode uint16) []IndexBlock {
+	var out []IndexBlock
+	for _, b := range ix.Blocks {
+		for _, op := range b.Opcodes {
+			if op == opcode {
+				out = append(out, b)
+				break
+			}
+		}
+	}
+	return out
+}
+
+func writeIndex(w *bufio.Writer, ix *Index, offset int64) error {
+	be := binary.BigEndian
+	buf := []byte(indexMagic)
+	buf = be.AppendUint32(buf, uint32(ix.BlockSize))
+	buf = be.AppendUint64(buf, uint64(ix.Packets))
+	buf = be.AppendUint32(buf, uint32(len(ix.Blocks)))
+	for _, b := range ix.Blocks {
+		buf = be.AppendUint64(buf, uint64(b.Offset))
+		buf = be.AppendUint64(buf, uint64(b.FirstNs))
+		buf = be.AppendUint64(buf, uint64(b.LastNs))
+		buf = be.AppendUint16(buf, uint16(len(b.Opcodes)))
+		for _, op := range b.Opcodes {
+			buf = be.AppendUint16(buf, op)
+		}
+	}
+	buf = be.AppendUint64(buf, uint64(offset))
+	buf = append(buf, indexMagic...)
+	_, err := w.Write(buf)
+	return err
+}
+
+// readIndex reads the index at the end of rs, whose file header is at
+// base. A file without one, such as a capture still being written, has a
+// nil index.
+func readIndex(rs io.ReadSeeker, base int64) (*Index, error) {
+	end, err := rs.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, fmt.Errorf("pcap: %w", err)
+	}
+	if end-base < HeaderSize+indexTrailerSize {
+		return nil, nil
+	}
+	trailer := make([]byte, indexTrailerSize)
+	if _, err := rs.Seek(end-indexTrailerSize, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("pcap: %w", err)
+	}
+	if _, err := io.ReadFull(rs, trailer); err != nil {
+		return nil, fmt.Errorf("pcap: read index trailer: %w", err)
+	}
+	if string(trailer[8:]) != indexMagic {
+		return nil, nil
+	}
+	offset := int64(binary.BigEndian.Uint64(trailer))
+	if offset < HeaderSize || base+offset > end-indexTrailerSize {
+		return nil, errors.New("pcap: index offset out of range")
+	}
+	if _, err := rs.Seek(base+offset, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("pcap: %w", err)
+	}
+	ix, err := decodeIndex(io.LimitReader(rs, end-indexTrailerSize-base-offset))
+	if err != nil {
+		return nil, fmt.Errorf("pcap: read index: %w", err)
+	}
+	return ix, nil
+}
+
+func decodeIndex(r io.Reader) (*Index, error) {
+	br := bufio.NewReader(r)
+	var head struct {
+		Magic     [4]byte
+		BlockSize uint32
+		Packets   uint64
+		Blocks    uint32
+	}
+	if err := binary.Read(br, binary.BigEndian, &head); err != nil {
+		return nil, err
+	}
+	if string(head.Magic[:]) != indexMagic || head.BlockSize == 0 {
+		return nil, errors.New("bad index header")
+	}
+	if uint64(head.Blocks) != (head.Packets+uint64(head.BlockSize)-1)/uint64(head.BlockSize) {
+		return nil, fmt.Errorf("%d blocks do not hold %d packets", head.Blocks, head.Packets)
+	}
+	ix := &Index{BlockSize: int(head.BlockSize), Packets: int(head.Packets), Blocks: make([]IndexBlock, head.Blocks)}
+	for i := range ix.Blocks {
+		var b struct {
+			Offset  int64
+			FirstNs int64
+			LastNs  int64
+			Opcodes uint16
+		}
+		if err := binary.Read(br, binary.BigEndian, &b); err != nil {
+			return nil, err
+		}
+		opcodes := make([]uint16, b.Opcodes)
+		if err := binary.Read(br, binary.BigEndian, opcodes); err != nil {
+			return nil, err
+		}
+		ix.Blocks[i] = IndexBlock{Offset: b.Offset, First: i * ix.BlockSize, FirstNs: b.FirstNs, LastNs: b.LastNs, Opcodes: opcodes}
+	}
+	return ix, nil
+}
+
+// Index returns the index of the file, or nil when it has none or the
+// Reader was not given an io.ReadSeeker.
+func (rd *Reader) Index() *Index {
+	return rd.index
+}
+
+// Seek positions the reader so that ReadPacket returns the packet at index
+// i next. With an index only the packets of i's block before it are read;
+// without one, every packet before it is. The Reader must have been given
+// an io.ReadSeeker.
+func (rd *Reader) Seek(i int) error {
+	if rd.src == nil {
+		return errors.New("pcap: reader cannot seek")
+	}
+	if i < 0 {
+		return fmt.Errorf("pcap: packet %d out of range", i)
+	}
+	offset, first := rd.dataOffset(), 0
+	if rd.index != nil {
+		if i >= rd.index.Packets {
+			return fmt.Errorf("pcap: packet %d out of range, the capture has %d", i, rd.index.Packets)
+		}
+		b := rd.index.Blocks[i/rd.index.BlockSize]
+		offset, first = b.Offset, b.First
+	}
+	// Reading on from the current position is cheaper than restarting.
+	if rd.next > i || rd.next < first || rd.done {
+		if _, err := rd.src.Seek(rd.base+offset, io.SeekStart); err != nil {
+			return fmt.Errorf("pcap: %w", err)
+		}
+		r, err := decompress(rd.src, rd.Header.Flags)
+		if err != nil {
+			return err
+		}
+		rd.r, rd.next, rd.done = r, first, false
+		rd.resetHash(first == 0)
+	}
+	for rd.next < i {
+		if _, err := rd.ReadPacket(); err == io.EOF {
+			return fmt.Errorf("pcap: packet %d out of range, the capture has %d", i, rd.next)
+		} else if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReadAt returns the packet at index i, leaving the reader positioned
+// after it.
+func (rd *Reader) ReadAt(i int) (PacketRecord, error) {
+	if err := rd.Seek(i); err != nil {
+		return PacketRecord{}, err
+	}
+	return rd.ReadPacket()
+}
+
+// SeekTime positions the reader at the first packet recorded at or after
+// ns, and returns its index. It returns io.EOF when every packet is
+// earlier.
+func (rd *Reader) SeekTime(ns int64) (int, error) {
+	start := 0
+	if rd.index != nil {
+		// Packets are recorded in order, give or take a packet written
+		// while another was being timed, so the first block ending at or
+		// after ns holds the packet.
+		b := sort.Search(len(rd.index.Blocks), func(b int) bool { return rd.index.Blocks[b].LastNs >= ns })
+		if b == len(rd.index.Blocks) {
+			return 0, io.EOF
+		}
+		start = rd.index.Blocks[b].First
+	}
+	if err := rd.Seek(start); err != nil {
+		return 0, err
+	}
+	for {
+		i := rd.next
+		rec, err := rd.ReadPacket()
+		if err != nil {
+			return 0, err
+		}
+		if rec.TimestampNs >= ns {
+			return i, rd.Seek(i)
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package pcap
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+// indexedCapture writes n packets, one microsecond apart, with the opcode
+// 0x0061 on every 500th and 0x0013 otherwise.
+func indexedCapture(t *testing.T, flags uint32, n int, close bool) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, FileHeader{Version: FormatVersion, ServerType: ServerTypeChannel, Flags: flags}, SessionMetadata{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < n; i++ {
+		op := uint16(0x0013)
+		if i%500 == 0 {
+			op = 0x0061
+		}
+		rec := PacketRecord{TimestampNs: int64(i) * 1000, Direction: DirClientToServer, Opcode: op, Payload: []byte{byte(op >> 8), byte(op), byte(i), byte(i >> 8)}}
+		if err := w.WritePacket(rec); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if close {
+		err = w.Close()
+	} else {
+		err = w.Flush()
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestIndex(t *testing.T) {
+	for _, flags := range []uint32{0, FlagGzip, FlagZstd} {
+		t.Run(Compression(flags), func(t *testing.T) {
+			data := indexedCapture(t, flags, 2500, true)
+			r, err := NewReader(bytes.NewReader(data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			ix := r.Index()
+			if ix == nil {
+				t.Fatal("no index")
+			}
+			if ix.Packets != 2500 || ix.BlockSize != IndexBlockSize || len(ix.Blocks) != 3 {
+				t.Fatalf("index = %d packets in %d blocks of %d", ix.Packets, len(ix.Blocks), ix.BlockSize)
+			}
+			if b := ix.Blocks[1]; b.First != 1024 || b.FirstNs != 1024000 || b.LastNs != 2047000 {
+				t.Errorf("block 1 = %+v", b)
+			}
+			if got := ix.BlocksWithOpcode(0x0061); len(got) != 2 || got[1].First != 1024 {
+				t.Errorf("BlocksWithOpcode(0x0061) = %d blocks", len(got))
+			}
+			if got := ix.BlocksWithOpcode(0x0017); len(got) != 0 {
+				t.Errorf("BlocksWithOpcode(0x0017) = %d blocks", len(got))
+			}
+
+			for _, i := range []int{2000, 5, 1024, 1023, 2499, 0} {
+				rec, err := r.ReadAt(i)
+				if err != nil {
+					t.Fatalf("ReadAt(%d): %v", i, err)
+				}
+				if rec.TimestampNs != int64(i)*1000 {
+					t.Errorf("ReadAt(%d) = packet at %d", i, rec.TimestampNs)
+				}
+			}
+			if _, err := r.ReadAt(2500); err == nil {
+				t.Error("ReadAt(2500) succeeded")
+			}
+
+			i, err := r.SeekTime(1500500)
+			if err != nil || i != 1501 {
+				t.Fatalf("SeekTime = %d, %v", i, err)
+			}
+			if rec, err := r.ReadPacket(); err != nil || rec.TimestampNs != 1501000 {
+				t.Errorf("after SeekTime: %+v, %v", rec, err)
+			}
+			if _, err := r.SeekTime(1 << 40); err != io.EOF {
+				t.Errorf("SeekTime past the end = %v, want io.EOF", err)
+			}
+
+			// Reading in order stops at the end of the records, before the index.
+			r, err = NewReader(bytes.NewReader(data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			n := 0
+			for {
+				if _, err := r.ReadPacket(); err == io.EOF {
+					break
+				} else if err != nil {
+					t.Fatalf("ReadPacket(%d): %v", n, err)
+				}
+				n++
+			}
+			if n != 2500 {
+				t.Errorf("read %d packets, want 2500", n)
+			}
+		})
+	}
+}
+
+func TestIndex_Unclosed(t *testing.T) {
+	data := indexedCapture(t, 0, 1100, false)
+	r, err := NewReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Index() != nil {
+		t.Fatal("unclosed capture has an index")
+	}
+	// Without an index Seek reads from the start.
+	if rec, err := r.ReadAt(1050); err != nil || rec.TimestampNs != 1050000 {
+		t.Errorf("ReadAt(1050) = %+v, %v", rec, err)
+	}
+	if _, err := r.ReadAt(1100); err == nil {
+		t.Error("ReadAt(1100) succeeded")
+	}
+}
+
+func TestIndex_NotSeekable(t *testing.T) {
+	data := indexedCapture(t, FlagZstd, 10, true)
+	r, err := NewReader(io.MultiReader(bytes.NewReader(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Index() != nil {
+		t.Error("index read without seeking")
+	}
+	if err := r.Seek(3); err == nil {
+		t.Error("Seek succeeded on a reader that cannot seek")
+	}
+	n := 0
+	for ; ; n++ {
+		if _, err := r.ReadPacket(); err != nil {
+			break
+		}
+	}
+	if n != 10 {
+		t.Errorf("read %d packets, want 10", n)
+	}
+}
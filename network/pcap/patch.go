@@ -16,14 +16,14 @@ func PatchMetadata(f *os.File, meta SessionMetadata) error {
 		return fmt.Errorf("pcap: marshal metadata: %w", err)
 	}
 
-	// Read MetadataLen from header (offset 20: after magic(4)+version(2)+servertype(1)+clientmode(1)+startnanos(8)+reserved(4)).
-	var metaLen uint32
-	if _, err := f.Seek(20, 0); err != nil {
-		return fmt.Errorf("pcap: seek to metadata len: %w", err)
-	}
-	if err := binary.Read(f, binary.BigEndian, &metaLen); err != nil {
+	// Read MetadataLen from header (offset 20: after magic(4)+version(2)+servertype(1)+clientmode(1)+startnanos(8)+flags(4)).
+	// ReadAt and WriteAt leave the file offset alone, as a Writer may still
+	// be appending packets to f.
+	lenBuf := make([]byte, 4)
+	if _, err := f.ReadAt(lenBuf, 20); err != nil {
 		return fmt.Errorf("pcap: read metadata len: %w", err)
 	}
+	metaLen := binary.BigEndian.Uint32(lenBuf)
 
 	if uint32(len(newJSON)) > metaLen {
 		return fmt.Errorf("pcap: new metadata (%d bytes) exceeds allocated space (%d bytes)", len(newJSON), metaLen)
@@ -37,10 +37,7 @@ func PatchMetadata(f *os.File, meta SessionMetadata) error {
 	}
 
 	// Write at offset HeaderSize (32).
-	if _, err := f.Seek(HeaderSize, 0); err != nil {
-		return fmt.Errorf("pcap: seek to metadata: %w", err)
-	}
-	if _, err := f.Write(padded); err != nil {
+	if _, err := f.WriteAt(padded, HeaderSize); err != nil {
 		return fmt.Errorf("pcap: write metadata: %w", err)
 	}
 
@@ -2,9 +2,12 @@ package pcap
 
 import (
 	"bytes"
+	"errors"
 	"io"
 	"os"
+	"path/filepath"
 	"testing"
+	"time"
 )
 
 func TestRoundTrip(t *testing.T) {
@@ -361,3 +364,345 @@ func TestServerTypeString(t *testing.T) {
 		t.Errorf("unknown server type = %q", ServerType(0xFF).String())
 	}
 }
+
+func TestPrune(t *testing.T) {
+	dir := t.TempDir()
+	old := time.Now().Add(-48 * time.Hour)
+	for _, name := range []string{"old.mhfr", "new.mhfr", "old.txt"} {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte("x"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if name != "new.mhfr" {
+			if err := os.Chtimes(path, old, old); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	n, err := Prune(dir, time.Now().Add(-24*time.Hour))
+	if err != nil || n != 1 {
+		t.Fatalf("Prune() = %d, %v, want 1", n, err)
+	}
+	for name, want := range map[string]bool{"old.mhfr": false, "new.mhfr": true, "old.txt": true} {
+		if _, err := os.Stat(filepath.Join(dir, name)); (err == nil) != want {
+			t.Errorf("%s kept = %v, want %v", name, err == nil, want)
+		}
+	}
+	if n, err := Prune(filepath.Join(dir, "missing"), time.Now()); err != nil || n != 0 {
+		t.Errorf("Prune() of a missing dir = %d, %v", n, err)
+	}
+}
+
+func TestCompressedRoundTrip(t *testing.T) {
+	packets := []PacketRecord{
+		{TimestampNs: 100, Direction: DirClientToServer, Opcode: 0x0013, Payload: []byte{0x00, 0x13, 0x01, 0x02}},
+		{TimestampNs: 200, Direction: DirServerToClient, Opcode: 0x0012, Payload: bytes.Repeat([]byte{0x00, 0x12}, 4096)},
+	}
+	for _, name := range []string{"gzip", "zstd"} {
+		t.Run(name, func(t *testing.T) {
+			flag, err := CompressionFlag(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var buf bytes.Buffer
+			w, err := NewWriter(&buf, FileHeader{Version: FormatVersion, ServerType: ServerTypeChannel, Flags: flag}, SessionMetadata{Host: "127.0.0.1"})
+			if err != nil {
+				t.Fatalf("NewWriter: %v", err)
+			}
+			for _, p := range packets {
+				if err := w.WritePacket(p); err != nil {
+					t.Fatalf("WritePacket: %v", err)
+				}
+			}
+			if err := w.Close(); err != nil {
+				t.Fatalf("Close: %v", err)
+			}
+			if buf.Len() > HeaderSize+MinMetadataSize+1024 {
+				t.Errorf("compressed capture is %d bytes", buf.Len())
+			}
+
+			r, err := NewReader(bytes.NewReader(buf.Bytes()))
+			if err != nil {
+				t.Fatalf("NewReader: %v", err)
+			}
+			if r.Header.Flags != flag || Compression(r.Header.Flags) != name || r.Meta.Host != "127.0.0.1" {
+				t.Errorf("header = %+v, meta = %+v", r.Header, r.Meta)
+			}
+			for i, want := range packets {
+				got, err := r.ReadPacket()
+				if err != nil {
+					t.Fatalf("ReadPacket(%d): %v", i, err)
+				}
+				if got.TimestampNs != want.TimestampNs || !bytes.Equal(got.Payload, want.Payload) {
+					t.Errorf("packet %d differs", i)
+				}
+			}
+			if _, err := r.ReadPacket(); err != io.EOF {
+				t.Errorf("ReadPacket at end = %v, want io.EOF", err)
+			}
+		})
+	}
+}
+
+func TestCompressedFlushIsReadable(t *testing.T) {
+	for _, flag := range []uint32{FlagGzip, FlagZstd} {
+		var buf bytes.Buffer
+		w, err := NewWriter(&buf, FileHeader{Version: FormatVersion, Flags: flag}, SessionMetadata{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Nothing flushed yet reads as an empty capture.
+		r, err := NewReader(bytes.NewReader(buf.Bytes()))
+		if err != nil {
+			t.Fatalf("%s: NewReader before any packet: %v", Compression(flag), err)
+		}
+		if _, err := r.ReadPacket(); err != io.EOF {
+			t.Errorf("%s: ReadPacket = %v, want io.EOF", Compression(flag), err)
+		}
+
+		if err := w.WritePacket(PacketRecord{TimestampNs: 1, Direction: DirClientToServer, Opcode: 0x0017, Payload: []byte{0x00, 0x17}}); err != nil {
+			t.Fatal(err)
+		}
+		if err := w.Flush(); err != nil {
+			t.Fatal(err)
+		}
+		// The stream is still open, as for a session being recorded.
+		r, err = NewReader(bytes.NewReader(buf.Bytes()))
+		if err != nil {
+			t.Fatalf("%s: NewReader: %v", Compression(flag), err)
+		}
+		if rec, err := r.ReadPacket(); err != nil || rec.Opcode != 0x0017 {
+			t.Errorf("%s: ReadPacket = %+v, %v", Compression(flag), rec, err)
+		}
+	}
+}
+
+func TestHeaderFlags(t *testing.T) {
+	if _, err := NewWriter(io.Discard, FileHeader{Version: FormatVersion, Flags: FlagGzip | FlagZstd}, SessionMetadata{}); err == nil {
+		t.Error("NewWriter accepted two compression flags")
+	}
+	if _, err := NewWriter(io.Discard, FileHeader{Version: FormatVersion, Flags: 1 << 7}, SessionMetadata{}); err == nil {
+		t.Error("NewWriter accepted an unknown flag")
+	}
+
+	var buf bytes.Buffer
+	if _, err := NewWriter(&buf, FileHeader{Version: FormatVersion}, SessionMetadata{}); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+	data[19] = 0x80
+	if _, err := NewReader(bytes.NewReader(data)); err == nil {
+		t.Error("NewReader accepted an unknown flag")
+	}
+
+	if _, err := CompressionFlag("lz4"); err == nil {
+		t.Error("CompressionFlag accepted lz4")
+	}
+	if flag, err := CompressionFlag("none"); err != nil || flag != 0 || Compression(flag) != "none" {
+		t.Errorf(`CompressionFlag("none") = %d, %v`, flag, err)
+	}
+}
+
+func TestPatchMetadataWhileWriting(t *testing.T) {
+	f, err := os.Create(filepath.Join(t.TempDir(), "patch.mhfr"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = f.Close() }()
+	w, err := NewWriter(f, FileHeader{Version: FormatVersion}, SessionMetadata{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := PacketRecord{TimestampNs: 1, Direction: DirClientToServer, Opcode: 0x0013, Payload: []byte{0x00, 0x13}}
+	_ = w.WritePacket(rec)
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if err := PatchMetadata(f, SessionMetadata{CharID: 9}); err != nil {
+		t.Fatal(err)
+	}
+	_ = w.WritePacket(rec)
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	r, err := NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Meta.CharID != 9 || r.Index() == nil || r.Index().Packets != 2 {
+		t.Fatalf("meta = %+v, index = %+v", r.Meta, r.Index())
+	}
+	for i := 0; i < 2; i++ {
+		if got, err := r.ReadPacket(); err != nil || got.Opcode != 0x0013 {
+			t.Errorf("packet %d = %+v, %v", i, got, err)
+		}
+	}
+}
+
+func TestContainer(t *testing.T) {
+	meta := SessionMetadata{Sessions: []SessionInfo{
+		{ID: 1, ServerType: ServerTypeSign, ClientMode: 40, StartNs: 100, UserID: 7},
+		{ID: 2, ServerType: ServerTypeChannel, ClientMode: 40, StartNs: 150, CharID: 3},
+	}}
+	records := []PacketRecord{
+		{TimestampNs: 110, Direction: DirClientToServer, Opcode: 0x4453, Session: 1, Payload: []byte("DSGN:")},
+		{TimestampNs: 160, Direction: DirClientToServer, Opcode: 0x0013, Session: 2, Payload: []byte{0x00, 0x13}},
+		{TimestampNs: 170, Direction: DirServerToClient, Opcode: 0x0000, Session: 1, Payload: []byte{0x01}},
+	}
+	for _, flags := range []uint32{0, FlagZstd} {
+		var buf bytes.Buffer
+		hdr := FileHeader{Version: ContainerVersion, ServerType: ServerTypeChannel, ClientMode: 40, SessionStartNs: 100, Flags: flags}
+		w, err := NewWriter(&buf, hdr, meta)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, rec := range records {
+			if err := w.WritePacket(rec); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		r, err := NewReader(bytes.NewReader(buf.Bytes()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !r.Header.Container() {
+			t.Fatalf("flags %d: version %d read back", flags, r.Header.Version)
+		}
+		if s, ok := r.Meta.Session(2); !ok || s.ServerType != ServerTypeChannel || s.CharID != 3 {
+			t.Errorf("flags %d: Session(2) = %+v, %v", flags, s, ok)
+		}
+		var got []PacketRecord
+		for {
+			rec, err := r.ReadPacket()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			got = append(got, rec)
+		}
+		if len(got) != len(records) {
+			t.Fatalf("flags %d: read %d packets, want %d", flags, len(got), len(records))
+		}
+		for i := range records {
+			if got[i].Session != records[i].Session || !bytes.Equal(got[i].Payload, records[i].Payload) {
+				t.Errorf("flags %d: packet %d = %+v, want %+v", flags, i, got[i], records[i])
+			}
+		}
+		if sign := FilterBySession(got, 1); len(sign) != 2 || sign[1].TimestampNs != 170 {
+			t.Errorf("flags %d: FilterBySession(1) = %+v", flags, sign)
+		}
+		if rec, err := r.ReadAt(1); err != nil || rec.Session != 2 {
+			t.Errorf("flags %d: ReadAt(1) = %+v, %v", flags, rec, err)
+		}
+	}
+
+	// Only containers have sessions.
+	w, err := NewWriter(io.Discard, FileHeader{Version: FormatVersion}, SessionMetadata{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.WritePacket(records[0]); err == nil {
+		t.Error("WritePacket() of a packet with a session in a single session capture succeeded")
+	}
+}
+
+func TestChecksums(t *testing.T) {
+	for _, flags := range []uint32{0, FlagGzip, FlagZstd} {
+		data := indexedCapture(t, flags|FlagChecksums, 1200, true)
+		r, err := NewReader(bytes.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		n := 0
+		for {
+			if _, err := r.ReadPacket(); err == io.EOF {
+				break
+			} else if err != nil {
+				t.Fatalf("flags %d: packet %d: %v", flags, n, err)
+			}
+			n++
+		}
+		if n != 1200 || !r.Closed() {
+			t.Errorf("flags %d: read %d packets, closed %v", flags, n, r.Closed())
+		}
+		// Reading from the middle skips the hash, which covers every record.
+		if rec, err := r.ReadAt(1100); err != nil || rec.TimestampNs != 1100*1000 {
+			t.Fatalf("flags %d: ReadAt(1100) = %+v, %v", flags, rec, err)
+		}
+		for {
+			if _, err := r.ReadPacket(); err == io.EOF {
+				break
+			} else if err != nil {
+				t.Fatalf("flags %d: after ReadAt: %v", flags, err)
+			}
+		}
+	}
+}
+
+func TestChecksumsCorrupt(t *testing.T) {
+	data := indexedCapture(t, FlagChecksums, 10, true)
+	r, err := NewReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The last byte of the payload of packet 3.
+	start := int(r.dataOffset())
+	recordSize := PacketRecordHeaderSize + 4 + checksumSize
+
+	corrupt := bytes.Clone(data)
+	corrupt[start+4*recordSize-checksumSize-1] ^= 0xFF
+	if n, err := readAll(corrupt); n != 3 || !errors.Is(err, ErrCorrupt) {
+		t.Errorf("corrupt packet: read %d packets, %v; want 3, ErrCorrupt", n, err)
+	}
+
+	// The first byte of the hash, after the end marker and its checksum.
+	corrupt = bytes.Clone(data)
+	corrupt[start+10*recordSize+PacketRecordHeaderSize+checksumSize] ^= 0xFF
+	if n, err := readAll(corrupt); n != 10 || !errors.Is(err, ErrCorrupt) {
+		t.Errorf("corrupt hash: read %d packets, %v; want 10, ErrCorrupt", n, err)
+	}
+
+	// Cut short within packet 5.
+	if n, err := readAll(data[:start+5*recordSize+10]); n != 5 || !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("truncated: read %d packets, %v; want 5, io.ErrUnexpectedEOF", n, err)
+	}
+	r, err = NewReader(bytes.NewReader(data[:start+5*recordSize]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for err == nil {
+		_, err = r.ReadPacket()
+	}
+	if err != io.EOF || r.Closed() {
+		t.Errorf("truncated between packets: %v, closed %v; want io.EOF, not closed", err, r.Closed())
+	}
+}
+
+// readAll reads the packets of a capture until an error, returning how
+// many were read and the error, nil at the end.
+func readAll(data []byte) (int, error) {
+	r, err := NewReader(bytes.NewReader(data))
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for {
+		if _, err := r.ReadPacket(); err == io.EOF {
+			return n, nil
+		} else if err != nil {
+			return n, err
+		}
+		n++
+	}
+}
@@ -0,0 +1,81 @@
+package pcap
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Prune deletes the .mhfr capture files in dir last written before before,
+// and returns the number deleted. A missing dir holds nothing to delete.
+func Prune(dir string, before time.Time) (int, error) {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return 0, nil
+	} else if err != nil {
+		return 0, err
+	}
+	n := 0
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".mhfr") {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil || !info.ModTime().Before(before) {
+			continue
+		}
+		if err := os.Remove(filepath.Join(dir, e.Name())); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
+
+// PruneToSize deletes the oldest .mhfr capture files in dir until those
+// left total at most maxTotal bytes, and returns the number deleted. Files
+// a Recording is still writing are neither deleted nor counted.
+func PruneToSize(dir string, maxTotal int64) (int, error) {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return 0, nil
+	} else if err != nil {
+		return 0, err
+	}
+	type capture struct {
+		path    string
+		size    int64
+		modTime time.Time
+	}
+	var captures []capture
+	var total int64
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".mhfr") {
+			continue
+		}
+		path := filepath.Join(dir, e.Name())
+		info, err := e.Info()
+		if err != nil || isOpen(path) {
+			continue
+		}
+		captures = append(captures, capture{path, info.Size(), info.ModTime()})
+		total += info.Size()
+	}
+	sort.Slice(captures, func(i, j int) bool { return captures[i].modTime.Before(captures[j].modTime) })
+	n := 0
+	for _, c := range captures {
+		if total <= maxTotal {
+			break
+		}
+		if err := os.Remove(c.path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return n, err
+		}
+		total -= c.size
+		n++
+	}
+	return n, nil
+}
@@ -1,10 +1,20 @@
 package pcap
 
 import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
 	"encoding/binary"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"hash"
+	"hash/crc32"
 	"io"
+	"slices"
+
+	"github.com/klauspost/compress/zstd"
 )
 
 // Reader reads .mhfr capture files.
@@ -12,8 +22,21 @@ type Reader struct {
 	r      io.Reader
 	Header FileHeader
 	Meta   SessionMetadata
+
+	src    io.ReadSeeker // The file, when it can seek
+	base   int64         // Offset of the file header in src
+	index  *Index
+	next   int            // Index of the packet ReadPacket returns next
+	done   bool           // The end of the packet records was read
+	cipher *payloadCipher // Opens the payloads of an encrypted file, see SetKey
+	hash   hash.Hash      // Hashes the records read since the first, in a file with checksums
+	buf    []byte         // Record being read
 }
 
+// ErrCorrupt is returned reading a record or end of a file with checksums
+// whose bytes are not those written.
+var ErrCorrupt = errors.New("pcap: capture is corrupt")
+
 // NewReader creates a Reader, reading and validating the file header and metadata.
 func NewReader(r io.Reader) (*Reader, error) {
 	// Read magic.
@@ -30,8 +53,8 @@ func NewReader(r io.Reader) (*Reader, error) {
 	if err := binary.Read(r, binary.BigEndian, &hdr.Version); err != nil {
 		return nil, fmt.Errorf("pcap: read version: %w", err)
 	}
-	if hdr.Version != FormatVersion {
-		return nil, fmt.Errorf("pcap: unsupported version %d, expected %d", hdr.Version, FormatVersion)
+	if hdr.Version != FormatVersion && hdr.Version != ContainerVersion {
+		return nil, fmt.Errorf("pcap: unsupported version %d, expected %d or %d", hdr.Version, FormatVersion, ContainerVersion)
 	}
 
 	var serverType byte
@@ -47,18 +70,19 @@ func NewReader(r io.Reader) (*Reader, error) {
 		return nil, fmt.Errorf("pcap: read session start: %w", err)
 	}
 
-	// Skip 4 reserved bytes.
-	if _, err := io.ReadFull(r, make([]byte, 4)); err != nil {
-		return nil, fmt.Errorf("pcap: read reserved: %w", err)
+	if err := binary.Read(r, binary.BigEndian, &hdr.Flags); err != nil {
+		return nil, fmt.Errorf("pcap: read flags: %w", err)
+	}
+	if hdr.Flags&^knownFlags != 0 {
+		return nil, fmt.Errorf("pcap: unknown header flags 0x%X", hdr.Flags&^knownFlags)
 	}
 
 	if err := binary.Read(r, binary.BigEndian, &hdr.MetadataLen); err != nil {
 		return nil, fmt.Errorf("pcap: read metadata len: %w", err)
 	}
 
-	// Skip 8 reserved bytes.
-	if _, err := io.ReadFull(r, make([]byte, 8)); err != nil {
-		return nil, fmt.Errorf("pcap: read reserved: %w", err)
+	if _, err := io.ReadFull(r, hdr.KeySalt[:]); err != nil {
+		return nil, fmt.Errorf("pcap: read key salt: %w", err)
 	}
 
 	// Read metadata JSON.
@@ -72,39 +96,177 @@ func NewReader(r io.Reader) (*Reader, error) {
 		return nil, fmt.Errorf("pcap: unmarshal metadata: %w", err)
 	}
 
-	return &Reader{r: r, Header: hdr, Meta: meta}, nil
+	rd := &Reader{Header: hdr, Meta: meta}
+	rd.resetHash(true)
+	// Pipes are files too, but cannot seek.
+	if rs, ok := r.(io.ReadSeeker); ok {
+		if pos, err := rs.Seek(0, io.SeekCurrent); err == nil {
+			rd.src = rs
+			rd.base = pos - rd.dataOffset()
+			if rd.index, err = readIndex(rs, rd.base); err != nil {
+				return nil, err
+			}
+			if _, err := rs.Seek(pos, io.SeekStart); err != nil {
+				return nil, fmt.Errorf("pcap: %w", err)
+			}
+		}
+	}
+	packets, err := decompress(r, hdr.Flags)
+	if err != nil {
+		return nil, err
+	}
+	rd.r = packets
+	return rd, nil
+}
+
+// dataOffset returns the offset of the first packet record from the start
+// of the file.
+func (rd *Reader) dataOffset() int64 {
+	return HeaderSize + int64(rd.Header.MetadataLen)
+}
+
+// decompress returns a reader of the packet records following the
+// metadata.
+func decompress(r io.Reader, flags uint32) (io.Reader, error) {
+	switch {
+	case flags&FlagGzip != 0:
+		gz, err := gzip.NewReader(r)
+		if errors.Is(err, io.EOF) {
+			// Nothing was flushed before the capture stopped.
+			return bytes.NewReader(nil), nil
+		} else if err != nil {
+			return nil, fmt.Errorf("pcap: gzip: %w", err)
+		}
+		return bufio.NewReader(gz), nil
+	case flags&FlagZstd != 0:
+		// One goroutine-free stream decoder, as the file is read in order.
+		dec, err := zstd.NewReader(r, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return nil, fmt.Errorf("pcap: zstd: %w", err)
+		}
+		return bufio.NewReader(dec), nil
+	}
+	return bufio.NewReader(r), nil
+}
+
+// resetHash starts hashing the records read anew, from the first when
+// first is set; the hash of the file can only be checked then.
+func (rd *Reader) resetHash(first bool) {
+	rd.hash = nil
+	if first && rd.Header.Flags&FlagChecksums != 0 {
+		rd.hash = sha256.New()
+	}
+}
+
+// Closed reports whether the end of the packet records was read: a
+// capture that was closed by its writer. A capture ending without it was
+// cut short, as when the server writing it was killed.
+func (rd *Reader) Closed() bool {
+	return rd.done
 }
 
-// ReadPacket reads the next packet record. Returns io.EOF when no more packets.
+// ReadPacket reads the next packet record. Returns io.EOF when no more
+// packets, ErrEncrypted for an encrypted capture without SetKey, and
+// ErrCorrupt when the checksum of the record or the hash of a closed file
+// does not match.
 func (rd *Reader) ReadPacket() (PacketRecord, error) {
 	var rec PacketRecord
+	if rd.done {
+		return rec, io.EOF
+	}
 
-	if err := binary.Read(rd.r, binary.BigEndian, &rec.TimestampNs); err != nil {
-		if err == io.EOF || err == io.ErrUnexpectedEOF {
+	size := rd.Header.RecordHeaderSize()
+	b := slices.Grow(rd.buf[:0], size)[:size]
+	// A file cut short within a timestamp ends like one cut between records.
+	if n, err := io.ReadFull(rd.r, b); err != nil {
+		if n < 8 && (err == io.EOF || err == io.ErrUnexpectedEOF) {
 			return rec, io.EOF
 		}
-		return rec, fmt.Errorf("pcap: read timestamp: %w", err)
+		return rec, fmt.Errorf("pcap: read record: %w", err)
 	}
-
-	var dir byte
-	if err := binary.Read(rd.r, binary.BigEndian, &dir); err != nil {
-		return rec, fmt.Errorf("pcap: read direction: %w", err)
+	be := binary.BigEndian
+	rec.TimestampNs = int64(be.Uint64(b))
+	rec.Direction = Direction(b[8])
+	rec.Opcode = be.Uint16(b[9:])
+	field := b[11:]
+	if rd.Header.Container() {
+		rec.Session = be.Uint16(field)
+		field = field[2:]
+	}
+	if rd.Header.Flags&FlagTiming != 0 {
+		rec.ProcessingNs = int64(be.Uint64(field))
 	}
-	rec.Direction = Direction(dir)
+	payloadLen := be.Uint32(b[size-4:])
 
-	if err := binary.Read(rd.r, binary.BigEndian, &rec.Opcode); err != nil {
-		return rec, fmt.Errorf("pcap: read opcode: %w", err)
+	if payloadLen > 0 {
+		b = slices.Grow(b, int(payloadLen))
+		if _, err := io.ReadFull(rd.r, b[size:size+int(payloadLen)]); err != nil {
+			return rec, fmt.Errorf("pcap: read payload: %w", err)
+		}
+		b = b[:size+int(payloadLen)]
+	}
+	rd.buf = b
+	if err := rd.checkRecord(b); err != nil {
+		return rec, err
 	}
 
-	var payloadLen uint32
-	if err := binary.Read(rd.r, binary.BigEndian, &payloadLen); err != nil {
-		return rec, fmt.Errorf("pcap: read payload len: %w", err)
+	// A record without a direction ends the packets of a closed file.
+	if rec.Direction == 0 && payloadLen == 0 {
+		if err := rd.checkHash(); err != nil {
+			return rec, err
+		}
+		rd.done = true
+		return PacketRecord{}, io.EOF
 	}
 
-	rec.Payload = make([]byte, payloadLen)
-	if _, err := io.ReadFull(rd.r, rec.Payload); err != nil {
-		return rec, fmt.Errorf("pcap: read payload: %w", err)
+	rec.Payload = bytes.Clone(b[size:])
+	if rd.Header.Flags&FlagEncrypted != 0 {
+		if rd.cipher == nil {
+			return rec, ErrEncrypted
+		}
+		p, err := rd.cipher.open(rec)
+		if err != nil {
+			return rec, err
+		}
+		rec.Payload = p
 	}
 
+	rd.next++
 	return rec, nil
 }
+
+// checkRecord reads the checksum following the record b, in a file with
+// checksums, and checks it.
+func (rd *Reader) checkRecord(b []byte) error {
+	if rd.Header.Flags&FlagChecksums == 0 {
+		return nil
+	}
+	var sum [checksumSize]byte
+	if _, err := io.ReadFull(rd.r, sum[:]); err != nil {
+		return fmt.Errorf("pcap: read checksum: %w", err)
+	}
+	if binary.BigEndian.Uint32(sum[:]) != crc32.ChecksumIEEE(b) {
+		return fmt.Errorf("%w: checksum of packet %d does not match", ErrCorrupt, rd.next)
+	}
+	if rd.hash != nil {
+		rd.hash.Write(b)
+		rd.hash.Write(sum[:])
+	}
+	return nil
+}
+
+// checkHash reads the hash following the end marker, in a file with
+// checksums, and checks it when every record was read.
+func (rd *Reader) checkHash() error {
+	if rd.Header.Flags&FlagChecksums == 0 {
+		return nil
+	}
+	var sum [hashSize]byte
+	if _, err := io.ReadFull(rd.r, sum[:]); err != nil {
+		return fmt.Errorf("pcap: read hash: %w", err)
+	}
+	if rd.hash != nil && !bytes.Equal(sum[:], rd.hash.Sum(nil)) {
+		return fmt.Errorf("%w: hash of the packets does not match", ErrCorrupt)
+	}
+	return nil
+}
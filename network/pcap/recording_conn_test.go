@@ -5,6 +5,7 @@ import (
 	"io"
 	"sync"
 	"testing"
+	"time"
 )
 
 // mockConn implements network.Conn for testing.
@@ -71,7 +72,8 @@ func TestRecordingConnBasic(t *testing.T) {
 		t.Fatalf("SendPacket: %v", err)
 	}
 
-	// Flush and read back.
+	// Wait for the queued packets, flush and read back.
+	rc.Close()
 	if err := w.Flush(); err != nil {
 		t.Fatalf("Flush: %v", err)
 	}
@@ -156,6 +158,7 @@ func TestRecordingConnConcurrent(t *testing.T) {
 
 	wg.Wait()
 
+	rc.Close()
 	if err := w.Flush(); err != nil {
 		t.Fatalf("Flush: %v", err)
 	}
@@ -227,6 +230,7 @@ func TestRecordingConnExcludeOpcodes(t *testing.T) {
 		t.Fatalf("SendPacket kept: %v", err)
 	}
 
+	rc.Close()
 	if err := w.Flush(); err != nil {
 		t.Fatalf("Flush: %v", err)
 	}
@@ -262,3 +266,111 @@ func TestRecordingConnExcludeOpcodes(t *testing.T) {
 		t.Errorf("records[2].Opcode = 0x%04X, want 0x0012", records[2].Opcode)
 	}
 }
+
+// blockingWriter holds up WritePacket until release is closed.
+type blockingWriter struct {
+	release chan struct{}
+	written int
+}
+
+func (b *blockingWriter) WritePacket(PacketRecord) error {
+	<-b.release
+	b.written++
+	return nil
+}
+
+func TestRecordingConnDropsWhenQueueFull(t *testing.T) {
+	bw := &blockingWriter{release: make(chan struct{})}
+	rc := NewRecordingConn(&mockConn{}, bw, 0, nil)
+	before := DroppedRecords()
+
+	// The writer goroutine holds one packet; the rest fill the queue.
+	sent := recordQueueSize + 10
+	for i := 0; i < sent; i++ {
+		if err := rc.SendPacket([]byte{0x00, 0x12}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	dropped := rc.Dropped()
+	if dropped < 9 || dropped > 10 {
+		t.Errorf("Dropped() = %d, want 9 or 10", dropped)
+	}
+	if got := DroppedRecords() - before; got != dropped {
+		t.Errorf("DroppedRecords() grew by %d, want %d", got, dropped)
+	}
+
+	close(bw.release)
+	rc.Close()
+	if bw.written != sent-int(dropped) {
+		t.Errorf("wrote %d packets, want %d", bw.written, sent-int(dropped))
+	}
+
+	// Packets after Close are not recorded.
+	_ = rc.SendPacket([]byte{0x00, 0x12})
+	if bw.written != sent-int(dropped) || rc.Dropped() != dropped {
+		t.Errorf("packet after Close recorded: written %d, dropped %d", bw.written, rc.Dropped())
+	}
+}
+
+func TestRecordingConnCopiesPayload(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, FileHeader{Version: FormatVersion}, SessionMetadata{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rc := NewRecordingConn(&mockConn{}, w, 0, nil)
+	data := []byte{0x00, 0x12, 0x01}
+	_ = rc.SendPacket(data)
+	data[2] = 0x02 // Reused by the caller before the packet is written
+	rc.Close()
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	r, err := NewReader(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec, err := r.ReadPacket(); err != nil || rec.Payload[2] != 0x01 {
+		t.Errorf("ReadPacket() = %+v, %v, want the payload as sent", rec, err)
+	}
+}
+
+// flushingWriter counts the flushes of its packets.
+type flushingWriter struct {
+	mu      sync.Mutex
+	written int
+	flushed int
+}
+
+func (f *flushingWriter) WritePacket(PacketRecord) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.written++
+	return nil
+}
+
+func (f *flushingWriter) Flush() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.flushed = f.written
+	return nil
+}
+
+func TestRecordingConnFlushesPeriodically(t *testing.T) {
+	fw := &flushingWriter{}
+	rc := NewRecordingConn(&mockConn{}, fw, 0, nil)
+	defer rc.Close()
+	_ = rc.SendPacket([]byte{0x00, 0x12})
+
+	deadline := time.Now().Add(3 * flushInterval)
+	for time.Now().Before(deadline) {
+		fw.mu.Lock()
+		flushed := fw.flushed
+		fw.mu.Unlock()
+		if flushed == 1 {
+			return
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	t.Error("the packet was not flushed while recording")
+}
@@ -0,0 +1,195 @@
+package pcap
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// PacketWriter is where a RecordingConn records packets: a Writer, or a
+// Recording rotating through files.
+type PacketWriter interface {
+	WritePacket(rec PacketRecord) error
+}
+
+// RecordingOptions limits the files of a Recording.
+type RecordingOptions struct {
+	MaxBytes    int64         // Bytes of packet records written to a file before the next is started, 0 for no limit
+	MaxDuration time.Duration // Time a file is written to before the next is started, 0 for no limit
+	MaxTotal    int64         // Cap on the size of all capture files in the directory, deleting the oldest first; 0 disables
+	Secret      []byte        // Encrypts the packet payloads of the files, see NewEncryptedWriter; nil to not
+}
+
+func (o RecordingOptions) rotates() bool {
+	return o.MaxBytes > 0 || o.MaxDuration > 0
+}
+
+// openCaptures holds the paths of the files Recordings are writing, which
+// PruneToSize leaves alone.
+var openCaptures = struct {
+	sync.Mutex
+	paths map[string]bool
+}{paths: map[string]bool{}}
+
+func setOpen(path string, open bool) {
+	openCaptures.Lock()
+	defer openCaptures.Unlock()
+	if open {
+		openCaptures.paths[path] = true
+	} else {
+		delete(openCaptures.paths, path)
+	}
+}
+
+func isOpen(path string) bool {
+	openCaptures.Lock()
+	defer openCaptures.Unlock()
+	return openCaptures.paths[path]
+}
+
+// Recording writes the capture of a session to files, starting the next
+// file once one is over the size or age of its RecordingOptions. Rotated
+// files are numbered name-0001.mhfr, name-0002.mhfr and so on, each a
+// complete capture with the header and metadata of the session; Part in
+// their metadata gives their order. A Recording is not safe for concurrent
+// use; a RecordingConn serialises its calls.
+type Recording struct {
+	base   string // Path without the .mhfr extension
+	hdr    FileHeader
+	meta   SessionMetadata
+	opts   RecordingOptions
+	f      *os.File
+	w      *Writer
+	opened time.Time
+	size   int64 // Bytes of packet records written to the current file
+	paths  []string
+	closed bool
+}
+
+// CreateRecording creates the first file of a Recording at path, which
+// should end in .mhfr. Without rotation, that is the only file, written
+// to path itself. With a MaxTotal, the oldest capture files in the
+// directory are deleted whenever a file is created, until all of them
+// together fit.
+func CreateRecording(path string, hdr FileHeader, meta SessionMetadata, opts RecordingOptions) (*Recording, error) {
+	r := &Recording{base: strings.TrimSuffix(path, ".mhfr"), hdr: hdr, meta: meta, opts: opts}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// open starts the next file.
+func (r *Recording) open() error {
+	path := r.base + ".mhfr"
+	if r.opts.rotates() {
+		r.meta.Part = len(r.paths) + 1
+		path = fmt.Sprintf("%s-%04d.mhfr", r.base, r.meta.Part)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	var w *Writer
+	if r.opts.Secret != nil {
+		w, err = NewEncryptedWriter(f, r.hdr, r.meta, r.opts.Secret)
+	} else {
+		w, err = NewWriter(f, r.hdr, r.meta)
+	}
+	if err != nil {
+		_ = f.Close()
+		_ = os.Remove(path)
+		return err
+	}
+	setOpen(path, true)
+	r.f, r.w, r.opened, r.size = f, w, time.Now(), 0
+	r.paths = append(r.paths, path)
+	if r.opts.MaxTotal > 0 {
+		if _, err := PruneToSize(filepath.Dir(path), r.opts.MaxTotal); err != nil {
+			return fmt.Errorf("pcap: prune captures: %w", err)
+		}
+	}
+	return nil
+}
+
+// finish closes the current file.
+func (r *Recording) finish() error {
+	err := r.w.Close()
+	if cerr := r.f.Close(); err == nil {
+		err = cerr
+	}
+	setOpen(r.f.Name(), false)
+	return err
+}
+
+// WritePacket records rec, first starting the next file if the current
+// one is full or too old.
+func (r *Recording) WritePacket(rec PacketRecord) error {
+	size := int64(r.hdr.RecordHeaderSize() + len(rec.Payload))
+	if r.hdr.Flags&FlagChecksums != 0 {
+		size += checksumSize
+	}
+	if r.size > 0 && (r.opts.MaxBytes > 0 && r.size+size > r.opts.MaxBytes ||
+		r.opts.MaxDuration > 0 && time.Since(r.opened) >= r.opts.MaxDuration) {
+		if err := r.finish(); err != nil {
+			return err
+		}
+		if err := r.open(); err != nil {
+			return err
+		}
+	}
+	if err := r.w.WritePacket(rec); err != nil {
+		return err
+	}
+	r.size += size
+	return nil
+}
+
+// Flush writes the buffered records of the current file to it.
+func (r *Recording) Flush() error {
+	if r.closed {
+		return nil
+	}
+	return r.w.Flush()
+}
+
+// SetSessionInfo sets the CharID and UserID of the metadata of the
+// current file and the files after it.
+func (r *Recording) SetSessionInfo(charID, userID uint32) error {
+	r.meta.CharID = charID
+	r.meta.UserID = userID
+	return PatchMetadata(r.f, r.meta)
+}
+
+// Paths returns the files written so far, oldest first. Files PruneToSize
+// deleted are still listed; none are once the Recording is discarded.
+func (r *Recording) Paths() []string {
+	return r.paths
+}
+
+// Close finishes the current file. Closing a closed Recording does
+// nothing.
+func (r *Recording) Close() error {
+	if r.closed {
+		return nil
+	}
+	r.closed = true
+	return r.finish()
+}
+
+// Discard closes the Recording and deletes its files.
+func (r *Recording) Discard() error {
+	err := r.Close()
+	for _, path := range r.paths {
+		if rerr := os.Remove(path); rerr != nil && !errors.Is(rerr, fs.ErrNotExist) && err == nil {
+			err = rerr
+		}
+	}
+	r.paths = nil
+	return err
+}
@@ -0,0 +1,141 @@
+package pcap
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRecordingRotatesBySize(t *testing.T) {
+	dir := t.TempDir()
+	hdr := FileHeader{Version: FormatVersion, ServerType: ServerTypeChannel, SessionStartNs: 1000}
+	rec, err := CreateRecording(filepath.Join(dir, "channel.mhfr"), hdr, SessionMetadata{Host: "127.0.0.1"}, RecordingOptions{MaxBytes: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Each record is 15+40 bytes, so every file holds one.
+	for i := range 3 {
+		if err := rec.WritePacket(PacketRecord{TimestampNs: int64(1000 + i), Opcode: uint16(i), Payload: make([]byte, 40)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := rec.SetSessionInfo(7, 8); err != nil {
+		t.Fatal(err)
+	}
+	if err := rec.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	paths := rec.Paths()
+	if len(paths) != 3 || filepath.Base(paths[2]) != "channel-0003.mhfr" {
+		t.Fatalf("Paths() = %v", paths)
+	}
+	for i, path := range paths {
+		f, err := os.Open(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r, err := NewReader(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r.Meta.Part != i+1 || r.Meta.Host != "127.0.0.1" || r.Header.SessionStartNs != 1000 {
+			t.Errorf("%s: meta = %+v, header = %+v", path, r.Meta, r.Header)
+		}
+		if (r.Meta.CharID == 7) != (i == 2) {
+			t.Errorf("%s: CharID = %d", path, r.Meta.CharID)
+		}
+		if got, err := r.ReadPacket(); err != nil || got.Opcode != uint16(i) {
+			t.Errorf("%s: packet = %+v, %v", path, got, err)
+		}
+		if r.Index() == nil || r.Index().Packets != 1 {
+			t.Errorf("%s: index = %+v", path, r.Index())
+		}
+		_ = f.Close()
+	}
+}
+
+func TestRecordingWithoutRotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sign.mhfr")
+	rec, err := CreateRecording(path, FileHeader{Version: FormatVersion}, SessionMetadata{}, RecordingOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rc := NewRecordingConn(&mockConn{readData: [][]byte{{0x00, 0x13}}}, rec, 0, nil)
+	if _, err := rc.ReadPacket(); err != nil {
+		t.Fatal(err)
+	}
+	rc.SetSessionInfo(5, 6)
+	rc.Close()
+	if err := rec.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if paths := rec.Paths(); len(paths) != 1 || paths[0] != path {
+		t.Fatalf("Paths() = %v", paths)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = f.Close() }()
+	r, err := NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Meta.CharID != 5 || r.Meta.UserID != 6 || r.Meta.Part != 0 {
+		t.Errorf("meta = %+v", r.Meta)
+	}
+}
+
+func TestPruneToSize(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	for i, name := range []string{"a.mhfr", "b.mhfr", "c.mhfr", "d.txt"} {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, make([]byte, 100), 0644); err != nil {
+			t.Fatal(err)
+		}
+		mod := now.Add(time.Duration(i-10) * time.Hour)
+		if err := os.Chtimes(path, mod, mod); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// a.mhfr is the oldest, but still being written.
+	setOpen(filepath.Join(dir, "a.mhfr"), true)
+	defer setOpen(filepath.Join(dir, "a.mhfr"), false)
+
+	n, err := PruneToSize(dir, 150)
+	if err != nil || n != 1 {
+		t.Fatalf("PruneToSize() = %d, %v, want 1", n, err)
+	}
+	for name, want := range map[string]bool{"a.mhfr": true, "b.mhfr": false, "c.mhfr": true, "d.txt": true} {
+		if _, err := os.Stat(filepath.Join(dir, name)); (err == nil) != want {
+			t.Errorf("%s kept = %v, want %v", name, err == nil, want)
+		}
+	}
+	if n, err := PruneToSize(filepath.Join(dir, "missing"), 0); err != nil || n != 0 {
+		t.Errorf("PruneToSize() of a missing dir = %d, %v", n, err)
+	}
+}
+
+func TestRecordingDiscard(t *testing.T) {
+	dir := t.TempDir()
+	rec, err := CreateRecording(filepath.Join(dir, "entrance.mhfr"), FileHeader{Version: FormatVersion}, SessionMetadata{}, RecordingOptions{MaxBytes: 20})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rc := NewRecordingConn(&mockConn{}, rec, 0, nil)
+	for range 3 {
+		_ = rc.SendPacket([]byte{0x00, 0x12, 0x01, 0x02, 0x03, 0x04})
+	}
+	if err := rc.Discard(); err != nil {
+		t.Fatal(err)
+	}
+	if err := rec.Close(); err != nil {
+		t.Errorf("Close() after Discard() = %v", err)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 || len(rec.Paths()) != 0 {
+		t.Errorf("left %d files, Paths() = %v", len(entries), rec.Paths())
+	}
+}
@@ -0,0 +1,264 @@
+package pcap
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// recordQueueSize is the number of records a SessionRecorder queues for
+// its writer before it drops them.
+const recordQueueSize = 4096
+
+// flushInterval is how often a SessionRecorder flushes a writer it wrote
+// to, so a capture can be followed while it is written.
+const flushInterval = time.Second
+
+// droppedRecords counts the records every SessionRecorder dropped.
+var droppedRecords atomic.Uint64
+
+// DroppedRecords returns the number of records SessionRecorders dropped
+// because their writer fell behind.
+func DroppedRecords() uint64 {
+	return droppedRecords.Load()
+}
+
+// SessionRecorder records the packets of a session to a Writer or a
+// Recording as it is fed them, for servers whose packets do not go through
+// a network.Conn a RecordingConn can wrap. The ServerType of the capture is
+// that of the header the writer was created with. Records are queued and
+// written by a goroutine of their own, so a slow disk does not hold up the
+// session; when the queue is full, records are dropped from the capture
+// and counted. It is safe for concurrent use.
+type SessionRecorder struct {
+	writer         PacketWriter
+	startNs        int64
+	excludeOpcodes map[uint16]struct{}
+	records        chan PacketRecord
+	stop           chan struct{} // Closed by Close
+	done           chan struct{} // Closed when the writer goroutine exits
+	stopOnce       sync.Once
+	dropped        atomic.Uint64
+	metaFile       *os.File         // capture file handle for metadata patching
+	meta           *SessionMetadata // current metadata (mutated by SetSessionInfo)
+	mu             sync.Mutex       // Guards the writer and metadata
+	timing         bool             // C→S packets are held until Handled, see EnableTiming
+	pending        *PacketRecord    // C→S packet being handled
+	held           []PacketRecord   // Packets recorded while pending was handled
+	holdMu         sync.Mutex       // Guards pending and held
+}
+
+// NewSessionRecorder records to w. startNs is the session start time in
+// nanoseconds, as in the header of w. excludeOpcodes is an optional list
+// of opcodes to skip. Close must be called before w is closed.
+func NewSessionRecorder(w PacketWriter, startNs int64, excludeOpcodes []uint16) *SessionRecorder {
+	var excl map[uint16]struct{}
+	if len(excludeOpcodes) > 0 {
+		excl = make(map[uint16]struct{}, len(excludeOpcodes))
+		for _, op := range excludeOpcodes {
+			excl[op] = struct{}{}
+		}
+	}
+	sr := &SessionRecorder{
+		writer:         w,
+		startNs:        startNs,
+		excludeOpcodes: excl,
+		records:        make(chan PacketRecord, recordQueueSize),
+		stop:           make(chan struct{}),
+		done:           make(chan struct{}),
+	}
+	go sr.writeRecords()
+	return sr
+}
+
+// writeRecords writes queued records until Close, then writes those still
+// queued. Writers with a Flush method are flushed every flushInterval
+// they were written to.
+func (sr *SessionRecorder) writeRecords() {
+	defer close(sr.done)
+	dirty := false
+	write := func(rec PacketRecord) {
+		sr.mu.Lock()
+		_ = sr.writer.WritePacket(rec)
+		sr.mu.Unlock()
+		dirty = true
+	}
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case rec := <-sr.records:
+			write(rec)
+		case <-ticker.C:
+			if f, ok := sr.writer.(interface{ Flush() error }); ok && dirty {
+				sr.mu.Lock()
+				_ = f.Flush()
+				sr.mu.Unlock()
+				dirty = false
+			}
+		case <-sr.stop:
+			for {
+				select {
+				case rec := <-sr.records:
+					write(rec)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// EnableTiming makes the recorder hold each C→S packet until Handled
+// reports how long the server took to handle it, recorded as its
+// ProcessingNs, for a writer with FlagTiming. Packets recorded meanwhile
+// are held behind it, so the capture keeps the order they came in. It must
+// be called before anything is recorded.
+func (sr *SessionRecorder) EnableTiming() {
+	sr.timing = true
+}
+
+// Record records a packet with the given opcode, timestamped now. The
+// payload is recorded as it is, whatever the framing of the server, and
+// copied: the caller may reuse it.
+func (sr *SessionRecorder) Record(dir Direction, opcode uint16, payload []byte) {
+	select {
+	case <-sr.stop:
+		return
+	default:
+	}
+
+	sr.holdMu.Lock()
+	defer sr.holdMu.Unlock()
+	handled := sr.timing && dir == DirClientToServer
+	if handled {
+		// A packet read before the last was handled was not timed.
+		sr.release(0)
+	}
+	if sr.excludeOpcodes != nil {
+		if _, excluded := sr.excludeOpcodes[opcode]; excluded {
+			return
+		}
+	}
+
+	rec := PacketRecord{
+		TimestampNs: time.Now().UnixNano(),
+		Direction:   dir,
+		Opcode:      opcode,
+		Payload:     bytes.Clone(payload),
+	}
+	switch {
+	case handled:
+		sr.pending = &rec
+	case sr.pending != nil:
+		sr.held = append(sr.held, rec)
+	default:
+		sr.queue(rec)
+	}
+}
+
+// Handled records d as the time the server took to handle the last C→S
+// packet, and the packets held behind it. It does nothing without
+// EnableTiming.
+func (sr *SessionRecorder) Handled(d time.Duration) {
+	sr.holdMu.Lock()
+	defer sr.holdMu.Unlock()
+	sr.release(d)
+}
+
+// release queues the pending packet, handled in d, and the packets held
+// behind it.
+func (sr *SessionRecorder) release(d time.Duration) {
+	if sr.pending == nil {
+		return
+	}
+	sr.pending.ProcessingNs = int64(d)
+	sr.queue(*sr.pending)
+	for _, rec := range sr.held {
+		sr.queue(rec)
+	}
+	sr.pending, sr.held = nil, nil
+}
+
+// queue hands rec to the writer goroutine, or drops it when the queue is
+// full.
+func (sr *SessionRecorder) queue(rec PacketRecord) {
+	select {
+	case sr.records <- rec:
+	default:
+		sr.dropped.Add(1)
+		droppedRecords.Add(1)
+	}
+}
+
+// RecordPacket records a packet whose first two bytes are its opcode, as
+// channel server packets are.
+func (sr *SessionRecorder) RecordPacket(dir Direction, data []byte) {
+	var opcode uint16
+	if len(data) >= 2 {
+		opcode = binary.BigEndian.Uint16(data[:2])
+	}
+	sr.Record(dir, opcode, data)
+}
+
+// Close stops recording and waits for the queued packets to be written.
+// Packets after Close are not recorded. It does not close the writer.
+func (sr *SessionRecorder) Close() {
+	sr.holdMu.Lock()
+	sr.release(0)
+	sr.holdMu.Unlock()
+	sr.stopOnce.Do(func() { close(sr.stop) })
+	<-sr.done
+}
+
+// Discard stops recording and, when recording to a Recording, discards
+// it, for a session that turned out not to be worth capturing.
+func (sr *SessionRecorder) Discard() error {
+	sr.Close()
+	sr.mu.Lock()
+	defer sr.mu.Unlock()
+	if r, ok := sr.writer.(*Recording); ok {
+		return r.Discard()
+	}
+	return nil
+}
+
+// Dropped returns the number of packets left out of the capture because
+// the writer fell behind.
+func (sr *SessionRecorder) Dropped() uint64 {
+	return sr.dropped.Load()
+}
+
+// SetCaptureFile sets the file handle and metadata pointer for in-place metadata patching.
+// Must be called before SetSessionInfo. Not required if metadata patching is not needed.
+func (sr *SessionRecorder) SetCaptureFile(f *os.File, meta *SessionMetadata) {
+	sr.mu.Lock()
+	sr.metaFile = f
+	sr.meta = meta
+	sr.mu.Unlock()
+}
+
+// SetSessionInfo updates the CharID and UserID in the capture file metadata.
+// This is called after login when the session identity is known.
+func (sr *SessionRecorder) SetSessionInfo(charID, userID uint32) {
+	sr.mu.Lock()
+	defer sr.mu.Unlock()
+
+	if r, ok := sr.writer.(*Recording); ok {
+		_ = r.SetSessionInfo(charID, userID)
+		return
+	}
+
+	if sr.meta == nil || sr.metaFile == nil {
+		return
+	}
+
+	sr.meta.CharID = charID
+	sr.meta.UserID = userID
+
+	// Best-effort patch — log errors are handled by the caller.
+	_ = PatchMetadata(sr.metaFile, *sr.meta)
+}
@@ -0,0 +1,113 @@
+package pcap
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSessionRecorder(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, FileHeader{Version: FormatVersion, ServerType: ServerTypeSign}, SessionMetadata{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sr := NewSessionRecorder(w, 0, []uint16{0x0002})
+
+	// Sign server requests start with a string, not an opcode.
+	req := []byte("DSGN:\x00user\x00pass\x00")
+	sr.Record(DirClientToServer, 0x0001, req)
+	req[0] = 'X' // Reused by the caller before the packet is written
+	sr.Record(DirServerToClient, 0x0002, []byte{0x01})
+	sr.RecordPacket(DirServerToClient, []byte{0x00, 0x03, 0xAA})
+	sr.Close()
+	sr.Record(DirClientToServer, 0x0004, nil)
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := NewReader(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Header.ServerType != ServerTypeSign {
+		t.Errorf("ServerType = %v, want sign", r.Header.ServerType)
+	}
+	var recs []PacketRecord
+	for {
+		rec, err := r.ReadPacket()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		recs = append(recs, rec)
+	}
+	if len(recs) != 2 {
+		t.Fatalf("got %d records, want 2", len(recs))
+	}
+	if recs[0].Opcode != 0x0001 || recs[0].Direction != DirClientToServer || !bytes.HasPrefix(recs[0].Payload, []byte("DSGN:")) {
+		t.Errorf("records[0] = %+v, want the sign request as fed", recs[0])
+	}
+	if recs[1].Opcode != 0x0003 || !bytes.Equal(recs[1].Payload, []byte{0x00, 0x03, 0xAA}) {
+		t.Errorf("records[1] = %+v, want opcode 0x0003 from the packet", recs[1])
+	}
+}
+
+func TestSessionRecorderTiming(t *testing.T) {
+	for _, secret := range []string{"", "capture key"} {
+		var buf bytes.Buffer
+		hdr := FileHeader{Version: FormatVersion, ServerType: ServerTypeChannel, Flags: FlagTiming | FlagChecksums}
+		var w *Writer
+		var err error
+		if secret != "" {
+			w, err = NewEncryptedWriter(&buf, hdr, SessionMetadata{}, []byte(secret))
+		} else {
+			w, err = NewWriter(&buf, hdr, SessionMetadata{})
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		sr := NewSessionRecorder(w, 0, []uint16{0x0018})
+		sr.EnableTiming()
+
+		sr.RecordPacket(DirClientToServer, []byte{0x00, 0x17, 1})
+		sr.RecordPacket(DirServerToClient, []byte{0x00, 0x12, 1}) // Sent while the request is handled
+		sr.Handled(5 * time.Millisecond)
+		sr.RecordPacket(DirClientToServer, []byte{0x00, 0x17, 2}) // Never reported handled
+		sr.RecordPacket(DirClientToServer, []byte{0x00, 0x18, 3}) // Excluded
+		sr.Handled(time.Second)
+		sr.RecordPacket(DirClientToServer, []byte{0x00, 0x17, 4})
+		sr.Close()
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		r, err := NewReader(bytes.NewReader(buf.Bytes()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := r.SetKey([]byte(secret)); err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for {
+			rec, err := r.ReadPacket()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			got = append(got, fmt.Sprintf("%s %d %v", rec.Direction, rec.Payload[2], time.Duration(rec.ProcessingNs)))
+		}
+		want := "C→S 1 5ms, S→C 1 0s, C→S 2 0s, C→S 4 0s"
+		if s := strings.Join(got, ", "); s != want {
+			t.Errorf("secret %q: records %s, want %s", secret, s, want)
+		}
+	}
+}
@@ -2,19 +2,95 @@ package pcap
 
 import (
 	"bufio"
+	"compress/gzip"
+	"crypto/rand"
+	"crypto/sha256"
 	"encoding/binary"
 	"encoding/json"
 	"fmt"
+	"hash"
+	"hash/crc32"
 	"io"
+
+	"github.com/klauspost/compress/zstd"
 )
 
 // Writer writes .mhfr capture files.
 type Writer struct {
-	bw *bufio.Writer
+	bw        *bufio.Writer // Packet records are written here
+	comp      compressor    // Compresses bw into out; nil when uncompressed
+	out       *bufio.Writer // The file; bw itself when uncompressed
+	counter   *countingWriter
+	index     Index
+	block     map[uint16]bool // Opcodes of the block being written
+	cipher    *payloadCipher  // Seals payloads; nil when not encrypted
+	container bool            // Records carry their session, see ContainerVersion
+	timing    bool            // Records carry their handling time, see FlagTiming
+	hash      hash.Hash       // Hashes the records of a file with checksums; nil without
+	buf       []byte          // Record being written
+}
+
+// compressor is the part of gzip.Writer and zstd.Encoder the Writer uses.
+type compressor interface {
+	io.Writer
+	Flush() error
+	Close() error
+	Reset(w io.Writer)
+}
+
+// countingWriter counts the bytes written through it, giving the offsets
+// of the index.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
 }
 
 // NewWriter creates a Writer, immediately writing the file header and metadata block.
+// The compression flag in header, if any, compresses the packet records.
+// A header with ContainerVersion writes a container, whose records carry
+// their session; the sessions are listed in meta.
+// Offsets in the index Close writes count from where w was when NewWriter
+// was called, normally the start of the file.
 func NewWriter(w io.Writer, header FileHeader, meta SessionMetadata) (*Writer, error) {
+	if header.Flags&FlagEncrypted != 0 {
+		return nil, fmt.Errorf("pcap: encrypted captures are written by NewEncryptedWriter")
+	}
+	return newWriter(w, header, meta, nil)
+}
+
+// NewEncryptedWriter creates a Writer like NewWriter that encrypts the
+// payload of each packet with a key derived from secret and a random salt
+// kept in the header. The header, metadata, and the timestamp, direction
+// and opcode of each packet are not encrypted. Encrypted payloads do not
+// compress.
+func NewEncryptedWriter(w io.Writer, header FileHeader, meta SessionMetadata, secret []byte) (*Writer, error) {
+	header.Flags |= FlagEncrypted
+	if _, err := rand.Read(header.KeySalt[:]); err != nil {
+		return nil, fmt.Errorf("pcap: salt: %w", err)
+	}
+	c, err := newPayloadCipher(secret, header.KeySalt)
+	if err != nil {
+		return nil, err
+	}
+	return newWriter(w, header, meta, c)
+}
+
+func newWriter(w io.Writer, header FileHeader, meta SessionMetadata, c *payloadCipher) (*Writer, error) {
+	if header.Flags&^knownFlags != 0 {
+		return nil, fmt.Errorf("pcap: unknown header flags 0x%X", header.Flags&^knownFlags)
+	}
+	if header.Flags&FlagGzip != 0 && header.Flags&FlagZstd != 0 {
+		return nil, fmt.Errorf("pcap: more than one compression flag set")
+	}
+	if header.Version != FormatVersion && header.Version != ContainerVersion {
+		return nil, fmt.Errorf("pcap: unsupported version %d", header.Version)
+	}
 	metaBytes, err := json.Marshal(&meta)
 	if err != nil {
 		return nil, fmt.Errorf("pcap: marshal metadata: %w", err)
@@ -30,7 +106,8 @@ func NewWriter(w io.Writer, header FileHeader, meta SessionMetadata) (*Writer, e
 	}
 	header.MetadataLen = uint32(len(metaBytes))
 
-	bw := bufio.NewWriter(w)
+	counter := &countingWriter{w: w}
+	bw := bufio.NewWriter(counter)
 
 	// Write 32-byte file header.
 	if _, err := bw.WriteString(Magic); err != nil {
@@ -48,15 +125,13 @@ func NewWriter(w io.Writer, header FileHeader, meta SessionMetadata) (*Writer, e
 	if err := binary.Write(bw, binary.BigEndian, header.SessionStartNs); err != nil {
 		return nil, err
 	}
-	// 4 bytes reserved
-	if _, err := bw.Write(make([]byte, 4)); err != nil {
+	if err := binary.Write(bw, binary.BigEndian, header.Flags); err != nil {
 		return nil, err
 	}
 	if err := binary.Write(bw, binary.BigEndian, header.MetadataLen); err != nil {
 		return nil, err
 	}
-	// 8 bytes reserved
-	if _, err := bw.Write(make([]byte, 8)); err != nil {
+	if _, err := bw.Write(header.KeySalt[:]); err != nil {
 		return nil, err
 	}
 
@@ -69,30 +144,151 @@ func NewWriter(w io.Writer, header FileHeader, meta SessionMetadata) (*Writer, e
 		return nil, err
 	}
 
-	return &Writer{bw: bw}, nil
+	wr := &Writer{bw: bw, out: bw, counter: counter, index: Index{BlockSize: IndexBlockSize}, cipher: c, container: header.Container(), timing: header.Flags&FlagTiming != 0}
+	if header.Flags&FlagChecksums != 0 {
+		wr.hash = sha256.New()
+	}
+	switch {
+	case header.Flags&FlagGzip != 0:
+		wr.comp = gzip.NewWriter(bw)
+	case header.Flags&FlagZstd != 0:
+		if wr.comp, err = zstd.NewWriter(bw); err != nil {
+			return nil, fmt.Errorf("pcap: zstd: %w", err)
+		}
+	}
+	if wr.comp != nil {
+		wr.bw = bufio.NewWriter(wr.comp)
+	}
+	return wr, nil
 }
 
-// WritePacket appends a single packet record.
+// WritePacket appends a single packet record. Only the records of a
+// container may have a session. The handling time of the packet is kept
+// only in a file with FlagTiming.
 func (w *Writer) WritePacket(rec PacketRecord) error {
-	if err := binary.Write(w.bw, binary.BigEndian, rec.TimestampNs); err != nil {
-		return err
+	if rec.Session != 0 && !w.container {
+		return fmt.Errorf("pcap: packet of session %d in a capture that is not a container", rec.Session)
 	}
-	if err := w.bw.WriteByte(byte(rec.Direction)); err != nil {
-		return err
+	if w.index.Packets%IndexBlockSize == 0 {
+		if err := w.startBlock(rec.TimestampNs); err != nil {
+			return err
+		}
 	}
-	if err := binary.Write(w.bw, binary.BigEndian, rec.Opcode); err != nil {
-		return err
+	if !w.timing {
+		rec.ProcessingNs = 0
+	}
+	if w.cipher != nil {
+		rec.Payload = w.cipher.seal(rec)
 	}
-	if err := binary.Write(w.bw, binary.BigEndian, uint32(len(rec.Payload))); err != nil {
+	if err := w.writeRecord(rec); err != nil {
 		return err
 	}
-	if _, err := w.bw.Write(rec.Payload); err != nil {
+	b := &w.index.Blocks[len(w.index.Blocks)-1]
+	b.FirstNs = min(b.FirstNs, rec.TimestampNs)
+	b.LastNs = max(b.LastNs, rec.TimestampNs)
+	if !w.block[rec.Opcode] {
+		w.block[rec.Opcode] = true
+		b.Opcodes = append(b.Opcodes, rec.Opcode)
+	}
+	w.index.Packets++
+	return nil
+}
+
+// writeRecord writes rec, followed by its checksum in a file with
+// checksums.
+func (w *Writer) writeRecord(rec PacketRecord) error {
+	b := w.appendRecord(w.buf[:0], rec)
+	if w.hash != nil {
+		b = binary.BigEndian.AppendUint32(b, crc32.ChecksumIEEE(b))
+		w.hash.Write(b)
+	}
+	w.buf = b
+	_, err := w.bw.Write(b)
+	return err
+}
+
+// appendRecord appends the bytes of rec to b.
+func (w *Writer) appendRecord(b []byte, rec PacketRecord) []byte {
+	b = binary.BigEndian.AppendUint64(b, uint64(rec.TimestampNs))
+	b = append(b, byte(rec.Direction))
+	b = binary.BigEndian.AppendUint16(b, rec.Opcode)
+	if w.container {
+		b = binary.BigEndian.AppendUint16(b, rec.Session)
+	}
+	if w.timing {
+		b = binary.BigEndian.AppendUint64(b, uint64(rec.ProcessingNs))
+	}
+	b = binary.BigEndian.AppendUint32(b, uint32(len(rec.Payload)))
+	return append(b, rec.Payload...)
+}
+
+// startBlock begins the next block of the index at the current offset.
+// A compressed block starts a new compressed frame, so a reader can start
+// decompressing there.
+func (w *Writer) startBlock(ts int64) error {
+	if len(w.index.Blocks) > 0 && w.comp != nil {
+		if err := w.bw.Flush(); err != nil {
+			return err
+		}
+		if err := w.comp.Close(); err != nil {
+			return err
+		}
+		w.comp.Reset(w.out)
+	}
+	if err := w.out.Flush(); err != nil {
 		return err
 	}
+	w.index.Blocks = append(w.index.Blocks, IndexBlock{
+		Offset:  w.counter.n,
+		First:   w.index.Packets,
+		FirstNs: ts,
+		LastNs:  ts,
+	})
+	w.block = make(map[uint16]bool)
 	return nil
 }
 
-// Flush flushes the buffered writer.
+// Flush writes the buffered records to the underlying writer. A compressed
+// file can be read up to the last Flush while it is still being written.
 func (w *Writer) Flush() error {
-	return w.bw.Flush()
+	if err := w.bw.Flush(); err != nil {
+		return err
+	}
+	if w.comp == nil {
+		return nil
+	}
+	if err := w.comp.Flush(); err != nil {
+		return err
+	}
+	return w.out.Flush()
+}
+
+// Close ends the packet records, writes the index and flushes the writer.
+// It does not close the underlying writer. A file that was never closed,
+// such as the capture of a crashed server, is still read in full, but
+// has no index.
+func (w *Writer) Close() error {
+	if err := w.writeRecord(PacketRecord{}); err != nil {
+		return err
+	}
+	if w.hash != nil {
+		if _, err := w.bw.Write(w.hash.Sum(nil)); err != nil {
+			return err
+		}
+	}
+	if err := w.bw.Flush(); err != nil {
+		return err
+	}
+	if w.comp != nil {
+		if err := w.comp.Close(); err != nil {
+			return err
+		}
+	}
+	if err := w.out.Flush(); err != nil {
+		return err
+	}
+	if err := writeIndex(w.out, &w.index, w.counter.n); err != nil {
+		return err
+	}
+	return w.out.Flush()
 }
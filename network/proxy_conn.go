@@ -0,0 +1,261 @@
+package network
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	cfg "erupe-ce/config"
+)
+
+// ErrBadProxyHeader is returned by reads from a ProxyConn whose PROXY
+// protocol header could not be parsed.
+var ErrBadProxyHeader = errors.New("network: bad PROXY protocol header")
+
+// proxyV2Signature starts every PROXY protocol version 2 header.
+var proxyV2Signature = []byte("\r\n\r\n\x00\r\nQUIT\n")
+
+const (
+	proxyV1MaxLen         = 107 // Longest version 1 header, CRLF included
+	defaultHeaderTimeout  = 5 * time.Second
+	proxyV2CommandLocal   = 0x0
+	proxyV2CommandProxy   = 0x1
+	proxyV2FamilyTCP4     = 0x11
+	proxyV2FamilyTCP6     = 0x21
+	proxyV2AddressLenIPv4 = 12
+	proxyV2AddressLenIPv6 = 36
+)
+
+// WithProxyProtocol wraps l in a ProxyListener when the PROXY protocol is
+// enabled in c, and returns it unchanged otherwise. Enabling it without
+// trusted proxies is an error: any client could then claim any address,
+// past bans and connection limits.
+func WithProxyProtocol(l net.Listener, c cfg.ProxyProtocolOptions) (net.Listener, error) {
+	if !c.Enabled {
+		return l, nil
+	}
+	trusted, err := ParseTrustedProxies(c.TrustedProxies)
+	if err != nil {
+		return nil, err
+	}
+	if len(trusted) == 0 {
+		return nil, errors.New("PROXY protocol enabled without trusted proxies")
+	}
+	timeout := time.Duration(c.HeaderTimeout) * time.Second
+	if timeout <= 0 {
+		timeout = defaultHeaderTimeout
+	}
+	return &ProxyListener{Listener: l, trusted: trusted, timeout: timeout}, nil
+}
+
+// ParseTrustedProxies parses addresses and CIDR ranges. A bare address is a
+// range of that one address.
+func ParseTrustedProxies(entries []string) ([]*net.IPNet, error) {
+	var nets []*net.IPNet
+	for _, e := range entries {
+		e = strings.TrimSpace(e)
+		if !strings.Contains(e, "/") {
+			ip := net.ParseIP(e)
+			if ip == nil {
+				return nil, fmt.Errorf("trusted proxy %q is not an address or CIDR range", e)
+			}
+			bits := 128
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 32
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, n, err := net.ParseCIDR(e)
+		if err != nil {
+			return nil, fmt.Errorf("trusted proxy %q: %w", e, err)
+		}
+		nets = append(nets, n)
+	}
+	return nets, nil
+}
+
+// ProxyListener accepts connections that may start with a HAProxy PROXY
+// protocol version 1 or 2 header, so the address of the client behind a load
+// balancer or TCP proxy is seen as the connection's remote address.
+//
+// Headers are only honoured from trusted sources; a connection from anywhere
+// else is passed through untouched, so clients cannot forge their address. A
+// trusted source may still connect without a header.
+type ProxyListener struct {
+	net.Listener
+	trusted []*net.IPNet
+	timeout time.Duration
+}
+
+// Accept returns the next connection. Its header is read on its first Read
+// or RemoteAddr call, so a slow client does not hold up the accept loop;
+// callers should make that call on the connection's own goroutine.
+func (l *ProxyListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	if !l.isTrusted(conn.RemoteAddr()) {
+		return conn, nil
+	}
+	return &ProxyConn{Conn: conn, r: bufio.NewReaderSize(conn, 256), remote: conn.RemoteAddr(), timeout: l.timeout}, nil
+}
+
+func (l *ProxyListener) isTrusted(addr net.Addr) bool {
+	tcp, ok := addr.(*net.TCPAddr)
+	if !ok {
+		return false
+	}
+	for _, n := range l.trusted {
+		if n.Contains(tcp.IP) {
+			return true
+		}
+	}
+	return false
+}
+
+// ProxyConn is a connection from a trusted proxy. Its remote address is the
+// client's address from the PROXY header, or the proxy's when the header is
+// absent or, as with health checks, describes no client.
+type ProxyConn struct {
+	net.Conn
+	r       *bufio.Reader
+	timeout time.Duration
+
+	once   sync.Once
+	remote net.Addr
+	err    error
+}
+
+// Read reads the connection's data after the PROXY header.
+func (c *ProxyConn) Read(b []byte) (int, error) {
+	c.once.Do(c.readHeader)
+	if c.err != nil {
+		return 0, c.err
+	}
+	return c.r.Read(b)
+}
+
+// RemoteAddr returns the address of the client behind the proxy.
+func (c *ProxyConn) RemoteAddr() net.Addr {
+	c.once.Do(c.readHeader)
+	return c.remote
+}
+
+func (c *ProxyConn) readHeader() {
+	_ = c.Conn.SetReadDeadline(time.Now().Add(c.timeout))
+	defer func() { _ = c.Conn.SetReadDeadline(time.Time{}) }()
+
+	first, err := c.r.Peek(1)
+	if err != nil {
+		c.err = err
+		return
+	}
+	var addr net.Addr
+	switch first[0] {
+	case 'P':
+		addr, err = readProxyV1(c.r)
+	case proxyV2Signature[0]:
+		addr, err = readProxyV2(c.r)
+	default:
+		// No header: a client connecting to the proxy's address directly.
+		return
+	}
+	if err != nil {
+		c.err = fmt.Errorf("%w: %v", ErrBadProxyHeader, err)
+		return
+	}
+	if addr != nil {
+		c.remote = addr
+	}
+}
+
+// readProxyV1 reads a text header such as
+// "PROXY TCP4 203.0.113.7 10.0.0.2 51234 53310\r\n".
+func readProxyV1(r *bufio.Reader) (net.Addr, error) {
+	var line []byte
+	for len(line) < proxyV1MaxLen {
+		b, err := r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		line = append(line, b)
+		if b == '\n' {
+			break
+		}
+	}
+	s, ok := strings.CutSuffix(string(line), "\r\n")
+	if !ok {
+		return nil, errors.New("version 1 header is not terminated")
+	}
+	fields := strings.Split(s, " ")
+	if fields[0] != "PROXY" || len(fields) < 2 {
+		return nil, errors.New("version 1 header does not start with PROXY")
+	}
+	switch fields[1] {
+	case "UNKNOWN":
+		return nil, nil
+	case "TCP4", "TCP6":
+	default:
+		return nil, fmt.Errorf("unknown protocol %q", fields[1])
+	}
+	if len(fields) != 6 {
+		return nil, errors.New("version 1 header has the wrong number of fields")
+	}
+	ip := net.ParseIP(fields[2])
+	port, err := strconv.ParseUint(fields[4], 10, 16)
+	if ip == nil || err != nil || (fields[1] == "TCP4") != (ip.To4() != nil) {
+		return nil, fmt.Errorf("bad source address %s port %s", fields[2], fields[4])
+	}
+	return &net.TCPAddr{IP: ip, Port: int(port)}, nil
+}
+
+// readProxyV2 reads a binary header.
+func readProxyV2(r *bufio.Reader) (net.Addr, error) {
+	head := make([]byte, 16)
+	if _, err := io.ReadFull(r, head); err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(head[:12], proxyV2Signature) {
+		return nil, errors.New("bad version 2 signature")
+	}
+	if head[12]>>4 != 2 {
+		return nil, fmt.Errorf("unsupported version %d", head[12]>>4)
+	}
+	payload := make([]byte, binary.BigEndian.Uint16(head[14:16]))
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, err
+	}
+	switch head[12] & 0xF {
+	case proxyV2CommandLocal:
+		return nil, nil
+	case proxyV2CommandProxy:
+	default:
+		return nil, fmt.Errorf("unknown command %d", head[12]&0xF)
+	}
+	// Any TLVs after the addresses are skipped.
+	switch head[13] {
+	case proxyV2FamilyTCP4:
+		if len(payload) < proxyV2AddressLenIPv4 {
+			return nil, errors.New("short IPv4 addresses")
+		}
+		return &net.TCPAddr{IP: net.IP(payload[0:4]), Port: int(binary.BigEndian.Uint16(payload[8:10]))}, nil
+	case proxyV2FamilyTCP6:
+		if len(payload) < proxyV2AddressLenIPv6 {
+			return nil, errors.New("short IPv6 addresses")
+		}
+		return &net.TCPAddr{IP: net.IP(payload[0:16]), Port: int(binary.BigEndian.Uint16(payload[32:34]))}, nil
+	default:
+		// UDP or Unix sockets describe no TCP client; keep the proxy's address.
+		return nil, nil
+	}
+}
@@ -0,0 +1,190 @@
+package network
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"testing"
+
+	cfg "erupe-ce/config"
+)
+
+// proxyV2Header builds a version 2 PROXY header for a TCP4 client.
+func proxyV2Header(command byte, src net.IP, port uint16, tlv []byte) []byte {
+	var b bytes.Buffer
+	b.Write(proxyV2Signature)
+	b.WriteByte(0x20 | command)
+	b.WriteByte(proxyV2FamilyTCP4)
+	payload := append(append([]byte{}, src.To4()...), 10, 0, 0, 2)
+	payload = binary.BigEndian.AppendUint16(payload, port)
+	payload = binary.BigEndian.AppendUint16(payload, 53310)
+	payload = append(payload, tlv...)
+	_ = binary.Write(&b, binary.BigEndian, uint16(len(payload)))
+	b.Write(payload)
+	return b.Bytes()
+}
+
+func TestReadProxyHeaders(t *testing.T) {
+	tests := []struct {
+		name   string
+		header []byte
+		want   string // Empty when the header describes no client
+	}{
+		{"v1 TCP4", []byte("PROXY TCP4 203.0.113.7 10.0.0.2 51234 53310\r\n"), "203.0.113.7:51234"},
+		{"v1 TCP6", []byte("PROXY TCP6 2001:db8::7 2001:db8::2 51234 53310\r\n"), "[2001:db8::7]:51234"},
+		{"v1 UNKNOWN", []byte("PROXY UNKNOWN\r\n"), ""},
+		{"v2 PROXY", proxyV2Header(proxyV2CommandProxy, net.IPv4(198, 51, 100, 9), 40000, nil), "198.51.100.9:40000"},
+		{"v2 PROXY with TLVs", proxyV2Header(proxyV2CommandProxy, net.IPv4(198, 51, 100, 9), 40000, []byte{0x04, 0x00, 0x01, 0xFF}), "198.51.100.9:40000"},
+		{"v2 LOCAL", proxyV2Header(proxyV2CommandLocal, net.IPv4(198, 51, 100, 9), 40000, nil), ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := bufio.NewReader(io.MultiReader(bytes.NewReader(tt.header), strings.NewReader("\x00\x00")))
+			var addr net.Addr
+			var err error
+			if tt.header[0] == 'P' {
+				addr, err = readProxyV1(r)
+			} else {
+				addr, err = readProxyV2(r)
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := ""
+			if addr != nil {
+				got = addr.String()
+			}
+			if got != tt.want {
+				t.Errorf("address = %q, want %q", got, tt.want)
+			}
+			if rest, _ := io.ReadAll(r); string(rest) != "\x00\x00" {
+				t.Errorf("data after the header = %q", rest)
+			}
+		})
+	}
+}
+
+func TestReadProxyV1_Bad(t *testing.T) {
+	for _, header := range []string{
+		"PROXY TCP4 203.0.113.7 10.0.0.2 51234\r\n",
+		"PROXY TCP4 2001:db8::7 10.0.0.2 51234 53310\r\n",
+		"PROXY TCP4 203.0.113.7 10.0.0.2 99999 53310\r\n",
+		"PROXY SCTP 203.0.113.7 10.0.0.2 51234 53310\r\n",
+		"PROXY " + strings.Repeat("x", 120) + "\r\n",
+	} {
+		if _, err := readProxyV1(bufio.NewReader(strings.NewReader(header))); err == nil {
+			t.Errorf("header %q was accepted", header)
+		}
+	}
+}
+
+func TestParseTrustedProxies(t *testing.T) {
+	nets, err := ParseTrustedProxies([]string{"10.0.0.0/8", "192.0.2.1", "2001:db8::/32"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for ip, want := range map[string]bool{
+		"10.1.2.3":    true,
+		"192.0.2.1":   true,
+		"192.0.2.2":   false,
+		"2001:db8::5": true,
+	} {
+		l := &ProxyListener{trusted: nets}
+		if got := l.isTrusted(&net.TCPAddr{IP: net.ParseIP(ip)}); got != want {
+			t.Errorf("isTrusted(%s) = %v, want %v", ip, got, want)
+		}
+	}
+	if _, err := ParseTrustedProxies([]string{"proxy.example.com"}); err == nil {
+		t.Error("host name was accepted as a trusted proxy")
+	}
+}
+
+// proxyListen starts a PROXY protocol listener and returns it with a
+// function that dials it, writes data and returns the accepted connection.
+func proxyListen(t *testing.T, c cfg.ProxyProtocolOptions) func(data []byte) net.Conn {
+	t.Helper()
+	inner, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("cannot listen: %v", err)
+	}
+	l, err := WithProxyProtocol(inner, c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = l.Close() })
+	return func(data []byte) net.Conn {
+		client, err := net.Dial("tcp", l.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { _ = client.Close() })
+		if _, err := client.Write(data); err != nil {
+			t.Fatal(err)
+		}
+		conn, err := l.Accept()
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { _ = conn.Close() })
+		return conn
+	}
+}
+
+func TestProxyListener(t *testing.T) {
+	dial := proxyListen(t, cfg.ProxyProtocolOptions{Enabled: true, TrustedProxies: []string{"127.0.0.1"}})
+
+	conn := dial([]byte("PROXY TCP4 203.0.113.7 10.0.0.2 51234 53310\r\n\x00\x00\x00\x00\x00\x00\x00\x00"))
+	if got := conn.RemoteAddr().String(); got != "203.0.113.7:51234" {
+		t.Errorf("RemoteAddr() = %s, want the client's address", got)
+	}
+	buf := make([]byte, 8)
+	if _, err := io.ReadFull(conn, buf); err != nil || !bytes.Equal(buf, make([]byte, 8)) {
+		t.Errorf("read %v after the header, err %v", buf, err)
+	}
+
+	// A trusted proxy may still connect without a header.
+	conn = dial(make([]byte, 8))
+	if _, err := io.ReadFull(conn, buf); err != nil || !strings.HasPrefix(conn.RemoteAddr().String(), "127.0.0.1:") {
+		t.Errorf("headerless connection from %s, err %v", conn.RemoteAddr(), err)
+	}
+
+	conn = dial([]byte("PROXY TCP4 bogus\r\n"))
+	if _, err := conn.Read(buf); !errors.Is(err, ErrBadProxyHeader) {
+		t.Errorf("Read after a bad header: err = %v, want ErrBadProxyHeader", err)
+	}
+}
+
+func TestProxyListener_Untrusted(t *testing.T) {
+	dial := proxyListen(t, cfg.ProxyProtocolOptions{Enabled: true, TrustedProxies: []string{"10.0.0.0/8"}})
+
+	header := "PROXY TCP4 203.0.113.7 10.0.0.2 51234 53310\r\n"
+	conn := dial([]byte(header))
+	if _, ok := conn.(*ProxyConn); ok || !strings.HasPrefix(conn.RemoteAddr().String(), "127.0.0.1:") {
+		t.Errorf("header from an untrusted source was honoured: %s", conn.RemoteAddr())
+	}
+	buf := make([]byte, len(header))
+	if _, err := io.ReadFull(conn, buf); err != nil || string(buf) != header {
+		t.Errorf("untrusted connection read %q, err %v", buf, err)
+	}
+}
+
+func TestWithProxyProtocol_Disabled(t *testing.T) {
+	inner, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("cannot listen: %v", err)
+	}
+	defer func() { _ = inner.Close() }()
+	if l, err := WithProxyProtocol(inner, cfg.ProxyProtocolOptions{}); err != nil || l != inner {
+		t.Errorf("disabled PROXY protocol wrapped the listener: %T, err %v", l, err)
+	}
+	if _, err := WithProxyProtocol(inner, cfg.ProxyProtocolOptions{Enabled: true, TrustedProxies: []string{"nope"}}); err == nil {
+		t.Error("bad trusted proxy was accepted")
+	}
+	if _, err := WithProxyProtocol(inner, cfg.ProxyProtocolOptions{Enabled: true}); err == nil {
+		t.Error("PROXY protocol was enabled without trusted proxies")
+	}
+}
@@ -0,0 +1,47 @@
+package network
+
+import (
+	"errors"
+	"io"
+	"net"
+	"sync"
+)
+
+// Relay forwards traffic between client and upstream in both directions
+// until both sides have finished, then closes both connections.
+//
+// Frames are passed through as-is: nothing is decrypted, decoded or
+// re-encoded, so the relay needs no key rotation state. Between two
+// *net.TCPConn, io.Copy uses splice(2) on Linux and the payload never
+// enters user space. Writes block while the receiver is slow, so
+// backpressure reaches the sender through TCP flow control instead of
+// queueing in Erupe.
+func Relay(client, upstream net.Conn) error {
+	var (
+		wg       sync.WaitGroup
+		errOnce  sync.Once
+		firstErr error
+	)
+	pipe := func(dst, src net.Conn) {
+		defer wg.Done()
+		_, err := io.Copy(dst, src)
+		if err != nil && !errors.Is(err, net.ErrClosed) && !errors.Is(err, io.ErrClosedPipe) {
+			errOnce.Do(func() { firstErr = err })
+		}
+		// Pass the EOF on so the other side can finish its last frames,
+		// or tear down both sides if half-close is unsupported.
+		if cw, ok := dst.(interface{ CloseWrite() error }); ok && err == nil {
+			_ = cw.CloseWrite()
+		} else {
+			_ = dst.Close()
+			_ = src.Close()
+		}
+	}
+	wg.Add(2)
+	go pipe(upstream, client)
+	go pipe(client, upstream)
+	wg.Wait()
+	_ = client.Close()
+	_ = upstream.Close()
+	return firstErr
+}
@@ -0,0 +1,93 @@
+package network
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"testing"
+)
+
+// tcpPair returns both ends of a loopback TCP connection.
+func tcpPair(t *testing.T) (net.Conn, net.Conn) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen() error: %v", err)
+	}
+	defer func() { _ = ln.Close() }()
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		c, _ := ln.Accept()
+		accepted <- c
+	}()
+	dialed, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatalf("Dial() error: %v", err)
+	}
+	return dialed, <-accepted
+}
+
+func TestRelayForwardsBothDirections(t *testing.T) {
+	client, relayClient := tcpPair(t)
+	relayUpstream, upstream := tcpPair(t)
+
+	done := make(chan error, 1)
+	go func() { done <- Relay(relayClient, relayUpstream) }()
+
+	request := bytes.Repeat([]byte{0xAB}, 256*1024)
+	response := []byte{0x01, 0x02, 0x03}
+
+	go func() {
+		_, _ = client.Write(request)
+		_ = client.(*net.TCPConn).CloseWrite()
+	}()
+	got, err := io.ReadAll(upstream)
+	if err != nil {
+		t.Fatalf("upstream read error: %v", err)
+	}
+	if !bytes.Equal(got, request) {
+		t.Fatalf("upstream received %d bytes, want %d unmodified", len(got), len(request))
+	}
+
+	// The upstream can still answer after the client finished sending.
+	_, _ = upstream.Write(response)
+	_ = upstream.Close()
+	got, err = io.ReadAll(client)
+	if err != nil {
+		t.Fatalf("client read error: %v", err)
+	}
+	if !bytes.Equal(got, response) {
+		t.Errorf("client received %x, want %x", got, response)
+	}
+
+	if err := <-done; err != nil {
+		t.Errorf("Relay() error: %v", err)
+	}
+	_ = client.Close()
+}
+
+func TestRelayWithoutHalfClose(t *testing.T) {
+	client, relayClient := net.Pipe()
+	relayUpstream, upstream := net.Pipe()
+
+	done := make(chan error, 1)
+	go func() { done <- Relay(relayClient, relayUpstream) }()
+
+	go func() { _, _ = client.Write([]byte{0x10, 0x20}) }()
+	buf := make([]byte, 2)
+	if _, err := io.ReadFull(upstream, buf); err != nil {
+		t.Fatalf("upstream read error: %v", err)
+	}
+	if !bytes.Equal(buf, []byte{0x10, 0x20}) {
+		t.Errorf("upstream received %x, want 1020", buf)
+	}
+
+	// Closing one side tears down the whole relay.
+	_ = client.Close()
+	if err := <-done; err != nil {
+		t.Errorf("Relay() error: %v", err)
+	}
+	if _, err := upstream.Read(buf); err == nil {
+		t.Error("expected upstream to be closed")
+	}
+}
@@ -0,0 +1,132 @@
+package analytics
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"erupe-ce/server/scheduler"
+)
+
+// Metrics kept in the daily rollups. Each is counted per UTC day and key.
+const (
+	ZennySink    = "zenny_sink"    // Zenny spent, by where
+	GCPSource    = "gcp_source"    // GCP gained, by where
+	GCPSink      = "gcp_sink"      // GCP spent, by where
+	FPointSource = "fpoint_source" // Frontier points gained, by where
+	FPointSink   = "fpoint_sink"   // Frontier points spent, by where
+	Quests       = "quests"        // Quests returned from, by quest file
+	Items        = "items"         // Items handed out, by ItemKey
+	PlayersPeak  = "players_peak"  // Most players online at once, sampled by the rollup job
+)
+
+// Keys of the currency metrics.
+const (
+	KeyShop         = "shop"
+	KeyExchange     = "exchange"
+	KeyMercenary    = "mercenary"
+	KeyDistribution = "distribution"
+	KeyAll          = "all"
+)
+
+// ItemKey returns the key of an item in the Items metric.
+func ItemKey(itemType uint8, itemID uint32) string {
+	return fmt.Sprintf("%d:%d", itemType, itemID)
+}
+
+// Row is one day's value of a metric and key.
+type Row struct {
+	Day    string `json:"day" db:"day"` // YYYY-MM-DD, UTC
+	Metric string `json:"metric" db:"metric"`
+	Key    string `json:"key" db:"key"`
+	Value  int64  `json:"value" db:"value"`
+}
+
+// Store keeps the daily rollups.
+type Store interface {
+	// Add adds each row's value to the stored value of its day, metric and
+	// key.
+	Add(rows []Row) error
+	// SamplePlayers raises today's PlayersPeak to the players online now,
+	// if higher.
+	SamplePlayers(now time.Time) error
+}
+
+type counter struct {
+	day, metric, key string
+}
+
+// Recorder counts metrics in memory until they are flushed to a Store. It
+// is safe for concurrent use by every session of every channel server, and
+// a nil *Recorder discards everything.
+type Recorder struct {
+	mu     sync.Mutex
+	counts map[counter]int64
+	now    func() time.Time
+}
+
+// NewRecorder creates an empty Recorder.
+func NewRecorder() *Recorder {
+	return &Recorder{counts: make(map[counter]int64), now: time.Now}
+}
+
+// Add counts n towards today's value of a metric and key.
+func (r *Recorder) Add(metric, key string, n int64) {
+	if r == nil || n == 0 {
+		return
+	}
+	c := counter{day: Day(r.now()), metric: metric, key: key}
+	r.mu.Lock()
+	r.counts[c] += n
+	r.mu.Unlock()
+}
+
+// Flush writes everything counted since the last flush to store. Counts
+// are kept for the next flush if the write fails.
+func (r *Recorder) Flush(store Store) error {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	counts := r.counts
+	r.counts = make(map[counter]int64)
+	r.mu.Unlock()
+	if len(counts) == 0 {
+		return nil
+	}
+
+	rows := make([]Row, 0, len(counts))
+	for c, n := range counts {
+		rows = append(rows, Row{Day: c.day, Metric: c.metric, Key: c.key, Value: n})
+	}
+	if err := store.Add(rows); err != nil {
+		r.mu.Lock()
+		for c, n := range counts {
+			r.counts[c] += n
+		}
+		r.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+// RollupJob returns the scheduler job that samples the players online and
+// flushes the recorder to store, by default every minute.
+func (r *Recorder) RollupJob(store Store) scheduler.Job {
+	return scheduler.Job{
+		Name:     "analytics-rollup",
+		Schedule: "@every 1m",
+		Run: func(context.Context) error {
+			if err := store.SamplePlayers(time.Now()); err != nil {
+				return err
+			}
+			return r.Flush(store)
+		},
+	}
+}
+
+// Day returns the UTC day of t as the rollups key it.
+func Day(t time.Time) string {
+	return t.UTC().Format(time.DateOnly)
+}
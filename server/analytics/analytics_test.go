@@ -0,0 +1,124 @@
+package analytics
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+type memStore struct {
+	rows    []Row
+	err     error
+	sampled int
+}
+
+func (m *memStore) Add(rows []Row) error {
+	if m.err != nil {
+		return m.err
+	}
+	m.rows = append(m.rows, rows...)
+	return nil
+}
+
+func (m *memStore) SamplePlayers(time.Time) error {
+	m.sampled++
+	return nil
+}
+
+func sortRows(rows []Row) {
+	slices.SortFunc(rows, func(a, b Row) int {
+		return strings.Compare(a.Day+a.Metric+a.Key, b.Day+b.Metric+b.Key)
+	})
+}
+
+func TestRecorderFlush(t *testing.T) {
+	r := NewRecorder()
+	now := time.Date(2026, 10, 17, 23, 59, 0, 0, time.UTC)
+	r.now = func() time.Time { return now }
+	r.Add(ZennySink, KeyShop, 300)
+	r.Add(ZennySink, KeyShop, 200)
+	r.Add(Items, ItemKey(7, 1234), 0)
+	now = now.Add(2 * time.Minute)
+	r.Add(ZennySink, KeyShop, 50)
+
+	store := &memStore{err: errors.New("down")}
+	if err := r.Flush(store); err == nil {
+		t.Fatal("Flush() succeeded with a failing store")
+	}
+	// The counts survive the failed flush and are written by the next.
+	r.Add(GCPSource, KeyMercenary, 10)
+	store.err = nil
+	if err := r.Flush(store); err != nil {
+		t.Fatalf("Flush() error: %v", err)
+	}
+	sortRows(store.rows)
+	want := []Row{
+		{Day: "2026-10-17", Metric: ZennySink, Key: KeyShop, Value: 500},
+		{Day: "2026-10-18", Metric: GCPSource, Key: KeyMercenary, Value: 10},
+		{Day: "2026-10-18", Metric: ZennySink, Key: KeyShop, Value: 50},
+	}
+	if !slices.Equal(store.rows, want) {
+		t.Errorf("rows = %+v, want %+v", store.rows, want)
+	}
+
+	store.rows = nil
+	if err := r.Flush(store); err != nil || store.rows != nil {
+		t.Errorf("second Flush() = %v, rows %+v", err, store.rows)
+	}
+}
+
+func TestRecorder_Nil(t *testing.T) {
+	var r *Recorder
+	r.Add(Quests, "23045d0", 1)
+	if err := r.Flush(&memStore{}); err != nil {
+		t.Errorf("Flush() on nil = %v", err)
+	}
+}
+
+func TestRollupJob(t *testing.T) {
+	r := NewRecorder()
+	r.Add(Quests, "23045d0", 1)
+	store := &memStore{}
+	if err := r.RollupJob(store).Run(context.Background()); err != nil {
+		t.Fatalf("Run() error: %v", err)
+	}
+	if store.sampled != 1 || len(store.rows) != 1 {
+		t.Errorf("sampled %d, rows %+v", store.sampled, store.rows)
+	}
+}
+
+func TestWriteCSV(t *testing.T) {
+	var b strings.Builder
+	err := WriteCSV(&b, []Row{
+		{Day: "2026-10-17", Metric: Items, Key: "7:1234", Value: 3},
+		{Day: "2026-10-17", Metric: Quests, Key: "a,b", Value: 1},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "day,metric,key,value\n2026-10-17,items,7:1234,3\n2026-10-17,quests,\"a,b\",1\n"
+	if b.String() != want {
+		t.Errorf("WriteCSV() = %q, want %q", b.String(), want)
+	}
+}
+
+func TestTotals(t *testing.T) {
+	got := Totals([]Row{
+		{Day: "2026-10-16", Metric: Items, Key: "7:1", Value: 2},
+		{Day: "2026-10-16", Metric: Items, Key: "7:2", Value: 5},
+		{Day: "2026-10-16", Metric: PlayersPeak, Key: KeyAll, Value: 40},
+		{Day: "2026-10-17", Metric: Items, Key: "7:1", Value: 9},
+		{Day: "2026-10-17", Metric: PlayersPeak, Key: KeyAll, Value: 25},
+	})
+	want := []Total{
+		{Metric: Items, Key: "7:1", Value: 11, Days: 2},
+		{Metric: Items, Key: "7:2", Value: 5, Days: 1},
+		{Metric: PlayersPeak, Key: KeyAll, Value: 40, Days: 2},
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("Totals() = %+v, want %+v", got, want)
+	}
+}
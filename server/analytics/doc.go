@@ -0,0 +1,13 @@
+// Package analytics keeps daily rollups of the game's economy and activity:
+// zenny, GCP and frontier points entering and leaving the game through the
+// server, quests played, items handed out, and the most players online at
+// once. Channel servers count into a shared Recorder, which a scheduler job
+// flushes into the analytics_daily table, and the admin API exports the
+// table as JSON, CSV or Parquet so operators can spot inflation and item
+// duplication without writing SQL.
+//
+// Only what passes through the server is counted. Zenny earned on quests
+// and spent at most NPCs never leaves the client's save, so the zenny
+// metrics cover shops and other server-side sinks rather than the whole
+// economy.
+package analytics
@@ -0,0 +1,63 @@
+package analytics
+
+import (
+	"cmp"
+	"encoding/csv"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Export formats.
+const (
+	FormatJSON    = "json"
+	FormatCSV     = "csv"
+	FormatParquet = "parquet"
+)
+
+// WriteCSV writes rows as CSV with a header line.
+func WriteCSV(w io.Writer, rows []Row) error {
+	cw := csv.NewWriter(w)
+	_ = cw.Write([]string{"day", "metric", "key", "value"})
+	for _, row := range rows {
+		_ = cw.Write([]string{row.Day, row.Metric, row.Key, strconv.FormatInt(row.Value, 10)})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// Total is a metric and key summed over a range of days.
+type Total struct {
+	Metric string `json:"metric"`
+	Key    string `json:"key"`
+	Value  int64  `json:"value"`
+	Days   int    `json:"days"` // Days with a value
+}
+
+// Totals sums rows by metric and key, ordered by metric and then by value,
+// largest first. PlayersPeak keeps its highest day instead of a sum.
+func Totals(rows []Row) []Total {
+	index := make(map[[2]string]int)
+	totals := []Total{}
+	for _, row := range rows {
+		k := [2]string{row.Metric, row.Key}
+		i, ok := index[k]
+		if !ok {
+			i = len(totals)
+			index[k] = i
+			totals = append(totals, Total{Metric: row.Metric, Key: row.Key})
+		}
+		t := &totals[i]
+		if row.Metric == PlayersPeak {
+			t.Value = max(t.Value, row.Value)
+		} else {
+			t.Value += row.Value
+		}
+		t.Days++
+	}
+	slices.SortFunc(totals, func(a, b Total) int {
+		return cmp.Or(strings.Compare(a.Metric, b.Metric), cmp.Compare(b.Value, a.Value), strings.Compare(a.Key, b.Key))
+	})
+	return totals
+}
@@ -0,0 +1,236 @@
+package analytics
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"time"
+)
+
+// WriteParquet writes rows as a Parquet file with the required columns day
+// (DATE), metric and key (UTF8) and value (INT64). The file holds one row
+// group of uncompressed PLAIN pages, which every Parquet reader accepts;
+// the rollups are small enough that nothing more is worth a dependency.
+// See https://github.com/apache/parquet-format for the layout.
+func WriteParquet(w io.Writer, rows []Row) error {
+	cols := []parquetColumn{
+		{name: "day", typ: parquetInt32, converted: convertedDate},
+		{name: "metric", typ: parquetByteArray, converted: convertedUTF8},
+		{name: "key", typ: parquetByteArray, converted: convertedUTF8},
+		{name: "value", typ: parquetInt64, converted: -1},
+	}
+	for _, row := range rows {
+		day, err := time.Parse(time.DateOnly, row.Day)
+		if err != nil {
+			return fmt.Errorf("row day %q: %w", row.Day, err)
+		}
+		cols[0].values = binary.LittleEndian.AppendUint32(cols[0].values, uint32(day.Unix()/86400))
+		cols[1].values = appendByteArray(cols[1].values, row.Metric)
+		cols[2].values = appendByteArray(cols[2].values, row.Key)
+		cols[3].values = binary.LittleEndian.AppendUint64(cols[3].values, uint64(row.Value))
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(parquetMagic)
+	for i := range cols {
+		c := &cols[i]
+		c.offset = int64(buf.Len())
+		buf.Write(pageHeader(len(c.values), len(rows)))
+		buf.Write(c.values)
+		c.size = int64(buf.Len()) - c.offset
+	}
+	footer := fileMetaData(cols, len(rows))
+	buf.Write(footer)
+	buf.Write(binary.LittleEndian.AppendUint32(nil, uint32(len(footer))))
+	buf.WriteString(parquetMagic)
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+const parquetMagic = "PAR1"
+
+// Parquet enum values, from parquet.thrift.
+const (
+	parquetInt32     = 1
+	parquetInt64     = 2
+	parquetByteArray = 6
+
+	convertedUTF8 = 0
+	convertedDate = 6
+
+	repetitionRequired = 0
+	encodingPlain      = 0
+	encodingRLE        = 3
+	codecUncompressed  = 0
+	pageTypeData       = 0
+)
+
+type parquetColumn struct {
+	name      string
+	typ       int32
+	converted int32  // -1 for none
+	values    []byte // PLAIN encoded
+	offset    int64  // Of the column chunk in the file
+	size      int64  // Of the column chunk, page header included
+}
+
+func appendByteArray(b []byte, s string) []byte {
+	b = binary.LittleEndian.AppendUint32(b, uint32(len(s)))
+	return append(b, s...)
+}
+
+// pageHeader encodes the PageHeader of a data page. Required columns have
+// no repetition or definition levels, so the page is only the values.
+func pageHeader(size, values int) []byte {
+	t := newThriftWriter()
+	t.i32(1, pageTypeData)
+	t.i32(2, int32(size))
+	t.i32(3, int32(size))
+	t.beginStruct(5)
+	t.i32(1, int32(values))
+	t.i32(2, encodingPlain)
+	t.i32(3, encodingRLE)
+	t.i32(4, encodingRLE)
+	t.endStruct()
+	return t.end()
+}
+
+// fileMetaData encodes the FileMetaData footer.
+func fileMetaData(cols []parquetColumn, rows int) []byte {
+	t := newThriftWriter()
+	t.i32(1, 1)
+	t.beginList(2, thriftStruct, len(cols)+1)
+	t.beginElem()
+	t.binary(4, "schema")
+	t.i32(5, int32(len(cols)))
+	t.endStruct()
+	for _, c := range cols {
+		t.beginElem()
+		t.i32(1, c.typ)
+		t.i32(3, repetitionRequired)
+		t.binary(4, c.name)
+		if c.converted >= 0 {
+			t.i32(6, c.converted)
+		}
+		t.endStruct()
+	}
+	t.i64(3, int64(rows))
+
+	t.beginList(4, thriftStruct, 1)
+	t.beginElem()
+	t.beginList(1, thriftStruct, len(cols))
+	var total int64
+	for _, c := range cols {
+		t.beginElem()
+		t.i64(2, c.offset)
+		t.beginStruct(3)
+		t.i32(1, c.typ)
+		t.beginList(2, thriftI32, 1)
+		t.varint(encodingPlain)
+		t.beginList(3, thriftBinary, 1)
+		t.bytes(c.name)
+		t.i32(4, codecUncompressed)
+		t.i64(5, int64(rows))
+		t.i64(6, c.size)
+		t.i64(7, c.size)
+		t.i64(9, c.offset)
+		t.endStruct()
+		t.endStruct()
+		total += c.size
+	}
+	t.i64(2, total)
+	t.i64(3, int64(rows))
+	t.endStruct()
+
+	t.binary(6, "erupe-ce")
+	return t.end()
+}
+
+// Thrift compact protocol type IDs.
+const (
+	thriftI32    = 5
+	thriftI64    = 6
+	thriftBinary = 8
+	thriftList   = 9
+	thriftStruct = 12
+)
+
+// thriftWriter encodes a struct in the Thrift compact protocol, which
+// Parquet uses for its metadata.
+type thriftWriter struct {
+	buf  []byte
+	last []int16 // Last field ID written in each open struct
+}
+
+func newThriftWriter() *thriftWriter {
+	return &thriftWriter{last: []int16{0}}
+}
+
+func (t *thriftWriter) field(id int16, typ byte) {
+	last := &t.last[len(t.last)-1]
+	if delta := id - *last; delta > 0 && delta <= 15 {
+		t.buf = append(t.buf, byte(delta)<<4|typ)
+	} else {
+		t.buf = append(t.buf, typ)
+		t.varint(int64(id))
+	}
+	*last = id
+}
+
+// varint appends a zigzag varint, as compact protocol integers are.
+func (t *thriftWriter) varint(v int64) {
+	t.buf = binary.AppendUvarint(t.buf, uint64(v<<1^v>>63))
+}
+
+func (t *thriftWriter) bytes(s string) {
+	t.buf = binary.AppendUvarint(t.buf, uint64(len(s)))
+	t.buf = append(t.buf, s...)
+}
+
+func (t *thriftWriter) i32(id int16, v int32) {
+	t.field(id, thriftI32)
+	t.varint(int64(v))
+}
+
+func (t *thriftWriter) i64(id int16, v int64) {
+	t.field(id, thriftI64)
+	t.varint(v)
+}
+
+func (t *thriftWriter) binary(id int16, s string) {
+	t.field(id, thriftBinary)
+	t.bytes(s)
+}
+
+// beginList starts a list field of n elements, written with varint, bytes
+// or beginElem.
+func (t *thriftWriter) beginList(id int16, elem byte, n int) {
+	t.field(id, thriftList)
+	if n < 15 {
+		t.buf = append(t.buf, byte(n)<<4|elem)
+	} else {
+		t.buf = append(t.buf, 0xF0|elem)
+		t.buf = binary.AppendUvarint(t.buf, uint64(n))
+	}
+}
+
+func (t *thriftWriter) beginStruct(id int16) {
+	t.field(id, thriftStruct)
+	t.beginElem()
+}
+
+// beginElem starts a struct element of a list.
+func (t *thriftWriter) beginElem() {
+	t.last = append(t.last, 0)
+}
+
+func (t *thriftWriter) endStruct() {
+	t.buf = append(t.buf, 0)
+	t.last = t.last[:len(t.last)-1]
+}
+
+// end closes the outermost struct and returns the encoding.
+func (t *thriftWriter) end() []byte {
+	return append(t.buf, 0)
+}
@@ -0,0 +1,130 @@
+package analytics
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+// thriftReader decodes compact protocol structs into maps of field ID to
+// value, enough to check the metadata WriteParquet produces.
+type thriftReader struct {
+	t   *testing.T
+	buf []byte
+}
+
+func (r *thriftReader) uvarint() uint64 {
+	v, n := binary.Uvarint(r.buf)
+	if n <= 0 {
+		r.t.Fatal("bad varint")
+	}
+	r.buf = r.buf[n:]
+	return v
+}
+
+func (r *thriftReader) varint() int64 {
+	v := r.uvarint()
+	return int64(v>>1) ^ -int64(v&1)
+}
+
+func (r *thriftReader) value(typ byte) any {
+	switch typ {
+	case thriftI32, thriftI64:
+		return r.varint()
+	case thriftBinary:
+		n := r.uvarint()
+		s := string(r.buf[:n])
+		r.buf = r.buf[n:]
+		return s
+	case thriftList:
+		head := r.buf[0]
+		r.buf = r.buf[1:]
+		n := int(head >> 4)
+		if n == 15 {
+			n = int(r.uvarint())
+		}
+		list := make([]any, n)
+		for i := range list {
+			list[i] = r.value(head & 0x0F)
+		}
+		return list
+	case thriftStruct:
+		return r.readStruct()
+	}
+	r.t.Fatalf("unexpected type %d", typ)
+	return nil
+}
+
+func (r *thriftReader) readStruct() map[int64]any {
+	fields := map[int64]any{}
+	var id int64
+	for {
+		head := r.buf[0]
+		r.buf = r.buf[1:]
+		if head == 0 {
+			return fields
+		}
+		if delta := int64(head >> 4); delta != 0 {
+			id += delta
+		} else {
+			id = r.varint()
+		}
+		fields[id] = r.value(head & 0x0F)
+	}
+}
+
+func TestWriteParquet(t *testing.T) {
+	rows := []Row{
+		{Day: "2026-10-17", Metric: ZennySink, Key: KeyShop, Value: 1500},
+		{Day: "2026-10-18", Metric: Items, Key: "7:1234", Value: -2},
+	}
+	var b bytes.Buffer
+	if err := WriteParquet(&b, rows); err != nil {
+		t.Fatal(err)
+	}
+	data := b.Bytes()
+	if string(data[:4]) != parquetMagic || string(data[len(data)-4:]) != parquetMagic {
+		t.Fatal("missing PAR1 magic")
+	}
+	n := int(binary.LittleEndian.Uint32(data[len(data)-8:]))
+	footer := &thriftReader{t: t, buf: data[len(data)-8-n : len(data)-8]}
+	meta := footer.readStruct()
+	if len(footer.buf) != 0 {
+		t.Fatalf("%d bytes after the footer", len(footer.buf))
+	}
+	if meta[3] != int64(2) {
+		t.Errorf("num_rows = %v", meta[3])
+	}
+	schema := meta[2].([]any)
+	if len(schema) != 5 || schema[1].(map[int64]any)[4] != "day" || schema[1].(map[int64]any)[6] != int64(convertedDate) {
+		t.Errorf("schema = %v", schema)
+	}
+
+	group := meta[4].([]any)[0].(map[int64]any)
+	chunks := group[1].([]any)
+	if len(chunks) != 4 {
+		t.Fatalf("%d column chunks", len(chunks))
+	}
+	// The value column's page holds the values in PLAIN encoding.
+	chunk := chunks[3].(map[int64]any)[3].(map[int64]any)
+	page := &thriftReader{t: t, buf: data[chunk[9].(int64):]}
+	header := page.readStruct()
+	if header[5].(map[int64]any)[1] != int64(2) {
+		t.Errorf("page header = %v", header)
+	}
+	values := page.buf[:header[2].(int64)]
+	if int64(binary.LittleEndian.Uint64(values)) != 1500 || int64(binary.LittleEndian.Uint64(values[8:])) != -2 {
+		t.Errorf("values = %x", values)
+	}
+	// The day column counts days since the epoch.
+	chunk = chunks[0].(map[int64]any)[3].(map[int64]any)
+	page = &thriftReader{t: t, buf: data[chunk[9].(int64):]}
+	page.readStruct()
+	if days := binary.LittleEndian.Uint32(page.buf); days != 20743 {
+		t.Errorf("day = %d", days)
+	}
+
+	if err := WriteParquet(&b, []Row{{Day: "yesterday"}}); err == nil {
+		t.Error("WriteParquet accepted a bad day")
+	}
+}
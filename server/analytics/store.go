@@ -0,0 +1,70 @@
+package analytics
+
+import (
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// Filter narrows a Query. Zero fields match everything.
+type Filter struct {
+	Metric string
+	Since  time.Time // First day included
+	Until  time.Time // Last day included
+}
+
+// Repository reads and writes the analytics_daily table.
+type Repository struct {
+	db *sqlx.DB
+}
+
+// NewRepository creates a new Repository.
+func NewRepository(db *sqlx.DB) *Repository {
+	return &Repository{db: db}
+}
+
+// Add adds each row's value to the stored value of its day, metric and key.
+func (r *Repository) Add(rows []Row) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+	for _, row := range rows {
+		if _, err := tx.Exec(`INSERT INTO analytics_daily (day, metric, key, value) VALUES ($1, $2, $3, $4)
+			ON CONFLICT (day, metric, key) DO UPDATE SET value = analytics_daily.value + EXCLUDED.value`,
+			row.Day, row.Metric, row.Key, row.Value); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// SamplePlayers raises today's PlayersPeak to the players online now on
+// every channel in the servers table, if higher.
+func (r *Repository) SamplePlayers(now time.Time) error {
+	_, err := r.db.Exec(`INSERT INTO analytics_daily (day, metric, key, value)
+		SELECT $1, $2, $3, COALESCE(sum(current_players), 0) FROM servers
+		ON CONFLICT (day, metric, key) DO UPDATE SET value = GREATEST(analytics_daily.value, EXCLUDED.value)`,
+		Day(now), PlayersPeak, KeyAll)
+	return err
+}
+
+// Query returns the rows matching f, by day, metric and key.
+func (r *Repository) Query(f Filter) ([]Row, error) {
+	rows := []Row{}
+	err := r.db.Select(&rows, `SELECT to_char(day, 'YYYY-MM-DD') AS day, metric, key, value
+		FROM analytics_daily
+		WHERE ($1 = '' OR metric = $1) AND ($2::date IS NULL OR day >= $2) AND ($3::date IS NULL OR day <= $3)
+		ORDER BY day, metric, key`,
+		f.Metric, nullDay(f.Since), nullDay(f.Until))
+	return rows, err
+}
+
+func nullDay(t time.Time) *string {
+	if t.IsZero() {
+		return nil
+	}
+	day := Day(t)
+	return &day
+}
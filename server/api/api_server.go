@@ -3,6 +3,17 @@ package api
 import (
 	"context"
 	cfg "erupe-ce/config"
+	"erupe-ce/server/analytics"
+	"erupe-ce/server/audit"
+	"erupe-ce/server/channelserver"
+	"erupe-ce/server/console"
+	"erupe-ce/server/federation"
+	"erupe-ce/server/opmetrics"
+	"erupe-ce/server/questcache"
+	"erupe-ce/server/savedump"
+	"erupe-ce/server/scheduler"
+	"erupe-ce/server/sessionlog"
+	"erupe-ce/server/status"
 	"fmt"
 	"net/http"
 	"os"
@@ -17,9 +28,20 @@ import (
 
 // Config holds the dependencies required to initialize an APIServer.
 type Config struct {
-	Logger      *zap.Logger
-	DB          *sqlx.DB
-	ErupeConfig *cfg.Config
+	Logger       *zap.Logger
+	DB           *sqlx.DB
+	ErupeConfig  *cfg.Config
+	QuestCache   *questcache.Cache                      // Channel servers' quest cache, managed by the admin endpoints
+	OpMetrics    *opmetrics.Registry                    // Channel servers' handler metrics, served by /metrics
+	Console      *console.Console                       // Admin console, run by /admin/console
+	SaveDumps    *savedump.Store                        // Channel servers' save dumps, listed and restored by the admin endpoints
+	Scheduler    *scheduler.Scheduler                   // Background jobs, listed and run by /admin/jobs
+	Federation   *federation.Federation                 // Link to allied servers, shown by /admin/federation
+	ReloadConfig func() ([]cfg.Change, []string, error) // Reloads the config file, run by /admin/config/reload
+	// UpdateGameplay applies and optionally saves a partial GameplayOptions
+	// object, run by PATCH /admin/gameplay. persisted reports whether the
+	// changes were saved to the config file.
+	UpdateGameplay func(patch []byte, persist bool) (changes []cfg.Change, persisted bool, err error)
 }
 
 // APIServer is Erupes Standard API interface
@@ -31,6 +53,20 @@ type APIServer struct {
 	userRepo       APIUserRepo
 	charRepo       APICharacterRepo
 	sessionRepo    APISessionRepo
+	auditRepo      APIAuditRepo
+	sessionEvents  APISessionEventRepo
+	analytics      APIAnalyticsRepo
+	saveRepo       APISaveRepo
+	itemBoxRepo    APIItemBoxRepo
+	statusSource   status.Source
+	questCache     *questcache.Cache
+	opMetrics      *opmetrics.Registry
+	console        *console.Console
+	saveDumps      *savedump.Store
+	scheduler      *scheduler.Scheduler
+	federation     *federation.Federation
+	reloadConfig   func() ([]cfg.Change, []string, error)
+	updateGameplay func(patch []byte, persist bool) ([]cfg.Change, bool, error)
 	httpServer     *http.Server
 	isShuttingDown bool
 }
@@ -38,15 +74,31 @@ type APIServer struct {
 // NewAPIServer creates a new Server type.
 func NewAPIServer(config *Config) *APIServer {
 	s := &APIServer{
-		logger:      config.Logger,
-		db:          config.DB,
-		erupeConfig: config.ErupeConfig,
-		httpServer:  &http.Server{},
+		logger:         config.Logger,
+		db:             config.DB,
+		erupeConfig:    config.ErupeConfig,
+		questCache:     config.QuestCache,
+		opMetrics:      config.OpMetrics,
+		console:        config.Console,
+		saveDumps:      config.SaveDumps,
+		scheduler:      config.Scheduler,
+		federation:     config.Federation,
+		reloadConfig:   config.ReloadConfig,
+		updateGameplay: config.UpdateGameplay,
+		httpServer:     &http.Server{},
 	}
 	if config.DB != nil {
 		s.userRepo = NewAPIUserRepository(config.DB)
 		s.charRepo = NewAPICharacterRepository(config.DB)
 		s.sessionRepo = NewAPISessionRepository(config.DB)
+		s.auditRepo = audit.NewRepository(config.DB)
+		s.sessionEvents = sessionlog.NewRepository(config.DB)
+		if config.ErupeConfig.Analytics.Enabled {
+			s.analytics = analytics.NewRepository(config.DB)
+		}
+		s.saveRepo = NewAPISaveRepository(config.DB, config.ErupeConfig.Compression)
+		s.itemBoxRepo = channelserver.NewHouseRepository(config.DB)
+		s.statusSource = status.NewRepository(config.DB)
 	}
 	return s
 }
@@ -65,7 +117,25 @@ func (s *APIServer) Start() error {
 	r.HandleFunc("/api/ss/bbs/{id}", s.ScreenShotGet)
 	r.HandleFunc("/", s.LandingPage)
 	r.HandleFunc("/health", s.Health)
+	r.HandleFunc("/status", s.Status)
 	r.HandleFunc("/version", s.Version)
+	r.HandleFunc("/admin/quests/cache", s.requireAdmin(s.QuestCacheStats)).Methods("GET")
+	r.HandleFunc("/admin/quests/invalidate", s.requireAdmin(s.InvalidateQuests)).Methods("POST")
+	r.HandleFunc("/metrics", s.requireAdmin(s.Metrics)).Methods("GET")
+	r.HandleFunc("/admin/audit", s.requireAdmin(s.AuditLog)).Methods("GET")
+	r.HandleFunc("/admin/sessions/events", s.requireAdmin(s.SessionEvents)).Methods("GET")
+	r.HandleFunc("/admin/analytics", s.requireAdmin(s.Analytics)).Methods("GET")
+	r.HandleFunc("/admin/analytics/totals", s.requireAdmin(s.AnalyticsTotals)).Methods("GET")
+	r.HandleFunc("/admin/console", s.requireAdmin(s.Console)).Methods("POST")
+	r.HandleFunc("/admin/config/reload", s.requireAdmin(s.ReloadConfig)).Methods("POST")
+	r.HandleFunc("/admin/gameplay", s.requireAdmin(s.Gameplay)).Methods("GET")
+	r.HandleFunc("/admin/gameplay", s.requireAdmin(s.UpdateGameplay)).Methods("PATCH")
+	r.HandleFunc("/admin/jobs", s.requireAdmin(s.Jobs)).Methods("GET")
+	r.HandleFunc("/admin/jobs/{name}/run", s.requireAdmin(s.RunJob)).Methods("POST")
+	r.HandleFunc("/admin/federation", s.requireAdmin(s.Federation)).Methods("GET")
+	r.HandleFunc("/admin/characters/{id}/savedumps", s.requireAdmin(s.SaveDumps)).Methods("GET")
+	r.HandleFunc("/admin/characters/{id}/savedumps/restore", s.requireAdmin(s.RestoreSaveDump)).Methods("POST")
+	r.HandleFunc("/admin/characters/{id}/itembox/transfer", s.requireAdmin(s.TransferItemBox)).Methods("POST")
 	handler := handlers.CORS(handlers.AllowedHeaders([]string{"Content-Type"}))(r)
 	s.httpServer.Handler = handlers.LoggingHandler(os.Stdout, handler)
 	s.httpServer.Addr = fmt.Sprintf(":%d", s.erupeConfig.API.Port)
@@ -1,13 +1,24 @@
 package api
 
 import (
+	"bytes"
 	"context"
+	"crypto/subtle"
 	"database/sql"
 	"encoding/json"
 	"encoding/xml"
 	"errors"
 	"erupe-ce/common/gametime"
 	cfg "erupe-ce/config"
+	"erupe-ce/server/analytics"
+	"erupe-ce/server/audit"
+	"erupe-ce/server/channelserver"
+	"erupe-ce/server/channelserver/compression/nullcomp"
+	"erupe-ce/server/federation"
+	"erupe-ce/server/savedump"
+	"erupe-ce/server/scheduler"
+	"erupe-ce/server/sessionlog"
+	"erupe-ce/server/status"
 	"fmt"
 	"image"
 	"image/jpeg"
@@ -16,6 +27,7 @@ import (
 	"os"
 	"path/filepath"
 	"regexp"
+	"strconv"
 	"strings"
 	"time"
 
@@ -107,19 +119,19 @@ func (s *APIServer) newAuthData(userID uint32, userRights uint32, userTokenID ui
 		}
 	}
 	stalls := []uint32{10, 3, 6, 9, 4, 8, 5, 7}
-	if s.erupeConfig.GameplayOptions.MezFesSwitchMinigame {
+	if s.erupeConfig.Live().GameplayOptions.MezFesSwitchMinigame {
 		stalls[4] = 2
 	}
 	resp.MezFes = &MezFes{
 		ID:           uint32(gametime.WeekStart().Unix()),
-		Start:        uint32(gametime.WeekStart().Add(-time.Duration(s.erupeConfig.GameplayOptions.MezFesDuration) * time.Second).Unix()),
+		Start:        uint32(gametime.WeekStart().Add(-time.Duration(s.erupeConfig.Live().GameplayOptions.MezFesDuration) * time.Second).Unix()),
 		End:          uint32(gametime.WeekNext().Unix()),
-		SoloTickets:  s.erupeConfig.GameplayOptions.MezFesSoloTickets,
-		GroupTickets: s.erupeConfig.GameplayOptions.MezFesGroupTickets,
+		SoloTickets:  s.erupeConfig.Live().GameplayOptions.MezFesSoloTickets,
+		GroupTickets: s.erupeConfig.Live().GameplayOptions.MezFesGroupTickets,
 		Stalls:       stalls,
 	}
-	if !s.erupeConfig.HideLoginNotice {
-		resp.Notices = append(resp.Notices, strings.Join(s.erupeConfig.LoginNotices[:], "<PAGE>"))
+	if !s.erupeConfig.Live().HideLoginNotice {
+		resp.Notices = append(resp.Notices, strings.Join(s.erupeConfig.Live().LoginNotices[:], "<PAGE>"))
 	}
 	return resp
 }
@@ -471,3 +483,647 @@ func (s *APIServer) Health(w http.ResponseWriter, r *http.Request) {
 		"status": "ok",
 	})
 }
+
+// Status handles GET /status, returning per-world populations and the
+// active and upcoming scheduled events.
+func (s *APIServer) Status(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if s.statusSource == nil {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		_ = json.NewEncoder(w).Encode(map[string]string{
+			"error": "database not configured",
+		})
+		return
+	}
+	snap, err := status.Collect(s.statusSource, time.Now())
+	if err != nil {
+		s.logger.Error("Failed to collect server status", zap.Error(err))
+		w.WriteHeader(http.StatusInternalServerError)
+		_ = json.NewEncoder(w).Encode(map[string]string{
+			"error": "failed to collect status",
+		})
+		return
+	}
+	_ = json.NewEncoder(w).Encode(snap)
+}
+
+// requireAdmin wraps an administrative handler so it only runs for requests
+// bearing API.AdminToken. The endpoints do not exist while no token is set.
+// Requests other than GETs change server state and are recorded in the
+// audit log.
+func (s *APIServer) requireAdmin(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token := s.erupeConfig.API.AdminToken
+		if token == "" {
+			http.NotFound(w, r)
+			return
+		}
+		given, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.Method != http.MethodGet {
+			s.auditAdminCall(r)
+		}
+		next(w, r)
+	}
+}
+
+// maxAuditedBodySize caps the request body stored with an audited admin call.
+const maxAuditedBodySize = 64 << 10
+
+// auditAdminCall records an admin API call, with its JSON body as the
+// parameters, and leaves the body readable for the handler.
+func (s *APIServer) auditAdminCall(r *http.Request) {
+	if s.auditRepo == nil {
+		return
+	}
+	var params json.RawMessage
+	if r.Body != nil {
+		body, err := io.ReadAll(io.LimitReader(r.Body, maxAuditedBodySize))
+		if err == nil {
+			r.Body = io.NopCloser(io.MultiReader(bytes.NewReader(body), r.Body))
+			if json.Valid(body) {
+				params = body
+			}
+		}
+	}
+	action := r.Method + " " + r.URL.Path
+	if err := s.auditRepo.Record(audit.SourceAPI, r.RemoteAddr, action, "", params); err != nil {
+		s.logger.Error("Failed to record audit log entry", zap.Error(err), zap.String("action", action))
+	}
+}
+
+// AuditLog handles GET /admin/audit, returning audit log entries newest
+// first. The source, actor and action query parameters filter by exact
+// match, since takes an RFC 3339 time, before pages back from an entry ID
+// and limit caps the number of entries.
+func (s *APIServer) AuditLog(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if s.auditRepo == nil {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		_ = json.NewEncoder(w).Encode(map[string]string{
+			"error": "audit log not configured",
+		})
+		return
+	}
+	q := r.URL.Query()
+	f := audit.Filter{
+		Source: q.Get("source"),
+		Actor:  q.Get("actor"),
+		Action: q.Get("action"),
+	}
+	var err error
+	if v := q.Get("since"); v != "" {
+		if f.Since, err = time.Parse(time.RFC3339, v); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+	}
+	if v := q.Get("before"); v != "" {
+		if f.Before, err = strconv.ParseInt(v, 10, 64); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+	}
+	if v := q.Get("limit"); v != "" {
+		if f.Limit, err = strconv.Atoi(v); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+	}
+	entries, err := s.auditRepo.Query(f)
+	if err != nil {
+		s.logger.Error("Failed to query audit log", zap.Error(err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	_ = json.NewEncoder(w).Encode(entries)
+}
+
+// SessionEvents handles GET /admin/sessions/events, returning session
+// lifecycle events newest first. char_id, user_id and event filter by exact
+// match, since and until take RFC 3339 times, before pages back from an
+// event ID and limit caps the number of events.
+func (s *APIServer) SessionEvents(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if s.sessionEvents == nil {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		_ = json.NewEncoder(w).Encode(map[string]string{
+			"error": "session events not configured",
+		})
+		return
+	}
+	q := r.URL.Query()
+	f := sessionlog.Filter{Event: q.Get("event")}
+	var err error
+	for name, dst := range map[string]*uint32{"char_id": &f.CharID, "user_id": &f.UserID} {
+		if v := q.Get(name); v != "" {
+			id, err := strconv.ParseUint(v, 10, 32)
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			*dst = uint32(id)
+		}
+	}
+	for name, dst := range map[string]*time.Time{"since": &f.Since, "until": &f.Until} {
+		if v := q.Get(name); v != "" {
+			if *dst, err = time.Parse(time.RFC3339, v); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+		}
+	}
+	if v := q.Get("before"); v != "" {
+		if f.Before, err = strconv.ParseInt(v, 10, 64); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+	}
+	if v := q.Get("limit"); v != "" {
+		if f.Limit, err = strconv.Atoi(v); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+	}
+	events, err := s.sessionEvents.Query(f)
+	if err != nil {
+		s.logger.Error("Failed to query session events", zap.Error(err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	_ = json.NewEncoder(w).Encode(events)
+}
+
+// Analytics handles GET /admin/analytics, exporting the daily economy and
+// activity rollups by day, metric and key. metric filters by exact match,
+// since and until take YYYY-MM-DD days and are included, and format is
+// json (the default), csv or parquet.
+func (s *APIServer) Analytics(w http.ResponseWriter, r *http.Request) {
+	format := r.URL.Query().Get("format")
+	switch format {
+	case "", analytics.FormatJSON, analytics.FormatCSV, analytics.FormatParquet:
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	rows, ok := s.queryAnalytics(w, r)
+	if !ok {
+		return
+	}
+	var err error
+	switch format {
+	case "", analytics.FormatJSON:
+		w.Header().Set("Content-Type", "application/json")
+		err = json.NewEncoder(w).Encode(rows)
+	case analytics.FormatCSV:
+		w.Header().Set("Content-Type", "text/csv")
+		w.Header().Set("Content-Disposition", `attachment; filename="analytics.csv"`)
+		err = analytics.WriteCSV(w, rows)
+	case analytics.FormatParquet:
+		w.Header().Set("Content-Type", "application/vnd.apache.parquet")
+		w.Header().Set("Content-Disposition", `attachment; filename="analytics.parquet"`)
+		err = analytics.WriteParquet(w, rows)
+	}
+	if err != nil {
+		s.logger.Warn("Failed to write analytics export", zap.Error(err))
+	}
+}
+
+// AnalyticsTotals handles GET /admin/analytics/totals, summing the rollups
+// of each metric and key over the days Analytics would export, largest
+// first within each metric.
+func (s *APIServer) AnalyticsTotals(w http.ResponseWriter, r *http.Request) {
+	rows, ok := s.queryAnalytics(w, r)
+	if !ok {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(analytics.Totals(rows))
+}
+
+// queryAnalytics reads the rollups matching the request's filters, writing
+// the error response if it cannot.
+func (s *APIServer) queryAnalytics(w http.ResponseWriter, r *http.Request) ([]analytics.Row, bool) {
+	if s.analytics == nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		_ = json.NewEncoder(w).Encode(map[string]string{
+			"error": "analytics not configured",
+		})
+		return nil, false
+	}
+	q := r.URL.Query()
+	f := analytics.Filter{Metric: q.Get("metric")}
+	for name, dst := range map[string]*time.Time{"since": &f.Since, "until": &f.Until} {
+		if v := q.Get(name); v != "" {
+			var err error
+			if *dst, err = time.Parse(time.DateOnly, v); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return nil, false
+			}
+		}
+	}
+	rows, err := s.analytics.Query(f)
+	if err != nil {
+		s.logger.Error("Failed to query analytics", zap.Error(err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return nil, false
+	}
+	return rows, true
+}
+
+// Console handles POST /admin/console, running one admin console command
+// and returning its output as text.
+func (s *APIServer) Console(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if s.console == nil {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		_ = json.NewEncoder(w).Encode(map[string]string{
+			"error": "console not configured",
+		})
+		return
+	}
+	var reqData struct {
+		Command string `json:"command"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&reqData); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	var out strings.Builder
+	if err := s.console.Exec(&out, reqData.Command); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		_ = json.NewEncoder(w).Encode(map[string]string{
+			"error": err.Error(),
+		})
+		return
+	}
+	_ = json.NewEncoder(w).Encode(map[string]string{
+		"output": out.String(),
+	})
+}
+
+// ReloadConfig handles POST /admin/config/reload, applying the reloadable
+// settings of the config file and returning those it changed, with the
+// changed settings that need a restart.
+func (s *APIServer) ReloadConfig(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if s.reloadConfig == nil {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		_ = json.NewEncoder(w).Encode(map[string]string{
+			"error": "config reload not configured",
+		})
+		return
+	}
+	changes, restart, err := s.reloadConfig()
+	if err != nil {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		_ = json.NewEncoder(w).Encode(map[string]string{
+			"error": err.Error(),
+		})
+		return
+	}
+	_ = json.NewEncoder(w).Encode(struct {
+		Changes []cfg.Change `json:"changes"`
+		Restart []string     `json:"restart"`
+	}{changes, restart})
+}
+
+// Gameplay handles GET /admin/gameplay, returning the live GameplayOptions.
+func (s *APIServer) Gameplay(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(s.erupeConfig.Live().GameplayOptions)
+}
+
+// UpdateGameplay handles PATCH /admin/gameplay. The body holds the
+// GameplayOptions to change, such as {"ZennyMultiplier": 2}, which take
+// effect immediately. They are saved to the config file unless the query
+// has persist=false.
+func (s *APIServer) UpdateGameplay(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if s.updateGameplay == nil {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		_ = json.NewEncoder(w).Encode(map[string]string{
+			"error": "gameplay updates not configured",
+		})
+		return
+	}
+	patch, err := io.ReadAll(io.LimitReader(r.Body, 64<<10))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		_ = json.NewEncoder(w).Encode(map[string]string{
+			"error": "failed to read body",
+		})
+		return
+	}
+	persist := r.URL.Query().Get("persist") != "false"
+	changes, persisted, err := s.updateGameplay(patch, persist)
+	if err != nil {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		_ = json.NewEncoder(w).Encode(map[string]string{
+			"error": err.Error(),
+		})
+		return
+	}
+	_ = json.NewEncoder(w).Encode(struct {
+		Changes   []cfg.Change        `json:"changes"`
+		Persisted bool                `json:"persisted"`
+		Gameplay  cfg.GameplayOptions `json:"gameplay"`
+	}{changes, persisted, s.erupeConfig.Live().GameplayOptions})
+}
+
+// Jobs handles GET /admin/jobs, listing the scheduler's jobs with their
+// schedule, next run and recent runs.
+func (s *APIServer) Jobs(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if s.scheduler == nil {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		_ = json.NewEncoder(w).Encode(map[string]string{
+			"error": "scheduler not configured",
+		})
+		return
+	}
+	_ = json.NewEncoder(w).Encode(s.scheduler.Jobs())
+}
+
+// RunJob handles POST /admin/jobs/{name}/run, starting a run of the job now
+// unless it is already running.
+func (s *APIServer) RunJob(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if s.scheduler == nil {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		_ = json.NewEncoder(w).Encode(map[string]string{
+			"error": "scheduler not configured",
+		})
+		return
+	}
+	err := s.scheduler.RunNow(mux.Vars(r)["name"])
+	switch {
+	case errors.Is(err, scheduler.ErrUnknownJob):
+		w.WriteHeader(http.StatusNotFound)
+	case err != nil:
+		w.WriteHeader(http.StatusConflict)
+	default:
+		w.WriteHeader(http.StatusAccepted)
+		_ = json.NewEncoder(w).Encode(map[string]string{
+			"status": "started",
+		})
+		return
+	}
+	_ = json.NewEncoder(w).Encode(map[string]string{
+		"error": err.Error(),
+	})
+}
+
+// Federation handles GET /admin/federation, listing the allied servers
+// connected and the players online on each.
+func (s *APIServer) Federation(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if s.federation == nil {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		_ = json.NewEncoder(w).Encode(map[string]string{
+			"error": "federation not configured",
+		})
+		return
+	}
+	presence := s.federation.Presence()
+	if presence == nil {
+		presence = []federation.Presence{}
+	}
+	peers := s.federation.Peers()
+	if peers == nil {
+		peers = []string{}
+	}
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"name":     s.federation.Name(),
+		"peers":    peers,
+		"presence": presence,
+	})
+}
+
+// QuestCacheStats handles GET /admin/quests/cache, returning the size and
+// hit, miss and eviction counters of the channel servers' quest cache.
+func (s *APIServer) QuestCacheStats(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if s.questCache == nil {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		_ = json.NewEncoder(w).Encode(map[string]string{
+			"error": "quest cache not configured",
+		})
+		return
+	}
+	_ = json.NewEncoder(w).Encode(s.questCache.Stats())
+}
+
+// Metrics handles GET /metrics, serving the channel servers' per-opcode
+// handler duration histograms, error counts and payload sizes in the
+// Prometheus text format.
+func (s *APIServer) Metrics(w http.ResponseWriter, r *http.Request) {
+	if s.opMetrics == nil {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	if err := s.opMetrics.WritePrometheus(w); err != nil {
+		s.logger.Warn("Failed to write metrics", zap.Error(err))
+	}
+}
+
+// InvalidateQuests handles POST /admin/quests/invalidate, dropping the
+// listed quests from the quest cache so an uploaded quest file is read
+// again. An empty list invalidates every quest.
+func (s *APIServer) InvalidateQuests(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if s.questCache == nil {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		_ = json.NewEncoder(w).Encode(map[string]string{
+			"error": "quest cache not configured",
+		})
+		return
+	}
+	var reqData struct {
+		Quests []int `json:"quests"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&reqData); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+	}
+	n := s.questCache.Invalidate(reqData.Quests...)
+	s.logger.Info("Invalidated cached quests", zap.Ints("quests", reqData.Quests), zap.Int("removed", n))
+	_ = json.NewEncoder(w).Encode(map[string]int{
+		"invalidated": n,
+	})
+}
+
+// charIDVar parses the {id} of an /admin/characters/{id} route.
+func charIDVar(r *http.Request) (uint32, bool) {
+	id, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 32)
+	return uint32(id), err == nil && id != 0
+}
+
+// SaveDumps handles GET /admin/characters/{id}/savedumps, returning the
+// character's save dumps newest first with the time, client mode, stage and
+// quest each was written in.
+func (s *APIServer) SaveDumps(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if !s.saveDumps.Enabled() {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		_ = json.NewEncoder(w).Encode(map[string]string{
+			"error": "save dumps not configured",
+		})
+		return
+	}
+	charID, ok := charIDVar(r)
+	if !ok {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	dumps, err := s.saveDumps.List(charID)
+	if err != nil {
+		s.logger.Error("Failed to list save dumps", zap.Error(err), zap.Uint32("charID", charID))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if dumps == nil {
+		dumps = []savedump.Entry{}
+	}
+	_ = json.NewEncoder(w).Encode(dumps)
+}
+
+// RestoreSaveDump handles POST /admin/characters/{id}/savedumps/restore,
+// storing the character's savedata dump named by file as its save. A dump
+// written in another client mode is migrated to the server's first. The
+// character must be offline, as its next save from the game would replace
+// the restored one.
+func (s *APIServer) RestoreSaveDump(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if !s.saveDumps.Enabled() || s.saveRepo == nil {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		_ = json.NewEncoder(w).Encode(map[string]string{
+			"error": "save dumps not configured",
+		})
+		return
+	}
+	charID, ok := charIDVar(r)
+	if !ok {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	var reqData struct {
+		File string `json:"file"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&reqData); err != nil || reqData.File == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	entry, data, err := s.saveDumps.Read(charID, reqData.File)
+	if errors.Is(err, savedump.ErrNotFound) {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	} else if err != nil {
+		s.logger.Error("Failed to read save dump", zap.Error(err), zap.Uint32("charID", charID), zap.String("file", reqData.File))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if entry.Kind != savedump.KindSavedata && entry.Kind != savedump.KindRaw {
+		w.WriteHeader(http.StatusBadRequest)
+		_ = json.NewEncoder(w).Encode(map[string]string{
+			"error": fmt.Sprintf("%s is a %s dump, not a save", entry.File, entry.Kind),
+		})
+		return
+	}
+
+	mode := s.erupeConfig.RealClientMode
+	// Raw dumps are not compressed, which Decompress passes through.
+	save, err := nullcomp.Decompress(data)
+	if err == nil && entry.Mode != 0 && entry.Mode != mode {
+		save, err = channelserver.MigrateSaveData(save, entry.Mode, mode)
+	}
+	if err == nil {
+		err = channelserver.ValidateSaveData(save, mode)
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		_ = json.NewEncoder(w).Encode(map[string]string{
+			"error": err.Error(),
+		})
+		return
+	}
+	if err := s.saveRepo.Store(charID, save, mode); errors.Is(err, channelserver.ErrCharacterOnline) {
+		w.WriteHeader(http.StatusConflict)
+		_ = json.NewEncoder(w).Encode(map[string]string{
+			"error": err.Error(),
+		})
+		return
+	} else if err != nil {
+		s.logger.Error("Failed to restore save dump", zap.Error(err), zap.Uint32("charID", charID), zap.String("file", entry.File))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	s.logger.Info("Restored save dump", zap.Uint32("charID", charID), zap.String("file", entry.File))
+	_ = json.NewEncoder(w).Encode(map[string]string{
+		"restored": entry.File,
+	})
+}
+
+// TransferItemBox handles POST /admin/characters/{id}/itembox/transfer,
+// moving or copying the character's warehouse item box pages to another
+// character of the same account. The body gives the destination as to, the
+// pages as boxes (all when empty), move to empty the source pages and
+// conflict as fail, merge or replace for destination pages holding items.
+// Both characters must be offline.
+func (s *APIServer) TransferItemBox(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if s.itemBoxRepo == nil {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		_ = json.NewEncoder(w).Encode(map[string]string{
+			"error": "database not configured",
+		})
+		return
+	}
+	charID, ok := charIDVar(r)
+	if !ok {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	var t channelserver.ItemBoxTransfer
+	if err := json.NewDecoder(r.Body).Decode(&t); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	t.From = charID
+	if err := t.Validate(); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		_ = json.NewEncoder(w).Encode(map[string]string{
+			"error": err.Error(),
+		})
+		return
+	}
+	results, err := s.itemBoxRepo.TransferItemBoxes(t)
+	if err != nil {
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, channelserver.ErrNotSameAccount):
+			status = http.StatusUnprocessableEntity
+		case errors.Is(err, channelserver.ErrItemBoxConflict), errors.Is(err, channelserver.ErrCharacterOnline):
+			status = http.StatusConflict
+		default:
+			s.logger.Error("Failed to transfer item box", zap.Error(err), zap.Uint32("from", t.From), zap.Uint32("to", t.To))
+		}
+		w.WriteHeader(status)
+		_ = json.NewEncoder(w).Encode(map[string]string{
+			"error": err.Error(),
+		})
+		return
+	}
+	s.logger.Info("Transferred item box", zap.Uint32("from", t.From), zap.Uint32("to", t.To),
+		zap.Bool("move", t.Move), zap.String("conflict", t.Conflict))
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"boxes": results,
+	})
+}
@@ -2,16 +2,35 @@ package api
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
 	"strings"
 	"testing"
 	"time"
 
 	"erupe-ce/common/gametime"
 	cfg "erupe-ce/config"
+	"erupe-ce/network"
+	"erupe-ce/server/analytics"
+	"erupe-ce/server/audit"
+	"erupe-ce/server/channelserver"
+	"erupe-ce/server/console"
+	"erupe-ce/server/federation"
+	"erupe-ce/server/opmetrics"
+	"erupe-ce/server/questcache"
+	"erupe-ce/server/savedump"
+	"erupe-ce/server/scheduler"
+	"erupe-ce/server/sessionlog"
+	"erupe-ce/server/status"
+
+	"github.com/gorilla/mux"
 	"go.uber.org/zap"
 )
 
@@ -661,3 +680,720 @@ func BenchmarkNewAuthData(b *testing.B) {
 		_ = server.newAuthData(1, 0, 1, "token", characters)
 	}
 }
+
+func TestStatusEndpoint(t *testing.T) {
+	server := &APIServer{
+		logger:      NewTestLogger(t),
+		erupeConfig: NewTestConfig(),
+		statusSource: &mockStatusSource{
+			worlds: []status.World{{Name: "Newbie", Channels: 2, Players: 5}},
+			events: []status.Event{{Type: "festa", Start: time.Now().Add(time.Hour)}},
+		},
+	}
+
+	recorder := httptest.NewRecorder()
+	server.Status(recorder, httptest.NewRequest("GET", "/status", nil))
+
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, recorder.Code)
+	}
+	var snap status.Snapshot
+	if err := json.NewDecoder(recorder.Body).Decode(&snap); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if snap.Players != 5 || len(snap.Worlds) != 1 {
+		t.Errorf("snapshot = %+v, want 5 players in one world", snap)
+	}
+	if len(snap.Upcoming) != 1 || snap.Upcoming[0].Name != "Hunting Festival" {
+		t.Errorf("upcoming = %+v, want the festival", snap.Upcoming)
+	}
+}
+
+func TestStatusEndpointNoDB(t *testing.T) {
+	server := &APIServer{logger: NewTestLogger(t), erupeConfig: NewTestConfig()}
+
+	recorder := httptest.NewRecorder()
+	server.Status(recorder, httptest.NewRequest("GET", "/status", nil))
+
+	if recorder.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status %d, got %d", http.StatusServiceUnavailable, recorder.Code)
+	}
+}
+
+func TestAdminEndpointsRequireToken(t *testing.T) {
+	c := NewTestConfig()
+	server := &APIServer{logger: NewTestLogger(t), erupeConfig: c, questCache: questcache.New(60, 0, 0)}
+	handler := server.requireAdmin(server.QuestCacheStats)
+
+	tests := []struct {
+		name       string
+		adminToken string
+		header     string
+		want       int
+	}{
+		{"disabled", "", "Bearer secret", http.StatusNotFound},
+		{"missing", "secret", "", http.StatusUnauthorized},
+		{"wrong", "secret", "Bearer nope", http.StatusUnauthorized},
+		{"valid", "secret", "Bearer secret", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c.API.AdminToken = tt.adminToken
+			req := httptest.NewRequest("GET", "/admin/quests/cache", nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			recorder := httptest.NewRecorder()
+			handler(recorder, req)
+			if recorder.Code != tt.want {
+				t.Errorf("status = %d, want %d", recorder.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestInvalidateQuestsEndpoint(t *testing.T) {
+	cache := questcache.New(60, 0, 0)
+	cache.Put(1, []byte{0x01})
+	cache.Put(2, []byte{0x02})
+	server := &APIServer{logger: NewTestLogger(t), erupeConfig: NewTestConfig(), questCache: cache}
+
+	recorder := httptest.NewRecorder()
+	server.InvalidateQuests(recorder, httptest.NewRequest("POST", "/admin/quests/invalidate",
+		strings.NewReader(`{"quests":[1,3]}`)))
+
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, recorder.Code)
+	}
+	var resp map[string]int
+	if err := json.NewDecoder(recorder.Body).Decode(&resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if resp["invalidated"] != 1 {
+		t.Errorf("invalidated = %d, want 1", resp["invalidated"])
+	}
+	if _, ok := cache.Get(1); ok {
+		t.Error("expected quest 1 to be invalidated")
+	}
+
+	// An empty body invalidates everything.
+	recorder = httptest.NewRecorder()
+	server.InvalidateQuests(recorder, httptest.NewRequest("POST", "/admin/quests/invalidate", nil))
+	if st := cache.Stats(); st.Entries != 0 {
+		t.Errorf("Entries = %d after invalidating all, want 0", st.Entries)
+	}
+}
+
+func TestQuestCacheStatsEndpoint(t *testing.T) {
+	cache := questcache.New(60, 0, 0)
+	cache.Put(1, []byte{0x01, 0x02})
+	cache.Get(1)
+	cache.Get(2)
+	server := &APIServer{logger: NewTestLogger(t), erupeConfig: NewTestConfig(), questCache: cache}
+
+	recorder := httptest.NewRecorder()
+	server.QuestCacheStats(recorder, httptest.NewRequest("GET", "/admin/quests/cache", nil))
+
+	var stats questcache.Stats
+	if err := json.NewDecoder(recorder.Body).Decode(&stats); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if stats.Entries != 1 || stats.Bytes != 2 || stats.Hits != 1 || stats.Misses != 1 {
+		t.Errorf("stats = %+v, want 1 entry, 2 bytes, 1 hit, 1 miss", stats)
+	}
+}
+
+func TestQuestCacheEndpointsNoCache(t *testing.T) {
+	server := &APIServer{logger: NewTestLogger(t), erupeConfig: NewTestConfig()}
+
+	recorder := httptest.NewRecorder()
+	server.InvalidateQuests(recorder, httptest.NewRequest("POST", "/admin/quests/invalidate", nil))
+	if recorder.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status %d, got %d", http.StatusServiceUnavailable, recorder.Code)
+	}
+}
+
+func TestMetricsEndpoint(t *testing.T) {
+	metrics := opmetrics.New()
+	metrics.Observe(network.MSG_MHF_ENUMERATE_QUEST, 3*time.Millisecond, 12, false)
+	server := &APIServer{logger: NewTestLogger(t), erupeConfig: NewTestConfig(), opMetrics: metrics}
+
+	recorder := httptest.NewRecorder()
+	server.Metrics(recorder, httptest.NewRequest("GET", "/metrics", nil))
+
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, recorder.Code)
+	}
+	want := `erupe_handler_duration_seconds_count{opcode="MSG_MHF_ENUMERATE_QUEST"} 1`
+	if !strings.Contains(recorder.Body.String(), want) {
+		t.Errorf("body does not contain %q:\n%s", want, recorder.Body.String())
+	}
+}
+
+func TestMetricsEndpointNoMetrics(t *testing.T) {
+	server := &APIServer{logger: NewTestLogger(t), erupeConfig: NewTestConfig()}
+
+	recorder := httptest.NewRecorder()
+	server.Metrics(recorder, httptest.NewRequest("GET", "/metrics", nil))
+	if recorder.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status %d, got %d", http.StatusServiceUnavailable, recorder.Code)
+	}
+}
+
+func TestRequireAdminAuditsStateChangingCalls(t *testing.T) {
+	c := NewTestConfig()
+	c.API.AdminToken = "secret"
+	auditRepo := &mockAPIAuditRepo{}
+	server := &APIServer{logger: NewTestLogger(t), erupeConfig: c, auditRepo: auditRepo}
+
+	var body string
+	handler := server.requireAdmin(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		body = string(b)
+	})
+
+	req := httptest.NewRequest("POST", "/admin/quests/invalidate", strings.NewReader(`{"quests":[1]}`))
+	req.Header.Set("Authorization", "Bearer secret")
+	handler(httptest.NewRecorder(), req)
+
+	if body != `{"quests":[1]}` {
+		t.Errorf("handler read body %q, want the original body", body)
+	}
+	if len(auditRepo.recorded) != 1 {
+		t.Fatalf("audit entries = %d, want 1", len(auditRepo.recorded))
+	}
+	entry := auditRepo.recorded[0]
+	if entry.Source != audit.SourceAPI || entry.Action != "POST /admin/quests/invalidate" {
+		t.Errorf("audit entry = %+v", entry)
+	}
+	if string(entry.Params) != `{"quests":[1]}` {
+		t.Errorf("params = %s, want the request body", entry.Params)
+	}
+
+	// Reads and rejected calls are not audited.
+	req = httptest.NewRequest("GET", "/admin/quests/cache", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	handler(httptest.NewRecorder(), req)
+	handler(httptest.NewRecorder(), httptest.NewRequest("POST", "/admin/quests/invalidate", nil))
+	if len(auditRepo.recorded) != 1 {
+		t.Errorf("audit entries = %d, want only the authorized POST", len(auditRepo.recorded))
+	}
+}
+
+func TestAuditLogEndpoint(t *testing.T) {
+	auditRepo := &mockAPIAuditRepo{entries: []audit.Entry{
+		{ID: 7, Source: audit.SourceGame, Actor: "Op (1)", Action: "command:Ban", Target: "211111", Params: json.RawMessage(`{}`)},
+	}}
+	server := &APIServer{logger: NewTestLogger(t), erupeConfig: NewTestConfig(), auditRepo: auditRepo}
+
+	recorder := httptest.NewRecorder()
+	server.AuditLog(recorder, httptest.NewRequest("GET",
+		"/admin/audit?source=game&action=command:Ban&since=2026-01-02T03:04:05Z&before=50&limit=10", nil))
+
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, recorder.Code)
+	}
+	var entries []audit.Entry
+	if err := json.NewDecoder(recorder.Body).Decode(&entries); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(entries) != 1 || entries[0].ID != 7 {
+		t.Errorf("entries = %+v, want entry 7", entries)
+	}
+	f := auditRepo.filter
+	since := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	if f.Source != "game" || f.Action != "command:Ban" || !f.Since.Equal(since) || f.Before != 50 || f.Limit != 10 {
+		t.Errorf("filter = %+v", f)
+	}
+}
+
+func TestAuditLogEndpointErrors(t *testing.T) {
+	server := &APIServer{logger: NewTestLogger(t), erupeConfig: NewTestConfig(), auditRepo: &mockAPIAuditRepo{}}
+	for _, query := range []string{"since=yesterday", "before=x", "limit=x"} {
+		recorder := httptest.NewRecorder()
+		server.AuditLog(recorder, httptest.NewRequest("GET", "/admin/audit?"+query, nil))
+		if recorder.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want %d", query, recorder.Code, http.StatusBadRequest)
+		}
+	}
+
+	server = &APIServer{logger: NewTestLogger(t), erupeConfig: NewTestConfig()}
+	recorder := httptest.NewRecorder()
+	server.AuditLog(recorder, httptest.NewRequest("GET", "/admin/audit", nil))
+	if recorder.Code != http.StatusServiceUnavailable {
+		t.Errorf("status = %d, want %d", recorder.Code, http.StatusServiceUnavailable)
+	}
+}
+
+func TestSessionEventsEndpoint(t *testing.T) {
+	repo := &mockAPISessionEventRepo{events: []sessionlog.Event{
+		{ID: 3, Event: sessionlog.EventQuestStart, Subject: sessionlog.Subject{Server: "channel-4112", CharID: 42}, Detail: json.RawMessage(`{"stage":"sl1Qs1p0a0u0"}`)},
+	}}
+	server := &APIServer{logger: NewTestLogger(t), erupeConfig: NewTestConfig(), sessionEvents: repo}
+
+	recorder := httptest.NewRecorder()
+	server.SessionEvents(recorder, httptest.NewRequest("GET",
+		"/admin/sessions/events?char_id=42&event=quest_start&since=2026-01-02T21:00:00Z&until=2026-01-02T22:00:00Z&limit=5", nil))
+
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, recorder.Code)
+	}
+	var events []sessionlog.Event
+	if err := json.NewDecoder(recorder.Body).Decode(&events); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(events) != 1 || events[0].ID != 3 || events[0].CharID != 42 {
+		t.Errorf("events = %+v, want event 3", events)
+	}
+	f := repo.filter
+	since := time.Date(2026, 1, 2, 21, 0, 0, 0, time.UTC)
+	if f.CharID != 42 || f.Event != "quest_start" || !f.Since.Equal(since) || !f.Until.Equal(since.Add(time.Hour)) || f.Limit != 5 {
+		t.Errorf("filter = %+v", f)
+	}
+}
+
+func TestSessionEventsEndpointErrors(t *testing.T) {
+	server := &APIServer{logger: NewTestLogger(t), erupeConfig: NewTestConfig(), sessionEvents: &mockAPISessionEventRepo{}}
+	for _, query := range []string{"char_id=x", "user_id=-1", "until=later", "before=x", "limit=x"} {
+		recorder := httptest.NewRecorder()
+		server.SessionEvents(recorder, httptest.NewRequest("GET", "/admin/sessions/events?"+query, nil))
+		if recorder.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want %d", query, recorder.Code, http.StatusBadRequest)
+		}
+	}
+
+	server = &APIServer{logger: NewTestLogger(t), erupeConfig: NewTestConfig()}
+	recorder := httptest.NewRecorder()
+	server.SessionEvents(recorder, httptest.NewRequest("GET", "/admin/sessions/events", nil))
+	if recorder.Code != http.StatusServiceUnavailable {
+		t.Errorf("status = %d, want %d", recorder.Code, http.StatusServiceUnavailable)
+	}
+}
+
+func TestAnalyticsEndpoint(t *testing.T) {
+	repo := &mockAPIAnalyticsRepo{rows: []analytics.Row{
+		{Day: "2026-10-16", Metric: analytics.Items, Key: "7:1234", Value: 3},
+		{Day: "2026-10-17", Metric: analytics.Items, Key: "7:1234", Value: 4},
+	}}
+	server := &APIServer{logger: NewTestLogger(t), erupeConfig: NewTestConfig(), analytics: repo}
+
+	recorder := httptest.NewRecorder()
+	server.Analytics(recorder, httptest.NewRequest("GET", "/admin/analytics?metric=items&since=2026-10-16&until=2026-10-17", nil))
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("status = %d", recorder.Code)
+	}
+	var rows []analytics.Row
+	if err := json.NewDecoder(recorder.Body).Decode(&rows); err != nil || len(rows) != 2 {
+		t.Fatalf("rows = %+v, err %v", rows, err)
+	}
+	f := repo.filter
+	if f.Metric != analytics.Items || !f.Since.Equal(time.Date(2026, 10, 16, 0, 0, 0, 0, time.UTC)) || f.Until.Day() != 17 {
+		t.Errorf("filter = %+v", f)
+	}
+
+	recorder = httptest.NewRecorder()
+	server.Analytics(recorder, httptest.NewRequest("GET", "/admin/analytics?format=csv", nil))
+	if got := recorder.Header().Get("Content-Type"); got != "text/csv" || !strings.HasPrefix(recorder.Body.String(), "day,metric,key,value\n") {
+		t.Errorf("csv export: %s %q", got, recorder.Body.String())
+	}
+
+	recorder = httptest.NewRecorder()
+	server.Analytics(recorder, httptest.NewRequest("GET", "/admin/analytics?format=parquet", nil))
+	if body := recorder.Body.String(); !strings.HasPrefix(body, "PAR1") || !strings.HasSuffix(body, "PAR1") {
+		t.Errorf("parquet export is not a Parquet file")
+	}
+
+	recorder = httptest.NewRecorder()
+	server.AnalyticsTotals(recorder, httptest.NewRequest("GET", "/admin/analytics/totals", nil))
+	var totals []analytics.Total
+	if err := json.NewDecoder(recorder.Body).Decode(&totals); err != nil || len(totals) != 1 || totals[0].Value != 7 {
+		t.Errorf("totals = %+v, err %v", totals, err)
+	}
+}
+
+func TestAnalyticsEndpointErrors(t *testing.T) {
+	server := &APIServer{logger: NewTestLogger(t), erupeConfig: NewTestConfig(), analytics: &mockAPIAnalyticsRepo{}}
+	for _, query := range []string{"since=2026-10-1", "until=yesterday", "format=xlsx"} {
+		recorder := httptest.NewRecorder()
+		server.Analytics(recorder, httptest.NewRequest("GET", "/admin/analytics?"+query, nil))
+		if recorder.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want %d", query, recorder.Code, http.StatusBadRequest)
+		}
+	}
+
+	server = &APIServer{logger: NewTestLogger(t), erupeConfig: NewTestConfig()}
+	recorder := httptest.NewRecorder()
+	server.AnalyticsTotals(recorder, httptest.NewRequest("GET", "/admin/analytics/totals", nil))
+	if recorder.Code != http.StatusServiceUnavailable {
+		t.Errorf("status = %d, want %d", recorder.Code, http.StatusServiceUnavailable)
+	}
+}
+
+func TestConsoleEndpoint(t *testing.T) {
+	debug := &cfg.DebugOptions{}
+	server := &APIServer{
+		logger:      NewTestLogger(t),
+		erupeConfig: NewTestConfig(),
+		console:     console.New(&console.Config{Logger: zap.NewNop(), Debug: debug}),
+	}
+
+	recorder := httptest.NewRecorder()
+	server.Console(recorder, httptest.NewRequest("POST", "/admin/console", strings.NewReader(`{"command":"debug QuestTools on"}`)))
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, recorder.Code)
+	}
+	var resp map[string]string
+	if err := json.NewDecoder(recorder.Body).Decode(&resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if resp["output"] != "QuestTools: on\n" || !debug.QuestTools {
+		t.Errorf("output = %q, QuestTools = %v", resp["output"], debug.QuestTools)
+	}
+
+	recorder = httptest.NewRecorder()
+	server.Console(recorder, httptest.NewRequest("POST", "/admin/console", strings.NewReader(`{"command":"watch"}`)))
+	if recorder.Code != http.StatusBadRequest {
+		t.Errorf("watch: status = %d, want %d", recorder.Code, http.StatusBadRequest)
+	}
+
+	server = &APIServer{logger: NewTestLogger(t), erupeConfig: NewTestConfig()}
+	recorder = httptest.NewRecorder()
+	server.Console(recorder, httptest.NewRequest("POST", "/admin/console", strings.NewReader(`{"command":"status"}`)))
+	if recorder.Code != http.StatusServiceUnavailable {
+		t.Errorf("status = %d, want %d", recorder.Code, http.StatusServiceUnavailable)
+	}
+}
+
+func TestReloadConfigEndpoint(t *testing.T) {
+	server := &APIServer{
+		logger:      NewTestLogger(t),
+		erupeConfig: NewTestConfig(),
+		reloadConfig: func() ([]cfg.Change, []string, error) {
+			return []cfg.Change{{Setting: "EarthStatus", Old: 0, New: 2}}, []string{"Database"}, nil
+		},
+	}
+
+	recorder := httptest.NewRecorder()
+	server.ReloadConfig(recorder, httptest.NewRequest("POST", "/admin/config/reload", nil))
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, recorder.Code)
+	}
+	var resp struct {
+		Changes []cfg.Change `json:"changes"`
+		Restart []string     `json:"restart"`
+	}
+	if err := json.NewDecoder(recorder.Body).Decode(&resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(resp.Changes) != 1 || resp.Changes[0].Setting != "EarthStatus" || len(resp.Restart) != 1 {
+		t.Errorf("response = %+v", resp)
+	}
+
+	server.reloadConfig = func() ([]cfg.Change, []string, error) {
+		return nil, nil, errors.New("CommandPrefix is empty")
+	}
+	recorder = httptest.NewRecorder()
+	server.ReloadConfig(recorder, httptest.NewRequest("POST", "/admin/config/reload", nil))
+	if recorder.Code != http.StatusUnprocessableEntity {
+		t.Errorf("invalid config: status = %d, want %d", recorder.Code, http.StatusUnprocessableEntity)
+	}
+
+	server = &APIServer{logger: NewTestLogger(t), erupeConfig: NewTestConfig()}
+	recorder = httptest.NewRecorder()
+	server.ReloadConfig(recorder, httptest.NewRequest("POST", "/admin/config/reload", nil))
+	if recorder.Code != http.StatusServiceUnavailable {
+		t.Errorf("status = %d, want %d", recorder.Code, http.StatusServiceUnavailable)
+	}
+}
+
+func TestSaveDumpsEndpoint(t *testing.T) {
+	dumps := savedump.New(cfg.SaveDumpOptions{Enabled: true, OutputDir: t.TempDir()})
+	if _, err := dumps.Write(12, savedump.KindSavedata, []byte{0x01}, savedump.Meta{Mode: cfg.ZZ, Quest: "23045d0"}); err != nil {
+		t.Fatal(err)
+	}
+	server := &APIServer{logger: NewTestLogger(t), erupeConfig: NewTestConfig(), saveDumps: dumps}
+
+	recorder := httptest.NewRecorder()
+	req := mux.SetURLVars(httptest.NewRequest("GET", "/admin/characters/12/savedumps", nil), map[string]string{"id": "12"})
+	server.SaveDumps(recorder, req)
+
+	var entries []savedump.Entry
+	if err := json.NewDecoder(recorder.Body).Decode(&entries); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Kind != savedump.KindSavedata || entries[0].Quest != "23045d0" {
+		t.Errorf("entries = %+v, want the savedata dump", entries)
+	}
+}
+
+func TestRestoreSaveDumpEndpoint(t *testing.T) {
+	dumps := savedump.New(cfg.SaveDumpOptions{Enabled: true, OutputDir: t.TempDir()})
+	backup, _ := channelserver.SaveFixture{Mode: cfg.G10, Name: "Hunter", HR: 40}.Compressed()
+	entry, err := dumps.Write(12, savedump.KindSavedata, backup, savedump.Meta{Mode: cfg.G10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	minidata, _ := dumps.Write(12, "minidata", []byte{0x01}, savedump.Meta{})
+	saves := &mockAPISaveRepo{}
+	config := NewTestConfig()
+	config.RealClientMode = cfg.ZZ
+	server := &APIServer{logger: NewTestLogger(t), erupeConfig: config, saveDumps: dumps, saveRepo: saves}
+
+	restore := func(file string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		req := httptest.NewRequest("POST", "/admin/characters/12/savedumps/restore", strings.NewReader(`{"file":"`+file+`"}`))
+		server.RestoreSaveDump(recorder, mux.SetURLVars(req, map[string]string{"id": "12"}))
+		return recorder
+	}
+
+	if rec := restore(entry.File); rec.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+	}
+	if saves.charID != 12 || saves.mode != cfg.ZZ {
+		t.Errorf("stored for character %d in mode %d, want 12 in ZZ", saves.charID, saves.mode)
+	}
+	if err := channelserver.ValidateSaveData(saves.data, cfg.ZZ); err != nil {
+		t.Errorf("G10 dump was not migrated to ZZ: %v", err)
+	}
+
+	if rec := restore("missing.bin"); rec.Code != http.StatusNotFound {
+		t.Errorf("missing dump: status %d, want %d", rec.Code, http.StatusNotFound)
+	}
+	if rec := restore(minidata.File); rec.Code != http.StatusBadRequest {
+		t.Errorf("minidata dump: status %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+
+	saves.err = fmt.Errorf("%w: 12", channelserver.ErrCharacterOnline)
+	if rec := restore(entry.File); rec.Code != http.StatusConflict {
+		t.Errorf("online character: status %d, want %d", rec.Code, http.StatusConflict)
+	}
+}
+
+func TestRestoreSaveDumpWhileOnline(t *testing.T) {
+	db := channelserver.SetupTestDB(t)
+	userID := channelserver.CreateTestUser(t, db, "restore_online")
+	charID := channelserver.CreateTestCharacter(t, db, userID, "Online")
+	channelserver.CreateTestSignSession(t, db, userID, "restore-token")
+	if _, err := db.Exec(`UPDATE sign_sessions SET server_id = 1, char_id = $1 WHERE token = 'restore-token'`, charID); err != nil {
+		t.Fatal(err)
+	}
+
+	dumps := savedump.New(cfg.SaveDumpOptions{Enabled: true, OutputDir: t.TempDir()})
+	backup, _ := channelserver.SaveFixture{Mode: cfg.ZZ, Name: "Online", HR: 40}.Compressed()
+	entry, err := dumps.Write(charID, savedump.KindSavedata, backup, savedump.Meta{Mode: cfg.ZZ})
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := NewTestConfig()
+	config.RealClientMode = cfg.ZZ
+	saves := NewAPISaveRepository(db, config.Compression)
+	server := &APIServer{logger: NewTestLogger(t), erupeConfig: config, saveDumps: dumps, saveRepo: saves}
+
+	id := strconv.FormatUint(uint64(charID), 10)
+	restore := func() int {
+		recorder := httptest.NewRecorder()
+		req := httptest.NewRequest("POST", "/admin/characters/"+id+"/savedumps/restore", strings.NewReader(`{"file":"`+entry.File+`"}`))
+		server.RestoreSaveDump(recorder, mux.SetURLVars(req, map[string]string{"id": id}))
+		return recorder.Code
+	}
+
+	// The session's channel server may hold the character's save in its
+	// cache, which it writes back at logout over anything restored.
+	if code := restore(); code != http.StatusConflict {
+		t.Fatalf("restore while online: status %d, want %d", code, http.StatusConflict)
+	}
+	if _, err := db.Exec(`UPDATE sign_sessions SET server_id = NULL, char_id = NULL WHERE token = 'restore-token'`); err != nil {
+		t.Fatal(err)
+	}
+	if code := restore(); code != http.StatusOK {
+		t.Errorf("restore after logout: status %d, want %d", code, http.StatusOK)
+	}
+}
+
+func TestSaveDumpEndpointsNotConfigured(t *testing.T) {
+	server := &APIServer{logger: NewTestLogger(t), erupeConfig: NewTestConfig()}
+
+	recorder := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/admin/characters/12/savedumps/restore", strings.NewReader(`{"file":"a.bin"}`))
+	server.RestoreSaveDump(recorder, mux.SetURLVars(req, map[string]string{"id": "12"}))
+	if recorder.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status %d, got %d", http.StatusServiceUnavailable, recorder.Code)
+	}
+}
+
+func TestTransferItemBoxEndpoint(t *testing.T) {
+	repo := &mockAPIItemBoxRepo{results: []channelserver.ItemBoxPageResult{{Box: 2, Stacks: 4, Total: 4}}}
+	server := &APIServer{logger: NewTestLogger(t), erupeConfig: NewTestConfig(), itemBoxRepo: repo}
+
+	transfer := func(body string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		req := httptest.NewRequest("POST", "/admin/characters/12/itembox/transfer", strings.NewReader(body))
+		server.TransferItemBox(recorder, mux.SetURLVars(req, map[string]string{"id": "12"}))
+		return recorder
+	}
+
+	rec := transfer(`{"to":40,"boxes":[2],"move":true,"conflict":"merge"}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+	}
+	if repo.transfer.From != 12 || repo.transfer.To != 40 || !repo.transfer.Move || repo.transfer.Conflict != channelserver.ItemBoxConflictMerge {
+		t.Errorf("transfer = %+v", repo.transfer)
+	}
+	var resp struct {
+		Boxes []channelserver.ItemBoxPageResult `json:"boxes"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil || len(resp.Boxes) != 1 || resp.Boxes[0].Stacks != 4 {
+		t.Errorf("response = %+v, %v", resp, err)
+	}
+
+	if rec := transfer(`{"to":12}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("transfer to itself: status %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{channelserver.ErrItemBoxConflict, http.StatusConflict},
+		{channelserver.ErrCharacterOnline, http.StatusConflict},
+		{channelserver.ErrNotSameAccount, http.StatusUnprocessableEntity},
+		{errors.New("db down"), http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		repo.err = tt.err
+		if rec := transfer(`{"to":40}`); rec.Code != tt.want {
+			t.Errorf("%v: status %d, want %d", tt.err, rec.Code, tt.want)
+		}
+	}
+}
+
+func TestGameplayEndpoints(t *testing.T) {
+	erupeConfig := NewTestConfig()
+	erupeConfig.GameplayOptions.ZennyMultiplier = 1
+	var gotPersist bool
+	server := &APIServer{
+		logger:      NewTestLogger(t),
+		erupeConfig: erupeConfig,
+		updateGameplay: func(patch []byte, persist bool) ([]cfg.Change, bool, error) {
+			gotPersist = persist
+			changes, err := erupeConfig.UpdateGameplay(patch)
+			return changes, persist, err
+		},
+	}
+
+	recorder := httptest.NewRecorder()
+	server.Gameplay(recorder, httptest.NewRequest("GET", "/admin/gameplay", nil))
+	var current cfg.GameplayOptions
+	if err := json.NewDecoder(recorder.Body).Decode(&current); err != nil || current.ZennyMultiplier != 1 {
+		t.Fatalf("GET = %+v, %v", current, err)
+	}
+
+	recorder = httptest.NewRecorder()
+	server.UpdateGameplay(recorder, httptest.NewRequest("PATCH", "/admin/gameplay?persist=false", strings.NewReader(`{"ZennyMultiplier": 2}`)))
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("PATCH status = %d: %s", recorder.Code, recorder.Body)
+	}
+	var resp struct {
+		Changes   []cfg.Change        `json:"changes"`
+		Persisted bool                `json:"persisted"`
+		Gameplay  cfg.GameplayOptions `json:"gameplay"`
+	}
+	if err := json.NewDecoder(recorder.Body).Decode(&resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(resp.Changes) != 1 || resp.Gameplay.ZennyMultiplier != 2 || resp.Persisted || gotPersist {
+		t.Errorf("response = %+v, persist = %v", resp, gotPersist)
+	}
+	if erupeConfig.Live().GameplayOptions.ZennyMultiplier != 2 {
+		t.Error("update did not take effect")
+	}
+
+	recorder = httptest.NewRecorder()
+	server.UpdateGameplay(recorder, httptest.NewRequest("PATCH", "/admin/gameplay", strings.NewReader(`{"ZennyMultiplier": -1}`)))
+	if recorder.Code != http.StatusUnprocessableEntity || !gotPersist {
+		t.Errorf("invalid update: status = %d, persist = %v", recorder.Code, gotPersist)
+	}
+
+	server = &APIServer{logger: NewTestLogger(t), erupeConfig: NewTestConfig()}
+	recorder = httptest.NewRecorder()
+	server.UpdateGameplay(recorder, httptest.NewRequest("PATCH", "/admin/gameplay", strings.NewReader(`{}`)))
+	if recorder.Code != http.StatusServiceUnavailable {
+		t.Errorf("status = %d, want %d", recorder.Code, http.StatusServiceUnavailable)
+	}
+}
+
+// jobStore keeps no scheduler run history.
+type jobStore struct{}
+
+func (jobStore) Record(scheduler.Run) error                  { return nil }
+func (jobStore) Recent(string, int) ([]scheduler.Run, error) { return nil, nil }
+func (jobStore) LastStarted(string) (time.Time, error)       { return time.Now(), nil }
+
+func TestJobsEndpoints(t *testing.T) {
+	jobs := scheduler.New(jobStore{}, nil, zap.NewNop())
+	ran := make(chan struct{})
+	if err := jobs.Register(scheduler.Job{Name: "nightly", Schedule: "30 4 * * *", Run: func(context.Context) error {
+		close(ran)
+		return nil
+	}}); err != nil {
+		t.Fatal(err)
+	}
+	defer jobs.Start()()
+	server := &APIServer{logger: NewTestLogger(t), erupeConfig: NewTestConfig(), scheduler: jobs}
+
+	recorder := httptest.NewRecorder()
+	server.Jobs(recorder, httptest.NewRequest("GET", "/admin/jobs", nil))
+	var listed []scheduler.Status
+	if err := json.NewDecoder(recorder.Body).Decode(&listed); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(listed) != 1 || listed[0].Name != "nightly" || listed[0].Next == nil || listed[0].Next.Minute() != 30 {
+		t.Errorf("jobs = %+v", listed)
+	}
+
+	recorder = httptest.NewRecorder()
+	req := mux.SetURLVars(httptest.NewRequest("POST", "/admin/jobs/nightly/run", nil), map[string]string{"name": "nightly"})
+	server.RunJob(recorder, req)
+	if recorder.Code != http.StatusAccepted {
+		t.Errorf("run status = %d: %s", recorder.Code, recorder.Body)
+	}
+	select {
+	case <-ran:
+	case <-time.After(time.Second):
+		t.Error("job did not run")
+	}
+
+	recorder = httptest.NewRecorder()
+	req = mux.SetURLVars(httptest.NewRequest("POST", "/admin/jobs/missing/run", nil), map[string]string{"name": "missing"})
+	server.RunJob(recorder, req)
+	if recorder.Code != http.StatusNotFound {
+		t.Errorf("unknown job status = %d", recorder.Code)
+	}
+
+	server = &APIServer{logger: NewTestLogger(t), erupeConfig: NewTestConfig()}
+	recorder = httptest.NewRecorder()
+	server.Jobs(recorder, httptest.NewRequest("GET", "/admin/jobs", nil))
+	if recorder.Code != http.StatusServiceUnavailable {
+		t.Errorf("status = %d, want %d", recorder.Code, http.StatusServiceUnavailable)
+	}
+}
+
+func TestFederationEndpoint(t *testing.T) {
+	allies := federation.New(cfg.FederationOptions{Name: "here", Presence: true}, nil)
+	server := &APIServer{logger: NewTestLogger(t), erupeConfig: NewTestConfig(), federation: allies}
+	recorder := httptest.NewRecorder()
+	server.Federation(recorder, httptest.NewRequest("GET", "/admin/federation", nil))
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", recorder.Code, recorder.Body)
+	}
+	want := `{"name":"here","peers":[],"presence":[]}` + "\n"
+	if recorder.Body.String() != want {
+		t.Errorf("body = %s, want %s", recorder.Body, want)
+	}
+
+	server = &APIServer{logger: NewTestLogger(t), erupeConfig: NewTestConfig()}
+	recorder = httptest.NewRecorder()
+	server.Federation(recorder, httptest.NewRequest("GET", "/admin/federation", nil))
+	if recorder.Code != http.StatusServiceUnavailable {
+		t.Errorf("status = %d, want %d", recorder.Code, http.StatusServiceUnavailable)
+	}
+}
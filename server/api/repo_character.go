@@ -2,6 +2,13 @@ package api
 
 import (
 	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	cfg "erupe-ce/config"
+	"erupe-ce/server/channelserver"
+	"erupe-ce/server/channelserver/compression/blobcomp"
 
 	"github.com/jmoiron/sqlx"
 )
@@ -83,5 +90,41 @@ func (r *APICharacterRepository) ExportSave(ctx context.Context, userID, charID
 	if err != nil {
 		return nil, err
 	}
+	// Exports carry blobs in the client's format whatever they are stored with.
+	for _, column := range channelserver.CompressedColumns {
+		if data, ok := result[column].([]byte); ok {
+			if result[column], err = blobcomp.ToClient(data); err != nil {
+				return nil, fmt.Errorf("%s: %w", column, err)
+			}
+		}
+	}
 	return result, nil
 }
+
+// APISaveRepository implements APISaveRepo through the channel server's save
+// path, so a restored save is checked and stored as one from the game is.
+type APISaveRepository struct {
+	db       *sqlx.DB
+	charRepo channelserver.CharacterRepo
+}
+
+// NewAPISaveRepository creates a new APISaveRepository storing saves with the
+// algorithm the compression options select.
+func NewAPISaveRepository(db *sqlx.DB, opts cfg.CompressionOptions) *APISaveRepository {
+	compression, _ := channelserver.CompressionFor(opts)
+	return &APISaveRepository{db: db, charRepo: channelserver.NewCharacterRepository(db).WithCompression(compression)}
+}
+
+// Store refuses characters bound to a sign session. Their save may be
+// cached by the channel server, which is only released at logout, before
+// the session is cleared, and would overwrite the stored one.
+func (r *APISaveRepository) Store(charID uint32, data []byte, mode cfg.Mode) error {
+	var online uint32
+	err := r.db.QueryRow(`SELECT char_id FROM sign_sessions WHERE char_id = $1 LIMIT 1`, charID).Scan(&online)
+	if err == nil {
+		return fmt.Errorf("%w: %d", channelserver.ErrCharacterOnline, charID)
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+	return channelserver.StoreCharacterSave(r.charRepo, charID, data, mode)
+}
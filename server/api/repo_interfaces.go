@@ -2,6 +2,11 @@ package api
 
 import (
 	"context"
+	cfg "erupe-ce/config"
+	"erupe-ce/server/analytics"
+	"erupe-ce/server/audit"
+	"erupe-ce/server/channelserver"
+	"erupe-ce/server/sessionlog"
 	"time"
 )
 
@@ -51,3 +56,42 @@ type APISessionRepo interface {
 	// GetUserIDByToken returns the user ID for a given session token.
 	GetUserIDByToken(ctx context.Context, token string) (uint32, error)
 }
+
+// APIAuditRepo defines the contract for the audit log of admin actions.
+type APIAuditRepo interface {
+	// Record appends a privileged action to the audit log.
+	Record(source, actor, action, target string, params any) error
+	// Query returns the audit log entries matching the filter, newest first.
+	Query(f audit.Filter) ([]audit.Entry, error)
+}
+
+// APISessionEventRepo defines the contract for reading session lifecycle
+// events.
+type APISessionEventRepo interface {
+	// Query returns the session events matching the filter, newest first.
+	Query(f sessionlog.Filter) ([]sessionlog.Event, error)
+}
+
+// APIAnalyticsRepo defines the contract for reading the daily economy and
+// activity rollups.
+type APIAnalyticsRepo interface {
+	// Query returns the rollups matching the filter, by day, metric and key.
+	Query(f analytics.Filter) ([]analytics.Row, error)
+}
+
+// APIItemBoxRepo defines the contract for moving warehouse item boxes
+// between characters.
+type APIItemBoxRepo interface {
+	// TransferItemBoxes moves or copies item box pages between two offline
+	// characters of the same account.
+	TransferItemBoxes(t channelserver.ItemBoxTransfer) ([]channelserver.ItemBoxPageResult, error)
+}
+
+// APISaveRepo defines the contract for writing character saves restored by
+// the admin endpoints.
+type APISaveRepo interface {
+	// Store validates a decompressed save written in mode and stores it for a
+	// character, failing with channelserver.ErrCharacterOnline while the
+	// character is online.
+	Store(charID uint32, data []byte, mode cfg.Mode) error
+}
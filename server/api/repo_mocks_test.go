@@ -2,7 +2,15 @@ package api
 
 import (
 	"context"
+	"encoding/json"
 	"time"
+
+	cfg "erupe-ce/config"
+	"erupe-ce/server/analytics"
+	"erupe-ce/server/audit"
+	"erupe-ce/server/channelserver"
+	"erupe-ce/server/sessionlog"
+	"erupe-ce/server/status"
 )
 
 // mockAPIUserRepo implements APIUserRepo for testing.
@@ -122,3 +130,87 @@ func (m *mockAPISessionRepo) CreateToken(_ context.Context, _ uint32, _ string)
 func (m *mockAPISessionRepo) GetUserIDByToken(_ context.Context, _ string) (uint32, error) {
 	return m.userID, m.userIDErr
 }
+
+// mockStatusSource implements status.Source for testing.
+type mockStatusSource struct {
+	worlds    []status.World
+	events    []status.Event
+	worldsErr error
+}
+
+func (m *mockStatusSource) Worlds() ([]status.World, error) {
+	return m.worlds, m.worldsErr
+}
+
+func (m *mockStatusSource) Events() ([]status.Event, error) {
+	return m.events, nil
+}
+
+// mockAPIAuditRepo implements APIAuditRepo for testing.
+type mockAPIAuditRepo struct {
+	recorded []audit.Entry
+	filter   audit.Filter
+	entries  []audit.Entry
+	queryErr error
+}
+
+func (m *mockAPIAuditRepo) Record(source, actor, action, target string, params any) error {
+	raw, _ := params.(json.RawMessage)
+	m.recorded = append(m.recorded, audit.Entry{Source: source, Actor: actor, Action: action, Target: target, Params: raw})
+	return nil
+}
+
+func (m *mockAPIAuditRepo) Query(f audit.Filter) ([]audit.Entry, error) {
+	m.filter = f
+	return m.entries, m.queryErr
+}
+
+// mockAPISessionEventRepo implements APISessionEventRepo for testing.
+type mockAPISessionEventRepo struct {
+	filter sessionlog.Filter
+	events []sessionlog.Event
+}
+
+func (m *mockAPISessionEventRepo) Query(f sessionlog.Filter) ([]sessionlog.Event, error) {
+	m.filter = f
+	return m.events, nil
+}
+
+// mockAPIAnalyticsRepo implements APIAnalyticsRepo for testing.
+type mockAPIAnalyticsRepo struct {
+	filter analytics.Filter
+	rows   []analytics.Row
+}
+
+func (m *mockAPIAnalyticsRepo) Query(f analytics.Filter) ([]analytics.Row, error) {
+	m.filter = f
+	return m.rows, nil
+}
+
+// mockAPISaveRepo implements APISaveRepo for testing.
+type mockAPISaveRepo struct {
+	charID uint32
+	data   []byte
+	mode   cfg.Mode
+	err    error
+}
+
+func (m *mockAPISaveRepo) Store(charID uint32, data []byte, mode cfg.Mode) error {
+	if m.err != nil {
+		return m.err
+	}
+	m.charID, m.data, m.mode = charID, data, mode
+	return nil
+}
+
+// mockAPIItemBoxRepo implements APIItemBoxRepo for testing.
+type mockAPIItemBoxRepo struct {
+	transfer channelserver.ItemBoxTransfer
+	results  []channelserver.ItemBoxPageResult
+	err      error
+}
+
+func (m *mockAPIItemBoxRepo) TransferItemBoxes(t channelserver.ItemBoxTransfer) ([]channelserver.ItemBoxPageResult, error) {
+	m.transfer = t
+	return m.results, m.err
+}
@@ -0,0 +1,112 @@
+package audit
+
+import (
+	"encoding/json"
+	"os/user"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// Sources of audited actions.
+const (
+	SourceGame    = "game"    // Chat commands
+	SourceDiscord = "discord" // Bans and timeouts mirrored from Discord
+	SourceAPI     = "api"     // Admin API calls
+	SourceWizard  = "wizard"  // Setup wizard
+	SourceCLI     = "cli"     // Command line tools run against the database
+)
+
+// CLIActor names the user running a command line tool, as the actor of its
+// SourceCLI entries. tool stands in when the user cannot be looked up.
+func CLIActor(tool string) string {
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+	return tool
+}
+
+// Entry is one audited action.
+type Entry struct {
+	ID      int64           `json:"id" db:"id"`
+	Source  string          `json:"source" db:"source"`
+	Actor   string          `json:"actor" db:"actor"`
+	Action  string          `json:"action" db:"action"`
+	Target  string          `json:"target" db:"target"`
+	Params  json.RawMessage `json:"params" db:"params"`
+	Created time.Time       `json:"created_at" db:"created_at"`
+}
+
+// Filter narrows a Query. Zero fields match everything.
+type Filter struct {
+	Source string
+	Actor  string
+	Action string
+	Since  time.Time
+	Before int64 // Only entries with a lower ID, for paging backwards
+	Limit  int
+}
+
+// DefaultLimit and MaxLimit bound the number of entries a Query returns.
+const (
+	DefaultLimit = 100
+	MaxLimit     = 1000
+)
+
+// Repository reads and appends to the audit_log table.
+type Repository struct {
+	db *sqlx.DB
+}
+
+// NewRepository creates a new Repository.
+func NewRepository(db *sqlx.DB) *Repository {
+	return &Repository{db: db}
+}
+
+// Record appends an action to the audit log. params is stored as JSON; nil
+// stores an empty object.
+func (r *Repository) Record(source, actor, action, target string, params any) error {
+	raw, err := marshalParams(params)
+	if err != nil {
+		return err
+	}
+	_, err = r.db.Exec(`INSERT INTO audit_log (source, actor, action, target, params) VALUES ($1, $2, $3, $4, $5)`,
+		source, actor, action, target, string(raw))
+	return err
+}
+
+// Query returns the entries matching f, newest first.
+func (r *Repository) Query(f Filter) ([]Entry, error) {
+	entries := []Entry{}
+	err := r.db.Select(&entries, `SELECT id, source, actor, action, target, params, created_at FROM audit_log
+		WHERE ($1 = '' OR source = $1) AND ($2 = '' OR actor = $2) AND ($3 = '' OR action = $3)
+		AND ($4::timestamptz IS NULL OR created_at >= $4) AND ($5 = 0 OR id < $5)
+		ORDER BY id DESC LIMIT $6`,
+		f.Source, f.Actor, f.Action, nullTime(f.Since), f.Before, clampLimit(f.Limit))
+	return entries, err
+}
+
+func marshalParams(params any) ([]byte, error) {
+	if raw, ok := params.(json.RawMessage); ok && len(raw) == 0 {
+		return []byte("{}"), nil
+	}
+	b, err := json.Marshal(params)
+	if err == nil && string(b) == "null" {
+		return []byte("{}"), nil
+	}
+	return b, err
+}
+
+func nullTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+func clampLimit(n int) int {
+	if n <= 0 {
+		return DefaultLimit
+	}
+	return min(n, MaxLimit)
+}
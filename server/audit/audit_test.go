@@ -0,0 +1,72 @@
+package audit
+
+import (
+	"encoding/json"
+	"os/user"
+	"testing"
+	"time"
+)
+
+func TestMarshalParams(t *testing.T) {
+	tests := []struct {
+		name   string
+		params any
+		want   string
+	}{
+		{"nil", nil, `{}`},
+		{"empty raw message", json.RawMessage(nil), `{}`},
+		{"nil map", map[string]int(nil), `{}`},
+		{"raw message", json.RawMessage(`{"quests":[1,2]}`), `{"quests":[1,2]}`},
+		{"map", map[string][]string{"args": {"211111", "30d"}}, `{"args":["211111","30d"]}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := marshalParams(tt.params)
+			if err != nil {
+				t.Fatalf("marshalParams() error = %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("marshalParams() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMarshalParams_InvalidRawMessage(t *testing.T) {
+	if _, err := marshalParams(json.RawMessage(`{`)); err == nil {
+		t.Error("marshalParams() accepted invalid JSON")
+	}
+}
+
+func TestClampLimit(t *testing.T) {
+	for _, tt := range []struct{ in, want int }{
+		{0, DefaultLimit},
+		{-5, DefaultLimit},
+		{10, 10},
+		{MaxLimit + 1, MaxLimit},
+	} {
+		if got := clampLimit(tt.in); got != tt.want {
+			t.Errorf("clampLimit(%d) = %d, want %d", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestNullTime(t *testing.T) {
+	if nullTime(time.Time{}) != nil {
+		t.Error("nullTime(zero) should be nil")
+	}
+	now := time.Now()
+	if got := nullTime(now); got == nil || !got.Equal(now) {
+		t.Errorf("nullTime(now) = %v, want %v", got, now)
+	}
+}
+
+func TestCLIActor(t *testing.T) {
+	want := "account"
+	if u, err := user.Current(); err == nil {
+		want = u.Username
+	}
+	if got := CLIActor("account"); got != want {
+		t.Errorf("CLIActor() = %q, want %q", got, want)
+	}
+}
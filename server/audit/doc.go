@@ -0,0 +1,5 @@
+// Package audit records privileged actions (operator chat commands, bans
+// and mutes, admin API calls and setup wizard database operations) in the
+// append-only audit_log table, and reads them back for the API's audit
+// endpoint.
+package audit
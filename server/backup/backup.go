@@ -0,0 +1,458 @@
+package backup
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	cfg "erupe-ce/config"
+	"erupe-ce/server/scheduler"
+
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+)
+
+const (
+	archivePrefix = "erupe-backup-"
+	archiveSuffix = ".tar.gz"
+	// archiveTime is the layout of the time in an archive's name, which sorts
+	// archives oldest first.
+	archiveTime  = "20060102-150405"
+	manifestName = "manifest.json"
+	recordDir    = "characters/"
+)
+
+// table is a table backed up per character. Only the listed columns are
+// archived and restored; key holds the character ID.
+type table struct {
+	name    string
+	key     string
+	columns []string
+}
+
+// tables are the rows that make up a character, in restore order.
+var tables = []table{
+	{"characters", "id", []string{
+		"name", "is_female", "hr", "gr", "weapon_type", "weapon_id", "time_played",
+		"savedata", "savedata_mode", "savedata_checksum", "decomyset", "hunternavi",
+		"otomoairou", "partner", "platebox", "platedata", "platemyset", "rengokudata",
+		"savemercenary", "scenariodata", "savefavoritequest", "gacha_items",
+		"house_info", "login_boost", "skin_hist", "mezfes",
+	}},
+	{"user_binary", "id", []string{
+		"house_tier", "house_state", "house_password", "house_data", "house_furniture",
+		"bookshelf", "gallery", "tore", "garden", "mission",
+	}},
+	{"warehouse", "character_id", warehouseColumns()},
+}
+
+func warehouseColumns() []string {
+	var columns []string
+	for _, kind := range []string{"item", "equip"} {
+		for i := 0; i <= 10; i++ {
+			columns = append(columns, fmt.Sprintf("%s%d", kind, i))
+		}
+		for i := 0; i < 10; i++ {
+			columns = append(columns, fmt.Sprintf("%s%dname", kind, i))
+		}
+	}
+	return columns
+}
+
+// Record is one character in an archive: its row of each backed up table,
+// by table then column. Tables the character has no row in are left out.
+type Record struct {
+	CharID uint32                    `json:"char_id"`
+	Tables map[string]map[string]any `json:"tables"`
+}
+
+// Manifest describes an archive. It is the archive's last entry, so an
+// archive without one was cut short.
+type Manifest struct {
+	Created    time.Time `json:"created"`
+	Characters int       `json:"characters"`
+}
+
+// Backup writes and restores archives of every character.
+type Backup struct {
+	db        *sqlx.DB
+	store     Store
+	retention int
+	logger    *zap.Logger
+	now       func() time.Time
+}
+
+// New creates a Backup storing archives where opts says: in the S3 bucket
+// when one is set, else in the directory.
+func New(db *sqlx.DB, opts cfg.BackupOptions, logger *zap.Logger) (*Backup, error) {
+	var store Store
+	if opts.S3.Bucket != "" {
+		s3, err := newS3Store(opts.S3)
+		if err != nil {
+			return nil, err
+		}
+		store = s3
+	} else {
+		if opts.Dir == "" {
+			return nil, errors.New("backup needs a Dir or an S3 bucket")
+		}
+		store = dirStore{dir: opts.Dir}
+	}
+	return &Backup{db: db, store: store, retention: opts.Retention, logger: logger, now: time.Now}, nil
+}
+
+// Run archives every character that has not been deleted, then deletes the
+// archives past the retention. It returns the new archive's name and the
+// number of characters in it.
+func (b *Backup) Run() (string, int, error) {
+	var ids []uint32
+	if err := b.db.Select(&ids, "SELECT id FROM characters WHERE deleted = false ORDER BY id"); err != nil {
+		return "", 0, err
+	}
+
+	f, err := os.CreateTemp("", archivePrefix+"*"+archiveSuffix)
+	if err != nil {
+		return "", 0, err
+	}
+	defer func() {
+		_ = f.Close()
+		_ = os.Remove(f.Name())
+	}()
+	created := b.now().UTC()
+	if err := b.write(f, ids, created); err != nil {
+		return "", 0, err
+	}
+	size, err := f.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return "", 0, err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return "", 0, err
+	}
+	name := archivePrefix + created.Format(archiveTime) + archiveSuffix
+	if err := b.store.Put(name, f, size); err != nil {
+		return "", 0, fmt.Errorf("store %s: %w", name, err)
+	}
+	if err := b.prune(); err != nil {
+		b.logger.Warn("Failed to delete old backups", zap.Error(err))
+	}
+	return name, len(ids), nil
+}
+
+// write writes an archive of the characters to w.
+func (b *Backup) write(w io.Writer, ids []uint32, created time.Time) error {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	for _, id := range ids {
+		rec, err := b.export(id)
+		if err != nil {
+			return fmt.Errorf("character %d: %w", id, err)
+		}
+		if err := writeEntry(tw, recordDir+strconv.FormatUint(uint64(id), 10)+".json", rec, created); err != nil {
+			return err
+		}
+	}
+	if err := writeEntry(tw, manifestName, Manifest{Created: created, Characters: len(ids)}, created); err != nil {
+		return err
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+func writeEntry(tw *tar.Writer, name string, v any, modTime time.Time) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	hdr := &tar.Header{Name: name, Mode: 0644, Size: int64(len(data)), ModTime: modTime, Typeflag: tar.TypeReg}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	_, err = tw.Write(data)
+	return err
+}
+
+// export reads a character's rows.
+func (b *Backup) export(charID uint32) (Record, error) {
+	rec := Record{CharID: charID, Tables: make(map[string]map[string]any)}
+	for _, t := range tables {
+		rows, err := b.db.Queryx(fmt.Sprintf("SELECT %s FROM %s WHERE %s = $1",
+			strings.Join(t.columns, ", "), t.name, t.key), charID)
+		if err != nil {
+			return rec, fmt.Errorf("%s: %w", t.name, err)
+		}
+		if rows.Next() {
+			row := make(map[string]any, len(t.columns))
+			if err := rows.MapScan(row); err != nil {
+				_ = rows.Close()
+				return rec, fmt.Errorf("%s: %w", t.name, err)
+			}
+			// Only bytea columns are blobs; other values the driver hands
+			// back as bytes are text.
+			columnTypes, err := rows.ColumnTypes()
+			if err != nil {
+				_ = rows.Close()
+				return rec, err
+			}
+			for _, ct := range columnTypes {
+				if v, ok := row[ct.Name()].([]byte); ok && ct.DatabaseTypeName() != "BYTEA" {
+					row[ct.Name()] = string(v)
+				}
+			}
+			rec.Tables[t.name] = row
+		}
+		if err := rows.Close(); err != nil {
+			return rec, err
+		}
+	}
+	return rec, nil
+}
+
+// Archives returns the names of the stored archives, oldest first.
+func (b *Backup) Archives() ([]string, error) {
+	names, err := b.store.List()
+	if err != nil {
+		return nil, err
+	}
+	slices.Sort(names)
+	return names, nil
+}
+
+// prune deletes the oldest archives past the retention.
+func (b *Backup) prune() error {
+	if b.retention <= 0 {
+		return nil
+	}
+	names, err := b.Archives()
+	if err != nil {
+		return err
+	}
+	for len(names) > b.retention {
+		if err := b.store.Delete(names[0]); err != nil {
+			return fmt.Errorf("delete %s: %w", names[0], err)
+		}
+		b.logger.Debug("Deleted old backup", zap.String("archive", names[0]))
+		names = names[1:]
+	}
+	return nil
+}
+
+// Read returns the records of an archive, with only the characters in
+// charIDs when it is not empty.
+func (b *Backup) Read(name string, charIDs []uint32) ([]Record, Manifest, error) {
+	rc, err := b.store.Get(name)
+	if err != nil {
+		return nil, Manifest{}, err
+	}
+	defer func() { _ = rc.Close() }()
+	return readArchive(rc, charIDs)
+}
+
+func readArchive(r io.Reader, charIDs []uint32) ([]Record, Manifest, error) {
+	var manifest Manifest
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, manifest, err
+	}
+	tr := tar.NewReader(gz)
+	var records []Record
+	found := false
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return nil, manifest, err
+		}
+		dec := json.NewDecoder(tr)
+		// Numbers stay as written, so large IDs and checksums survive.
+		dec.UseNumber()
+		switch {
+		case hdr.Name == manifestName:
+			if err := dec.Decode(&manifest); err != nil {
+				return nil, manifest, fmt.Errorf("%s: %w", hdr.Name, err)
+			}
+			found = true
+		case strings.HasPrefix(hdr.Name, recordDir):
+			id, err := strconv.ParseUint(strings.TrimSuffix(path.Base(hdr.Name), ".json"), 10, 32)
+			if err != nil {
+				return nil, manifest, fmt.Errorf("%s: bad character ID", hdr.Name)
+			}
+			if len(charIDs) > 0 && !slices.Contains(charIDs, uint32(id)) {
+				continue
+			}
+			var rec Record
+			if err := dec.Decode(&rec); err != nil {
+				return nil, manifest, fmt.Errorf("%s: %w", hdr.Name, err)
+			}
+			records = append(records, rec)
+		}
+	}
+	if !found {
+		return nil, manifest, errors.New("archive has no manifest, it was cut short")
+	}
+	return records, manifest, nil
+}
+
+// Restore writes the records' rows back in one transaction. A character whose
+// characters row no longer exists is skipped, so a restore never creates
+// characters. It returns the IDs of the characters restored.
+//
+// The characters must not be logged in, or their next save overwrites the
+// restore.
+func (b *Backup) Restore(records []Record) ([]uint32, error) {
+	tx, err := b.db.Beginx()
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	types := make(map[string]map[string]string, len(tables))
+	for _, t := range tables {
+		if types[t.name], err = columnTypes(tx, t.name); err != nil {
+			return nil, err
+		}
+	}
+	var restored []uint32
+	for _, rec := range records {
+		ok, err := restoreRecord(tx, rec, types)
+		if err != nil {
+			return nil, fmt.Errorf("character %d: %w", rec.CharID, err)
+		}
+		if ok {
+			restored = append(restored, rec.CharID)
+		} else {
+			b.logger.Warn("Skipped restoring a character that no longer exists", zap.Uint32("charID", rec.CharID))
+		}
+	}
+	return restored, tx.Commit()
+}
+
+// columnTypes returns the data type of each column of a table.
+func columnTypes(tx *sqlx.Tx, name string) (map[string]string, error) {
+	rows, err := tx.Query(`SELECT column_name, data_type FROM information_schema.columns
+		WHERE table_schema = current_schema() AND table_name = $1`, name)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+	types := make(map[string]string)
+	for rows.Next() {
+		var column, dataType string
+		if err := rows.Scan(&column, &dataType); err != nil {
+			return nil, err
+		}
+		types[column] = dataType
+	}
+	return types, rows.Err()
+}
+
+// restoreRecord writes one character's rows, reporting false when the
+// character no longer exists.
+func restoreRecord(tx *sqlx.Tx, rec Record, types map[string]map[string]string) (bool, error) {
+	for i, t := range tables {
+		row, ok := rec.Tables[t.name]
+		if !ok {
+			continue
+		}
+		var columns, values []string
+		args := []any{rec.CharID}
+		for _, column := range t.columns {
+			v, ok := row[column]
+			if !ok {
+				continue
+			}
+			args = append(args, v)
+			placeholder := "$" + strconv.Itoa(len(args))
+			// JSON carries blobs as base64.
+			if types[t.name][column] == "bytea" {
+				placeholder = "decode(" + placeholder + ", 'base64')"
+			}
+			columns = append(columns, column)
+			values = append(values, placeholder)
+		}
+		if len(columns) == 0 {
+			continue
+		}
+		if i == 0 {
+			sets := make([]string, len(columns))
+			for j := range columns {
+				sets[j] = columns[j] + " = " + values[j]
+			}
+			res, err := tx.Exec(fmt.Sprintf("UPDATE %s SET %s WHERE %s = $1",
+				t.name, strings.Join(sets, ", "), t.key), args...)
+			if err != nil {
+				return false, fmt.Errorf("%s: %w", t.name, err)
+			}
+			if n, err := res.RowsAffected(); err != nil {
+				return false, err
+			} else if n == 0 {
+				return false, nil
+			}
+			continue
+		}
+		sets := make([]string, len(columns))
+		for j, column := range columns {
+			sets[j] = column + " = EXCLUDED." + column
+		}
+		_, err := tx.Exec(fmt.Sprintf("INSERT INTO %s (%s, %s) VALUES ($1, %s) ON CONFLICT (%s) DO UPDATE SET %s",
+			t.name, t.key, strings.Join(columns, ", "), strings.Join(values, ", "), t.key, strings.Join(sets, ", ")), args...)
+		if err != nil {
+			return false, fmt.Errorf("%s: %w", t.name, err)
+		}
+	}
+	return true, nil
+}
+
+// Job returns the scheduler job that archives every character, by default
+// every interval. Backups count from the newest stored archive, so the first
+// runs at once when there is none.
+func (b *Backup) Job(interval time.Duration) scheduler.Job {
+	return scheduler.Job{
+		Name:     "backup",
+		Schedule: scheduler.Every(interval).String(),
+		Run: func(context.Context) error {
+			start := b.now()
+			name, n, err := b.Run()
+			if err != nil {
+				return err
+			}
+			b.logger.Info("Backed up characters", zap.String("archive", name),
+				zap.Int("characters", n), zap.Duration("took", b.now().Sub(start)))
+			return nil
+		},
+		LastRun: b.LastArchive,
+	}
+}
+
+// LastArchive returns when the newest stored archive was created, or the
+// zero time if there is none.
+func (b *Backup) LastArchive() time.Time {
+	names, err := b.Archives()
+	if err != nil || len(names) == 0 {
+		return time.Time{}
+	}
+	last, err := archiveCreated(names[len(names)-1])
+	if err != nil {
+		return time.Time{}
+	}
+	return last
+}
+
+// archiveCreated parses the time in an archive's name.
+func archiveCreated(name string) (time.Time, error) {
+	s := strings.TrimSuffix(strings.TrimPrefix(name, archivePrefix), archiveSuffix)
+	return time.Parse(archiveTime, s)
+}
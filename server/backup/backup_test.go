@@ -0,0 +1,139 @@
+package backup
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// testArchive builds an archive of records, with a manifest when asked.
+func testArchive(t *testing.T, records []Record, manifest bool) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	created := time.Date(2026, 10, 17, 3, 0, 0, 0, time.UTC)
+	for _, rec := range records {
+		name := recordDir + strconv.FormatUint(uint64(rec.CharID), 10) + ".json"
+		if err := writeEntry(tw, name, rec, created); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if manifest {
+		if err := writeEntry(tw, manifestName, Manifest{Created: created, Characters: len(records)}, created); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_ = tw.Close()
+	_ = gz.Close()
+	return buf.Bytes()
+}
+
+func TestReadArchive(t *testing.T) {
+	records := []Record{
+		{CharID: 12, Tables: map[string]map[string]any{
+			"characters": {"name": "Hunter", "savedata": []byte{1, 2, 3}, "savedata_checksum": int64(1) << 40},
+		}},
+		{CharID: 40, Tables: map[string]map[string]any{
+			"warehouse": {"item0": []byte{9}},
+		}},
+	}
+	data := testArchive(t, records, true)
+
+	got, manifest, err := readArchive(bytes.NewReader(data), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if manifest.Characters != 2 || len(got) != 2 {
+		t.Fatalf("manifest %+v, %d records", manifest, len(got))
+	}
+	row := got[0].Tables["characters"]
+	// Blobs come back as base64 for the restore to decode, large numbers intact.
+	if row["name"] != "Hunter" || row["savedata"] != "AQID" || row["savedata_checksum"] != json.Number("1099511627776") {
+		t.Errorf("characters row = %v", row)
+	}
+
+	got, _, err = readArchive(bytes.NewReader(data), []uint32{40})
+	if err != nil || len(got) != 1 || got[0].CharID != 40 {
+		t.Errorf("filtered to 40: %v, err %v", got, err)
+	}
+}
+
+func TestReadArchive_NoManifest(t *testing.T) {
+	data := testArchive(t, []Record{{CharID: 12}}, false)
+	if _, _, err := readArchive(bytes.NewReader(data), nil); err == nil {
+		t.Error("archive cut short before its manifest was read")
+	}
+}
+
+func TestWarehouseColumns(t *testing.T) {
+	columns := warehouseColumns()
+	for _, want := range []string{"item0", "item10", "item9name", "equip10", "equip0name"} {
+		if !slices.Contains(columns, want) {
+			t.Errorf("warehouse columns miss %s", want)
+		}
+	}
+	if slices.Contains(columns, "item10name") || len(columns) != 42 {
+		t.Errorf("warehouse columns = %v", columns)
+	}
+}
+
+func TestPruneAndSchedule(t *testing.T) {
+	dir := t.TempDir()
+	store := dirStore{dir: dir}
+	now := time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)
+	b := &Backup{store: store, retention: 2, logger: zap.NewNop(), now: func() time.Time { return now }}
+
+	if last := b.LastArchive(); !last.IsZero() {
+		t.Errorf("LastArchive() = %v with no archives", last)
+	}
+	for _, name := range []string{
+		"erupe-backup-20261014-030000.tar.gz",
+		"erupe-backup-20261016-030000.tar.gz",
+		"erupe-backup-20261015-030000.tar.gz",
+		"notes.txt",
+	} {
+		if err := store.Put(name, strings.NewReader("x"), 1); err != nil && name != "notes.txt" {
+			t.Fatal(err)
+		}
+	}
+	if err := b.prune(); err != nil {
+		t.Fatal(err)
+	}
+	names, err := b.Archives()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"erupe-backup-20261015-030000.tar.gz", "erupe-backup-20261016-030000.tar.gz"}
+	if !slices.Equal(names, want) {
+		t.Errorf("after prune = %v, want %v", names, want)
+	}
+	if last := b.LastArchive(); !last.Equal(time.Date(2026, 10, 16, 3, 0, 0, 0, time.UTC)) {
+		t.Errorf("LastArchive() = %v, want the newest archive's time", last)
+	}
+	if job := b.Job(24 * time.Hour); job.Name != "backup" || job.Schedule != "@every 24h" {
+		t.Errorf("Job() = %s on %q", job.Name, job.Schedule)
+	}
+
+	rc, err := store.Get(want[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := io.ReadAll(rc)
+	_ = rc.Close()
+	if string(data) != "x" {
+		t.Errorf("Get = %q", data)
+	}
+	if _, err := store.Get("../config.json"); err != ErrNotFound {
+		t.Errorf("Get outside the directory: err = %v", err)
+	}
+}
@@ -0,0 +1,8 @@
+// Package backup archives every character's saves and related rows on a
+// schedule, to a local directory or an S3-compatible bucket, and restores
+// characters from those archives.
+//
+// An archive is a gzipped tar holding one JSON record per character, with
+// the character's row of each backed up table, and a manifest. Blobs are kept
+// exactly as stored, whatever compression they use.
+package backup
@@ -0,0 +1,248 @@
+package backup
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	cfg "erupe-ce/config"
+)
+
+// s3Store keeps archives in an S3-compatible bucket, addressed path-style so
+// it works with MinIO and other self-hosted services as well as AWS. Requests
+// are signed with AWS Signature Version 4.
+type s3Store struct {
+	endpoint  *url.URL
+	region    string
+	bucket    string
+	prefix    string
+	accessKey string
+	secretKey string
+	client    *http.Client
+	now       func() time.Time
+}
+
+func newS3Store(opts cfg.BackupS3Options) (*s3Store, error) {
+	if opts.Endpoint == "" {
+		return nil, errors.New("backup S3 bucket needs an Endpoint")
+	}
+	endpoint, err := url.Parse(strings.TrimSuffix(opts.Endpoint, "/"))
+	if err != nil || endpoint.Host == "" {
+		return nil, fmt.Errorf("bad backup S3 Endpoint %q", opts.Endpoint)
+	}
+	s := &s3Store{
+		endpoint:  endpoint,
+		region:    opts.Region,
+		bucket:    opts.Bucket,
+		prefix:    opts.Prefix,
+		accessKey: opts.AccessKey,
+		secretKey: opts.SecretKey,
+		client:    &http.Client{Timeout: 30 * time.Minute},
+		now:       time.Now,
+	}
+	if s.accessKey == "" {
+		s.accessKey = os.Getenv("AWS_ACCESS_KEY_ID")
+	}
+	if s.secretKey == "" {
+		s.secretKey = os.Getenv("AWS_SECRET_ACCESS_KEY")
+	}
+	if s.accessKey == "" || s.secretKey == "" {
+		return nil, errors.New("backup S3 bucket needs an AccessKey and SecretKey")
+	}
+	if s.region == "" {
+		s.region = "us-east-1"
+	}
+	return s, nil
+}
+
+func (s *s3Store) Put(name string, r io.Reader, size int64) error {
+	resp, err := s.do(http.MethodPut, s.prefix+name, nil, r, size)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+func (s *s3Store) Get(name string) (io.ReadCloser, error) {
+	resp, err := s.do(http.MethodGet, s.prefix+name, nil, nil, 0)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+func (s *s3Store) Delete(name string) error {
+	resp, err := s.do(http.MethodDelete, s.prefix+name, nil, nil, 0)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+// listResult is the part of a ListObjectsV2 response List reads.
+type listResult struct {
+	Contents []struct {
+		Key string
+	}
+	IsTruncated           bool
+	NextContinuationToken string
+}
+
+func (s *s3Store) List() ([]string, error) {
+	var names []string
+	query := url.Values{"list-type": {"2"}, "prefix": {s.prefix + archivePrefix}}
+	for {
+		resp, err := s.do(http.MethodGet, "", query, nil, 0)
+		if err != nil {
+			return nil, err
+		}
+		var page listResult
+		err = xml.NewDecoder(resp.Body).Decode(&page)
+		_ = resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("list bucket: %w", err)
+		}
+		for _, c := range page.Contents {
+			if name := strings.TrimPrefix(c.Key, s.prefix); isArchiveName(name) {
+				names = append(names, name)
+			}
+		}
+		if !page.IsTruncated || page.NextContinuationToken == "" {
+			return names, nil
+		}
+		query.Set("continuation-token", page.NextContinuationToken)
+	}
+}
+
+// do sends a signed request for key in the bucket, or for the bucket itself
+// when key is empty. A response that is not a success is returned as an error
+// and a missing key as ErrNotFound.
+func (s *s3Store) do(method, key string, query url.Values, body io.Reader, size int64) (*http.Response, error) {
+	u := *s.endpoint
+	u.Path = s.endpoint.Path + "/" + s.bucket
+	if key != "" {
+		u.Path += "/" + key
+	}
+	u.RawQuery = canonicalQuery(query)
+	req, err := http.NewRequest(method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.ContentLength = size
+	}
+	// Archives are streamed from disk, so the payload is not hashed.
+	s.sign(req, "UNSIGNED-PAYLOAD")
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 == 2 {
+		return resp, nil
+	}
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	_ = resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound && key != "" {
+		return nil, ErrNotFound
+	}
+	return nil, fmt.Errorf("S3 %s %s: %s: %s", method, u.Path, resp.Status, strings.TrimSpace(string(msg)))
+}
+
+// sign adds the Signature Version 4 Authorization header to req, signing
+// every header already set on it along with the host and date.
+func (s *s3Store) sign(req *http.Request, payloadHash string) {
+	now := s.now().UTC()
+	amzDate := now.Format("20060102T150405Z")
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		uriEncode(req.URL.Path, false),
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	scope := now.Format("20060102") + "/" + s.region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hexSHA256(canonicalRequest)
+
+	key := hmacSHA256([]byte("AWS4"+s.secretKey), now.Format("20060102"))
+	key = hmacSHA256(key, s.region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+s.accessKey+"/"+scope+
+		", SignedHeaders="+signedHeaders+", Signature="+signature)
+}
+
+// canonicalQuery encodes query sorted by key as Signature Version 4 expects,
+// which is also a valid query string.
+func canonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	var parts []string
+	for _, k := range keys {
+		for _, v := range query[k] {
+			parts = append(parts, uriEncode(k, true)+"="+uriEncode(v, true))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// uriEncode percent-encodes every byte but the unreserved characters, and
+// slashes too when encodeSlash is set.
+func uriEncode(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~', c == '/' && !encodeSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+func hexSHA256(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,102 @@
+package backup
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ErrNotFound is returned when an archive does not exist.
+var ErrNotFound = errors.New("archive not found")
+
+// Store keeps archives by name.
+type Store interface {
+	// Put writes an archive of size bytes read from r.
+	Put(name string, r io.Reader, size int64) error
+	// Get opens an archive for reading.
+	Get(name string) (io.ReadCloser, error)
+	// List returns the names of the archives, in any order.
+	List() ([]string, error)
+	// Delete removes an archive.
+	Delete(name string) error
+}
+
+// dirStore keeps archives as files in a directory.
+type dirStore struct {
+	dir string
+}
+
+func (s dirStore) path(name string) (string, error) {
+	if name != filepath.Base(name) || !isArchiveName(name) {
+		return "", ErrNotFound
+	}
+	return filepath.Join(s.dir, name), nil
+}
+
+func (s dirStore) Put(name string, r io.Reader, _ int64) error {
+	if err := os.MkdirAll(s.dir, 0755); err != nil {
+		return err
+	}
+	path, err := s.path(name)
+	if err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(s.dir, name+".*.tmp")
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(f, r)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), path)
+	}
+	if err != nil {
+		_ = os.Remove(f.Name())
+	}
+	return err
+}
+
+func (s dirStore) Get(name string) (io.ReadCloser, error) {
+	path, err := s.path(name)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	return f, err
+}
+
+func (s dirStore) List() ([]string, error) {
+	entries, err := os.ReadDir(s.dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, e := range entries {
+		if !e.IsDir() && isArchiveName(e.Name()) {
+			names = append(names, e.Name())
+		}
+	}
+	return names, nil
+}
+
+func (s dirStore) Delete(name string) error {
+	path, err := s.path(name)
+	if err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
+
+// isArchiveName reports whether name is an archive's name.
+func isArchiveName(name string) bool {
+	return strings.HasPrefix(name, archivePrefix) && strings.HasSuffix(name, archiveSuffix)
+}
@@ -149,15 +149,13 @@ func TestChannelIsolation_CrossChannelRegistryAfterShutdown(t *testing.T) {
 		conn := &mockConn{}
 		sess := createTestSessionForServer(ch, conn, uint32(i+1), "Player")
 		sess.stage = NewStage("sl1Ns200p0a0u0")
-		ch.Lock()
-		ch.sessions[conn] = sess
-		ch.Unlock()
+		ch.sessions.Store(conn, sess)
 	}
 
 	// Simulate channel 1 shutting down by marking it and clearing sessions.
 	channels[0].Lock()
 	channels[0].isShuttingDown = true
-	channels[0].sessions = make(map[net.Conn]*Session)
+	channels[0].sessions = SessionMap{}
 	channels[0].Unlock()
 
 	// Registry operations should still work for remaining channels.
@@ -7,7 +7,8 @@ import (
 
 // ChannelRegistry abstracts cross-channel operations behind an interface.
 // The default LocalChannelRegistry wraps the in-process []*Server slice.
-// Future implementations may use DB/Redis/NATS for multi-process deployments.
+// ClusterChannelRegistry adds the channels of other processes, reached over
+// the cluster bus.
 type ChannelRegistry interface {
 	// Worldcast broadcasts a packet to all sessions across all channels.
 	Worldcast(pkt mhfpacket.MHFPacket, ignoredSession *Session, ignoredChannel *Server)
@@ -0,0 +1,210 @@
+package channelserver
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"time"
+
+	"erupe-ce/network/clientctx"
+	"erupe-ce/network/mhfpacket"
+	"erupe-ce/server/cluster"
+)
+
+// clusterRequestTimeout bounds how long a handler waits on the other
+// processes, since the client waits for its answer.
+const clusterRequestTimeout = 500 * time.Millisecond
+
+// Cluster bus message kinds.
+const (
+	clusterWorldcast  = "worldcast"
+	clusterDisconnect = "disconnect"
+	clusterMail       = "mail"
+	clusterFindStage  = "find_stage"
+	clusterSessions   = "sessions"
+	clusterStages     = "stages"
+)
+
+// clusterMailNotice is a mail notification for a character on another node.
+type clusterMailNotice struct {
+	CharID     uint32
+	SenderID   uint32
+	SenderName string
+}
+
+// clusterStageSearch is a stage search on another node.
+type clusterStageSearch struct {
+	Prefix string
+	Max    int
+}
+
+// ClusterChannelRegistry is the ChannelRegistry of a process running some
+// of the channels, reaching the channels of other processes over the
+// cluster bus. FindSessionByCharID only finds sessions of this process,
+// since a session cannot leave it.
+type ClusterChannelRegistry struct {
+	*LocalChannelRegistry
+	bus *cluster.Bus
+	ctx *clientctx.ClientContext
+}
+
+// NewClusterChannelRegistry creates a ClusterChannelRegistry for the
+// channels of this process, and serves the requests of other processes
+// from them.
+func NewClusterChannelRegistry(channels []*Server, bus *cluster.Bus) *ClusterChannelRegistry {
+	r := &ClusterChannelRegistry{
+		LocalChannelRegistry: NewLocalChannelRegistry(channels),
+		bus:                  bus,
+		ctx:                  &clientctx.ClientContext{},
+	}
+	if len(channels) > 0 {
+		r.ctx.RealClientMode = channels[0].erupeConfig.RealClientMode
+	}
+	bus.Handle(clusterWorldcast, r.serveWorldcast)
+	bus.Handle(clusterDisconnect, r.serveDisconnect)
+	bus.Handle(clusterMail, r.serveMail)
+	bus.Handle(clusterFindStage, r.serveFindStage)
+	bus.Handle(clusterSessions, r.serveSessions)
+	bus.Handle(clusterStages, r.serveStages)
+	return r
+}
+
+func (r *ClusterChannelRegistry) Worldcast(pkt mhfpacket.MHFPacket, ignoredSession *Session, ignoredChannel *Server) {
+	r.LocalChannelRegistry.Worldcast(pkt, ignoredSession, ignoredChannel)
+	_ = r.bus.Publish(clusterWorldcast, buildPacket(pkt, r.ctx))
+}
+
+func (r *ClusterChannelRegistry) DisconnectUser(cids []uint32) {
+	r.LocalChannelRegistry.DisconnectUser(cids)
+	_ = r.bus.Publish(clusterDisconnect, cids)
+}
+
+func (r *ClusterChannelRegistry) FindChannelForStage(stageSuffix string) string {
+	if gid := r.LocalChannelRegistry.FindChannelForStage(stageSuffix); gid != "" {
+		return gid
+	}
+	for _, reply := range r.request(clusterFindStage, stageSuffix) {
+		var gid string
+		if json.Unmarshal(reply, &gid) == nil && gid != "" {
+			return gid
+		}
+	}
+	return ""
+}
+
+func (r *ClusterChannelRegistry) SearchSessions(predicate func(SessionSnapshot) bool, max int) []SessionSnapshot {
+	results := r.LocalChannelRegistry.SearchSessions(predicate, max)
+	for _, reply := range r.request(clusterSessions, nil) {
+		var snaps []SessionSnapshot
+		if json.Unmarshal(reply, &snaps) != nil {
+			continue
+		}
+		for _, snap := range snaps {
+			if len(results) >= max {
+				return results
+			}
+			snap.ServerIP = snap.ServerIP.To4()
+			if predicate(snap) {
+				results = append(results, snap)
+			}
+		}
+	}
+	return results
+}
+
+func (r *ClusterChannelRegistry) SearchStages(stagePrefix string, max int) []StageSnapshot {
+	results := r.LocalChannelRegistry.SearchStages(stagePrefix, max)
+	if len(results) >= max {
+		return results
+	}
+	for _, reply := range r.request(clusterStages, clusterStageSearch{Prefix: stagePrefix, Max: max - len(results)}) {
+		var snaps []StageSnapshot
+		if json.Unmarshal(reply, &snaps) != nil {
+			continue
+		}
+		for _, snap := range snaps {
+			if len(results) >= max {
+				return results
+			}
+			snap.ServerIP = snap.ServerIP.To4()
+			results = append(results, snap)
+		}
+	}
+	return results
+}
+
+func (r *ClusterChannelRegistry) NotifyMailToCharID(charID uint32, sender *Session, mail *Mail) {
+	if session := r.FindSessionByCharID(charID); session != nil {
+		SendMailNotification(sender, mail, session)
+		return
+	}
+	_ = r.bus.Publish(clusterMail, clusterMailNotice{
+		CharID:     charID,
+		SenderID:   mail.SenderID,
+		SenderName: getCharacterName(sender, mail.SenderID),
+	})
+}
+
+// request asks the other processes and returns their replies.
+func (r *ClusterChannelRegistry) request(kind string, body any) []json.RawMessage {
+	ctx, cancel := context.WithTimeout(context.Background(), clusterRequestTimeout)
+	defer cancel()
+	replies, _ := r.bus.Request(ctx, kind, body)
+	return replies
+}
+
+func (r *ClusterChannelRegistry) serveWorldcast(_ string, body json.RawMessage) (any, error) {
+	var data []byte
+	if err := json.Unmarshal(body, &data); err != nil {
+		return nil, err
+	}
+	// The buffer is shared by every recipient, as in broadcastTo.
+	data = data[:len(data):len(data)]
+	for _, c := range r.channels {
+		enqueueBroadcast(c.sessions.Snapshot(), nil, data, 0)
+	}
+	return nil, nil
+}
+
+func (r *ClusterChannelRegistry) serveDisconnect(_ string, body json.RawMessage) (any, error) {
+	var cids []uint32
+	if err := json.Unmarshal(body, &cids); err != nil {
+		return nil, err
+	}
+	r.LocalChannelRegistry.DisconnectUser(cids)
+	return nil, nil
+}
+
+func (r *ClusterChannelRegistry) serveMail(_ string, body json.RawMessage) (any, error) {
+	var notice clusterMailNotice
+	if err := json.Unmarshal(body, &notice); err != nil {
+		return nil, err
+	}
+	if session := r.FindSessionByCharID(notice.CharID); session != nil {
+		session.QueueSendMHFNonBlocking(mailNotification(notice.SenderID, notice.SenderName))
+	}
+	return nil, nil
+}
+
+func (r *ClusterChannelRegistry) serveFindStage(_ string, body json.RawMessage) (any, error) {
+	var suffix string
+	if err := json.Unmarshal(body, &suffix); err != nil {
+		return nil, err
+	}
+	return r.LocalChannelRegistry.FindChannelForStage(suffix), nil
+}
+
+// serveSessions returns every session, as the predicate of the search
+// cannot be sent and is applied by the asking process.
+func (r *ClusterChannelRegistry) serveSessions(string, json.RawMessage) (any, error) {
+	all := func(SessionSnapshot) bool { return true }
+	return r.LocalChannelRegistry.SearchSessions(all, math.MaxInt), nil
+}
+
+func (r *ClusterChannelRegistry) serveStages(_ string, body json.RawMessage) (any, error) {
+	var search clusterStageSearch
+	if err := json.Unmarshal(body, &search); err != nil {
+		return nil, err
+	}
+	return r.LocalChannelRegistry.SearchStages(search.Prefix, search.Max), nil
+}
@@ -0,0 +1,95 @@
+package channelserver
+
+import (
+	"testing"
+	"time"
+
+	"erupe-ce/network/mhfpacket"
+	"erupe-ce/server/cluster"
+)
+
+// createTestCluster returns the registries of two processes, each running
+// one channel, connected over the cluster bus.
+func createTestCluster(t *testing.T) (*ClusterChannelRegistry, *ClusterChannelRegistry) {
+	t.Helper()
+	var regs []*ClusterChannelRegistry
+	var buses []*cluster.Bus
+	for i, node := range []string{"a", "b"} {
+		bus := cluster.New(node, "secret", nil)
+		t.Cleanup(bus.Close)
+		if err := bus.Listen("127.0.0.1:0"); err != nil {
+			t.Fatal(err)
+		}
+		channels := createTestChannels(1)
+		channels[0].Port = uint16(54001 + i)
+		channels[0].GlobalID = []string{"0101", "0102"}[i]
+		regs = append(regs, NewClusterChannelRegistry(channels, bus))
+		buses = append(buses, bus)
+	}
+	buses[0].Connect([]string{buses[1].Addr().String()})
+	buses[1].Connect([]string{buses[0].Addr().String()})
+	deadline := time.Now().Add(5 * time.Second)
+	for len(buses[0].Connected()) == 0 || len(buses[1].Connected()) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("cluster did not connect")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return regs[0], regs[1]
+}
+
+func TestClusterRegistrySearches(t *testing.T) {
+	a, b := createTestCluster(t)
+	remote := b.channels[0]
+	conn := &mockConn{}
+	remote.sessions.Store(conn, createTestSessionForServer(remote, conn, 200, "Bob"))
+	remote.stages.Store("sl2Qs123p0a0u42", NewStage("sl2Qs123p0a0u42"))
+
+	if gid := a.FindChannelForStage("u42"); gid != "0102" {
+		t.Errorf("FindChannelForStage(u42) = %q, want 0102", gid)
+	}
+	if gid := a.FindChannelForStage("u999"); gid != "" {
+		t.Errorf("FindChannelForStage(u999) = %q, want empty", gid)
+	}
+
+	sessions := a.SearchSessions(func(s SessionSnapshot) bool { return s.Name == "Bob" }, 10)
+	if len(sessions) != 1 || sessions[0].CharID != 200 || sessions[0].ServerPort != 54002 {
+		t.Fatalf("SearchSessions() = %+v", sessions)
+	}
+	if len(sessions[0].ServerIP) != 4 {
+		t.Errorf("ServerIP = %v, want 4 bytes", sessions[0].ServerIP)
+	}
+
+	stages := a.SearchStages("sl2Qs", 10)
+	if len(stages) != 1 || stages[0].StageID != "sl2Qs123p0a0u42" || len(stages[0].ServerIP) != 4 {
+		t.Errorf("SearchStages() = %+v", stages)
+	}
+
+	if a.FindSessionByCharID(200) != nil {
+		t.Error("FindSessionByCharID found a session of another process")
+	}
+}
+
+func TestClusterRegistryBroadcasts(t *testing.T) {
+	a, b := createTestCluster(t)
+	remote := b.channels[0]
+	conn := &mockConn{}
+	session := createTestSessionForServer(remote, conn, 42, "Target")
+	remote.sessions.Store(conn, session)
+
+	a.Worldcast(&mhfpacket.MsgSysNop{}, nil, nil)
+	select {
+	case <-session.sendPackets:
+	case <-time.After(5 * time.Second):
+		t.Error("worldcast did not reach the other process")
+	}
+
+	a.DisconnectUser([]uint32{42})
+	deadline := time.Now().Add(5 * time.Second)
+	for !conn.WasClosed() {
+		if time.Now().After(deadline) {
+			t.Fatal("DisconnectUser did not reach the other process")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
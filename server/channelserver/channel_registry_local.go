@@ -27,22 +27,18 @@ func (r *LocalChannelRegistry) Worldcast(pkt mhfpacket.MHFPacket, ignoredSession
 
 func (r *LocalChannelRegistry) FindSessionByCharID(charID uint32) *Session {
 	for _, c := range r.channels {
-		c.Lock()
-		for _, session := range c.sessions {
+		for _, session := range c.sessions.Snapshot() {
 			if session.charID == charID {
-				c.Unlock()
 				return session
 			}
 		}
-		c.Unlock()
 	}
 	return nil
 }
 
 func (r *LocalChannelRegistry) DisconnectUser(cids []uint32) {
 	for _, c := range r.channels {
-		c.Lock()
-		for _, session := range c.sessions {
+		for _, session := range c.sessions.Snapshot() {
 			for _, cid := range cids {
 				if session.charID == cid {
 					_ = session.rawConn.Close()
@@ -50,7 +46,6 @@ func (r *LocalChannelRegistry) DisconnectUser(cids []uint32) {
 				}
 			}
 		}
-		c.Unlock()
 	}
 }
 
@@ -77,8 +72,7 @@ func (r *LocalChannelRegistry) SearchSessions(predicate func(SessionSnapshot) bo
 		if len(results) >= max {
 			break
 		}
-		c.Lock()
-		for _, session := range c.sessions {
+		for _, session := range c.sessions.Snapshot() {
 			if len(results) >= max {
 				break
 			}
@@ -96,7 +90,6 @@ func (r *LocalChannelRegistry) SearchSessions(predicate func(SessionSnapshot) bo
 				results = append(results, snap)
 			}
 		}
-		c.Unlock()
 	}
 	return results
 }
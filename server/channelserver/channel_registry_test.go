@@ -26,15 +26,11 @@ func TestLocalRegistryFindSessionByCharID(t *testing.T) {
 
 	conn1 := &mockConn{}
 	sess1 := createTestSessionForServer(channels[0], conn1, 100, "Alice")
-	channels[0].Lock()
-	channels[0].sessions[conn1] = sess1
-	channels[0].Unlock()
+	channels[0].sessions.Store(conn1, sess1)
 
 	conn2 := &mockConn{}
 	sess2 := createTestSessionForServer(channels[1], conn2, 200, "Bob")
-	channels[1].Lock()
-	channels[1].sessions[conn2] = sess2
-	channels[1].Unlock()
+	channels[1].sessions.Store(conn2, sess2)
 
 	// Find on first channel
 	found := reg.FindSessionByCharID(100)
@@ -80,9 +76,7 @@ func TestLocalRegistryDisconnectUser(t *testing.T) {
 
 	conn := &mockConn{}
 	sess := createTestSessionForServer(channels[0], conn, 42, "Target")
-	channels[0].Lock()
-	channels[0].sessions[conn] = sess
-	channels[0].Unlock()
+	channels[0].sessions.Store(conn, sess)
 
 	reg.DisconnectUser([]uint32{42})
 
@@ -100,16 +94,12 @@ func TestLocalRegistrySearchSessions(t *testing.T) {
 		conn := &mockConn{}
 		sess := createTestSessionForServer(ch, conn, uint32(i+1), "Player")
 		sess.stage = NewStage("sl1Ns200p0a0u0")
-		ch.Lock()
-		ch.sessions[conn] = sess
-		ch.Unlock()
+		ch.sessions.Store(conn, sess)
 	}
 	conn3 := &mockConn{}
 	sess3 := createTestSessionForServer(channels[0], conn3, 3, "Player")
 	sess3.stage = NewStage("sl1Ns200p0a0u0")
-	channels[0].Lock()
-	channels[0].sessions[conn3] = sess3
-	channels[0].Unlock()
+	channels[0].sessions.Store(conn3, sess3)
 
 	// Search all
 	results := reg.SearchSessions(func(s SessionSnapshot) bool { return true }, 10)
@@ -159,9 +149,7 @@ func TestLocalRegistryConcurrentAccess(t *testing.T) {
 			conn := &mockConn{remoteAddr: &net.TCPAddr{IP: net.ParseIP("127.0.0.1"), Port: 50000 + i}}
 			sess := createTestSessionForServer(ch, conn, uint32(i+1), "Player")
 			sess.stage = NewStage("sl1Ns200p0a0u0")
-			ch.Lock()
-			ch.sessions[conn] = sess
-			ch.Unlock()
+			ch.sessions.Store(conn, sess)
 		}
 	}
 
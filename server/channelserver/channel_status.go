@@ -0,0 +1,114 @@
+package channelserver
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+)
+
+// ChannelStatus is a point-in-time view of a channel for admin tools.
+type ChannelStatus struct {
+	ID      uint16
+	Name    string
+	Port    uint16
+	Players int
+	Packets uint64 // Inbound packets handled since start
+	Stages  []StageStatus
+}
+
+// StageStatus describes the occupancy of a stage.
+type StageStatus struct {
+	ID         string
+	Players    int
+	Reserved   int
+	MaxPlayers uint16
+	Host       string // Name of the host, if any
+}
+
+// SessionStatus describes a connected player.
+type SessionStatus struct {
+	CharID uint32
+	Name   string
+	Stage  string
+	Remote string
+	Lag    Lag // Simulated bad connection, zero for none
+}
+
+// Status returns the channel's population and the occupancy of every stage
+// that has players in it or slots reserved, sorted by stage ID.
+func (s *Server) Status() ChannelStatus {
+	status := ChannelStatus{
+		ID:      s.ID,
+		Name:    s.name,
+		Port:    s.Port,
+		Players: s.sessions.Len(),
+		Packets: s.packetsReceived.Load(),
+	}
+	s.stages.Range(func(id string, stage *Stage) bool {
+		stage.RLock()
+		st := StageStatus{
+			ID:         id,
+			Players:    len(stage.clients),
+			Reserved:   len(stage.reservedClientSlots),
+			MaxPlayers: stage.maxPlayers,
+		}
+		if stage.host != nil {
+			st.Host = stage.host.Name
+		}
+		stage.RUnlock()
+		if st.Players > 0 || st.Reserved > 0 {
+			status.Stages = append(status.Stages, st)
+		}
+		return true
+	})
+	slices.SortFunc(status.Stages, func(a, b StageStatus) int {
+		return strings.Compare(a.ID, b.ID)
+	})
+	return status
+}
+
+// Sessions lists the players connected to the channel, sorted by
+// character ID.
+func (s *Server) Sessions() []SessionStatus {
+	var out []SessionStatus
+	for _, session := range s.sessions.Snapshot() {
+		session.Lock()
+		st := SessionStatus{CharID: session.charID, Name: session.Name, Lag: session.currentLag()}
+		if session.stage != nil {
+			st.Stage = session.stage.id
+		}
+		if session.rawConn != nil {
+			st.Remote = session.rawConn.RemoteAddr().String()
+		}
+		session.Unlock()
+		out = append(out, st)
+	}
+	slices.SortFunc(out, func(a, b SessionStatus) int {
+		return cmp.Compare(a.CharID, b.CharID)
+	})
+	return out
+}
+
+// Kick closes the connection of the character's session on this channel,
+// reporting whether one was found.
+func (s *Server) Kick(charID uint32) bool {
+	for _, session := range s.sessions.Snapshot() {
+		if session.charID == charID {
+			_ = session.rawConn.Close()
+			return true
+		}
+	}
+	return false
+}
+
+// SetLag simulates a bad connection on the character's session on this
+// channel, reporting whether one was found. A zero Lag clears it.
+func (s *Server) SetLag(charID uint32, l Lag) bool {
+	for _, session := range s.sessions.Snapshot() {
+		if session.charID == charID {
+			session.setLag(l)
+			return true
+		}
+	}
+	return false
+}
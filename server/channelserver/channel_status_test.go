@@ -0,0 +1,90 @@
+package channelserver
+
+import (
+	"testing"
+	"time"
+)
+
+func TestServerStatus(t *testing.T) {
+	s := createTestChannels(1)[0]
+	s.name = "Newbie"
+	s.packetsReceived.Add(7)
+
+	conn1, conn2 := &mockConn{}, &mockConn{}
+	alice := createTestSessionForServer(s, conn1, 100, "Alice")
+	bob := createTestSessionForServer(s, conn2, 200, "Bob")
+	s.sessions.Store(conn1, alice)
+	s.sessions.Store(conn2, bob)
+
+	quest := NewStage("sl2Qs123p0a0u42")
+	quest.clients[alice] = alice.charID
+	quest.reservedClientSlots[bob.charID] = false
+	quest.host = alice
+	quest.maxPlayers = 4
+	s.stages.Store(quest.id, quest)
+	s.stages.Store("sl1Ns200p0a0u0", NewStage("sl1Ns200p0a0u0"))
+
+	st := s.Status()
+	if st.Name != "Newbie" || st.Players != 2 || st.Packets != 7 {
+		t.Errorf("Status() = %+v, want Newbie with 2 players and 7 packets", st)
+	}
+	if len(st.Stages) != 1 {
+		t.Fatalf("Status() listed %d stages, want only the occupied one", len(st.Stages))
+	}
+	want := StageStatus{ID: quest.id, Players: 1, Reserved: 1, MaxPlayers: 4, Host: "Alice"}
+	if st.Stages[0] != want {
+		t.Errorf("Stages[0] = %+v, want %+v", st.Stages[0], want)
+	}
+}
+
+func TestServerSessionsAndKick(t *testing.T) {
+	s := createTestChannels(1)[0]
+	conn1, conn2 := &mockConn{}, &mockConn{}
+	s.sessions.Store(conn2, createTestSessionForServer(s, conn2, 200, "Bob"))
+	s.sessions.Store(conn1, createTestSessionForServer(s, conn1, 100, "Alice"))
+
+	sessions := s.Sessions()
+	if len(sessions) != 2 || sessions[0].CharID != 100 || sessions[1].Name != "Bob" {
+		t.Errorf("Sessions() = %+v, want Alice then Bob", sessions)
+	}
+
+	if s.Kick(999) {
+		t.Error("Kick(999) = true for a character that is not connected")
+	}
+	if !s.Kick(200) || !conn2.WasClosed() {
+		t.Error("Kick(200) should close Bob's connection")
+	}
+	if conn1.WasClosed() {
+		t.Error("Kick(200) closed another session's connection")
+	}
+}
+
+func TestServerSetLag(t *testing.T) {
+	s := createTestChannels(1)[0]
+	conn := &mockConn{}
+	s.sessions.Store(conn, createTestSessionForServer(s, conn, 100, "Alice"))
+
+	lag := Lag{Delay: 300 * time.Millisecond, Jitter: 50 * time.Millisecond}
+	if s.SetLag(999, lag) {
+		t.Error("SetLag(999) = true for a character that is not connected")
+	}
+	if !s.SetLag(100, lag) {
+		t.Fatal("SetLag(100) = false")
+	}
+	if got := s.Sessions()[0].Lag; got != lag {
+		t.Errorf("Sessions()[0].Lag = %+v, want %+v", got, lag)
+	}
+	s.SetLag(100, Lag{})
+	if got := s.Sessions()[0].Lag; !got.IsZero() {
+		t.Errorf("Lag = %+v after clearing", got)
+	}
+}
+
+func TestLagNext(t *testing.T) {
+	lag := Lag{Delay: 100 * time.Millisecond, Jitter: 20 * time.Millisecond}
+	for i := 0; i < 100; i++ {
+		if d := lag.next(); d < lag.Delay || d > lag.Delay+lag.Jitter {
+			t.Fatalf("next() = %s, want between %s and %s", d, lag.Delay, lag.Delay+lag.Jitter)
+		}
+	}
+}
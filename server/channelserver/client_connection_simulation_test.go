@@ -277,7 +277,7 @@ func TestClientConnection_SessionTimeout(t *testing.T) {
 	time.Sleep(100 * time.Millisecond)
 
 	// Simulate timeout by setting lastPacket to long ago
-	session.lastPacket = time.Now().Add(-35 * time.Second)
+	session.lastPacket.Store(time.Now().Add(-35 * time.Second).UnixNano())
 
 	// In production, invalidateSessions() goroutine would detect this
 	// and call logoutPlayer(session)
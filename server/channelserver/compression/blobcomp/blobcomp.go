@@ -0,0 +1,114 @@
+package blobcomp
+
+import (
+	"bytes"
+	"fmt"
+
+	"erupe-ce/server/channelserver/compression/deltacomp"
+	"erupe-ce/server/channelserver/compression/nullcomp"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Algorithm is a storage format for compressed blobs.
+type Algorithm string
+
+// Algorithms a blob can be stored with.
+const (
+	Null Algorithm = "null" // nullcomp, as sent by the client
+	Zstd Algorithm = "zstd" // zstd over the decompressed blob
+)
+
+var (
+	nullMagic = []byte("cmp\x2020110113\x20\x20\x20\x00")
+	zstdMagic = []byte{0x28, 0xB5, 0x2F, 0xFD}
+)
+
+// The encoder and decoder are safe for concurrent use through EncodeAll and
+// DecodeAll.
+var (
+	encoder, _ = zstd.NewWriter(nil, zstd.WithEncoderLevel(zstd.SpeedDefault))
+	decoder, _ = zstd.NewReader(nil)
+)
+
+// Parse returns the algorithm a config value names. An empty value is Null.
+func Parse(s string) (Algorithm, error) {
+	switch Algorithm(s) {
+	case "", Null:
+		return Null, nil
+	case Zstd:
+		return Zstd, nil
+	}
+	return "", fmt.Errorf("unknown compression algorithm %q", s)
+}
+
+// Detect returns the algorithm a blob is stored with, or false when it is not
+// compressed, as savedata before G1 is not.
+func Detect(data []byte) (Algorithm, bool) {
+	switch {
+	case bytes.HasPrefix(data, nullMagic):
+		return Null, true
+	case bytes.HasPrefix(data, zstdMagic):
+		return Zstd, true
+	}
+	return "", false
+}
+
+// Decompress returns a blob stored with any algorithm decompressed. Blobs
+// that are not compressed are returned as they are.
+func Decompress(data []byte) ([]byte, error) {
+	alg, ok := Detect(data)
+	if !ok {
+		return data, nil
+	}
+	if alg == Zstd {
+		out, err := decoder.DecodeAll(data, nil)
+		if err != nil {
+			return nil, fmt.Errorf("zstd: %w", err)
+		}
+		return out, nil
+	}
+	return nullcomp.Decompress(data)
+}
+
+// Compress compresses a blob with an algorithm.
+func Compress(alg Algorithm, data []byte) ([]byte, error) {
+	if alg == Zstd {
+		return encoder.EncodeAll(data, nil), nil
+	}
+	return nullcomp.Compress(data)
+}
+
+// Convert re-encodes a compressed blob with another algorithm. Blobs that are
+// not compressed, or already use alg, are returned as they are, so a blob
+// stored raw is read back raw.
+func Convert(data []byte, alg Algorithm) ([]byte, error) {
+	from, ok := Detect(data)
+	if !ok || from == alg {
+		return data, nil
+	}
+	decomp, err := Decompress(data)
+	if err != nil {
+		return nil, err
+	}
+	return Compress(alg, decomp)
+}
+
+// ToClient returns a stored blob in the client's format.
+func ToClient(data []byte) ([]byte, error) {
+	return Convert(data, Null)
+}
+
+// ApplyDiff applies a deltacomp diff from the client to a stored blob, or to
+// emptySize zero bytes when there is none yet, and returns the result
+// compressed in the client's format.
+func ApplyDiff(stored, diff []byte, emptySize int) ([]byte, error) {
+	base := make([]byte, emptySize)
+	if len(stored) > 0 {
+		var err error
+		if base, err = Decompress(stored); err != nil {
+			return nil, err
+		}
+	}
+	return nullcomp.Compress(deltacomp.ApplyDataDiff(diff, base))
+}
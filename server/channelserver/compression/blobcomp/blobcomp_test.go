@@ -0,0 +1,87 @@
+package blobcomp
+
+import (
+	"bytes"
+	"testing"
+
+	"erupe-ce/server/channelserver/compression/nullcomp"
+)
+
+func testBlob() []byte {
+	data := make([]byte, 4096)
+	for i := 0; i < len(data); i += 7 {
+		data[i] = byte(i)
+	}
+	return data
+}
+
+func TestConvertRoundTrip(t *testing.T) {
+	client, _ := nullcomp.Compress(testBlob())
+	stored, err := Convert(client, Zstd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if alg, ok := Detect(stored); !ok || alg != Zstd {
+		t.Fatalf("Detect = %q %v, want zstd", alg, ok)
+	}
+	if len(stored) >= len(client) {
+		t.Errorf("zstd blob is %d bytes, nullcomp %d", len(stored), len(client))
+	}
+	back, err := ToClient(stored)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(back, client) {
+		t.Error("zstd blob did not convert back to the same nullcomp bytes")
+	}
+	decomp, err := Decompress(stored)
+	if err != nil || !bytes.Equal(decomp, testBlob()) {
+		t.Errorf("Decompress = %d bytes, %v", len(decomp), err)
+	}
+}
+
+func TestConvertPassesThrough(t *testing.T) {
+	raw := testBlob()
+	for _, alg := range []Algorithm{Null, Zstd} {
+		if out, err := Convert(raw, alg); err != nil || !bytes.Equal(out, raw) {
+			t.Errorf("uncompressed blob changed by Convert to %s", alg)
+		}
+	}
+	client, _ := nullcomp.Compress(raw)
+	if out, _ := Convert(client, Null); !bytes.Equal(out, client) {
+		t.Error("nullcomp blob changed by Convert to null")
+	}
+	if _, err := Decompress(append(bytes.Clone(zstdMagic), 0xFF, 0xFF)); err == nil {
+		t.Error("corrupt zstd blob was decompressed")
+	}
+}
+
+func TestParse(t *testing.T) {
+	for in, want := range map[string]Algorithm{"": Null, "null": Null, "zstd": Zstd} {
+		if got, err := Parse(in); err != nil || got != want {
+			t.Errorf("Parse(%q) = %q, %v", in, got, err)
+		}
+	}
+	if _, err := Parse("lz4"); err == nil {
+		t.Error("unknown algorithm was accepted")
+	}
+}
+
+func TestApplyDiff(t *testing.T) {
+	// Skip to offset 2 and write one byte.
+	diff := []byte{3, 2, 0xAA}
+	out, err := ApplyDiff(nil, diff, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, _ := nullcomp.Decompress(out)
+	if !bytes.Equal(got, []byte{0, 0, 0xAA, 0, 0, 0, 0, 0}) {
+		t.Errorf("empty base: % X", got)
+	}
+
+	stored, _ := Compress(Zstd, []byte{1, 1, 1, 1})
+	out, _ = ApplyDiff(stored, diff, 8)
+	if got, _ := nullcomp.Decompress(out); !bytes.Equal(got, []byte{1, 1, 0xAA, 1}) {
+		t.Errorf("zstd base: % X", got)
+	}
+}
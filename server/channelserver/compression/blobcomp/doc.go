@@ -0,0 +1,9 @@
+// Package blobcomp stores the compressed character blobs, such as savedata,
+// in a server-side format chosen per blob type. Null is nullcomp, the format
+// the client sends and expects back. Zstd takes less space in the database;
+// blobs stored with it are converted back to nullcomp when read, so the rest
+// of the server and the client only ever see the client's format.
+//
+// Stored blobs are recognised by their header, so rows written with different
+// algorithms can coexist while a server switches between them.
+package blobcomp
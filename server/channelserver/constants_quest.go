@@ -25,22 +25,13 @@ const (
 	questBodyLenZZ   = 320
 )
 
-// BackportQuest constants
+// Quest string table constants
 const (
-	questRewardTableBase    = uint32(96)
 	questStringPointerOff   = 40
 	questStringTablePadding = 32
 	questStringCount        = 8
 )
 
-// BackportQuest fill lengths per version
-const (
-	questBackportFillS6   = uint32(44)
-	questBackportFillF5   = uint32(52)
-	questBackportFillG101 = uint32(76)
-	questBackportFillZZ   = uint32(108)
-)
-
 // Tune value count limits per game version
 const (
 	tuneLimitG1   = 256
@@ -0,0 +1,398 @@
+package channelserver
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"erupe-ce/common/byteframe"
+	"erupe-ce/common/gametime"
+	cfg "erupe-ce/config"
+	"erupe-ce/network"
+	"erupe-ce/network/clientctx"
+	"erupe-ce/network/mhfpacket"
+	"erupe-ce/network/pcap"
+
+	"go.uber.org/zap"
+)
+
+// Golden tests replay channel captures (.mhfr files from the Capture
+// options) through the real handlers against the test database and compare
+// the responses with the ones recorded. See testdata/golden/README.md for
+// how to add a capture.
+
+// goldenCaptureEnv names a directory of captures replayed in addition to
+// those in testdata/golden.
+const goldenCaptureEnv = "ERUPE_GOLDEN_CAPTURES"
+
+// goldenRule relaxes the comparison of the responses to one opcode.
+type goldenRule struct {
+	Skip     bool     // Do not compare the responses at all
+	SizeOnly bool     // Compare only the payload length
+	Ignore   [][2]int // Byte ranges [start, end) allowed to differ
+}
+
+// ignores reports whether the byte at offset may differ.
+func (r goldenRule) ignores(offset int) bool {
+	for _, span := range r.Ignore {
+		if offset >= span[0] && offset < span[1] {
+			return true
+		}
+	}
+	return false
+}
+
+// goldenRules are keyed by the opcode of the request an ack answers, or by
+// the packet's own opcode for anything else. Offsets are into the ack data,
+// or into the payload after the opcode.
+var goldenRules = map[network.PacketID]goldenRule{
+	// Quest and scenario files are read from BinPath, which tests do not ship.
+	network.MSG_SYS_GET_FILE:        {Skip: true},
+	network.MSG_MHF_ENUMERATE_QUEST: {Skip: true},
+	// The high half of an object ID is the session's object ID, which depends
+	// on every session the capturing server had accepted before this one.
+	network.MSG_SYS_CREATE_OBJECT: {Ignore: [][2]int{{0, 2}}},
+	// The login time can land a second after the capture timestamp.
+	network.MSG_SYS_LOGIN: {Ignore: [][2]int{{0, 4}}},
+}
+
+func TestGoldenCaptures(t *testing.T) {
+	paths, _ := filepath.Glob(filepath.Join("testdata", "golden", "*.mhfr"))
+	if dir := os.Getenv(goldenCaptureEnv); dir != "" {
+		more, _ := filepath.Glob(filepath.Join(dir, "*.mhfr"))
+		paths = append(paths, more...)
+	}
+	if len(paths) == 0 {
+		t.Skipf("No golden captures in testdata/golden or $%s", goldenCaptureEnv)
+	}
+	for _, path := range paths {
+		t.Run(filepath.Base(path), func(t *testing.T) {
+			replayGoldenCapture(t, loadGoldenCapture(t, path), goldenRules)
+		})
+	}
+}
+
+// replayGoldenCapture replays capture through a new server on the test
+// database, with its character recreated.
+func replayGoldenCapture(t *testing.T, capture *goldenCapture, rules map[network.PacketID]goldenRule) {
+	t.Helper()
+	if capture.header.ServerType != pcap.ServerTypeChannel {
+		t.Skipf("%s capture, only channel captures are replayed", capture.header.ServerType)
+	}
+	db := SetupTestDB(t)
+	config := &cfg.Config{RealClientMode: cfg.Mode(capture.header.ClientMode)}
+	config.DebugOptions.DisableTokenCheck = true
+	server := NewServer(&Config{ID: 1, Logger: zap.NewNop(), DB: db, ErupeConfig: config})
+	if charID := capture.meta.CharID; charID != 0 {
+		// Recreate the captured character, fresh, under its original ID.
+		created := CreateTestCharacter(t, db, CreateTestUser(t, db, "golden"), "Golden")
+		if _, err := db.Exec("UPDATE characters SET id=$1 WHERE id=$2", charID, created); err != nil {
+			t.Fatalf("Failed to renumber character: %v", err)
+		}
+	}
+	replayGolden(t, server, capture, rules)
+}
+
+// replayGeneratedCapture replays a capture written into a test by replay
+// --mode gentest. Only the opcodes and sizes of the responses are
+// compared, apart from those goldenRules skips, so the test holds as long
+// as the handlers answer in the same shape.
+func replayGeneratedCapture(t *testing.T, capture *goldenCapture) {
+	t.Helper()
+	rules := make(map[network.PacketID]goldenRule)
+	for _, rec := range capture.records {
+		rules[network.PacketID(rec.Opcode)] = goldenRule{SizeOnly: true}
+	}
+	for opcode, rule := range goldenRules {
+		if rule.Skip {
+			rules[opcode] = rule
+		}
+	}
+	replayGoldenCapture(t, capture, rules)
+}
+
+// goldenPayload decodes a payload a generated test spells in hex.
+func goldenPayload(s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+// goldenCapture is a capture file read into memory.
+type goldenCapture struct {
+	header  pcap.FileHeader
+	meta    pcap.SessionMetadata
+	records []pcap.PacketRecord
+}
+
+func loadGoldenCapture(t testing.TB, path string) *goldenCapture {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = f.Close() }()
+	r, err := pcap.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &goldenCapture{header: r.Header, meta: r.Meta}
+	for {
+		rec, err := r.ReadPacket()
+		if errors.Is(err, io.EOF) {
+			return c
+		}
+		if err != nil {
+			t.Fatalf("Failed to read %s: %v", path, err)
+		}
+		c.records = append(c.records, rec)
+	}
+}
+
+// replayGolden feeds the client packets of c to a new session on server,
+// with the game clock set to when each was captured, and compares what the
+// handlers queue with the server packets in c.
+func replayGolden(t testing.TB, server *Server, c *goldenCapture, rules map[network.PacketID]goldenRule) {
+	t.Helper()
+	clk := gametime.NewFakeClock(time.Unix(0, c.header.SessionStartNs))
+	defer gametime.SetClock(clk)()
+
+	conn := &mockConn{}
+	session := &Session{
+		logger:        server.logger,
+		server:        server,
+		rawConn:       conn,
+		cryptConn:     &MockCryptConn{},
+		sendPackets:   make(chan packet, 1024),
+		clientContext: &clientctx.ClientContext{RealClientMode: server.erupeConfig.RealClientMode},
+		sessionStart:  TimeAdjusted().Unix(),
+		ackStart:      make(map[uint32]time.Time),
+		semaphoreID:   make([]uint16, 2),
+		charID:        c.meta.CharID, // Captures that start after login replay as its character
+	}
+	server.sessions.Store(conn, session)
+	defer server.sessions.Delete(conn)
+
+	requests := make(map[uint32]network.PacketID)
+	var expected, actual []goldenPacket
+	for _, rec := range c.records {
+		switch rec.Direction {
+		case pcap.DirClientToServer:
+			clk.Set(time.Unix(0, rec.TimestampNs))
+			if len(rec.Payload) >= 6 {
+				requests[binary.BigEndian.Uint32(rec.Payload[2:6])] = network.PacketID(rec.Opcode)
+			}
+			session.handlePacketGroup(rec.Payload)
+			actual = append(actual, drainGolden(t, session)...)
+		case pcap.DirServerToClient:
+			// The send loop appends MSG_SYS_END to every packet.
+			expected = append(expected, decodeGolden(t, bytes.TrimSuffix(rec.Payload, []byte{0x00, 0x10})))
+		}
+	}
+	compareGolden(t, expected, actual, requests, rules)
+}
+
+// goldenPacket is a server packet split into its opcode and payload, with
+// acks decoded.
+type goldenPacket struct {
+	opcode network.PacketID
+	body   []byte
+	ack    *mhfpacket.MsgSysAck
+}
+
+func decodeGolden(t testing.TB, data []byte) goldenPacket {
+	t.Helper()
+	if len(data) < 2 {
+		t.Fatalf("server packet of %d bytes has no opcode", len(data))
+	}
+	p := goldenPacket{opcode: network.PacketID(binary.BigEndian.Uint16(data)), body: data[2:]}
+	if p.opcode == network.MSG_SYS_ACK {
+		p.ack = &mhfpacket.MsgSysAck{}
+		bf := byteframe.NewByteFrameFromBytes(p.body)
+		if err := p.ack.Parse(bf, &clientctx.ClientContext{}); err != nil || bf.Err() != nil {
+			t.Fatalf("malformed ack % X", data)
+		}
+	}
+	return p
+}
+
+func drainGolden(t testing.TB, s *Session) []goldenPacket {
+	var out []goldenPacket
+	for {
+		select {
+		case p := <-s.sendPackets:
+			data := p.data
+			if p.coalesceKey != 0 {
+				data = s.takeCoalesced(p.coalesceKey, data)
+			}
+			out = append(out, decodeGolden(t, data))
+		default:
+			return out
+		}
+	}
+}
+
+// compareGolden matches acks by ack handle and other packets by opcode in
+// the order they were sent. Server packets without a replayed counterpart,
+// such as broadcasts from other players on the capturing server, are not
+// reported.
+func compareGolden(t testing.TB, expected, actual []goldenPacket, requests map[uint32]network.PacketID, rules map[network.PacketID]goldenRule) {
+	t.Helper()
+	acks := make(map[uint32][]goldenPacket)
+	others := make(map[network.PacketID][]goldenPacket)
+	for _, p := range actual {
+		if p.ack != nil {
+			acks[p.ack.AckHandle] = append(acks[p.ack.AckHandle], p)
+		} else {
+			others[p.opcode] = append(others[p.opcode], p)
+		}
+	}
+
+	for _, exp := range expected {
+		if exp.ack != nil {
+			handle := exp.ack.AckHandle
+			request := requests[handle]
+			label := fmt.Sprintf("ack %d to %s", handle, request)
+			if len(acks[handle]) == 0 {
+				if !rules[request].Skip {
+					t.Errorf("%s: no response", label)
+				}
+				continue
+			}
+			act := acks[handle][0]
+			acks[handle] = acks[handle][1:]
+			if exp.ack.ErrorCode != act.ack.ErrorCode || exp.ack.IsBufferResponse != act.ack.IsBufferResponse {
+				t.Errorf("%s: error code %d, buffered %v, want %d, %v", label,
+					act.ack.ErrorCode, act.ack.IsBufferResponse, exp.ack.ErrorCode, exp.ack.IsBufferResponse)
+				continue
+			}
+			compareGoldenBytes(t, label, rules[request], exp.ack.AckData, act.ack.AckData)
+			continue
+		}
+		if len(others[exp.opcode]) == 0 {
+			continue
+		}
+		act := others[exp.opcode][0]
+		others[exp.opcode] = others[exp.opcode][1:]
+		compareGoldenBytes(t, exp.opcode.String(), rules[exp.opcode], exp.body, act.body)
+	}
+
+	for _, handle := range slices.Sorted(maps.Keys(acks)) {
+		if len(acks[handle]) > 0 && !rules[requests[handle]].Skip {
+			t.Errorf("ack %d to %s: sent but not in the capture", handle, requests[handle])
+		}
+	}
+	for opcode, rest := range others {
+		if len(rest) > 0 && !rules[opcode].Skip {
+			t.Errorf("%s: %d more sent than captured", opcode, len(rest))
+		}
+	}
+}
+
+// compareGoldenBytes reports the first difference between want and got that
+// rule does not allow.
+func compareGoldenBytes(t testing.TB, label string, rule goldenRule, want, got []byte) {
+	t.Helper()
+	switch {
+	case rule.Skip:
+	case len(want) != len(got):
+		t.Errorf("%s: %d bytes, want %d", label, len(got), len(want))
+	case rule.SizeOnly:
+	default:
+		for i := range want {
+			if want[i] != got[i] && !rule.ignores(i) {
+				t.Errorf("%s: byte %d is %02X, want %02X\ngot  % X\nwant % X", label, i, got[i], want[i], got, want)
+				return
+			}
+		}
+	}
+}
+
+// writeGoldenCapture writes records to a channel capture in a temporary
+// directory and loads it back.
+func writeGoldenCapture(t *testing.T, start time.Time, records []pcap.PacketRecord) *goldenCapture {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "session.mhfr")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	header := pcap.FileHeader{Version: pcap.FormatVersion, ServerType: pcap.ServerTypeChannel, ClientMode: byte(cfg.ZZ), SessionStartNs: start.UnixNano()}
+	w, err := pcap.NewWriter(f, header, pcap.SessionMetadata{CharID: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, rec := range records {
+		if err := w.WritePacket(rec); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	_ = f.Close()
+	return loadGoldenCapture(t, path)
+}
+
+func TestReplayGolden_EarthStatus(t *testing.T) {
+	at := time.Date(2024, 3, 6, 12, 0, 0, 0, time.UTC)
+	request := byteframe.NewByteFrame()
+	request.WriteUint16(uint16(network.MSG_MHF_GET_EARTH_STATUS))
+	request.WriteUint32(77) // AckHandle
+	request.WriteUint32(0)
+	request.WriteUint32(0)
+
+	// Record the response as the handler gave it at that time.
+	h := newHandlerHarness(t)
+	restore := gametime.SetClock(gametime.NewFakeClock(at))
+	h.Handle(&mhfpacket.MsgMhfGetEarthStatus{AckHandle: 77})
+	restore()
+	response := append([]byte{0x00, byte(network.MSG_SYS_ACK)}, h.Next().Body...)
+
+	newCapture := func(t *testing.T, response []byte) *goldenCapture {
+		return writeGoldenCapture(t, at.Add(-time.Minute), []pcap.PacketRecord{
+			{TimestampNs: at.UnixNano(), Direction: pcap.DirClientToServer, Opcode: uint16(network.MSG_MHF_GET_EARTH_STATUS), Payload: request.Data()},
+			{TimestampNs: at.UnixNano(), Direction: pcap.DirServerToClient, Opcode: uint16(network.MSG_SYS_ACK), Payload: append(slices.Clone(response), 0x00, 0x10)},
+		})
+	}
+
+	t.Run("matches", func(t *testing.T) {
+		replayGolden(t, createMockServer(), newCapture(t, response), nil)
+	})
+
+	// The ack data, and with it the week start, begins 10 bytes in.
+	changed := slices.Clone(response)
+	changed[10] ^= 0xFF
+	t.Run("reports a difference", func(t *testing.T) {
+		rec := &recordingTB{TB: t}
+		replayGolden(rec, createMockServer(), newCapture(t, changed), nil)
+		if len(rec.errors) != 1 || !strings.Contains(rec.errors[0], "byte 0") {
+			t.Errorf("recorded %q, want one difference at byte 0", rec.errors)
+		}
+	})
+	t.Run("ignores a tolerated range", func(t *testing.T) {
+		rules := map[network.PacketID]goldenRule{network.MSG_MHF_GET_EARTH_STATUS: {Ignore: [][2]int{{0, 4}}}}
+		replayGolden(t, createMockServer(), newCapture(t, changed), rules)
+	})
+	t.Run("reports a missing response", func(t *testing.T) {
+		server := createMockServer()
+		delete(server.handlerTable, network.MSG_MHF_GET_EARTH_STATUS)
+		rec := &recordingTB{TB: t}
+		replayGolden(rec, server, newCapture(t, response), nil)
+		if len(rec.errors) != 1 || !strings.Contains(rec.errors[0], "no response") {
+			t.Errorf("recorded %q, want a missing response", rec.errors)
+		}
+	})
+}
@@ -1,10 +1,12 @@
 package channelserver
 
 import (
+	"fmt"
 	"io"
 
 	"erupe-ce/common/byteframe"
 	"erupe-ce/network/mhfpacket"
+	"erupe-ce/server/eventbus"
 	"go.uber.org/zap"
 )
 
@@ -146,6 +148,28 @@ func handleMsgMhfAddAchievement(s *Session, p mhfpacket.MHFPacket) {
 
 	if err := s.server.achievementService.Increment(s.charID, pkt.AchievementID); err != nil {
 		s.logger.Warn("Failed to increment achievement", zap.Error(err))
+		return
+	}
+
+	if s.server.eventBus == nil {
+		return
+	}
+	first, err := s.server.achievementService.IsServerFirstGold(s.charID, pkt.AchievementID)
+	if err != nil {
+		s.logger.Warn("Failed to check server-first achievement", zap.Error(err))
+		return
+	}
+	if first {
+		s.server.eventBus.Publish(eventbus.Event{
+			Type:    eventbus.ServerFirst,
+			Title:   "Server first!",
+			Message: fmt.Sprintf("%s is the first hunter to earn a gold trophy in achievement #%d.", s.Name, pkt.AchievementID),
+			Fields: []eventbus.Field{
+				{Name: "Hunter", Value: s.Name},
+				{Name: "Achievement", Value: fmt.Sprint(pkt.AchievementID)},
+			},
+			Key: fmt.Sprintf("%d-%d", pkt.AchievementID, s.charID),
+		})
 	}
 }
 
@@ -53,8 +53,8 @@ func handleMsgMhfCheckDailyCafepoint(s *Session, p mhfpacket.MHFPacket) {
 	if midday.After(dailyTime) {
 		_ = addPointNetcafe(s, 5)
 		bondBonus = 5 // Bond point bonus quests
-		bonusQuests = s.server.erupeConfig.GameplayOptions.BonusQuestAllowance
-		dailyQuests = s.server.erupeConfig.GameplayOptions.DailyQuestAllowance
+		bonusQuests = s.server.erupeConfig.Live().GameplayOptions.BonusQuestAllowance
+		dailyQuests = s.server.erupeConfig.Live().GameplayOptions.DailyQuestAllowance
 		if err := s.server.charRepo.UpdateDailyCafe(s.charID, midday, bonusQuests, dailyQuests); err != nil {
 			s.logger.Error("Failed to update daily cafe data", zap.Error(err))
 		}
@@ -182,7 +182,7 @@ func addPointNetcafe(s *Session, p int) error {
 	if err != nil {
 		return err
 	}
-	points = min(points+p, s.server.erupeConfig.GameplayOptions.MaximumNP)
+	points = min(points+p, s.server.erupeConfig.Live().GameplayOptions.MaximumNP)
 	if err := s.server.charRepo.SaveInt(s.charID, "netcafe_points", points); err != nil {
 		s.logger.Error("Failed to update netcafe points", zap.Error(err))
 	}
@@ -192,8 +192,8 @@ func addPointNetcafe(s *Session, p int) error {
 func handleMsgMhfStartBoostTime(s *Session, p mhfpacket.MHFPacket) {
 	pkt := p.(*mhfpacket.MsgMhfStartBoostTime)
 	bf := byteframe.NewByteFrame()
-	boostLimit := TimeAdjusted().Add(time.Duration(s.server.erupeConfig.GameplayOptions.BoostTimeDuration) * time.Second)
-	if s.server.erupeConfig.GameplayOptions.DisableBoostTime {
+	boostLimit := TimeAdjusted().Add(time.Duration(s.server.erupeConfig.Live().GameplayOptions.BoostTimeDuration) * time.Second)
+	if s.server.erupeConfig.Live().GameplayOptions.DisableBoostTime {
 		bf.WriteUint32(0)
 		doAckBufSucceed(s, pkt.AckHandle, bf.Data())
 		return
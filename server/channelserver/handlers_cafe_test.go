@@ -4,6 +4,7 @@ import (
 	"testing"
 	"time"
 
+	"erupe-ce/common/gametime"
 	"erupe-ce/network/mhfpacket"
 )
 
@@ -334,3 +335,24 @@ func TestHandleMsgMhfGetBoostRight_NoRecord(t *testing.T) {
 		t.Error("No response packet queued")
 	}
 }
+
+func TestHandleMsgMhfGetBoostRight_Expires(t *testing.T) {
+	clk := gametime.NewFakeClock(time.Date(2026, 3, 4, 12, 0, 0, 0, time.UTC))
+	defer gametime.SetClock(clk)()
+
+	h := newHandlerHarness(t)
+	charMock := newMockCharacterRepo()
+	charMock.times["boost_time"] = clk.Now().Add(time.Hour)
+	h.Server.charRepo = charMock
+
+	h.Handle(&mhfpacket.MsgMhfGetBoostRight{AckHandle: 1})
+	if got := h.Next().ExpectAck(1).AckData().ReadUint32(); got != 1 {
+		t.Errorf("boost right = %d, want 1 (active)", got)
+	}
+
+	clk.Advance(2 * time.Hour)
+	h.Handle(&mhfpacket.MsgMhfGetBoostRight{AckHandle: 2})
+	if got := h.Next().ExpectAck(2).AckData().ReadUint32(); got != 2 {
+		t.Errorf("boost right after expiry = %d, want 2 (expired)", got)
+	}
+}
@@ -5,9 +5,11 @@ import (
 	"erupe-ce/common/token"
 	"erupe-ce/network/binpacket"
 	"erupe-ce/network/mhfpacket"
+	"erupe-ce/server/discordbot"
 	"fmt"
 	"math"
 	"strings"
+	"time"
 
 	"go.uber.org/zap"
 )
@@ -89,7 +91,18 @@ func handleMsgSysCastBinary(s *Session, p mhfpacket.MHFPacket) {
 			return
 		}
 		realPayload = msgBinTargeted.RawDataPayload
+		if pkt.MessageType == BinaryMessageTypeChat {
+			if s.isMuted() {
+				sendMutedMessage(s)
+				return
+			}
+			relayGuildChat(s, realPayload)
+		}
 	} else if pkt.MessageType == BinaryMessageTypeChat {
+		if s.isMuted() && !strings.HasPrefix(message, s.server.erupeConfig.Live().CommandPrefix) {
+			sendMutedMessage(s)
+			return
+		}
 		if message == "@dice" {
 			returnToSender = true
 			m := binpacket.MsgBinChat{
@@ -107,12 +120,15 @@ func handleMsgSysCastBinary(s *Session, p mhfpacket.MHFPacket) {
 			bf.SetLE()
 			chatMessage := &binpacket.MsgBinChat{}
 			_ = chatMessage.Parse(bf)
-			if strings.HasPrefix(chatMessage.Message, s.server.erupeConfig.CommandPrefix) {
+			if strings.HasPrefix(chatMessage.Message, s.server.erupeConfig.Live().CommandPrefix) {
 				parseChatCommand(s, chatMessage.Message)
 				return
 			}
 			if (pkt.BroadcastType == BroadcastTypeStage && s.stage.id == "sl1Ns200p0a0u0") || pkt.BroadcastType == BroadcastTypeWorld {
 				s.server.DiscordChannelSend(chatMessage.SenderName, chatMessage.Message)
+				s.server.federation.SendChat(chatMessage.SenderName, chatMessage.Message)
+			} else if pkt.BroadcastType == BroadcastTypeServer {
+				s.server.DiscordRelaySend(discordbot.ScopeSiege, 0, chatMessage.SenderName, chatMessage.Message)
 			}
 		}
 	}
@@ -162,4 +178,30 @@ func handleMsgSysCastBinary(s *Session, p mhfpacket.MHFPacket) {
 	}
 }
 
+// relayGuildChat forwards a targeted guild chat message to Discord.
+func relayGuildChat(s *Session, payload []byte) {
+	if !s.server.erupeConfig.Discord.Enabled || s.server.discordBot == nil {
+		return
+	}
+	bf := byteframe.NewByteFrameFromBytes(payload)
+	bf.SetLE()
+	chatMessage := &binpacket.MsgBinChat{}
+	if err := chatMessage.Parse(bf); err != nil || chatMessage.Type != binpacket.ChatTypeGuild {
+		return
+	}
+	guild, err := s.server.guildRepo.GetByCharID(s.charID)
+	if err != nil || guild == nil {
+		return
+	}
+	s.server.DiscordRelaySend(discordbot.ScopeGuild, guild.ID, chatMessage.SenderName, chatMessage.Message)
+}
+
 func handleMsgSysCastedBinary(s *Session, p mhfpacket.MHFPacket) {}
+
+// sendMutedMessage tells a muted player their chat was not delivered.
+func sendMutedMessage(s *Session) {
+	s.Lock()
+	until := s.mutedUntil
+	s.Unlock()
+	sendServerChatMessage(s, fmt.Sprintf(s.server.i18n.muted, until.Format(time.DateTime)))
+}
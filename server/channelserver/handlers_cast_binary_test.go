@@ -1,10 +1,10 @@
 package channelserver
 
 import (
-	"net"
 	"slices"
 	"strings"
 	"testing"
+	"time"
 
 	"erupe-ce/common/byteframe"
 	"erupe-ce/common/mhfcourse"
@@ -85,7 +85,7 @@ func TestHandleMsgSysCastBinary_SimpleData(t *testing.T) {
 	s.charID = 54321
 	s.stage = NewStage("test_stage")
 	s.stage.clients[s] = s.charID
-	s.server.sessions = make(map[net.Conn]*Session)
+	s.server.sessions = SessionMap{}
 
 	// Create a data message payload
 	bf := byteframe.NewByteFrame()
@@ -110,7 +110,7 @@ func TestHandleMsgSysCastBinary_DiceCommand(t *testing.T) {
 	s.charID = 99999
 	s.stage = NewStage("test_stage")
 	s.stage.clients[s] = s.charID
-	s.server.sessions = make(map[net.Conn]*Session)
+	s.server.sessions = SessionMap{}
 
 	// Build a chat message with @dice command
 	bf := byteframe.NewByteFrame()
@@ -140,6 +140,44 @@ func TestHandleMsgSysCastBinary_DiceCommand(t *testing.T) {
 	}
 }
 
+// TestHandleMsgSysCastBinary_MutedChat verifies chat from a muted player is
+// not delivered and the player is told why.
+func TestHandleMsgSysCastBinary_MutedChat(t *testing.T) {
+	s := createTestSession(&MockCryptConn{sentPackets: make([][]byte, 0)})
+	s.charID = 99999
+	s.server.erupeConfig.CommandPrefix = "!"
+	s.server.i18n = getLangStrings(s.server)
+	s.mutedUntil = time.Now().Add(time.Hour)
+	s.stage = NewStage("test_stage")
+	s.stage.clients[s] = s.charID
+	other := createTestSession(&MockCryptConn{sentPackets: make([][]byte, 0)})
+	other.charID = 88888
+	s.stage.clients[other] = other.charID
+
+	bf := byteframe.NewByteFrame()
+	bf.SetLE()
+	msg := &binpacket.MsgBinChat{
+		Type:       5,
+		Flags:      0x80,
+		Message:    "hello",
+		SenderName: "TestPlayer",
+	}
+	_ = msg.Build(bf)
+
+	handleMsgSysCastBinary(s, &mhfpacket.MsgSysCastBinary{
+		BroadcastType:  BroadcastTypeStage,
+		MessageType:    BinaryMessageTypeChat,
+		RawDataPayload: bf.Data(),
+	})
+
+	if len(other.sendPackets) != 0 {
+		t.Error("muted chat was delivered to other players")
+	}
+	if len(s.sendPackets) != 1 {
+		t.Errorf("sender packets = %d, want 1 muted notice", len(s.sendPackets))
+	}
+}
+
 // TestBroadcastTypes verifies different broadcast types are handled
 func TestBroadcastTypes(t *testing.T) {
 	tests := []struct {
@@ -200,7 +238,7 @@ func TestBroadcastTypes(t *testing.T) {
 			s.charID = 22222
 			s.stage = NewStage("test_stage")
 			s.stage.clients[s] = s.charID
-			s.server.sessions = make(map[net.Conn]*Session)
+			s.server.sessions = SessionMap{}
 
 			pkt := &mhfpacket.MsgSysCastBinary{
 				Unk:            0,
@@ -298,7 +336,7 @@ func TestBinaryMessageTypes(t *testing.T) {
 			s.charID = 33333
 			s.stage = NewStage("test_stage")
 			s.stage.clients[s] = s.charID
-			s.server.sessions = make(map[net.Conn]*Session)
+			s.server.sessions = SessionMap{}
 
 			pkt := &mhfpacket.MsgSysCastBinary{
 				Unk:            0,
@@ -570,7 +608,7 @@ func TestPayloadHandling(t *testing.T) {
 			s.charID = 44444
 			s.stage = NewStage("test_stage")
 			s.stage.clients[s] = s.charID
-			s.server.sessions = make(map[net.Conn]*Session)
+			s.server.sessions = SessionMap{}
 
 			// Create payload of specified size
 			payload := make([]byte, tt.payloadSize)
@@ -626,7 +664,7 @@ func TestNilPayloadHandling(t *testing.T) {
 	s.charID = 55555
 	s.stage = NewStage("test_stage")
 	s.stage.clients[s] = s.charID
-	s.server.sessions = make(map[net.Conn]*Session)
+	s.server.sessions = SessionMap{}
 
 	pkt := &mhfpacket.MsgSysCastBinary{
 		Unk:            0,
@@ -659,7 +697,7 @@ func BenchmarkHandleMsgSysCastBinary(b *testing.B) {
 	s.charID = 99999
 	s.stage = NewStage("test_stage")
 	s.stage.clients[s] = s.charID
-	s.server.sessions = make(map[net.Conn]*Session)
+	s.server.sessions = SessionMap{}
 
 	// Prepare packet
 	bf := byteframe.NewByteFrame()
@@ -12,6 +12,17 @@ import (
 
 // GetCharacterSaveData loads a character's save data from the database.
 func GetCharacterSaveData(s *Session, charID uint32) (*CharacterSaveData, error) {
+	cache := s.server.saveCache
+	if cache != nil && charID == s.charID {
+		if save, ok := cache.Get(charID); ok {
+			return save, nil
+		}
+	}
+
+	if pool := s.server.saveWorkers; pool != nil {
+		pool.Wait(charID)
+	}
+
 	id, savedata, isNew, name, err := s.server.charRepo.LoadSaveData(charID)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
@@ -24,11 +35,10 @@ func GetCharacterSaveData(s *Session, charID uint32) (*CharacterSaveData, error)
 
 	saveData := &CharacterSaveData{
 		CharID:         id,
-		compSave:       savedata,
+		compSave:       loadStoredSave(s, charID, savedata),
 		IsNewCharacter: isNew,
 		Name:           name,
 		Mode:           s.server.erupeConfig.RealClientMode,
-		Pointers:       getPointers(s.server.erupeConfig.RealClientMode),
 	}
 
 	if saveData.compSave == nil {
@@ -43,6 +53,10 @@ func GetCharacterSaveData(s *Session, charID uint32) (*CharacterSaveData, error)
 
 	saveData.updateStructWithSaveData()
 
+	if cache != nil && charID == s.charID {
+		cache.Load(saveData)
+	}
+
 	return saveData, nil
 }
 
@@ -62,24 +76,82 @@ func (save *CharacterSaveData) Save(s *Session) {
 
 	save.updateSaveDataWithStruct()
 
-	if s.server.erupeConfig.RealClientMode >= cfg.G1 {
-		err := save.Compress()
-		if err != nil {
-			s.logger.Error("Failed to compress savedata", zap.Error(err))
-			return
+	if cache := s.server.saveCache; cache != nil && save.CharID == s.charID {
+		if err := cache.Put(save); err != nil {
+			s.logger.Error("Failed to journal savedata", zap.Error(err), zap.Uint32("charID", save.CharID))
 		}
-	} else {
-		// Saves were not compressed
-		save.compSave = save.decompSave
+		// New characters are written immediately so the character select
+		// screen never sees an empty save.
+		if save.IsNewCharacter {
+			if err := cache.Flush(save.CharID); err != nil {
+				s.logger.Error("Failed to update savedata", zap.Error(err), zap.Uint32("charID", save.CharID))
+			}
+		}
+		return
+	}
+
+	if pool := s.server.saveWorkers; pool != nil {
+		snapshot, charRepo, logger := save.clone(), s.server.charRepo, s.logger
+		pool.Submit(save.CharID, func() {
+			if err := snapshot.persist(charRepo); err != nil {
+				logger.Error("Failed to update savedata", zap.Error(err), zap.Uint32("charID", snapshot.CharID))
+			}
+		})
+		return
 	}
 
-	if err := s.server.charRepo.SaveCharacterData(save.CharID, save.compSave, save.HR, save.GR, save.Gender, save.WeaponType, save.WeaponID); err != nil {
+	if err := save.persist(s.server.charRepo); err != nil {
 		s.logger.Error("Failed to update savedata", zap.Error(err), zap.Uint32("charID", save.CharID))
 	}
+}
+
+// migrateSaveForServer converts stored savedata written under another client
+// mode to the server's. The result is not written back; the next save stores
+// it along with the new mode. On failure the save is returned unchanged.
+func migrateSaveForServer(s *Session, charID uint32, stored []byte) []byte {
+	mode, err := s.server.charRepo.ReadInt(charID, "savedata_mode")
+	if err != nil {
+		s.logger.Warn("Failed to read savedata mode", zap.Error(err), zap.Uint32("charID", charID))
+		return stored
+	}
+	from, to := cfg.Mode(mode), s.server.erupeConfig.RealClientMode
+	migrated, err := migrateStoredSave(stored, from, to)
+	if err != nil {
+		s.logger.Error("Failed to migrate savedata to the server's client mode", zap.Error(err),
+			zap.Uint32("charID", charID), zap.Int("from", int(from)), zap.Int("to", int(to)))
+		return stored
+	}
+	if from != 0 && from != to {
+		s.logger.Info("Migrated savedata to the server's client mode",
+			zap.Uint32("charID", charID), zap.Int("from", int(from)), zap.Int("to", int(to)))
+	}
+	return migrated
+}
+
+// persist compresses the save and writes it and the house data to the
+// database. It does not modify save.
+func (save *CharacterSaveData) persist(charRepo CharacterRepo) error {
+	compSave, err := encodeStoredSave(save.decompSave, save.Mode)
+	if err != nil {
+		return err
+	}
+
+	return errors.Join(
+		charRepo.SaveCharacterData(save.CharID, compSave, save.Mode, save.HR, save.GR, save.Gender, save.WeaponType, save.WeaponID),
+		charRepo.SaveHouseData(save.CharID, save.HouseTier, save.HouseData, save.BookshelfData, save.GalleryData, save.ToreData, save.GardenData),
+	)
+}
 
-	if err := s.server.charRepo.SaveHouseData(s.charID, save.HouseTier, save.HouseData, save.BookshelfData, save.GalleryData, save.ToreData, save.GardenData); err != nil {
-		s.logger.Error("Failed to update user binary house data", zap.Error(err))
+// StoreCharacterSave validates a decompressed save written in mode and
+// stores it for a character as the server would, for tools that import saves.
+// The character should be offline, or the next save from the game replaces it.
+func StoreCharacterSave(charRepo CharacterRepo, charID uint32, data []byte, mode cfg.Mode) error {
+	if err := ValidateSaveData(data, mode); err != nil {
+		return err
 	}
+	save := &CharacterSaveData{CharID: charID, Mode: mode, decompSave: data}
+	save.updateStructWithSaveData()
+	return save.persist(charRepo)
 }
 
 func handleMsgMhfSexChanger(s *Session, p mhfpacket.MHFPacket) {
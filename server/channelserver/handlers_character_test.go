@@ -10,73 +10,9 @@ import (
 	cfg "erupe-ce/config"
 	"erupe-ce/network/mhfpacket"
 	"erupe-ce/server/channelserver/compression/nullcomp"
+	"erupe-ce/server/channelserver/savedata"
 )
 
-// TestGetPointers tests the pointer map generation for different game versions
-func TestGetPointers(t *testing.T) {
-	tests := []struct {
-		name       string
-		clientMode cfg.Mode
-		wantGender int
-		wantHR     int
-	}{
-		{
-			name:       "ZZ_version",
-			clientMode: cfg.ZZ,
-			wantGender: 81,
-			wantHR:     130550,
-		},
-		{
-			name:       "Z2_version",
-			clientMode: cfg.Z2,
-			wantGender: 81,
-			wantHR:     94550,
-		},
-		{
-			name:       "G10_version",
-			clientMode: cfg.G10,
-			wantGender: 81,
-			wantHR:     94550,
-		},
-		{
-			name:       "F5_version",
-			clientMode: cfg.F5,
-			wantGender: 81,
-			wantHR:     62550,
-		},
-		{
-			name:       "S6_version",
-			clientMode: cfg.S6,
-			wantGender: 81,
-			wantHR:     14550,
-		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			pointers := getPointers(tt.clientMode)
-
-			if pointers[pGender] != tt.wantGender {
-				t.Errorf("pGender = %d, want %d", pointers[pGender], tt.wantGender)
-			}
-
-			if pointers[pHR] != tt.wantHR {
-				t.Errorf("pHR = %d, want %d", pointers[pHR], tt.wantHR)
-			}
-
-			// Verify all required pointers exist
-			requiredPointers := []SavePointer{pGender, pRP, pHouseTier, pHouseData, pBookshelfData,
-				pGalleryData, pToreData, pGardenData, pPlaytime, pWeaponType, pWeaponID, pHR, lBookshelfData}
-
-			for _, ptr := range requiredPointers {
-				if _, exists := pointers[ptr]; !exists {
-					t.Errorf("pointer %v not found in map", ptr)
-				}
-			}
-		})
-	}
-}
-
 // TestCharacterSaveData_Compress tests savedata compression
 func TestCharacterSaveData_Compress(t *testing.T) {
 	tests := []struct {
@@ -261,7 +197,6 @@ func TestCharacterSaveData_updateStructWithSaveData(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			save := &CharacterSaveData{
 				Mode:           cfg.Z2,
-				Pointers:       getPointers(cfg.Z2),
 				decompSave:     tt.setupSaveData(),
 				IsNewCharacter: tt.isNewCharacter,
 			}
@@ -311,7 +246,6 @@ func TestCharacterSaveData_updateSaveDataWithStruct(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			save := &CharacterSaveData{
 				Mode:       cfg.G10,
-				Pointers:   getPointers(cfg.G10),
 				decompSave: make([]byte, 150000),
 				RP:         tt.rp,
 				KQF:        tt.kqf,
@@ -320,14 +254,14 @@ func TestCharacterSaveData_updateSaveDataWithStruct(t *testing.T) {
 			save.updateSaveDataWithStruct()
 
 			// Verify RP was written correctly
-			rpOffset := save.Pointers[pRP]
+			rpOffset := fieldOffset(cfg.G10, savedata.FieldRP)
 			gotRP := binary.LittleEndian.Uint16(save.decompSave[rpOffset : rpOffset+2])
 			if gotRP != tt.wantRP {
 				t.Errorf("RP in save data = %d, want %d", gotRP, tt.wantRP)
 			}
 
 			// Verify KQF was written correctly
-			kqfOffset := save.Pointers[pKQF]
+			kqfOffset := fieldOffset(cfg.G10, savedata.FieldKQF)
 			gotKQF := save.decompSave[kqfOffset : kqfOffset+8]
 			if !bytes.Equal(gotKQF, tt.kqf) {
 				t.Errorf("KQF in save data = %v, want %v", gotKQF, tt.kqf)
@@ -538,9 +472,9 @@ func TestGRPtoGR(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			gotGR := grpToGR(tt.grp)
+			gotGR := savedata.GRFromGRP(tt.grp)
 			if gotGR != tt.wantGR {
-				t.Errorf("grpToGR(%d) = %d, want %d", tt.grp, gotGR, tt.wantGR)
+				t.Errorf("savedata.GRFromGRP(%d) = %d, want %d", tt.grp, gotGR, tt.wantGR)
 			}
 		})
 	}
@@ -738,10 +672,28 @@ func TestGetCharacterSaveData_ConfigMode(t *testing.T) {
 			if result.Mode != mode {
 				t.Errorf("Mode = %v, want %v", result.Mode, mode)
 			}
-			expectedPointers := getPointers(mode)
-			if len(result.Pointers) != len(expectedPointers) {
-				t.Errorf("Pointers count = %d, want %d", len(result.Pointers), len(expectedPointers))
+			if result.Name != "ModeTest" {
+				t.Errorf("Name = %q, want ModeTest", result.Name)
 			}
 		})
 	}
 }
+
+func TestStoreCharacterSave(t *testing.T) {
+	repo := newMockCharacterRepo()
+	data := SaveFixture{Mode: cfg.G10, Name: "Imported", HR: 999, GR: 50}.Build()
+	if err := StoreCharacterSave(repo, 7, data, cfg.G10); err != nil {
+		t.Fatal(err)
+	}
+	if repo.saveCharacterDataCalls != 1 || repo.saveCharacterDataMode != cfg.G10 {
+		t.Errorf("stored %d times in mode %d", repo.saveCharacterDataCalls, repo.saveCharacterDataMode)
+	}
+
+	data[fieldOffset(cfg.G10, savedata.FieldGender)] = 9
+	if err := StoreCharacterSave(repo, 7, data, cfg.G10); !errors.Is(err, ErrSaveCorrupt) {
+		t.Errorf("corrupt save: err = %v, want ErrSaveCorrupt", err)
+	}
+	if repo.saveCharacterDataCalls != 1 {
+		t.Error("corrupt save was stored")
+	}
+}
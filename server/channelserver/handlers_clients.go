@@ -1,6 +1,8 @@
 package channelserver
 
 import (
+	"fmt"
+
 	"erupe-ce/common/byteframe"
 	"erupe-ce/common/stringsupport"
 	"erupe-ce/network/mhfpacket"
@@ -95,6 +97,7 @@ func handleMsgMhfOprMember(s *Session, p mhfpacket.MHFPacket) {
 		} else { // Friendlist
 			csv, err := s.server.charRepo.ReadString(s.charID, "friends")
 			if err == nil {
+				added := !pkt.Operation && !stringsupport.CSVContains(csv, int(cid))
 				if pkt.Operation {
 					csv = stringsupport.CSVRemove(csv, int(cid))
 				} else {
@@ -102,6 +105,8 @@ func handleMsgMhfOprMember(s *Session, p mhfpacket.MHFPacket) {
 				}
 				if err := s.server.charRepo.SaveString(s.charID, "friends", csv); err != nil {
 					s.logger.Error("Failed to update friends list", zap.Error(err))
+				} else if added {
+					s.server.DiscordNotifyOffline(cid, fmt.Sprintf("%s added you as a friend", s.Name))
 				}
 			}
 		}
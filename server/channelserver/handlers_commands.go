@@ -10,6 +10,7 @@ import (
 	"erupe-ce/network"
 	"erupe-ce/network/binpacket"
 	"erupe-ce/network/mhfpacket"
+	"erupe-ce/server/audit"
 	"fmt"
 	"math"
 	"slices"
@@ -24,13 +25,13 @@ import (
 var (
 	commands     map[string]cfg.Command
 	commandsOnce sync.Once
+	commandsMu   sync.RWMutex // Guards commands against ReloadCommands
 )
 
 func initCommands(cmds []cfg.Command, logger *zap.Logger) {
 	commandsOnce.Do(func() {
-		commands = make(map[string]cfg.Command)
+		commands = commandMap(cmds)
 		for _, cmd := range cmds {
-			commands[cmd.Name] = cmd
 			if cmd.Enabled {
 				logger.Info("Command registered", zap.String("name", cmd.Name), zap.String("prefix", cmd.Prefix), zap.Bool("enabled", true))
 			} else {
@@ -40,6 +41,24 @@ func initCommands(cmds []cfg.Command, logger *zap.Logger) {
 	})
 }
 
+func commandMap(cmds []cfg.Command) map[string]cfg.Command {
+	m := make(map[string]cfg.Command, len(cmds))
+	for _, cmd := range cmds {
+		m[cmd.Name] = cmd
+	}
+	return m
+}
+
+// ReloadCommands replaces the chat commands of every channel, waiting for
+// commands already running to finish.
+func ReloadCommands(cmds []cfg.Command) {
+	commandsOnce.Do(func() {})
+	m := commandMap(cmds)
+	commandsMu.Lock()
+	commands = m
+	commandsMu.Unlock()
+}
+
 func sendDisabledCommandMessage(s *Session, cmd cfg.Command) {
 	sendServerChatMessage(s, fmt.Sprintf(s.server.i18n.commands.disabled, cmd.Name))
 }
@@ -68,8 +87,54 @@ func sendServerChatMessage(s *Session, message string) {
 	s.QueueSendMHFNonBlocking(castedBin)
 }
 
+// auditChatCommand records a chat command in the audit log when running it
+// takes privileges: op-only commands, commands disabled for players, and
+// Rights, which changes the account's own rights. The caller holds commandsMu.
+func auditChatCommand(s *Session, args []string) {
+	if s.server.auditRepo == nil {
+		return
+	}
+	for name, cmd := range commands {
+		if cmd.Prefix != args[0] {
+			continue
+		}
+		if name != "Ban" && name != "Rights" && cmd.Enabled {
+			return
+		}
+		if (name == "Ban" || !cmd.Enabled) && !s.isOp() {
+			return
+		}
+		var target string
+		switch name {
+		case "Ban":
+			if len(args) > 1 {
+				target = args[1]
+			}
+		case "Rights":
+			target = strconv.FormatUint(uint64(s.userID), 10)
+		}
+		actor := fmt.Sprintf("%s (%d)", s.Name, s.charID)
+		params := map[string][]string{"args": args[1:]}
+		if err := s.server.auditRepo.Record(audit.SourceGame, actor, "command:"+name, target, params); err != nil {
+			s.logger.Error("Failed to record audit log entry", zap.Error(err))
+		}
+		return
+	}
+}
+
 func parseChatCommand(s *Session, command string) {
-	args := strings.Split(command[len(s.server.erupeConfig.CommandPrefix):], " ")
+	commandsMu.RLock()
+	defer commandsMu.RUnlock()
+	args := strings.Split(command[len(s.server.erupeConfig.Live().CommandPrefix):], " ")
+	if runChatCommand(s, args) {
+		auditChatCommand(s, args)
+	}
+}
+
+// runChatCommand runs a parsed chat command and reports whether it took
+// effect. Refused, malformed and failed commands return false. The caller
+// holds commandsMu.
+func runChatCommand(s *Session, args []string) bool {
 	switch args[0] {
 	case commands["Ban"].Prefix:
 		if s.isOp() {
@@ -96,7 +161,7 @@ func parseChatCommand(s *Session, command string) {
 						}
 					} else {
 						sendServerChatMessage(s, s.server.i18n.commands.ban.error)
-						return
+						return false
 					}
 				}
 				cid := mhfcid.ConvertCID(args[1])
@@ -104,28 +169,35 @@ func parseChatCommand(s *Session, command string) {
 					uid, uname, err := s.server.userRepo.GetByIDAndUsername(cid)
 					if err == nil {
 						if expiry.IsZero() {
-							if err := s.server.userRepo.BanUser(uid, nil); err != nil {
+							err = s.server.BanUser(uid, nil, ModerationSourceGame, s.Name)
+							if err != nil {
 								s.logger.Error("Failed to ban user", zap.Error(err))
 							}
 							sendServerChatMessage(s, fmt.Sprintf(s.server.i18n.commands.ban.success, uname))
+							return err == nil
 						} else {
-							if err := s.server.userRepo.BanUser(uid, &expiry); err != nil {
+							err = s.server.BanUser(uid, &expiry, ModerationSourceGame, s.Name)
+							if err != nil {
 								s.logger.Error("Failed to ban user with expiry", zap.Error(err))
 							}
 							sendServerChatMessage(s, fmt.Sprintf(s.server.i18n.commands.ban.success, uname)+fmt.Sprintf(s.server.i18n.commands.ban.length, expiry.Format(time.DateTime)))
+							return err == nil
 						}
-						s.server.DisconnectUser(uid)
 					} else {
 						sendServerChatMessage(s, s.server.i18n.commands.ban.noUser)
+						return false
 					}
 				} else {
 					sendServerChatMessage(s, s.server.i18n.commands.ban.invalid)
+					return false
 				}
 			} else {
 				sendServerChatMessage(s, s.server.i18n.commands.ban.error)
+				return false
 			}
 		} else {
 			sendServerChatMessage(s, s.server.i18n.commands.noOp)
+			return false
 		}
 	case commands["Timer"].Prefix:
 		if commands["Timer"].Enabled || s.isOp() {
@@ -135,6 +207,7 @@ func parseChatCommand(s *Session, command string) {
 			}
 			if err := s.server.userRepo.SetTimer(s.userID, !state); err != nil {
 				s.logger.Error("Failed to update timer setting", zap.Error(err))
+				return false
 			}
 			if state {
 				sendServerChatMessage(s, s.server.i18n.commands.timer.disabled)
@@ -143,6 +216,7 @@ func parseChatCommand(s *Session, command string) {
 			}
 		} else {
 			sendDisabledCommandMessage(s, commands["Timer"])
+			return false
 		}
 	case commands["PSN"].Prefix:
 		if commands["PSN"].Enabled || s.isOp() {
@@ -153,17 +227,22 @@ func parseChatCommand(s *Session, command string) {
 				}
 				if exists == 0 {
 					err := s.server.userRepo.SetPSNID(s.userID, args[1])
-					if err == nil {
-						sendServerChatMessage(s, fmt.Sprintf(s.server.i18n.commands.psn.success, args[1]))
+					if err != nil {
+						s.logger.Error("Failed to set PSN ID", zap.Error(err))
+						return false
 					}
+					sendServerChatMessage(s, fmt.Sprintf(s.server.i18n.commands.psn.success, args[1]))
 				} else {
 					sendServerChatMessage(s, s.server.i18n.commands.psn.exists)
+					return false
 				}
 			} else {
 				sendServerChatMessage(s, fmt.Sprintf(s.server.i18n.commands.psn.error, commands["PSN"].Prefix))
+				return false
 			}
 		} else {
 			sendDisabledCommandMessage(s, commands["PSN"])
+			return false
 		}
 	case commands["Reload"].Prefix:
 		if commands["Reload"].Enabled || s.isOp() {
@@ -178,7 +257,7 @@ func parseChatCommand(s *Session, command string) {
 				deleteNotif.WriteUint16(uint16(temp.Opcode()))
 				_ = temp.Build(deleteNotif, s.clientContext)
 			}
-			for _, session := range s.server.sessions {
+			for _, session := range s.server.sessions.Snapshot() {
 				if s == session {
 					continue
 				}
@@ -190,7 +269,7 @@ func parseChatCommand(s *Session, command string) {
 			s.QueueSendNonBlocking(deleteNotif.Data())
 			time.Sleep(500 * time.Millisecond)
 			reloadNotif := byteframe.NewByteFrame()
-			for _, session := range s.server.sessions {
+			for _, session := range s.server.sessions.Snapshot() {
 				if s == session {
 					continue
 				}
@@ -225,11 +304,13 @@ func parseChatCommand(s *Session, command string) {
 			s.QueueSendNonBlocking(reloadNotif.Data())
 		} else {
 			sendDisabledCommandMessage(s, commands["Reload"])
+			return false
 		}
 	case commands["KeyQuest"].Prefix:
 		if commands["KeyQuest"].Enabled || s.isOp() {
 			if s.server.erupeConfig.RealClientMode < cfg.G10 {
 				sendServerChatMessage(s, s.server.i18n.commands.kqf.version)
+				return false
 			} else {
 				if len(args) > 1 {
 					switch args[1] {
@@ -240,19 +321,25 @@ func parseChatCommand(s *Session, command string) {
 							hexd, err := hex.DecodeString(args[2])
 							if err != nil {
 								sendServerChatMessage(s, fmt.Sprintf(s.server.i18n.commands.kqf.set.error, commands["KeyQuest"].Prefix))
-								return
+								return false
 							}
 							s.kqf = hexd
 							s.kqfOverride = true
 							sendServerChatMessage(s, s.server.i18n.commands.kqf.set.success)
 						} else {
 							sendServerChatMessage(s, fmt.Sprintf(s.server.i18n.commands.kqf.set.error, commands["KeyQuest"].Prefix))
+							return false
 						}
+					default:
+						return false
 					}
+				} else {
+					return false
 				}
 			}
 		} else {
 			sendDisabledCommandMessage(s, commands["KeyQuest"])
+			return false
 		}
 	case commands["Rights"].Prefix:
 		if commands["Rights"].Enabled || s.isOp() {
@@ -260,19 +347,23 @@ func parseChatCommand(s *Session, command string) {
 				v, err := strconv.Atoi(args[1])
 				if err != nil {
 					sendServerChatMessage(s, fmt.Sprintf(s.server.i18n.commands.rights.error, commands["Rights"].Prefix))
-					return
+					return false
 				}
 				err = s.server.userRepo.SetRights(s.userID, uint32(v))
 				if err == nil {
+					s.server.DiscordSyncRoles(s.userID)
 					sendServerChatMessage(s, fmt.Sprintf(s.server.i18n.commands.rights.success, v))
 				} else {
 					sendServerChatMessage(s, fmt.Sprintf(s.server.i18n.commands.rights.error, commands["Rights"].Prefix))
+					return false
 				}
 			} else {
 				sendServerChatMessage(s, fmt.Sprintf(s.server.i18n.commands.rights.error, commands["Rights"].Prefix))
+				return false
 			}
 		} else {
 			sendDisabledCommandMessage(s, commands["Rights"])
+			return false
 		}
 	case commands["Course"].Prefix:
 		if commands["Course"].Enabled || s.isOp() {
@@ -301,23 +392,27 @@ func parseChatCommand(s *Session, command string) {
 								}
 								rightsInt, err := s.server.userRepo.GetRights(s.userID)
 								if err == nil {
-									if err := s.server.userRepo.SetRights(s.userID, rightsInt+delta); err != nil {
+									if err = s.server.userRepo.SetRights(s.userID, rightsInt+delta); err != nil {
 										s.logger.Error("Failed to update user rights", zap.Error(err))
 									}
 								}
 								updateRights(s)
-							} else {
-								sendServerChatMessage(s, fmt.Sprintf(s.server.i18n.commands.course.locked, course.Aliases()[0]))
+								s.server.DiscordSyncRoles(s.userID)
+								return err == nil
 							}
-							return
+							sendServerChatMessage(s, fmt.Sprintf(s.server.i18n.commands.course.locked, course.Aliases()[0]))
+							return false
 						}
 					}
 				}
+				return false
 			} else {
 				sendServerChatMessage(s, fmt.Sprintf(s.server.i18n.commands.course.error, commands["Course"].Prefix))
+				return false
 			}
 		} else {
 			sendDisabledCommandMessage(s, commands["Course"])
+			return false
 		}
 	case commands["Raviente"].Prefix:
 		if commands["Raviente"].Enabled || s.isOp() {
@@ -331,6 +426,7 @@ func parseChatCommand(s *Session, command string) {
 							s.notifyRavi()
 						} else {
 							sendServerChatMessage(s, s.server.i18n.commands.ravi.start.error)
+							return false
 						}
 					case "cm", "check", "checkmultiplier", "multiplier":
 						sendServerChatMessage(s, fmt.Sprintf(s.server.i18n.commands.ravi.multiplier, s.server.GetRaviMultiplier()))
@@ -343,6 +439,7 @@ func parseChatCommand(s *Session, command string) {
 									s.server.raviente.state[28] = 0
 								} else {
 									sendServerChatMessage(s, s.server.i18n.commands.ravi.res.error)
+									return false
 								}
 							case "ss", "sendsed":
 								sendServerChatMessage(s, s.server.i18n.commands.ravi.sed.success)
@@ -357,18 +454,23 @@ func parseChatCommand(s *Session, command string) {
 							}
 						} else {
 							sendServerChatMessage(s, s.server.i18n.commands.ravi.version)
+							return false
 						}
 					default:
 						sendServerChatMessage(s, s.server.i18n.commands.ravi.error)
+						return false
 					}
 				} else {
 					sendServerChatMessage(s, s.server.i18n.commands.ravi.noPlayers)
+					return false
 				}
 			} else {
 				sendServerChatMessage(s, s.server.i18n.commands.ravi.error)
+				return false
 			}
 		} else {
 			sendDisabledCommandMessage(s, commands["Raviente"])
+			return false
 		}
 	case commands["Teleport"].Prefix:
 		if commands["Teleport"].Enabled || s.isOp() {
@@ -376,12 +478,12 @@ func parseChatCommand(s *Session, command string) {
 				x, err := strconv.ParseInt(args[1], 10, 16)
 				if err != nil {
 					sendServerChatMessage(s, fmt.Sprintf(s.server.i18n.commands.teleport.error, commands["Teleport"].Prefix))
-					return
+					return false
 				}
 				y, err := strconv.ParseInt(args[2], 10, 16)
 				if err != nil {
 					sendServerChatMessage(s, fmt.Sprintf(s.server.i18n.commands.teleport.error, commands["Teleport"].Prefix))
-					return
+					return false
 				}
 				payload := byteframe.NewByteFrame()
 				payload.SetLE()
@@ -397,9 +499,11 @@ func parseChatCommand(s *Session, command string) {
 				sendServerChatMessage(s, fmt.Sprintf(s.server.i18n.commands.teleport.success, x, y))
 			} else {
 				sendServerChatMessage(s, fmt.Sprintf(s.server.i18n.commands.teleport.error, commands["Teleport"].Prefix))
+				return false
 			}
 		} else {
 			sendDisabledCommandMessage(s, commands["Teleport"])
+			return false
 		}
 	case commands["Discord"].Prefix:
 		if commands["Discord"].Enabled || s.isOp() {
@@ -410,11 +514,13 @@ func parseChatCommand(s *Session, command string) {
 				_token = fmt.Sprintf("%x-%x", randToken[:2], randToken[2:])
 				if err := s.server.userRepo.SetDiscordToken(s.userID, _token); err != nil {
 					s.logger.Error("Failed to update discord token", zap.Error(err))
+					return false
 				}
 			}
 			sendServerChatMessage(s, fmt.Sprintf(s.server.i18n.commands.discord.success, _token))
 		} else {
 			sendDisabledCommandMessage(s, commands["Discord"])
+			return false
 		}
 	case commands["Playtime"].Prefix:
 		if commands["Playtime"].Enabled || s.isOp() {
@@ -422,16 +528,37 @@ func parseChatCommand(s *Session, command string) {
 			sendServerChatMessage(s, fmt.Sprintf(s.server.i18n.commands.playtime, playtime/60/60, playtime/60%60, playtime%60))
 		} else {
 			sendDisabledCommandMessage(s, commands["Playtime"])
+			return false
+		}
+	case commands["Allies"].Prefix:
+		if commands["Allies"].Enabled || s.isOp() {
+			presence := s.server.federation.Presence()
+			if len(presence) == 0 {
+				sendServerChatMessage(s, s.server.i18n.commands.allies.none)
+			}
+			for _, p := range presence {
+				message := fmt.Sprintf(s.server.i18n.commands.allies.online, p.Server, len(p.Players), strings.Join(p.Players, ", "))
+				for _, line := range splitChatLines(message, 61) {
+					sendServerChatMessage(s, line)
+				}
+			}
+		} else {
+			sendDisabledCommandMessage(s, commands["Allies"])
+			return false
 		}
 	case commands["Help"].Prefix:
 		if commands["Help"].Enabled || s.isOp() {
 			for _, command := range commands {
 				if command.Enabled || s.isOp() {
-					sendServerChatMessage(s, fmt.Sprintf("%s%s: %s", s.server.erupeConfig.CommandPrefix, command.Prefix, command.Description))
+					sendServerChatMessage(s, fmt.Sprintf("%s%s: %s", s.server.erupeConfig.Live().CommandPrefix, command.Prefix, command.Description))
 				}
 			}
 		} else {
 			sendDisabledCommandMessage(s, commands["Help"])
+			return false
 		}
+	default:
+		return false
 	}
+	return true
 }
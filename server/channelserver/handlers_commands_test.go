@@ -10,6 +10,7 @@ import (
 	"erupe-ce/common/mhfcourse"
 	cfg "erupe-ce/config"
 	"erupe-ce/network/clientctx"
+	"erupe-ce/server/audit"
 
 	"go.uber.org/zap"
 )
@@ -108,6 +109,9 @@ func createCommandSession(repo *mockUserRepoCommands) *Session {
 	server.erupeConfig.CommandPrefix = "!"
 	server.userRepo = repo
 	server.charRepo = newMockCharacterRepo()
+	server.moderationRepo = &mockModerationRepo{}
+	server.auditRepo = &mockAuditRepo{}
+	ensureModerationService(server)
 	session := createMockSession(1, server)
 	session.userID = 1
 	return session
@@ -354,6 +358,118 @@ func TestParseChatCommand_Ban_Success(t *testing.T) {
 	}
 }
 
+func TestParseChatCommand_Ban_RecordsModerationLog(t *testing.T) {
+	setupCommandsMap(true)
+	repo := &mockUserRepoCommands{
+		opResult:  true,
+		foundUID:  42,
+		foundName: "TestUser",
+	}
+	s := createCommandSession(repo)
+	modRepo := s.server.moderationRepo.(*mockModerationRepo)
+
+	parseChatCommand(s, "!ban 211111")
+
+	if len(modRepo.log) != 1 {
+		t.Fatalf("log entries = %d, want 1", len(modRepo.log))
+	}
+	entry := modRepo.log[0]
+	if entry.userID != 42 || entry.action != ModerationBan || entry.source != ModerationSourceGame {
+		t.Errorf("log entry = %+v, want ban of 42 from game", entry)
+	}
+	if entry.actor != s.Name {
+		t.Errorf("actor = %q, want %q", entry.actor, s.Name)
+	}
+}
+
+func TestParseChatCommand_Ban_RecordsAuditLog(t *testing.T) {
+	setupCommandsMap(true)
+	repo := &mockUserRepoCommands{
+		opResult:  true,
+		foundUID:  42,
+		foundName: "TestUser",
+	}
+	s := createCommandSession(repo)
+	auditRepo := s.server.auditRepo.(*mockAuditRepo)
+
+	parseChatCommand(s, "!ban 211111 30d")
+
+	if len(auditRepo.entries) != 1 {
+		t.Fatalf("audit entries = %d, want 1", len(auditRepo.entries))
+	}
+	entry := auditRepo.entries[0]
+	if entry.source != audit.SourceGame || entry.action != "command:Ban" || entry.target != "211111" {
+		t.Errorf("audit entry = %+v, want a game command:Ban of 211111", entry)
+	}
+	if entry.actor != "TestPlayer (1)" {
+		t.Errorf("actor = %q, want %q", entry.actor, "TestPlayer (1)")
+	}
+}
+
+func TestParseChatCommand_Ban_NonOpNotAudited(t *testing.T) {
+	setupCommandsMap(true)
+	s := createCommandSession(&mockUserRepoCommands{opResult: false})
+
+	parseChatCommand(s, "!ban 211111")
+
+	if n := len(s.server.auditRepo.(*mockAuditRepo).entries); n != 0 {
+		t.Errorf("audit entries = %d, want 0 for a refused command", n)
+	}
+}
+
+func TestParseChatCommand_AuditsPrivilegedCommandsOnly(t *testing.T) {
+	tests := []struct {
+		name    string
+		enabled bool
+		op      bool
+		command string
+		audited bool
+	}{
+		{"rights is always audited", true, false, "!rights 30", true},
+		{"enabled command", true, false, "!playtime", false},
+		{"disabled command run by op", false, true, "!playtime", true},
+		{"disabled command refused", false, false, "!playtime", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setupCommandsMap(tt.enabled)
+			s := createCommandSession(&mockUserRepoCommands{opResult: tt.op})
+
+			parseChatCommand(s, tt.command)
+
+			if got := len(s.server.auditRepo.(*mockAuditRepo).entries) == 1; got != tt.audited {
+				t.Errorf("audited = %v, want %v", got, tt.audited)
+			}
+		})
+	}
+}
+
+func TestParseChatCommand_FailedCommandsNotAudited(t *testing.T) {
+	tests := []struct {
+		name    string
+		repo    *mockUserRepoCommands
+		command string
+	}{
+		{"ban of unknown user", &mockUserRepoCommands{opResult: true, findErr: errors.New("not found")}, "!ban 211111"},
+		{"ban that fails", &mockUserRepoCommands{opResult: true, foundUID: 42, banErr: errors.New("db error")}, "!ban 211111"},
+		{"ban with bad length", &mockUserRepoCommands{opResult: true}, "!ban 211111 soon"},
+		{"rights with bad value", &mockUserRepoCommands{}, "!rights abc"},
+		{"rights without value", &mockUserRepoCommands{}, "!rights"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setupCommandsMap(true)
+			s := createCommandSession(tt.repo)
+
+			parseChatCommand(s, tt.command)
+
+			if n := len(s.server.auditRepo.(*mockAuditRepo).entries); n != 0 {
+				t.Errorf("audit entries = %d, want 0 for a failed command", n)
+			}
+		})
+	}
+}
+
 func TestParseChatCommand_Ban_WithDuration(t *testing.T) {
 	setupCommandsMap(true)
 	repo := &mockUserRepoCommands{
@@ -967,7 +1083,7 @@ func TestParseChatCommand_Reload_WithOtherPlayersAndObjects(t *testing.T) {
 		server:        s.server,
 		logger:        otherLogger,
 	}
-	s.server.sessions[&net.TCPConn{}] = other
+	s.server.sessions.Store(&net.TCPConn{}, other)
 
 	// Stage with an object owned by the other session
 	s.stage = &Stage{
@@ -1216,6 +1332,21 @@ func TestInitCommands(t *testing.T) {
 	}
 }
 
+func TestReloadCommands(t *testing.T) {
+	saved := commands
+	defer func() { commands = saved }()
+	commands = map[string]cfg.Command{"Old": {Name: "Old", Prefix: "old", Enabled: true}}
+
+	ReloadCommands([]cfg.Command{{Name: "TestCmd", Prefix: "test", Enabled: true}})
+
+	if _, ok := commands["Old"]; ok {
+		t.Error("removed command is still registered")
+	}
+	if commands["TestCmd"].Prefix != "test" {
+		t.Errorf("TestCmd prefix = %q, want %q", commands["TestCmd"].Prefix, "test")
+	}
+}
+
 // --- sendServerChatMessage ---
 
 func TestSendServerChatMessage_CommandsContext(t *testing.T) {
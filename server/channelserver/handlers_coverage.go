@@ -0,0 +1,176 @@
+package channelserver
+
+import (
+	"sync"
+
+	"erupe-ce/network"
+)
+
+// HandlerState is how the channel server handles packets with an opcode.
+type HandlerState int
+
+const (
+	HandlerMissing     HandlerState = iota // No handler; the packet is logged and dropped
+	HandlerStub                            // A handler that does nothing with the packet
+	HandlerImplemented                     // A handler that acts on the packet
+)
+
+func (h HandlerState) String() string {
+	switch h {
+	case HandlerStub:
+		return "stub"
+	case HandlerImplemented:
+		return "implemented"
+	}
+	return "missing"
+}
+
+var handlerTableOnce = sync.OnceValue(buildHandlerTable)
+
+// Handler reports how the channel server handles packets with opcode id,
+// for tools comparing captures to what the server implements.
+func Handler(id network.PacketID) HandlerState {
+	if _, ok := handlerTableOnce()[id]; !ok {
+		return HandlerMissing
+	}
+	if stubHandlers[id] {
+		return HandlerStub
+	}
+	return HandlerImplemented
+}
+
+// stubHandlers are the opcodes whose handler has an empty body.
+// TestStubHandlerList fails when the list is out of date.
+var stubHandlers = map[network.PacketID]bool{
+	network.MSG_HEAD:                           true,
+	network.MSG_SYS_reserve01:                  true,
+	network.MSG_SYS_reserve02:                  true,
+	network.MSG_SYS_reserve03:                  true,
+	network.MSG_SYS_reserve04:                  true,
+	network.MSG_SYS_reserve05:                  true,
+	network.MSG_SYS_reserve06:                  true,
+	network.MSG_SYS_reserve07:                  true,
+	network.MSG_SYS_ADD_OBJECT:                 true,
+	network.MSG_SYS_DEL_OBJECT:                 true,
+	network.MSG_SYS_DISP_OBJECT:                true,
+	network.MSG_SYS_HIDE_OBJECT:                true,
+	network.MSG_SYS_reserve0C:                  true,
+	network.MSG_SYS_reserve0D:                  true,
+	network.MSG_SYS_reserve0E:                  true,
+	network.MSG_SYS_EXTEND_THRESHOLD:           true,
+	network.MSG_SYS_END:                        true,
+	network.MSG_SYS_NOP:                        true,
+	network.MSG_SYS_ACK:                        true,
+	network.MSG_SYS_SET_STATUS:                 true,
+	network.MSG_SYS_HIDE_CLIENT:                true,
+	network.MSG_SYS_CASTED_BINARY:              true,
+	network.MSG_SYS_ECHO:                       true,
+	network.MSG_SYS_STAGE_DESTRUCT:             true,
+	network.MSG_SYS_LEAVE_STAGE:                true,
+	network.MSG_SYS_CREATE_MUTEX:               true,
+	network.MSG_SYS_CREATE_OPEN_MUTEX:          true,
+	network.MSG_SYS_DELETE_MUTEX:               true,
+	network.MSG_SYS_OPEN_MUTEX:                 true,
+	network.MSG_SYS_CLOSE_MUTEX:                true,
+	network.MSG_SYS_RELEASE_SEMAPHORE:          true,
+	network.MSG_SYS_NOTIFY_REGISTER:            true,
+	network.MSG_SYS_DELETE_OBJECT:              true,
+	network.MSG_SYS_ROTATE_OBJECT:              true,
+	network.MSG_SYS_DUPLICATE_OBJECT:           true,
+	network.MSG_SYS_GET_OBJECT_BINARY:          true,
+	network.MSG_SYS_GET_OBJECT_OWNER:           true,
+	network.MSG_SYS_UPDATE_OBJECT_BINARY:       true,
+	network.MSG_SYS_CLEANUP_OBJECT:             true,
+	network.MSG_SYS_reserve4A:                  true,
+	network.MSG_SYS_reserve4B:                  true,
+	network.MSG_SYS_reserve4C:                  true,
+	network.MSG_SYS_reserve4D:                  true,
+	network.MSG_SYS_reserve4E:                  true,
+	network.MSG_SYS_reserve4F:                  true,
+	network.MSG_SYS_INSERT_USER:                true,
+	network.MSG_SYS_DELETE_USER:                true,
+	network.MSG_SYS_NOTIFY_USER_BINARY:         true,
+	network.MSG_SYS_reserve55:                  true,
+	network.MSG_SYS_reserve56:                  true,
+	network.MSG_SYS_reserve57:                  true,
+	network.MSG_SYS_UPDATE_RIGHT:               true,
+	network.MSG_SYS_AUTH_QUERY:                 true,
+	network.MSG_SYS_AUTH_DATA:                  true,
+	network.MSG_SYS_AUTH_TERMINAL:              true,
+	network.MSG_SYS_reserve5C:                  true,
+	network.MSG_SYS_reserve5E:                  true,
+	network.MSG_SYS_reserve5F:                  true,
+	network.MSG_SYS_reserve71:                  true,
+	network.MSG_SYS_reserve72:                  true,
+	network.MSG_SYS_reserve73:                  true,
+	network.MSG_SYS_reserve74:                  true,
+	network.MSG_SYS_reserve75:                  true,
+	network.MSG_SYS_reserve76:                  true,
+	network.MSG_SYS_reserve77:                  true,
+	network.MSG_SYS_reserve78:                  true,
+	network.MSG_SYS_reserve79:                  true,
+	network.MSG_SYS_reserve7A:                  true,
+	network.MSG_SYS_reserve7B:                  true,
+	network.MSG_SYS_reserve7C:                  true,
+	network.MSG_CA_EXCHANGE_ITEM:               true,
+	network.MSG_SYS_reserve7E:                  true,
+	network.MSG_MHF_SERVER_COMMAND:             true,
+	network.MSG_MHF_SHUT_CLIENT:                true,
+	network.MSG_MHF_SET_LOGINWINDOW:            true,
+	network.MSG_SYS_TRANS_BINARY:               true,
+	network.MSG_SYS_COLLECT_BINARY:             true,
+	network.MSG_SYS_GET_STATE:                  true,
+	network.MSG_SYS_SERIALIZE:                  true,
+	network.MSG_SYS_ENUMLOBBY:                  true,
+	network.MSG_SYS_ENUMUSER:                   true,
+	network.MSG_SYS_INFOKYSERVER:               true,
+	network.MSG_MHF_GET_CA_UNIQUE_ID:           true,
+	network.MSG_MHF_SET_CA_ACHIEVEMENT:         true,
+	network.MSG_MHF_UPDATE_GUILD:               true,
+	network.MSG_MHF_GET_EXTRA_INFO:             true,
+	network.MSG_MHF_GET_COG_INFO:               true,
+	network.MSG_MHF_ENTER_TOURNAMENT_QUEST:     true,
+	network.MSG_MHF_RESET_ACHIEVEMENT:          true,
+	network.MSG_MHF_PAYMENT_ACHIEVEMENT:        true,
+	network.MSG_MHF_DISPLAYED_ACHIEVEMENT:      true,
+	network.MSG_MHF_STAMPCARD_PRIZE:            true,
+	network.MSG_MHF_UPDATE_GUILDCARD:           true,
+	network.MSG_MHF_ACCEPT_READ_REWARD:         true,
+	network.MSG_MHF_KICK_EXPORT_FORCE:          true,
+	network.MSG_MHF_reserve10F:                 true,
+	network.MSG_MHF_REGIST_SPABI_TIME:          true,
+	network.MSG_MHF_DEBUG_POST_VALUE:           true,
+	network.MSG_MHF_POST_RYOUDAMA:              true,
+	network.MSG_MHF_GET_DAILY_MISSION_MASTER:   true,
+	network.MSG_MHF_GET_DAILY_MISSION_PERSONAL: true,
+	network.MSG_MHF_SET_DAILY_MISSION_PERSONAL: true,
+	network.MSG_MHF_GET_CA_ACHIEVEMENT_HIST:    true,
+	network.MSG_MHF_USE_REWARD_SONG:            true,
+	network.MSG_MHF_ADD_REWARD_SONG_COUNT:      true,
+	network.MSG_MHF_GET_UD_TACTICS_LOG:         true,
+	network.MSG_MHF_SET_UD_TACTICS_FOLLOWER:    true,
+	network.MSG_MHF_USE_UD_SHOP_COIN:           true,
+	network.MSG_SYS_reserve180:                 true,
+	network.MSG_MHF_GET_RESTRICTION_EVENT:      true,
+	network.MSG_SYS_reserve18E:                 true,
+	network.MSG_SYS_reserve18F:                 true,
+	network.MSG_SYS_reserve192:                 true,
+	network.MSG_SYS_reserve193:                 true,
+	network.MSG_SYS_reserve194:                 true,
+	network.MSG_SYS_reserve19B:                 true,
+	network.MSG_SYS_reserve19E:                 true,
+	network.MSG_SYS_reserve19F:                 true,
+	network.MSG_MHF_UPDATE_FORCE_GUILD_RANK:    true,
+	network.MSG_MHF_RESET_TITLE:                true,
+	network.MSG_SYS_reserve1A4:                 true,
+	network.MSG_SYS_reserve1A6:                 true,
+	network.MSG_SYS_reserve1A7:                 true,
+	network.MSG_SYS_reserve1A8:                 true,
+	network.MSG_SYS_reserve1A9:                 true,
+	network.MSG_SYS_reserve1AA:                 true,
+	network.MSG_SYS_reserve1AB:                 true,
+	network.MSG_SYS_reserve1AC:                 true,
+	network.MSG_SYS_reserve1AD:                 true,
+	network.MSG_SYS_reserve1AE:                 true,
+	network.MSG_SYS_reserve1AF:                 true,
+}
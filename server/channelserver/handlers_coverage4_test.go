@@ -4,6 +4,7 @@ import (
 	"testing"
 
 	"erupe-ce/network/mhfpacket"
+	"erupe-ce/server/channelserver/savedata"
 )
 
 // =============================================================================
@@ -194,9 +195,9 @@ func TestGrpToGR(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := grpToGR(tt.input)
+			got := savedata.GRFromGRP(tt.input)
 			if got != tt.expected {
-				t.Errorf("grpToGR(%d) = %d, want %d", tt.input, got, tt.expected)
+				t.Errorf("savedata.GRFromGRP(%d) = %d, want %d", tt.input, got, tt.expected)
 			}
 		})
 	}
@@ -1,18 +1,15 @@
 package channelserver
 
 import (
-	"erupe-ce/common/stringsupport"
-	cfg "erupe-ce/config"
-	"fmt"
-	"io"
 	"os"
 	"path/filepath"
 	"time"
 
-	"erupe-ce/common/byteframe"
 	"erupe-ce/network/mhfpacket"
 	"erupe-ce/server/channelserver/compression/deltacomp"
 	"erupe-ce/server/channelserver/compression/nullcomp"
+	"erupe-ce/server/channelserver/savedata"
+	"erupe-ce/server/savedump"
 
 	"go.uber.org/zap"
 )
@@ -44,7 +41,6 @@ func handleMsgMhfSavedata(s *Session, p mhfpacket.MHFPacket) {
 		s.logger.Info("Diffing...")
 		characterSaveData.decompSave = deltacomp.ApplyDataDiff(diff, characterSaveData.decompSave)
 	} else {
-		dumpSaveData(s, pkt.RawDataPayload, "savedata")
 		// Regular blob update.
 		saveData, err := nullcomp.Decompress(pkt.RawDataPayload)
 		if err != nil {
@@ -53,7 +49,7 @@ func handleMsgMhfSavedata(s *Session, p mhfpacket.MHFPacket) {
 			return
 		}
 		if s.server.erupeConfig.SaveDumps.RawEnabled {
-			dumpSaveData(s, saveData, "raw-savedata")
+			dumpSaveData(s, saveData, savedump.KindRaw)
 		}
 		s.logger.Info("Updating save with blob")
 		characterSaveData.decompSave = saveData
@@ -90,84 +86,39 @@ func handleMsgMhfSavedata(s *Session, p mhfpacket.MHFPacket) {
 		characterSaveData.updateSaveDataWithStruct()
 	}
 
-	if characterSaveData.Name == s.Name || s.server.erupeConfig.RealClientMode <= cfg.S10 {
-		characterSaveData.Save(s)
-		s.logger.Info("Wrote recompressed savedata back to DB.")
-	} else {
-		_ = s.rawConn.Close()
-		s.logger.Warn("Save cancelled due to corruption.")
-		if s.server.erupeConfig.DeleteOnSaveCorruption {
-			if err := s.server.charRepo.SetDeleted(s.charID); err != nil {
-				s.logger.Error("Failed to mark character as deleted", zap.Error(err))
-			}
-		}
+	// Keep the stored save and the backup of it if the new one is corrupt.
+	if err := ValidateSaveData(characterSaveData.decompSave, characterSaveData.Mode); err != nil {
+		s.logger.Warn("Save cancelled due to corruption", zap.Error(err), zap.Uint32("charID", s.charID))
+		dumpSaveData(s, pkt.RawDataPayload, savedump.KindRejected)
+		sendServerChatMessage(s, s.server.i18n.save.rejected)
+		doAckSimpleFail(s, pkt.AckHandle, make([]byte, 4))
 		return
 	}
+	characterSaveData.Save(s)
+	s.logger.Info("Wrote recompressed savedata back to DB.")
+	backupSaveData(s, characterSaveData)
 	if err := s.server.charRepo.SaveString(s.charID, "name", characterSaveData.Name); err != nil {
 		s.logger.Error("Failed to update character name in db", zap.Error(err))
 	}
 	doAckSimpleSucceed(s, pkt.AckHandle, make([]byte, 4))
 }
 
-func grpToGR(n int) uint16 {
-	var gr int
-	a := []int{208750, 593400, 993400, 1400900, 2315900, 3340900, 4505900, 5850900, 7415900, 9230900, 11345900, 100000000}
-	b := []int{7850, 8000, 8150, 9150, 10250, 11650, 13450, 15650, 18150, 21150, 23950}
-	c := []int{51, 100, 150, 200, 300, 400, 500, 600, 700, 800, 900}
-
-	for i := 0; i < len(a); i++ {
-		if n < a[i] {
-			if i == 0 {
-				for {
-					n -= 500
-					if n <= 500 {
-						if n < 0 {
-							i--
-						}
-						break
-					} else {
-						i++
-						for j := 0; j < i; j++ {
-							n -= 150
-						}
-					}
-				}
-				gr = i + 2
-			} else {
-				n -= a[i-1]
-				gr = c[i-1]
-				gr += n / b[i-1]
-			}
-			break
-		}
-	}
-	return uint16(gr)
-}
-
-func dumpSaveData(s *Session, data []byte, suffix string) {
-	if !s.server.erupeConfig.SaveDumps.Enabled {
+// dumpSaveData writes data to the SaveDumps directory as a dump of kind for
+// the session's character, recording the stage and quest it was written in.
+func dumpSaveData(s *Session, data []byte, kind string) {
+	if !s.server.saveDumps.Enabled() {
 		return
-	} else {
-		dir := filepath.Join(s.server.erupeConfig.SaveDumps.OutputDir, fmt.Sprintf("%d", s.charID))
-		path := filepath.Join(s.server.erupeConfig.SaveDumps.OutputDir, fmt.Sprintf("%d", s.charID), fmt.Sprintf("%d_%s.bin", s.charID, suffix))
-		_, err := os.Stat(dir)
-		if err != nil {
-			if os.IsNotExist(err) {
-				err = os.MkdirAll(dir, os.ModePerm)
-				if err != nil {
-					s.logger.Error("Error dumping savedata, could not create folder")
-					return
-				}
-			} else {
-				s.logger.Error("Error dumping savedata")
-				return
-			}
-		}
-		err = os.WriteFile(path, data, 0644)
-		if err != nil {
-			s.logger.Error("Error dumping savedata, could not write file", zap.Error(err))
+	}
+	meta := savedump.Meta{Mode: s.server.erupeConfig.RealClientMode}
+	if s.stage != nil {
+		meta.Stage = s.stage.id
+		if isQuestStage(s.stage.id) {
+			meta.Quest = s.questFile
 		}
 	}
+	if _, err := s.server.saveDumps.Write(s.charID, kind, data, meta); err != nil {
+		s.logger.Error("Error dumping savedata", zap.Error(err), zap.String("kind", kind))
+	}
 }
 
 func handleMsgMhfLoaddata(s *Session, p mhfpacket.MHFPacket) {
@@ -178,23 +129,33 @@ func handleMsgMhfLoaddata(s *Session, p mhfpacket.MHFPacket) {
 		return
 	}
 
+	// Cached saves from a previous channel must reach the database first.
+	if s.server.saveCache != nil {
+		if err := s.server.saveCache.Flush(s.charID); err != nil {
+			s.logger.Error("Failed to flush cached savedata", zap.Error(err), zap.Uint32("charID", s.charID))
+		}
+	}
+
+	if s.server.saveWorkers != nil {
+		s.server.saveWorkers.Wait(s.charID)
+	}
+
 	data, err := s.server.charRepo.LoadColumn(s.charID, "savedata")
 	if err != nil || len(data) == 0 {
 		s.logger.Warn("Failed to load savedata", zap.Uint32("charID", s.charID), zap.Error(err))
 		_ = s.rawConn.Close() // Terminate the connection
 		return
 	}
+	data = loadStoredSave(s, s.charID, data)
 	doAckBufSucceed(s, pkt.AckHandle, data)
 
 	decompSaveData, err := nullcomp.Decompress(data)
 	if err != nil {
 		s.logger.Error("Failed to decompress savedata", zap.Error(err))
 	}
-	bf := byteframe.NewByteFrameFromBytes(decompSaveData)
-	_, _ = bf.Seek(88, io.SeekStart)
-	name := bf.ReadNullTerminatedBytes()
-	s.server.userBinary.Set(s.charID, 1, append(name, []byte{0x00}...))
-	s.Name = stringsupport.SJISToUTF8Lossy(name)
+	save := savedata.New(decompSaveData, s.server.erupeConfig.RealClientMode)
+	s.server.userBinary.Set(s.charID, 1, append(save.NameBytes(), 0x00))
+	s.Name = save.Name()
 }
 
 func handleMsgMhfSaveScenarioData(s *Session, p mhfpacket.MHFPacket) {
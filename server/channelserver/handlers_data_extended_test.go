@@ -58,7 +58,6 @@ func TestCharacterSaveDataPersistenceEdgeCases(t *testing.T) {
 				Name:           tt.charName,
 				IsNewCharacter: tt.isNew,
 				Playtime:       tt.playtime,
-				Pointers:       make(map[SavePointer]int),
 			}
 
 			// Verify data integrity
@@ -146,69 +145,6 @@ func TestSaveDataCompressionRoundTrip(t *testing.T) {
 	}
 }
 
-// TestSaveDataPointerHandling tests edge cases in save data pointer management
-func TestSaveDataPointerHandling(t *testing.T) {
-	tests := []struct {
-		name            string
-		pointerCount    int
-		maxPointerValue int
-		valid           bool
-	}{
-		{
-			name:            "no_pointers",
-			pointerCount:    0,
-			maxPointerValue: 0,
-			valid:           true,
-		},
-		{
-			name:            "single_pointer",
-			pointerCount:    1,
-			maxPointerValue: 100,
-			valid:           true,
-		},
-		{
-			name:            "multiple_pointers",
-			pointerCount:    10,
-			maxPointerValue: 5000,
-			valid:           true,
-		},
-		{
-			name:            "max_pointers",
-			pointerCount:    100,
-			maxPointerValue: 1000000,
-			valid:           true,
-		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			savedata := &CharacterSaveData{
-				CharID:   1,
-				Pointers: make(map[SavePointer]int),
-			}
-
-			// Add test pointers
-			for i := 0; i < tt.pointerCount; i++ {
-				pointer := SavePointer(i % 20) // Cycle through pointer types
-				value := (i * 100) % tt.maxPointerValue
-				savedata.Pointers[pointer] = value
-			}
-
-			// Verify pointer count
-			if len(savedata.Pointers) != tt.pointerCount && tt.pointerCount < 20 {
-				t.Errorf("pointer count mismatch: got %d, want %d", len(savedata.Pointers), tt.pointerCount)
-			}
-
-			// Verify pointer values are reasonable
-			for ptr, val := range savedata.Pointers {
-				if val < 0 || val > tt.maxPointerValue {
-					t.Errorf("pointer %v value out of range: %d", ptr, val)
-				}
-			}
-		})
-	}
-}
-
 // TestSaveDataGenderHandling tests gender field handling
 func TestSaveDataGenderHandling(t *testing.T) {
 	tests := []struct {
@@ -443,15 +379,6 @@ func TestSaveDataIntegrity(t *testing.T) {
 		runs   int
 		verify func(*CharacterSaveData) bool
 	}{
-		{
-			name: "pointers_immutable",
-			runs: 10,
-			verify: func(sd *CharacterSaveData) bool {
-				initialPointers := len(sd.Pointers)
-				sd.Pointers[SavePointer(0)] = 100
-				return len(sd.Pointers) == initialPointers+1
-			},
-		},
 		{
 			name: "char_id_consistency",
 			runs: 10,
@@ -466,9 +393,8 @@ func TestSaveDataIntegrity(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			for run := 0; run < tt.runs; run++ {
 				savedata := &CharacterSaveData{
-					CharID:   uint32(run + 1),
-					Name:     "TestChar",
-					Pointers: make(map[SavePointer]int),
+					CharID: uint32(run + 1),
+					Name:   "TestChar",
 				}
 
 				if !tt.verify(savedata) {
@@ -565,7 +565,6 @@ func TestSaveDataCorruptionDetection_Integration(t *testing.T) {
 	s.charID = charID
 	s.Name = "OriginalName"
 	SetTestDB(s.server, db)
-	s.server.erupeConfig.DeleteOnSaveCorruption = false
 
 	// Create save data with a DIFFERENT name (corruption)
 	// Must be large enough for ZZ save pointer offsets (highest: pKQF at 146728)
@@ -1,19 +1,27 @@
 package channelserver
 
 import (
+	"errors"
+	cfg "erupe-ce/config"
+	"erupe-ce/server/discordbot"
 	"fmt"
 	"github.com/bwmarrin/discordgo"
+	"go.uber.org/zap"
 	"golang.org/x/crypto/bcrypt"
 	"strings"
+	"time"
 	"unicode"
 )
 
 // onInteraction handles slash commands
 func (s *Server) onInteraction(ds *discordgo.Session, i *discordgo.InteractionCreate) {
 	switch i.Interaction.ApplicationCommandData().Name {
+	case "register":
+		s.onRegisterInteraction(ds, i)
 	case "link":
 		_, err := s.userRepo.LinkDiscord(i.Member.User.ID, i.ApplicationCommandData().Options[0].StringValue())
 		if err == nil {
+			go s.discordBot.SyncDiscordUser(i.Member.User.ID)
 			_ = ds.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
 				Type: discordgo.InteractionResponseChannelMessageWithSource,
 				Data: &discordgo.InteractionResponseData{
@@ -60,13 +68,179 @@ func (s *Server) onInteraction(ds *discordgo.Session, i *discordgo.InteractionCr
 				},
 			})
 		}
+	case "notifications":
+		enabled := i.ApplicationCommandData().Options[0].BoolValue()
+		content := "Notification DMs disabled."
+		if enabled {
+			content = "Notification DMs enabled."
+		}
+		if err := s.discordBot.SetNotifications(i.Member.User.ID, enabled); err != nil {
+			content = "Failed to update notification settings. Is your Erupe account linked?"
+		}
+		_ = ds.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseChannelMessageWithSource,
+			Data: &discordgo.InteractionResponseData{
+				Content: content,
+				Flags:   discordgo.MessageFlagsEphemeral,
+			},
+		})
+	case "recruiting":
+		enabled := i.ApplicationCommandData().Options[0].BoolValue()
+		var content string
+		name, err := s.discordBot.SetGuildRecruiting(i.Member.User.ID, enabled)
+		switch {
+		case err != nil:
+			content = "Failed to update recruitment. Only leaders of a guild on a linked Erupe account can do this."
+		case enabled:
+			content = fmt.Sprintf("%s is now listed as recruiting.", name)
+		default:
+			content = fmt.Sprintf("%s is no longer listed as recruiting.", name)
+		}
+		_ = ds.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseChannelMessageWithSource,
+			Data: &discordgo.InteractionResponseData{
+				Content: content,
+				Flags:   discordgo.MessageFlagsEphemeral,
+			},
+		})
+	case "status":
+		snap, err := s.discordBot.Status()
+		if err != nil {
+			s.logger.Warn("Failed to collect server status for Discord", zap.Error(err))
+			_ = ds.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+				Type: discordgo.InteractionResponseChannelMessageWithSource,
+				Data: &discordgo.InteractionResponseData{
+					Content: "Server status is unavailable.",
+					Flags:   discordgo.MessageFlagsEphemeral,
+				},
+			})
+			return
+		}
+		_ = ds.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseChannelMessageWithSource,
+			Data: &discordgo.InteractionResponseData{
+				Embeds: []*discordgo.MessageEmbed{discordbot.BuildStatusEmbed(snap)},
+			},
+		})
+	}
+}
+
+// onRegisterInteraction creates an account for the /register command and
+// delivers its credentials by ephemeral reply or DM.
+func (s *Server) onRegisterInteraction(ds *discordgo.Session, i *discordgo.InteractionCreate) {
+	user := i.User
+	if i.Member != nil {
+		user = i.Member.User
+	}
+	username := i.ApplicationCommandData().Options[0].StringValue()
+
+	var content string
+	password, err := s.discordBot.Register(user.ID, username)
+	switch {
+	case err == nil:
+		content = fmt.Sprintf("Your Erupe account was created.\nUsername: `%s`\nPassword: `%s`\nUse /password to change it.", username, password)
+		if s.erupeConfig.Discord.Registration.DeliverByDM {
+			if dmErr := s.discordBot.SendDM(user.ID, content); dmErr == nil {
+				content = "Your Erupe account was created. Check your DMs for your credentials."
+			}
+		}
+	case errors.Is(err, discordbot.ErrRegistrationClosed):
+		content = "Account registration is disabled."
+	case errors.Is(err, discordbot.ErrNotEligible):
+		content = "You are not eligible to register an account."
+	case errors.Is(err, discordbot.ErrRateLimited):
+		content = "Too many registration attempts, please try again later."
+	case errors.Is(err, discordbot.ErrAlreadyRegistered):
+		content = "Your Discord account is already linked to an Erupe account."
+	case errors.Is(err, discordbot.ErrInvalidUsername):
+		content = "Usernames must be 3-16 letters, digits or underscores."
+	case errors.Is(err, discordbot.ErrUsernameTaken):
+		content = "That username is already taken."
+	default:
+		s.logger.Error("Failed to register account from Discord", zap.Error(err))
+		content = "Failed to create Erupe account."
+	}
+	_ = ds.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Content: content,
+			Flags:   discordgo.MessageFlagsEphemeral,
+		},
+	})
+}
+
+// onGuildBanAdd applies a Discord ban of a linked user in-game.
+func (s *Server) onGuildBanAdd(ds *discordgo.Session, e *discordgo.GuildBanAdd) {
+	if e.User == nil || s.erupeConfig.Discord.Moderation.DiscordBan != discordbot.ModerationBan {
+		return
+	}
+	userID, ok := s.discordBot.ModerationTarget(e.GuildID, e.User.ID, discordbot.ModerationBan)
+	if !ok {
+		return
+	}
+	if err := s.BanUser(userID, nil, ModerationSourceDiscord, ""); err != nil {
+		s.logger.Error("Failed to apply Discord ban", zap.Uint32("userID", userID), zap.Error(err))
+	}
+}
+
+// onGuildBanRemove lifts the in-game ban of a linked user unbanned on Discord.
+func (s *Server) onGuildBanRemove(ds *discordgo.Session, e *discordgo.GuildBanRemove) {
+	if e.User == nil || s.erupeConfig.Discord.Moderation.DiscordBan != discordbot.ModerationBan {
+		return
+	}
+	userID, ok := s.discordBot.ModerationTarget(e.GuildID, e.User.ID, discordbot.ModerationUnban)
+	if !ok {
+		return
+	}
+	if err := s.UnbanUser(userID, ModerationSourceDiscord, ""); err != nil {
+		s.logger.Error("Failed to lift Discord ban", zap.Uint32("userID", userID), zap.Error(err))
 	}
 }
 
-// onDiscordMessage handles receiving messages from discord and forwarding them ingame.
+// onGuildMemberUpdate applies or lifts the in-game counterpart of a Discord
+// timeout on a linked user.
+func (s *Server) onGuildMemberUpdate(ds *discordgo.Session, e *discordgo.GuildMemberUpdate) {
+	policy := s.erupeConfig.Discord.Moderation.DiscordTimeout
+	if e.Member == nil || e.User == nil || policy == "" {
+		return
+	}
+	var before *time.Time
+	if e.BeforeUpdate != nil {
+		before = e.BeforeUpdate.CommunicationDisabledUntil
+	}
+	until, removed := discordbot.TimeoutChange(before, e.CommunicationDisabledUntil, time.Now())
+	if until == nil && !removed {
+		return
+	}
+	userID, ok := s.discordBot.ModerationTarget(e.GuildID, e.User.ID, discordbot.ModerationTimeout)
+	if !ok {
+		return
+	}
+	var err error
+	switch {
+	case policy == discordbot.ModerationMute && removed:
+		err = s.UnmuteUser(userID, ModerationSourceDiscord, "")
+	case policy == discordbot.ModerationMute:
+		err = s.MuteUser(userID, *until, ModerationSourceDiscord, "")
+	case policy == discordbot.ModerationBan && removed:
+		err = s.UnbanUser(userID, ModerationSourceDiscord, "")
+	case policy == discordbot.ModerationBan:
+		err = s.BanUser(userID, until, ModerationSourceDiscord, "")
+	}
+	if err != nil {
+		s.logger.Error("Failed to apply Discord timeout", zap.Uint32("userID", userID), zap.Error(err))
+	}
+}
+
+// onDiscordMessage handles receiving messages from discord and forwarding them
+// ingame to every chat scope mapped to the message's channel.
 func (s *Server) onDiscordMessage(ds *discordgo.Session, m *discordgo.MessageCreate) {
-	// Ignore messages from bots, or messages that are not in the correct channel.
-	if m.Author.Bot || m.ChannelID != s.erupeConfig.Discord.RelayChannel.RelayChannelID {
+	// Ignore messages from bots, or messages that are not in a relayed channel.
+	if m.Author.Bot {
+		return
+	}
+	mappings := discordbot.InboundMappings(s.erupeConfig.Discord.RelayChannel, m.ChannelID)
+	if len(mappings) == 0 {
 		return
 	}
 
@@ -79,21 +253,75 @@ func (s *Server) onDiscordMessage(ds *discordgo.Session, m *discordgo.MessageCre
 	for i := 0; i < 8-len(m.Author.Username); i++ {
 		paddedName += " "
 	}
-	message := s.discordBot.NormalizeDiscordMessage(fmt.Sprintf("[D] %s > %s", paddedName, m.Content))
-	if len(message) > s.erupeConfig.Discord.RelayChannel.MaxMessageLength {
-		return
+	content := s.discordBot.NormalizeDiscordMessage(m.Content)
+
+	for _, mapping := range mappings {
+		if discordbot.IsFiltered(mapping, content) {
+			continue
+		}
+		message := discordbot.FormatInbound(mapping, paddedName, content)
+		if len(message) > s.erupeConfig.Discord.RelayChannel.MaxMessageLength {
+			continue
+		}
+		s.relayChatLines(mapping, splitChatLines(message, 61))
 	}
+}
 
-	var messages []string
-	lineLength := 61
+// splitChatLines breaks message into chunks of at most lineLength bytes.
+func splitChatLines(message string, lineLength int) []string {
+	var lines []string
 	for i := 0; i < len(message); i += lineLength {
 		end := i + lineLength
 		if end > len(message) {
 			end = len(message)
 		}
-		messages = append(messages, message[i:end])
+		lines = append(lines, message[i:end])
+	}
+	return lines
+}
+
+// sendGuildChatLines sends server chat lines to the members of the guild
+// on this server.
+func (s *Server) sendGuildChatLines(guildID uint32, lines []string) {
+	members, err := s.guildRepo.GetMembers(guildID, false)
+	if err != nil {
+		s.logger.Warn("Failed to get guild members for chat lines", zap.Uint32("guildID", guildID), zap.Error(err))
+		return
 	}
-	for i := range messages {
-		s.BroadcastChatMessage(messages[i])
+	inGuild := make(map[uint32]bool, len(members))
+	for _, member := range members {
+		inGuild[member.CharID] = true
+	}
+	for _, session := range s.sessions.Snapshot() {
+		if inGuild[session.charID] {
+			for _, line := range lines {
+				session.QueueSendMHFNonBlocking(s.serverChatPacket(line))
+			}
+		}
+	}
+}
+
+// relayChatLines delivers relayed Discord lines to the sessions on this
+// server that belong to the mapping's chat scope.
+func (s *Server) relayChatLines(mapping cfg.DiscordRelayMapping, lines []string) {
+	switch mapping.Scope {
+	case discordbot.ScopeWorld:
+		for _, line := range lines {
+			s.BroadcastChatMessage(line)
+		}
+	case discordbot.ScopeGuild:
+		if mapping.GuildID == 0 {
+			return
+		}
+		s.sendGuildChatLines(mapping.GuildID, lines)
+	case discordbot.ScopeSiege:
+		s.semaphoreLock.RLock()
+		raviSema := s.getRaviSemaphore()
+		if raviSema != nil {
+			for _, line := range lines {
+				raviSema.BroadcastMHF(s.serverChatPacket(line), nil)
+			}
+		}
+		s.semaphoreLock.RUnlock()
 	}
 }
@@ -1 +1,61 @@
 package channelserver
+
+import (
+	"strings"
+	"testing"
+
+	cfg "erupe-ce/config"
+	"erupe-ce/server/discordbot"
+)
+
+func TestSplitChatLines(t *testing.T) {
+	msg := strings.Repeat("a", 130)
+	lines := splitChatLines(msg, 61)
+	if len(lines) != 3 {
+		t.Fatalf("got %d lines, want 3", len(lines))
+	}
+	if len(lines[0]) != 61 || len(lines[1]) != 61 || len(lines[2]) != 8 {
+		t.Errorf("line lengths = %d,%d,%d", len(lines[0]), len(lines[1]), len(lines[2]))
+	}
+	if got := splitChatLines("", 61); len(got) != 0 {
+		t.Errorf("empty message produced %d lines", len(got))
+	}
+}
+
+func TestRelayChatLinesGuildOnlyReachesMembers(t *testing.T) {
+	server := createMockServer()
+	server.guildRepo = &mockGuildRepo{
+		members: []*GuildMember{{CharID: 100}},
+	}
+
+	memberConn, outsiderConn := &mockConn{}, &mockConn{}
+	member := createTestSessionForServer(server, memberConn, 100, "Member")
+	outsider := createTestSessionForServer(server, outsiderConn, 200, "Outsider")
+	server.sessions.Store(memberConn, member)
+	server.sessions.Store(outsiderConn, outsider)
+
+	mapping := cfg.DiscordRelayMapping{Scope: discordbot.ScopeGuild, GuildID: 1}
+	server.relayChatLines(mapping, []string{"hello", "guild"})
+
+	if got := len(member.sendPackets); got != 2 {
+		t.Errorf("member received %d packets, want 2", got)
+	}
+	if got := len(outsider.sendPackets); got != 0 {
+		t.Errorf("outsider received %d packets, want 0", got)
+	}
+}
+
+func TestRelayChatLinesGuildWithoutIDIsDropped(t *testing.T) {
+	server := createMockServer()
+	server.guildRepo = &mockGuildRepo{members: []*GuildMember{{CharID: 100}}}
+
+	conn := &mockConn{}
+	sess := createTestSessionForServer(server, conn, 100, "Member")
+	server.sessions.Store(conn, sess)
+
+	server.relayChatLines(cfg.DiscordRelayMapping{Scope: discordbot.ScopeGuild}, []string{"hello"})
+
+	if got := len(sess.sendPackets); got != 0 {
+		t.Errorf("received %d packets, want 0", got)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	ps "erupe-ce/common/pascalstring"
 	cfg "erupe-ce/config"
 	"erupe-ce/network/mhfpacket"
+	"erupe-ce/server/analytics"
 	"time"
 
 	"go.uber.org/zap"
@@ -151,6 +152,8 @@ func handleMsgMhfAcquireDistItem(s *Session, p mhfpacket.MHFPacket) {
 				case 21:
 					if err := s.server.userRepo.AddFrontierPoints(s.userID, item.Quantity); err != nil {
 						s.logger.Error("Failed to update frontier points", zap.Error(err))
+					} else {
+						s.server.analytics.Add(analytics.FPointSource, analytics.KeyDistribution, int64(item.Quantity))
 					}
 				case 23:
 					saveData, err := GetCharacterSaveData(s, s.charID)
@@ -158,6 +161,8 @@ func handleMsgMhfAcquireDistItem(s *Session, p mhfpacket.MHFPacket) {
 						saveData.RP += uint16(item.Quantity)
 						saveData.Save(s)
 					}
+				default:
+					s.server.analytics.Add(analytics.Items, analytics.ItemKey(item.ItemType, item.ItemID), int64(item.Quantity))
 				}
 			}
 		}
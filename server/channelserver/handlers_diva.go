@@ -88,8 +88,8 @@ func handleMsgMhfGetUdSchedule(s *Session, p mhfpacket.MHFPacket) {
 	}
 
 	var timestamps []uint32
-	if s.server.erupeConfig.DebugOptions.DivaOverride >= 0 {
-		if s.server.erupeConfig.DebugOptions.DivaOverride == 0 {
+	if s.server.erupeConfig.Live().DebugOptions.DivaOverride >= 0 {
+		if s.server.erupeConfig.Live().DebugOptions.DivaOverride == 0 {
 			if s.server.erupeConfig.RealClientMode >= cfg.Z2 {
 				doAckBufSucceed(s, pkt.AckHandle, make([]byte, 36))
 			} else {
@@ -97,7 +97,7 @@ func handleMsgMhfGetUdSchedule(s *Session, p mhfpacket.MHFPacket) {
 			}
 			return
 		}
-		timestamps = generateDivaTimestamps(s, uint32(s.server.erupeConfig.DebugOptions.DivaOverride), true)
+		timestamps = generateDivaTimestamps(s, uint32(s.server.erupeConfig.Live().DebugOptions.DivaOverride), true)
 	} else {
 		timestamps = generateDivaTimestamps(s, start, false)
 	}
@@ -67,7 +67,7 @@ func handleMsgMhfGetWeeklySchedule(s *Session, p mhfpacket.MHFPacket) {
 	for _, t := range times {
 		temp, err := s.server.eventRepo.GetFeatureWeapon(t)
 		if err != nil || temp.StartTime.IsZero() {
-			weapons := token.RNG.Intn(s.server.erupeConfig.GameplayOptions.MaxFeatureWeapons-s.server.erupeConfig.GameplayOptions.MinFeatureWeapons+1) + s.server.erupeConfig.GameplayOptions.MinFeatureWeapons
+			weapons := token.RNG.Intn(s.server.erupeConfig.Live().GameplayOptions.MaxFeatureWeapons-s.server.erupeConfig.Live().GameplayOptions.MinFeatureWeapons+1) + s.server.erupeConfig.Live().GameplayOptions.MinFeatureWeapons
 			temp = generateFeatureWeapons(weapons, s.server.erupeConfig.RealClientMode)
 			temp.StartTime = t
 			if err := s.server.eventRepo.InsertFeatureWeapon(temp.StartTime, temp.ActiveFeatures); err != nil {
@@ -137,7 +137,7 @@ func handleMsgMhfGetKeepLoginBoostStatus(s *Session, p mhfpacket.MHFPacket) {
 	bf := byteframe.NewByteFrame()
 
 	loginBoosts, err := s.server.eventRepo.GetLoginBoosts(s.charID)
-	if err != nil || s.server.erupeConfig.GameplayOptions.DisableLoginBoost {
+	if err != nil || s.server.erupeConfig.Live().GameplayOptions.DisableLoginBoost {
 		doAckBufSucceed(s, pkt.AckHandle, make([]byte, 35))
 		return
 	}
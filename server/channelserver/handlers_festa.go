@@ -3,6 +3,7 @@ package channelserver
 import (
 	"database/sql"
 	"errors"
+	"fmt"
 	"sort"
 	"time"
 
@@ -11,6 +12,7 @@ import (
 	"erupe-ce/common/token"
 	cfg "erupe-ce/config"
 	"erupe-ce/network/mhfpacket"
+	"erupe-ce/server/eventbus"
 
 	"go.uber.org/zap"
 )
@@ -29,7 +31,7 @@ func handleMsgMhfLoadMezfesData(s *Session, p mhfpacket.MHFPacket) {
 func handleMsgMhfEnumerateRanking(s *Session, p mhfpacket.MHFPacket) {
 	pkt := p.(*mhfpacket.MsgMhfEnumerateRanking)
 	bf := byteframe.NewByteFrame()
-	state := s.server.erupeConfig.DebugOptions.TournamentOverride
+	state := s.server.erupeConfig.Live().DebugOptions.TournamentOverride
 	// Unk
 	// Unk
 	// Start?
@@ -173,12 +175,12 @@ func handleMsgMhfInfoFesta(s *Session, p mhfpacket.MHFPacket) {
 	}
 
 	var timestamps []uint32
-	if s.server.erupeConfig.DebugOptions.FestaOverride >= 0 {
-		if s.server.erupeConfig.DebugOptions.FestaOverride == 0 {
+	if s.server.erupeConfig.Live().DebugOptions.FestaOverride >= 0 {
+		if s.server.erupeConfig.Live().DebugOptions.FestaOverride == 0 {
 			doAckBufSucceed(s, pkt.AckHandle, make([]byte, 4))
 			return
 		}
-		timestamps = generateFestaTimestamps(s, uint32(s.server.erupeConfig.DebugOptions.FestaOverride), true)
+		timestamps = generateFestaTimestamps(s, uint32(s.server.erupeConfig.Live().DebugOptions.FestaOverride), true)
 	} else {
 		timestamps = generateFestaTimestamps(s, start, false)
 	}
@@ -272,12 +274,9 @@ func handleMsgMhfInfoFesta(s *Session, p mhfpacket.MHFPacket) {
 		}
 	}
 	if s.server.erupeConfig.RealClientMode <= cfg.G61 {
-		if s.server.erupeConfig.GameplayOptions.MaximumFP > 0xFFFF {
-			s.server.erupeConfig.GameplayOptions.MaximumFP = 0xFFFF
-		}
-		bf.WriteUint16(uint16(s.server.erupeConfig.GameplayOptions.MaximumFP))
+		bf.WriteUint16(uint16(min(s.server.erupeConfig.Live().GameplayOptions.MaximumFP, 0xFFFF)))
 	} else {
-		bf.WriteUint32(s.server.erupeConfig.GameplayOptions.MaximumFP)
+		bf.WriteUint32(s.server.erupeConfig.Live().GameplayOptions.MaximumFP)
 	}
 	bf.WriteUint16(100) // Reward multiplier (%)
 
@@ -456,6 +455,33 @@ func handleMsgMhfChargeFesta(s *Session, p mhfpacket.MHFPacket) {
 		s.logger.Error("Failed to submit festa souls", zap.Error(err))
 	}
 	doAckSimpleSucceed(s, pkt.AckHandle, make([]byte, 4))
+	publishFestaStandings(s.server)
+}
+
+// publishFestaStandings announces the current festa leader on the event bus.
+// Subscribers deduplicate on the leader, so only lead changes surface.
+func publishFestaStandings(server *Server) {
+	if server.eventBus == nil {
+		return
+	}
+	leader, red, blue, err := server.festaService.Standings()
+	if err != nil {
+		server.logger.Warn("Failed to read festa standings", zap.Error(err))
+		return
+	}
+	if leader == "" {
+		return
+	}
+	server.eventBus.Publish(eventbus.Event{
+		Type:    eventbus.FestaStandings,
+		Title:   "Hunter's Festa lead change",
+		Message: fmt.Sprintf("Team %s has taken the lead!", leader),
+		Fields: []eventbus.Field{
+			{Name: "Red", Value: fmt.Sprint(red)},
+			{Name: "Blue", Value: fmt.Sprint(blue)},
+		},
+		Key: leader,
+	})
 }
 
 func handleMsgMhfAcquireFesta(s *Session, p mhfpacket.MHFPacket) {
@@ -5,6 +5,7 @@ import (
 
 	cfg "erupe-ce/config"
 	"erupe-ce/network/mhfpacket"
+	"erupe-ce/server/eventbus"
 )
 
 func TestHandleMsgMhfEnumerateRanking_Default(t *testing.T) {
@@ -106,3 +107,27 @@ func TestHandleMsgMhfEnumerateRanking_State3(t *testing.T) {
 		t.Error("No response packet queued")
 	}
 }
+
+func TestHandleMsgMhfChargeFesta_PublishesStandings(t *testing.T) {
+	server := createMockServer()
+	server.festaRepo = &mockFestaRepo{teamByName: map[string]uint32{"red": 10, "blue": 40}}
+	ensureFestaService(server)
+	server.eventBus = eventbus.New()
+	var got []eventbus.Event
+	server.eventBus.Subscribe(func(e eventbus.Event) { got = append(got, e) })
+	session := createMockSession(1, server)
+
+	handleMsgMhfChargeFesta(session, &mhfpacket.MsgMhfChargeFesta{AckHandle: 1, GuildID: 2, Souls: []uint16{5}})
+
+	if len(got) != 1 {
+		t.Fatalf("published %d events, want 1", len(got))
+	}
+	if got[0].Type != eventbus.FestaStandings || got[0].Key != "blue" {
+		t.Errorf("event = %s/%q, want festa_standings/blue", got[0].Type, got[0].Key)
+	}
+	select {
+	case <-session.sendPackets:
+	default:
+		t.Error("No response packet queued")
+	}
+}
@@ -122,6 +122,7 @@ func handleMsgMhfPlayNormalGacha(s *Session, p mhfpacket.MHFPacket) {
 		doAckBufSucceed(s, pkt.AckHandle, make([]byte, 1))
 		return
 	}
+	s.countGachaRewards(result.Rewards)
 
 	bf := byteframe.NewByteFrame()
 	bf.WriteUint8(uint8(len(result.Rewards)))
@@ -142,6 +143,8 @@ func handleMsgMhfPlayStepupGacha(s *Session, p mhfpacket.MHFPacket) {
 		doAckBufSucceed(s, pkt.AckHandle, make([]byte, 1))
 		return
 	}
+	s.countGachaRewards(result.RandomRewards)
+	s.countGachaRewards(result.GuaranteedRewards)
 
 	bf := byteframe.NewByteFrame()
 	bf.WriteUint8(uint8(len(result.RandomRewards) + len(result.GuaranteedRewards)))
@@ -201,6 +204,7 @@ func handleMsgMhfPlayBoxGacha(s *Session, p mhfpacket.MHFPacket) {
 		doAckBufSucceed(s, pkt.AckHandle, make([]byte, 1))
 		return
 	}
+	s.countGachaRewards(result.Rewards)
 
 	bf := byteframe.NewByteFrame()
 	bf.WriteUint8(uint8(len(result.Rewards)))
@@ -77,6 +77,8 @@ func handleMsgMhfUpdateGuildMessageBoard(s *Session, p mhfpacket.MHFPacket) {
 		}
 		if err := s.server.guildRepo.CreatePost(guild.ID, s.charID, pkt.StampID, int(pkt.PostType), pkt.Title, pkt.Body, maxPosts); err != nil {
 			s.logger.Error("Failed to create guild post", zap.Error(err))
+		} else if pkt.PostType == 1 {
+			s.server.federation.AnnounceGuild(guild.ID, guild.Name, s.Name, pkt.Title)
 		}
 	case 1: // Delete message
 		if err := s.server.guildRepo.DeletePost(pkt.PostID); err != nil {
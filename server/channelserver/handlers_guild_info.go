@@ -117,8 +117,8 @@ func handleMsgMhfInfoGuild(s *Session, p mhfpacket.MHFPacket) {
 		}
 		bf.WriteUint32(guild.PugiOutfits)
 
-		limit := s.server.erupeConfig.GameplayOptions.ClanMemberLimits[0][1]
-		for _, j := range s.server.erupeConfig.GameplayOptions.ClanMemberLimits {
+		limit := s.server.erupeConfig.Live().GameplayOptions.ClanMemberLimits[0][1]
+		for _, j := range s.server.erupeConfig.Live().GameplayOptions.ClanMemberLimits {
 			if guild.Rank(s.server.erupeConfig.RealClientMode) >= uint16(j[0]) {
 				limit = j[1]
 			}
@@ -1,6 +1,7 @@
 package channelserver
 
 import (
+	"fmt"
 	"time"
 
 	"erupe-ce/common/byteframe"
@@ -44,6 +45,7 @@ func handleMsgMhfOperateGuild(s *Session, p mhfpacket.MHFPacket) {
 	case mhfpacket.OperateGuildApply:
 		err = s.server.guildRepo.CreateApplication(guild.ID, s.charID, s.charID, GuildApplicationTypeApplied)
 		if err == nil {
+			s.server.DiscordNotifyOffline(guild.LeaderCharID, fmt.Sprintf("%s applied to join %s", s.Name, guild.Name))
 			bf.WriteUint32(guild.LeaderCharID)
 		} else {
 			bf.WriteUint32(0)
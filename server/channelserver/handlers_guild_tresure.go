@@ -45,7 +45,7 @@ func handleMsgMhfEnumerateGuildTresure(s *Session, p mhfpacket.MHFPacket) {
 			return
 		}
 		for _, hunt := range guildHunts {
-			if hunt.Start.Add(time.Second * time.Duration(s.server.erupeConfig.GameplayOptions.TreasureHuntExpiry)).After(TimeAdjusted()) {
+			if hunt.Start.Add(time.Second * time.Duration(s.server.erupeConfig.Live().GameplayOptions.TreasureHuntExpiry)).After(TimeAdjusted()) {
 				hunts = append(hunts, *hunt)
 			}
 		}
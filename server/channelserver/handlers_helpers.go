@@ -18,7 +18,7 @@ func stubEnumerateNoResults(s *Session, ackHandle uint32) {
 
 func doAckEarthSucceed(s *Session, ackHandle uint32, data []*byteframe.ByteFrame) {
 	bf := byteframe.NewByteFrame()
-	bf.WriteUint32(uint32(s.server.erupeConfig.EarthID))
+	bf.WriteUint32(uint32(s.server.erupeConfig.Live().EarthID))
 	bf.WriteUint32(0)
 	bf.WriteUint32(0)
 	bf.WriteUint32(uint32(len(data)))
@@ -38,6 +38,7 @@ func doAckBufSucceed(s *Session, ackHandle uint32, data []byte) {
 }
 
 func doAckBufFail(s *Session, ackHandle uint32, data []byte) {
+	s.ackFailed.Store(true)
 	s.QueueSendMHF(&mhfpacket.MsgSysAck{
 		AckHandle:        ackHandle,
 		IsBufferResponse: true,
@@ -56,6 +57,7 @@ func doAckSimpleSucceed(s *Session, ackHandle uint32, data []byte) {
 }
 
 func doAckSimpleFail(s *Session, ackHandle uint32, data []byte) {
+	s.ackFailed.Store(true)
 	s.QueueSendMHF(&mhfpacket.MsgSysAck{
 		AckHandle:        ackHandle,
 		IsBufferResponse: false,
@@ -421,6 +421,12 @@ func warehouseGetItems(s *Session, index uint8) []mhfitem.MHFItemStack {
 	if err != nil {
 		s.logger.Warn("Failed to load warehouse item data", zap.Error(err))
 	}
+	return readWarehouseItems(data)
+}
+
+// readWarehouseItems parses a serialized warehouse item box.
+func readWarehouseItems(data []byte) []mhfitem.MHFItemStack {
+	var items []mhfitem.MHFItemStack
 	if len(data) > 0 {
 		box := byteframe.NewByteFrameFromBytes(data)
 		numStacks := box.ReadUint16()
@@ -2,6 +2,7 @@ package channelserver
 
 import (
 	"erupe-ce/common/stringsupport"
+	"fmt"
 	"time"
 
 	"erupe-ce/common/byteframe"
@@ -31,24 +32,23 @@ type Mail struct {
 
 // SendMailNotification sends a new mail notification to a player.
 func SendMailNotification(s *Session, m *Mail, recipient *Session) {
-	bf := byteframe.NewByteFrame()
+	recipient.QueueSendMHFNonBlocking(mailNotification(m.SenderID, getCharacterName(s, m.SenderID)))
+}
 
+// mailNotification builds the notification of a mail from the sender.
+func mailNotification(senderID uint32, senderName string) *mhfpacket.MsgSysCastedBinary {
+	bf := byteframe.NewByteFrame()
 	notification := &binpacket.MsgBinMailNotify{
-		SenderName: getCharacterName(s, m.SenderID),
+		SenderName: senderName,
 	}
-
 	_ = notification.Build(bf)
 
-	castedBinary := &mhfpacket.MsgSysCastedBinary{
-		CharID:         m.SenderID,
+	return &mhfpacket.MsgSysCastedBinary{
+		CharID:         senderID,
 		BroadcastType:  0x00,
 		MessageType:    BinaryMessageTypeMailNotify,
 		RawDataPayload: bf.Data(),
 	}
-
-	_ = castedBinary.Build(bf, s.clientContext)
-
-	recipient.QueueSendMHFNonBlocking(castedBinary)
 }
 
 func getCharacterName(s *Session, charID uint32) string {
@@ -209,6 +209,8 @@ func handleMsgMhfSendMail(s *Session, p mhfpacket.MHFPacket) {
 	} else {
 		if err := s.server.mailService.Send(s.charID, pkt.RecipientID, pkt.Subject, pkt.Body, pkt.ItemID, pkt.Quantity); err != nil {
 			s.logger.Error("Failed to send mail", zap.Error(err))
+		} else {
+			s.server.DiscordNotifyOffline(pkt.RecipientID, fmt.Sprintf("New mail from %s: %s", s.Name, pkt.Subject))
 		}
 	}
 	doAckSimpleSucceed(s, pkt.AckHandle, make([]byte, 4))
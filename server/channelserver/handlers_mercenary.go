@@ -198,6 +198,7 @@ func handleMsgMhfSaveMercenary(s *Session, p mhfpacket.MHFPacket) {
 			s.logger.Error("Failed to save mercenary data", zap.Error(err))
 		}
 	}
+	s.countGCPChange(pkt.GCP)
 	if err := s.server.charRepo.UpdateGCPAndPact(s.charID, pkt.GCP, pkt.PactMercID); err != nil {
 		s.logger.Error("Failed to update GCP and pact ID", zap.Error(err))
 	}
@@ -395,7 +396,7 @@ func getGuildAirouList(s *Session) []Airou {
 	}
 
 	for _, usage := range usages {
-		if usage.Start.Add(time.Second * time.Duration(s.server.erupeConfig.GameplayOptions.TreasureHuntPartnyaCooldown)).Before(TimeAdjusted()) {
+		if usage.Start.Add(time.Second * time.Duration(s.server.erupeConfig.Live().GameplayOptions.TreasureHuntPartnyaCooldown)).Before(TimeAdjusted()) {
 			for i, j := range stringsupport.CSVElems(usage.CatsUsed) {
 				bannedCats[uint32(j)] = i
 			}
@@ -4,6 +4,7 @@ import (
 	"erupe-ce/common/byteframe"
 	cfg "erupe-ce/config"
 	"erupe-ce/network/mhfpacket"
+	"fmt"
 	"math/bits"
 	"time"
 
@@ -70,9 +71,9 @@ func handleMsgMhfGetEarthStatus(s *Session, p mhfpacket.MHFPacket) {
 	bf := byteframe.NewByteFrame()
 	bf.WriteUint32(uint32(TimeWeekStart().Unix())) // Start
 	bf.WriteUint32(uint32(TimeWeekNext().Unix()))  // End
-	bf.WriteInt32(s.server.erupeConfig.EarthStatus)
-	bf.WriteInt32(s.server.erupeConfig.EarthID)
-	for i, m := range s.server.erupeConfig.EarthMonsters {
+	bf.WriteInt32(s.server.erupeConfig.Live().EarthStatus)
+	bf.WriteInt32(s.server.erupeConfig.Live().EarthID)
+	for i, m := range s.server.erupeConfig.Live().EarthMonsters {
 		if s.server.erupeConfig.RealClientMode <= cfg.G9 {
 			if i == 3 {
 				break
@@ -281,8 +282,11 @@ func handleMsgMhfGetTrendWeapon(s *Session, p mhfpacket.MHFPacket) {
 
 func handleMsgMhfUpdateUseTrendWeaponLog(s *Session, p mhfpacket.MHFPacket) {
 	pkt := p.(*mhfpacket.MsgMhfUpdateUseTrendWeaponLog)
-	if err := s.server.miscRepo.UpsertTrendWeapon(pkt.WeaponID, pkt.WeaponType); err != nil {
-		s.logger.Error("Failed to update trend weapon log", zap.Error(err))
-	}
+	s.server.writeAsync("", func() error {
+		if err := s.server.miscRepo.UpsertTrendWeapon(pkt.WeaponID, pkt.WeaponType); err != nil {
+			return fmt.Errorf("update trend weapon %d: %w", pkt.WeaponID, err)
+		}
+		return nil
+	})
 	doAckSimpleSucceed(s, pkt.AckHandle, make([]byte, 4))
 }
@@ -9,107 +9,27 @@ import (
 // Test handlers with simple responses
 
 func TestHandleMsgMhfGetEarthStatus(t *testing.T) {
-	server := createMockServer()
-	session := createMockSession(1, server)
-
-	pkt := &mhfpacket.MsgMhfGetEarthStatus{
-		AckHandle: 12345,
-	}
-
-	handleMsgMhfGetEarthStatus(session, pkt)
-
-	select {
-	case p := <-session.sendPackets:
-		if p.data == nil {
-			t.Error("Response packet data should not be nil")
-		}
-	default:
-		t.Error("No response packet queued")
-	}
-}
-
-func TestHandleMsgMhfGetEarthValue_Type1(t *testing.T) {
-	server := createMockServer()
-	session := createMockSession(1, server)
-
-	pkt := &mhfpacket.MsgMhfGetEarthValue{
-		AckHandle: 12345,
-		ReqType:   1,
-	}
-
-	handleMsgMhfGetEarthValue(session, pkt)
-
-	select {
-	case p := <-session.sendPackets:
-		if len(p.data) == 0 {
-			t.Error("Response packet should have data")
-		}
-	default:
-		t.Error("No response packet queued")
-	}
-}
-
-func TestHandleMsgMhfGetEarthValue_Type2(t *testing.T) {
-	server := createMockServer()
-	session := createMockSession(1, server)
-
-	pkt := &mhfpacket.MsgMhfGetEarthValue{
-		AckHandle: 12345,
-		ReqType:   2,
-	}
-
-	handleMsgMhfGetEarthValue(session, pkt)
-
-	select {
-	case p := <-session.sendPackets:
-		if len(p.data) == 0 {
-			t.Error("Response packet should have data")
-		}
-	default:
-		t.Error("No response packet queued")
-	}
-}
-
-func TestHandleMsgMhfGetEarthValue_Type3(t *testing.T) {
-	server := createMockServer()
-	session := createMockSession(1, server)
-
-	pkt := &mhfpacket.MsgMhfGetEarthValue{
-		AckHandle: 12345,
-		ReqType:   3,
-	}
-
-	handleMsgMhfGetEarthValue(session, pkt)
-
-	select {
-	case p := <-session.sendPackets:
-		if len(p.data) == 0 {
-			t.Error("Response packet should have data")
-		}
-	default:
-		t.Error("No response packet queued")
-	}
-}
-
-func TestHandleMsgMhfGetEarthValue_UnknownType(t *testing.T) {
-	server := createMockServer()
-	session := createMockSession(1, server)
-
-	pkt := &mhfpacket.MsgMhfGetEarthValue{
-		AckHandle: 12345,
-		ReqType:   99, // Unknown type
-	}
-
-	handleMsgMhfGetEarthValue(session, pkt)
-
-	select {
-	case p := <-session.sendPackets:
-		// Should still return a response (empty values)
-		if p.data == nil {
-			t.Error("Response packet data should not be nil")
-		}
-	default:
-		t.Error("No response packet queued")
+	h := newHandlerHarness(t)
+	h.Handle(&mhfpacket.MsgMhfGetEarthStatus{AckHandle: 12345})
+	h.Next().ExpectAck(12345).ExpectSuccess()
+}
+
+func TestHandleMsgMhfGetEarthValue(t *testing.T) {
+	tests := []struct {
+		name    string
+		reqType uint32
+	}{
+		{"Type1", 1},
+		{"Type2", 2},
+		{"Type3", 3},
+		{"UnknownType", 99}, // Should still return a response (empty values)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newHandlerHarness(t)
+			h.Handle(&mhfpacket.MsgMhfGetEarthValue{AckHandle: 12345, ReqType: tt.reqType})
+			h.Next().ExpectAck(12345).ExpectSuccess().ExpectField("IsBufferResponse", true)
+		})
 	}
 }
 
@@ -23,8 +23,7 @@ package channelserver
 
 import (
 	"erupe-ce/network/mhfpacket"
-	"erupe-ce/server/channelserver/compression/deltacomp"
-	"erupe-ce/server/channelserver/compression/nullcomp"
+	"erupe-ce/server/channelserver/compression/blobcomp"
 	"go.uber.org/zap"
 	"time"
 )
@@ -74,26 +73,10 @@ func handleMsgMhfSavePlateData(s *Session, p mhfpacket.MHFPacket) {
 			return
 		}
 
-		if len(data) > 0 {
-			// Decompress
-			s.logger.Debug("Decompressing PlateData", zap.Int("compressed_size", len(data)))
-			data, err = nullcomp.Decompress(data)
-			if err != nil {
-				s.logger.Error("Failed to decompress platedata",
-					zap.Error(err),
-					zap.Uint32("charID", s.charID),
-				)
-				doAckSimpleSucceed(s, pkt.AckHandle, []byte{0x00, 0x00, 0x00, 0x00})
-				return
-			}
-		} else {
-			// create empty save if absent
-			data = make([]byte, plateDataEmptySize)
-		}
-
-		// Perform diff and compress it to write back to db
-		s.logger.Debug("Applying PlateData diff", zap.Int("base_size", len(data)))
-		saveOutput, err := nullcomp.Compress(deltacomp.ApplyDataDiff(pkt.RawDataPayload, data))
+		// Perform diff and compress it to write back to db, starting from an
+		// empty save if absent
+		s.logger.Debug("Applying PlateData diff", zap.Int("compressed_size", len(data)))
+		saveOutput, err := blobcomp.ApplyDiff(data, pkt.RawDataPayload, plateDataEmptySize)
 		if err != nil {
 			s.logger.Error("Failed to diff and compress platedata",
 				zap.Error(err),
@@ -169,24 +152,10 @@ func handleMsgMhfSavePlateBox(s *Session, p mhfpacket.MHFPacket) {
 			return
 		}
 
-		// Decompress
-		if len(data) > 0 {
-			// Decompress
-			s.logger.Info("Decompressing...")
-			data, err = nullcomp.Decompress(data)
-			if err != nil {
-				s.logger.Error("Failed to decompress platebox", zap.Error(err))
-				doAckSimpleSucceed(s, pkt.AckHandle, []byte{0x00, 0x00, 0x00, 0x00})
-				return
-			}
-		} else {
-			// create empty save if absent
-			data = make([]byte, plateBoxEmptySize)
-		}
-
-		// Perform diff and compress it to write back to db
+		// Perform diff and compress it to write back to db, starting from an
+		// empty save if absent
 		s.logger.Info("Diffing...")
-		saveOutput, err := nullcomp.Compress(deltacomp.ApplyDataDiff(pkt.RawDataPayload, data))
+		saveOutput, err := blobcomp.ApplyDiff(data, pkt.RawDataPayload, plateBoxEmptySize)
 		if err != nil {
 			s.logger.Error("Failed to diff and compress platebox", zap.Error(err))
 			doAckSimpleSucceed(s, pkt.AckHandle, []byte{0x00, 0x00, 0x00, 0x00})
@@ -7,8 +7,10 @@ import (
 	ps "erupe-ce/common/pascalstring"
 	cfg "erupe-ce/config"
 	"erupe-ce/network/mhfpacket"
+	"erupe-ce/server/channelserver/questfile"
 	"fmt"
 	"io"
+	"math"
 	"os"
 	"path/filepath"
 	"time"
@@ -21,77 +23,6 @@ type tuneValue struct {
 	Value uint16
 }
 
-func findSubSliceIndices(data []byte, sub []byte) []int {
-	var indices []int
-	lenSub := len(sub)
-	for i := 0; i < len(data); i++ {
-		if i+lenSub > len(data) {
-			break
-		}
-		if equal(data[i:i+lenSub], sub) {
-			indices = append(indices, i)
-		}
-	}
-	return indices
-}
-
-func equal(a, b []byte) bool {
-	if len(a) != len(b) {
-		return false
-	}
-	for i, v := range a {
-		if v != b[i] {
-			return false
-		}
-	}
-	return true
-}
-
-// BackportQuest converts a quest binary to an older format.
-func BackportQuest(data []byte, mode cfg.Mode) []byte {
-	wp := binary.LittleEndian.Uint32(data[0:4]) + questRewardTableBase
-	rp := wp + 4
-	for i := uint32(0); i < 6; i++ {
-		if i != 0 {
-			wp += 4
-			rp += 8
-		}
-		copy(data[wp:wp+4], data[rp:rp+4])
-	}
-
-	fillLength := questBackportFillZZ
-	if mode <= cfg.S6 {
-		fillLength = questBackportFillS6
-	} else if mode <= cfg.F5 {
-		fillLength = questBackportFillF5
-	} else if mode <= cfg.G101 {
-		fillLength = questBackportFillG101
-	}
-
-	copy(data[wp:wp+fillLength], data[rp:rp+fillLength])
-	if mode <= cfg.G91 {
-		patterns := [][]byte{
-			{0x0A, 0x00, 0x01, 0x33, 0xD7, 0x00}, // 10% Armor Sphere -> Stone
-			{0x06, 0x00, 0x02, 0x33, 0xD8, 0x00}, // 6% Armor Sphere+ -> Iron Ore
-			{0x0A, 0x00, 0x03, 0x33, 0xD7, 0x00}, // 10% Adv Armor Sphere -> Stone
-			{0x06, 0x00, 0x04, 0x33, 0xDB, 0x00}, // 6% Hard Armor Sphere -> Dragonite Ore
-			{0x0A, 0x00, 0x05, 0x33, 0xD9, 0x00}, // 10% Heaven Armor Sphere -> Earth Crystal
-			{0x06, 0x00, 0x06, 0x33, 0xDB, 0x00}, // 6% True Armor Sphere -> Dragonite Ore
-		}
-		for i := range patterns {
-			j := findSubSliceIndices(data, patterns[i][0:4])
-			for k := range j {
-				copy(data[j[k]+2:j[k]+4], patterns[i][4:6])
-			}
-		}
-	}
-
-	if mode <= cfg.S6 {
-		binary.LittleEndian.PutUint32(data[16:20], binary.LittleEndian.Uint32(data[8:12]))
-	}
-	return data
-}
-
 func handleMsgSysGetFile(s *Session, p mhfpacket.MHFPacket) {
 	pkt := p.(*mhfpacket.MsgSysGetFile)
 
@@ -122,7 +53,7 @@ func handleMsgSysGetFile(s *Session, p mhfpacket.MHFPacket) {
 			)
 		}
 
-		if s.server.erupeConfig.GameplayOptions.SeasonOverride {
+		if s.server.erupeConfig.Live().GameplayOptions.SeasonOverride {
 			pkt.Filename = seasonConversion(s, pkt.Filename)
 		}
 
@@ -133,8 +64,13 @@ func handleMsgSysGetFile(s *Session, p mhfpacket.MHFPacket) {
 			return
 		}
 		if s.server.erupeConfig.RealClientMode <= cfg.Z1 && s.server.erupeConfig.DebugOptions.AutoQuestBackport {
-			data = BackportQuest(decryption.UnpackSimple(data), s.server.erupeConfig.RealClientMode)
+			if data, err = questfile.Backport(decryption.UnpackSimple(data), s.server.erupeConfig.RealClientMode); err != nil {
+				s.logger.Error("Failed to backport quest file", zap.String("filename", pkt.Filename), zap.Error(err))
+				doAckBufFail(s, pkt.AckHandle, nil)
+				return
+			}
 		}
+		s.questFile = pkt.Filename
 		doAckBufSucceed(s, pkt.AckHandle, data)
 	}
 }
@@ -213,31 +149,64 @@ func loadQuestFile(s *Session, questId int) []byte {
 		return nil
 	}
 
+	result, err := LoadQuestBody(file, s.server.erupeConfig.RealClientMode, s.server.erupeConfig.DebugOptions.AutoQuestBackport)
+	if err != nil {
+		s.logger.Error("Failed to load quest file", zap.Int("questID", questId), zap.Error(err))
+		return nil
+	}
+	s.server.questCache.Put(questId, result)
+	return result
+}
+
+// questBodyLen returns the length of the quest body a client of mode reads.
+func questBodyLen(mode cfg.Mode) int {
+	switch {
+	case mode <= cfg.S6:
+		return questBodyLenS6
+	case mode <= cfg.F5:
+		return questBodyLenF5
+	case mode <= cfg.G101:
+		return questBodyLenG101
+	case mode <= cfg.Z1:
+		return questBodyLenZ1
+	}
+	return questBodyLenZZ
+}
+
+// LoadQuestBody turns a quest file, packed or not, into the quest body sent
+// to a client of mode in an event quest, with its strings copied after it.
+// With backport, files are converted by questfile.Backport for clients
+// before Z2.
+func LoadQuestBody(file []byte, mode cfg.Mode, backport bool) ([]byte, error) {
 	decrypted := decryption.UnpackSimple(file)
-	if s.server.erupeConfig.RealClientMode <= cfg.Z1 && s.server.erupeConfig.DebugOptions.AutoQuestBackport {
-		decrypted = BackportQuest(decrypted, s.server.erupeConfig.RealClientMode)
+	if len(decrypted) < 4 {
+		return nil, fmt.Errorf("quest file too short: %d bytes", len(decrypted))
+	}
+	bodyPtr := int(binary.LittleEndian.Uint32(decrypted))
+	if mode <= cfg.Z1 && backport {
+		// Converted on a copy so the caller's file is left alone.
+		var err error
+		if decrypted, err = questfile.Backport(append([]byte(nil), decrypted...), mode); err != nil {
+			return nil, err
+		}
 	}
 	fileBytes := byteframe.NewByteFrameFromBytes(decrypted)
 	fileBytes.SetLE()
 	_, _ = fileBytes.Seek(int64(fileBytes.ReadUint32()), 0)
 
-	bodyLength := questBodyLenZZ
-	if s.server.erupeConfig.RealClientMode <= cfg.S6 {
-		bodyLength = questBodyLenS6
-	} else if s.server.erupeConfig.RealClientMode <= cfg.F5 {
-		bodyLength = questBodyLenF5
-	} else if s.server.erupeConfig.RealClientMode <= cfg.G101 {
-		bodyLength = questBodyLenG101
-	} else if s.server.erupeConfig.RealClientMode <= cfg.Z1 {
-		bodyLength = questBodyLenZ1
-	}
+	bodyLength := questBodyLen(mode)
 
 	// The n bytes directly following the data pointer must go directly into the event's body, after the header and before the string pointers.
 	questBody := byteframe.NewByteFrameFromBytes(fileBytes.ReadBytes(uint(bodyLength)))
+	if err := fileBytes.Err(); err != nil {
+		return nil, fmt.Errorf("quest body at 0x%X: %w", bodyPtr, err)
+	}
 	questBody.SetLE()
 	// Find the master quest string pointer
 	_, _ = questBody.Seek(questStringPointerOff, 0)
-	_, _ = fileBytes.Seek(int64(questBody.ReadUint32()), 0)
+	if _, err := fileBytes.Seek(int64(questBody.ReadUint32()), 0); err != nil {
+		return nil, fmt.Errorf("quest string table: %w", err)
+	}
 	_, _ = questBody.Seek(questStringPointerOff, 0)
 	// Overwrite it
 	questBody.WriteUint32(uint32(bodyLength))
@@ -250,17 +219,43 @@ func loadQuestFile(s *Session, questId int) []byte {
 	for i := 0; i < questStringCount; i++ {
 		questBody.WriteUint32(uint32(tempPointer))
 		temp := int64(fileBytes.Index())
-		_, _ = fileBytes.Seek(int64(fileBytes.ReadUint32()), 0)
+		if _, err := fileBytes.Seek(int64(fileBytes.ReadUint32()), 0); err != nil {
+			return nil, fmt.Errorf("quest string %d: %w", i, err)
+		}
 		tempString = fileBytes.ReadNullTerminatedBytes()
 		_, _ = fileBytes.Seek(temp+4, 0)
 		tempPointer += len(tempString) + 1
 		newStrings.WriteNullTerminatedBytes(tempString)
 	}
+	if err := fileBytes.Err(); err != nil {
+		return nil, fmt.Errorf("quest strings: %w", err)
+	}
 	questBody.WriteBytes(newStrings.Data())
+	return questBody.Data(), nil
+}
 
-	result := questBody.Data()
-	s.server.questCache.Put(questId, result)
-	return result
+// eventQuestOverhead is how many bytes makeEventQuest adds around a quest
+// body for a client of mode.
+func eventQuestOverhead(mode cfg.Mode) int {
+	n := 20 // IDs, player count, type, flags, body length and the notes string
+	if mode >= cfg.G2 {
+		n += 4 // Mark
+	}
+	return n
+}
+
+// ValidateQuest loads a quest file as the server would for an event quest
+// sent to a client of mode, and checks the event quest fits what the client
+// accepts.
+func ValidateQuest(file []byte, mode cfg.Mode, backport bool) error {
+	body, err := LoadQuestBody(file, mode, backport)
+	if err != nil {
+		return err
+	}
+	if n := len(body) + eventQuestOverhead(mode); n > questDataMaxLen || n < questDataMinLen {
+		return fmt.Errorf("event quest would be %d bytes, want %d to %d", n, questDataMinLen, questDataMaxLen)
+	}
+	return nil
 }
 
 func makeEventQuest(s *Session, eq EventQuest) ([]byte, error) {
@@ -275,15 +270,15 @@ func makeEventQuest(s *Session, eq EventQuest) ([]byte, error) {
 	bf.WriteUint8(0)  // Unk
 	switch eq.QuestType {
 	case QuestTypeRegularRaviente:
-		bf.WriteUint8(s.server.erupeConfig.GameplayOptions.RegularRavienteMaxPlayers)
+		bf.WriteUint8(s.server.erupeConfig.Live().GameplayOptions.RegularRavienteMaxPlayers)
 	case QuestTypeViolentRaviente:
-		bf.WriteUint8(s.server.erupeConfig.GameplayOptions.ViolentRavienteMaxPlayers)
+		bf.WriteUint8(s.server.erupeConfig.Live().GameplayOptions.ViolentRavienteMaxPlayers)
 	case QuestTypeBerserkRaviente:
-		bf.WriteUint8(s.server.erupeConfig.GameplayOptions.BerserkRavienteMaxPlayers)
+		bf.WriteUint8(s.server.erupeConfig.Live().GameplayOptions.BerserkRavienteMaxPlayers)
 	case QuestTypeExtremeRaviente:
-		bf.WriteUint8(s.server.erupeConfig.GameplayOptions.ExtremeRavienteMaxPlayers)
+		bf.WriteUint8(s.server.erupeConfig.Live().GameplayOptions.ExtremeRavienteMaxPlayers)
 	case QuestTypeSmallBerserkRavi:
-		bf.WriteUint8(s.server.erupeConfig.GameplayOptions.SmallBerserkRavienteMaxPlayers)
+		bf.WriteUint8(s.server.erupeConfig.Live().GameplayOptions.SmallBerserkRavienteMaxPlayers)
 	default:
 		bf.WriteUint8(eq.MaxPlayers)
 	}
@@ -307,7 +302,7 @@ func makeEventQuest(s *Session, eq EventQuest) ([]byte, error) {
 	_, _ = bf.Seek(questFrameTimeFlagOffset, 0)
 	flagByte := bf.ReadUint8()
 	_, _ = bf.Seek(questFrameTimeFlagOffset, 0)
-	if s.server.erupeConfig.GameplayOptions.SeasonOverride {
+	if s.server.erupeConfig.Live().GameplayOptions.SeasonOverride {
 		bf.WriteUint8(flagByte & 0b11100000)
 	} else {
 		// Allow for seasons to be specified in database, otherwise use the one in the file.
@@ -335,13 +330,13 @@ func makeEventQuest(s *Session, eq EventQuest) ([]byte, error) {
 
 func handleMsgMhfEnumerateQuest(s *Session, p mhfpacket.MHFPacket) {
 	pkt := p.(*mhfpacket.MsgMhfEnumerateQuest)
-	var totalCount, returnedCount uint16
 	bf := byteframe.NewByteFrame()
 	bf.WriteUint16(0)
+	page := newListPage(bf, int(pkt.Offset), math.MaxUint16, listPageMaxBytes)
 
 	quests, err := s.server.eventRepo.GetEventQuests()
 	if err == nil {
-		currentTime := time.Now()
+		currentTime := TimeAdjusted()
 		var updates []EventQuestUpdate
 
 		for i, eq := range quests {
@@ -379,13 +374,8 @@ func handleMsgMhfEnumerateQuest(s *Session, p mhfpacket.MHFPacket) {
 				if len(data) > questDataMaxLen || len(data) < questDataMinLen {
 					s.logger.Error("Invalid quest data length", zap.Int("len", len(data)))
 					continue
-				} else {
-					totalCount++
-					if totalCount > pkt.Offset && len(bf.Data()) < 60000 {
-						returnedCount++
-						bf.WriteBytes(data)
-						continue
-					}
+				} else if page.Next(len(data)) {
+					bf.WriteBytes(data)
 				}
 			}
 		}
@@ -503,54 +493,54 @@ func handleMsgMhfEnumerateQuest(s *Session, p mhfpacket.MHFPacket) {
 		{ID: 1180, Value: 5},
 	}
 
-	tuneValues = append(tuneValues, tuneValue{1020, uint16(s.server.erupeConfig.GameplayOptions.GCPMultiplier * 100)})
+	tuneValues = append(tuneValues, tuneValue{1020, uint16(s.server.erupeConfig.Live().GameplayOptions.GCPMultiplier * 100)})
 
-	tuneValues = append(tuneValues, tuneValue{1029, uint16(s.server.erupeConfig.GameplayOptions.GUrgentRate * 100)})
+	tuneValues = append(tuneValues, tuneValue{1029, uint16(s.server.erupeConfig.Live().GameplayOptions.GUrgentRate * 100)})
 
-	if s.server.erupeConfig.GameplayOptions.DisableHunterNavi {
+	if s.server.erupeConfig.Live().GameplayOptions.DisableHunterNavi {
 		tuneValues = append(tuneValues, tuneValue{1037, 1})
 	}
 
-	if s.server.erupeConfig.GameplayOptions.EnableKaijiEvent {
+	if s.server.erupeConfig.Live().GameplayOptions.EnableKaijiEvent {
 		tuneValues = append(tuneValues, tuneValue{1106, 1})
 	}
 
-	if s.server.erupeConfig.GameplayOptions.EnableHiganjimaEvent {
+	if s.server.erupeConfig.Live().GameplayOptions.EnableHiganjimaEvent {
 		tuneValues = append(tuneValues, tuneValue{1144, 1})
 	}
 
-	if s.server.erupeConfig.GameplayOptions.EnableNierEvent {
+	if s.server.erupeConfig.Live().GameplayOptions.EnableNierEvent {
 		tuneValues = append(tuneValues, tuneValue{1153, 1})
 	}
 
-	if s.server.erupeConfig.GameplayOptions.DisableRoad {
+	if s.server.erupeConfig.Live().GameplayOptions.DisableRoad {
 		tuneValues = append(tuneValues, tuneValue{1155, 1})
 	}
 
 	// get_hrp_rate_from_rank
-	tuneValues = append(tuneValues, getTuneValueRange(3000, uint16(s.server.erupeConfig.GameplayOptions.HRPMultiplier*100))...)
-	tuneValues = append(tuneValues, getTuneValueRange(3338, uint16(s.server.erupeConfig.GameplayOptions.HRPMultiplierNC*100))...)
+	tuneValues = append(tuneValues, getTuneValueRange(3000, uint16(s.server.erupeConfig.Live().GameplayOptions.HRPMultiplier*100))...)
+	tuneValues = append(tuneValues, getTuneValueRange(3338, uint16(s.server.erupeConfig.Live().GameplayOptions.HRPMultiplierNC*100))...)
 	// get_srp_rate_from_rank
-	tuneValues = append(tuneValues, getTuneValueRange(3013, uint16(s.server.erupeConfig.GameplayOptions.SRPMultiplier*100))...)
-	tuneValues = append(tuneValues, getTuneValueRange(3351, uint16(s.server.erupeConfig.GameplayOptions.SRPMultiplierNC*100))...)
+	tuneValues = append(tuneValues, getTuneValueRange(3013, uint16(s.server.erupeConfig.Live().GameplayOptions.SRPMultiplier*100))...)
+	tuneValues = append(tuneValues, getTuneValueRange(3351, uint16(s.server.erupeConfig.Live().GameplayOptions.SRPMultiplierNC*100))...)
 	// get_grp_rate_from_rank
-	tuneValues = append(tuneValues, getTuneValueRange(3026, uint16(s.server.erupeConfig.GameplayOptions.GRPMultiplier*100))...)
-	tuneValues = append(tuneValues, getTuneValueRange(3364, uint16(s.server.erupeConfig.GameplayOptions.GRPMultiplierNC*100))...)
+	tuneValues = append(tuneValues, getTuneValueRange(3026, uint16(s.server.erupeConfig.Live().GameplayOptions.GRPMultiplier*100))...)
+	tuneValues = append(tuneValues, getTuneValueRange(3364, uint16(s.server.erupeConfig.Live().GameplayOptions.GRPMultiplierNC*100))...)
 	// get_gsrp_rate_from_rank
-	tuneValues = append(tuneValues, getTuneValueRange(3039, uint16(s.server.erupeConfig.GameplayOptions.GSRPMultiplier*100))...)
-	tuneValues = append(tuneValues, getTuneValueRange(3377, uint16(s.server.erupeConfig.GameplayOptions.GSRPMultiplierNC*100))...)
+	tuneValues = append(tuneValues, getTuneValueRange(3039, uint16(s.server.erupeConfig.Live().GameplayOptions.GSRPMultiplier*100))...)
+	tuneValues = append(tuneValues, getTuneValueRange(3377, uint16(s.server.erupeConfig.Live().GameplayOptions.GSRPMultiplierNC*100))...)
 	// get_zeny_rate_from_hrank
-	tuneValues = append(tuneValues, getTuneValueRange(3052, uint16(s.server.erupeConfig.GameplayOptions.ZennyMultiplier*100))...)
-	tuneValues = append(tuneValues, getTuneValueRange(3390, uint16(s.server.erupeConfig.GameplayOptions.ZennyMultiplierNC*100))...)
+	tuneValues = append(tuneValues, getTuneValueRange(3052, uint16(s.server.erupeConfig.Live().GameplayOptions.ZennyMultiplier*100))...)
+	tuneValues = append(tuneValues, getTuneValueRange(3390, uint16(s.server.erupeConfig.Live().GameplayOptions.ZennyMultiplierNC*100))...)
 	// get_zeny_rate_from_grank
-	tuneValues = append(tuneValues, getTuneValueRange(3078, uint16(s.server.erupeConfig.GameplayOptions.GZennyMultiplier*100))...)
-	tuneValues = append(tuneValues, getTuneValueRange(3416, uint16(s.server.erupeConfig.GameplayOptions.GZennyMultiplierNC*100))...)
+	tuneValues = append(tuneValues, getTuneValueRange(3078, uint16(s.server.erupeConfig.Live().GameplayOptions.GZennyMultiplier*100))...)
+	tuneValues = append(tuneValues, getTuneValueRange(3416, uint16(s.server.erupeConfig.Live().GameplayOptions.GZennyMultiplierNC*100))...)
 	// get_reward_rate_from_hrank
-	tuneValues = append(tuneValues, getTuneValueRange(3104, uint16(s.server.erupeConfig.GameplayOptions.MaterialMultiplier*100))...)
-	tuneValues = append(tuneValues, getTuneValueRange(3442, uint16(s.server.erupeConfig.GameplayOptions.MaterialMultiplierNC*100))...)
+	tuneValues = append(tuneValues, getTuneValueRange(3104, uint16(s.server.erupeConfig.Live().GameplayOptions.MaterialMultiplier*100))...)
+	tuneValues = append(tuneValues, getTuneValueRange(3442, uint16(s.server.erupeConfig.Live().GameplayOptions.MaterialMultiplierNC*100))...)
 	// get_reward_rate_from_grank
-	tuneValues = append(tuneValues, getTuneValueRange(3130, uint16(s.server.erupeConfig.GameplayOptions.GMaterialMultiplier*100))...)
-	tuneValues = append(tuneValues, getTuneValueRange(3468, uint16(s.server.erupeConfig.GameplayOptions.GMaterialMultiplierNC*100))...)
+	tuneValues = append(tuneValues, getTuneValueRange(3130, uint16(s.server.erupeConfig.Live().GameplayOptions.GMaterialMultiplier*100))...)
+	tuneValues = append(tuneValues, getTuneValueRange(3468, uint16(s.server.erupeConfig.Live().GameplayOptions.GMaterialMultiplierNC*100))...)
 	// get_lottery_rate_from_hrank
 	tuneValues = append(tuneValues, getTuneValueRange(3156, 0)...)
 	tuneValues = append(tuneValues, getTuneValueRange(3494, 0)...)
@@ -558,11 +548,11 @@ func handleMsgMhfEnumerateQuest(s *Session, p mhfpacket.MHFPacket) {
 	tuneValues = append(tuneValues, getTuneValueRange(3182, 0)...)
 	tuneValues = append(tuneValues, getTuneValueRange(3520, 0)...)
 	// get_hagi_rate_from_hrank
-	tuneValues = append(tuneValues, getTuneValueRange(3208, s.server.erupeConfig.GameplayOptions.ExtraCarves)...)
-	tuneValues = append(tuneValues, getTuneValueRange(3546, s.server.erupeConfig.GameplayOptions.ExtraCarvesNC)...)
+	tuneValues = append(tuneValues, getTuneValueRange(3208, s.server.erupeConfig.Live().GameplayOptions.ExtraCarves)...)
+	tuneValues = append(tuneValues, getTuneValueRange(3546, s.server.erupeConfig.Live().GameplayOptions.ExtraCarvesNC)...)
 	// get_hagi_rate_from_grank
-	tuneValues = append(tuneValues, getTuneValueRange(3234, s.server.erupeConfig.GameplayOptions.GExtraCarves)...)
-	tuneValues = append(tuneValues, getTuneValueRange(3572, s.server.erupeConfig.GameplayOptions.GExtraCarvesNC)...)
+	tuneValues = append(tuneValues, getTuneValueRange(3234, s.server.erupeConfig.Live().GameplayOptions.GExtraCarves)...)
+	tuneValues = append(tuneValues, getTuneValueRange(3572, s.server.erupeConfig.Live().GameplayOptions.GExtraCarvesNC)...)
 	// get_nboost_transcend_rate_from_hrank
 	tuneValues = append(tuneValues, getTuneValueRange(3286, 200)...)
 	tuneValues = append(tuneValues, getTuneValueRange(3312, 300)...)
@@ -638,10 +628,10 @@ func handleMsgMhfEnumerateQuest(s *Session, p mhfpacket.MHFPacket) {
 		bf.WriteUint32(vsQuestBets[i].Quantity)
 	}
 
-	bf.WriteUint16(totalCount)
+	bf.WriteUint16(page.Total())
 	bf.WriteUint16(pkt.Offset)
 	_, _ = bf.Seek(0, io.SeekStart)
-	bf.WriteUint16(returnedCount)
+	bf.WriteUint16(page.Returned())
 
 	doAckBufSucceed(s, pkt.AckHandle, bf.Data())
 }
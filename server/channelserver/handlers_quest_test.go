@@ -12,153 +12,6 @@ import (
 	"time"
 )
 
-// TestBackportQuestBasic tests basic quest backport functionality
-func TestBackportQuestBasic(t *testing.T) {
-	tests := []struct {
-		name     string
-		dataSize int
-		verify   func([]byte) bool
-	}{
-		{
-			name:     "minimal_valid_quest_data",
-			dataSize: 500, // Minimum size for valid quest data
-			verify: func(data []byte) bool {
-				// Verify data has expected minimum size
-				if len(data) < 100 {
-					return false
-				}
-				return true
-			},
-		},
-		{
-			name:     "large_quest_data",
-			dataSize: 1000,
-			verify: func(data []byte) bool {
-				return len(data) >= 500
-			},
-		},
-	}
-
-	for _, tc := range tests {
-		t.Run(tc.name, func(t *testing.T) {
-			// Create properly sized quest data
-			// The BackportQuest function expects specific binary format with valid offsets
-			data := make([]byte, tc.dataSize)
-
-			// Set a safe pointer offset (should be within data bounds)
-			offset := uint32(100)
-			binary.LittleEndian.PutUint32(data[0:4], offset)
-
-			// Fill remaining data with pattern
-			for i := 4; i < len(data); i++ {
-				data[i] = byte(i % 256)
-			}
-
-			// BackportQuest may panic with invalid data, so we protect the call
-			defer func() {
-				if r := recover(); r != nil {
-					// Expected with test data - BackportQuest requires valid quest binary format
-					t.Logf("BackportQuest panicked with test data (expected): %v", r)
-				}
-			}()
-
-			result := BackportQuest(data, cfg.ZZ)
-			if result != nil && !tc.verify(result) {
-				t.Errorf("BackportQuest verification failed for result: %d bytes", len(result))
-			}
-		})
-	}
-}
-
-// TestFindSubSliceIndices tests byte slice pattern finding
-func TestFindSubSliceIndices(t *testing.T) {
-	tests := []struct {
-		name     string
-		data     []byte
-		pattern  []byte
-		expected int
-	}{
-		{
-			name:     "single_match",
-			data:     []byte{0x01, 0x02, 0x03, 0x04, 0x05},
-			pattern:  []byte{0x02, 0x03},
-			expected: 1,
-		},
-		{
-			name:     "multiple_matches",
-			data:     []byte{0x01, 0x02, 0x01, 0x02, 0x01, 0x02},
-			pattern:  []byte{0x01, 0x02},
-			expected: 3,
-		},
-		{
-			name:     "no_match",
-			data:     []byte{0x01, 0x02, 0x03},
-			pattern:  []byte{0x04, 0x05},
-			expected: 0,
-		},
-		{
-			name:     "pattern_at_end",
-			data:     []byte{0x01, 0x02, 0x03, 0x04},
-			pattern:  []byte{0x03, 0x04},
-			expected: 1,
-		},
-	}
-
-	for _, tc := range tests {
-		t.Run(tc.name, func(t *testing.T) {
-			result := findSubSliceIndices(tc.data, tc.pattern)
-			if len(result) != tc.expected {
-				t.Errorf("findSubSliceIndices(%v, %v) = %v, want length %d",
-					tc.data, tc.pattern, result, tc.expected)
-			}
-		})
-	}
-}
-
-// TestEqualByteSlices tests byte slice equality check
-func TestEqualByteSlices(t *testing.T) {
-	tests := []struct {
-		name     string
-		a        []byte
-		b        []byte
-		expected bool
-	}{
-		{
-			name:     "equal_slices",
-			a:        []byte{0x01, 0x02, 0x03},
-			b:        []byte{0x01, 0x02, 0x03},
-			expected: true,
-		},
-		{
-			name:     "different_values",
-			a:        []byte{0x01, 0x02, 0x03},
-			b:        []byte{0x01, 0x02, 0x04},
-			expected: false,
-		},
-		{
-			name:     "different_lengths",
-			a:        []byte{0x01, 0x02},
-			b:        []byte{0x01, 0x02, 0x03},
-			expected: false,
-		},
-		{
-			name:     "empty_slices",
-			a:        []byte{},
-			b:        []byte{},
-			expected: true,
-		},
-	}
-
-	for _, tc := range tests {
-		t.Run(tc.name, func(t *testing.T) {
-			result := equal(tc.a, tc.b)
-			if result != tc.expected {
-				t.Errorf("equal(%v, %v) = %v, want %v", tc.a, tc.b, result, tc.expected)
-			}
-		})
-	}
-}
-
 // TestLoadFavoriteQuestWithData tests loading favorite quest when data exists
 func TestLoadFavoriteQuestWithData(t *testing.T) {
 	// Create test session
@@ -680,16 +533,6 @@ func BenchmarkQuestEnumeration(b *testing.B) {
 	}
 }
 
-// BenchmarkBackportQuest benchmarks quest backport performance
-func BenchmarkBackportQuest(b *testing.B) {
-	data := make([]byte, 500)
-	binary.LittleEndian.PutUint32(data[0:4], 100)
-
-	for i := 0; i < b.N; i++ {
-		_ = BackportQuest(data, cfg.ZZ)
-	}
-}
-
 // parseAckFromChannel reads a queued packet from the session's sendPackets channel
 // and parses the ErrorCode from the MsgSysAck wire format.
 func parseAckFromChannel(t *testing.T, s *Session) (errorCode uint8) {
@@ -782,3 +625,54 @@ func TestHandleMsgSysGetFile_ExistingQuestFile(t *testing.T) {
 		t.Errorf("expected success ack (ErrorCode=0) for existing quest file, got ErrorCode=%d", errorCode)
 	}
 }
+
+// testQuestFile builds an unpacked ZZ quest file whose body is at 0x40 and
+// whose strings are all title.
+func testQuestFile(title string) []byte {
+	const body, table = 0x40, 0x40 + questBodyLenZZ
+	data := make([]byte, table+questStringCount*4)
+	binary.LittleEndian.PutUint32(data, body)
+	binary.LittleEndian.PutUint32(data[body+questStringPointerOff:], table)
+	for i := 0; i < questStringCount; i++ {
+		binary.LittleEndian.PutUint32(data[table+i*4:], uint32(len(data)))
+	}
+	return append(data, append([]byte(title), 0)...)
+}
+
+func TestLoadQuestBody(t *testing.T) {
+	body, err := LoadQuestBody(testQuestFile("Quest"), cfg.ZZ, false)
+	if err != nil {
+		t.Fatalf("LoadQuestBody() error: %v", err)
+	}
+	if want := questBodyLenZZ + questStringCount*4 + questStringCount*6; len(body) != want {
+		t.Errorf("len(body) = %d, want %d", len(body), want)
+	}
+	if ptr := binary.LittleEndian.Uint32(body[questStringPointerOff:]); ptr != questBodyLenZZ {
+		t.Errorf("string pointer = %d, want %d", ptr, questBodyLenZZ)
+	}
+
+	// A backported file is converted on a copy.
+	file := testQuestFile("Quest")
+	orig := bytes.Clone(file)
+	if _, err := LoadQuestBody(file, cfg.G101, true); err != nil {
+		t.Fatalf("LoadQuestBody() backported error: %v", err)
+	}
+	if !bytes.Equal(file, orig) {
+		t.Error("backporting changed the caller's file")
+	}
+
+	for _, bad := range [][]byte{nil, testQuestFile("Quest")[:0x100]} {
+		if _, err := LoadQuestBody(bad, cfg.ZZ, false); err == nil {
+			t.Errorf("LoadQuestBody() accepted a %d byte file", len(bad))
+		}
+	}
+}
+
+func TestValidateQuest(t *testing.T) {
+	if err := ValidateQuest(testQuestFile("Quest"), cfg.ZZ, false); err != nil {
+		t.Errorf("ValidateQuest() error: %v", err)
+	}
+	if err := ValidateQuest(testQuestFile(string(bytes.Repeat([]byte("x"), 100))), cfg.ZZ, false); err == nil {
+		t.Error("ValidateQuest() accepted an event quest over the client's limit")
+	}
+}
@@ -95,7 +95,7 @@ func handleMsgSysOperateRegister(s *Session, p mhfpacket.MHFPacket) {
 	s.server.raviente.Unlock()
 	doAckBufSucceed(s, pkt.AckHandle, bf.Data())
 
-	if s.server.erupeConfig.GameplayOptions.LowLatencyRaviente {
+	if s.server.erupeConfig.Live().GameplayOptions.LowLatencyRaviente {
 		s.notifyRavi()
 	}
 }
@@ -135,7 +135,7 @@ func (s *Session) notifyRavi() {
 	raviNotif.WriteUint16(uint16(temp.Opcode()))
 	_ = temp.Build(raviNotif, s.clientContext)
 	raviNotif.WriteUint16(0x0010) // End it.
-	if s.server.erupeConfig.GameplayOptions.LowLatencyRaviente {
+	if s.server.erupeConfig.Live().GameplayOptions.LowLatencyRaviente {
 		for session := range sema.clients {
 			session.QueueSendNonBlocking(raviNotif.Data())
 		}
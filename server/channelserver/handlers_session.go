@@ -9,7 +9,9 @@ import (
 	ps "erupe-ce/common/pascalstring"
 	"erupe-ce/common/stringsupport"
 	cfg "erupe-ce/config"
+	"erupe-ce/network"
 	"erupe-ce/network/mhfpacket"
+	"erupe-ce/server/sessionlog"
 	"fmt"
 	"io"
 	"strings"
@@ -78,17 +80,13 @@ func handleMsgSysLogin(s *Session, p mhfpacket.MHFPacket) {
 
 	if s.captureConn != nil {
 		s.captureConn.SetSessionInfo(s.charID, s.userID)
+		s.discardUntargetedCapture()
 	}
 
 	bf := byteframe.NewByteFrame()
 	bf.WriteUint32(uint32(TimeAdjusted().Unix())) // Unix timestamp
 
-	err = s.server.sessionRepo.UpdatePlayerCount(s.server.ID, len(s.server.sessions))
-	if err != nil {
-		s.logger.Error("Failed to update current players", zap.Error(err))
-		doAckSimpleFail(s, pkt.AckHandle, make([]byte, 4))
-		return
-	}
+	s.server.updatePlayerCountAsync()
 
 	err = s.server.sessionRepo.BindSession(s.token, s.server.ID, s.charID)
 	if err != nil {
@@ -97,11 +95,13 @@ func handleMsgSysLogin(s *Session, p mhfpacket.MHFPacket) {
 		return
 	}
 
-	if err = s.server.charRepo.UpdateLastLogin(s.charID, TimeAdjusted().Unix()); err != nil {
-		s.logger.Error("Failed to update last login", zap.Error(err))
-		doAckSimpleFail(s, pkt.AckHandle, make([]byte, 4))
-		return
-	}
+	charID, lastLogin := s.charID, TimeAdjusted().Unix()
+	s.server.writeAsync(fmt.Sprintf("last_login:%d", charID), func() error {
+		if err := s.server.charRepo.UpdateLastLogin(charID, lastLogin); err != nil {
+			return fmt.Errorf("update last login for char %d: %w", charID, err)
+		}
+		return nil
+	})
 
 	err = s.server.userRepo.SetLastCharacter(s.userID, s.charID)
 	if err != nil {
@@ -110,7 +110,16 @@ func handleMsgSysLogin(s *Session, p mhfpacket.MHFPacket) {
 		return
 	}
 
+	mutedUntil, err := s.server.moderationService.MutedUntil(s.userID)
+	if err != nil {
+		s.logger.Warn("Failed to load chat mute", zap.Error(err))
+	}
+	s.Lock()
+	s.mutedUntil = mutedUntil
+	s.Unlock()
+
 	doAckSimpleSucceed(s, pkt.AckHandle, bf.Data())
+	s.recordEvent(sessionlog.EventCharacterSelect, nil)
 
 	updateRights(s)
 
@@ -179,10 +188,10 @@ func saveAllCharacterData(s *Session, rpToAdd int) error {
 	// Update RP if any gained during session
 	if rpToAdd > 0 {
 		characterSaveData.RP += uint16(rpToAdd)
-		if characterSaveData.RP >= s.server.erupeConfig.GameplayOptions.MaximumRP {
-			characterSaveData.RP = s.server.erupeConfig.GameplayOptions.MaximumRP
+		if characterSaveData.RP >= s.server.erupeConfig.Live().GameplayOptions.MaximumRP {
+			characterSaveData.RP = s.server.erupeConfig.Live().GameplayOptions.MaximumRP
 			s.logger.Debug("RP capped at maximum",
-				zap.Uint16("max_rp", s.server.erupeConfig.GameplayOptions.MaximumRP),
+				zap.Uint16("max_rp", s.server.erupeConfig.Live().GameplayOptions.MaximumRP),
 				zap.Uint32("charID", s.charID),
 			)
 		}
@@ -233,6 +242,10 @@ func logoutPlayer(s *Session) {
 		zap.String("name", s.Name),
 		zap.Duration("session_duration", sessionDuration),
 	)
+	if s.stage != nil && isQuestStage(s.stage.id) {
+		s.recordEvent(sessionlog.EventQuestEnd, map[string]any{"stage": s.stage.id, "disconnected": true})
+	}
+	s.recordEvent(sessionlog.EventDisconnect, map[string]int64{"session_seconds": int64(sessionDuration.Seconds())})
 
 	// Calculate session metrics FIRST (before cleanup)
 	var timePlayed int
@@ -277,11 +290,20 @@ func logoutPlayer(s *Session) {
 			)
 			// Continue with logout even if save fails
 		}
+		if s.server.saveCache != nil {
+			if err := s.server.saveCache.Release(s.charID); err != nil {
+				s.logger.Error("Failed to flush cached savedata during logout", zap.Error(err), zap.Uint32("charID", s.charID))
+			}
+		}
 
 		// Update time_played and guild treasure hunt
-		if err := s.server.charRepo.UpdateTimePlayed(s.charID, timePlayed); err != nil {
-			s.logger.Error("Failed to update time played", zap.Error(err))
-		}
+		charID := s.charID
+		s.server.writeAsync(fmt.Sprintf("time_played:%d", charID), func() error {
+			if err := s.server.charRepo.UpdateTimePlayed(charID, timePlayed); err != nil {
+				return fmt.Errorf("update time played for char %d: %w", charID, err)
+			}
+			return nil
+		})
 		if err := s.server.guildRepo.ClearTreasureHunt(s.charID); err != nil {
 			s.logger.Error("Failed to clear treasure hunt", zap.Error(err))
 		}
@@ -293,18 +315,11 @@ func logoutPlayer(s *Session) {
 	}
 
 	// NOW do cleanup (after save is complete)
-	s.server.Lock()
-	delete(s.server.sessions, s.rawConn)
+	s.server.sessions.Delete(s.rawConn)
 	_ = s.rawConn.Close()
-	s.server.Unlock()
 
-	// Stage cleanup — snapshot sessions first under server mutex, then iterate stages
-	s.server.Lock()
-	sessionSnapshot := make([]*Session, 0, len(s.server.sessions))
-	for _, sess := range s.server.sessions {
-		sessionSnapshot = append(sessionSnapshot, sess)
-	}
-	s.server.Unlock()
+	// Stage cleanup — snapshot sessions first, then iterate stages
+	sessionSnapshot := s.server.sessions.Snapshot()
 
 	s.server.stages.Range(func(_ string, stage *Stage) bool {
 		stage.Lock()
@@ -333,9 +348,7 @@ func logoutPlayer(s *Session) {
 			s.logger.Error("Failed to clear sign session", zap.Error(err))
 		}
 
-		if err := s.server.sessionRepo.UpdatePlayerCount(s.server.ID, len(s.server.sessions)); err != nil {
-			s.logger.Error("Failed to update player count", zap.Error(err))
-		}
+		s.server.updatePlayerCountAsync()
 	}
 
 	if s.stage == nil {
@@ -484,7 +497,7 @@ func handleMsgSysRightsReload(s *Session, p mhfpacket.MHFPacket) {
 func handleMsgMhfTransitMessage(s *Session, p mhfpacket.MHFPacket) {
 	pkt := p.(*mhfpacket.MsgMhfTransitMessage)
 
-	local := strings.Split(s.rawConn.RemoteAddr().String(), ":")[0] == "127.0.0.1"
+	local := network.IsLoopback(s.rawConn.RemoteAddr())
 
 	var maxResults, port, count uint16
 	var cid uint32
@@ -4,6 +4,7 @@ import (
 	"encoding/binary"
 	"errors"
 	"testing"
+	"time"
 
 	"erupe-ce/common/byteframe"
 	cfg "erupe-ce/config"
@@ -47,6 +48,10 @@ func TestHandleMsgSysLogin_Success(t *testing.T) {
 	userRepo := &mockUserRepoGacha{}
 	server.userRepo = userRepo
 
+	mutedUntil := time.Now().Add(time.Hour)
+	server.moderationRepo = &mockModerationRepo{mutedUntil: &mutedUntil}
+	ensureModerationService(server)
+
 	session := createMockSession(0, server)
 
 	pkt := &mhfpacket.MsgSysLogin{
@@ -56,6 +61,9 @@ func TestHandleMsgSysLogin_Success(t *testing.T) {
 	}
 	handleMsgSysLogin(session, pkt)
 
+	if !session.isMuted() {
+		t.Error("Expected chat mute to be loaded at login")
+	}
 	if session.charID != 42 {
 		t.Errorf("Expected charID 42, got %d", session.charID)
 	}
@@ -5,6 +5,8 @@ import (
 	ps "erupe-ce/common/pascalstring"
 	cfg "erupe-ce/config"
 	"erupe-ce/network/mhfpacket"
+	"erupe-ce/server/analytics"
+	"io"
 
 	"go.uber.org/zap"
 )
@@ -29,30 +31,67 @@ func writeShopItems(bf *byteframe.ByteFrame, items []ShopItem, mode cfg.Mode) {
 	bf.WriteUint16(uint16(len(items)))
 	bf.WriteUint16(uint16(len(items)))
 	for _, item := range items {
-		if mode >= cfg.Z2 {
-			bf.WriteUint32(item.ID)
-		}
-		bf.WriteUint32(item.ItemID)
-		bf.WriteUint32(item.Cost)
-		bf.WriteUint16(item.Quantity)
-		bf.WriteUint16(item.MinHR)
-		bf.WriteUint16(item.MinSR)
-		if mode >= cfg.Z2 {
-			bf.WriteUint16(item.MinGR)
-		}
-		bf.WriteUint8(0) // Unk
-		bf.WriteUint8(item.StoreLevel)
-		if mode >= cfg.Z2 {
-			bf.WriteUint16(item.MaxQuantity)
-			bf.WriteUint16(item.UsedQuantity)
-		}
-		if mode == cfg.Z1 {
-			bf.WriteUint8(uint8(item.RoadFloors))
-			bf.WriteUint8(uint8(item.RoadFatalis))
-		} else if mode >= cfg.Z2 {
-			bf.WriteUint16(item.RoadFloors)
-			bf.WriteUint16(item.RoadFatalis)
+		writeShopItem(bf, item, mode)
+	}
+}
+
+// writeShopItemPage writes as many items as fit in a page of at most limit
+// items, without building the truncated list first.
+func writeShopItemPage(bf *byteframe.ByteFrame, items []ShopItem, mode cfg.Mode, limit int) {
+	start := bf.Index()
+	bf.WriteUint16(0)
+	bf.WriteUint16(0)
+	page := newListPage(bf, 0, limit, listPageMaxBytes)
+	size := shopItemSize(mode)
+	for _, item := range items {
+		if !page.Next(size) {
+			break
 		}
+		writeShopItem(bf, item, mode)
+	}
+	end := bf.Index()
+	_, _ = bf.Seek(int64(start), io.SeekStart)
+	bf.WriteUint16(page.Returned())
+	bf.WriteUint16(page.Returned())
+	_, _ = bf.Seek(int64(end), io.SeekStart)
+}
+
+func writeShopItem(bf *byteframe.ByteFrame, item ShopItem, mode cfg.Mode) {
+	if mode >= cfg.Z2 {
+		bf.WriteUint32(item.ID)
+	}
+	bf.WriteUint32(item.ItemID)
+	bf.WriteUint32(item.Cost)
+	bf.WriteUint16(item.Quantity)
+	bf.WriteUint16(item.MinHR)
+	bf.WriteUint16(item.MinSR)
+	if mode >= cfg.Z2 {
+		bf.WriteUint16(item.MinGR)
+	}
+	bf.WriteUint8(0) // Unk
+	bf.WriteUint8(item.StoreLevel)
+	if mode >= cfg.Z2 {
+		bf.WriteUint16(item.MaxQuantity)
+		bf.WriteUint16(item.UsedQuantity)
+	}
+	if mode == cfg.Z1 {
+		bf.WriteUint8(uint8(item.RoadFloors))
+		bf.WriteUint8(uint8(item.RoadFatalis))
+	} else if mode >= cfg.Z2 {
+		bf.WriteUint16(item.RoadFloors)
+		bf.WriteUint16(item.RoadFatalis)
+	}
+}
+
+// shopItemSize returns the number of bytes writeShopItem writes per item.
+func shopItemSize(mode cfg.Mode) int {
+	switch {
+	case mode >= cfg.Z2:
+		return 30
+	case mode == cfg.Z1:
+		return 18
+	default:
+		return 16
 	}
 }
 
@@ -189,10 +228,7 @@ func handleMsgMhfEnumerateShop(s *Session, p mhfpacket.MHFPacket) {
 	case 10: // Item shop, 0-8
 		bf := byteframe.NewByteFrame()
 		items := getShopItems(s, pkt.ShopType, pkt.ShopID)
-		if len(items) > int(pkt.Limit) {
-			items = items[:pkt.Limit]
-		}
-		writeShopItems(bf, items, s.server.erupeConfig.RealClientMode)
+		writeShopItemPage(bf, items, s.server.erupeConfig.RealClientMode, int(pkt.Limit))
 		doAckBufSucceed(s, pkt.AckHandle, bf.Data())
 	}
 }
@@ -210,6 +246,7 @@ func handleMsgMhfAcquireExchangeShop(s *Session, p mhfpacket.MHFPacket) {
 		if err := s.server.shopRepo.RecordPurchase(s.charID, itemHash, buyCount); err != nil {
 			s.logger.Error("Failed to update shop item purchase count", zap.Error(err))
 		}
+		s.countShopPurchase(itemHash, buyCount)
 	}
 	doAckSimpleSucceed(s, pkt.AckHandle, []byte{0x00, 0x00, 0x00, 0x00})
 }
@@ -239,6 +276,7 @@ func handleMsgMhfExchangeFpoint2Item(s *Session, p mhfpacket.MHFPacket) {
 		doAckSimpleFail(s, pkt.AckHandle, nil)
 		return
 	}
+	s.server.analytics.Add(analytics.FPointSink, analytics.KeyExchange, int64(cost))
 	bf := byteframe.NewByteFrame()
 	bf.WriteUint32(balance)
 	doAckSimpleSucceed(s, pkt.AckHandle, bf.Data())
@@ -259,6 +297,7 @@ func handleMsgMhfExchangeItem2Fpoint(s *Session, p mhfpacket.MHFPacket) {
 		doAckSimpleFail(s, pkt.AckHandle, nil)
 		return
 	}
+	s.server.analytics.Add(analytics.FPointSource, analytics.KeyExchange, int64(cost))
 	bf := byteframe.NewByteFrame()
 	bf.WriteUint32(balance)
 	doAckSimpleSucceed(s, pkt.AckHandle, bf.Data())
@@ -36,7 +36,9 @@ func doStageTransfer(s *Session, ackHandle uint32, stageID string) {
 	stage.Unlock()
 
 	// Ensure this session no longer belongs to reservations.
+	var prevStageID string
 	if s.stage != nil {
+		prevStageID = s.stage.id
 		removeSessionFromStage(s)
 	}
 
@@ -44,6 +46,8 @@ func doStageTransfer(s *Session, ackHandle uint32, stageID string) {
 	s.Lock()
 	s.stage = stage
 	s.Unlock()
+	s.recordStageEvents(prevStageID, stageID)
+	s.countQuestReturn(prevStageID, stageID)
 
 	// Tell the client to cleanup its current stage objects.
 	// Use blocking send to ensure this critical cleanup packet is not dropped.
@@ -58,17 +62,14 @@ func doStageTransfer(s *Session, ackHandle uint32, stageID string) {
 	if !s.loaded {
 		s.loaded = true
 
-		// Lock server to safely iterate over sessions map
-		// We need to copy the session list first to avoid holding the lock during packet building
-		s.server.Lock()
+		// Copy the session list first so packets are built from a stable set
 		var sessionList []*Session
-		for _, session := range s.server.sessions {
+		for _, session := range s.server.sessions.Snapshot() {
 			if s == session || !session.loaded {
 				continue
 			}
 			sessionList = append(sessionList, session)
 		}
-		s.server.Unlock()
 
 		// Build packets for each session without holding the lock
 		var temp mhfpacket.MHFPacket
@@ -2,7 +2,6 @@ package channelserver
 
 import (
 	"bytes"
-	"net"
 	"sync"
 	"testing"
 	"time"
@@ -242,7 +241,7 @@ func TestEnumerateStage(t *testing.T) {
 	mock := &MockCryptConn{sentPackets: make([][]byte, 0)}
 	s := createTestSession(mock)
 
-	s.server.sessions = make(map[net.Conn]*Session)
+	s.server.sessions = SessionMap{}
 
 	// Create multiple stages
 	for i := 0; i < 3; i++ {
@@ -327,7 +326,7 @@ func TestStageTransferBasic(t *testing.T) {
 	mock := &MockCryptConn{sentPackets: make([][]byte, 0)}
 	s := createTestSession(mock)
 
-	s.server.sessions = make(map[net.Conn]*Session)
+	s.server.sessions = SessionMap{}
 
 	// Transfer to non-existent stage (should create it)
 	doStageTransfer(s, 0x12345678, "new_transfer_stage")
@@ -357,7 +356,7 @@ func TestEnterStageBasic(t *testing.T) {
 	mock := &MockCryptConn{sentPackets: make([][]byte, 0)}
 	s := createTestSession(mock)
 
-	s.server.sessions = make(map[net.Conn]*Session)
+	s.server.sessions = SessionMap{}
 
 	stage := NewStage("entry_stage")
 	stage.clients = make(map[*Session]uint32)
@@ -383,7 +382,7 @@ func TestMoveStagePreservesData(t *testing.T) {
 	mock := &MockCryptConn{sentPackets: make([][]byte, 0)}
 	s := createTestSession(mock)
 
-	s.server.sessions = make(map[net.Conn]*Session)
+	s.server.sessions = SessionMap{}
 
 	// Create source stage with binary data
 	sourceStage := NewStage("source_stage")
@@ -458,7 +457,7 @@ func TestBackStageNavigation(t *testing.T) {
 	mock := &MockCryptConn{sentPackets: make([][]byte, 0)}
 	s := createTestSession(mock)
 
-	s.server.sessions = make(map[net.Conn]*Session)
+	s.server.sessions = SessionMap{}
 
 	// Create a stringstack for stage move history
 	ss := stringstack.New()
@@ -501,7 +500,7 @@ func TestRaceConditionRemoveSessionFromStageNotLocked(t *testing.T) {
 	mock := &MockCryptConn{sentPackets: make([][]byte, 0)}
 	s := createTestSession(mock)
 
-	s.server.sessions = make(map[net.Conn]*Session)
+	s.server.sessions = SessionMap{}
 
 	stage := NewStage("race_test_stage")
 	stage.clients = make(map[*Session]uint32)
@@ -566,7 +565,7 @@ func TestRaceConditionDoStageTransferUnlockedAccess(t *testing.T) {
 	mock := &MockCryptConn{sentPackets: make([][]byte, 0)}
 	baseSession := createTestSession(mock)
 
-	baseSession.server.sessions = make(map[net.Conn]*Session)
+	baseSession.server.sessions = SessionMap{}
 
 	// Create initial stage
 	stage := NewStage("initial_stage")
@@ -630,7 +629,7 @@ func TestRaceConditionStageObjectsIteration(t *testing.T) {
 	mock := &MockCryptConn{sentPackets: make([][]byte, 0)}
 	baseSession := createTestSession(mock)
 
-	baseSession.server.sessions = make(map[net.Conn]*Session)
+	baseSession.server.sessions = SessionMap{}
 
 	stage := NewStage("object_race_stage")
 	stage.clients = make(map[*Session]uint32)
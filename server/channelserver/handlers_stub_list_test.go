@@ -0,0 +1,90 @@
+package channelserver
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"slices"
+	"strings"
+	"testing"
+
+	"erupe-ce/network"
+)
+
+// TestStubHandlerList checks stubHandlers against the handlers in the
+// package's source that have empty bodies.
+func TestStubHandlerList(t *testing.T) {
+	fset := token.NewFileSet()
+	pkgs, err := parser.ParseDir(fset, ".", func(fi fs.FileInfo) bool {
+		return !strings.HasSuffix(fi.Name(), "_test.go")
+	}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	empty := map[string]bool{}
+	registered := map[string]string{} // Opcode name to handler name
+	for _, f := range pkgs["channelserver"].Files {
+		ast.Inspect(f, func(n ast.Node) bool {
+			switch n := n.(type) {
+			case *ast.FuncDecl:
+				if n.Recv == nil && n.Body != nil && len(n.Body.List) == 0 {
+					empty[n.Name.Name] = true
+				}
+			case *ast.AssignStmt:
+				ix, ok := n.Lhs[0].(*ast.IndexExpr)
+				if !ok {
+					return true
+				}
+				table, ok := ix.X.(*ast.Ident)
+				op, ok2 := ix.Index.(*ast.SelectorExpr)
+				handler, ok3 := n.Rhs[0].(*ast.Ident)
+				if ok && ok2 && ok3 && table.Name == "handlerTable" {
+					registered[op.Sel.Name] = handler.Name
+				}
+			}
+			return true
+		})
+	}
+	if len(registered) != len(buildHandlerTable()) {
+		t.Fatalf("found %d handler registrations in the source, the table has %d", len(registered), len(buildHandlerTable()))
+	}
+
+	var want, got []string
+	for op, handler := range registered {
+		if empty[handler] {
+			want = append(want, op)
+		}
+	}
+	for id := range stubHandlers {
+		got = append(got, id.String())
+	}
+	slices.Sort(want)
+	slices.Sort(got)
+	for _, op := range want {
+		if !slices.Contains(got, op) {
+			t.Errorf("%s has an empty handler but is missing from stubHandlers", op)
+		}
+	}
+	for _, op := range got {
+		if !slices.Contains(want, op) {
+			t.Errorf("%s is in stubHandlers but its handler is not empty", op)
+		}
+	}
+}
+
+func TestHandler(t *testing.T) {
+	tests := []struct {
+		id   network.PacketID
+		want HandlerState
+	}{
+		{network.MSG_SYS_PING, HandlerImplemented},
+		{network.MSG_SYS_reserve55, HandlerStub},
+		{network.PacketID(0xFFFF), HandlerMissing},
+	}
+	for _, tt := range tests {
+		if got := Handler(tt.id); got != tt.want {
+			t.Errorf("Handler(%s) = %s, want %s", tt.id, got, tt.want)
+		}
+	}
+}
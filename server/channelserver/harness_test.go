@@ -0,0 +1,241 @@
+package channelserver
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"erupe-ce/common/byteframe"
+	"erupe-ce/network"
+	"erupe-ce/network/mhfpacket"
+)
+
+// handlerHarness runs handlers against a mock server and a session on a
+// fake connection, and decodes the packets they queue so tests can assert on
+// fields instead of raw bytes:
+//
+//	h := newHandlerHarness(t)
+//	h.Handle(&mhfpacket.MsgMhfGetEarthStatus{AckHandle: 1})
+//	h.Next().ExpectAck(1).ExpectSuccess()
+type handlerHarness struct {
+	t       testing.TB
+	Server  *Server
+	Session *Session
+	Conn    *mockConn
+}
+
+// newHandlerHarness creates a harness with a mock server and a session for
+// character 1.
+func newHandlerHarness(t testing.TB) *handlerHarness {
+	t.Helper()
+	server := createMockServer()
+	conn := &mockConn{}
+	session := createMockSession(1, server)
+	session.rawConn = conn
+	server.sessions.Store(conn, session)
+	return &handlerHarness{t: t, Server: server, Session: session, Conn: conn}
+}
+
+// Handle dispatches pkt through the server's handler table, as the packet
+// loop does for a packet read from the client.
+func (h *handlerHarness) Handle(pkt mhfpacket.MHFPacket) {
+	h.t.Helper()
+	handler, ok := h.Server.handlerTable[pkt.Opcode()]
+	if !ok {
+		h.t.Fatalf("no handler registered for %s", pkt.Opcode())
+	}
+	handler(h.Session, pkt)
+}
+
+// Sent returns every packet queued on the session since the last call,
+// decoded.
+func (h *handlerHarness) Sent() []*sentPacket {
+	h.t.Helper()
+	var out []*sentPacket
+	for {
+		p, ok := h.take()
+		if !ok {
+			return out
+		}
+		out = append(out, p)
+	}
+}
+
+// Next returns the next queued packet, failing the test if there is none.
+func (h *handlerHarness) Next() *sentPacket {
+	h.t.Helper()
+	p, ok := h.take()
+	if !ok {
+		h.t.Fatal("no packet queued")
+	}
+	return p
+}
+
+func (h *handlerHarness) take() (*sentPacket, bool) {
+	h.t.Helper()
+	select {
+	case p := <-h.Session.sendPackets:
+		data := p.data
+		if p.coalesceKey != 0 {
+			data = h.Session.takeCoalesced(p.coalesceKey, data)
+		}
+		return h.decode(data), true
+	default:
+		return nil, false
+	}
+}
+
+// ExpectNone fails the test if any packet is queued.
+func (h *handlerHarness) ExpectNone() {
+	h.t.Helper()
+	if sent := h.Sent(); len(sent) > 0 {
+		h.t.Errorf("expected no packets, got %d starting with %s", len(sent), sent[0].Opcode)
+	}
+}
+
+func (h *handlerHarness) decode(data []byte) *sentPacket {
+	h.t.Helper()
+	if len(data) < 2 {
+		h.t.Fatalf("queued packet of %d bytes has no opcode", len(data))
+	}
+	bf := byteframe.NewByteFrameFromBytes(data)
+	p := &sentPacket{t: h.t, Opcode: network.PacketID(bf.ReadUint16()), Body: data[2:]}
+	if pkt := mhfpacket.FromOpcode(p.Opcode); pkt != nil {
+		p.ParseErr = parseSafely(pkt, bf, h.Session)
+		if p.ParseErr == nil {
+			p.Packet = pkt
+		}
+	} else {
+		p.ParseErr = fmt.Errorf("no packet type for %s", p.Opcode)
+	}
+	return p
+}
+
+// parseSafely parses a packet that may only have been written to be built,
+// turning a panic in its Parse into an error.
+func parseSafely(pkt mhfpacket.MHFPacket, bf *byteframe.ByteFrame, s *Session) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("parse %s: %v", pkt.Opcode(), r)
+		}
+	}()
+	return pkt.Parse(bf, s.clientContext)
+}
+
+// sentPacket is a packet queued by a handler. Its Expect methods report
+// failures without stopping the test and return the packet for chaining.
+type sentPacket struct {
+	t        testing.TB
+	Opcode   network.PacketID
+	Body     []byte              // Payload after the opcode
+	Packet   mhfpacket.MHFPacket // Decoded payload, nil if it could not be parsed
+	ParseErr error
+}
+
+// ExpectOpcode checks the packet's opcode.
+func (p *sentPacket) ExpectOpcode(want network.PacketID) *sentPacket {
+	p.t.Helper()
+	if p.Opcode != want {
+		p.t.Errorf("opcode = %s, want %s", p.Opcode, want)
+	}
+	return p
+}
+
+// ExpectField checks an exported field of the decoded packet. want may be an
+// untyped constant; it is converted to the field's type before comparing.
+func (p *sentPacket) ExpectField(name string, want any) *sentPacket {
+	p.t.Helper()
+	if p.Packet == nil {
+		p.t.Errorf("%s: cannot check field %s of a packet that was not decoded: %v", p.Opcode, name, p.ParseErr)
+		return p
+	}
+	field := reflect.Indirect(reflect.ValueOf(p.Packet)).FieldByName(name)
+	if !field.IsValid() {
+		p.t.Errorf("%s has no field %s", p.Opcode, name)
+		return p
+	}
+	w := reflect.ValueOf(want)
+	if w.IsValid() && w.Type() != field.Type() && w.Type().ConvertibleTo(field.Type()) {
+		w = w.Convert(field.Type())
+	}
+	if !w.IsValid() || !reflect.DeepEqual(field.Interface(), w.Interface()) {
+		p.t.Errorf("%s.%s = %v, want %v", p.Opcode, name, field.Interface(), want)
+	}
+	return p
+}
+
+// ExpectAck checks that the packet is an ack for ackHandle.
+func (p *sentPacket) ExpectAck(ackHandle uint32) *sentPacket {
+	p.t.Helper()
+	return p.ExpectOpcode(network.MSG_SYS_ACK).ExpectField("AckHandle", ackHandle)
+}
+
+// ExpectSuccess checks that an ack reports success.
+func (p *sentPacket) ExpectSuccess() *sentPacket {
+	p.t.Helper()
+	return p.ExpectField("ErrorCode", 0)
+}
+
+// ExpectFailure checks that an ack reports failure.
+func (p *sentPacket) ExpectFailure() *sentPacket {
+	p.t.Helper()
+	return p.ExpectField("ErrorCode", 1)
+}
+
+// AckData returns a reader over the data of an ack, failing the test if the
+// packet is not one.
+func (p *sentPacket) AckData() *byteframe.ByteFrame {
+	p.t.Helper()
+	ack, ok := p.Packet.(*mhfpacket.MsgSysAck)
+	if !ok {
+		p.t.Fatalf("%s is not a decoded ack: %v", p.Opcode, p.ParseErr)
+	}
+	return byteframe.NewByteFrameFromBytes(ack.AckData)
+}
+
+// recordingTB collects the failures reported through it.
+type recordingTB struct {
+	testing.TB
+	errors []string
+}
+
+func (r *recordingTB) Helper() {}
+
+func (r *recordingTB) Errorf(format string, args ...any) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func TestHandlerHarness_DecodesAcks(t *testing.T) {
+	h := newHandlerHarness(t)
+	h.Server.erupeConfig.EarthID = 7
+	h.Handle(&mhfpacket.MsgMhfGetEarthValue{AckHandle: 12345, ReqType: 1})
+
+	data := h.Next().ExpectAck(12345).ExpectSuccess().ExpectField("IsBufferResponse", true).AckData()
+	if id := data.ReadUint32(); id != 7 {
+		t.Errorf("earth ID = %d, want 7", id)
+	}
+	h.ExpectNone()
+}
+
+func TestHandlerHarness_ReportsMismatches(t *testing.T) {
+	h := newHandlerHarness(t)
+	doAckSimpleFail(h.Session, 5, nil)
+	doAckSimpleSucceed(h.Session, 6, nil)
+
+	rec := &recordingTB{}
+	sent := h.Sent()
+	if len(sent) != 2 {
+		t.Fatalf("Sent() returned %d packets, want 2", len(sent))
+	}
+	sent[0].t = rec
+	sent[0].ExpectAck(6).ExpectSuccess().ExpectField("Missing", 1).ExpectOpcode(network.MSG_SYS_PING)
+	if len(rec.errors) != 4 {
+		t.Errorf("recorded %d failures, want 4: %q", len(rec.errors), rec.errors)
+	}
+	sent[1].t = rec
+	rec.errors = nil
+	sent[1].ExpectAck(6).ExpectSuccess()
+	if len(rec.errors) != 0 {
+		t.Errorf("recorded failures for a matching packet: %q", rec.errors)
+	}
+}
@@ -0,0 +1,171 @@
+package channelserver
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"erupe-ce/common/byteframe"
+	"erupe-ce/common/stringsupport"
+	cfg "erupe-ce/config"
+	"erupe-ce/network/mhfpacket"
+
+	"go.uber.org/zap"
+)
+
+// Benchmarks for the packet paths a busy channel spends its time in. Record
+// a baseline before a performance change and compare after it with
+// cmd/benchrec; see CONTRIBUTING.md.
+
+// drainSent empties the session's send queue.
+func drainSent(s *Session) {
+	for len(s.sendPackets) > 0 {
+		<-s.sendPackets
+	}
+}
+
+// BenchmarkHandlePacketGroup measures the receive path from a decrypted
+// packet group to its handlers: opcode lookup, parsing, dispatch and the
+// recursion into the rest of the group.
+func BenchmarkHandlePacketGroup(b *testing.B) {
+	h := newHandlerHarness(b)
+	h.Session.ackStart = make(map[uint32]time.Time)
+	ctx := h.Session.clientContext
+	var group []byte
+	group = append(group, buildPacket(&mhfpacket.MsgSysPing{AckHandle: 1}, ctx)...)
+	group = append(group, buildPacket(&mhfpacket.MsgSysTime{Timestamp: 1}, ctx)...)
+	group = append(group, buildPacket(&mhfpacket.MsgSysPing{AckHandle: 2}, ctx)...)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		h.Session.handlePacketGroup(group)
+		drainSent(h.Session)
+	}
+}
+
+// BenchmarkSaveDataParse measures decompressing a ZZ save and reading its
+// fields, which every save and character load does.
+func BenchmarkSaveDataParse(b *testing.B) {
+	comp, err := SaveFixture{Name: "Bench", HR: 999, GR: 500, RP: 100}.Compressed()
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		save := &CharacterSaveData{Mode: cfg.ZZ, compSave: comp}
+		if err := save.Decompress(); err != nil {
+			b.Fatal(err)
+		}
+		save.updateStructWithSaveData()
+	}
+}
+
+// BenchmarkHandleSavedata measures a full blob save: loading the stored
+// save, decompressing the client's, parsing, recompressing and persisting.
+func BenchmarkHandleSavedata(b *testing.B) {
+	fixture := SaveFixture{Name: "Bench", HR: 999, GR: 500, RP: 100}
+	stored, err := fixture.Compressed()
+	if err != nil {
+		b.Fatal(err)
+	}
+	fixture.RP = 200
+	payload, err := fixture.Compressed()
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	h := newHandlerHarness(b)
+	repo := newMockCharacterRepo()
+	repo.loadSaveDataID = 1
+	repo.loadSaveDataData = stored
+	repo.loadSaveDataName = "Bench"
+	h.Server.charRepo = repo
+	h.Session.Name = "Bench"
+	h.Session.logger = zap.NewNop() // Every save logs at info
+	pkt := &mhfpacket.MsgMhfSavedata{AckHandle: 1, RawDataPayload: payload}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		handleMsgMhfSavedata(h.Session, pkt)
+		drainSent(h.Session)
+	}
+	b.StopTimer()
+	if repo.saveCharacterDataCalls != b.N {
+		b.Fatalf("saved %d times, want %d", repo.saveCharacterDataCalls, b.N)
+	}
+}
+
+// BenchmarkHandleLoaddata measures sending a stored save back to the client
+// and reading the character name out of it.
+func BenchmarkHandleLoaddata(b *testing.B) {
+	stored, err := SaveFixture{Name: "Bench", HR: 999, GR: 500}.Compressed()
+	if err != nil {
+		b.Fatal(err)
+	}
+	h := newHandlerHarness(b)
+	repo := newMockCharacterRepo()
+	repo.columns["savedata"] = stored
+	h.Server.charRepo = repo
+	h.Server.userBinary = NewUserBinaryStore()
+	pkt := &mhfpacket.MsgMhfLoaddata{AckHandle: 1}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		handleMsgMhfLoaddata(h.Session, pkt)
+		drainSent(h.Session)
+	}
+}
+
+// BenchmarkEnumerateGuild measures building the guild search list from 500
+// guilds, by name and sorted by member count.
+func BenchmarkEnumerateGuild(b *testing.B) {
+	guilds := make([]*Guild, 500)
+	for i := range guilds {
+		guilds[i] = &Guild{
+			ID:          uint32(i + 1),
+			Name:        fmt.Sprintf("Guild %03d", i),
+			MemberCount: uint16(i*7%60 + 1),
+			CreatedAt:   time.Unix(int64(1700000000+i), 0),
+		}
+		guilds[i].LeaderCharID = uint32(i + 1)
+		guilds[i].LeaderName = fmt.Sprintf("Leader %03d", i)
+	}
+
+	b.Run("name", func(b *testing.B) {
+		h := newHandlerHarness(b)
+		h.Server.guildRepo = &mockGuildRepo{allGuilds: guilds}
+		search := append(stringsupport.UTF8ToSJIS("Guild 1"), 0)
+
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			handleMsgMhfEnumerateGuild(h.Session, &mhfpacket.MsgMhfEnumerateGuild{
+				AckHandle: 1,
+				Type:      mhfpacket.ENUMERATE_GUILD_TYPE_GUILD_NAME,
+				Data2:     byteframe.NewByteFrameFromBytes(search),
+			})
+			drainSent(h.Session)
+		}
+	})
+	b.Run("members", func(b *testing.B) {
+		h := newHandlerHarness(b)
+		h.Server.guildRepo = &mockGuildRepo{allGuilds: guilds}
+
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			// Alternate the order so every iteration really sorts.
+			handleMsgMhfEnumerateGuild(h.Session, &mhfpacket.MsgMhfEnumerateGuild{
+				AckHandle: 1,
+				Type:      mhfpacket.ENUMERATE_GUILD_TYPE_ORDER_MEMBERS,
+				Sorting:   i%2 == 0,
+			})
+			drainSent(h.Session)
+		}
+	})
+}
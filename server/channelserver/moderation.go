@@ -0,0 +1,66 @@
+package channelserver
+
+import (
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// BanUser bans a user until expires, or permanently if expires is nil, and
+// disconnects them. Bans issued in-game are mirrored to Discord.
+func (s *Server) BanUser(userID uint32, expires *time.Time, source, actor string) error {
+	if err := s.moderationService.Ban(userID, expires, source, actor); err != nil {
+		return err
+	}
+	s.DisconnectUser(userID)
+	if source == ModerationSourceGame && s.discordBot != nil {
+		go s.discordBot.MirrorGameBan(userID, expires)
+	}
+	return nil
+}
+
+// UnbanUser lifts a user's ban. Unbans issued in-game are mirrored to Discord.
+func (s *Server) UnbanUser(userID uint32, source, actor string) error {
+	if err := s.moderationService.Unban(userID, source, actor); err != nil {
+		return err
+	}
+	if source == ModerationSourceGame && s.discordBot != nil {
+		go s.discordBot.MirrorGameUnban(userID)
+	}
+	return nil
+}
+
+// MuteUser prevents a user from chatting until the given time, applying the
+// mute to any of their sessions that are online.
+func (s *Server) MuteUser(userID uint32, until time.Time, source, actor string) error {
+	if err := s.moderationService.Mute(userID, until, source, actor); err != nil {
+		return err
+	}
+	s.setMutedUntil(userID, until)
+	return nil
+}
+
+// UnmuteUser lifts a user's chat mute.
+func (s *Server) UnmuteUser(userID uint32, source, actor string) error {
+	if err := s.moderationService.Unmute(userID, source, actor); err != nil {
+		return err
+	}
+	s.setMutedUntil(userID, time.Time{})
+	return nil
+}
+
+// setMutedUntil updates the mute on every online session of the user.
+func (s *Server) setMutedUntil(userID uint32, until time.Time) {
+	cids, err := s.charRepo.GetCharIDsByUserID(userID)
+	if err != nil {
+		s.logger.Error("Failed to query characters for mute", zap.Error(err))
+		return
+	}
+	for _, cid := range cids {
+		if session := s.Registry.FindSessionByCharID(cid); session != nil {
+			session.Lock()
+			session.mutedUntil = until
+			session.Unlock()
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package questfile
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	cfg "erupe-ce/config"
+)
+
+// rewardTableBase is where the reward pointers Backport compacts sit, from
+// the body pointer.
+const rewardTableBase = uint32(96)
+
+// Backport converts an unpacked ZZ quest file in place to the layout of an
+// older client, mode, as AutoQuestBackport does when serving it. It returns
+// an error, changing nothing, when the file is too short to convert.
+func Backport(data []byte, mode cfg.Mode) ([]byte, error) {
+	if len(data) < 4 {
+		return nil, fmt.Errorf("quest file too short: %d bytes", len(data))
+	}
+	fillLength := backportFill(mode)
+	// The reward pointers are compacted from rp, 4 bytes past wp, in six
+	// steps of 8 bytes, and the fill copied from where rp ends.
+	if end := int(binary.LittleEndian.Uint32(data)) + int(rewardTableBase) + 4 + 5*8 + int(fillLength); end > len(data) {
+		return nil, fmt.Errorf("quest body runs past the %d byte file, %d bytes are converted", len(data), end)
+	}
+	wp := binary.LittleEndian.Uint32(data[0:4]) + rewardTableBase
+	rp := wp + 4
+	for i := uint32(0); i < 6; i++ {
+		if i != 0 {
+			wp += 4
+			rp += 8
+		}
+		copy(data[wp:wp+4], data[rp:rp+4])
+	}
+
+	copy(data[wp:wp+fillLength], data[rp:rp+fillLength])
+	if mode <= cfg.G91 {
+		patterns := [][]byte{
+			{0x0A, 0x00, 0x01, 0x33, 0xD7, 0x00}, // 10% Armor Sphere -> Stone
+			{0x06, 0x00, 0x02, 0x33, 0xD8, 0x00}, // 6% Armor Sphere+ -> Iron Ore
+			{0x0A, 0x00, 0x03, 0x33, 0xD7, 0x00}, // 10% Adv Armor Sphere -> Stone
+			{0x06, 0x00, 0x04, 0x33, 0xDB, 0x00}, // 6% Hard Armor Sphere -> Dragonite Ore
+			{0x0A, 0x00, 0x05, 0x33, 0xD9, 0x00}, // 10% Heaven Armor Sphere -> Earth Crystal
+			{0x06, 0x00, 0x06, 0x33, 0xDB, 0x00}, // 6% True Armor Sphere -> Dragonite Ore
+		}
+		for i := range patterns {
+			j := findSubSliceIndices(data, patterns[i][0:4])
+			for k := range j {
+				copy(data[j[k]+2:j[k]+4], patterns[i][4:6])
+			}
+		}
+	}
+
+	if mode <= cfg.S6 {
+		binary.LittleEndian.PutUint32(data[16:20], binary.LittleEndian.Uint32(data[8:12]))
+	}
+	return data, nil
+}
+
+// backportFill returns how many bytes past the reward pointers a client of
+// mode reads.
+func backportFill(mode cfg.Mode) uint32 {
+	switch {
+	case mode <= cfg.S6:
+		return 44
+	case mode <= cfg.F5:
+		return 52
+	case mode <= cfg.G101:
+		return 76
+	}
+	return 108
+}
+
+func findSubSliceIndices(data []byte, sub []byte) []int {
+	var indices []int
+	lenSub := len(sub)
+	for i := 0; i < len(data); i++ {
+		if i+lenSub > len(data) {
+			break
+		}
+		if equal(data[i:i+lenSub], sub) {
+			indices = append(indices, i)
+		}
+	}
+	return indices
+}
+
+func equal(a, b []byte) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i, v := range a {
+		if v != b[i] {
+			return false
+		}
+	}
+	return true
+}
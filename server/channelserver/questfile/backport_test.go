@@ -0,0 +1,269 @@
+package questfile
+
+import (
+	"encoding/binary"
+	"testing"
+
+	cfg "erupe-ce/config"
+)
+
+func TestBackport_Basic(t *testing.T) {
+	// Create a quest data buffer large enough for Backport to work with.
+	// The function reads a uint32 from data[0:4] as offset, then works at offset+96.
+	// We need at least offset + 96 + 108 + 6*8 bytes.
+	// Set offset (wp base) = 0, so wp starts at 96, rp at 100.
+	data := make([]byte, 512)
+	binary.LittleEndian.PutUint32(data[0:4], 0) // offset = 0
+
+	// Fill some data at the rp positions so we can verify copies
+	for i := 100; i < 400; i++ {
+		data[i] = byte(i & 0xFF)
+	}
+
+	result, err := Backport(data, cfg.ZZ)
+	if err != nil {
+		t.Fatalf("Backport() error: %v", err)
+	}
+	if result == nil {
+		t.Fatal("Backport returned nil")
+	}
+	if len(result) != len(data) {
+		t.Errorf("BackportQuest changed data length: got %d, want %d", len(result), len(data))
+	}
+}
+
+func TestBackport_S6Mode(t *testing.T) {
+	data := make([]byte, 512)
+	binary.LittleEndian.PutUint32(data[0:4], 0)
+
+	for i := 0; i < len(data); i++ {
+		data[i+4] = byte(i % 256)
+		if i+4 >= len(data)-1 {
+			break
+		}
+	}
+
+	// Set some values at data[8:12] so we can check they get copied to data[16:20]
+	binary.LittleEndian.PutUint32(data[8:12], 0xDEADBEEF)
+
+	result, err := Backport(data, cfg.S6)
+	if err != nil {
+		t.Fatalf("Backport() error: %v", err)
+	}
+	if result == nil {
+		t.Fatal("Backport returned nil")
+	}
+
+	// In S6 mode, data[16:20] should be copied from data[8:12]
+	got := binary.LittleEndian.Uint32(result[16:20])
+	if got != 0xDEADBEEF {
+		t.Errorf("S6 mode: data[16:20] = 0x%X, want 0xDEADBEEF", got)
+	}
+}
+
+func TestBackport_G91Mode_PatternReplacement(t *testing.T) {
+	data := make([]byte, 512)
+	binary.LittleEndian.PutUint32(data[0:4], 0)
+
+	// Insert an armor sphere pattern at a known location
+	// Pattern: 0x0A, 0x00, 0x01, 0x33 -> should replace bytes at +2 with 0xD7, 0x00
+	offset := 300
+	data[offset] = 0x0A
+	data[offset+1] = 0x00
+	data[offset+2] = 0x01
+	data[offset+3] = 0x33
+
+	result, err := Backport(data, cfg.G91)
+	if err != nil {
+		t.Fatalf("Backport() error: %v", err)
+	}
+
+	// After BackportQuest, the pattern's last 2 bytes should be replaced
+	if result[offset+2] != 0xD7 || result[offset+3] != 0x00 {
+		t.Errorf("G91 pattern replacement failed: got [0x%X, 0x%X], want [0xD7, 0x00]",
+			result[offset+2], result[offset+3])
+	}
+}
+
+func TestBackport_F5Mode(t *testing.T) {
+	data := make([]byte, 512)
+	binary.LittleEndian.PutUint32(data[0:4], 0)
+
+	result, err := Backport(data, cfg.F5)
+	if err != nil {
+		t.Fatalf("Backport() error: %v", err)
+	}
+	if result == nil {
+		t.Fatal("Backport returned nil")
+	}
+}
+
+func TestBackport_G101Mode(t *testing.T) {
+	data := make([]byte, 512)
+	binary.LittleEndian.PutUint32(data[0:4], 0)
+
+	result, err := Backport(data, cfg.G101)
+	if err != nil {
+		t.Fatalf("Backport() error: %v", err)
+	}
+	if result == nil {
+		t.Fatal("Backport returned nil")
+	}
+}
+
+// TestBackportBasic tests basic quest backport functionality
+func TestBackportBasic(t *testing.T) {
+	tests := []struct {
+		name     string
+		dataSize int
+		verify   func([]byte) bool
+	}{
+		{
+			name:     "minimal_valid_quest_data",
+			dataSize: 500, // Minimum size for valid quest data
+			verify: func(data []byte) bool {
+				// Verify data has expected minimum size
+				if len(data) < 100 {
+					return false
+				}
+				return true
+			},
+		},
+		{
+			name:     "large_quest_data",
+			dataSize: 1000,
+			verify: func(data []byte) bool {
+				return len(data) >= 500
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			// Create properly sized quest data
+			// The Backport function expects specific binary format with valid offsets
+			data := make([]byte, tc.dataSize)
+
+			// Set a safe pointer offset (should be within data bounds)
+			offset := uint32(100)
+			binary.LittleEndian.PutUint32(data[0:4], offset)
+
+			// Fill remaining data with pattern
+			for i := 4; i < len(data); i++ {
+				data[i] = byte(i % 256)
+			}
+
+			// Backport may panic with invalid data, so we protect the call
+			defer func() {
+				if r := recover(); r != nil {
+					// Expected with test data - Backport requires valid quest binary format
+					t.Logf("Backport panicked with test data (expected): %v", r)
+				}
+			}()
+
+			result, _ := Backport(data, cfg.ZZ)
+			if result != nil && !tc.verify(result) {
+				t.Errorf("Backport verification failed for result: %d bytes", len(result))
+			}
+		})
+	}
+}
+
+// TestFindSubSliceIndices tests byte slice pattern finding
+func TestFindSubSliceIndices(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     []byte
+		pattern  []byte
+		expected int
+	}{
+		{
+			name:     "single_match",
+			data:     []byte{0x01, 0x02, 0x03, 0x04, 0x05},
+			pattern:  []byte{0x02, 0x03},
+			expected: 1,
+		},
+		{
+			name:     "multiple_matches",
+			data:     []byte{0x01, 0x02, 0x01, 0x02, 0x01, 0x02},
+			pattern:  []byte{0x01, 0x02},
+			expected: 3,
+		},
+		{
+			name:     "no_match",
+			data:     []byte{0x01, 0x02, 0x03},
+			pattern:  []byte{0x04, 0x05},
+			expected: 0,
+		},
+		{
+			name:     "pattern_at_end",
+			data:     []byte{0x01, 0x02, 0x03, 0x04},
+			pattern:  []byte{0x03, 0x04},
+			expected: 1,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			result := findSubSliceIndices(tc.data, tc.pattern)
+			if len(result) != tc.expected {
+				t.Errorf("findSubSliceIndices(%v, %v) = %v, want length %d",
+					tc.data, tc.pattern, result, tc.expected)
+			}
+		})
+	}
+}
+
+// TestEqualByteSlices tests byte slice equality check
+func TestEqualByteSlices(t *testing.T) {
+	tests := []struct {
+		name     string
+		a        []byte
+		b        []byte
+		expected bool
+	}{
+		{
+			name:     "equal_slices",
+			a:        []byte{0x01, 0x02, 0x03},
+			b:        []byte{0x01, 0x02, 0x03},
+			expected: true,
+		},
+		{
+			name:     "different_values",
+			a:        []byte{0x01, 0x02, 0x03},
+			b:        []byte{0x01, 0x02, 0x04},
+			expected: false,
+		},
+		{
+			name:     "different_lengths",
+			a:        []byte{0x01, 0x02},
+			b:        []byte{0x01, 0x02, 0x03},
+			expected: false,
+		},
+		{
+			name:     "empty_slices",
+			a:        []byte{},
+			b:        []byte{},
+			expected: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			result := equal(tc.a, tc.b)
+			if result != tc.expected {
+				t.Errorf("equal(%v, %v) = %v, want %v", tc.a, tc.b, result, tc.expected)
+			}
+		})
+	}
+}
+
+// BenchmarkBackport benchmarks quest backport performance
+func BenchmarkBackport(b *testing.B) {
+	data := make([]byte, 500)
+	binary.LittleEndian.PutUint32(data[0:4], 100)
+
+	for i := 0; i < b.N; i++ {
+		_, _ = Backport(data, cfg.ZZ)
+	}
+}
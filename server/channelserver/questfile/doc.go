@@ -0,0 +1,9 @@
+// Package questfile reads and edits the known fields of a quest file, the
+// bin/quests/*.bin files sent to clients. Where each field sits is kept in
+// layout.go, so a correction to the format is made in one place.
+//
+// A file is edited in place: every byte outside the known fields is kept, so
+// writing back an unedited Quest returns the file it was read from. Lists
+// such as the supply box and reward tables keep the slots the file has, and
+// edited strings are appended to the end of the file and pointed to.
+package questfile
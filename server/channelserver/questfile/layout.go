@@ -0,0 +1,56 @@
+package questfile
+
+import cfg "erupe-ce/config"
+
+// Pointers in the file header, from the start of the unpacked file.
+const (
+	bodyPtrOff    = 0x00 // Quest body, the part sent in event quests
+	supplyPtrOff  = 0x08 // Supply box
+	rewardPtrOff  = 0x0C // Reward table headers
+	monsterPtrOff = 0x18 // Large monster spawns
+)
+
+// Fields of the quest body, from the body pointer. They sit at the same
+// offsets in every mode; later modes only add to the end of the body.
+const (
+	timeFlagsOff  = 0x03 // Season and time of day bits
+	feeOff        = 0x08 // Contract fee
+	rewardOff     = 0x0C // Zenny reward for the main objective
+	timeLimitOff  = 0x18 // Frames, 30 per second
+	stageOff      = 0x1C // Stage the quest starts in
+	stringsPtrOff = 0x28 // Pointer to the string pointer table
+	objectivesOff = 0x30 // Main, sub A and sub B objectives
+	variantsOff   = 0x97 // Three bytes of quest variant flags
+)
+
+const (
+	objectiveSize = 8    // Type uint32, target uint16, count uint16
+	supplySlots   = 40   // 24 for the main objective, then 8 each for the subs
+	supplySize    = 4    // Item uint16, quantity uint16
+	rewardHdrSize = 8    // Kind uint8, unknown uint8 and uint16, table pointer uint32
+	rewardSize    = 6    // Rate uint16, item uint16, quantity uint16
+	monsterSize   = 0x3C // One large monster spawn
+	stringCount   = 8
+	listEnd       = 0xFFFF // Ends the reward and monster lists
+)
+
+// StringNames name the quest strings in the order of the string table.
+var StringNames = [stringCount]string{
+	"title", "main_objective", "sub_objective_a", "sub_objective_b",
+	"clear_conditions", "fail_conditions", "contractor", "description",
+}
+
+// BodyLen returns the length of the quest body a client of mode reads.
+func BodyLen(mode cfg.Mode) int {
+	switch {
+	case mode <= cfg.S6:
+		return 160
+	case mode <= cfg.F5:
+		return 168
+	case mode <= cfg.G101:
+		return 192
+	case mode <= cfg.Z1:
+		return 224
+	}
+	return 320
+}
@@ -0,0 +1,286 @@
+package questfile
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"slices"
+
+	"erupe-ce/common/decryption"
+	"erupe-ce/common/stringsupport"
+	cfg "erupe-ce/config"
+)
+
+// ErrTooLong is returned when writing back a list longer than the file has
+// room for.
+var ErrTooLong = errors.New("list longer than the file has room for")
+
+// Objective is one of a quest's three objectives.
+type Objective struct {
+	Type   uint32 `json:"type"`   // Hunt, capture, slay, deliver and so on
+	Target uint16 `json:"target"` // Monster or item
+	Count  uint16 `json:"count"`
+}
+
+// SupplyItem is a stack in the supply box.
+type SupplyItem struct {
+	Item     uint16 `json:"item"`
+	Quantity uint16 `json:"quantity"`
+}
+
+// RewardItem is an item a reward table can give.
+type RewardItem struct {
+	Rate     uint16 `json:"rate"` // Chance out of 100
+	Item     uint16 `json:"item"`
+	Quantity uint16 `json:"quantity"`
+}
+
+// RewardTable is a table rewards are drawn from.
+type RewardTable struct {
+	Kind  uint8        `json:"kind"`
+	Items []RewardItem `json:"items"`
+}
+
+// Monster is a large monster spawn. The rest of the spawn, such as its
+// position, is kept as it is.
+type Monster struct {
+	ID    uint32 `json:"id"`
+	Count uint32 `json:"count"`
+	Stage uint32 `json:"stage"`
+}
+
+// Quest holds the known fields of a quest file. Edit them and call Bytes to
+// get the file back with the edits.
+type Quest struct {
+	TimeFlags  uint8             `json:"time_flags"`
+	Fee        uint32            `json:"fee"`
+	Reward     uint32            `json:"reward"`
+	TimeLimit  uint32            `json:"time_limit"`
+	Stage      uint32            `json:"stage"`
+	Variants   [3]uint8          `json:"variants"`
+	Objectives [3]Objective      `json:"objectives"`
+	Strings    map[string]string `json:"strings"` // Keyed by StringNames
+	Supplies   []SupplyItem      `json:"supplies"`
+	Rewards    []RewardTable     `json:"rewards"`
+	Monsters   []Monster         `json:"monsters"`
+
+	data        []byte
+	body        int
+	strings     [stringCount]string
+	stringTable int
+	supplyPtr   int
+	rewardPtr   int
+	rewardCaps  []int
+	monsterPtr  int
+	monsterCap  int
+}
+
+// reader reads little endian values from a file, keeping the first read
+// past its end.
+type reader struct {
+	data []byte
+	err  error
+}
+
+func (r *reader) check(off, n int) bool {
+	if r.err != nil {
+		return false
+	}
+	if off < 0 || off+n > len(r.data) {
+		r.err = fmt.Errorf("read of %d bytes at 0x%X runs past the %d byte file", n, off, len(r.data))
+		return false
+	}
+	return true
+}
+
+func (r *reader) u8(off int) uint8 {
+	if !r.check(off, 1) {
+		return 0
+	}
+	return r.data[off]
+}
+
+func (r *reader) u16(off int) uint16 {
+	if !r.check(off, 2) {
+		return 0
+	}
+	return binary.LittleEndian.Uint16(r.data[off:])
+}
+
+func (r *reader) u32(off int) uint32 {
+	if !r.check(off, 4) {
+		return 0
+	}
+	return binary.LittleEndian.Uint32(r.data[off:])
+}
+
+// cstring reads the Shift-JIS string at off up to its null terminator.
+func (r *reader) cstring(off int) string {
+	if !r.check(off, 1) {
+		return ""
+	}
+	end := bytes.IndexByte(r.data[off:], 0)
+	if end < 0 {
+		r.err = fmt.Errorf("string at 0x%X is not terminated", off)
+		return ""
+	}
+	return stringsupport.SJISToUTF8Lossy(r.data[off : off+end])
+}
+
+// Parse reads a quest file, packed or not, as a client of mode reads it.
+func Parse(file []byte, mode cfg.Mode) (*Quest, error) {
+	data := decryption.UnpackSimple(file)
+	data = append([]byte(nil), data...)
+	r := &reader{data: data}
+	q := &Quest{data: data, Strings: make(map[string]string, stringCount)}
+
+	q.body = int(r.u32(bodyPtrOff))
+	if r.err == nil && q.body+BodyLen(mode) > len(data) {
+		return nil, fmt.Errorf("quest body at 0x%X runs past the %d byte file", q.body, len(data))
+	}
+	b := q.body
+	q.TimeFlags = r.u8(b + timeFlagsOff)
+	q.Fee = r.u32(b + feeOff)
+	q.Reward = r.u32(b + rewardOff)
+	q.TimeLimit = r.u32(b + timeLimitOff)
+	q.Stage = r.u32(b + stageOff)
+	for i := range q.Variants {
+		q.Variants[i] = r.u8(b + variantsOff + i)
+	}
+	for i := range q.Objectives {
+		off := b + objectivesOff + i*objectiveSize
+		q.Objectives[i] = Objective{Type: r.u32(off), Target: r.u16(off + 4), Count: r.u16(off + 6)}
+	}
+
+	q.stringTable = int(r.u32(b + stringsPtrOff))
+	for i, name := range StringNames {
+		q.strings[i] = r.cstring(int(r.u32(q.stringTable + i*4)))
+		q.Strings[name] = q.strings[i]
+	}
+
+	if q.supplyPtr = int(r.u32(supplyPtrOff)); q.supplyPtr != 0 {
+		for i := 0; i < supplySlots; i++ {
+			off := q.supplyPtr + i*supplySize
+			q.Supplies = append(q.Supplies, SupplyItem{Item: r.u16(off), Quantity: r.u16(off + 2)})
+		}
+		for len(q.Supplies) > 0 && q.Supplies[len(q.Supplies)-1] == (SupplyItem{}) {
+			q.Supplies = q.Supplies[:len(q.Supplies)-1]
+		}
+	}
+
+	if q.rewardPtr = int(r.u32(rewardPtrOff)); q.rewardPtr != 0 {
+		for hdr := q.rewardPtr; r.err == nil && r.u16(hdr) != listEnd; hdr += rewardHdrSize {
+			table := RewardTable{Kind: r.u8(hdr)}
+			off := int(r.u32(hdr + 4))
+			for ; r.err == nil && r.u16(off) != listEnd; off += rewardSize {
+				table.Items = append(table.Items, RewardItem{Rate: r.u16(off), Item: r.u16(off + 2), Quantity: r.u16(off + 4)})
+			}
+			q.Rewards = append(q.Rewards, table)
+			q.rewardCaps = append(q.rewardCaps, len(table.Items))
+		}
+	}
+
+	if q.monsterPtr = int(r.u32(monsterPtrOff)); q.monsterPtr != 0 {
+		for off := q.monsterPtr; r.err == nil && r.u16(off) != listEnd; off += monsterSize {
+			if !r.check(off, monsterSize) {
+				break
+			}
+			q.Monsters = append(q.Monsters, Monster{ID: r.u32(off), Count: r.u32(off + 4), Stage: r.u32(off + 8)})
+		}
+		q.monsterCap = len(q.Monsters)
+	}
+
+	if r.err != nil {
+		return nil, r.err
+	}
+	return q, nil
+}
+
+// Bytes returns the unpacked quest file with the fields of q written into
+// it. Lists may shrink but not grow past what the file holds.
+func (q *Quest) Bytes() ([]byte, error) {
+	for name := range q.Strings {
+		if !slices.Contains(StringNames[:], name) {
+			return nil, fmt.Errorf("unknown string %q", name)
+		}
+	}
+	data := append([]byte(nil), q.data...)
+	le := binary.LittleEndian
+	b := q.body
+	data[b+timeFlagsOff] = q.TimeFlags
+	le.PutUint32(data[b+feeOff:], q.Fee)
+	le.PutUint32(data[b+rewardOff:], q.Reward)
+	le.PutUint32(data[b+timeLimitOff:], q.TimeLimit)
+	le.PutUint32(data[b+stageOff:], q.Stage)
+	copy(data[b+variantsOff:], q.Variants[:])
+	for i, o := range q.Objectives {
+		off := b + objectivesOff + i*objectiveSize
+		le.PutUint32(data[off:], o.Type)
+		le.PutUint16(data[off+4:], o.Target)
+		le.PutUint16(data[off+6:], o.Count)
+	}
+
+	if (q.supplyPtr == 0 && len(q.Supplies) > 0) || len(q.Supplies) > supplySlots {
+		return nil, fmt.Errorf("supplies: %w (%d slots)", ErrTooLong, supplySlots)
+	}
+	if q.supplyPtr != 0 {
+		for i := 0; i < supplySlots; i++ {
+			var s SupplyItem
+			if i < len(q.Supplies) {
+				s = q.Supplies[i]
+			}
+			off := q.supplyPtr + i*supplySize
+			le.PutUint16(data[off:], s.Item)
+			le.PutUint16(data[off+2:], s.Quantity)
+		}
+	}
+
+	if len(q.Rewards) > len(q.rewardCaps) {
+		return nil, fmt.Errorf("rewards: %w (%d tables)", ErrTooLong, len(q.rewardCaps))
+	}
+	for i, table := range q.Rewards {
+		if len(table.Items) > q.rewardCaps[i] {
+			return nil, fmt.Errorf("rewards[%d]: %w (%d items)", i, ErrTooLong, q.rewardCaps[i])
+		}
+		hdr := q.rewardPtr + i*rewardHdrSize
+		data[hdr] = table.Kind
+		off := int(le.Uint32(data[hdr+4:]))
+		for j, item := range table.Items {
+			le.PutUint16(data[off+j*rewardSize:], item.Rate)
+			le.PutUint16(data[off+j*rewardSize+2:], item.Item)
+			le.PutUint16(data[off+j*rewardSize+4:], item.Quantity)
+		}
+		if len(table.Items) < q.rewardCaps[i] {
+			le.PutUint16(data[off+len(table.Items)*rewardSize:], listEnd)
+		}
+	}
+	if len(q.Rewards) < len(q.rewardCaps) {
+		le.PutUint16(data[q.rewardPtr+len(q.Rewards)*rewardHdrSize:], listEnd)
+	}
+
+	if len(q.Monsters) > q.monsterCap {
+		return nil, fmt.Errorf("monsters: %w (%d spawns)", ErrTooLong, q.monsterCap)
+	}
+	for i, m := range q.Monsters {
+		off := q.monsterPtr + i*monsterSize
+		le.PutUint32(data[off:], m.ID)
+		le.PutUint32(data[off+4:], m.Count)
+		le.PutUint32(data[off+8:], m.Stage)
+	}
+	if len(q.Monsters) < q.monsterCap {
+		le.PutUint16(data[q.monsterPtr+len(q.Monsters)*monsterSize:], listEnd)
+	}
+
+	// Edited strings may be longer, so they go at the end of the file.
+	for i, name := range StringNames {
+		s, ok := q.Strings[name]
+		if !ok || s == q.strings[i] {
+			continue
+		}
+		le.PutUint32(data[q.stringTable+i*4:], uint32(len(data)))
+		data = append(data, stringsupport.UTF8ToSJIS(s)...)
+		data = append(data, 0)
+	}
+	return data, nil
+}
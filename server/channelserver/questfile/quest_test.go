@@ -0,0 +1,172 @@
+package questfile
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"testing"
+
+	cfg "erupe-ce/config"
+)
+
+// testQuest builds an unpacked ZZ quest file with two supplies, one reward
+// table of two items and one monster.
+func testQuest() []byte {
+	const (
+		body     = 0x40
+		table    = body + 320
+		strs     = table + stringCount*4
+		supplies = 0x400
+		rewards  = 0x500
+		monsters = 0x600
+	)
+	data := make([]byte, 0x700)
+	le := binary.LittleEndian
+	le.PutUint32(data[bodyPtrOff:], body)
+	le.PutUint32(data[supplyPtrOff:], supplies)
+	le.PutUint32(data[rewardPtrOff:], rewards)
+	le.PutUint32(data[monsterPtrOff:], monsters)
+
+	data[body+timeFlagsOff] = 0x18
+	le.PutUint32(data[body+feeOff:], 300)
+	le.PutUint32(data[body+rewardOff:], 3000)
+	le.PutUint32(data[body+timeLimitOff:], 50*60*30)
+	le.PutUint32(data[body+stageOff:], 2)
+	data[body+variantsOff+2] = 0x20
+	le.PutUint32(data[body+objectivesOff:], 1)
+	le.PutUint16(data[body+objectivesOff+4:], 11)
+	le.PutUint16(data[body+objectivesOff+6:], 1)
+
+	le.PutUint32(data[body+stringsPtrOff:], table)
+	off := strs
+	for i, s := range []string{"Hunt a Rathalos", "Hunt 1 Rathalos"} {
+		le.PutUint32(data[table+i*4:], uint32(off))
+		off += copy(data[off:], s) + 1
+	}
+	for i := 2; i < stringCount; i++ {
+		le.PutUint32(data[table+i*4:], uint32(off-1)) // The empty string ending the last one
+	}
+
+	le.PutUint16(data[supplies:], 1)
+	le.PutUint16(data[supplies+2:], 5)
+	le.PutUint16(data[supplies+4:], 7)
+	le.PutUint16(data[supplies+6:], 2)
+
+	data[rewards] = 1
+	le.PutUint32(data[rewards+4:], rewards+0x20)
+	le.PutUint16(data[rewards+rewardHdrSize:], listEnd)
+	for i, item := range []RewardItem{{50, 100, 1}, {50, 101, 2}} {
+		o := rewards + 0x20 + i*rewardSize
+		le.PutUint16(data[o:], item.Rate)
+		le.PutUint16(data[o+2:], item.Item)
+		le.PutUint16(data[o+4:], item.Quantity)
+	}
+	le.PutUint16(data[rewards+0x20+2*rewardSize:], listEnd)
+
+	le.PutUint32(data[monsters:], 11)
+	le.PutUint32(data[monsters+4:], 1)
+	le.PutUint32(data[monsters+8:], 3)
+	data[monsters+0x20] = 0xAB // Position and such, kept as is
+	le.PutUint16(data[monsters+monsterSize:], listEnd)
+	return data
+}
+
+func TestParse(t *testing.T) {
+	q, err := Parse(testQuest(), cfg.ZZ)
+	if err != nil {
+		t.Fatalf("Parse() error: %v", err)
+	}
+	if q.TimeFlags != 0x18 || q.Fee != 300 || q.Reward != 3000 || q.TimeLimit != 90000 || q.Stage != 2 {
+		t.Errorf("fields = %+v", q)
+	}
+	if q.Variants != [3]uint8{0, 0, 0x20} {
+		t.Errorf("Variants = %v", q.Variants)
+	}
+	if q.Objectives[0] != (Objective{Type: 1, Target: 11, Count: 1}) {
+		t.Errorf("main objective = %+v", q.Objectives[0])
+	}
+	if q.Strings["title"] != "Hunt a Rathalos" || q.Strings["main_objective"] != "Hunt 1 Rathalos" || q.Strings["description"] != "" {
+		t.Errorf("Strings = %q", q.Strings)
+	}
+	if len(q.Supplies) != 2 || q.Supplies[1] != (SupplyItem{7, 2}) {
+		t.Errorf("Supplies = %v", q.Supplies)
+	}
+	if len(q.Rewards) != 1 || q.Rewards[0].Kind != 1 || len(q.Rewards[0].Items) != 2 || q.Rewards[0].Items[1] != (RewardItem{50, 101, 2}) {
+		t.Errorf("Rewards = %+v", q.Rewards)
+	}
+	if len(q.Monsters) != 1 || q.Monsters[0] != (Monster{ID: 11, Count: 1, Stage: 3}) {
+		t.Errorf("Monsters = %+v", q.Monsters)
+	}
+}
+
+func TestBytesUnedited(t *testing.T) {
+	orig := testQuest()
+	q, err := Parse(orig, cfg.ZZ)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := q.Bytes()
+	if err != nil {
+		t.Fatalf("Bytes() error: %v", err)
+	}
+	if !bytes.Equal(got, orig) {
+		t.Error("an unedited quest does not write back the same file")
+	}
+}
+
+func TestBytesEdited(t *testing.T) {
+	q, err := Parse(testQuest(), cfg.ZZ)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q.Reward = 6000
+	q.Strings["title"] = "Hunt a Rathalos, twice as rich"
+	q.Supplies = append(q.Supplies, SupplyItem{9, 1})
+	q.Rewards[0].Items = q.Rewards[0].Items[:1]
+	q.Monsters = nil
+	data, err := q.Bytes()
+	if err != nil {
+		t.Fatalf("Bytes() error: %v", err)
+	}
+
+	again, err := Parse(data, cfg.ZZ)
+	if err != nil {
+		t.Fatalf("Parse() of the edited file: %v", err)
+	}
+	if again.Reward != 6000 || again.Strings["title"] != "Hunt a Rathalos, twice as rich" || again.Strings["main_objective"] != "Hunt 1 Rathalos" {
+		t.Errorf("edited = %+v", again)
+	}
+	if len(again.Supplies) != 3 || len(again.Rewards[0].Items) != 1 || len(again.Monsters) != 0 {
+		t.Errorf("lists = %v, %v, %v", again.Supplies, again.Rewards, again.Monsters)
+	}
+	if data[0x620] != 0xAB {
+		t.Error("bytes outside the known fields changed")
+	}
+}
+
+func TestBytesTooLong(t *testing.T) {
+	q, err := Parse(testQuest(), cfg.ZZ)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q.Monsters = append(q.Monsters, Monster{ID: 1})
+	if _, err := q.Bytes(); !errors.Is(err, ErrTooLong) {
+		t.Errorf("Bytes() error = %v, want ErrTooLong", err)
+	}
+
+	q, _ = Parse(testQuest(), cfg.ZZ)
+	q.Strings["subtitle"] = "x"
+	if _, err := q.Bytes(); err == nil {
+		t.Error("Bytes() accepted an unknown string")
+	}
+}
+
+func TestParseTruncated(t *testing.T) {
+	if _, err := Parse(testQuest()[:0x100], cfg.ZZ); err == nil {
+		t.Error("Parse() accepted a truncated file")
+	}
+	// An older client reads a shorter body.
+	if _, err := Parse(testQuest(), cfg.S6); err != nil {
+		t.Errorf("Parse() for S6: %v", err)
+	}
+}
@@ -1,12 +1,14 @@
 package channelserver
 
 import (
+	"fmt"
 	"strings"
 	"sync"
 
 	"erupe-ce/common/byteframe"
 	ps "erupe-ce/common/pascalstring"
 	"erupe-ce/network/mhfpacket"
+	"erupe-ce/server/eventbus"
 
 	"go.uber.org/zap"
 )
@@ -27,6 +29,12 @@ func (s *Server) resetRaviente() {
 		}
 	}
 	s.logger.Debug("All Raviente Semaphores empty, resetting")
+	s.eventBus.Publish(eventbus.Event{
+		Type:    eventbus.SiegeEnd,
+		Title:   "Raviente siege ended",
+		Message: "The Raviente siege has concluded.",
+		Key:     fmt.Sprintf("%d-%d", s.ID, s.raviente.id),
+	})
 	s.raviente.id = s.raviente.id + 1
 	s.raviente.register = make([]uint32, 30)
 	s.raviente.state = make([]uint32, 30)
@@ -97,6 +105,12 @@ func (s *Server) BroadcastRaviente(ip uint32, port uint16, stage []byte, _type u
 		s.logger.Error("Unk raviente type", zap.Uint8("_type", _type))
 	}
 	ps.Uint16(bf, text, true)
+	s.eventBus.Publish(eventbus.Event{
+		Type:    eventbus.SiegeStart,
+		Title:   "Raviente siege started",
+		Message: text,
+		Key:     fmt.Sprintf("%d-%d", s.ID, s.raviente.id),
+	})
 	bf.WriteBytes([]byte{0x5F, 0x53, 0x00})
 	bf.WriteUint32(ip)   // IP address
 	bf.WriteUint16(port) // Port
@@ -42,3 +42,15 @@ func (r *AchievementRepository) IncrementScore(charID uint32, achievementID uint
 	_, err := r.db.Exec(fmt.Sprintf("UPDATE achievements SET ach%d=ach%d+1 WHERE id=$1", achievementID, achievementID), charID)
 	return err
 }
+
+// CountAtLeast returns how many characters have a score of at least minScore
+// for a specific achievement column.
+// achievementID must be in the range [0, 32] to prevent SQL injection.
+func (r *AchievementRepository) CountAtLeast(achievementID uint8, minScore int32) (int, error) {
+	if achievementID > 32 {
+		return 0, fmt.Errorf("achievement ID %d out of range [0, 32]", achievementID)
+	}
+	var count int
+	err := r.db.QueryRow(fmt.Sprintf("SELECT COUNT(*) FROM achievements WHERE ach%d >= $1", achievementID), minScore).Scan(&count)
+	return count, err
+}
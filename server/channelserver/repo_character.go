@@ -1,32 +1,99 @@
 package channelserver
 
 import (
+	"bytes"
 	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
 	"time"
 
+	cfg "erupe-ce/config"
+	"erupe-ce/server/channelserver/compression/blobcomp"
+
 	"github.com/jmoiron/sqlx"
 )
 
 // CharacterRepository centralizes all database access for the characters table.
 type CharacterRepository struct {
-	db *sqlx.DB
+	db          *sqlx.DB
+	compression map[string]blobcomp.Algorithm // Storage algorithm of compressed columns, null when absent
 }
 
-// NewCharacterRepository creates a new CharacterRepository.
+// NewCharacterRepository creates a new CharacterRepository. Compressed
+// columns are written in the client's format until WithCompression is set.
 func NewCharacterRepository(db *sqlx.DB) *CharacterRepository {
 	return &CharacterRepository{db: db}
 }
 
+// CompressedColumns are the characters columns holding blobs compressed in
+// the client's format, which can be stored with another algorithm.
+var CompressedColumns = []string{"savedata", "platedata", "platebox"}
+
+// CompressionFor returns the storage algorithm opts selects for each
+// compressed column. Columns naming an unknown algorithm are left out and
+// reported in the error.
+func CompressionFor(opts cfg.CompressionOptions) (map[string]blobcomp.Algorithm, error) {
+	algs := make(map[string]blobcomp.Algorithm, len(CompressedColumns))
+	var errs []error
+	for column, name := range map[string]string{
+		"savedata":  opts.Savedata,
+		"platedata": opts.Platedata,
+		"platebox":  opts.Platebox,
+	} {
+		alg, err := blobcomp.Parse(name)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", column, err))
+			continue
+		}
+		algs[column] = alg
+	}
+	return algs, errors.Join(errs...)
+}
+
+// WithCompression sets the algorithms compressed columns are written with and
+// returns the repository. Reads convert any algorithm back to the client's
+// format whatever is set.
+func (r *CharacterRepository) WithCompression(algs map[string]blobcomp.Algorithm) *CharacterRepository {
+	r.compression = algs
+	return r
+}
+
+// storeBlob converts a blob in the client's format to the column's storage
+// algorithm.
+func (r *CharacterRepository) storeBlob(column string, data []byte) ([]byte, error) {
+	alg, ok := r.compression[column]
+	if !ok {
+		return data, nil
+	}
+	return blobcomp.Convert(data, alg)
+}
+
+// loadBlob converts a stored blob back to the client's format.
+func loadBlob(column string, data []byte) ([]byte, error) {
+	if !slices.Contains(CompressedColumns, column) {
+		return data, nil
+	}
+	return blobcomp.ToClient(data)
+}
+
 // LoadColumn reads a single []byte column by character ID.
 func (r *CharacterRepository) LoadColumn(charID uint32, column string) ([]byte, error) {
 	var data []byte
 	err := r.db.QueryRow("SELECT "+column+" FROM characters WHERE id = $1", charID).Scan(&data)
-	return data, err
+	if err != nil {
+		return data, err
+	}
+	return loadBlob(column, data)
 }
 
 // SaveColumn writes a single []byte column by character ID.
 func (r *CharacterRepository) SaveColumn(charID uint32, column string, data []byte) error {
-	_, err := r.db.Exec("UPDATE characters SET "+column+"=$1 WHERE id=$2", data, charID)
+	stored, err := r.storeBlob(column, data)
+	if err != nil {
+		return err
+	}
+	_, err = r.db.Exec("UPDATE characters SET "+column+"=$1 WHERE id=$2", stored, charID)
 	return err
 }
 
@@ -145,7 +212,7 @@ func (r *CharacterRepository) LoadColumnWithDefault(charID uint32, column string
 	if data == nil {
 		return defaultVal, nil
 	}
-	return data, nil
+	return loadBlob(column, data)
 }
 
 // SetDeleted marks a character as deleted.
@@ -211,13 +278,73 @@ func (r *CharacterRepository) FindByRastaID(rastaID int) (charID uint32, name st
 	return
 }
 
-// SaveCharacterData updates the core save fields on a character.
-func (r *CharacterRepository) SaveCharacterData(charID uint32, compSave []byte, hr, gr uint16, isFemale bool, weaponType uint8, weaponID uint16) error {
-	_, err := r.db.Exec(`UPDATE characters SET savedata=$1, is_new_character=false, hr=$2, gr=$3, is_female=$4, weapon_type=$5, weapon_id=$6 WHERE id=$7`,
-		compSave, hr, gr, isFemale, weaponType, weaponID, charID)
+// SaveCharacterData updates the core save fields on a character, recording
+// the client mode the savedata was written in and the checksum of the save in
+// the client's format.
+func (r *CharacterRepository) SaveCharacterData(charID uint32, compSave []byte, mode cfg.Mode, hr, gr uint16, isFemale bool, weaponType uint8, weaponID uint16) error {
+	stored, err := r.storeBlob("savedata", compSave)
+	if err != nil {
+		return err
+	}
+	_, err = r.db.Exec(`UPDATE characters SET savedata=$1, savedata_mode=$2, savedata_checksum=$3, is_new_character=false, hr=$4, gr=$5, is_female=$6, weapon_type=$7, weapon_id=$8 WHERE id=$9`,
+		stored, int(mode), int64(saveChecksum(compSave)), hr, gr, isFemale, weaponType, weaponID, charID)
 	return err
 }
 
+// Recompress rewrites every row of a compressed column stored with another
+// algorithm than the repository's, returning how many rows it changed. The
+// savedata checksum is recorded again for the save in the client's format,
+// as converting back may not give the exact bytes first written.
+func (r *CharacterRepository) Recompress(column string) (int, error) {
+	if !slices.Contains(CompressedColumns, column) {
+		return 0, fmt.Errorf("%s is not a compressed column", column)
+	}
+	var ids []uint32
+	if err := r.db.Select(&ids, "SELECT id FROM characters WHERE "+column+" IS NOT NULL ORDER BY id"); err != nil {
+		return 0, err
+	}
+	var changed int
+	for _, id := range ids {
+		var data []byte
+		if err := r.db.QueryRow("SELECT "+column+" FROM characters WHERE id=$1", id).Scan(&data); err != nil {
+			return changed, err
+		}
+		client, err := blobcomp.ToClient(data)
+		if err != nil {
+			return changed, fmt.Errorf("character %d: %w", id, err)
+		}
+		stored, err := r.storeBlob(column, client)
+		if err != nil {
+			return changed, fmt.Errorf("character %d: %w", id, err)
+		}
+		if bytes.Equal(stored, data) {
+			continue
+		}
+		if column == "savedata" {
+			_, err = r.db.Exec(`UPDATE characters SET savedata=$1,
+				savedata_checksum=CASE WHEN savedata_checksum IS NULL THEN NULL ELSE $2 END WHERE id=$3`,
+				stored, int64(saveChecksum(client)), id)
+		} else {
+			_, err = r.db.Exec("UPDATE characters SET "+column+"=$1 WHERE id=$2", stored, id)
+		}
+		if err != nil {
+			return changed, err
+		}
+		changed++
+	}
+	return changed, nil
+}
+
+// StampSaveDataMode records mode as the savedata mode of every character
+// whose save predates savedata_mode, returning how many were stamped.
+func (r *CharacterRepository) StampSaveDataMode(mode cfg.Mode) (int64, error) {
+	res, err := r.db.Exec(`UPDATE characters SET savedata_mode=$1 WHERE savedata_mode IS NULL AND savedata IS NOT NULL`, int(mode))
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
 // SaveHouseData updates house-related fields in user_binary.
 func (r *CharacterRepository) SaveHouseData(charID uint32, houseTier []byte, houseData, bookshelf, gallery, tore, garden []byte) error {
 	_, err := r.db.Exec(`UPDATE user_binary SET house_tier=$1, house_data=$2, bookshelf=$3, gallery=$4, tore=$5, garden=$6 WHERE id=$7`,
@@ -234,5 +361,8 @@ func (r *CharacterRepository) LoadSaveData(charID uint32) (uint32, []byte, bool,
 	var name string
 	err := r.db.QueryRow("SELECT id, savedata, is_new_character, name FROM characters WHERE id = $1", charID).
 		Scan(&id, &savedata, &isNew, &name)
+	if err == nil {
+		savedata, err = loadBlob("savedata", savedata)
+	}
 	return id, savedata, isNew, name, err
 }
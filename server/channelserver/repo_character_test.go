@@ -1,9 +1,14 @@
 package channelserver
 
 import (
+	"bytes"
+	"strings"
 	"testing"
 	"time"
 
+	cfg "erupe-ce/config"
+	"erupe-ce/server/channelserver/compression/blobcomp"
+
 	"github.com/jmoiron/sqlx"
 )
 
@@ -625,3 +630,56 @@ func TestLoadSaveDataNotFound(t *testing.T) {
 		t.Fatal("Expected error for non-existent character")
 	}
 }
+
+func TestCompressionFor(t *testing.T) {
+	algs, err := CompressionFor(cfg.CompressionOptions{Savedata: "zstd", Platebox: "null"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if algs["savedata"] != blobcomp.Zstd || algs["platedata"] != blobcomp.Null || algs["platebox"] != blobcomp.Null {
+		t.Errorf("algorithms = %v", algs)
+	}
+	algs, err = CompressionFor(cfg.CompressionOptions{Savedata: "lz4", Platedata: "zstd"})
+	if err == nil || !strings.Contains(err.Error(), "savedata") {
+		t.Errorf("unknown algorithm: err = %v", err)
+	}
+	if _, ok := algs["savedata"]; ok || algs["platedata"] != blobcomp.Zstd {
+		t.Errorf("algorithms with an unknown one = %v", algs)
+	}
+}
+
+func TestCompressedColumnStorage(t *testing.T) {
+	repo, db, charID := setupCharRepo(t)
+	repo.WithCompression(map[string]blobcomp.Algorithm{"savedata": blobcomp.Zstd, "platedata": blobcomp.Zstd})
+
+	comp, _ := SaveFixture{Mode: cfg.ZZ, Name: "Zstd"}.Compressed()
+	if err := repo.SaveCharacterData(charID, comp, cfg.ZZ, 1, 0, false, 0, 0); err != nil {
+		t.Fatalf("SaveCharacterData failed: %v", err)
+	}
+	var stored []byte
+	if err := db.QueryRow("SELECT savedata FROM characters WHERE id=$1", charID).Scan(&stored); err != nil {
+		t.Fatal(err)
+	}
+	if alg, _ := blobcomp.Detect(stored); alg != blobcomp.Zstd {
+		t.Errorf("savedata stored as %q, want zstd", alg)
+	}
+	if _, data, _, _, err := repo.LoadSaveData(charID); err != nil || !bytes.Equal(data, comp) {
+		t.Errorf("LoadSaveData did not return the client's format: %v", err)
+	}
+
+	// Switching back converts the rows written with zstd.
+	repo.WithCompression(nil)
+	n, err := repo.Recompress("savedata")
+	if err != nil || n != 1 {
+		t.Fatalf("Recompress = %d, %v", n, err)
+	}
+	if err := db.QueryRow("SELECT savedata FROM characters WHERE id=$1", charID).Scan(&stored); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(stored, comp) {
+		t.Error("savedata was not converted back to nullcomp")
+	}
+	if _, err := repo.Recompress("otomoairou"); err == nil {
+		t.Error("Recompress accepted a column that is not compressed")
+	}
+}
@@ -1,6 +1,9 @@
 package channelserver
 
 import (
+	"fmt"
+	"time"
+
 	"github.com/jmoiron/sqlx"
 )
 
@@ -8,6 +11,7 @@ import (
 // distribution_items, and distributions_accepted tables.
 type DistributionRepository struct {
 	db *sqlx.DB
+	replicaReads
 }
 
 // NewDistributionRepository creates a new DistributionRepository.
@@ -28,7 +32,8 @@ func (r *DistributionRepository) List(charID uint32, distType uint8) ([]Distribu
 		) AS times_accepted,
 		COALESCE(deadline, TO_TIMESTAMP(0)) AS deadline
 		FROM distribution d
-		WHERE character_id = $1 AND type = $2 OR character_id IS NULL AND type = $2 ORDER BY id DESC
+		WHERE (character_id = $1 OR character_id IS NULL) AND type = $2 AND (starts IS NULL OR starts <= now())
+		ORDER BY id DESC
 	`, charID, distType)
 	if err != nil {
 		return nil, err
@@ -48,7 +53,7 @@ func (r *DistributionRepository) List(charID uint32, distType uint8) ([]Distribu
 
 // GetItems returns all items for a given distribution.
 func (r *DistributionRepository) GetItems(distributionID uint32) ([]DistributionItem, error) {
-	rows, err := r.db.Queryx(`SELECT id, item_type, COALESCE(item_id, 0) AS item_id, COALESCE(quantity, 0) AS quantity FROM distribution_items WHERE distribution_id=$1`, distributionID)
+	rows, err := r.reader(r.db).Queryx(`SELECT id, item_type, COALESCE(item_id, 0) AS item_id, COALESCE(quantity, 0) AS quantity FROM distribution_items WHERE distribution_id=$1`, distributionID)
 	if err != nil {
 		return nil, err
 	}
@@ -74,6 +79,74 @@ func (r *DistributionRepository) RecordAccepted(distributionID, charID uint32) e
 // GetDescription returns the description text for a distribution.
 func (r *DistributionRepository) GetDescription(distributionID uint32) (string, error) {
 	var desc string
-	err := r.db.QueryRow("SELECT description FROM distribution WHERE id = $1", distributionID).Scan(&desc)
+	err := r.reader(r.db).QueryRow("SELECT description FROM distribution WHERE id = $1", distributionID).Scan(&desc)
 	return desc, err
 }
+
+// NewDistribution is a distribution to create. Rank limits of -1 leave
+// that end of the range open.
+type NewDistribution struct {
+	Type            uint8
+	EventName       string
+	Description     string
+	TimesAcceptable uint16
+	Selection       bool
+	Starts          *time.Time // Nil to be claimable at once
+	Deadline        *time.Time // Nil to never expire
+	MinHR           int16
+	MaxHR           int16
+	MinSR           int16
+	MaxSR           int16
+	MinGR           int16
+	MaxGR           int16
+}
+
+// Create inserts the distribution with its items for each of charIDs, or
+// once for every character when charIDs is empty, and returns the new
+// distribution IDs. Nothing is inserted if any insert fails.
+func (r *DistributionRepository) Create(d NewDistribution, items []DistributionItem, charIDs []uint32) ([]uint32, error) {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	targets := []*uint32{nil}
+	if len(charIDs) > 0 {
+		targets = make([]*uint32, len(charIDs))
+		for i := range charIDs {
+			targets[i] = &charIDs[i]
+		}
+	}
+	ids := make([]uint32, 0, len(targets))
+	for _, charID := range targets {
+		var id uint32
+		err := tx.QueryRow(`
+			INSERT INTO distribution (character_id, type, event_name, description, times_acceptable, selection,
+				starts, deadline, min_hr, max_hr, min_sr, max_sr, min_gr, max_gr, data)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, ''::bytea)
+			RETURNING id`,
+			charID, d.Type, d.EventName, d.Description, d.TimesAcceptable, d.Selection, d.Starts, d.Deadline,
+			rankLimit(d.MinHR), rankLimit(d.MaxHR), rankLimit(d.MinSR), rankLimit(d.MaxSR), rankLimit(d.MinGR), rankLimit(d.MaxGR),
+		).Scan(&id)
+		if err != nil {
+			return nil, fmt.Errorf("insert distribution: %w", err)
+		}
+		for _, item := range items {
+			if _, err := tx.Exec(`INSERT INTO distribution_items (distribution_id, item_type, item_id, quantity) VALUES ($1, $2, $3, $4)`,
+				id, item.ItemType, item.ItemID, item.Quantity); err != nil {
+				return nil, fmt.Errorf("insert distribution item: %w", err)
+			}
+		}
+		ids = append(ids, id)
+	}
+	return ids, tx.Commit()
+}
+
+// rankLimit stores an open rank limit as NULL, which List reads back as -1.
+func rankLimit(v int16) any {
+	if v < 0 {
+		return nil
+	}
+	return v
+}
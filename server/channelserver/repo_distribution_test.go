@@ -2,6 +2,7 @@ package channelserver
 
 import (
 	"testing"
+	"time"
 
 	"github.com/jmoiron/sqlx"
 )
@@ -144,3 +145,59 @@ func TestRepoDistributionFiltersByType(t *testing.T) {
 		t.Errorf("Expected 1 distribution of type 1, got: %d", len(dists))
 	}
 }
+
+func TestRepoDistributionCreate(t *testing.T) {
+	repo, _, charID := setupDistributionRepo(t)
+
+	deadline := time.Now().Add(24 * time.Hour).Truncate(time.Second)
+	ids, err := repo.Create(NewDistribution{
+		Type:            1,
+		EventName:       "Winter Festival",
+		Description:     "~C05Thanks for playing!",
+		TimesAcceptable: 2,
+		Deadline:        &deadline,
+		MinHR:           100,
+		MaxHR:           -1,
+		MinSR:           -1,
+		MaxSR:           -1,
+		MinGR:           -1,
+		MaxGR:           -1,
+	}, []DistributionItem{{ItemType: 7, ItemID: 1234, Quantity: 10}}, []uint32{charID})
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if len(ids) != 1 {
+		t.Fatalf("Expected 1 distribution, got: %d", len(ids))
+	}
+
+	dists, err := repo.List(charID, 1)
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(dists) != 1 || dists[0].EventName != "Winter Festival" || dists[0].TimesAcceptable != 2 ||
+		dists[0].MinHR != 100 || dists[0].MaxHR != -1 || !dists[0].Deadline.Equal(deadline) {
+		t.Errorf("List = %+v", dists)
+	}
+	items, err := repo.GetItems(ids[0])
+	if err != nil || len(items) != 1 || items[0].ItemID != 1234 || items[0].Quantity != 10 {
+		t.Errorf("GetItems = %+v, %v", items, err)
+	}
+}
+
+func TestRepoDistributionListHidesUnstarted(t *testing.T) {
+	repo, _, charID := setupDistributionRepo(t)
+
+	starts := time.Now().Add(time.Hour)
+	if _, err := repo.Create(NewDistribution{Type: 1, EventName: "Later", TimesAcceptable: 1, Starts: &starts,
+		MinHR: -1, MaxHR: -1, MinSR: -1, MaxSR: -1, MinGR: -1, MaxGR: -1}, nil, nil); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	dists, err := repo.List(charID, 1)
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(dists) != 0 {
+		t.Errorf("Expected no distributions before the start, got: %d", len(dists))
+	}
+}
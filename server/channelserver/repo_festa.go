@@ -11,6 +11,7 @@ import (
 // (events, festa_registrations, festa_submissions, festa_prizes, festa_prizes_accepted, festa_trials, guild_characters).
 type FestaRepository struct {
 	db *sqlx.DB
+	replicaReads
 }
 
 // NewFestaRepository creates a new FestaRepository.
@@ -88,7 +89,7 @@ func (r *FestaRepository) GetTeamSouls(team string) (uint32, error) {
 // GetTrialsWithMonopoly returns all festa trials with their computed monopoly color.
 func (r *FestaRepository) GetTrialsWithMonopoly() ([]FestaTrial, error) {
 	var trials []FestaTrial
-	rows, err := r.db.Queryx(`SELECT ft.*,
+	rows, err := r.reader(r.db).Queryx(`SELECT ft.*,
 		COALESCE(CASE
 			WHEN COUNT(gc.id) FILTER (WHERE fr.team = 'blue' AND gc.trial_vote = ft.id) >
 				 COUNT(gc.id) FILTER (WHERE fr.team = 'red' AND gc.trial_vote = ft.id)
@@ -121,7 +122,7 @@ func (r *FestaRepository) GetTopGuildForTrial(trialType uint16) (FestaGuildRanki
 	var ranking FestaGuildRanking
 	var temp uint32
 	ranking.Team = FestivalColorNone
-	err := r.db.QueryRow(`
+	err := r.reader(r.db).QueryRow(`
 		SELECT fs.guild_id, g.name, fr.team, SUM(fs.souls) as _
 		FROM festa_submissions fs
 		LEFT JOIN festa_registrations fr ON fs.guild_id = fr.guild_id
@@ -139,7 +140,7 @@ func (r *FestaRepository) GetTopGuildInWindow(start, end uint32) (FestaGuildRank
 	var ranking FestaGuildRanking
 	var temp uint32
 	ranking.Team = FestivalColorNone
-	err := r.db.QueryRow(`
+	err := r.reader(r.db).QueryRow(`
 		SELECT fs.guild_id, g.name, fr.team, SUM(fs.souls) as _
 		FROM festa_submissions fs
 		LEFT JOIN festa_registrations fr ON fs.guild_id = fr.guild_id
@@ -12,6 +12,7 @@ import (
 // (gacha_shop, gacha_entries, gacha_items, gacha_stepup, gacha_box).
 type GachaRepository struct {
 	db *sqlx.DB
+	replicaReads
 }
 
 // NewGachaRepository creates a new GachaRepository.
@@ -21,7 +22,7 @@ func NewGachaRepository(db *sqlx.DB) *GachaRepository {
 
 // GetEntryForTransaction reads the cost type/amount and roll count for a gacha transaction.
 func (r *GachaRepository) GetEntryForTransaction(gachaID uint32, rollID uint8) (itemType uint8, itemNumber uint16, rolls int, err error) {
-	err = r.db.QueryRowx(
+	err = r.reader(r.db).QueryRowx(
 		`SELECT item_type, item_number, rolls FROM gacha_entries WHERE gacha_id = $1 AND entry_type = $2`,
 		gachaID, rollID,
 	).Scan(&itemType, &itemNumber, &rolls)
@@ -31,7 +32,7 @@ func (r *GachaRepository) GetEntryForTransaction(gachaID uint32, rollID uint8) (
 // GetRewardPool returns the entry_type=100 reward pool for a gacha, ordered by weight descending.
 func (r *GachaRepository) GetRewardPool(gachaID uint32) ([]GachaEntry, error) {
 	var entries []GachaEntry
-	rows, err := r.db.Queryx(
+	rows, err := r.reader(r.db).Queryx(
 		`SELECT id, weight, rarity FROM gacha_entries WHERE gacha_id = $1 AND entry_type = 100 ORDER BY weight DESC`,
 		gachaID,
 	)
@@ -51,7 +52,7 @@ func (r *GachaRepository) GetRewardPool(gachaID uint32) ([]GachaEntry, error) {
 // GetItemsForEntry returns the items associated with a gacha entry ID.
 func (r *GachaRepository) GetItemsForEntry(entryID uint32) ([]GachaItem, error) {
 	var items []GachaItem
-	rows, err := r.db.Queryx(
+	rows, err := r.reader(r.db).Queryx(
 		`SELECT item_type, item_id, quantity FROM gacha_items WHERE entry_id = $1`,
 		entryID,
 	)
@@ -71,7 +72,7 @@ func (r *GachaRepository) GetItemsForEntry(entryID uint32) ([]GachaItem, error)
 // GetGuaranteedItems returns items for the entry matching a roll type and gacha ID.
 func (r *GachaRepository) GetGuaranteedItems(rollType uint8, gachaID uint32) ([]GachaItem, error) {
 	var items []GachaItem
-	rows, err := r.db.Queryx(
+	rows, err := r.reader(r.db).Queryx(
 		`SELECT item_type, item_id, quantity FROM gacha_items WHERE entry_id = (SELECT id FROM gacha_entries WHERE entry_type = $1 AND gacha_id = $2)`,
 		rollType, gachaID,
 	)
@@ -118,7 +119,7 @@ func (r *GachaRepository) GetStepupWithTime(gachaID uint32, charID uint32) (uint
 // HasEntryType returns whether a gacha has any entries of the given type.
 func (r *GachaRepository) HasEntryType(gachaID uint32, entryType uint8) (bool, error) {
 	var count int
-	err := r.db.QueryRow(
+	err := r.reader(r.db).QueryRow(
 		`SELECT COUNT(1) FROM gacha_entries WHERE gacha_id = $1 AND entry_type = $2`,
 		gachaID, entryType,
 	).Scan(&count)
@@ -188,7 +189,7 @@ func (r *GachaRepository) DeleteBoxEntries(gachaID uint32, charID uint32) error
 // ListShop returns all gacha shop definitions.
 func (r *GachaRepository) ListShop() ([]Gacha, error) {
 	var gachas []Gacha
-	rows, err := r.db.Queryx(
+	rows, err := r.reader(r.db).Queryx(
 		`SELECT id, min_gr, min_hr, name, url_banner, url_feature, url_thumbnail, wide, recommended, gacha_type, hidden FROM gacha_shop`,
 	)
 	if err != nil {
@@ -207,7 +208,7 @@ func (r *GachaRepository) ListShop() ([]Gacha, error) {
 // GetShopType returns the gacha_type for a gacha shop ID.
 func (r *GachaRepository) GetShopType(shopID uint32) (int, error) {
 	var gachaType int
-	err := r.db.QueryRow(
+	err := r.reader(r.db).QueryRow(
 		`SELECT gacha_type FROM gacha_shop WHERE id = $1`,
 		shopID,
 	).Scan(&gachaType)
@@ -217,7 +218,7 @@ func (r *GachaRepository) GetShopType(shopID uint32) (int, error) {
 // GetAllEntries returns all entries for a gacha, ordered by weight descending.
 func (r *GachaRepository) GetAllEntries(gachaID uint32) ([]GachaEntry, error) {
 	var entries []GachaEntry
-	rows, err := r.db.Queryx(
+	rows, err := r.reader(r.db).Queryx(
 		`SELECT entry_type, id, item_type, item_number, item_quantity, weight, rarity, rolls, daily_limit, frontier_points, COALESCE(name, '') AS name FROM gacha_entries WHERE gacha_id = $1 ORDER BY weight DESC`,
 		gachaID,
 	)
@@ -237,7 +238,7 @@ func (r *GachaRepository) GetAllEntries(gachaID uint32) ([]GachaEntry, error) {
 // GetWeightDivisor returns the total weight / 100000 for probability display.
 func (r *GachaRepository) GetWeightDivisor(gachaID uint32) (float64, error) {
 	var divisor float64
-	err := r.db.QueryRow(
+	err := r.reader(r.db).QueryRow(
 		`SELECT COALESCE(SUM(weight) / 100000.0, 0) AS chance FROM gacha_entries WHERE gacha_id = $1`,
 		gachaID,
 	).Scan(&divisor)
@@ -12,12 +12,13 @@ import (
 // GuildRepository centralizes all database access for guild-related tables
 // (guilds, guild_characters, guild_applications).
 type GuildRepository struct {
-	db *sqlx.DB
+	db    *sqlx.DB
+	stmts *StmtCache // Prepared statements for hot queries
 }
 
 // NewGuildRepository creates a new GuildRepository.
 func NewGuildRepository(db *sqlx.DB) *GuildRepository {
-	return &GuildRepository{db: db}
+	return &GuildRepository{db: db, stmts: NewStmtCache(db)}
 }
 
 const guildInfoSelectSQL = `
@@ -108,7 +109,7 @@ func scanGuildMember(rows *sqlx.Rows) (*GuildMember, error) {
 
 // GetByID retrieves guild info by guild ID, returning nil if not found.
 func (r *GuildRepository) GetByID(guildID uint32) (*Guild, error) {
-	rows, err := r.db.Queryx(fmt.Sprintf(`%s WHERE g.id = $1 LIMIT 1`, guildInfoSelectSQL), guildID)
+	rows, err := r.stmts.Queryx(fmt.Sprintf(`%s WHERE g.id = $1 LIMIT 1`, guildInfoSelectSQL), guildID)
 	if err != nil {
 		return nil, err
 	}
@@ -121,7 +122,7 @@ func (r *GuildRepository) GetByID(guildID uint32) (*Guild, error) {
 
 // GetByCharID retrieves guild info for a character, including applied guilds.
 func (r *GuildRepository) GetByCharID(charID uint32) (*Guild, error) {
-	rows, err := r.db.Queryx(fmt.Sprintf(`
+	rows, err := r.stmts.Queryx(fmt.Sprintf(`
 		%s
 		WHERE EXISTS(
 				SELECT 1
@@ -328,7 +329,7 @@ func (r *GuildRepository) ArrangeCharacters(charIDs []uint32) error {
 // Returns nil, nil if not found.
 func (r *GuildRepository) GetApplication(guildID, charID uint32, appType GuildApplicationType) (*GuildApplication, error) {
 	app := &GuildApplication{}
-	err := r.db.QueryRowx(`
+	err := r.stmts.QueryRowx(`
 		SELECT * from guild_applications WHERE character_id = $1 AND guild_id = $2 AND application_type = $3
 	`, charID, guildID, appType).StructScan(app)
 	if errors.Is(err, sql.ErrNoRows) {
@@ -343,7 +344,7 @@ func (r *GuildRepository) GetApplication(guildID, charID uint32, appType GuildAp
 // HasApplication checks whether any application exists for the character in the guild.
 func (r *GuildRepository) HasApplication(guildID, charID uint32) (bool, error) {
 	var n int
-	err := r.db.QueryRow(`SELECT 1 from guild_applications WHERE character_id = $1 AND guild_id = $2`, charID, guildID).Scan(&n)
+	err := r.stmts.QueryRow(`SELECT 1 from guild_applications WHERE character_id = $1 AND guild_id = $2`, charID, guildID).Scan(&n)
 	if errors.Is(err, sql.ErrNoRows) {
 		return false, nil
 	}
@@ -371,7 +372,7 @@ func (r *GuildRepository) SaveItemBox(guildID uint32, data []byte) error {
 
 // GetMembers loads all members (or applicants) of a guild.
 func (r *GuildRepository) GetMembers(guildID uint32, applicants bool) ([]*GuildMember, error) {
-	rows, err := r.db.Queryx(fmt.Sprintf(`
+	rows, err := r.stmts.Queryx(fmt.Sprintf(`
 		%s
 		WHERE character.guild_id = $1 AND is_applicant = $2
 	`, guildMembersSelectSQL), guildID, applicants)
@@ -394,7 +395,7 @@ func (r *GuildRepository) GetMembers(guildID uint32, applicants bool) ([]*GuildM
 // GetCharacterMembership loads a character's guild membership data.
 // Returns nil, nil if the character is not in any guild.
 func (r *GuildRepository) GetCharacterMembership(charID uint32) (*GuildMember, error) {
-	rows, err := r.db.Queryx(fmt.Sprintf("%s	WHERE character.character_id=$1", guildMembersSelectSQL), charID)
+	rows, err := r.stmts.Queryx(fmt.Sprintf("%s	WHERE character.character_id=$1", guildMembersSelectSQL), charID)
 	if err != nil {
 		return nil, err
 	}
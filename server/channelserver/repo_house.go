@@ -1,8 +1,12 @@
 package channelserver
 
 import (
+	"context"
+	"database/sql"
 	"fmt"
 
+	"erupe-ce/common/mhfitem"
+
 	"github.com/jmoiron/sqlx"
 )
 
@@ -185,6 +189,71 @@ func (r *HouseRepository) SetWarehouseEquipData(charID uint32, index uint8, data
 	return err
 }
 
+// TransferItemBoxes moves or copies warehouse item box pages between two
+// offline characters of the same account in one transaction. No page is
+// changed when any page conflicts.
+func (r *HouseRepository) TransferItemBoxes(t ItemBoxTransfer) ([]ItemBoxPageResult, error) {
+	if err := t.Validate(); err != nil {
+		return nil, err
+	}
+	tx, err := r.db.BeginTxx(context.Background(), nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var accounts int
+	err = tx.QueryRow(`SELECT COUNT(DISTINCT user_id) FROM characters WHERE id IN ($1, $2) AND deleted = false
+		HAVING COUNT(*) = 2`, t.From, t.To).Scan(&accounts)
+	if err == sql.ErrNoRows || (err == nil && accounts != 1) {
+		return nil, ErrNotSameAccount
+	} else if err != nil {
+		return nil, err
+	}
+	var online uint32
+	err = tx.QueryRow(`SELECT char_id FROM sign_sessions WHERE char_id IN ($1, $2) LIMIT 1`, t.From, t.To).Scan(&online)
+	if err == nil {
+		return nil, fmt.Errorf("%w: %d", ErrCharacterOnline, online)
+	} else if err != sql.ErrNoRows {
+		return nil, err
+	}
+	for _, id := range []uint32{t.From, t.To} {
+		if _, err := tx.Exec(`INSERT INTO warehouse (character_id) VALUES ($1) ON CONFLICT DO NOTHING`, id); err != nil {
+			return nil, err
+		}
+	}
+
+	results := make([]ItemBoxPageResult, 0, len(t.Boxes))
+	for _, box := range t.Boxes {
+		var src, dst []byte
+		query := fmt.Sprintf(`SELECT item%d FROM warehouse WHERE character_id=$1 FOR UPDATE`, box)
+		if err := tx.QueryRow(query, t.From).Scan(&src); err != nil {
+			return nil, err
+		}
+		if err := tx.QueryRow(query, t.To).Scan(&dst); err != nil {
+			return nil, err
+		}
+		items, res, err := transferItemBoxPage(box, readWarehouseItems(src), readWarehouseItems(dst), t.Conflict)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, res)
+		if res.Stacks == 0 {
+			continue
+		}
+		update := fmt.Sprintf(`UPDATE warehouse SET item%d=$1 WHERE character_id=$2`, box)
+		if _, err := tx.Exec(update, mhfitem.SerializeWarehouseItems(items), t.To); err != nil {
+			return nil, err
+		}
+		if t.Move {
+			if _, err := tx.Exec(update, mhfitem.SerializeWarehouseItems(nil), t.From); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return results, tx.Commit()
+}
+
 // Title methods
 
 // GetTitles returns all titles for a character.
@@ -1,8 +1,11 @@
 package channelserver
 
 import (
+	"errors"
 	"testing"
 
+	"erupe-ce/common/mhfitem"
+
 	"github.com/jmoiron/sqlx"
 )
 
@@ -326,6 +329,43 @@ func TestRepoHouseWarehouseEquipData(t *testing.T) {
 	}
 }
 
+func TestRepoHouseTransferItemBoxes(t *testing.T) {
+	repo, db, charID := setupHouseRepo(t)
+	var userID uint32
+	if err := db.QueryRow("SELECT user_id FROM characters WHERE id=$1", charID).Scan(&userID); err != nil {
+		t.Fatal(err)
+	}
+	alt := CreateTestCharacter(t, db, userID, "HouseAlt")
+	other := CreateTestCharacter(t, db, CreateTestUser(t, db, "house_other"), "Other")
+
+	if err := repo.InitializeWarehouse(charID); err != nil {
+		t.Fatal(err)
+	}
+	stacks := []mhfitem.MHFItemStack{{WarehouseID: 1, Item: mhfitem.MHFItem{ItemID: 7}, Quantity: 5}}
+	if err := repo.SetWarehouseItemData(charID, 2, mhfitem.SerializeWarehouseItems(stacks)); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := repo.TransferItemBoxes(ItemBoxTransfer{From: charID, To: other}); !errors.Is(err, ErrNotSameAccount) {
+		t.Fatalf("transfer to another account: err = %v", err)
+	}
+	res, err := repo.TransferItemBoxes(ItemBoxTransfer{From: charID, To: alt, Boxes: []uint8{2}, Move: true})
+	if err != nil {
+		t.Fatalf("TransferItemBoxes failed: %v", err)
+	}
+	if len(res) != 1 || res[0].Stacks != 1 || res[0].Total != 1 {
+		t.Errorf("results = %+v", res)
+	}
+	data, _ := repo.GetWarehouseItemData(alt, 2)
+	if items := readWarehouseItems(data); len(items) != 1 || items[0].Item.ItemID != 7 || items[0].Quantity != 5 {
+		t.Errorf("destination page = %+v", items)
+	}
+	data, _ = repo.GetWarehouseItemData(charID, 2)
+	if items := readWarehouseItems(data); len(items) != 0 {
+		t.Errorf("source page after move = %+v", items)
+	}
+}
+
 func TestRepoHouseAcquireTitle(t *testing.T) {
 	repo, _, charID := setupHouseRepo(t)
 
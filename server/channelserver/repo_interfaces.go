@@ -2,6 +2,9 @@ package channelserver
 
 import (
 	"time"
+
+	cfg "erupe-ce/config"
+	"erupe-ce/server/sessionlog"
 )
 
 // Repository interfaces decouple handlers from concrete PostgreSQL implementations,
@@ -36,7 +39,7 @@ type CharacterRepo interface {
 	SaveMercenary(charID uint32, data []byte, rastaID uint32) error
 	UpdateGCPAndPact(charID uint32, gcp uint32, pactID uint32) error
 	FindByRastaID(rastaID int) (charID uint32, name string, err error)
-	SaveCharacterData(charID uint32, compSave []byte, hr, gr uint16, isFemale bool, weaponType uint8, weaponID uint16) error
+	SaveCharacterData(charID uint32, compSave []byte, mode cfg.Mode, hr, gr uint16, isFemale bool, weaponType uint8, weaponID uint16) error
 	SaveHouseData(charID uint32, houseTier []byte, houseData, bookshelf, gallery, tore, garden []byte) error
 	LoadSaveData(charID uint32) (uint32, []byte, bool, string, error)
 }
@@ -280,12 +283,14 @@ type AchievementRepo interface {
 	EnsureExists(charID uint32) error
 	GetAllScores(charID uint32) ([33]int32, error)
 	IncrementScore(charID uint32, achievementID uint8) error
+	CountAtLeast(achievementID uint8, minScore int32) (int, error)
 }
 
 // ShopRepo defines the contract for shop data access.
 type ShopRepo interface {
 	GetShopItems(shopType uint8, shopID uint32, charID uint32) ([]ShopItem, error)
 	RecordPurchase(charID, shopItemID, quantity uint32) error
+	GetShopItem(id uint32) (shopType uint8, item ShopItem, err error)
 	GetFpointItem(tradeID uint32) (quantity, fpoints int, err error)
 	GetFpointExchangeList() ([]FPointExchange, error)
 }
@@ -333,3 +338,24 @@ type MercenaryRepo interface {
 	GetGuildHuntCatsUsed(charID uint32) ([]GuildHuntCatUsage, error)
 	GetGuildAirou(guildID uint32) ([][]byte, error)
 }
+
+// ModerationRepo defines the contract for unbans, chat mutes and recording
+// moderation actions in the audit log.
+type ModerationRepo interface {
+	Unban(userID uint32) error
+	SetMutedUntil(userID uint32, until *time.Time) error
+	GetMutedUntil(userID uint32) (*time.Time, error)
+	InsertLog(userID uint32, action string, expires *time.Time, source, actor string) error
+}
+
+// AuditRepo defines the contract for recording privileged actions in the
+// audit log.
+type AuditRepo interface {
+	Record(source, actor, action, target string, params any) error
+}
+
+// SessionEventRepo defines the contract for recording session lifecycle
+// events.
+type SessionEventRepo interface {
+	Record(s sessionlog.Subject, event string, detail any) error
+}
@@ -9,6 +9,7 @@ import (
 // MiscRepository centralizes database access for miscellaneous game tables.
 type MiscRepository struct {
 	db *sqlx.DB
+	replicaReads
 }
 
 // NewMiscRepository creates a new MiscRepository.
@@ -18,7 +19,7 @@ func NewMiscRepository(db *sqlx.DB) *MiscRepository {
 
 // GetTrendWeapons returns the top 3 weapon IDs for a given weapon type, ordered by count descending.
 func (r *MiscRepository) GetTrendWeapons(weaponType uint8) ([]uint16, error) {
-	rows, err := r.db.Query("SELECT weapon_id FROM trend_weapons WHERE weapon_type=$1 ORDER BY count DESC LIMIT 3", weaponType)
+	rows, err := r.reader(r.db).Query("SELECT weapon_id FROM trend_weapons WHERE weapon_type=$1 ORDER BY count DESC LIMIT 3", weaponType)
 	if err != nil {
 		return nil, fmt.Errorf("query trend_weapons: %w", err)
 	}
@@ -3,6 +3,9 @@ package channelserver
 import (
 	"errors"
 	"time"
+
+	cfg "erupe-ce/config"
+	"erupe-ce/server/sessionlog"
 )
 
 // errNotFound is a sentinel for mock repos that simulate "not found".
@@ -17,6 +20,8 @@ type mockAchievementRepo struct {
 	getScoresErr  error
 	incrementErr  error
 	incrementedID uint8
+	countAtLeast  int
+	countErr      error
 }
 
 func (m *mockAchievementRepo) EnsureExists(_ uint32) error {
@@ -33,6 +38,10 @@ func (m *mockAchievementRepo) IncrementScore(_ uint32, id uint8) error {
 	return m.incrementErr
 }
 
+func (m *mockAchievementRepo) CountAtLeast(_ uint8, _ int32) (int, error) {
+	return m.countAtLeast, m.countErr
+}
+
 // --- mockMailRepo ---
 
 type mockMailRepo struct {
@@ -121,6 +130,12 @@ type mockCharacterRepo struct {
 	loadSaveDataNew  bool
 	loadSaveDataName string
 	loadSaveDataErr  error
+
+	// SaveCharacterData mock fields
+	saveCharacterDataCalls int
+	saveCharacterDataData  []byte
+	saveCharacterDataMode  cfg.Mode
+	saveCharacterDataErr   error
 }
 
 func newMockCharacterRepo() *mockCharacterRepo {
@@ -215,7 +230,13 @@ func (m *mockCharacterRepo) ReadGuildPostChecked(_ uint32) (time.Time, error) {
 func (m *mockCharacterRepo) SaveMercenary(_ uint32, _ []byte, _ uint32) error    { return nil }
 func (m *mockCharacterRepo) UpdateGCPAndPact(_ uint32, _ uint32, _ uint32) error { return nil }
 func (m *mockCharacterRepo) FindByRastaID(_ int) (uint32, string, error)         { return 0, "", nil }
-func (m *mockCharacterRepo) SaveCharacterData(_ uint32, _ []byte, _, _ uint16, _ bool, _ uint8, _ uint16) error {
+func (m *mockCharacterRepo) SaveCharacterData(_ uint32, data []byte, mode cfg.Mode, _, _ uint16, _ bool, _ uint8, _ uint16) error {
+	if m.saveCharacterDataErr != nil {
+		return m.saveCharacterDataErr
+	}
+	m.saveCharacterDataCalls++
+	m.saveCharacterDataData = data
+	m.saveCharacterDataMode = mode
 	return nil
 }
 func (m *mockCharacterRepo) SaveHouseData(_ uint32, _ []byte, _, _, _, _, _ []byte) error { return nil }
@@ -266,8 +287,9 @@ func (m *mockGoocooRepo) SaveSlot(_ uint32, slot uint32, data []byte) error {
 
 type mockGuildRepo struct {
 	// Core data
-	guild   *Guild
-	members []*GuildMember
+	guild     *Guild
+	members   []*GuildMember
+	allGuilds []*Guild // Returned by ListAll
 
 	// Configurable errors
 	getErr        error
@@ -534,7 +556,7 @@ func (m *mockGuildRepo) CountGuildKills(_, _ uint32) (int, error) {
 }
 
 // No-op stubs for remaining GuildRepo interface methods.
-func (m *mockGuildRepo) ListAll() ([]*Guild, error)               { return nil, nil }
+func (m *mockGuildRepo) ListAll() ([]*Guild, error)               { return m.allGuilds, nil }
 func (m *mockGuildRepo) Create(_ uint32, _ string) (int32, error) { return 0, nil }
 func (m *mockGuildRepo) CreateApplicationWithMail(_, _, _ uint32, _ GuildApplicationType, _, _ uint32, _, _ string) error {
 	return nil
@@ -872,6 +894,9 @@ type mockShopRepo struct {
 	fpointValue     int
 	fpointItemErr   error
 	fpointExchanges []FPointExchange
+	shopItemType    uint8
+	shopItem        ShopItem
+	shopItemErr     error
 }
 
 type shopPurchaseRecord struct {
@@ -885,6 +910,9 @@ func (m *mockShopRepo) RecordPurchase(charID, itemHash, quantity uint32) error {
 	m.purchases = append(m.purchases, shopPurchaseRecord{charID, itemHash, quantity})
 	return m.recordErr
 }
+func (m *mockShopRepo) GetShopItem(_ uint32) (uint8, ShopItem, error) {
+	return m.shopItemType, m.shopItem, m.shopItemErr
+}
 func (m *mockShopRepo) GetFpointItem(_ uint32) (int, int, error) {
 	return m.fpointQuantity, m.fpointValue, m.fpointItemErr
 }
@@ -996,6 +1024,7 @@ type mockFestaRepo struct {
 	eventsErr   error
 	teamSouls   uint32
 	teamErr     error
+	teamByName  map[string]uint32
 	trials      []FestaTrial
 	trialsErr   error
 	topGuild    FestaGuildRanking
@@ -1025,7 +1054,12 @@ func (m *mockFestaRepo) InsertEvent(start uint32) error {
 	return m.insertErr
 }
 func (m *mockFestaRepo) GetFestaEvents() ([]FestaEvent, error)     { return m.events, m.eventsErr }
-func (m *mockFestaRepo) GetTeamSouls(_ string) (uint32, error)     { return m.teamSouls, m.teamErr }
+func (m *mockFestaRepo) GetTeamSouls(team string) (uint32, error) {
+	if souls, ok := m.teamByName[team]; ok {
+		return souls, m.teamErr
+	}
+	return m.teamSouls, m.teamErr
+}
 func (m *mockFestaRepo) GetTrialsWithMonopoly() ([]FestaTrial, error) {
 	return m.trials, m.trialsErr
 }
@@ -1156,3 +1190,92 @@ func (m *mockCafeRepo) GetBonusItem(_ uint32) (uint32, uint32, error) {
 	return m.bonusItemType, m.bonusItemQty, m.bonusItemErr
 }
 func (m *mockCafeRepo) AcceptBonus(_, _ uint32) error { return nil }
+
+// --- mockModerationRepo ---
+
+type moderationLogEntry struct {
+	userID  uint32
+	action  string
+	expires *time.Time
+	source  string
+	actor   string
+}
+
+type mockModerationRepo struct {
+	mutedUntil  *time.Time
+	unbanned    []uint32
+	log         []moderationLogEntry
+	unbanErr    error
+	muteErr     error
+	insertLogErr error
+}
+
+func (m *mockModerationRepo) Unban(userID uint32) error {
+	if m.unbanErr != nil {
+		return m.unbanErr
+	}
+	m.unbanned = append(m.unbanned, userID)
+	return nil
+}
+func (m *mockModerationRepo) SetMutedUntil(_ uint32, until *time.Time) error {
+	if m.muteErr != nil {
+		return m.muteErr
+	}
+	m.mutedUntil = until
+	return nil
+}
+func (m *mockModerationRepo) GetMutedUntil(_ uint32) (*time.Time, error) {
+	return m.mutedUntil, nil
+}
+func (m *mockModerationRepo) InsertLog(userID uint32, action string, expires *time.Time, source, actor string) error {
+	if m.insertLogErr != nil {
+		return m.insertLogErr
+	}
+	m.log = append(m.log, moderationLogEntry{userID, action, expires, source, actor})
+	return nil
+}
+
+// --- mockAuditRepo ---
+
+type auditEntry struct {
+	source, actor, action, target string
+	params                        any
+}
+
+type mockAuditRepo struct {
+	entries   []auditEntry
+	recordErr error
+}
+
+func (m *mockAuditRepo) Record(source, actor, action, target string, params any) error {
+	if m.recordErr != nil {
+		return m.recordErr
+	}
+	m.entries = append(m.entries, auditEntry{source, actor, action, target, params})
+	return nil
+}
+
+// --- mockSessionEventRepo ---
+
+type sessionEvent struct {
+	subject sessionlog.Subject
+	event   string
+	detail  any
+}
+
+type mockSessionEventRepo struct {
+	events []sessionEvent
+}
+
+func (m *mockSessionEventRepo) Record(s sessionlog.Subject, event string, detail any) error {
+	m.events = append(m.events, sessionEvent{s, event, detail})
+	return nil
+}
+
+func (m *mockSessionEventRepo) names() []string {
+	names := make([]string, len(m.events))
+	for i, e := range m.events {
+		names[i] = e.event
+	}
+	return names
+}
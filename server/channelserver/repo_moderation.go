@@ -0,0 +1,51 @@
+package channelserver
+
+import (
+	"strconv"
+	"time"
+
+	"erupe-ce/server/audit"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// ModerationRepository centralizes database access for unbans and chat
+// mutes, and records moderation actions in the audit_log table.
+type ModerationRepository struct {
+	db    *sqlx.DB
+	audit *audit.Repository
+}
+
+// NewModerationRepository creates a new ModerationRepository.
+func NewModerationRepository(db *sqlx.DB) *ModerationRepository {
+	return &ModerationRepository{db: db, audit: audit.NewRepository(db)}
+}
+
+// Unban removes any ban on the user.
+func (r *ModerationRepository) Unban(userID uint32) error {
+	_, err := r.db.Exec(`DELETE FROM bans WHERE user_id=$1`, userID)
+	return err
+}
+
+// SetMutedUntil mutes the user until the given time, or unmutes them if until is nil.
+func (r *ModerationRepository) SetMutedUntil(userID uint32, until *time.Time) error {
+	_, err := r.db.Exec(`UPDATE users SET muted_until=$1 WHERE id=$2`, until, userID)
+	return err
+}
+
+// GetMutedUntil returns the end of the user's mute, or nil if they are not muted.
+func (r *ModerationRepository) GetMutedUntil(userID uint32) (*time.Time, error) {
+	var until *time.Time
+	err := r.db.QueryRow(`SELECT muted_until FROM users WHERE id=$1`, userID).Scan(&until)
+	return until, err
+}
+
+// InsertLog records a moderation action in the audit log as
+// "moderation:<action>" against the user's ID.
+func (r *ModerationRepository) InsertLog(userID uint32, action string, expires *time.Time, source, actor string) error {
+	var params any
+	if expires != nil {
+		params = map[string]time.Time{"expires": *expires}
+	}
+	return r.audit.Record(source, actor, "moderation:"+action, strconv.FormatUint(uint64(userID), 10), params)
+}
@@ -9,6 +9,7 @@ import (
 // RengokuRepository centralizes all database access for the rengoku_score table.
 type RengokuRepository struct {
 	db *sqlx.DB
+	replicaReads
 }
 
 // NewRengokuRepository creates a new RengokuRepository.
@@ -67,12 +68,12 @@ func (r *RengokuRepository) GetRanking(leaderboard uint32, guildID uint32) ([]Re
 	var result []RengokuScore
 	var err error
 	if rengokuIsGuildFiltered(leaderboard) {
-		err = r.db.Select(&result,
+		err = r.reader(r.db).Select(&result,
 			fmt.Sprintf("SELECT %s AS score %s WHERE guild_id=$1 ORDER BY %s DESC", col, rengokuScoreQueryRepo, col),
 			guildID,
 		)
 	} else {
-		err = r.db.Select(&result,
+		err = r.reader(r.db).Select(&result,
 			fmt.Sprintf("SELECT %s AS score %s ORDER BY %s DESC", col, rengokuScoreQueryRepo, col),
 		)
 	}
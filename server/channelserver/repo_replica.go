@@ -0,0 +1,55 @@
+package channelserver
+
+import (
+	"sync/atomic"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// ReplicaSet spreads read-only queries over one or more read replicas in
+// round-robin order.
+type ReplicaSet struct {
+	dbs  []*sqlx.DB
+	next atomic.Uint32
+}
+
+// NewReplicaSet creates a ReplicaSet, or returns nil if dbs is empty so
+// repositories keep reading from the primary.
+func NewReplicaSet(dbs []*sqlx.DB) *ReplicaSet {
+	if len(dbs) == 0 {
+		return nil
+	}
+	return &ReplicaSet{dbs: dbs}
+}
+
+// pick returns the next replica.
+func (rs *ReplicaSet) pick() *sqlx.DB {
+	n := rs.next.Add(1) - 1
+	return rs.dbs[n%uint32(len(rs.dbs))]
+}
+
+// replicaRouted is implemented by repositories with read-only queries that
+// can be served by a read replica.
+type replicaRouted interface {
+	useReplicas(rs *ReplicaSet)
+}
+
+// replicaReads is embedded by repositories whose read-only queries tolerate
+// replication lag: catalogs, rankings and other data the caller did not
+// just write. Queries that must see the caller's own writes stay on the
+// primary.
+type replicaReads struct {
+	replicas *ReplicaSet
+}
+
+func (r *replicaReads) useReplicas(rs *ReplicaSet) {
+	r.replicas = rs
+}
+
+// reader returns a replica when any are configured, otherwise primary.
+func (r *replicaReads) reader(primary *sqlx.DB) *sqlx.DB {
+	if r.replicas == nil {
+		return primary
+	}
+	return r.replicas.pick()
+}
@@ -0,0 +1,46 @@
+package channelserver
+
+import (
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+)
+
+func TestNewReplicaSet_Empty(t *testing.T) {
+	if rs := NewReplicaSet(nil); rs != nil {
+		t.Error("expected nil ReplicaSet without replicas")
+	}
+}
+
+func TestReplicaReads_FallsBackToPrimary(t *testing.T) {
+	primary := &sqlx.DB{}
+	repo := NewGachaRepository(primary)
+	if got := repo.reader(primary); got != primary {
+		t.Error("reader() without replicas should return the primary")
+	}
+}
+
+func TestReplicaReads_RoundRobin(t *testing.T) {
+	primary, a, b := &sqlx.DB{}, &sqlx.DB{}, &sqlx.DB{}
+	repo := NewShopRepository(primary)
+	repo.useReplicas(NewReplicaSet([]*sqlx.DB{a, b}))
+
+	want := []*sqlx.DB{a, b, a, b}
+	for i, w := range want {
+		if got := repo.reader(primary); got != w {
+			t.Errorf("reader() call %d returned the wrong pool", i)
+		}
+	}
+}
+
+func TestNewServer_RoutesReadsToReplicas(t *testing.T) {
+	primary, replica := &sqlx.DB{}, &sqlx.DB{}
+	s := NewServer(&Config{
+		DB:          primary,
+		ReadDBs:     []*sqlx.DB{replica},
+		ErupeConfig: createMockServer().erupeConfig,
+	})
+	if got := s.scenarioRepo.(*ScenarioRepository).reader(primary); got != replica {
+		t.Error("scenario reads should use the replica")
+	}
+}
@@ -9,6 +9,7 @@ import (
 // ScenarioRepository centralizes all database access for the scenario_counter table.
 type ScenarioRepository struct {
 	db *sqlx.DB
+	replicaReads
 }
 
 // NewScenarioRepository creates a new ScenarioRepository.
@@ -18,7 +19,7 @@ func NewScenarioRepository(db *sqlx.DB) *ScenarioRepository {
 
 // GetCounters returns all scenario counters.
 func (r *ScenarioRepository) GetCounters() ([]Scenario, error) {
-	rows, err := r.db.Query("SELECT scenario_id, category_id FROM scenario_counter")
+	rows, err := r.reader(r.db).Query("SELECT scenario_id, category_id FROM scenario_counter")
 	if err != nil {
 		return nil, fmt.Errorf("query scenario_counter: %w", err)
 	}
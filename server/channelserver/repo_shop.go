@@ -6,18 +6,20 @@ import (
 
 // ShopRepository centralizes all database access for shop-related tables.
 type ShopRepository struct {
-	db *sqlx.DB
+	db    *sqlx.DB
+	stmts *StmtCache // Prepared statements for hot queries
+	replicaReads
 }
 
 // NewShopRepository creates a new ShopRepository.
 func NewShopRepository(db *sqlx.DB) *ShopRepository {
-	return &ShopRepository{db: db}
+	return &ShopRepository{db: db, stmts: NewStmtCache(db)}
 }
 
 // GetShopItems returns shop items with per-character purchase counts.
 func (r *ShopRepository) GetShopItems(shopType uint8, shopID uint32, charID uint32) ([]ShopItem, error) {
 	var result []ShopItem
-	err := r.db.Select(&result, `SELECT id, item_id, cost, quantity, min_hr, min_sr, min_gr, store_level, max_quantity,
+	err := r.stmts.Select(&result, `SELECT id, item_id, cost, quantity, min_hr, min_sr, min_gr, store_level, max_quantity,
        		COALESCE((SELECT bought FROM shop_items_bought WHERE shop_item_id=si.id AND character_id=$3), 0) as used_quantity,
        		road_floors, road_fatalis FROM shop_items si WHERE shop_type=$1 AND shop_id=$2
        		`, shopType, shopID, charID)
@@ -33,15 +35,23 @@ func (r *ShopRepository) RecordPurchase(charID, shopItemID, quantity uint32) err
 	return err
 }
 
+// GetShopItem returns the shop type, item, quantity and cost of a shop item.
+func (r *ShopRepository) GetShopItem(id uint32) (shopType uint8, item ShopItem, err error) {
+	item.ID = id
+	err = r.stmts.QueryRow("SELECT shop_type, item_id, cost, quantity FROM shop_items WHERE id=$1", id).
+		Scan(&shopType, &item.ItemID, &item.Cost, &item.Quantity)
+	return
+}
+
 // GetFpointItem returns the quantity and fpoints cost for a frontier point item.
 func (r *ShopRepository) GetFpointItem(tradeID uint32) (quantity, fpoints int, err error) {
-	err = r.db.QueryRow("SELECT quantity, fpoints FROM fpoint_items WHERE id=$1", tradeID).Scan(&quantity, &fpoints)
+	err = r.stmts.QueryRow("SELECT quantity, fpoints FROM fpoint_items WHERE id=$1", tradeID).Scan(&quantity, &fpoints)
 	return
 }
 
 // GetFpointExchangeList returns all frontier point exchange items ordered by buyable status.
 func (r *ShopRepository) GetFpointExchangeList() ([]FPointExchange, error) {
 	var result []FPointExchange
-	err := r.db.Select(&result, `SELECT id, item_type, item_id, quantity, fpoints, buyable FROM fpoint_items ORDER BY buyable DESC`)
+	err := r.reader(r.db).Select(&result, `SELECT id, item_type, item_id, quantity, fpoints, buyable FROM fpoint_items ORDER BY buyable DESC`)
 	return result, err
 }
@@ -0,0 +1,106 @@
+package channelserver
+
+import (
+	"database/sql"
+	"sync"
+	"sync/atomic"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// Statement cache counters shared by every StmtCache, for StmtCacheStats.
+var (
+	stmtCacheHits   atomic.Uint64
+	stmtCacheMisses atomic.Uint64
+)
+
+// StmtCacheStats returns how often hot queries reused a prepared statement
+// (hits) and how often one had to be prepared (misses) since startup.
+func StmtCacheStats() (hits, misses uint64) {
+	return stmtCacheHits.Load(), stmtCacheMisses.Load()
+}
+
+// StmtCache prepares each query the first time it runs and reuses the
+// statement afterwards, so hot queries are not re-parsed on every call.
+// Its methods mirror those of sqlx.DB; a query that fails to prepare runs
+// unprepared instead.
+type StmtCache struct {
+	db    *sqlx.DB
+	mu    sync.RWMutex
+	stmts map[string]*sqlx.Stmt
+}
+
+// NewStmtCache creates an empty StmtCache for db.
+func NewStmtCache(db *sqlx.DB) *StmtCache {
+	return &StmtCache{db: db, stmts: make(map[string]*sqlx.Stmt)}
+}
+
+// stmt returns the prepared statement for query, preparing it if needed.
+func (c *StmtCache) stmt(query string) (*sqlx.Stmt, error) {
+	c.mu.RLock()
+	stmt, ok := c.stmts[query]
+	c.mu.RUnlock()
+	if ok {
+		stmtCacheHits.Add(1)
+		return stmt, nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if stmt, ok := c.stmts[query]; ok {
+		stmtCacheHits.Add(1)
+		return stmt, nil
+	}
+	stmtCacheMisses.Add(1)
+	stmt, err := c.db.Preparex(query)
+	if err != nil {
+		return nil, err
+	}
+	c.stmts[query] = stmt
+	return stmt, nil
+}
+
+// Exec executes query with args.
+func (c *StmtCache) Exec(query string, args ...interface{}) (sql.Result, error) {
+	stmt, err := c.stmt(query)
+	if err != nil {
+		return c.db.Exec(query, args...)
+	}
+	return stmt.Exec(args...)
+}
+
+// QueryRow executes query, which is expected to return at most one row.
+func (c *StmtCache) QueryRow(query string, args ...interface{}) *sql.Row {
+	stmt, err := c.stmt(query)
+	if err != nil {
+		return c.db.QueryRow(query, args...)
+	}
+	return stmt.QueryRow(args...)
+}
+
+// QueryRowx executes query, which is expected to return at most one row.
+func (c *StmtCache) QueryRowx(query string, args ...interface{}) *sqlx.Row {
+	stmt, err := c.stmt(query)
+	if err != nil {
+		return c.db.QueryRowx(query, args...)
+	}
+	return stmt.QueryRowx(args...)
+}
+
+// Queryx executes query and returns its rows.
+func (c *StmtCache) Queryx(query string, args ...interface{}) (*sqlx.Rows, error) {
+	stmt, err := c.stmt(query)
+	if err != nil {
+		return c.db.Queryx(query, args...)
+	}
+	return stmt.Queryx(args...)
+}
+
+// Select executes query and scans every row into dest.
+func (c *StmtCache) Select(dest interface{}, query string, args ...interface{}) error {
+	stmt, err := c.stmt(query)
+	if err != nil {
+		return c.db.Select(dest, query, args...)
+	}
+	return stmt.Select(dest, args...)
+}
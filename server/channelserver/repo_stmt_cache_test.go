@@ -0,0 +1,46 @@
+package channelserver
+
+import (
+	"testing"
+)
+
+func TestStmtCacheReusesStatements(t *testing.T) {
+	db := SetupTestDB(t)
+	defer TeardownTestDB(t, db)
+	cache := NewStmtCache(db)
+
+	hits, misses := StmtCacheStats()
+	for i := 0; i < 3; i++ {
+		var n int
+		if err := cache.QueryRow("SELECT $1::int", i).Scan(&n); err != nil {
+			t.Fatalf("QueryRow failed: %v", err)
+		}
+		if n != i {
+			t.Errorf("got %d, want %d", n, i)
+		}
+	}
+	newHits, newMisses := StmtCacheStats()
+
+	if newMisses-misses != 1 {
+		t.Errorf("misses = %d, want 1", newMisses-misses)
+	}
+	if newHits-hits != 2 {
+		t.Errorf("hits = %d, want 2", newHits-hits)
+	}
+	if len(cache.stmts) != 1 {
+		t.Errorf("cached %d statements, want 1", len(cache.stmts))
+	}
+}
+
+func TestStmtCacheFallsBackOnPrepareError(t *testing.T) {
+	db := SetupTestDB(t)
+	defer TeardownTestDB(t, db)
+	cache := NewStmtCache(db)
+
+	if _, err := cache.Exec("NOT VALID SQL"); err == nil {
+		t.Fatal("expected error for invalid query")
+	}
+	if len(cache.stmts) != 0 {
+		t.Error("failed statements should not be cached")
+	}
+}
@@ -0,0 +1,8 @@
+// Package savedata reads and writes the fields of a decompressed character
+// save through typed accessors. Where each field sits in every client mode's
+// save is kept in Layout, so handlers and tools never index the save blob
+// with raw offsets and a layout change is made in one place.
+//
+// Only fields whose offsets are known are covered. The item box is not part
+// of the save; the server keeps it in the warehouse table.
+package savedata
@@ -0,0 +1,224 @@
+package savedata
+
+import (
+	"sort"
+
+	cfg "erupe-ce/config"
+)
+
+// Field is a field of a save.
+type Field int
+
+// Fields of a save. Which a mode's save has is up to its Layout.
+const (
+	FieldName Field = iota
+	FieldGender
+	FieldRP
+	FieldHouseTier
+	FieldHouseData
+	FieldBookshelf
+	FieldGallery
+	FieldTore
+	FieldGarden
+	FieldPlaytime
+	FieldWeaponType
+	FieldWeaponID
+	FieldHR
+	FieldGRP
+	FieldKQF
+)
+
+// fieldNames are the names tools use for the fields.
+var fieldNames = [...]string{
+	FieldName:       "name",
+	FieldGender:     "gender",
+	FieldRP:         "rp",
+	FieldHouseTier:  "house_tier",
+	FieldHouseData:  "house_data",
+	FieldBookshelf:  "bookshelf",
+	FieldGallery:    "gallery",
+	FieldTore:       "tore",
+	FieldGarden:     "garden",
+	FieldPlaytime:   "playtime",
+	FieldWeaponType: "weapon_type",
+	FieldWeaponID:   "weapon_id",
+	FieldHR:         "hr",
+	FieldGRP:        "grp",
+	FieldKQF:        "kqf",
+}
+
+func (f Field) String() string {
+	if f < 0 || int(f) >= len(fieldNames) {
+		return "unknown"
+	}
+	return fieldNames[f]
+}
+
+// fieldSizes are the lengths of the fields. The bookshelf's depends on the
+// mode.
+var fieldSizes = map[Field]int{
+	FieldName:       12,
+	FieldGender:     1,
+	FieldRP:         2,
+	FieldHouseTier:  5,
+	FieldHouseData:  195,
+	FieldGallery:    1748,
+	FieldTore:       240,
+	FieldGarden:     68,
+	FieldPlaytime:   4,
+	FieldWeaponType: 1,
+	FieldWeaponID:   2,
+	FieldHR:         2,
+	FieldGRP:        4,
+	FieldKQF:        8,
+}
+
+// Layout is where the fields of one client mode's save are.
+type Layout struct {
+	Mode         cfg.Mode
+	offsets      map[Field]int
+	bookshelfLen int
+}
+
+// LayoutFor returns the layout of a mode's save. Every mode has the name and
+// gender at the same offsets; the other fields are only known for S6, F4 to
+// F5, G1 to Z2 and ZZ.
+func LayoutFor(mode cfg.Mode) Layout {
+	l := Layout{
+		Mode:         mode,
+		offsets:      map[Field]int{FieldName: 88, FieldGender: 81},
+		bookshelfLen: 5576,
+	}
+	o := l.offsets
+	switch mode {
+	case cfg.ZZ:
+		o[FieldPlaytime] = 128356
+		o[FieldWeaponID] = 128522
+		o[FieldWeaponType] = 128789
+		o[FieldHouseTier] = 129900
+		o[FieldTore] = 130228
+		o[FieldHR] = 130550
+		o[FieldGRP] = 130556
+		o[FieldHouseData] = 130561
+		o[FieldBookshelf] = 139928
+		o[FieldGallery] = 140064
+		o[FieldGarden] = 142424
+		o[FieldRP] = 142614
+		o[FieldKQF] = 146720
+	case cfg.Z2, cfg.Z1, cfg.G101, cfg.G10, cfg.G91, cfg.G9, cfg.G81, cfg.G8,
+		cfg.G7, cfg.G61, cfg.G6, cfg.G52, cfg.G51, cfg.G5, cfg.GG, cfg.G32, cfg.G31,
+		cfg.G3, cfg.G2, cfg.G1:
+		o[FieldPlaytime] = 92356
+		o[FieldWeaponID] = 92522
+		o[FieldWeaponType] = 92789
+		o[FieldHouseTier] = 93900
+		o[FieldTore] = 94228
+		o[FieldHR] = 94550
+		o[FieldGRP] = 94556
+		o[FieldHouseData] = 94561
+		o[FieldBookshelf] = 89118 // TODO: fix bookshelf data pointer
+		o[FieldGallery] = 104064
+		o[FieldGarden] = 106424
+		o[FieldRP] = 106614
+		o[FieldKQF] = 110720
+	case cfg.F5, cfg.F4:
+		o[FieldPlaytime] = 60356
+		o[FieldWeaponID] = 60522
+		o[FieldWeaponType] = 60789
+		o[FieldHouseTier] = 61900
+		o[FieldTore] = 62228
+		o[FieldHR] = 62550
+		o[FieldHouseData] = 62561
+		o[FieldBookshelf] = 57118 // TODO: fix bookshelf data pointer
+		o[FieldGallery] = 72064
+		o[FieldGarden] = 74424
+		o[FieldRP] = 74614
+	case cfg.S6:
+		o[FieldPlaytime] = 12356
+		o[FieldWeaponID] = 12522
+		o[FieldWeaponType] = 12789
+		o[FieldHouseTier] = 13900
+		o[FieldTore] = 14228
+		o[FieldHR] = 14550
+		o[FieldHouseData] = 14561
+		o[FieldBookshelf] = 9118 // TODO: fix bookshelf data pointer
+		o[FieldGallery] = 24064
+		o[FieldGarden] = 26424
+		o[FieldRP] = 26614
+	}
+	if mode == cfg.G5 {
+		l.bookshelfLen = 5548
+	} else if mode <= cfg.GG {
+		l.bookshelfLen = 4520
+	}
+	return l
+}
+
+// Known reports whether the layout has more than the name and gender.
+func (l Layout) Known() bool {
+	_, ok := l.offsets[FieldHR]
+	return ok
+}
+
+// Has reports whether the server reads a field in the layout's mode. The
+// key quest flags are only read from G10 on, though G1 saves have room for
+// them.
+func (l Layout) Has(f Field) bool {
+	_, ok := l.offsets[f]
+	return ok && (f != FieldKQF || l.Mode >= cfg.G10)
+}
+
+// Offset returns where a field starts, whether or not the server reads it in
+// the layout's mode.
+func (l Layout) Offset(f Field) (int, bool) {
+	off, ok := l.offsets[f]
+	return off, ok
+}
+
+// Size returns the length of a field.
+func (l Layout) Size(f Field) int {
+	if f == FieldBookshelf {
+		return l.bookshelfLen
+	}
+	return fieldSizes[f]
+}
+
+// End returns the offset just past the last field of the layout. A save
+// shorter than this cannot be read.
+func (l Layout) End() int {
+	end := 0
+	for f, off := range l.offsets {
+		end = max(end, off+l.Size(f))
+	}
+	return end
+}
+
+// FieldInfo is where a field is in a mode's save, for tools that inspect
+// saves.
+type FieldInfo struct {
+	Name   string
+	Field  Field
+	Offset int
+	Size   int
+}
+
+// End returns the offset just past the field.
+func (f FieldInfo) End() int {
+	return f.Offset + f.Size
+}
+
+// Fields returns the fields the server reads in a mode's save, ordered by
+// offset. The bookshelf is only listed for ZZ, the one mode its offset is
+// known to be right for.
+func Fields(mode cfg.Mode) []FieldInfo {
+	l := LayoutFor(mode)
+	var fields []FieldInfo
+	for f, off := range l.offsets {
+		if !l.Has(f) || (f == FieldBookshelf && mode != cfg.ZZ) {
+			continue
+		}
+		fields = append(fields, FieldInfo{f.String(), f, off, l.Size(f)})
+	}
+	sort.Slice(fields, func(i, j int) bool { return fields[i].Offset < fields[j].Offset })
+	return fields
+}
@@ -0,0 +1,78 @@
+package savedata
+
+import (
+	"slices"
+	"testing"
+
+	cfg "erupe-ce/config"
+)
+
+// TestLayoutFor checks the offsets of each layout against known saves.
+func TestLayoutFor(t *testing.T) {
+	tests := []struct {
+		mode   cfg.Mode
+		hr     int
+		kqf    bool
+		end    int
+		shelfN int
+	}{
+		{cfg.ZZ, 130550, true, 146728, 5576},
+		{cfg.Z2, 94550, true, 110728, 5576},
+		{cfg.G10, 94550, true, 110728, 5576},
+		{cfg.G5, 94550, false, 110728, 5548},
+		{cfg.GG, 94550, false, 110728, 4520},
+		{cfg.F5, 62550, false, 74616, 4520},
+		{cfg.S6, 14550, false, 26616, 4520},
+	}
+	for _, tt := range tests {
+		l := LayoutFor(tt.mode)
+		if off, _ := l.Offset(FieldHR); off != tt.hr {
+			t.Errorf("mode %d: HR at %d, want %d", tt.mode, off, tt.hr)
+		}
+		if off, _ := l.Offset(FieldGender); off != 81 {
+			t.Errorf("mode %d: gender at %d, want 81", tt.mode, off)
+		}
+		if l.Has(FieldKQF) != tt.kqf {
+			t.Errorf("mode %d: Has(KQF) = %v, want %v", tt.mode, !tt.kqf, tt.kqf)
+		}
+		if l.End() != tt.end {
+			t.Errorf("mode %d: End = %d, want %d", tt.mode, l.End(), tt.end)
+		}
+		if l.Size(FieldBookshelf) != tt.shelfN {
+			t.Errorf("mode %d: bookshelf is %d bytes, want %d", tt.mode, l.Size(FieldBookshelf), tt.shelfN)
+		}
+	}
+
+	s10 := LayoutFor(cfg.S10)
+	if s10.Known() || s10.Has(FieldHR) || !s10.Has(FieldName) || s10.End() != 100 {
+		t.Errorf("S10 layout = %+v", s10)
+	}
+}
+
+func TestFields(t *testing.T) {
+	names := func(mode cfg.Mode) []string {
+		var out []string
+		fields := Fields(mode)
+		for i, f := range fields {
+			if i > 0 && f.Offset < fields[i-1].Offset {
+				t.Errorf("mode %d: %s is out of order", mode, f.Name)
+			}
+			out = append(out, f.Name)
+		}
+		return out
+	}
+
+	zz := names(cfg.ZZ)
+	if len(zz) != 15 || !slices.Contains(zz, "bookshelf") || !slices.Contains(zz, "kqf") {
+		t.Errorf("ZZ fields = %v", zz)
+	}
+	if g := names(cfg.G5); slices.Contains(g, "bookshelf") || slices.Contains(g, "kqf") || !slices.Contains(g, "grp") {
+		t.Errorf("G5 fields = %v", g)
+	}
+	if f := names(cfg.F5); slices.Contains(f, "grp") || !slices.Contains(f, "rp") {
+		t.Errorf("F5 fields = %v", f)
+	}
+	if s := names(cfg.S10); !slices.Equal(s, []string{"gender", "name"}) {
+		t.Errorf("S10 fields = %v", s)
+	}
+}
@@ -0,0 +1,279 @@
+package savedata
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+
+	"erupe-ce/common/bfutil"
+	"erupe-ce/common/stringsupport"
+	cfg "erupe-ce/config"
+)
+
+// ErrNoField is returned when setting a field the save's layout does not
+// have, or that runs past the end of the save.
+var ErrNoField = errors.New("field not in save")
+
+// Save is a decompressed save read through the layout of the mode it was
+// written in. It reads and writes the blob it was created with in place.
+type Save struct {
+	layout Layout
+	data   []byte
+}
+
+// New returns a Save over data, a decompressed save written in mode.
+func New(data []byte, mode cfg.Mode) *Save {
+	return &Save{layout: LayoutFor(mode), data: data}
+}
+
+// Layout returns the save's layout.
+func (s *Save) Layout() Layout {
+	return s.layout
+}
+
+// Bytes returns the save's blob.
+func (s *Save) Bytes() []byte {
+	return s.data
+}
+
+// Raw returns the bytes of a field, backed by the save, or nil when the
+// save does not have it.
+func (s *Save) Raw(f Field) []byte {
+	if !s.layout.Has(f) {
+		return nil
+	}
+	off, _ := s.layout.Offset(f)
+	end := off + s.layout.Size(f)
+	if end > len(s.data) {
+		return nil
+	}
+	return s.data[off:end:end]
+}
+
+// field returns the bytes of a field to be set.
+func (s *Save) field(f Field) ([]byte, error) {
+	b := s.Raw(f)
+	if b == nil {
+		return nil, fmt.Errorf("%w: %s in a %d byte mode %d save", ErrNoField, f, len(s.data), s.layout.Mode)
+	}
+	return b, nil
+}
+
+func (s *Save) uint16(f Field) uint16 {
+	if b := s.Raw(f); b != nil {
+		return binary.LittleEndian.Uint16(b)
+	}
+	return 0
+}
+
+func (s *Save) uint32(f Field) uint32 {
+	if b := s.Raw(f); b != nil {
+		return binary.LittleEndian.Uint32(b)
+	}
+	return 0
+}
+
+func (s *Save) setUint16(f Field, v uint16) error {
+	b, err := s.field(f)
+	if err == nil {
+		binary.LittleEndian.PutUint16(b, v)
+	}
+	return err
+}
+
+func (s *Save) setUint32(f Field, v uint32) error {
+	b, err := s.field(f)
+	if err == nil {
+		binary.LittleEndian.PutUint32(b, v)
+	}
+	return err
+}
+
+// setBytes copies v over a field, cutting it to the field's length.
+func (s *Save) setBytes(f Field, v []byte) error {
+	b, err := s.field(f)
+	if err == nil {
+		copy(b, v)
+	}
+	return err
+}
+
+// NameBytes returns the character name as stored, in Shift-JIS without its
+// terminator.
+func (s *Save) NameBytes() []byte {
+	return bfutil.UpToNull(s.Raw(FieldName))
+}
+
+// Name returns the character name.
+func (s *Save) Name() string {
+	return stringsupport.SJISToUTF8Lossy(s.NameBytes())
+}
+
+// SetName writes the character name in Shift-JIS with its terminator,
+// leaving the rest of the field as it is.
+func (s *Save) SetName(name string) error {
+	b, err := s.field(FieldName)
+	if err != nil {
+		return err
+	}
+	sjis := stringsupport.UTF8ToSJIS(name)
+	if len(sjis) >= len(b) {
+		return fmt.Errorf("name %q is longer than %d bytes in Shift-JIS", name, len(b)-1)
+	}
+	copy(b, append(sjis, 0))
+	return nil
+}
+
+// Female reports whether the character is female.
+func (s *Save) Female() bool {
+	b := s.Raw(FieldGender)
+	return b != nil && b[0] == 1
+}
+
+// SetFemale sets the character's gender.
+func (s *Save) SetFemale(female bool) error {
+	b, err := s.field(FieldGender)
+	if err != nil {
+		return err
+	}
+	b[0] = 0
+	if female {
+		b[0] = 1
+	}
+	return nil
+}
+
+// RP returns the character's road points.
+func (s *Save) RP() uint16 { return s.uint16(FieldRP) }
+
+// SetRP sets the character's road points.
+func (s *Save) SetRP(rp uint16) error { return s.setUint16(FieldRP, rp) }
+
+// Playtime returns the character's play time in seconds.
+func (s *Save) Playtime() uint32 { return s.uint32(FieldPlaytime) }
+
+// SetPlaytime sets the character's play time in seconds.
+func (s *Save) SetPlaytime(seconds uint32) error { return s.setUint32(FieldPlaytime, seconds) }
+
+// WeaponType returns the type of the equipped weapon.
+func (s *Save) WeaponType() uint8 {
+	if b := s.Raw(FieldWeaponType); b != nil {
+		return b[0]
+	}
+	return 0
+}
+
+// SetWeaponType sets the type of the equipped weapon.
+func (s *Save) SetWeaponType(wt uint8) error {
+	b, err := s.field(FieldWeaponType)
+	if err == nil {
+		b[0] = wt
+	}
+	return err
+}
+
+// WeaponID returns the ID of the equipped weapon.
+func (s *Save) WeaponID() uint16 { return s.uint16(FieldWeaponID) }
+
+// SetWeaponID sets the ID of the equipped weapon.
+func (s *Save) SetWeaponID(id uint16) error { return s.setUint16(FieldWeaponID, id) }
+
+// HR returns the character's hunter rank, 999 once G rank is reached.
+func (s *Save) HR() uint16 { return s.uint16(FieldHR) }
+
+// SetHR sets the character's hunter rank.
+func (s *Save) SetHR(hr uint16) error { return s.setUint16(FieldHR, hr) }
+
+// GRP returns the character's G rank points.
+func (s *Save) GRP() uint32 { return s.uint32(FieldGRP) }
+
+// SetGRP sets the character's G rank points.
+func (s *Save) SetGRP(grp uint32) error { return s.setUint32(FieldGRP, grp) }
+
+// GR returns the character's G rank, worked out from its G rank points, or 0
+// before HR 999 or in modes before G1.
+func (s *Save) GR() uint16 {
+	if !s.layout.Has(FieldGRP) || s.HR() != 999 {
+		return 0
+	}
+	return GRFromGRP(int(s.GRP()))
+}
+
+// SetGR sets the character's hunter rank to 999 and its G rank points to the
+// least that give gr.
+func (s *Save) SetGR(gr uint16) error {
+	if err := s.SetGRP(GRPForGR(gr)); err != nil {
+		return err
+	}
+	return s.SetHR(999)
+}
+
+// HouseTier returns the house theme, backed by the save.
+func (s *Save) HouseTier() []byte { return s.Raw(FieldHouseTier) }
+
+// SetHouseTier writes the house theme, cut to the length of the field.
+func (s *Save) SetHouseTier(tier []byte) error { return s.setBytes(FieldHouseTier, tier) }
+
+// KQF returns the key quest flags, backed by the save. Modes before G10 have
+// none.
+func (s *Save) KQF() []byte { return s.Raw(FieldKQF) }
+
+// SetKQF writes the key quest flags, cut to the length of the field.
+func (s *Save) SetKQF(kqf []byte) error { return s.setBytes(FieldKQF, kqf) }
+
+// HouseData returns the house furniture, backed by the save.
+func (s *Save) HouseData() []byte { return s.Raw(FieldHouseData) }
+
+// Bookshelf returns the bookshelf, backed by the save.
+func (s *Save) Bookshelf() []byte { return s.Raw(FieldBookshelf) }
+
+// Gallery returns the gallery, backed by the save.
+func (s *Save) Gallery() []byte { return s.Raw(FieldGallery) }
+
+// Tore returns the tore data, backed by the save.
+func (s *Save) Tore() []byte { return s.Raw(FieldTore) }
+
+// Garden returns the garden, backed by the save.
+func (s *Save) Garden() []byte { return s.Raw(FieldGarden) }
+
+// GRFromGRP returns the G rank a number of G rank points gives.
+func GRFromGRP(n int) uint16 {
+	var gr int
+	a := []int{208750, 593400, 993400, 1400900, 2315900, 3340900, 4505900, 5850900, 7415900, 9230900, 11345900, 100000000}
+	b := []int{7850, 8000, 8150, 9150, 10250, 11650, 13450, 15650, 18150, 21150, 23950}
+	c := []int{51, 100, 150, 200, 300, 400, 500, 600, 700, 800, 900}
+
+	for i := 0; i < len(a); i++ {
+		if n < a[i] {
+			if i == 0 {
+				for {
+					n -= 500
+					if n <= 500 {
+						if n < 0 {
+							i--
+						}
+						break
+					} else {
+						i++
+						for j := 0; j < i; j++ {
+							n -= 150
+						}
+					}
+				}
+				gr = i + 2
+			} else {
+				n -= a[i-1]
+				gr = c[i-1]
+				gr += n / b[i-1]
+			}
+			break
+		}
+	}
+	return uint16(gr)
+}
+
+// GRPForGR returns the least G rank points GRFromGRP maps to gr.
+func GRPForGR(gr uint16) uint32 {
+	return uint32(sort.Search(100000000, func(n int) bool { return GRFromGRP(n) >= gr }))
+}
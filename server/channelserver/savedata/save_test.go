@@ -0,0 +1,71 @@
+package savedata
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	cfg "erupe-ce/config"
+)
+
+func TestSaveAccessors(t *testing.T) {
+	data := make([]byte, LayoutFor(cfg.ZZ).End())
+	s := New(data, cfg.ZZ)
+
+	if err := s.SetName("Hunter"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SetName("ABCDEFGHIJKL"); err == nil {
+		t.Error("12 byte name was accepted")
+	}
+	_ = s.SetFemale(true)
+	_ = s.SetRP(1234)
+	_ = s.SetPlaytime(86400)
+	_ = s.SetWeaponType(9)
+	_ = s.SetWeaponID(321)
+	_ = s.SetGR(250)
+	_ = s.SetHouseTier([]byte{1, 2, 3, 4, 5})
+	_ = s.SetKQF([]byte{0xFF, 0, 0xFF, 0, 0xFF, 0, 0xFF, 0})
+
+	// Read back from a fresh Save over the same blob.
+	s = New(data, cfg.ZZ)
+	if s.Name() != "Hunter" || !s.Female() || s.RP() != 1234 || s.Playtime() != 86400 {
+		t.Errorf("name %q female %v RP %d playtime %d", s.Name(), s.Female(), s.RP(), s.Playtime())
+	}
+	if s.WeaponType() != 9 || s.WeaponID() != 321 || s.HR() != 999 || s.GR() != 250 {
+		t.Errorf("weapon %d/%d HR %d GR %d", s.WeaponType(), s.WeaponID(), s.HR(), s.GR())
+	}
+	if !bytes.Equal(s.HouseTier(), []byte{1, 2, 3, 4, 5}) || s.KQF()[2] != 0xFF {
+		t.Errorf("house tier % X KQF % X", s.HouseTier(), s.KQF())
+	}
+	if off, _ := s.Layout().Offset(FieldHR); data[off] != 0xE7 || data[off+1] != 0x03 {
+		t.Error("HR was not written little endian at its offset")
+	}
+}
+
+func TestSaveMissingFields(t *testing.T) {
+	data := make([]byte, LayoutFor(cfg.G5).End())
+	s := New(data, cfg.G5)
+	if s.KQF() != nil {
+		t.Error("G5 save has key quest flags")
+	}
+	if err := s.SetKQF(make([]byte, 8)); !errors.Is(err, ErrNoField) {
+		t.Errorf("SetKQF on G5: err = %v, want ErrNoField", err)
+	}
+
+	short := New(make([]byte, 200), cfg.ZZ)
+	if short.HR() != 0 || short.HouseData() != nil {
+		t.Error("fields past the end of a short save were read")
+	}
+	if err := short.SetHR(1); !errors.Is(err, ErrNoField) {
+		t.Errorf("SetHR past the end: err = %v, want ErrNoField", err)
+	}
+}
+
+func TestGRPForGR_RoundTrip(t *testing.T) {
+	for gr := uint16(1); gr <= 900; gr++ {
+		if got := GRFromGRP(int(GRPForGR(gr))); got != gr {
+			t.Fatalf("GRFromGRP(GRPForGR(%d)) = %d", gr, got)
+		}
+	}
+}
@@ -0,0 +1,269 @@
+package channelserver
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	cfg "erupe-ce/config"
+
+	"go.uber.org/zap"
+)
+
+// journalFlagNew marks a journaled save as belonging to a new character.
+const journalFlagNew = 0x01
+
+// SaveDataCache keeps the decompressed savedata of online characters in
+// memory so handlers do not reload and recompress the full blob on every
+// save. Saves replace the cached copy and mark it dirty; dirty entries are
+// written to the database by Flush, periodically and at critical points such
+// as logout. Each dirty entry is journaled to disk first so saves that were
+// not yet flushed survive a crash.
+//
+// A single cache is shared by every channel server so a character switching
+// channels always sees its latest save.
+type SaveDataCache struct {
+	mu         sync.Mutex
+	entries    map[uint32]*saveCacheEntry
+	flushMu    sync.Mutex // Serializes writes to the database
+	charRepo   CharacterRepo
+	journalDir string
+	logger     *zap.Logger
+}
+
+type saveCacheEntry struct {
+	save  *CharacterSaveData
+	dirty bool
+}
+
+// NewSaveDataCache creates an empty SaveDataCache that persists through
+// charRepo and journals to journalDir, or does not journal if it is empty.
+func NewSaveDataCache(charRepo CharacterRepo, journalDir string, logger *zap.Logger) *SaveDataCache {
+	return &SaveDataCache{
+		entries:    make(map[uint32]*saveCacheEntry),
+		charRepo:   charRepo,
+		journalDir: journalDir,
+		logger:     logger,
+	}
+}
+
+// Get returns a copy of the cached save for charID.
+func (c *SaveDataCache) Get(charID uint32) (*CharacterSaveData, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[charID]
+	if !ok {
+		return nil, false
+	}
+	return e.save.clone(), true
+}
+
+// Load caches a save just read from the database, unless a newer copy is
+// already cached.
+func (c *SaveDataCache) Load(save *CharacterSaveData) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.entries[save.CharID]; !ok {
+		c.entries[save.CharID] = &saveCacheEntry{save: save.clone()}
+	}
+}
+
+// Put replaces the cached save and marks it dirty, journaling it first.
+func (c *SaveDataCache) Put(save *CharacterSaveData) error {
+	stored := save.clone()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	err := c.writeJournal(stored)
+	c.entries[save.CharID] = &saveCacheEntry{save: stored, dirty: true}
+	return err
+}
+
+// Flush writes the save of charID to the database if it is dirty.
+func (c *SaveDataCache) Flush(charID uint32) error {
+	c.flushMu.Lock()
+	defer c.flushMu.Unlock()
+	return c.flushLocked(charID)
+}
+
+// FlushAll writes every dirty save to the database.
+func (c *SaveDataCache) FlushAll() error {
+	c.flushMu.Lock()
+	defer c.flushMu.Unlock()
+	c.mu.Lock()
+	var dirty []uint32
+	for charID, e := range c.entries {
+		if e.dirty {
+			dirty = append(dirty, charID)
+		}
+	}
+	c.mu.Unlock()
+
+	var errs []error
+	for _, charID := range dirty {
+		errs = append(errs, c.flushLocked(charID))
+	}
+	return errors.Join(errs...)
+}
+
+// Release flushes the save of charID and drops it from the cache. If the
+// flush fails the save stays cached so a later flush can retry it.
+func (c *SaveDataCache) Release(charID uint32) error {
+	c.flushMu.Lock()
+	defer c.flushMu.Unlock()
+	if err := c.flushLocked(charID); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	if e, ok := c.entries[charID]; ok && !e.dirty {
+		delete(c.entries, charID)
+	}
+	c.mu.Unlock()
+	return nil
+}
+
+// flushLocked persists a dirty entry. The caller must hold flushMu. Stored
+// saves are never modified, so the entry is written without holding mu.
+func (c *SaveDataCache) flushLocked(charID uint32) error {
+	c.mu.Lock()
+	e, ok := c.entries[charID]
+	if !ok || !e.dirty {
+		c.mu.Unlock()
+		return nil
+	}
+	save := e.save
+	c.mu.Unlock()
+
+	if err := save.persist(c.charRepo); err != nil {
+		return fmt.Errorf("flush savedata for char %d: %w", charID, err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	// Only mark clean if no newer save arrived during the write.
+	if e, ok := c.entries[charID]; ok && e.save == save {
+		e.dirty = false
+		c.removeJournal(charID)
+	}
+	return nil
+}
+
+// Start flushes dirty saves every interval until the returned func is called.
+func (c *SaveDataCache) Start(interval time.Duration) func() {
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if err := c.FlushAll(); err != nil {
+					c.logger.Error("Failed to flush cached savedata", zap.Error(err))
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() { close(done) }
+}
+
+func (c *SaveDataCache) journalPath(charID uint32) string {
+	return filepath.Join(c.journalDir, fmt.Sprintf("%d.bin", charID))
+}
+
+// writeJournal atomically writes save to its journal file. The caller must
+// hold mu.
+func (c *SaveDataCache) writeJournal(save *CharacterSaveData) error {
+	if c.journalDir == "" {
+		return nil
+	}
+	if err := os.MkdirAll(c.journalDir, 0755); err != nil {
+		return err
+	}
+	var flags byte
+	if save.IsNewCharacter {
+		flags |= journalFlagNew
+	}
+	path := c.journalPath(save.CharID)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, append([]byte{flags}, save.decompSave...), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// removeJournal deletes the journal file of charID. The caller must hold mu.
+func (c *SaveDataCache) removeJournal(charID uint32) {
+	if c.journalDir == "" {
+		return
+	}
+	if err := os.Remove(c.journalPath(charID)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		c.logger.Warn("Failed to remove savedata journal", zap.Uint32("charID", charID), zap.Error(err))
+	}
+}
+
+// ReplayJournal writes saves left in the journal by an unclean shutdown to
+// the database. It must run before any channel server accepts players.
+func (c *SaveDataCache) ReplayJournal(mode cfg.Mode) error {
+	if c.journalDir == "" {
+		return nil
+	}
+	files, err := os.ReadDir(c.journalDir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	var errs []error
+	for _, f := range files {
+		name, ok := strings.CutSuffix(f.Name(), ".bin")
+		if !ok {
+			continue
+		}
+		charID, err := strconv.ParseUint(name, 10, 32)
+		if err != nil {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(c.journalDir, f.Name()))
+		if err != nil || len(data) < 2 {
+			errs = append(errs, fmt.Errorf("read savedata journal %s: %w", f.Name(), err))
+			continue
+		}
+		save := &CharacterSaveData{
+			CharID:         uint32(charID),
+			IsNewCharacter: data[0]&journalFlagNew != 0,
+			Mode:           mode,
+			decompSave:     data[1:],
+		}
+		save.updateStructWithSaveData()
+		if err := save.persist(c.charRepo); err != nil {
+			errs = append(errs, fmt.Errorf("replay savedata journal for char %d: %w", charID, err))
+			continue
+		}
+		c.removeJournal(uint32(charID))
+		c.logger.Info("Recovered journaled savedata", zap.Uint64("charID", charID))
+	}
+	return errors.Join(errs...)
+}
+
+// clone returns a deep copy of save that shares no byte slices with it.
+func (save *CharacterSaveData) clone() *CharacterSaveData {
+	c := *save
+	c.compSave = nil
+	c.decompSave = bytes.Clone(save.decompSave)
+	c.HouseTier = bytes.Clone(save.HouseTier)
+	c.HouseData = bytes.Clone(save.HouseData)
+	c.BookshelfData = bytes.Clone(save.BookshelfData)
+	c.GalleryData = bytes.Clone(save.GalleryData)
+	c.ToreData = bytes.Clone(save.ToreData)
+	c.GardenData = bytes.Clone(save.GardenData)
+	c.KQF = bytes.Clone(save.KQF)
+	return &c
+}
@@ -0,0 +1,187 @@
+package channelserver
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	cfg "erupe-ce/config"
+
+	"go.uber.org/zap"
+)
+
+func newTestCachedSave(charID uint32) *CharacterSaveData {
+	return &CharacterSaveData{
+		CharID:     charID,
+		Mode:       cfg.ZZ,
+		decompSave: make([]byte, 150000),
+		HouseData:  []byte{0x01},
+	}
+}
+
+func TestSaveDataCache_GetMiss(t *testing.T) {
+	c := NewSaveDataCache(newMockCharacterRepo(), "", zap.NewNop())
+	if _, ok := c.Get(1); ok {
+		t.Error("expected miss for unknown charID")
+	}
+}
+
+func TestSaveDataCache_GetReturnsCopy(t *testing.T) {
+	c := NewSaveDataCache(newMockCharacterRepo(), "", zap.NewNop())
+	if err := c.Put(newTestCachedSave(1)); err != nil {
+		t.Fatal(err)
+	}
+
+	got, ok := c.Get(1)
+	if !ok {
+		t.Fatal("expected hit")
+	}
+	got.decompSave[0] = 0xFF
+	got.HouseData[0] = 0xFF
+
+	again, _ := c.Get(1)
+	if again.decompSave[0] != 0 || again.HouseData[0] != 0x01 {
+		t.Error("modifying a returned save should not change the cache")
+	}
+}
+
+func TestSaveDataCache_LoadKeepsNewerSave(t *testing.T) {
+	c := NewSaveDataCache(newMockCharacterRepo(), "", zap.NewNop())
+	save := newTestCachedSave(1)
+	save.HR = 5
+	_ = c.Put(save)
+
+	stale := newTestCachedSave(1)
+	stale.HR = 1
+	c.Load(stale)
+
+	got, _ := c.Get(1)
+	if got.HR != 5 {
+		t.Errorf("HR = %d, want 5", got.HR)
+	}
+}
+
+func TestSaveDataCache_WriteBehind(t *testing.T) {
+	repo := newMockCharacterRepo()
+	c := NewSaveDataCache(repo, "", zap.NewNop())
+
+	_ = c.Put(newTestCachedSave(1))
+	_ = c.Put(newTestCachedSave(1))
+	if repo.saveCharacterDataCalls != 0 {
+		t.Fatalf("Put wrote to the database %d times, want 0", repo.saveCharacterDataCalls)
+	}
+
+	if err := c.FlushAll(); err != nil {
+		t.Fatal(err)
+	}
+	if repo.saveCharacterDataCalls != 1 {
+		t.Errorf("flush wrote %d times, want 1", repo.saveCharacterDataCalls)
+	}
+	if len(repo.saveCharacterDataData) == 0 || len(repo.saveCharacterDataData) >= 150000 {
+		t.Errorf("expected compressed savedata, got %d bytes", len(repo.saveCharacterDataData))
+	}
+
+	// Clean entries are not written again.
+	_ = c.Flush(1)
+	if repo.saveCharacterDataCalls != 1 {
+		t.Errorf("clean flush wrote %d times, want 1", repo.saveCharacterDataCalls)
+	}
+}
+
+func TestSaveDataCache_FlushErrorKeepsDirty(t *testing.T) {
+	repo := newMockCharacterRepo()
+	repo.saveCharacterDataErr = errors.New("db down")
+	c := NewSaveDataCache(repo, "", zap.NewNop())
+	_ = c.Put(newTestCachedSave(1))
+
+	if err := c.Release(1); err == nil {
+		t.Fatal("expected flush error")
+	}
+	if _, ok := c.Get(1); !ok {
+		t.Fatal("failed release should keep the save cached")
+	}
+
+	repo.saveCharacterDataErr = nil
+	if err := c.Release(1); err != nil {
+		t.Fatal(err)
+	}
+	if repo.saveCharacterDataCalls != 1 {
+		t.Errorf("retry wrote %d times, want 1", repo.saveCharacterDataCalls)
+	}
+	if _, ok := c.Get(1); ok {
+		t.Error("release should evict the save")
+	}
+}
+
+func TestSaveDataCache_Journal(t *testing.T) {
+	dir := t.TempDir()
+	repo := newMockCharacterRepo()
+	c := NewSaveDataCache(repo, dir, zap.NewNop())
+
+	save := newTestCachedSave(7)
+	save.decompSave[100] = 0xAB
+	if err := c.Put(save); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "7.bin")
+	if _, err := os.Stat(path); err != nil {
+		t.Fatalf("expected journal file: %v", err)
+	}
+
+	if err := c.Flush(7); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Error("flush should remove the journal file")
+	}
+}
+
+func TestSaveDataCache_ReplayJournal(t *testing.T) {
+	dir := t.TempDir()
+
+	// A save journaled by a server that crashed before flushing.
+	crashed := NewSaveDataCache(newMockCharacterRepo(), dir, zap.NewNop())
+	_ = crashed.Put(newTestCachedSave(7))
+
+	repo := newMockCharacterRepo()
+	c := NewSaveDataCache(repo, dir, zap.NewNop())
+	if err := c.ReplayJournal(cfg.ZZ); err != nil {
+		t.Fatal(err)
+	}
+	if repo.saveCharacterDataCalls != 1 {
+		t.Errorf("replay wrote %d times, want 1", repo.saveCharacterDataCalls)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "7.bin")); !os.IsNotExist(err) {
+		t.Error("replay should remove the journal file")
+	}
+}
+
+func TestCharacterSaveData_Save_Cached(t *testing.T) {
+	server := createMockServer()
+	repo := newMockCharacterRepo()
+	server.charRepo = repo
+	server.saveCache = NewSaveDataCache(repo, "", zap.NewNop())
+	s := createMockSession(1, server)
+
+	save := newTestCachedSave(1)
+	save.HR = 42
+	save.Save(s)
+	if repo.saveCharacterDataCalls != 0 {
+		t.Fatalf("cached save wrote to the database %d times, want 0", repo.saveCharacterDataCalls)
+	}
+
+	got, err := GetCharacterSaveData(s, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.HR != 42 {
+		t.Errorf("HR = %d, want 42", got.HR)
+	}
+
+	// Saves of other characters bypass the cache.
+	newTestCachedSave(2).Save(s)
+	if repo.saveCharacterDataCalls != 1 {
+		t.Errorf("uncached save wrote %d times, want 1", repo.saveCharacterDataCalls)
+	}
+}
@@ -0,0 +1,101 @@
+package channelserver
+
+import (
+	"erupe-ce/common/mhfitem"
+	"erupe-ce/common/stringsupport"
+	cfg "erupe-ce/config"
+	"erupe-ce/server/channelserver/compression/nullcomp"
+	"erupe-ce/server/channelserver/savedata"
+)
+
+// SaveFixture describes a character save to generate for tests and tools.
+// Only the fields the server reads for the mode are written, through the
+// mode's savedata layout; the rest of the blob is zero. Modes without a
+// known layout (S1 to S5.5 and S7 to F3) get the name and gender only.
+type SaveFixture struct {
+	Mode       cfg.Mode // Defaults to ZZ
+	Name       string
+	Female     bool
+	IsNew      bool   // Stored in the characters row; the server skips parsing new saves
+	HR         uint16 // Ignored when GR is set
+	GR         uint16 // G1 onward; stored as HR 999 plus the matching GRP
+	RP         uint16
+	WeaponType uint8
+	WeaponID   uint16
+	Playtime   uint32
+	HouseTier  []byte // Up to 5 bytes
+	KQF        []byte // Up to 8 bytes, G10 onward
+
+	// ItemBox is not part of the save; CreateTestCharacterWithSave stores it
+	// in the character's warehouse.
+	ItemBox []mhfitem.MHFItemStack
+}
+
+// mode returns the fixture's mode, defaulting to ZZ.
+func (f SaveFixture) mode() cfg.Mode {
+	if f.Mode == 0 {
+		return cfg.ZZ
+	}
+	return f.Mode
+}
+
+// fixtureSaveSize returns the size of a generated save for a layout: the end
+// of its last field plus some slack, rounded up to a thousand bytes. For ZZ
+// this is 150000.
+func fixtureSaveSize(l savedata.Layout) int {
+	return (l.End() + 3000 + 999) / 1000 * 1000
+}
+
+// Build returns the decompressed save.
+func (f SaveFixture) Build() []byte {
+	mode := f.mode()
+	l := savedata.LayoutFor(mode)
+	save := make([]byte, fixtureSaveSize(l))
+	sd := savedata.New(save, mode)
+
+	name := stringsupport.UTF8ToSJIS(f.Name)
+	if n := l.Size(savedata.FieldName) - 1; len(name) > n {
+		name = name[:n]
+	}
+	copy(sd.Raw(savedata.FieldName), name)
+	_ = sd.SetFemale(f.Female)
+
+	if !l.Known() {
+		return save
+	}
+	_ = sd.SetRP(f.RP)
+	_ = sd.SetHouseTier(f.HouseTier)
+	_ = sd.SetPlaytime(f.Playtime)
+	_ = sd.SetWeaponType(f.WeaponType)
+	_ = sd.SetWeaponID(f.WeaponID)
+	_ = sd.SetHR(f.HR)
+	if mode >= cfg.G1 && f.GR > 0 {
+		_ = sd.SetGR(f.GR)
+	}
+	if mode >= cfg.G10 {
+		_ = sd.SetKQF(f.KQF)
+	}
+	return save
+}
+
+// Compressed returns the save as it is stored in characters.savedata.
+func (f SaveFixture) Compressed() ([]byte, error) {
+	return nullcomp.Compress(f.Build())
+}
+
+// ItemBoxData returns the item box serialized as it is stored in the
+// warehouse, or nil when the fixture has none.
+func (f SaveFixture) ItemBoxData() []byte {
+	if len(f.ItemBox) == 0 {
+		return nil
+	}
+	return mhfitem.SerializeWarehouseItems(f.ItemBox)
+}
+
+// ranks returns the values stored in the characters hr and gr columns.
+func (f SaveFixture) ranks() (hr, gr uint16) {
+	if f.mode() >= cfg.G1 && f.GR > 0 {
+		return 999, f.GR
+	}
+	return f.HR, 0
+}
@@ -0,0 +1,128 @@
+package channelserver
+
+import (
+	"bytes"
+	"testing"
+
+	"erupe-ce/common/byteframe"
+	"erupe-ce/common/mhfitem"
+	cfg "erupe-ce/config"
+	"erupe-ce/server/channelserver/compression/nullcomp"
+	"erupe-ce/server/channelserver/savedata"
+)
+
+// parseFixture runs a generated save through the server's save parsing.
+func parseFixture(t *testing.T, f SaveFixture) *CharacterSaveData {
+	t.Helper()
+	comp, err := f.Compressed()
+	if err != nil {
+		t.Fatalf("Compressed: %v", err)
+	}
+	save := &CharacterSaveData{Mode: f.mode(), compSave: comp}
+	if err := save.Decompress(); err != nil {
+		t.Fatalf("Decompress: %v", err)
+	}
+	save.updateStructWithSaveData()
+	return save
+}
+
+func TestSaveFixture_EveryMode(t *testing.T) {
+	for mode := cfg.S1; mode <= cfg.ZZ; mode++ {
+		f := SaveFixture{
+			Mode:       mode,
+			Name:       "Fixture",
+			Female:     true,
+			HR:         7,
+			GR:         250,
+			RP:         1234,
+			WeaponType: 9,
+			WeaponID:   321,
+			Playtime:   86400,
+			HouseTier:  []byte{1, 2, 3, 4, 5},
+			KQF:        []byte{0xFF, 0, 0xFF, 0, 0xFF, 0, 0xFF, 0},
+		}
+		save := parseFixture(t, f)
+
+		if save.Name != f.Name || !save.Gender {
+			t.Errorf("mode %d: name %q gender %v, want %q true", mode, save.Name, save.Gender, f.Name)
+		}
+		if !savedata.LayoutFor(mode).Known() {
+			continue
+		}
+		if save.RP != f.RP || save.WeaponType != f.WeaponType || save.WeaponID != f.WeaponID || save.Playtime != f.Playtime {
+			t.Errorf("mode %d: RP %d weapon %d/%d playtime %d", mode, save.RP, save.WeaponType, save.WeaponID, save.Playtime)
+		}
+		if !bytes.Equal(save.HouseTier, f.HouseTier) {
+			t.Errorf("mode %d: house tier % X, want % X", mode, save.HouseTier, f.HouseTier)
+		}
+		wantHR, wantGR := f.HR, uint16(0)
+		if mode >= cfg.G1 {
+			wantHR, wantGR = 999, f.GR
+		}
+		if save.HR != wantHR || save.GR != wantGR {
+			t.Errorf("mode %d: HR %d GR %d, want %d %d", mode, save.HR, save.GR, wantHR, wantGR)
+		}
+		if mode >= cfg.G10 && !bytes.Equal(save.KQF, f.KQF) {
+			t.Errorf("mode %d: KQF % X, want % X", mode, save.KQF, f.KQF)
+		}
+	}
+}
+
+func TestSaveFixture_Defaults(t *testing.T) {
+	data := SaveFixture{Name: "Hunter"}.Build()
+	if len(data) != 150000 {
+		t.Errorf("ZZ save is %d bytes, want 150000", len(data))
+	}
+	save := parseFixture(t, SaveFixture{Name: "Hunter"})
+	if save.Name != "Hunter" || save.Gender || save.HR != 0 || save.GR != 0 {
+		t.Errorf("got %+v", save)
+	}
+}
+
+func TestSaveFixture_LongNameTruncated(t *testing.T) {
+	data := SaveFixture{Name: "ABCDEFGHIJKLMNOP"}.Build()
+	if got := savedata.New(data, cfg.ZZ).Raw(savedata.FieldName)[11]; got != 0 {
+		t.Errorf("name is not null terminated, last byte %X", got)
+	}
+}
+
+func TestSaveFixture_CompressedRoundTrip(t *testing.T) {
+	f := SaveFixture{Mode: cfg.G5, Name: "Round", HR: 50}
+	comp, err := f.Compressed()
+	if err != nil {
+		t.Fatal(err)
+	}
+	decomp, err := nullcomp.Decompress(comp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(decomp, f.Build()) {
+		t.Error("decompressed save differs from Build")
+	}
+}
+
+func TestSaveFixture_ItemBoxData(t *testing.T) {
+	if (SaveFixture{}).ItemBoxData() != nil {
+		t.Error("empty item box should serialize to nil")
+	}
+	f := SaveFixture{ItemBox: []mhfitem.MHFItemStack{
+		{WarehouseID: 1, Item: mhfitem.MHFItem{ItemID: 7}, Quantity: 10},
+		{WarehouseID: 2, Item: mhfitem.MHFItem{ItemID: 8}, Quantity: 99},
+	}}
+	bf := byteframe.NewByteFrameFromBytes(f.ItemBoxData())
+	if n := bf.ReadUint16(); n != 2 {
+		t.Fatalf("count = %d, want 2", n)
+	}
+	bf.ReadUint16()
+	for _, want := range f.ItemBox {
+		if got := mhfitem.ReadWarehouseItem(bf); got != want {
+			t.Errorf("item = %+v, want %+v", got, want)
+		}
+	}
+}
+
+// fieldOffset returns where a field is in a mode's save.
+func fieldOffset(mode cfg.Mode, f savedata.Field) int {
+	off, _ := savedata.LayoutFor(mode).Offset(f)
+	return off
+}
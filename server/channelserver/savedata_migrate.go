@@ -0,0 +1,122 @@
+package channelserver
+
+import (
+	"errors"
+	"fmt"
+
+	cfg "erupe-ce/config"
+	"erupe-ce/server/channelserver/compression/nullcomp"
+	"erupe-ce/server/channelserver/savedata"
+)
+
+// ErrSaveLayoutUnknown is returned when a save cannot be migrated because the
+// layout of one of the modes involved is not known.
+var ErrSaveLayoutUnknown = errors.New("savedata layout unknown for client mode")
+
+// saveLayout is a range of client modes sharing a savedata layout.
+type saveLayout struct {
+	first, last cfg.Mode
+}
+
+// saveLayouts lists the layouts saves can be migrated between, oldest first.
+var saveLayouts = []saveLayout{
+	{cfg.S6, cfg.S6},
+	{cfg.F4, cfg.F5},
+	{cfg.G1, cfg.Z2},
+	{cfg.ZZ, cfg.ZZ},
+}
+
+// saveBlock is the block of data a layout inserts over the one before it.
+// Everything from insertAt in the older layout moves up by size in the newer
+// one. insertAt is the first field known to move, which may be after where
+// the client really inserts the block; bytes in between stay put.
+type saveBlock struct {
+	insertAt int
+	size     int
+}
+
+// saveBlocks[i] upgrades saveLayouts[i] to saveLayouts[i+1].
+var saveBlocks = []saveBlock{
+	{insertAt: 9118, size: 48000},  // S6 to F: from the bookshelf
+	{insertAt: 57118, size: 32000}, // F to G: from the bookshelf
+	{insertAt: 92356, size: 36000}, // G to Z: from the playtime, as the G bookshelf pointer is unreliable
+}
+
+// saveLayoutIndex returns the index in saveLayouts of the layout mode uses.
+func saveLayoutIndex(mode cfg.Mode) (int, bool) {
+	for i, l := range saveLayouts {
+		if mode >= l.first && mode <= l.last {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// MigrateSaveData converts a decompressed save written by a from client into
+// the layout a to client expects, one adjacent layout at a time. Inserted
+// blocks are zero filled and removed blocks are dropped, and the GRP and key
+// quest flags start empty for a mode that did not have them. The bookshelf
+// moves with the data around it, as its pointers before ZZ are not known to
+// be right. data is not modified.
+func MigrateSaveData(data []byte, from, to cfg.Mode) ([]byte, error) {
+	fromIdx, ok := saveLayoutIndex(from)
+	if !ok {
+		return nil, fmt.Errorf("%w: %d", ErrSaveLayoutUnknown, from)
+	}
+	toIdx, ok := saveLayoutIndex(to)
+	if !ok {
+		return nil, fmt.Errorf("%w: %d", ErrSaveLayoutUnknown, to)
+	}
+	if end := savedata.LayoutFor(from).End(); len(data) < end {
+		return nil, fmt.Errorf("savedata is %d bytes, a mode %d save is at least %d", len(data), from, end)
+	}
+
+	out := append([]byte(nil), data...)
+	for i := fromIdx; i < toIdx; i++ {
+		b := saveBlocks[i]
+		grown := make([]byte, len(out)+b.size)
+		copy(grown, out[:b.insertAt])
+		copy(grown[b.insertAt+b.size:], out[b.insertAt:])
+		out = grown
+	}
+	for i := fromIdx; i > toIdx; i-- {
+		b := saveBlocks[i-1]
+		shrunk := make([]byte, len(out)-b.size)
+		copy(shrunk, out[:b.insertAt])
+		copy(shrunk[b.insertAt:], out[b.insertAt+b.size:])
+		out = shrunk
+	}
+
+	if end := savedata.LayoutFor(to).End(); len(out) < end {
+		out = append(out, make([]byte, end-len(out))...)
+	}
+	dst := savedata.New(out, to)
+	if to >= cfg.G1 && from < cfg.G1 {
+		clear(dst.Raw(savedata.FieldGRP))
+	}
+	if to >= cfg.G10 && from < cfg.G10 {
+		clear(dst.Raw(savedata.FieldKQF))
+	}
+	return out, nil
+}
+
+// migrateStoredSave converts savedata as stored in the characters table from
+// the mode it was written in to the server's, returning it unchanged when no
+// migration is needed. Saves before G1 are stored uncompressed.
+func migrateStoredSave(stored []byte, from, to cfg.Mode) ([]byte, error) {
+	if from == 0 || from == to || len(stored) == 0 {
+		return stored, nil
+	}
+	decomp := stored
+	if from >= cfg.G1 {
+		var err error
+		if decomp, err = nullcomp.Decompress(stored); err != nil {
+			return nil, fmt.Errorf("decompress savedata: %w", err)
+		}
+	}
+	migrated, err := MigrateSaveData(decomp, from, to)
+	if err != nil {
+		return nil, err
+	}
+	return encodeStoredSave(migrated, to)
+}
@@ -0,0 +1,187 @@
+package channelserver
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"testing"
+
+	cfg "erupe-ce/config"
+	"erupe-ce/network/mhfpacket"
+	"erupe-ce/server/channelserver/compression/nullcomp"
+	"erupe-ce/server/channelserver/savedata"
+)
+
+// TestSaveBlocks_MatchLayouts checks every migration step against the
+// offsets the savedata layouts give the two modes.
+func TestSaveBlocks_MatchLayouts(t *testing.T) {
+	for i, b := range saveBlocks {
+		lower, upper := savedata.LayoutFor(saveLayouts[i].first), savedata.LayoutFor(saveLayouts[i+1].first)
+		for _, f := range savedata.Fields(saveLayouts[i].first) {
+			if f.Field == savedata.FieldGender {
+				continue
+			}
+			off, _ := lower.Offset(f.Field)
+			if f.Field == savedata.FieldBookshelf && upper.Mode == cfg.ZZ {
+				continue
+			}
+			want := off
+			if off >= b.insertAt {
+				want += b.size
+			}
+			if got, _ := upper.Offset(f.Field); got != want {
+				t.Errorf("step %d: %s at %d moves to %d, layout has %d", i, f.Name, off, want, got)
+			}
+		}
+	}
+}
+
+func TestMigrateSaveData_Fields(t *testing.T) {
+	modes := []cfg.Mode{cfg.S6, cfg.F5, cfg.G1, cfg.G5, cfg.G10, cfg.Z2, cfg.ZZ}
+	for _, from := range modes {
+		for _, to := range modes {
+			t.Run(fmt.Sprintf("%d_to_%d", from, to), func(t *testing.T) {
+				f := SaveFixture{
+					Mode:       from,
+					Name:       "Migrant",
+					Female:     true,
+					HR:         500,
+					GR:         300,
+					RP:         77,
+					WeaponType: 3,
+					WeaponID:   1234,
+					Playtime:   3600,
+					HouseTier:  []byte{1, 2, 3, 4, 5},
+					KQF:        []byte{1, 2, 3, 4, 5, 6, 7, 8},
+				}
+				out, err := MigrateSaveData(f.Build(), from, to)
+				if err != nil {
+					t.Fatal(err)
+				}
+				save := &CharacterSaveData{Mode: to, decompSave: out}
+				save.updateStructWithSaveData()
+
+				if save.Name != f.Name || !save.Gender || save.RP != f.RP || save.Playtime != f.Playtime ||
+					save.WeaponType != f.WeaponType || save.WeaponID != f.WeaponID || !bytes.Equal(save.HouseTier, f.HouseTier) {
+					t.Errorf("fields not carried over: %+v", save)
+				}
+				wantHR, wantGR := f.HR, uint16(0)
+				if from >= cfg.G1 {
+					wantHR = 999
+					if to >= cfg.G1 {
+						wantGR = f.GR
+					}
+				}
+				if save.HR != wantHR || save.GR != wantGR {
+					t.Errorf("HR %d GR %d, want %d %d", save.HR, save.GR, wantHR, wantGR)
+				}
+				if to >= cfg.G10 {
+					want := make([]byte, savedata.LayoutFor(to).Size(savedata.FieldKQF))
+					if from >= cfg.G10 {
+						want = f.KQF
+					}
+					if !bytes.Equal(save.KQF, want) {
+						t.Errorf("KQF % X, want % X", save.KQF, want)
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestMigrateSaveData_Sizes(t *testing.T) {
+	zz := SaveFixture{Name: "Size"}.Build()
+	g, err := MigrateSaveData(zz, cfg.ZZ, cfg.G10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(g) != len(zz)-36000 {
+		t.Errorf("ZZ to G is %d bytes, want %d", len(g), len(zz)-36000)
+	}
+	back, err := MigrateSaveData(g, cfg.G10, cfg.ZZ)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(back, zz) {
+		t.Error("ZZ to G10 and back changed the save")
+	}
+}
+
+func TestMigrateSaveData_Errors(t *testing.T) {
+	zz := SaveFixture{Name: "Err"}.Build()
+	if _, err := MigrateSaveData(zz, cfg.ZZ, cfg.S5); !errors.Is(err, ErrSaveLayoutUnknown) {
+		t.Errorf("to S5: err = %v, want ErrSaveLayoutUnknown", err)
+	}
+	if _, err := MigrateSaveData(zz, cfg.F2, cfg.ZZ); !errors.Is(err, ErrSaveLayoutUnknown) {
+		t.Errorf("from F2: err = %v, want ErrSaveLayoutUnknown", err)
+	}
+	if _, err := MigrateSaveData(zz[:1000], cfg.ZZ, cfg.G10); err == nil {
+		t.Error("a truncated save migrated")
+	}
+}
+
+func TestMigrateStoredSave_Compression(t *testing.T) {
+	f5 := SaveFixture{Mode: cfg.F5, Name: "Raw", HR: 200}.Build()
+
+	// Saves before G1 are stored raw, later ones compressed.
+	stored, err := migrateStoredSave(f5, cfg.F5, cfg.ZZ)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zz, err := nullcomp.Decompress(stored)
+	if err != nil {
+		t.Fatal(err)
+	}
+	back, err := migrateStoredSave(stored, cfg.ZZ, cfg.F5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(back) != len(zz)-36000-32000 {
+		t.Errorf("ZZ to F5 stored %d bytes, want a raw %d", len(back), len(zz)-68000)
+	}
+
+	for _, from := range []cfg.Mode{0, cfg.ZZ} {
+		if got, err := migrateStoredSave(stored, from, cfg.ZZ); err != nil || &got[0] != &stored[0] {
+			t.Errorf("from %d: save was not passed through unchanged", from)
+		}
+	}
+}
+
+func TestHandleMsgMhfLoaddata_MigratesSave(t *testing.T) {
+	stored, err := SaveFixture{Mode: cfg.G10, Name: "Old", HR: 999, GR: 100}.Compressed()
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := newHandlerHarness(t)
+	h.Server.erupeConfig.RealClientMode = cfg.ZZ
+	h.Server.userBinary = NewUserBinaryStore()
+	repo := newMockCharacterRepo()
+	repo.columns["savedata"] = stored
+	repo.ints["savedata_mode"] = int(cfg.G10)
+	h.Server.charRepo = repo
+
+	h.Handle(&mhfpacket.MsgMhfLoaddata{AckHandle: 1})
+	data, err := nullcomp.Decompress(h.Next().ExpectAck(1).ExpectSuccess().AckData().Data())
+	if err != nil {
+		t.Fatal(err)
+	}
+	save := &CharacterSaveData{Mode: cfg.ZZ, decompSave: data}
+	save.updateStructWithSaveData()
+	if save.Name != "Old" || save.GR != 100 {
+		t.Errorf("client got name %q GR %d, want Old 100", save.Name, save.GR)
+	}
+	if h.Session.Name != "Old" {
+		t.Errorf("session name = %q", h.Session.Name)
+	}
+}
+
+func TestCharacterSaveData_PersistRecordsMode(t *testing.T) {
+	repo := newMockCharacterRepo()
+	save := &CharacterSaveData{CharID: 1, Mode: cfg.G10, decompSave: SaveFixture{Mode: cfg.G10}.Build()}
+	if err := save.persist(repo); err != nil {
+		t.Fatal(err)
+	}
+	if repo.saveCharacterDataMode != cfg.G10 {
+		t.Errorf("recorded mode %d, want %d", repo.saveCharacterDataMode, cfg.G10)
+	}
+}
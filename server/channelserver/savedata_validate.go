@@ -0,0 +1,182 @@
+package channelserver
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"hash/crc32"
+
+	cfg "erupe-ce/config"
+	"erupe-ce/server/channelserver/compression/nullcomp"
+	"erupe-ce/server/channelserver/savedata"
+	"erupe-ce/server/savedump"
+
+	"go.uber.org/zap"
+)
+
+// ErrSaveCorrupt is returned when savedata fails validation.
+var ErrSaveCorrupt = errors.New("savedata corrupt")
+
+// nullcompMagic is the header of nullcomp compressed data, which every save
+// stored from G1 on starts with.
+var nullcompMagic = []byte("cmp\x2020110113\x20\x20\x20\x00")
+
+// maxWeaponType is the highest weapon type the client uses.
+const maxWeaponType = 13
+
+// saveChecksum returns the checksum recorded alongside stored savedata.
+func saveChecksum(stored []byte) uint32 {
+	return crc32.ChecksumIEEE(stored)
+}
+
+// ValidateSaveData checks a decompressed save against the layout of mode. It
+// must be long enough for every field the server reads, have a null
+// terminated name, and hold plausible values in the fields where the client
+// only uses a few.
+func ValidateSaveData(data []byte, mode cfg.Mode) error {
+	sd := savedata.New(data, mode)
+	if end := sd.Layout().End(); len(data) < end {
+		return fmt.Errorf("%w: %d bytes, a mode %d save is at least %d", ErrSaveCorrupt, len(data), mode, end)
+	}
+	if bytes.IndexByte(sd.Raw(savedata.FieldName), 0) < 0 {
+		return fmt.Errorf("%w: name is not terminated", ErrSaveCorrupt)
+	}
+	if g := sd.Raw(savedata.FieldGender)[0]; g > 1 {
+		return fmt.Errorf("%w: gender %d", ErrSaveCorrupt, g)
+	}
+	if !sd.Layout().Known() {
+		return nil
+	}
+	if wt := sd.WeaponType(); wt > maxWeaponType {
+		return fmt.Errorf("%w: weapon type %d", ErrSaveCorrupt, wt)
+	}
+	if hr := sd.HR(); hr > 999 {
+		return fmt.Errorf("%w: HR %d", ErrSaveCorrupt, hr)
+	}
+	return nil
+}
+
+// validateStoredSave checks savedata as stored in the characters table for
+// mode, where saves from G1 on are compressed.
+func validateStoredSave(stored []byte, mode cfg.Mode) error {
+	if mode >= cfg.G1 && !bytes.HasPrefix(stored, nullcompMagic) {
+		return fmt.Errorf("%w: missing compression header", ErrSaveCorrupt)
+	}
+	data, err := nullcomp.Decompress(stored)
+	if err != nil {
+		return fmt.Errorf("%w: decompress: %v", ErrSaveCorrupt, err)
+	}
+	return ValidateSaveData(data, mode)
+}
+
+// encodeStoredSave converts a decompressed save to the form stored in the
+// characters table. Saves before G1 were not compressed.
+func encodeStoredSave(data []byte, mode cfg.Mode) ([]byte, error) {
+	if mode < cfg.G1 {
+		return data, nil
+	}
+	comp, err := nullcomp.Compress(data)
+	if err != nil {
+		return nil, fmt.Errorf("compress savedata: %w", err)
+	}
+	return comp, nil
+}
+
+// backupSaveData dumps a validated save to SaveDumps, so it can be restored
+// if the stored save is later found corrupt.
+func backupSaveData(s *Session, save *CharacterSaveData) {
+	if !s.server.saveDumps.Enabled() {
+		return
+	}
+	comp, err := nullcomp.Compress(save.decompSave)
+	if err != nil {
+		s.logger.Error("Failed to compress savedata backup", zap.Error(err))
+		return
+	}
+	dumpSaveData(s, comp, savedump.KindSavedata)
+}
+
+// restoreSaveBackup reads and validates the newest backup of a character's
+// save, returning it decompressed and migrated to the server's client mode.
+func restoreSaveBackup(s *Session, charID uint32) ([]byte, error) {
+	if !s.server.saveDumps.Enabled() {
+		return nil, errors.New("SaveDumps is disabled")
+	}
+	entry, comp, err := s.server.saveDumps.Latest(charID, savedump.KindSavedata)
+	if err != nil {
+		return nil, err
+	}
+	data, err := nullcomp.Decompress(comp)
+	if err != nil {
+		return nil, fmt.Errorf("decompress backup: %w", err)
+	}
+	mode := s.server.erupeConfig.RealClientMode
+	if entry.Mode != 0 && entry.Mode != mode {
+		if data, err = MigrateSaveData(data, entry.Mode, mode); err != nil {
+			return nil, fmt.Errorf("migrate backup: %w", err)
+		}
+	}
+	if err := ValidateSaveData(data, mode); err != nil {
+		return nil, fmt.Errorf("backup: %w", err)
+	}
+	return data, nil
+}
+
+// loadStoredSave prepares savedata read from the database for the server. It
+// checks the recorded checksum, migrates the save to the server's client mode
+// and validates it. A corrupt save is replaced in the database by the
+// character's SaveDumps backup when that is valid, and the player is told by
+// mail. When there is no usable backup the stored save is returned as is.
+func loadStoredSave(s *Session, charID uint32, stored []byte) []byte {
+	if len(stored) == 0 {
+		return stored
+	}
+	mode := s.server.erupeConfig.RealClientMode
+	err := verifySaveChecksum(s, charID, stored)
+	if err == nil {
+		stored = migrateSaveForServer(s, charID, stored)
+		err = validateStoredSave(stored, mode)
+	}
+	if err == nil {
+		return stored
+	}
+	s.logger.Error("Stored savedata is corrupt", zap.Error(err), zap.Uint32("charID", charID))
+
+	data, err := restoreSaveBackup(s, charID)
+	if err != nil {
+		s.logger.Error("Failed to restore savedata from backup", zap.Error(err), zap.Uint32("charID", charID))
+		return stored
+	}
+	restored := &CharacterSaveData{CharID: charID, Mode: mode, decompSave: data}
+	restored.updateStructWithSaveData()
+	if err := restored.persist(s.server.charRepo); err != nil {
+		s.logger.Error("Failed to write restored savedata", zap.Error(err), zap.Uint32("charID", charID))
+	}
+	comp, err := encodeStoredSave(data, mode)
+	if err != nil {
+		s.logger.Error("Failed to encode restored savedata", zap.Error(err), zap.Uint32("charID", charID))
+		return stored
+	}
+	s.logger.Warn("Restored corrupt savedata from backup", zap.Uint32("charID", charID))
+	if s.server.mailService != nil {
+		t := s.server.i18n.save.restored
+		if err := s.server.mailService.SendSystem(charID, t.title, t.body); err != nil {
+			s.logger.Error("Failed to send save restored mail", zap.Error(err), zap.Uint32("charID", charID))
+		}
+	}
+	return comp
+}
+
+// verifySaveChecksum compares stored savedata with the checksum recorded when
+// it was written. Saves written before checksums were recorded pass.
+func verifySaveChecksum(s *Session, charID uint32, stored []byte) error {
+	want, err := s.server.charRepo.ReadInt(charID, "savedata_checksum")
+	if err != nil {
+		s.logger.Warn("Failed to read savedata checksum", zap.Error(err), zap.Uint32("charID", charID))
+		return nil
+	}
+	if got := saveChecksum(stored); want != 0 && uint32(want) != got {
+		return fmt.Errorf("%w: checksum %08x, recorded %08x", ErrSaveCorrupt, got, uint32(want))
+	}
+	return nil
+}
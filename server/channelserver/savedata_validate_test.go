@@ -0,0 +1,209 @@
+package channelserver
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	cfg "erupe-ce/config"
+	"erupe-ce/network"
+	"erupe-ce/network/mhfpacket"
+	"erupe-ce/server/channelserver/compression/nullcomp"
+	"erupe-ce/server/channelserver/savedata"
+	"erupe-ce/server/savedump"
+)
+
+func TestValidateSaveData(t *testing.T) {
+	for _, mode := range []cfg.Mode{cfg.S6, cfg.F5, cfg.G10, cfg.ZZ} {
+		if err := ValidateSaveData(SaveFixture{Mode: mode, Name: "Valid", HR: 999}.Build(), mode); err != nil {
+			t.Errorf("mode %d: %v", mode, err)
+		}
+	}
+
+	off := func(f savedata.Field) int { return fieldOffset(cfg.ZZ, f) }
+	tests := []struct {
+		name    string
+		corrupt func([]byte) []byte
+	}{
+		{"truncated", func(d []byte) []byte { return d[:off(savedata.FieldKQF)] }},
+		{"unterminated name", func(d []byte) []byte {
+			copy(d[off(savedata.FieldName):], "ABCDEFGHIJKL")
+			return d
+		}},
+		{"gender", func(d []byte) []byte { d[off(savedata.FieldGender)] = 2; return d }},
+		{"weapon type", func(d []byte) []byte { d[off(savedata.FieldWeaponType)] = 14; return d }},
+		{"HR", func(d []byte) []byte { d[off(savedata.FieldHR)], d[off(savedata.FieldHR)+1] = 0xE8, 0x03; return d }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := tt.corrupt(SaveFixture{Name: "Corrupt"}.Build())
+			if err := ValidateSaveData(data, cfg.ZZ); !errors.Is(err, ErrSaveCorrupt) {
+				t.Errorf("err = %v, want ErrSaveCorrupt", err)
+			}
+		})
+	}
+}
+
+func TestValidateStoredSave_Header(t *testing.T) {
+	f := SaveFixture{Name: "Header"}
+	comp, err := f.Compressed()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := validateStoredSave(comp, cfg.ZZ); err != nil {
+		t.Errorf("compressed save: %v", err)
+	}
+	if err := validateStoredSave(f.Build(), cfg.ZZ); !errors.Is(err, ErrSaveCorrupt) {
+		t.Errorf("uncompressed ZZ save: err = %v, want ErrSaveCorrupt", err)
+	}
+}
+
+// newSaveHarness returns a ZZ harness with SaveDumps writing to a temporary
+// directory and the given save stored for character 1.
+func newSaveHarness(t *testing.T, stored []byte) (*handlerHarness, *mockCharacterRepo, *mockMailRepo) {
+	h := newHandlerHarness(t)
+	h.Server.erupeConfig.RealClientMode = cfg.ZZ
+	h.Server.erupeConfig.SaveDumps = cfg.SaveDumpOptions{Enabled: true, OutputDir: t.TempDir()}
+	h.Server.saveDumps = savedump.New(h.Server.erupeConfig.SaveDumps)
+	h.Server.userBinary = NewUserBinaryStore()
+	repo := newMockCharacterRepo()
+	repo.columns["savedata"] = stored
+	repo.loadSaveDataID = 1
+	repo.loadSaveDataData = stored
+	repo.loadSaveDataName = "Hunter"
+	h.Server.charRepo = repo
+	mail := &mockMailRepo{}
+	h.Server.mailService = NewMailService(mail, nil, h.Server.logger)
+	h.Session.Name = "Hunter"
+	return h, repo, mail
+}
+
+func TestHandleMsgMhfSavedata_BacksUpValidSave(t *testing.T) {
+	stored, _ := SaveFixture{Name: "Hunter", HR: 10}.Compressed()
+	payload, _ := SaveFixture{Name: "Hunter", HR: 11}.Compressed()
+	h, repo, _ := newSaveHarness(t, stored)
+
+	h.Handle(&mhfpacket.MsgMhfSavedata{AckHandle: 1, RawDataPayload: payload})
+	h.Next().ExpectAck(1).ExpectSuccess()
+	if repo.saveCharacterDataCalls != 1 {
+		t.Fatalf("saved %d times, want 1", repo.saveCharacterDataCalls)
+	}
+	backup, err := restoreSaveBackup(h.Session, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, _ := nullcomp.Decompress(payload)
+	if !bytes.Equal(backup, want) {
+		t.Error("backup differs from the saved data")
+	}
+}
+
+func TestHandleMsgMhfSavedata_RejectsCorruptSave(t *testing.T) {
+	stored, _ := SaveFixture{Name: "Hunter"}.Compressed()
+	data := SaveFixture{Name: "Hunter"}.Build()
+	data[fieldOffset(cfg.ZZ, savedata.FieldWeaponType)] = 0xFF
+	payload, _ := nullcomp.Compress(data)
+	h, repo, _ := newSaveHarness(t, stored)
+
+	h.Handle(&mhfpacket.MsgMhfSavedata{AckHandle: 1, RawDataPayload: payload})
+	sent := h.Sent()
+	if len(sent) != 2 {
+		t.Fatalf("sent %d packets, want a chat message and an ack", len(sent))
+	}
+	sent[0].ExpectOpcode(network.MSG_SYS_CASTED_BINARY)
+	sent[1].ExpectAck(1).ExpectFailure()
+	if repo.saveCharacterDataCalls != 0 {
+		t.Error("corrupt save was written")
+	}
+	if _, _, err := h.Server.saveDumps.Latest(1, savedump.KindSavedata); !errors.Is(err, savedump.ErrNotFound) {
+		t.Errorf("corrupt save was backed up: %v", err)
+	}
+	if _, _, err := h.Server.saveDumps.Latest(1, savedump.KindRejected); err != nil {
+		t.Errorf("corrupt save was not dumped: %v", err)
+	}
+}
+
+func TestHandleMsgMhfLoaddata_RestoresBackup(t *testing.T) {
+	stored, _ := SaveFixture{Name: "Hunter", HR: 50}.Compressed()
+	h, repo, mail := newSaveHarness(t, stored)
+	repo.ints["savedata_checksum"] = int(saveChecksum(stored) ^ 1)
+
+	backup, _ := SaveFixture{Name: "Hunter", HR: 40}.Compressed()
+	if _, err := h.Server.saveDumps.Write(1, savedump.KindSavedata, backup, savedump.Meta{Mode: cfg.ZZ}); err != nil {
+		t.Fatal(err)
+	}
+
+	h.Handle(&mhfpacket.MsgMhfLoaddata{AckHandle: 1})
+	if got := h.Next().ExpectAck(1).ExpectSuccess().AckData().Data(); !bytes.Equal(got, backup) {
+		t.Error("client was not sent the backup")
+	}
+	if !bytes.Equal(repo.saveCharacterDataData, backup) {
+		t.Error("backup was not written to the database")
+	}
+	if len(mail.sentMails) != 1 || !mail.sentMails[0].isSystemMessage || mail.sentMails[0].recipientID != 1 {
+		t.Errorf("player was not mailed: %+v", mail.sentMails)
+	}
+}
+
+func TestHandleMsgMhfLoaddata_CorruptWithoutBackup(t *testing.T) {
+	data := SaveFixture{Name: "Hunter"}.Build()
+	data[fieldOffset(cfg.ZZ, savedata.FieldGender)] = 7
+	stored, _ := nullcomp.Compress(data)
+	h, repo, mail := newSaveHarness(t, stored)
+
+	h.Handle(&mhfpacket.MsgMhfLoaddata{AckHandle: 1})
+	if got := h.Next().ExpectAck(1).ExpectSuccess().AckData().Data(); !bytes.Equal(got, stored) {
+		t.Error("stored save was not sent unchanged")
+	}
+	if repo.saveCharacterDataCalls != 0 || len(mail.sentMails) != 0 {
+		t.Error("save was restored without a backup")
+	}
+}
+
+func TestVerifySaveChecksum(t *testing.T) {
+	h := newHandlerHarness(t)
+	repo := newMockCharacterRepo()
+	h.Server.charRepo = repo
+	stored := []byte("savedata")
+
+	if err := verifySaveChecksum(h.Session, 1, stored); err != nil {
+		t.Errorf("no recorded checksum: %v", err)
+	}
+	repo.ints["savedata_checksum"] = int(saveChecksum(stored))
+	if err := verifySaveChecksum(h.Session, 1, stored); err != nil {
+		t.Errorf("matching checksum: %v", err)
+	}
+	if err := verifySaveChecksum(h.Session, 1, []byte("savedatb")); !errors.Is(err, ErrSaveCorrupt) {
+		t.Errorf("mismatched checksum: err = %v, want ErrSaveCorrupt", err)
+	}
+}
+
+func TestRestoreSaveBackup_MigratesMode(t *testing.T) {
+	h, _, _ := newSaveHarness(t, nil)
+	backup, _ := SaveFixture{Mode: cfg.G10, Name: "Hunter", HR: 40}.Compressed()
+	if _, err := h.Server.saveDumps.Write(1, savedump.KindSavedata, backup, savedump.Meta{Mode: cfg.G10}); err != nil {
+		t.Fatal(err)
+	}
+	data, err := restoreSaveBackup(h.Session, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ValidateSaveData(data, cfg.ZZ); err != nil {
+		t.Errorf("restored G10 backup is not a ZZ save: %v", err)
+	}
+}
+
+func TestDumpSaveData_RecordsQuest(t *testing.T) {
+	h, _, _ := newSaveHarness(t, nil)
+	h.Session.stage = NewStage("sl1Qs100p0a0u0")
+	h.Session.questFile = "23045d0"
+	dumpSaveData(h.Session, []byte{0x01}, "test")
+
+	list, err := h.Server.saveDumps.List(1)
+	if err != nil || len(list) != 1 {
+		t.Fatalf("List = %v, %v, want one dump", list, err)
+	}
+	if e := list[0]; e.Stage != "sl1Qs100p0a0u0" || e.Quest != "23045d0" || e.Mode != cfg.ZZ {
+		t.Errorf("entry = %+v", e)
+	}
+}
@@ -2,7 +2,6 @@ package channelserver
 
 import (
 	"bytes"
-	"net"
 	"testing"
 	"time"
 
@@ -581,7 +580,6 @@ func createTestServerWithDB(t *testing.T, db *sqlx.DB) *Server {
 	// Note: This may need adjustment based on actual Server initialization
 	server := &Server{
 		db:         db,
-		sessions:   make(map[net.Conn]*Session),
 		userBinary: NewUserBinaryStore(),
 		minidata:   NewMinidataStore(),
 		semaphore:  make(map[string]*Semaphore),
@@ -625,16 +623,13 @@ func createTestSessionForServerWithChar(server *Server, charID uint32, name stri
 		cryptConn:     mock,
 		sendPackets:   make(chan packet, 20),
 		clientContext: &clientctx.ClientContext{},
-		lastPacket:    time.Now(),
 		sessionStart:  time.Now().Unix(),
 		charID:        charID,
 		Name:          name,
 	}
 
 	// Register session with server (needed for logout to work properly)
-	server.Lock()
-	server.sessions[mockNetConn] = session
-	server.Unlock()
+	server.sessions.Store(mockNetConn, session)
 
 	return session
 }
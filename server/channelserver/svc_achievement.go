@@ -60,3 +60,33 @@ func (svc *AchievementService) Increment(charID uint32, achievementID uint8) err
 
 	return svc.achievementRepo.IncrementScore(charID, achievementID)
 }
+
+// goldThreshold returns the cumulative score needed for a gold trophy.
+func goldThreshold(achievementID uint8) int32 {
+	var total int32
+	for _, v := range achievementCurveMap[achievementID] {
+		total += v
+	}
+	return total
+}
+
+// IsServerFirstGold reports whether the character has just reached the gold
+// trophy for the achievement and is the only character on the server to hold it.
+func (svc *AchievementService) IsServerFirstGold(charID uint32, achievementID uint8) (bool, error) {
+	if achievementID >= achievementEntryCount {
+		return false, fmt.Errorf("achievement ID %d out of range [0, 32]", achievementID)
+	}
+	scores, err := svc.achievementRepo.GetAllScores(charID)
+	if err != nil {
+		return false, err
+	}
+	threshold := goldThreshold(achievementID)
+	if scores[achievementID] != threshold {
+		return false, nil
+	}
+	count, err := svc.achievementRepo.CountAtLeast(achievementID, threshold)
+	if err != nil {
+		return false, err
+	}
+	return count == 1, nil
+}
@@ -167,3 +167,49 @@ func TestAchievementService_Increment(t *testing.T) {
 		})
 	}
 }
+
+func TestAchievementService_IsServerFirstGold(t *testing.T) {
+	gold := goldThreshold(0)
+	tests := []struct {
+		name     string
+		score    int32
+		count    int
+		countErr error
+		want     bool
+		wantErr  bool
+	}{
+		{name: "below gold", score: gold - 1, count: 0, want: false},
+		{name: "first to gold", score: gold, count: 1, want: true},
+		{name: "gold but not first", score: gold, count: 3, want: false},
+		{name: "past gold", score: gold + 1, count: 1, want: false},
+		{name: "count error", score: gold, countErr: errNotFound, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := &mockAchievementRepo{countAtLeast: tt.count, countErr: tt.countErr}
+			mock.scores[0] = tt.score
+			svc := newTestAchievementService(mock)
+
+			got, err := svc.IsServerFirstGold(1, 0)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("Expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("IsServerFirstGold() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAchievementService_IsServerFirstGold_InvalidID(t *testing.T) {
+	svc := newTestAchievementService(&mockAchievementRepo{})
+	if _, err := svc.IsServerFirstGold(1, 33); err == nil {
+		t.Error("Expected error for out-of-range achievement ID")
+	}
+}
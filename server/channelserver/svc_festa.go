@@ -43,6 +43,22 @@ func (svc *FestaService) EnsureActiveEvent(currentStart uint32, now time.Time, n
 	return newStart, nil
 }
 
+// Rotate ends the current festa event once it is over and schedules the
+// next one, as EnsureActiveEvent does when a client asks for the festa
+// schedule, so the rollover happens on time whether or not anyone asks.
+// Returns the start time of the active event.
+func (svc *FestaService) Rotate(now time.Time, nextMidnight time.Time) (uint32, error) {
+	events, err := svc.festaRepo.GetFestaEvents()
+	if err != nil {
+		return 0, err
+	}
+	var start uint32
+	for _, e := range events {
+		start = e.StartTime
+	}
+	return svc.EnsureActiveEvent(start, now, nextMidnight)
+}
+
 // SubmitSouls filters out zero-value soul entries and records the remaining
 // submissions for the character. Returns nil if all entries are zero.
 func (svc *FestaService) SubmitSouls(charID, guildID uint32, souls []uint16) error {
@@ -59,3 +75,21 @@ func (svc *FestaService) SubmitSouls(charID, guildID uint32, souls []uint16) err
 	}
 	return svc.festaRepo.SubmitSouls(charID, guildID, souls)
 }
+
+// Standings returns both teams' soul totals and the currently leading team
+// ("red" or "blue"). The leader is empty while the teams are tied.
+func (svc *FestaService) Standings() (leader string, red, blue uint32, err error) {
+	if red, err = svc.festaRepo.GetTeamSouls("red"); err != nil {
+		return "", 0, 0, err
+	}
+	if blue, err = svc.festaRepo.GetTeamSouls("blue"); err != nil {
+		return "", 0, 0, err
+	}
+	switch {
+	case red > blue:
+		leader = "red"
+	case blue > red:
+		leader = "blue"
+	}
+	return leader, red, blue, nil
+}
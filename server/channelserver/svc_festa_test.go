@@ -98,6 +98,37 @@ func TestFestaService_EnsureActiveEvent_InsertError(t *testing.T) {
 	}
 }
 
+// --- Rotate tests ---
+
+func TestFestaService_Rotate(t *testing.T) {
+	now := time.Unix(10000000, 0)
+	nextMidnight := now.Add(24 * time.Hour)
+
+	active := &mockFestaRepo{events: []FestaEvent{{ID: 1, StartTime: uint32(now.Unix() - 100)}}}
+	if start, err := newTestFestaService(active).Rotate(now, nextMidnight); err != nil || start != uint32(now.Unix()-100) {
+		t.Errorf("Rotate() of an active event = %d, %v", start, err)
+	}
+	if active.cleanupCalled {
+		t.Error("CleanupAll should not be called when event is active")
+	}
+
+	expired := &mockFestaRepo{events: []FestaEvent{{ID: 1, StartTime: 1}}}
+	if start, err := newTestFestaService(expired).Rotate(now, nextMidnight); err != nil || start != uint32(nextMidnight.Unix()) {
+		t.Errorf("Rotate() of an expired event = %d, %v", start, err)
+	}
+	if !expired.cleanupCalled || expired.insertedStart != uint32(nextMidnight.Unix()) {
+		t.Error("expired event was not replaced")
+	}
+
+	failing := &mockFestaRepo{eventsErr: errors.New("db error")}
+	if _, err := newTestFestaService(failing).Rotate(now, nextMidnight); err == nil {
+		t.Error("Rotate() ignored a repo error")
+	}
+	if failing.cleanupCalled {
+		t.Error("CleanupAll should not be called when events cannot be read")
+	}
+}
+
 // --- SubmitSouls tests ---
 
 func TestFestaService_SubmitSouls_FiltersZeros(t *testing.T) {
@@ -136,3 +167,38 @@ func TestFestaService_SubmitSouls_RepoError(t *testing.T) {
 		t.Fatal("expected error from repo failure")
 	}
 }
+
+func TestFestaService_Standings(t *testing.T) {
+	tests := []struct {
+		name       string
+		red, blue  uint32
+		wantLeader string
+	}{
+		{"red leads", 300, 200, "red"},
+		{"blue leads", 100, 250, "blue"},
+		{"tied", 50, 50, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := &mockFestaRepo{teamByName: map[string]uint32{"red": tt.red, "blue": tt.blue}}
+			svc := newTestFestaService(mock)
+
+			leader, red, blue, err := svc.Standings()
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if leader != tt.wantLeader || red != tt.red || blue != tt.blue {
+				t.Errorf("Standings() = %q, %d, %d, want %q, %d, %d", leader, red, blue, tt.wantLeader, tt.red, tt.blue)
+			}
+		})
+	}
+}
+
+func TestFestaService_Standings_RepoError(t *testing.T) {
+	mock := &mockFestaRepo{teamErr: errors.New("db error")}
+	svc := newTestFestaService(mock)
+
+	if _, _, _, err := svc.Standings(); err == nil {
+		t.Fatal("expected error from repo failure")
+	}
+}
@@ -0,0 +1,96 @@
+package channelserver
+
+import (
+	"time"
+
+	"erupe-ce/server/audit"
+
+	"go.uber.org/zap"
+)
+
+// Moderation actions recorded in the audit log.
+const (
+	ModerationBan    = "ban"
+	ModerationUnban  = "unban"
+	ModerationMute   = "mute"
+	ModerationUnmute = "unmute"
+)
+
+// Sources of moderation actions recorded in the audit log.
+const (
+	ModerationSourceGame    = audit.SourceGame
+	ModerationSourceDiscord = audit.SourceDiscord
+	ModerationSourceCLI     = audit.SourceCLI
+)
+
+// ModerationService applies bans and chat mutes and records every action in
+// the audit log, whichever side it originated from.
+type ModerationService struct {
+	userRepo       UserRepo
+	moderationRepo ModerationRepo
+	logger         *zap.Logger
+}
+
+// NewModerationService creates a new ModerationService.
+func NewModerationService(ur UserRepo, mr ModerationRepo, log *zap.Logger) *ModerationService {
+	return &ModerationService{
+		userRepo:       ur,
+		moderationRepo: mr,
+		logger:         log,
+	}
+}
+
+// Ban bans the user until expires, or permanently if expires is nil.
+func (svc *ModerationService) Ban(userID uint32, expires *time.Time, source, actor string) error {
+	if err := svc.userRepo.BanUser(userID, expires); err != nil {
+		return err
+	}
+	svc.record(userID, ModerationBan, expires, source, actor)
+	return nil
+}
+
+// Unban lifts any ban on the user.
+func (svc *ModerationService) Unban(userID uint32, source, actor string) error {
+	if err := svc.moderationRepo.Unban(userID); err != nil {
+		return err
+	}
+	svc.record(userID, ModerationUnban, nil, source, actor)
+	return nil
+}
+
+// Mute prevents the user from chatting until the given time.
+func (svc *ModerationService) Mute(userID uint32, until time.Time, source, actor string) error {
+	if err := svc.moderationRepo.SetMutedUntil(userID, &until); err != nil {
+		return err
+	}
+	svc.record(userID, ModerationMute, &until, source, actor)
+	return nil
+}
+
+// Unmute lifts any chat mute on the user.
+func (svc *ModerationService) Unmute(userID uint32, source, actor string) error {
+	if err := svc.moderationRepo.SetMutedUntil(userID, nil); err != nil {
+		return err
+	}
+	svc.record(userID, ModerationUnmute, nil, source, actor)
+	return nil
+}
+
+// MutedUntil returns the end of the user's chat mute, or the zero time if
+// they are not muted.
+func (svc *ModerationService) MutedUntil(userID uint32) (time.Time, error) {
+	until, err := svc.moderationRepo.GetMutedUntil(userID)
+	if err != nil || until == nil {
+		return time.Time{}, err
+	}
+	return *until, nil
+}
+
+// record writes an audit log entry. The action has already been applied, so
+// a logging failure is reported but not returned.
+func (svc *ModerationService) record(userID uint32, action string, expires *time.Time, source, actor string) {
+	if err := svc.moderationRepo.InsertLog(userID, action, expires, source, actor); err != nil {
+		svc.logger.Error("Failed to record audit log entry",
+			zap.Uint32("userID", userID), zap.String("action", action), zap.Error(err))
+	}
+}
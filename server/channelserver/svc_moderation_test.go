@@ -0,0 +1,81 @@
+package channelserver
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func newTestModerationService(ur UserRepo, mr *mockModerationRepo) *ModerationService {
+	logger, _ := zap.NewDevelopment()
+	return NewModerationService(ur, mr, logger)
+}
+
+func TestModerationService_Ban_RecordsLog(t *testing.T) {
+	users := &mockUserRepoCommands{}
+	mod := &mockModerationRepo{}
+	svc := newTestModerationService(users, mod)
+	expires := time.Now().Add(time.Hour)
+
+	if err := svc.Ban(7, &expires, ModerationSourceDiscord, ""); err != nil {
+		t.Fatalf("Ban returned error: %v", err)
+	}
+	if users.bannedUID != 7 || users.banExpiry != &expires {
+		t.Errorf("BanUser not called with user 7 and expiry")
+	}
+	if len(mod.log) != 1 || mod.log[0].action != ModerationBan || mod.log[0].source != ModerationSourceDiscord {
+		t.Errorf("log = %+v, want one ban from discord", mod.log)
+	}
+}
+
+func TestModerationService_Ban_ErrorSkipsLog(t *testing.T) {
+	users := &mockUserRepoCommands{banErr: errors.New("db error")}
+	mod := &mockModerationRepo{}
+	svc := newTestModerationService(users, mod)
+
+	if err := svc.Ban(7, nil, ModerationSourceGame, "Op"); err == nil {
+		t.Fatal("Ban should return error when BanUser fails")
+	}
+	if len(mod.log) != 0 {
+		t.Errorf("log entries = %d, want 0", len(mod.log))
+	}
+}
+
+func TestModerationService_LogErrorNotReturned(t *testing.T) {
+	mod := &mockModerationRepo{insertLogErr: errors.New("db error")}
+	svc := newTestModerationService(&mockUserRepoCommands{}, mod)
+
+	if err := svc.Unban(7, ModerationSourceGame, "Op"); err != nil {
+		t.Errorf("Unban returned error: %v", err)
+	}
+	if len(mod.unbanned) != 1 {
+		t.Errorf("unbanned = %v, want [7]", mod.unbanned)
+	}
+}
+
+func TestModerationService_MuteUnmute(t *testing.T) {
+	mod := &mockModerationRepo{}
+	svc := newTestModerationService(&mockUserRepoCommands{}, mod)
+	until := time.Now().Add(time.Hour).Truncate(time.Second)
+
+	if err := svc.Mute(7, until, ModerationSourceDiscord, ""); err != nil {
+		t.Fatalf("Mute returned error: %v", err)
+	}
+	got, err := svc.MutedUntil(7)
+	if err != nil || !got.Equal(until) {
+		t.Errorf("MutedUntil = %v, %v, want %v", got, err, until)
+	}
+
+	if err := svc.Unmute(7, ModerationSourceDiscord, ""); err != nil {
+		t.Fatalf("Unmute returned error: %v", err)
+	}
+	got, _ = svc.MutedUntil(7)
+	if !got.IsZero() {
+		t.Errorf("MutedUntil after unmute = %v, want zero", got)
+	}
+	if len(mod.log) != 2 || mod.log[0].action != ModerationMute || mod.log[1].action != ModerationUnmute {
+		t.Errorf("log = %+v, want mute then unmute", mod.log)
+	}
+}
@@ -0,0 +1,70 @@
+package channelserver
+
+import (
+	"erupe-ce/server/analytics"
+
+	"go.uber.org/zap"
+)
+
+// itemTypeItem is the item type of ordinary items, the only kind shops sell.
+const itemTypeItem = 7
+
+// shopCurrencies are the metrics the prices of each shop type are counted
+// in. Shops priced in items or event points are not followed.
+var shopCurrencies = map[uint8]string{
+	5:  analytics.GCPSink,   // GCP->Item
+	10: analytics.ZennySink, // Item shop
+}
+
+// countShopPurchase counts count purchases of a shop item towards the
+// analytics: the price spent and the items bought.
+func (s *Session) countShopPurchase(shopItemID, count uint32) {
+	if s.server.analytics == nil {
+		return
+	}
+	shopType, item, err := s.server.shopRepo.GetShopItem(shopItemID)
+	if err != nil {
+		s.logger.Warn("Failed to read shop item for analytics", zap.Uint32("shopItemID", shopItemID), zap.Error(err))
+		return
+	}
+	if metric, ok := shopCurrencies[shopType]; ok {
+		s.server.analytics.Add(metric, analytics.KeyShop, int64(item.Cost)*int64(count))
+	}
+	// Item->GCP exchanges take items rather than hand them out.
+	if shopType != 7 {
+		s.server.analytics.Add(analytics.Items, analytics.ItemKey(itemTypeItem, item.ItemID), int64(item.Quantity)*int64(count))
+	}
+}
+
+// countGachaRewards counts the items won from a gacha.
+func (s *Session) countGachaRewards(rewards []GachaReward) {
+	for _, r := range rewards {
+		s.server.analytics.Add(analytics.Items, analytics.ItemKey(r.ItemType, uint32(r.ItemID)), int64(r.Quantity))
+	}
+}
+
+// countGCPChange counts the difference between the character's stored GCP
+// and gcp, the total the client is about to save.
+func (s *Session) countGCPChange(gcp uint32) {
+	if s.server.analytics == nil {
+		return
+	}
+	old, err := readCharacterInt(s, "gcp")
+	if err != nil {
+		s.logger.Warn("Failed to read GCP for analytics", zap.Error(err))
+		return
+	}
+	if delta := int64(gcp) - int64(old); delta > 0 {
+		s.server.analytics.Add(analytics.GCPSource, analytics.KeyMercenary, delta)
+	} else {
+		s.server.analytics.Add(analytics.GCPSink, analytics.KeyMercenary, -delta)
+	}
+}
+
+// countQuestReturn counts a quest played when the session leaves a quest
+// stage, keyed by the quest file it last loaded.
+func (s *Session) countQuestReturn(from, to string) {
+	if isQuestStage(from) && !isQuestStage(to) && s.questFile != "" {
+		s.server.analytics.Add(analytics.Quests, s.questFile, 1)
+	}
+}
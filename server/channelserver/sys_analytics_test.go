@@ -0,0 +1,89 @@
+package channelserver
+
+import (
+	"maps"
+	"testing"
+	"time"
+
+	"erupe-ce/server/analytics"
+)
+
+// analyticsCounts is an analytics.Store keeping counts by metric and key.
+type analyticsCounts map[string]int64
+
+func (c analyticsCounts) Add(rows []analytics.Row) error {
+	for _, row := range rows {
+		c[row.Metric+"/"+row.Key] += row.Value
+	}
+	return nil
+}
+
+func (c analyticsCounts) SamplePlayers(time.Time) error { return nil }
+
+func flushCounts(t *testing.T, r *analytics.Recorder) analyticsCounts {
+	t.Helper()
+	counts := analyticsCounts{}
+	if err := r.Flush(counts); err != nil {
+		t.Fatal(err)
+	}
+	return counts
+}
+
+func TestCountShopPurchase(t *testing.T) {
+	tests := []struct {
+		shopType uint8
+		want     analyticsCounts
+	}{
+		{10, analyticsCounts{"zenny_sink/shop": 1500, "items/7:100": 30}},
+		{5, analyticsCounts{"gcp_sink/shop": 1500, "items/7:100": 30}},
+		{7, analyticsCounts{}},
+		{8, analyticsCounts{"items/7:100": 30}},
+	}
+	for _, tt := range tests {
+		server := createMockServer()
+		server.analytics = analytics.NewRecorder()
+		server.shopRepo = &mockShopRepo{shopItemType: tt.shopType, shopItem: ShopItem{ItemID: 100, Cost: 500, Quantity: 10}}
+		createMockSession(1, server).countShopPurchase(1, 3)
+		if got := flushCounts(t, server.analytics); !maps.Equal(got, tt.want) {
+			t.Errorf("shop type %d: counts = %v, want %v", tt.shopType, got, tt.want)
+		}
+	}
+
+	// Without a recorder the shop item is not even read.
+	server := createMockServer()
+	server.shopRepo = nil
+	createMockSession(1, server).countShopPurchase(1, 3)
+}
+
+func TestCountGCPChange(t *testing.T) {
+	server := createMockServer()
+	server.analytics = analytics.NewRecorder()
+	charRepo := newMockCharacterRepo()
+	charRepo.ints["gcp"] = 1000
+	server.charRepo = charRepo
+	s := createMockSession(1, server)
+
+	s.countGCPChange(1250)
+	charRepo.ints["gcp"] = 1250
+	s.countGCPChange(1250)
+	s.countGCPChange(900)
+	want := analyticsCounts{"gcp_source/mercenary": 250, "gcp_sink/mercenary": 350}
+	if got := flushCounts(t, server.analytics); !maps.Equal(got, want) {
+		t.Errorf("counts = %v, want %v", got, want)
+	}
+}
+
+func TestCountQuestReturn(t *testing.T) {
+	server := createMockServer()
+	server.analytics = analytics.NewRecorder()
+	s := createMockSession(1, server)
+	s.questFile = "23045d0"
+
+	s.countQuestReturn("sl1Ns200p0a0u0", "sl1Qs1p0a0u0")
+	s.countQuestReturn("sl1Qs1p0a0u0", "sl1Qs2p0a0u0")
+	s.countQuestReturn("sl1Qs2p0a0u0", "sl1Ns200p0a0u0")
+	want := analyticsCounts{"quests/23045d0": 1}
+	if got := flushCounts(t, server.analytics); !maps.Equal(got, want) {
+		t.Errorf("counts = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,132 @@
+package channelserver
+
+import (
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// asyncWriteBatchSize is the number of pending writes that triggers a flush
+// before the interval elapses.
+const asyncWriteBatchSize = 256
+
+// AsyncWriter defers low-value, frequent database writes such as last login
+// times, player counts and usage counters off the packet handlers and runs
+// them in batches. Writes sharing a key are coalesced so only the latest
+// reaches the database. Pending writes are flushed on Close.
+type AsyncWriter struct {
+	mu       sync.Mutex
+	pending  []asyncWrite
+	index    map[string]int // Key → position in pending
+	closed   bool
+	interval time.Duration
+	kick     chan struct{}
+	stop     chan struct{}
+	stopped  chan struct{}
+	logger   *zap.Logger
+}
+
+type asyncWrite struct {
+	key string
+	fn  func() error
+}
+
+// NewAsyncWriter creates an AsyncWriter that flushes every interval. Call
+// Start to begin flushing.
+func NewAsyncWriter(interval time.Duration, logger *zap.Logger) *AsyncWriter {
+	return &AsyncWriter{
+		index:    make(map[string]int),
+		interval: interval,
+		kick:     make(chan struct{}, 1),
+		stop:     make(chan struct{}),
+		stopped:  make(chan struct{}),
+		logger:   logger,
+	}
+}
+
+// Start runs the flush loop until Close is called.
+func (w *AsyncWriter) Start() {
+	go w.run()
+}
+
+func (w *AsyncWriter) run() {
+	defer close(w.stopped)
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-w.kick:
+		case <-w.stop:
+			w.Flush()
+			return
+		}
+		w.Flush()
+	}
+}
+
+// Enqueue queues fn to run on the next flush. A non-empty key replaces any
+// pending write with the same key, keeping its place in the queue; an empty
+// key always queues fn. fn should wrap its errors with enough context to
+// identify the write, as they are only logged. After Close, fn runs
+// immediately.
+func (w *AsyncWriter) Enqueue(key string, fn func() error) {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		w.exec(fn)
+		return
+	}
+	if i, ok := w.index[key]; ok && key != "" {
+		w.pending[i].fn = fn
+		w.mu.Unlock()
+		return
+	}
+	if key != "" {
+		w.index[key] = len(w.pending)
+	}
+	w.pending = append(w.pending, asyncWrite{key: key, fn: fn})
+	full := len(w.pending) >= asyncWriteBatchSize
+	w.mu.Unlock()
+
+	if full {
+		select {
+		case w.kick <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Flush runs every pending write in the order it was queued.
+func (w *AsyncWriter) Flush() {
+	w.mu.Lock()
+	batch := w.pending
+	w.pending = nil
+	clear(w.index)
+	w.mu.Unlock()
+
+	for _, write := range batch {
+		w.exec(write.fn)
+	}
+}
+
+func (w *AsyncWriter) exec(fn func() error) {
+	if err := fn(); err != nil {
+		w.logger.Error("Deferred write failed", zap.Error(err))
+	}
+}
+
+// Close stops the flush loop after running every pending write. It must
+// only be called after Start.
+func (w *AsyncWriter) Close() {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return
+	}
+	w.closed = true
+	w.mu.Unlock()
+	close(w.stop)
+	<-w.stopped
+}
@@ -0,0 +1,118 @@
+package channelserver
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// recordingWrites collects the values written by queued writes.
+type recordingWrites struct {
+	mu     sync.Mutex
+	values []int
+}
+
+func (r *recordingWrites) write(v int) func() error {
+	return func() error {
+		r.mu.Lock()
+		r.values = append(r.values, v)
+		r.mu.Unlock()
+		return nil
+	}
+}
+
+func (r *recordingWrites) get() []int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]int(nil), r.values...)
+}
+
+func TestAsyncWriter_CoalescesByKey(t *testing.T) {
+	w := NewAsyncWriter(time.Hour, zap.NewNop())
+	var rec recordingWrites
+
+	w.Enqueue("a", rec.write(1))
+	w.Enqueue("", rec.write(2))
+	w.Enqueue("", rec.write(3))
+	w.Enqueue("a", rec.write(4)) // Replaces 1 in its place
+	w.Flush()
+
+	got := rec.get()
+	want := []int{4, 2, 3}
+	if len(got) != len(want) {
+		t.Fatalf("writes = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("writes = %v, want %v", got, want)
+		}
+	}
+
+	// Keys start over after a flush.
+	w.Enqueue("a", rec.write(5))
+	w.Flush()
+	if got := rec.get(); got[len(got)-1] != 5 {
+		t.Errorf("writes = %v, want 5 last", got)
+	}
+}
+
+func TestAsyncWriter_CloseFlushes(t *testing.T) {
+	w := NewAsyncWriter(time.Hour, zap.NewNop())
+	w.Start()
+	var rec recordingWrites
+
+	w.Enqueue("a", rec.write(1))
+	w.Close()
+	if got := rec.get(); len(got) != 1 {
+		t.Fatalf("writes after Close = %v, want [1]", got)
+	}
+
+	// Writes after Close run immediately.
+	w.Enqueue("a", rec.write(2))
+	if got := rec.get(); len(got) != 2 {
+		t.Errorf("writes = %v, want the late write to run", got)
+	}
+	w.Close()
+}
+
+func TestAsyncWriter_FlushesFullBatch(t *testing.T) {
+	w := NewAsyncWriter(time.Hour, zap.NewNop())
+	w.Start()
+	defer w.Close()
+	var rec recordingWrites
+
+	for i := 0; i < asyncWriteBatchSize; i++ {
+		w.Enqueue("", rec.write(i))
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for len(rec.get()) < asyncWriteBatchSize {
+		if time.Now().After(deadline) {
+			t.Fatalf("flushed %d writes, want %d", len(rec.get()), asyncWriteBatchSize)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestAsyncWriter_ErrorDoesNotStopBatch(t *testing.T) {
+	w := NewAsyncWriter(time.Hour, zap.NewNop())
+	var rec recordingWrites
+
+	w.Enqueue("", func() error { return errors.New("boom") })
+	w.Enqueue("", rec.write(1))
+	w.Flush()
+	if got := rec.get(); len(got) != 1 {
+		t.Errorf("writes = %v, want the write after the failure", got)
+	}
+}
+
+func TestServer_WriteAsyncWithoutWriter(t *testing.T) {
+	s := createMockServer()
+	var rec recordingWrites
+	s.writeAsync("a", rec.write(1))
+	if got := rec.get(); len(got) != 1 {
+		t.Errorf("writes = %v, want an immediate write", got)
+	}
+}
@@ -0,0 +1,144 @@
+package channelserver
+
+import (
+	"bytes"
+	"runtime"
+	"sync"
+
+	"erupe-ce/common/byteframe"
+	"erupe-ce/network/clientctx"
+	"erupe-ce/network/mhfpacket"
+)
+
+// fanoutBatch is the number of recipients each fan-out worker enqueues to.
+// Broadcasts to fewer sessions than this are enqueued inline.
+const fanoutBatch = 32
+
+// fanoutJob enqueues one serialized packet to a batch of sessions.
+type fanoutJob struct {
+	data     []byte
+	key      uint64
+	sessions []*Session
+	ignored  *Session
+	wg       *sync.WaitGroup
+}
+
+// fanoutPool is a fixed set of workers that enqueue broadcast packets to
+// session send queues in parallel.
+type fanoutPool struct {
+	jobs chan fanoutJob
+	done <-chan struct{}
+}
+
+// newFanoutPool starts workers that run until done is closed.
+func newFanoutPool(workers int, done <-chan struct{}) *fanoutPool {
+	p := &fanoutPool{jobs: make(chan fanoutJob), done: done}
+	for i := 0; i < workers; i++ {
+		go p.work()
+	}
+	return p
+}
+
+func (p *fanoutPool) work() {
+	for {
+		select {
+		case job := <-p.jobs:
+			enqueueBroadcast(job.sessions, job.ignored, job.data, job.key)
+			job.wg.Done()
+		case <-p.done:
+			return
+		}
+	}
+}
+
+// run enqueues data to sessions in batches and returns once every batch has
+// been enqueued, so a broadcast is complete before the caller continues.
+func (p *fanoutPool) run(sessions []*Session, ignored *Session, data []byte, key uint64) {
+	var wg sync.WaitGroup
+	for len(sessions) > 0 {
+		n := min(fanoutBatch, len(sessions))
+		batch := sessions[:n]
+		sessions = sessions[n:]
+		wg.Add(1)
+		select {
+		case p.jobs <- fanoutJob{data: data, key: key, sessions: batch, ignored: ignored, wg: &wg}:
+		case <-p.done:
+			enqueueBroadcast(batch, ignored, data, key)
+			wg.Done()
+		}
+	}
+	wg.Wait()
+}
+
+func defaultFanoutWorkers() int {
+	return max(2, runtime.GOMAXPROCS(0)/2)
+}
+
+// broadcastTo serializes pkt once and enqueues it to every session except
+// ignored. Sessions on one channel share a client mode, so the first
+// session's context is used to build the packet for all of them.
+func broadcastTo(sessions []*Session, ignored *Session, pkt mhfpacket.MHFPacket) {
+	if len(sessions) == 0 || len(sessions) == 1 && sessions[0] == ignored {
+		return
+	}
+	first := sessions[0]
+	if tracingEnabled(first.server) {
+		defer traceBroadcast(ignored, pkt, len(sessions)).End()
+	}
+
+	// The buffer is shared by every recipient; clamp its capacity so the
+	// send loop's terminator append copies instead of writing into it.
+	data := buildPacket(pkt, first.clientContext)
+	data = data[:len(data):len(data)]
+
+	key := coalesceKey(pkt)
+	if first.server != nil && first.server.fanout != nil && len(sessions) > fanoutBatch {
+		first.server.fanout.run(sessions, ignored, data, key)
+		return
+	}
+	enqueueBroadcast(sessions, ignored, data, key)
+}
+
+// buildPacket serializes pkt with its opcode header. It builds in a pooled
+// byteframe and returns a copy, so the result is safe to retain.
+func buildPacket(pkt mhfpacket.MHFPacket, ctx *clientctx.ClientContext) []byte {
+	bf := byteframe.Acquire()
+	defer bf.Release()
+
+	// Make the header
+	bf.WriteUint16(uint16(pkt.Opcode()))
+
+	// Build the packet onto the byteframe.
+	_ = pkt.Build(bf, ctx)
+
+	return bytes.Clone(bf.Data())
+}
+
+func enqueueBroadcast(sessions []*Session, ignored *Session, data []byte, key uint64) {
+	for _, session := range sessions {
+		if session == ignored {
+			continue
+		}
+		if key != 0 {
+			session.QueueSendCoalesced(key, data)
+		} else {
+			// Enqueue in a non-blocking way that drops the packet if the connections send buffer channel is full.
+			session.QueueSendNonBlocking(data)
+		}
+	}
+}
+
+// coalesceKey returns a non-zero key for packets that only matter as the
+// latest of their kind, such as object positions and player state updates.
+// A newer packet with the same key replaces one still waiting to be sent.
+func coalesceKey(pkt mhfpacket.MHFPacket) uint64 {
+	switch p := pkt.(type) {
+	case *mhfpacket.MsgSysPositionObject:
+		return uint64(p.Opcode())<<32 | uint64(p.ObjID)
+	case *mhfpacket.MsgSysCastedBinary:
+		if p.MessageType == BinaryMessageTypeState {
+			return uint64(p.Opcode())<<32 | uint64(p.CharID)
+		}
+	}
+	return 0
+}
@@ -0,0 +1,137 @@
+package channelserver
+
+import (
+	"bytes"
+	"testing"
+
+	"erupe-ce/network/mhfpacket"
+)
+
+func TestBroadcastTo_SharedBufferIsNotAliased(t *testing.T) {
+	server := createMockServer()
+	s1 := createMockSession(1, server)
+	s2 := createMockSession(2, server)
+
+	broadcastTo([]*Session{s1, s2}, nil, &mockPacket{opcode: 0x1234})
+
+	p1 := <-s1.sendPackets
+	p2 := <-s2.sendPackets
+	if cap(p1.data) != len(p1.data) {
+		t.Fatalf("shared buffer has spare capacity %d, appends would alias", cap(p1.data)-len(p1.data))
+	}
+	framed := append(p1.data, 0x00, 0x10)
+	framed[0] = 0xFF
+	if p2.data[0] == 0xFF {
+		t.Error("appending to one recipient's packet modified another's")
+	}
+}
+
+func TestBroadcastTo_FanoutPool(t *testing.T) {
+	server := createMockServer()
+	done := make(chan struct{})
+	defer close(done)
+	server.fanout = newFanoutPool(4, done)
+
+	sessions := make([]*Session, fanoutBatch*3+5)
+	for i := range sessions {
+		sessions[i] = createMockSession(uint32(i), server)
+	}
+
+	broadcastTo(sessions, nil, &mockPacket{opcode: 0x1234})
+
+	// The pool must finish before broadcastTo returns.
+	for i, session := range sessions {
+		select {
+		case p := <-session.sendPackets:
+			if len(p.data) == 0 {
+				t.Errorf("session %d received empty data", i)
+			}
+		default:
+			t.Fatalf("session %d did not receive data", i)
+		}
+	}
+}
+
+func TestBroadcastTo_CoalescesPositions(t *testing.T) {
+	server := createMockServer()
+	session := createMockSession(1, server)
+
+	for _, x := range []float32{1, 2, 3} {
+		broadcastTo([]*Session{session}, nil, &mhfpacket.MsgSysPositionObject{ObjID: 7, X: x})
+	}
+	broadcastTo([]*Session{session}, nil, &mhfpacket.MsgSysPositionObject{ObjID: 8, X: 9})
+
+	if n := len(session.sendPackets); n != 2 {
+		t.Fatalf("queued %d packets, want 2", n)
+	}
+
+	first := <-session.sendPackets
+	got := session.takeCoalesced(first.coalesceKey, first.data)
+	want := buildTestPacket(t, session, &mhfpacket.MsgSysPositionObject{ObjID: 7, X: 3})
+	if !bytes.Equal(got, want) {
+		t.Error("coalesced packet should carry the latest position")
+	}
+
+	// Once sent, the next update is queued again.
+	broadcastTo([]*Session{session}, nil, &mhfpacket.MsgSysPositionObject{ObjID: 7, X: 4})
+	if n := len(session.sendPackets); n != 2 {
+		t.Errorf("queued %d packets, want 2", n)
+	}
+}
+
+func TestCoalesceKey(t *testing.T) {
+	tests := []struct {
+		name string
+		pkt  mhfpacket.MHFPacket
+		want bool
+	}{
+		{"position", &mhfpacket.MsgSysPositionObject{ObjID: 1}, true},
+		{"state", &mhfpacket.MsgSysCastedBinary{CharID: 1, MessageType: BinaryMessageTypeState}, true},
+		{"chat", &mhfpacket.MsgSysCastedBinary{CharID: 1, MessageType: BinaryMessageTypeChat}, false},
+		{"other", &mockPacket{opcode: 0x1234}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := coalesceKey(tt.pkt) != 0; got != tt.want {
+				t.Errorf("coalesced = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func buildTestPacket(t *testing.T, s *Session, pkt mhfpacket.MHFPacket) []byte {
+	t.Helper()
+	session := createMockSession(0, s.server)
+	session.QueueSendMHFNonBlocking(pkt)
+	return (<-session.sendPackets).data
+}
+
+// BenchmarkStageBroadcast200 measures a chat broadcast to a full 200-player
+// stage, the hottest fan-out on a busy channel.
+func BenchmarkStageBroadcast200(b *testing.B) {
+	server := createMockServer()
+	done := make(chan struct{})
+	defer close(done)
+	server.fanout = newFanoutPool(defaultFanoutWorkers(), done)
+
+	stage := NewStage("bench_stage")
+	sessions := make([]*Session, 200)
+	for i := range sessions {
+		sessions[i] = createMockSession(uint32(i+1), server)
+		stage.clients[sessions[i]] = sessions[i].charID
+	}
+	pkt := &mhfpacket.MsgSysCastedBinary{
+		CharID:         1,
+		MessageType:    BinaryMessageTypeChat,
+		RawDataPayload: make([]byte, 64),
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		stage.BroadcastMHF(pkt, sessions[0])
+		for _, session := range sessions[1:] {
+			<-session.sendPackets
+		}
+	}
+}
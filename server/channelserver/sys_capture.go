@@ -17,7 +17,7 @@ import (
 // Returns the (possibly wrapped) conn, the RecordingConn (nil if capture disabled),
 // and a cleanup function that must be called on session close.
 func startCapture(server *Server, conn network.Conn, remoteAddr net.Addr, serverType pcap.ServerType) (network.Conn, *pcap.RecordingConn, func()) {
-	capCfg := server.erupeConfig.Capture
+	capCfg := server.erupeConfig.Live().Capture
 	if !capCfg.Enabled {
 		return conn, nil, func() {}
 	}
@@ -37,12 +37,13 @@ func startCapture(server *Server, conn network.Conn, remoteAddr net.Addr, server
 		}
 	}
 
+	logger := server.logger.Named("capture")
 	outputDir := capCfg.OutputDir
 	if outputDir == "" {
 		outputDir = "captures"
 	}
 	if err := os.MkdirAll(outputDir, 0o755); err != nil {
-		server.logger.Warn("Failed to create capture directory", zap.Error(err))
+		logger.Warn("Failed to create capture directory", zap.Error(err))
 		return conn, nil, func() {}
 	}
 
@@ -54,48 +55,83 @@ func startCapture(server *Server, conn network.Conn, remoteAddr net.Addr, server
 	)
 	path := filepath.Join(outputDir, filename)
 
-	f, err := os.Create(path)
+	flags, err := pcap.CompressionFlag(capCfg.Compression)
 	if err != nil {
-		server.logger.Warn("Failed to create capture file", zap.Error(err), zap.String("path", path))
-		return conn, nil, func() {}
+		logger.Warn("Unknown capture compression, recording uncompressed", zap.Error(err))
+	}
+	// The channel server reports how long it took to handle each packet.
+	if serverType == pcap.ServerTypeChannel {
+		flags |= pcap.FlagTiming
 	}
-
 	startNs := now.UnixNano()
 	hdr := pcap.FileHeader{
 		Version:        pcap.FormatVersion,
 		ServerType:     serverType,
 		ClientMode:     byte(server.erupeConfig.RealClientMode),
 		SessionStartNs: startNs,
+		Flags:          flags | pcap.FlagChecksums,
 	}
 	meta := pcap.SessionMetadata{
 		Host:       server.erupeConfig.Host,
 		RemoteAddr: remoteAddr.String(),
 	}
 
-	w, err := pcap.NewWriter(f, hdr, meta)
+	opts := pcap.RecordingOptions{
+		MaxBytes:    int64(capCfg.RotateSizeMB) << 20,
+		MaxDuration: time.Duration(capCfg.RotateMinutes) * time.Minute,
+		MaxTotal:    int64(capCfg.MaxTotalMB) << 20,
+	}
+	if capCfg.EncryptionKey != "" {
+		opts.Secret = []byte(capCfg.EncryptionKey)
+	}
+	rec, err := pcap.CreateRecording(path, hdr, meta, opts)
 	if err != nil {
-		server.logger.Warn("Failed to initialize capture writer", zap.Error(err))
-		_ = f.Close()
+		logger.Warn("Failed to create capture file", zap.Error(err), zap.String("path", path))
 		return conn, nil, func() {}
 	}
 
-	server.logger.Info("Capture started", zap.String("file", path))
+	logger.Info("Capture started", zap.String("file", path))
 
-	rc := pcap.NewRecordingConn(conn, w, startNs, capCfg.ExcludeOpcodes)
-	rc.SetCaptureFile(f, &meta)
+	rc := pcap.NewRecordingConn(conn, rec, startNs, capCfg.ExcludeOpcodes)
+	if hdr.Flags&pcap.FlagTiming != 0 {
+		rc.EnableTiming()
+	}
 	cleanup := func() {
-		if err := w.Flush(); err != nil {
-			server.logger.Warn("Failed to flush capture", zap.Error(err))
+		rc.Close()
+		if err := rec.Close(); err != nil {
+			logger.Warn("Failed to close capture file", zap.Error(err))
 		}
-		if err := f.Close(); err != nil {
-			server.logger.Warn("Failed to close capture file", zap.Error(err))
+		if paths := rec.Paths(); len(paths) > 0 {
+			logger.Info("Capture saved", zap.Strings("files", paths), zap.Uint64("dropped", rc.Dropped()))
 		}
-		server.logger.Info("Capture saved", zap.String("file", path))
 	}
 
 	return rc, rc, cleanup
 }
 
+// discardUntargetedCapture discards the capture of a session that logged
+// in as a character and account Capture does not target.
+func (s *Session) discardUntargetedCapture() {
+	capCfg := s.server.erupeConfig.Live().Capture
+	if s.captureConn == nil || !capCfg.Targeted() {
+		return
+	}
+	var username string
+	if len(capCfg.Usernames) > 0 {
+		var err error
+		if _, username, err = s.server.userRepo.GetByIDAndUsername(s.charID); err != nil {
+			s.logger.Warn("Failed to resolve username for capture targeting", zap.Error(err))
+		}
+	}
+	if capCfg.Targets(username, s.charID) {
+		return
+	}
+	if err := s.captureConn.Discard(); err != nil {
+		s.logger.Warn("Failed to discard capture", zap.Error(err))
+	}
+	s.logger.Debug("Capture discarded, session not targeted", zap.Uint32("charID", s.charID))
+}
+
 // sanitizeAddr replaces characters that are problematic in filenames.
 func sanitizeAddr(addr string) string {
 	out := make([]byte, 0, len(addr))
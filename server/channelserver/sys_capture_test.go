@@ -0,0 +1,43 @@
+package channelserver
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"erupe-ce/network/pcap"
+)
+
+func TestDiscardUntargetedCapture(t *testing.T) {
+	tests := []struct {
+		name    string
+		charIDs []uint32
+		kept    bool
+	}{
+		{"no targets", nil, true},
+		{"targeted", []uint32{1, 42}, true},
+		{"not targeted", []uint32{7}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := createMockServer()
+			server.erupeConfig.Capture.CharIDs = tt.charIDs
+			path := filepath.Join(t.TempDir(), "channel.mhfr")
+			rec, err := pcap.CreateRecording(path, pcap.FileHeader{Version: pcap.FormatVersion}, pcap.SessionMetadata{}, pcap.RecordingOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			s := createMockSession(42, server)
+			s.captureConn = pcap.NewRecordingConn(&MockCryptConn{}, rec, 0, nil)
+
+			s.discardUntargetedCapture()
+			s.captureConn.Close()
+			if err := rec.Close(); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := os.Stat(path); (err == nil) != tt.kept {
+				t.Errorf("capture kept = %v, want %v", err == nil, tt.kept)
+			}
+		})
+	}
+}
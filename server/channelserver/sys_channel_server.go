@@ -5,14 +5,24 @@ import (
 	"fmt"
 	"net"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"erupe-ce/common/byteframe"
 	cfg "erupe-ce/config"
 	"erupe-ce/network"
 	"erupe-ce/network/binpacket"
+	"erupe-ce/network/crypto"
 	"erupe-ce/network/mhfpacket"
+	"erupe-ce/server/analytics"
+	"erupe-ce/server/audit"
 	"erupe-ce/server/discordbot"
+	"erupe-ce/server/eventbus"
+	"erupe-ce/server/federation"
+	"erupe-ce/server/opmetrics"
+	"erupe-ce/server/questcache"
+	"erupe-ce/server/savedump"
+	"erupe-ce/server/sessionlog"
 
 	"github.com/jmoiron/sqlx"
 	"go.uber.org/zap"
@@ -23,7 +33,18 @@ type Config struct {
 	ID          uint16
 	Logger      *zap.Logger
 	DB          *sqlx.DB
+	ReadDBs     []*sqlx.DB // Read replicas for read-only queries; empty reads from DB
 	DiscordBot  *discordbot.DiscordBot
+	EventBus    *eventbus.Bus
+	SaveCache   *SaveDataCache
+	QuestCache  *questcache.Cache      // Shared by all channels; nil creates one per server
+	OpMetrics   *opmetrics.Registry    // Shared by all channels; nil disables handler metrics
+	Analytics   *analytics.Recorder    // Shared by all channels; nil disables economy analytics
+	Federation  *federation.Federation // Shared by all channels; nil when not federated
+	SaveWorkers *SaveWorkerPool        // Shared by all channels; nil saves on the session goroutine
+	SaveDumps   *savedump.Store        // Shared by all channels; nil creates one per server
+	ConnLimiter *network.ConnLimiter   // Shared by all listeners; nil disables connection limits
+	Cipher      crypto.Cipher          // Packet body cipher; nil for the retail cipher
 	ErupeConfig *cfg.Config
 	Name        string
 	Enable      bool
@@ -32,12 +53,12 @@ type Config struct {
 // Server is a MHF channel server.
 //
 // Lock ordering (acquire in this order to avoid deadlocks):
-//  1. Server.Mutex          – protects sessions map
+//  1. Server.Mutex          – protects shutdown state
 //  2. Stage.RWMutex         – protects per-stage state (clients, objects)
 //  3. Server.semaphoreLock  – protects semaphore map
 //
-// Note: Server.stages is a StageMap (sync.Map-backed), so it requires no
-// external lock for reads or writes.
+// Note: Server.stages and Server.sessions are a StageMap and SessionMap
+// (sync.Map-backed), so they require no external lock for reads or writes.
 //
 // Self-contained stores (userBinary, minidata, questCache) manage their
 // own locks internally and may be acquired at any point.
@@ -71,18 +92,23 @@ type Server struct {
 	miscRepo           MiscRepo
 	scenarioRepo       ScenarioRepo
 	mercenaryRepo      MercenaryRepo
+	moderationRepo     ModerationRepo
+	auditRepo          AuditRepo
+	sessionEvents      SessionEventRepo // nil when session events are disabled
 	mailService        *MailService
 	guildService       *GuildService
 	achievementService *AchievementService
 	gachaService       *GachaService
 	towerService       *TowerService
 	festaService       *FestaService
+	moderationService  *ModerationService
 	erupeConfig        *cfg.Config
 	acceptConns        chan net.Conn
 	deleteConns        chan net.Conn
-	sessions           map[net.Conn]*Session
+	sessions           SessionMap
 	listener           net.Listener // Listener that is created when Server.Start is called.
 	isShuttingDown     bool
+	draining           bool          // Set by StopAccepting to close new connections.
 	done               chan struct{} // Closed on Shutdown to wake background goroutines.
 
 	stages StageMap
@@ -93,6 +119,12 @@ type Server struct {
 	userBinary *UserBinaryStore
 	minidata   *MinidataStore
 
+	// Savedata of online characters, shared by all channels; nil if disabled
+	saveCache *SaveDataCache
+
+	// Compresses and writes savedata, shared by all channels; nil if disabled
+	saveWorkers *SaveWorkerPool
+
 	// Semaphore
 	semaphoreLock  sync.RWMutex
 	semaphore      map[string]*Semaphore
@@ -101,13 +133,35 @@ type Server struct {
 	// Discord chat integration
 	discordBot *discordbot.DiscordBot
 
+	// Notable server events (sieges, festa, server firsts) for integrations
+	eventBus *eventbus.Bus
+
 	name string
 
 	raviente *Raviente
 
-	questCache *QuestCache
+	questCache *questcache.Cache
+	opMetrics  *opmetrics.Registry
+	analytics  *analytics.Recorder
+	federation *federation.Federation
+	saveDumps  *savedump.Store
+
+	connLimiter *network.ConnLimiter
+	cipher      crypto.Cipher
+
+	// Inbound packets handled since start, for the admin console
+	packetsReceived atomic.Uint64
+
+	// Workers that enqueue large broadcasts in parallel
+	fanout *fanoutPool
+
+	// Batches non-critical writes; nil writes them immediately
+	asyncWrites *AsyncWriter
 
 	handlerTable map[network.PacketID]handlerFunc
+
+	// What is done with packets that fail validation before their handler
+	packetValidation PacketValidation
 }
 
 // NewServer creates a new Server type.
@@ -120,12 +174,14 @@ func NewServer(config *Config) *Server {
 		acceptConns:    make(chan net.Conn),
 		deleteConns:    make(chan net.Conn),
 		done:           make(chan struct{}),
-		sessions:       make(map[net.Conn]*Session),
 		userBinary:     NewUserBinaryStore(),
 		minidata:       NewMinidataStore(),
 		semaphore:      make(map[string]*Semaphore),
 		semaphoreIndex: 7,
 		discordBot:     config.DiscordBot,
+		eventBus:       config.EventBus,
+		saveCache:      config.SaveCache,
+		saveWorkers:    config.SaveWorkers,
 		name:           config.Name,
 		raviente: &Raviente{
 			id:       1,
@@ -133,11 +189,35 @@ func NewServer(config *Config) *Server {
 			state:    make([]uint32, 30),
 			support:  make([]uint32, 30),
 		},
-		questCache:   NewQuestCache(config.ErupeConfig.QuestCacheExpiry),
+		questCache:   config.QuestCache,
+		opMetrics:    config.OpMetrics,
+		analytics:    config.Analytics,
+		federation:   config.Federation,
+		saveDumps:    config.SaveDumps,
+		connLimiter:  config.ConnLimiter,
+		cipher:       config.Cipher,
 		handlerTable: buildHandlerTable(),
 	}
+	// An unknown setting is refused at startup, so it cannot get this far.
+	s.packetValidation, _ = ParsePacketValidation(config.ErupeConfig.Channel.PacketValidation)
+	s.fanout = newFanoutPool(defaultFanoutWorkers(), s.done)
+	if ms := config.ErupeConfig.Channel.AsyncWriteInterval; ms > 0 {
+		s.asyncWrites = NewAsyncWriter(time.Duration(ms)*time.Millisecond, s.logger)
+		s.asyncWrites.Start()
+	}
+	if s.questCache == nil {
+		s.questCache = questcache.New(config.ErupeConfig.QuestCacheExpiry,
+			config.ErupeConfig.QuestCacheMaxEntries, config.ErupeConfig.QuestCacheMaxBytes)
+	}
+	if s.saveDumps == nil {
+		s.saveDumps = savedump.New(config.ErupeConfig.SaveDumps)
+	}
 
-	s.charRepo = NewCharacterRepository(config.DB)
+	compression, err := CompressionFor(config.ErupeConfig.Compression)
+	if err != nil {
+		s.logger.Warn("Unknown compression algorithm, storing in the client's format", zap.Error(err))
+	}
+	s.charRepo = NewCharacterRepository(config.DB).WithCompression(compression)
 	s.guildRepo = NewGuildRepository(config.DB)
 	s.userRepo = NewUserRepository(config.DB)
 	s.gachaRepo = NewGachaRepository(config.DB)
@@ -158,13 +238,30 @@ func NewServer(config *Config) *Server {
 	s.miscRepo = NewMiscRepository(config.DB)
 	s.scenarioRepo = NewScenarioRepository(config.DB)
 	s.mercenaryRepo = NewMercenaryRepository(config.DB)
+	s.moderationRepo = NewModerationRepository(config.DB)
+	s.auditRepo = audit.NewRepository(config.DB)
+	if config.DB != nil && config.ErupeConfig.SessionEvents.Enabled {
+		s.sessionEvents = sessionlog.NewRepository(config.DB)
+	}
+
+	if replicas := NewReplicaSet(config.ReadDBs); replicas != nil {
+		for _, repo := range []any{
+			s.gachaRepo, s.festaRepo, s.rengokuRepo, s.distRepo,
+			s.eventRepo, s.shopRepo, s.miscRepo, s.scenarioRepo,
+		} {
+			if r, ok := repo.(replicaRouted); ok {
+				r.useReplicas(replicas)
+			}
+		}
+	}
 
 	s.mailService = NewMailService(s.mailRepo, s.guildRepo, s.logger)
 	s.guildService = NewGuildService(s.guildRepo, s.mailService, s.charRepo, s.logger)
 	s.achievementService = NewAchievementService(s.achievementRepo, s.logger)
-	s.gachaService = NewGachaService(s.gachaRepo, s.userRepo, s.charRepo, s.logger, config.ErupeConfig.GameplayOptions.MaximumNP)
+	s.gachaService = NewGachaService(s.gachaRepo, s.userRepo, s.charRepo, s.logger, config.ErupeConfig.Live().GameplayOptions.MaximumNP)
 	s.towerService = NewTowerService(s.towerRepo, s.logger)
 	s.festaService = NewFestaService(s.festaRepo, s.logger)
+	s.moderationService = NewModerationService(s.userRepo, s.moderationRepo, s.logger)
 
 	// Mezeporta
 	s.stages.Store("sl1Ns200p0a0u0", NewStage("sl1Ns200p0a0u0"))
@@ -198,18 +295,30 @@ func (s *Server) Start() error {
 	if err != nil {
 		return err
 	}
-	s.listener = l
+	s.listener, err = network.WithProxyProtocol(l, s.erupeConfig.ProxyProtocol)
+	if err != nil {
+		_ = l.Close()
+		return err
+	}
+	if s.connLimiter != nil {
+		s.listener = s.connLimiter.Wrap(s.listener, false)
+	}
 
-	initCommands(s.erupeConfig.Commands, s.logger)
+	initCommands(s.erupeConfig.Live().Commands, s.logger)
 
 	go s.acceptClients()
 	go s.manageSessions()
 	go s.invalidateSessions()
 
 	// Start the discord bot for chat integration.
-	if s.erupeConfig.Discord.Enabled && s.discordBot != nil {
+	if s.erupeConfig.Discord.Enabled && s.discordBot != nil && !s.discordBot.WebhookOnly() {
 		s.discordBot.Session.AddHandler(s.onDiscordMessage)
-		s.discordBot.Session.AddHandler(s.onInteraction)
+		if s.discordBot.ClaimInteractions() {
+			s.discordBot.Session.AddHandler(s.onInteraction)
+			s.discordBot.Session.AddHandler(s.onGuildBanAdd)
+			s.discordBot.Session.AddHandler(s.onGuildBanRemove)
+			s.discordBot.Session.AddHandler(s.onGuildMemberUpdate)
+		}
 	}
 
 	return nil
@@ -232,6 +341,32 @@ func (s *Server) Shutdown() {
 		_ = s.listener.Close()
 	}
 
+	if s.asyncWrites != nil {
+		s.asyncWrites.Close()
+	}
+}
+
+// writeAsync queues a non-critical write under key (see AsyncWriter.Enqueue),
+// or runs it immediately if async writes are disabled.
+func (s *Server) writeAsync(key string, fn func() error) {
+	if s.asyncWrites != nil {
+		s.asyncWrites.Enqueue(key, fn)
+		return
+	}
+	if err := fn(); err != nil {
+		s.logger.Error("Write failed", zap.Error(err))
+	}
+}
+
+// updatePlayerCountAsync records the channel's player count. The count is
+// read when the write runs, so queued updates collapse into the latest.
+func (s *Server) updatePlayerCountAsync() {
+	s.writeAsync("player_count", func() error {
+		if err := s.sessionRepo.UpdatePlayerCount(s.ID, s.sessions.Len()); err != nil {
+			return fmt.Errorf("update player count for server %d: %w", s.ID, err)
+		}
+		return nil
+	})
 }
 
 func (s *Server) acceptClients() {
@@ -249,12 +384,27 @@ func (s *Server) acceptClients() {
 				continue
 			}
 		}
-		select {
-		case s.acceptConns <- conn:
-		case <-s.done:
+		s.Lock()
+		draining := s.draining
+		s.Unlock()
+		if draining {
 			_ = conn.Close()
-			return
+			continue
 		}
+		go s.handOff(conn)
+	}
+}
+
+// handOff passes conn to manageSessions once its remote address is known.
+// Behind a proxy that means reading the PROXY header, which can take up to
+// the header timeout, so it is read here rather than in manageSessions,
+// where it would hold up every session's arrival and departure.
+func (s *Server) handOff(conn net.Conn) {
+	_ = conn.RemoteAddr()
+	select {
+	case s.acceptConns <- conn:
+	case <-s.done:
+		_ = conn.Close()
 	}
 }
 
@@ -266,23 +416,19 @@ func (s *Server) manageSessions() {
 		case newConn := <-s.acceptConns:
 			session := NewSession(s, newConn)
 
-			s.Lock()
-			s.sessions[newConn] = session
-			s.Unlock()
+			s.sessions.Store(newConn, session)
 
 			session.Start()
 
 		case delConn := <-s.deleteConns:
-			s.Lock()
-			delete(s.sessions, delConn)
-			s.Unlock()
+			s.sessions.Delete(delConn)
 		}
 	}
 }
 
 func (s *Server) getObjectId() uint16 {
 	ids := make(map[uint16]struct{})
-	for _, sess := range s.sessions {
+	for _, sess := range s.sessions.Snapshot() {
 		ids[sess.objectID] = struct{}{}
 	}
 	for i := uint16(1); i < 100; i++ {
@@ -290,56 +436,30 @@ func (s *Server) getObjectId() uint16 {
 			return i
 		}
 	}
-	s.logger.Warn("object ids overflowed", zap.Int("sessions", len(s.sessions)))
+	s.logger.Warn("object ids overflowed", zap.Int("sessions", s.sessions.Len()))
 	return 0
 }
 
+// invalidateSessions times out dead and idle sessions until the server stops.
 func (s *Server) invalidateSessions() {
-	ticker := time.NewTicker(10 * time.Second)
+	ticker := time.NewTicker(idleCheckInterval)
 	defer ticker.Stop()
 	for {
 		select {
 		case <-s.done:
 			return
-		case <-ticker.C:
-		}
-
-		s.Lock()
-		var timedOut []*Session
-		for _, sess := range s.sessions {
-			if time.Since(sess.lastPacket) > time.Second*time.Duration(30) {
-				timedOut = append(timedOut, sess)
+		case now := <-ticker.C:
+			t := s.idleTimeouts()
+			for _, sess := range s.sessions.Snapshot() {
+				sess.checkIdle(now, t)
 			}
 		}
-		s.Unlock()
-
-		for _, sess := range timedOut {
-			s.logger.Info("session timeout", zap.String("Name", sess.Name))
-			logoutPlayer(sess)
-		}
 	}
 }
 
 // BroadcastMHF queues a MHFPacket to be sent to all sessions.
 func (s *Server) BroadcastMHF(pkt mhfpacket.MHFPacket, ignoredSession *Session) {
-	// Broadcast the data.
-	s.Lock()
-	defer s.Unlock()
-	for _, session := range s.sessions {
-		if session == ignoredSession {
-			continue
-		}
-
-		// Make the header
-		bf := byteframe.NewByteFrame()
-		bf.WriteUint16(uint16(pkt.Opcode()))
-
-		// Build the packet onto the byteframe.
-		_ = pkt.Build(bf, session.clientContext)
-
-		// Enqueue in a non-blocking way that drops the packet if the connections send buffer channel is full.
-		session.QueueSendNonBlocking(bf.Data())
-	}
+	broadcastTo(s.sessions.Snapshot(), ignoredSession, pkt)
 }
 
 // WorldcastMHF broadcasts a packet to all sessions across all channel servers.
@@ -349,6 +469,11 @@ func (s *Server) WorldcastMHF(pkt mhfpacket.MHFPacket, ignoredSession *Session,
 
 // BroadcastChatMessage broadcasts a simple chat message to all the sessions.
 func (s *Server) BroadcastChatMessage(message string) {
+	s.BroadcastMHF(s.serverChatPacket(message), nil)
+}
+
+// serverChatPacket builds a server-originated chat message packet.
+func (s *Server) serverChatPacket(message string) *mhfpacket.MsgSysCastedBinary {
 	bf := byteframe.NewByteFrame()
 	bf.SetLE()
 	msgBinChat := &binpacket.MsgBinChat{
@@ -360,18 +485,46 @@ func (s *Server) BroadcastChatMessage(message string) {
 	}
 	_ = msgBinChat.Build(bf)
 
-	s.BroadcastMHF(&mhfpacket.MsgSysCastedBinary{
+	return &mhfpacket.MsgSysCastedBinary{
 		MessageType:    BinaryMessageTypeChat,
 		RawDataPayload: bf.Data(),
-	}, nil)
+	}
 }
 
-// DiscordChannelSend sends a chat message to the configured Discord channel.
+// DiscordChannelSend sends a world chat message to the Discord channels
+// mapped to the world scope.
 func (s *Server) DiscordChannelSend(charName string, content string) {
+	s.DiscordRelaySend(discordbot.ScopeWorld, 0, charName, content)
+}
+
+// DiscordRelaySend sends a chat message from the given in-game scope to the
+// Discord channels mapped to it.
+func (s *Server) DiscordRelaySend(scope string, guildID uint32, charName string, content string) {
 	if s.erupeConfig.Discord.Enabled && s.discordBot != nil {
-		message := fmt.Sprintf("**%s**: %s", charName, content)
-		_ = s.discordBot.RealtimeChannelSend(message)
+		if err := s.discordBot.RelayToDiscord(scope, guildID, charName, content); err != nil {
+			s.logger.Warn("Failed to relay chat to Discord", zap.String("scope", scope), zap.Error(err))
+		}
+	}
+}
+
+// DiscordSyncRoles re-syncs the Discord roles of the given account in the
+// background, after its rights or courses changed.
+func (s *Server) DiscordSyncRoles(userID uint32) {
+	if s.erupeConfig.Discord.Enabled && s.discordBot != nil {
+		go s.discordBot.SyncUserRoles(userID)
+	}
+}
+
+// DiscordNotifyOffline queues a Discord DM for the owner of charID when the
+// character is not online on any channel.
+func (s *Server) DiscordNotifyOffline(charID uint32, text string) {
+	if !s.erupeConfig.Discord.Enabled || s.discordBot == nil || !s.erupeConfig.Discord.Notifications.Enabled {
+		return
+	}
+	if s.FindSessionByCharID(charID) != nil {
+		return
 	}
+	go s.discordBot.QueueNotification(charID, text)
 }
 
 // DiscordScreenShotSend sends a screenshot link to the configured Discord channel.
@@ -8,8 +8,10 @@ import (
 	"time"
 
 	cfg "erupe-ce/config"
+	"erupe-ce/network"
 	"erupe-ce/network/clientctx"
 	"erupe-ce/network/mhfpacket"
+	"erupe-ce/server/questcache"
 
 	"go.uber.org/zap"
 )
@@ -57,9 +59,8 @@ func createTestServer() *Server {
 	s := &Server{
 		ID:         1,
 		logger:     logger,
-		sessions:   make(map[net.Conn]*Session),
 		semaphore:  make(map[string]*Semaphore),
-		questCache: NewQuestCache(0),
+		questCache: questcache.New(0, 0, 0),
 		erupeConfig: &cfg.Config{
 			DebugOptions: cfg.DebugOptions{
 				LogOutboundMessages: false,
@@ -87,7 +88,6 @@ func createTestSessionForServer(server *Server, conn net.Conn, charID uint32, na
 		cryptConn:     mock,
 		sendPackets:   make(chan packet, 20),
 		clientContext: &clientctx.ClientContext{},
-		lastPacket:    time.Now(),
 		charID:        charID,
 		Name:          name,
 	}
@@ -178,22 +178,16 @@ func TestSessionTimeout(t *testing.T) {
 			session := createTestSessionForServer(server, conn, 1, "TestChar")
 
 			// Set last packet time in the past
-			session.lastPacket = time.Now().Add(-tt.lastPacketAge)
+			session.lastPacket.Store(time.Now().Add(-tt.lastPacketAge).UnixNano())
 
-			server.Lock()
-			server.sessions[conn] = session
-			server.Unlock()
+			server.sessions.Store(conn, session)
 
 			// Run one iteration of session invalidation
-			for _, sess := range server.sessions {
-				if time.Since(sess.lastPacket) > time.Second*time.Duration(60) {
-					server.logger.Info("session timeout", zap.String("Name", sess.Name))
-					// Don't actually call logoutPlayer in test, just mark as closed
-					sess.closed.Store(true)
-				}
+			for _, sess := range server.sessions.Snapshot() {
+				sess.checkIdle(time.Now(), idleTimeouts{keepalive: 60 * time.Second})
 			}
 
-			gotTimeout := session.closed.Load()
+			gotTimeout := conn.WasClosed()
 			if gotTimeout != tt.wantTimeout {
 				t.Errorf("session timeout = %v, want %v (age: %v)", gotTimeout, tt.wantTimeout, tt.lastPacketAge)
 			}
@@ -216,9 +210,7 @@ func TestBroadcastMHF(t *testing.T) {
 		// Start the send loop for this session
 		go sessions[i].sendLoop()
 
-		server.Lock()
-		server.sessions[conn] = sessions[i]
-		server.Unlock()
+		server.sessions.Store(conn, sessions[i])
 	}
 
 	// Create a test packet
@@ -266,9 +258,7 @@ func TestBroadcastMHFAllSessions(t *testing.T) {
 		// Start the send loop
 		go session.sendLoop()
 
-		server.Lock()
-		server.sessions[conn] = session
-		server.Unlock()
+		server.sessions.Store(conn, session)
 	}
 
 	// Broadcast to all sessions
@@ -285,7 +275,7 @@ func TestBroadcastMHFAllSessions(t *testing.T) {
 
 	// Verify all sessions received the packet
 	receivedCount := 0
-	for _, sess := range server.sessions {
+	for _, sess := range server.sessions.Snapshot() {
 		mock := sess.cryptConn.(*MockCryptConn)
 		if mock.PacketCount() > 0 {
 			receivedCount++
@@ -308,9 +298,7 @@ func TestFindSessionByCharID(t *testing.T) {
 		conn := &mockConn{remoteAddr: &net.TCPAddr{IP: net.ParseIP("127.0.0.1"), Port: int(30000 + charID)}}
 		session := createTestSessionForServer(server, conn, charID, fmt.Sprintf("Char%d", charID))
 
-		server.Lock()
-		server.sessions[conn] = session
-		server.Unlock()
+		server.sessions.Store(conn, session)
 	}
 
 	tests := []struct {
@@ -591,9 +579,7 @@ func TestBroadcastChatMessage(t *testing.T) {
 	// Start the send loop
 	go session.sendLoop()
 
-	server.Lock()
-	server.sessions[conn] = session
-	server.Unlock()
+	server.sessions.Store(conn, session)
 
 	// Broadcast a message
 	server.BroadcastChatMessage("Test message")
@@ -638,17 +624,13 @@ func TestConcurrentSessionAccess(t *testing.T) {
 			conn := &mockConn{remoteAddr: &net.TCPAddr{IP: net.ParseIP("127.0.0.1"), Port: 40000 + id}}
 			session := createTestSessionForServer(server, conn, uint32(id), fmt.Sprintf("Concurrent%d", id))
 
-			server.Lock()
-			server.sessions[conn] = session
-			server.Unlock()
+			server.sessions.Store(conn, session)
 		}(i)
 	}
 	wg.Wait()
 
 	// Verify all sessions were added
-	server.Lock()
-	count := len(server.sessions)
-	server.Unlock()
+	count := server.sessions.Len()
 
 	if count != iterations {
 		t.Errorf("Session count = %d, want %d", count, iterations)
@@ -659,9 +641,7 @@ func TestConcurrentSessionAccess(t *testing.T) {
 	for i := 0; i < iterations; i++ {
 		go func() {
 			defer wg.Done()
-			server.Lock()
-			_ = len(server.sessions)
-			server.Unlock()
+			_ = server.sessions.Len()
 		}()
 	}
 	wg.Wait()
@@ -727,3 +707,45 @@ func TestFindObjectByChar(t *testing.T) {
 		})
 	}
 }
+
+// A proxied client that never sends its PROXY header must not hold up the
+// clients after it.
+func TestAcceptClients_SlowProxyHeader(t *testing.T) {
+	s := createTestServer()
+	inner, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("cannot listen: %v", err)
+	}
+	s.listener, err = network.WithProxyProtocol(inner, cfg.ProxyProtocolOptions{Enabled: true, TrustedProxies: []string{"127.0.0.1"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.done = make(chan struct{})
+	s.acceptConns = make(chan net.Conn)
+	go s.acceptClients()
+	defer s.Shutdown()
+
+	silent, err := net.Dial("tcp", inner.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = silent.Close() }()
+	client, err := net.Dial("tcp", inner.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = client.Close() }()
+	if _, err := client.Write([]byte("PROXY TCP4 203.0.113.7 10.0.0.2 51234 53310\r\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case conn := <-s.acceptConns:
+		defer func() { _ = conn.Close() }()
+		if got := conn.RemoteAddr().String(); got != "203.0.113.7:51234" {
+			t.Errorf("first connection handed over is from %s, want the client with a header", got)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("a silent connection held up the client behind it")
+	}
+}
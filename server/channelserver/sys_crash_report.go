@@ -0,0 +1,155 @@
+package channelserver
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"time"
+
+	"erupe-ce/network"
+	"erupe-ce/network/mhfpacket"
+
+	"go.uber.org/zap"
+)
+
+// crashPacketDataMax caps the bytes kept per packet in the recent packet
+// ring, so a session full of large uploads does not pin much memory.
+const crashPacketDataMax = 512
+
+// inboundPacket is a packet group received by a session, as kept in its
+// recent packet ring.
+type inboundPacket struct {
+	Time   time.Time `json:"time"`
+	Opcode string    `json:"opcode"`
+	Size   int       `json:"size"`
+	Data   string    `json:"data"` // Hex, truncated to crashPacketDataMax bytes
+}
+
+// packetRing keeps the last few packets a session received. It is only
+// touched by the session's receive loop, so it needs no locking.
+type packetRing struct {
+	buf  []inboundPacket
+	next int
+	full bool
+}
+
+func newPacketRing(size int) *packetRing {
+	if size <= 0 {
+		return nil
+	}
+	return &packetRing{buf: make([]inboundPacket, size)}
+}
+
+// record adds a packet group, overwriting the oldest once the ring is full.
+func (r *packetRing) record(opcode network.PacketID, data []byte) {
+	if r == nil {
+		return
+	}
+	size := len(data)
+	if len(data) > crashPacketDataMax {
+		data = data[:crashPacketDataMax]
+	}
+	r.buf[r.next] = inboundPacket{
+		Time:   time.Now(),
+		Opcode: opcode.String(),
+		Size:   size,
+		Data:   hex.EncodeToString(data),
+	}
+	r.next++
+	if r.next == len(r.buf) {
+		r.next = 0
+		r.full = true
+	}
+}
+
+// packets returns the recorded packets, oldest first.
+func (r *packetRing) packets() []inboundPacket {
+	if r == nil {
+		return nil
+	}
+	if !r.full {
+		return append([]inboundPacket(nil), r.buf[:r.next]...)
+	}
+	return append(append([]inboundPacket(nil), r.buf[r.next:]...), r.buf[:r.next]...)
+}
+
+// crashReport describes a handler panic with enough context to reproduce it.
+type crashReport struct {
+	Time   time.Time       `json:"time"`
+	Panic  string          `json:"panic"`
+	Opcode string          `json:"opcode"`
+	Packet string          `json:"packet,omitempty"` // Decoded fields, if parsing got that far
+	CharID uint32          `json:"char_id"`
+	Name   string          `json:"name"`
+	Remote string          `json:"remote"`
+	Mode   string          `json:"client_mode"`
+	Recent []inboundPacket `json:"recent_packets"`
+	Stack  string          `json:"stack"`
+}
+
+// reportCrash logs a recovered handler panic along with the packet that
+// caused it, and writes a crash report file when CrashReportDir is set.
+// pkt is nil if the panic happened before the packet was parsed.
+func (s *Session) reportCrash(r any, opcode network.PacketID, pkt mhfpacket.MHFPacket) {
+	report := crashReport{
+		Time:   time.Now(),
+		Panic:  fmt.Sprint(r),
+		Opcode: opcode.String(),
+		CharID: s.charID,
+		Name:   s.Name,
+		Mode:   s.server.erupeConfig.RealClientMode.String(),
+		Recent: s.recentPackets.packets(),
+		Stack:  string(debug.Stack()),
+	}
+	if pkt != nil {
+		report.Packet = fmt.Sprintf("%+v", pkt)
+	}
+	if s.rawConn != nil {
+		report.Remote = s.rawConn.RemoteAddr().String()
+	}
+
+	fields := []zap.Field{
+		zap.String("name", s.Name),
+		zap.Uint32("charID", s.charID),
+		zap.Stringer("opcode", opcode),
+		zap.Any("panic", r),
+	}
+	if report.Packet != "" {
+		fields = append(fields, zap.String("packet", report.Packet))
+	}
+	path, err := writeCrashReport(s.server.erupeConfig.DebugOptions.CrashReportDir, report)
+	if err != nil {
+		fields = append(fields, zap.NamedError("reportError", err))
+	} else if path != "" {
+		fields = append(fields, zap.String("report", path))
+	}
+	s.logger.Error("Recovered from panic", fields...)
+}
+
+// writeCrashReport writes report as JSON into dir and returns the file's
+// path. It writes nothing and returns an empty path if dir is empty.
+func writeCrashReport(dir string, report crashReport) (string, error) {
+	if dir == "" {
+		return "", nil
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	name := fmt.Sprintf("crash_%s_%d_%s.json",
+		report.Time.Format("20060102_150405.000"),
+		report.CharID,
+		report.Opcode,
+	)
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return "", err
+	}
+	return path, nil
+}
@@ -0,0 +1,107 @@
+package channelserver
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"erupe-ce/common/byteframe"
+	"erupe-ce/network"
+	"erupe-ce/network/mhfpacket"
+)
+
+func TestPacketRing_KeepsNewestInOrder(t *testing.T) {
+	r := newPacketRing(3)
+	for _, op := range []network.PacketID{network.MSG_SYS_PING, network.MSG_SYS_TIME, network.MSG_SYS_NOP, network.MSG_SYS_END} {
+		r.record(op, []byte{byte(op)})
+	}
+
+	got := r.packets()
+	want := []string{network.MSG_SYS_TIME.String(), network.MSG_SYS_NOP.String(), network.MSG_SYS_END.String()}
+	if len(got) != len(want) {
+		t.Fatalf("packets() returned %d packets, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if got[i].Opcode != want[i] {
+			t.Errorf("packets()[%d] = %s, want %s", i, got[i].Opcode, want[i])
+		}
+	}
+}
+
+func TestPacketRing_TruncatesData(t *testing.T) {
+	r := newPacketRing(1)
+	r.record(network.MSG_SYS_PING, make([]byte, crashPacketDataMax*2))
+
+	got := r.packets()[0]
+	if got.Size != crashPacketDataMax*2 {
+		t.Errorf("Size = %d, want %d", got.Size, crashPacketDataMax*2)
+	}
+	if len(got.Data) != crashPacketDataMax*2 { // Two hex digits per byte
+		t.Errorf("kept %d hex digits, want %d", len(got.Data), crashPacketDataMax*2)
+	}
+}
+
+func TestPacketRing_Disabled(t *testing.T) {
+	r := newPacketRing(0)
+	r.record(network.MSG_SYS_PING, []byte{1})
+	if got := r.packets(); got != nil {
+		t.Errorf("packets() = %v, want nil", got)
+	}
+}
+
+func TestHandlePacketGroup_WritesCrashReport(t *testing.T) {
+	dir := t.TempDir()
+	server := createMockServer()
+	server.erupeConfig.DebugOptions.CrashReportDir = dir
+	server.handlerTable = map[network.PacketID]handlerFunc{
+		network.MSG_SYS_PING: func(s *Session, p mhfpacket.MHFPacket) { panic("boom") },
+	}
+	session := createMockSession(42, server)
+	session.ackStart = make(map[uint32]time.Time)
+	session.recentPackets = newPacketRing(4)
+
+	bf := byteframe.NewByteFrame()
+	bf.WriteUint16(uint16(network.MSG_SYS_PING))
+	bf.WriteUint32(0xCAFE)
+	session.handlePacketGroup(bf.Data())
+
+	files, err := filepath.Glob(filepath.Join(dir, "crash_*.json"))
+	if err != nil || len(files) != 1 {
+		t.Fatalf("found crash reports %v (err %v), want one", files, err)
+	}
+	data, err := os.ReadFile(files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	var report crashReport
+	if err := json.Unmarshal(data, &report); err != nil {
+		t.Fatalf("crash report is not valid JSON: %v", err)
+	}
+
+	if report.Panic != "boom" || report.CharID != 42 {
+		t.Errorf("report = panic %q, char %d; want boom, 42", report.Panic, report.CharID)
+	}
+	if report.Opcode != network.MSG_SYS_PING.String() {
+		t.Errorf("Opcode = %s, want %s", report.Opcode, network.MSG_SYS_PING)
+	}
+	if !strings.Contains(report.Packet, "AckHandle:51966") {
+		t.Errorf("Packet = %q, want the decoded ack handle", report.Packet)
+	}
+	if len(report.Recent) != 1 || report.Recent[0].Data != hex.EncodeToString(bf.Data()) {
+		t.Errorf("Recent = %+v, want the packet that crashed", report.Recent)
+	}
+	if !strings.Contains(report.Stack, "sys_crash_report_test.go") {
+		t.Error("Stack does not reach the panicking handler")
+	}
+}
+
+func TestWriteCrashReport_NoDir(t *testing.T) {
+	path, err := writeCrashReport("", crashReport{})
+	if path != "" || err != nil {
+		t.Errorf("writeCrashReport(\"\") = %q, %v; want nothing written", path, err)
+	}
+}
@@ -0,0 +1,42 @@
+package channelserver
+
+import (
+	"fmt"
+
+	"erupe-ce/server/federation"
+)
+
+// ServeFederation delivers the chat and guild announcements of allied
+// servers to the channels of this process, and tells them who is online
+// here. Allied servers reach every process of a cluster, so each delivers
+// to its own channels only.
+func ServeFederation(f *federation.Federation, channels []*Server) {
+	if f == nil {
+		return
+	}
+	f.OnChat = func(c federation.Chat) {
+		lines := splitChatLines(fmt.Sprintf("[%s] %s: %s", c.Server, c.Sender, c.Message), 61)
+		for _, ch := range channels {
+			for _, line := range lines {
+				ch.BroadcastChatMessage(line)
+			}
+		}
+	}
+	f.OnGuildAnnouncement = func(guildID uint32, a federation.GuildAnnouncement) {
+		lines := splitChatLines(fmt.Sprintf("[%s] %s: %s", a.Server, a.GuildName, a.Title), 61)
+		for _, ch := range channels {
+			ch.sendGuildChatLines(guildID, lines)
+		}
+	}
+	f.Players = func() []string {
+		names := []string{}
+		for _, ch := range channels {
+			for _, session := range ch.sessions.Snapshot() {
+				if session.charID != 0 {
+					names = append(names, session.Name)
+				}
+			}
+		}
+		return names
+	}
+}
@@ -0,0 +1,42 @@
+package channelserver
+
+import (
+	"slices"
+	"testing"
+
+	cfg "erupe-ce/config"
+	"erupe-ce/server/federation"
+)
+
+func TestServeFederation(t *testing.T) {
+	server := createMockServer()
+	server.guildRepo = &mockGuildRepo{members: []*GuildMember{{CharID: 100}}}
+	memberConn, outsiderConn, loginConn := &mockConn{}, &mockConn{}, &mockConn{}
+	member := createTestSessionForServer(server, memberConn, 100, "Member")
+	outsider := createTestSessionForServer(server, outsiderConn, 200, "Outsider")
+	server.sessions.Store(memberConn, member)
+	server.sessions.Store(outsiderConn, outsider)
+	// A session still logging in has no character yet.
+	server.sessions.Store(loginConn, createTestSessionForServer(server, loginConn, 0, ""))
+
+	f := federation.New(cfg.FederationOptions{Name: "here"}, nil)
+	ServeFederation(f, []*Server{server})
+
+	players := f.Players()
+	slices.Sort(players)
+	if !slices.Equal(players, []string{"Member", "Outsider"}) {
+		t.Errorf("Players() = %v", players)
+	}
+
+	f.OnGuildAnnouncement(1, federation.GuildAnnouncement{Server: "there", GuildName: "Hunters", Title: "Raid tonight"})
+	if got := len(member.sendPackets); got != 1 {
+		t.Errorf("member received %d packets, want 1", got)
+	}
+	if got := len(outsider.sendPackets); got != 0 {
+		t.Errorf("outsider received %d packets, want 0", got)
+	}
+}
+
+func TestServeFederation_Nil(t *testing.T) {
+	ServeFederation(nil, createTestChannels(1))
+}
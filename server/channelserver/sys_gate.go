@@ -0,0 +1,89 @@
+package channelserver
+
+import (
+	"errors"
+	"fmt"
+
+	"erupe-ce/common/mhfcourse"
+	"erupe-ce/network"
+)
+
+// Errors a packet is refused with when the session may not send it yet.
+var (
+	ErrPacketState  = errors.New("packet not allowed in session state")
+	ErrPacketRights = errors.New("packet needs a course the account lacks")
+)
+
+// sessionState is how far a session has got, from connecting to questing.
+// Each state includes the ones before it.
+type sessionState int
+
+const (
+	statePreLogin  sessionState = iota // Connected, no character logged in yet
+	stateCharacter                     // Logged in to a character
+	stateQuest                         // In a quest stage
+)
+
+func (st sessionState) String() string {
+	switch st {
+	case statePreLogin:
+		return "pre-login"
+	case stateCharacter:
+		return "character"
+	case stateQuest:
+		return "quest"
+	}
+	return fmt.Sprintf("sessionState(%d)", int(st))
+}
+
+// packetGate is what a session needs before it may send an opcode.
+type packetGate struct {
+	state  sessionState // Least state the session must have reached
+	course uint16       // Course the account must hold, 0 for none
+}
+
+// packetGates lists the opcodes whose needs differ from the default of a
+// logged in character. Only opcodes a capture shows the client never sends
+// otherwise belong here, since each refusal counts towards
+// Channel.MaxPacketViolations. Net Café bonus claims are sent by accounts
+// without the course too, and answered with an empty bonus.
+var packetGates = map[network.PacketID]packetGate{
+	network.MSG_SYS_LOGIN: {state: statePreLogin},
+}
+
+// gateFor returns what a session needs to send opcode. The keepalives the
+// server ignores may be sent at any time.
+func gateFor(opcode network.PacketID) packetGate {
+	if g, ok := packetGates[opcode]; ok {
+		return g
+	}
+	if ignored(opcode) {
+		return packetGate{state: statePreLogin}
+	}
+	return packetGate{state: stateCharacter}
+}
+
+// state returns how far the session has got.
+func (s *Session) state() sessionState {
+	if s.charID == 0 {
+		return statePreLogin
+	}
+	s.Lock()
+	defer s.Unlock()
+	if s.stage != nil && isQuestStage(s.stage.id) {
+		return stateQuest
+	}
+	return stateCharacter
+}
+
+// checkGate reports whether the session may send opcode now.
+func (s *Session) checkGate(opcode network.PacketID) error {
+	g := gateFor(opcode)
+	if st := s.state(); st < g.state {
+		return fmt.Errorf("%w: %s, needs %s", ErrPacketState, st, g.state)
+	}
+	if g.course != 0 && !mhfcourse.CourseExists(g.course, s.courses) {
+		return fmt.Errorf("%w: %d", ErrPacketRights, g.course)
+	}
+	return nil
+}
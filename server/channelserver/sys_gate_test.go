@@ -0,0 +1,88 @@
+package channelserver
+
+import (
+	"errors"
+	"testing"
+
+	"erupe-ce/common/mhfcourse"
+	"erupe-ce/network"
+)
+
+func TestCheckGate(t *testing.T) {
+	// No opcode is gated by quest or course yet, so gate two for the test.
+	const questOnly, courseOnly = network.MSG_SYS_OPERATE_REGISTER, network.MSG_MHF_RECEIVE_CAFE_DURATION_BONUS
+	packetGates[questOnly] = packetGate{state: stateQuest}
+	packetGates[courseOnly] = packetGate{state: stateCharacter, course: 30}
+	t.Cleanup(func() {
+		delete(packetGates, questOnly)
+		delete(packetGates, courseOnly)
+	})
+
+	server := createMockServer()
+	town := &Stage{id: "sl1Ns200p0a0u0"}
+	quest := &Stage{id: "sl2Qs001p0a0u0"}
+	cafe := []mhfcourse.Course{{ID: 30}}
+
+	tests := []struct {
+		name    string
+		charID  uint32
+		stage   *Stage
+		courses []mhfcourse.Course
+		opcode  network.PacketID
+		want    error
+	}{
+		{"login before login", 0, nil, nil, network.MSG_SYS_LOGIN, nil},
+		{"ping before login", 0, nil, nil, network.MSG_SYS_PING, nil},
+		{"enter stage before login", 0, nil, nil, network.MSG_SYS_ENTER_STAGE, ErrPacketState},
+		{"savedata before login", 0, nil, nil, network.MSG_MHF_SAVEDATA, ErrPacketState},
+		{"enter stage after login", 1, town, nil, network.MSG_SYS_ENTER_STAGE, nil},
+		{"quest packet in town", 1, town, nil, questOnly, ErrPacketState},
+		{"quest packet in quest", 1, quest, nil, questOnly, nil},
+		{"town packet in quest", 1, quest, nil, network.MSG_MHF_SAVEDATA, nil},
+		{"course packet without course", 1, town, nil, courseOnly, ErrPacketRights},
+		{"course packet with course", 1, town, cafe, courseOnly, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			session := createMockSession(tt.charID, server)
+			session.stage = tt.stage
+			session.courses = tt.courses
+			err := session.checkGate(tt.opcode)
+			if !errors.Is(err, tt.want) || (tt.want == nil && err != nil) {
+				t.Errorf("checkGate(%s) = %v, want %v", tt.opcode, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestGateFor_Ungated(t *testing.T) {
+	// Accounts without Net Café claim the bonus too, and get an empty one.
+	// No capture shows where Raviente registers are sent from.
+	for _, opcode := range []network.PacketID{network.MSG_SYS_OPERATE_REGISTER, network.MSG_MHF_RECEIVE_CAFE_DURATION_BONUS} {
+		if g := gateFor(opcode); g != (packetGate{state: stateCharacter}) {
+			t.Errorf("gateFor(%s) = %+v, want a logged in character only", opcode, g)
+		}
+	}
+}
+
+func TestHandlePacketGroup_RejectsPacketBeforeLogin(t *testing.T) {
+	handled := 0
+	session := validationSession(PacketValidationReject, &handled)
+	session.charID = 0
+
+	session.handlePacketGroup(enterStageGroup(0x55, "sl1Ns200p0a0u0"))
+	if handled != 0 {
+		t.Error("handler ran before login")
+	}
+	select {
+	case p := <-session.sendPackets:
+		if len(p.data) < 2 || network.PacketID(uint16(p.data[0])<<8|uint16(p.data[1])) != network.MSG_SYS_ACK {
+			t.Errorf("answered with % X, want an ACK", p.data)
+		}
+	default:
+		t.Error("refused packet was not answered")
+	}
+	if got := session.server.opMetrics.PacketsRejected()["state"]; got != 1 {
+		t.Errorf("state rejections = %d, want 1", got)
+	}
+}
@@ -0,0 +1,90 @@
+package channelserver
+
+import (
+	"fmt"
+	"time"
+
+	"erupe-ce/network"
+
+	"go.uber.org/zap"
+)
+
+// idleCheckInterval is how often the channel looks for dead and idle
+// sessions, which bounds how late a timeout or warning can be.
+const idleCheckInterval = 10 * time.Second
+
+// keepaliveOpcodes are the packets the client sends on its own while the game
+// is open. They show the connection is alive but not that a player is at it.
+var keepaliveOpcodes = map[network.PacketID]struct{}{
+	network.MSG_SYS_END:              {},
+	network.MSG_SYS_PING:             {},
+	network.MSG_SYS_NOP:              {},
+	network.MSG_SYS_TIME:             {},
+	network.MSG_SYS_EXTEND_THRESHOLD: {},
+}
+
+// idleTimeouts are the Channel keepalive and idle settings.
+type idleTimeouts struct {
+	keepalive time.Duration // Silence before a session is dropped as dead
+	idle      time.Duration // Inactivity before a player is disconnected
+	warning   time.Duration // Warning given before the idle disconnect
+}
+
+func (s *Server) idleTimeouts() idleTimeouts {
+	c := s.erupeConfig.Live().Channel
+	return idleTimeouts{
+		keepalive: time.Duration(c.KeepaliveTimeout) * time.Second,
+		idle:      time.Duration(c.IdleTimeout) * time.Second,
+		warning:   time.Duration(c.IdleWarning) * time.Second,
+	}
+}
+
+// touch records a packet received at now. Only packets other than
+// keepalives count as player activity.
+func (s *Session) touch(now time.Time, opcode network.PacketID) {
+	s.lastPacket.Store(now.UnixNano())
+	if _, keepalive := keepaliveOpcodes[opcode]; !keepalive {
+		s.lastActive.Store(now.UnixNano())
+		s.idleWarned.Store(false)
+	}
+}
+
+// since returns the time from the Unix nanoseconds t to now.
+func since(now time.Time, t int64) time.Duration {
+	return now.Sub(time.Unix(0, t))
+}
+
+// checkIdle disconnects the session if its client has stopped sending
+// packets, as happens when the game crashes or the network drops without
+// closing the connection, or if the player has been idle for too long. A
+// player nearing the idle timeout is warned once in chat.
+func (s *Session) checkIdle(now time.Time, t idleTimeouts) {
+	if silent := since(now, s.lastPacket.Load()); t.keepalive > 0 && silent > t.keepalive {
+		s.timeOut("keepalive", silent)
+		return
+	}
+	if t.idle <= 0 || s.charID == 0 {
+		return
+	}
+	idle := since(now, s.lastActive.Load())
+	if idle > t.idle {
+		s.timeOut("idle", idle)
+		return
+	}
+	if t.warning > 0 && idle > t.idle-t.warning && !s.idleWarned.Swap(true) {
+		left := (t.idle - idle).Round(time.Second)
+		sendServerChatMessage(s, fmt.Sprintf(s.server.i18n.idleWarning, int(left.Seconds())))
+	}
+}
+
+// timeOut closes the connection of a timed out session. The receive loop
+// then fails and logs the player out, saving their data.
+func (s *Session) timeOut(reason string, after time.Duration) {
+	s.logger.Info("Session timed out",
+		zap.Uint32("charID", s.charID),
+		zap.String("name", s.Name),
+		zap.String("reason", reason),
+		zap.Duration("after", after),
+	)
+	_ = s.rawConn.Close()
+}
@@ -0,0 +1,73 @@
+package channelserver
+
+import (
+	"testing"
+	"time"
+
+	"erupe-ce/network"
+)
+
+func TestSessionTouch(t *testing.T) {
+	session := createMockSession(1, createMockServer())
+	start := time.Now()
+	session.touch(start, network.MSG_MHF_SAVEDATA)
+
+	session.idleWarned.Store(true)
+	session.touch(start.Add(time.Minute), network.MSG_SYS_PING)
+	if got := since(start.Add(time.Minute), session.lastActive.Load()); got != time.Minute {
+		t.Errorf("keepalive counted as activity, idle for %s", got)
+	}
+	if got := since(start.Add(time.Minute), session.lastPacket.Load()); got != 0 {
+		t.Errorf("keepalive not recorded, silent for %s", got)
+	}
+	if !session.idleWarned.Load() {
+		t.Error("keepalive reset the idle warning")
+	}
+
+	session.touch(start.Add(2*time.Minute), network.MSG_SYS_POSITION_OBJECT)
+	if session.idleWarned.Load() || session.lastActive.Load() != session.lastPacket.Load() {
+		t.Error("movement was not counted as activity")
+	}
+}
+
+func TestCheckIdle(t *testing.T) {
+	timeouts := idleTimeouts{keepalive: 30 * time.Second, idle: 10 * time.Minute, warning: time.Minute}
+	tests := []struct {
+		name       string
+		charID     uint32
+		silent     time.Duration
+		idle       time.Duration
+		wantClosed bool
+		wantWarned bool
+	}{
+		{"active", 1, time.Second, time.Second, false, false},
+		{"dead connection", 1, 31 * time.Second, 31 * time.Second, true, false},
+		{"nearly idle", 1, time.Second, 9*time.Minute + 30*time.Second, false, true},
+		{"idle", 1, time.Second, 11 * time.Minute, true, false},
+		{"idle before login", 0, time.Second, 11 * time.Minute, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := createMockServer()
+			conn := &mockConn{}
+			session := createTestSessionForServer(server, conn, tt.charID, "TestChar")
+			now := time.Now()
+			session.lastPacket.Store(now.Add(-tt.silent).UnixNano())
+			session.lastActive.Store(now.Add(-tt.idle).UnixNano())
+
+			session.checkIdle(now, timeouts)
+			if conn.WasClosed() != tt.wantClosed {
+				t.Errorf("closed = %v, want %v", conn.WasClosed(), tt.wantClosed)
+			}
+			if warned := len(session.sendPackets) > 0; warned != tt.wantWarned {
+				t.Errorf("warned = %v, want %v", warned, tt.wantWarned)
+			}
+
+			// The warning is given once.
+			session.checkIdle(now, timeouts)
+			if len(session.sendPackets) > 1 {
+				t.Errorf("warned %d times", len(session.sendPackets))
+			}
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package channelserver
+
+import (
+	"math/rand"
+	"time"
+)
+
+// MaxLag bounds the delay and the jitter of a simulated bad connection, so a
+// typo cannot leave a session effectively frozen.
+const MaxLag = 10 * time.Second
+
+// Lag is a bad connection simulated on the packets sent to one session, set
+// from the admin console to reproduce client behaviour behind desync
+// reports. Each packet is held for Delay plus up to Jitter before it is
+// sent. Packets keep their order, so a burst takes longer to drain, as it
+// would on a slow link.
+type Lag struct {
+	Delay  time.Duration
+	Jitter time.Duration
+}
+
+// IsZero reports whether l simulates nothing.
+func (l Lag) IsZero() bool {
+	return l.Delay <= 0 && l.Jitter <= 0
+}
+
+// next returns how long to hold the next packet.
+func (l Lag) next() time.Duration {
+	d := l.Delay
+	if l.Jitter > 0 {
+		d += time.Duration(rand.Int63n(int64(l.Jitter) + 1))
+	}
+	return d
+}
+
+// setLag sets the simulated connection of the session; a zero Lag clears it.
+func (s *Session) setLag(l Lag) {
+	if l.IsZero() {
+		s.lag.Store(nil)
+		return
+	}
+	s.lag.Store(&l)
+}
+
+// currentLag returns the simulated connection of the session.
+func (s *Session) currentLag() Lag {
+	if l := s.lag.Load(); l != nil {
+		return *l
+	}
+	return Lag{}
+}
@@ -5,13 +5,19 @@ type i18n struct {
 	cafe     struct {
 		reset string
 	}
-	timer    string
-	commands struct {
+	timer       string
+	muted       string
+	idleWarning string
+	commands    struct {
 		noOp     string
 		disabled string
 		reload   string
 		playtime string
-		kqf      struct {
+		allies   struct {
+			none   string
+			online string
+		}
+		kqf struct {
 			get string
 			set struct {
 				error   string
@@ -78,6 +84,13 @@ type i18n struct {
 		extremeLimited string
 		berserkSmall   string
 	}
+	save struct {
+		rejected string
+		restored struct {
+			title string
+			body  string
+		}
+	}
 	guild struct {
 		invite struct {
 			title   string
@@ -109,10 +122,14 @@ func getLangStrings(s *Server) i18n {
 		i.language = "日本語"
 		i.cafe.reset = "%d/%dにリセット"
 		i.timer = "タイマー：%02d'%02d\"%02d.%03d (%df)"
+		i.muted = "%sまでチャットが制限されています"
+		i.idleWarning = "操作がないため、%d秒後に切断されます"
 
 		i.commands.noOp = "You don't have permission to use this command"
 		i.commands.disabled = "%sのコマンドは無効です"
 		i.commands.reload = "リロードします"
+		i.commands.allies.none = "同盟サーバーに接続していません"
+		i.commands.allies.online = "[%s] %d人オンライン：%s"
 		i.commands.kqf.get = "現在のキークエストフラグ：%x"
 		i.commands.kqf.set.error = "キークエコマンドエラー　例：%s set xxxxxxxxxxxxxxxx"
 		i.commands.kqf.set.success = "キークエストのフラグが更新されました。ワールド／ランドを移動してください"
@@ -154,6 +171,10 @@ func getLangStrings(s *Server) i18n {
 		i.raviente.extremeLimited = "<大討伐：猛狂期【極】(制限付)>が開催されました！"
 		i.raviente.berserkSmall = "<大討伐：猛狂期(小数)>が開催されました！"
 
+		i.save.rejected = "セーブデータの破損を検出したため、保存されませんでした。"
+		i.save.restored.title = "セーブデータ復元"
+		i.save.restored.body = "セーブデータの破損を検出したため、\n最新のバックアップから復元しました。\n直近の進行状況が失われている可能性があります。"
+
 		i.guild.invite.title = "猟団勧誘のご案内"
 		i.guild.invite.body = "猟団「%s」からの勧誘通知です。\n「勧誘に返答」より、返答を行ってください。"
 
@@ -172,11 +193,15 @@ func getLangStrings(s *Server) i18n {
 		i.language = "English"
 		i.cafe.reset = "Resets on %d/%d"
 		i.timer = "Time: %02d:%02d:%02d.%03d (%df)"
+		i.muted = "You are muted until %s"
+		i.idleWarning = "You will be disconnected for inactivity in %d seconds"
 
 		i.commands.noOp = "You don't have permission to use this command"
 		i.commands.disabled = "%s command is disabled"
 		i.commands.reload = "Reloading players..."
 		i.commands.playtime = "Playtime: %d hours %d minutes %d seconds"
+		i.commands.allies.none = "No allied servers are connected"
+		i.commands.allies.online = "[%s] %d online: %s"
 
 		i.commands.kqf.get = "KQF: %x"
 		i.commands.kqf.set.error = "Error in command. Format: %s set xxxxxxxxxxxxxxxx"
@@ -222,6 +247,10 @@ func getLangStrings(s *Server) i18n {
 		i.raviente.extremeLimited = "<Great Slaying: Extreme (Limited)> is being held!"
 		i.raviente.berserkSmall = "<Great Slaying: Berserk (Small)> is being held!"
 
+		i.save.rejected = "Your save was found to be corrupted and was not written"
+		i.save.restored.title = "Save Restored"
+		i.save.restored.body = "Your save was found to be corrupted\nand has been restored from the latest\nbackup. Recent progress may be lost."
+
 		i.guild.invite.title = "Invitation!"
 		i.guild.invite.body = "You have been invited to join\n「%s」\nDo you want to accept?"
 
@@ -0,0 +1,55 @@
+package channelserver
+
+import (
+	"erupe-ce/common/byteframe"
+)
+
+// listPageMaxBytes caps the size of a paged list response. The client asks
+// for the next page by offset, so entries past the cap are not lost.
+const listPageMaxBytes = 60000
+
+// listPage decides, entry by entry, which entries of a list response belong
+// in the page the client asked for. Entries are written straight into the
+// response as they are produced instead of collecting the whole list first,
+// and the page stops growing once the next entry would push the response
+// past maxBytes.
+type listPage struct {
+	bf       *byteframe.ByteFrame
+	offset   int
+	limit    int
+	maxBytes int
+	total    int
+	returned int
+	full     bool
+}
+
+// newListPage starts a page of at most limit entries, skipping the first
+// offset, in a response written to bf.
+func newListPage(bf *byteframe.ByteFrame, offset, limit, maxBytes int) *listPage {
+	return &listPage{bf: bf, offset: offset, limit: limit, maxBytes: maxBytes}
+}
+
+// Next counts an entry of size bytes toward the list total and reports
+// whether the caller should write it now.
+func (p *listPage) Next(size int) bool {
+	p.total++
+	if p.full || p.total <= p.offset {
+		return false
+	}
+	if p.returned >= p.limit || len(p.bf.Data())+size > p.maxBytes {
+		p.full = true
+		return false
+	}
+	p.returned++
+	return true
+}
+
+// Total returns the number of entries counted so far, written or not.
+func (p *listPage) Total() uint16 {
+	return uint16(p.total)
+}
+
+// Returned returns the number of entries written to the page.
+func (p *listPage) Returned() uint16 {
+	return uint16(p.returned)
+}
@@ -0,0 +1,137 @@
+package channelserver
+
+import (
+	"testing"
+
+	"erupe-ce/common/byteframe"
+	cfg "erupe-ce/config"
+)
+
+func TestListPage_OffsetAndLimit(t *testing.T) {
+	bf := byteframe.NewByteFrame()
+	page := newListPage(bf, 2, 3, listPageMaxBytes)
+
+	var written []int
+	for i := 0; i < 10; i++ {
+		if page.Next(4) {
+			written = append(written, i)
+			bf.WriteUint32(uint32(i))
+		}
+	}
+
+	want := []int{2, 3, 4}
+	if len(written) != len(want) {
+		t.Fatalf("written = %v, want %v", written, want)
+	}
+	for i := range want {
+		if written[i] != want[i] {
+			t.Fatalf("written = %v, want %v", written, want)
+		}
+	}
+	if page.Total() != 10 {
+		t.Errorf("Total() = %d, want 10", page.Total())
+	}
+	if page.Returned() != 3 {
+		t.Errorf("Returned() = %d, want 3", page.Returned())
+	}
+}
+
+func TestListPage_StopsAtByteCap(t *testing.T) {
+	bf := byteframe.NewByteFrame()
+	bf.WriteUint16(0) // Header counts toward the cap
+	page := newListPage(bf, 0, 100, 32)
+
+	for i := 0; i < 10; i++ {
+		if page.Next(10) {
+			bf.WriteBytes(make([]byte, 10))
+		}
+	}
+	if got := len(bf.Data()); got > 32 {
+		t.Errorf("response is %d bytes, want at most 32", got)
+	}
+	if page.Returned() != 3 {
+		t.Errorf("Returned() = %d, want 3", page.Returned())
+	}
+
+	// A smaller entry after the page filled must not be squeezed in, or the
+	// client's next offset would skip the entries before it.
+	if page.Next(1) {
+		t.Error("Next() accepted an entry after the page filled")
+	}
+	if page.Total() != 11 {
+		t.Errorf("Total() = %d, want 11", page.Total())
+	}
+}
+
+func TestShopItemSize(t *testing.T) {
+	for _, mode := range []cfg.Mode{cfg.S6, cfg.F5, cfg.Z1, cfg.Z2, cfg.ZZ} {
+		bf := byteframe.NewByteFrame()
+		writeShopItem(bf, ShopItem{}, mode)
+		if got := len(bf.Data()); got != shopItemSize(mode) {
+			t.Errorf("mode %v: writeShopItem wrote %d bytes, shopItemSize = %d", mode, got, shopItemSize(mode))
+		}
+	}
+}
+
+func TestWriteShopItemPage(t *testing.T) {
+	items := make([]ShopItem, 10)
+	for i := range items {
+		items[i].ID = uint32(i + 1)
+	}
+
+	bf := byteframe.NewByteFrame()
+	writeShopItemPage(bf, items, cfg.ZZ, 4)
+
+	r := byteframe.NewByteFrameFromBytes(bf.Data())
+	if n := r.ReadUint16(); n != 4 {
+		t.Fatalf("count = %d, want 4", n)
+	}
+	if n := r.ReadUint16(); n != 4 {
+		t.Fatalf("second count = %d, want 4", n)
+	}
+	if got, want := len(bf.Data()), 4+4*shopItemSize(cfg.ZZ); got != want {
+		t.Errorf("response is %d bytes, want %d", got, want)
+	}
+	for i := uint32(1); i <= 4; i++ {
+		if id := r.ReadUint32(); id != i {
+			t.Fatalf("item ID = %d, want %d", id, i)
+		}
+		r.ReadBytes(uint(shopItemSize(cfg.ZZ) - 4))
+	}
+}
+
+func TestWriteShopItemPage_CapsLargeShops(t *testing.T) {
+	items := make([]ShopItem, 5000)
+
+	bf := byteframe.NewByteFrame()
+	writeShopItemPage(bf, items, cfg.ZZ, 0xFFFF)
+	if got := len(bf.Data()); got > listPageMaxBytes {
+		t.Errorf("response is %d bytes, want at most %d", got, listPageMaxBytes)
+	}
+}
+
+// benchShopItems is a catalog large enough that the client only asks for a
+// slice of it.
+var benchShopItems = make([]ShopItem, 5000)
+
+// BenchmarkShopItemsWholeList measures the previous approach of truncating
+// the list to the page and writing it in one go.
+func BenchmarkShopItemsWholeList(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		bf := byteframe.NewByteFrame()
+		items := benchShopItems
+		if len(items) > 0xFFFF {
+			items = items[:0xFFFF]
+		}
+		writeShopItems(bf, items, cfg.ZZ)
+	}
+}
+
+func BenchmarkShopItemsPaged(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		bf := byteframe.NewByteFrame()
+		writeShopItemPage(bf, benchShopItems, cfg.ZZ, 0xFFFF)
+	}
+}
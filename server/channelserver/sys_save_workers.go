@@ -0,0 +1,74 @@
+package channelserver
+
+import (
+	"sync"
+)
+
+// saveWorkerQueueSize is the number of saves each worker queues before
+// Submit blocks, pushing back on the saving session instead of buffering
+// without bound.
+const saveWorkerQueueSize = 64
+
+// SaveWorkerPool compresses and writes savedata off the session goroutines,
+// so a burst of saves does not stall packet processing. Jobs for the same
+// character always run on the same worker in submission order, so a later
+// save can never reach the database before an earlier one.
+type SaveWorkerPool struct {
+	mu     sync.RWMutex // Held for reading while enqueueing, for writing by Close
+	closed bool
+	queues []chan func()
+	wg     sync.WaitGroup
+}
+
+// NewSaveWorkerPool starts the given number of workers.
+func NewSaveWorkerPool(workers int) *SaveWorkerPool {
+	p := &SaveWorkerPool{queues: make([]chan func(), max(1, workers))}
+	for i := range p.queues {
+		q := make(chan func(), saveWorkerQueueSize)
+		p.queues[i] = q
+		p.wg.Add(1)
+		go func() {
+			defer p.wg.Done()
+			for job := range q {
+				job()
+			}
+		}()
+	}
+	return p
+}
+
+// Submit queues job on the worker for charID. After Close, job runs on the
+// calling goroutine.
+func (p *SaveWorkerPool) Submit(charID uint32, job func()) {
+	p.mu.RLock()
+	if p.closed {
+		p.mu.RUnlock()
+		job()
+		return
+	}
+	p.queues[charID%uint32(len(p.queues))] <- job
+	p.mu.RUnlock()
+}
+
+// Wait blocks until every job submitted for charID so far has finished.
+// Call it before reading savedata back from the database.
+func (p *SaveWorkerPool) Wait(charID uint32) {
+	done := make(chan struct{})
+	p.Submit(charID, func() { close(done) })
+	<-done
+}
+
+// Close runs every queued job and stops the workers.
+func (p *SaveWorkerPool) Close() {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return
+	}
+	p.closed = true
+	for _, q := range p.queues {
+		close(q)
+	}
+	p.mu.Unlock()
+	p.wg.Wait()
+}
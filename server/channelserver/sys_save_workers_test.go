@@ -0,0 +1,92 @@
+package channelserver
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestSaveWorkerPool_OrdersJobsPerCharacter(t *testing.T) {
+	p := NewSaveWorkerPool(4)
+	defer p.Close()
+
+	var mu sync.Mutex
+	got := make(map[uint32][]int)
+	for i := 0; i < 50; i++ {
+		for charID := uint32(1); charID <= 3; charID++ {
+			p.Submit(charID, func() {
+				mu.Lock()
+				got[charID] = append(got[charID], i)
+				mu.Unlock()
+			})
+		}
+	}
+	for charID := uint32(1); charID <= 3; charID++ {
+		p.Wait(charID)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	for charID, seq := range got {
+		if len(seq) != 50 {
+			t.Fatalf("char %d ran %d jobs, want 50", charID, len(seq))
+		}
+		for i, v := range seq {
+			if v != i {
+				t.Fatalf("char %d jobs ran out of order: %v", charID, seq)
+			}
+		}
+	}
+}
+
+func TestSaveWorkerPool_WaitBlocksOnPendingSave(t *testing.T) {
+	p := NewSaveWorkerPool(2)
+	defer p.Close()
+
+	release := make(chan struct{})
+	var done bool
+	p.Submit(7, func() {
+		<-release
+		done = true
+	})
+
+	waited := make(chan struct{})
+	go func() {
+		p.Wait(7)
+		close(waited)
+	}()
+	select {
+	case <-waited:
+		t.Fatal("Wait returned before the pending save finished")
+	default:
+	}
+	close(release)
+	<-waited
+	if !done {
+		t.Error("Wait returned before the save ran")
+	}
+}
+
+func TestSaveWorkerPool_CloseDrainsQueue(t *testing.T) {
+	p := NewSaveWorkerPool(1)
+	var mu sync.Mutex
+	ran := 0
+	for i := 0; i < 10; i++ {
+		p.Submit(1, func() {
+			mu.Lock()
+			ran++
+			mu.Unlock()
+		})
+	}
+	p.Close()
+	if ran != 10 {
+		t.Errorf("ran %d jobs before Close returned, want 10", ran)
+	}
+
+	// Jobs submitted after Close run inline.
+	p.Submit(1, func() { ran++ })
+	if ran != 11 {
+		t.Errorf("job submitted after Close did not run")
+	}
+	p.Wait(1)
+	p.Close()
+}
@@ -1,7 +1,6 @@
 package channelserver
 
 import (
-	"erupe-ce/common/byteframe"
 	"erupe-ce/network/mhfpacket"
 
 	"sync"
@@ -40,20 +39,9 @@ func NewSemaphore(s *Session, ID string, MaxPlayers uint16) *Semaphore {
 
 // BroadcastMHF queues a MHFPacket to be sent to all sessions in the Semaphore
 func (s *Semaphore) BroadcastMHF(pkt mhfpacket.MHFPacket, ignoredSession *Session) {
-	// Broadcast the data.
+	sessions := make([]*Session, 0, len(s.clients))
 	for session := range s.clients {
-		if session == ignoredSession {
-			continue
-		}
-
-		// Make the header
-		bf := byteframe.NewByteFrame()
-		bf.WriteUint16(uint16(pkt.Opcode()))
-
-		// Build the packet onto the byteframe.
-		_ = pkt.Build(bf, session.clientContext)
-
-		// Enqueue in a non-blocking way that drops the packet if the connections send buffer channel is full.
-		session.QueueSendNonBlocking(bf.Data())
+		sessions = append(sessions, session)
 	}
+	broadcastTo(sessions, ignoredSession, pkt)
 }
@@ -1,6 +1,7 @@
 package channelserver
 
 import (
+	"bytes"
 	"encoding/binary"
 	"encoding/hex"
 	"erupe-ce/common/mhfcourse"
@@ -17,6 +18,7 @@ import (
 	"erupe-ce/network/clientctx"
 	"erupe-ce/network/mhfpacket"
 	"erupe-ce/network/pcap"
+	"erupe-ce/server/sessionlog"
 
 	"go.uber.org/zap"
 )
@@ -24,6 +26,7 @@ import (
 type packet struct {
 	data        []byte
 	nonBlocking bool
+	coalesceKey uint64 // Non-zero if a newer packet in coalesced replaces data
 }
 
 // Session holds state for the channel server connection.
@@ -35,7 +38,9 @@ type Session struct {
 	cryptConn     network.Conn
 	sendPackets   chan packet
 	clientContext *clientctx.ClientContext
-	lastPacket    time.Time
+	lastPacket    atomic.Int64 // Unix nanoseconds of the last packet received, keepalives included
+	lastActive    atomic.Int64 // Unix nanoseconds of the last packet other than a keepalive
+	idleWarned    atomic.Bool  // Set once the player has been warned of the idle timeout
 
 	objectID    uint16
 	objectIndex uint16
@@ -44,6 +49,7 @@ type Session struct {
 	stage            *Stage
 	reservationStage *Stage // Required for the stateful MsgSysUnreserveStage packet.
 	stagePass        string // Temporary storage
+	questFile        string // Last quest file sent, recorded in save dump indexes
 	prevGuildID      uint32 // Stores the last GuildID used in InfoGuild
 	charID           uint32
 	userID           uint32
@@ -56,6 +62,7 @@ type Session struct {
 
 	playtime     uint32
 	playtimeTime time.Time
+	mutedUntil   time.Time
 
 	semaphore     *Semaphore // Required for the stateful MsgSysUnreserveStage packet.
 	semaphoreMode bool
@@ -71,16 +78,26 @@ type Session struct {
 	// Contains the mail list that maps accumulated indexes to mail IDs
 	mailList []int
 
-	Name           string
-	closed         atomic.Bool
-	ackStart       map[uint32]time.Time
-	captureConn    *pcap.RecordingConn // non-nil when capture is active
-	captureCleanup func()              // Called on session close to flush/close capture file
+	Name             string
+	closed           atomic.Bool
+	ackStart         map[uint32]time.Time
+	captureConn      *pcap.RecordingConn // non-nil when capture is active
+	captureCleanup   func()              // Called on session close to flush/close capture file
+	traceParent      atomic.Value        // trace.SpanContext of the packet being handled
+	ackFailed        atomic.Bool         // Set when the handler being run sends a failure ACK
+	recentPackets    *packetRing         // Last inbound packets, for crash reports
+	packetViolations int                 // Packets that failed validation, only touched by the receive loop
+	lag              atomic.Pointer[Lag] // Simulated bad connection set from the console, nil for none
+
+	// Latest data of coalesced packets still waiting in sendPackets, by key
+	coalesceMu sync.Mutex
+	coalesced  map[uint64][]byte
 }
 
 // NewSession creates a new Session type.
 func NewSession(server *Server, conn net.Conn) *Session {
-	var cryptConn network.Conn = network.NewCryptConn(conn, server.erupeConfig.RealClientMode, server.logger.Named(conn.RemoteAddr().String()))
+	var cryptConn network.Conn = network.NewCipherConn(conn, server.erupeConfig.RealClientMode, server.cipher, server.logger.Named(conn.RemoteAddr().String()))
+	cryptConn = network.WithFaults(cryptConn, conn, server.erupeConfig.DebugOptions.FaultInjection)
 
 	cryptConn, captureConn, captureCleanup := startCapture(server, cryptConn, conn.RemoteAddr(), pcap.ServerTypeChannel)
 
@@ -91,7 +108,6 @@ func NewSession(server *Server, conn net.Conn) *Session {
 		cryptConn:      cryptConn,
 		sendPackets:    make(chan packet, 20),
 		clientContext:  &clientctx.ClientContext{RealClientMode: server.erupeConfig.RealClientMode},
-		lastPacket:     time.Now(),
 		objectID:       server.getObjectId(),
 		sessionStart:   TimeAdjusted().Unix(),
 		stageMoveStack: stringstack.New(),
@@ -99,13 +115,18 @@ func NewSession(server *Server, conn net.Conn) *Session {
 		semaphoreID:    make([]uint16, 2),
 		captureConn:    captureConn,
 		captureCleanup: captureCleanup,
+		recentPackets:  newPacketRing(server.erupeConfig.DebugOptions.CrashReportPackets),
 	}
+	now := time.Now().UnixNano()
+	s.lastPacket.Store(now)
+	s.lastActive.Store(now)
 	return s
 }
 
 // Start starts the session packet send and recv loop(s).
 func (s *Session) Start() {
 	s.logger.Debug("New connection", zap.String("RemoteAddr", s.rawConn.RemoteAddr().String()))
+	s.recordEvent(sessionlog.EventConnect, nil)
 	// Unlike the sign and entrance server,
 	// the client DOES NOT initalize the channel connection with 8 NULL bytes.
 	go s.sendLoop()
@@ -117,13 +138,13 @@ func (s *Session) QueueSend(data []byte) {
 	if len(data) >= 2 {
 		s.logMessage(binary.BigEndian.Uint16(data[0:2]), data, "Server", s.Name)
 	}
-	s.sendPackets <- packet{data, true}
+	s.sendPackets <- packet{data: data, nonBlocking: true}
 }
 
 // QueueSendNonBlocking queues a packet (raw []byte) to be sent, dropping the packet entirely if the queue is full.
 func (s *Session) QueueSendNonBlocking(data []byte) {
 	select {
-	case s.sendPackets <- packet{data, true}:
+	case s.sendPackets <- packet{data: data, nonBlocking: true}:
 		if len(data) >= 2 {
 			s.logMessage(binary.BigEndian.Uint16(data[0:2]), data, "Server", s.Name)
 		}
@@ -132,39 +153,68 @@ func (s *Session) QueueSendNonBlocking(data []byte) {
 	}
 }
 
-// QueueSendMHF queues a MHFPacket to be sent.
-func (s *Session) QueueSendMHF(pkt mhfpacket.MHFPacket) {
-	// Make the header
-	bf := byteframe.NewByteFrame()
-	bf.WriteUint16(uint16(pkt.Opcode()))
+// QueueSendCoalesced queues a packet (raw []byte) that supersedes any earlier
+// packet with the same key not yet sent. The newest data is sent in the queue
+// position of the first, and the packet is dropped if the queue is full.
+func (s *Session) QueueSendCoalesced(key uint64, data []byte) {
+	s.coalesceMu.Lock()
+	if _, pending := s.coalesced[key]; pending {
+		s.coalesced[key] = data
+		s.coalesceMu.Unlock()
+		return
+	}
+	if s.coalesced == nil {
+		s.coalesced = make(map[uint64][]byte)
+	}
+	s.coalesced[key] = data
+	s.coalesceMu.Unlock()
 
-	// Build the packet onto the byteframe.
-	_ = pkt.Build(bf, s.clientContext)
+	select {
+	case s.sendPackets <- packet{data: data, nonBlocking: true, coalesceKey: key}:
+		if len(data) >= 2 {
+			s.logMessage(binary.BigEndian.Uint16(data[0:2]), data, "Server", s.Name)
+		}
+	default:
+		s.coalesceMu.Lock()
+		delete(s.coalesced, key)
+		s.coalesceMu.Unlock()
+		s.logger.Warn("Packet queue too full, dropping!")
+	}
+}
 
+// takeCoalesced returns the newest data queued under key and clears it so
+// the next packet with that key is queued again.
+func (s *Session) takeCoalesced(key uint64, queued []byte) []byte {
+	s.coalesceMu.Lock()
+	defer s.coalesceMu.Unlock()
+	data, ok := s.coalesced[key]
+	if !ok {
+		return queued
+	}
+	delete(s.coalesced, key)
+	return data
+}
+
+// QueueSendMHF queues a MHFPacket to be sent.
+func (s *Session) QueueSendMHF(pkt mhfpacket.MHFPacket) {
 	// Queue it.
-	s.QueueSend(bf.Data())
+	s.QueueSend(buildPacket(pkt, s.clientContext))
 }
 
 // QueueSendMHFNonBlocking queues a MHFPacket to be sent, dropping the packet entirely if the queue is full.
 func (s *Session) QueueSendMHFNonBlocking(pkt mhfpacket.MHFPacket) {
-	// Make the header
-	bf := byteframe.NewByteFrame()
-	bf.WriteUint16(uint16(pkt.Opcode()))
-
-	// Build the packet onto the byteframe.
-	_ = pkt.Build(bf, s.clientContext)
-
 	// Queue it.
-	s.QueueSendNonBlocking(bf.Data())
+	s.QueueSendNonBlocking(buildPacket(pkt, s.clientContext))
 }
 
 // QueueAck is a helper function to queue an MSG_SYS_ACK with the given ack handle and data.
 func (s *Session) QueueAck(ackHandle uint32, data []byte) {
-	bf := byteframe.NewByteFrame()
+	bf := byteframe.Acquire()
 	bf.WriteUint16(uint16(network.MSG_SYS_ACK))
 	bf.WriteUint32(ackHandle)
 	bf.WriteBytes(data)
-	s.QueueSend(bf.Data())
+	s.QueueSend(bytes.Clone(bf.Data()))
+	bf.Release()
 }
 
 func (s *Session) sendLoop() {
@@ -175,7 +225,14 @@ func (s *Session) sendLoop() {
 		// Send each packet individually with its own terminator
 		for len(s.sendPackets) > 0 {
 			pkt := <-s.sendPackets
-			err := s.cryptConn.SendPacket(append(pkt.data, []byte{0x00, 0x10}...))
+			if l := s.lag.Load(); l != nil {
+				time.Sleep(l.next())
+			}
+			data := pkt.data
+			if pkt.coalesceKey != 0 {
+				data = s.takeCoalesced(pkt.coalesceKey, data)
+			}
+			err := s.cryptConn.SendPacket(append(data, []byte{0x00, 0x10}...))
 			if err != nil {
 				s.logger.Warn("Failed to send packet", zap.Error(err))
 			}
@@ -209,6 +266,7 @@ func (s *Session) recvLoop() {
 				zap.String("disconnect_type", "connection_lost"),
 				zap.Duration("session_duration", sessionDuration),
 			)
+			s.closed.Store(true) // Stop the send loop
 			logoutPlayer(s)
 			return
 		} else if err != nil {
@@ -219,16 +277,20 @@ func (s *Session) recvLoop() {
 				zap.String("name", s.Name),
 				zap.String("disconnect_type", "error"),
 			)
+			s.closed.Store(true) // Stop the send loop
 			logoutPlayer(s)
 			return
 		}
+		start := time.Now()
 		s.handlePacketGroup(pkt)
+		if s.captureConn != nil {
+			s.captureConn.Handled(time.Since(start))
+		}
 		time.Sleep(time.Duration(s.server.erupeConfig.LoopDelay) * time.Millisecond)
 	}
 }
 
 func (s *Session) handlePacketGroup(pktGroup []byte) {
-	s.lastPacket = time.Now()
 	bf := byteframe.NewByteFrameFromBytes(pktGroup)
 	opcodeUint16 := bf.ReadUint16()
 	if len(bf.Data()) >= 6 {
@@ -236,11 +298,15 @@ func (s *Session) handlePacketGroup(pktGroup []byte) {
 		_, _ = bf.Seek(2, io.SeekStart)
 	}
 	opcode := network.PacketID(opcodeUint16)
+	s.touch(time.Now(), opcode)
+	s.recentPackets.record(opcode, pktGroup)
+	s.server.packetsReceived.Add(1)
 
 	// This shouldn't be needed, but it's better to recover and let the connection die than to panic the server.
+	var mhfPkt mhfpacket.MHFPacket
 	defer func() {
 		if r := recover(); r != nil {
-			s.logger.Error("Recovered from panic", zap.String("name", s.Name), zap.Any("panic", r))
+			s.reportCrash(r, opcode, mhfPkt)
 		}
 	}()
 
@@ -251,9 +317,10 @@ func (s *Session) handlePacketGroup(pktGroup []byte) {
 		return
 	}
 	// Get the packet parser and handler for this opcode.
-	mhfPkt := mhfpacket.FromOpcode(opcode)
+	mhfPkt = mhfpacket.FromOpcode(opcode)
 	if mhfPkt == nil {
-		s.logger.Warn("Got opcode which we don't know how to parse, can't parse anymore for this group")
+		// Nothing after it can be parsed, so the rest of the group is dropped.
+		s.rejectPacket(opcode, nil, len(pktGroup), ErrUnknownOpcode)
 		return
 	}
 	// Parse the packet.
@@ -266,11 +333,7 @@ func (s *Session) handlePacketGroup(pktGroup []byte) {
 		return
 	}
 	if bf.Err() != nil {
-		s.logger.Warn("Malformed packet (read overflow during parse)",
-			zap.String("name", s.Name),
-			zap.Stringer("opcode", opcode),
-			zap.Error(bf.Err()),
-		)
+		s.rejectPacket(opcode, nil, len(pktGroup), fmt.Errorf("%w: %v", ErrPacketTruncated, bf.Err()))
 		return
 	}
 	// Handle the packet.
@@ -279,7 +342,18 @@ func (s *Session) handlePacketGroup(pktGroup []byte) {
 		s.logger.Warn("No handler for opcode", zap.Stringer("opcode", opcode))
 		return
 	}
-	handler(s, mhfPkt)
+	size := int(bf.Index())
+	err = validatePacket(opcode, mhfPkt, size)
+	if err == nil {
+		err = s.checkGate(opcode)
+	}
+	if err != nil && s.rejectPacket(opcode, mhfPkt, size, err) {
+		if s.closed.Load() {
+			return // Disconnected for too many invalid packets
+		}
+	} else {
+		s.runHandler(handler, opcode, mhfPkt, size)
+	}
 	// If there is more data on the stream that the .Parse method didn't read, then read another packet off it.
 	remainingData := bf.DataFromCurrent()
 	if len(remainingData) >= 2 {
@@ -287,6 +361,23 @@ func (s *Session) handlePacketGroup(pktGroup []byte) {
 	}
 }
 
+// runHandler runs handler for a parsed packet of size bytes, recording its
+// duration and outcome in the server's handler metrics.
+func (s *Session) runHandler(handler handlerFunc, opcode network.PacketID, pkt mhfpacket.MHFPacket, size int) {
+	if m := s.server.opMetrics; m != nil {
+		s.ackFailed.Store(false)
+		start := time.Now()
+		defer func() {
+			r := recover()
+			m.Observe(opcode, time.Since(start), size, r != nil || s.ackFailed.Load())
+			if r != nil {
+				panic(r)
+			}
+		}()
+	}
+	s.traceHandler(handler, opcode, pkt)
+}
+
 var ignoredOpcodes = map[network.PacketID]struct{}{
 	network.MSG_SYS_END:              {},
 	network.MSG_SYS_PING:             {},
@@ -355,6 +446,13 @@ func (s *Session) GetSemaphoreID() uint32 {
 	}
 }
 
+// isMuted reports whether the session's account is currently muted.
+func (s *Session) isMuted() bool {
+	s.Lock()
+	defer s.Unlock()
+	return time.Now().Before(s.mutedUntil)
+}
+
 func (s *Session) isOp() bool {
 	op, err := s.server.userRepo.IsOp(s.userID)
 	if err != nil {
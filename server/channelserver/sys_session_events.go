@@ -0,0 +1,54 @@
+package channelserver
+
+import (
+	"fmt"
+
+	"erupe-ce/server/sessionlog"
+)
+
+// isQuestStage reports whether a stage ID belongs to a quest.
+func isQuestStage(id string) bool {
+	return len(id) >= 5 && id[3:5] == "Qs"
+}
+
+// recordEvent queues a session lifecycle event for the session_events
+// table. detail is stored as JSON and may be nil.
+func (s *Session) recordEvent(event string, detail any) {
+	repo := s.server.sessionEvents
+	if repo == nil {
+		return
+	}
+	subject := s.eventSubject()
+	s.server.writeAsync("", func() error {
+		if err := repo.Record(subject, event, detail); err != nil {
+			return fmt.Errorf("record %s event for char %d: %w", event, subject.CharID, err)
+		}
+		return nil
+	})
+}
+
+func (s *Session) eventSubject() sessionlog.Subject {
+	s.Lock()
+	defer s.Unlock()
+	subject := sessionlog.Subject{
+		Server: fmt.Sprintf("channel-%d", s.server.ID),
+		UserID: s.userID,
+		CharID: s.charID,
+	}
+	if s.rawConn != nil {
+		subject.Remote = s.rawConn.RemoteAddr().String()
+	}
+	return subject
+}
+
+// recordStageEvents records a move between stages, and the start or end of
+// a quest when the move enters or leaves a quest stage.
+func (s *Session) recordStageEvents(from, to string) {
+	if isQuestStage(from) && !isQuestStage(to) {
+		s.recordEvent(sessionlog.EventQuestEnd, map[string]string{"stage": from})
+	}
+	s.recordEvent(sessionlog.EventStage, map[string]string{"from": from, "to": to})
+	if isQuestStage(to) && !isQuestStage(from) {
+		s.recordEvent(sessionlog.EventQuestStart, map[string]string{"stage": to})
+	}
+}
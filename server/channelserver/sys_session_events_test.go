@@ -0,0 +1,56 @@
+package channelserver
+
+import (
+	"slices"
+	"testing"
+
+	"erupe-ce/server/sessionlog"
+)
+
+func TestIsQuestStage(t *testing.T) {
+	for id, want := range map[string]bool{
+		"sl1Qs1p0a0u0":   true,
+		"sl1Ns200p0a0u0": false,
+		"sl1":            false,
+		"":               false,
+	} {
+		if got := isQuestStage(id); got != want {
+			t.Errorf("isQuestStage(%q) = %v, want %v", id, got, want)
+		}
+	}
+}
+
+func TestStageTransfer_RecordsQuestEvents(t *testing.T) {
+	s := createTestSession(&MockCryptConn{sentPackets: make([][]byte, 0)})
+	s.server.sessions = SessionMap{}
+	repo := &mockSessionEventRepo{}
+	s.server.sessionEvents = repo
+	s.charID = 7
+
+	doStageTransfer(s, 1, "sl1Ns200p0a0u0")
+	doStageTransfer(s, 2, "sl1Qs1p0a0u0")
+	doStageTransfer(s, 3, "sl1Qs2p0a0u0")
+	doStageTransfer(s, 4, "sl1Ns200p0a0u0")
+
+	want := []string{
+		sessionlog.EventStage,
+		sessionlog.EventStage, sessionlog.EventQuestStart,
+		sessionlog.EventStage,
+		sessionlog.EventQuestEnd, sessionlog.EventStage,
+	}
+	if got := repo.names(); !slices.Equal(got, want) {
+		t.Fatalf("events = %v, want %v", got, want)
+	}
+	if got := repo.events[4].detail.(map[string]string)["stage"]; got != "sl1Qs2p0a0u0" {
+		t.Errorf("quest end stage = %q, want the last quest stage", got)
+	}
+	if got := repo.events[0].subject.CharID; got != 7 {
+		t.Errorf("subject char = %d, want 7", got)
+	}
+}
+
+func TestRecordEvent_Disabled(t *testing.T) {
+	s := createTestSession(&MockCryptConn{sentPackets: make([][]byte, 0)})
+	s.server.sessions = SessionMap{}
+	doStageTransfer(s, 1, "sl1Qs1p0a0u0") // Must not panic without a repo
+}
@@ -0,0 +1,74 @@
+package channelserver
+
+import (
+	"net"
+	"sync"
+	"sync/atomic"
+)
+
+// SessionMap is a concurrent-safe map of connection → *Session backed by
+// sync.Map. It replaces the former Server.Mutex + map[net.Conn]*Session
+// pattern so broadcasts no longer serialize with joins and leaves.
+//
+// Iteration goes through Snapshot, a copy-on-read slice that is rebuilt only
+// after the set of sessions changes. The zero value is ready to use.
+type SessionMap struct {
+	m        sync.Map
+	count    atomic.Int64
+	gen      atomic.Uint64 // Incremented on every change
+	snapshot atomic.Pointer[sessionSnapshot]
+}
+
+type sessionSnapshot struct {
+	gen      uint64
+	sessions []*Session
+}
+
+// Get returns the session for conn, or (nil, false) if not found.
+func (sm *SessionMap) Get(conn net.Conn) (*Session, bool) {
+	v, ok := sm.m.Load(conn)
+	if !ok {
+		return nil, false
+	}
+	return v.(*Session), true
+}
+
+// Store sets the session for conn.
+func (sm *SessionMap) Store(conn net.Conn, session *Session) {
+	if _, loaded := sm.m.Swap(conn, session); !loaded {
+		sm.count.Add(1)
+	}
+	sm.gen.Add(1)
+}
+
+// Delete removes the session for conn.
+func (sm *SessionMap) Delete(conn net.Conn) {
+	if _, loaded := sm.m.LoadAndDelete(conn); loaded {
+		sm.count.Add(-1)
+		sm.gen.Add(1)
+	}
+}
+
+// Len returns the number of sessions.
+func (sm *SessionMap) Len() int {
+	return int(sm.count.Load())
+}
+
+// Snapshot returns every session at the time of the call. The slice is
+// shared between callers until the map next changes and must not be
+// modified.
+func (sm *SessionMap) Snapshot() []*Session {
+	// Changes bump gen after updating the map, so a snapshot built after
+	// reading gen contains every change up to it.
+	gen := sm.gen.Load()
+	if p := sm.snapshot.Load(); p != nil && p.gen == gen {
+		return p.sessions
+	}
+	sessions := make([]*Session, 0, sm.Len())
+	sm.m.Range(func(_, value any) bool {
+		sessions = append(sessions, value.(*Session))
+		return true
+	})
+	sm.snapshot.Store(&sessionSnapshot{gen: gen, sessions: sessions})
+	return sessions
+}
@@ -0,0 +1,87 @@
+package channelserver
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestSessionMap_StoreGetDelete(t *testing.T) {
+	var sm SessionMap
+	server := createMockServer()
+	conn := &mockConn{}
+	session := createMockSession(1, server)
+
+	sm.Store(conn, session)
+	sm.Store(conn, session) // Replacing does not change the count
+	if got, ok := sm.Get(conn); !ok || got != session {
+		t.Fatal("expected stored session")
+	}
+	if sm.Len() != 1 {
+		t.Errorf("Len() = %d, want 1", sm.Len())
+	}
+
+	sm.Delete(conn)
+	sm.Delete(conn)
+	if _, ok := sm.Get(conn); ok {
+		t.Error("expected miss after delete")
+	}
+	if sm.Len() != 0 {
+		t.Errorf("Len() = %d, want 0", sm.Len())
+	}
+}
+
+func TestSessionMap_Snapshot(t *testing.T) {
+	var sm SessionMap
+	server := createMockServer()
+	conn1, conn2 := &mockConn{}, &mockConn{}
+	sm.Store(conn1, createMockSession(1, server))
+
+	first := sm.Snapshot()
+	if len(first) != 1 {
+		t.Fatalf("len(Snapshot()) = %d, want 1", len(first))
+	}
+	if again := sm.Snapshot(); &again[0] != &first[0] {
+		t.Error("unchanged map should reuse the snapshot")
+	}
+
+	sm.Store(conn2, createMockSession(2, server))
+	if got := sm.Snapshot(); len(got) != 2 {
+		t.Errorf("len(Snapshot()) = %d after store, want 2", len(got))
+	}
+	sm.Delete(conn1)
+	if got := sm.Snapshot(); len(got) != 1 || got[0].charID != 2 {
+		t.Errorf("Snapshot() after delete = %v, want only char 2", got)
+	}
+	if len(first) != 1 {
+		t.Error("earlier snapshots must not change")
+	}
+}
+
+func TestSessionMap_Concurrent(t *testing.T) {
+	var sm SessionMap
+	server := createMockServer()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(2)
+		go func(id int) {
+			defer wg.Done()
+			conn := &mockConn{}
+			sm.Store(conn, createMockSession(uint32(id), server))
+			if id%2 == 0 {
+				sm.Delete(conn)
+			}
+		}(i)
+		go func() {
+			defer wg.Done()
+			for _, s := range sm.Snapshot() {
+				_ = s.charID
+			}
+		}()
+	}
+	wg.Wait()
+
+	if sm.Len() != 25 || len(sm.Snapshot()) != 25 {
+		t.Errorf("Len() = %d, len(Snapshot()) = %d, want 25", sm.Len(), len(sm.Snapshot()))
+	}
+}
@@ -7,6 +7,8 @@ import (
 
 	cfg "erupe-ce/config"
 	"erupe-ce/network"
+	"erupe-ce/network/mhfpacket"
+	"erupe-ce/server/opmetrics"
 	"sync"
 	"testing"
 	"time"
@@ -112,7 +114,7 @@ func TestPacketQueueIndividualSending(t *testing.T) {
 			// Queue multiple packets
 			for i := 0; i < tt.packetCount; i++ {
 				testData := []byte{0x00, byte(i), 0xAA, 0xBB}
-				s.sendPackets <- packet{testData, true}
+				s.sendPackets <- packet{data: testData, nonBlocking: true}
 			}
 
 			// Wait for packets to be processed
@@ -161,9 +163,9 @@ func TestPacketQueueNoConcatenation(t *testing.T) {
 	packet2 := []byte{0x00, 0x02, 0xBB}
 	packet3 := []byte{0x00, 0x03, 0xCC}
 
-	s.sendPackets <- packet{packet1, true}
-	s.sendPackets <- packet{packet2, true}
-	s.sendPackets <- packet{packet3, true}
+	s.sendPackets <- packet{data: packet1, nonBlocking: true}
+	s.sendPackets <- packet{data: packet2, nonBlocking: true}
+	s.sendPackets <- packet{data: packet3, nonBlocking: true}
 
 	time.Sleep(100 * time.Millisecond)
 	s.closed.Store(true)
@@ -235,7 +237,7 @@ func TestPacketTerminatorFormat(t *testing.T) {
 	go s.sendLoop()
 
 	testData := []byte{0x00, 0x01, 0xAA, 0xBB}
-	s.sendPackets <- packet{testData, true}
+	s.sendPackets <- packet{data: testData, nonBlocking: true}
 
 	time.Sleep(100 * time.Millisecond)
 	s.closed.Store(true)
@@ -357,3 +359,26 @@ func TestPacketQueueAckFormat(t *testing.T) {
 		t.Error("ACK packet missing proper terminator")
 	}
 }
+
+func TestRunHandler_RecordsMetrics(t *testing.T) {
+	server := createMockServer()
+	server.opMetrics = opmetrics.New()
+	session := createMockSession(1, server)
+
+	ok := func(s *Session, p mhfpacket.MHFPacket) { doAckSimpleSucceed(s, 1, make([]byte, 4)) }
+	fail := func(s *Session, p mhfpacket.MHFPacket) { doAckSimpleFail(s, 1, make([]byte, 4)) }
+	session.runHandler(fail, network.MSG_MHF_ENUMERATE_QUEST, nil, 16)
+	session.runHandler(ok, network.MSG_MHF_ENUMERATE_QUEST, nil, 16)
+	func() {
+		defer func() { _ = recover() }()
+		session.runHandler(func(*Session, mhfpacket.MHFPacket) { panic("boom") }, network.MSG_MHF_ENUMERATE_QUEST, nil, 16)
+	}()
+
+	stats := server.opMetrics.Snapshot()
+	if len(stats) != 1 {
+		t.Fatalf("recorded %d opcodes, want 1", len(stats))
+	}
+	if got := stats[0]; got.Count != 3 || got.Errors != 2 || got.Bytes != 48 {
+		t.Errorf("stats = %+v, want 3 packets, 2 errors, 48 bytes", got)
+	}
+}
@@ -0,0 +1,131 @@
+package channelserver
+
+import (
+	"fmt"
+	"time"
+
+	cfg "erupe-ce/config"
+
+	"go.uber.org/zap"
+)
+
+var (
+	// shutdownTick is how often DrainChannels counts down and checks on quests.
+	shutdownTick = time.Second
+	// disconnectTimeout bounds how long DrainChannels waits for each channel's
+	// sessions to log out.
+	disconnectTimeout = 30 * time.Second
+)
+
+// StopAccepting makes the channel close new connections as soon as they are
+// accepted, keeping its listener open for the players already connected.
+func (s *Server) StopAccepting() {
+	s.Lock()
+	s.draining = true
+	s.Unlock()
+}
+
+// PlayersOnQuest returns the number of players in a quest stage.
+func (s *Server) PlayersOnQuest() int {
+	n := 0
+	for _, session := range s.sessions.Snapshot() {
+		session.Lock()
+		if session.stage != nil && isQuestStage(session.stage.id) {
+			n++
+		}
+		session.Unlock()
+	}
+	return n
+}
+
+// DisconnectAll closes every session's connection, so each logs out as on a
+// disconnect: its data is saved and its capture file closed. It waits up to
+// timeout for the logouts and returns the number of sessions still left.
+func (s *Server) DisconnectAll(timeout time.Duration) int {
+	for _, session := range s.sessions.Snapshot() {
+		_ = session.rawConn.Close()
+	}
+	deadline := time.Now().Add(timeout)
+	for s.sessions.Len() > 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	return s.sessions.Len()
+}
+
+// DrainChannels empties the channels before a shutdown. It stops them taking
+// new connections, counts down in chat, waits up to the quest timeout for
+// players on quests to return, then logs everyone out so their data is saved.
+// The channels are left to be shut down by the caller.
+func DrainChannels(channels []*Server, opts cfg.ShutdownOptions, logger *zap.Logger) {
+	for _, c := range channels {
+		c.StopAccepting()
+	}
+	broadcast := func(message string) {
+		for _, c := range channels {
+			c.BroadcastChatMessage(message)
+		}
+		logger.Info(message)
+	}
+
+	for remaining := opts.Countdown; remaining > 0; remaining-- {
+		if remaining <= 10 {
+			broadcast(fmt.Sprintf("Shutting down in %d...", remaining))
+		} else if announceCountdown(remaining) {
+			broadcast(fmt.Sprintf("Shutting down in %s.", countdownText(remaining)))
+		}
+		time.Sleep(shutdownTick)
+	}
+
+	onQuest := func() int {
+		n := 0
+		for _, c := range channels {
+			n += c.PlayersOnQuest()
+		}
+		return n
+	}
+	if n := onQuest(); n > 0 && opts.QuestTimeout > 0 {
+		broadcast(fmt.Sprintf("Waiting up to %s for %d player(s) on quests to return.", countdownText(opts.QuestTimeout), n))
+		for waited := 0; ; waited++ {
+			if n = onQuest(); n == 0 {
+				break
+			}
+			if waited >= opts.QuestTimeout {
+				logger.Warn("Gave up waiting for quests to end", zap.Int("players", n))
+				break
+			}
+			if waited > 0 && waited%60 == 0 {
+				broadcast(fmt.Sprintf("Shutting down in at most %s, %d player(s) still on quests.", countdownText(opts.QuestTimeout-waited), n))
+			}
+			time.Sleep(shutdownTick)
+		}
+	}
+
+	for _, c := range channels {
+		if left := c.DisconnectAll(disconnectTimeout); left > 0 {
+			logger.Warn("Sessions did not log out before shutdown", zap.Uint16("channel", c.ID), zap.Int("sessions", left))
+		}
+	}
+}
+
+// announceCountdown reports whether the countdown is announced with this many
+// seconds remaining: every minute, at 30 seconds, and each of the last ten.
+func announceCountdown(remaining int) bool {
+	return remaining%60 == 0 || remaining == 30 || remaining <= 10
+}
+
+// countdownText formats a number of seconds for a chat notice.
+func countdownText(seconds int) string {
+	if seconds >= 60 && seconds%60 == 0 {
+		if seconds == 60 {
+			return "1 minute"
+		}
+		return fmt.Sprintf("%d minutes", seconds/60)
+	}
+	if seconds > 60 {
+		return (time.Duration(seconds) * time.Second).String()
+	}
+	if seconds == 1 {
+		return "1 second"
+	}
+	return fmt.Sprintf("%d seconds", seconds)
+}
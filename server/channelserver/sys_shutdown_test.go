@@ -0,0 +1,101 @@
+package channelserver
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	cfg "erupe-ce/config"
+
+	"go.uber.org/zap"
+)
+
+func TestPlayersOnQuest(t *testing.T) {
+	s := createTestChannels(1)[0]
+	conn1, conn2 := &mockConn{}, &mockConn{}
+	alice := createTestSessionForServer(s, conn1, 100, "Alice")
+	bob := createTestSessionForServer(s, conn2, 200, "Bob")
+	alice.stage = NewStage("sl2Qs123p0a0u42")
+	bob.stage = NewStage("sl1Ns200p0a0u0")
+	s.sessions.Store(conn1, alice)
+	s.sessions.Store(conn2, bob)
+
+	if n := s.PlayersOnQuest(); n != 1 {
+		t.Errorf("PlayersOnQuest() = %d, want 1", n)
+	}
+}
+
+func TestStopAccepting(t *testing.T) {
+	s := createTestServer()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("cannot listen: %v", err)
+	}
+	s.listener = l
+	s.done = make(chan struct{})
+	s.acceptConns = make(chan net.Conn, 1)
+	s.StopAccepting()
+	go s.acceptClients()
+	defer s.Shutdown()
+
+	conn, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = conn.Close() }()
+	_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if _, err := conn.Read(make([]byte, 1)); err == nil {
+		t.Error("connection to a draining channel was kept open")
+	}
+	if len(s.acceptConns) != 0 {
+		t.Error("connection to a draining channel was handed to a session")
+	}
+}
+
+func TestDrainChannels(t *testing.T) {
+	oldTick, oldTimeout := shutdownTick, disconnectTimeout
+	shutdownTick, disconnectTimeout = time.Millisecond, 10*time.Millisecond
+	defer func() { shutdownTick, disconnectTimeout = oldTick, oldTimeout }()
+
+	s := createTestChannels(1)[0]
+	conn1, conn2 := &mockConn{}, &mockConn{}
+	alice := createTestSessionForServer(s, conn1, 100, "Alice")
+	alice.stage = NewStage("sl2Qs123p0a0u42")
+	s.sessions.Store(conn1, alice)
+	s.sessions.Store(conn2, createTestSessionForServer(s, conn2, 200, "Bob"))
+
+	DrainChannels([]*Server{s}, cfg.ShutdownOptions{Countdown: 2, QuestTimeout: 1}, zap.NewNop())
+
+	if !s.draining {
+		t.Error("channel still takes new connections")
+	}
+	// Two countdown notices, then one about the player on a quest.
+	if n := len(alice.sendPackets); n != 3 {
+		t.Errorf("Alice was sent %d notices, want 3", n)
+	}
+	if !conn1.WasClosed() || !conn2.WasClosed() {
+		t.Error("sessions were not disconnected")
+	}
+}
+
+func TestCountdownText(t *testing.T) {
+	for _, tt := range []struct {
+		seconds int
+		want    string
+	}{
+		{1, "1 second"},
+		{30, "30 seconds"},
+		{60, "1 minute"},
+		{300, "5 minutes"},
+		{90, "1m30s"},
+	} {
+		if got := countdownText(tt.seconds); got != tt.want {
+			t.Errorf("countdownText(%d) = %q, want %q", tt.seconds, got, tt.want)
+		}
+	}
+	for remaining, want := range map[int]bool{600: true, 90: false, 30: true, 11: false, 3: true} {
+		if got := announceCountdown(remaining); got != want {
+			t.Errorf("announceCountdown(%d) = %v, want %v", remaining, got, want)
+		}
+	}
+}
@@ -3,7 +3,6 @@ package channelserver
 import (
 	"sync"
 
-	"erupe-ce/common/byteframe"
 	"erupe-ce/network/mhfpacket"
 )
 
@@ -117,21 +116,11 @@ func NewStage(ID string) *Stage {
 
 // BroadcastMHF queues a MHFPacket to be sent to all sessions in the stage.
 func (s *Stage) BroadcastMHF(pkt mhfpacket.MHFPacket, ignoredSession *Session) {
-	s.Lock()
-	defer s.Unlock()
+	s.RLock()
+	sessions := make([]*Session, 0, len(s.clients))
 	for session := range s.clients {
-		if session == ignoredSession {
-			continue
-		}
-
-		// Make the header
-		bf := byteframe.NewByteFrame()
-		bf.WriteUint16(uint16(pkt.Opcode()))
-
-		// Build the packet onto the byteframe.
-		_ = pkt.Build(bf, session.clientContext)
-
-		// Enqueue in a non-blocking way that drops the packet if the connections send buffer channel is full.
-		session.QueueSendNonBlocking(bf.Data())
+		sessions = append(sessions, session)
 	}
+	s.RUnlock()
+	broadcastTo(sessions, ignoredSession, pkt)
 }
@@ -0,0 +1,66 @@
+package channelserver
+
+import (
+	"context"
+
+	"erupe-ce/network"
+	"erupe-ce/network/mhfpacket"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracerName is the instrumentation scope of the channel server's spans.
+const tracerName = "erupe-ce/server/channelserver"
+
+// tracingEnabled reports whether spans should be recorded for the server.
+// Checking first skips the span bookkeeping entirely when tracing is off.
+func tracingEnabled(server *Server) bool {
+	return server != nil && server.erupeConfig != nil && server.erupeConfig.Tracing.Enabled
+}
+
+// traceHandler runs handler for pkt, inside a span when tracing is enabled.
+// Broadcasts sent while the handler runs are recorded as children of it.
+func (s *Session) traceHandler(handler handlerFunc, opcode network.PacketID, pkt mhfpacket.MHFPacket) {
+	if !tracingEnabled(s.server) {
+		handler(s, pkt)
+		return
+	}
+	_, span := otel.Tracer(tracerName).Start(context.Background(), opcode.String(),
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(
+			attribute.Int("erupe.opcode", int(opcode)),
+			attribute.Int64("erupe.char_id", int64(s.charID)),
+		),
+	)
+	if s.rawConn != nil {
+		span.SetAttributes(attribute.String("erupe.session", s.rawConn.RemoteAddr().String()))
+	}
+	s.traceParent.Store(span.SpanContext())
+	defer func() {
+		s.traceParent.Store(trace.SpanContext{})
+		span.End()
+	}()
+	handler(s, pkt)
+}
+
+// traceBroadcast starts a span for a broadcast of pkt to recipients
+// sessions. The sender, when known, is the session left out of the
+// broadcast; its current packet span becomes the parent.
+func traceBroadcast(sender *Session, pkt mhfpacket.MHFPacket, recipients int) trace.Span {
+	ctx := context.Background()
+	if sender != nil {
+		if sc, ok := sender.traceParent.Load().(trace.SpanContext); ok && sc.IsValid() {
+			ctx = trace.ContextWithSpanContext(ctx, sc)
+		}
+	}
+	_, span := otel.Tracer(tracerName).Start(ctx, "broadcast "+pkt.Opcode().String(),
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(
+			attribute.Int("erupe.opcode", int(pkt.Opcode())),
+			attribute.Int("erupe.recipients", recipients),
+		),
+	)
+	return span
+}
@@ -0,0 +1,84 @@
+package channelserver
+
+import (
+	"net"
+	"testing"
+
+	"erupe-ce/network"
+	"erupe-ce/network/mhfpacket"
+
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestRunHandler_TracesPacketAndBroadcast(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	prev := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	defer otel.SetTracerProvider(prev)
+
+	server := createMockServer()
+	server.erupeConfig.Tracing.Enabled = true
+	sender := createMockSession(42, server)
+	client, conn := net.Pipe()
+	defer func() { _ = client.Close() }()
+	defer func() { _ = conn.Close() }()
+	sender.rawConn = conn
+	receiver := createMockSession(43, server)
+
+	stage := NewStage("trace_stage")
+	stage.clients[sender] = sender.charID
+	stage.clients[receiver] = receiver.charID
+
+	handler := func(s *Session, p mhfpacket.MHFPacket) {
+		stage.BroadcastMHF(&mhfpacket.MsgSysCastedBinary{CharID: s.charID}, s)
+	}
+	sender.runHandler(handler, network.MSG_SYS_CAST_BINARY, &mhfpacket.MsgSysCastBinary{}, 0)
+	<-receiver.sendPackets
+
+	spans := recorder.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("recorded %d spans, want 2", len(spans))
+	}
+	broadcast, packet := spans[0], spans[1]
+	if packet.Name() != network.MSG_SYS_CAST_BINARY.String() {
+		t.Errorf("packet span name = %q", packet.Name())
+	}
+	if broadcast.Parent().SpanID() != packet.SpanContext().SpanID() {
+		t.Error("broadcast span is not a child of the packet span")
+	}
+	attrs := make(map[string]any)
+	for _, kv := range packet.Attributes() {
+		attrs[string(kv.Key)] = kv.Value.AsInterface()
+	}
+	if attrs["erupe.char_id"] != int64(42) {
+		t.Errorf("erupe.char_id = %v, want 42", attrs["erupe.char_id"])
+	}
+	if attrs["erupe.opcode"] != int64(network.MSG_SYS_CAST_BINARY) {
+		t.Errorf("erupe.opcode = %v", attrs["erupe.opcode"])
+	}
+	if _, ok := attrs["erupe.session"]; !ok {
+		t.Error("packet span has no erupe.session attribute")
+	}
+}
+
+func TestRunHandler_NoSpansWhenDisabled(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	prev := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	defer otel.SetTracerProvider(prev)
+
+	server := createMockServer()
+	session := createMockSession(1, server)
+	ran := false
+	session.runHandler(func(*Session, mhfpacket.MHFPacket) { ran = true }, network.MSG_SYS_PING, &mhfpacket.MsgSysPing{}, 0)
+	if !ran {
+		t.Fatal("handler did not run")
+	}
+	if n := len(recorder.Ended()); n != 0 {
+		t.Errorf("recorded %d spans with tracing disabled", n)
+	}
+}
@@ -0,0 +1,218 @@
+package channelserver
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"erupe-ce/network"
+	"erupe-ce/network/mhfpacket"
+
+	"go.uber.org/zap"
+)
+
+// PacketValidation selects what the channel server does with a packet that
+// fails validation before its handler runs.
+type PacketValidation int
+
+const (
+	// PacketValidationReject drops the packet, failing its ACK if it has one.
+	PacketValidationReject PacketValidation = iota
+	// PacketValidationLog logs the packet and handles it anyway.
+	PacketValidationLog
+	// PacketValidationOff skips validation.
+	PacketValidationOff
+)
+
+// ParsePacketValidation parses the Channel.PacketValidation setting. An
+// empty setting rejects.
+func ParsePacketValidation(name string) (PacketValidation, error) {
+	switch strings.ToLower(name) {
+	case "", "reject":
+		return PacketValidationReject, nil
+	case "log":
+		return PacketValidationLog, nil
+	case "off":
+		return PacketValidationOff, nil
+	}
+	return PacketValidationReject, fmt.Errorf("unknown packet validation %q, want reject, log or off", name)
+}
+
+// Errors a packet fails validation with, also the reasons counted in the
+// handler metrics.
+var (
+	ErrUnknownOpcode   = errors.New("unknown opcode")
+	ErrPacketTruncated = errors.New("packet truncated")
+	ErrPacketSize      = errors.New("packet too large")
+	ErrPacketField     = errors.New("bad packet field")
+)
+
+// maxPacketSizes bounds the bytes, opcode included, of packets whose size the
+// client chooses through a length prefix or a null terminated string. Other
+// packets have a fixed size, or carry saves and binaries that are validated
+// by their handlers.
+var maxPacketSizes = map[network.PacketID]int{
+	network.MSG_SYS_LOGIN:             128,
+	network.MSG_SYS_SET_STAGE_PASS:    64,
+	network.MSG_SYS_CREATE_STAGE:      128,
+	network.MSG_SYS_ENTER_STAGE:       128,
+	network.MSG_SYS_MOVE_STAGE:        128,
+	network.MSG_SYS_RESERVE_STAGE:     128,
+	network.MSG_SYS_LOCK_STAGE:        128,
+	network.MSG_SYS_ENUMERATE_CLIENT:  128,
+	network.MSG_SYS_GET_STAGE_BINARY:  128,
+	network.MSG_SYS_WAIT_STAGE_BINARY: 128,
+	network.MSG_MHF_CREATE_GUILD:      128,
+	network.MSG_MHF_CREATE_JOINT:      128,
+	network.MSG_MHF_OPERATE_WAREHOUSE: 128,
+	network.MSG_MHF_SEND_MAIL:         1024,
+	network.MSG_MHF_TRANSIT_MESSAGE:   2048,
+	network.MSG_SYS_CAST_BINARY:       8192,
+}
+
+// maxStageIDLen is the longest stage ID accepted. The client's are a few
+// characters plus a handful of digits, such as sl1Ns200p0a0u0.
+const maxStageIDLen = 32
+
+// packetChecks hold the field checks of packets whose handlers would
+// otherwise trust a value the client chose.
+var packetChecks = map[network.PacketID]func(mhfpacket.MHFPacket) error{
+	network.MSG_SYS_CREATE_STAGE: func(p mhfpacket.MHFPacket) error {
+		return checkStageID(p.(*mhfpacket.MsgSysCreateStage).StageID)
+	},
+	network.MSG_SYS_ENTER_STAGE: func(p mhfpacket.MHFPacket) error {
+		return checkStageID(p.(*mhfpacket.MsgSysEnterStage).StageID)
+	},
+	network.MSG_SYS_MOVE_STAGE: func(p mhfpacket.MHFPacket) error {
+		return checkStageID(p.(*mhfpacket.MsgSysMoveStage).StageID)
+	},
+	network.MSG_SYS_RESERVE_STAGE: func(p mhfpacket.MHFPacket) error {
+		return checkStageID(p.(*mhfpacket.MsgSysReserveStage).StageID)
+	},
+	network.MSG_SYS_LOCK_STAGE: func(p mhfpacket.MHFPacket) error {
+		return checkStageID(p.(*mhfpacket.MsgSysLockStage).StageID)
+	},
+	network.MSG_SYS_ENUMERATE_CLIENT: func(p mhfpacket.MHFPacket) error {
+		return checkStageID(p.(*mhfpacket.MsgSysEnumerateClient).StageID)
+	},
+	network.MSG_SYS_GET_STAGE_BINARY: func(p mhfpacket.MHFPacket) error {
+		return checkStageID(p.(*mhfpacket.MsgSysGetStageBinary).StageID)
+	},
+	network.MSG_SYS_WAIT_STAGE_BINARY: func(p mhfpacket.MHFPacket) error {
+		return checkStageID(p.(*mhfpacket.MsgSysWaitStageBinary).StageID)
+	},
+	network.MSG_SYS_LOGIN: func(p mhfpacket.MHFPacket) error {
+		if token := p.(*mhfpacket.MsgSysLogin).LoginTokenString; !isPrintableASCII(token) {
+			return fmt.Errorf("%w: login token %q", ErrPacketField, token)
+		}
+		return nil
+	},
+	network.MSG_MHF_OPERATE_WAREHOUSE: func(p mhfpacket.MHFPacket) error {
+		if pkt := p.(*mhfpacket.MsgMhfOperateWarehouse); pkt.BoxType > 1 {
+			return fmt.Errorf("%w: warehouse box type %d", ErrPacketField, pkt.BoxType)
+		}
+		return nil
+	},
+}
+
+// checkStageID rejects stage IDs the client would never send.
+func checkStageID(id string) error {
+	if len(id) > maxStageIDLen || !isPrintableASCII(id) {
+		return fmt.Errorf("%w: stage ID %q", ErrPacketField, id)
+	}
+	return nil
+}
+
+func isPrintableASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < 0x20 || s[i] > 0x7E {
+			return false
+		}
+	}
+	return true
+}
+
+// validatePacket checks a parsed packet that took size bytes of its group.
+func validatePacket(opcode network.PacketID, pkt mhfpacket.MHFPacket, size int) error {
+	if max, ok := maxPacketSizes[opcode]; ok && size > max {
+		return fmt.Errorf("%w: %d bytes, at most %d", ErrPacketSize, size, max)
+	}
+	if check, ok := packetChecks[opcode]; ok {
+		return check(pkt)
+	}
+	return nil
+}
+
+// rejectPacket logs a packet that failed validation and reports whether its
+// handler must be skipped. A rejected packet with an ACK handle is answered
+// with a failure so the client does not wait on it, and a session that sends
+// Channel.MaxPacketViolations invalid packets is disconnected.
+func (s *Session) rejectPacket(opcode network.PacketID, pkt mhfpacket.MHFPacket, size int, err error) bool {
+	mode := s.server.packetValidation
+	if mode != PacketValidationOff {
+		s.packetViolations++
+	}
+	s.logger.Warn("Invalid packet",
+		zap.String("name", s.Name),
+		zap.Stringer("opcode", opcode),
+		zap.Int("size", size),
+		zap.Error(err),
+		zap.Int("violations", s.packetViolations),
+	)
+	if mode == PacketValidationOff {
+		return false
+	}
+	if m := s.server.opMetrics; m != nil {
+		m.PacketRejected(packetRejectReason(err))
+	}
+	if mode == PacketValidationLog {
+		return false
+	}
+	if ack, ok := ackHandleOf(pkt); ok {
+		doAckSimpleFail(s, ack, make([]byte, 4))
+	}
+	if max := s.server.erupeConfig.Channel.MaxPacketViolations; max > 0 && s.packetViolations >= max {
+		s.logger.Warn("Disconnecting session after too many invalid packets",
+			zap.String("name", s.Name),
+			zap.Uint32("charID", s.charID),
+		)
+		s.closed.Store(true)
+	}
+	return true
+}
+
+// packetRejectReason names the metric label for a validation error.
+func packetRejectReason(err error) string {
+	switch {
+	case errors.Is(err, ErrUnknownOpcode):
+		return "unknown_opcode"
+	case errors.Is(err, ErrPacketTruncated):
+		return "truncated"
+	case errors.Is(err, ErrPacketSize):
+		return "size"
+	case errors.Is(err, ErrPacketState):
+		return "state"
+	case errors.Is(err, ErrPacketRights):
+		return "rights"
+	default:
+		return "field"
+	}
+}
+
+// ackHandleOf returns the AckHandle field of pkt, which every packet the
+// client expects an answer to has.
+func ackHandleOf(pkt mhfpacket.MHFPacket) (uint32, bool) {
+	if pkt == nil {
+		return 0, false
+	}
+	v := reflect.ValueOf(pkt)
+	if v.Kind() != reflect.Pointer || v.Elem().Kind() != reflect.Struct {
+		return 0, false
+	}
+	f := v.Elem().FieldByName("AckHandle")
+	if !f.IsValid() || f.Kind() != reflect.Uint32 {
+		return 0, false
+	}
+	return uint32(f.Uint()), true
+}
@@ -0,0 +1,159 @@
+package channelserver
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"erupe-ce/common/byteframe"
+	"erupe-ce/network"
+	"erupe-ce/network/mhfpacket"
+	"erupe-ce/server/opmetrics"
+)
+
+func TestParsePacketValidation(t *testing.T) {
+	for name, want := range map[string]PacketValidation{
+		"":       PacketValidationReject,
+		"reject": PacketValidationReject,
+		"Log":    PacketValidationLog,
+		"off":    PacketValidationOff,
+	} {
+		if got, err := ParsePacketValidation(name); err != nil || got != want {
+			t.Errorf("ParsePacketValidation(%q) = %v, %v; want %v", name, got, err, want)
+		}
+	}
+	if _, err := ParsePacketValidation("drop"); err == nil {
+		t.Error("unknown setting was accepted")
+	}
+}
+
+func TestValidatePacket(t *testing.T) {
+	tests := []struct {
+		name string
+		pkt  mhfpacket.MHFPacket
+		size int
+		want error
+	}{
+		{"stage ID", &mhfpacket.MsgSysEnterStage{StageID: "sl1Ns200p0a0u0"}, 20, nil},
+		{"empty stage ID", &mhfpacket.MsgSysEnterStage{}, 8, nil},
+		{"long stage ID", &mhfpacket.MsgSysEnterStage{StageID: strings.Repeat("s", 40)}, 48, ErrPacketField},
+		{"binary stage ID", &mhfpacket.MsgSysMoveStage{StageID: "sl1\x01"}, 12, ErrPacketField},
+		{"oversized cast", &mhfpacket.MsgSysCastBinary{}, 9000, ErrPacketSize},
+		{"login token", &mhfpacket.MsgSysLogin{LoginTokenString: "abcDEF0123456789"}, 40, nil},
+		{"binary login token", &mhfpacket.MsgSysLogin{LoginTokenString: "\xff\xfe"}, 26, ErrPacketField},
+		{"warehouse box type", &mhfpacket.MsgMhfOperateWarehouse{BoxType: 7}, 12, ErrPacketField},
+		{"unlimited save", &mhfpacket.MsgMhfSavedata{}, 100000, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validatePacket(tt.pkt.Opcode(), tt.pkt, tt.size)
+			if !errors.Is(err, tt.want) || (tt.want == nil && err != nil) {
+				t.Errorf("validatePacket() = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestAckHandleOf(t *testing.T) {
+	if ack, ok := ackHandleOf(&mhfpacket.MsgSysEnterStage{AckHandle: 7}); !ok || ack != 7 {
+		t.Errorf("ackHandleOf(MsgSysEnterStage) = %d, %v", ack, ok)
+	}
+	if _, ok := ackHandleOf(&mhfpacket.MsgSysSetStagePass{}); ok {
+		t.Error("ackHandleOf found a handle on a packet without one")
+	}
+	if _, ok := ackHandleOf(nil); ok {
+		t.Error("ackHandleOf(nil) found a handle")
+	}
+}
+
+// enterStageGroup builds a MSG_SYS_ENTER_STAGE packet group.
+func enterStageGroup(ack uint32, stageID string) []byte {
+	bf := byteframe.NewByteFrame()
+	bf.WriteUint16(uint16(network.MSG_SYS_ENTER_STAGE))
+	bf.WriteUint32(ack)
+	bf.WriteBool(false)
+	bf.WriteUint8(uint8(len(stageID) + 1))
+	bf.WriteNullTerminatedBytes([]byte(stageID))
+	return bf.Data()
+}
+
+func validationSession(mode PacketValidation, handled *int) *Session {
+	server := createMockServer()
+	server.packetValidation = mode
+	server.opMetrics = opmetrics.New()
+	server.handlerTable = map[network.PacketID]handlerFunc{
+		network.MSG_SYS_ENTER_STAGE: func(s *Session, p mhfpacket.MHFPacket) { *handled++ },
+	}
+	session := createMockSession(1, server)
+	session.ackStart = make(map[uint32]time.Time)
+	return session
+}
+
+func TestHandlePacketGroup_RejectsInvalidPacket(t *testing.T) {
+	handled := 0
+	session := validationSession(PacketValidationReject, &handled)
+
+	session.handlePacketGroup(enterStageGroup(0x1234, "sl1\x01\x02"))
+	if handled != 0 {
+		t.Error("handler ran for a rejected packet")
+	}
+	select {
+	case p := <-session.sendPackets:
+		bf := byteframe.NewByteFrameFromBytes(p.data)
+		if op := network.PacketID(bf.ReadUint16()); op != network.MSG_SYS_ACK || bf.ReadUint32() != 0x1234 {
+			t.Errorf("rejected packet answered with %v", op)
+		}
+	default:
+		t.Error("rejected packet was not answered with a failure ACK")
+	}
+	if got := session.server.opMetrics.PacketsRejected()["field"]; got != 1 {
+		t.Errorf("field rejections = %d, want 1", got)
+	}
+
+	session.handlePacketGroup(enterStageGroup(1, "sl1Ns200p0a0u0"))
+	if handled != 1 {
+		t.Error("handler did not run for a valid packet")
+	}
+}
+
+func TestHandlePacketGroup_LogsInvalidPacket(t *testing.T) {
+	handled := 0
+	session := validationSession(PacketValidationLog, &handled)
+
+	session.handlePacketGroup(enterStageGroup(1, strings.Repeat("s", 40)))
+	if handled != 1 {
+		t.Error("handler did not run in log mode")
+	}
+	if session.packetViolations != 1 {
+		t.Errorf("packetViolations = %d, want 1", session.packetViolations)
+	}
+}
+
+func TestHandlePacketGroup_DisconnectsAfterViolations(t *testing.T) {
+	handled := 0
+	session := validationSession(PacketValidationReject, &handled)
+	session.server.erupeConfig.Channel.MaxPacketViolations = 2
+
+	// The second bad packet disconnects the session, so the valid packet
+	// after it in the group is never handled.
+	group := append(enterStageGroup(1, "bad\x01"), enterStageGroup(2, "bad\x01")...)
+	group = append(group, enterStageGroup(3, "sl1Ns200p0a0u0")...)
+	session.handlePacketGroup(group)
+	if !session.closed.Load() {
+		t.Error("session was not disconnected")
+	}
+	if handled != 0 {
+		t.Errorf("handled %d packets after the disconnect", handled)
+	}
+}
+
+func TestHandlePacketGroup_CountsUnknownOpcode(t *testing.T) {
+	handled := 0
+	session := validationSession(PacketValidationReject, &handled)
+
+	session.handlePacketGroup([]byte{0xFF, 0xFF, 0, 0})
+	if got := session.server.opMetrics.PacketsRejected()["unknown_opcode"]; got != 1 {
+		t.Errorf("unknown opcode rejections = %d, want 1", got)
+	}
+}
@@ -1,8 +1,6 @@
 package channelserver
 
 import (
-	"net"
-
 	"erupe-ce/common/byteframe"
 	cfg "erupe-ce/config"
 	"erupe-ce/network"
@@ -41,7 +39,6 @@ func createMockServer() *Server {
 		logger:      logger,
 		erupeConfig: &cfg.Config{},
 		// stages is a StageMap (zero value is ready to use)
-		sessions:     make(map[net.Conn]*Session),
 		handlerTable: buildHandlerTable(),
 		raviente: &Raviente{
 			register: make([]uint32, 30),
@@ -88,6 +85,11 @@ func ensureFestaService(s *Server) {
 	s.festaService = NewFestaService(s.festaRepo, s.logger)
 }
 
+// ensureModerationService wires the ModerationService from the server's current repos.
+func ensureModerationService(s *Server) {
+	s.moderationService = NewModerationService(s.userRepo, s.moderationRepo, s.logger)
+}
+
 // createMockSession creates a minimal Session for testing.
 // Imported from v9.2.x-stable and adapted for main.
 func createMockSession(charID uint32, server *Server) *Session {
@@ -8,7 +8,6 @@ import (
 	"testing"
 	"time"
 
-	"erupe-ce/server/channelserver/compression/nullcomp"
 	"erupe-ce/server/migrations"
 	"github.com/jmoiron/sqlx"
 	_ "github.com/lib/pq"
@@ -178,33 +177,42 @@ func CreateTestUser(t *testing.T, db *sqlx.DB, username string) uint32 {
 	return userID
 }
 
-// CreateTestCharacter creates a test character and returns the character ID
+// CreateTestCharacter creates a test character with a generated ZZ save and
+// returns the character ID
 func CreateTestCharacter(t *testing.T, db *sqlx.DB, userID uint32, name string) uint32 {
 	t.Helper()
+	return CreateTestCharacterWithSave(t, db, userID, SaveFixture{Name: name})
+}
 
-	// Create minimal valid savedata (needs to be large enough for the game to parse)
-	// The name is at offset 88, and various game mode pointers extend up to ~147KB for ZZ mode
-	// We need at least 150KB to accommodate all possible pointer offsets
-	saveData := make([]byte, 150000)                // Large enough for all game modes
-	copy(saveData[88:], append([]byte(name), 0x00)) // Name at offset 88 with null terminator
+// CreateTestCharacterWithSave creates a test character whose savedata and
+// characters row are generated from the fixture, stores the fixture's item box
+// in its warehouse, and returns the character ID
+func CreateTestCharacterWithSave(t *testing.T, db *sqlx.DB, userID uint32, save SaveFixture) uint32 {
+	t.Helper()
 
-	// Import the nullcomp package for compression
-	compressed, err := nullcomp.Compress(saveData)
+	compressed, err := save.Compressed()
 	if err != nil {
 		t.Fatalf("Failed to compress savedata: %v", err)
 	}
 
+	hr, gr := save.ranks()
 	var charID uint32
 	err = db.QueryRow(`
 		INSERT INTO characters (user_id, is_female, is_new_character, name, unk_desc_string, gr, hr, weapon_type, last_login, savedata, decomyset, savemercenary)
-		VALUES ($1, false, false, $2, '', 0, 0, 0, 0, $3, '', '')
+		VALUES ($1, $2, $3, $4, '', $5, $6, $7, 0, $8, '', '')
 		RETURNING id
-	`, userID, name, compressed).Scan(&charID)
+	`, userID, save.Female, save.IsNew, save.Name, gr, hr, save.WeaponType, compressed).Scan(&charID)
 
 	if err != nil {
 		t.Fatalf("Failed to create test character: %v", err)
 	}
 
+	if items := save.ItemBoxData(); items != nil {
+		if _, err := db.Exec(`INSERT INTO warehouse (character_id, item0) VALUES ($1, $2)`, charID, items); err != nil {
+			t.Fatalf("Failed to create test warehouse: %v", err)
+		}
+	}
+
 	return charID
 }
 
@@ -0,0 +1,109 @@
+package channelserver
+
+import (
+	"errors"
+	"fmt"
+
+	"erupe-ce/common/mhfitem"
+	"erupe-ce/common/token"
+)
+
+// Conflict policies for an item box transfer into a page that already holds
+// items.
+const (
+	ItemBoxConflictFail    = "fail"    // Refuse the whole transfer
+	ItemBoxConflictMerge   = "merge"   // Add the moved stacks after the page's own
+	ItemBoxConflictReplace = "replace" // Overwrite the page
+)
+
+// itemBoxPages is the number of warehouse item box pages. Index 10, the
+// gift box, is never transferred.
+const itemBoxPages = 10
+
+var (
+	// ErrItemBoxConflict is returned when a destination page holds items and
+	// the transfer's conflict policy is fail.
+	ErrItemBoxConflict = errors.New("destination item box page is not empty")
+	// ErrNotSameAccount is returned when the two characters of a transfer
+	// belong to different accounts, or one does not exist.
+	ErrNotSameAccount = errors.New("characters are not on the same account")
+	// ErrCharacterOnline is returned when either character of a transfer is
+	// logged in, as the game would keep showing its old item box.
+	ErrCharacterOnline = errors.New("character is online")
+)
+
+// ItemBoxTransfer moves or copies warehouse item box pages from one
+// character to another on the same account.
+type ItemBoxTransfer struct {
+	From     uint32  `json:"from"`
+	To       uint32  `json:"to"`
+	Boxes    []uint8 `json:"boxes"`    // Pages 0-9, every page when empty
+	Move     bool    `json:"move"`     // Empty the source pages afterwards
+	Conflict string  `json:"conflict"` // ItemBoxConflict policy, fail when empty
+}
+
+// ItemBoxPageResult is what a transfer did to one page.
+type ItemBoxPageResult struct {
+	Box      uint8 `json:"box"`
+	Stacks   int   `json:"stacks"`   // Stacks taken from the source page
+	Replaced int   `json:"replaced"` // Stacks of the destination page overwritten
+	Total    int   `json:"total"`    // Stacks in the destination page afterwards
+}
+
+// Validate checks the transfer and fills in its defaults.
+func (t *ItemBoxTransfer) Validate() error {
+	if t.From == 0 || t.To == 0 || t.From == t.To {
+		return errors.New("transfer needs two different characters")
+	}
+	switch t.Conflict {
+	case "":
+		t.Conflict = ItemBoxConflictFail
+	case ItemBoxConflictFail, ItemBoxConflictMerge, ItemBoxConflictReplace:
+	default:
+		return fmt.Errorf("unknown conflict policy %q", t.Conflict)
+	}
+	if len(t.Boxes) == 0 {
+		for i := uint8(0); i < itemBoxPages; i++ {
+			t.Boxes = append(t.Boxes, i)
+		}
+	}
+	seen := make(map[uint8]bool, len(t.Boxes))
+	for _, b := range t.Boxes {
+		if b >= itemBoxPages {
+			return fmt.Errorf("item box page %d out of range 0-%d", b, itemBoxPages-1)
+		}
+		if seen[b] {
+			return fmt.Errorf("item box page %d listed twice", b)
+		}
+		seen[b] = true
+	}
+	return nil
+}
+
+// transferItemBoxPage returns the destination page after src is moved into
+// dst under a conflict policy. An empty source page leaves dst as it is.
+// Moved stacks get new warehouse IDs so they cannot clash with the
+// destination's.
+func transferItemBoxPage(box uint8, src, dst []mhfitem.MHFItemStack, conflict string) ([]mhfitem.MHFItemStack, ItemBoxPageResult, error) {
+	res := ItemBoxPageResult{Box: box, Stacks: len(src), Total: len(dst)}
+	if len(src) == 0 {
+		return dst, res, nil
+	}
+	var out []mhfitem.MHFItemStack
+	if len(dst) > 0 {
+		switch conflict {
+		case ItemBoxConflictMerge:
+			out = append(out, dst...)
+		case ItemBoxConflictReplace:
+			res.Replaced = len(dst)
+		default:
+			return nil, res, fmt.Errorf("%w: page %d has %d stacks", ErrItemBoxConflict, box, len(dst))
+		}
+	}
+	for _, stack := range src {
+		stack.WarehouseID = token.RNG.Uint32()
+		out = append(out, stack)
+	}
+	res.Total = len(out)
+	return out, res, nil
+}
@@ -0,0 +1,67 @@
+package channelserver
+
+import (
+	"errors"
+	"testing"
+
+	"erupe-ce/common/mhfitem"
+)
+
+func TestItemBoxTransferCheck(t *testing.T) {
+	tr := ItemBoxTransfer{From: 1, To: 2}
+	if err := tr.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if len(tr.Boxes) != itemBoxPages || tr.Conflict != ItemBoxConflictFail {
+		t.Errorf("defaults = %+v", tr)
+	}
+
+	bad := []ItemBoxTransfer{
+		{From: 1, To: 1},
+		{From: 1},
+		{From: 1, To: 2, Boxes: []uint8{10}},
+		{From: 1, To: 2, Boxes: []uint8{3, 3}},
+		{From: 1, To: 2, Conflict: "swap"},
+	}
+	for _, tr := range bad {
+		if err := tr.Validate(); err == nil {
+			t.Errorf("%+v was accepted", tr)
+		}
+	}
+}
+
+func TestTransferItemBoxPage(t *testing.T) {
+	stack := func(id uint32, item uint16) mhfitem.MHFItemStack {
+		return mhfitem.MHFItemStack{WarehouseID: id, Item: mhfitem.MHFItem{ItemID: item}, Quantity: 3}
+	}
+	src := []mhfitem.MHFItemStack{stack(1, 100), stack(2, 200)}
+	dst := []mhfitem.MHFItemStack{stack(1, 300)}
+
+	if _, _, err := transferItemBoxPage(0, src, dst, ItemBoxConflictFail); !errors.Is(err, ErrItemBoxConflict) {
+		t.Errorf("fail policy: err = %v", err)
+	}
+
+	out, res, err := transferItemBoxPage(0, src, dst, ItemBoxConflictMerge)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out) != 3 || out[0].Item.ItemID != 300 || out[2].Item.ItemID != 200 || res.Total != 3 || res.Replaced != 0 {
+		t.Errorf("merge: %+v %+v", out, res)
+	}
+	if out[1].WarehouseID == src[0].WarehouseID && out[2].WarehouseID == src[1].WarehouseID {
+		t.Error("moved stacks kept their warehouse IDs")
+	}
+
+	out, res, err = transferItemBoxPage(0, src, dst, ItemBoxConflictReplace)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out) != 2 || out[0].Item.ItemID != 100 || res.Replaced != 1 {
+		t.Errorf("replace: %+v %+v", out, res)
+	}
+
+	out, res, err = transferItemBoxPage(0, nil, dst, ItemBoxConflictFail)
+	if err != nil || len(out) != 1 || res.Stacks != 0 || res.Total != 1 {
+		t.Errorf("empty source: %+v %+v %v", out, res, err)
+	}
+}
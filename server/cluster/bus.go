@@ -0,0 +1,553 @@
+package cluster
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	dialTimeout      = 5 * time.Second
+	handshakeTimeout = 5 * time.Second
+	retryDelay       = 2 * time.Second
+	kindHello        = "hello"
+	exporterLabel    = "EXPORTER-erupe-cluster"
+)
+
+// ErrClosed is returned when the bus has been closed.
+var ErrClosed = errors.New("cluster: bus closed")
+
+// Message is one message on the bus.
+type Message struct {
+	Kind  string          `json:"kind"`
+	From  string          `json:"from,omitempty"`
+	ID    uint64          `json:"id,omitempty"` // Set on requests and their replies
+	Reply bool            `json:"reply,omitempty"`
+	Error string          `json:"error,omitempty"`
+	Body  json.RawMessage `json:"body,omitempty"`
+}
+
+// Handler serves a message of one kind from the node from, the name the
+// sending peer authenticated as. Its result is the reply to a request, and
+// is ignored for published messages.
+type Handler func(from string, body json.RawMessage) (any, error)
+
+// Secrets returns the secret this node shares with the named peer, or false
+// if it knows no such peer.
+type Secrets func(node string) (string, bool)
+
+// Bus connects this node to its peers.
+type Bus struct {
+	node    string
+	secrets Secrets
+	logger  *zap.Logger
+
+	mu       sync.Mutex
+	handlers map[string]Handler
+	peers    []*peer
+	inbound  map[net.Conn]struct{}
+	pending  map[uint64]chan Message
+	nextID   uint64
+	listener net.Listener
+	closed   bool
+
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+// peer is the connection this node opened to another node. Requests and
+// published messages go out on it, and replies come back on it.
+type peer struct {
+	addr   string
+	expect string     // Name the peer must authenticate as, empty for any
+	mu     sync.Mutex // Serialises writes
+	conn   net.Conn   // nil while disconnected
+	node   string
+	enc    *json.Encoder
+}
+
+// New creates a Bus for the node, which shares one secret with every peer.
+func New(node, secret string, logger *zap.Logger) *Bus {
+	return NewKeyed(node, func(string) (string, bool) { return secret, true }, logger)
+}
+
+// NewKeyed creates a Bus for the node, which shares a secret of its own with
+// each peer. A peer is only accepted under a name it has the secret for, so
+// no peer can speak for another.
+func NewKeyed(node string, secrets Secrets, logger *zap.Logger) *Bus {
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+	return &Bus{
+		node:     node,
+		secrets:  secrets,
+		logger:   logger,
+		handlers: make(map[string]Handler),
+		inbound:  make(map[net.Conn]struct{}),
+		pending:  make(map[uint64]chan Message),
+		done:     make(chan struct{}),
+	}
+}
+
+// Handle registers h for the messages of the kind.
+func (b *Bus) Handle(kind string, h Handler) {
+	b.mu.Lock()
+	b.handlers[kind] = h
+	b.mu.Unlock()
+}
+
+// Listen accepts connections from peers on addr.
+func (b *Bus) Listen(addr string) error {
+	cert, err := selfSignedCert()
+	if err != nil {
+		return err
+	}
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	l = tls.NewListener(l, &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS13})
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		_ = l.Close()
+		return ErrClosed
+	}
+	b.listener = l
+	b.mu.Unlock()
+	b.wg.Add(1)
+	go b.acceptPeers(l)
+	return nil
+}
+
+// Addr returns the address the bus listens on, or nil before Listen.
+func (b *Bus) Addr() net.Addr {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.listener == nil {
+		return nil
+	}
+	return b.listener.Addr()
+}
+
+// Connect dials the peers, redialling any that drop until the bus is closed.
+func (b *Bus) Connect(addrs []string) {
+	for _, addr := range addrs {
+		b.ConnectNode("", addr)
+	}
+}
+
+// ConnectNode dials the peer at addr, which must authenticate as node,
+// redialling it if it drops until the bus is closed. An empty node accepts
+// any name the peer has the secret for.
+func (b *Bus) ConnectNode(node, addr string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	p := &peer{addr: addr, expect: node}
+	b.peers = append(b.peers, p)
+	b.wg.Add(1)
+	go b.dial(p)
+}
+
+// Connected returns the names of the peers currently connected.
+func (b *Bus) Connected() []string {
+	var nodes []string
+	for _, p := range b.connectedPeers() {
+		nodes = append(nodes, p.node)
+	}
+	return nodes
+}
+
+// Publish sends a message to every connected peer without waiting.
+func (b *Bus) Publish(kind string, body any) error {
+	raw, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	for _, p := range b.connectedPeers() {
+		p.send(Message{Kind: kind, From: b.node, Body: raw})
+	}
+	return nil
+}
+
+// Request sends a message to every connected peer and returns their
+// replies, gathered until all have answered or ctx is done. Peers that
+// fail or do not answer in time are left out.
+func (b *Bus) Request(ctx context.Context, kind string, body any) ([]json.RawMessage, error) {
+	raw, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	peers := b.connectedPeers()
+	replies := make(chan Message, len(peers))
+	ids := make([]uint64, 0, len(peers))
+	b.mu.Lock()
+	for range peers {
+		b.nextID++
+		b.pending[b.nextID] = replies
+		ids = append(ids, b.nextID)
+	}
+	b.mu.Unlock()
+	defer func() {
+		b.mu.Lock()
+		for _, id := range ids {
+			delete(b.pending, id)
+		}
+		b.mu.Unlock()
+	}()
+
+	for i, p := range peers {
+		if !p.send(Message{Kind: kind, From: b.node, ID: ids[i], Body: raw}) {
+			replies <- Message{Error: "peer disconnected"}
+		}
+	}
+
+	var out []json.RawMessage
+	for range peers {
+		select {
+		case m := <-replies:
+			if m.Error != "" {
+				b.logger.Debug("Cluster request failed on a peer", zap.String("kind", kind), zap.String("node", m.From), zap.String("error", m.Error))
+				continue
+			}
+			out = append(out, m.Body)
+		case <-ctx.Done():
+			b.logger.Debug("Cluster request timed out", zap.String("kind", kind), zap.Int("replies", len(out)), zap.Int("peers", len(peers)))
+			return out, nil
+		case <-b.done:
+			return out, ErrClosed
+		}
+	}
+	return out, nil
+}
+
+// Close disconnects from every peer and stops listening.
+func (b *Bus) Close() {
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return
+	}
+	b.closed = true
+	close(b.done)
+	if b.listener != nil {
+		_ = b.listener.Close()
+	}
+	for c := range b.inbound {
+		_ = c.Close()
+	}
+	peers := b.peers
+	b.mu.Unlock()
+	// A peer checks closed before it stores its connection, so each one is
+	// either closed here or never connects.
+	for _, p := range peers {
+		p.close()
+	}
+	b.wg.Wait()
+}
+
+func (b *Bus) connectedPeers() []*peer {
+	b.mu.Lock()
+	peers := b.peers
+	b.mu.Unlock()
+	var out []*peer
+	for _, p := range peers {
+		p.mu.Lock()
+		if p.conn != nil {
+			out = append(out, p)
+		}
+		p.mu.Unlock()
+	}
+	return out
+}
+
+// dial keeps the connection to a peer open until the bus is closed.
+func (b *Bus) dial(p *peer) {
+	defer b.wg.Done()
+	// Peers present throwaway certificates, so they are not verified here:
+	// the handshake authenticates them by the secret, bound to the session.
+	dialer := &net.Dialer{Timeout: dialTimeout}
+	config := &tls.Config{InsecureSkipVerify: true, MinVersion: tls.VersionTLS13} //nolint:gosec // Authenticated by the secret
+	for {
+		conn, err := tls.DialWithDialer(dialer, "tcp", p.addr, config)
+		if err == nil {
+			err = b.serveOutbound(p, conn)
+		}
+		select {
+		case <-b.done:
+			return
+		default:
+		}
+		b.logger.Debug("Cluster peer unreachable", zap.String("addr", p.addr), zap.Error(err))
+		select {
+		case <-b.done:
+			return
+		case <-time.After(retryDelay):
+		}
+	}
+}
+
+// serveOutbound authenticates to the peer and the peer to this node, then
+// delivers its replies until the connection fails.
+func (b *Bus) serveOutbound(p *peer, conn *tls.Conn) error {
+	defer func() { _ = conn.Close() }()
+	enc := json.NewEncoder(conn)
+	dec := json.NewDecoder(conn)
+	_ = conn.SetDeadline(time.Now().Add(handshakeTimeout))
+	secret, ok := b.secrets(p.expect)
+	if !ok {
+		return fmt.Errorf("cluster: no secret for peer %q", p.expect)
+	}
+	proof, err := sessionProof(conn, secret, "dialer")
+	if err != nil {
+		return err
+	}
+	body, _ := json.Marshal(proof)
+	if err := enc.Encode(Message{Kind: kindHello, From: b.node, Body: body}); err != nil {
+		return err
+	}
+	var ack Message
+	if err := dec.Decode(&ack); err != nil {
+		return err
+	}
+	if ack.Kind != kindHello || !ack.Reply {
+		return fmt.Errorf("cluster: unexpected handshake %q", ack.Kind)
+	}
+	if p.expect != "" && ack.From != p.expect {
+		return fmt.Errorf("cluster: peer at %s is %q, not %q", p.addr, ack.From, p.expect)
+	}
+	if !checkProof(conn, secret, "listener", ack.Body) {
+		return fmt.Errorf("cluster: peer %q does not know the secret", ack.From)
+	}
+	_ = conn.SetDeadline(time.Time{})
+
+	p.mu.Lock()
+	b.mu.Lock()
+	closed := b.closed
+	b.mu.Unlock()
+	if closed {
+		p.mu.Unlock()
+		return ErrClosed
+	}
+	p.conn, p.enc, p.node = conn, enc, ack.From
+	p.mu.Unlock()
+	b.logger.Info("Cluster peer connected", zap.String("node", ack.From), zap.String("addr", p.addr))
+	defer func() {
+		p.close()
+		b.logger.Info("Cluster peer disconnected", zap.String("node", ack.From), zap.String("addr", p.addr))
+	}()
+
+	for {
+		var m Message
+		if err := dec.Decode(&m); err != nil {
+			return err
+		}
+		if !m.Reply {
+			continue
+		}
+		b.mu.Lock()
+		replies, ok := b.pending[m.ID]
+		delete(b.pending, m.ID)
+		b.mu.Unlock()
+		if ok {
+			replies <- m
+		}
+	}
+}
+
+func (b *Bus) acceptPeers(l net.Listener) {
+	defer b.wg.Done()
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			select {
+			case <-b.done:
+				return
+			default:
+			}
+			b.logger.Warn("Error accepting cluster peer", zap.Error(err))
+			continue
+		}
+		b.mu.Lock()
+		if b.closed {
+			b.mu.Unlock()
+			_ = conn.Close()
+			return
+		}
+		b.inbound[conn] = struct{}{}
+		b.mu.Unlock()
+		b.wg.Add(1)
+		go func() {
+			defer b.wg.Done()
+			b.serveInbound(conn)
+			b.mu.Lock()
+			delete(b.inbound, conn)
+			b.mu.Unlock()
+		}()
+	}
+}
+
+// serveInbound authenticates a peer and this node to it, then serves its
+// messages until the connection fails. Messages are served as from the name
+// the peer authenticated as, whatever they claim. Published messages are
+// served in the order they arrive; requests are served concurrently.
+func (b *Bus) serveInbound(conn net.Conn) {
+	defer func() { _ = conn.Close() }()
+	tc, ok := conn.(*tls.Conn)
+	if !ok {
+		return
+	}
+	enc := json.NewEncoder(conn)
+	dec := json.NewDecoder(conn)
+	_ = conn.SetDeadline(time.Now().Add(handshakeTimeout))
+	if err := tc.Handshake(); err != nil {
+		return
+	}
+	var hello Message
+	if err := dec.Decode(&hello); err != nil {
+		return
+	}
+	secret, ok := b.secrets(hello.From)
+	if hello.Kind != kindHello || !ok || !checkProof(tc, secret, "dialer", hello.Body) {
+		b.logger.Warn("Cluster peer rejected", zap.String("node", hello.From), zap.String("remoteAddr", conn.RemoteAddr().String()))
+		return
+	}
+	from := hello.From
+	proof, err := sessionProof(tc, secret, "listener")
+	if err != nil {
+		return
+	}
+	body, _ := json.Marshal(proof)
+	if err := enc.Encode(Message{Kind: kindHello, From: b.node, Reply: true, Body: body}); err != nil {
+		return
+	}
+	_ = conn.SetDeadline(time.Time{})
+
+	var writeMu sync.Mutex
+	for {
+		var m Message
+		if err := dec.Decode(&m); err != nil {
+			return
+		}
+		if m.ID == 0 {
+			b.serve(from, m)
+			continue
+		}
+		go func() {
+			reply := b.serve(from, m)
+			writeMu.Lock()
+			_ = enc.Encode(reply)
+			writeMu.Unlock()
+		}()
+	}
+}
+
+// sessionProof shows that this node knows the secret, as an HMAC of keying
+// material exported from the TLS session. It is worthless on any other
+// session, so a peer relaying it between two connections gains nothing.
+func sessionProof(conn *tls.Conn, secret, role string) ([]byte, error) {
+	state := conn.ConnectionState()
+	ekm, err := state.ExportKeyingMaterial(exporterLabel, []byte(role), sha256.Size)
+	if err != nil {
+		return nil, err
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(ekm)
+	return mac.Sum(nil), nil
+}
+
+// checkProof reports whether body holds the peer's sessionProof for role.
+func checkProof(conn *tls.Conn, secret, role string, body json.RawMessage) bool {
+	var got []byte
+	if err := json.Unmarshal(body, &got); err != nil {
+		return false
+	}
+	want, err := sessionProof(conn, secret, role)
+	return err == nil && hmac.Equal(got, want)
+}
+
+// selfSignedCert creates the throwaway certificate a bus listens with.
+func selfSignedCert() (tls.Certificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().AddDate(10, 0, 0),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, nil
+}
+
+// serve runs the handler of a message from the peer from and returns the
+// reply to it.
+func (b *Bus) serve(from string, m Message) Message {
+	reply := Message{Kind: m.Kind, From: b.node, ID: m.ID, Reply: true}
+	b.mu.Lock()
+	h, ok := b.handlers[m.Kind]
+	b.mu.Unlock()
+	if !ok {
+		reply.Error = "no handler for " + m.Kind
+		return reply
+	}
+	result, err := h(from, m.Body)
+	if err == nil {
+		reply.Body, err = json.Marshal(result)
+	}
+	if err != nil {
+		reply.Error = err.Error()
+		if m.ID == 0 {
+			b.logger.Warn("Cluster message failed", zap.String("kind", m.Kind), zap.String("node", from), zap.Error(err))
+		}
+	}
+	return reply
+}
+
+// send writes a message to the peer, dropping the connection if it fails.
+func (p *peer) send(m Message) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.conn == nil {
+		return false
+	}
+	if err := p.enc.Encode(m); err != nil {
+		_ = p.conn.Close()
+		p.conn, p.enc = nil, nil
+		return false
+	}
+	return true
+}
+
+func (p *peer) close() {
+	p.mu.Lock()
+	if p.conn != nil {
+		_ = p.conn.Close()
+		p.conn, p.enc = nil, nil
+	}
+	p.mu.Unlock()
+}
@@ -0,0 +1,245 @@
+package cluster
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newPair returns two buses connected both ways.
+func newPair(t *testing.T, secretB string) (*Bus, *Bus) {
+	t.Helper()
+	a, b := New("a", "secret", nil), New("b", secretB, nil)
+	t.Cleanup(a.Close)
+	t.Cleanup(b.Close)
+	for _, bus := range []*Bus{a, b} {
+		if err := bus.Listen("127.0.0.1:0"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	a.Connect([]string{b.Addr().String()})
+	b.Connect([]string{a.Addr().String()})
+	return a, b
+}
+
+func waitConnected(t *testing.T, buses ...*Bus) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for _, bus := range buses {
+		for len(bus.Connected()) == 0 {
+			if time.Now().After(deadline) {
+				t.Fatal("peers did not connect")
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+}
+
+func TestPublish(t *testing.T) {
+	a, b := newPair(t, "secret")
+	got := make(chan string, 1)
+	b.Handle("chat", func(from string, body json.RawMessage) (any, error) {
+		var msg string
+		_ = json.Unmarshal(body, &msg)
+		got <- from + ": " + msg
+		return nil, nil
+	})
+	waitConnected(t, a, b)
+
+	if err := a.Publish("chat", "hello"); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case msg := <-got:
+		if msg != "a: hello" {
+			t.Errorf("received %q", msg)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("message not delivered")
+	}
+}
+
+func TestRequest(t *testing.T) {
+	a, b := newPair(t, "secret")
+	b.Handle("double", func(_ string, body json.RawMessage) (any, error) {
+		var n int
+		_ = json.Unmarshal(body, &n)
+		return n * 2, nil
+	})
+	b.Handle("fail", func(string, json.RawMessage) (any, error) {
+		return nil, errors.New("no")
+	})
+	waitConnected(t, a, b)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	replies, err := a.Request(ctx, "double", 21)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(replies) != 1 || string(replies[0]) != "42" {
+		t.Errorf("replies = %s", replies)
+	}
+	if replies, _ := a.Request(ctx, "fail", nil); len(replies) != 0 {
+		t.Errorf("failed request replied %s", replies)
+	}
+	if replies, _ := a.Request(ctx, "unknown", nil); len(replies) != 0 {
+		t.Errorf("unhandled request replied %s", replies)
+	}
+	if !slices.Equal(a.Connected(), []string{"b"}) {
+		t.Errorf("Connected() = %v", a.Connected())
+	}
+}
+
+func TestWrongSecretRejected(t *testing.T) {
+	a, b := newPair(t, "wrong")
+	time.Sleep(200 * time.Millisecond)
+	if len(a.Connected()) != 0 || len(b.Connected()) != 0 {
+		t.Errorf("connected with a wrong secret: %v, %v", a.Connected(), b.Connected())
+	}
+}
+
+func TestPublishInOrder(t *testing.T) {
+	a, b := newPair(t, "secret")
+	got := make(chan int, 100)
+	b.Handle("chat", func(_ string, body json.RawMessage) (any, error) {
+		var n int
+		_ = json.Unmarshal(body, &n)
+		if n%10 == 0 {
+			time.Sleep(5 * time.Millisecond)
+		}
+		got <- n
+		return nil, nil
+	})
+	waitConnected(t, a, b)
+
+	for n := 0; n < 100; n++ {
+		if err := a.Publish("chat", n); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for want := 0; want < 100; want++ {
+		select {
+		case n := <-got:
+			if n != want {
+				t.Fatalf("received %d, want %d", n, want)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("message not delivered")
+		}
+	}
+}
+
+func TestHandshakeKeepsSecret(t *testing.T) {
+	cert, err := selfSignedCert()
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: []tls.Certificate{cert}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = l.Close() }()
+	hello := make(chan Message, 1)
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer func() { _ = conn.Close() }()
+		var m Message
+		_ = json.NewDecoder(conn).Decode(&m)
+		hello <- m
+		// Accept without knowing the secret.
+		_ = json.NewEncoder(conn).Encode(Message{Kind: kindHello, From: "impostor", Reply: true})
+		time.Sleep(500 * time.Millisecond)
+	}()
+
+	a := New("a", "secret", nil)
+	defer a.Close()
+	a.Connect([]string{l.Addr().String()})
+	select {
+	case m := <-hello:
+		if strings.Contains(string(m.Body), "secret") {
+			t.Errorf("hello carries the secret: %s", m.Body)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no hello received")
+	}
+	time.Sleep(200 * time.Millisecond)
+	if len(a.Connected()) != 0 {
+		t.Errorf("connected to a peer without the secret: %v", a.Connected())
+	}
+}
+
+func TestKeyedRejectsImpersonation(t *testing.T) {
+	secrets := func(keys map[string]string) Secrets {
+		return func(node string) (string, bool) {
+			s, ok := keys[node]
+			return s, ok
+		}
+	}
+	b := NewKeyed("b", secrets(map[string]string{"a": "ab", "c": "cb"}), nil)
+	defer b.Close()
+	if err := b.Listen("127.0.0.1:0"); err != nil {
+		t.Fatal(err)
+	}
+	// c knows only the secret it shares with b, and claims to be a.
+	impostor := NewKeyed("a", secrets(map[string]string{"b": "cb"}), nil)
+	defer impostor.Close()
+	impostor.ConnectNode("b", b.Addr().String())
+	a := NewKeyed("a", secrets(map[string]string{"b": "ab"}), nil)
+	defer a.Close()
+	a.ConnectNode("b", b.Addr().String())
+
+	waitConnected(t, a)
+	time.Sleep(200 * time.Millisecond)
+	if len(impostor.Connected()) != 0 {
+		t.Error("a peer was accepted under a name it has no secret for")
+	}
+}
+
+func TestServeUsesAuthenticatedName(t *testing.T) {
+	b := New("b", "secret", nil)
+	defer b.Close()
+	got := make(chan string, 1)
+	b.Handle("chat", func(from string, _ json.RawMessage) (any, error) {
+		got <- from
+		return nil, nil
+	})
+	if err := b.Listen("127.0.0.1:0"); err != nil {
+		t.Fatal(err)
+	}
+
+	conn, err := tls.Dial("tcp", b.Addr().String(), &tls.Config{InsecureSkipVerify: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = conn.Close() }()
+	proof, err := sessionProof(conn, "secret", "dialer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := json.Marshal(proof)
+	enc, dec := json.NewEncoder(conn), json.NewDecoder(conn)
+	_ = enc.Encode(Message{Kind: kindHello, From: "c", Body: body})
+	var ack Message
+	if err := dec.Decode(&ack); err != nil {
+		t.Fatal(err)
+	}
+	_ = enc.Encode(Message{Kind: "chat", From: "a"})
+
+	select {
+	case from := <-got:
+		if from != "c" {
+			t.Errorf("served as from %q, want the authenticated %q", from, "c")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("message not delivered")
+	}
+}
@@ -0,0 +1,8 @@
+// Package cluster is the internal message bus between Erupe processes that
+// each run some of the channels. Every node dials every peer it is
+// configured with over TLS, and the two prove to each other that they know
+// a shared secret without sending it. A node can then publish a message to
+// all connected peers, which each serve them in order, or send a request to
+// all of them and gather their replies. Messages are JSON objects, one
+// after another on the connection.
+package cluster
@@ -0,0 +1,433 @@
+package console
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"erupe-ce/common/gametime"
+	cfg "erupe-ce/config"
+	"erupe-ce/server/channelserver"
+
+	"go.uber.org/zap"
+)
+
+// Channel is the part of a channel server the console reads and controls.
+type Channel interface {
+	Status() channelserver.ChannelStatus
+	Sessions() []channelserver.SessionStatus
+	Kick(charID uint32) bool
+	SetLag(charID uint32, lag channelserver.Lag) bool
+}
+
+// Config holds the dependencies required to initialize a Console.
+type Config struct {
+	Logger *zap.Logger
+	Debug  *cfg.DebugOptions // Flags toggled by the debug command
+	Errors *ErrorLog         // Source of the errors command, may be nil
+}
+
+// Console runs admin commands against the channel servers.
+type Console struct {
+	logger *zap.Logger
+	debug  *cfg.DebugOptions
+	errors *ErrorLog
+	now    func() time.Time
+
+	mu       sync.Mutex
+	channels []Channel
+	sampled  time.Time
+	packets  map[uint16]uint64  // Packet counts at sampled
+	rates    map[uint16]float64 // Packets per second up to sampled
+
+	ln    net.Listener
+	open  map[net.Conn]struct{} // Attached consoles, guarded by mu
+	conns sync.WaitGroup
+}
+
+// New creates a Console. Channels are added with SetChannels once they have
+// started.
+func New(config *Config) *Console {
+	return &Console{
+		logger: config.Logger,
+		debug:  config.Debug,
+		errors: config.Errors,
+		now:    time.Now,
+	}
+}
+
+// SetChannels sets the channel servers the console reports on.
+func (c *Console) SetChannels(channels []Channel) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.channels = channels
+	c.sampled = c.now()
+	c.packets = make(map[uint16]uint64, len(channels))
+	c.rates = make(map[uint16]float64, len(channels))
+	for _, ch := range channels {
+		st := ch.Status()
+		c.packets[st.ID] = st.Packets
+	}
+}
+
+// snapshot returns the status of every channel and its packet rate. Rates
+// are measured between calls at least a second apart, so repeated status
+// commands and watch refreshes each show the rate since the last one.
+func (c *Console) snapshot() ([]channelserver.ChannelStatus, map[uint16]float64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	statuses := make([]channelserver.ChannelStatus, len(c.channels))
+	for i, ch := range c.channels {
+		statuses[i] = ch.Status()
+	}
+	now := c.now()
+	if elapsed := now.Sub(c.sampled).Seconds(); elapsed >= 1 {
+		for _, st := range statuses {
+			c.rates[st.ID] = float64(st.Packets-c.packets[st.ID]) / elapsed
+			c.packets[st.ID] = st.Packets
+		}
+		c.sampled = now
+	}
+	rates := make(map[uint16]float64, len(c.rates))
+	for id, r := range c.rates {
+		rates[id] = r
+	}
+	return statuses, rates
+}
+
+func (c *Console) channelList() []Channel {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.channels
+}
+
+// errUsage is returned for a command given the wrong arguments.
+var errUsage = errors.New("usage")
+
+type command struct {
+	usage string
+	help  string
+	run   func(c *Console, w io.Writer, args []string) error
+}
+
+var commands map[string]command
+
+func init() {
+	commands = map[string]command{
+		"help":     {"help", "List the commands", (*Console).help},
+		"status":   {"status", "Show the population and packet rate of each channel", (*Console).status},
+		"stages":   {"stages [channel]", "Show the occupancy of stages with players in them", (*Console).stages},
+		"sessions": {"sessions [channel]", "List connected players", (*Console).sessions},
+		"errors":   {"errors [count]", "Show the most recent errors", (*Console).recentErrors},
+		"kick":     {"kick <charID>", "Disconnect a character", (*Console).kick},
+		"debug":    {"debug [flag on|off]", "Show or toggle debug flags", (*Console).toggleDebug},
+		"clock":    {"clock [warp <duration>|reset]", "Show or warp the game clock, such as warp 36h or warp 7d", (*Console).gameClock},
+		"lag":      {"lag [charID <delay> [jitter]|charID off]", "Show or simulate a bad connection to a character, such as lag 1234 300ms 200ms", (*Console).lag},
+		"watch":    {"watch [seconds]", "Refresh status until a line is entered (socket only)", nil},
+		"quit":     {"quit", "Close the console (socket only)", nil},
+	}
+}
+
+// commandOrder is the order commands are listed by help.
+var commandOrder = []string{"status", "stages", "sessions", "errors", "kick", "debug", "clock", "lag", "watch", "help", "quit"}
+
+// Exec runs one command line and writes its output to w. Commands that need
+// a live connection, watch and quit, are only handled on the socket.
+func (c *Console) Exec(w io.Writer, line string) error {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return nil
+	}
+	cmd, ok := commands[strings.ToLower(fields[0])]
+	if !ok {
+		return fmt.Errorf("unknown command %q, try help", fields[0])
+	}
+	if cmd.run == nil {
+		return fmt.Errorf("%s is only available on the console socket", fields[0])
+	}
+	if err := cmd.run(c, w, fields[1:]); err != nil {
+		if errors.Is(err, errUsage) {
+			return fmt.Errorf("usage: %s", cmd.usage)
+		}
+		return err
+	}
+	return nil
+}
+
+func (c *Console) help(w io.Writer, _ []string) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, name := range commandOrder {
+		_, _ = fmt.Fprintf(tw, "%s\t%s\n", commands[name].usage, commands[name].help)
+	}
+	return tw.Flush()
+}
+
+func (c *Console) status(w io.Writer, args []string) error {
+	if len(args) > 0 {
+		return errUsage
+	}
+	statuses, rates := c.snapshot()
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "CHANNEL\tNAME\tPORT\tPLAYERS\tSTAGES\tPACKETS/S")
+	var players int
+	var rate float64
+	for _, st := range statuses {
+		players += st.Players
+		rate += rates[st.ID]
+		_, _ = fmt.Fprintf(tw, "%d\t%s\t%d\t%d\t%d\t%.1f\n", st.ID, st.Name, st.Port, st.Players, len(st.Stages), rates[st.ID])
+	}
+	_, _ = fmt.Fprintf(tw, "total\t\t\t%d\t\t%.1f\n", players, rate)
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	if c.errors != nil {
+		_, _ = fmt.Fprintf(w, "%d errors logged since start\n", c.errors.Total())
+	}
+	return nil
+}
+
+// channelFilter parses the optional channel ID argument of stages and
+// sessions, returning 0 for all channels.
+func channelFilter(args []string) (uint16, error) {
+	switch len(args) {
+	case 0:
+		return 0, nil
+	case 1:
+		id, err := strconv.ParseUint(args[0], 10, 16)
+		if err != nil {
+			return 0, errUsage
+		}
+		return uint16(id), nil
+	default:
+		return 0, errUsage
+	}
+}
+
+func (c *Console) stages(w io.Writer, args []string) error {
+	filter, err := channelFilter(args)
+	if err != nil {
+		return err
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "CHANNEL\tSTAGE\tPLAYERS\tRESERVED\tMAX\tHOST")
+	for _, ch := range c.channelList() {
+		st := ch.Status()
+		if filter != 0 && st.ID != filter {
+			continue
+		}
+		for _, stage := range st.Stages {
+			_, _ = fmt.Fprintf(tw, "%d\t%s\t%d\t%d\t%d\t%s\n", st.ID, stage.ID, stage.Players, stage.Reserved, stage.MaxPlayers, stage.Host)
+		}
+	}
+	return tw.Flush()
+}
+
+func (c *Console) sessions(w io.Writer, args []string) error {
+	filter, err := channelFilter(args)
+	if err != nil {
+		return err
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "CHANNEL\tCHAR\tNAME\tSTAGE\tREMOTE")
+	for _, ch := range c.channelList() {
+		id := ch.Status().ID
+		if filter != 0 && id != filter {
+			continue
+		}
+		for _, s := range ch.Sessions() {
+			_, _ = fmt.Fprintf(tw, "%d\t%d\t%s\t%s\t%s\n", id, s.CharID, s.Name, s.Stage, s.Remote)
+		}
+	}
+	return tw.Flush()
+}
+
+func (c *Console) recentErrors(w io.Writer, args []string) error {
+	n := 10
+	switch len(args) {
+	case 0:
+	case 1:
+		v, err := strconv.Atoi(args[0])
+		if err != nil || v <= 0 {
+			return errUsage
+		}
+		n = v
+	default:
+		return errUsage
+	}
+	if c.errors == nil {
+		return errors.New("error log is not enabled")
+	}
+	for _, e := range c.errors.Recent(n) {
+		_, _ = fmt.Fprintf(w, "%s %s: %s %s\n", e.Time.Format(time.DateTime), e.Logger, e.Message, e.Fields)
+	}
+	return nil
+}
+
+func (c *Console) kick(w io.Writer, args []string) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+	charID, err := strconv.ParseUint(args[0], 10, 32)
+	if err != nil {
+		return errUsage
+	}
+	for _, ch := range c.channelList() {
+		if ch.Kick(uint32(charID)) {
+			c.logger.Info("Kicked character from console", zap.Uint64("charID", charID), zap.Uint16("channel", ch.Status().ID))
+			_, _ = fmt.Fprintf(w, "Kicked character %d from channel %d\n", charID, ch.Status().ID)
+			return nil
+		}
+	}
+	return fmt.Errorf("character %d is not connected", charID)
+}
+
+type debugFlag struct {
+	name string
+	v    *bool
+}
+
+// debugFlags returns the debug options that can be toggled at runtime. They
+// are read without locking, so a change is seen by each reader on its next
+// check rather than at once.
+func (c *Console) debugFlags() []debugFlag {
+	return []debugFlag{
+		{"LogInboundMessages", &c.debug.LogInboundMessages},
+		{"LogOutboundMessages", &c.debug.LogOutboundMessages},
+		{"LogMessageData", &c.debug.LogMessageData},
+		{"QuestTools", &c.debug.QuestTools},
+		{"AutoQuestBackport", &c.debug.AutoQuestBackport},
+	}
+}
+
+func (c *Console) toggleDebug(w io.Writer, args []string) error {
+	if c.debug == nil {
+		return errors.New("debug flags are not available")
+	}
+	flags := c.debugFlags()
+	switch len(args) {
+	case 0:
+		for _, f := range flags {
+			_, _ = fmt.Fprintf(w, "%s: %s\n", f.name, onOff(*f.v))
+		}
+		return nil
+	case 2:
+	default:
+		return errUsage
+	}
+	var value bool
+	switch strings.ToLower(args[1]) {
+	case "on", "true", "1":
+		value = true
+	case "off", "false", "0":
+	default:
+		return errUsage
+	}
+	for _, f := range flags {
+		if strings.EqualFold(f.name, args[0]) {
+			*f.v = value
+			c.logger.Info("Debug flag changed from console", zap.String("flag", f.name), zap.Bool("value", value))
+			_, _ = fmt.Fprintf(w, "%s: %s\n", f.name, onOff(value))
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown debug flag %q", args[0])
+}
+
+func (c *Console) gameClock(w io.Writer, args []string) error {
+	var warp func()
+	switch {
+	case len(args) == 0:
+	case len(args) == 1 && strings.EqualFold(args[0], "reset"):
+		warp = gametime.ResetWarp
+	case len(args) == 2 && strings.EqualFold(args[0], "warp"):
+		d, err := parseWarp(args[1])
+		if err != nil {
+			return errUsage
+		}
+		warp = func() { gametime.Warp(d) }
+	default:
+		return errUsage
+	}
+	if warp != nil {
+		if c.debug == nil || !c.debug.TimeWarp {
+			return errors.New("time warp is disabled, set DebugOptions.TimeWarp to allow it")
+		}
+		warp()
+		c.logger.Warn("Game clock warped from console", zap.Duration("offset", gametime.WarpOffset()))
+	}
+	_, _ = fmt.Fprintf(w, "Game time: %s (warped %s)\n", gametime.Adjusted().Format(time.DateTime), gametime.WarpOffset())
+	return nil
+}
+
+func (c *Console) lag(w io.Writer, args []string) error {
+	if len(args) == 0 {
+		for _, ch := range c.channelList() {
+			for _, s := range ch.Sessions() {
+				if !s.Lag.IsZero() {
+					_, _ = fmt.Fprintf(w, "%d %s: delay %s, jitter %s\n", s.CharID, s.Name, s.Lag.Delay, s.Lag.Jitter)
+				}
+			}
+		}
+		return nil
+	}
+	if len(args) > 3 {
+		return errUsage
+	}
+	charID, err := strconv.ParseUint(args[0], 10, 32)
+	if err != nil || len(args) == 1 {
+		return errUsage
+	}
+	var lag channelserver.Lag
+	if len(args) != 2 || !strings.EqualFold(args[1], "off") {
+		if lag.Delay, err = time.ParseDuration(args[1]); err != nil {
+			return errUsage
+		}
+		if len(args) == 3 {
+			if lag.Jitter, err = time.ParseDuration(args[2]); err != nil {
+				return errUsage
+			}
+		}
+		if lag.Delay < 0 || lag.Jitter < 0 || lag.Delay > channelserver.MaxLag || lag.Jitter > channelserver.MaxLag {
+			return fmt.Errorf("delay and jitter must be between 0 and %s", channelserver.MaxLag)
+		}
+		if c.debug == nil || !c.debug.LagSimulation {
+			return errors.New("lag simulation is disabled, set DebugOptions.LagSimulation to allow it")
+		}
+	}
+	for _, ch := range c.channelList() {
+		if ch.SetLag(uint32(charID), lag) {
+			c.logger.Warn("Lag simulation changed from console", zap.Uint64("charID", charID),
+				zap.Duration("delay", lag.Delay), zap.Duration("jitter", lag.Jitter))
+			if lag.IsZero() {
+				_, _ = fmt.Fprintf(w, "Character %d: lag off\n", charID)
+			} else {
+				_, _ = fmt.Fprintf(w, "Character %d: delay %s, jitter %s\n", charID, lag.Delay, lag.Jitter)
+			}
+			return nil
+		}
+	}
+	return fmt.Errorf("character %d is not connected", charID)
+}
+
+// parseWarp parses a duration for time.ParseDuration, or a whole number of
+// days such as "7d" or "-1d".
+func parseWarp(s string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		return time.Duration(n) * 24 * time.Hour, err
+	}
+	return time.ParseDuration(s)
+}
+
+func onOff(v bool) string {
+	if v {
+		return "on"
+	}
+	return "off"
+}
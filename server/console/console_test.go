@@ -0,0 +1,240 @@
+package console
+
+import (
+	"bufio"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"erupe-ce/common/gametime"
+	cfg "erupe-ce/config"
+	"erupe-ce/server/channelserver"
+
+	"go.uber.org/zap"
+)
+
+type fakeChannel struct {
+	status   channelserver.ChannelStatus
+	sessions []channelserver.SessionStatus
+	kicked   []uint32
+	lags     map[uint32]channelserver.Lag
+}
+
+func (f *fakeChannel) Status() channelserver.ChannelStatus     { return f.status }
+func (f *fakeChannel) Sessions() []channelserver.SessionStatus { return f.sessions }
+
+func (f *fakeChannel) Kick(charID uint32) bool {
+	for _, s := range f.sessions {
+		if s.CharID == charID {
+			f.kicked = append(f.kicked, charID)
+			return true
+		}
+	}
+	return false
+}
+
+func (f *fakeChannel) SetLag(charID uint32, lag channelserver.Lag) bool {
+	for i, s := range f.sessions {
+		if s.CharID == charID {
+			f.sessions[i].Lag = lag
+			return true
+		}
+	}
+	return false
+}
+
+func newTestConsole(t *testing.T) (*Console, *fakeChannel, *cfg.DebugOptions) {
+	t.Helper()
+	ch := &fakeChannel{
+		status: channelserver.ChannelStatus{
+			ID: 4112, Name: "Newbie", Port: 54001, Players: 1,
+			Stages: []channelserver.StageStatus{{ID: "sl2Qs123p0a0u42", Players: 1, MaxPlayers: 4, Host: "Alice"}},
+		},
+		sessions: []channelserver.SessionStatus{{CharID: 100, Name: "Alice", Stage: "sl2Qs123p0a0u42", Remote: "10.0.0.2:5000"}},
+	}
+	debug := &cfg.DebugOptions{}
+	c := New(&Config{Logger: zap.NewNop(), Debug: debug, Errors: NewErrorLog(10)})
+	c.SetChannels([]Channel{ch})
+	return c, ch, debug
+}
+
+func exec(t *testing.T, c *Console, line string) string {
+	t.Helper()
+	var out strings.Builder
+	if err := c.Exec(&out, line); err != nil {
+		t.Fatalf("Exec(%q) error: %v", line, err)
+	}
+	return out.String()
+}
+
+func TestConsole_StatusReportsPacketRate(t *testing.T) {
+	c, ch, _ := newTestConsole(t)
+	now := time.Now()
+	c.now = func() time.Time { return now }
+	c.SetChannels([]Channel{ch})
+
+	ch.status.Packets = 50
+	now = now.Add(10 * time.Second)
+	out := exec(t, c, "status")
+	if !strings.Contains(out, "Newbie") || !strings.Contains(out, "5.0") {
+		t.Errorf("status output missing channel or rate of 5.0:\n%s", out)
+	}
+}
+
+func TestConsole_StagesAndSessions(t *testing.T) {
+	c, _, _ := newTestConsole(t)
+	if out := exec(t, c, "stages"); !strings.Contains(out, "sl2Qs123p0a0u42") || !strings.Contains(out, "Alice") {
+		t.Errorf("stages output:\n%s", out)
+	}
+	if out := exec(t, c, "sessions 4112"); !strings.Contains(out, "10.0.0.2:5000") {
+		t.Errorf("sessions output:\n%s", out)
+	}
+	if out := exec(t, c, "sessions 1"); strings.Contains(out, "Alice") {
+		t.Errorf("sessions for another channel listed Alice:\n%s", out)
+	}
+}
+
+func TestConsole_Kick(t *testing.T) {
+	c, ch, _ := newTestConsole(t)
+	exec(t, c, "kick 100")
+	if len(ch.kicked) != 1 || ch.kicked[0] != 100 {
+		t.Errorf("kicked = %v, want [100]", ch.kicked)
+	}
+	if err := c.Exec(&strings.Builder{}, "kick 999"); err == nil {
+		t.Error("kick of a character that is not connected should fail")
+	}
+	if err := c.Exec(&strings.Builder{}, "kick alice"); err == nil || !strings.HasPrefix(err.Error(), "usage:") {
+		t.Errorf("kick alice error = %v, want usage", err)
+	}
+}
+
+func TestConsole_ToggleDebug(t *testing.T) {
+	c, _, debug := newTestConsole(t)
+	exec(t, c, "debug loginboundmessages on")
+	if !debug.LogInboundMessages {
+		t.Error("debug on did not set LogInboundMessages")
+	}
+	if out := exec(t, c, "debug"); !strings.Contains(out, "LogInboundMessages: on") {
+		t.Errorf("debug output:\n%s", out)
+	}
+	if err := c.Exec(&strings.Builder{}, "debug CleanDB on"); err == nil {
+		t.Error("toggling a flag that is not exposed should fail")
+	}
+}
+
+func TestConsole_Lag(t *testing.T) {
+	c, ch, debug := newTestConsole(t)
+
+	if err := c.Exec(&strings.Builder{}, "lag 100 300ms"); err == nil {
+		t.Fatal("lag succeeded with DebugOptions.LagSimulation off")
+	}
+	debug.LagSimulation = true
+	exec(t, c, "lag 100 300ms 50ms")
+	if got := ch.sessions[0].Lag; got.Delay != 300*time.Millisecond || got.Jitter != 50*time.Millisecond {
+		t.Errorf("Lag = %+v, want 300ms delay and 50ms jitter", got)
+	}
+	if out := exec(t, c, "lag"); !strings.Contains(out, "100 Alice: delay 300ms, jitter 50ms") {
+		t.Errorf("lag output:\n%s", out)
+	}
+	exec(t, c, "lag 100 off")
+	if !ch.sessions[0].Lag.IsZero() {
+		t.Errorf("Lag = %+v after off", ch.sessions[0].Lag)
+	}
+	if err := c.Exec(&strings.Builder{}, "lag 100 1h"); err == nil {
+		t.Error("lag above MaxLag should fail")
+	}
+	if err := c.Exec(&strings.Builder{}, "lag 999 100ms"); err == nil {
+		t.Error("lag of a character that is not connected should fail")
+	}
+}
+
+func TestConsole_ClockWarp(t *testing.T) {
+	c, _, debug := newTestConsole(t)
+	defer gametime.ResetWarp()
+
+	if err := c.Exec(&strings.Builder{}, "clock warp 2d"); err == nil {
+		t.Fatal("clock warp succeeded with DebugOptions.TimeWarp off")
+	}
+	debug.TimeWarp = true
+	if out := exec(t, c, "clock warp 2d"); !strings.Contains(out, "warped 48h0m0s") {
+		t.Errorf("clock warp output:\n%s", out)
+	}
+	exec(t, c, "clock warp -90m")
+	if got := gametime.WarpOffset(); got != 46*time.Hour+30*time.Minute {
+		t.Errorf("WarpOffset() = %v, want 46h30m", got)
+	}
+	exec(t, c, "clock reset")
+	if got := gametime.WarpOffset(); got != 0 {
+		t.Errorf("WarpOffset() after reset = %v, want 0", got)
+	}
+	if err := c.Exec(&strings.Builder{}, "clock warp soon"); err == nil {
+		t.Error("clock warp with a bad duration should fail")
+	}
+}
+
+func TestConsole_Errors(t *testing.T) {
+	c, _, _ := newTestConsole(t)
+	logger := zap.NewNop().WithOptions(c.errors.LoggerOption()).Named("channel-1")
+	logger.Warn("Not kept")
+	logger.Error("Failed to save", zap.Uint32("charID", 100))
+
+	out := exec(t, c, "errors")
+	if !strings.Contains(out, "channel-1: Failed to save charID=100") || strings.Contains(out, "Not kept") {
+		t.Errorf("errors output:\n%s", out)
+	}
+}
+
+func TestConsole_UnknownAndSocketOnlyCommands(t *testing.T) {
+	c, _, _ := newTestConsole(t)
+	for _, line := range []string{"reboot", "watch", "quit"} {
+		if err := c.Exec(&strings.Builder{}, line); err == nil {
+			t.Errorf("Exec(%q) succeeded, want an error", line)
+		}
+	}
+}
+
+func TestErrorLog_KeepsNewest(t *testing.T) {
+	l := NewErrorLog(2)
+	for _, msg := range []string{"a", "b", "c"} {
+		l.add(ErrorEntry{Message: msg})
+	}
+	got := l.Recent(10)
+	if len(got) != 2 || got[0].Message != "b" || got[1].Message != "c" {
+		t.Errorf("Recent(10) = %+v, want b then c", got)
+	}
+	if got := l.Recent(1); len(got) != 1 || got[0].Message != "c" {
+		t.Errorf("Recent(1) = %+v, want c", got)
+	}
+	if l.Total() != 3 {
+		t.Errorf("Total() = %d, want 3", l.Total())
+	}
+}
+
+func TestConsole_Socket(t *testing.T) {
+	c, _, _ := newTestConsole(t)
+	path := filepath.Join(t.TempDir(), "console.sock")
+	if err := c.Listen(path); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Shutdown()
+
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = conn.Close() }()
+	_ = conn.SetDeadline(time.Now().Add(5 * time.Second))
+	if _, err := conn.Write([]byte("sessions\nbogus\nquit\n")); err != nil {
+		t.Fatal(err)
+	}
+	var out strings.Builder
+	scanner := bufio.NewScanner(conn)
+	for scanner.Scan() {
+		out.WriteString(scanner.Text() + "\n")
+	}
+	if !strings.Contains(out.String(), "Alice") || !strings.Contains(out.String(), `error: unknown command "bogus"`) {
+		t.Errorf("socket output:\n%s", out.String())
+	}
+}
@@ -0,0 +1,4 @@
+// Package console implements the interactive admin console, served on a
+// local Unix socket and through the admin API, for watching channel
+// populations and errors and for kicking players or toggling debug flags.
+package console
@@ -0,0 +1,117 @@
+package console
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// ErrorEntry is an error log entry kept for the console.
+type ErrorEntry struct {
+	Time    time.Time
+	Logger  string
+	Message string
+	Fields  string // Context fields as space-separated key=value pairs
+}
+
+// ErrorLog keeps the most recent error log entries in a ring.
+type ErrorLog struct {
+	mu      sync.Mutex
+	entries []ErrorEntry
+	next    int
+	total   uint64
+}
+
+// NewErrorLog creates an ErrorLog holding up to size entries.
+func NewErrorLog(size int) *ErrorLog {
+	return &ErrorLog{entries: make([]ErrorEntry, 0, max(size, 1))}
+}
+
+func (l *ErrorLog) add(e ErrorEntry) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.total++
+	if len(l.entries) < cap(l.entries) {
+		l.entries = append(l.entries, e)
+		return
+	}
+	l.entries[l.next] = e
+	l.next = (l.next + 1) % len(l.entries)
+}
+
+// Recent returns up to n of the newest entries, oldest first.
+func (l *ErrorLog) Recent(n int) []ErrorEntry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	ordered := append(slices.Clone(l.entries[l.next:]), l.entries[:l.next]...)
+	if n < len(ordered) {
+		ordered = ordered[len(ordered)-n:]
+	}
+	return ordered
+}
+
+// Total returns the number of entries logged since start, including those
+// no longer kept.
+func (l *ErrorLog) Total() uint64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.total
+}
+
+// LoggerOption tees a logger's error entries to l.
+func (l *ErrorLog) LoggerOption() zap.Option {
+	return zap.WrapCore(func(c zapcore.Core) zapcore.Core {
+		return zapcore.NewTee(c, &errorCore{log: l})
+	})
+}
+
+// errorCore is a zap core recording entries at Error level and above.
+type errorCore struct {
+	log    *ErrorLog
+	fields []zapcore.Field
+}
+
+func (c *errorCore) Enabled(lvl zapcore.Level) bool {
+	return lvl >= zapcore.ErrorLevel
+}
+
+func (c *errorCore) With(fields []zapcore.Field) zapcore.Core {
+	return &errorCore{log: c.log, fields: append(slices.Clip(c.fields), fields...)}
+}
+
+func (c *errorCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *errorCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	enc := zapcore.NewMapObjectEncoder()
+	for _, f := range append(slices.Clip(c.fields), fields...) {
+		f.AddTo(enc)
+	}
+	keys := make([]string, 0, len(enc.Fields))
+	for k := range enc.Fields {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	pairs := make([]string, len(keys))
+	for i, k := range keys {
+		pairs[i] = fmt.Sprintf("%s=%v", k, enc.Fields[k])
+	}
+	c.log.add(ErrorEntry{
+		Time:    ent.Time,
+		Logger:  ent.LoggerName,
+		Message: ent.Message,
+		Fields:  strings.Join(pairs, " "),
+	})
+	return nil
+}
+
+func (c *errorCore) Sync() error { return nil }
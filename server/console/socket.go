@@ -0,0 +1,152 @@
+package console
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Listen serves the console on a Unix socket at path in a new goroutine.
+// A stale socket left by an unclean exit is replaced.
+func (c *Console) Listen(path string) error {
+	if info, err := os.Lstat(path); err == nil && info.Mode()&os.ModeSocket != 0 {
+		_ = os.Remove(path)
+	}
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return err
+	}
+	// Anyone who can connect can kick players, so keep it to this user.
+	if err := os.Chmod(path, 0o600); err != nil {
+		_ = ln.Close()
+		return err
+	}
+	c.ln = ln
+	c.open = make(map[net.Conn]struct{})
+	go c.accept()
+	return nil
+}
+
+func (c *Console) accept() {
+	for {
+		conn, err := c.ln.Accept()
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				c.logger.Error("Console stopped accepting connections", zap.Error(err))
+			}
+			return
+		}
+		c.mu.Lock()
+		c.open[conn] = struct{}{}
+		c.mu.Unlock()
+		c.conns.Add(1)
+		go func() {
+			defer c.conns.Done()
+			c.serve(conn)
+			c.mu.Lock()
+			delete(c.open, conn)
+			c.mu.Unlock()
+		}()
+	}
+}
+
+// serve runs commands read from conn, one per line, until it is closed or
+// sends quit.
+func (c *Console) serve(conn net.Conn) {
+	defer func() { _ = conn.Close() }()
+	c.logger.Info("Console attached")
+	defer c.logger.Info("Console detached")
+
+	lines := make(chan string)
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		defer close(lines)
+		scanner := bufio.NewScanner(conn)
+		for scanner.Scan() {
+			select {
+			case lines <- scanner.Text():
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	_, _ = fmt.Fprintln(conn, "Erupe console, type help for commands")
+	for {
+		_, _ = io.WriteString(conn, "> ")
+		line, ok := <-lines
+		if !ok {
+			return
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		var err error
+		switch strings.ToLower(fields[0]) {
+		case "quit", "exit":
+			return
+		case "watch":
+			if !c.watch(conn, fields[1:], lines) {
+				return
+			}
+		default:
+			err = c.Exec(conn, line)
+		}
+		if err != nil {
+			_, _ = fmt.Fprintf(conn, "error: %v\n", err)
+		}
+	}
+}
+
+// watch reprints the status every interval until a line arrives on lines,
+// reporting false if the connection closed meanwhile.
+func (c *Console) watch(w io.Writer, args []string, lines <-chan string) bool {
+	interval := 2 * time.Second
+	if len(args) > 0 {
+		secs, err := strconv.Atoi(args[0])
+		if err != nil || secs <= 0 {
+			_, _ = fmt.Fprintf(w, "error: usage: %s\n", commands["watch"].usage)
+			return true
+		}
+		interval = time.Duration(secs) * time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		_, _ = fmt.Fprintf(w, "\n%s (press enter to stop)\n", c.now().Format(time.DateTime))
+		if err := c.status(w, nil); err != nil {
+			return false
+		}
+		select {
+		case _, ok := <-lines:
+			return ok
+		case <-ticker.C:
+		}
+	}
+}
+
+// Shutdown stops listening and disconnects attached consoles.
+func (c *Console) Shutdown() {
+	if c.ln == nil {
+		return
+	}
+	if err := c.ln.Close(); err != nil {
+		c.logger.Warn("Got error on console shutdown", zap.Error(err))
+	}
+	c.mu.Lock()
+	for conn := range c.open {
+		_ = conn.Close()
+	}
+	c.mu.Unlock()
+	c.conns.Wait()
+}
@@ -0,0 +1,126 @@
+package debugserver
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	rpprof "runtime/pprof"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Config holds the dependencies required to initialize a Server.
+type Config struct {
+	Logger  *zap.Logger
+	Address string // host:port to listen on
+}
+
+// Server serves the debug endpoints.
+type Server struct {
+	logger     *zap.Logger
+	httpServer *http.Server
+}
+
+// NewServer creates a new Server.
+func NewServer(config *Config) *Server {
+	return &Server{
+		logger: config.Logger,
+		httpServer: &http.Server{
+			Addr:    config.Address,
+			Handler: Handler(),
+		},
+	}
+}
+
+// Handler returns the debug endpoints on their own mux, so nothing is
+// registered on http.DefaultServeMux where the API could expose it.
+func Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.HandleFunc("/debug/runtime", Runtime)
+	mux.HandleFunc("/debug/goroutines", Goroutines)
+	mux.HandleFunc("/debug/heap", Heap)
+	return mux
+}
+
+// Start listens on the configured address and serves in a new goroutine.
+func (s *Server) Start() error {
+	ln, err := net.Listen("tcp", s.httpServer.Addr)
+	if err != nil {
+		return err
+	}
+	if host, _, err := net.SplitHostPort(s.httpServer.Addr); err == nil {
+		if ip := net.ParseIP(host); host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+			s.logger.Warn("Debug endpoints are reachable from other hosts", zap.String("address", s.httpServer.Addr))
+		}
+	}
+	go func() {
+		if err := s.httpServer.Serve(ln); err != nil && err != http.ErrServerClosed {
+			s.logger.Error("Debug server stopped", zap.Error(err))
+		}
+	}()
+	return nil
+}
+
+// Shutdown exits the server gracefully.
+func (s *Server) Shutdown() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := s.httpServer.Shutdown(ctx); err != nil {
+		s.logger.Warn("Got error on debug server shutdown", zap.Error(err))
+	}
+}
+
+// RuntimeStats is the JSON payload returned by /debug/runtime.
+type RuntimeStats struct {
+	Goroutines   int    `json:"goroutines"`
+	HeapAlloc    uint64 `json:"heap_alloc"`
+	HeapInuse    uint64 `json:"heap_inuse"`
+	HeapObjects  uint64 `json:"heap_objects"`
+	Sys          uint64 `json:"sys"`
+	NumGC        uint32 `json:"num_gc"`
+	PauseTotalNs uint64 `json:"pause_total_ns"`
+	LastPauseNs  uint64 `json:"last_pause_ns"`
+}
+
+// Runtime handles GET /debug/runtime, returning goroutine, heap and GC
+// figures for a quick look during a lag spike.
+func Runtime(w http.ResponseWriter, r *http.Request) {
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(RuntimeStats{
+		Goroutines:   runtime.NumGoroutine(),
+		HeapAlloc:    ms.HeapAlloc,
+		HeapInuse:    ms.HeapInuse,
+		HeapObjects:  ms.HeapObjects,
+		Sys:          ms.Sys,
+		NumGC:        ms.NumGC,
+		PauseTotalNs: ms.PauseTotalNs,
+		LastPauseNs:  ms.PauseNs[(ms.NumGC+255)%256],
+	})
+}
+
+// Goroutines handles GET /debug/goroutines, returning the stack of every
+// goroutine as plain text.
+func Goroutines(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	_ = rpprof.Lookup("goroutine").WriteTo(w, 2)
+}
+
+// Heap handles GET /debug/heap, running a garbage collection and returning
+// a heap profile for `go tool pprof`.
+func Heap(w http.ResponseWriter, r *http.Request) {
+	runtime.GC()
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Disposition", `attachment; filename="heap.pprof"`)
+	_ = rpprof.Lookup("heap").WriteTo(w, 0)
+}
@@ -0,0 +1,52 @@
+package debugserver
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestRuntimeEndpoint(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	Runtime(recorder, httptest.NewRequest("GET", "/debug/runtime", nil))
+
+	var stats RuntimeStats
+	if err := json.NewDecoder(recorder.Body).Decode(&stats); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if stats.Goroutines == 0 || stats.HeapAlloc == 0 {
+		t.Errorf("stats = %+v, want goroutines and heap figures", stats)
+	}
+}
+
+func TestGoroutinesEndpoint(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	Goroutines(recorder, httptest.NewRequest("GET", "/debug/goroutines", nil))
+
+	if !strings.Contains(recorder.Body.String(), "TestGoroutinesEndpoint") {
+		t.Error("expected the dump to include the test goroutine")
+	}
+}
+
+func TestHandlerRoutes(t *testing.T) {
+	h := Handler()
+	for _, path := range []string{"/debug/pprof/", "/debug/runtime", "/debug/goroutines", "/debug/heap"} {
+		recorder := httptest.NewRecorder()
+		h.ServeHTTP(recorder, httptest.NewRequest("GET", path, nil))
+		if recorder.Code != http.StatusOK {
+			t.Errorf("GET %s = %d, want %d", path, recorder.Code, http.StatusOK)
+		}
+	}
+}
+
+func TestServerStartShutdown(t *testing.T) {
+	s := NewServer(&Config{Logger: zap.NewNop(), Address: "127.0.0.1:0"})
+	if err := s.Start(); err != nil {
+		t.Fatalf("Start() error: %v", err)
+	}
+	s.Shutdown()
+}
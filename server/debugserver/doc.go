@@ -0,0 +1,4 @@
+// Package debugserver serves net/http/pprof profiles and runtime snapshots
+// on a separate listener, bound to localhost by default, so operators can
+// profile a live server without rebuilding it.
+package debugserver
@@ -0,0 +1,88 @@
+package discordbot
+
+import (
+	"erupe-ce/server/eventbus"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"go.uber.org/zap"
+)
+
+// Default embed colors per event type, used when an announcement has no
+// explicit Color configured.
+var defaultEventColors = map[eventbus.Type]int{
+	eventbus.SiegeStart:     0xE67E22,
+	eventbus.SiegeEnd:       0x95A5A6,
+	eventbus.FestaStandings: 0xC0392B,
+	eventbus.ServerFirst:    0xF1C40F,
+	eventbus.Maintenance:    0x3498DB,
+}
+
+// BuildEmbed renders an event as a Discord embed. A color of 0 selects the
+// default color for the event type.
+func BuildEmbed(e eventbus.Event, color int) *discordgo.MessageEmbed {
+	if color == 0 {
+		color = defaultEventColors[e.Type]
+	}
+	embed := &discordgo.MessageEmbed{
+		Title:       e.Title,
+		Description: e.Message,
+		Color:       color,
+		Timestamp:   e.Time.Format(time.RFC3339),
+	}
+	for _, f := range e.Fields {
+		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
+			Name:   f.Name,
+			Value:  f.Value,
+			Inline: true,
+		})
+	}
+	return embed
+}
+
+// SubscribeEvents registers the bot on bus so that events with a matching
+// enabled announcement are posted as embeds. It returns the unsubscribe func.
+func (bot *DiscordBot) SubscribeEvents(bus *eventbus.Bus) func() {
+	return bus.Subscribe(func(e eventbus.Event) {
+		go func() {
+			if err := bot.Announce(e); err != nil {
+				bot.logger.Warn("Discord: Failed to announce event", zap.String("event", string(e.Type)), zap.Error(err))
+			}
+		}()
+	})
+}
+
+// Announce posts e to every channel configured for its event type. Events
+// repeating the previous Key for the same type are suppressed, so a state
+// change published by several channel servers is only announced once.
+func (bot *DiscordBot) Announce(e eventbus.Event) error {
+	if !bot.shouldAnnounce(e) {
+		return nil
+	}
+	for _, a := range bot.config.Discord.Announcements {
+		if !a.Enabled || (a.ChannelID == "" && a.Webhook == "") || eventbus.Type(a.Event) != e.Type {
+			continue
+		}
+		msg := &discordgo.MessageSend{Embeds: []*discordgo.MessageEmbed{BuildEmbed(e, a.Color)}}
+		if err := bot.post(a.ChannelID, a.Webhook, msg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (bot *DiscordBot) shouldAnnounce(e eventbus.Event) bool {
+	if e.Key == "" {
+		return true
+	}
+	bot.announceMu.Lock()
+	defer bot.announceMu.Unlock()
+	if bot.lastEventKeys == nil {
+		bot.lastEventKeys = make(map[eventbus.Type]string)
+	}
+	if bot.lastEventKeys[e.Type] == e.Key {
+		return false
+	}
+	bot.lastEventKeys[e.Type] = e.Key
+	return true
+}
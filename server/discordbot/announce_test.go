@@ -0,0 +1,57 @@
+package discordbot
+
+import (
+	"erupe-ce/server/eventbus"
+	"testing"
+	"time"
+)
+
+func TestBuildEmbed(t *testing.T) {
+	ts := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	e := eventbus.Event{
+		Type:    eventbus.SiegeStart,
+		Time:    ts,
+		Title:   "Raviente siege started",
+		Message: "Berserk Raviente has appeared!",
+		Fields:  []eventbus.Field{{Name: "Type", Value: "Berserk"}},
+	}
+
+	embed := BuildEmbed(e, 0)
+	if embed.Title != e.Title || embed.Description != e.Message {
+		t.Errorf("embed title/description = %q/%q", embed.Title, embed.Description)
+	}
+	if embed.Color != defaultEventColors[eventbus.SiegeStart] {
+		t.Errorf("Color = %#x, want default %#x", embed.Color, defaultEventColors[eventbus.SiegeStart])
+	}
+	if embed.Timestamp != "2024-05-01T12:00:00Z" {
+		t.Errorf("Timestamp = %q", embed.Timestamp)
+	}
+	if len(embed.Fields) != 1 || embed.Fields[0].Name != "Type" || embed.Fields[0].Value != "Berserk" {
+		t.Errorf("Fields = %+v", embed.Fields)
+	}
+
+	if got := BuildEmbed(e, 0x123456).Color; got != 0x123456 {
+		t.Errorf("Color override = %#x, want 0x123456", got)
+	}
+}
+
+func TestShouldAnnounceSuppressesRepeatedKeys(t *testing.T) {
+	bot := &DiscordBot{}
+
+	tests := []struct {
+		event eventbus.Event
+		want  bool
+	}{
+		{eventbus.Event{Type: eventbus.FestaStandings, Key: "red"}, true},
+		{eventbus.Event{Type: eventbus.FestaStandings, Key: "red"}, false},
+		{eventbus.Event{Type: eventbus.FestaStandings, Key: "blue"}, true},
+		{eventbus.Event{Type: eventbus.SiegeStart, Key: "red"}, true},
+		{eventbus.Event{Type: eventbus.Maintenance}, true},
+		{eventbus.Event{Type: eventbus.Maintenance}, true},
+	}
+	for i, tt := range tests {
+		if got := bot.shouldAnnounce(tt.event); got != tt.want {
+			t.Errorf("case %d: shouldAnnounce(%s/%q) = %v, want %v", i, tt.event.Type, tt.event.Key, got, tt.want)
+		}
+	}
+}
@@ -2,15 +2,33 @@ package discordbot
 
 import (
 	cfg "erupe-ce/config"
+	"erupe-ce/server/eventbus"
+	"erupe-ce/server/status"
 	"regexp"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/bwmarrin/discordgo"
 	"go.uber.org/zap"
 )
 
 // Commands defines the slash commands registered with Discord, including
-// account linking and password management.
+// account registration and linking, password management, notification
+// preferences, guild recruitment and server status.
 var Commands = []*discordgo.ApplicationCommand{
+	{
+		Name:        "register",
+		Description: "Create an Erupe account linked to your Discord account",
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Type:        discordgo.ApplicationCommandOptionString,
+				Name:        "username",
+				Description: "3-16 letters, digits or underscores",
+				Required:    true,
+			},
+		},
+	},
 	{
 		Name:        "link",
 		Description: "Link your Erupe account to Discord",
@@ -35,6 +53,34 @@ var Commands = []*discordgo.ApplicationCommand{
 			},
 		},
 	},
+	{
+		Name:        "notifications",
+		Description: "Receive DMs about mail, guild applications and friends while offline",
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Type:        discordgo.ApplicationCommandOptionBoolean,
+				Name:        "enabled",
+				Description: "Whether to receive notification DMs",
+				Required:    true,
+			},
+		},
+	},
+	{
+		Name:        "recruiting",
+		Description: "List or hide the guild you lead on the recruitment board",
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Type:        discordgo.ApplicationCommandOptionBoolean,
+				Name:        "enabled",
+				Description: "Whether your guild is recruiting",
+				Required:    true,
+			},
+		},
+	},
+	{
+		Name:        "status",
+		Description: "Show world populations and upcoming events",
+	},
 }
 
 // DiscordBot manages a Discord session and provides methods for relaying
@@ -45,6 +91,30 @@ type DiscordBot struct {
 	logger       *zap.Logger
 	MainGuild    *discordgo.Guild
 	RelayChannel *discordgo.Channel
+
+	announceMu    sync.Mutex
+	lastEventKeys map[eventbus.Type]string
+
+	accounts AccountRepo
+
+	notifyMu sync.Mutex
+	pending  map[string][]string
+
+	guilds         GuildRepo
+	boardMu        sync.Mutex
+	boardMessageID string
+
+	registerLimit registerLimiter
+	// guildMember looks up a member of a Discord server, through Session
+	// when nil.
+	guildMember func(guildID, userID string) (*discordgo.Member, error)
+
+	echoMu sync.Mutex
+	echoes map[string]time.Time
+
+	status status.Source
+
+	interactionsClaimed atomic.Bool
 }
 
 // Options holds the configuration and logger required to create a DiscordBot.
@@ -56,7 +126,12 @@ type Options struct {
 // NewDiscordBot creates a DiscordBot using the provided options, establishing
 // a Discord session and optionally resolving the relay channel.
 func NewDiscordBot(options Options) (discordBot *DiscordBot, err error) {
-	session, err := discordgo.New("Bot " + options.Config.Discord.BotToken)
+	token := "Bot " + options.Config.Discord.BotToken
+	if options.Config.Discord.WebhookOnly {
+		// Webhook requests are authenticated by the token in their URL.
+		token = ""
+	}
+	session, err := discordgo.New(token)
 
 	if err != nil {
 		options.Logger.Fatal("Discord failed", zap.Error(err))
@@ -65,7 +140,7 @@ func NewDiscordBot(options Options) (discordBot *DiscordBot, err error) {
 
 	var relayChannel *discordgo.Channel
 
-	if options.Config.Discord.RelayChannel.Enabled {
+	if options.Config.Discord.RelayChannel.Enabled && !options.Config.Discord.WebhookOnly {
 		relayChannel, err = session.Channel(options.Config.Discord.RelayChannel.RelayChannelID)
 	}
 
@@ -74,6 +149,11 @@ func NewDiscordBot(options Options) (discordBot *DiscordBot, err error) {
 		return nil, err
 	}
 
+	if options.Config.Discord.Moderation.Enabled {
+		// Timeouts are only reported through member updates.
+		session.Identify.Intents |= discordgo.IntentsGuildMembers
+	}
+
 	discordBot = &DiscordBot{
 		config:       options.Config,
 		logger:       options.Logger,
@@ -84,6 +164,30 @@ func NewDiscordBot(options Options) (discordBot *DiscordBot, err error) {
 	return
 }
 
+// UseAccounts gives the bot access to game accounts, enabling the features
+// that act on linked accounts such as role sync and DM notifications.
+func (bot *DiscordBot) UseAccounts(accounts AccountRepo) {
+	bot.accounts = accounts
+}
+
+// UseGuilds gives the bot access to guild data for the recruitment board.
+func (bot *DiscordBot) UseGuilds(guilds GuildRepo) {
+	bot.guilds = guilds
+}
+
+// UseStatus gives the bot access to server status for its presence and the
+// /status command.
+func (bot *DiscordBot) UseStatus(src status.Source) {
+	bot.status = src
+}
+
+// ClaimInteractions reports whether the caller should register the slash
+// command and moderation event handlers. Only the first call returns true, so
+// events are handled once even though every channel server shares the bot.
+func (bot *DiscordBot) ClaimInteractions() bool {
+	return bot.interactionsClaimed.CompareAndSwap(false, true)
+}
+
 // Start opens the websocket connection to Discord.
 func (bot *DiscordBot) Start() (err error) {
 	err = bot.Session.Open()
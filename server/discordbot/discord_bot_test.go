@@ -413,3 +413,13 @@ func BenchmarkReplaceTextAll_NoMatches(b *testing.B) {
 		_ = ReplaceTextAll(text, userRegex, handler)
 	}
 }
+
+func TestClaimInteractionsOnce(t *testing.T) {
+	bot := &DiscordBot{}
+	if !bot.ClaimInteractions() {
+		t.Fatal("first claim should succeed")
+	}
+	if bot.ClaimInteractions() {
+		t.Error("second claim should fail")
+	}
+}
@@ -0,0 +1,156 @@
+package discordbot
+
+import (
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Moderation actions named by the DiscordModeration policy fields.
+const (
+	ModerationBan     = "ban"
+	ModerationMute    = "mute"
+	ModerationTimeout = "timeout"
+	ModerationUnban   = "unban"
+)
+
+// Discord rejects timeouts longer than 28 days.
+const maxTimeout = 28 * 24 * time.Hour
+
+// Actions the bot takes itself are reported back by Discord as events; they
+// are ignored for this long so they are not applied in-game a second time.
+const echoWindow = 30 * time.Second
+
+// TimeoutChange compares a member's timeout before and after an update. It
+// returns the new timeout end if one was applied or changed, and whether an
+// active timeout was lifted. before is nil when the previous state is unknown.
+func TimeoutChange(before, after *time.Time, now time.Time) (applied *time.Time, removed bool) {
+	active := func(t *time.Time) bool { return t != nil && t.After(now) }
+	switch {
+	case active(after) && (before == nil || !before.Equal(*after)):
+		return after, false
+	case !active(after) && active(before):
+		return nil, true
+	}
+	return nil, false
+}
+
+// GameBanAction returns the Discord action the policy maps an in-game ban to,
+// and the timeout end for ModerationTimeout. Timeouts are capped at 28 days.
+func (bot *DiscordBot) GameBanAction(expires *time.Time, now time.Time) (string, *time.Time) {
+	m := bot.config.Discord.Moderation
+	if expires == nil {
+		if m.GameBan == ModerationBan {
+			return ModerationBan, nil
+		}
+		return "", nil
+	}
+	switch m.GameTempBan {
+	case ModerationBan:
+		return ModerationBan, nil
+	case ModerationTimeout:
+		until := *expires
+		if until.Sub(now) > maxTimeout {
+			until = now.Add(maxTimeout)
+		}
+		return ModerationTimeout, &until
+	}
+	return "", nil
+}
+
+func (bot *DiscordBot) moderationEnabled() bool {
+	m := bot.config.Discord.Moderation
+	return m.Enabled && m.GuildID != "" && bot.accounts != nil
+}
+
+func (bot *DiscordBot) markEcho(discordID, action string) {
+	bot.echoMu.Lock()
+	defer bot.echoMu.Unlock()
+	if bot.echoes == nil {
+		bot.echoes = make(map[string]time.Time)
+	}
+	bot.echoes[discordID+"/"+action] = time.Now().Add(echoWindow)
+}
+
+// consumeEcho reports whether action on discordID was taken by the bot
+// itself within the echo window, forgetting it if so.
+func (bot *DiscordBot) consumeEcho(discordID, action string) bool {
+	bot.echoMu.Lock()
+	defer bot.echoMu.Unlock()
+	key := discordID + "/" + action
+	expiry, ok := bot.echoes[key]
+	delete(bot.echoes, key)
+	return ok && time.Now().Before(expiry)
+}
+
+// ModerationTarget resolves a Discord moderation event to the linked game
+// account it should be applied to. It returns false when moderation sync is
+// disabled, the event is from another server or was caused by the bot, or
+// the Discord user has no linked account.
+func (bot *DiscordBot) ModerationTarget(guildID, discordID, action string) (uint32, bool) {
+	if !bot.moderationEnabled() || guildID != bot.config.Discord.Moderation.GuildID {
+		return 0, false
+	}
+	if bot.consumeEcho(discordID, action) {
+		return 0, false
+	}
+	account, err := bot.accounts.GetByDiscordID(discordID)
+	if err != nil {
+		return 0, false
+	}
+	return account.UserID, true
+}
+
+// MirrorGameBan applies an in-game ban of userID to their linked Discord
+// account according to the moderation policy.
+func (bot *DiscordBot) MirrorGameBan(userID uint32, expires *time.Time) {
+	if !bot.moderationEnabled() {
+		return
+	}
+	action, until := bot.GameBanAction(expires, time.Now())
+	if action == "" {
+		return
+	}
+	account, err := bot.accounts.GetByUserID(userID)
+	if err != nil || account.DiscordID == "" {
+		return
+	}
+	guildID := bot.config.Discord.Moderation.GuildID
+	bot.markEcho(account.DiscordID, action)
+	switch action {
+	case ModerationBan:
+		err = bot.Session.GuildBanCreateWithReason(guildID, account.DiscordID, "Banned in-game", 0)
+	case ModerationTimeout:
+		err = bot.Session.GuildMemberTimeout(guildID, account.DiscordID, until)
+	}
+	if err != nil {
+		bot.logger.Warn(fmt.Sprintf("Discord: Failed to mirror %s", action), zap.String("discord_id", account.DiscordID), zap.Error(err))
+	}
+}
+
+// MirrorGameUnban lifts the Discord ban or timeout of the account linked to
+// userID after they are unbanned in-game.
+func (bot *DiscordBot) MirrorGameUnban(userID uint32) {
+	if !bot.moderationEnabled() {
+		return
+	}
+	account, err := bot.accounts.GetByUserID(userID)
+	if err != nil || account.DiscordID == "" {
+		return
+	}
+	guildID := bot.config.Discord.Moderation.GuildID
+	m := bot.config.Discord.Moderation
+	if m.GameBan == ModerationBan || m.GameTempBan == ModerationBan {
+		bot.markEcho(account.DiscordID, ModerationUnban)
+		if err := bot.Session.GuildBanDelete(guildID, account.DiscordID); err != nil {
+			bot.logger.Debug("Discord: No ban to lift", zap.String("discord_id", account.DiscordID), zap.Error(err))
+		}
+	}
+	if m.GameTempBan == ModerationTimeout {
+		bot.markEcho(account.DiscordID, ModerationTimeout)
+		if err := bot.Session.GuildMemberTimeout(guildID, account.DiscordID, nil); err != nil {
+			bot.logger.Debug("Discord: No timeout to lift", zap.String("discord_id", account.DiscordID), zap.Error(err))
+		}
+	}
+}
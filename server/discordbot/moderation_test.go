@@ -0,0 +1,106 @@
+package discordbot
+
+import (
+	cfg "erupe-ce/config"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func newModerationBot(policy cfg.DiscordModeration, repo AccountRepo) *DiscordBot {
+	config := &cfg.Config{}
+	config.Discord.Moderation = policy
+	bot := &DiscordBot{config: config, logger: zap.NewNop()}
+	if repo != nil {
+		bot.UseAccounts(repo)
+	}
+	return bot
+}
+
+func TestTimeoutChange(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	past := now.Add(-time.Hour)
+	future := now.Add(time.Hour)
+	later := now.Add(2 * time.Hour)
+
+	tests := []struct {
+		name        string
+		before      *time.Time
+		after       *time.Time
+		wantApplied *time.Time
+		wantRemoved bool
+	}{
+		{"applied", nil, &future, &future, false},
+		{"extended", &future, &later, &later, false},
+		{"unchanged", &future, &future, nil, false},
+		{"lifted", &future, nil, nil, true},
+		{"expired timestamp", &future, &past, nil, true},
+		{"unknown before", nil, nil, nil, false},
+		{"never timed out", &past, nil, nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			applied, removed := TimeoutChange(tt.before, tt.after, now)
+			if removed != tt.wantRemoved {
+				t.Errorf("removed = %v, want %v", removed, tt.wantRemoved)
+			}
+			if (applied == nil) != (tt.wantApplied == nil) || (applied != nil && !applied.Equal(*tt.wantApplied)) {
+				t.Errorf("applied = %v, want %v", applied, tt.wantApplied)
+			}
+		})
+	}
+}
+
+func TestGameBanAction(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	week := now.Add(7 * 24 * time.Hour)
+	year := now.Add(365 * 24 * time.Hour)
+
+	bot := newModerationBot(cfg.DiscordModeration{GameBan: "ban", GameTempBan: "timeout"}, nil)
+	if action, _ := bot.GameBanAction(nil, now); action != ModerationBan {
+		t.Errorf("permanent ban action = %q, want ban", action)
+	}
+	if action, until := bot.GameBanAction(&week, now); action != ModerationTimeout || !until.Equal(week) {
+		t.Errorf("temp ban = %q until %v, want timeout until %v", action, until, week)
+	}
+	if _, until := bot.GameBanAction(&year, now); !until.Equal(now.Add(maxTimeout)) {
+		t.Errorf("long temp ban timeout = %v, want capped at 28 days", until)
+	}
+
+	bot = newModerationBot(cfg.DiscordModeration{GameTempBan: "ban"}, nil)
+	if action, _ := bot.GameBanAction(nil, now); action != "" {
+		t.Errorf("permanent ban action = %q, want none", action)
+	}
+	if action, until := bot.GameBanAction(&week, now); action != ModerationBan || until != nil {
+		t.Errorf("temp ban = %q until %v, want ban", action, until)
+	}
+}
+
+func TestModerationTarget(t *testing.T) {
+	repo := &mockAccountRepo{accounts: []LinkedAccount{{UserID: 5, DiscordID: "100"}}}
+	bot := newModerationBot(cfg.DiscordModeration{Enabled: true, GuildID: "g1"}, repo)
+
+	if userID, ok := bot.ModerationTarget("g1", "100", ModerationBan); !ok || userID != 5 {
+		t.Errorf("ModerationTarget() = %d, %v, want 5, true", userID, ok)
+	}
+	if _, ok := bot.ModerationTarget("g2", "100", ModerationBan); ok {
+		t.Error("event from another Discord server was accepted")
+	}
+	if _, ok := bot.ModerationTarget("g1", "200", ModerationBan); ok {
+		t.Error("unlinked Discord user was accepted")
+	}
+
+	bot.markEcho("100", ModerationBan)
+	if _, ok := bot.ModerationTarget("g1", "100", ModerationBan); ok {
+		t.Error("ban made by the bot was applied in-game")
+	}
+	if _, ok := bot.ModerationTarget("g1", "100", ModerationBan); !ok {
+		t.Error("echo suppression should only apply once")
+	}
+
+	disabled := newModerationBot(cfg.DiscordModeration{GuildID: "g1"}, repo)
+	if _, ok := disabled.ModerationTarget("g1", "100", ModerationBan); ok {
+		t.Error("ModerationTarget() accepted events while disabled")
+	}
+}
@@ -0,0 +1,114 @@
+package discordbot
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+var errNoAccounts = errors.New("account access is not configured")
+
+// InQuietHours reports whether t falls within the quiet hours [start, end).
+// The range may wrap past midnight; start == end disables quiet hours.
+func InQuietHours(start, end int, t time.Time) bool {
+	if start == end {
+		return false
+	}
+	h := t.Hour()
+	if start < end {
+		return h >= start && h < end
+	}
+	return h >= start || h < end
+}
+
+// FormatDigest joins batched notifications into a single DM.
+func FormatDigest(lines []string) string {
+	if len(lines) == 1 {
+		return lines[0]
+	}
+	var sb strings.Builder
+	sb.WriteString("While you were away:")
+	for _, line := range lines {
+		sb.WriteString("\n- ")
+		sb.WriteString(line)
+	}
+	return sb.String()
+}
+
+// SetNotifications enables or disables DM notifications for the account
+// linked to discordID.
+func (bot *DiscordBot) SetNotifications(discordID string, enabled bool) error {
+	if bot.accounts == nil {
+		return errNoAccounts
+	}
+	return bot.accounts.SetNotifyDM(discordID, enabled)
+}
+
+// QueueNotification queues text for the owner of charID if their account is
+// linked and opted in. Queued notifications are sent by the job started with
+// StartNotifications.
+func (bot *DiscordBot) QueueNotification(charID uint32, text string) {
+	if bot.accounts == nil || !bot.config.Discord.Notifications.Enabled {
+		return
+	}
+	account, err := bot.accounts.GetByCharID(charID)
+	if err != nil {
+		bot.logger.Warn("Discord: Failed to load account for notification", zap.Uint32("char_id", charID), zap.Error(err))
+		return
+	}
+	if account.DiscordID == "" || !account.NotifyDM {
+		return
+	}
+	bot.notifyMu.Lock()
+	defer bot.notifyMu.Unlock()
+	if bot.pending == nil {
+		bot.pending = make(map[string][]string)
+	}
+	bot.pending[account.DiscordID] = append(bot.pending[account.DiscordID], text)
+}
+
+// StartNotifications starts the job that sends queued notifications every
+// BatchInterval, holding them during quiet hours. It returns a func that
+// stops the job.
+func (bot *DiscordBot) StartNotifications() func() {
+	n := bot.config.Discord.Notifications
+	if !n.Enabled {
+		return func() {}
+	}
+	interval := time.Duration(n.BatchInterval) * time.Minute
+	if interval <= 0 {
+		interval = 10 * time.Minute
+	}
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case now := <-ticker.C:
+				if !InQuietHours(n.QuietHoursStart, n.QuietHoursEnd, now) {
+					bot.FlushNotifications()
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() { close(done) }
+}
+
+// FlushNotifications sends every queued notification, one DM per user.
+func (bot *DiscordBot) FlushNotifications() {
+	bot.notifyMu.Lock()
+	pending := bot.pending
+	bot.pending = nil
+	bot.notifyMu.Unlock()
+
+	for discordID, lines := range pending {
+		if err := bot.SendDM(discordID, FormatDigest(lines)); err != nil {
+			bot.logger.Warn("Discord: Failed to send DM", zap.String("discord_id", discordID), zap.Error(err))
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package discordbot
+
+import (
+	cfg "erupe-ce/config"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func TestInQuietHours(t *testing.T) {
+	at := func(h int) time.Time { return time.Date(2024, 1, 1, h, 30, 0, 0, time.UTC) }
+	tests := []struct {
+		start, end, hour int
+		want             bool
+	}{
+		{0, 0, 3, false},
+		{1, 6, 3, true},
+		{1, 6, 6, false},
+		{22, 7, 23, true},
+		{22, 7, 2, true},
+		{22, 7, 12, false},
+	}
+	for _, tt := range tests {
+		if got := InQuietHours(tt.start, tt.end, at(tt.hour)); got != tt.want {
+			t.Errorf("InQuietHours(%d, %d, %02d:30) = %v, want %v", tt.start, tt.end, tt.hour, got, tt.want)
+		}
+	}
+}
+
+func TestFormatDigest(t *testing.T) {
+	if got := FormatDigest([]string{"one"}); got != "one" {
+		t.Errorf("single line digest = %q", got)
+	}
+	want := "While you were away:\n- one\n- two"
+	if got := FormatDigest([]string{"one", "two"}); got != want {
+		t.Errorf("digest = %q, want %q", got, want)
+	}
+}
+
+func TestQueueNotificationOnlyQueuesOptedInAccounts(t *testing.T) {
+	repo := &mockAccountRepo{
+		accounts: []LinkedAccount{
+			{UserID: 1, DiscordID: "opted-in", NotifyDM: true},
+			{UserID: 2, DiscordID: "opted-out"},
+			{UserID: 3},
+		},
+		charIDs: map[uint32]uint32{10: 1, 20: 2, 30: 3},
+	}
+	config := &cfg.Config{}
+	config.Discord.Notifications.Enabled = true
+	bot := &DiscordBot{config: config, logger: zap.NewNop()}
+	bot.UseAccounts(repo)
+
+	bot.QueueNotification(10, "mail")
+	bot.QueueNotification(10, "friend")
+	bot.QueueNotification(20, "mail")
+	bot.QueueNotification(30, "mail")
+
+	if len(bot.pending) != 1 {
+		t.Fatalf("pending users = %d, want 1", len(bot.pending))
+	}
+	if got := bot.pending["opted-in"]; len(got) != 2 {
+		t.Errorf("pending for opted-in = %v, want 2 entries", got)
+	}
+}
+
+func TestSetNotifications(t *testing.T) {
+	bot := &DiscordBot{config: &cfg.Config{}, logger: zap.NewNop()}
+	if err := bot.SetNotifications("id", true); err == nil {
+		t.Error("expected error without an account repo")
+	}
+	repo := &mockAccountRepo{}
+	bot.UseAccounts(repo)
+	if err := bot.SetNotifications("id", true); err != nil || !repo.notifyDM["id"] {
+		t.Errorf("SetNotifications err = %v, stored = %v", err, repo.notifyDM)
+	}
+}
@@ -0,0 +1,151 @@
+package discordbot
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"go.uber.org/zap"
+)
+
+// Discord rejects embeds with more than 25 fields.
+const maxEmbedFields = 25
+
+const recruitmentTitle = "Guild recruitment"
+
+var errNoGuilds = errors.New("guild access is not configured")
+
+// BuildRecruitmentEmbed renders the recruitment board for guilds.
+func BuildRecruitmentEmbed(guilds []RecruitingGuild, now time.Time) *discordgo.MessageEmbed {
+	embed := &discordgo.MessageEmbed{
+		Title:     recruitmentTitle,
+		Color:     0x2ECC71,
+		Timestamp: now.Format(time.RFC3339),
+		Footer:    &discordgo.MessageEmbedFooter{Text: "Guild leaders can use /recruiting to list or hide their guild"},
+	}
+	if len(guilds) == 0 {
+		embed.Description = "No guilds are recruiting right now."
+		return embed
+	}
+	shown := guilds
+	if len(shown) > maxEmbedFields {
+		shown = shown[:maxEmbedFields]
+		embed.Description = fmt.Sprintf("Showing %d of %d recruiting guilds.", maxEmbedFields, len(guilds))
+	}
+	for _, g := range shown {
+		leader := g.LeaderName
+		if g.LeaderDiscordID != "" {
+			leader = fmt.Sprintf("%s (<@%s>)", g.LeaderName, g.LeaderDiscordID)
+		}
+		value := fmt.Sprintf("Members: %d\nLeader: %s", g.Members, leader)
+		if g.Comment != "" {
+			value += "\n" + g.Comment
+		}
+		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
+			Name:  g.Name,
+			Value: value,
+		})
+	}
+	return embed
+}
+
+// SetGuildRecruiting toggles recruiting for the guild led by the account
+// linked to discordID and refreshes the board. It returns the guild name.
+func (bot *DiscordBot) SetGuildRecruiting(discordID string, recruiting bool) (string, error) {
+	if bot.guilds == nil {
+		return "", errNoGuilds
+	}
+	name, err := bot.guilds.SetRecruitingByLeader(discordID, recruiting)
+	if err != nil {
+		return "", err
+	}
+	go func() {
+		if err := bot.RefreshRecruitmentBoard(); err != nil {
+			bot.logger.Warn("Discord: Failed to refresh recruitment board", zap.Error(err))
+		}
+	}()
+	return name, nil
+}
+
+// StartRecruitmentBoard starts the job that keeps the recruitment post up to
+// date. It returns a func that stops the job.
+func (bot *DiscordBot) StartRecruitmentBoard() func() {
+	r := bot.config.Discord.Recruitment
+	if !r.Enabled || r.ChannelID == "" || bot.guilds == nil {
+		return func() {}
+	}
+	interval := time.Duration(r.Interval) * time.Minute
+	if interval <= 0 {
+		interval = 15 * time.Minute
+	}
+	done := make(chan struct{})
+	go func() {
+		refresh := func() {
+			if err := bot.RefreshRecruitmentBoard(); err != nil {
+				bot.logger.Warn("Discord: Failed to refresh recruitment board", zap.Error(err))
+			}
+		}
+		refresh()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				refresh()
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() { close(done) }
+}
+
+// RefreshRecruitmentBoard edits the pinned recruitment post, creating and
+// pinning it first if it does not exist yet.
+func (bot *DiscordBot) RefreshRecruitmentBoard() error {
+	r := bot.config.Discord.Recruitment
+	if !r.Enabled || r.ChannelID == "" || bot.guilds == nil {
+		return nil
+	}
+	guilds, err := bot.guilds.ListRecruiting()
+	if err != nil {
+		return err
+	}
+	embed := BuildRecruitmentEmbed(guilds, time.Now())
+
+	bot.boardMu.Lock()
+	defer bot.boardMu.Unlock()
+	if bot.boardMessageID == "" {
+		bot.boardMessageID = bot.findPinnedBoard(r.ChannelID)
+	}
+	if bot.boardMessageID != "" {
+		if _, err := bot.Session.ChannelMessageEditEmbed(r.ChannelID, bot.boardMessageID, embed); err == nil {
+			return nil
+		}
+		// The post was deleted or is no longer editable, replace it.
+		bot.boardMessageID = ""
+	}
+	msg, err := bot.Session.ChannelMessageSendEmbed(r.ChannelID, embed)
+	if err != nil {
+		return err
+	}
+	bot.boardMessageID = msg.ID
+	return bot.Session.ChannelMessagePin(r.ChannelID, msg.ID)
+}
+
+// findPinnedBoard returns the ID of a recruitment post previously pinned by
+// the bot in channelID, so restarts reuse the existing post.
+func (bot *DiscordBot) findPinnedBoard(channelID string) string {
+	pinned, err := bot.Session.ChannelMessagesPinned(channelID)
+	if err != nil || bot.Session.State == nil || bot.Session.State.User == nil {
+		return ""
+	}
+	for _, m := range pinned {
+		if m.Author != nil && m.Author.ID == bot.Session.State.User.ID &&
+			len(m.Embeds) > 0 && m.Embeds[0].Title == recruitmentTitle {
+			return m.ID
+		}
+	}
+	return ""
+}
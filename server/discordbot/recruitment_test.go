@@ -0,0 +1,73 @@
+package discordbot
+
+import (
+	"errors"
+	cfg "erupe-ce/config"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func TestBuildRecruitmentEmbed(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	guilds := []RecruitingGuild{
+		{ID: 1, Name: "Hunters", Comment: "All welcome", Members: 12, LeaderName: "Alice", LeaderDiscordID: "42"},
+		{ID: 2, Name: "Quiet", Members: 3, LeaderName: "Bob"},
+	}
+	embed := BuildRecruitmentEmbed(guilds, now)
+
+	if embed.Title != recruitmentTitle || len(embed.Fields) != 2 {
+		t.Fatalf("embed = %q with %d fields", embed.Title, len(embed.Fields))
+	}
+	first := embed.Fields[0]
+	if first.Name != "Hunters" || !strings.Contains(first.Value, "Members: 12") ||
+		!strings.Contains(first.Value, "Alice (<@42>)") || !strings.Contains(first.Value, "All welcome") {
+		t.Errorf("first field = %+v", first)
+	}
+	if strings.Contains(embed.Fields[1].Value, "<@") {
+		t.Errorf("unlinked leader rendered a mention: %q", embed.Fields[1].Value)
+	}
+}
+
+func TestBuildRecruitmentEmbedLimits(t *testing.T) {
+	if embed := BuildRecruitmentEmbed(nil, time.Now()); embed.Description == "" || len(embed.Fields) != 0 {
+		t.Errorf("empty board = %+v", embed)
+	}
+
+	var guilds []RecruitingGuild
+	for i := 0; i < maxEmbedFields+5; i++ {
+		guilds = append(guilds, RecruitingGuild{ID: uint32(i), Name: fmt.Sprint(i)})
+	}
+	embed := BuildRecruitmentEmbed(guilds, time.Now())
+	if len(embed.Fields) != maxEmbedFields {
+		t.Errorf("fields = %d, want %d", len(embed.Fields), maxEmbedFields)
+	}
+	if !strings.Contains(embed.Description, "30") {
+		t.Errorf("description = %q, want total count", embed.Description)
+	}
+}
+
+func TestSetGuildRecruiting(t *testing.T) {
+	bot := &DiscordBot{config: &cfg.Config{}, logger: zap.NewNop()}
+	if _, err := bot.SetGuildRecruiting("42", true); err == nil {
+		t.Error("expected error without a guild repo")
+	}
+
+	repo := &mockGuildRepo{name: "Hunters"}
+	bot.UseGuilds(repo)
+	name, err := bot.SetGuildRecruiting("42", false)
+	if err != nil || name != "Hunters" {
+		t.Fatalf("SetGuildRecruiting = (%q, %v)", name, err)
+	}
+	if repo.lastDiscordID != "42" || repo.lastRecruiting {
+		t.Errorf("repo got (%q, %v)", repo.lastDiscordID, repo.lastRecruiting)
+	}
+
+	repo.err = errors.New("not a leader")
+	if _, err := bot.SetGuildRecruiting("42", true); err == nil {
+		t.Error("expected repo error to be returned")
+	}
+}
@@ -0,0 +1,161 @@
+package discordbot
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"errors"
+	"math/big"
+	"regexp"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/lib/pq"
+	"go.uber.org/zap"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Registration errors, returned by Register so callers can explain the
+// refusal to the user.
+var (
+	ErrRegistrationClosed = errors.New("registration is disabled")
+	ErrNotEligible        = errors.New("not a member of the required Discord server or role")
+	ErrRateLimited        = errors.New("too many registration attempts")
+	ErrAlreadyRegistered  = errors.New("discord user already has a linked account")
+	ErrInvalidUsername    = errors.New("invalid username")
+	ErrUsernameTaken      = errors.New("username already exists")
+)
+
+var usernamePattern = regexp.MustCompile(`^[A-Za-z0-9_]{3,16}$`)
+
+const passwordAlphabet = "abcdefghijkmnpqrstuvwxyzABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+
+// registerLimiter enforces a per-user cooldown between attempts and a global
+// cap on accounts created per hour.
+type registerLimiter struct {
+	mu      sync.Mutex
+	last    map[string]time.Time
+	created []time.Time
+}
+
+// allow records an attempt by discordID at now and reports whether it may
+// proceed under the given cooldown and hourly cap.
+func (l *registerLimiter) allow(discordID string, now time.Time, cooldown time.Duration, maxPerHour int) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.last == nil {
+		l.last = make(map[string]time.Time)
+	}
+	if last, ok := l.last[discordID]; ok && now.Sub(last) < cooldown {
+		return false
+	}
+	recent := l.created[:0]
+	for _, t := range l.created {
+		if now.Sub(t) < time.Hour {
+			recent = append(recent, t)
+		}
+	}
+	l.created = recent
+	if maxPerHour > 0 && len(l.created) >= maxPerHour {
+		return false
+	}
+	l.last[discordID] = now
+	return true
+}
+
+// succeeded counts a created account against the hourly cap.
+func (l *registerLimiter) succeeded(now time.Time) {
+	l.mu.Lock()
+	l.created = append(l.created, now)
+	l.mu.Unlock()
+}
+
+func generatePassword(n int) (string, error) {
+	b := make([]byte, n)
+	max := big.NewInt(int64(len(passwordAlphabet)))
+	for i := range b {
+		v, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		b[i] = passwordAlphabet[v.Int64()]
+	}
+	return string(b), nil
+}
+
+// Register creates a game account named username linked to discordID and
+// returns its generated password.
+func (bot *DiscordBot) Register(discordID, username string) (string, error) {
+	reg := bot.config.Discord.Registration
+	if !reg.Enabled || bot.accounts == nil {
+		return "", ErrRegistrationClosed
+	}
+	if !usernamePattern.MatchString(username) {
+		return "", ErrInvalidUsername
+	}
+	if !bot.registerLimit.allow(discordID, time.Now(), time.Duration(reg.Cooldown)*time.Minute, reg.MaxPerHour) {
+		return "", ErrRateLimited
+	}
+	if err := bot.checkEligible(discordID); err != nil {
+		return "", err
+	}
+	if _, err := bot.accounts.GetByDiscordID(discordID); err == nil {
+		return "", ErrAlreadyRegistered
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		return "", err
+	}
+
+	password, err := generatePassword(12)
+	if err != nil {
+		return "", err
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	if _, err := bot.accounts.CreateLinked(username, hash, discordID); err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Constraint == "users_username_key" {
+			return "", ErrUsernameTaken
+		}
+		return "", err
+	}
+	bot.registerLimit.succeeded(time.Now())
+	bot.logger.Info("Discord: Registered account", zap.String("username", username), zap.String("discord_id", discordID))
+	return password, nil
+}
+
+// checkEligible verifies discordID is a member of the configured Discord
+// server and holds the required role, if any. Without a server nobody is
+// eligible, as anyone able to message the bot could register otherwise.
+func (bot *DiscordBot) checkEligible(discordID string) error {
+	reg := bot.config.Discord.Registration
+	if reg.GuildID == "" {
+		return ErrNotEligible
+	}
+	var member *discordgo.Member
+	var err error
+	if bot.guildMember != nil {
+		member, err = bot.guildMember(reg.GuildID, discordID)
+	} else {
+		member, err = bot.Session.GuildMember(reg.GuildID, discordID)
+	}
+	if err != nil {
+		return ErrNotEligible
+	}
+	if reg.RequiredRoleID != "" && !slices.Contains(member.Roles, reg.RequiredRoleID) {
+		return ErrNotEligible
+	}
+	return nil
+}
+
+// SendDM sends a direct message to the given Discord user.
+func (bot *DiscordBot) SendDM(discordID, message string) error {
+	channel, err := bot.Session.UserChannelCreate(discordID)
+	if err != nil {
+		return err
+	}
+	_, err = bot.Session.ChannelMessageSend(channel.ID, message)
+	return err
+}
@@ -0,0 +1,121 @@
+package discordbot
+
+import (
+	"errors"
+	cfg "erupe-ce/config"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"go.uber.org/zap"
+)
+
+// newRegisterBot returns a bot registering members of the Discord server
+// "guild", where user 100 holds role "hunter" and user 200 holds none.
+func newRegisterBot(repo AccountRepo) *DiscordBot {
+	config := &cfg.Config{}
+	config.Discord.Registration = cfg.DiscordRegistration{Enabled: true, GuildID: "guild", Cooldown: 10, MaxPerHour: 2}
+	bot := &DiscordBot{config: config, logger: zap.NewNop()}
+	bot.guildMember = func(guildID, userID string) (*discordgo.Member, error) {
+		roles := map[string][]string{"100": {"hunter"}, "200": {}}
+		if r, ok := roles[userID]; ok && guildID == "guild" {
+			return &discordgo.Member{Roles: r}, nil
+		}
+		return nil, errors.New("unknown member")
+	}
+	if repo != nil {
+		bot.UseAccounts(repo)
+	}
+	return bot
+}
+
+func TestRegisterCreatesLinkedAccount(t *testing.T) {
+	repo := &mockAccountRepo{}
+	bot := newRegisterBot(repo)
+
+	password, err := bot.Register("100", "hunter_1")
+	if err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+	if len(password) != 12 || strings.Trim(password, passwordAlphabet) != "" {
+		t.Errorf("password = %q", password)
+	}
+	if len(repo.created) != 1 || repo.created[0] != "hunter_1" {
+		t.Errorf("created = %v", repo.created)
+	}
+}
+
+func TestRegisterRejections(t *testing.T) {
+	t.Run("disabled", func(t *testing.T) {
+		bot := newRegisterBot(nil)
+		if _, err := bot.Register("100", "hunter"); !errors.Is(err, ErrRegistrationClosed) {
+			t.Errorf("err = %v, want ErrRegistrationClosed", err)
+		}
+	})
+	t.Run("invalid username", func(t *testing.T) {
+		bot := newRegisterBot(&mockAccountRepo{})
+		for _, name := range []string{"ab", "has space", "waytoolongusername123", "ünïcode"} {
+			if _, err := bot.Register("100", name); !errors.Is(err, ErrInvalidUsername) {
+				t.Errorf("Register(%q) err = %v, want ErrInvalidUsername", name, err)
+			}
+		}
+	})
+	t.Run("not a member", func(t *testing.T) {
+		bot := newRegisterBot(&mockAccountRepo{})
+		if _, err := bot.Register("300", "hunter"); !errors.Is(err, ErrNotEligible) {
+			t.Errorf("err = %v, want ErrNotEligible", err)
+		}
+	})
+	t.Run("missing role", func(t *testing.T) {
+		bot := newRegisterBot(&mockAccountRepo{})
+		bot.config.Discord.Registration.RequiredRoleID = "hunter"
+		if _, err := bot.Register("200", "hunter"); !errors.Is(err, ErrNotEligible) {
+			t.Errorf("err = %v, want ErrNotEligible", err)
+		}
+	})
+	t.Run("no server configured", func(t *testing.T) {
+		bot := newRegisterBot(&mockAccountRepo{})
+		bot.config.Discord.Registration.GuildID = ""
+		if _, err := bot.Register("100", "hunter"); !errors.Is(err, ErrNotEligible) {
+			t.Errorf("err = %v, want ErrNotEligible", err)
+		}
+	})
+	t.Run("already linked", func(t *testing.T) {
+		bot := newRegisterBot(&mockAccountRepo{accounts: []LinkedAccount{{UserID: 1, DiscordID: "100"}}})
+		if _, err := bot.Register("100", "hunter"); !errors.Is(err, ErrAlreadyRegistered) {
+			t.Errorf("err = %v, want ErrAlreadyRegistered", err)
+		}
+	})
+	t.Run("cooldown", func(t *testing.T) {
+		bot := newRegisterBot(&mockAccountRepo{accounts: []LinkedAccount{{UserID: 1, DiscordID: "100"}}})
+		_, _ = bot.Register("100", "hunter")
+		if _, err := bot.Register("100", "hunter"); !errors.Is(err, ErrRateLimited) {
+			t.Errorf("err = %v, want ErrRateLimited", err)
+		}
+	})
+}
+
+func TestRegisterLimiter(t *testing.T) {
+	var l registerLimiter
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	if !l.allow("a", now, time.Minute, 2) {
+		t.Fatal("first attempt should be allowed")
+	}
+	if l.allow("a", now.Add(30*time.Second), time.Minute, 2) {
+		t.Error("attempt within cooldown should be refused")
+	}
+	if !l.allow("a", now.Add(2*time.Minute), time.Minute, 2) {
+		t.Error("attempt after cooldown should be allowed")
+	}
+
+	l.succeeded(now)
+	l.succeeded(now)
+	if l.allow("b", now.Add(time.Minute), time.Minute, 2) {
+		t.Error("attempt over the hourly cap should be refused")
+	}
+	if !l.allow("b", now.Add(61*time.Minute), time.Minute, 2) {
+		t.Error("attempt after the hour window should be allowed")
+	}
+}
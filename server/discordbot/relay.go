@@ -0,0 +1,103 @@
+package discordbot
+
+import (
+	cfg "erupe-ce/config"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// In-game chat scopes that can be relayed to Discord.
+const (
+	ScopeWorld = "world"
+	ScopeGuild = "guild"
+	ScopeSiege = "siege"
+)
+
+// Default message formats when a mapping has no NameFormat.
+const (
+	defaultToDiscordFormat   = "**{name}**: {message}"
+	defaultFromDiscordFormat = "[D] {name} > {message}"
+)
+
+// RelayMappings returns the effective relay mappings. When no Mappings are
+// configured, the legacy RelayChannelID is treated as a bidirectional world
+// mapping so existing configs keep working.
+func RelayMappings(relay cfg.DiscordRelay) []cfg.DiscordRelayMapping {
+	if !relay.Enabled {
+		return nil
+	}
+	if len(relay.Mappings) > 0 {
+		return relay.Mappings
+	}
+	if relay.RelayChannelID == "" {
+		return nil
+	}
+	return []cfg.DiscordRelayMapping{{
+		Scope:       ScopeWorld,
+		ChannelID:   relay.RelayChannelID,
+		ToDiscord:   true,
+		FromDiscord: true,
+	}}
+}
+
+// FormatRelayMessage substitutes {name} and {message} in format, falling back
+// to fallback when format is empty.
+func FormatRelayMessage(format, fallback, name, message string) string {
+	if format == "" {
+		format = fallback
+	}
+	return strings.NewReplacer("{name}", name, "{message}", message).Replace(format)
+}
+
+// IsFiltered reports whether message contains any of the mapping's filter
+// words, compared case-insensitively.
+func IsFiltered(mapping cfg.DiscordRelayMapping, message string) bool {
+	lower := strings.ToLower(message)
+	for _, f := range mapping.Filters {
+		if f != "" && strings.Contains(lower, strings.ToLower(f)) {
+			return true
+		}
+	}
+	return false
+}
+
+// RelayToDiscord sends an in-game chat message to every Discord channel
+// mapped to scope. guildID is matched against guild-scoped mappings.
+func (bot *DiscordBot) RelayToDiscord(scope string, guildID uint32, name, message string) error {
+	var firstErr error
+	for _, m := range RelayMappings(bot.config.Discord.RelayChannel) {
+		if !m.ToDiscord || m.Scope != scope || (m.ChannelID == "" && m.Webhook == "") {
+			continue
+		}
+		if scope == ScopeGuild && m.GuildID != 0 && m.GuildID != guildID {
+			continue
+		}
+		if IsFiltered(m, message) {
+			continue
+		}
+		text := FormatRelayMessage(m.NameFormat, defaultToDiscordFormat, name, message)
+		if err := bot.post(m.ChannelID, m.Webhook, &discordgo.MessageSend{Content: text}); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// InboundMappings returns the mappings of relay that accept messages from
+// the given Discord channel.
+func InboundMappings(relay cfg.DiscordRelay, channelID string) []cfg.DiscordRelayMapping {
+	var out []cfg.DiscordRelayMapping
+	for _, m := range RelayMappings(relay) {
+		if m.FromDiscord && m.ChannelID == channelID {
+			out = append(out, m)
+		}
+	}
+	return out
+}
+
+// FormatInbound renders a Discord message for in-game display using the
+// mapping's GameFormat.
+func FormatInbound(mapping cfg.DiscordRelayMapping, name, message string) string {
+	return FormatRelayMessage(mapping.GameFormat, defaultFromDiscordFormat, name, message)
+}
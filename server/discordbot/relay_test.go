@@ -0,0 +1,74 @@
+package discordbot
+
+import (
+	cfg "erupe-ce/config"
+	"testing"
+)
+
+func TestRelayMappingsLegacyFallback(t *testing.T) {
+	relay := cfg.DiscordRelay{Enabled: true, RelayChannelID: "123"}
+	got := RelayMappings(relay)
+	if len(got) != 1 {
+		t.Fatalf("got %d mappings, want 1", len(got))
+	}
+	m := got[0]
+	if m.Scope != ScopeWorld || m.ChannelID != "123" || !m.ToDiscord || !m.FromDiscord {
+		t.Errorf("legacy mapping = %+v", m)
+	}
+
+	relay.Enabled = false
+	if got := RelayMappings(relay); got != nil {
+		t.Errorf("disabled relay returned %+v", got)
+	}
+}
+
+func TestRelayMappingsPrefersExplicitMappings(t *testing.T) {
+	relay := cfg.DiscordRelay{
+		Enabled:        true,
+		RelayChannelID: "legacy",
+		Mappings: []cfg.DiscordRelayMapping{
+			{Scope: ScopeGuild, ChannelID: "guild", GuildID: 5, ToDiscord: true},
+		},
+	}
+	got := RelayMappings(relay)
+	if len(got) != 1 || got[0].ChannelID != "guild" {
+		t.Errorf("RelayMappings = %+v", got)
+	}
+}
+
+func TestFormatRelayMessage(t *testing.T) {
+	if got := FormatRelayMessage("", defaultToDiscordFormat, "Hunter", "hi"); got != "**Hunter**: hi" {
+		t.Errorf("default format = %q", got)
+	}
+	if got := FormatRelayMessage("<{name}> {message}", defaultToDiscordFormat, "Hunter", "hi"); got != "<Hunter> hi" {
+		t.Errorf("custom format = %q", got)
+	}
+}
+
+func TestIsFiltered(t *testing.T) {
+	m := cfg.DiscordRelayMapping{Filters: []string{"spoiler", ""}}
+	if !IsFiltered(m, "No SPOILERS please") {
+		t.Error("expected case-insensitive filter match")
+	}
+	if IsFiltered(m, "hello") {
+		t.Error("unexpected filter match")
+	}
+}
+
+func TestInboundMappings(t *testing.T) {
+	relay := cfg.DiscordRelay{
+		Enabled: true,
+		Mappings: []cfg.DiscordRelayMapping{
+			{Scope: ScopeWorld, ChannelID: "a", FromDiscord: true},
+			{Scope: ScopeSiege, ChannelID: "a", ToDiscord: true},
+			{Scope: ScopeGuild, ChannelID: "b", FromDiscord: true},
+		},
+	}
+	got := InboundMappings(relay, "a")
+	if len(got) != 1 || got[0].Scope != ScopeWorld {
+		t.Errorf("InboundMappings(a) = %+v", got)
+	}
+	if got := InboundMappings(relay, "c"); len(got) != 0 {
+		t.Errorf("InboundMappings(c) = %+v", got)
+	}
+}
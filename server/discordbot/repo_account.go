@@ -0,0 +1,85 @@
+package discordbot
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// AccountRepository implements AccountRepo with PostgreSQL.
+type AccountRepository struct {
+	db *sqlx.DB
+}
+
+// NewAccountRepository creates a new AccountRepository.
+func NewAccountRepository(db *sqlx.DB) *AccountRepository {
+	return &AccountRepository{db: db}
+}
+
+func (r *AccountRepository) ListLinked() ([]LinkedAccount, error) {
+	rows, err := r.db.Query(`SELECT id, discord_id, rights, COALESCE(op, false), discord_dm FROM users WHERE discord_id IS NOT NULL AND discord_id != ''`)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+	var accounts []LinkedAccount
+	for rows.Next() {
+		var a LinkedAccount
+		if err := rows.Scan(&a.UserID, &a.DiscordID, &a.Rights, &a.Op, &a.NotifyDM); err != nil {
+			return nil, err
+		}
+		accounts = append(accounts, a)
+	}
+	return accounts, rows.Err()
+}
+
+func (r *AccountRepository) GetByDiscordID(discordID string) (LinkedAccount, error) {
+	var a LinkedAccount
+	err := r.db.QueryRow(
+		`SELECT id, discord_id, rights, COALESCE(op, false), discord_dm FROM users WHERE discord_id = $1`, discordID,
+	).Scan(&a.UserID, &a.DiscordID, &a.Rights, &a.Op, &a.NotifyDM)
+	return a, err
+}
+
+func (r *AccountRepository) GetByUserID(userID uint32) (LinkedAccount, error) {
+	var a LinkedAccount
+	err := r.db.QueryRow(
+		`SELECT id, COALESCE(discord_id, ''), rights, COALESCE(op, false), discord_dm FROM users WHERE id = $1`, userID,
+	).Scan(&a.UserID, &a.DiscordID, &a.Rights, &a.Op, &a.NotifyDM)
+	return a, err
+}
+
+func (r *AccountRepository) GetByCharID(charID uint32) (LinkedAccount, error) {
+	var a LinkedAccount
+	err := r.db.QueryRow(
+		`SELECT u.id, COALESCE(u.discord_id, ''), u.rights, COALESCE(u.op, false), u.discord_dm
+		FROM users u JOIN characters c ON c.user_id = u.id WHERE c.id = $1`, charID,
+	).Scan(&a.UserID, &a.DiscordID, &a.Rights, &a.Op, &a.NotifyDM)
+	return a, err
+}
+
+func (r *AccountRepository) SetRights(userID uint32, rights uint32) error {
+	_, err := r.db.Exec(`UPDATE users SET rights = $1 WHERE id = $2`, rights, userID)
+	return err
+}
+
+func (r *AccountRepository) SetNotifyDM(discordID string, enabled bool) error {
+	res, err := r.db.Exec(`UPDATE users SET discord_dm = $1 WHERE discord_id = $2`, enabled, discordID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func (r *AccountRepository) CreateLinked(username string, passwordHash []byte, discordID string) (uint32, error) {
+	var id uint32
+	err := r.db.QueryRow(
+		`INSERT INTO users (username, password, return_expires, discord_id) VALUES ($1, $2, $3, $4) RETURNING id`,
+		username, string(passwordHash), time.Now().Add(time.Hour*24*30), discordID,
+	).Scan(&id)
+	return id, err
+}
@@ -0,0 +1,52 @@
+package discordbot
+
+import (
+	"github.com/jmoiron/sqlx"
+)
+
+// GuildRepository implements GuildRepo with PostgreSQL.
+type GuildRepository struct {
+	db *sqlx.DB
+}
+
+// NewGuildRepository creates a new GuildRepository.
+func NewGuildRepository(db *sqlx.DB) *GuildRepository {
+	return &GuildRepository{db: db}
+}
+
+func (r *GuildRepository) ListRecruiting() ([]RecruitingGuild, error) {
+	rows, err := r.db.Query(`
+		SELECT g.id, COALESCE(g.name, ''), g.comment,
+			(SELECT COUNT(*) FROM guild_characters gc WHERE gc.guild_id = g.id),
+			COALESCE(c.name, ''), COALESCE(u.discord_id, '')
+		FROM guilds g
+		JOIN characters c ON c.id = g.leader_id
+		JOIN users u ON u.id = c.user_id
+		WHERE g.recruiting = true
+		ORDER BY 4 DESC, g.id
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+	var guilds []RecruitingGuild
+	for rows.Next() {
+		var g RecruitingGuild
+		if err := rows.Scan(&g.ID, &g.Name, &g.Comment, &g.Members, &g.LeaderName, &g.LeaderDiscordID); err != nil {
+			return nil, err
+		}
+		guilds = append(guilds, g)
+	}
+	return guilds, rows.Err()
+}
+
+func (r *GuildRepository) SetRecruitingByLeader(discordID string, recruiting bool) (string, error) {
+	var name string
+	err := r.db.QueryRow(`
+		UPDATE guilds g SET recruiting = $1
+		FROM characters c JOIN users u ON u.id = c.user_id
+		WHERE c.id = g.leader_id AND u.discord_id = $2
+		RETURNING COALESCE(g.name, '')
+	`, recruiting, discordID).Scan(&name)
+	return name, err
+}
@@ -0,0 +1,52 @@
+package discordbot
+
+// Repository interfaces decouple the bot from the PostgreSQL implementation,
+// enabling mock injection for unit tests.
+
+// LinkedAccount is a game account linked to a Discord user.
+type LinkedAccount struct {
+	UserID    uint32
+	DiscordID string
+	Rights    uint32
+	Op        bool
+	NotifyDM  bool
+}
+
+// AccountRepo defines the contract for the account data used by the bot.
+type AccountRepo interface {
+	// ListLinked returns every account linked to a Discord user.
+	ListLinked() ([]LinkedAccount, error)
+	// GetByDiscordID returns the account linked to the given Discord user.
+	GetByDiscordID(discordID string) (LinkedAccount, error)
+	// GetByUserID returns the account with the given user ID.
+	GetByUserID(userID uint32) (LinkedAccount, error)
+	// GetByCharID returns the account owning the given character.
+	GetByCharID(charID uint32) (LinkedAccount, error)
+	// SetRights sets the account's rights bitmask.
+	SetRights(userID uint32, rights uint32) error
+	// CreateLinked creates an account already linked to discordID and returns
+	// its user ID.
+	CreateLinked(username string, passwordHash []byte, discordID string) (uint32, error)
+	// SetNotifyDM sets whether the account linked to the given Discord user
+	// receives DM notifications.
+	SetNotifyDM(discordID string, enabled bool) error
+}
+
+// RecruitingGuild is a guild listed on the recruitment board.
+type RecruitingGuild struct {
+	ID              uint32
+	Name            string
+	Comment         string
+	Members         int
+	LeaderName      string
+	LeaderDiscordID string
+}
+
+// GuildRepo defines the contract for the guild data used by the bot.
+type GuildRepo interface {
+	// ListRecruiting returns every guild flagged as recruiting, largest first.
+	ListRecruiting() ([]RecruitingGuild, error)
+	// SetRecruitingByLeader sets the recruiting flag of the guild led by a
+	// character of the account linked to discordID, returning the guild name.
+	SetRecruitingByLeader(discordID string, recruiting bool) (string, error)
+}
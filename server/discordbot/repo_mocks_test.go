@@ -0,0 +1,92 @@
+package discordbot
+
+import "database/sql"
+
+// mockAccountRepo implements AccountRepo for testing.
+type mockAccountRepo struct {
+	accounts []LinkedAccount
+	charIDs  map[uint32]uint32 // charID -> userID
+	err      error
+
+	setRightsCalls int
+	notifyDM       map[string]bool
+	created        []string
+}
+
+func (m *mockAccountRepo) ListLinked() ([]LinkedAccount, error) {
+	return m.accounts, m.err
+}
+
+func (m *mockAccountRepo) GetByDiscordID(discordID string) (LinkedAccount, error) {
+	for _, a := range m.accounts {
+		if a.DiscordID == discordID {
+			return a, nil
+		}
+	}
+	if m.err != nil {
+		return LinkedAccount{}, m.err
+	}
+	return LinkedAccount{}, sql.ErrNoRows
+}
+
+func (m *mockAccountRepo) GetByUserID(userID uint32) (LinkedAccount, error) {
+	for _, a := range m.accounts {
+		if a.UserID == userID {
+			return a, nil
+		}
+	}
+	return LinkedAccount{}, m.err
+}
+
+func (m *mockAccountRepo) GetByCharID(charID uint32) (LinkedAccount, error) {
+	if userID, ok := m.charIDs[charID]; ok {
+		return m.GetByUserID(userID)
+	}
+	return LinkedAccount{}, m.err
+}
+
+func (m *mockAccountRepo) SetRights(_ uint32, _ uint32) error {
+	m.setRightsCalls++
+	return m.err
+}
+
+func (m *mockAccountRepo) CreateLinked(username string, _ []byte, discordID string) (uint32, error) {
+	if m.err != nil {
+		return 0, m.err
+	}
+	id := uint32(len(m.accounts) + 1)
+	m.accounts = append(m.accounts, LinkedAccount{UserID: id, DiscordID: discordID})
+	m.created = append(m.created, username)
+	return id, nil
+}
+
+func (m *mockAccountRepo) SetNotifyDM(discordID string, enabled bool) error {
+	if m.err != nil {
+		return m.err
+	}
+	if m.notifyDM == nil {
+		m.notifyDM = make(map[string]bool)
+	}
+	m.notifyDM[discordID] = enabled
+	return nil
+}
+
+// mockGuildRepo implements GuildRepo for testing.
+type mockGuildRepo struct {
+	guilds []RecruitingGuild
+	name   string
+	err    error
+
+	lastDiscordID  string
+	lastRecruiting bool
+}
+
+func (m *mockGuildRepo) ListRecruiting() ([]RecruitingGuild, error) {
+	return m.guilds, m.err
+}
+
+func (m *mockGuildRepo) SetRecruitingByLeader(discordID string, recruiting bool) (string, error) {
+	m.lastDiscordID = discordID
+	m.lastRecruiting = recruiting
+	return m.name, m.err
+}
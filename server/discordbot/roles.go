@@ -0,0 +1,189 @@
+package discordbot
+
+import (
+	"erupe-ce/common/mhfcourse"
+	cfg "erupe-ce/config"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// ManagedRoles returns the set of role IDs controlled by role sync. Roles
+// outside this set are never added or removed.
+func ManagedRoles(rs cfg.DiscordRoleSync) map[string]bool {
+	managed := make(map[string]bool)
+	if rs.OperatorRoleID != "" {
+		managed[rs.OperatorRoleID] = true
+	}
+	for _, cr := range rs.CourseRoles {
+		if cr.RoleID != "" {
+			managed[cr.RoleID] = true
+		}
+	}
+	return managed
+}
+
+// DesiredRoles returns the managed roles an account should hold.
+func DesiredRoles(rs cfg.DiscordRoleSync, account LinkedAccount) map[string]bool {
+	desired := make(map[string]bool)
+	if account.Op && rs.OperatorRoleID != "" {
+		desired[rs.OperatorRoleID] = true
+	}
+	for _, cr := range rs.CourseRoles {
+		course, ok := mhfcourse.FindCourse(cr.Course)
+		if !ok || cr.RoleID == "" {
+			continue
+		}
+		if account.Rights&course.Value() != 0 {
+			desired[cr.RoleID] = true
+		}
+	}
+	return desired
+}
+
+// RoleDiff compares a member's current roles with the desired set and returns
+// the managed roles to add and remove.
+func RoleDiff(current []string, desired, managed map[string]bool) (add, remove []string) {
+	has := make(map[string]bool, len(current))
+	for _, id := range current {
+		has[id] = true
+		if managed[id] && !desired[id] {
+			remove = append(remove, id)
+		}
+	}
+	for id := range desired {
+		if !has[id] {
+			add = append(add, id)
+		}
+	}
+	return add, remove
+}
+
+// BoosterRights returns rights with the configured booster course granted.
+// The second result reports whether rights changed. The course is not revoked
+// when boosting ends, since it may also have been obtained in-game.
+func BoosterRights(rs cfg.DiscordRoleSync, rights uint32) (uint32, bool) {
+	if rs.BoosterCourse == "" {
+		return rights, false
+	}
+	course, ok := mhfcourse.FindCourse(rs.BoosterCourse)
+	if !ok || rights&course.Value() != 0 {
+		return rights, false
+	}
+	return rights | course.Value(), true
+}
+
+// StartRoleSync starts the periodic re-sync of every linked account. It
+// returns a func that stops the periodic job.
+func (bot *DiscordBot) StartRoleSync() func() {
+	if !bot.roleSyncEnabled() {
+		return func() {}
+	}
+
+	interval := time.Duration(bot.config.Discord.RoleSync.Interval) * time.Minute
+	if interval <= 0 {
+		interval = time.Hour
+	}
+	done := make(chan struct{})
+	go func() {
+		bot.SyncAllRoles()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				bot.SyncAllRoles()
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() { close(done) }
+}
+
+// SyncAllRoles re-syncs the roles of every linked account.
+func (bot *DiscordBot) SyncAllRoles() {
+	if !bot.roleSyncEnabled() {
+		return
+	}
+	accounts, err := bot.accounts.ListLinked()
+	if err != nil {
+		bot.logger.Warn("Discord: Failed to list linked accounts", zap.Error(err))
+		return
+	}
+	for _, account := range accounts {
+		if err := bot.syncRoles(account); err != nil {
+			bot.logger.Warn("Discord: Failed to sync roles", zap.String("discord_id", account.DiscordID), zap.Error(err))
+		}
+	}
+}
+
+// SyncDiscordUser re-syncs the roles of the account linked to discordID.
+// It is a no-op when role sync is disabled.
+func (bot *DiscordBot) SyncDiscordUser(discordID string) {
+	if !bot.roleSyncEnabled() {
+		return
+	}
+	account, err := bot.accounts.GetByDiscordID(discordID)
+	if err != nil {
+		bot.logger.Warn("Discord: Failed to load linked account", zap.String("discord_id", discordID), zap.Error(err))
+		return
+	}
+	if err := bot.syncRoles(account); err != nil {
+		bot.logger.Warn("Discord: Failed to sync roles", zap.String("discord_id", discordID), zap.Error(err))
+	}
+}
+
+// SyncUserRoles re-syncs the roles of the given game account if it is linked.
+// It is a no-op when role sync is disabled.
+func (bot *DiscordBot) SyncUserRoles(userID uint32) {
+	if !bot.roleSyncEnabled() {
+		return
+	}
+	account, err := bot.accounts.GetByUserID(userID)
+	if err != nil {
+		bot.logger.Warn("Discord: Failed to load account", zap.Uint32("user_id", userID), zap.Error(err))
+		return
+	}
+	if account.DiscordID == "" {
+		return
+	}
+	if err := bot.syncRoles(account); err != nil {
+		bot.logger.Warn("Discord: Failed to sync roles", zap.Uint32("user_id", userID), zap.Error(err))
+	}
+}
+
+func (bot *DiscordBot) roleSyncEnabled() bool {
+	rs := bot.config.Discord.RoleSync
+	return bot.accounts != nil && rs.Enabled && rs.GuildID != ""
+}
+
+func (bot *DiscordBot) syncRoles(account LinkedAccount) error {
+	rs := bot.config.Discord.RoleSync
+	member, err := bot.Session.GuildMember(rs.GuildID, account.DiscordID)
+	if err != nil {
+		return err
+	}
+
+	if member.PremiumSince != nil {
+		if rights, changed := BoosterRights(rs, account.Rights); changed {
+			if err := bot.accounts.SetRights(account.UserID, rights); err != nil {
+				return err
+			}
+			account.Rights = rights
+		}
+	}
+
+	add, remove := RoleDiff(member.Roles, DesiredRoles(rs, account), ManagedRoles(rs))
+	for _, id := range add {
+		if err := bot.Session.GuildMemberRoleAdd(rs.GuildID, account.DiscordID, id); err != nil {
+			return err
+		}
+	}
+	for _, id := range remove {
+		if err := bot.Session.GuildMemberRoleRemove(rs.GuildID, account.DiscordID, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
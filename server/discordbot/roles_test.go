@@ -0,0 +1,96 @@
+package discordbot
+
+import (
+	cfg "erupe-ce/config"
+	"sort"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+var testRoleSync = cfg.DiscordRoleSync{
+	Enabled:        true,
+	GuildID:        "guild",
+	OperatorRoleID: "op",
+	CourseRoles: []cfg.DiscordCourseRole{
+		{Course: "HunterLife", RoleID: "hl"},
+		{Course: "Extra", RoleID: "ex"},
+		{Course: "NotACourse", RoleID: "bogus"},
+	},
+	BoosterCourse: "Premium",
+}
+
+func TestDesiredRoles(t *testing.T) {
+	account := LinkedAccount{Rights: 1 << 2, Op: true} // HunterLife
+	got := DesiredRoles(testRoleSync, account)
+	if len(got) != 2 || !got["op"] || !got["hl"] {
+		t.Errorf("DesiredRoles = %v, want op and hl", got)
+	}
+
+	if got := DesiredRoles(testRoleSync, LinkedAccount{}); len(got) != 0 {
+		t.Errorf("DesiredRoles for plain account = %v, want none", got)
+	}
+}
+
+func TestRoleDiffOnlyTouchesManagedRoles(t *testing.T) {
+	managed := ManagedRoles(testRoleSync)
+	desired := map[string]bool{"hl": true, "op": true}
+	add, remove := RoleDiff([]string{"ex", "unmanaged", "op"}, desired, managed)
+	sort.Strings(add)
+	sort.Strings(remove)
+
+	if len(add) != 1 || add[0] != "hl" {
+		t.Errorf("add = %v, want [hl]", add)
+	}
+	if len(remove) != 1 || remove[0] != "ex" {
+		t.Errorf("remove = %v, want [ex]", remove)
+	}
+}
+
+func TestBoosterRights(t *testing.T) {
+	rights, changed := BoosterRights(testRoleSync, 1<<2)
+	if !changed || rights != 1<<2|1<<6 {
+		t.Errorf("BoosterRights = (%d, %v), want (%d, true)", rights, changed, 1<<2|1<<6)
+	}
+
+	if _, changed := BoosterRights(testRoleSync, 1<<6); changed {
+		t.Error("BoosterRights changed rights that already had the course")
+	}
+
+	disabled := testRoleSync
+	disabled.BoosterCourse = ""
+	if _, changed := BoosterRights(disabled, 0); changed {
+		t.Error("BoosterRights changed rights with no booster course configured")
+	}
+}
+
+func TestSyncUserRolesSkipsUnlinkedAccounts(t *testing.T) {
+	repo := &mockAccountRepo{accounts: []LinkedAccount{{UserID: 1}}}
+	bot := &DiscordBot{
+		config:   &cfg.Config{Discord: cfg.Discord{RoleSync: testRoleSync}},
+		logger:   zap.NewNop(),
+		accounts: repo,
+	}
+
+	// Session is nil, so reaching the Discord API would panic.
+	bot.SyncUserRoles(1)
+
+	if repo.setRightsCalls != 0 {
+		t.Errorf("SetRights called %d times, want 0", repo.setRightsCalls)
+	}
+}
+
+func TestRoleSyncRequiresAccountsAndConfig(t *testing.T) {
+	bot := &DiscordBot{config: &cfg.Config{Discord: cfg.Discord{RoleSync: testRoleSync}}, logger: zap.NewNop()}
+	if bot.roleSyncEnabled() {
+		t.Error("role sync enabled without an account repo")
+	}
+	bot.UseAccounts(&mockAccountRepo{})
+	if !bot.roleSyncEnabled() {
+		t.Error("role sync disabled with an account repo and config")
+	}
+	bot.config.Discord.RoleSync.Enabled = false
+	if bot.roleSyncEnabled() {
+		t.Error("role sync enabled while disabled in config")
+	}
+}
@@ -0,0 +1,121 @@
+package discordbot
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"erupe-ce/server/status"
+
+	"github.com/bwmarrin/discordgo"
+	"go.uber.org/zap"
+)
+
+var errNoStatus = errors.New("server status is not configured")
+
+// Discord truncates activity names past 128 characters.
+const maxPresenceLength = 128
+
+// PresenceText summarises snap for the bot's presence.
+func PresenceText(snap status.Snapshot) string {
+	text := fmt.Sprintf("%d hunters online", snap.Players)
+	if snap.Players == 1 {
+		text = "1 hunter online"
+	}
+	for _, e := range snap.Active {
+		if len(text)+len(e.Name)+3 > maxPresenceLength {
+			break
+		}
+		text += " | " + e.Name
+	}
+	return text
+}
+
+// BuildStatusEmbed renders snap for the /status command.
+func BuildStatusEmbed(snap status.Snapshot) *discordgo.MessageEmbed {
+	embed := &discordgo.MessageEmbed{
+		Title:       "Server status",
+		Description: fmt.Sprintf("%d hunters online", snap.Players),
+		Color:       0x3498DB,
+		Timestamp:   snap.Time.Format(time.RFC3339),
+	}
+	for _, w := range snap.Worlds {
+		if len(embed.Fields) == maxEmbedFields-2 {
+			break
+		}
+		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
+			Name:   w.Name,
+			Value:  fmt.Sprintf("%d players", w.Players),
+			Inline: true,
+		})
+	}
+	if len(snap.Active) > 0 {
+		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
+			Name:  "Active events",
+			Value: formatEvents(snap.Active),
+		})
+	}
+	if len(snap.Upcoming) > 0 {
+		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
+			Name:  "Upcoming events",
+			Value: formatEvents(snap.Upcoming),
+		})
+	}
+	return embed
+}
+
+// formatEvents lists events with Discord timestamps, which render in the
+// reader's time zone.
+func formatEvents(events []status.Event) string {
+	lines := make([]string, len(events))
+	for i, e := range events {
+		lines[i] = fmt.Sprintf("%s <t:%d:R>", e.Name, e.Start.Unix())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Status collects the current server status.
+func (bot *DiscordBot) Status() (status.Snapshot, error) {
+	if bot.status == nil {
+		return status.Snapshot{}, errNoStatus
+	}
+	return status.Collect(bot.status, time.Now())
+}
+
+// StartPresence starts the job that keeps the bot's presence showing the
+// online player count and active events. It returns a func that stops the job.
+func (bot *DiscordBot) StartPresence() func() {
+	s := bot.config.Discord.Status
+	if !s.Enabled || bot.status == nil {
+		return func() {}
+	}
+	interval := time.Duration(s.Interval) * time.Minute
+	if interval <= 0 {
+		interval = 5 * time.Minute
+	}
+	done := make(chan struct{})
+	go func() {
+		update := func() {
+			snap, err := bot.Status()
+			if err == nil {
+				err = bot.Session.UpdateWatchStatus(0, PresenceText(snap))
+			}
+			if err != nil {
+				bot.logger.Warn("Discord: Failed to update presence", zap.Error(err))
+			}
+		}
+		update()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				update()
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() { close(done) }
+}
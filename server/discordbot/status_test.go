@@ -0,0 +1,53 @@
+package discordbot
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"erupe-ce/server/status"
+)
+
+func TestPresenceText(t *testing.T) {
+	snap := status.Snapshot{Players: 12, Active: []status.Event{{Name: "Hunting Festival"}}}
+	if got := PresenceText(snap); got != "12 hunters online | Hunting Festival" {
+		t.Errorf("PresenceText() = %q", got)
+	}
+	if got := PresenceText(status.Snapshot{Players: 1}); got != "1 hunter online" {
+		t.Errorf("PresenceText() = %q", got)
+	}
+
+	long := status.Snapshot{}
+	for i := 0; i < 20; i++ {
+		long.Active = append(long.Active, status.Event{Name: "Hunting Festival"})
+	}
+	if got := PresenceText(long); len(got) > maxPresenceLength {
+		t.Errorf("PresenceText() length = %d, want <= %d", len(got), maxPresenceLength)
+	}
+}
+
+func TestBuildStatusEmbed(t *testing.T) {
+	start := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+	snap := status.Snapshot{
+		Players:  7,
+		Worlds:   []status.World{{Name: "Newbie", Players: 3}, {Name: "Normal", Players: 4}},
+		Upcoming: []status.Event{{Name: "Diva Defense", Start: start}},
+	}
+	embed := BuildStatusEmbed(snap)
+	if len(embed.Fields) != 3 {
+		t.Fatalf("fields = %d, want 2 worlds and upcoming events", len(embed.Fields))
+	}
+	if embed.Fields[0].Value != "3 players" {
+		t.Errorf("world field = %q", embed.Fields[0].Value)
+	}
+	if !strings.Contains(embed.Fields[2].Value, "<t:1704153600:R>") {
+		t.Errorf("upcoming field = %q, want a Discord timestamp", embed.Fields[2].Value)
+	}
+}
+
+func TestStatusWithoutSource(t *testing.T) {
+	bot := &DiscordBot{}
+	if _, err := bot.Status(); err != errNoStatus {
+		t.Errorf("Status() error = %v, want errNoStatus", err)
+	}
+}
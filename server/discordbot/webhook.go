@@ -0,0 +1,48 @@
+package discordbot
+
+import (
+	"errors"
+	"regexp"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+var errInvalidWebhook = errors.New("invalid webhook URL")
+
+var webhookPattern = regexp.MustCompile(`/api/(?:v\d+/)?webhooks/(\d+)/([\w-]+)`)
+
+// ParseWebhookURL splits a Discord webhook URL into its ID and token.
+func ParseWebhookURL(url string) (id, token string, ok bool) {
+	m := webhookPattern.FindStringSubmatch(url)
+	if m == nil {
+		return "", "", false
+	}
+	return m[1], m[2], true
+}
+
+// WebhookOnly reports whether the bot runs without a bot token, posting only
+// through webhooks.
+func (bot *DiscordBot) WebhookOnly() bool {
+	return bot.config.Discord.WebhookOnly
+}
+
+// post sends msg through webhook if one is set, otherwise through the bot to
+// channelID. Without a webhook in webhook-only mode nothing is sent.
+func (bot *DiscordBot) post(channelID, webhook string, msg *discordgo.MessageSend) error {
+	if webhook != "" {
+		id, token, ok := ParseWebhookURL(webhook)
+		if !ok {
+			return errInvalidWebhook
+		}
+		_, err := bot.Session.WebhookExecute(id, token, false, &discordgo.WebhookParams{
+			Content: msg.Content,
+			Embeds:  msg.Embeds,
+		})
+		return err
+	}
+	if bot.WebhookOnly() || channelID == "" {
+		return nil
+	}
+	_, err := bot.Session.ChannelMessageSendComplex(channelID, msg)
+	return err
+}
@@ -0,0 +1,47 @@
+package discordbot
+
+import (
+	cfg "erupe-ce/config"
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+	"go.uber.org/zap"
+)
+
+func TestParseWebhookURL(t *testing.T) {
+	tests := []struct {
+		url       string
+		wantID    string
+		wantToken string
+		wantOK    bool
+	}{
+		{"https://discord.com/api/webhooks/123456/abc-DEF_1", "123456", "abc-DEF_1", true},
+		{"https://discordapp.com/api/v10/webhooks/42/tok", "42", "tok", true},
+		{"https://example.com/hook", "", "", false},
+		{"", "", "", false},
+	}
+	for _, tt := range tests {
+		id, token, ok := ParseWebhookURL(tt.url)
+		if id != tt.wantID || token != tt.wantToken || ok != tt.wantOK {
+			t.Errorf("ParseWebhookURL(%q) = %q, %q, %v", tt.url, id, token, ok)
+		}
+	}
+}
+
+func TestPostWebhookOnlySkipsChannels(t *testing.T) {
+	config := &cfg.Config{}
+	config.Discord.WebhookOnly = true
+	config.Discord.RelayChannel = cfg.DiscordRelay{
+		Enabled:  true,
+		Mappings: []cfg.DiscordRelayMapping{{Scope: ScopeWorld, ChannelID: "1", ToDiscord: true}},
+	}
+	// No session: any attempt to reach Discord would panic.
+	bot := &DiscordBot{config: config, logger: zap.NewNop()}
+
+	if err := bot.RelayToDiscord(ScopeWorld, 0, "Hunter", "hello"); err != nil {
+		t.Errorf("RelayToDiscord() error = %v", err)
+	}
+	if err := bot.post("", "https://example.com/hook", &discordgo.MessageSend{Content: "x"}); err != errInvalidWebhook {
+		t.Errorf("post() error = %v, want errInvalidWebhook", err)
+	}
+}
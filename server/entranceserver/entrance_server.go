@@ -5,11 +5,11 @@ import (
 	"fmt"
 	"io"
 	"net"
-	"strings"
 	"sync"
 
 	cfg "erupe-ce/config"
 	"erupe-ce/network"
+	"erupe-ce/network/crypto"
 	"github.com/jmoiron/sqlx"
 	"go.uber.org/zap"
 )
@@ -21,6 +21,8 @@ type Server struct {
 	erupeConfig    *cfg.Config
 	serverRepo     EntranceServerRepo
 	sessionRepo    EntranceSessionRepo
+	connLimiter    *network.ConnLimiter
+	cipher         crypto.Cipher
 	listener       net.Listener
 	isShuttingDown bool
 }
@@ -30,6 +32,8 @@ type Config struct {
 	Logger      *zap.Logger
 	DB          *sqlx.DB
 	ErupeConfig *cfg.Config
+	ConnLimiter *network.ConnLimiter // Shared by all listeners; nil disables connection limits
+	Cipher      crypto.Cipher        // Packet body cipher; nil for the retail cipher
 }
 
 // NewServer creates a new Server type.
@@ -37,6 +41,8 @@ func NewServer(config *Config) *Server {
 	s := &Server{
 		logger:      config.Logger,
 		erupeConfig: config.ErupeConfig,
+		connLimiter: config.ConnLimiter,
+		cipher:      config.Cipher,
 	}
 	if config.DB != nil {
 		s.serverRepo = NewEntranceServerRepository(config.DB)
@@ -52,8 +58,15 @@ func (s *Server) Start() error {
 	if err != nil {
 		return err
 	}
-
-	s.listener = l
+	s.listener, err = network.WithProxyProtocol(l, s.erupeConfig.ProxyProtocol)
+	if err != nil {
+		_ = l.Close()
+		return err
+	}
+	if s.connLimiter != nil {
+		// Clients open with 8 NULL bytes, so anything else is turned away early.
+		s.listener = s.connLimiter.Wrap(s.listener, true)
+	}
 
 	go s.acceptClients()
 
@@ -108,8 +121,9 @@ func (s *Server) handleEntranceServerConnection(conn net.Conn) {
 	}
 
 	// Create a new encrypted connection handler and read a packet from it.
-	var cc network.Conn = network.NewCryptConn(conn, s.erupeConfig.RealClientMode, s.logger)
-	cc, captureCleanup := startEntranceCapture(s, cc, conn.RemoteAddr())
+	var cc network.Conn = network.NewCipherConn(conn, s.erupeConfig.RealClientMode, s.cipher, s.logger)
+	cc = network.WithFaults(cc, conn, s.erupeConfig.DebugOptions.FaultInjection)
+	cc, captureConn, captureCleanup := startEntranceCapture(s, cc, conn.RemoteAddr())
 	defer captureCleanup()
 
 	pkt, err := cc.ReadPacket()
@@ -122,13 +136,18 @@ func (s *Server) handleEntranceServerConnection(conn net.Conn) {
 		s.logger.Debug("Inbound packet", zap.Int("bytes", len(pkt)), zap.String("data", hex.Dump(pkt)))
 	}
 
-	local := strings.Split(conn.RemoteAddr().String(), ":")[0] == "127.0.0.1"
+	local := network.IsLoopback(conn.RemoteAddr())
 
 	data := makeSv2Resp(s.erupeConfig, s, local)
 	if len(pkt) > 5 {
 		data = append(data, makeUsrResp(pkt, s)...)
 	}
 	_ = cc.SendPacket(data)
+	if captureConn != nil && !s.erupeConfig.Live().Capture.Targets("", usrCharIDs(pkt)...) {
+		if err := captureConn.Discard(); err != nil {
+			s.logger.Warn("Failed to discard capture", zap.Error(err))
+		}
+	}
 	// Close because we only need to send the response once.
 	// Any further requests from the client will come from a new connection.
 }
@@ -1,11 +1,10 @@
 package entranceserver
 
 import (
-	"encoding/binary"
 	"encoding/hex"
 	"erupe-ce/common/stringsupport"
 	cfg "erupe-ce/config"
-	"net"
+	"erupe-ce/network"
 
 	"erupe-ce/common/byteframe"
 	"erupe-ce/common/gametime"
@@ -35,7 +34,7 @@ func encodeServerInfo(config *cfg.Config, s *Server, local bool) []byte {
 		if local {
 			bf.WriteUint32(0x0100007F) // 127.0.0.1
 		} else {
-			bf.WriteUint32(binary.LittleEndian.Uint32(net.ParseIP(si.IP).To4()))
+			bf.WriteUint32(network.IPv4LE(si.IP))
 		}
 		bf.WriteUint16(uint16(serverIdx | 16))
 		bf.WriteUint16(0)
@@ -92,8 +91,8 @@ func encodeServerInfo(config *cfg.Config, s *Server, local bool) []byte {
 	// ClanMemberLimits requires at least 1 element with 2 columns to avoid index out of range panics
 	// Use default value (60) if array is empty or last row is too small
 	var maxClanMembers uint8 = 60
-	if len(s.erupeConfig.GameplayOptions.ClanMemberLimits) > 0 {
-		lastRow := s.erupeConfig.GameplayOptions.ClanMemberLimits[len(s.erupeConfig.GameplayOptions.ClanMemberLimits)-1]
+	if len(s.erupeConfig.Live().GameplayOptions.ClanMemberLimits) > 0 {
+		lastRow := s.erupeConfig.Live().GameplayOptions.ClanMemberLimits[len(s.erupeConfig.Live().GameplayOptions.ClanMemberLimits)-1]
 		if len(lastRow) > 1 {
 			maxClanMembers = lastRow[1]
 		}
@@ -261,3 +261,20 @@ func TestEncodeServerInfo_MissingSecondColumnClanMemberLimits(t *testing.T) {
 		t.Log("✅ encodeServerInfo handled missing ClanMemberLimits column without array bounds panic")
 	}
 }
+
+func TestUsrCharIDs(t *testing.T) {
+	pkt := []byte{
+		'A', 'L', 'L', '+',
+		0x00,
+		0x00, 0x03, // 3 entries, the last cut short
+		0x00, 0x00, 0x00, 0x07,
+		0x00, 0x00, 0x01, 0x00,
+		0x00, 0x00,
+	}
+	if got := usrCharIDs(pkt); len(got) != 2 || got[0] != 7 || got[1] != 256 {
+		t.Errorf("usrCharIDs() = %v, want [7 256]", got)
+	}
+	if got := usrCharIDs([]byte("ALL+\x00")); got != nil {
+		t.Errorf("usrCharIDs() without USR = %v, want none", got)
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"path/filepath"
 	"time"
 
+	"erupe-ce/common/byteframe"
 	"erupe-ce/network"
 	"erupe-ce/network/pcap"
 
@@ -14,19 +15,20 @@ import (
 )
 
 // startEntranceCapture wraps a Conn with a RecordingConn if capture is enabled for entrance server.
-func startEntranceCapture(s *Server, conn network.Conn, remoteAddr net.Addr) (network.Conn, func()) {
-	capCfg := s.erupeConfig.Capture
+func startEntranceCapture(s *Server, conn network.Conn, remoteAddr net.Addr) (network.Conn, *pcap.RecordingConn, func()) {
+	capCfg := s.erupeConfig.Live().Capture
 	if !capCfg.Enabled || !capCfg.CaptureEntrance {
-		return conn, func() {}
+		return conn, nil, func() {}
 	}
 
+	logger := s.logger.Named("capture")
 	outputDir := capCfg.OutputDir
 	if outputDir == "" {
 		outputDir = "captures"
 	}
 	if err := os.MkdirAll(outputDir, 0o755); err != nil {
-		s.logger.Warn("Failed to create capture directory", zap.Error(err))
-		return conn, func() {}
+		logger.Warn("Failed to create capture directory", zap.Error(err))
+		return conn, nil, func() {}
 	}
 
 	now := time.Now()
@@ -36,18 +38,17 @@ func startEntranceCapture(s *Server, conn network.Conn, remoteAddr net.Addr) (ne
 	)
 	path := filepath.Join(outputDir, filename)
 
-	f, err := os.Create(path)
+	flags, err := pcap.CompressionFlag(capCfg.Compression)
 	if err != nil {
-		s.logger.Warn("Failed to create capture file", zap.Error(err), zap.String("path", path))
-		return conn, func() {}
+		logger.Warn("Unknown capture compression, recording uncompressed", zap.Error(err))
 	}
-
 	startNs := now.UnixNano()
 	hdr := pcap.FileHeader{
 		Version:        pcap.FormatVersion,
 		ServerType:     pcap.ServerTypeEntrance,
 		ClientMode:     byte(s.erupeConfig.RealClientMode),
 		SessionStartNs: startNs,
+		Flags:          flags | pcap.FlagChecksums,
 	}
 	meta := pcap.SessionMetadata{
 		Host:       s.erupeConfig.Host,
@@ -55,27 +56,53 @@ func startEntranceCapture(s *Server, conn network.Conn, remoteAddr net.Addr) (ne
 		RemoteAddr: remoteAddr.String(),
 	}
 
-	w, err := pcap.NewWriter(f, hdr, meta)
+	opts := pcap.RecordingOptions{
+		MaxBytes:    int64(capCfg.RotateSizeMB) << 20,
+		MaxDuration: time.Duration(capCfg.RotateMinutes) * time.Minute,
+		MaxTotal:    int64(capCfg.MaxTotalMB) << 20,
+	}
+	if capCfg.EncryptionKey != "" {
+		opts.Secret = []byte(capCfg.EncryptionKey)
+	}
+	rec, err := pcap.CreateRecording(path, hdr, meta, opts)
 	if err != nil {
-		s.logger.Warn("Failed to initialize capture writer", zap.Error(err))
-		_ = f.Close()
-		return conn, func() {}
+		logger.Warn("Failed to create capture file", zap.Error(err), zap.String("path", path))
+		return conn, nil, func() {}
 	}
 
-	s.logger.Info("Capture started", zap.String("file", path))
+	logger.Info("Capture started", zap.String("file", path))
 
-	rc := pcap.NewRecordingConn(conn, w, startNs, capCfg.ExcludeOpcodes)
+	rc := pcap.NewRecordingConn(conn, rec, startNs, capCfg.ExcludeOpcodes)
 	cleanup := func() {
-		if err := w.Flush(); err != nil {
-			s.logger.Warn("Failed to flush capture", zap.Error(err))
+		rc.Close()
+		if err := rec.Close(); err != nil {
+			logger.Warn("Failed to close capture file", zap.Error(err))
 		}
-		if err := f.Close(); err != nil {
-			s.logger.Warn("Failed to close capture file", zap.Error(err))
+		if paths := rec.Paths(); len(paths) > 0 {
+			logger.Info("Capture saved", zap.Strings("files", paths), zap.Uint64("dropped", rc.Dropped()))
 		}
-		s.logger.Info("Capture saved", zap.String("file", path))
 	}
 
-	return rc, cleanup
+	return rc, rc, cleanup
+}
+
+// usrCharIDs returns the characters a client asks the servers of in the
+// USR part of its request, the characters of the account signed in to.
+// Capture targeting matches them, as the entrance server is not told the
+// account.
+func usrCharIDs(pkt []byte) []uint32 {
+	if len(pkt) <= 5 {
+		return nil
+	}
+	bf := byteframe.NewByteFrameFromBytes(pkt)
+	_ = bf.ReadUint32() // ALL+
+	_ = bf.ReadUint8()  // 0x00
+	n := int(bf.ReadUint16())
+	ids := make([]uint32, 0, min(n, (len(pkt)-7)/4))
+	for i := 0; i < n && len(pkt)-7 >= 4*(i+1); i++ {
+		ids = append(ids, bf.ReadUint32())
+	}
+	return ids
 }
 
 func sanitizeAddr(addr string) string {
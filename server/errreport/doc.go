@@ -0,0 +1,3 @@
+// Package errreport sends handler panics and error logs to a
+// Sentry-compatible error tracker configured by the ErrorReporting section.
+package errreport
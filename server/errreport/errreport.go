@@ -0,0 +1,108 @@
+package errreport
+
+import (
+	"fmt"
+	"time"
+
+	cfg "erupe-ce/config"
+
+	"github.com/getsentry/sentry-go"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// flushTimeout bounds how long shutdown waits for queued events to send.
+const flushTimeout = 2 * time.Second
+
+// tagFields are log fields promoted to event tags, so reports can be
+// searched and grouped by session and opcode.
+var tagFields = []string{"opcode", "name", "charID"}
+
+// Setup initializes the global reporting client from c, tagging events with
+// release. It returns a function that sends queued events before shutdown.
+// Reporting stays disabled when no DSN is set.
+func Setup(c cfg.ErrorReportingOptions, release string) (func(), error) {
+	if c.DSN == "" {
+		return func() {}, nil
+	}
+	err := sentry.Init(sentry.ClientOptions{
+		Dsn:              c.DSN,
+		Environment:      c.Environment,
+		Release:          release,
+		SampleRate:       c.SampleRate,
+		AttachStacktrace: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return func() { sentry.Flush(flushTimeout) }, nil
+}
+
+// NewCore returns a zapcore.Core that reports entries at error level and
+// above to hub, with their fields attached. Tee it with the logger's own
+// core.
+func NewCore(hub *sentry.Hub) zapcore.Core {
+	return &core{hub: hub}
+}
+
+type core struct {
+	hub    *sentry.Hub
+	fields []zapcore.Field
+}
+
+func (c *core) Enabled(lvl zapcore.Level) bool {
+	return lvl >= zapcore.ErrorLevel
+}
+
+func (c *core) With(fields []zapcore.Field) zapcore.Core {
+	return &core{hub: c.hub, fields: append(c.fields[:len(c.fields):len(c.fields)], fields...)}
+}
+
+func (c *core) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *core) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	enc := zapcore.NewMapObjectEncoder()
+	for _, f := range c.fields {
+		f.AddTo(enc)
+	}
+	for _, f := range fields {
+		f.AddTo(enc)
+	}
+
+	event := sentry.NewEvent()
+	event.Level = sentry.LevelError
+	if ent.Level > zapcore.ErrorLevel {
+		event.Level = sentry.LevelFatal
+	}
+	event.Message = ent.Message
+	event.Logger = ent.LoggerName
+	event.Timestamp = ent.Time
+	event.Extra = enc.Fields
+	for _, key := range tagFields {
+		if v, ok := enc.Fields[key]; ok {
+			event.Tags[key] = fmt.Sprint(v)
+		}
+	}
+	if v, ok := enc.Fields["charID"]; ok {
+		event.User.ID = fmt.Sprint(v)
+	}
+	c.hub.CaptureEvent(event)
+	return nil
+}
+
+func (c *core) Sync() error {
+	return nil
+}
+
+// LoggerOption tees a logger's output to the global reporting client set up
+// by Setup.
+func LoggerOption() zap.Option {
+	return zap.WrapCore(func(c zapcore.Core) zapcore.Core {
+		return zapcore.NewTee(c, NewCore(sentry.CurrentHub()))
+	})
+}
@@ -0,0 +1,92 @@
+package errreport
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	cfg "erupe-ce/config"
+
+	"github.com/getsentry/sentry-go"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// recordingTransport keeps the events a client sends.
+type recordingTransport struct {
+	mu     sync.Mutex
+	events []*sentry.Event
+}
+
+func (t *recordingTransport) Configure(sentry.ClientOptions)        {}
+func (t *recordingTransport) Flush(time.Duration) bool              { return true }
+func (t *recordingTransport) FlushWithContext(context.Context) bool { return true }
+func (t *recordingTransport) Close()                                {}
+func (t *recordingTransport) SendEvent(e *sentry.Event) {
+	t.mu.Lock()
+	t.events = append(t.events, e)
+	t.mu.Unlock()
+}
+
+func newTestLogger(t *testing.T) (*zap.Logger, *recordingTransport) {
+	t.Helper()
+	transport := &recordingTransport{}
+	client, err := sentry.NewClient(sentry.ClientOptions{Dsn: "https://key@example.com/1", Transport: transport, Release: "erupe@test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	hub := sentry.NewHub(client, sentry.NewScope())
+	return zap.New(zapcore.NewTee(zapcore.NewNopCore(), NewCore(hub))), transport
+}
+
+func TestCore_ReportsErrorsWithContext(t *testing.T) {
+	logger, transport := newTestLogger(t)
+
+	session := logger.Named("channel-1").With(zap.String("name", "Hunter"))
+	session.Warn("Not reported")
+	session.Error("Recovered from panic",
+		zap.Uint32("charID", 42),
+		zap.String("opcode", "MSG_MHF_ENUMERATE_QUEST"),
+		zap.Any("panic", "index out of range"),
+	)
+
+	if len(transport.events) != 1 {
+		t.Fatalf("sent %d events, want 1", len(transport.events))
+	}
+	e := transport.events[0]
+	if e.Message != "Recovered from panic" || e.Level != sentry.LevelError || e.Logger != "channel-1" {
+		t.Errorf("event = %q at %s from %q", e.Message, e.Level, e.Logger)
+	}
+	if e.Release != "erupe@test" {
+		t.Errorf("Release = %q, want erupe@test", e.Release)
+	}
+	for key, want := range map[string]string{"opcode": "MSG_MHF_ENUMERATE_QUEST", "name": "Hunter", "charID": "42"} {
+		if got := e.Tags[key]; got != want {
+			t.Errorf("tag %s = %q, want %q", key, got, want)
+		}
+	}
+	if e.User.ID != "42" {
+		t.Errorf("User.ID = %q, want 42", e.User.ID)
+	}
+	if e.Extra["panic"] != "index out of range" {
+		t.Errorf("Extra = %v, want the panic value", e.Extra)
+	}
+}
+
+func TestSetup_DisabledWithoutDSN(t *testing.T) {
+	flush, err := Setup(cfg.ErrorReportingOptions{}, "erupe@test")
+	if err != nil {
+		t.Fatalf("Setup() error = %v", err)
+	}
+	flush()
+	if sentry.CurrentHub().Client() != nil {
+		t.Error("Setup() without a DSN installed a client")
+	}
+}
+
+func TestSetup_InvalidDSN(t *testing.T) {
+	if _, err := Setup(cfg.ErrorReportingOptions{DSN: "not a dsn"}, "erupe@test"); err == nil {
+		t.Error("Setup() accepted an invalid DSN")
+	}
+}
@@ -0,0 +1,5 @@
+// Package eventbus provides a small in-process publish/subscribe bus used to
+// announce notable server events (siege start/end, festa standings, server
+// firsts, maintenance) to integrations such as the Discord bot without the
+// emitting subsystem having to know who is listening.
+package eventbus
@@ -0,0 +1,91 @@
+package eventbus
+
+import (
+	"sync"
+	"time"
+)
+
+// Type identifies the kind of event published on the bus.
+type Type string
+
+// Event types published by the game servers.
+const (
+	SiegeStart     Type = "siege_start"     // A Raviente siege was announced
+	SiegeEnd       Type = "siege_end"       // All Raviente semaphores were released
+	FestaStandings Type = "festa_standings" // The leading Hunter's Festa team changed
+	ServerFirst    Type = "server_first"    // A character was first on the server to reach a milestone
+	Maintenance    Type = "maintenance"     // The server is going down for maintenance
+)
+
+// Field is a single named value attached to an event.
+type Field struct {
+	Name  string
+	Value string
+}
+
+// Event is a notable occurrence published on the bus.
+type Event struct {
+	Type    Type
+	Time    time.Time
+	Title   string
+	Message string
+	Fields  []Field
+	// Key identifies the state the event describes. Subscribers may use it to
+	// suppress duplicates when several channels publish the same transition.
+	Key string
+}
+
+// Handler receives published events. Handlers are called synchronously from
+// the publishing goroutine and must not block.
+type Handler func(Event)
+
+// Bus fans events out to all subscribed handlers. A nil *Bus is valid and
+// silently discards published events.
+type Bus struct {
+	mu       sync.RWMutex
+	nextID   int
+	handlers map[int]Handler
+}
+
+// New creates an empty Bus.
+func New() *Bus {
+	return &Bus{handlers: make(map[int]Handler)}
+}
+
+// Subscribe registers h for all future events and returns a function that
+// removes the subscription.
+func (b *Bus) Subscribe(h Handler) (unsubscribe func()) {
+	b.mu.Lock()
+	id := b.nextID
+	b.nextID++
+	b.handlers[id] = h
+	b.mu.Unlock()
+
+	return func() {
+		b.mu.Lock()
+		delete(b.handlers, id)
+		b.mu.Unlock()
+	}
+}
+
+// Publish delivers e to every subscriber. A zero Time is replaced with the
+// current time.
+func (b *Bus) Publish(e Event) {
+	if b == nil {
+		return
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+
+	b.mu.RLock()
+	handlers := make([]Handler, 0, len(b.handlers))
+	for _, h := range b.handlers {
+		handlers = append(handlers, h)
+	}
+	b.mu.RUnlock()
+
+	for _, h := range handlers {
+		h(e)
+	}
+}
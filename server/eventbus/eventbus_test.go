@@ -0,0 +1,58 @@
+package eventbus
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPublishDeliversToAllSubscribers(t *testing.T) {
+	bus := New()
+	var a, b []Event
+	bus.Subscribe(func(e Event) { a = append(a, e) })
+	bus.Subscribe(func(e Event) { b = append(b, e) })
+
+	bus.Publish(Event{Type: SiegeStart, Title: "Berserk"})
+
+	if len(a) != 1 || len(b) != 1 {
+		t.Fatalf("deliveries = %d, %d, want 1, 1", len(a), len(b))
+	}
+	if a[0].Type != SiegeStart || a[0].Title != "Berserk" {
+		t.Errorf("event = %+v", a[0])
+	}
+}
+
+func TestPublishFillsTime(t *testing.T) {
+	bus := New()
+	var got Event
+	bus.Subscribe(func(e Event) { got = e })
+
+	bus.Publish(Event{Type: Maintenance})
+	if got.Time.IsZero() {
+		t.Error("Publish should set Time when zero")
+	}
+
+	fixed := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	bus.Publish(Event{Type: Maintenance, Time: fixed})
+	if !got.Time.Equal(fixed) {
+		t.Errorf("Time = %v, want %v", got.Time, fixed)
+	}
+}
+
+func TestUnsubscribe(t *testing.T) {
+	bus := New()
+	count := 0
+	unsubscribe := bus.Subscribe(func(Event) { count++ })
+
+	bus.Publish(Event{Type: SiegeEnd})
+	unsubscribe()
+	bus.Publish(Event{Type: SiegeEnd})
+
+	if count != 1 {
+		t.Errorf("count = %d, want 1", count)
+	}
+}
+
+func TestNilBusPublish(t *testing.T) {
+	var bus *Bus
+	bus.Publish(Event{Type: SiegeStart}) // must not panic
+}
@@ -0,0 +1,7 @@
+// Package federation links independent Erupe servers, each with its own
+// database, so that allied communities can share world chat, the players
+// online and guild announcements. Servers connect over the cluster bus,
+// encrypted and with a secret of their own, and know each other by the
+// names they federate under. Each server must list the others as peers,
+// since a server only sends on the connections it dialled.
+package federation
@@ -0,0 +1,211 @@
+package federation
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	cfg "erupe-ce/config"
+	"erupe-ce/server/cluster"
+
+	"go.uber.org/zap"
+)
+
+// presenceTimeout bounds how long a presence request waits on the allied
+// servers, which may be far away.
+const presenceTimeout = time.Second
+
+// Message kinds.
+const (
+	kindChat     = "chat"
+	kindGuild    = "guild_announcement"
+	kindPresence = "presence"
+)
+
+var errPresenceNotShared = errors.New("presence is not shared")
+
+// Chat is a world chat message from an allied server.
+type Chat struct {
+	Server  string `json:"server"`
+	Sender  string `json:"sender"`
+	Message string `json:"message"`
+}
+
+// GuildAnnouncement is a news post of a guild linked to one on another
+// server.
+type GuildAnnouncement struct {
+	Server    string `json:"server"`
+	GuildID   uint32 `json:"guild_id"` // Guild on the sending server
+	GuildName string `json:"guild_name"`
+	Sender    string `json:"sender"`
+	Title     string `json:"title"`
+}
+
+// Presence is the players online on an allied server.
+type Presence struct {
+	Server  string   `json:"server"`
+	Players []string `json:"players"`
+}
+
+// Federation is this server's link to its allies. Its methods may be
+// called on a nil Federation, which shares nothing.
+type Federation struct {
+	opts cfg.FederationOptions
+	bus  *cluster.Bus
+
+	// OnChat delivers chat from an allied server. It is set before Start.
+	OnChat func(Chat)
+	// OnGuildAnnouncement delivers an announcement to the members of the
+	// local guild linked to the announcing one. It is set before Start.
+	OnGuildAnnouncement func(guildID uint32, a GuildAnnouncement)
+	// Players lists the names of the players online here. It is set
+	// before Start.
+	Players func() []string
+}
+
+// New creates the Federation described by opts.
+func New(opts cfg.FederationOptions, logger *zap.Logger) *Federation {
+	secrets := make(map[string]string, len(opts.Allies))
+	for _, a := range opts.Allies {
+		secrets[a.Name] = a.Secret
+	}
+	f := &Federation{
+		opts: opts,
+		bus: cluster.NewKeyed(opts.Name, func(ally string) (string, bool) {
+			secret, ok := secrets[ally]
+			return secret, ok
+		}, logger),
+	}
+	f.bus.Handle(kindChat, f.serveChat)
+	f.bus.Handle(kindGuild, f.serveGuild)
+	f.bus.Handle(kindPresence, f.servePresence)
+	return f
+}
+
+// Start accepts the allied servers and connects to them.
+func (f *Federation) Start() error {
+	if err := f.bus.Listen(f.opts.Listen); err != nil {
+		return err
+	}
+	for _, a := range f.opts.Allies {
+		f.bus.ConnectNode(a.Name, a.Address)
+	}
+	return nil
+}
+
+// Close disconnects from the allied servers.
+func (f *Federation) Close() {
+	if f != nil {
+		f.bus.Close()
+	}
+}
+
+// Name returns the name this server federates under.
+func (f *Federation) Name() string {
+	if f == nil {
+		return ""
+	}
+	return f.opts.Name
+}
+
+// Peers returns the names of the allied servers connected.
+func (f *Federation) Peers() []string {
+	if f == nil {
+		return nil
+	}
+	return f.bus.Connected()
+}
+
+// SendChat shares a world chat message with the allied servers.
+func (f *Federation) SendChat(sender, message string) {
+	if f == nil || !f.opts.Chat {
+		return
+	}
+	_ = f.bus.Publish(kindChat, Chat{Server: f.opts.Name, Sender: sender, Message: message})
+}
+
+// AnnounceGuild shares a news post of the guild with the allied servers,
+// if the guild is linked to one of theirs.
+func (f *Federation) AnnounceGuild(guildID uint32, guildName, sender, title string) {
+	if f == nil || !f.linked(guildID) {
+		return
+	}
+	_ = f.bus.Publish(kindGuild, GuildAnnouncement{
+		Server:    f.opts.Name,
+		GuildID:   guildID,
+		GuildName: guildName,
+		Sender:    sender,
+		Title:     title,
+	})
+}
+
+// Presence asks the allied servers who is online. Servers that do not
+// share presence or do not answer in time are left out.
+func (f *Federation) Presence() []Presence {
+	if f == nil || !f.opts.Presence {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), presenceTimeout)
+	defer cancel()
+	replies, _ := f.bus.Request(ctx, kindPresence, nil)
+	var out []Presence
+	for _, reply := range replies {
+		var p Presence
+		if json.Unmarshal(reply, &p) == nil {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+func (f *Federation) linked(guildID uint32) bool {
+	for _, g := range f.opts.Guilds {
+		if g.GuildID == guildID {
+			return true
+		}
+	}
+	return false
+}
+
+func (f *Federation) serveChat(from string, body json.RawMessage) (any, error) {
+	var c Chat
+	if err := json.Unmarshal(body, &c); err != nil {
+		return nil, err
+	}
+	if f.opts.Chat && f.OnChat != nil {
+		// The sending server is the ally its connection authenticated as,
+		// whatever the message claims.
+		c.Server = from
+		f.OnChat(c)
+	}
+	return nil, nil
+}
+
+func (f *Federation) serveGuild(from string, body json.RawMessage) (any, error) {
+	var a GuildAnnouncement
+	if err := json.Unmarshal(body, &a); err != nil {
+		return nil, err
+	}
+	if f.OnGuildAnnouncement == nil {
+		return nil, nil
+	}
+	a.Server = from
+	for _, g := range f.opts.Guilds {
+		if g.Peer == from && g.PeerGuildID == a.GuildID {
+			f.OnGuildAnnouncement(g.GuildID, a)
+		}
+	}
+	return nil, nil
+}
+
+func (f *Federation) servePresence(string, json.RawMessage) (any, error) {
+	if !f.opts.Presence {
+		return nil, errPresenceNotShared
+	}
+	p := Presence{Server: f.opts.Name, Players: []string{}}
+	if f.Players != nil {
+		p.Players = f.Players()
+	}
+	return p, nil
+}
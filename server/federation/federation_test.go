@@ -0,0 +1,168 @@
+package federation
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	cfg "erupe-ce/config"
+)
+
+// newPair returns two federated servers named a and b, listening but not
+// yet connected so that their callbacks can be set first.
+func newPair(t *testing.T, optsA, optsB cfg.FederationOptions) (*Federation, *Federation) {
+	t.Helper()
+	optsA.Name, optsB.Name = "a", "b"
+	optsA.Allies = []cfg.FederationAlly{{Name: "b", Secret: "ab"}}
+	optsB.Allies = []cfg.FederationAlly{{Name: "a", Secret: "ab"}}
+	a, b := New(optsA, nil), New(optsB, nil)
+	t.Cleanup(a.Close)
+	t.Cleanup(b.Close)
+	for _, f := range []*Federation{a, b} {
+		if err := f.bus.Listen("127.0.0.1:0"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return a, b
+}
+
+// connect connects a and b both ways.
+func connect(t *testing.T, a, b *Federation) {
+	t.Helper()
+	a.bus.ConnectNode("b", b.bus.Addr().String())
+	b.bus.ConnectNode("a", a.bus.Addr().String())
+	deadline := time.Now().Add(5 * time.Second)
+	for len(a.Peers()) == 0 || len(b.Peers()) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("servers did not connect")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestSendChat(t *testing.T) {
+	a, b := newPair(t, cfg.FederationOptions{Chat: true}, cfg.FederationOptions{Chat: true})
+	got := make(chan Chat, 1)
+	b.OnChat = func(c Chat) { got <- c }
+	connect(t, a, b)
+
+	a.SendChat("Alice", "hello")
+	select {
+	case c := <-got:
+		if c != (Chat{Server: "a", Sender: "Alice", Message: "hello"}) {
+			t.Errorf("received %+v", c)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("chat not delivered")
+	}
+}
+
+func TestSendChat_AllyCannotImpersonate(t *testing.T) {
+	b := New(cfg.FederationOptions{Name: "b", Chat: true, Allies: []cfg.FederationAlly{
+		{Name: "a", Secret: "ab"},
+		{Name: "c", Secret: "cb"},
+	}}, nil)
+	t.Cleanup(b.Close)
+	if err := b.bus.Listen("127.0.0.1:0"); err != nil {
+		t.Fatal(err)
+	}
+	got := make(chan Chat, 1)
+	b.OnChat = func(c Chat) { got <- c }
+
+	// c federates under a's name, but only knows the secret it shares with b.
+	impostor := New(cfg.FederationOptions{Name: "a", Chat: true, Allies: []cfg.FederationAlly{{Name: "b", Secret: "cb"}}}, nil)
+	t.Cleanup(impostor.Close)
+	impostor.bus.ConnectNode("b", b.bus.Addr().String())
+	time.Sleep(200 * time.Millisecond)
+	impostor.SendChat("Mallory", "hello")
+	select {
+	case c := <-got:
+		t.Errorf("impostor's chat delivered: %+v", c)
+	case <-time.After(200 * time.Millisecond):
+	}
+}
+
+func TestSendChat_Disabled(t *testing.T) {
+	a, b := newPair(t, cfg.FederationOptions{Chat: true}, cfg.FederationOptions{})
+	got := make(chan Chat, 1)
+	b.OnChat = func(c Chat) { got <- c }
+	a.OnChat = func(c Chat) { got <- c }
+	connect(t, a, b)
+
+	a.SendChat("Alice", "hello")
+	b.SendChat("Bob", "hello")
+	select {
+	case c := <-got:
+		t.Errorf("chat delivered with sharing disabled: %+v", c)
+	case <-time.After(200 * time.Millisecond):
+	}
+}
+
+func TestAnnounceGuild(t *testing.T) {
+	a, b := newPair(t,
+		cfg.FederationOptions{Guilds: []cfg.FederationGuild{{GuildID: 5, Peer: "b", PeerGuildID: 50}}},
+		cfg.FederationOptions{Guilds: []cfg.FederationGuild{
+			{GuildID: 50, Peer: "a", PeerGuildID: 5},
+			{GuildID: 60, Peer: "c", PeerGuildID: 5},
+		}},
+	)
+	got := make(chan uint32, 2)
+	b.OnGuildAnnouncement = func(guildID uint32, a GuildAnnouncement) {
+		if a.Server != "a" || a.GuildName != "Hunters" || a.Title != "Raid tonight" {
+			t.Errorf("announcement = %+v", a)
+		}
+		got <- guildID
+	}
+	connect(t, a, b)
+
+	// Guild 6 is not linked, so nothing of it leaves a.
+	a.AnnounceGuild(6, "Others", "Alice", "Secret")
+	a.AnnounceGuild(5, "Hunters", "Alice", "Raid tonight")
+	select {
+	case guildID := <-got:
+		if guildID != 50 {
+			t.Errorf("delivered to guild %d, want 50", guildID)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("announcement not delivered")
+	}
+	select {
+	case guildID := <-got:
+		t.Errorf("also delivered to guild %d", guildID)
+	case <-time.After(200 * time.Millisecond):
+	}
+}
+
+func TestPresence(t *testing.T) {
+	a, b := newPair(t, cfg.FederationOptions{Presence: true}, cfg.FederationOptions{Presence: true})
+	b.Players = func() []string { return []string{"Bob", "Carol"} }
+	connect(t, a, b)
+
+	got := a.Presence()
+	if len(got) != 1 || got[0].Server != "b" || !slices.Equal(got[0].Players, []string{"Bob", "Carol"}) {
+		t.Errorf("Presence() = %+v", got)
+	}
+	// a shares no players function, so it answers with nobody online.
+	if got := b.Presence(); len(got) != 1 || got[0].Server != "a" || len(got[0].Players) != 0 {
+		t.Errorf("Presence() from b = %+v", got)
+	}
+}
+
+func TestPresence_NotShared(t *testing.T) {
+	a, b := newPair(t, cfg.FederationOptions{Presence: true}, cfg.FederationOptions{})
+	b.Players = func() []string { return []string{"Bob"} }
+	connect(t, a, b)
+	if got := a.Presence(); len(got) != 0 {
+		t.Errorf("Presence() = %+v, want none", got)
+	}
+}
+
+func TestFederation_Nil(t *testing.T) {
+	var f *Federation
+	f.SendChat("Alice", "hello")
+	f.AnnounceGuild(1, "Hunters", "Alice", "Raid tonight")
+	f.Close()
+	if f.Presence() != nil || f.Peers() != nil || f.Name() != "" {
+		t.Error("nil Federation shared something")
+	}
+}
@@ -0,0 +1,4 @@
+// Package logging builds the server's zap logger from the Logging config
+// section, routing each subsystem to its own level, format and output, and
+// rotates log files by size and age.
+package logging
@@ -0,0 +1,172 @@
+package logging
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	cfg "erupe-ce/config"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// New builds a logger from c. Entries from a logger named after a
+// subsystem, or after a numbered instance of one such as "channel-3", use
+// that subsystem's overrides, as do entries from its descendants. The
+// returned function flushes and closes the log files.
+func New(c cfg.LoggingOptions) (*zap.Logger, func() error, error) {
+	b := &builder{opts: c, outputs: make(map[string]zapcore.WriteSyncer)}
+	def, err := b.core(c.Level, c.Format, c.Output)
+	if err != nil {
+		_ = b.close()
+		return nil, nil, err
+	}
+	subs := make(map[string]zapcore.Core, len(c.Subsystems))
+	for name, t := range c.Subsystems {
+		core, err := b.core(inherit(t.Level, c.Level), inherit(t.Format, c.Format), inherit(t.Output, c.Output))
+		if err != nil {
+			_ = b.close()
+			return nil, nil, fmt.Errorf("subsystem %s: %w", name, err)
+		}
+		subs[name] = core
+	}
+	logger := zap.New(&routingCore{def: def, subs: subs}, zap.AddCaller(), zap.AddStacktrace(zapcore.ErrorLevel))
+	return logger, b.close, nil
+}
+
+func inherit(v, parent string) string {
+	if v == "" {
+		return parent
+	}
+	return v
+}
+
+// builder creates cores, sharing one writer between every core that logs to
+// the same output.
+type builder struct {
+	opts    cfg.LoggingOptions
+	outputs map[string]zapcore.WriteSyncer
+	closers []io.Closer
+}
+
+func (b *builder) core(level, format, output string) (zapcore.Core, error) {
+	lvl, err := zapcore.ParseLevel(level)
+	if err != nil {
+		return nil, err
+	}
+	var enc zapcore.Encoder
+	switch format {
+	case "console":
+		enc = zapcore.NewConsoleEncoder(zap.NewDevelopmentEncoderConfig())
+	case "json":
+		ec := zap.NewProductionEncoderConfig()
+		ec.EncodeTime = zapcore.ISO8601TimeEncoder
+		enc = zapcore.NewJSONEncoder(ec)
+	default:
+		return nil, fmt.Errorf("unknown log format %q", format)
+	}
+	ws, err := b.output(output)
+	if err != nil {
+		return nil, err
+	}
+	return zapcore.NewCore(enc, ws, lvl), nil
+}
+
+func (b *builder) output(name string) (zapcore.WriteSyncer, error) {
+	if ws, ok := b.outputs[name]; ok {
+		return ws, nil
+	}
+	var ws zapcore.WriteSyncer
+	switch name {
+	case "stderr":
+		ws = zapcore.Lock(os.Stderr)
+	case "stdout":
+		ws = zapcore.Lock(os.Stdout)
+	default:
+		f, err := openRotatingFile(name,
+			int64(b.opts.MaxSize)<<20,
+			time.Duration(b.opts.MaxAge)*time.Hour,
+			b.opts.MaxBackups,
+		)
+		if err != nil {
+			return nil, err
+		}
+		b.closers = append(b.closers, f)
+		ws = f
+	}
+	b.outputs[name] = ws
+	return ws, nil
+}
+
+func (b *builder) close() error {
+	var errs []error
+	for _, c := range b.closers {
+		errs = append(errs, c.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// routingCore sends each entry to the core of the subsystem its logger is
+// named after, or to def.
+type routingCore struct {
+	def  zapcore.Core
+	subs map[string]zapcore.Core
+}
+
+// coreFor finds the subsystem core for a logger name such as
+// "main.channel-1.127.0.0.1:54321", preferring the innermost match.
+func (c *routingCore) coreFor(loggerName string) zapcore.Core {
+	names := strings.Split(loggerName, ".")
+	for i := len(names) - 1; i >= 0; i-- {
+		name := names[i]
+		if core, ok := c.subs[name]; ok {
+			return core
+		}
+		if j := strings.LastIndexByte(name, '-'); j > 0 {
+			if core, ok := c.subs[name[:j]]; ok {
+				return core
+			}
+		}
+	}
+	return c.def
+}
+
+func (c *routingCore) Enabled(lvl zapcore.Level) bool {
+	if c.def.Enabled(lvl) {
+		return true
+	}
+	for _, core := range c.subs {
+		if core.Enabled(lvl) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *routingCore) With(fields []zapcore.Field) zapcore.Core {
+	subs := make(map[string]zapcore.Core, len(c.subs))
+	for name, core := range c.subs {
+		subs[name] = core.With(fields)
+	}
+	return &routingCore{def: c.def.With(fields), subs: subs}
+}
+
+func (c *routingCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	return c.coreFor(ent.LoggerName).Check(ent, ce)
+}
+
+func (c *routingCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	return c.coreFor(ent.LoggerName).Write(ent, fields)
+}
+
+func (c *routingCore) Sync() error {
+	errs := []error{c.def.Sync()}
+	for _, core := range c.subs {
+		errs = append(errs, core.Sync())
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,97 @@
+package logging
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	cfg "erupe-ce/config"
+)
+
+func readLines(t *testing.T, path string) []string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.Split(strings.TrimSpace(string(data)), "\n")
+}
+
+func TestNew_RoutesSubsystems(t *testing.T) {
+	dir := t.TempDir()
+	mainLog := filepath.Join(dir, "erupe.log")
+	channelLog := filepath.Join(dir, "channel.log")
+	logger, closeLogs, err := New(cfg.LoggingOptions{
+		Level:  "info",
+		Format: "json",
+		Output: mainLog,
+		Subsystems: map[string]cfg.LogTarget{
+			"channel": {Level: "warn", Output: channelLog},
+			"capture": {Level: "debug"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	root := logger.Named("main")
+
+	root.Info("main info")
+	root.Debug("main debug")
+	channel := root.Named("channel-2").Named("127.0.0.1:5000")
+	channel.Info("channel info")
+	channel.Warn("channel warn")
+	channel.Named("capture").Debug("capture debug")
+	_ = logger.Sync()
+	if err := closeLogs(); err != nil {
+		t.Fatalf("close error = %v", err)
+	}
+
+	mainLines := readLines(t, mainLog)
+	if len(mainLines) != 2 || !strings.Contains(mainLines[0], "main info") || !strings.Contains(mainLines[1], "capture debug") {
+		t.Errorf("main log = %q, want main info and capture debug", mainLines)
+	}
+	var entry map[string]any
+	if err := json.Unmarshal([]byte(mainLines[0]), &entry); err != nil {
+		t.Errorf("main log is not JSON: %v", err)
+	}
+
+	channelLines := readLines(t, channelLog)
+	if len(channelLines) != 1 || !strings.Contains(channelLines[0], "channel warn") {
+		t.Errorf("channel log = %q, want only the warning", channelLines)
+	}
+}
+
+func TestNew_WithFieldsReachSubsystems(t *testing.T) {
+	dir := t.TempDir()
+	signLog := filepath.Join(dir, "sign.log")
+	logger, closeLogs, err := New(cfg.LoggingOptions{
+		Level:      "info",
+		Format:     "json",
+		Output:     filepath.Join(dir, "erupe.log"),
+		Subsystems: map[string]cfg.LogTarget{"sign": {Output: signLog}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger.With().Named("sign").Sugar().With("user", "alice").Info("login")
+	_ = closeLogs()
+
+	lines := readLines(t, signLog)
+	if len(lines) != 1 || !strings.Contains(lines[0], `"user":"alice"`) {
+		t.Errorf("sign log = %q, want the login with its field", lines)
+	}
+}
+
+func TestNew_InvalidOptions(t *testing.T) {
+	for _, c := range []cfg.LoggingOptions{
+		{Level: "loud", Format: "console", Output: "stderr"},
+		{Level: "info", Format: "xml", Output: "stderr"},
+		{Level: "info", Format: "console", Output: "stderr", Subsystems: map[string]cfg.LogTarget{"api": {Level: "loud"}}},
+	} {
+		if _, _, err := New(c); err == nil {
+			t.Errorf("New(%+v) succeeded, want an error", c)
+		}
+	}
+}
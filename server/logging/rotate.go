@@ -0,0 +1,132 @@
+package logging
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// backupTimeFormat names rotated files so they sort oldest first.
+const backupTimeFormat = "20060102-150405.000"
+
+// rotatingFile is a log file that is renamed aside and started over once it
+// grows past maxSize bytes or has been written to for maxAge. Only the
+// newest maxBackups rotated files are kept.
+type rotatingFile struct {
+	mu         sync.Mutex
+	path       string
+	maxSize    int64         // 0 for no limit
+	maxAge     time.Duration // 0 for no limit
+	maxBackups int           // 0 to keep all
+	now        func() time.Time
+
+	f      *os.File
+	size   int64
+	opened time.Time
+}
+
+func openRotatingFile(path string, maxSize int64, maxAge time.Duration, maxBackups int) (*rotatingFile, error) {
+	r := &rotatingFile{
+		path:       path,
+		maxSize:    maxSize,
+		maxAge:     maxAge,
+		maxBackups: maxBackups,
+		now:        time.Now,
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// open opens the log file for appending, picking up the size of anything
+// already in it.
+func (r *rotatingFile) open() error {
+	f, err := os.OpenFile(r.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return err
+	}
+	r.f, r.size, r.opened = f, info.Size(), r.now()
+	return nil
+}
+
+func (r *rotatingFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.due(len(p)) {
+		if err := r.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := r.f.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+// due reports whether the file must be rotated before writing n more
+// bytes. An empty file is never rotated, so an entry larger than maxSize
+// is still written.
+func (r *rotatingFile) due(n int) bool {
+	if r.size == 0 {
+		return false
+	}
+	if r.maxSize > 0 && r.size+int64(n) > r.maxSize {
+		return true
+	}
+	return r.maxAge > 0 && r.now().Sub(r.opened) >= r.maxAge
+}
+
+func (r *rotatingFile) rotate() error {
+	if err := r.f.Close(); err != nil {
+		return err
+	}
+	ext := filepath.Ext(r.path)
+	backup := strings.TrimSuffix(r.path, ext) + "-" + r.now().Format(backupTimeFormat) + ext
+	if err := os.Rename(r.path, backup); err != nil {
+		return err
+	}
+	if err := r.open(); err != nil {
+		return err
+	}
+	r.prune()
+	return nil
+}
+
+// prune deletes the oldest rotated files beyond maxBackups.
+func (r *rotatingFile) prune() {
+	if r.maxBackups <= 0 {
+		return
+	}
+	ext := filepath.Ext(r.path)
+	backups, err := filepath.Glob(strings.TrimSuffix(r.path, ext) + "-*" + ext)
+	if err != nil || len(backups) <= r.maxBackups {
+		return
+	}
+	slices.Sort(backups)
+	for _, b := range backups[:len(backups)-r.maxBackups] {
+		_ = os.Remove(b)
+	}
+}
+
+func (r *rotatingFile) Sync() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.f.Sync()
+}
+
+func (r *rotatingFile) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.f.Close()
+}
@@ -0,0 +1,86 @@
+package logging
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRotatingFile_RotatesBySize(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "erupe.log")
+	r, err := openRotatingFile(path, 10, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	r.now = func() time.Time { clock = clock.Add(time.Second); return clock }
+
+	for _, line := range []string{"12345\n", "12345\n", "12345678901234\n"} {
+		if _, err := r.Write([]byte(line)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_ = r.Close()
+
+	backups, _ := filepath.Glob(filepath.Join(dir, "erupe-*.log"))
+	if len(backups) != 2 {
+		t.Fatalf("backups = %v, want 2", backups)
+	}
+	data, _ := os.ReadFile(path)
+	if string(data) != "12345678901234\n" {
+		t.Errorf("current log = %q, want the oversized entry on its own", data)
+	}
+}
+
+func TestRotatingFile_RotatesByAge(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "erupe.log")
+	clock := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	r, err := openRotatingFile(path, 0, time.Hour, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.now = func() time.Time { return clock }
+	r.opened = clock
+
+	_, _ = r.Write([]byte("first\n"))
+	clock = clock.Add(30 * time.Minute)
+	_, _ = r.Write([]byte("second\n"))
+	clock = clock.Add(30 * time.Minute)
+	_, _ = r.Write([]byte("third\n"))
+	_ = r.Close()
+
+	backup := filepath.Join(dir, "erupe-20240101-010000.000.log")
+	if data, err := os.ReadFile(backup); err != nil || string(data) != "first\nsecond\n" {
+		t.Errorf("backup = %q (err %v), want the first hour", data, err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "third\n" {
+		t.Errorf("current log = %q, want the entry after rotation", data)
+	}
+}
+
+func TestRotatingFile_PrunesBackups(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "erupe.log")
+	r, err := openRotatingFile(path, 1, 0, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	r.now = func() time.Time { clock = clock.Add(time.Second); return clock }
+
+	for i := 0; i < 6; i++ {
+		_, _ = r.Write([]byte("x"))
+	}
+	_ = r.Close()
+
+	backups, _ := filepath.Glob(filepath.Join(dir, "erupe-*.log"))
+	if len(backups) != 2 {
+		t.Fatalf("backups = %v, want the newest 2", backups)
+	}
+	if filepath.Base(backups[1]) != "erupe-20240101-000009.000.log" {
+		t.Errorf("newest backup = %s, want the last rotation", backups[1])
+	}
+}
@@ -105,6 +105,19 @@ func Version(db *sqlx.DB) (int, error) {
 	return version, err
 }
 
+// Latest returns the highest migration number this build embeds, the
+// schema version Migrate brings a database to.
+func Latest() (int, error) {
+	migrations, err := readMigrations()
+	if err != nil {
+		return 0, err
+	}
+	if len(migrations) == 0 {
+		return 0, nil
+	}
+	return migrations[len(migrations)-1].version, nil
+}
+
 type migration struct {
 	version  int
 	filename string
@@ -200,3 +200,17 @@ func TestReadMigrations(t *testing.T) {
 		t.Errorf("first migration filename = %q, want 0001_init.sql", migrations[0].filename)
 	}
 }
+
+func TestLatest(t *testing.T) {
+	migrations, err := readMigrations()
+	if err != nil {
+		t.Fatalf("readMigrations failed: %v", err)
+	}
+	latest, err := Latest()
+	if err != nil {
+		t.Fatalf("Latest failed: %v", err)
+	}
+	if want := migrations[len(migrations)-1].version; latest != want {
+		t.Errorf("Latest() = %d, want %d", latest, want)
+	}
+}
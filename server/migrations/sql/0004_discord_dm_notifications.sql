@@ -0,0 +1,2 @@
+-- Opt-in flag for Discord DM notifications about offline events.
+ALTER TABLE public.users ADD COLUMN IF NOT EXISTS discord_dm boolean NOT NULL DEFAULT false;
@@ -0,0 +1,13 @@
+-- Chat mutes, and an audit log of bans and mutes including those mirrored
+-- from Discord.
+ALTER TABLE public.users ADD COLUMN IF NOT EXISTS muted_until timestamp with time zone;
+
+CREATE TABLE IF NOT EXISTS public.moderation_log (
+    id serial PRIMARY KEY,
+    user_id integer NOT NULL,
+    action text NOT NULL,
+    expires timestamp with time zone,
+    source text NOT NULL,
+    actor text DEFAULT ''::text NOT NULL,
+    created_at timestamp with time zone DEFAULT now() NOT NULL
+);
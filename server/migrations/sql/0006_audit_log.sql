@@ -0,0 +1,25 @@
+-- Append-only record of privileged actions: chat commands, admin API calls
+-- and setup wizard database operations.
+CREATE TABLE IF NOT EXISTS public.audit_log (
+    id bigserial PRIMARY KEY,
+    source text NOT NULL,
+    actor text NOT NULL,
+    action text NOT NULL,
+    target text DEFAULT ''::text NOT NULL,
+    params jsonb DEFAULT '{}'::jsonb NOT NULL,
+    created_at timestamp with time zone DEFAULT now() NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS audit_log_created_at_idx ON public.audit_log (created_at);
+
+CREATE OR REPLACE FUNCTION public.audit_log_append_only() RETURNS trigger
+    LANGUAGE plpgsql
+    AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_log is append-only';
+END;
+$$;
+
+DROP TRIGGER IF EXISTS audit_log_append_only ON public.audit_log;
+CREATE TRIGGER audit_log_append_only BEFORE UPDATE OR DELETE ON public.audit_log
+    FOR EACH ROW EXECUTE FUNCTION public.audit_log_append_only();
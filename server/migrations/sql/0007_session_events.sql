@@ -0,0 +1,16 @@
+-- Session lifecycle events (connect, login, stage moves, quests, disconnect)
+-- for reconstructing what happened to a character at a given time.
+CREATE TABLE IF NOT EXISTS public.session_events (
+    id bigserial PRIMARY KEY,
+    event text NOT NULL,
+    server text NOT NULL,
+    remote text DEFAULT ''::text NOT NULL,
+    user_id integer DEFAULT 0 NOT NULL,
+    char_id integer DEFAULT 0 NOT NULL,
+    detail jsonb DEFAULT '{}'::jsonb NOT NULL,
+    created_at timestamp with time zone DEFAULT now() NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS session_events_char_id_idx ON public.session_events (char_id, created_at);
+CREATE INDEX IF NOT EXISTS session_events_user_id_idx ON public.session_events (user_id, created_at);
+CREATE INDEX IF NOT EXISTS session_events_created_at_idx ON public.session_events (created_at);
@@ -0,0 +1,5 @@
+-- Client mode (config.Mode) the savedata blob was last written in, so saves
+-- can be migrated when the server's ClientMode changes. NULL for saves
+-- written before it was tracked; the server stamps those with its current
+-- mode on startup.
+ALTER TABLE public.characters ADD COLUMN IF NOT EXISTS savedata_mode smallint;
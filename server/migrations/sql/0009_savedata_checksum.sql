@@ -0,0 +1,4 @@
+-- CRC-32 of the savedata blob as last written by the server, checked when the
+-- save is loaded to catch corruption at rest. NULL for saves written before it
+-- was recorded.
+ALTER TABLE public.characters ADD COLUMN IF NOT EXISTS savedata_checksum bigint;
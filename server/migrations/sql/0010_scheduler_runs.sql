@@ -0,0 +1,12 @@
+-- Run history of the scheduler's background jobs, keeping the newest runs
+-- of each job.
+CREATE TABLE IF NOT EXISTS public.scheduler_runs (
+    id bigserial PRIMARY KEY,
+    job text NOT NULL,
+    started_at timestamp with time zone NOT NULL,
+    finished_at timestamp with time zone NOT NULL,
+    status text NOT NULL,
+    error text DEFAULT ''::text NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS scheduler_runs_job_idx ON public.scheduler_runs (job, id);
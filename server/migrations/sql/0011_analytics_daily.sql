@@ -0,0 +1,9 @@
+-- Daily rollups of the economy and activity metrics, one row per day,
+-- metric and key.
+CREATE TABLE IF NOT EXISTS public.analytics_daily (
+    day date NOT NULL,
+    metric text NOT NULL,
+    key text NOT NULL,
+    value bigint NOT NULL,
+    PRIMARY KEY (day, metric, key)
+);
@@ -0,0 +1,3 @@
+-- Time a distribution can first be claimed from, so rollouts can be
+-- inserted ahead of their event. NULL for distributions claimable at once.
+ALTER TABLE public.distribution ADD COLUMN IF NOT EXISTS starts timestamp with time zone;
@@ -0,0 +1,10 @@
+-- Fold the moderation log into the audit log, so bans and mutes from every
+-- source are listed alongside the other privileged actions.
+INSERT INTO public.audit_log (source, actor, action, target, params, created_at)
+    SELECT source, actor, 'moderation:' || action, user_id::text,
+        CASE WHEN expires IS NULL THEN '{}'::jsonb ELSE jsonb_build_object('expires', expires) END,
+        created_at
+    FROM public.moderation_log
+    ORDER BY id;
+
+DROP TABLE IF EXISTS public.moderation_log;
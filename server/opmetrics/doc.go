@@ -0,0 +1,4 @@
+// Package opmetrics records per-opcode packet handler latency, error and
+// payload size metrics for the channel servers, served by the API's
+// /metrics endpoint and summarized periodically in the log.
+package opmetrics
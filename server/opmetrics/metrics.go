@@ -0,0 +1,283 @@
+package opmetrics
+
+import (
+	"cmp"
+	"fmt"
+	"io"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"erupe-ce/network"
+	"erupe-ce/network/pcap"
+
+	"go.uber.org/zap"
+)
+
+// Buckets are the upper bounds of the handler duration histogram. Durations
+// above the last bound are only counted in the total.
+var Buckets = []time.Duration{
+	time.Millisecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	25 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	5 * time.Second,
+}
+
+// Registry accumulates handler metrics by opcode. It is safe for concurrent
+// use by every session of every channel server.
+type Registry struct {
+	ops         sync.Map // network.PacketID → *counters
+	slowQueries atomic.Uint64
+	rejected    sync.Map // reason → *atomic.Uint64
+	invalid     sync.Map // reason → *atomic.Uint64
+}
+
+type counters struct {
+	count   atomic.Uint64
+	errors  atomic.Uint64
+	bytes   atomic.Uint64
+	nanos   atomic.Uint64
+	buckets []atomic.Uint64 // Non-cumulative, one per bound in Buckets
+}
+
+// OpcodeStats is a snapshot of the metrics of one opcode.
+type OpcodeStats struct {
+	Opcode       network.PacketID `json:"-"`
+	Name         string           `json:"opcode"`
+	Count        uint64           `json:"count"`
+	Errors       uint64           `json:"errors"`
+	Bytes        uint64           `json:"bytes"`
+	TotalSeconds float64          `json:"total_seconds"`
+	Buckets      []uint64         `json:"buckets"` // Cumulative counts for each bound in Buckets
+}
+
+// New creates an empty Registry.
+func New() *Registry {
+	return &Registry{}
+}
+
+// Observe records one handled packet of size bytes that took d. failed
+// marks a handler that panicked or answered with a failure ACK.
+func (r *Registry) Observe(opcode network.PacketID, d time.Duration, size int, failed bool) {
+	c, ok := r.ops.Load(opcode)
+	if !ok {
+		c, _ = r.ops.LoadOrStore(opcode, &counters{buckets: make([]atomic.Uint64, len(Buckets))})
+	}
+	ctr := c.(*counters)
+	ctr.count.Add(1)
+	ctr.bytes.Add(uint64(size))
+	ctr.nanos.Add(uint64(d))
+	if failed {
+		ctr.errors.Add(1)
+	}
+	if i, _ := slices.BinarySearch(Buckets, d); i < len(Buckets) {
+		ctr.buckets[i].Add(1)
+	}
+}
+
+// SlowQuery counts a database statement that exceeded the slow query
+// threshold.
+func (r *Registry) SlowQuery() {
+	r.slowQueries.Add(1)
+}
+
+// SlowQueries returns the number of slow database statements so far.
+func (r *Registry) SlowQueries() uint64 {
+	return r.slowQueries.Load()
+}
+
+// ConnRejected counts a connection refused by the per-IP connection limits
+// for reason.
+func (r *Registry) ConnRejected(reason string) {
+	c, ok := r.rejected.Load(reason)
+	if !ok {
+		c, _ = r.rejected.LoadOrStore(reason, new(atomic.Uint64))
+	}
+	c.(*atomic.Uint64).Add(1)
+}
+
+// ConnsRejected returns the number of refused connections by reason.
+func (r *Registry) ConnsRejected() map[string]uint64 {
+	return loadCounts(&r.rejected)
+}
+
+// loadCounts reads a map of counters keyed by label.
+func loadCounts(m *sync.Map) map[string]uint64 {
+	out := make(map[string]uint64)
+	m.Range(func(k, v any) bool {
+		out[k.(string)] = v.(*atomic.Uint64).Load()
+		return true
+	})
+	return out
+}
+
+// PacketRejected counts a packet that failed validation before its handler
+// for reason.
+func (r *Registry) PacketRejected(reason string) {
+	c, ok := r.invalid.Load(reason)
+	if !ok {
+		c, _ = r.invalid.LoadOrStore(reason, new(atomic.Uint64))
+	}
+	c.(*atomic.Uint64).Add(1)
+}
+
+// PacketsRejected returns the number of invalid packets by reason.
+func (r *Registry) PacketsRejected() map[string]uint64 {
+	return loadCounts(&r.invalid)
+}
+
+// Snapshot returns the metrics of every opcode seen so far, ordered by
+// opcode.
+func (r *Registry) Snapshot() []OpcodeStats {
+	var stats []OpcodeStats
+	r.ops.Range(func(k, v any) bool {
+		op, ctr := k.(network.PacketID), v.(*counters)
+		s := OpcodeStats{
+			Opcode:       op,
+			Name:         op.String(),
+			Count:        ctr.count.Load(),
+			Errors:       ctr.errors.Load(),
+			Bytes:        ctr.bytes.Load(),
+			TotalSeconds: time.Duration(ctr.nanos.Load()).Seconds(),
+			Buckets:      make([]uint64, len(Buckets)),
+		}
+		var cumulative uint64
+		for i := range ctr.buckets {
+			cumulative += ctr.buckets[i].Load()
+			s.Buckets[i] = cumulative
+		}
+		stats = append(stats, s)
+		return true
+	})
+	slices.SortFunc(stats, func(a, b OpcodeStats) int { return cmp.Compare(a.Opcode, b.Opcode) })
+	return stats
+}
+
+// WritePrometheus writes the metrics in the Prometheus text exposition
+// format.
+func (r *Registry) WritePrometheus(w io.Writer) error {
+	stats := r.Snapshot()
+	bw := &errWriter{w: w}
+	bw.printf("# HELP erupe_handler_duration_seconds Packet handler duration by opcode.\n")
+	bw.printf("# TYPE erupe_handler_duration_seconds histogram\n")
+	for _, s := range stats {
+		for i, bound := range Buckets {
+			bw.printf("erupe_handler_duration_seconds_bucket{opcode=%q,le=\"%g\"} %d\n", s.Name, bound.Seconds(), s.Buckets[i])
+		}
+		bw.printf("erupe_handler_duration_seconds_bucket{opcode=%q,le=\"+Inf\"} %d\n", s.Name, s.Count)
+		bw.printf("erupe_handler_duration_seconds_sum{opcode=%q} %g\n", s.Name, s.TotalSeconds)
+		bw.printf("erupe_handler_duration_seconds_count{opcode=%q} %d\n", s.Name, s.Count)
+	}
+	bw.printf("# HELP erupe_handler_errors_total Packet handlers that panicked or answered with a failure ACK.\n")
+	bw.printf("# TYPE erupe_handler_errors_total counter\n")
+	for _, s := range stats {
+		bw.printf("erupe_handler_errors_total{opcode=%q} %d\n", s.Name, s.Errors)
+	}
+	bw.printf("# HELP erupe_handler_payload_bytes_total Bytes of packet payload received by opcode.\n")
+	bw.printf("# TYPE erupe_handler_payload_bytes_total counter\n")
+	for _, s := range stats {
+		bw.printf("erupe_handler_payload_bytes_total{opcode=%q} %d\n", s.Name, s.Bytes)
+	}
+	bw.printf("# HELP erupe_db_slow_queries_total Database statements slower than Database.SlowQueryThreshold.\n")
+	bw.printf("# TYPE erupe_db_slow_queries_total counter\n")
+	bw.printf("erupe_db_slow_queries_total %d\n", r.SlowQueries())
+	bw.printf("# HELP erupe_capture_records_dropped_total Packets left out of captures because the capture writer fell behind.\n")
+	bw.printf("# TYPE erupe_capture_records_dropped_total counter\n")
+	bw.printf("erupe_capture_records_dropped_total %d\n", pcap.DroppedRecords())
+	bw.counters("erupe_connections_rejected_total", "Connections refused by ConnectionLimits, by reason.", r.ConnsRejected())
+	bw.counters("erupe_packets_rejected_total", "Packets that failed validation before their handler, by reason.", r.PacketsRejected())
+	return bw.err
+}
+
+type errWriter struct {
+	w   io.Writer
+	err error
+}
+
+// counters writes a counter labelled by reason, in label order.
+func (e *errWriter) counters(name, help string, counts map[string]uint64) {
+	reasons := make([]string, 0, len(counts))
+	for reason := range counts {
+		reasons = append(reasons, reason)
+	}
+	slices.Sort(reasons)
+	e.printf("# HELP %s %s\n", name, help)
+	e.printf("# TYPE %s counter\n", name)
+	for _, reason := range reasons {
+		e.printf("%s{reason=%q} %d\n", name, reason, counts[reason])
+	}
+}
+
+func (e *errWriter) printf(format string, args ...any) {
+	if e.err == nil {
+		_, e.err = fmt.Fprintf(e.w, format, args...)
+	}
+}
+
+// summaryTop is the number of opcodes listed in each log summary.
+const summaryTop = 5
+
+// StartLogSummary logs the opcodes that spent the most time in their
+// handlers every interval, counting only packets handled since the previous
+// summary. It returns a function that stops the summaries.
+func (r *Registry) StartLogSummary(logger *zap.Logger, interval time.Duration) func() {
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		prev := make(map[network.PacketID]OpcodeStats)
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				r.logSummary(logger, prev)
+			}
+		}
+	}()
+	return func() {
+		close(stop)
+		<-done
+	}
+}
+
+// logSummary logs the busiest opcodes since prev, then updates prev to the
+// current totals.
+func (r *Registry) logSummary(logger *zap.Logger, prev map[network.PacketID]OpcodeStats) {
+	var deltas []OpcodeStats
+	for _, s := range r.Snapshot() {
+		p := prev[s.Opcode]
+		prev[s.Opcode] = s
+		if s.Count == p.Count {
+			continue
+		}
+		deltas = append(deltas, OpcodeStats{
+			Opcode:       s.Opcode,
+			Name:         s.Name,
+			Count:        s.Count - p.Count,
+			Errors:       s.Errors - p.Errors,
+			Bytes:        s.Bytes - p.Bytes,
+			TotalSeconds: s.TotalSeconds - p.TotalSeconds,
+		})
+	}
+	slices.SortFunc(deltas, func(a, b OpcodeStats) int { return cmp.Compare(b.TotalSeconds, a.TotalSeconds) })
+	for _, d := range deltas[:min(summaryTop, len(deltas))] {
+		logger.Info("Handler metrics",
+			zap.String("opcode", d.Name),
+			zap.Uint64("count", d.Count),
+			zap.Uint64("errors", d.Errors),
+			zap.Uint64("bytes", d.Bytes),
+			zap.Duration("avg", time.Duration(d.TotalSeconds/float64(d.Count)*float64(time.Second))),
+			zap.Duration("total", time.Duration(d.TotalSeconds*float64(time.Second))),
+		)
+	}
+}
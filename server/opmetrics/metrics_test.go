@@ -0,0 +1,118 @@
+package opmetrics
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"erupe-ce/network"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestObserveAndSnapshot(t *testing.T) {
+	r := New()
+	r.Observe(network.MSG_SYS_PING, 500*time.Microsecond, 10, false)
+	r.Observe(network.MSG_SYS_PING, 7*time.Millisecond, 10, true)
+	r.Observe(network.MSG_SYS_PING, 10*time.Second, 10, false)
+	r.Observe(network.MSG_SYS_ACK, time.Millisecond, 4, false)
+
+	stats := r.Snapshot()
+	if len(stats) != 2 {
+		t.Fatalf("Snapshot() has %d opcodes, want 2", len(stats))
+	}
+	if stats[0].Opcode > stats[1].Opcode {
+		t.Error("Snapshot() is not ordered by opcode")
+	}
+	var ping OpcodeStats
+	for _, s := range stats {
+		if s.Opcode == network.MSG_SYS_PING {
+			ping = s
+		}
+	}
+	if ping.Count != 3 || ping.Errors != 1 || ping.Bytes != 30 {
+		t.Errorf("ping = %+v, want 3 packets, 1 error, 30 bytes", ping)
+	}
+	// 0.5ms falls in the 1ms bucket, 7ms in the 10ms bucket and 10s in none.
+	want := []uint64{1, 1, 2, 2, 2, 2, 2, 2, 2, 2}
+	for i := range want {
+		if ping.Buckets[i] != want[i] {
+			t.Fatalf("buckets = %v, want %v", ping.Buckets, want)
+		}
+	}
+}
+
+func TestObserve_BucketBoundIsInclusive(t *testing.T) {
+	r := New()
+	r.Observe(network.MSG_SYS_PING, 5*time.Millisecond, 0, false)
+	if b := r.Snapshot()[0].Buckets; b[0] != 0 || b[1] != 1 {
+		t.Errorf("buckets = %v, want the 5ms bucket to count 5ms", b)
+	}
+}
+
+func TestWritePrometheus(t *testing.T) {
+	r := New()
+	r.Observe(network.MSG_SYS_PING, 2*time.Millisecond, 8, true)
+	r.SlowQuery()
+	r.ConnRejected("rate")
+	r.ConnRejected("rate")
+	r.PacketRejected("size")
+
+	var sb strings.Builder
+	if err := r.WritePrometheus(&sb); err != nil {
+		t.Fatalf("WritePrometheus() error = %v", err)
+	}
+	out := sb.String()
+	for _, line := range []string{
+		"# TYPE erupe_handler_duration_seconds histogram",
+		`erupe_handler_duration_seconds_bucket{opcode="MSG_SYS_PING",le="0.001"} 0`,
+		`erupe_handler_duration_seconds_bucket{opcode="MSG_SYS_PING",le="0.005"} 1`,
+		`erupe_handler_duration_seconds_bucket{opcode="MSG_SYS_PING",le="+Inf"} 1`,
+		`erupe_handler_duration_seconds_count{opcode="MSG_SYS_PING"} 1`,
+		`erupe_handler_errors_total{opcode="MSG_SYS_PING"} 1`,
+		`erupe_handler_payload_bytes_total{opcode="MSG_SYS_PING"} 8`,
+		`erupe_db_slow_queries_total 1`,
+		"# TYPE erupe_capture_records_dropped_total counter",
+		`erupe_connections_rejected_total{reason="rate"} 2`,
+		`erupe_packets_rejected_total{reason="size"} 1`,
+	} {
+		if !strings.Contains(out, line+"\n") {
+			t.Errorf("output is missing %q:\n%s", line, out)
+		}
+	}
+}
+
+func TestLogSummary_CountsSincePrevious(t *testing.T) {
+	core, logs := observer.New(zap.InfoLevel)
+	logger := zap.New(core)
+	r := New()
+	prev := make(map[network.PacketID]OpcodeStats)
+
+	r.Observe(network.MSG_SYS_PING, time.Millisecond, 1, false)
+	r.logSummary(logger, prev)
+	if logs.Len() != 1 {
+		t.Fatalf("logged %d summaries, want 1", logs.Len())
+	}
+
+	// Nothing new was handled, so nothing is logged.
+	r.logSummary(logger, prev)
+	if logs.Len() != 1 {
+		t.Fatalf("logged %d summaries after an idle interval, want 1", logs.Len())
+	}
+
+	r.Observe(network.MSG_SYS_PING, time.Millisecond, 1, false)
+	r.Observe(network.MSG_SYS_PING, time.Millisecond, 1, false)
+	r.logSummary(logger, prev)
+	entries := logs.All()
+	if got := entries[len(entries)-1].ContextMap()["count"]; got != uint64(2) {
+		t.Errorf("count = %v, want 2 packets since the previous summary", got)
+	}
+}
+
+func TestStartLogSummary_Stops(t *testing.T) {
+	r := New()
+	stop := r.StartLogSummary(zap.NewNop(), time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+	stop()
+}
@@ -0,0 +1,7 @@
+// Package proxyserver runs Erupe as a man-in-the-middle between a client and
+// another server. Each route accepts the client on a local port, forwards
+// its packets to an upstream server and the replies back, records both
+// directions of every session into a .mhfr capture, and lets hooks rewrite
+// or drop packets in flight. It is the tool for capturing reference
+// behaviour from other servers to compare Erupe against.
+package proxyserver
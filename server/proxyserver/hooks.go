@@ -0,0 +1,84 @@
+package proxyserver
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"slices"
+
+	cfg "erupe-ce/config"
+	"erupe-ce/network/pcap"
+)
+
+// Hook inspects a packet passing through the proxy on a route of the given
+// server kind. It returns the packet to forward, which may be pkt itself or
+// a rewritten copy, or nil to drop it.
+type Hook func(server string, dir pcap.Direction, pkt []byte) []byte
+
+// Handle registers h for the packets with the opcode. Hooks run in the
+// order they were registered, each on the output of the one before. They do
+// not see the packets of pass-through routes.
+func (s *Server) Handle(opcode uint16, h Hook) {
+	s.hooksMu.Lock()
+	defer s.hooksMu.Unlock()
+	s.opcodeHooks[opcode] = append(s.opcodeHooks[opcode], h)
+}
+
+// HandleAll registers h for every packet, including the sign and entrance
+// packets which carry no opcode. It runs before the opcode hooks.
+func (s *Server) HandleAll(h Hook) {
+	s.hooksMu.Lock()
+	defer s.hooksMu.Unlock()
+	s.hooks = append(s.hooks, h)
+}
+
+// rewrite runs the hooks on a packet, returning nil when one drops it.
+func (s *Server) rewrite(server string, dir pcap.Direction, pkt []byte) []byte {
+	s.hooksMu.RLock()
+	hooks := s.hooks
+	if len(pkt) >= 2 {
+		hooks = append(slices.Clip(hooks), s.opcodeHooks[binary.BigEndian.Uint16(pkt)]...)
+	}
+	s.hooksMu.RUnlock()
+	for _, h := range hooks {
+		if pkt = h(server, dir, pkt); pkt == nil {
+			return nil
+		}
+	}
+	return pkt
+}
+
+// rewriteHook turns a configured byte replacement into a Hook.
+func rewriteHook(r cfg.ProxyRewrite) (Hook, error) {
+	find, err := hex.DecodeString(r.Find)
+	if err != nil {
+		return nil, fmt.Errorf("rewrite Find: %w", err)
+	}
+	if len(find) == 0 {
+		return nil, fmt.Errorf("rewrite Find is empty")
+	}
+	replace, err := hex.DecodeString(r.Replace)
+	if err != nil {
+		return nil, fmt.Errorf("rewrite Replace: %w", err)
+	}
+	var dir pcap.Direction
+	switch r.Direction {
+	case "":
+	case "client":
+		dir = pcap.DirClientToServer
+	case "server":
+		dir = pcap.DirServerToClient
+	default:
+		return nil, fmt.Errorf("rewrite Direction %q is not client or server", r.Direction)
+	}
+	return func(server string, d pcap.Direction, pkt []byte) []byte {
+		if r.Server != "" && r.Server != server || dir != 0 && dir != d {
+			return pkt
+		}
+		if len(r.Opcodes) > 0 && (len(pkt) < 2 || !slices.Contains(r.Opcodes, binary.BigEndian.Uint16(pkt))) {
+			return pkt
+		}
+		return bytes.ReplaceAll(pkt, find, replace)
+	}, nil
+}
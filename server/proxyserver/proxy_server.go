@@ -0,0 +1,320 @@
+package proxyserver
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	cfg "erupe-ce/config"
+	"erupe-ce/network"
+	"erupe-ce/network/crypto"
+	"erupe-ce/network/pcap"
+
+	"go.uber.org/zap"
+)
+
+// dialTimeout bounds how long a client waits for the upstream to answer.
+const dialTimeout = 10 * time.Second
+
+// serverTypes maps the route kinds to the server types of their captures.
+var serverTypes = map[string]pcap.ServerType{
+	"sign":     pcap.ServerTypeSign,
+	"entrance": pcap.ServerTypeEntrance,
+	"channel":  pcap.ServerTypeChannel,
+}
+
+// Config holds the dependencies required to initialize a Server.
+type Config struct {
+	Logger      *zap.Logger
+	ErupeConfig *cfg.Config
+}
+
+// Server forwards clients to the upstream servers of the Proxy routes.
+type Server struct {
+	logger      *zap.Logger
+	erupeConfig *cfg.Config
+
+	hooksMu     sync.RWMutex
+	hooks       []Hook
+	opcodeHooks map[uint16][]Hook
+
+	mu             sync.Mutex
+	listeners      []net.Listener
+	conns          map[net.Conn]struct{}
+	isShuttingDown bool
+	wg             sync.WaitGroup
+}
+
+// NewServer creates a new Server.
+func NewServer(config *Config) *Server {
+	return &Server{
+		logger:      config.Logger,
+		erupeConfig: config.ErupeConfig,
+		opcodeHooks: make(map[uint16][]Hook),
+		conns:       make(map[net.Conn]struct{}),
+	}
+}
+
+// Start registers the configured rewrites and listens on every route.
+func (s *Server) Start() error {
+	opts := s.erupeConfig.Proxy
+	for i, r := range opts.Rewrites {
+		h, err := rewriteHook(r)
+		if err != nil {
+			return fmt.Errorf("proxy rewrite %d: %w", i, err)
+		}
+		s.HandleAll(h)
+	}
+	for _, route := range opts.Routes {
+		if _, ok := serverTypes[route.Server]; !ok {
+			s.Shutdown()
+			return fmt.Errorf("proxy route on port %d: unknown server %q", route.Port, route.Server)
+		}
+		cipher, err := network.CipherFor(s.erupeConfig.PacketCrypto, route.Server)
+		if err != nil {
+			s.Shutdown()
+			return err
+		}
+		l, err := net.Listen("tcp", fmt.Sprintf(":%d", route.Port))
+		if err != nil {
+			s.Shutdown()
+			return err
+		}
+		s.mu.Lock()
+		s.listeners = append(s.listeners, l)
+		s.mu.Unlock()
+		s.logger.Info("Proxying", zap.String("server", route.Server), zap.Uint16("port", route.Port), zap.String("upstream", route.Upstream), zap.Bool("passThrough", route.PassThrough))
+		go s.acceptClients(l, route, cipher)
+	}
+	return nil
+}
+
+// Shutdown stops listening and closes the proxied connections.
+func (s *Server) Shutdown() {
+	s.logger.Debug("Shutting down...")
+
+	s.mu.Lock()
+	s.isShuttingDown = true
+	for _, l := range s.listeners {
+		_ = l.Close()
+	}
+	for c := range s.conns {
+		_ = c.Close()
+	}
+	s.mu.Unlock()
+
+	// Wait for the sessions to save their captures.
+	s.wg.Wait()
+}
+
+func (s *Server) acceptClients(l net.Listener, route cfg.ProxyRoute, cipher crypto.Cipher) {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			s.mu.Lock()
+			shutdown := s.isShuttingDown
+			s.mu.Unlock()
+			if shutdown {
+				return
+			}
+			s.logger.Warn("Error accepting client", zap.Error(err))
+			continue
+		}
+		if !s.track(conn) {
+			_ = conn.Close()
+			return
+		}
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			defer s.untrack(conn)
+			s.handleConnection(conn, route, cipher)
+		}()
+	}
+}
+
+// track records conn so Shutdown closes it, reporting false when the
+// server is already shutting down.
+func (s *Server) track(conn net.Conn) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.isShuttingDown {
+		return false
+	}
+	s.conns[conn] = struct{}{}
+	return true
+}
+
+func (s *Server) untrack(conn net.Conn) {
+	s.mu.Lock()
+	delete(s.conns, conn)
+	s.mu.Unlock()
+	_ = conn.Close()
+}
+
+func (s *Server) handleConnection(client net.Conn, route cfg.ProxyRoute, cipher crypto.Cipher) {
+	logger := s.logger.With(zap.String("server", route.Server), zap.String("remoteAddr", client.RemoteAddr().String()))
+	if route.PassThrough {
+		s.relay(client, route, logger)
+		return
+	}
+
+	// Sign and entrance clients open with 8 NULL bytes, which the upstream
+	// expects too. Channel clients start with their first packet.
+	var init []byte
+	if route.Server != "channel" {
+		init = make([]byte, 8)
+		if _, err := io.ReadFull(client, init); err != nil {
+			logger.Warn("Failed to read 8 NULL init", zap.Error(err))
+			return
+		}
+	}
+
+	upstream, err := net.DialTimeout("tcp", route.Upstream, dialTimeout)
+	if err != nil {
+		logger.Warn("Failed to connect to upstream", zap.String("upstream", route.Upstream), zap.Error(err))
+		return
+	}
+	if !s.track(upstream) {
+		_ = upstream.Close()
+		return
+	}
+	defer s.untrack(upstream)
+	if _, err := upstream.Write(init); err != nil {
+		logger.Warn("Failed to initialize upstream", zap.Error(err))
+		return
+	}
+
+	mode := s.erupeConfig.RealClientMode
+	var cc network.Conn = network.NewCipherConn(client, mode, cipher, logger)
+	uc := network.NewCipherConn(upstream, mode, cipher, logger)
+	cc, captureCleanup := s.startCapture(route, cc, client.RemoteAddr())
+	defer captureCleanup()
+
+	logger.Debug("Proxying session", zap.String("upstream", route.Upstream))
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		s.forward(route.Server, pcap.DirServerToClient, uc, cc)
+		_ = client.Close()
+	}()
+	s.forward(route.Server, pcap.DirClientToServer, cc, uc)
+	_ = upstream.Close()
+	<-done
+	logger.Debug("Proxied session ended")
+}
+
+// relay forwards a pass-through route's connection to the upstream as raw
+// bytes, the NULL init included, without decrypting it.
+func (s *Server) relay(client net.Conn, route cfg.ProxyRoute, logger *zap.Logger) {
+	upstream, err := net.DialTimeout("tcp", route.Upstream, dialTimeout)
+	if err != nil {
+		logger.Warn("Failed to connect to upstream", zap.String("upstream", route.Upstream), zap.Error(err))
+		return
+	}
+	if !s.track(upstream) {
+		_ = upstream.Close()
+		return
+	}
+	defer s.untrack(upstream)
+
+	logger.Debug("Relaying session", zap.String("upstream", route.Upstream))
+	if err := network.Relay(client, upstream); err != nil {
+		logger.Debug("Relayed session ended", zap.Error(err))
+		return
+	}
+	logger.Debug("Relayed session ended")
+}
+
+// forward copies packets from src to dst through the hooks until either
+// side fails.
+func (s *Server) forward(server string, dir pcap.Direction, src, dst network.Conn) {
+	for {
+		pkt, err := src.ReadPacket()
+		if err != nil {
+			return
+		}
+		if pkt = s.rewrite(server, dir, pkt); pkt == nil {
+			continue
+		}
+		if err := dst.SendPacket(pkt); err != nil {
+			return
+		}
+	}
+}
+
+// startCapture records the packets the client sends and receives: its
+// packets as they arrived and the upstream's as they were delivered, after
+// any rewrites.
+func (s *Server) startCapture(route cfg.ProxyRoute, conn network.Conn, remoteAddr net.Addr) (network.Conn, func()) {
+	opts := s.erupeConfig.Proxy
+	logger := s.logger.Named("capture")
+	outputDir := opts.OutputDir
+	if outputDir == "" {
+		outputDir = "captures"
+	}
+	if err := os.MkdirAll(outputDir, 0o755); err != nil {
+		logger.Warn("Failed to create capture directory", zap.Error(err))
+		return conn, func() {}
+	}
+
+	now := time.Now()
+	filename := fmt.Sprintf("proxy_%s_%s_%s.mhfr",
+		route.Server,
+		now.Format("20060102_150405"),
+		strings.ReplaceAll(remoteAddr.String(), ":", "_"),
+	)
+	path := filepath.Join(outputDir, filename)
+
+	f, err := os.Create(path)
+	if err != nil {
+		logger.Warn("Failed to create capture file", zap.Error(err), zap.String("path", path))
+		return conn, func() {}
+	}
+
+	flags, err := pcap.CompressionFlag(opts.Compression)
+	if err != nil {
+		logger.Warn("Unknown capture compression, recording uncompressed", zap.Error(err))
+	}
+	startNs := now.UnixNano()
+	hdr := pcap.FileHeader{
+		Version:        pcap.FormatVersion,
+		ServerType:     serverTypes[route.Server],
+		ClientMode:     byte(s.erupeConfig.RealClientMode),
+		SessionStartNs: startNs,
+		Flags:          flags | pcap.FlagChecksums,
+	}
+	meta := pcap.SessionMetadata{
+		ServerVersion: "proxy",
+		Host:          route.Upstream,
+		Port:          int(route.Port),
+		RemoteAddr:    remoteAddr.String(),
+	}
+
+	w, err := pcap.NewWriter(f, hdr, meta)
+	if err != nil {
+		logger.Warn("Failed to initialize capture writer", zap.Error(err))
+		_ = f.Close()
+		return conn, func() {}
+	}
+
+	logger.Info("Capture started", zap.String("file", path))
+
+	rc := pcap.NewRecordingConn(conn, w, startNs, opts.ExcludeOpcodes)
+	cleanup := func() {
+		rc.Close()
+		if err := w.Close(); err != nil {
+			logger.Warn("Failed to flush capture", zap.Error(err))
+		}
+		if err := f.Close(); err != nil {
+			logger.Warn("Failed to close capture file", zap.Error(err))
+		}
+		logger.Info("Capture saved", zap.String("file", path), zap.Uint64("dropped", rc.Dropped()))
+	}
+	return rc, cleanup
+}
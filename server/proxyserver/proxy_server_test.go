@@ -0,0 +1,216 @@
+package proxyserver
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	cfg "erupe-ce/config"
+	"erupe-ce/network"
+	"erupe-ce/network/pcap"
+
+	"go.uber.org/zap"
+)
+
+// startUpstream serves one connection, expecting the 8 NULL bytes when init
+// is set, and answers every packet with the same packet prefixed by 0xFF.
+func startUpstream(t *testing.T, init bool) string {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = l.Close() })
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer func() { _ = conn.Close() }()
+		if init {
+			if _, err := io.ReadFull(conn, make([]byte, 8)); err != nil {
+				return
+			}
+		}
+		cc := network.NewCryptConn(conn, cfg.ZZ, nil)
+		for {
+			pkt, err := cc.ReadPacket()
+			if err != nil {
+				return
+			}
+			if err := cc.SendPacket(append([]byte{0xFF}, pkt...)); err != nil {
+				return
+			}
+		}
+	}()
+	return l.Addr().String()
+}
+
+func newTestServer(t *testing.T, routes []cfg.ProxyRoute, rewrites []cfg.ProxyRewrite) (*Server, string) {
+	t.Helper()
+	dir := t.TempDir()
+	s := NewServer(&Config{
+		Logger: zap.NewNop(),
+		ErupeConfig: &cfg.Config{
+			RealClientMode: cfg.ZZ,
+			Proxy:          cfg.ProxyOptions{Enabled: true, OutputDir: dir, Routes: routes, Rewrites: rewrites},
+		},
+	})
+	return s, dir
+}
+
+func TestProxyForwardsAndRecords(t *testing.T) {
+	upstream := startUpstream(t, false)
+	s, dir := newTestServer(t,
+		[]cfg.ProxyRoute{{Server: "channel", Upstream: upstream}},
+		[]cfg.ProxyRewrite{{Direction: "server", Opcodes: []uint16{0xFF00}, Find: "abcd", Replace: "0102"}},
+	)
+	s.Handle(0x0001, func(server string, dir pcap.Direction, pkt []byte) []byte { return nil })
+	if err := s.Start(); err != nil {
+		t.Fatalf("Start() error: %v", err)
+	}
+
+	conn, err := net.Dial("tcp", s.listeners[0].Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	cc := network.NewCryptConn(conn, cfg.ZZ, nil)
+	if err := cc.SendPacket([]byte{0x00, 0x01, 0x99}); err != nil {
+		t.Fatal(err)
+	}
+	if err := cc.SendPacket([]byte{0x00, 0xAB, 0xCD}); err != nil {
+		t.Fatal(err)
+	}
+	got, err := cc.ReadPacket()
+	if err != nil {
+		t.Fatalf("ReadPacket() error: %v", err)
+	}
+	if want := []byte{0xFF, 0x00, 0x01, 0x02}; !bytes.Equal(got, want) {
+		t.Errorf("client received %X, want %X", got, want)
+	}
+	_ = conn.Close()
+	s.Shutdown()
+
+	files, _ := filepath.Glob(filepath.Join(dir, "proxy_channel_*.mhfr"))
+	if len(files) != 1 {
+		t.Fatalf("captures = %v, want one", files)
+	}
+	f, err := os.Open(files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = f.Close() }()
+	r, err := pcap.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Header.ServerType != pcap.ServerTypeChannel || r.Meta.Host != upstream {
+		t.Errorf("header = %+v, meta = %+v", r.Header, r.Meta)
+	}
+	var records []pcap.PacketRecord
+	for {
+		rec, err := r.ReadPacket()
+		if err != nil {
+			break
+		}
+		records = append(records, rec)
+	}
+	// The dropped packet is recorded, as the client did send it.
+	if len(records) != 3 {
+		t.Fatalf("recorded %d packets, want 3", len(records))
+	}
+	if records[2].Direction != pcap.DirServerToClient || !bytes.Equal(records[2].Payload, got) {
+		t.Errorf("last record = %+v, want the rewritten reply", records[2])
+	}
+}
+
+func TestProxyForwardsSignInit(t *testing.T) {
+	upstream := startUpstream(t, true)
+	s, _ := newTestServer(t, []cfg.ProxyRoute{{Server: "sign", Upstream: upstream}}, nil)
+	if err := s.Start(); err != nil {
+		t.Fatalf("Start() error: %v", err)
+	}
+	defer s.Shutdown()
+
+	conn, err := net.Dial("tcp", s.listeners[0].Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = conn.Close() }()
+	if _, err := conn.Write(make([]byte, 8)); err != nil {
+		t.Fatal(err)
+	}
+	cc := network.NewCryptConn(conn, cfg.ZZ, nil)
+	if err := cc.SendPacket([]byte("DSGN:100")); err != nil {
+		t.Fatal(err)
+	}
+	got, err := cc.ReadPacket()
+	if err != nil {
+		t.Fatalf("ReadPacket() error: %v", err)
+	}
+	if want := append([]byte{0xFF}, "DSGN:100"...); !bytes.Equal(got, want) {
+		t.Errorf("client received %q, want %q", got, want)
+	}
+}
+
+func TestProxyPassThrough(t *testing.T) {
+	upstream := startUpstream(t, true)
+	s, dir := newTestServer(t,
+		[]cfg.ProxyRoute{{Server: "sign", Upstream: upstream, PassThrough: true}},
+		[]cfg.ProxyRewrite{{Find: "4453", Replace: "0000"}},
+	)
+	if err := s.Start(); err != nil {
+		t.Fatalf("Start() error: %v", err)
+	}
+
+	conn, err := net.Dial("tcp", s.listeners[0].Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := conn.Write(make([]byte, 8)); err != nil {
+		t.Fatal(err)
+	}
+	cc := network.NewCryptConn(conn, cfg.ZZ, nil)
+	if err := cc.SendPacket([]byte("DSGN:100")); err != nil {
+		t.Fatal(err)
+	}
+	got, err := cc.ReadPacket()
+	if err != nil {
+		t.Fatalf("ReadPacket() error: %v", err)
+	}
+	// Relayed untouched: the rewrite of "DS" does not apply.
+	if want := append([]byte{0xFF}, "DSGN:100"...); !bytes.Equal(got, want) {
+		t.Errorf("client received %q, want %q", got, want)
+	}
+	_ = conn.Close()
+	s.Shutdown()
+
+	if files, _ := filepath.Glob(filepath.Join(dir, "*.mhfr")); len(files) != 0 {
+		t.Errorf("pass-through route recorded %v", files)
+	}
+}
+
+func TestStartRejectsInvalidConfig(t *testing.T) {
+	tests := []struct {
+		name     string
+		routes   []cfg.ProxyRoute
+		rewrites []cfg.ProxyRewrite
+	}{
+		{"unknown server", []cfg.ProxyRoute{{Server: "api"}}, nil},
+		{"bad hex", nil, []cfg.ProxyRewrite{{Find: "zz"}}},
+		{"empty find", nil, []cfg.ProxyRewrite{{Replace: "00"}}},
+		{"bad direction", nil, []cfg.ProxyRewrite{{Find: "00", Direction: "up"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, _ := newTestServer(t, tt.routes, tt.rewrites)
+			if err := s.Start(); err == nil {
+				s.Shutdown()
+				t.Fatal("Start() succeeded")
+			}
+		})
+	}
+}
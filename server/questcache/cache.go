@@ -0,0 +1,161 @@
+package questcache
+
+import (
+	"container/list"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Cache is a thread-safe, expiring LRU cache for parsed quest file data,
+// bounded by both entry count and total bytes.
+type Cache struct {
+	mu         sync.Mutex
+	entries    map[int]*list.Element
+	lru        *list.List // Front is the most recently used
+	ttl        time.Duration
+	maxEntries int
+	maxBytes   int
+	size       int // Total bytes of cached data
+
+	hits      atomic.Uint64
+	misses    atomic.Uint64
+	evictions atomic.Uint64
+}
+
+type entry struct {
+	questID int
+	data    []byte
+	expiry  time.Time
+}
+
+// Stats is a snapshot of the cache's size and counters.
+type Stats struct {
+	Entries   int    `json:"entries"`
+	Bytes     int    `json:"bytes"`
+	Hits      uint64 `json:"hits"`
+	Misses    uint64 `json:"misses"`
+	Evictions uint64 `json:"evictions"`
+}
+
+// New creates a Cache with the given TTL in seconds. A TTL of 0 disables
+// caching (Get always misses). maxEntries and maxBytes bound the cache,
+// evicting the least recently used quests first; 0 leaves a bound unset.
+func New(ttlSeconds, maxEntries, maxBytes int) *Cache {
+	return &Cache{
+		entries:    make(map[int]*list.Element),
+		lru:        list.New(),
+		ttl:        time.Duration(ttlSeconds) * time.Second,
+		maxEntries: maxEntries,
+		maxBytes:   maxBytes,
+	}
+}
+
+// Get returns cached quest data if it exists and has not expired.
+func (c *Cache) Get(questID int) ([]byte, bool) {
+	if c.ttl <= 0 {
+		c.misses.Add(1)
+		return nil, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.entries[questID]
+	if !ok {
+		c.misses.Add(1)
+		return nil, false
+	}
+	e := el.Value.(*entry)
+	if time.Now().After(e.expiry) {
+		c.remove(el)
+		c.misses.Add(1)
+		return nil, false
+	}
+	c.lru.MoveToFront(el)
+	c.hits.Add(1)
+	return e.data, true
+}
+
+// Put stores quest data in the cache with the configured TTL, evicting the
+// least recently used quests until the cache is within its bounds. Data
+// larger than the byte bound is not cached.
+func (c *Cache) Put(questID int, b []byte) {
+	if c.ttl <= 0 || c.maxBytes > 0 && len(b) > c.maxBytes {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.entries[questID]; ok {
+		c.remove(el)
+	}
+	c.entries[questID] = c.lru.PushFront(&entry{
+		questID: questID,
+		data:    b,
+		expiry:  time.Now().Add(c.ttl),
+	})
+	c.size += len(b)
+	for c.maxEntries > 0 && c.lru.Len() > c.maxEntries || c.maxBytes > 0 && c.size > c.maxBytes {
+		c.remove(c.lru.Back())
+		c.evictions.Add(1)
+	}
+}
+
+// Invalidate removes the given quests from the cache, or every quest if
+// none are given, and returns the number removed.
+func (c *Cache) Invalidate(questIDs ...int) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(questIDs) == 0 {
+		n := c.lru.Len()
+		c.entries = make(map[int]*list.Element)
+		c.lru.Init()
+		c.size = 0
+		return n
+	}
+	n := 0
+	for _, id := range questIDs {
+		if el, ok := c.entries[id]; ok {
+			c.remove(el)
+			n++
+		}
+	}
+	return n
+}
+
+// Purge removes the expired quests, which otherwise hold memory until they
+// are next requested or evicted, and returns the number removed.
+func (c *Cache) Purge() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	n := 0
+	for el := c.lru.Back(); el != nil; {
+		prev := el.Prev()
+		if now.After(el.Value.(*entry).expiry) {
+			c.remove(el)
+			n++
+		}
+		el = prev
+	}
+	return n
+}
+
+// Stats returns the current size and hit, miss and eviction counters.
+func (c *Cache) Stats() Stats {
+	c.mu.Lock()
+	entries, size := c.lru.Len(), c.size
+	c.mu.Unlock()
+	return Stats{
+		Entries:   entries,
+		Bytes:     size,
+		Hits:      c.hits.Load(),
+		Misses:    c.misses.Load(),
+		Evictions: c.evictions.Load(),
+	}
+}
+
+// remove drops el from the cache. Callers must hold c.mu.
+func (c *Cache) remove(el *list.Element) {
+	e := c.lru.Remove(el).(*entry)
+	delete(c.entries, e.questID)
+	c.size -= len(e.data)
+}
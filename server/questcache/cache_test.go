@@ -0,0 +1,171 @@
+package questcache
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCache_GetMiss(t *testing.T) {
+	c := New(60, 0, 0)
+	_, ok := c.Get(999)
+	if ok {
+		t.Error("expected cache miss for unknown quest ID")
+	}
+}
+
+func TestCache_PutGet(t *testing.T) {
+	c := New(60, 0, 0)
+	data := []byte{0xDE, 0xAD}
+	c.Put(1, data)
+
+	got, ok := c.Get(1)
+	if !ok {
+		t.Fatal("expected cache hit")
+	}
+	if len(got) != 2 || got[0] != 0xDE || got[1] != 0xAD {
+		t.Errorf("got %v, want [0xDE 0xAD]", got)
+	}
+}
+
+func TestCache_Expiry(t *testing.T) {
+	c := New(0, 0, 0) // TTL=0 disables caching
+	c.Put(1, []byte{0x01})
+
+	_, ok := c.Get(1)
+	if ok {
+		t.Error("expected cache miss when TTL is 0")
+	}
+}
+
+func TestCache_ExpiryElapsed(t *testing.T) {
+	c := New(0, 0, 0)
+	c.ttl = 50 * time.Millisecond
+	c.Put(1, []byte{0x01})
+
+	// Should hit immediately
+	if _, ok := c.Get(1); !ok {
+		t.Fatal("expected cache hit before expiry")
+	}
+
+	time.Sleep(60 * time.Millisecond)
+
+	// Should miss after expiry
+	if _, ok := c.Get(1); ok {
+		t.Error("expected cache miss after expiry")
+	}
+}
+
+func TestCache_Purge(t *testing.T) {
+	c := New(60, 0, 0)
+	c.Put(1, []byte{0x01, 0x02})
+	c.Put(2, []byte{0x03})
+	c.entries[1].Value.(*entry).expiry = time.Now().Add(-time.Second)
+
+	if n := c.Purge(); n != 1 {
+		t.Errorf("Purge() = %d, want 1", n)
+	}
+	if st := c.Stats(); st.Entries != 1 || st.Bytes != 1 {
+		t.Errorf("Stats() = %+v, want quest 2 only", st)
+	}
+	if _, ok := c.Get(2); !ok {
+		t.Error("expected quest 2 to be kept")
+	}
+}
+
+func TestCache_ConcurrentAccess(t *testing.T) {
+	c := New(60, 0, 0)
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(2)
+		id := i
+		go func() {
+			defer wg.Done()
+			c.Put(id, []byte{byte(id)})
+		}()
+		go func() {
+			defer wg.Done()
+			c.Get(id)
+		}()
+	}
+	wg.Wait()
+}
+
+func TestCache_EvictsLeastRecentlyUsed(t *testing.T) {
+	c := New(60, 2, 0)
+	c.Put(1, []byte{0x01})
+	c.Put(2, []byte{0x02})
+	c.Get(1) // 2 is now the least recently used
+	c.Put(3, []byte{0x03})
+
+	if _, ok := c.Get(2); ok {
+		t.Error("expected quest 2 to be evicted")
+	}
+	for _, id := range []int{1, 3} {
+		if _, ok := c.Get(id); !ok {
+			t.Errorf("expected quest %d to be cached", id)
+		}
+	}
+	if got := c.Stats().Evictions; got != 1 {
+		t.Errorf("Evictions = %d, want 1", got)
+	}
+}
+
+func TestCache_ByteBound(t *testing.T) {
+	c := New(60, 0, 10)
+	c.Put(1, make([]byte, 6))
+	c.Put(2, make([]byte, 6)) // Evicts 1 to stay within 10 bytes
+	c.Put(3, make([]byte, 11))
+
+	if _, ok := c.Get(1); ok {
+		t.Error("expected quest 1 to be evicted")
+	}
+	if _, ok := c.Get(3); ok {
+		t.Error("data larger than the byte bound should not be cached")
+	}
+	if st := c.Stats(); st.Entries != 1 || st.Bytes != 6 {
+		t.Errorf("Stats() = %+v, want 1 entry of 6 bytes", st)
+	}
+}
+
+func TestCache_ReplaceUpdatesSize(t *testing.T) {
+	c := New(60, 0, 0)
+	c.Put(1, make([]byte, 4))
+	c.Put(1, make([]byte, 8))
+	if st := c.Stats(); st.Entries != 1 || st.Bytes != 8 {
+		t.Errorf("Stats() = %+v, want 1 entry of 8 bytes", st)
+	}
+}
+
+func TestCache_Invalidate(t *testing.T) {
+	c := New(60, 0, 0)
+	for id := 1; id <= 3; id++ {
+		c.Put(id, []byte{byte(id)})
+	}
+
+	if n := c.Invalidate(1, 4); n != 1 {
+		t.Errorf("Invalidate(1, 4) = %d, want 1", n)
+	}
+	if _, ok := c.Get(1); ok {
+		t.Error("expected quest 1 to be invalidated")
+	}
+	if n := c.Invalidate(); n != 2 {
+		t.Errorf("Invalidate() = %d, want 2", n)
+	}
+	if st := c.Stats(); st.Entries != 0 || st.Bytes != 0 {
+		t.Errorf("Stats() = %+v, want empty", st)
+	}
+}
+
+func TestCache_HitMissCounters(t *testing.T) {
+	c := New(60, 0, 0)
+	c.Put(1, []byte{0x01})
+	c.Get(1)
+	c.Get(1)
+	c.Get(2)
+
+	st := c.Stats()
+	if st.Hits != 2 || st.Misses != 1 {
+		t.Errorf("Hits = %d, Misses = %d, want 2 and 1", st.Hits, st.Misses)
+	}
+}
@@ -0,0 +1,3 @@
+// Package questcache provides the LRU cache of parsed quest files shared by
+// the channel servers and managed through the API admin endpoints.
+package questcache
@@ -0,0 +1,5 @@
+// Package savedump keeps the SaveDumps directory: timestamped dumps of
+// character saves with a per-character index, rotation, compression of older
+// dumps and a cap on their total size, so support staff can find the save a
+// character had at a given time and restore it.
+package savedump
@@ -0,0 +1,408 @@
+package savedump
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	cfg "erupe-ce/config"
+)
+
+// Dump kinds written by the channel server.
+const (
+	KindSavedata = "savedata"          // Validated save, nullcomp compressed, restored when the stored save is corrupt
+	KindRaw      = "raw-savedata"      // Save payload as the client sent it, decompressed
+	KindRejected = "savedata-rejected" // Save payload that failed validation
+)
+
+// ErrNotFound is returned for a dump that is not in a character's index.
+var ErrNotFound = errors.New("save dump not found")
+
+// indexName is the index file in each character's directory.
+const indexName = "index.json"
+
+// timeLayout is the timestamp in dump file names.
+const timeLayout = "20060102-150405.000000"
+
+// Entry describes one dump in a character's index.
+type Entry struct {
+	File       string    `json:"file"`
+	Kind       string    `json:"kind"`
+	Time       time.Time `json:"time"`
+	Size       int64     `json:"size"`            // Bytes on disk
+	Compressed bool      `json:"compressed"`      // Gzipped on disk
+	Mode       cfg.Mode  `json:"mode,omitempty"`  // Client mode the save was written in
+	Stage      string    `json:"stage,omitempty"` // Stage the character was in
+	Quest      string    `json:"quest,omitempty"` // Quest file the character was on, if in a quest
+}
+
+// Meta is what is recorded about a dump besides its data.
+type Meta struct {
+	Mode  cfg.Mode
+	Stage string
+	Quest string
+}
+
+// Store writes and reads the dumps under SaveDumps.OutputDir. Each character
+// has a directory named by its ID holding its dumps and an index.json listing
+// them oldest first. It is safe for concurrent use.
+type Store struct {
+	opts  cfg.SaveDumpOptions
+	now   func() time.Time
+	mu    sync.Mutex
+	total int64 // Bytes of every dump, -1 until counted
+}
+
+// New creates a Store for the SaveDumps options.
+func New(opts cfg.SaveDumpOptions) *Store {
+	return &Store{opts: opts, now: time.Now, total: -1}
+}
+
+// Enabled reports whether dumps should be written.
+func (s *Store) Enabled() bool {
+	return s != nil && s.opts.Enabled
+}
+
+func (s *Store) dir(charID uint32) string {
+	return filepath.Join(s.opts.OutputDir, strconv.FormatUint(uint64(charID), 10))
+}
+
+// Write dumps data for a character and records it in the character's index.
+// The character's dumps of the same kind are then rotated: those past
+// Retention are deleted and those past CompressAfter gzipped. If every dump
+// together is over MaxTotalMB the oldest are deleted, keeping the newest of
+// each kind for each character. An error after the dump was written is
+// returned with its entry.
+func (s *Store) Write(charID uint32, kind string, data []byte, meta Meta) (Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	dir := s.dir(charID)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return Entry{}, err
+	}
+	idx, err := s.loadIndex(charID)
+	if err != nil {
+		return Entry{}, err
+	}
+	t := s.now()
+	e := Entry{
+		File:  fileName(idx, charID, kind, t),
+		Kind:  kind,
+		Time:  t,
+		Size:  int64(len(data)),
+		Mode:  meta.Mode,
+		Stage: meta.Stage,
+		Quest: meta.Quest,
+	}
+	if err := os.WriteFile(filepath.Join(dir, e.File), data, 0644); err != nil {
+		return Entry{}, err
+	}
+	s.addTotal(e.Size)
+
+	idx, rotateErr := s.rotate(dir, append(idx, e), kind)
+	if err := writeIndex(dir, idx); err != nil {
+		return e, err
+	}
+	return e, errors.Join(rotateErr, s.prune())
+}
+
+// List returns a character's dumps, newest first.
+func (s *Store) List(charID uint32) ([]Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	idx, err := s.loadIndex(charID)
+	if err != nil {
+		return nil, err
+	}
+	slices.Reverse(idx)
+	return idx, nil
+}
+
+// Read returns the data of one of a character's dumps, as it was written.
+func (s *Store) Read(charID uint32, file string) (Entry, []byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	idx, err := s.loadIndex(charID)
+	if err != nil {
+		return Entry{}, nil, err
+	}
+	i := slices.IndexFunc(idx, func(e Entry) bool { return e.File == file })
+	if i < 0 {
+		return Entry{}, nil, ErrNotFound
+	}
+	data, err := s.readEntry(charID, idx[i])
+	return idx[i], data, err
+}
+
+// Latest returns a character's newest dump of kind.
+func (s *Store) Latest(charID uint32, kind string) (Entry, []byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	idx, err := s.loadIndex(charID)
+	if err != nil {
+		return Entry{}, nil, err
+	}
+	for i := len(idx) - 1; i >= 0; i-- {
+		if idx[i].Kind == kind {
+			data, err := s.readEntry(charID, idx[i])
+			return idx[i], data, err
+		}
+	}
+	return Entry{}, nil, ErrNotFound
+}
+
+func (s *Store) readEntry(charID uint32, e Entry) ([]byte, error) {
+	data, err := os.ReadFile(filepath.Join(s.dir(charID), e.File))
+	if err != nil || !e.Compressed {
+		return data, err
+	}
+	r, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", e.File, err)
+	}
+	return io.ReadAll(r)
+}
+
+// rotate deletes and compresses the dumps of kind in idx as Retention and
+// CompressAfter ask, returning the updated index. A dump that cannot be
+// deleted or compressed is kept as it is and tried again on the next write.
+func (s *Store) rotate(dir string, idx []Entry, kind string) ([]Entry, error) {
+	var errs []error
+	out := make([]Entry, 0, len(idx))
+	n := 0
+	for i := len(idx) - 1; i >= 0; i-- {
+		e := idx[i]
+		if e.Kind == kind {
+			n++
+			if s.opts.Retention > 0 && n > s.opts.Retention {
+				err := s.remove(dir, e)
+				if err == nil {
+					continue
+				}
+				errs = append(errs, err)
+			} else if s.opts.CompressAfter > 0 && n > s.opts.CompressAfter && !e.Compressed {
+				if c, err := s.compress(dir, e); err == nil {
+					e = c
+				} else {
+					errs = append(errs, err)
+				}
+			}
+		}
+		out = append(out, e)
+	}
+	slices.Reverse(out)
+	return out, errors.Join(errs...)
+}
+
+// prune deletes the oldest dumps while every dump together is over
+// MaxTotalMB. The newest dump of each kind of each character is kept.
+func (s *Store) prune() error {
+	if s.opts.MaxTotalMB <= 0 {
+		return nil
+	}
+	limit := int64(s.opts.MaxTotalMB) << 20
+	if s.total < 0 {
+		total, err := s.count()
+		if err != nil {
+			return err
+		}
+		s.total = total
+	}
+	if s.total <= limit {
+		return nil
+	}
+
+	type candidate struct {
+		charID uint32
+		entry  Entry
+	}
+	var candidates []candidate
+	indexes := make(map[uint32][]Entry)
+	dirs, err := os.ReadDir(s.opts.OutputDir)
+	if err != nil {
+		return err
+	}
+	for _, d := range dirs {
+		id, err := strconv.ParseUint(d.Name(), 10, 32)
+		if err != nil || !d.IsDir() {
+			continue
+		}
+		charID := uint32(id)
+		idx, err := s.loadIndex(charID)
+		if err != nil {
+			return err
+		}
+		indexes[charID] = idx
+		newest := make(map[string]bool)
+		for i := len(idx) - 1; i >= 0; i-- {
+			if newest[idx[i].Kind] {
+				candidates = append(candidates, candidate{charID, idx[i]})
+			}
+			newest[idx[i].Kind] = true
+		}
+	}
+	slices.SortFunc(candidates, func(a, b candidate) int { return a.entry.Time.Compare(b.entry.Time) })
+
+	var errs []error
+	changed := make(map[uint32]bool)
+	for _, c := range candidates {
+		if s.total <= limit {
+			break
+		}
+		if err := s.remove(s.dir(c.charID), c.entry); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		indexes[c.charID] = slices.DeleteFunc(indexes[c.charID], func(e Entry) bool { return e.File == c.entry.File })
+		changed[c.charID] = true
+	}
+	for charID := range changed {
+		if err := writeIndex(s.dir(charID), indexes[charID]); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// count returns the bytes of every dump on disk.
+func (s *Store) count() (int64, error) {
+	var total int64
+	err := filepath.WalkDir(s.opts.OutputDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || d.Name() == indexName {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		total += info.Size()
+		return nil
+	})
+	return total, err
+}
+
+func (s *Store) addTotal(n int64) {
+	if s.total >= 0 {
+		s.total += n
+	}
+}
+
+func (s *Store) remove(dir string, e Entry) error {
+	if err := os.Remove(filepath.Join(dir, e.File)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	s.addTotal(-e.Size)
+	return nil
+}
+
+// compress gzips a dump, returning its updated entry.
+func (s *Store) compress(dir string, e Entry) (Entry, error) {
+	path := filepath.Join(dir, e.File)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return e, err
+	}
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write(data); err != nil {
+		return e, err
+	}
+	if err := w.Close(); err != nil {
+		return e, err
+	}
+	if err := os.WriteFile(path+".gz", buf.Bytes(), 0644); err != nil {
+		return e, err
+	}
+	if err := os.Remove(path); err != nil {
+		_ = os.Remove(path + ".gz")
+		return e, err
+	}
+	s.addTotal(int64(buf.Len()) - e.Size)
+	e.File += ".gz"
+	e.Size = int64(buf.Len())
+	e.Compressed = true
+	return e, nil
+}
+
+// loadIndex reads a character's index. A directory without one, written
+// before dumps were indexed, is indexed from its <id>_<kind>.bin files.
+func (s *Store) loadIndex(charID uint32) ([]Entry, error) {
+	dir := s.dir(charID)
+	data, err := os.ReadFile(filepath.Join(dir, indexName))
+	if err == nil {
+		var idx []Entry
+		if err := json.Unmarshal(data, &idx); err != nil {
+			return nil, fmt.Errorf("%s: %w", filepath.Join(dir, indexName), err)
+		}
+		return idx, nil
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+
+	files, err := os.ReadDir(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	prefix := fmt.Sprintf("%d_", charID)
+	var idx []Entry
+	for _, f := range files {
+		kind, ok := strings.CutPrefix(f.Name(), prefix)
+		if !ok || f.IsDir() {
+			continue
+		}
+		if kind, ok = strings.CutSuffix(kind, ".bin"); !ok {
+			continue
+		}
+		info, err := f.Info()
+		if err != nil {
+			return nil, err
+		}
+		idx = append(idx, Entry{File: f.Name(), Kind: kind, Time: info.ModTime(), Size: info.Size()})
+	}
+	slices.SortFunc(idx, func(a, b Entry) int { return a.Time.Compare(b.Time) })
+	return idx, nil
+}
+
+// writeIndex replaces a character's index.
+func writeIndex(dir string, idx []Entry) error {
+	if idx == nil {
+		idx = []Entry{}
+	}
+	data, err := json.MarshalIndent(idx, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := filepath.Join(dir, indexName+".tmp")
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filepath.Join(dir, indexName))
+}
+
+// fileName returns a name for a new dump not already used in idx.
+func fileName(idx []Entry, charID uint32, kind string, t time.Time) string {
+	base := fmt.Sprintf("%d_%s_%s", charID, kind, t.UTC().Format(timeLayout))
+	name := base + ".bin"
+	for n := 2; slices.ContainsFunc(idx, func(e Entry) bool { return strings.TrimSuffix(e.File, ".gz") == name }); n++ {
+		name = fmt.Sprintf("%s-%d.bin", base, n)
+	}
+	return name
+}
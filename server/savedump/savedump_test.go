@@ -0,0 +1,127 @@
+package savedump
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	cfg "erupe-ce/config"
+)
+
+// newTestStore returns a Store in a temporary directory whose clock advances
+// a second on every write.
+func newTestStore(t *testing.T, opts cfg.SaveDumpOptions) *Store {
+	opts.Enabled = true
+	opts.OutputDir = t.TempDir()
+	s := New(opts)
+	clock := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	s.now = func() time.Time {
+		clock = clock.Add(time.Second)
+		return clock
+	}
+	return s
+}
+
+func TestWriteRotatesAndCompresses(t *testing.T) {
+	s := newTestStore(t, cfg.SaveDumpOptions{Retention: 3, CompressAfter: 1})
+	for i := byte(1); i <= 5; i++ {
+		if _, err := s.Write(1, KindSavedata, bytes.Repeat([]byte{i}, 100), Meta{Mode: cfg.ZZ, Quest: "23045d0"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := s.Write(1, KindRaw, []byte("raw"), Meta{}); err != nil {
+		t.Fatal(err)
+	}
+
+	list, err := s.List(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 4 {
+		t.Fatalf("%d dumps, want 3 savedata and 1 raw: %+v", len(list), list)
+	}
+	if list[0].Kind != KindRaw || list[0].Compressed {
+		t.Errorf("newest = %+v, want the uncompressed raw dump", list[0])
+	}
+	if list[1].Compressed || !list[2].Compressed || !list[3].Compressed {
+		t.Error("only the newest savedata dump should be uncompressed")
+	}
+	if list[3].Mode != cfg.ZZ || list[3].Quest != "23045d0" {
+		t.Errorf("entry metadata = %+v", list[3])
+	}
+
+	files, _ := os.ReadDir(s.dir(1))
+	if len(files) != 5 {
+		t.Errorf("%d files, want 4 dumps and the index", len(files))
+	}
+
+	e, data, err := s.Read(1, list[3].File)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(e.File, ".gz") || !bytes.Equal(data, bytes.Repeat([]byte{3}, 100)) {
+		t.Errorf("read %s = %x, want the third dump decompressed", e.File, data[:4])
+	}
+	if _, _, err := s.Read(1, "../../etc/passwd"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("unindexed file: err = %v, want ErrNotFound", err)
+	}
+}
+
+func TestLatest(t *testing.T) {
+	s := newTestStore(t, cfg.SaveDumpOptions{})
+	if _, _, err := s.Latest(1, KindSavedata); !errors.Is(err, ErrNotFound) {
+		t.Errorf("no dumps: err = %v, want ErrNotFound", err)
+	}
+	_, _ = s.Write(1, KindSavedata, []byte("old"), Meta{})
+	_, _ = s.Write(1, KindSavedata, []byte("new"), Meta{})
+	_, _ = s.Write(1, KindRejected, []byte("bad"), Meta{})
+	if _, data, err := s.Latest(1, KindSavedata); err != nil || string(data) != "new" {
+		t.Errorf("Latest = %q, %v, want new", data, err)
+	}
+}
+
+func TestPruneKeepsNewestOfEachKind(t *testing.T) {
+	s := newTestStore(t, cfg.SaveDumpOptions{MaxTotalMB: 1})
+	mb := make([]byte, 1<<20)
+	for _, charID := range []uint32{1, 2, 1, 2} {
+		if _, err := s.Write(charID, KindSavedata, mb[:600<<10], Meta{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, charID := range []uint32{1, 2} {
+		list, _ := s.List(charID)
+		if len(list) != 1 {
+			t.Errorf("character %d has %d dumps, want its newest", charID, len(list))
+		}
+	}
+	total, _ := s.count()
+	if total != 2*600<<10 {
+		t.Errorf("total = %d, want the two newest dumps", total)
+	}
+}
+
+func TestLegacyDumpsAreIndexed(t *testing.T) {
+	s := newTestStore(t, cfg.SaveDumpOptions{Retention: 2})
+	dir := s.dir(7)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "7_savedata.bin"), []byte("legacy"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	old := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	_ = os.Chtimes(filepath.Join(dir, "7_savedata.bin"), old, old)
+
+	if _, data, err := s.Latest(7, KindSavedata); err != nil || string(data) != "legacy" {
+		t.Fatalf("Latest = %q, %v, want the legacy dump", data, err)
+	}
+	_, _ = s.Write(7, KindSavedata, []byte("a"), Meta{})
+	_, _ = s.Write(7, KindSavedata, []byte("b"), Meta{})
+	if _, err := os.Stat(filepath.Join(dir, "7_savedata.bin")); !os.IsNotExist(err) {
+		t.Error("legacy dump was not rotated out")
+	}
+}
@@ -0,0 +1,203 @@
+package scheduler
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Off is the schedule of a disabled job.
+const Off = "off"
+
+// Schedule returns when a job runs next.
+type Schedule interface {
+	// Next returns the first time after t the job runs, or the zero time if
+	// it never does.
+	Next(t time.Time) time.Time
+}
+
+// Every runs a job at a fixed interval, counted from its last run.
+type Every time.Duration
+
+// Next returns t plus the interval.
+func (e Every) Next(t time.Time) time.Time {
+	return t.Add(time.Duration(e))
+}
+
+// String returns the interval as an @every descriptor, such as "@every 6h".
+func (e Every) String() string {
+	s := time.Duration(e).String()
+	if strings.HasSuffix(s, "m0s") {
+		s = strings.TrimSuffix(s, "0s")
+	}
+	if strings.HasSuffix(s, "h0m") {
+		s = strings.TrimSuffix(s, "0m")
+	}
+	return "@every " + s
+}
+
+// descriptors are the named schedules Parse accepts besides @every.
+var descriptors = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// cron is a parsed five field cron expression. Each field is a bitset of
+// the values it matches.
+type cron struct {
+	minute, hour, dom, month, dow uint64
+	// A day matches either day field when both are restricted, as in cron.
+	domStar, dowStar bool
+}
+
+// field describes the range and names of a cron field.
+type field struct {
+	name     string
+	min, max int
+	names    []string // Names of the values from min, if any
+}
+
+var fields = [5]field{
+	{name: "minute", min: 0, max: 59},
+	{name: "hour", min: 0, max: 23},
+	{name: "day of month", min: 1, max: 31},
+	{name: "month", min: 1, max: 12, names: []string{"jan", "feb", "mar", "apr", "may", "jun", "jul", "aug", "sep", "oct", "nov", "dec"}},
+	{name: "day of week", min: 0, max: 7, names: []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}},
+}
+
+// Parse parses a schedule: a five field cron expression of minute, hour,
+// day of month, month and day of week, such as "30 4 * * 1-5", one of
+// @yearly, @monthly, @weekly, @daily, @midnight or @hourly, or @every
+// followed by a duration, such as "@every 90m". Cron expressions are in
+// the server's local time.
+func Parse(spec string) (Schedule, error) {
+	spec = strings.TrimSpace(spec)
+	if d, ok := strings.CutPrefix(spec, "@every "); ok {
+		interval, err := time.ParseDuration(strings.TrimSpace(d))
+		if err != nil || interval < time.Second {
+			return nil, fmt.Errorf("bad interval in %q", spec)
+		}
+		return Every(interval), nil
+	}
+	if strings.HasPrefix(spec, "@") {
+		expr, ok := descriptors[strings.ToLower(spec)]
+		if !ok {
+			return nil, fmt.Errorf("unknown schedule %q", spec)
+		}
+		spec = expr
+	}
+	parts := strings.Fields(spec)
+	if len(parts) != len(fields) {
+		return nil, fmt.Errorf("schedule %q has %d fields, want 5", spec, len(parts))
+	}
+	var sets [5]uint64
+	for i, part := range parts {
+		set, err := parseField(part, fields[i])
+		if err != nil {
+			return nil, fmt.Errorf("schedule %q: %w", spec, err)
+		}
+		sets[i] = set
+	}
+	// Sunday is 0 or 7.
+	if sets[4]&(1<<7) != 0 {
+		sets[4] = sets[4]&^(1<<7) | 1
+	}
+	return &cron{
+		minute:  sets[0],
+		hour:    sets[1],
+		dom:     sets[2],
+		month:   sets[3],
+		dow:     sets[4],
+		domStar: strings.HasPrefix(parts[2], "*"),
+		dowStar: strings.HasPrefix(parts[4], "*"),
+	}, nil
+}
+
+// parseField parses a comma separated list of values, ranges and steps.
+func parseField(s string, f field) (uint64, error) {
+	var set uint64
+	for _, term := range strings.Split(s, ",") {
+		rng, step := term, 1
+		if r, st, ok := strings.Cut(term, "/"); ok {
+			n, err := strconv.Atoi(st)
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("bad step in %s %q", f.name, term)
+			}
+			rng, step = r, n
+		}
+		lo, hi := f.min, f.max
+		if rng != "*" {
+			a, b, isRange := strings.Cut(rng, "-")
+			var err error
+			if lo, err = f.value(a); err != nil {
+				return 0, err
+			}
+			hi = lo
+			if isRange {
+				if hi, err = f.value(b); err != nil {
+					return 0, err
+				}
+			} else if step > 1 {
+				hi = f.max // "5/15" runs from 5 on
+			}
+			if hi < lo {
+				return 0, fmt.Errorf("bad range in %s %q", f.name, term)
+			}
+		}
+		for v := lo; v <= hi; v += step {
+			set |= 1 << v
+		}
+	}
+	return set, nil
+}
+
+// value parses a number or name of the field.
+func (f field) value(s string) (int, error) {
+	for i, name := range f.names {
+		if strings.EqualFold(s, name) {
+			return f.min + i, nil
+		}
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n < f.min || n > f.max {
+		return 0, fmt.Errorf("bad %s %q", f.name, s)
+	}
+	return n, nil
+}
+
+// Next returns the first minute after t the expression matches, or the
+// zero time if none does within five years, as for "0 0 30 2 *".
+func (c *cron) Next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		switch {
+		case c.month&(1<<uint(t.Month())) == 0:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+		case !c.dayMatches(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+		case c.hour&(1<<uint(t.Hour())) == 0:
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+		case c.minute&(1<<uint(t.Minute())) == 0:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+func (c *cron) dayMatches(t time.Time) bool {
+	dom := c.dom&(1<<uint(t.Day())) != 0
+	dow := c.dow&(1<<uint(t.Weekday())) != 0
+	if c.domStar || c.dowStar {
+		return dom && dow
+	}
+	return dom || dow
+}
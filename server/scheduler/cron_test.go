@@ -0,0 +1,77 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseNext(t *testing.T) {
+	// A Saturday.
+	from := time.Date(2026, 10, 17, 12, 34, 56, 0, time.UTC)
+	tests := []struct {
+		spec string
+		want time.Time
+	}{
+		{"* * * * *", time.Date(2026, 10, 17, 12, 35, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2026, 10, 17, 12, 45, 0, 0, time.UTC)},
+		{"30 4 * * *", time.Date(2026, 10, 18, 4, 30, 0, 0, time.UTC)},
+		{"0 0 * * mon-fri", time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC)},
+		{"0 12 1,15 * *", time.Date(2026, 11, 1, 12, 0, 0, 0, time.UTC)},
+		{"5/20 13 * * *", time.Date(2026, 10, 17, 13, 5, 0, 0, time.UTC)},
+		{"0 0 1 jan *", time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC)},
+		// Both day fields restricted: either matches.
+		{"0 0 20 * sun", time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC)},
+		{"@hourly", time.Date(2026, 10, 17, 13, 0, 0, 0, time.UTC)},
+		{"@daily", time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC)},
+		{"@weekly", time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC)},
+		{"@monthly", time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC)},
+		{"@every 90m", from.Add(90 * time.Minute)},
+		{"0 0 30 2 *", time.Time{}},
+	}
+	for _, tt := range tests {
+		s, err := Parse(tt.spec)
+		if err != nil {
+			t.Errorf("Parse(%q) error: %v", tt.spec, err)
+			continue
+		}
+		if got := s.Next(from); !got.Equal(tt.want) {
+			t.Errorf("Parse(%q).Next = %v, want %v", tt.spec, got, tt.want)
+		}
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	for _, spec := range []string{
+		"",
+		"* * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"5-1 * * * *",
+		"*/0 * * * *",
+		"* * * smarch *",
+		"@fortnightly",
+		"@every soon",
+		"@every 10ms",
+	} {
+		if _, err := Parse(spec); err == nil {
+			t.Errorf("Parse(%q) succeeded", spec)
+		}
+	}
+}
+
+func TestEveryString(t *testing.T) {
+	for d, want := range map[time.Duration]string{
+		24 * time.Hour:   "@every 24h",
+		90 * time.Minute: "@every 1h30m",
+		10 * time.Minute: "@every 10m",
+		30 * time.Second: "@every 30s",
+	} {
+		if got := Every(d).String(); got != want {
+			t.Errorf("Every(%v).String() = %q, want %q", d, got, want)
+		}
+	}
+}
@@ -0,0 +1,6 @@
+// Package scheduler runs the server's periodic jobs, such as backups and
+// pruning old session events, on cron-style schedules that config.json can
+// override. Each run is recorded in the scheduler_runs table, a job never
+// runs twice at once, and the admin API lists the jobs with their next run
+// and recent history.
+package scheduler
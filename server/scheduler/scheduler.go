@@ -0,0 +1,319 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Job is a task run on a schedule.
+type Job struct {
+	Name     string // Unique name, used in Scheduler.Jobs and the admin API
+	Schedule string // Default schedule, in the forms Parse accepts or Off
+	Run      func(ctx context.Context) error
+	// LastRun, if set, returns when the job last ran, for jobs that can tell
+	// from what they produce, such as backups. @every schedules count from
+	// the later of it and the run history.
+	LastRun func() time.Time
+}
+
+// Run statuses.
+const (
+	StatusOK      = "ok"
+	StatusFailed  = "failed"
+	StatusSkipped = "skipped" // Due while the previous run was still going
+)
+
+// Run is one run of a job.
+type Run struct {
+	Job      string    `json:"job" db:"job"`
+	Started  time.Time `json:"started_at" db:"started_at"`
+	Finished time.Time `json:"finished_at" db:"finished_at"`
+	Status   string    `json:"status" db:"status"`
+	Error    string    `json:"error,omitempty" db:"error"`
+}
+
+// Store keeps the run history.
+type Store interface {
+	Record(run Run) error
+	// Recent returns the job's newest runs, newest first.
+	Recent(job string, limit int) ([]Run, error)
+	// LastStarted returns when the job's newest run that was not skipped
+	// started, or the zero time if it never ran.
+	LastStarted(job string) (time.Time, error)
+}
+
+// Status is a job as the admin API lists it.
+type Status struct {
+	Name     string     `json:"name"`
+	Schedule string     `json:"schedule"`
+	Next     *time.Time `json:"next"` // Nil when the job is off or never runs again
+	Running  bool       `json:"running"`
+	Runs     []Run      `json:"runs"` // Newest first
+}
+
+// statusRuns is how many runs of each job Jobs returns.
+const statusRuns = 10
+
+// Errors returned by RunNow.
+var (
+	ErrUnknownJob = errors.New("unknown job")
+	ErrRunning    = errors.New("job is already running")
+	ErrStopped    = errors.New("scheduler stopped")
+)
+
+type job struct {
+	Job
+	spec     string
+	schedule Schedule // Nil when off
+
+	// Guarded by Scheduler.mu.
+	next    time.Time
+	running bool
+}
+
+// Scheduler runs registered jobs on their schedules. A job never runs twice
+// at once: a run that comes due while the previous one is still going is
+// recorded as skipped.
+type Scheduler struct {
+	store     Store
+	overrides map[string]string
+	logger    *zap.Logger
+	now       func() time.Time
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	mu      sync.Mutex
+	jobs    []*job
+	started bool
+}
+
+// New creates a Scheduler recording runs in store. overrides maps job names
+// to schedules replacing their defaults, as Scheduler.Jobs in config.json.
+func New(store Store, overrides map[string]string, logger *zap.Logger) *Scheduler {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Scheduler{
+		store:     store,
+		overrides: overrides,
+		logger:    logger,
+		now:       time.Now,
+		ctx:       ctx,
+		cancel:    cancel,
+	}
+}
+
+// Register adds a job, scheduled as overridden in config.json or else by
+// its default schedule.
+func (s *Scheduler) Register(j Job) error {
+	spec := j.Schedule
+	for name, override := range s.overrides {
+		if strings.EqualFold(name, j.Name) {
+			spec = override
+		}
+	}
+	var schedule Schedule
+	if !strings.EqualFold(spec, Off) {
+		var err error
+		if schedule, err = Parse(spec); err != nil {
+			return fmt.Errorf("job %s: %w", j.Name, err)
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, other := range s.jobs {
+		if other.Name == j.Name {
+			return fmt.Errorf("job %s registered twice", j.Name)
+		}
+	}
+	jb := &job{Job: j, spec: spec, schedule: schedule}
+	s.jobs = append(s.jobs, jb)
+	if s.started {
+		s.schedule(jb)
+	}
+	return nil
+}
+
+// Start runs the jobs on their schedules and returns a function that stops
+// them, waiting for runs in progress to return.
+func (s *Scheduler) Start() func() {
+	s.mu.Lock()
+	s.started = true
+	for _, j := range s.jobs {
+		s.schedule(j)
+	}
+	s.mu.Unlock()
+	return func() {
+		s.mu.Lock()
+		s.cancel()
+		s.mu.Unlock()
+		s.wg.Wait()
+	}
+}
+
+// schedule starts the goroutine running j. The caller holds s.mu.
+func (s *Scheduler) schedule(j *job) {
+	if j.schedule == nil {
+		return
+	}
+	j.next = s.first(j)
+	if j.next.IsZero() {
+		return
+	}
+	s.wg.Add(1)
+	go s.loop(j, j.next)
+}
+
+// first returns when j first runs. @every schedules count from the last run
+// and run at once when overdue; cron schedules ignore it.
+func (s *Scheduler) first(j *job) time.Time {
+	now := s.now()
+	every, ok := j.schedule.(Every)
+	if !ok {
+		return j.schedule.Next(now)
+	}
+	last, err := s.store.LastStarted(j.Name)
+	if err != nil {
+		s.logger.Warn("Failed to read job history", zap.String("job", j.Name), zap.Error(err))
+	}
+	if j.LastRun != nil {
+		if t := j.LastRun(); t.After(last) {
+			last = t
+		}
+	}
+	if next := every.Next(last); next.After(now) {
+		return next
+	}
+	return now
+}
+
+func (s *Scheduler) loop(j *job, next time.Time) {
+	defer s.wg.Done()
+	timer := time.NewTimer(next.Sub(s.now()))
+	defer timer.Stop()
+	for {
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-timer.C:
+		}
+		_ = s.trigger(j, true)
+		now := s.now()
+		next := j.schedule.Next(now)
+		s.mu.Lock()
+		j.next = next
+		s.mu.Unlock()
+		if next.IsZero() {
+			return
+		}
+		timer.Reset(next.Sub(now))
+	}
+}
+
+// RunNow starts a run of the named job, even one that is off, unless it is
+// already running.
+func (s *Scheduler) RunNow(name string) error {
+	s.mu.Lock()
+	var found *job
+	for _, j := range s.jobs {
+		if strings.EqualFold(j.Name, name) {
+			found = j
+		}
+	}
+	s.mu.Unlock()
+	if found == nil {
+		return ErrUnknownJob
+	}
+	return s.trigger(found, false)
+}
+
+// trigger starts a run of j in its own goroutine. A scheduled run due while
+// j is running is recorded as skipped.
+func (s *Scheduler) trigger(j *job, scheduled bool) error {
+	s.mu.Lock()
+	if s.ctx.Err() != nil {
+		s.mu.Unlock()
+		return ErrStopped
+	}
+	if j.running {
+		s.mu.Unlock()
+		if scheduled {
+			now := s.now()
+			s.logger.Warn("Skipped job, previous run still in progress", zap.String("job", j.Name))
+			s.record(Run{Job: j.Name, Started: now, Finished: now, Status: StatusSkipped, Error: "previous run still in progress"})
+		}
+		return ErrRunning
+	}
+	j.running = true
+	s.wg.Add(1)
+	s.mu.Unlock()
+
+	go func() {
+		defer s.wg.Done()
+		run := Run{Job: j.Name, Started: s.now(), Status: StatusOK}
+		err := s.call(j)
+		run.Finished = s.now()
+		if err != nil {
+			run.Status, run.Error = StatusFailed, err.Error()
+			s.logger.Error("Job failed", zap.String("job", j.Name), zap.Error(err))
+		} else {
+			s.logger.Debug("Job finished", zap.String("job", j.Name), zap.Duration("took", run.Finished.Sub(run.Started)))
+		}
+		s.record(run)
+		s.mu.Lock()
+		j.running = false
+		s.mu.Unlock()
+	}()
+	return nil
+}
+
+// call runs j, turning a panic into an error so one job cannot take the
+// server down.
+func (s *Scheduler) call(j *job) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return j.Run(s.ctx)
+}
+
+func (s *Scheduler) record(run Run) {
+	if err := s.store.Record(run); err != nil {
+		s.logger.Warn("Failed to record job run", zap.String("job", run.Job), zap.Error(err))
+	}
+}
+
+// Jobs returns every registered job with its next run and recent history,
+// in the order they were registered.
+func (s *Scheduler) Jobs() []Status {
+	s.mu.Lock()
+	statuses := make([]Status, len(s.jobs))
+	for i, j := range s.jobs {
+		statuses[i] = Status{Name: j.Name, Schedule: j.spec, Running: j.running, Runs: []Run{}}
+		if !j.next.IsZero() {
+			next := j.next
+			statuses[i].Next = &next
+		}
+	}
+	s.mu.Unlock()
+
+	for i := range statuses {
+		runs, err := s.store.Recent(statuses[i].Name, statusRuns)
+		if err != nil {
+			s.logger.Warn("Failed to read job history", zap.String("job", statuses[i].Name), zap.Error(err))
+			continue
+		}
+		if runs != nil {
+			statuses[i].Runs = runs
+		}
+	}
+	return statuses
+}
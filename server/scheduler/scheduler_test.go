@@ -0,0 +1,180 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// memStore keeps the run history in memory.
+type memStore struct {
+	mu   sync.Mutex
+	runs []Run
+	last map[string]time.Time
+}
+
+func (m *memStore) Record(run Run) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.runs = append(m.runs, run)
+	return nil
+}
+
+func (m *memStore) Recent(job string, limit int) ([]Run, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var runs []Run
+	for i := len(m.runs) - 1; i >= 0 && len(runs) < limit; i-- {
+		if m.runs[i].Job == job {
+			runs = append(runs, m.runs[i])
+		}
+	}
+	return runs, nil
+}
+
+func (m *memStore) LastStarted(job string) (time.Time, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.last[job], nil
+}
+
+func (m *memStore) statuses(job string) map[string]int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	counts := map[string]int{}
+	for _, r := range m.runs {
+		if r.Job == job {
+			counts[r.Status]++
+		}
+	}
+	return counts
+}
+
+// waitFor polls cond until it holds or three seconds pass.
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(3 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestRegister(t *testing.T) {
+	s := New(&memStore{}, map[string]string{"nightly": "30 4 * * *", "noisy": "off"}, zap.NewNop())
+	run := func(context.Context) error { return nil }
+	for _, j := range []Job{
+		{Name: "nightly", Schedule: "@daily", Run: run},
+		{Name: "noisy", Schedule: "@hourly", Run: run},
+		{Name: "plain", Schedule: "@every 6h", Run: run},
+	} {
+		if err := s.Register(j); err != nil {
+			t.Fatalf("Register(%s) error: %v", j.Name, err)
+		}
+	}
+	if err := s.Register(Job{Name: "plain", Schedule: "@hourly", Run: run}); err == nil {
+		t.Error("Register accepted a duplicate name")
+	}
+	if err := s.Register(Job{Name: "broken", Schedule: "daily", Run: run}); err == nil {
+		t.Error("Register accepted a bad schedule")
+	}
+
+	stop := s.Start()
+	defer stop()
+	jobs := s.Jobs()
+	if len(jobs) != 3 {
+		t.Fatalf("Jobs() = %+v", jobs)
+	}
+	if jobs[0].Schedule != "30 4 * * *" || jobs[0].Next == nil || jobs[0].Next.Hour() != 4 {
+		t.Errorf("overridden job = %+v", jobs[0])
+	}
+	if jobs[1].Schedule != "off" || jobs[1].Next != nil {
+		t.Errorf("disabled job = %+v", jobs[1])
+	}
+}
+
+func TestEveryCountsFromLastRun(t *testing.T) {
+	now := time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)
+	store := &memStore{last: map[string]time.Time{"recent": now.Add(-2 * time.Hour)}}
+	s := New(store, nil, zap.NewNop())
+	s.now = func() time.Time { return now }
+
+	recent := &job{Job: Job{Name: "recent"}, schedule: Every(6 * time.Hour)}
+	if got := s.first(recent); !got.Equal(now.Add(4 * time.Hour)) {
+		t.Errorf("first run of a job that ran 2h ago = %v", got)
+	}
+	never := &job{Job: Job{Name: "never"}, schedule: Every(6 * time.Hour)}
+	if got := s.first(never); !got.Equal(now) {
+		t.Errorf("first run of a job that never ran = %v", got)
+	}
+	// The job's own record wins when it is newer than the history.
+	own := &job{Job: Job{Name: "recent", LastRun: func() time.Time { return now.Add(-time.Hour) }}, schedule: Every(6 * time.Hour)}
+	if got := s.first(own); !got.Equal(now.Add(5 * time.Hour)) {
+		t.Errorf("first run with LastRun = %v", got)
+	}
+}
+
+func TestRunsAndSkipsOverlap(t *testing.T) {
+	store := &memStore{}
+	s := New(store, nil, zap.NewNop())
+	release := make(chan struct{})
+	var calls sync.WaitGroup
+	calls.Add(1)
+	var once sync.Once
+	if err := s.Register(Job{Name: "slow", Schedule: "@every 1s", Run: func(ctx context.Context) error {
+		once.Do(calls.Done)
+		select {
+		case <-release:
+		case <-ctx.Done():
+		}
+		return errors.New("gave up")
+	}}); err != nil {
+		t.Fatal(err)
+	}
+	stop := s.Start()
+
+	// The job never ran, so it runs at once, and is still running a second later.
+	calls.Wait()
+	if err := s.RunNow("slow"); !errors.Is(err, ErrRunning) {
+		t.Errorf("RunNow() while running = %v", err)
+	}
+	waitFor(t, func() bool { return store.statuses("slow")[StatusSkipped] > 0 })
+	if jobs := s.Jobs(); !jobs[0].Running || jobs[0].Runs[0].Status != StatusSkipped {
+		t.Errorf("Jobs() = %+v", jobs)
+	}
+
+	close(release)
+	waitFor(t, func() bool { return store.statuses("slow")[StatusFailed] > 0 })
+	stop()
+	if err := s.RunNow("slow"); !errors.Is(err, ErrStopped) {
+		t.Errorf("RunNow() after stop = %v", err)
+	}
+}
+
+func TestRunNow(t *testing.T) {
+	store := &memStore{}
+	s := New(store, nil, zap.NewNop())
+	if err := s.Register(Job{Name: "manual", Schedule: Off, Run: func(context.Context) error {
+		panic("boom")
+	}}); err != nil {
+		t.Fatal(err)
+	}
+	stop := s.Start()
+	if err := s.RunNow("MANUAL"); err != nil {
+		t.Fatalf("RunNow() error: %v", err)
+	}
+	if err := s.RunNow("missing"); !errors.Is(err, ErrUnknownJob) {
+		t.Errorf("RunNow(missing) = %v", err)
+	}
+	waitFor(t, func() bool { return store.statuses("manual")[StatusFailed] == 1 })
+	stop()
+	if runs, _ := store.Recent("manual", 1); runs[0].Error != "panic: boom" {
+		t.Errorf("run = %+v", runs[0])
+	}
+}
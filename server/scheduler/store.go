@@ -0,0 +1,49 @@
+package scheduler
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// keepRuns is how many runs of each job the scheduler_runs table keeps.
+const keepRuns = 100
+
+// Repository keeps the run history in the scheduler_runs table.
+type Repository struct {
+	db *sqlx.DB
+}
+
+// NewRepository creates a new Repository.
+func NewRepository(db *sqlx.DB) *Repository {
+	return &Repository{db: db}
+}
+
+// Record stores a run and deletes the job's runs past the newest keepRuns.
+func (r *Repository) Record(run Run) error {
+	if _, err := r.db.Exec(`INSERT INTO scheduler_runs (job, started_at, finished_at, status, error) VALUES ($1, $2, $3, $4, $5)`,
+		run.Job, run.Started, run.Finished, run.Status, run.Error); err != nil {
+		return err
+	}
+	_, err := r.db.Exec(`DELETE FROM scheduler_runs WHERE job = $1 AND id NOT IN (
+		SELECT id FROM scheduler_runs WHERE job = $1 ORDER BY id DESC LIMIT $2)`, run.Job, keepRuns)
+	return err
+}
+
+// Recent returns the job's newest runs, newest first.
+func (r *Repository) Recent(job string, limit int) ([]Run, error) {
+	runs := []Run{}
+	err := r.db.Select(&runs, `SELECT job, started_at, finished_at, status, error FROM scheduler_runs
+		WHERE job = $1 ORDER BY id DESC LIMIT $2`, job, limit)
+	return runs, err
+}
+
+// LastStarted returns when the job's newest run that was not skipped
+// started, or the zero time if it never ran.
+func (r *Repository) LastStarted(job string) (time.Time, error) {
+	var last sql.NullTime
+	err := r.db.QueryRow(`SELECT max(started_at) FROM scheduler_runs WHERE job = $1 AND status <> $2`,
+		job, StatusSkipped).Scan(&last)
+	return last.Time, err
+}
@@ -0,0 +1,4 @@
+// Package sessionlog records session lifecycle events, from connecting to
+// the sign server to disconnecting from a channel, so support staff can
+// reconstruct what happened to a character at a given time.
+package sessionlog
@@ -0,0 +1,144 @@
+package sessionlog
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"erupe-ce/server/scheduler"
+
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+)
+
+// Session lifecycle events.
+const (
+	EventConnect         = "connect"          // Connected to a channel
+	EventAuthenticate    = "authenticate"     // Signed in to the sign server
+	EventCharacterSelect = "character_select" // Logged in to a channel as a character
+	EventStage           = "stage"            // Moved to another stage
+	EventQuestStart      = "quest_start"      // Entered a quest stage
+	EventQuestEnd        = "quest_end"        // Left a quest stage
+	EventDisconnect      = "disconnect"       // Left a channel
+)
+
+// Subject identifies the session an event happened to. Fields that are not
+// known yet, such as the character before login, are left zero.
+type Subject struct {
+	Server string `json:"server" db:"server"`
+	Remote string `json:"remote" db:"remote"`
+	UserID uint32 `json:"user_id" db:"user_id"`
+	CharID uint32 `json:"char_id" db:"char_id"`
+}
+
+// Event is one recorded session event.
+type Event struct {
+	ID    int64  `json:"id" db:"id"`
+	Event string `json:"event" db:"event"`
+	Subject
+	Detail  json.RawMessage `json:"detail" db:"detail"`
+	Created time.Time       `json:"created_at" db:"created_at"`
+}
+
+// Filter narrows a Query. Zero fields match everything.
+type Filter struct {
+	UserID uint32
+	CharID uint32
+	Event  string
+	Since  time.Time
+	Until  time.Time
+	Before int64 // Only events with a lower ID, for paging backwards
+	Limit  int
+}
+
+// DefaultLimit and MaxLimit bound the number of events a Query returns.
+const (
+	DefaultLimit = 100
+	MaxLimit     = 1000
+)
+
+// Repository reads and writes the session_events table.
+type Repository struct {
+	db *sqlx.DB
+}
+
+// NewRepository creates a new Repository.
+func NewRepository(db *sqlx.DB) *Repository {
+	return &Repository{db: db}
+}
+
+// Record stores an event. detail is stored as JSON; nil stores an empty
+// object.
+func (r *Repository) Record(s Subject, event string, detail any) error {
+	raw, err := marshalDetail(detail)
+	if err != nil {
+		return err
+	}
+	_, err = r.db.Exec(`INSERT INTO session_events (event, server, remote, user_id, char_id, detail)
+		VALUES ($1, $2, $3, $4, $5, $6)`,
+		event, s.Server, s.Remote, s.UserID, s.CharID, string(raw))
+	return err
+}
+
+// Query returns the events matching f, newest first.
+func (r *Repository) Query(f Filter) ([]Event, error) {
+	events := []Event{}
+	err := r.db.Select(&events, `SELECT id, event, server, remote, user_id, char_id, detail, created_at
+		FROM session_events
+		WHERE ($1 = 0 OR user_id = $1) AND ($2 = 0 OR char_id = $2) AND ($3 = '' OR event = $3)
+		AND ($4::timestamptz IS NULL OR created_at >= $4) AND ($5::timestamptz IS NULL OR created_at < $5)
+		AND ($6 = 0 OR id < $6)
+		ORDER BY id DESC LIMIT $7`,
+		f.UserID, f.CharID, f.Event, nullTime(f.Since), nullTime(f.Until), f.Before, clampLimit(f.Limit))
+	return events, err
+}
+
+// Prune deletes events older than before and returns how many it deleted.
+func (r *Repository) Prune(before time.Time) (int64, error) {
+	res, err := r.db.Exec(`DELETE FROM session_events WHERE created_at < $1`, before)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func marshalDetail(detail any) ([]byte, error) {
+	b, err := json.Marshal(detail)
+	if err == nil && string(b) == "null" {
+		return []byte("{}"), nil
+	}
+	return b, err
+}
+
+func nullTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+func clampLimit(n int) int {
+	if n <= 0 {
+		return DefaultLimit
+	}
+	return min(n, MaxLimit)
+}
+
+// PruneJob returns the scheduler job that deletes events older than
+// retention, by default every hour.
+func (r *Repository) PruneJob(retention time.Duration, logger *zap.Logger) scheduler.Job {
+	return scheduler.Job{
+		Name:     "session-events-prune",
+		Schedule: "@every 1h",
+		Run: func(context.Context) error {
+			n, err := r.Prune(time.Now().Add(-retention))
+			if err != nil {
+				return err
+			}
+			if n > 0 {
+				logger.Debug("Pruned session events", zap.Int64("deleted", n))
+			}
+			return nil
+		},
+	}
+}
@@ -0,0 +1,41 @@
+package sessionlog
+
+import (
+	"testing"
+)
+
+func TestMarshalDetail(t *testing.T) {
+	tests := []struct {
+		name   string
+		detail any
+		want   string
+	}{
+		{"nil", nil, `{}`},
+		{"nil map", map[string]string(nil), `{}`},
+		{"map", map[string]string{"stage": "sl1Qs1p0a0u0"}, `{"stage":"sl1Qs1p0a0u0"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := marshalDetail(tt.detail)
+			if err != nil {
+				t.Fatalf("marshalDetail() error = %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("marshalDetail() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClampLimit(t *testing.T) {
+	for _, tt := range []struct{ in, want int }{
+		{0, DefaultLimit},
+		{-1, DefaultLimit},
+		{50, 50},
+		{MaxLimit * 2, MaxLimit},
+	} {
+		if got := clampLimit(tt.in); got != tt.want {
+			t.Errorf("clampLimit(%d) = %d, want %d", tt.in, got, tt.want)
+		}
+	}
+}
@@ -1,11 +1,16 @@
 package setup
 
 import (
+	"bytes"
+	"crypto/subtle"
 	"embed"
 	"encoding/json"
 	"fmt"
+	"mime"
 	"net/http"
 
+	cfg "erupe-ce/config"
+	"erupe-ce/server/audit"
 	"erupe-ce/server/migrations"
 
 	"github.com/jmoiron/sqlx"
@@ -16,10 +21,36 @@ import (
 //go:embed wizard.html
 var wizardHTML embed.FS
 
+// tokenHeader carries the wizard's token on the requests that change
+// anything.
+const tokenHeader = "X-Setup-Token"
+
 // wizardServer holds state for the setup wizard HTTP handlers.
 type wizardServer struct {
 	logger *zap.Logger
 	done   chan struct{} // closed when setup is complete
+	token  string        // printed at startup, required by guarded requests
+}
+
+// guard refuses requests another site could have made the browser send: they
+// must be JSON, come from the wizard's own page if a browser sent them, and
+// carry the token printed at startup.
+func (ws *wizardServer) guard(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type")); err != nil || mediaType != "application/json" {
+			writeJSON(w, http.StatusUnsupportedMediaType, map[string]string{"error": "expected application/json"})
+			return
+		}
+		if origin := r.Header.Get("Origin"); origin != "" && origin != "http://"+r.Host {
+			writeJSON(w, http.StatusForbidden, map[string]string{"error": "cross-origin request refused"})
+			return
+		}
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get(tokenHeader)), []byte(ws.token)) != 1 {
+			writeJSON(w, http.StatusForbidden, map[string]string{"error": "missing or wrong setup token, open the wizard with the address printed at startup"})
+			return
+		}
+		next(w, r)
+	}
 }
 
 func (ws *wizardServer) handleIndex(w http.ResponseWriter, _ *http.Request) {
@@ -97,6 +128,13 @@ func (ws *wizardServer) handleInitDB(w http.ResponseWriter, r *http.Request) {
 		ws.logger.Info(msg)
 	}
 
+	// Operations are audited once the schema, and with it audit_log, exists.
+	type dbOperation struct {
+		action string
+		params map[string]int
+	}
+	var operations []dbOperation
+
 	if req.CreateDB {
 		addLog(fmt.Sprintf("Creating database '%s'...", req.DBName))
 		if err := createDatabase(req.Host, req.Port, req.User, req.Password, req.DBName); err != nil {
@@ -105,6 +143,7 @@ func (ws *wizardServer) handleInitDB(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 		addLog("Database created successfully")
+		operations = append(operations, dbOperation{action: "create_database"})
 	}
 
 	if req.ApplySchema || req.ApplyBundled {
@@ -129,6 +168,7 @@ func (ws *wizardServer) handleInitDB(w http.ResponseWriter, r *http.Request) {
 				return
 			}
 			addLog(fmt.Sprintf("Schema migrations applied (%d migration(s))", applied))
+			operations = append(operations, dbOperation{"apply_schema", map[string]int{"applied": applied}})
 		}
 
 		if req.ApplyBundled {
@@ -140,6 +180,14 @@ func (ws *wizardServer) handleInitDB(w http.ResponseWriter, r *http.Request) {
 				return
 			}
 			addLog(fmt.Sprintf("Bundled data applied (%d files)", applied))
+			operations = append(operations, dbOperation{"apply_bundled_data", map[string]int{"applied": applied}})
+		}
+
+		auditRepo := audit.NewRepository(db)
+		for _, op := range operations {
+			if err := auditRepo.Record(audit.SourceWizard, r.RemoteAddr, op.action, req.DBName, op.params); err != nil {
+				ws.logger.Warn("Failed to record audit log entry", zap.Error(err), zap.String("action", op.action))
+			}
 		}
 	}
 
@@ -167,6 +215,65 @@ func (ws *wizardServer) handleFinish(w http.ResponseWriter, r *http.Request) {
 	close(ws.done)
 }
 
+// handleConfig returns every setting of the existing config.json, for the
+// wizard to edit, or exists false when there is none yet. Secrets are
+// redacted.
+func (ws *wizardServer) handleConfig(w http.ResponseWriter, _ *http.Request) {
+	config, err := readConfig()
+	if err == nil && config != nil {
+		config, err = cfg.RedactSecrets(config)
+	}
+	if err != nil {
+		writeJSON(w, http.StatusOK, map[string]interface{}{"exists": true, "error": err.Error()})
+		return
+	}
+	if config == nil {
+		writeJSON(w, http.StatusOK, map[string]interface{}{"exists": false})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"exists": true, "config": config})
+}
+
+// handleSaveConfig replaces config.json with the settings in the request
+// body, a whole config as GET /api/setup/config returns it, backing up the
+// old file first. Secrets left redacted keep their values.
+func (ws *wizardServer) handleSaveConfig(w http.ResponseWriter, r *http.Request) {
+	var config json.RawMessage
+	if err := json.NewDecoder(r.Body).Decode(&config); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON"})
+		return
+	}
+	if err := checkConfigObject(config); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "config " + err.Error()})
+		return
+	}
+	orig, err := readConfig()
+	if err == nil {
+		config, err = cfg.RestoreSecrets(config, orig)
+	}
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	var data bytes.Buffer
+	if err := json.Indent(&data, config, "", "  "); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON"})
+		return
+	}
+
+	backup, err := replaceConfig(data.Bytes())
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	ws.logger.Info("config.json updated", zap.String("backup", backup))
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok", "backup": backup})
+
+	// Signal completion — this will cause the HTTP server to shut down.
+	close(ws.done)
+}
+
 func writeJSON(w http.ResponseWriter, status int, v interface{}) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
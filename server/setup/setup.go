@@ -2,36 +2,56 @@ package setup
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
 	"net/http"
+	"os"
 
 	"github.com/gorilla/mux"
 	"go.uber.org/zap"
 )
 
-// Run starts a temporary HTTP server serving the setup wizard.
+// Run starts a temporary HTTP server serving the setup wizard. When
+// config.json already exists the wizard offers to edit it instead, and
+// listens on localhost only, since anyone reaching it could rewrite the file.
+// Requests that change anything must carry a token printed at startup, so
+// other sites open in the browser cannot make them.
 // It blocks until the user completes setup and config.json is written.
 func Run(logger *zap.Logger, port int) error {
+	host := ""
+	if _, err := os.Stat(configPath); err == nil {
+		host = "127.0.0.1"
+	}
+
+	token := make([]byte, 16)
+	if _, err := rand.Read(token); err != nil {
+		return fmt.Errorf("generating setup token: %w", err)
+	}
 	ws := &wizardServer{
 		logger: logger,
 		done:   make(chan struct{}),
+		token:  hex.EncodeToString(token),
 	}
 
 	r := mux.NewRouter()
 	r.HandleFunc("/", ws.handleIndex).Methods("GET")
 	r.HandleFunc("/api/setup/detect-ip", ws.handleDetectIP).Methods("GET")
 	r.HandleFunc("/api/setup/client-modes", ws.handleClientModes).Methods("GET")
-	r.HandleFunc("/api/setup/test-db", ws.handleTestDB).Methods("POST")
-	r.HandleFunc("/api/setup/init-db", ws.handleInitDB).Methods("POST")
-	r.HandleFunc("/api/setup/finish", ws.handleFinish).Methods("POST")
+	r.HandleFunc("/api/setup/test-db", ws.guard(ws.handleTestDB)).Methods("POST")
+	r.HandleFunc("/api/setup/init-db", ws.guard(ws.handleInitDB)).Methods("POST")
+	r.HandleFunc("/api/setup/finish", ws.guard(ws.handleFinish)).Methods("POST")
+	r.HandleFunc("/api/setup/config", ws.handleConfig).Methods("GET")
+	r.HandleFunc("/api/setup/config", ws.guard(ws.handleSaveConfig)).Methods("POST")
 
 	srv := &http.Server{
-		Addr:    fmt.Sprintf(":%d", port),
+		Addr:    fmt.Sprintf("%s:%d", host, port),
 		Handler: r,
 	}
 
-	logger.Info(fmt.Sprintf("Setup wizard available at http://localhost:%d", port))
-	fmt.Printf("\n  >>> Open http://localhost:%d in your browser to configure Erupe <<<\n\n", port)
+	url := fmt.Sprintf("http://localhost:%d/?token=%s", port, ws.token)
+	logger.Info("Setup wizard available at " + url)
+	fmt.Printf("\n  >>> Open %s in your browser to configure Erupe <<<\n\n", url)
 
 	// Start the HTTP server in a goroutine.
 	errCh := make(chan error, 1)
@@ -6,8 +6,15 @@ import (
 	"fmt"
 	"net"
 	"os"
+	"time"
+
+	cfg "erupe-ce/config"
 )
 
+// configPath is the config file the wizard writes, in the working directory
+// the server loads it from.
+const configPath = "config.json"
+
 // clientModes returns all supported client version strings.
 func clientModes() []string {
 	return []string{
@@ -39,6 +46,7 @@ func buildDefaultConfig(req FinishRequest) map[string]interface{} {
 		lang = "jp"
 	}
 	return map[string]interface{}{
+		"ConfigVersion":     cfg.LatestConfigVersion,
 		"Host":              req.Host,
 		"Language":          lang,
 		"ClientMode":        req.ClientMode,
@@ -53,18 +61,68 @@ func buildDefaultConfig(req FinishRequest) map[string]interface{} {
 	}
 }
 
-// writeConfig writes the config map to config.json with pretty formatting.
+// writeConfig writes the config map to config.json with pretty formatting,
+// backing up any config.json already there first.
 func writeConfig(config map[string]interface{}) error {
 	data, err := json.MarshalIndent(config, "", "  ")
 	if err != nil {
 		return fmt.Errorf("marshalling config: %w", err)
 	}
-	if err := os.WriteFile("config.json", data, 0600); err != nil {
-		return fmt.Errorf("writing config.json: %w", err)
+	_, err = replaceConfig(data)
+	return err
+}
+
+// readConfig returns the settings of the existing config.json as written,
+// keys in their order, or nil when there is none.
+func readConfig() (json.RawMessage, error) {
+	data, err := os.ReadFile(configPath)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", configPath, err)
+	}
+	if err := checkConfigObject(data); err != nil {
+		return nil, fmt.Errorf("%s: %w", configPath, err)
+	}
+	return data, nil
+}
+
+// checkConfigObject checks that data is a JSON object, as a config file must be.
+func checkConfigObject(data []byte) error {
+	var settings map[string]json.RawMessage
+	if err := json.Unmarshal(data, &settings); err != nil {
+		return fmt.Errorf("not a JSON object: %w", err)
+	}
+	if settings == nil {
+		return fmt.Errorf("not a JSON object")
 	}
 	return nil
 }
 
+// replaceConfig writes data, the whole of a config file, to config.json.
+// An existing config.json is first copied to a timestamped backup beside
+// it, whose name is returned, or "" when there was nothing to back up.
+func replaceConfig(data []byte) (string, error) {
+	perm := os.FileMode(0600)
+	backup := ""
+	if orig, err := os.ReadFile(configPath); err == nil {
+		if info, err := os.Stat(configPath); err == nil {
+			perm = info.Mode().Perm()
+		}
+		backup = fmt.Sprintf("%s.%s.bak", configPath, time.Now().Format("20060102-150405"))
+		if err := os.WriteFile(backup, orig, perm); err != nil {
+			return "", fmt.Errorf("backing up %s: %w", configPath, err)
+		}
+	} else if !os.IsNotExist(err) {
+		return "", fmt.Errorf("reading %s: %w", configPath, err)
+	}
+	if err := os.WriteFile(configPath, data, perm); err != nil {
+		return "", fmt.Errorf("writing %s: %w", configPath, err)
+	}
+	return backup, nil
+}
+
 // detectOutboundIP returns the preferred outbound IPv4 address.
 func detectOutboundIP() (string, error) {
 	conn, err := net.Dial("udp4", "8.8.8.8:80")
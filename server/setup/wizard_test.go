@@ -6,8 +6,11 @@ import (
 	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
+	"erupe-ce/config"
+
 	"go.uber.org/zap"
 )
 
@@ -34,6 +37,9 @@ func TestBuildDefaultConfig(t *testing.T) {
 	if cfg["AutoCreateAccount"] != true {
 		t.Errorf("AutoCreateAccount = %v, want true", cfg["AutoCreateAccount"])
 	}
+	if cfg["ConfigVersion"] != config.LatestConfigVersion {
+		t.Errorf("ConfigVersion = %v, want %d", cfg["ConfigVersion"], config.LatestConfigVersion)
+	}
 
 	// Check database section
 	db, ok := cfg["Database"].(map[string]interface{})
@@ -162,6 +168,195 @@ func TestWriteConfig(t *testing.T) {
 	}
 }
 
+func TestWriteConfigBacksUpExisting(t *testing.T) {
+	dir := t.TempDir()
+	origDir, _ := os.Getwd()
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.Chdir(origDir) }()
+
+	if err := os.WriteFile("config.json", []byte(`{"Host": "old"}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeConfig(buildDefaultConfig(FinishRequest{Host: "new"})); err != nil {
+		t.Fatalf("writeConfig failed: %v", err)
+	}
+
+	backups, _ := filepath.Glob("config.json.*.bak")
+	if len(backups) != 1 {
+		t.Fatalf("backups = %v, want one", backups)
+	}
+	data, _ := os.ReadFile(backups[0])
+	if string(data) != `{"Host": "old"}` {
+		t.Errorf("backup = %s, want the old config", data)
+	}
+}
+
+func TestHandleConfig(t *testing.T) {
+	dir := t.TempDir()
+	origDir, _ := os.Getwd()
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.Chdir(origDir) }()
+
+	ws := &wizardServer{
+		logger: zap.NewNop(),
+		done:   make(chan struct{}),
+	}
+	get := func() map[string]interface{} {
+		w := httptest.NewRecorder()
+		ws.handleConfig(w, httptest.NewRequest("GET", "/api/setup/config", nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("status = %d, want 200", w.Code)
+		}
+		var resp map[string]interface{}
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Fatalf("decode error: %v", err)
+		}
+		return resp
+	}
+
+	if resp := get(); resp["exists"] != false {
+		t.Errorf("exists = %v without config.json, want false", resp["exists"])
+	}
+
+	file := `{"Host": "10.0.0.1", "Database": {"Port": 5433, "Password": "hunter2"}, "Channel": {"Enabled": true}}`
+	if err := os.WriteFile("config.json", []byte(file), 0600); err != nil {
+		t.Fatal(err)
+	}
+	resp := get()
+	if resp["exists"] != true {
+		t.Fatalf("exists = %v, want true", resp["exists"])
+	}
+	settings, ok := resp["config"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("config = %v, want the settings", resp["config"])
+	}
+	if settings["Host"] != "10.0.0.1" {
+		t.Errorf("Host = %v, want 10.0.0.1", settings["Host"])
+	}
+	if channel, _ := settings["Channel"].(map[string]interface{}); channel["Enabled"] != true {
+		t.Errorf("Channel = %v, want every setting of the file", settings["Channel"])
+	}
+	if db, _ := settings["Database"].(map[string]interface{}); db["Password"] != config.RedactedSecret {
+		t.Errorf("Database.Password = %v, want it redacted", db["Password"])
+	}
+
+	if err := os.WriteFile("config.json", []byte(`{"Host": `), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if resp := get(); resp["exists"] != true || resp["error"] == nil {
+		t.Errorf("got %v for a broken config.json, want exists and an error", resp)
+	}
+}
+
+func TestHandleSaveConfig(t *testing.T) {
+	dir := t.TempDir()
+	origDir, _ := os.Getwd()
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.Chdir(origDir) }()
+
+	orig := `{"Host": "127.0.0.1", "ClientMode": "ZZ", "Database": {"Password": "hunter2"}}`
+	if err := os.WriteFile("config.json", []byte(orig), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	ws := &wizardServer{
+		logger: zap.NewNop(),
+		done:   make(chan struct{}),
+	}
+	for _, body := range []string{`[1, 2]`, `null`, `{"Host": `} {
+		w := httptest.NewRecorder()
+		ws.handleSaveConfig(w, httptest.NewRequest("POST", "/api/setup/config", strings.NewReader(body)))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("status = %d for %s, want 400", w.Code, body)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	body := `{"Host": "10.0.0.1", "ClientMode": "G10", "Database": {"Port": 5433, "Password": "<redacted>"}}`
+	ws.handleSaveConfig(w, httptest.NewRequest("POST", "/api/setup/config", strings.NewReader(body)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", w.Code, w.Body)
+	}
+	var resp map[string]string
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode error: %v", err)
+	}
+
+	backup, err := os.ReadFile(resp["backup"])
+	if err != nil {
+		t.Fatalf("reading backup %q: %v", resp["backup"], err)
+	}
+	if string(backup) != orig {
+		t.Errorf("backup = %s, want %s", backup, orig)
+	}
+	data, err := os.ReadFile("config.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "{\n  \"Host\": \"10.0.0.1\",\n  \"ClientMode\": \"G10\",\n  \"Database\": {\n    \"Port\": 5433,\n    \"Password\": \"hunter2\"\n  }\n}"
+	if string(data) != want {
+		t.Errorf("config.json = %s, want %s with the keys in order", data, want)
+	}
+
+	select {
+	case <-ws.done:
+	default:
+		t.Error("saving the config did not finish the wizard")
+	}
+}
+
+func TestGuard(t *testing.T) {
+	ws := &wizardServer{
+		logger: zap.NewNop(),
+		done:   make(chan struct{}),
+		token:  "t0ken",
+	}
+	handler := ws.guard(func(w http.ResponseWriter, _ *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+	})
+
+	tests := []struct {
+		name        string
+		contentType string
+		origin      string
+		token       string
+		want        int
+	}{
+		{"wizard page", "application/json", "http://localhost:8080", "t0ken", http.StatusOK},
+		{"without a browser", "application/json; charset=utf-8", "", "t0ken", http.StatusOK},
+		{"form post", "text/plain", "http://localhost:8080", "t0ken", http.StatusUnsupportedMediaType},
+		{"no content type", "", "", "t0ken", http.StatusUnsupportedMediaType},
+		{"other site", "application/json", "http://evil.example", "t0ken", http.StatusForbidden},
+		{"no token", "application/json", "http://localhost:8080", "", http.StatusForbidden},
+		{"wrong token", "application/json", "http://localhost:8080", "guess", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "http://localhost:8080/api/setup/config", strings.NewReader(`{}`))
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+			if tt.origin != "" {
+				req.Header.Set("Origin", tt.origin)
+			}
+			if tt.token != "" {
+				req.Header.Set(tokenHeader, tt.token)
+			}
+			w := httptest.NewRecorder()
+			handler(w, req)
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d: %s", w.Code, tt.want, w.Body)
+			}
+		})
+	}
+}
+
 func TestHandleIndex(t *testing.T) {
 	ws := &wizardServer{
 		logger: zap.NewNop(),
@@ -6,6 +6,8 @@ import (
 	ps "erupe-ce/common/pascalstring"
 	"erupe-ce/common/stringsupport"
 	cfg "erupe-ce/config"
+	"erupe-ce/network"
+	"erupe-ce/server/sessionlog"
 	"fmt"
 	"strings"
 	"time"
@@ -13,6 +15,20 @@ import (
 	"go.uber.org/zap"
 )
 
+// recordAuthenticate records a successful sign-in in the session event log.
+func (s *Session) recordAuthenticate(uid uint32) {
+	if s.server.sessionEvents == nil || uid == 0 {
+		return
+	}
+	subject := sessionlog.Subject{Server: "sign", UserID: uid}
+	if s.rawConn != nil {
+		subject.Remote = s.rawConn.RemoteAddr().String()
+	}
+	if err := s.server.sessionEvents.Record(subject, sessionlog.EventAuthenticate, nil); err != nil {
+		s.logger.Warn("Failed to record authenticate event", zap.Error(err), zap.Uint32("uid", uid))
+	}
+}
+
 func (s *Session) makeSignResponse(uid uint32) []byte {
 	// Get the characters from the DB.
 	chars, err := s.server.getCharactersForUser(uid)
@@ -25,6 +41,9 @@ func (s *Session) makeSignResponse(uid uint32) []byte {
 	if err != nil {
 		s.logger.Warn("Error getting characters from DB", zap.Error(err))
 	}
+	for _, c := range chars {
+		s.charIDs = append(s.charIDs, c.ID)
+	}
 
 	bf := byteframe.NewByteFrame()
 	var tokenID uint32
@@ -44,6 +63,8 @@ func (s *Session) makeSignResponse(uid uint32) []byte {
 		return bf.Data()
 	}
 
+	s.recordAuthenticate(uid)
+
 	bf.WriteUint8(uint8(SIGN_SUCCESS))
 	bf.WriteUint8(2) // patch server count
 	bf.WriteUint8(1) // entrance server count
@@ -58,10 +79,10 @@ func (s *Session) makeSignResponse(uid uint32) []byte {
 		ps.Uint8(bf, s.server.erupeConfig.PatchServerManifest, false)
 		ps.Uint8(bf, s.server.erupeConfig.PatchServerFile, false)
 	}
-	if strings.Split(s.rawConn.RemoteAddr().String(), ":")[0] == "127.0.0.1" {
+	if network.IsLoopback(s.rawConn.RemoteAddr()) {
 		ps.Uint8(bf, fmt.Sprintf("127.0.0.1:%d", s.server.erupeConfig.Entrance.Port), false)
 	} else {
-		ps.Uint8(bf, fmt.Sprintf("%s:%d", s.server.erupeConfig.Host, s.server.erupeConfig.Entrance.Port), false)
+		ps.Uint8(bf, network.AdvertisedHost(s.server.erupeConfig.Host, s.server.erupeConfig.HostV6, s.rawConn.RemoteAddr(), s.server.erupeConfig.Entrance.Port), false)
 	}
 
 	lastPlayed := uint32(0)
@@ -124,13 +145,13 @@ func (s *Session) makeSignResponse(uid uint32) []byte {
 		}
 	}
 
-	if s.server.erupeConfig.HideLoginNotice {
+	if s.server.erupeConfig.Live().HideLoginNotice {
 		bf.WriteBool(false)
 	} else {
 		bf.WriteBool(true)
 		bf.WriteUint8(0)
 		bf.WriteUint8(0)
-		ps.Uint16(bf, strings.Join(s.server.erupeConfig.LoginNotices[:], "<PAGE>"), true)
+		ps.Uint16(bf, strings.Join(s.server.erupeConfig.Live().LoginNotices[:], "<PAGE>"), true)
 	}
 
 	bf.WriteUint32(s.server.getLastCID(uid))
@@ -376,20 +397,20 @@ func (s *Session) makeSignResponse(uid uint32) []byte {
 	bf.WriteUint32(0)
 
 	tickets := []uint32{
-		s.server.erupeConfig.GameplayOptions.MezFesSoloTickets,
-		s.server.erupeConfig.GameplayOptions.MezFesGroupTickets,
+		s.server.erupeConfig.Live().GameplayOptions.MezFesSoloTickets,
+		s.server.erupeConfig.Live().GameplayOptions.MezFesGroupTickets,
 	}
 	stalls := []uint8{
 		10, 3, 6, 9, 4, 8, 5, 7,
 	}
-	if s.server.erupeConfig.GameplayOptions.MezFesSwitchMinigame {
+	if s.server.erupeConfig.Live().GameplayOptions.MezFesSwitchMinigame {
 		stalls[4] = 2
 	}
 
 	// We can just use the start timestamp as the event ID
 	bf.WriteUint32(uint32(gametime.WeekStart().Unix()))
 	// Start time
-	bf.WriteUint32(uint32(gametime.WeekNext().Add(-time.Duration(s.server.erupeConfig.GameplayOptions.MezFesDuration) * time.Second).Unix()))
+	bf.WriteUint32(uint32(gametime.WeekNext().Add(-time.Duration(s.server.erupeConfig.Live().GameplayOptions.MezFesDuration) * time.Second).Unix()))
 	// End time
 	bf.WriteUint32(uint32(gametime.WeekNext().Unix()))
 	bf.WriteUint8(uint8(len(tickets)))
@@ -9,6 +9,7 @@ import (
 	"go.uber.org/zap"
 
 	cfg "erupe-ce/config"
+	"erupe-ce/server/sessionlog"
 )
 
 // newMakeSignResponseServer creates a Server with mock repos for makeSignResponse tests.
@@ -263,3 +264,28 @@ func TestMakeSignResponse_FullFlow(t *testing.T) {
 		t.Errorf("makeSignResponse() first byte = %d, want %d (SIGN_SUCCESS)", result[0], SIGN_SUCCESS)
 	}
 }
+
+func TestMakeSignResponse_RecordsAuthenticate(t *testing.T) {
+	config := &cfg.Config{
+		DebugOptions: cfg.DebugOptions{
+			CapLink: cfg.CapLinkOptions{Values: []uint16{0, 0, 0, 0, 0}},
+		},
+	}
+	server := newMakeSignResponseServer(config)
+	events := &mockSignSessionEventRepo{}
+	server.sessionEvents = events
+	session := &Session{
+		logger:  zap.NewNop(),
+		server:  server,
+		rawConn: newMockConn(),
+		client:  PC100,
+	}
+
+	session.makeSignResponse(5)
+	if len(events.events) != 1 || events.events[0] != sessionlog.EventAuthenticate {
+		t.Fatalf("events = %v, want one authenticate", events.events)
+	}
+	if got := events.subjects[0]; got.UserID != 5 || got.Server != "sign" {
+		t.Errorf("subject = %+v, want user 5 on sign", got)
+	}
+}
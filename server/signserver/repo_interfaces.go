@@ -1,6 +1,10 @@
 package signserver
 
-import "time"
+import (
+	"time"
+
+	"erupe-ce/server/sessionlog"
+)
 
 // Repository interfaces decouple sign server business logic from concrete
 // PostgreSQL implementations, enabling mock/stub injection for unit tests.
@@ -64,3 +68,9 @@ type SignSessionRepo interface {
 	Validate(token string, tokenID uint32) (bool, error)
 	GetPSNIDByToken(token string) (string, error)
 }
+
+// SignSessionEventRepo defines the contract for recording session lifecycle
+// events.
+type SignSessionEventRepo interface {
+	Record(s sessionlog.Subject, event string, detail any) error
+}
@@ -3,6 +3,8 @@ package signserver
 import (
 	"errors"
 	"time"
+
+	"erupe-ce/server/sessionlog"
 )
 
 // errMockDB is a sentinel for mock repo error injection.
@@ -261,3 +263,16 @@ func newTestServer(userRepo SignUserRepo, charRepo SignCharacterRepo, sessionRep
 		sessionRepo: sessionRepo,
 	}
 }
+
+// --- mockSignSessionEventRepo ---
+
+type mockSignSessionEventRepo struct {
+	subjects []sessionlog.Subject
+	events   []string
+}
+
+func (m *mockSignSessionEventRepo) Record(s sessionlog.Subject, event string, detail any) error {
+	m.subjects = append(m.subjects, s)
+	m.events = append(m.events, event)
+	return nil
+}
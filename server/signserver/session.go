@@ -10,6 +10,7 @@ import (
 
 	"erupe-ce/common/byteframe"
 	"erupe-ce/network"
+	"erupe-ce/network/pcap"
 
 	"go.uber.org/zap"
 )
@@ -33,6 +34,9 @@ type Session struct {
 	cryptConn      network.Conn
 	client         client
 	psn            string
+	username       string   // Account signed in with credentials
+	charIDs        []uint32 // Characters of the account signed in to
+	captureConn    *pcap.RecordingConn
 	captureCleanup func()
 }
 
@@ -96,6 +100,7 @@ func (s *Session) authenticate(username string, password string) {
 		username = username[:len(username)-1]
 		newCharaReq = true
 	}
+	s.username = username
 	bf := byteframe.NewByteFrame()
 	uid, resp := s.server.validateLogin(username, password)
 	switch resp {
@@ -159,6 +164,7 @@ func (s *Session) handlePSNLink(bf *byteframe.ByteFrame) {
 	credStr := stringsupport.SJISToUTF8Lossy(bf.ReadNullTerminatedBytes())
 	credentials := strings.Split(credStr, "\n")
 	tok := string(bf.ReadNullTerminatedBytes())
+	s.username = credentials[0]
 	uid, resp := s.server.validateLogin(credentials[0], credentials[1])
 	if resp == SIGN_SUCCESS && uid > 0 {
 		psn, err := s.server.sessionRepo.GetPSNIDByToken(tok)
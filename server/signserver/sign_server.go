@@ -8,6 +8,8 @@ import (
 
 	cfg "erupe-ce/config"
 	"erupe-ce/network"
+	"erupe-ce/network/crypto"
+	"erupe-ce/server/sessionlog"
 	"github.com/jmoiron/sqlx"
 	"go.uber.org/zap"
 )
@@ -17,6 +19,8 @@ type Config struct {
 	Logger      *zap.Logger
 	DB          *sqlx.DB
 	ErupeConfig *cfg.Config
+	ConnLimiter *network.ConnLimiter // Shared by all listeners; nil disables connection limits
+	Cipher      crypto.Cipher        // Packet body cipher; nil for the retail cipher
 }
 
 // Server is a MHF sign server.
@@ -27,6 +31,9 @@ type Server struct {
 	userRepo       SignUserRepo
 	charRepo       SignCharacterRepo
 	sessionRepo    SignSessionRepo
+	sessionEvents  SignSessionEventRepo // nil when session events are disabled
+	connLimiter    *network.ConnLimiter
+	cipher         crypto.Cipher
 	listener       net.Listener
 	isShuttingDown bool
 }
@@ -36,11 +43,16 @@ func NewServer(config *Config) *Server {
 	s := &Server{
 		logger:      config.Logger,
 		erupeConfig: config.ErupeConfig,
+		connLimiter: config.ConnLimiter,
+		cipher:      config.Cipher,
 	}
 	if config.DB != nil {
 		s.userRepo = NewSignUserRepository(config.DB)
 		s.charRepo = NewSignCharacterRepository(config.DB)
 		s.sessionRepo = NewSignSessionRepository(config.DB)
+		if config.ErupeConfig.SessionEvents.Enabled {
+			s.sessionEvents = sessionlog.NewRepository(config.DB)
+		}
 	}
 	return s
 }
@@ -51,7 +63,15 @@ func (s *Server) Start() error {
 	if err != nil {
 		return err
 	}
-	s.listener = l
+	s.listener, err = network.WithProxyProtocol(l, s.erupeConfig.ProxyProtocol)
+	if err != nil {
+		_ = l.Close()
+		return err
+	}
+	if s.connLimiter != nil {
+		// Clients open with 8 NULL bytes, so anything else is turned away early.
+		s.listener = s.connLimiter.Wrap(s.listener, true)
+	}
 
 	go s.acceptClients()
 
@@ -104,19 +124,22 @@ func (s *Server) handleConnection(conn net.Conn) {
 	}
 
 	// Create a new session.
-	var cc network.Conn = network.NewCryptConn(conn, s.erupeConfig.RealClientMode, s.logger)
-	cc, captureCleanup := startSignCapture(s, cc, conn.RemoteAddr())
+	var cc network.Conn = network.NewCipherConn(conn, s.erupeConfig.RealClientMode, s.cipher, s.logger)
+	cc = network.WithFaults(cc, conn, s.erupeConfig.DebugOptions.FaultInjection)
+	cc, captureConn, captureCleanup := startSignCapture(s, cc, conn.RemoteAddr())
 
 	session := &Session{
 		logger:         s.logger,
 		server:         s,
 		rawConn:        conn,
 		cryptConn:      cc,
+		captureConn:    captureConn,
 		captureCleanup: captureCleanup,
 	}
 
 	// Do the session's work.
 	session.work()
+	session.discardUntargetedCapture()
 
 	if session.captureCleanup != nil {
 		session.captureCleanup()
@@ -14,19 +14,20 @@ import (
 )
 
 // startSignCapture wraps a Conn with a RecordingConn if capture is enabled for sign server.
-func startSignCapture(s *Server, conn network.Conn, remoteAddr net.Addr) (network.Conn, func()) {
-	capCfg := s.erupeConfig.Capture
+func startSignCapture(s *Server, conn network.Conn, remoteAddr net.Addr) (network.Conn, *pcap.RecordingConn, func()) {
+	capCfg := s.erupeConfig.Live().Capture
 	if !capCfg.Enabled || !capCfg.CaptureSign {
-		return conn, func() {}
+		return conn, nil, func() {}
 	}
 
+	logger := s.logger.Named("capture")
 	outputDir := capCfg.OutputDir
 	if outputDir == "" {
 		outputDir = "captures"
 	}
 	if err := os.MkdirAll(outputDir, 0o755); err != nil {
-		s.logger.Warn("Failed to create capture directory", zap.Error(err))
-		return conn, func() {}
+		logger.Warn("Failed to create capture directory", zap.Error(err))
+		return conn, nil, func() {}
 	}
 
 	now := time.Now()
@@ -36,18 +37,17 @@ func startSignCapture(s *Server, conn network.Conn, remoteAddr net.Addr) (networ
 	)
 	path := filepath.Join(outputDir, filename)
 
-	f, err := os.Create(path)
+	flags, err := pcap.CompressionFlag(capCfg.Compression)
 	if err != nil {
-		s.logger.Warn("Failed to create capture file", zap.Error(err), zap.String("path", path))
-		return conn, func() {}
+		logger.Warn("Unknown capture compression, recording uncompressed", zap.Error(err))
 	}
-
 	startNs := now.UnixNano()
 	hdr := pcap.FileHeader{
 		Version:        pcap.FormatVersion,
 		ServerType:     pcap.ServerTypeSign,
 		ClientMode:     byte(s.erupeConfig.RealClientMode),
 		SessionStartNs: startNs,
+		Flags:          flags | pcap.FlagChecksums,
 	}
 	meta := pcap.SessionMetadata{
 		Host:       s.erupeConfig.Host,
@@ -55,27 +55,47 @@ func startSignCapture(s *Server, conn network.Conn, remoteAddr net.Addr) (networ
 		RemoteAddr: remoteAddr.String(),
 	}
 
-	w, err := pcap.NewWriter(f, hdr, meta)
+	opts := pcap.RecordingOptions{
+		MaxBytes:    int64(capCfg.RotateSizeMB) << 20,
+		MaxDuration: time.Duration(capCfg.RotateMinutes) * time.Minute,
+		MaxTotal:    int64(capCfg.MaxTotalMB) << 20,
+	}
+	if capCfg.EncryptionKey != "" {
+		opts.Secret = []byte(capCfg.EncryptionKey)
+	}
+	rec, err := pcap.CreateRecording(path, hdr, meta, opts)
 	if err != nil {
-		s.logger.Warn("Failed to initialize capture writer", zap.Error(err))
-		_ = f.Close()
-		return conn, func() {}
+		logger.Warn("Failed to create capture file", zap.Error(err), zap.String("path", path))
+		return conn, nil, func() {}
 	}
 
-	s.logger.Info("Capture started", zap.String("file", path))
+	logger.Info("Capture started", zap.String("file", path))
 
-	rc := pcap.NewRecordingConn(conn, w, startNs, capCfg.ExcludeOpcodes)
+	rc := pcap.NewRecordingConn(conn, rec, startNs, capCfg.ExcludeOpcodes)
 	cleanup := func() {
-		if err := w.Flush(); err != nil {
-			s.logger.Warn("Failed to flush capture", zap.Error(err))
+		rc.Close()
+		if err := rec.Close(); err != nil {
+			logger.Warn("Failed to close capture file", zap.Error(err))
 		}
-		if err := f.Close(); err != nil {
-			s.logger.Warn("Failed to close capture file", zap.Error(err))
+		if paths := rec.Paths(); len(paths) > 0 {
+			logger.Info("Capture saved", zap.Strings("files", paths), zap.Uint64("dropped", rc.Dropped()))
 		}
-		s.logger.Info("Capture saved", zap.String("file", path))
 	}
 
-	return rc, cleanup
+	return rc, rc, cleanup
+}
+
+// discardUntargetedCapture discards the capture of a session that did not
+// sign in to an account or characters Capture targets.
+func (s *Session) discardUntargetedCapture() {
+	capCfg := s.server.erupeConfig.Live().Capture
+	if s.captureConn == nil || capCfg.Targets(s.username, s.charIDs...) {
+		return
+	}
+	if err := s.captureConn.Discard(); err != nil {
+		s.logger.Warn("Failed to discard capture", zap.Error(err))
+	}
+	s.logger.Debug("Capture discarded, session not targeted", zap.String("username", s.username))
 }
 
 func sanitizeAddr(addr string) string {
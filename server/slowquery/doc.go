@@ -0,0 +1,4 @@
+// Package slowquery wraps a database driver so statements that take longer
+// than a configured threshold are logged with their caller and counted, to
+// help operators find missing indexes.
+package slowquery
@@ -0,0 +1,238 @@
+package slowquery
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Monitor logs statements that run longer than its threshold.
+type Monitor struct {
+	threshold time.Duration
+	logger    *zap.Logger
+	onSlow    func() // Called for every slow statement, may be nil
+}
+
+// New creates a Monitor that logs statements slower than threshold to
+// logger and calls onSlow for each of them.
+func New(threshold time.Duration, logger *zap.Logger, onSlow func()) *Monitor {
+	return &Monitor{threshold: threshold, logger: logger, onSlow: onSlow}
+}
+
+// Wrap returns a connector whose connections time every statement.
+func (m *Monitor) Wrap(c driver.Connector) driver.Connector {
+	return &connector{Connector: c, m: m}
+}
+
+// observe logs query if it has been running since start for longer than the
+// threshold. Only the types of args are logged, since they can hold
+// passwords, tokens and savedata.
+func (m *Monitor) observe(query string, args []driver.NamedValue, start time.Time) {
+	d := time.Since(start)
+	if d < m.threshold {
+		return
+	}
+	if m.onSlow != nil {
+		m.onSlow()
+	}
+	m.logger.Warn("Slow query",
+		zap.Duration("duration", d),
+		zap.String("query", strings.Join(strings.Fields(query), " ")),
+		zap.Strings("args", redactArgs(args)),
+		zap.String("caller", caller()),
+	)
+}
+
+// redactArgs describes each argument by its type, and its length for
+// strings and byte slices.
+func redactArgs(args []driver.NamedValue) []string {
+	out := make([]string, len(args))
+	for i, a := range args {
+		switch v := a.Value.(type) {
+		case nil:
+			out[i] = "NULL"
+		case string:
+			out[i] = fmt.Sprintf("string(len=%d)", len(v))
+		case []byte:
+			out[i] = fmt.Sprintf("[]byte(len=%d)", len(v))
+		default:
+			out[i] = fmt.Sprintf("%T", v)
+		}
+	}
+	return out
+}
+
+// internalPackages are skipped when looking for the code that ran a query.
+var internalPackages = []string{
+	"runtime.",
+	"database/sql.",
+	"github.com/jmoiron/sqlx.",
+	"github.com/uptrace/opentelemetry-go-extra/otelsql.",
+	"erupe-ce/server/slowquery.",
+}
+
+// caller returns the first function on the stack outside the database
+// layers, usually a repository method.
+func caller() string {
+	pcs := make([]uintptr, 32)
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(2, pcs)])
+	for {
+		f, more := frames.Next()
+		if !isInternal(f) {
+			return fmt.Sprintf("%s (%s:%d)", f.Function, filepath.Base(f.File), f.Line)
+		}
+		if !more {
+			return "unknown"
+		}
+	}
+}
+
+func isInternal(f runtime.Frame) bool {
+	if strings.HasSuffix(f.File, "_test.go") {
+		return false
+	}
+	for _, p := range internalPackages {
+		if strings.HasPrefix(f.Function, p) {
+			return true
+		}
+	}
+	return false
+}
+
+type connector struct {
+	driver.Connector
+	m *Monitor
+}
+
+func (c *connector) Connect(ctx context.Context) (driver.Conn, error) {
+	cn, err := c.Connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &conn{Conn: cn, m: c.m}, nil
+}
+
+// conn times the statements run on a driver connection. Optional driver
+// interfaces are forwarded when the wrapped connection implements them.
+type conn struct {
+	driver.Conn
+	m *Monitor
+}
+
+func (c *conn) Prepare(query string) (driver.Stmt, error) {
+	return c.PrepareContext(context.Background(), query)
+}
+
+func (c *conn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	var st driver.Stmt
+	var err error
+	if p, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		st, err = p.PrepareContext(ctx, query)
+	} else {
+		st, err = c.Conn.Prepare(query)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &stmt{Stmt: st, query: query, m: c.m}, nil
+}
+
+func (c *conn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	q, ok := c.Conn.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	start := time.Now()
+	rows, err := q.QueryContext(ctx, query, args)
+	c.m.observe(query, args, start)
+	return rows, err
+}
+
+func (c *conn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	e, ok := c.Conn.(driver.ExecerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	start := time.Now()
+	res, err := e.ExecContext(ctx, query, args)
+	c.m.observe(query, args, start)
+	return res, err
+}
+
+func (c *conn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if b, ok := c.Conn.(driver.ConnBeginTx); ok {
+		return b.BeginTx(ctx, opts)
+	}
+	return c.Conn.Begin() //nolint:staticcheck // Fallback for drivers without BeginTx
+}
+
+func (c *conn) Ping(ctx context.Context) error {
+	if p, ok := c.Conn.(driver.Pinger); ok {
+		return p.Ping(ctx)
+	}
+	return nil
+}
+
+func (c *conn) ResetSession(ctx context.Context) error {
+	if r, ok := c.Conn.(driver.SessionResetter); ok {
+		return r.ResetSession(ctx)
+	}
+	return nil
+}
+
+func (c *conn) IsValid() bool {
+	if v, ok := c.Conn.(driver.Validator); ok {
+		return v.IsValid()
+	}
+	return true
+}
+
+// stmt times the executions of a prepared statement.
+type stmt struct {
+	driver.Stmt
+	query string
+	m     *Monitor
+}
+
+func (s *stmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	start := time.Now()
+	defer s.m.observe(s.query, args, start)
+	if e, ok := s.Stmt.(driver.StmtExecContext); ok {
+		return e.ExecContext(ctx, args)
+	}
+	values, err := namedValues(args)
+	if err != nil {
+		return nil, err
+	}
+	return s.Stmt.Exec(values) //nolint:staticcheck // Fallback for drivers without ExecContext
+}
+
+func (s *stmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	start := time.Now()
+	defer s.m.observe(s.query, args, start)
+	if q, ok := s.Stmt.(driver.StmtQueryContext); ok {
+		return q.QueryContext(ctx, args)
+	}
+	values, err := namedValues(args)
+	if err != nil {
+		return nil, err
+	}
+	return s.Stmt.Query(values) //nolint:staticcheck // Fallback for drivers without QueryContext
+}
+
+func namedValues(args []driver.NamedValue) ([]driver.Value, error) {
+	values := make([]driver.Value, len(args))
+	for i, a := range args {
+		if a.Name != "" {
+			return nil, fmt.Errorf("slowquery: driver does not support named parameter %q", a.Name)
+		}
+		values[i] = a.Value
+	}
+	return values, nil
+}
@@ -0,0 +1,108 @@
+package slowquery
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// fakeConnector hands out connections that take longer on statements
+// containing "pg_sleep".
+type fakeConnector struct{}
+
+func (fakeConnector) Connect(context.Context) (driver.Conn, error) { return fakeConn{}, nil }
+func (fakeConnector) Driver() driver.Driver                        { return nil }
+
+type fakeConn struct{}
+
+func (fakeConn) Prepare(string) (driver.Stmt, error) { return nil, driver.ErrSkip }
+func (fakeConn) Close() error                        { return nil }
+func (fakeConn) Begin() (driver.Tx, error)           { return nil, driver.ErrSkip }
+
+func (fakeConn) ExecContext(_ context.Context, query string, _ []driver.NamedValue) (driver.Result, error) {
+	if strings.Contains(query, "pg_sleep") {
+		time.Sleep(20 * time.Millisecond)
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func (fakeConn) QueryContext(_ context.Context, query string, _ []driver.NamedValue) (driver.Rows, error) {
+	if strings.Contains(query, "pg_sleep") {
+		time.Sleep(20 * time.Millisecond)
+	}
+	return fakeRows{}, nil
+}
+
+type fakeRows struct{}
+
+func (fakeRows) Columns() []string         { return []string{"n"} }
+func (fakeRows) Close() error              { return nil }
+func (fakeRows) Next([]driver.Value) error { return io.EOF }
+
+func openTestDB(t *testing.T) (*sql.DB, *observer.ObservedLogs, *int) {
+	core, logs := observer.New(zapcore.WarnLevel)
+	var slow int
+	m := New(10*time.Millisecond, zap.New(core), func() { slow++ })
+	db := sql.OpenDB(m.Wrap(fakeConnector{}))
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { _ = db.Close() })
+	return db, logs, &slow
+}
+
+func TestMonitor_LogsSlowStatements(t *testing.T) {
+	db, logs, slow := openTestDB(t)
+
+	if _, err := db.Exec("UPDATE characters SET name=$1 WHERE id=$2", "Fast", 1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec("SELECT pg_sleep(1)\n  FROM users WHERE password=$1", "hunter2"); err != nil {
+		t.Fatal(err)
+	}
+	rows, err := db.Query("SELECT pg_sleep(1)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = rows.Close()
+
+	if *slow != 2 {
+		t.Errorf("counted %d slow statements, want 2", *slow)
+	}
+	entries := logs.FilterMessage("Slow query").All()
+	if len(entries) != 2 {
+		t.Fatalf("logged %d slow statements, want 2", len(entries))
+	}
+
+	fields := entries[0].ContextMap()
+	if got := fields["query"]; got != "SELECT pg_sleep(1) FROM users WHERE password=$1" {
+		t.Errorf("query = %q, want it on one line", got)
+	}
+	if got := fields["args"]; len(got.([]interface{})) != 1 || got.([]interface{})[0] != "string(len=7)" {
+		t.Errorf("args = %v, want only the argument's type and length", got)
+	}
+	if got, _ := fields["caller"].(string); !strings.Contains(got, "TestMonitor_LogsSlowStatements") {
+		t.Errorf("caller = %q, want the test function", got)
+	}
+}
+
+func TestRedactArgs(t *testing.T) {
+	got := redactArgs([]driver.NamedValue{
+		{Value: nil},
+		{Value: int64(5)},
+		{Value: []byte{1, 2, 3}},
+		{Value: time.Time{}},
+	})
+	want := []string{"NULL", "int64", "[]byte(len=3)", "time.Time"}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("redactArgs()[%d] = %q, want %q", i, got[i], want[i])
+		}
+	}
+}
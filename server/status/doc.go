@@ -0,0 +1,4 @@
+// Package status collects a snapshot of live server state (per-world
+// populations and scheduled events) shared by the API status endpoint and
+// the Discord bot.
+package status
@@ -0,0 +1,31 @@
+package status
+
+import (
+	"github.com/jmoiron/sqlx"
+)
+
+// Repository reads server status from the servers and events tables.
+type Repository struct {
+	db *sqlx.DB
+}
+
+// NewRepository creates a new Repository.
+func NewRepository(db *sqlx.DB) *Repository {
+	return &Repository{db: db}
+}
+
+// Worlds returns the player count of each world, summed over its channels.
+func (r *Repository) Worlds() ([]World, error) {
+	var worlds []World
+	err := r.db.Select(&worlds, `SELECT COALESCE(world_name, '') AS name, COALESCE(world_description, '') AS description,
+		COUNT(*) AS channels, COALESCE(SUM(current_players), 0) AS players
+		FROM servers GROUP BY world_name, world_description ORDER BY MIN(server_id)`)
+	return worlds, err
+}
+
+// Events returns every scheduled event.
+func (r *Repository) Events() ([]Event, error) {
+	var events []Event
+	err := r.db.Select(&events, `SELECT event_type AS type, start_time AS start FROM events`)
+	return events, err
+}
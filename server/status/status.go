@@ -0,0 +1,106 @@
+package status
+
+import (
+	"sort"
+	"time"
+)
+
+// World is the population of one entrance server world.
+type World struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Channels    int    `json:"channels"`
+	Players     int    `json:"players"`
+}
+
+// Event is a scheduled event from the events table.
+type Event struct {
+	Type  string    `json:"type"`
+	Name  string    `json:"name"`
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+// Snapshot is the server status at a point in time.
+type Snapshot struct {
+	Time     time.Time `json:"time"`
+	Players  int       `json:"players"`
+	Worlds   []World   `json:"worlds"`
+	Active   []Event   `json:"active_events"`
+	Upcoming []Event   `json:"upcoming_events"`
+}
+
+// Source provides the data a Snapshot is built from.
+type Source interface {
+	Worlds() ([]World, error)
+	Events() ([]Event, error)
+}
+
+var eventNames = map[string]string{
+	"festa":  "Hunting Festival",
+	"diva":   "Diva Defense",
+	"vs":     "VS Tournament",
+	"mezfes": "MezFes",
+}
+
+// eventDurations is how long each type of event runs from its start. A
+// festival or Diva Defense row is only replaced once a player asks for the
+// next one, so its row outlives it; these match the channel server's
+// festaEventLifespan and divaTotalLifespan.
+var eventDurations = map[string]time.Duration{
+	"festa":  2977200 * time.Second,
+	"diva":   2977200 * time.Second,
+	"vs":     7 * 24 * time.Hour,
+	"mezfes": 7 * 24 * time.Hour,
+}
+
+// defaultEventDuration is the duration of event types not listed above.
+const defaultEventDuration = 7 * 24 * time.Hour
+
+// EventDuration returns how long an event type runs.
+func EventDuration(eventType string) time.Duration {
+	if d, ok := eventDurations[eventType]; ok {
+		return d
+	}
+	return defaultEventDuration
+}
+
+// EventName returns the display name of an event type.
+func EventName(eventType string) string {
+	if name, ok := eventNames[eventType]; ok {
+		return name
+	}
+	return eventType
+}
+
+// Collect builds a Snapshot from src, splitting events into those running
+// now and those scheduled after now. Events that have ended are left out.
+func Collect(src Source, now time.Time) (Snapshot, error) {
+	snap := Snapshot{Time: now}
+	worlds, err := src.Worlds()
+	if err != nil {
+		return snap, err
+	}
+	events, err := src.Events()
+	if err != nil {
+		return snap, err
+	}
+	snap.Worlds = worlds
+	for _, w := range worlds {
+		snap.Players += w.Players
+	}
+	sort.Slice(events, func(i, j int) bool { return events[i].Start.Before(events[j].Start) })
+	for _, e := range events {
+		e.Name = EventName(e.Type)
+		e.End = e.Start.Add(EventDuration(e.Type))
+		if !now.Before(e.End) {
+			continue
+		}
+		if e.Start.After(now) {
+			snap.Upcoming = append(snap.Upcoming, e)
+		} else {
+			snap.Active = append(snap.Active, e)
+		}
+	}
+	return snap, nil
+}
@@ -0,0 +1,61 @@
+package status
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+type fakeSource struct {
+	worlds []World
+	events []Event
+	err    error
+}
+
+func (f *fakeSource) Worlds() ([]World, error) { return f.worlds, f.err }
+func (f *fakeSource) Events() ([]Event, error) { return f.events, nil }
+
+func TestCollect(t *testing.T) {
+	now := time.Date(2024, 1, 10, 0, 0, 0, 0, time.UTC)
+	src := &fakeSource{
+		worlds: []World{
+			{Name: "Newbie", Channels: 2, Players: 3},
+			{Name: "Normal", Channels: 2, Players: 4},
+		},
+		events: []Event{
+			{Type: "diva", Start: now.Add(48 * time.Hour)},
+			{Type: "festa", Start: now.Add(-24 * time.Hour)},
+			{Type: "diva", Start: now.Add(-40 * 24 * time.Hour)}, // Ended, though its row remains
+			{Type: "mezfes", Start: now.Add(24 * time.Hour)},
+		},
+	}
+
+	snap, err := Collect(src, now)
+	if err != nil {
+		t.Fatalf("Collect() error = %v", err)
+	}
+	if snap.Players != 7 {
+		t.Errorf("Players = %d, want 7", snap.Players)
+	}
+	if len(snap.Active) != 1 || snap.Active[0].Name != "Hunting Festival" {
+		t.Errorf("Active = %+v, want the festival", snap.Active)
+	}
+	if want := now.Add(-24*time.Hour + EventDuration("festa")); len(snap.Active) == 1 && !snap.Active[0].End.Equal(want) {
+		t.Errorf("festival ends %v, want %v", snap.Active[0].End, want)
+	}
+	if len(snap.Upcoming) != 2 || snap.Upcoming[0].Type != "mezfes" || snap.Upcoming[1].Type != "diva" {
+		t.Errorf("Upcoming = %+v, want mezfes then diva", snap.Upcoming)
+	}
+}
+
+func TestCollectError(t *testing.T) {
+	if _, err := Collect(&fakeSource{err: errors.New("db error")}, time.Now()); err == nil {
+		t.Error("Collect() should return the source error")
+	}
+}
+
+func TestEventName(t *testing.T) {
+	if got := EventName("unknown"); got != "unknown" {
+		t.Errorf("EventName(unknown) = %q", got)
+	}
+}
@@ -0,0 +1,3 @@
+// Package tracing configures OpenTelemetry span export from the Tracing
+// config section and opens databases whose queries are traced.
+package tracing
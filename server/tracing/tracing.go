@@ -0,0 +1,59 @@
+package tracing
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"os"
+
+	cfg "erupe-ce/config"
+
+	"github.com/uptrace/opentelemetry-go-extra/otelsql"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// Setup installs the tracer provider described by c as the global provider
+// and returns a function that flushes pending spans and stops it. When
+// tracing is disabled the global no-op provider is left in place.
+func Setup(ctx context.Context, c cfg.TracingOptions) (func(context.Context) error, error) {
+	if !c.Enabled {
+		return func(context.Context) error { return nil }, nil
+	}
+	exporter, err := newExporter(ctx, c)
+	if err != nil {
+		return nil, err
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(c.SampleRatio))),
+		sdktrace.WithResource(resource.NewSchemaless(attribute.String("service.name", c.ServiceName))),
+	)
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
+
+func newExporter(ctx context.Context, c cfg.TracingOptions) (sdktrace.SpanExporter, error) {
+	switch c.Exporter {
+	case "otlp":
+		opts := []otlptracehttp.Option{otlptracehttp.WithEndpoint(c.Endpoint)}
+		if c.Insecure {
+			opts = append(opts, otlptracehttp.WithInsecure())
+		}
+		return otlptracehttp.New(ctx, opts...)
+	case "stdout":
+		return stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+	default:
+		return nil, fmt.Errorf("unknown tracing exporter %q", c.Exporter)
+	}
+}
+
+// OpenDB opens a database on c, recording a span for every query.
+func OpenDB(c driver.Connector) *sql.DB {
+	return otelsql.OpenDB(c, otelsql.WithDBSystem("postgresql"))
+}
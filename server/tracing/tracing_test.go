@@ -0,0 +1,40 @@
+package tracing
+
+import (
+	"context"
+	"testing"
+
+	cfg "erupe-ce/config"
+)
+
+func TestSetup_Disabled(t *testing.T) {
+	stop, err := Setup(context.Background(), cfg.TracingOptions{})
+	if err != nil {
+		t.Fatalf("Setup() error = %v", err)
+	}
+	if err := stop(context.Background()); err != nil {
+		t.Errorf("stop() error = %v", err)
+	}
+}
+
+func TestSetup_Stdout(t *testing.T) {
+	stop, err := Setup(context.Background(), cfg.TracingOptions{
+		Enabled:     true,
+		Exporter:    "stdout",
+		SampleRatio: 1,
+		ServiceName: "erupe-test",
+	})
+	if err != nil {
+		t.Fatalf("Setup() error = %v", err)
+	}
+	if err := stop(context.Background()); err != nil {
+		t.Errorf("stop() error = %v", err)
+	}
+}
+
+func TestSetup_UnknownExporter(t *testing.T) {
+	_, err := Setup(context.Background(), cfg.TracingOptions{Enabled: true, Exporter: "zipkin"})
+	if err == nil {
+		t.Error("Setup() with an unknown exporter succeeded")
+	}
+}